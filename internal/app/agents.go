@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+)
+
+// AgentName returns the name of the agent the user talks to.
+func (app *App) AgentName() string {
+	app.agentsMu.Lock()
+	defer app.agentsMu.Unlock()
+	return app.agentName
+}
+
+// SwitchAgent makes the named main agent the one the user talks to, creating
+// it the first time. The sessions are kept, the agent picks them up on the
+// next prompt.
+func (app *App) SwitchAgent(name string) error {
+	cfg := config.Get()
+	if !cfg.IsMainAgent(name) {
+		return fmt.Errorf("unknown agent %q, expected one of %s", name, strings.Join(cfg.MainAgents(), ", "))
+	}
+
+	app.agentsMu.Lock()
+	defer app.agentsMu.Unlock()
+	if app.CoderAgent != nil && app.CoderAgent.IsBusy() {
+		return agent.ErrSessionBusy
+	}
+	next, ok := app.agents[name]
+	if !ok {
+		var err error
+		next, err = app.newMainAgent(name)
+		if err != nil {
+			return err
+		}
+		app.agents[name] = next
+	}
+	app.CoderAgent = next
+	app.agentName = name
+	return nil
+}
+
+// newMainAgent creates the named main agent, sending its events to the TUI
+// and webhooks.
+func (app *App) newMainAgent(name string) (agent.Service, error) {
+	// The config may have been reloaded since the app started.
+	agentCfg := config.Get().Agents[name]
+	if agentCfg.ID == "" {
+		return nil, fmt.Errorf("%s agent configuration is missing", name)
+	}
+	a, err := agent.NewAgent(
+		app.globalCtx,
+		agentCfg,
+		app.Permissions,
+		app.Sessions,
+		app.Messages,
+		app.History,
+		app.LSPClients,
+		app.agentOpts...,
+	)
+	if err != nil {
+		slog.Error("Failed to create agent", "agent", name, "err", err)
+		return nil, err
+	}
+	setupSubscriber(app.eventsCtx, app.serviceEventsWG, name+"Agent", a.Subscribe, app.events)
+	setupSubscriber(app.eventsCtx, app.serviceEventsWG, name+"Agent-progress", a.SubscribeProgress, app.events)
+	if app.webhooks != nil {
+		app.webhooks.WatchAgent(app.eventsCtx, a)
+	}
+	return a, nil
+}
+
+// mainAgents returns the main agents created so far.
+func (app *App) mainAgents() []agent.Service {
+	app.agentsMu.Lock()
+	defer app.agentsMu.Unlock()
+	return slices.Collect(maps.Values(app.agents))
+}
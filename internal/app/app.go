@@ -2,11 +2,12 @@ package app
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"log/slog"
 	"maps"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
@@ -15,8 +16,10 @@ import (
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/format"
+	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/pubsub"
 
@@ -24,6 +27,8 @@ import (
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tokenizer"
+	"github.com/charmbracelet/crush/internal/webhook"
 )
 
 type App struct {
@@ -32,7 +37,13 @@ type App struct {
 	History     history.Service
 	Permissions permission.Service
 
+	// CoderAgent is the agent the user talks to, the coder agent or the
+	// custom agent switched to, see agents.go.
 	CoderAgent agent.Service
+	agentName  string
+	// agents are the main agents created so far, by name.
+	agents   map[string]agent.Service
+	agentsMu sync.Mutex
 
 	LSPClients map[string]*lsp.Client
 
@@ -42,6 +53,10 @@ type App struct {
 	lspWatcherWG       sync.WaitGroup
 
 	config *config.Config
+	// agentOpts are the options of the coder agent, kept to recreate it.
+	agentOpts []agent.Option
+	// webhooks receive session events, nil when none are configured.
+	webhooks *webhook.Dispatcher
 
 	serviceEventsWG *sync.WaitGroup
 	eventsCtx       context.Context
@@ -54,27 +69,38 @@ type App struct {
 }
 
 // New initializes a new applcation instance.
-func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
-	q := db.New(conn)
-	sessions := session.NewService(q)
-	messages := message.NewService(q)
-	files := history.NewService(q, conn)
+func New(ctx context.Context, store db.Store, cfg *config.Config, agentOpts ...agent.Option) (*App, error) {
+	sessions := session.NewService(store)
+	messages := message.NewService(store)
+	files := history.NewService(store)
 	skipPermissionsRequests := cfg.Permissions != nil && cfg.Permissions.SkipRequests
 	allowedTools := []string{}
 	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
 		allowedTools = cfg.Permissions.AllowedTools
 	}
+	allowDestructive := cfg.Permissions != nil && cfg.Permissions.AllowDestructive
+	permissionOpts := []permission.Option{
+		permission.WithAuditLog(filepath.Join(cfg.Options.DataDirectory, permission.AuditFile)),
+		permission.WithGrants(permission.NewGrants(cfg.Options.DataDirectory)),
+	}
+	if cfg.Permissions != nil {
+		if err := permission.ValidateRules(cfg.Permissions.Rules); err != nil {
+			return nil, err
+		}
+		permissionOpts = append(permissionOpts, permission.WithRules(cfg.Permissions.Rules))
+	}
 
 	app := &App{
 		Sessions:    sessions,
 		Messages:    messages,
 		History:     files,
-		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
+		Permissions: permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools, allowDestructive, permissionOpts...),
 		LSPClients:  make(map[string]*lsp.Client),
 
 		globalCtx: ctx,
 
-		config: cfg,
+		config:    cfg,
+		agentOpts: agentOpts,
 
 		watcherCancelFuncs: csync.NewSlice[context.CancelFunc](),
 
@@ -84,6 +110,17 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	}
 
 	app.setupEvents()
+	if err := app.setupWebhooks(); err != nil {
+		return nil, err
+	}
+
+	// The turns interrupted when Crush was killed or crashed are marked as
+	// such before any turn runs, for their sessions to be continued.
+	if turns, err := agent.RecoverInterrupted(ctx, cfg.Options.DataDirectory, sessions, messages); err != nil {
+		slog.Error("Failed to recover the interrupted turns", "error", err)
+	} else if len(turns) > 0 {
+		slog.Info("Found interrupted turns", "count", len(turns))
+	}
 
 	// Initialize LSP clients in the background.
 	app.initLSPClients(ctx)
@@ -96,9 +133,56 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	} else {
 		slog.Warn("No agent configuration found")
 	}
+
+	app.watchConfig(ctx)
+	app.watchFiles(ctx)
+	app.removeOutputs(ctx)
+	app.downloadTokenizers(ctx)
 	return app, nil
 }
 
+// removeOutputs stops the background jobs of the sessions once they're
+// deleted, and deletes the saved outputs of their tool calls.
+func (app *App) removeOutputs(ctx context.Context) {
+	events := app.Sessions.Subscribe(ctx)
+	go func() {
+		for event := range events {
+			if event.Type != pubsub.DeletedEvent {
+				continue
+			}
+			tools.KillSessionJobs(event.Payload.ID)
+			if err := tools.RemoveOutputs(event.Payload.ID); err != nil {
+				slog.Warn("Failed to remove the outputs of the session", "session_id", event.Payload.ID, "error", err)
+			}
+		}
+	}()
+}
+
+// downloadTokenizers downloads the missing BPE encodings in the background
+// when the config asks to, so the tokens of the OpenAI models are counted
+// exactly from then on.
+func (app *App) downloadTokenizers(ctx context.Context) {
+	if cfg := app.config.Options.Tokenizers; cfg == nil || !cfg.Download {
+		return
+	}
+	go func() {
+		if err := tokenizer.Download(ctx, tokenizer.Dir()); err != nil {
+			slog.Warn("Failed to download the tokenizers", "error", err)
+		}
+	}()
+}
+
+// watchFiles keeps the list of the files of the project up to date, for the
+// directory listings of the prompt, the tools, and the completions not to
+// walk the project every time.
+func (app *App) watchFiles(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	app.cleanupFuncs = append(app.cleanupFuncs, cancel)
+	if _, err := fsext.Watch(ctx, app.config.WorkingDir()); err != nil {
+		slog.Error("Failed to watch the project files", "error", err)
+	}
+}
+
 // Config returns the application configuration.
 func (app *App) Config() *config.Config {
 	return app.config
@@ -128,18 +212,7 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 	defer stopSpinner()
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
-
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
-	}
-	title := titlePrefix + titleSuffix
-
-	sess, err := app.Sessions.Create(ctx, title)
+	sess, err := app.Sessions.Create(ctx, nonInteractiveTitle(prompt))
 	if err != nil {
 		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
 	}
@@ -148,7 +221,11 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	// Automatically approve all permission requests for this non-interactive session
 	app.Permissions.AutoApproveSession(sess.ID)
 
-	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt)
+	attachments, err := message.MentionedAttachments(prompt, app.config.WorkingDir())
+	if err != nil {
+		return err
+	}
+	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt, attachments...)
 	if err != nil {
 		return fmt.Errorf("failed to start agent processing stream: %w", err)
 	}
@@ -175,6 +252,12 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 				return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(msgContent), readBts)
 			}
 			fmt.Println(msgContent[readBts:])
+			if citations := result.Message.Citations(); len(citations) > 0 {
+				fmt.Println("\nSources:")
+				for i, c := range citations {
+					fmt.Printf("%d. %s\n", i+1, c.String())
+				}
+			}
 
 			slog.Info("Non-interactive: run completed", "session_id", sess.ID)
 			return nil
@@ -195,6 +278,15 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 }
 
+// nonInteractiveTitle returns the title of the sessions of single prompts.
+func nonInteractiveTitle(prompt string) string {
+	const maxPromptLengthForTitle = 100
+	if len(prompt) > maxPromptLengthForTitle {
+		prompt = prompt[:maxPromptLengthForTitle] + "..."
+	}
+	return "Non-interactive: " + prompt
+}
+
 func (app *App) UpdateAgentModel() error {
 	return app.CoderAgent.UpdateModel()
 }
@@ -207,6 +299,7 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions", app.Permissions.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "permissions-notifications", app.Permissions.SubscribeNotifications, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "mcp", agent.SubscribeMCPStates, app.events)
 	cleanupFunc := func() {
 		cancel()
 		app.serviceEventsWG.Wait()
@@ -214,6 +307,23 @@ func (app *App) setupEvents() {
 	app.cleanupFuncs = append(app.cleanupFuncs, cleanupFunc)
 }
 
+// setupWebhooks posts session events to the webhooks of the config.
+func (app *App) setupWebhooks() error {
+	dispatcher, err := webhook.New(app.config, app.Sessions, app.Messages)
+	if err != nil {
+		return fmt.Errorf("failed to set up webhooks: %w", err)
+	}
+	if dispatcher == nil {
+		return nil
+	}
+	app.webhooks = dispatcher
+	app.webhooks.Watch(app.eventsCtx, app.Permissions)
+	app.cleanupFuncs = append(app.cleanupFuncs, func() {
+		app.webhooks.Close(10 * time.Second)
+	})
+	return nil
+}
+
 func setupSubscriber[T any](
 	ctx context.Context,
 	wg *sync.WaitGroup,
@@ -250,25 +360,15 @@ func setupSubscriber[T any](
 }
 
 func (app *App) InitCoderAgent() error {
-	coderAgentCfg := app.config.Agents["coder"]
-	if coderAgentCfg.ID == "" {
-		return fmt.Errorf("coder agent configuration is missing")
-	}
-	var err error
-	app.CoderAgent, err = agent.NewAgent(
-		app.globalCtx,
-		coderAgentCfg,
-		app.Permissions,
-		app.Sessions,
-		app.Messages,
-		app.History,
-		app.LSPClients,
-	)
+	coderAgent, err := app.newMainAgent("coder")
 	if err != nil {
-		slog.Error("Failed to create coder agent", "err", err)
 		return err
 	}
-	setupSubscriber(app.eventsCtx, app.serviceEventsWG, "coderAgent", app.CoderAgent.Subscribe, app.events)
+	app.agentsMu.Lock()
+	defer app.agentsMu.Unlock()
+	app.CoderAgent = coderAgent
+	app.agentName = "coder"
+	app.agents = map[string]agent.Service{"coder": coderAgent}
 	return nil
 }
 
@@ -305,8 +405,16 @@ func (app *App) Subscribe(program *tea.Program) {
 
 // Shutdown performs a graceful shutdown of the application.
 func (app *App) Shutdown() {
-	if app.CoderAgent != nil {
-		app.CoderAgent.CancelAll()
+	app.agentsMu.Lock()
+	for _, a := range app.agents {
+		a.CancelAll()
+	}
+	agents := slices.Collect(maps.Values(app.agents))
+	app.agentsMu.Unlock()
+
+	// Run the session_end hooks of the sessions worked on.
+	for _, a := range agents {
+		a.EndSessions(app.globalCtx)
 	}
 
 	for cancel := range app.watcherCancelFuncs.Seq() {
@@ -331,6 +439,20 @@ func (app *App) Shutdown() {
 		cancel()
 	}
 
+	// Stop the MCP servers.
+	agent.CloseMCPServers()
+
+	// Stop the background commands of the bash tool and the terminals.
+	tools.KillJobs()
+	tools.CloseTerminals()
+
+	// Close the browser of the browser tool.
+	agent.CloseBrowser()
+
+	// Stop indexing the project.
+	agent.CloseCodeSearch()
+	agent.CloseMemory()
+
 	// Call call cleanup functions.
 	for _, cleanup := range app.cleanupFuncs {
 		if cleanup != nil {
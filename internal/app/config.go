@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// ConfigReloadedMsg is sent to the TUI when the config was reloaded after it
+// changed on disk, or failed to.
+type ConfigReloadedMsg struct {
+	Err error
+}
+
+// watchConfig reloads the config when its files or the providers cache
+// change. Changes to providers, models, and options apply from the next turn,
+// LSPs and MCPs still need a restart.
+func (app *App) watchConfig(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	app.cleanupFuncs = append(app.cleanupFuncs, cancel)
+
+	changes := make(chan struct{}, 1)
+	err := config.Watch(ctx, app.config.WorkingDir(), func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		slog.Error("Failed to watch config", "error", err)
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case <-changes:
+				app.reloadConfig(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (app *App) reloadConfig(ctx context.Context) {
+	// Swapping providers mid-turn would mix models in the same turn, so wait
+	// for the agent to finish.
+	for app.CoderAgent != nil && app.CoderAgent.IsBusy() {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	_, changed, err := config.Reload()
+	if err != nil {
+		slog.Error("Failed to reload config", "error", err)
+		app.sendEvent(ctx, ConfigReloadedMsg{Err: err})
+		return
+	}
+	if !changed {
+		return
+	}
+	slog.Info("Config reloaded")
+
+	for _, a := range app.mainAgents() {
+		if err := a.Reload(); err != nil {
+			slog.Error("Failed to apply reloaded config to agent", "error", err)
+			app.sendEvent(ctx, ConfigReloadedMsg{Err: err})
+			return
+		}
+	}
+	app.sendEvent(ctx, ConfigReloadedMsg{})
+}
+
+func (app *App) sendEvent(ctx context.Context, msg tea.Msg) {
+	select {
+	case app.events <- msg:
+	case <-time.After(2 * time.Second):
+		slog.Warn("message dropped due to slow consumer", "msg", msg)
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Types of the events of headless runs.
+const (
+	HeadlessSession    = "session"
+	HeadlessText       = "text"
+	HeadlessToolCall   = "tool_call"
+	HeadlessToolResult = "tool_result"
+	HeadlessDiff       = "diff"
+	HeadlessUsage      = "usage"
+	HeadlessResult     = "result"
+)
+
+// Statuses of the result of headless runs.
+const (
+	HeadlessSucceeded = "succeeded"
+	HeadlessFailed    = "failed"
+	HeadlessCancelled = "cancelled"
+)
+
+// HeadlessEvent is a line of the JSONL output of headless runs. Only the
+// fields of its type are set.
+type HeadlessEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id,omitempty"`
+	// Text is a chunk of the answer of the model for text events, and the
+	// whole answer for the result.
+	MessageID string `json:"message_id,omitempty"`
+	Text      string `json:"text,omitempty"`
+	// Tool calls and their results.
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Input      json.RawMessage `json:"input,omitempty"`
+	Content    string          `json:"content,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+	// Changes of the edit and write tools.
+	Path      string `json:"path,omitempty"`
+	Diff      string `json:"diff,omitempty"`
+	Additions int    `json:"additions,omitempty"`
+	Removals  int    `json:"removals,omitempty"`
+	// Usage of the session so far, for usage events and the result.
+	Usage *HeadlessUsageTotals `json:"usage,omitempty"`
+	// Status and error of the result.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HeadlessUsageTotals are the tokens and dollars spent by a session.
+type HeadlessUsageTotals struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// RunHeadless runs a single prompt, writing its progress to w as JSONL
+// events and ending with a result event. It returns the error of the run,
+// context.Canceled when it was interrupted.
+func (app *App) RunHeadless(ctx context.Context, prompt string, w io.Writer) error {
+	slog.Info("Running in headless mode")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sess, err := app.Sessions.Create(ctx, nonInteractiveTitle(prompt))
+	if err != nil {
+		return fmt.Errorf("failed to create session for headless mode: %w", err)
+	}
+	app.Permissions.AutoApproveSession(sess.ID)
+
+	e := newHeadlessEmitter(w, sess.ID)
+	e.emit(HeadlessEvent{Type: HeadlessSession})
+
+	// Subscribe before running, not to miss the first messages.
+	messageEvents := app.Messages.Subscribe(ctx)
+	sessionEvents := app.Sessions.Subscribe(ctx)
+	done, err := app.CoderAgent.Run(ctx, sess.ID, prompt)
+	if err != nil {
+		e.result(sess, nil, err)
+		return fmt.Errorf("failed to start agent processing stream: %w", err)
+	}
+
+	for {
+		select {
+		case result := <-done:
+			// Usage is saved before the run is done.
+			if updated, err := app.Sessions.Get(context.WithoutCancel(ctx), sess.ID); err == nil {
+				sess = updated
+			}
+			if result.Error != nil {
+				e.result(sess, nil, result.Error)
+				return result.Error
+			}
+			e.message(result.Message)
+			e.result(sess, &result.Message, nil)
+			return nil
+		case event := <-messageEvents:
+			e.message(event.Payload)
+		case event := <-sessionEvents:
+			e.session(event.Payload)
+		case <-ctx.Done():
+			e.result(sess, nil, ctx.Err())
+			return ctx.Err()
+		}
+	}
+}
+
+// headlessEmitter turns the updates of the messages of a session into
+// events, each tool call and result once.
+type headlessEmitter struct {
+	enc       *json.Encoder
+	sessionID string
+	// written is the length of the text of each message already written.
+	written map[string]int
+	calls   map[string]message.ToolCall
+	results map[string]bool
+	usage   HeadlessUsageTotals
+}
+
+func newHeadlessEmitter(w io.Writer, sessionID string) *headlessEmitter {
+	return &headlessEmitter{
+		enc:       json.NewEncoder(w),
+		sessionID: sessionID,
+		written:   map[string]int{},
+		calls:     map[string]message.ToolCall{},
+		results:   map[string]bool{},
+	}
+}
+
+func (e *headlessEmitter) emit(event HeadlessEvent) {
+	event.SessionID = e.sessionID
+	if err := e.enc.Encode(event); err != nil {
+		slog.Error("Failed to write headless event", "type", event.Type, "error", err)
+	}
+}
+
+func (e *headlessEmitter) message(msg message.Message) {
+	if msg.SessionID != e.sessionID {
+		return
+	}
+	switch msg.Role {
+	case message.Assistant:
+		text := msg.Content().Text
+		if written := e.written[msg.ID]; len(text) > written {
+			e.emit(HeadlessEvent{Type: HeadlessText, MessageID: msg.ID, Text: text[written:]})
+			e.written[msg.ID] = len(text)
+		}
+		for _, call := range msg.ToolCalls() {
+			if _, ok := e.calls[call.ID]; ok || !call.Finished {
+				continue
+			}
+			e.calls[call.ID] = call
+			e.emit(HeadlessEvent{Type: HeadlessToolCall, MessageID: msg.ID, ToolCallID: call.ID, Name: call.Name, Input: rawInput(call.Input)})
+		}
+	case message.Tool:
+		for _, result := range msg.ToolResults() {
+			if e.results[result.ToolCallID] {
+				continue
+			}
+			e.results[result.ToolCallID] = true
+			e.emit(HeadlessEvent{Type: HeadlessToolResult, ToolCallID: result.ToolCallID, Name: result.Name, Content: result.Content, IsError: result.IsError})
+			if !result.IsError {
+				e.diff(e.calls[result.ToolCallID], result)
+			}
+		}
+	}
+}
+
+// changeSetTools change several files at once, their results list them.
+var changeSetTools = []string{
+	tools.ApplyPatchToolName,
+	tools.TransactionToolName,
+	tools.RenameToolName,
+	tools.CodeActionToolName,
+}
+
+// diff writes the change of the file of edit and write tool calls, and of
+// every file of patches, transactions, and refactorings.
+func (e *headlessEmitter) diff(call message.ToolCall, result message.ToolResult) {
+	if slices.Contains(changeSetTools, call.Name) {
+		var meta tools.ChangeSetResponseMetadata
+		if err := json.Unmarshal([]byte(result.Metadata), &meta); err != nil {
+			return
+		}
+		for _, f := range meta.Files {
+			event := HeadlessEvent{Type: HeadlessDiff, ToolCallID: call.ID, Path: f.FilePath}
+			event.Diff, event.Additions, event.Removals = diff.GenerateDiff(f.OldContent, f.NewContent, f.FilePath)
+			e.emit(event)
+		}
+		return
+	}
+	var params struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil || params.FilePath == "" {
+		return
+	}
+	event := HeadlessEvent{Type: HeadlessDiff, ToolCallID: call.ID, Path: params.FilePath}
+	switch call.Name {
+	case tools.EditToolName, tools.MultiEditToolName:
+		var meta tools.EditResponseMetadata
+		if err := json.Unmarshal([]byte(result.Metadata), &meta); err != nil {
+			return
+		}
+		event.Diff, event.Additions, event.Removals = diff.GenerateDiff(meta.OldContent, meta.NewContent, params.FilePath)
+	case tools.WriteToolName:
+		var meta tools.WriteResponseMetadata
+		if err := json.Unmarshal([]byte(result.Metadata), &meta); err != nil {
+			return
+		}
+		event.Diff, event.Additions, event.Removals = meta.Diff, meta.Additions, meta.Removals
+	default:
+		return
+	}
+	e.emit(event)
+}
+
+// session writes the usage when the session spent more.
+func (e *headlessEmitter) session(sess session.Session) {
+	if sess.ID != e.sessionID {
+		return
+	}
+	usage := usageOf(sess)
+	if usage == e.usage {
+		return
+	}
+	e.usage = usage
+	e.emit(HeadlessEvent{Type: HeadlessUsage, Usage: &usage})
+}
+
+// result writes the last event, with the answer of the model or the error.
+func (e *headlessEmitter) result(sess session.Session, msg *message.Message, err error) {
+	usage := usageOf(sess)
+	event := HeadlessEvent{Type: HeadlessResult, Status: HeadlessSucceeded, Usage: &usage}
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, agent.ErrRequestCancelled):
+		event.Status = HeadlessCancelled
+	case err != nil:
+		event.Status = HeadlessFailed
+		event.Error = err.Error()
+	}
+	if msg != nil {
+		event.MessageID = msg.ID
+		event.Text = msg.Content().Text
+	}
+	e.emit(event)
+}
+
+func usageOf(sess session.Session) HeadlessUsageTotals {
+	return HeadlessUsageTotals{
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+	}
+}
+
+// rawInput returns the input of a tool call as JSON, as a string when the
+// model wrote invalid JSON.
+func rawInput(input string) json.RawMessage {
+	input = cmp.Or(input, "{}")
+	if json.Valid([]byte(input)) {
+		return json.RawMessage(input)
+	}
+	quoted, _ := json.Marshal(input)
+	return quoted
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeEvents(t *testing.T, data []byte) []HeadlessEvent {
+	t.Helper()
+	var events []HeadlessEvent
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var event HeadlessEvent
+		require.NoError(t, dec.Decode(&event))
+		require.Equal(t, "s1", event.SessionID)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestHeadlessEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	e := newHeadlessEmitter(&buf, "s1")
+
+	call := message.ToolCall{ID: "call_1", Name: tools.EditToolName, Input: `{"file_path": "main.go", "old_string": "a", "new_string": "b"}`}
+	assistant := message.Message{ID: "m1", SessionID: "s1", Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "Let me"}}}
+	e.message(assistant)
+	assistant.Parts = []message.ContentPart{message.TextContent{Text: "Let me fix it."}, call}
+	e.message(assistant)
+	call.Finished = true
+	assistant.Parts[1] = call
+	e.message(assistant)
+	e.message(assistant)
+	// Sub-agents run in their own sessions.
+	e.message(message.Message{ID: "m2", SessionID: "s2", Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: "other"}}})
+
+	meta, err := json.Marshal(tools.EditResponseMetadata{OldContent: "package a\n", NewContent: "package b\n"})
+	require.NoError(t, err)
+	result := message.Message{ID: "m3", SessionID: "s1", Role: message.Tool, Parts: []message.ContentPart{
+		message.ToolResult{ToolCallID: "call_1", Name: tools.EditToolName, Content: "edited", Metadata: string(meta)},
+	}}
+	e.message(result)
+	e.message(result)
+
+	sess := session.Session{ID: "s1", PromptTokens: 100, CompletionTokens: 20, Cost: 0.01}
+	e.session(sess)
+	e.session(sess)
+	e.result(sess, &assistant, nil)
+
+	events := decodeEvents(t, buf.Bytes())
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []string{HeadlessText, HeadlessText, HeadlessToolCall, HeadlessToolResult, HeadlessDiff, HeadlessUsage, HeadlessResult}, types)
+	require.Equal(t, " fix it.", events[1].Text)
+	require.JSONEq(t, call.Input, string(events[2].Input))
+	require.Equal(t, "edited", events[3].Content)
+	require.Equal(t, "main.go", events[4].Path)
+	require.Equal(t, 1, events[4].Additions)
+	require.Contains(t, events[4].Diff, "+package b")
+	require.Equal(t, &HeadlessUsageTotals{PromptTokens: 100, CompletionTokens: 20, Cost: 0.01}, events[5].Usage)
+	require.Equal(t, HeadlessSucceeded, events[6].Status)
+	require.Equal(t, "Let me fix it.", events[6].Text)
+}
+
+func TestHeadlessEmitter_Result(t *testing.T) {
+	var buf bytes.Buffer
+	e := newHeadlessEmitter(&buf, "s1")
+	e.result(session.Session{ID: "s1"}, nil, errors.New("provider unavailable"))
+	e.result(session.Session{ID: "s1"}, nil, context.Canceled)
+
+	events := decodeEvents(t, buf.Bytes())
+	require.Equal(t, HeadlessFailed, events[0].Status)
+	require.Equal(t, "provider unavailable", events[0].Error)
+	require.Equal(t, HeadlessCancelled, events[1].Status)
+	require.Empty(t, events[1].Error)
+}
@@ -5,25 +5,38 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/install"
 	"github.com/charmbracelet/crush/internal/lsp/watcher"
 )
 
 // initLSPClients initializes LSP clients.
 func (app *App) initLSPClients(ctx context.Context) {
 	for name, clientConfig := range app.config.LSP {
-		go app.createAndStartLSPClient(ctx, name, clientConfig.Command, clientConfig.Args...)
+		if clientConfig.Disabled {
+			continue
+		}
+		go app.createAndStartLSPClient(ctx, name, clientConfig)
 	}
 	slog.Info("LSP clients initialization started in background")
 }
 
 // createAndStartLSPClient creates a new LSP client, initializes it, and starts its workspace watcher
-func (app *App) createAndStartLSPClient(ctx context.Context, name string, command string, args ...string) {
-	slog.Info("Creating LSP client", "name", name, "command", command, "args", args)
+func (app *App) createAndStartLSPClient(ctx context.Context, name string, clientConfig config.LSPConfig) {
+	// The known servers of the detected languages may have to be installed
+	// first.
+	autoLSP := app.config.Options.AutoLSP
+	command, err := install.Command(ctx, name, clientConfig, autoLSP != nil && autoLSP.Install)
+	if err != nil {
+		slog.Warn("LSP server not available", "name", name, "error", err)
+		return
+	}
+	slog.Info("Creating LSP client", "name", name, "command", command, "args", clientConfig.Args)
 
 	// Create LSP client.
-	lspClient, err := lsp.NewClient(ctx, command, args...)
+	lspClient, err := lsp.NewClient(ctx, command, clientConfig.Args...)
 	if err != nil {
 		slog.Error("Failed to create LSP client for", name, err)
 		return
@@ -34,7 +47,7 @@ func (app *App) createAndStartLSPClient(ctx context.Context, name string, comman
 	defer cancel()
 
 	// Initialize LSP client.
-	_, err = lspClient.InitializeLSPClient(initCtx, app.config.WorkingDir())
+	_, err = lspClient.InitializeLSPClient(initCtx, app.config.WorkingDir(), clientConfig.InitOptions)
 	if err != nil {
 		slog.Error("Initialize failed", "name", name, "error", err)
 		lspClient.Close()
@@ -113,6 +126,6 @@ func (app *App) restartLSPClient(ctx context.Context, name string) {
 	}
 
 	// Create a new client using the shared function.
-	app.createAndStartLSPClient(ctx, name, clientConfig.Command, clientConfig.Args...)
+	app.createAndStartLSPClient(ctx, name, clientConfig)
 	slog.Info("Successfully restarted LSP client", "client", name)
 }
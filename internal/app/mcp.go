@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/version"
+	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ServeMCP serves the file, shell, search, and LSP tools of Crush over the
+// Model Context Protocol, reading requests from in and writing responses to
+// out, until ctx is done or in is closed.
+//
+// The calls run in a session of their own, auto-approved: the client is
+// expected to confirm them, and destructive actions are denied unless
+// allowed in the configuration.
+func (app *App) ServeMCP(ctx context.Context, in io.Reader, out io.Writer) error {
+	slog.Info("Serving tools over MCP")
+	sess, err := app.Sessions.Create(ctx, "MCP server")
+	if err != nil {
+		return fmt.Errorf("failed to create session for MCP server: %w", err)
+	}
+	app.Permissions.AutoApproveSession(sess.ID)
+
+	stdio := server.NewStdioServer(newMCPServer(sess.ID, app.mcpTools()))
+	stdio.SetErrorLogger(slog.NewLogLogger(slog.Default().Handler(), slog.LevelError))
+	if err := stdio.Listen(ctx, in, out); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to serve MCP: %w", err)
+	}
+	return nil
+}
+
+// mcpTools returns the tools exposed over MCP. Tools reaching the network
+// are left out.
+func (app *App) mcpTools() []tools.BaseTool {
+	cwd := app.config.WorkingDir()
+	exposed := []tools.BaseTool{
+		tools.NewBashTool(app.Permissions, cwd),
+		tools.NewJobsTool(),
+		tools.NewTerminalTool(app.Permissions, cwd),
+		tools.NewEditTool(app.LSPClients, app.Permissions, app.History, cwd),
+		tools.NewMultiEditTool(app.LSPClients, app.Permissions, app.History, cwd),
+		tools.NewWriteTool(app.LSPClients, app.Permissions, app.History, cwd),
+		tools.NewViewTool(app.LSPClients, app.Permissions, cwd),
+		tools.NewLsTool(app.Permissions, cwd),
+		tools.NewGlobTool(cwd),
+		tools.NewGrepTool(cwd),
+	}
+	if len(app.config.LSP) > 0 {
+		exposed = append(exposed, tools.NewDiagnosticsTool(app.LSPClients))
+	}
+	return exposed
+}
+
+func newMCPServer(sessionID string, exposed []tools.BaseTool) *server.MCPServer {
+	s := server.NewMCPServer("crush", version.Version, server.WithToolCapabilities(false))
+	for _, tool := range exposed {
+		info := tool.Info()
+		s.AddTool(mcp.Tool{
+			Name:        info.Name,
+			Description: info.Description,
+			InputSchema: mcp.ToolInputSchema{
+				Type:       "object",
+				Properties: info.Parameters,
+				Required:   info.Required,
+			},
+		}, mcpToolHandler(sessionID, tool))
+	}
+	return s
+}
+
+// mcpToolHandler runs the tool as if the model of the session called it.
+// Failures are reported as tool errors, for the client to show its model.
+func mcpToolHandler(sessionID string, tool tools.BaseTool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		input, err := json.Marshal(request.GetArguments())
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("invalid arguments", err), nil
+		}
+		callID := uuid.New().String()
+		ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+		ctx = context.WithValue(ctx, tools.MessageIDContextKey, callID)
+		resp, err := tool.Run(ctx, tools.ToolCall{ID: callID, Name: tool.Name(), Input: string(input)})
+		switch {
+		case errors.Is(err, permission.ErrorPermissionDenied):
+			return mcp.NewToolResultError("permission denied"), nil
+		case err != nil:
+			slog.Error("MCP tool call failed", "tool", tool.Name(), "error", err)
+			return mcp.NewToolResultErrorFromErr("tool call failed", err), nil
+		case resp.IsError:
+			return mcp.NewToolResultError(resp.Content), nil
+		}
+		return mcp.NewToolResultText(resp.Content), nil
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTool records the calls it runs and answers with err, or echoes
+// their input.
+type fakeTool struct {
+	name  string
+	err   error
+	calls []tools.ToolCall
+	// sessionID is the session of the context of the last call.
+	sessionID string
+}
+
+func (f *fakeTool) Name() string { return f.name }
+
+func (f *fakeTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name:        f.name,
+		Description: "A fake tool",
+		Parameters:  map[string]any{"text": map[string]any{"type": "string"}},
+		Required:    []string{"text"},
+	}
+}
+
+func (f *fakeTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
+	f.calls = append(f.calls, call)
+	f.sessionID, _ = tools.GetContextValues(ctx)
+	if f.err != nil {
+		return tools.ToolResponse{}, f.err
+	}
+	return tools.NewTextResponse(call.Input), nil
+}
+
+func TestMCPServer(t *testing.T) {
+	echo := &fakeTool{name: "echo"}
+	denied := &fakeTool{name: "denied", err: permission.ErrorPermissionDenied}
+
+	c, err := client.NewInProcessClient(newMCPServer("s1", []tools.BaseTool{echo, denied}))
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Start(t.Context()))
+	var initRequest mcp.InitializeRequest
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	_, err = c.Initialize(t.Context(), initRequest)
+	require.NoError(t, err)
+
+	list, err := c.ListTools(t.Context(), mcp.ListToolsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.Tools, 2)
+	for _, tool := range list.Tools {
+		require.Equal(t, []string{"text"}, tool.InputSchema.Required)
+	}
+
+	var call mcp.CallToolRequest
+	call.Params.Name = "echo"
+	call.Params.Arguments = map[string]any{"text": "hello"}
+	result, err := c.CallTool(t.Context(), call)
+	require.NoError(t, err)
+	require.False(t, result.IsError)
+	require.JSONEq(t, `{"text": "hello"}`, result.Content[0].(mcp.TextContent).Text)
+	require.Equal(t, "s1", echo.sessionID)
+	require.NotEmpty(t, echo.calls[0].ID)
+
+	call.Params.Name = "denied"
+	result, err = c.CallTool(t.Context(), call)
+	require.NoError(t, err)
+	require.True(t, result.IsError)
+	require.Equal(t, "permission denied", result.Content[0].(mcp.TextContent).Text)
+}
@@ -0,0 +1,159 @@
+// Package branch forks sessions at a message to explore another approach, and
+// merges the notes of the forks back into the sessions they came from, like
+// git branches for conversations.
+package branch
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// ErrNotFork is returned when merging the notes of a session that isn't a
+// fork.
+var ErrNotFork = errors.New("session is not a fork")
+
+// Fork creates a session with a copy of the messages of the session up to
+// the message, none if it's empty.
+func Fork(ctx context.Context, sessions session.Service, messages message.Service, sessionID, messageID string) (session.Session, error) {
+	origin, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	msgs, err := messages.List(ctx, sessionID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to list messages: %w", err)
+	}
+	i := slices.IndexFunc(msgs, func(msg message.Message) bool { return msg.ID == messageID })
+	if i == -1 && messageID != "" {
+		return session.Session{}, fmt.Errorf("message %s not found in the session", messageID)
+	}
+	msgs = msgs[:i+1]
+
+	fork, err := sessions.CreateFork(ctx, origin, messageID)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create fork: %w", err)
+	}
+	ids := make(map[string]string, len(msgs))
+	for _, msg := range msgs {
+		parts := msg.Parts
+		// Messages other than the assistant's are finished when created.
+		if msg.Role != message.Assistant {
+			parts = slices.DeleteFunc(slices.Clone(parts), func(part message.ContentPart) bool {
+				_, ok := part.(message.Finish)
+				return ok
+			})
+		}
+		copied, err := messages.Create(ctx, fork.ID, message.CreateMessageParams{
+			Role:     msg.Role,
+			Parts:    parts,
+			Model:    msg.Model,
+			Provider: msg.Provider,
+		})
+		if err != nil {
+			return session.Session{}, fmt.Errorf("failed to copy message: %w", err)
+		}
+		ids[msg.ID] = copied.ID
+	}
+
+	// The fork continues from the same compacted history.
+	if summaryID, ok := ids[origin.SummaryMessageID]; ok {
+		fork.SummaryMessageID = summaryID
+		fork.KeptMessageID = ids[origin.KeptMessageID]
+		if fork, err = sessions.Save(ctx, fork); err != nil {
+			return session.Session{}, fmt.Errorf("failed to save fork: %w", err)
+		}
+	}
+	return fork, nil
+}
+
+// MergeNotes adds the notes to the session the fork was forked from, for its
+// agent to know what the fork found. Without notes, the last response of the
+// fork is merged.
+func MergeNotes(ctx context.Context, sessions session.Service, messages message.Service, forkID, notes string) (message.Message, error) {
+	fork, err := sessions.Get(ctx, forkID)
+	if err != nil {
+		return message.Message{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	if fork.ForkedFromID == "" {
+		return message.Message{}, ErrNotFork
+	}
+	notes = strings.TrimSpace(notes)
+	if notes == "" {
+		msgs, err := messages.List(ctx, forkID)
+		if err != nil {
+			return message.Message{}, fmt.Errorf("failed to list messages: %w", err)
+		}
+		for _, msg := range slices.Backward(msgs) {
+			if msg.Role == message.Assistant && msg.Content().Text != "" {
+				notes = strings.TrimSpace(msg.Content().Text)
+				break
+			}
+		}
+		if notes == "" {
+			return message.Message{}, errors.New("no notes to merge")
+		}
+	}
+	return messages.Create(ctx, fork.ForkedFromID, message.CreateMessageParams{
+		Role: message.User,
+		Parts: []message.ContentPart{message.TextContent{
+			Text: fmt.Sprintf("Notes merged from the branch %q:\n\n%s", fork.Title, notes),
+		}},
+	})
+}
+
+// Node is a session in the tree of the forks.
+type Node struct {
+	Session session.Session
+	// Depth is the number of forks from the root session.
+	Depth int
+}
+
+// Tree returns the tree of the forks the session is part of, from the
+// session they all come from, each session followed by its forks, oldest
+// first.
+func Tree(sessions []session.Session, sessionID string) []Node {
+	byID := make(map[string]session.Session, len(sessions))
+	forks := map[string][]session.Session{}
+	for _, s := range sessions {
+		byID[s.ID] = s
+		if s.ForkedFromID != "" {
+			forks[s.ForkedFromID] = append(forks[s.ForkedFromID], s)
+		}
+	}
+	root, ok := byID[sessionID]
+	if !ok {
+		return nil
+	}
+	// The origin of a fork may have been deleted.
+	seen := map[string]bool{root.ID: true}
+	for {
+		origin, ok := byID[root.ForkedFromID]
+		if !ok || seen[origin.ID] {
+			break
+		}
+		seen[origin.ID] = true
+		root = origin
+	}
+
+	var nodes []Node
+	var walk func(s session.Session, depth int)
+	walk = func(s session.Session, depth int) {
+		nodes = append(nodes, Node{Session: s, Depth: depth})
+		children := forks[s.ID]
+		slices.SortFunc(children, func(a, b session.Session) int {
+			return cmp.Compare(a.CreatedAt, b.CreatedAt)
+		})
+		for _, child := range children {
+			walk(child, depth+1)
+		}
+	}
+	walk(root, 0)
+	return nodes
+}
@@ -0,0 +1,123 @@
+package branch
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func newServices(t *testing.T) (session.Service, message.Service) {
+	t.Helper()
+	store, err := db.OpenSQLiteStore(t.Context(), memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return session.NewService(store), message.NewService(store)
+}
+
+func addMessage(t *testing.T, messages message.Service, sessionID string, role message.MessageRole, text string) message.Message {
+	t.Helper()
+	parts := []message.ContentPart{message.TextContent{Text: text}}
+	if role == message.Assistant {
+		parts = append(parts, message.Finish{Reason: message.FinishReasonEndTurn})
+	}
+	msg, err := messages.Create(t.Context(), sessionID, message.CreateMessageParams{Role: role, Parts: parts})
+	require.NoError(t, err)
+	return msg
+}
+
+func texts(t *testing.T, messages message.Service, sessionID string) []string {
+	t.Helper()
+	msgs, err := messages.List(t.Context(), sessionID)
+	require.NoError(t, err)
+	var texts []string
+	for _, msg := range msgs {
+		texts = append(texts, string(msg.Role)+": "+msg.Content().Text)
+	}
+	return texts
+}
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+	sessions, messages := newServices(t)
+
+	origin, err := sessions.Create(t.Context(), "Refactor")
+	require.NoError(t, err)
+	addMessage(t, messages, origin.ID, message.User, "refactor the parser")
+	answer := addMessage(t, messages, origin.ID, message.Assistant, "done with a visitor")
+	addMessage(t, messages, origin.ID, message.User, "now add tests")
+
+	fork, err := Fork(t.Context(), sessions, messages, origin.ID, answer.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Refactor (fork)", fork.Title)
+	require.Equal(t, origin.ID, fork.ForkedFromID)
+	require.Equal(t, answer.ID, fork.ForkMessageID)
+	require.Equal(t, []string{"user: refactor the parser", "assistant: done with a visitor"}, texts(t, messages, fork.ID))
+
+	msgs, err := messages.List(t.Context(), fork.ID)
+	require.NoError(t, err)
+	require.NotEqual(t, answer.ID, msgs[1].ID)
+	require.Len(t, msgs[0].Parts, 2, "the user message is finished once")
+
+	empty, err := Fork(t.Context(), sessions, messages, origin.ID, "")
+	require.NoError(t, err)
+	require.Empty(t, texts(t, messages, empty.ID))
+
+	_, err = Fork(t.Context(), sessions, messages, origin.ID, "missing")
+	require.ErrorContains(t, err, "not found")
+}
+
+func TestMergeNotes(t *testing.T) {
+	t.Parallel()
+	sessions, messages := newServices(t)
+
+	origin, err := sessions.Create(t.Context(), "Refactor")
+	require.NoError(t, err)
+	question := addMessage(t, messages, origin.ID, message.User, "refactor the parser")
+	fork, err := Fork(t.Context(), sessions, messages, origin.ID, question.ID)
+	require.NoError(t, err)
+
+	_, err = MergeNotes(t.Context(), sessions, messages, origin.ID, "")
+	require.ErrorIs(t, err, ErrNotFork)
+	_, err = MergeNotes(t.Context(), sessions, messages, fork.ID, "")
+	require.ErrorContains(t, err, "no notes")
+
+	addMessage(t, messages, fork.ID, message.Assistant, "a visitor doesn't fit, the grammar is too small")
+	_, err = MergeNotes(t.Context(), sessions, messages, fork.ID, "")
+	require.NoError(t, err)
+	_, err = MergeNotes(t.Context(), sessions, messages, fork.ID, "  keep the switch  ")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"user: refactor the parser",
+		"user: Notes merged from the branch \"Refactor (fork)\":\n\na visitor doesn't fit, the grammar is too small",
+		"user: Notes merged from the branch \"Refactor (fork)\":\n\nkeep the switch",
+	}, texts(t, messages, origin.ID))
+}
+
+func TestTree(t *testing.T) {
+	t.Parallel()
+
+	sessions := []session.Session{
+		{ID: "other"},
+		{ID: "root"},
+		{ID: "b", ForkedFromID: "root", CreatedAt: 2},
+		{ID: "a", ForkedFromID: "root", CreatedAt: 1},
+		{ID: "a1", ForkedFromID: "a", CreatedAt: 3},
+		{ID: "orphan", ForkedFromID: "deleted"},
+	}
+	var ids []string
+	var depths []int
+	for _, node := range Tree(sessions, "a1") {
+		ids = append(ids, node.Session.ID)
+		depths = append(depths, node.Depth)
+	}
+	require.Equal(t, []string{"root", "a", "a1", "b"}, ids)
+	require.Equal(t, []int{0, 1, 2, 1}, depths)
+
+	require.Len(t, Tree(sessions, "orphan"), 1)
+	require.Nil(t, Tree(sessions, "missing"))
+}
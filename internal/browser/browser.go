@@ -0,0 +1,658 @@
+// Package browser drives Chrome or Chromium through the Chrome DevTools
+// Protocol, for the browser tool to load pages and act on them like a user.
+package browser
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by the actions on a browser that was closed or
+// crashed.
+var ErrClosed = errClosed
+
+const (
+	launchTimeout = 20 * time.Second
+	loadTimeout   = 30 * time.Second
+	// maxMessages is the number of console messages kept between actions.
+	maxMessages = 50
+)
+
+// Options of a browser.
+type Options struct {
+	// Path of the executable, looked up in the PATH if empty.
+	Path string
+	// Headed shows the window of the browser.
+	Headed bool
+	// Args are additional command line arguments.
+	Args []string
+}
+
+// candidates are the executables of the browsers looked up when none is
+// configured.
+var candidates = map[string][]string{
+	"darwin": {
+		"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+		"/Applications/Chromium.app/Contents/MacOS/Chromium",
+		"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+	},
+	"windows": {
+		`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+		`C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+	},
+}
+
+// Find returns the executable of the browser: the given path, or the first
+// Chrome, Chromium, or Edge found.
+func Find(path string) (string, error) {
+	if path != "" {
+		found, err := exec.LookPath(path)
+		if err != nil {
+			return "", fmt.Errorf("browser not found: %w", err)
+		}
+		return found, nil
+	}
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "chrome", "msedge", "microsoft-edge"} {
+		if found, err := exec.LookPath(name); err == nil {
+			return found, nil
+		}
+	}
+	for _, candidate := range candidates[runtime.GOOS] {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", errors.New("no Chrome, Chromium, or Edge found")
+}
+
+// Browser is a browser with a page the actions apply to.
+type Browser struct {
+	cmd       *exec.Cmd
+	dataDir   string
+	conn      *conn
+	targetID  string
+	sessionID string
+
+	mu       sync.Mutex
+	messages []string
+	status   int
+	statusOf string
+}
+
+// Launch starts the browser, with a new profile, and opens a blank page.
+func Launch(ctx context.Context, opts Options) (*Browser, error) {
+	path, err := Find(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	dataDir, err := os.MkdirTemp("", "crush-browser-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the browser profile: %w", err)
+	}
+
+	args := []string{
+		"--remote-debugging-port=0",
+		"--user-data-dir=" + dataDir,
+		"--no-first-run",
+		"--no-default-browser-check",
+		"--disable-background-networking",
+		"--disable-extensions",
+		"--disable-sync",
+		"--mute-audio",
+	}
+	if !opts.Headed {
+		args = append(args, "--headless=new", "--hide-scrollbars")
+	}
+	if runtime.GOOS == "linux" && os.Geteuid() == 0 {
+		// Chrome refuses to run as root with its sandbox.
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, opts.Args...)
+	args = append(args, "about:blank")
+
+	b := &Browser{cmd: exec.Command(path, args...), dataDir: dataDir}
+	stderr, err := b.cmd.StderrPipe()
+	if err != nil {
+		os.RemoveAll(dataDir)
+		return nil, fmt.Errorf("failed to launch the browser: %w", err)
+	}
+	if err := b.cmd.Start(); err != nil {
+		os.RemoveAll(dataDir)
+		return nil, fmt.Errorf("failed to launch the browser: %w", err)
+	}
+
+	endpoint := make(chan string, 1)
+	go func() {
+		// Keep reading after the endpoint, for the browser not to block
+		// writing its logs.
+		found := false
+		var output []string
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if url, ok := strings.CutPrefix(scanner.Text(), "DevTools listening on "); ok && !found {
+				endpoint <- strings.TrimSpace(url)
+				found = true
+			} else if !found && len(output) < 10 {
+				output = append(output, scanner.Text())
+			}
+		}
+		if !found {
+			endpoint <- "error: " + cmp.Or(strings.Join(output, "\n"), "the browser exited")
+		}
+	}()
+
+	var url string
+	select {
+	case url = <-endpoint:
+	case <-time.After(launchTimeout):
+		url = "error: timed out waiting for the DevTools endpoint"
+	case <-ctx.Done():
+		url = "error: " + ctx.Err().Error()
+	}
+	if msg, ok := strings.CutPrefix(url, "error: "); ok {
+		b.Close()
+		return nil, fmt.Errorf("failed to launch %s: %s", filepath.Base(path), strings.TrimSpace(msg))
+	}
+
+	if b.conn, err = dial(ctx, url, b.handleEvent); err != nil {
+		b.Close()
+		return nil, err
+	}
+	if err := b.openPage(ctx); err != nil {
+		b.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+// openPage opens the page the actions apply to and enables its events.
+func (b *Browser) openPage(ctx context.Context) error {
+	var target struct {
+		TargetID string `json:"targetId"`
+	}
+	if err := b.conn.call(ctx, "", "Target.createTarget", map[string]any{"url": "about:blank"}, &target); err != nil {
+		return fmt.Errorf("failed to open a page: %w", err)
+	}
+	var attached struct {
+		SessionID string `json:"sessionId"`
+	}
+	if err := b.conn.call(ctx, "", "Target.attachToTarget", map[string]any{"targetId": target.TargetID, "flatten": true}, &attached); err != nil {
+		return fmt.Errorf("failed to attach to the page: %w", err)
+	}
+	b.targetID = target.TargetID
+	b.sessionID = attached.SessionID
+	for _, method := range []string{"Page.enable", "DOM.enable", "Runtime.enable", "Network.enable", "Log.enable", "Accessibility.enable"} {
+		if err := b.call(ctx, method, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Browser) call(ctx context.Context, method string, params, result any) error {
+	return b.conn.call(ctx, b.sessionID, method, params, result)
+}
+
+// Closed reports whether the browser was closed or crashed.
+func (b *Browser) Closed() bool {
+	b.conn.mu.Lock()
+	defer b.conn.mu.Unlock()
+	return b.conn.closed
+}
+
+// Close stops the browser and removes its profile.
+func (b *Browser) Close() error {
+	if b.conn != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_ = b.conn.call(ctx, "", "Browser.close", nil, nil)
+		cancel()
+		b.conn.close()
+	} else if b.cmd.Process != nil {
+		b.cmd.Process.Kill()
+	}
+	if b.cmd.Process != nil {
+		done := make(chan struct{})
+		go func() {
+			b.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			b.cmd.Process.Kill()
+			<-done
+		}
+	}
+	return os.RemoveAll(b.dataDir)
+}
+
+// handleEvent records the errors of the page and the status of its
+// document.
+func (b *Browser) handleEvent(method string, params json.RawMessage) {
+	var msg string
+	switch method {
+	case "Runtime.consoleAPICalled":
+		var event struct {
+			Type string `json:"type"`
+			Args []struct {
+				Value       any    `json:"value"`
+				Description string `json:"description"`
+			} `json:"args"`
+		}
+		if json.Unmarshal(params, &event) != nil || (event.Type != "error" && event.Type != "warning" && event.Type != "assert") {
+			return
+		}
+		var parts []string
+		for _, arg := range event.Args {
+			if arg.Value != nil {
+				parts = append(parts, fmt.Sprint(arg.Value))
+			} else {
+				parts = append(parts, arg.Description)
+			}
+		}
+		msg = fmt.Sprintf("console.%s: %s", event.Type, strings.Join(parts, " "))
+	case "Runtime.exceptionThrown":
+		var event struct {
+			ExceptionDetails exceptionDetails `json:"exceptionDetails"`
+		}
+		if json.Unmarshal(params, &event) != nil {
+			return
+		}
+		msg = "uncaught " + event.ExceptionDetails.String()
+	case "Log.entryAdded":
+		var event struct {
+			Entry struct {
+				Level string `json:"level"`
+				Text  string `json:"text"`
+				URL   string `json:"url"`
+			} `json:"entry"`
+		}
+		// Browsers request a favicon of every site, existing or not.
+		if json.Unmarshal(params, &event) != nil || event.Entry.Level != "error" || strings.HasSuffix(event.Entry.URL, "/favicon.ico") {
+			return
+		}
+		msg = event.Entry.Text
+		if event.Entry.URL != "" {
+			msg += " (" + event.Entry.URL + ")"
+		}
+	case "Network.responseReceived":
+		var event struct {
+			Type     string `json:"type"`
+			FrameID  string `json:"frameId"`
+			Response struct {
+				URL    string `json:"url"`
+				Status int    `json:"status"`
+			} `json:"response"`
+		}
+		if json.Unmarshal(params, &event) != nil || event.Type != "Document" || event.FrameID != b.targetID {
+			return
+		}
+		b.mu.Lock()
+		b.status, b.statusOf = event.Response.Status, event.Response.URL
+		b.mu.Unlock()
+		return
+	default:
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.messages) < maxMessages {
+		b.messages = append(b.messages, strings.TrimSpace(msg))
+	}
+}
+
+// Messages returns the errors and warnings of the page since the last call.
+func (b *Browser) Messages() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	messages := b.messages
+	b.messages = nil
+	return messages
+}
+
+// Page describes the page the browser shows.
+type Page struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Status is the HTTP status of the document, if loaded over HTTP.
+	Status int `json:"-"`
+}
+
+// Page returns the title, URL, and status of the page.
+func (b *Browser) Page(ctx context.Context) (Page, error) {
+	var page Page
+	if err := b.evaluate(ctx, "({title: document.title, url: location.href})", &page); err != nil {
+		return page, err
+	}
+	b.mu.Lock()
+	if b.statusOf == page.URL {
+		page.Status = b.status
+	}
+	b.mu.Unlock()
+	return page, nil
+}
+
+// Navigate opens the URL and waits for the page to load.
+func (b *Browser) Navigate(ctx context.Context, url string) error {
+	var result struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := b.call(ctx, "Page.navigate", map[string]any{"url": url}, &result); err != nil {
+		return err
+	}
+	if result.ErrorText != "" {
+		return fmt.Errorf("failed to open %s: %s", url, result.ErrorText)
+	}
+	return b.waitForLoad(ctx)
+}
+
+// Snapshot returns the accessibility tree of the page, with the refs of the
+// elements to act on.
+func (b *Browser) Snapshot(ctx context.Context) (string, error) {
+	var result struct {
+		Nodes []axNode `json:"nodes"`
+	}
+	if err := b.call(ctx, "Accessibility.getFullAXTree", nil, &result); err != nil {
+		return "", err
+	}
+	return renderSnapshot(result.Nodes), nil
+}
+
+// Click clicks the element of the snapshot with the ref.
+func (b *Browser) Click(ctx context.Context, ref int64) error {
+	var point struct {
+		X, Y float64
+	}
+	err := b.callOn(ctx, ref, `function() {
+		const el = this.nodeType === Node.ELEMENT_NODE ? this : this.parentElement;
+		el.scrollIntoView({block: "center", inline: "center"});
+		const rect = el.getBoundingClientRect();
+		return {x: rect.x + rect.width / 2, y: rect.y + rect.height / 2};
+	}`, &point)
+	if err != nil {
+		return err
+	}
+	for _, event := range []map[string]any{
+		{"type": "mouseMoved", "x": point.X, "y": point.Y},
+		{"type": "mousePressed", "x": point.X, "y": point.Y, "button": "left", "clickCount": 1},
+		{"type": "mouseReleased", "x": point.X, "y": point.Y, "button": "left", "clickCount": 1},
+	} {
+		if err := b.call(ctx, "Input.dispatchMouseEvent", event, nil); err != nil {
+			return err
+		}
+	}
+	return b.waitForLoad(ctx)
+}
+
+// Type replaces the text of the field of the snapshot with the ref, like a
+// user typing it.
+func (b *Browser) Type(ctx context.Context, ref int64, text string) error {
+	err := b.callOn(ctx, ref, `function() {
+		const el = this.nodeType === Node.ELEMENT_NODE ? this : this.parentElement;
+		el.scrollIntoView({block: "center", inline: "center"});
+		el.focus();
+		if (typeof el.select === "function") {
+			el.select();
+		} else if (el.isContentEditable) {
+			document.execCommand("selectAll");
+		}
+	}`, nil)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		return b.Press(ctx, "Backspace")
+	}
+	if err := b.call(ctx, "Input.insertText", map[string]any{"text": text}, nil); err != nil {
+		return err
+	}
+	return b.waitForLoad(ctx)
+}
+
+// keys are the keys that can be pressed, with their codes.
+var keys = map[string]struct {
+	code int
+	text string
+}{
+	"Enter":      {13, "\r"},
+	"Tab":        {9, ""},
+	"Escape":     {27, ""},
+	"Backspace":  {8, ""},
+	"Delete":     {46, ""},
+	"ArrowUp":    {38, ""},
+	"ArrowDown":  {40, ""},
+	"ArrowLeft":  {37, ""},
+	"ArrowRight": {39, ""},
+	"PageUp":     {33, ""},
+	"PageDown":   {34, ""},
+	"Home":       {36, ""},
+	"End":        {35, ""},
+	"Space":      {32, " "},
+}
+
+// Keys returns the names of the keys that can be pressed.
+func Keys() []string {
+	return slices.Sorted(maps.Keys(keys))
+}
+
+// Press presses the key in the focused element.
+func (b *Browser) Press(ctx context.Context, key string) error {
+	k, ok := keys[key]
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	name := key
+	if key == "Space" {
+		name = " "
+	}
+	down := map[string]any{"type": "keyDown", "key": name, "code": key, "windowsVirtualKeyCode": k.code}
+	if k.text != "" {
+		down["text"] = k.text
+	}
+	if err := b.call(ctx, "Input.dispatchKeyEvent", down, nil); err != nil {
+		return err
+	}
+	up := map[string]any{"type": "keyUp", "key": name, "code": key, "windowsVirtualKeyCode": k.code}
+	if err := b.call(ctx, "Input.dispatchKeyEvent", up, nil); err != nil {
+		return err
+	}
+	return b.waitForLoad(ctx)
+}
+
+// Text returns the text of the first element matching the CSS selector, or
+// of the whole page without one.
+func (b *Browser) Text(ctx context.Context, selector string) (string, error) {
+	quoted, err := json.Marshal(selector)
+	if err != nil {
+		return "", err
+	}
+	var text *string
+	expression := fmt.Sprintf(`(() => {
+		const selector = %s;
+		const el = selector ? document.querySelector(selector) : document.body;
+		return el ? el.innerText : null;
+	})()`, quoted)
+	if err := b.evaluate(ctx, expression, &text); err != nil {
+		return "", err
+	}
+	if text == nil {
+		return "", fmt.Errorf("no element matches %s", selector)
+	}
+	return *text, nil
+}
+
+// exceptionDetails describes an exception thrown in the page.
+type exceptionDetails struct {
+	Text      string `json:"text"`
+	Exception *struct {
+		Description string `json:"description"`
+	} `json:"exception"`
+}
+
+func (e exceptionDetails) String() string {
+	if e.Exception != nil && e.Exception.Description != "" {
+		return e.Exception.Description
+	}
+	return e.Text
+}
+
+// evaluate evaluates the JavaScript expression in the page, awaiting it if a
+// promise, and decodes its value into result unless nil.
+func (b *Browser) evaluate(ctx context.Context, expression string, result any) error {
+	var res struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
+	}
+	params := map[string]any{"expression": expression, "returnByValue": true, "awaitPromise": true}
+	if err := b.call(ctx, "Runtime.evaluate", params, &res); err != nil {
+		return err
+	}
+	return decodeValue(res.Result.Value, res.ExceptionDetails, result)
+}
+
+// callOn calls the JavaScript function on the element of the snapshot with
+// the ref, and decodes its value into result unless nil.
+func (b *Browser) callOn(ctx context.Context, ref int64, function string, result any) error {
+	var node struct {
+		Object struct {
+			ObjectID string `json:"objectId"`
+		} `json:"object"`
+	}
+	if err := b.call(ctx, "DOM.resolveNode", map[string]any{"backendNodeId": ref}, &node); err != nil {
+		return fmt.Errorf("no element with ref %d, take a new snapshot: %w", ref, err)
+	}
+	var res struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *exceptionDetails `json:"exceptionDetails"`
+	}
+	params := map[string]any{
+		"objectId":            node.Object.ObjectID,
+		"functionDeclaration": function,
+		"returnByValue":       true,
+		"awaitPromise":        true,
+	}
+	if err := b.call(ctx, "Runtime.callFunctionOn", params, &res); err != nil {
+		return err
+	}
+	return decodeValue(res.Result.Value, res.ExceptionDetails, result)
+}
+
+func decodeValue(value json.RawMessage, exception *exceptionDetails, result any) error {
+	if exception != nil {
+		return fmt.Errorf("script failed: %s", exception)
+	}
+	if result == nil || len(value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(value, result); err != nil {
+		return fmt.Errorf("failed to decode the script result: %w", err)
+	}
+	return nil
+}
+
+// settleScript resolves once the DOM didn't change for a moment, for pages
+// rendered by scripts to be done rendering.
+const settleScript = `new Promise(resolve => {
+	let quiet;
+	const done = () => {
+		observer.disconnect();
+		clearTimeout(quiet);
+		clearTimeout(limit);
+		resolve(true);
+	};
+	const observer = new MutationObserver(() => {
+		clearTimeout(quiet);
+		quiet = setTimeout(done, 300);
+	});
+	observer.observe(document, {subtree: true, childList: true, attributes: true, characterData: true});
+	quiet = setTimeout(done, 300);
+	const limit = setTimeout(done, 3000);
+})`
+
+// waitForLoad waits for the document to be loaded and rendered, including
+// the one a click or key press navigated to.
+func (b *Browser) waitForLoad(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, loadTimeout)
+	defer cancel()
+	for {
+		var state string
+		// Evaluating fails while the page navigates, so retry until loaded.
+		if err := b.evaluate(ctx, "document.readyState", &state); err == nil && state == "complete" {
+			if err := b.evaluate(ctx, settleScript, nil); err == nil {
+				return nil
+			}
+		} else if errors.Is(err, errClosed) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the page to load: %w", ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Launcher launches a browser on first use, shared by the actions until
+// closed.
+type Launcher struct {
+	opts Options
+
+	mu      sync.Mutex
+	browser *Browser
+}
+
+// NewLauncher returns a launcher of browsers with the options.
+func NewLauncher(opts Options) *Launcher {
+	return &Launcher{opts: opts}
+}
+
+// Browser returns the browser, launching it if not running.
+func (l *Launcher) Browser(ctx context.Context) (*Browser, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.browser != nil && !l.browser.Closed() {
+		return l.browser, nil
+	}
+	if l.browser != nil {
+		// It crashed, clean up after it.
+		l.browser.Close()
+		l.browser = nil
+	}
+	b, err := Launch(ctx, l.opts)
+	if err != nil {
+		return nil, err
+	}
+	l.browser = b
+	return b, nil
+}
+
+// Close closes the browser if running.
+func (l *Launcher) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.browser == nil {
+		return nil
+	}
+	err := l.browser.Close()
+	l.browser = nil
+	return err
+}
@@ -0,0 +1,162 @@
+package browser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSnapshot(t *testing.T) {
+	const tree = `[
+		{"nodeId": "1", "role": {"value": "RootWebArea"}, "name": {"value": "Todos"}, "childIds": ["2"], "backendDOMNodeId": 1},
+		{"nodeId": "2", "parentId": "1", "role": {"value": "generic"}, "name": {"value": ""}, "childIds": ["3", "5", "7", "8"], "backendDOMNodeId": 2},
+		{"nodeId": "3", "parentId": "2", "role": {"value": "heading"}, "name": {"value": "My  todos"}, "properties": [{"name": "level", "value": {"value": 1}}], "childIds": ["4"], "backendDOMNodeId": 3},
+		{"nodeId": "4", "parentId": "3", "role": {"value": "StaticText"}, "name": {"value": "My todos"}, "backendDOMNodeId": 4},
+		{"nodeId": "5", "parentId": "2", "role": {"value": "textbox"}, "name": {"value": "New todo"}, "value": {"value": "Buy milk"}, "properties": [{"name": "focused", "value": {"value": true}}, {"name": "required", "value": {"value": false}}], "backendDOMNodeId": 5},
+		{"nodeId": "6", "parentId": "2", "ignored": true, "role": {"value": "none"}, "backendDOMNodeId": 6},
+		{"nodeId": "7", "parentId": "2", "role": {"value": "button"}, "name": {"value": "Add"}, "properties": [{"name": "disabled", "value": {"value": true}}], "backendDOMNodeId": 7},
+		{"nodeId": "8", "parentId": "2", "role": {"value": "paragraph"}, "name": {"value": ""}, "childIds": ["9"], "backendDOMNodeId": 8},
+		{"nodeId": "9", "parentId": "8", "role": {"value": "StaticText"}, "name": {"value": "Nothing to do"}, "backendDOMNodeId": 9}
+	]`
+	var nodes []axNode
+	require.NoError(t, json.Unmarshal([]byte(tree), &nodes))
+
+	require.Equal(t, strings.Join([]string{
+		`- document "Todos"`,
+		`  - heading "My todos" [level=1] [ref=3]`,
+		`  - textbox "New todo" value="Buy milk" [focused] [ref=5]`,
+		`  - button "Add" [disabled] [ref=7]`,
+		`  - paragraph [ref=8]`,
+		`    - text "Nothing to do"`,
+	}, "\n"), renderSnapshot(nodes))
+	require.Empty(t, renderSnapshot(nil))
+}
+
+func TestConn(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer ws.Close()
+		for {
+			var msg struct {
+				ID        int64          `json:"id"`
+				SessionID string         `json:"sessionId"`
+				Method    string         `json:"method"`
+				Params    map[string]any `json:"params"`
+			}
+			if ws.ReadJSON(&msg) != nil {
+				return
+			}
+			switch msg.Method {
+			case "Runtime.evaluate":
+				ws.WriteJSON(map[string]any{"method": "Runtime.consoleAPICalled", "params": map[string]any{"type": "error"}})
+				ws.WriteJSON(map[string]any{"id": msg.ID, "result": map[string]any{"session": msg.SessionID, "expression": msg.Params["expression"]}})
+			default:
+				ws.WriteJSON(map[string]any{"id": msg.ID, "error": map[string]any{"code": -32601, "message": fmt.Sprintf("'%s' wasn't found", msg.Method)}})
+			}
+		}
+	}))
+	defer server.Close()
+
+	events := make(chan string, 1)
+	c, err := dial(t.Context(), "ws"+strings.TrimPrefix(server.URL, "http"), func(method string, _ json.RawMessage) {
+		events <- method
+	})
+	require.NoError(t, err)
+
+	var result struct {
+		Session    string `json:"session"`
+		Expression string `json:"expression"`
+	}
+	require.NoError(t, c.call(t.Context(), "s1", "Runtime.evaluate", map[string]any{"expression": "1 + 1"}, &result))
+	require.Equal(t, "s1", result.Session)
+	require.Equal(t, "1 + 1", result.Expression)
+	require.Equal(t, "Runtime.consoleAPICalled", <-events)
+
+	require.EqualError(t, c.call(t.Context(), "", "Nope.nope", nil, nil), "Nope.nope: 'Nope.nope' wasn't found")
+
+	require.NoError(t, c.close())
+	require.ErrorIs(t, c.call(t.Context(), "", "Runtime.evaluate", nil, nil), errClosed)
+}
+
+func TestBrowser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Launching a browser is slow")
+	}
+	if _, err := Find(""); err != nil {
+		t.Skip(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<!doctype html>
+<title>Greeter</title>
+<label for="name">Name</label> <input id="name">
+<button onclick="greet()">Greet</button>
+<p id="greeting"></p>
+<script>
+function greet() {
+	document.getElementById("greeting").textContent = "Hello, " + document.getElementById("name").value + "!";
+	console.error("greeted");
+}
+</script>`)
+	}))
+	defer server.Close()
+
+	launcher := NewLauncher(Options{})
+	defer launcher.Close()
+	b, err := launcher.Browser(t.Context())
+	require.NoError(t, err)
+
+	require.NoError(t, b.Navigate(t.Context(), server.URL))
+	page, err := b.Page(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "Greeter", page.Title)
+	require.Equal(t, 200, page.Status)
+
+	snapshot, err := b.Snapshot(t.Context())
+	require.NoError(t, err)
+	ref := func(role, name string) int64 {
+		for line := range strings.SplitSeq(snapshot, "\n") {
+			if strings.Contains(line, fmt.Sprintf("- %s %q", role, name)) {
+				var ref int64
+				_, err := fmt.Sscanf(line[strings.LastIndex(line, "[ref="):], "[ref=%d]", &ref)
+				require.NoError(t, err)
+				return ref
+			}
+		}
+		t.Fatalf("no %s %q in the snapshot:\n%s", role, name, snapshot)
+		return 0
+	}
+
+	require.NoError(t, b.Type(t.Context(), ref("textbox", "Name"), "Crush"))
+	require.NoError(t, b.Click(t.Context(), ref("button", "Greet")))
+	text, err := b.Text(t.Context(), "#greeting")
+	require.NoError(t, err)
+	require.Equal(t, "Hello, Crush!", text)
+	require.Contains(t, b.Messages(), "console.error: greeted")
+
+	_, err = b.Text(t.Context(), "#nope")
+	require.Error(t, err)
+
+	require.NoError(t, b.Navigate(t.Context(), server.URL+"/missing"))
+	page, err = b.Page(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, 404, page.Status)
+
+	// The launcher launches a new browser once closed.
+	require.NoError(t, launcher.Close())
+	require.True(t, b.Closed())
+	b, err = launcher.Browser(t.Context())
+	require.NoError(t, err)
+	require.False(t, b.Closed())
+}
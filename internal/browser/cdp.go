@@ -0,0 +1,145 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// errClosed is returned by calls on a closed connection.
+var errClosed = errors.New("browser connection closed")
+
+// message is a message of the Chrome DevTools Protocol: a command, its
+// response, or an event.
+type message struct {
+	ID        int64           `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    any             `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// incoming is a message received from the browser, with its parameters
+// left raw.
+type incoming struct {
+	message
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// conn is a connection to the DevTools endpoint of a browser.
+type conn struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	lastID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan incoming
+	closed  bool
+	onEvent func(method string, params json.RawMessage)
+	done    chan struct{}
+}
+
+func dial(ctx context.Context, url string, onEvent func(string, json.RawMessage)) (*conn, error) {
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the browser: %w", err)
+	}
+	c := &conn{
+		ws:      ws,
+		pending: make(map[int64]chan incoming),
+		onEvent: onEvent,
+		done:    make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+func (c *conn) read() {
+	defer close(c.done)
+	for {
+		var msg incoming
+		if err := c.ws.ReadJSON(&msg); err != nil {
+			c.mu.Lock()
+			c.closed = true
+			for id, ch := range c.pending {
+				close(ch)
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+			return
+		}
+		if msg.ID == 0 {
+			if c.onEvent != nil && msg.Method != "" {
+				c.onEvent(msg.Method, msg.Params)
+			}
+			continue
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[msg.ID]
+		delete(c.pending, msg.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- msg
+		}
+	}
+}
+
+// call sends the command to the session, the browser itself if empty, and
+// decodes its result into result unless nil.
+func (c *conn) call(ctx context.Context, sessionID, method string, params, result any) error {
+	id := c.lastID.Add(1)
+	ch := make(chan incoming, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errClosed
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err := c.ws.WriteJSON(message{ID: id, SessionID: sessionID, Method: method, Params: params})
+	c.writeMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("failed to send %s: %w", method, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	case msg, ok := <-ch:
+		if !ok {
+			return errClosed
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("%s: %s", method, msg.Error.Message)
+		}
+		if result != nil && len(msg.Result) > 0 {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				return fmt.Errorf("failed to decode the result of %s: %w", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *conn) close() error {
+	err := c.ws.Close()
+	<-c.done
+	return err
+}
@@ -0,0 +1,128 @@
+package browser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// axValue is a value of an accessibility node.
+type axValue struct {
+	Value any `json:"value"`
+}
+
+func (v *axValue) String() string {
+	if v == nil || v.Value == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(fmt.Sprint(v.Value)), " ")
+}
+
+// axNode is a node of the accessibility tree of a page.
+type axNode struct {
+	NodeID     string   `json:"nodeId"`
+	ParentID   string   `json:"parentId,omitempty"`
+	Ignored    bool     `json:"ignored"`
+	Role       *axValue `json:"role,omitempty"`
+	Name       *axValue `json:"name,omitempty"`
+	Value      *axValue `json:"value,omitempty"`
+	Properties []struct {
+		Name  string  `json:"name"`
+		Value axValue `json:"value"`
+	} `json:"properties,omitempty"`
+	ChildIDs         []string `json:"childIds,omitempty"`
+	BackendDOMNodeID int64    `json:"backendDOMNodeId,omitempty"`
+}
+
+// maxNameLength is the length names are truncated to in snapshots.
+const maxNameLength = 200
+
+// hiddenRoles are the roles of the nodes left out of snapshots, their
+// children being shown in their place.
+var hiddenRoles = []string{"none", "presentation", "generic", "InlineTextBox", "LineBreak", "ListMarker"}
+
+// shownProperties are the properties of the nodes shown in snapshots, when
+// set.
+var shownProperties = []string{"checked", "disabled", "expanded", "selected", "pressed", "required", "focused", "level"}
+
+// renderSnapshot renders the accessibility tree as an indented list of the
+// nodes with their roles and names, and the refs to act on them with.
+func renderSnapshot(nodes []axNode) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	byID := make(map[string]*axNode, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].NodeID] = &nodes[i]
+	}
+
+	var sb strings.Builder
+	var walk func(node *axNode, depth int, parentName string)
+	walk = func(node *axNode, depth int, parentName string) {
+		role := node.Role.String()
+		name := node.Name.String()
+
+		shown := !node.Ignored && !(slices.Contains(hiddenRoles, role) && name == "")
+		if role == "StaticText" {
+			// Text is mostly the name of its parent already.
+			if shown && !strings.Contains(parentName, name) {
+				fmt.Fprintf(&sb, "%s- text %q\n", strings.Repeat("  ", depth), truncate(name))
+			}
+			return
+		}
+
+		if shown {
+			sb.WriteString(strings.Repeat("  ", depth))
+			sb.WriteString("- ")
+			if role == "RootWebArea" {
+				role = "document"
+			}
+			sb.WriteString(role)
+			if name != "" {
+				fmt.Fprintf(&sb, " %q", truncate(name))
+			}
+			if value := node.Value.String(); value != "" && value != name {
+				fmt.Fprintf(&sb, " value=%q", truncate(value))
+			}
+			for _, prop := range node.Properties {
+				if !slices.Contains(shownProperties, prop.Name) {
+					continue
+				}
+				switch value := prop.Value.String(); value {
+				case "", "false":
+				case "true":
+					fmt.Fprintf(&sb, " [%s]", prop.Name)
+				default:
+					fmt.Fprintf(&sb, " [%s=%s]", prop.Name, value)
+				}
+			}
+			if node.BackendDOMNodeID != 0 && role != "document" {
+				fmt.Fprintf(&sb, " [ref=%d]", node.BackendDOMNodeID)
+			}
+			sb.WriteString("\n")
+			depth++
+			parentName = name
+		}
+
+		for _, id := range node.ChildIDs {
+			if child, ok := byID[id]; ok {
+				walk(child, depth, parentName)
+			}
+		}
+	}
+
+	for i := range nodes {
+		if nodes[i].ParentID == "" {
+			walk(&nodes[i], 0, "")
+			break
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func truncate(s string) string {
+	if len(s) <= maxNameLength {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxNameLength], "") + "…"
+}
@@ -0,0 +1,316 @@
+// Package bundle packs the personal setup of crush, its config files, custom
+// commands, and project memory, into a single file to move it to another
+// machine or share it with a teammate.
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// Version is the version of the bundle format.
+const Version = 1
+
+// Kind is what a file of the bundle holds.
+type Kind string
+
+const (
+	// KindPreferences are config files, holding the preferences and the
+	// permission grants.
+	KindPreferences Kind = "preferences"
+	KindCommands    Kind = "commands"
+	KindMemory      Kind = "memory"
+)
+
+// Root is a directory the files of the bundle are relative to, so they land
+// in the same place on another machine.
+type Root string
+
+const (
+	RootConfig  Root = "config"
+	RootData    Root = "data"
+	RootHome    Root = "home"
+	RootProject Root = "project"
+)
+
+// sanitizedKeys are the config keys holding credentials, left out of
+// sanitized bundles.
+var sanitizedKeys = []string{"api_key", "extra_headers", "headers", "env", "token"}
+
+type File struct {
+	Kind Kind `json:"kind"`
+	Root Root `json:"root"`
+	// Path is relative to the root, with slashes.
+	Path string `json:"path"`
+	Data []byte `json:"data"`
+}
+
+type Bundle struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Sanitized bundles have no credentials in their config files.
+	Sanitized bool   `json:"sanitized"`
+	Files     []File `json:"files"`
+}
+
+// Roots maps the roots to the directories of this machine.
+type Roots map[Root]string
+
+// DefaultRoots returns the directories crush keeps its files in, with the
+// project in workingDir.
+func DefaultRoots(workingDir string) Roots {
+	return Roots{
+		RootConfig:  config.GlobalConfigDir(),
+		RootData:    filepath.Dir(config.GlobalConfigData()),
+		RootHome:    config.HomeDir(),
+		RootProject: workingDir,
+	}
+}
+
+// locate returns the root holding the path, the most specific one if
+// several do, and the path relative to it.
+func (r Roots) locate(path string) (Root, string, bool) {
+	var (
+		found  Root
+		rel    string
+		longer int
+	)
+	for root, dir := range r {
+		if dir == "" {
+			continue
+		}
+		p, err := filepath.Rel(dir, path)
+		if err != nil || !filepath.IsLocal(p) {
+			continue
+		}
+		if len(dir) > longer || (len(dir) == longer && root < found) {
+			found, rel, longer = root, filepath.ToSlash(p), len(dir)
+		}
+	}
+	return found, rel, found != ""
+}
+
+// Sources are the files and directories to bundle. Missing ones are
+// skipped.
+type Sources struct {
+	Configs []string
+	// Commands are directories of custom commands.
+	Commands []string
+	// Memory are context files, or directories of context files.
+	Memory []string
+}
+
+// DefaultSources returns the config files, the command directories, and the
+// context files of the config.
+func DefaultSources(cfg *config.Config) Sources {
+	sources := Sources{Configs: config.ConfigPaths(cfg.WorkingDir())}
+	for _, source := range cfg.CommandSources() {
+		sources.Commands = append(sources.Commands, source.Path)
+	}
+	for _, path := range cfg.Options.ContextPaths {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			path = filepath.Join(config.HomeDir(), rest)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.WorkingDir(), path)
+		}
+		sources.Memory = append(sources.Memory, path)
+	}
+	return sources
+}
+
+// Collect reads the sources into a bundle. Sanitizing removes the
+// credentials from the config files.
+func Collect(roots Roots, sources Sources, sanitize bool) (*Bundle, error) {
+	b := &Bundle{Version: Version, CreatedAt: time.Now(), Sanitized: sanitize}
+	seen := map[string]bool{}
+	add := func(kind Kind, path string) error {
+		path = filepath.Clean(path)
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+		root, rel, ok := roots.locate(path)
+		if !ok {
+			return fmt.Errorf("%s is outside of the bundled directories", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if kind == KindPreferences && sanitize {
+			if data, err = sanitizeConfig(path, data); err != nil {
+				return fmt.Errorf("failed to sanitize %s: %w", path, err)
+			}
+		}
+		b.Files = append(b.Files, File{Kind: kind, Root: root, Path: rel, Data: data})
+		return nil
+	}
+
+	for _, path := range sources.Configs {
+		if !isFile(path) {
+			continue
+		}
+		if err := add(KindPreferences, path); err != nil {
+			return nil, err
+		}
+	}
+	for _, dir := range sources.Commands {
+		if err := walkFiles(dir, ".md", func(path string) error {
+			return add(KindCommands, path)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range sources.Memory {
+		if err := walkFiles(path, "", func(path string) error {
+			return add(KindMemory, path)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// walkFiles calls fn with the path if it's a file, or with the files under
+// it with the extension if it's a directory.
+func walkFiles(path, ext string, fn func(string) error) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(path)
+	}
+	return filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || (ext != "" && !strings.EqualFold(filepath.Ext(path), ext)) {
+			return nil
+		}
+		return fn(path)
+	})
+}
+
+// sanitizeConfig removes the credentials from the config file, keeping its
+// format. Comments are lost.
+func sanitizeConfig(path string, data []byte) ([]byte, error) {
+	format, err := config.FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = config.ConvertConfig(data, format, config.FormatJSON)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value map[string]any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	stripKeys(value)
+	if data, err = json.Marshal(value); err != nil {
+		return nil, err
+	}
+	return config.ConvertConfig(data, config.FormatJSON, format)
+}
+
+func stripKeys(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if slices.Contains(sanitizedKeys, key) {
+				delete(v, key)
+				continue
+			}
+			stripKeys(child)
+		}
+	case []any:
+		for _, child := range v {
+			stripKeys(child)
+		}
+	}
+}
+
+// Status is what installing a file did.
+type Status string
+
+const (
+	StatusCreated   Status = "created"
+	StatusUpdated   Status = "updated"
+	StatusUnchanged Status = "unchanged"
+	// StatusSkipped files already exist with other content, they are only
+	// overwritten when forced.
+	StatusSkipped Status = "skipped"
+)
+
+type Installed struct {
+	File   File
+	Path   string
+	Status Status
+}
+
+// Install writes the files of the bundle to the roots. Existing files are
+// only overwritten if force is set.
+func Install(b *Bundle, roots Roots, force bool) ([]Installed, error) {
+	var installed []Installed
+	for _, file := range b.Files {
+		dir, ok := roots[file.Root]
+		if !ok || dir == "" {
+			return installed, fmt.Errorf("unknown root %q of %s", file.Root, file.Path)
+		}
+		rel := filepath.FromSlash(file.Path)
+		if !filepath.IsLocal(rel) {
+			return installed, fmt.Errorf("invalid path %q in the bundle", file.Path)
+		}
+		path := filepath.Join(dir, rel)
+
+		status := StatusCreated
+		if existing, err := os.ReadFile(path); err == nil {
+			switch {
+			case bytes.Equal(existing, file.Data):
+				status = StatusUnchanged
+			case force:
+				status = StatusUpdated
+			default:
+				status = StatusSkipped
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return installed, err
+		}
+
+		if status == StatusCreated || status == StatusUpdated {
+			perm := os.FileMode(0o644)
+			if file.Kind == KindPreferences {
+				perm = 0o600
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return installed, err
+			}
+			if err := os.WriteFile(path, file.Data, perm); err != nil {
+				return installed, err
+			}
+		}
+		installed = append(installed, Installed{File: file, Path: path, Status: status})
+	}
+	return installed, nil
+}
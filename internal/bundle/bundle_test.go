@@ -0,0 +1,91 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func testRoots(t *testing.T) Roots {
+	return Roots{
+		RootConfig:  t.TempDir(),
+		RootData:    t.TempDir(),
+		RootHome:    t.TempDir(),
+		RootProject: t.TempDir(),
+	}
+}
+
+func TestBundle_RoundTrip(t *testing.T) {
+	src := testRoots(t)
+	writeFile(t, filepath.Join(src[RootConfig], "crush.json"), `{"providers": {"openai": {"api_key": "sk-secret", "base_url": "https://example.com"}}, "permissions": {"allowed_tools": ["view"]}}`)
+	writeFile(t, filepath.Join(src[RootConfig], "commands", "review", "pr.md"), "Review the PR")
+	writeFile(t, filepath.Join(src[RootConfig], "commands", "notes.txt"), "not a command")
+	writeFile(t, filepath.Join(src[RootProject], "CRUSH.md"), "# Memory")
+	writeFile(t, filepath.Join(src[RootProject], ".cursor", "rules", "go.mdc"), "Use testify")
+
+	sources := Sources{
+		Configs:  []string{filepath.Join(src[RootConfig], "crush.json"), filepath.Join(src[RootData], "crush.json")},
+		Commands: []string{filepath.Join(src[RootConfig], "commands"), filepath.Join(src[RootHome], ".crush", "commands")},
+		Memory:   []string{filepath.Join(src[RootProject], "CRUSH.md"), filepath.Join(src[RootProject], ".cursor", "rules"), filepath.Join(src[RootProject], "AGENTS.md")},
+	}
+	b, err := Collect(src, sources, true)
+	require.NoError(t, err)
+	require.Len(t, b.Files, 4)
+	require.Equal(t, File{Kind: KindCommands, Root: RootConfig, Path: "commands/review/pr.md", Data: []byte("Review the PR")}, b.Files[1])
+	require.NotContains(t, string(b.Files[0].Data), "sk-secret")
+	require.Contains(t, string(b.Files[0].Data), `"allowed_tools"`)
+
+	data, err := Encrypt(b, "correct horse")
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "Review the PR")
+	_, err = Decrypt(data, "wrong horse")
+	require.ErrorIs(t, err, ErrPassphrase)
+	decrypted, err := Decrypt(data, "correct horse")
+	require.NoError(t, err)
+	require.Equal(t, b.Files, decrypted.Files)
+
+	dst := testRoots(t)
+	writeFile(t, filepath.Join(dst[RootProject], "CRUSH.md"), "# Other memory")
+	installed, err := Install(decrypted, dst, false)
+	require.NoError(t, err)
+	var statuses []Status
+	for _, file := range installed {
+		statuses = append(statuses, file.Status)
+	}
+	require.Equal(t, []Status{StatusCreated, StatusCreated, StatusSkipped, StatusCreated}, statuses)
+	content, err := os.ReadFile(filepath.Join(dst[RootProject], ".cursor", "rules", "go.mdc"))
+	require.NoError(t, err)
+	require.Equal(t, "Use testify", string(content))
+
+	installed, err = Install(decrypted, dst, true)
+	require.NoError(t, err)
+	require.Equal(t, StatusUnchanged, installed[0].Status)
+	require.Equal(t, StatusUpdated, installed[2].Status)
+}
+
+func TestInstall_RejectsEscapingPaths(t *testing.T) {
+	b := &Bundle{Version: Version, Files: []File{{Kind: KindMemory, Root: RootProject, Path: "../outside.md"}}}
+	_, err := Install(b, testRoots(t), true)
+	require.ErrorContains(t, err, "invalid path")
+}
+
+func TestRoots_Locate(t *testing.T) {
+	home := t.TempDir()
+	roots := Roots{RootHome: home, RootConfig: filepath.Join(home, ".config", "crush")}
+
+	root, rel, ok := roots.locate(filepath.Join(home, ".config", "crush", "commands", "a.md"))
+	require.True(t, ok)
+	require.Equal(t, RootConfig, root)
+	require.Equal(t, "commands/a.md", rel)
+
+	_, _, ok = roots.locate(filepath.Dir(home))
+	require.False(t, ok)
+}
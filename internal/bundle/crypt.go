@@ -0,0 +1,128 @@
+package bundle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	format     = "crush-profile"
+	kdf        = "pbkdf2-sha256"
+	iterations = 600_000
+	// maxIterations bounds the work an untrusted bundle can ask for.
+	maxIterations = 10 * iterations
+)
+
+// ErrPassphrase is returned when a bundle can't be decrypted, most likely
+// because of a wrong passphrase.
+var ErrPassphrase = errors.New("wrong passphrase or corrupted bundle")
+
+// envelope is the encrypted bundle: the compressed JSON of the bundle,
+// sealed with AES-GCM under a key derived from the passphrase.
+type envelope struct {
+	Format     string `json:"format"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// Encrypt encrypts the bundle with the passphrase.
+func Encrypt(b *Bundle, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is empty")
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	env := envelope{
+		Format:     format,
+		KDF:        kdf,
+		Iterations: iterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Data = gcm.Seal(nil, env.Nonce, buf.Bytes(), []byte(format))
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// Decrypt decrypts a bundle encrypted with the passphrase.
+func Decrypt(data []byte, passphrase string) (*Bundle, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != format {
+		return nil, errors.New("not a crush profile")
+	}
+	if env.KDF != kdf {
+		return nil, fmt.Errorf("unsupported key derivation %q", env.KDF)
+	}
+	if env.Iterations < 1 || env.Iterations > maxIterations {
+		return nil, fmt.Errorf("invalid key derivation iterations %d", env.Iterations)
+	}
+	gcm, err := newGCM(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, ErrPassphrase
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Data, []byte(format))
+	if err != nil {
+		return nil, ErrPassphrase
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, err
+	}
+	if b.Version > Version {
+		return nil, fmt.Errorf("profile version %d is newer than supported version %d, update crush", b.Version, Version)
+	}
+	return &b, nil
+}
+
+func newGCM(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,333 @@
+// Package checkpoint snapshots the working directory in a shadow git
+// repository kept in the data directory, apart from the repository of the
+// project, so that the changes of the agent can be rolled back.
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// ErrNothingToUndo is returned by Undo when no checkpoint differs from the
+// working directory.
+var ErrNothingToUndo = errors.New("no checkpoint to undo")
+
+const (
+	sessionTrailer = "Crush-Session"
+	restoreTrailer = "Crush-Restore"
+	// undoRef is the last restored checkpoint.
+	undoRef = "refs/crush/restored"
+)
+
+// Checkpoint is a snapshot of the working directory.
+type Checkpoint struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id,omitempty"`
+	Message   string `json:"message"`
+	// Restore is set on the checkpoints taken before restoring another one,
+	// so that the restore can be undone too.
+	Restore   bool      `json:"restore,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	tree string
+}
+
+// ShortID is the abbreviated ID of the checkpoint.
+func (c Checkpoint) ShortID() string {
+	return c.ID[:min(len(c.ID), 8)]
+}
+
+// Store keeps the checkpoints of a working directory.
+type Store struct {
+	gitDir  string
+	workDir string
+	dataDir string
+	exclude []string
+	mu      sync.Mutex
+}
+
+// New returns the store of the checkpoints of the working directory in the
+// data directory. The ignored files of the project, the data directory, and
+// the paths of exclude, in the .gitignore syntax, aren't snapshotted.
+func New(dataDir, workDir string, exclude []string) *Store {
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(workDir, dataDir)
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(workDir)))
+	return &Store{
+		gitDir:  filepath.Join(dataDir, "checkpoints", hex.EncodeToString(sum[:])[:16]),
+		workDir: workDir,
+		dataDir: dataDir,
+		exclude: exclude,
+	}
+}
+
+// OpenProject returns the store of the working directory of the config, or
+// nil if the checkpoints are disabled or git isn't installed.
+func OpenProject(cfg *config.Config) *Store {
+	options := cfg.Options.Checkpoints
+	if (options != nil && options.Disabled) || !Available() {
+		return nil
+	}
+	var exclude []string
+	if options != nil {
+		exclude = options.Exclude
+	}
+	return New(cfg.Options.DataDirectory, cfg.WorkingDir(), exclude)
+}
+
+// Available reports whether git, which the checkpoints need, is installed.
+func Available() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// Create snapshots the working directory. When nothing changed since the
+// last checkpoint, it's returned instead of a new one, and created is false.
+func (s *Store) Create(ctx context.Context, sessionID, message string) (checkpoint Checkpoint, created bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(ctx, sessionID, message, false)
+}
+
+// List returns the checkpoints, the latest first.
+func (s *Store) List(ctx context.Context) ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.initialized() {
+		return nil, nil
+	}
+	if _, err := s.run(ctx, "rev-parse", "--quiet", "--verify", "HEAD"); err != nil {
+		return nil, nil
+	}
+	return s.log(ctx, "HEAD")
+}
+
+// Restore makes the working directory match the checkpoint, which may be
+// given by a prefix of its ID. The working directory is snapshotted first,
+// so the restore can itself be undone.
+func (s *Store) Restore(ctx context.Context, id string) (Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.initialized() {
+		return Checkpoint{}, fmt.Errorf("unknown checkpoint %s", id)
+	}
+	checkpoints, err := s.log(ctx, "-1", id+"^{commit}", "--")
+	if err != nil || len(checkpoints) == 0 {
+		return Checkpoint{}, fmt.Errorf("unknown checkpoint %s", id)
+	}
+	target := checkpoints[0]
+	if _, _, err := s.create(ctx, "", "Before restoring "+target.ShortID(), true); err != nil {
+		return Checkpoint{}, err
+	}
+	return target, s.restore(ctx, target)
+}
+
+// Undo restores the latest checkpoint taken before a turn that differs from
+// the working directory, which rolls back the last changes, and then the
+// ones before on each call.
+func (s *Store) Undo(ctx context.Context) (Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.initialized() {
+		return Checkpoint{}, ErrNothingToUndo
+	}
+	current, _, err := s.create(ctx, "", "Before undo", true)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	checkpoints, err := s.log(ctx, "HEAD")
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	// Nothing changed since the last restore, go on with the checkpoints
+	// before the restored one.
+	if restored, err := s.log(ctx, "-1", undoRef, "--"); err == nil && len(restored) == 1 && restored[0].tree == current.tree {
+		for i, checkpoint := range checkpoints {
+			if checkpoint.ID == restored[0].ID {
+				checkpoints = checkpoints[i+1:]
+				break
+			}
+		}
+	}
+	for _, checkpoint := range checkpoints {
+		if !checkpoint.Restore && checkpoint.tree != current.tree {
+			return checkpoint, s.restore(ctx, checkpoint)
+		}
+	}
+	return Checkpoint{}, ErrNothingToUndo
+}
+
+func (s *Store) initialized() bool {
+	_, err := os.Stat(filepath.Join(s.gitDir, "HEAD"))
+	return err == nil
+}
+
+func (s *Store) create(ctx context.Context, sessionID, message string, restore bool) (Checkpoint, bool, error) {
+	if !s.initialized() {
+		if err := os.MkdirAll(s.gitDir, 0o755); err != nil {
+			return Checkpoint{}, false, fmt.Errorf("failed to create the checkpoints directory: %w", err)
+		}
+		if _, err := s.run(ctx, "init", "--quiet"); err != nil {
+			return Checkpoint{}, false, fmt.Errorf("failed to create the checkpoints repository: %w", err)
+		}
+	}
+	if err := s.writeExclude(); err != nil {
+		return Checkpoint{}, false, err
+	}
+
+	if _, err := s.run(ctx, "add", "--all", "--", "."); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to snapshot the working directory: %w", err)
+	}
+	tree, err := s.run(ctx, "write-tree")
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to snapshot the working directory: %w", err)
+	}
+	tree = strings.TrimSpace(tree)
+
+	args := []string{"commit-tree", tree, "-m", message}
+	if head, err := s.log(ctx, "-1", "HEAD", "--"); err == nil && len(head) == 1 {
+		if head[0].tree == tree {
+			return head[0], false, nil
+		}
+		args = append(args, "-p", head[0].ID)
+	}
+	var trailers []string
+	if sessionID != "" {
+		trailers = append(trailers, sessionTrailer+": "+sessionID)
+	}
+	if restore {
+		trailers = append(trailers, restoreTrailer+": true")
+	}
+	if len(trailers) > 0 {
+		args = append(args, "-m", strings.Join(trailers, "\n"))
+	}
+	commit, err := s.run(ctx, args...)
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to record the checkpoint: %w", err)
+	}
+	commit = strings.TrimSpace(commit)
+	if _, err := s.run(ctx, "update-ref", "HEAD", commit); err != nil {
+		return Checkpoint{}, false, fmt.Errorf("failed to record the checkpoint: %w", err)
+	}
+	checkpoints, err := s.log(ctx, "-1", commit, "--")
+	if err != nil || len(checkpoints) == 0 {
+		return Checkpoint{}, false, fmt.Errorf("failed to read the checkpoint: %w", err)
+	}
+	return checkpoints[0], true, nil
+}
+
+// restore makes the working directory and the index match the checkpoint,
+// removing the files snapshotted since, and records it as the last restored
+// one.
+func (s *Store) restore(ctx context.Context, checkpoint Checkpoint) error {
+	if _, err := s.run(ctx, "read-tree", "--reset", "-u", checkpoint.ID); err != nil {
+		return fmt.Errorf("failed to restore checkpoint %s: %w", checkpoint.ShortID(), err)
+	}
+	if _, err := s.run(ctx, "update-ref", undoRef, checkpoint.ID); err != nil {
+		return fmt.Errorf("failed to record the restored checkpoint: %w", err)
+	}
+	return nil
+}
+
+// writeExclude leaves the data directory and the configured paths out of the
+// snapshots.
+func (s *Store) writeExclude() error {
+	var lines []string
+	if rel, err := filepath.Rel(s.workDir, s.dataDir); err == nil && !strings.HasPrefix(rel, "..") && rel != "." {
+		lines = append(lines, "/"+filepath.ToSlash(rel)+"/")
+	}
+	lines = append(lines, s.exclude...)
+	path := filepath.Join(s.gitDir, "info", "exclude")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to write the checkpoint excludes: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write the checkpoint excludes: %w", err)
+	}
+	return nil
+}
+
+// log returns the checkpoints listed by git log with the arguments.
+func (s *Store) log(ctx context.Context, args ...string) ([]Checkpoint, error) {
+	output, err := s.run(ctx, append([]string{"log", "--format=%H%x1f%T%x1f%ct%x1f%s%x1f%b%x1e"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []Checkpoint
+	for record := range strings.SplitSeq(output, "\x1e") {
+		fields := strings.Split(strings.TrimLeft(record, "\n"), "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		checkpoint := Checkpoint{ID: fields[0], tree: fields[1], Message: fields[3]}
+		if seconds, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			checkpoint.CreatedAt = time.Unix(seconds, 0)
+		}
+		for line := range strings.SplitSeq(fields[4], "\n") {
+			key, value, ok := strings.Cut(line, ": ")
+			switch {
+			case !ok:
+			case key == sessionTrailer:
+				checkpoint.SessionID = strings.TrimSpace(value)
+			case key == restoreTrailer:
+				checkpoint.Restore = strings.TrimSpace(value) == "true"
+			}
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+// run runs git on the shadow repository, whatever the git configuration and
+// environment of the project.
+func (s *Store) run(ctx context.Context, args ...string) (string, error) {
+	base := []string{
+		"--git-dir", s.gitDir,
+		"--work-tree", s.workDir,
+		"-c", "core.autocrlf=false",
+		"-c", "core.safecrlf=false",
+		"-c", "core.quotepath=off",
+		"-c", "gc.auto=0",
+	}
+	cmd := exec.CommandContext(ctx, "git", append(base, args...)...)
+	cmd.Dir = s.workDir
+	for _, env := range os.Environ() {
+		if name, _, _ := strings.Cut(env, "="); !strings.HasPrefix(name, "GIT_") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Env = append(cmd.Env,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_AUTHOR_NAME=Crush",
+		"GIT_AUTHOR_EMAIL=crush@localhost",
+		"GIT_COMMITTER_NAME=Crush",
+		"GIT_COMMITTER_EMAIL=crush@localhost",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
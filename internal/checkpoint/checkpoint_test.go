@@ -0,0 +1,137 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, files map[string]string) (*Store, string) {
+	t.Helper()
+	if !Available() {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	return New(filepath.Join(dir, ".crush"), dir, []string{"*.log"}), dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCreate(t *testing.T) {
+	store, dir := newTestStore(t, map[string]string{"a.txt": "a"})
+	ctx := t.Context()
+
+	first, created, err := store.Create(ctx, "session", "Fix the tests")
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "session", first.SessionID)
+	require.Equal(t, "Fix the tests", first.Message)
+
+	_, created, err = store.Create(ctx, "session", "Nothing changed")
+	require.NoError(t, err)
+	require.False(t, created)
+
+	writeFiles(t, dir, map[string]string{"a.txt": "b"})
+	second, created, err := store.Create(ctx, "other", "Change a")
+	require.NoError(t, err)
+	require.True(t, created)
+
+	checkpoints, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	require.Equal(t, second.ID, checkpoints[0].ID)
+	require.Equal(t, first.ID, checkpoints[1].ID)
+	require.False(t, checkpoints[0].CreatedAt.IsZero())
+}
+
+func TestRestore(t *testing.T) {
+	store, dir := newTestStore(t, map[string]string{
+		"a.txt":      "a",
+		"gone.txt":   "gone",
+		"debug.log":  "excluded",
+		".crush/db":  "data",
+		"sub/b.txt":  "b",
+		".gitignore": "build/\n",
+	})
+	ctx := t.Context()
+	checkpoint, _, err := store.Create(ctx, "session", "Before the turn")
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "gone.txt")))
+	writeFiles(t, dir, map[string]string{
+		"a.txt":       "changed",
+		"new.txt":     "new",
+		"debug.log":   "excluded, changed",
+		"build/out":   "ignored",
+		".crush/db":   "data, changed",
+		"sub/new.txt": "new",
+	})
+
+	restored, err := store.Restore(ctx, checkpoint.ShortID())
+	require.NoError(t, err)
+	require.Equal(t, checkpoint.ID, restored.ID)
+	require.Equal(t, "a", readFile(t, filepath.Join(dir, "a.txt")))
+	require.Equal(t, "gone", readFile(t, filepath.Join(dir, "gone.txt")))
+	require.NoFileExists(t, filepath.Join(dir, "new.txt"))
+	require.NoFileExists(t, filepath.Join(dir, "sub", "new.txt"))
+	// The excluded and ignored files are left alone.
+	require.Equal(t, "excluded, changed", readFile(t, filepath.Join(dir, "debug.log")))
+	require.Equal(t, "ignored", readFile(t, filepath.Join(dir, "build", "out")))
+	require.Equal(t, "data, changed", readFile(t, filepath.Join(dir, ".crush", "db")))
+
+	// The state before the restore was snapshotted, and can be restored.
+	checkpoints, err := store.List(ctx)
+	require.NoError(t, err)
+	require.True(t, checkpoints[0].Restore)
+	_, err = store.Restore(ctx, checkpoints[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, "changed", readFile(t, filepath.Join(dir, "a.txt")))
+	require.NoFileExists(t, filepath.Join(dir, "gone.txt"))
+
+	_, err = store.Restore(ctx, "deadbeef")
+	require.ErrorContains(t, err, "unknown checkpoint deadbeef")
+}
+
+func TestUndo(t *testing.T) {
+	store, dir := newTestStore(t, map[string]string{"a.txt": "1"})
+	ctx := t.Context()
+
+	_, err := store.Undo(ctx)
+	require.ErrorIs(t, err, ErrNothingToUndo)
+
+	// A checkpoint is taken before each turn changing files.
+	for _, content := range []string{"2", "3"} {
+		_, _, err := store.Create(ctx, "session", "Turn")
+		require.NoError(t, err)
+		writeFiles(t, dir, map[string]string{"a.txt": content})
+	}
+
+	_, err = store.Undo(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "2", readFile(t, filepath.Join(dir, "a.txt")))
+
+	_, err = store.Undo(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "1", readFile(t, filepath.Join(dir, "a.txt")))
+
+	_, err = store.Undo(ctx)
+	require.ErrorIs(t, err, ErrNothingToUndo)
+	require.Equal(t, "1", readFile(t, filepath.Join(dir, "a.txt")))
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/git"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Commit the staged changes with a generated message",
+	Long:  `Write a Conventional Commits message for the staged changes with the small model, and commit them with it once confirmed. The model writing the message can be changed with the commit key of the routing option.`,
+	Example: `
+# Commit the staged changes after confirming the message
+crush commit
+
+# Commit the changes of all tracked files without asking
+crush commit --all --yes
+
+# Only print the message
+crush commit --dry-run
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		yes, _ := cmd.Flags().GetBool("yes")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		edit, _ := cmd.Flags().GetBool("edit")
+		ctx := cmd.Context()
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		repo, err := git.Open(ctx, cwd)
+		if err != nil {
+			return err
+		}
+		opts := git.DiffOptions{Staged: true}
+		if all {
+			// The tracked files of the working tree against the HEAD.
+			opts = git.DiffOptions{Ref: "HEAD"}
+		}
+		diff, err := repo.Diff(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if len(diff.Files) == 0 {
+			if all {
+				return fmt.Errorf("no changes to commit")
+			}
+			return fmt.Errorf("no staged changes, stage them with git add or use --all")
+		}
+
+		profile, _ := cmd.Flags().GetString("profile")
+		debug, _ := cmd.Flags().GetBool("debug")
+		cfg, err := config.Init(cwd, profile, debug)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+		if !cfg.IsConfigured() {
+			return fmt.Errorf("no providers configured - please run 'crush' to set up a provider interactively")
+		}
+
+		fmt.Fprintf(os.Stderr, "Writing the message of %d changed files...\n", len(diff.Files))
+		message, err := agent.GenerateCommitMessage(ctx, cfg, diff)
+		if err != nil {
+			return fmt.Errorf("failed to write the commit message: %w", err)
+		}
+		fmt.Printf("%s\n", message)
+		if dryRun {
+			return nil
+		}
+
+		if !yes && !edit {
+			if !term.IsTerminal(os.Stdin.Fd()) {
+				return fmt.Errorf("not committing without a terminal, use --yes to commit or --dry-run to only print the message")
+			}
+			fmt.Fprint(os.Stderr, "\nCommit with this message? [Y/n/e(dit)] ")
+			answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "", "y", "yes":
+			case "e", "edit":
+				edit = true
+			default:
+				fmt.Fprintln(os.Stderr, "Not committed")
+				return nil
+			}
+		}
+
+		commit, err := repo.Commit(ctx, message, git.CommitOptions{All: all, Edit: edit})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Committed %s %s\n", commit.Hash[:min(len(commit.Hash), 7)], commit.Subject)
+		return nil
+	},
+}
+
+func init() {
+	commitCmd.Flags().BoolP("all", "a", false, "Commit the changes of all tracked files, staged or not")
+	commitCmd.Flags().BoolP("yes", "y", false, "Commit without asking to confirm the message")
+	commitCmd.Flags().BoolP("dry-run", "n", false, "Only print the message")
+	commitCmd.Flags().BoolP("edit", "e", false, "Edit the message in the editor of git before committing")
+	rootCmd.AddCommand(commitCmd)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage crush configuration files",
+}
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert <file>",
+	Short: "Convert a configuration file between JSON, YAML, and TOML",
+	Long:  `Convert a configuration file between JSON, YAML, and TOML. The input format is detected from the file extension. The result is written to stdout, or to the file given with --output, whose extension then selects the output format.`,
+	Example: `
+# Print crush.json as YAML
+crush config convert crush.json --to yaml
+
+# Convert crush.json to crush.toml
+crush config convert crush.json -o crush.toml
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		to, _ := cmd.Flags().GetString("to")
+
+		from, err := config.FormatFromPath(args[0])
+		if err != nil {
+			return err
+		}
+		var target config.Format
+		switch {
+		case to != "":
+			target, err = config.FormatFromPath("." + strings.ToLower(to))
+		case output != "":
+			target, err = config.FormatFromPath(output)
+		default:
+			return fmt.Errorf("either --to or --output is required")
+		}
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		converted, err := config.ConvertConfig(data, from, target)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", args[0], err)
+		}
+
+		if output == "" {
+			_, err = os.Stdout.Write(converted)
+			return err
+		}
+		if err := os.WriteFile(output, converted, 0o644); err != nil {
+			return fmt.Errorf("failed to write config file: %w", err)
+		}
+		return nil
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file...]",
+	Short: "Validate configuration files",
+	Long:  `Validate configuration files against the configuration schema, reporting unknown keys, values of the wrong type, and missing required fields. Without arguments, all configuration files crush would load for the current directory are validated.`,
+	Example: `
+# Validate the configuration files crush would load
+crush config validate
+
+# Validate a specific file
+crush config validate .crush.yaml
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		paths := args
+		if len(paths) == 0 {
+			cwd, err := ResolveCwd(cmd)
+			if err != nil {
+				return err
+			}
+			for _, path := range config.ConfigPaths(cwd) {
+				if _, err := os.Stat(path); err == nil {
+					paths = append(paths, path)
+				}
+			}
+			if len(paths) == 0 {
+				fmt.Println("No configuration files found")
+				return nil
+			}
+		}
+
+		var problems int
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read config file: %w", err)
+			}
+			errs, err := config.ValidateConfig(path, data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				problems++
+				continue
+			}
+			for _, e := range errs {
+				location := path
+				if e.Line > 0 {
+					location = fmt.Sprintf("%s:%d", path, e.Line)
+				}
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", location, cmp.Or(e.Path, "(root)"), e.Message)
+			}
+			problems += len(errs)
+		}
+		if problems > 0 {
+			return fmt.Errorf("found %d problem(s) in %d file(s)", problems, len(paths))
+		}
+		fmt.Printf("Validated %d file(s), no problems found\n", len(paths))
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configConvertCmd.Flags().String("to", "", "Output format: json, yaml, or toml")
+	configConvertCmd.Flags().StringP("output", "o", "", "Write the result to this file instead of stdout")
+	configCmd.AddCommand(configConvertCmd)
+	rootCmd.AddCommand(configCmd)
+}
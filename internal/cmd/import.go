@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/importer"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <claude-code|aider|codex> [file...]",
+	Short: "Import the history of other coding agents",
+	Long:  `Import the conversations of Claude Code, aider, or Codex CLI as sessions, to continue them in crush. Without files, the history of the project kept by the CLI is imported: the transcripts under ~/.claude/projects, .aider.chat.history.md in the project, or the rollouts under ~/.codex/sessions started in the project. Importing the same history twice creates its sessions twice.`,
+	Example: `
+# Import the Claude Code conversations of the project
+crush import claude-code
+
+# Import an exported Codex rollout
+crush import codex ~/.codex/sessions/2025/08/01/rollout-2025-08-01T10-00-00-1234.jsonl
+  `,
+	Args:      cobra.MinimumNArgs(1),
+	ValidArgs: []string{string(importer.FormatClaudeCode), string(importer.FormatAider), string(importer.FormatCodex)},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format := importer.Format(args[0])
+		if !slices.Contains(importer.Formats, format) {
+			return fmt.Errorf("unknown format %q, expected one of %v", format, importer.Formats)
+		}
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		paths := args[1:]
+		if len(paths) == 0 {
+			if paths, err = importer.Sources(format, cwd); err != nil {
+				return err
+			}
+			if len(paths) == 0 {
+				return fmt.Errorf("no %s history found for %s", format, cwd)
+			}
+		}
+
+		var convs []importer.Conversation
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			parsed, err := importer.Parse(format, f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			convs = append(convs, parsed...)
+		}
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		imported, err := importer.Import(cmd.Context(), session.NewService(store), message.NewService(store), convs)
+		for _, sess := range imported {
+			fmt.Printf("Imported %s\n", sess.Title)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d sessions from %d files\n", len(imported), len(paths))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/codesearch"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Index the project for the codesearch tool",
+	Long:  `Index the files of the project by their embeddings, for the codesearch tool to search them by meaning. Only new and modified files are embedded unless --rebuild is set. The codesearch tool indexes the project as well, this is mostly useful to index a large project ahead of time.`,
+	Example: `
+# Index the new and modified files
+crush index
+
+# Index every file again
+crush index --rebuild
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		index, err := openCodeSearchIndex(cmd)
+		if err != nil {
+			return err
+		}
+		defer index.Close()
+
+		if rebuild, _ := cmd.Flags().GetBool("rebuild"); rebuild {
+			if err := index.Reset(cmd.Context()); err != nil {
+				return err
+			}
+		}
+		stats, err := index.Update(cmd.Context(), func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\rEmbedding chunks %d/%d", done, total)
+			if done == total {
+				fmt.Fprintln(os.Stderr)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Indexed %d files in %d chunks: %d embedded, %d removed\n", stats.Files, stats.Chunks, stats.Embedded, stats.Removed)
+		return nil
+	},
+}
+
+var indexSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the index like the codesearch tool",
+	Example: `
+crush index search "where are users authenticated"
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		index, err := openCodeSearchIndex(cmd)
+		if err != nil {
+			return err
+		}
+		defer index.Close()
+
+		limit, _ := cmd.Flags().GetInt("limit")
+		results, err := index.Search(cmd.Context(), strings.Join(args, " "), limit)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("No results found, is the project indexed?")
+			return nil
+		}
+		for _, r := range results {
+			fmt.Printf("%.2f  %s:%d-%d\n", r.Score, r.Path, r.StartLine, r.EndLine)
+		}
+		return nil
+	},
+}
+
+func openCodeSearchIndex(cmd *cobra.Command) (*codesearch.Index, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	debug, _ := cmd.Flags().GetBool("debug")
+	// The embedding client reads the current config.
+	cfg, err := config.Init(cwd, profile, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	index, err := codesearch.OpenProject(cmd.Context(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the index: %w", err)
+	}
+	return index, nil
+}
+
+func init() {
+	indexCmd.Flags().Bool("rebuild", false, "Index every file again")
+	indexSearchCmd.Flags().IntP("limit", "l", 10, "Number of results")
+
+	indexCmd.AddCommand(indexSearchCmd)
+	rootCmd.AddCommand(indexCmd)
+}
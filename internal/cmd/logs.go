@@ -8,9 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/crush/internal/config"
+	crushlog "github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/log/v2"
 	"github.com/nxadm/tail"
 	"github.com/spf13/cobra"
@@ -22,6 +24,13 @@ var logsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "View crush logs",
 	Long:  `View the logs generated by Crush. This command allows you to see the log output for debugging and monitoring.`,
+	Example: `
+# Follow the logs of the providers
+crush logs --follow --filter provider
+
+# Show the last 200 lines of the logs of the tools and the LSP servers
+crush logs --tail 200 --filter tools,lsp
+  `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cwd, err := cmd.Flags().GetString("cwd")
 		if err != nil {
@@ -38,10 +47,21 @@ var logsCmd = &cobra.Command{
 			return fmt.Errorf("failed to get tail flag: %v", err)
 		}
 
+		filter, err := cmd.Flags().GetStringSlice("filter")
+		if err != nil {
+			return fmt.Errorf("failed to get filter flag: %v", err)
+		}
+		for _, subsystem := range filter {
+			if !slices.Contains(crushlog.Subsystems, subsystem) {
+				return fmt.Errorf("unknown subsystem %q, expected one of %s", subsystem, strings.Join(crushlog.Subsystems, ", "))
+			}
+		}
+
 		log.SetLevel(log.DebugLevel)
 		log.SetOutput(os.Stdout)
 
-		cfg, err := config.Load(cwd, false)
+		profile, _ := cmd.Flags().GetString("profile")
+		cfg, err := config.Load(cwd, profile, false)
 		if err != nil {
 			return fmt.Errorf("failed to load configuration: %v", err)
 		}
@@ -53,20 +73,21 @@ var logsCmd = &cobra.Command{
 		}
 
 		if follow {
-			return followLogs(cmd.Context(), logsFile, tailLines)
+			return followLogs(cmd.Context(), logsFile, tailLines, filter)
 		}
 
-		return showLogs(logsFile, tailLines)
+		return showLogs(logsFile, tailLines, filter)
 	},
 }
 
 func init() {
 	logsCmd.Flags().BoolP("follow", "f", false, "Follow log output")
 	logsCmd.Flags().IntP("tail", "t", defaultTailLines, "Show only the last N lines default: 1000 for performance")
+	logsCmd.Flags().StringSlice("filter", nil, "Show only the logs of the subsystems: provider, tools, lsp, or tui")
 	rootCmd.AddCommand(logsCmd)
 }
 
-func followLogs(ctx context.Context, logsFile string, tailLines int) error {
+func followLogs(ctx context.Context, logsFile string, tailLines int, filter []string) error {
 	t, err := tail.TailFile(logsFile, tail.Config{
 		Follow: false,
 		ReOpen: false,
@@ -78,7 +99,7 @@ func followLogs(ctx context.Context, logsFile string, tailLines int) error {
 
 	var lines []string
 	for line := range t.Lines {
-		if line.Err != nil {
+		if line.Err != nil || !matchesFilter(line.Text, filter) {
 			continue
 		}
 		lines = append(lines, line.Text)
@@ -111,7 +132,7 @@ func followLogs(ctx context.Context, logsFile string, tailLines int) error {
 	for {
 		select {
 		case line := <-t.Lines:
-			if line.Err != nil {
+			if line.Err != nil || !matchesFilter(line.Text, filter) {
 				continue
 			}
 			printLogLine(line.Text)
@@ -121,7 +142,7 @@ func followLogs(ctx context.Context, logsFile string, tailLines int) error {
 	}
 }
 
-func showLogs(logsFile string, tailLines int) error {
+func showLogs(logsFile string, tailLines int, filter []string) error {
 	t, err := tail.TailFile(logsFile, tail.Config{
 		Follow:      false,
 		ReOpen:      false,
@@ -135,7 +156,7 @@ func showLogs(logsFile string, tailLines int) error {
 
 	var lines []string
 	for line := range t.Lines {
-		if line.Err != nil {
+		if line.Err != nil || !matchesFilter(line.Text, filter) {
 			continue
 		}
 		lines = append(lines, line.Text)
@@ -155,9 +176,34 @@ func showLogs(logsFile string, tailLines int) error {
 	return nil
 }
 
+// matchesFilter reports whether the log line is of one of the subsystems of
+// the filter, or the filter is empty.
+func matchesFilter(lineText string, filter []string) bool {
+	return len(filter) == 0 || slices.Contains(filter, logSubsystem(lineText))
+}
+
+// logSubsystem returns the subsystem of the log line, in JSON or as
+// key=value pairs.
+func logSubsystem(lineText string) string {
+	var data struct {
+		Subsystem string `json:"subsystem"`
+	}
+	if err := json.Unmarshal([]byte(lineText), &data); err == nil {
+		return data.Subsystem
+	}
+	_, rest, ok := strings.Cut(lineText, " subsystem=")
+	if !ok {
+		return ""
+	}
+	subsystem, _, _ := strings.Cut(rest, " ")
+	return subsystem
+}
+
 func printLogLine(lineText string) {
 	var data map[string]any
 	if err := json.Unmarshal([]byte(lineText), &data); err != nil {
+		// Logs in the text format are printed as they are.
+		fmt.Println(lineText)
 		return
 	}
 	msg := data["msg"]
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/lsp/install"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Manage LSP servers",
+	Long:  `Check the language servers of the configuration and of the languages detected in the working directory, and install the missing ones.`,
+}
+
+var lspListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the LSP servers and where their commands are",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if len(cfg.LSP) == 0 {
+			fmt.Println("No LSP servers configured or detected")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCOMMAND\tPATH")
+		for _, l := range cfg.LSP.Sorted() {
+			path := "disabled"
+			if !l.LSP.Disabled {
+				if path, err = install.Command(cmd.Context(), l.Name, l.LSP, false); err != nil {
+					path = err.Error()
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", l.Name, strings.Join(append([]string{l.LSP.Command}, l.LSP.Args...), " "), path)
+		}
+		return w.Flush()
+	},
+}
+
+var lspInstallCmd = &cobra.Command{
+	Use:   "install [language...]",
+	Short: "Install the known LSP servers of languages",
+	Long: fmt.Sprintf(`Install the language servers of the given languages, or the missing ones of
+the languages detected in the working directory, in the data directory of
+Crush shared by the projects. The known languages are %s.
+
+gopls is installed with go, rust-analyzer is downloaded from its releases,
+and the TypeScript and Python servers are installed with npm.`, strings.Join(knownLanguages(), ", ")),
+	Example: `
+# Install the missing servers of the project
+crush lsp install
+
+# Install gopls, even if it's in the PATH
+crush lsp install go
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		languages := args
+		if len(languages) == 0 {
+			cfg, err := loadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			for _, l := range cfg.LSP.Sorted() {
+				if known, ok := config.LookupKnownLSP(l.Name); !ok || l.LSP.Disabled || l.LSP.Command != known.Command {
+					continue
+				}
+				if _, err := install.Command(cmd.Context(), l.Name, l.LSP, false); err != nil {
+					languages = append(languages, l.Name)
+				}
+			}
+			if len(languages) == 0 {
+				fmt.Println("No missing LSP servers")
+				return nil
+			}
+		}
+
+		for _, language := range languages {
+			known, ok := config.LookupKnownLSP(language)
+			if !ok {
+				return fmt.Errorf("unknown language %s, expected one of %s", language, strings.Join(knownLanguages(), ", "))
+			}
+			fmt.Printf("Installing %s...\n", known.Command)
+			path, err := install.Install(cmd.Context(), install.Dir(), known)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s\n", path)
+		}
+		return nil
+	},
+}
+
+func knownLanguages() []string {
+	var languages []string
+	for _, known := range config.KnownLSPs {
+		languages = append(languages, known.Language)
+	}
+	return slices.Sorted(slices.Values(languages))
+}
+
+func init() {
+	lspCmd.AddCommand(lspListCmd)
+	lspCmd.AddCommand(lspInstallCmd)
+	rootCmd.AddCommand(lspCmd)
+}
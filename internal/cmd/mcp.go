@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/mcpauth"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Manage MCP servers",
+	Long:  `Check the Model Context Protocol servers of the configuration, log in to remote ones, or serve the tools of Crush to other agents.`,
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the tools of Crush over MCP",
+	Long: `Serve the file, shell, search, and LSP tools of Crush over the Model Context
+Protocol, for other agents such as Claude Desktop or IDE agents.
+
+Tool calls run in the working directory, in a session of their own. They are
+approved without asking, as the client is expected to confirm them, but
+destructive actions are denied unless allow_destructive is set under
+permissions.`,
+	Example: `
+# Serve over stdin and stdout, as configured in an MCP client
+crush mcp serve --stdio
+
+# Serve the tools of another project
+crush mcp serve --stdio --cwd /path/to/project
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stdio, _ := cmd.Flags().GetBool("stdio")
+		if !stdio {
+			return fmt.Errorf("--stdio is required, it's the only supported transport")
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		return app.ServeMCP(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+var mcpLoginCmd = &cobra.Command{
+	Use:   "login <name>",
+	Short: "Log in to a remote MCP server",
+	Long: `Log in to a remote MCP server configured with oauth, with the OAuth device flow:
+open the printed URL, enter the code, and Crush saves the token in the data
+directory. The token is refreshed as needed; log in again once it can't be.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, m, err := loadMCPOAuth(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		metadata, err := mcpauth.Discover(cmd.Context(), m.URL, *m.OAuth)
+		if err != nil {
+			return err
+		}
+		token, err := mcpauth.Login(cmd.Context(), metadata, *m.OAuth, func(code mcpauth.DeviceCode) {
+			fmt.Printf("Open %s and enter the code %s\n", code.VerificationURI, code.UserCode)
+			if code.VerificationURIComplete != "" {
+				fmt.Printf("or open %s\n", code.VerificationURIComplete)
+			}
+			fmt.Println("Waiting for authorization...")
+		})
+		if err != nil {
+			return err
+		}
+		if err := mcpauth.NewStore(cfg.Options.DataDirectory).Save(args[0], token); err != nil {
+			return err
+		}
+		fmt.Printf("Logged in to %s\n", args[0])
+		return nil
+	},
+}
+
+var mcpLogoutCmd = &cobra.Command{
+	Use:   "logout <name>",
+	Short: "Forget the token of a remote MCP server",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, _, err := loadMCPOAuth(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		if err := mcpauth.NewStore(cfg.Options.DataDirectory).Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Logged out of %s\n", args[0])
+		return nil
+	},
+}
+
+var mcpListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the MCP servers and their health",
+	Long: `Start the enabled MCP servers of the configuration to report whether they
+run and how many tools they have, then stop them. The tools are cached for the
+next sessions to start the servers on first use.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkMCPServers(cmd)
+	},
+}
+
+var mcpRestartCmd = &cobra.Command{
+	Use:   "restart [name...]",
+	Short: "Restart MCP servers and refresh their tools",
+	Long: `Start the given MCP servers, all of them if none is given, to check them
+and refresh the cache of their tools, as after updating a server. Running
+sessions restart their servers from the command palette.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkMCPServers(cmd, args...)
+	},
+}
+
+func checkMCPServers(cmd *cobra.Command, names ...string) error {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+	if len(cfg.MCP) == 0 {
+		fmt.Println("No MCP servers configured")
+		return nil
+	}
+	states, err := agent.CheckMCPServers(cmd.Context(), cfg, names...)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tTOOLS\tERROR")
+	for _, state := range states {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", state.Name, state.Status, state.Tools, state.Error)
+	}
+	return w.Flush()
+}
+
+func loadConfig(cmd *cobra.Command) (*config.Config, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	debug, _ := cmd.Flags().GetBool("debug")
+	cfg, err := config.Load(cwd, profile, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	return cfg, nil
+}
+
+// loadMCPOAuth returns the config of an MCP server using OAuth.
+func loadMCPOAuth(cmd *cobra.Command, name string) (*config.Config, config.MCPConfig, error) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return nil, config.MCPConfig{}, err
+	}
+	m, ok := cfg.MCP[name]
+	if !ok {
+		return nil, config.MCPConfig{}, fmt.Errorf("no MCP server named %s", name)
+	}
+	if m.OAuth == nil {
+		return nil, config.MCPConfig{}, fmt.Errorf("MCP server %s isn't configured with oauth", name)
+	}
+	return cfg, m, nil
+}
+
+func init() {
+	mcpServeCmd.Flags().Bool("stdio", false, "Serve over stdin and stdout")
+	mcpCmd.AddCommand(mcpServeCmd)
+	mcpCmd.AddCommand(mcpListCmd)
+	mcpCmd.AddCommand(mcpRestartCmd)
+	mcpCmd.AddCommand(mcpLoginCmd)
+	mcpCmd.AddCommand(mcpLogoutCmd)
+	rootCmd.AddCommand(mcpCmd)
+}
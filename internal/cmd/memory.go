@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/memory"
+	"github.com/spf13/cobra"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Manage the long-term memory of the project",
+	Long:  `Manage the facts the agent learned about the project, such as its build commands, code conventions, and gotchas. With options.memory.enabled set, the agent adds them with the memory tool and is given the relevant ones each turn.`,
+}
+
+var memoryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the memories of the project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openMemory(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		memories, err := store.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if len(memories) == 0 {
+			fmt.Println("No memories")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tADDED\tMEMORY")
+		for _, m := range memories {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", m.ID, m.CreatedAt.Format(time.DateOnly), m.Content)
+		}
+		return w.Flush()
+	},
+}
+
+var memoryAddCmd = &cobra.Command{
+	Use:   "add <fact>",
+	Short: "Add a memory to the project",
+	Example: `
+crush memory add "Run the tests with task test, go test misses the build tags"
+  `,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openMemory(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		m, added, err := store.Add(cmd.Context(), strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		if !added {
+			fmt.Printf("Already remembered as %s\n", m.ID)
+			return nil
+		}
+		fmt.Printf("Added memory %s\n", m.ID)
+		return nil
+	},
+}
+
+var memoryDeleteCmd = &cobra.Command{
+	Use:   "delete <id>...",
+	Short: "Delete memories of the project",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := openMemory(cmd)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		for _, id := range args {
+			err := store.Delete(cmd.Context(), id)
+			if errors.Is(err, memory.ErrNotFound) {
+				return fmt.Errorf("no memory with ID %s", id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func openMemory(cmd *cobra.Command) (*memory.Store, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	debug, _ := cmd.Flags().GetBool("debug")
+	// The embedding client reads the current config.
+	cfg, err := config.Init(cwd, profile, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	store, err := memory.OpenProject(cmd.Context(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the memory: %w", err)
+	}
+	return store, nil
+}
+
+func init() {
+	memoryCmd.AddCommand(memoryListCmd)
+	memoryCmd.AddCommand(memoryAddCmd)
+	memoryCmd.AddCommand(memoryDeleteCmd)
+	rootCmd.AddCommand(memoryCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/spf13/cobra"
+)
+
+var permissionsCmd = &cobra.Command{
+	Use:   "permissions",
+	Short: "Manage the permissions granted in the project",
+	Long:  `Review and revoke the tool calls allowed in the project with "Allow in Project" in the permission dialog. Revoking a grant applies to running sessions too.`,
+}
+
+var permissionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the permissions granted in the project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		grants, err := loadPermissionGrants(cmd)
+		if err != nil {
+			return err
+		}
+		list, err := grants.List()
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("No permissions granted")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTOOL\tTARGET\tGRANTED")
+		for _, grant := range list {
+			tool := grant.ToolName
+			if grant.Action != "" {
+				tool += ":" + grant.Action
+			}
+			target := grant.Target()
+			if target == "" {
+				target = "any"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", grant.ID, tool, target, grant.CreatedAt.Local().Format(time.DateTime))
+		}
+		return w.Flush()
+	},
+}
+
+var permissionsRevokeCmd = &cobra.Command{
+	Use:   "revoke [id...]",
+	Short: "Revoke permissions granted in the project",
+	Example: `
+# Revoke a permission, see crush permissions list for the IDs
+crush permissions revoke 1a2b3c4d
+
+# Revoke all of them
+crush permissions revoke --all
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) > 0) {
+			return fmt.Errorf("either IDs or --all is required")
+		}
+		grants, err := loadPermissionGrants(cmd)
+		if err != nil {
+			return err
+		}
+		return grants.Revoke(args...)
+	},
+}
+
+func loadPermissionGrants(cmd *cobra.Command) (*permission.Grants, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	debug, _ := cmd.Flags().GetBool("debug")
+	cfg, err := config.Load(cwd, profile, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	return permission.NewGrants(cfg.Options.DataDirectory), nil
+}
+
+func init() {
+	permissionsRevokeCmd.Flags().Bool("all", false, "Revoke all the permissions")
+
+	permissionsCmd.AddCommand(permissionsListCmd)
+	permissionsCmd.AddCommand(permissionsRevokeCmd)
+	rootCmd.AddCommand(permissionsCmd)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/bundle"
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+)
+
+const passphraseEnv = "CRUSH_PROFILE_PASSPHRASE"
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Move your setup between machines",
+	Long:  `Bundle your config files, with their preferences and permission grants, your custom commands, and the memory files of the project into a single encrypted file, to restore them on another machine or share them with a teammate. The passphrase is read from ` + passphraseEnv + `, or asked for.`,
+}
+
+var profileExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export your setup to an encrypted file",
+	Example: `
+# Export your setup
+crush profile export setup.crush
+
+# Export it without API keys, headers, environment variables, and tokens,
+# to share it with a teammate
+crush profile export --sanitize team.crush
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sanitize, _ := cmd.Flags().GetBool("sanitize")
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		b, err := bundle.Collect(bundle.DefaultRoots(cfg.WorkingDir()), bundle.DefaultSources(cfg), sanitize)
+		if err != nil {
+			return fmt.Errorf("failed to collect the profile: %w", err)
+		}
+		if len(b.Files) == 0 {
+			return fmt.Errorf("nothing to export")
+		}
+		passphrase, err := readPassphrase(true)
+		if err != nil {
+			return err
+		}
+		data, err := bundle.Encrypt(b, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt the profile: %w", err)
+		}
+		if err := os.WriteFile(args[0], data, 0o600); err != nil {
+			return err
+		}
+
+		counts := map[bundle.Kind]int{}
+		for _, file := range b.Files {
+			counts[file.Kind]++
+		}
+		fmt.Printf("Exported %d config files, %d commands, and %d memory files to %s\n",
+			counts[bundle.KindPreferences], counts[bundle.KindCommands], counts[bundle.KindMemory], args[0])
+		return nil
+	},
+}
+
+var profileImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a setup exported with crush profile export",
+	Long:  `Import a setup exported with crush profile export. Memory files and project commands go to the current project. Existing files are kept unless --force is given.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			return err
+		}
+		b, err := bundle.Decrypt(data, passphrase)
+		if err != nil {
+			return err
+		}
+
+		installed, err := bundle.Install(b, bundle.DefaultRoots(cwd), force)
+		skipped := 0
+		for _, file := range installed {
+			fmt.Printf("%-9s %s\n", file.Status, file.Path)
+			if file.Status == bundle.StatusSkipped {
+				skipped++
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			fmt.Printf("Kept %d existing files, use --force to overwrite them\n", skipped)
+		}
+		return nil
+	},
+}
+
+// readPassphrase returns the passphrase from the environment, or asks for
+// it on the terminal, twice when confirming.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("no passphrase, set %s", passphraseEnv)
+	}
+	ask := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		passphrase, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(passphrase)), err
+	}
+	passphrase, err := ask("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase is empty")
+	}
+	if confirm {
+		again, err := ask("Confirm passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases don't match")
+		}
+	}
+	return passphrase, nil
+}
+
+func init() {
+	profileExportCmd.Flags().Bool("sanitize", false, "Leave out API keys, headers, environment variables, and tokens")
+	profileImportCmd.Flags().Bool("force", false, "Overwrite existing files")
+
+	profileCmd.AddCommand(profileExportCmd)
+	profileCmd.AddCommand(profileImportCmd)
+	rootCmd.AddCommand(profileCmd)
+}
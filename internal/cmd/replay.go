@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/crush/internal/recorder"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <bundle>",
+	Short: "Replay a recorded session",
+	Long: `Replay a session recorded with the record option: the prompts, the requests to the providers, their responses as they were streamed, and the calls of the tools with their results.
+The replay is the same every time, without requests nor tools running, for the bundle to be attached to bug reports about the behavior of the providers.
+The bundles are in the recordings directory of the data directory, one per session.`,
+	Example: `
+# Replay a session
+crush replay .crush/recordings/<session-id>.jsonl
+
+# Replay it at the pace it went
+crush replay --realtime .crush/recordings/<session-id>.jsonl
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		realtime, _ := cmd.Flags().GetBool("realtime")
+		entries, err := recorder.Load(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to load the bundle: %w", err)
+		}
+		return recorder.Replay(cmd.Context(), os.Stdout, entries, recorder.ReplayOptions{Realtime: realtime})
+	},
+}
+
+func init() {
+	replayCmd.Flags().Bool("realtime", false, "Stream the responses at the pace they were recorded")
+	rootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/report"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+)
+
+const issuesURL = "https://github.com/charmbracelet/crush/issues/new"
+
+var reportBugCmd = &cobra.Command{
+	Use:   "report-bug",
+	Short: "Gather diagnostics to attach to a bug report",
+	Long:  `Gather the environment, the config, the recent logs, and the metadata of the sessions into a zip archive to attach to an issue. API keys, headers, tokens, environment variables of MCP servers, and the values of secret environment variables are redacted, and neither the messages nor the titles of the sessions are included. Review the archive before sharing it.`,
+	Example: `
+# Write crush-report-<time>.zip in the current directory
+crush report-bug
+
+# Include more of the logs
+crush report-bug --tail 5000 -o report.zip
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		tailLines, _ := cmd.Flags().GetInt("tail")
+		if output == "" {
+			output = fmt.Sprintf("crush-report-%s.zip", time.Now().Format("20060102-150405"))
+		}
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		// A broken config is a common reason to report a bug, the report is
+		// written without it.
+		in := report.Input{LogLines: tailLines}
+		cfg, err := loadStorageConfig(cmd)
+		dataDir := filepath.Join(cwd, ".crush")
+		if err != nil {
+			in.ConfigErr = err
+		} else {
+			in.Config = cfg
+			dataDir = cfg.Options.DataDirectory
+			in.Sessions, in.SessionsErr = listSessions(cmd, cfg)
+		}
+		in.LogPath = filepath.Join(dataDir, "logs", "crush.log")
+
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		names, err := report.Write(f, in)
+		if err != nil {
+			f.Close()
+			os.Remove(output)
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Wrote %s with:\n", output)
+		for _, name := range names {
+			fmt.Printf("  %s\n", name)
+		}
+		fmt.Printf("Review it, then attach it to an issue: %s\n", issuesURL)
+		return nil
+	},
+}
+
+func listSessions(cmd *cobra.Command, cfg *config.Config) ([]session.Session, error) {
+	store, err := openStore(cmd.Context(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return session.NewService(store).List(cmd.Context())
+}
+
+func init() {
+	reportBugCmd.Flags().StringP("output", "o", "", "File to write the archive to")
+	reportBugCmd.Flags().IntP("tail", "t", defaultTailLines, "Number of log lines to include")
+	rootCmd.AddCommand(reportBugCmd)
+}
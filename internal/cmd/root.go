@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -10,7 +11,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
-	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/scheduler"
 	"github.com/charmbracelet/crush/internal/tui"
 	"github.com/charmbracelet/crush/internal/version"
 	"github.com/charmbracelet/fang"
@@ -21,6 +22,7 @@ import (
 func init() {
 	rootCmd.PersistentFlags().StringP("cwd", "c", "", "Current working directory")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "Configuration profile to use (defaults to $CRUSH_PROFILE)")
 
 	rootCmd.Flags().BoolP("help", "h", false, "Help")
 	rootCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
@@ -44,6 +46,9 @@ crush -d
 # Run with debug logging in a specific directory
 crush -d -c /path/to/project
 
+# Run with the "work" profile from the config
+crush --profile work
+
 # Print version
 crush -v
 
@@ -60,16 +65,26 @@ crush -y
 		}
 		defer app.Shutdown()
 
+		// Run the scheduled and queued tasks in the background.
+		tasks := scheduler.New(app)
+		go tasks.Run(cmd.Context())
+
 		// Set up the TUI.
 		program := tea.NewProgram(
-			tui.New(app),
+			tui.New(app, tasks),
 			tea.WithAltScreen(),
 			tea.WithContext(cmd.Context()),
 			tea.WithMouseCellMotion(),            // Use cell motion instead of all motion to reduce event flooding
+			tea.WithReportFocus(),                // Only notify when the terminal isn't focused
 			tea.WithFilter(tui.MouseEventFilter), // Filter mouse events based on focus state
 		)
 
 		go app.Subscribe(program)
+		go func() {
+			for event := range tasks.Subscribe(cmd.Context()) {
+				program.Send(event)
+			}
+		}()
 
 		if _, err := program.Run(); err != nil {
 			slog.Error("TUI run error", "error", err)
@@ -86,6 +101,10 @@ func Execute() {
 		fang.WithVersion(version.Version),
 		fang.WithNotifySignal(os.Interrupt),
 	); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -95,6 +114,7 @@ func Execute() {
 func setupApp(cmd *cobra.Command) (*app.App, error) {
 	debug, _ := cmd.Flags().GetBool("debug")
 	yolo, _ := cmd.Flags().GetBool("yolo")
+	profile, _ := cmd.Flags().GetString("profile")
 	ctx := cmd.Context()
 
 	cwd, err := ResolveCwd(cmd)
@@ -102,7 +122,7 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 		return nil, err
 	}
 
-	cfg, err := config.Init(cwd, debug)
+	cfg, err := config.Init(cwd, profile, debug)
 	if err != nil {
 		return nil, err
 	}
@@ -112,13 +132,13 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	}
 	cfg.Permissions.SkipRequests = yolo
 
-	// Connect to DB; this will also run migrations.
-	conn, err := db.Connect(ctx, cfg.Options.DataDirectory)
+	// Open the store; this will also run migrations.
+	store, err := openStore(ctx, cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	appInstance, err := app.New(ctx, conn, cfg)
+	appInstance, err := app.New(ctx, store, cfg)
 	if err != nil {
 		slog.Error("Failed to create app instance", "error", err)
 		return nil, err
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
 
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/spf13/cobra"
 )
 
@@ -22,9 +26,13 @@ echo "What is this code doing?" | crush run
 
 # Run with quiet mode (no spinner)
 crush run -q "Generate a README for this project"
+
+# Run in CI, printing the progress as JSON lines
+crush run --headless "Fix the failing tests" | jq -c 'select(.type == "result")'
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		headless, _ := cmd.Flags().GetBool("headless")
 
 		app, err := setupApp(cmd)
 		if err != nil {
@@ -48,11 +56,44 @@ crush run -q "Generate a README for this project"
 			return fmt.Errorf("no prompt provided")
 		}
 
+		if headless {
+			return headlessExit(app.RunHeadless(cmd.Context(), prompt, os.Stdout))
+		}
+
 		// Run non-interactive flow using the App method
 		return app.RunNonInteractive(cmd.Context(), prompt, quiet)
 	},
 }
 
+// Exit codes of headless runs, besides 0 when they succeed.
+const (
+	exitFailed      = 1
+	exitInterrupted = 130
+)
+
+// exitError makes crush exit with its code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// headlessExit returns the error of a headless run with its exit code.
+func headlessExit(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.Canceled) || errors.Is(err, agent.ErrRequestCancelled):
+		return &exitError{code: exitInterrupted, err: err}
+	default:
+		return &exitError{code: exitFailed, err: err}
+	}
+}
+
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().Bool("headless", false, "Print the progress as JSON lines instead of the answer, for scripts and CI")
 }
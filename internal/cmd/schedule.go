@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/scheduler"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage tasks run on a schedule or in the background",
+	Long:  `Manage prompts and custom commands run headless on a cron schedule, or once in the background. Tasks run while crush or 'crush serve' is up for the project, each run in a new session.`,
+}
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add <spec>",
+	Short: "Schedule a prompt or custom command",
+	Long:  `Schedule a prompt or custom command. The spec is a cron expression with five fields (minute, hour, day of month, month, day of week), or one of @hourly, @daily, @weekly, @monthly, and @yearly.`,
+	Example: `
+# Run the weekly-deps-report custom command every Monday at 7:00
+crush schedule add "0 7 * * 1" --command weekly-deps-report
+
+# Fill in the arguments of a command and post the result to a webhook
+crush schedule add @daily --command user:triage --arg LABEL=bug --webhook https://hooks.slack.com/services/...
+
+# Run a prompt every hour
+crush schedule add @hourly --prompt "Check the CI status of the main branch"
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := scheduler.ParseSpec(args[0]); err != nil {
+			return err
+		}
+		task, path, err := newTask(cmd, args[0])
+		if err != nil {
+			return err
+		}
+		tasks, err := scheduler.LoadTasks(path)
+		if err != nil {
+			return err
+		}
+		if err := scheduler.SaveTasks(path, append(tasks, task)); err != nil {
+			return err
+		}
+		fmt.Printf("Scheduled task %s\n", task.ID)
+		return nil
+	},
+}
+
+var scheduleEnqueueCmd = &cobra.Command{
+	Use:   "enqueue",
+	Short: "Queue a prompt or custom command to run once in the background",
+	Long:  `Queue a prompt or custom command to run once, headless, in a new session. It runs as soon as crush or 'crush serve' is up for the project, its session can be reviewed from Background Tasks in the command palette.`,
+	Example: `
+# Fix the lint warnings while you work on something else
+crush schedule enqueue --prompt "Run the linter and fix the warnings"
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		task, path, err := newTask(cmd, "")
+		if err != nil {
+			return err
+		}
+		tasks, err := scheduler.LoadTasks(path)
+		if err != nil {
+			return err
+		}
+		if err := scheduler.SaveTasks(path, append(tasks, task)); err != nil {
+			return err
+		}
+		fmt.Printf("Queued task %s\n", task.ID)
+		return nil
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled tasks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadScheduleConfig(cmd)
+		if err != nil {
+			return err
+		}
+		tasks, err := scheduler.LoadTasks(scheduler.TasksPath(cfg))
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			fmt.Println("No scheduled tasks")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSPEC\tTASK\tNEXT RUN\tLAST RUN")
+		for _, task := range tasks {
+			spec, next := task.Spec, "never"
+			if task.Queued() {
+				spec = "queued"
+				if task.LastRun == nil {
+					next = "now"
+				}
+			} else if parsed, err := scheduler.ParseSpec(task.Spec); err != nil {
+				next = "invalid spec"
+			} else if t := parsed.Next(time.Now()); !t.IsZero() {
+				next = t.Format(time.DateTime)
+			}
+			last := "never"
+			if task.LastRun != nil {
+				last = task.LastRun.StartedAt.Format(time.DateTime)
+				switch {
+				case task.Running():
+					last += " (running)"
+				case task.LastRun.Error != "":
+					last += " (failed)"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", task.ID, spec, task.Name(), next, last)
+		}
+		return w.Flush()
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove <id>",
+	Short: "Remove a scheduled task",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadScheduleConfig(cmd)
+		if err != nil {
+			return err
+		}
+		path := scheduler.TasksPath(cfg)
+		tasks, err := scheduler.LoadTasks(path)
+		if err != nil {
+			return err
+		}
+		remaining := slices.DeleteFunc(tasks, func(task scheduler.Task) bool {
+			return task.ID == args[0]
+		})
+		if len(remaining) == len(tasks) {
+			return fmt.Errorf("no scheduled task with ID %s", args[0])
+		}
+		return scheduler.SaveTasks(path, remaining)
+	},
+}
+
+// newTask returns the task with the spec described by the flags, and the path
+// of the file of the tasks of the project.
+func newTask(cmd *cobra.Command, spec string) (scheduler.Task, string, error) {
+	command, _ := cmd.Flags().GetString("command")
+	prompt, _ := cmd.Flags().GetString("prompt")
+	argValues, _ := cmd.Flags().GetStringArray("arg")
+	webhook, _ := cmd.Flags().GetString("webhook")
+
+	if (command == "") == (prompt == "") {
+		return scheduler.Task{}, "", fmt.Errorf("exactly one of --command or --prompt is required")
+	}
+	task := scheduler.Task{
+		ID:        uuid.New().String()[:8],
+		Spec:      spec,
+		Command:   command,
+		Prompt:    prompt,
+		Webhook:   webhook,
+		CreatedAt: time.Now(),
+	}
+	for _, arg := range argValues {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return scheduler.Task{}, "", fmt.Errorf("invalid argument %q, expected NAME=value", arg)
+		}
+		if task.Args == nil {
+			task.Args = map[string]string{}
+		}
+		task.Args[name] = value
+	}
+
+	cfg, err := loadScheduleConfig(cmd)
+	if err != nil {
+		return scheduler.Task{}, "", err
+	}
+	// Catch typos in the command or missing arguments now rather than
+	// when the task runs.
+	if _, err := task.ResolvePrompt(cfg); err != nil {
+		return scheduler.Task{}, "", err
+	}
+	return task, scheduler.TasksPath(cfg), nil
+}
+
+func loadScheduleConfig(cmd *cobra.Command) (*config.Config, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	debug, _ := cmd.Flags().GetBool("debug")
+	cfg, err := config.Load(cwd, profile, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	return cfg, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{scheduleAddCmd, scheduleEnqueueCmd} {
+		cmd.Flags().String("command", "", "Custom command to run, e.g. user:weekly-deps-report")
+		cmd.Flags().String("prompt", "", "Prompt to run")
+		cmd.Flags().StringArray("arg", nil, "Argument of the command as NAME=value, can be repeated")
+		cmd.Flags().String("webhook", "", "URL to post the result of every run to")
+	}
+
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleEnqueueCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRemoveCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 
 	"github.com/charmbracelet/crush/internal/config"
-	"github.com/invopop/jsonschema"
 	"github.com/spf13/cobra"
 )
 
@@ -15,8 +14,7 @@ var schemaCmd = &cobra.Command{
 	Long:   "Generate JSON schema for the crush configuration file",
 	Hidden: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		reflector := new(jsonschema.Reflector)
-		bts, err := json.MarshalIndent(reflector.Reflect(&config.Config{}), "", "  ")
+		bts, err := json.MarshalIndent(config.Schema(), "", "  ")
 		if err != nil {
 			return fmt.Errorf("failed to marshal schema: %w", err)
 		}
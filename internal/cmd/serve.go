@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"cmp"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/scheduler"
+	"github.com/charmbracelet/crush/internal/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run crush headless behind an HTTP API",
+	Long: `Run crush without the TUI, serving an HTTP API to start sessions and answer
+permission requests. Requests must carry the server token, taken from --token,
+$CRUSH_SERVE_TOKEN, or generated and printed on startup. Tasks scheduled with
+'crush schedule' run while the server is up.
+
+To share the server with a team, configure users under serve.users, each with
+their own token, sessions, budget, and permission policy. Roles, built-in or
+configured under serve.roles, restrict the tools of users and who answers
+their permission requests. Their actions are recorded in serve-audit.log in the
+data directory. The server token, if set, authenticates an admin who sees all
+sessions.
+
+Sessions can be watched live by other users or through read-only links, and
+their control handed over, for pairing and demos.
+
+The server also speaks the OpenAI chat completions API under /v1, so editors
+and tools can use Crush as their model, each conversation being a session.`,
+	Example: `
+# Serve the API on the default address
+crush serve
+
+# Serve the web dashboard to supervise sessions from a browser
+crush serve --dashboard
+
+# Listen on all interfaces with a fixed token
+crush serve --addr 0.0.0.0:8787 --token my-secret-token
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, _ := cmd.Flags().GetString("addr")
+		dashboard, _ := cmd.Flags().GetBool("dashboard")
+		token, _ := cmd.Flags().GetString("token")
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		cfg := app.Config()
+		roles := serveRoles(cfg)
+		users, err := serveUsers(cfg, roles)
+		if err != nil {
+			return err
+		}
+		token = cmp.Or(token, os.Getenv("CRUSH_SERVE_TOKEN"))
+		if token == "" && len(users) == 0 {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				return fmt.Errorf("failed to generate token: %w", err)
+			}
+			token = hex.EncodeToString(b)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Token: %s\n", token)
+		}
+
+		var audit io.Writer
+		if len(users) > 0 {
+			auditPath := filepath.Join(cfg.Options.DataDirectory, "serve-audit.log")
+			f, err := os.OpenFile(auditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+			if err != nil {
+				return fmt.Errorf("failed to open audit log: %w", err)
+			}
+			defer f.Close()
+			audit = f
+			fmt.Fprintf(cmd.ErrOrStderr(), "Serving %d users, audit log: %s\n", len(users), auditPath)
+		}
+
+		switch {
+		case dashboard && token != "":
+			fmt.Fprintf(cmd.ErrOrStderr(), "Dashboard: http://%s/#token=%s\n", addr, token)
+		case dashboard:
+			fmt.Fprintf(cmd.ErrOrStderr(), "Dashboard: http://%s/#token=<your token>\n", addr)
+		default:
+			fmt.Fprintf(cmd.ErrOrStderr(), "Listening on http://%s\n", addr)
+		}
+
+		ctx := cmd.Context()
+		go scheduler.New(app).Run(ctx)
+
+		srv := server.New(ctx, app, server.Options{
+			Token:     token,
+			Users:     users,
+			Roles:     roles,
+			Audit:     audit,
+			Dashboard: dashboard,
+		})
+		return srv.ListenAndServe(ctx, addr)
+	},
+}
+
+// serveRoles returns the built-in roles overridden by the ones of the serve
+// config.
+func serveRoles(cfg *config.Config) map[string]server.Role {
+	roles := server.DefaultRoles()
+	if cfg.Serve == nil {
+		return roles
+	}
+	for name, r := range cfg.Serve.Roles {
+		roles[name] = server.Role{
+			Tools:            r.Tools,
+			AllowedTools:     r.AllowedTools,
+			AllowDestructive: r.AllowDestructive,
+			Approve:          server.Approval(cmp.Or(r.Approve, string(server.ApproveNone))),
+		}
+	}
+	return roles
+}
+
+// serveUsers returns the users of the serve config, with their tokens
+// resolved.
+func serveUsers(cfg *config.Config, roles map[string]server.Role) ([]server.User, error) {
+	if cfg.Serve == nil {
+		return nil, nil
+	}
+	names := slices.Sorted(maps.Keys(cfg.Serve.Users))
+	users := make([]server.User, 0, len(names))
+	tokens := make(map[string]string, len(names))
+	for _, name := range names {
+		u := cfg.Serve.Users[name]
+		token, err := cfg.Resolve(u.Token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the token of user %s: %w", name, err)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("user %s has no token", name)
+		}
+		if other, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("users %s and %s have the same token", other, name)
+		}
+		tokens[token] = name
+		if _, ok := roles[u.Role]; u.Role != "" && !ok {
+			return nil, fmt.Errorf("user %s has unknown role %q, available roles: %s", name, u.Role, strings.Join(slices.Sorted(maps.Keys(roles)), ", "))
+		}
+		users = append(users, server.User{
+			Name:             name,
+			Token:            token,
+			Role:             u.Role,
+			Admin:            u.Admin,
+			Budget:           u.Budget,
+			AllowedTools:     u.AllowedTools,
+			DeniedTools:      u.DeniedTools,
+			AllowDestructive: u.AllowDestructive,
+		})
+	}
+	return users, nil
+}
+
+func init() {
+	serveCmd.Flags().String("addr", "127.0.0.1:8787", "Address to listen on")
+	serveCmd.Flags().Bool("dashboard", false, "Serve the web dashboard")
+	serveCmd.Flags().String("token", "", "Token to authenticate requests (defaults to $CRUSH_SERVE_TOKEN or a random token)")
+	rootCmd.AddCommand(serveCmd)
+}
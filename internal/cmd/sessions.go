@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/transcript"
+	"github.com/spf13/cobra"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "List, export, and import sessions",
+	Long:  `List the sessions of the project, export them as transcripts to share, and import the JSON exports into the sessions of another machine.`,
+}
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sessions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sessions, err := session.NewService(store).List(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to list the sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			fmt.Println("No sessions")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUPDATED\tMESSAGES\tCOST\tTITLE")
+		for _, s := range sessions {
+			fmt.Fprintf(w, "%s\t%s\t%d\t$%.2f\t%s\n", s.ID, time.Unix(s.UpdatedAt, 0).Format(time.DateTime), s.MessageCount, s.Cost, s.Title)
+		}
+		return w.Flush()
+	},
+}
+
+var sessionsExportCmd = &cobra.Command{
+	Use:   "export <session-id>",
+	Short: "Export a session as a transcript",
+	Long:  `Export the conversation of a session with its tool calls and their results, the diffs of the files it changed, and its tokens and cost. Markdown and HTML transcripts are for reading, JSON ones can be imported with "crush sessions import". The paths of the files in the project are relative to it. Transcripts include the content of the changed files and the output of the tools, review them before sharing.`,
+	Example: `
+# Print the transcript of a session in Markdown
+crush sessions export 3f2a9c1d-4b7e-4c1a-9a8e-2d5f6b7c8e9f
+
+# Save it as a web page
+crush sessions export --format html --output session.html 3f2a9c1d-4b7e-4c1a-9a8e-2d5f6b7c8e9f
+
+# Move it to another machine
+crush sessions export --format json --output session.json 3f2a9c1d-4b7e-4c1a-9a8e-2d5f6b7c8e9f
+  `,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		if !slices.Contains(transcript.Formats, transcript.Format(format)) {
+			return fmt.Errorf("unknown format %q, expected one of %v", format, transcript.Formats)
+		}
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		t, err := transcript.Build(cmd.Context(), session.NewService(store), message.NewService(store), history.NewService(store), args[0], cwd)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if err := transcript.Write(w, t, transcript.Format(format)); err != nil {
+			return fmt.Errorf("failed to write the transcript: %w", err)
+		}
+		if output != "" {
+			fmt.Printf("Exported %s to %s\n", t.Session.Title, output)
+		}
+		return nil
+	},
+}
+
+var sessionsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import a session exported in JSON",
+	Long:  `Import a transcript exported with "crush sessions export --format json" as a new session, to continue it. The relative paths of the changed files are in the project. Importing the same transcript twice creates two sessions.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		t, err := transcript.Read(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sess, err := transcript.Import(cmd.Context(), session.NewService(store), message.NewService(store), history.NewService(store), t, cwd)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %s as session %s\n", sess.Title, sess.ID)
+		return nil
+	},
+}
+
+func init() {
+	sessionsExportCmd.Flags().StringP("format", "f", string(transcript.FormatMarkdown), "Format of the transcript: md, json, or html")
+	sessionsExportCmd.Flags().StringP("output", "o", "", "File to write the transcript to, instead of the standard output")
+
+	sessionsCmd.AddCommand(sessionsListCmd)
+	sessionsCmd.AddCommand(sessionsExportCmd)
+	sessionsCmd.AddCommand(sessionsImportCmd)
+	rootCmd.AddCommand(sessionsCmd)
+}
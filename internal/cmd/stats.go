@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the latency and throughput of the models",
+	Long:  `Show the time to first token, the output tokens per second, and the error rate of the requests to each provider and model, to compare them before picking defaults. Timings only count the successful requests.`,
+	Example: `
+# Stats of the last 30 days
+crush stats
+
+# Stats of the last week
+crush stats --days 7
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days, _ := cmd.Flags().GetInt("days")
+		if days <= 0 {
+			return fmt.Errorf("--days must be positive")
+		}
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		stats, err := session.NewService(store).ModelStats(cmd.Context(), time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			return fmt.Errorf("failed to get the stats: %w", err)
+		}
+		if len(stats) == 0 {
+			fmt.Printf("No requests in the last %d days\n", days)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tMODEL\tREQUESTS\tERRORS\tFIRST TOKEN\tTOKENS/S")
+		for _, s := range stats {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d (%.1f%%)\t%s\t%.1f\n", s.Provider, s.Model, s.Requests, s.Errors, s.ErrorRate()*100, s.AvgFirstToken.Round(time.Millisecond), s.TokensPerSecond())
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	statsCmd.Flags().Int("days", 30, "Number of days of requests to include")
+	rootCmd.AddCommand(statsCmd)
+}
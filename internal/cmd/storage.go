@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage the storage of sessions and messages",
+	Long:  `Manage the storage of sessions, messages, and file history. The backend is selected with options.storage in the configuration, SQLite in the data directory by default.`,
+}
+
+var storageStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the storage backend and its content",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		version, err := store.Version(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get the schema version: %w", err)
+		}
+		snapshot, err := store.Export(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Backend\t%s\n", cfg.Options.Storage.Backend)
+		fmt.Fprintf(w, "Schema version\t%d\n", version)
+		fmt.Fprintf(w, "Sessions\t%d\n", len(snapshot.Sessions))
+		fmt.Fprintf(w, "Messages\t%d\n", len(snapshot.Messages))
+		fmt.Fprintf(w, "File versions\t%d\n", len(snapshot.Files))
+		fmt.Fprintf(w, "Usage records\t%d\n", len(snapshot.Usage))
+		fmt.Fprintf(w, "Request metrics\t%d\n", len(snapshot.Metrics))
+		fmt.Fprintf(w, "Change sets\t%d\n", len(snapshot.ChangeSets))
+		return w.Flush()
+	},
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy the sessions and messages to another storage backend",
+	Long:  `Copy all the sessions, messages, and file history of the configured storage backend to another one. The schema of the destination is created or upgraded first and it must be empty. The configured backend is left untouched, update options.storage once done to switch.`,
+	Example: `
+# Move the sessions of the project to a shared database
+crush storage migrate --backend postgres --dsn "$CRUSH_DATABASE_URL"
+
+# Copy the sessions to another SQLite file
+crush storage migrate --backend sqlite --dsn ./backup.db
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backend, _ := cmd.Flags().GetString("backend")
+		dsn, _ := cmd.Flags().GetString("dsn")
+		if dsn == "" {
+			return fmt.Errorf("--dsn is required")
+		}
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		src, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		dst, err := db.OpenStore(cmd.Context(), backend, dsn, "")
+		if err != nil {
+			return err
+		}
+		defer dst.Close()
+
+		copied, err := db.Copy(cmd.Context(), src, dst)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Copied %d sessions, %d messages, %d file versions, %d usage records, %d request metrics, and %d change sets to %s\n", len(copied.Sessions), len(copied.Messages), len(copied.Files), len(copied.Usage), len(copied.Metrics), len(copied.ChangeSets), backend)
+		return nil
+	},
+}
+
+// openStore opens the configured storage backend, running the schema
+// migrations.
+func openStore(ctx context.Context, cfg *config.Config) (db.Store, error) {
+	storage := cfg.Options.Storage
+	return db.OpenStore(ctx, storage.Backend, storage.DSN, cfg.Options.DataDirectory)
+}
+
+func loadStorageConfig(cmd *cobra.Command) (*config.Config, error) {
+	cwd, err := ResolveCwd(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, _ := cmd.Flags().GetString("profile")
+	debug, _ := cmd.Flags().GetBool("debug")
+	cfg, err := config.Load(cwd, profile, debug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	return cfg, nil
+}
+
+func init() {
+	storageMigrateCmd.Flags().String("backend", db.SQLiteBackend, "Storage backend to copy to")
+	storageMigrateCmd.Flags().String("dsn", "", "Data source name of the destination")
+
+	storageCmd.AddCommand(storageStatusCmd)
+	storageCmd.AddCommand(storageMigrateCmd)
+	rootCmd.AddCommand(storageCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [checkpoint]",
+	Short: "Roll back the changes of the agent to a checkpoint",
+	Long:  `Crush snapshots the working directory before each turn of the agent that changes files. Without arguments, undo restores the checkpoint before the last turn, and the ones before on each call. With the ID of a checkpoint, it restores that one. The working directory is snapshotted before each restore, so a restore can be undone too. The files ignored by git and the excluded ones are left alone.`,
+	Example: `
+# Undo the last turn of the agent
+crush undo
+
+# List the checkpoints
+crush undo --list
+
+# Restore a checkpoint
+crush undo 3f2a9c1d
+  `,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, _ := cmd.Flags().GetBool("list")
+		ctx := cmd.Context()
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store := checkpoint.OpenProject(cfg)
+		if store == nil {
+			return fmt.Errorf("checkpoints are disabled or git isn't installed")
+		}
+
+		if list {
+			checkpoints, err := store.List(ctx)
+			if err != nil {
+				return err
+			}
+			if len(checkpoints) == 0 {
+				fmt.Println("No checkpoints")
+				return nil
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tCREATED\tSESSION\tMESSAGE")
+			for _, c := range checkpoints {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.ShortID(), c.CreatedAt.Format(time.DateTime), c.SessionID, c.Message)
+			}
+			return w.Flush()
+		}
+
+		var restored checkpoint.Checkpoint
+		if len(args) == 1 {
+			restored, err = store.Restore(ctx, args[0])
+		} else {
+			restored, err = store.Undo(ctx)
+		}
+		if errors.Is(err, checkpoint.ErrNothingToUndo) {
+			fmt.Println("Nothing to undo")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s %s\n", restored.ShortID(), restored.Message)
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().BoolP("list", "l", false, "List the checkpoints, the latest first")
+	rootCmd.AddCommand(undoCmd)
+}
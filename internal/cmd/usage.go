@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report the tokens and costs spent",
+	Long:  `Report the requests, tokens, cached tokens, costs, and latency of the responses of the models, by model, provider, session, or day, for expense tracking. Reports print as a table, CSV, or JSON.`,
+	Example: `
+# Spending of the last 30 days by model
+crush usage
+
+# Spending of the last week by day, as CSV
+crush usage --since 7d --by day --format csv
+
+# Spending by session since a date, as JSON
+crush usage --since 2025-09-01 --by session --format json
+  `,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		by, _ := cmd.Flags().GetString("by")
+		format, _ := cmd.Flags().GetString("format")
+		since, err := parseSince(sinceFlag, time.Now())
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "table", "csv", "json":
+		default:
+			return fmt.Errorf("unknown format %q, expected table, csv, or json", format)
+		}
+
+		cfg, err := loadStorageConfig(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := openStore(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+
+		sessions := session.NewService(store)
+		records, err := sessions.ListUsage(cmd.Context(), since)
+		if err != nil {
+			return fmt.Errorf("failed to get the usage: %w", err)
+		}
+		groups, err := session.GroupUsage(records, by)
+		if err != nil {
+			return err
+		}
+		rows := make([]usageRow, 0, len(groups))
+		for _, g := range groups {
+			row := newUsageRow(g)
+			if by == session.UsageBySession {
+				if sess, err := sessions.Get(cmd.Context(), g.Key); err == nil {
+					row.Title = sess.Title
+				}
+			}
+			rows = append(rows, row)
+		}
+
+		switch format {
+		case "csv":
+			return writeUsageCSV(rows)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(rows)
+		}
+		if len(rows) == 0 {
+			fmt.Printf("No requests since %s\n", since.Format(time.DateOnly))
+			return nil
+		}
+		return writeUsageTable(rows, by)
+	},
+}
+
+// usageRow is a group of a usage report.
+type usageRow struct {
+	Key          string  `json:"key"`
+	Title        string  `json:"title,omitempty"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CachedTokens int64   `json:"cached_tokens"`
+	Cost         float64 `json:"cost"`
+	AvgLatencyMs int64   `json:"avg_latency_ms"`
+}
+
+func newUsageRow(g session.UsageGroup) usageRow {
+	return usageRow{
+		Key:          g.Key,
+		Requests:     g.Requests,
+		InputTokens:  g.InputTokens,
+		OutputTokens: g.OutputTokens,
+		CachedTokens: g.CachedTokens,
+		Cost:         g.Cost,
+		AvgLatencyMs: g.AvgLatency().Milliseconds(),
+	}
+}
+
+func writeUsageTable(rows []usageRow, by string) error {
+	var total usageRow
+	var latency time.Duration
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tREQUESTS\tINPUT\tOUTPUT\tCACHED\tCOST\tAVG LATENCY\n", strings.ToUpper(by))
+	for _, r := range rows {
+		key := r.Key
+		if r.Title != "" {
+			key = fmt.Sprintf("%s (%s)", r.Title, r.Key)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t$%.2f\t%s\n", key, r.Requests, r.InputTokens, r.OutputTokens, r.CachedTokens, r.Cost, time.Duration(r.AvgLatencyMs)*time.Millisecond)
+		total.Requests += r.Requests
+		total.InputTokens += r.InputTokens
+		total.OutputTokens += r.OutputTokens
+		total.CachedTokens += r.CachedTokens
+		total.Cost += r.Cost
+		latency += time.Duration(r.AvgLatencyMs*r.Requests) * time.Millisecond
+	}
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%d\t%d\t$%.2f\t%s\n", total.Requests, total.InputTokens, total.OutputTokens, total.CachedTokens, total.Cost, (latency / time.Duration(total.Requests)).Round(time.Millisecond))
+	return w.Flush()
+}
+
+func writeUsageCSV(rows []usageRow) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"key", "title", "requests", "input_tokens", "output_tokens", "cached_tokens", "cost", "avg_latency_ms"})
+	for _, r := range rows {
+		w.Write([]string{
+			r.Key,
+			r.Title,
+			strconv.FormatInt(r.Requests, 10),
+			strconv.FormatInt(r.InputTokens, 10),
+			strconv.FormatInt(r.OutputTokens, 10),
+			strconv.FormatInt(r.CachedTokens, 10),
+			strconv.FormatFloat(r.Cost, 'f', 6, 64),
+			strconv.FormatInt(r.AvgLatencyMs, 10),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// parseSince parses a duration back from now, in days, weeks, or any unit of
+// time.ParseDuration, or a date.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, since, time.Local); err == nil {
+		return t, nil
+	}
+	days := map[byte]int{'d': 1, 'w': 7}
+	if n := len(since); n > 1 && days[since[n-1]] > 0 {
+		count, err := strconv.Atoi(since[:n-1])
+		if err == nil && count > 0 {
+			return now.AddDate(0, 0, -count*days[since[n-1]]), nil
+		}
+	}
+	if d, err := time.ParseDuration(since); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, expected a duration such as 7d, 2w, or 12h, or a date such as 2025-09-01", since)
+}
+
+func init() {
+	usageCmd.Flags().String("since", "30d", "Start of the report: a duration such as 7d, 2w, or 12h, or a date")
+	usageCmd.Flags().String("by", session.UsageByModel, "Group by model, provider, session, or day")
+	usageCmd.Flags().String("format", "table", "Output format: table, csv, or json")
+	rootCmd.AddCommand(usageCmd)
+}
@@ -0,0 +1,59 @@
+package codesearch
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// chunkLines is the number of lines of a chunk.
+	chunkLines = 60
+	// chunkOverlap is the number of lines a chunk shares with the previous
+	// one, so code at the boundary is found with its context.
+	chunkOverlap = 10
+	// maxChunkBytes bounds chunks of long lines, e.g. minified files.
+	maxChunkBytes = 4000
+)
+
+// chunk is a range of lines of a file, embedded as a unit.
+type chunk struct {
+	StartLine int
+	EndLine   int
+	Content   string
+}
+
+// isText reports whether the content looks like text rather than binary.
+func isText(content []byte) bool {
+	head := content[:min(len(content), 8000)]
+	return !bytes.Contains(head, []byte{0}) && utf8.Valid(head)
+}
+
+// splitChunks splits the content into overlapping chunks of lines, leaving
+// out the blank ones.
+func splitChunks(content string) []chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var chunks []chunk
+	for start := 0; start < len(lines); {
+		end := start
+		size := 0
+		for end < len(lines) && end-start < chunkLines && (size == 0 || size+len(lines[end]) <= maxChunkBytes) {
+			size += len(lines[end]) + 1
+			end++
+		}
+		text := strings.Join(lines[start:end], "\n")
+		if len(text) > maxChunkBytes {
+			text = strings.ToValidUTF8(text[:maxChunkBytes], "")
+		}
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, chunk{StartLine: start + 1, EndLine: end, Content: text})
+		}
+		if end >= len(lines) {
+			break
+		}
+		// Chunks cut short by long lines overlap less, not to embed the
+		// same lines over and over.
+		start = max(end-min(chunkOverlap, (end-start)/6), start+1)
+	}
+	return chunks
+}
@@ -0,0 +1,573 @@
+// Package codesearch indexes the files of the project by the embeddings of
+// their chunks, for the codesearch tool to find code by meaning rather than
+// by pattern.
+package codesearch
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/llm/embeddings"
+	"github.com/zeebo/xxh3"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// IndexFile is the name of the index in the data directory.
+const IndexFile = "codesearch.db"
+
+const (
+	// maxFileSize is the size of the largest file indexed.
+	maxFileSize = 1 << 20
+	// maxFiles is the number of files indexed at most.
+	maxFiles = 20000
+	// batchSize is the number of chunks embedded per request.
+	batchSize = 32
+)
+
+// ErrUpdating is returned by Update while another update is running.
+var ErrUpdating = errors.New("the index is being updated")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	path TEXT PRIMARY KEY,
+	size INTEGER NOT NULL,
+	mod_time INTEGER NOT NULL,
+	hash TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chunks (
+	id INTEGER PRIMARY KEY,
+	path TEXT NOT NULL,
+	start_line INTEGER NOT NULL,
+	end_line INTEGER NOT NULL,
+	content TEXT NOT NULL,
+	embedding BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_chunks_path ON chunks (path);
+`
+
+// Options of an index.
+type Options struct {
+	// Ignore are patterns of file names left out of the index, in addition
+	// to the ones ignored by .gitignore and .crushignore.
+	Ignore []string
+}
+
+// Result is a chunk of a file matching a search.
+type Result struct {
+	// Path is relative to the root of the project, with forward slashes.
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+	// Score is the cosine similarity of the chunk and the query.
+	Score float32
+}
+
+// Stats describes the index.
+type Stats struct {
+	Files  int
+	Chunks int
+	// Embedded and Removed are the files embedded and removed by the update.
+	Embedded int
+	Removed  int
+}
+
+// vector is the embedding of a chunk, kept in memory to search.
+type vector struct {
+	id     int64
+	values []float32
+}
+
+// Index is the semantic index of the files of a project.
+type Index struct {
+	db       *sql.DB
+	root     string
+	dataDir  string
+	embedder embeddings.Client
+	opts     Options
+
+	updateMu sync.Mutex
+	// watched is the version of the watcher of the project the index was
+	// last updated at.
+	watched uint64
+
+	vectorsMu sync.Mutex
+	vectors   []vector
+}
+
+// Open opens the index of the project at root, stored in the data
+// directory. The index is emptied if it was built with another embedding
+// model.
+func Open(ctx context.Context, dataDir, root string, embedder embeddings.Client, opts Options) (*Index, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the project directory: %w", err)
+	}
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dataDir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the index: %w", err)
+	}
+	// A single connection serializes the writes of the index.
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL;", "PRAGMA busy_timeout = 5000;", schema} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set up the index: %w", err)
+		}
+	}
+
+	idx := &Index{db: db, root: root, dataDir: dataDir, embedder: embedder, opts: opts}
+	model := embedder.Model().ID
+	var indexed string
+	err = db.QueryRowContext(ctx, "SELECT value FROM meta WHERE key = 'model'").Scan(&indexed)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		db.Close()
+		return nil, fmt.Errorf("failed to read the index: %w", err)
+	}
+	if indexed != model {
+		// Embeddings of different models can't be compared.
+		if err := idx.Reset(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+		if _, err := db.ExecContext(ctx, "INSERT OR REPLACE INTO meta (key, value) VALUES ('model', ?)", model); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to write the index: %w", err)
+		}
+	}
+	return idx, nil
+}
+
+// OpenProject opens the index of the project of the configuration, with its
+// embedding model.
+func OpenProject(ctx context.Context, cfg *config.Config) (*Index, error) {
+	embedder, err := embeddings.New()
+	if err != nil {
+		return nil, err
+	}
+	var opts Options
+	if cfg.Options.CodeSearch != nil {
+		opts.Ignore = cfg.Options.CodeSearch.Ignore
+	}
+	return Open(ctx, cfg.Options.DataDirectory, cfg.WorkingDir(), embedder, opts)
+}
+
+// Close closes the index.
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// Reset empties the index, for the next update to index every file again.
+func (i *Index) Reset(ctx context.Context) error {
+	if _, err := i.db.ExecContext(ctx, "DELETE FROM chunks; DELETE FROM files;"); err != nil {
+		return fmt.Errorf("failed to reset the index: %w", err)
+	}
+	i.invalidate()
+	return nil
+}
+
+// Stats returns the number of files and chunks indexed.
+func (i *Index) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := i.db.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM files), (SELECT COUNT(*) FROM chunks)").Scan(&stats.Files, &stats.Chunks)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read the index: %w", err)
+	}
+	return stats, nil
+}
+
+type indexedFile struct {
+	size    int64
+	modTime int64
+	hash    string
+}
+
+// pendingFile is a new or modified file being embedded.
+type pendingFile struct {
+	path string
+	indexedFile
+	chunks    []chunk
+	vectors   [][]float32
+	remaining int
+}
+
+// Update indexes the new and modified files of the project and removes the
+// deleted ones, reporting the chunks embedded so far of the total. It
+// returns ErrUpdating if another update is running.
+func (i *Index) Update(ctx context.Context, progress func(done, total int)) (Stats, error) {
+	if !i.updateMu.TryLock() {
+		return Stats{}, ErrUpdating
+	}
+	defer i.updateMu.Unlock()
+
+	indexed, err := i.indexedFiles(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	paths, full, version, err := i.changedFiles()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	var pending []*pendingFile
+	seen := make(map[string]bool, len(paths))
+	checked := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		checked[path] = true
+		info, err := os.Stat(filepath.Join(i.root, filepath.FromSlash(path)))
+		if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileSize {
+			continue
+		}
+		current := indexedFile{size: info.Size(), modTime: info.ModTime().UnixNano()}
+		previous, ok := indexed[path]
+		if ok && previous.size == current.size && previous.modTime == current.modTime {
+			seen[path] = true
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(i.root, filepath.FromSlash(path)))
+		if err != nil || !isText(content) {
+			continue
+		}
+		seen[path] = true
+		current.hash = fmt.Sprintf("%016x", xxh3.Hash(content))
+		if ok && previous.hash == current.hash {
+			// Touched but not modified.
+			if _, err := i.db.ExecContext(ctx, "UPDATE files SET size = ?, mod_time = ? WHERE path = ?", current.size, current.modTime, path); err != nil {
+				return stats, fmt.Errorf("failed to write the index: %w", err)
+			}
+			continue
+		}
+		pending = append(pending, &pendingFile{path: path, indexedFile: current, chunks: splitChunks(string(content))})
+	}
+
+	for path := range indexed {
+		if seen[path] || !full && !checked[path] {
+			continue
+		}
+		if err := i.removeFile(ctx, path); err != nil {
+			return stats, err
+		}
+		stats.Removed++
+	}
+
+	type pendingChunk struct {
+		file  *pendingFile
+		index int
+	}
+	var queue []pendingChunk
+	for _, f := range pending {
+		f.vectors = make([][]float32, len(f.chunks))
+		f.remaining = len(f.chunks)
+		if f.remaining == 0 {
+			if err := i.writeFile(ctx, f); err != nil {
+				return stats, err
+			}
+			stats.Embedded++
+		}
+		for j := range f.chunks {
+			queue = append(queue, pendingChunk{file: f, index: j})
+		}
+	}
+
+	for start := 0; start < len(queue); start += batchSize {
+		batch := queue[start:min(start+batchSize, len(queue))]
+		inputs := make([]string, len(batch))
+		for k, pc := range batch {
+			c := pc.file.chunks[pc.index]
+			// The path helps matching queries about a feature or a
+			// package.
+			inputs[k] = fmt.Sprintf("%s:%d-%d\n%s", pc.file.path, c.StartLine, c.EndLine, c.Content)
+		}
+		vectors, err := i.embedder.Embed(ctx, inputs)
+		if err != nil {
+			return stats, fmt.Errorf("failed to embed %s: %w", batch[0].file.path, err)
+		}
+		for k, pc := range batch {
+			pc.file.vectors[pc.index] = normalize(vectors[k])
+			pc.file.remaining--
+			if pc.file.remaining == 0 {
+				if err := i.writeFile(ctx, pc.file); err != nil {
+					return stats, err
+				}
+				stats.Embedded++
+			}
+		}
+		if progress != nil {
+			progress(start+len(batch), len(queue))
+		}
+	}
+
+	i.watched = version
+
+	total, err := i.Stats(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.Files, stats.Chunks = total.Files, total.Chunks
+	return stats, nil
+}
+
+// changedFiles returns the paths of the files that may have changed since
+// the last update, and whether they are all the files of the project. When
+// the project is watched, only the files the watcher saw change are.
+func (i *Index) changedFiles() ([]string, bool, uint64, error) {
+	w := fsext.Watched(i.root)
+	if w == nil {
+		paths, err := i.listFiles()
+		return paths, true, 0, err
+	}
+	changed, removed, version, ok := w.Changes(i.watched)
+	if !ok {
+		paths, err := i.listFiles()
+		return paths, true, version, err
+	}
+	var paths []string
+	for _, path := range changed {
+		if !i.ignored(path) {
+			paths = append(paths, path)
+		}
+	}
+	// The removed files fail to stat, and are removed from the index.
+	return append(paths, removed...), false, version, nil
+}
+
+// ignored reports whether the path, relative to the root, is left out of
+// the index by the options or is in the data directory.
+func (i *Index) ignored(path string) bool {
+	dataDir, _ := filepath.Abs(i.dataDir)
+	if fsext.HasPrefix(filepath.Join(i.root, filepath.FromSlash(path)), dataDir) {
+		return true
+	}
+	for name := range strings.SplitSeq(path, "/") {
+		for _, pattern := range i.opts.Ignore {
+			if matched, err := filepath.Match(pattern, name); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listFiles returns the paths of the files of the project, relative to its
+// root, without the ignored ones.
+func (i *Index) listFiles() ([]string, error) {
+	entries, _, err := fsext.ListDirectory(i.root, i.opts.Ignore, maxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the files of the project: %w", err)
+	}
+	dataDir, _ := filepath.Abs(i.dataDir)
+	var paths []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry, string(filepath.Separator)) || fsext.HasPrefix(entry, dataDir) {
+			continue
+		}
+		rel, err := filepath.Rel(i.root, entry)
+		if err != nil {
+			continue
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths, nil
+}
+
+func (i *Index) indexedFiles(ctx context.Context) (map[string]indexedFile, error) {
+	rows, err := i.db.QueryContext(ctx, "SELECT path, size, mod_time, hash FROM files")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the index: %w", err)
+	}
+	defer rows.Close()
+	files := make(map[string]indexedFile)
+	for rows.Next() {
+		var path string
+		var f indexedFile
+		if err := rows.Scan(&path, &f.size, &f.modTime, &f.hash); err != nil {
+			return nil, fmt.Errorf("failed to read the index: %w", err)
+		}
+		files[path] = f
+	}
+	return files, rows.Err()
+}
+
+// writeFile replaces the chunks of the file in the index.
+func (i *Index) writeFile(ctx context.Context, f *pendingFile) error {
+	defer i.invalidate()
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM chunks WHERE path = ?", f.path); err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	for j, c := range f.chunks {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO chunks (path, start_line, end_line, content, embedding) VALUES (?, ?, ?, ?, ?)",
+			f.path, c.StartLine, c.EndLine, c.Content, encodeVector(f.vectors[j]),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to write the index: %w", err)
+		}
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT OR REPLACE INTO files (path, size, mod_time, hash) VALUES (?, ?, ?, ?)",
+		f.path, f.size, f.modTime, f.hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (i *Index) removeFile(ctx context.Context, path string) error {
+	defer i.invalidate()
+	for _, stmt := range []string{"DELETE FROM chunks WHERE path = ?", "DELETE FROM files WHERE path = ?"} {
+		if _, err := i.db.ExecContext(ctx, stmt, path); err != nil {
+			return fmt.Errorf("failed to write the index: %w", err)
+		}
+	}
+	return nil
+}
+
+// invalidate drops the vectors kept in memory after the index changed.
+func (i *Index) invalidate() {
+	i.vectorsMu.Lock()
+	i.vectors = nil
+	i.vectorsMu.Unlock()
+}
+
+// loadVectors returns the embeddings of the chunks, loading them once.
+func (i *Index) loadVectors(ctx context.Context) ([]vector, error) {
+	i.vectorsMu.Lock()
+	defer i.vectorsMu.Unlock()
+	if i.vectors != nil {
+		return i.vectors, nil
+	}
+	rows, err := i.db.QueryContext(ctx, "SELECT id, embedding FROM chunks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the index: %w", err)
+	}
+	defer rows.Close()
+	vectors := []vector{}
+	for rows.Next() {
+		var v vector
+		var blob []byte
+		if err := rows.Scan(&v.id, &blob); err != nil {
+			return nil, fmt.Errorf("failed to read the index: %w", err)
+		}
+		v.values = decodeVector(blob)
+		vectors = append(vectors, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the index: %w", err)
+	}
+	i.vectors = vectors
+	return vectors, nil
+}
+
+// Search returns the chunks closest in meaning to the query, the closest
+// first.
+func (i *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	embedded, err := i.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed the query: %w", err)
+	}
+	q := normalize(embedded[0])
+	vectors, err := i.loadVectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		id    int64
+		score float32
+	}
+	scores := make([]scored, 0, len(vectors))
+	for _, v := range vectors {
+		if len(v.values) != len(q) {
+			continue
+		}
+		var dot float32
+		for k := range q {
+			dot += q[k] * v.values[k]
+		}
+		scores = append(scores, scored{id: v.id, score: dot})
+	}
+	slices.SortFunc(scores, func(a, b scored) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	scores = scores[:min(limit, len(scores))]
+
+	results := make([]Result, 0, len(scores))
+	for _, s := range scores {
+		r := Result{Score: s.score}
+		err := i.db.QueryRowContext(ctx, "SELECT path, start_line, end_line, content FROM chunks WHERE id = ?", s.id).
+			Scan(&r.Path, &r.StartLine, &r.EndLine, &r.Content)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Removed by an update since loaded.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the index: %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// normalize scales the vector to unit length, for the dot product of two
+// vectors to be their cosine similarity.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for k, x := range v {
+		out[k] = x / norm
+	}
+	return out
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for k, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*k:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for k := range v {
+		v[k] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*k:]))
+	}
+	return v
+}
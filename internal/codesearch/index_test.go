@@ -0,0 +1,170 @@
+package codesearch
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/stretchr/testify/require"
+)
+
+// wordsEmbedder embeds texts as bags of words, for texts sharing words to be
+// similar.
+type wordsEmbedder struct {
+	model  string
+	inputs atomic.Int64
+}
+
+func (e *wordsEmbedder) Embed(_ context.Context, inputs []string) ([][]float32, error) {
+	e.inputs.Add(int64(len(inputs)))
+	vectors := make([][]float32, len(inputs))
+	for k, input := range inputs {
+		v := make([]float32, 64)
+		for _, word := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !('a' <= r && r <= 'z')
+		}) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%64]++
+		}
+		vectors[k] = v
+	}
+	return vectors, nil
+}
+
+func (e *wordsEmbedder) Model() catwalk.Model {
+	return catwalk.Model{ID: e.model}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, ".crush")
+	writeFile(t, filepath.Join(root, "auth", "login.go"), "package auth\n\n// Login checks the password of the user.\nfunc Login(user, password string) bool {\n\treturn check(user, password)\n}\n")
+	writeFile(t, filepath.Join(root, "render", "table.go"), "package render\n\n// Table renders the rows as a table with borders.\nfunc Table(rows [][]string) string {\n\treturn borders(rows)\n}\n")
+	writeFile(t, filepath.Join(root, "logo.png"), "\x89PNG\x00\x00")
+	writeFile(t, filepath.Join(root, "node_modules", "left-pad", "index.js"), "module.exports = password")
+	writeFile(t, filepath.Join(root, "auth", "login_test.go"), "package auth\n\nfunc TestLogin(t *testing.T) {}\n")
+
+	embedder := &wordsEmbedder{model: "words"}
+	idx, err := Open(t.Context(), dataDir, root, embedder, Options{Ignore: []string{"*_test.go"}})
+	require.NoError(t, err)
+	defer idx.Close()
+
+	var progress []int
+	stats, err := idx.Update(t.Context(), func(done, total int) {
+		progress = append(progress, done, total)
+	})
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 2, Chunks: 2, Embedded: 2}, stats)
+	require.Equal(t, []int{2, 2}, progress)
+
+	results, err := idx.Search(t.Context(), "check the password of the user", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "auth/login.go", results[0].Path)
+	require.Equal(t, 1, results[0].StartLine)
+	require.Equal(t, 6, results[0].EndLine)
+	require.Contains(t, results[0].Content, "func Login")
+
+	// Unchanged files aren't embedded again.
+	embedded := embedder.inputs.Load()
+	stats, err = idx.Update(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 2, Chunks: 2}, stats)
+	require.Equal(t, embedded, embedder.inputs.Load())
+
+	// Modified and deleted files are.
+	writeFile(t, filepath.Join(root, "render", "table.go"), "package render\n\n// Table renders the rows as a table with borders and the password.\n")
+	require.NoError(t, os.Remove(filepath.Join(root, "auth", "login.go")))
+	stats, err = idx.Update(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 1, Chunks: 1, Embedded: 1, Removed: 1}, stats)
+	results, err = idx.Search(t.Context(), "password", 5)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "render/table.go", results[0].Path)
+
+	// Another model starts over.
+	require.NoError(t, idx.Close())
+	idx, err = Open(t.Context(), dataDir, root, &wordsEmbedder{model: "other"}, Options{})
+	require.NoError(t, err)
+	stats, err = idx.Stats(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, Stats{}, stats)
+}
+
+func TestIndexWatched(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "auth", "login.go"), "package auth\n\n// Login checks the password of the user.\n")
+	writeFile(t, filepath.Join(root, "render", "table.go"), "package render\n\n// Table renders the rows as a table.\n")
+
+	w, err := fsext.Watch(t.Context(), root)
+	require.NoError(t, err)
+	var version uint64
+	require.Eventually(t, func() bool {
+		_, _, version, _ = w.Changes(0)
+		return version > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	embedder := &wordsEmbedder{model: "words"}
+	idx, err := Open(t.Context(), filepath.Join(root, ".crush"), root, embedder, Options{Ignore: []string{"*_test.go"}})
+	require.NoError(t, err)
+	defer idx.Close()
+	stats, err := idx.Update(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 2, Chunks: 2, Embedded: 2}, stats)
+
+	// Only the files the watcher saw change are checked.
+	writeFile(t, filepath.Join(root, "render", "table.go"), "package render\n\n// Table renders the rows as a table with the password.\n")
+	writeFile(t, filepath.Join(root, "render", "table_test.go"), "package render\n")
+	require.NoError(t, os.Remove(filepath.Join(root, "auth", "login.go")))
+	require.Eventually(t, func() bool {
+		changed, removed, _, ok := w.Changes(version)
+		return ok && len(changed) == 2 && len(removed) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stats, err = idx.Update(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 1, Chunks: 1, Embedded: 1, Removed: 1}, stats)
+
+	embedded := embedder.inputs.Load()
+	stats, err = idx.Update(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 1, Chunks: 1}, stats)
+	require.Equal(t, embedded, embedder.inputs.Load())
+}
+
+func TestSplitChunks(t *testing.T) {
+	var lines []string
+	for i := range 130 {
+		lines = append(lines, strings.Repeat("x", i%3+1))
+	}
+	chunks := splitChunks(strings.Join(lines, "\n") + "\n")
+	require.Len(t, chunks, 3)
+	require.Equal(t, [2]int{1, 60}, [2]int{chunks[0].StartLine, chunks[0].EndLine})
+	require.Equal(t, [2]int{51, 110}, [2]int{chunks[1].StartLine, chunks[1].EndLine})
+	require.Equal(t, [2]int{101, 130}, [2]int{chunks[2].StartLine, chunks[2].EndLine})
+
+	// Long lines make smaller chunks.
+	long := strings.Repeat(strings.Repeat("y", 1500)+"\n", 4)
+	chunks = splitChunks(long)
+	require.Len(t, chunks, 2)
+	require.Equal(t, [2]int{1, 2}, [2]int{chunks[0].StartLine, chunks[0].EndLine})
+	require.Equal(t, [2]int{3, 4}, [2]int{chunks[1].StartLine, chunks[1].EndLine})
+
+	require.Empty(t, splitChunks("\n\n  \n"))
+	require.False(t, isText([]byte("\x89PNG\x00")))
+	require.True(t, isText([]byte("package main")))
+}
@@ -0,0 +1,26 @@
+package config
+
+import "fmt"
+
+// Bash configures the commands of the bash tool.
+type Bash struct {
+	Timeout    int `json:"timeout,omitempty" jsonschema:"description=Seconds the commands run before they time out unless the model sets a timeout,default=60,minimum=1,example=120"`
+	MaxTimeout int `json:"max_timeout,omitempty" jsonschema:"description=Longest timeout in seconds the model can set; longer commands run in the background,default=600,minimum=1,example=1800"`
+	MaxOutput  int `json:"max_output,omitempty" jsonschema:"description=Characters of the output of a command sent to the model; the middle of longer outputs is cut and the full output saved to a file,default=30000,minimum=1000,example=50000"`
+}
+
+func (b *Bash) validate() error {
+	if b.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if b.MaxTimeout < 0 {
+		return fmt.Errorf("max_timeout must not be negative")
+	}
+	if b.Timeout > 0 && b.MaxTimeout > 0 && b.Timeout > b.MaxTimeout {
+		return fmt.Errorf("timeout %d is longer than max_timeout %d", b.Timeout, b.MaxTimeout)
+	}
+	if b.MaxOutput != 0 && b.MaxOutput < 1000 {
+		return fmt.Errorf("max_output must be at least 1000")
+	}
+	return nil
+}
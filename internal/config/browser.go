@@ -0,0 +1,10 @@
+package config
+
+// Browser configures the browser tool, available when Chrome or Chromium is
+// found.
+type Browser struct {
+	Disabled bool     `json:"disabled,omitempty" jsonschema:"description=Disable the browser tool,default=false"`
+	Path     string   `json:"path,omitempty" jsonschema:"description=Path of the Chrome or Chromium executable; looked up in the PATH without it,example=/usr/bin/chromium"`
+	Headed   bool     `json:"headed,omitempty" jsonschema:"description=Show the browser window rather than run it headless,default=false"`
+	Args     []string `json:"args,omitempty" jsonschema:"description=Additional command line arguments of the browser,example=--lang=en-US"`
+}
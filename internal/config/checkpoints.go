@@ -0,0 +1,8 @@
+package config
+
+// Checkpoints configures the snapshots of the working directory taken before
+// the turns of the agent changing files, which crush undo restores.
+type Checkpoints struct {
+	Disabled bool     `json:"disabled,omitempty" jsonschema:"description=Disable the checkpoints,default=false"`
+	Exclude  []string `json:"exclude,omitempty" jsonschema:"description=Paths left out of the checkpoints in the .gitignore syntax in addition to the ignored files of the project,example=node_modules/,example=*.log"`
+}
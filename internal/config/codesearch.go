@@ -0,0 +1,9 @@
+package config
+
+// CodeSearch configures the semantic index of the project searched by the
+// codesearch tool. The embedding model is the one of models.embedding, or the
+// first available.
+type CodeSearch struct {
+	Enabled bool     `json:"enabled,omitempty" jsonschema:"description=Index the project and enable the codesearch tool,default=false"`
+	Ignore  []string `json:"ignore,omitempty" jsonschema:"description=Patterns of file names left out of the index in addition to the ignored files,example=*.min.js,example=*_test.go"`
+}
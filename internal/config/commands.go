@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	UserCommandPrefix    = "user:"
+	ProjectCommandPrefix = "project:"
+)
+
+// CommandSource is a directory custom commands are loaded from. Commands are
+// markdown files, their ID is the prefix followed by their path relative to
+// the directory, without extension, with separators replaced by colons.
+type CommandSource struct {
+	Path   string
+	Prefix string
+}
+
+// CommandSources returns the directories custom commands are loaded from.
+func (c *Config) CommandSources() []CommandSource {
+	var sources []CommandSource
+
+	// XDG config directory
+	if dir := xdgCommandsDir(); dir != "" {
+		sources = append(sources, CommandSource{
+			Path:   dir,
+			Prefix: UserCommandPrefix,
+		})
+	}
+
+	// Home directory
+	if home, err := os.UserHomeDir(); err == nil {
+		sources = append(sources, CommandSource{
+			Path:   filepath.Join(home, ".crush", "commands"),
+			Prefix: UserCommandPrefix,
+		})
+	}
+
+	// Project directory
+	sources = append(sources, CommandSource{
+		Path:   filepath.Join(c.Options.DataDirectory, "commands"),
+		Prefix: ProjectCommandPrefix,
+	})
+
+	return sources
+}
+
+func xdgCommandsDir() string {
+	xdgHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			xdgHome = filepath.Join(home, ".config")
+		}
+	}
+	if xdgHome != "" {
+		return filepath.Join(xdgHome, appName, "commands")
+	}
+	return ""
+}
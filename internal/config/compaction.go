@@ -0,0 +1,22 @@
+package config
+
+import "fmt"
+
+// Compaction configures the automatic compaction of long conversations: once
+// the conversation fills most of the context window of the model, its older
+// turns are summarized with the summarize model and the most recent messages
+// are kept as they are. disable_auto_summarize turns it off.
+type Compaction struct {
+	Threshold        float64 `json:"threshold,omitempty" jsonschema:"description=Fraction of the context window of the model the conversation fills before it's compacted,default=0.8,minimum=0.1,maximum=0.95"`
+	KeepRecentTokens int     `json:"keep_recent_tokens,omitempty" jsonschema:"description=Estimated tokens of the most recent messages kept as they are,default=20000,minimum=0"`
+}
+
+func (c *Compaction) validate() error {
+	if c.Threshold != 0 && (c.Threshold < 0.1 || c.Threshold > 0.95) {
+		return fmt.Errorf("threshold must be between 0.1 and 0.95")
+	}
+	if c.KeepRecentTokens < 0 {
+		return fmt.Errorf("keep_recent_tokens must not be negative")
+	}
+	return nil
+}
@@ -18,11 +18,15 @@ import (
 )
 
 const (
-	appName              = "crush"
-	defaultDataDirectory = ".crush"
-	defaultLogLevel      = "info"
+	appName               = "crush"
+	defaultDataDirectory  = ".crush"
+	defaultLogLevel       = "info"
+	defaultStorageBackend = "sqlite"
 )
 
+// defaultContextPaths are the instruction files loaded into the system
+// prompt. The later ones take precedence: the files of other tools come
+// first, then AGENTS.md, then CRUSH.md and its local version.
 var defaultContextPaths = []string{
 	".github/copilot-instructions.md",
 	".cursorrules",
@@ -31,15 +35,15 @@ var defaultContextPaths = []string{
 	"CLAUDE.local.md",
 	"GEMINI.md",
 	"gemini.md",
+	"AGENTS.md",
+	"agents.md",
+	"Agents.md",
 	"crush.md",
-	"crush.local.md",
 	"Crush.md",
-	"Crush.local.md",
 	"CRUSH.md",
+	"crush.local.md",
+	"Crush.local.md",
 	"CRUSH.local.md",
-	"AGENTS.md",
-	"agents.md",
-	"Agents.md",
 }
 
 type SelectedModelType string
@@ -47,6 +51,9 @@ type SelectedModelType string
 const (
 	SelectedModelTypeLarge SelectedModelType = "large"
 	SelectedModelTypeSmall SelectedModelType = "small"
+	// SelectedModelTypeEmbedding is never used for chat, only to compute
+	// embeddings, e.g. for semantic search.
+	SelectedModelTypeEmbedding SelectedModelType = "embedding"
 )
 
 type SelectedModel struct {
@@ -58,13 +65,75 @@ type SelectedModel struct {
 	Provider string `json:"provider" jsonschema:"required,description=The model provider ID that matches a key in the providers config,example=openai"`
 
 	// Only used by models that use the openai provider and need this set.
-	ReasoningEffort string `json:"reasoning_effort,omitempty" jsonschema:"description=Reasoning effort level for OpenAI models that support it,enum=low,enum=medium,enum=high"`
+	ReasoningEffort string `json:"reasoning_effort,omitempty" jsonschema:"description=Reasoning effort level for OpenAI models that support it,enum=minimal,enum=low,enum=medium,enum=high"`
+
+	// Summary of the reasoning of OpenAI models streamed by the Responses
+	// API, left out by default.
+	ReasoningSummary string `json:"reasoning_summary,omitempty" jsonschema:"description=Summary of the reasoning of OpenAI models streamed by the Responses API; left out by default,enum=auto,enum=concise,enum=detailed"`
+
+	// API of the OpenAI model, overriding the one of the provider.
+	API string `json:"api,omitempty" jsonschema:"description=API of the model for providers of the openai type overriding the one of the provider,enum=chat,enum=responses"`
+
+	// Sampling temperature of OpenAI models. Models that can reason don't
+	// take it, it isn't sent to them.
+	Temperature *float64 `json:"temperature,omitempty" jsonschema:"description=Sampling temperature of OpenAI models; not sent to the models that can reason which don't take it,minimum=0,maximum=2,example=0.2"`
 
 	// Overrides the default model configuration.
 	MaxTokens int64 `json:"max_tokens,omitempty" jsonschema:"description=Maximum number of tokens for model responses,minimum=1,maximum=200000,example=4096"`
 
 	// Used by anthropic models that can reason to indicate if the model should think.
 	Think bool `json:"think,omitempty" jsonschema:"description=Enable thinking mode for Anthropic models that support reasoning"`
+
+	// ThinkingBudget is the number of tokens the model can think with when
+	// thinking is enabled, for Anthropic and Gemini models. Anthropic models
+	// think with 80% of the max tokens by default.
+	ThinkingBudget int64 `json:"thinking_budget,omitempty" jsonschema:"description=Number of tokens Anthropic and Gemini models can think with when thinking is enabled,minimum=0,example=8000"`
+
+	// How the model calls tools, natively by default. Models without
+	// reliable function calling, as many served by Ollama, can write the
+	// calls in their answers instead, to tools described in the prompt.
+	ToolCalling string `json:"tool_calling,omitempty" jsonschema:"description=How the model calls tools: native function calling or emulated with calls written in the answers for models without reliable function calling,enum=native,enum=emulated,default=native"`
+
+	// Overrides the prompt caching settings of the provider for this model.
+	PromptCache *PromptCache `json:"prompt_cache,omitempty" jsonschema:"description=Prompt caching settings for this model, overriding the ones of the provider"`
+}
+
+// How models call tools.
+const (
+	ToolCallingNative   = "native"
+	ToolCallingEmulated = "emulated"
+)
+
+const (
+	CacheBreakpointSystem   = "system"
+	CacheBreakpointTools    = "tools"
+	CacheBreakpointMessages = "messages"
+)
+
+// PromptCache controls prompt caching for providers that take explicit cache
+// breakpoints, such as Anthropic.
+type PromptCache struct {
+	// Disables prompt caching.
+	Disable bool `json:"disable,omitempty" jsonschema:"description=Disable prompt caching,default=false"`
+	// Where cache breakpoints are placed, all of them by default.
+	Breakpoints []string `json:"breakpoints,omitempty" jsonschema:"description=Parts of the prompt to cache,enum=system,enum=tools,enum=messages,example=system,example=tools"`
+	// The number of latest messages with a breakpoint, 2 by default.
+	Messages int `json:"messages,omitempty" jsonschema:"description=Number of latest messages to cache the conversation up to,minimum=1,maximum=2,default=2"`
+
+	// Override the cached token prices of the model.
+	CostPer1MWrite float64 `json:"cost_per_1m_write,omitempty" jsonschema:"description=Cost per million tokens written to the cache,minimum=0,example=3.75"`
+	CostPer1MRead  float64 `json:"cost_per_1m_read,omitempty" jsonschema:"description=Cost per million tokens read from the cache,minimum=0,example=0.3"`
+}
+
+// Caches reports whether the breakpoint is enabled.
+func (p *PromptCache) Caches(breakpoint string) bool {
+	if p == nil {
+		return true
+	}
+	if p.Disable {
+		return false
+	}
+	return len(p.Breakpoints) == 0 || slices.Contains(p.Breakpoints, breakpoint)
 }
 
 type ProviderConfig struct {
@@ -94,6 +163,123 @@ type ProviderConfig struct {
 
 	// The provider models
 	Models []catwalk.Model `json:"models,omitempty" jsonschema:"description=List of models available from this provider"`
+
+	// The provider embedding models, these are not offered as chat models.
+	EmbeddingModels []catwalk.Model `json:"embedding_models,omitempty" jsonschema:"description=List of embedding models available from this provider"`
+
+	// Settings for providers of the openai type.
+	OpenAI *OpenAIOptions `json:"openai,omitempty" jsonschema:"description=OpenAI settings for providers of the openai type"`
+
+	// Settings for providers of the azure type.
+	Azure *AzureOptions `json:"azure,omitempty" jsonschema:"description=Azure OpenAI settings for providers of the azure type"`
+
+	// Settings for providers of the bedrock type.
+	Bedrock *BedrockOptions `json:"bedrock,omitempty" jsonschema:"description=AWS Bedrock settings for providers of the bedrock type"`
+
+	// Settings for providers of the vertexai type.
+	VertexAI *VertexAIOptions `json:"vertexai,omitempty" jsonschema:"description=Google Cloud Vertex AI settings for providers of the vertexai type"`
+
+	// Prompt caching settings for the models of the provider.
+	PromptCache *PromptCache `json:"prompt_cache,omitempty" jsonschema:"description=Prompt caching settings for the models of the provider"`
+
+	// Prices of the models by model ID, overriding the ones of the catalog.
+	// The "*" entry applies to all the models of the provider.
+	Pricing map[string]Pricing `json:"pricing,omitempty" jsonschema:"description=Prices of the models by model ID overriding the catalog ones; the * entry applies to all the models of the provider"`
+
+	// Proxy of the requests to the provider, overriding the default one of
+	// the options.
+	Proxy *Proxy `json:"proxy,omitempty" jsonschema:"description=Proxy of the requests to the provider overriding the default one of the options"`
+
+	// CA bundle and client certificate of the connections to the provider,
+	// overriding the default ones of the options.
+	TLS *TLS `json:"tls,omitempty" jsonschema:"description=CA bundle and client certificate of the connections to the provider overriding the default ones of the options"`
+
+	// Client-side limits of the requests to the provider.
+	RateLimit *RateLimit `json:"rate_limit,omitempty" jsonschema:"description=Client-side limits of the requests to the provider shared by all sessions and agents"`
+
+	// Middleware of the requests to the provider, after the default ones of
+	// the options.
+	Middleware []Middleware `json:"middleware,omitempty" jsonschema:"description=Middleware of the requests to the provider run after the default ones of the options"`
+}
+
+// RateLimit caps the requests to a provider. Requests over the limits wait
+// for their turn instead of failing.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" jsonschema:"description=Maximum number of requests started per minute including retries,minimum=0,example=50"`
+	MaxConcurrent     int `json:"max_concurrent,omitempty" jsonschema:"description=Maximum number of requests in flight at once,minimum=0,example=4"`
+}
+
+// Pricing overrides the prices of a model, in dollars per million tokens.
+// Unset prices are kept.
+type Pricing struct {
+	CostPer1MIn        *float64 `json:"cost_per_1m_in,omitempty" jsonschema:"description=Cost per million input tokens,minimum=0,example=3"`
+	CostPer1MOut       *float64 `json:"cost_per_1m_out,omitempty" jsonschema:"description=Cost per million output tokens,minimum=0,example=15"`
+	CostPer1MInCached  *float64 `json:"cost_per_1m_in_cached,omitempty" jsonschema:"description=Cost per million tokens written to the prompt cache,minimum=0,example=3.75"`
+	CostPer1MOutCached *float64 `json:"cost_per_1m_out_cached,omitempty" jsonschema:"description=Cost per million tokens read from the prompt cache,minimum=0,example=0.3"`
+}
+
+func (p Pricing) apply(model *catwalk.Model) {
+	if p.CostPer1MIn != nil {
+		model.CostPer1MIn = *p.CostPer1MIn
+	}
+	if p.CostPer1MOut != nil {
+		model.CostPer1MOut = *p.CostPer1MOut
+	}
+	if p.CostPer1MInCached != nil {
+		model.CostPer1MInCached = *p.CostPer1MInCached
+	}
+	if p.CostPer1MOutCached != nil {
+		model.CostPer1MOutCached = *p.CostPer1MOutCached
+	}
+}
+
+// priced returns the models with the prices of the pricing table.
+func priced(models []catwalk.Model, pricing map[string]Pricing) []catwalk.Model {
+	if len(pricing) == 0 {
+		return models
+	}
+	models = slices.Clone(models)
+	for i := range models {
+		if p, ok := pricing["*"]; ok {
+			p.apply(&models[i])
+		}
+		if p, ok := pricing[models[i].ID]; ok {
+			p.apply(&models[i])
+		}
+	}
+	return models
+}
+
+type AzureOptions struct {
+	// The api-version query parameter sent with every request.
+	APIVersion string `json:"api_version,omitempty" jsonschema:"description=Azure OpenAI API version,example=2025-01-01-preview"`
+	// Deployment names by model ID, Azure routes requests to deployments
+	// rather than models. Models without a deployment are sent to a
+	// deployment named after them.
+	Deployments map[string]string `json:"deployments,omitempty" jsonschema:"description=Deployment names by model ID for deployments not named after their model,example={\"gpt-4o\":\"my-gpt-4o\"}"`
+	// Authenticate with Microsoft Entra ID instead of an API key.
+	EntraID bool `json:"entra_id,omitempty" jsonschema:"description=Authenticate with Microsoft Entra ID using the Azure CLI, a managed identity, or environment credentials instead of an API key,default=false"`
+}
+
+type BedrockOptions struct {
+	// The AWS region, defaults to $AWS_REGION or $AWS_DEFAULT_REGION.
+	Region string `json:"region,omitempty" jsonschema:"description=AWS region to call Bedrock in,example=us-east-1"`
+	// The AWS shared config profile to take credentials from, defaults to
+	// $AWS_PROFILE.
+	Profile string `json:"profile,omitempty" jsonschema:"description=AWS shared config profile to take credentials from,example=bedrock"`
+	// Bedrock model IDs, inference profile IDs, or ARNs by model ID. Models
+	// without an entry use the cross-region inference profile of the region.
+	Models map[string]string `json:"models,omitempty" jsonschema:"description=Bedrock model IDs or ARNs by model ID,example={\"anthropic.claude-sonnet-4-20250514-v1:0\":\"arn:aws:bedrock:us-east-1:123456789012:application-inference-profile/abc123\"}"`
+}
+
+type VertexAIOptions struct {
+	// The Google Cloud project, defaults to $VERTEXAI_PROJECT.
+	Project string `json:"project,omitempty" jsonschema:"description=Google Cloud project to call Vertex AI in,example=my-project"`
+	// The Google Cloud location, defaults to $VERTEXAI_LOCATION.
+	Location string `json:"location,omitempty" jsonschema:"description=Google Cloud location to call Vertex AI in,example=us-central1"`
+	// A service account key file. Application Default Credentials are used
+	// when it isn't set.
+	CredentialsFile string `json:"credentials_file,omitempty" jsonschema:"description=Path to a service account key file, Application Default Credentials are used otherwise,example=$HOME/keys/vertex.json"`
 }
 
 type MCPType string
@@ -114,32 +300,309 @@ type MCPConfig struct {
 
 	// TODO: maybe make it possible to get the value from the env
 	Headers map[string]string `json:"headers,omitempty" jsonschema:"description=HTTP headers for HTTP/SSE MCP servers"`
+	OAuth   *MCPOAuth         `json:"oauth,omitempty" jsonschema:"description=OAuth authentication for HTTP/SSE MCP servers"`
+}
+
+// MCPOAuth authenticates to a remote MCP server with OAuth. Tokens are
+// obtained with the device flow by 'crush mcp login' and refreshed as needed.
+type MCPOAuth struct {
+	ClientID     string   `json:"client_id" jsonschema:"required,description=OAuth client ID registered with the authorization server"`
+	ClientSecret string   `json:"client_secret,omitempty" jsonschema:"description=OAuth client secret for confidential clients"`
+	Scopes       []string `json:"scopes,omitempty" jsonschema:"description=OAuth scopes to request"`
+	MetadataURL  string   `json:"metadata_url,omitempty" jsonschema:"description=URL of the authorization server metadata; discovered from the MCP server when unset,format=uri"`
 }
 
 type LSPConfig struct {
-	Disabled bool     `json:"enabled,omitempty" jsonschema:"description=Whether this LSP server is disabled,default=false"`
-	Command  string   `json:"command" jsonschema:"required,description=Command to execute for the LSP server,example=gopls"`
+	Disabled bool     `json:"disabled,omitempty" jsonschema:"description=Whether this LSP server is disabled,default=false"`
+	Command  string   `json:"command,omitempty" jsonschema:"description=Command to execute for the LSP server; defaults to the known server of the language for the go and rust and typescript and python keys,example=gopls"`
 	Args     []string `json:"args,omitempty" jsonschema:"description=Arguments to pass to the LSP server command"`
 	Options  any      `json:"options,omitempty" jsonschema:"description=LSP server-specific configuration options"`
+	// InitOptions replace the default initialization options sent to the
+	// server.
+	InitOptions map[string]any `json:"init_options,omitempty" jsonschema:"description=Initialization options sent to the LSP server"`
 }
 
 type TUIOptions struct {
 	CompactMode bool `json:"compact_mode,omitempty" jsonschema:"description=Enable compact mode for the TUI interface,default=false"`
-	// Here we can add themes later or any TUI related options
+	// Don't send desktop notifications and progress updates to the terminal
+	DisableNotifications bool    `json:"disable_notifications,omitempty" jsonschema:"description=Disable terminal notifications and progress indicators,default=false"`
+	Keymap               *Keymap `json:"keymap,omitempty" jsonschema:"description=Key bindings of the TUI"`
+	// Notifications picks how the terminal is notified, see
+	// DisableNotifications to turn them off.
+	Notifications *Notifications `json:"notifications,omitempty" jsonschema:"description=How the terminal is notified when a turn finishes or fails or a permission is requested"`
+	// Theme is the name of a built-in theme or of a theme file, auto picks
+	// crush or crush-light for the background of the terminal.
+	Theme string `json:"theme,omitempty" jsonschema:"description=Theme of the TUI; auto picks a dark or light theme for the terminal,default=auto,example=auto,example=crush,example=crush-light,example=dracula,example=gruvbox"`
+	// LivePanel shows the live panel of the chat page at start.
+	LivePanel bool `json:"live_panel,omitempty" jsonschema:"description=Show the panel with the live tool output and the diagnostics next to the conversation at start,default=false"`
+	// Thinking is how the thinking of reasoning models is shown, auto
+	// shows it while the model thinks and collapses it once it's done.
+	Thinking string `json:"thinking,omitempty" jsonschema:"description=How the thinking of reasoning models is shown; auto shows it while the model thinks and collapses it to a line once done,enum=auto,enum=expanded,enum=collapsed,default=auto"`
+}
+
+// Displays of the thinking of reasoning models.
+const (
+	ThinkingAuto      = "auto"
+	ThinkingExpanded  = "expanded"
+	ThinkingCollapsed = "collapsed"
+)
+
+func validateThinkingDisplay(display string) error {
+	switch display {
+	case "", ThinkingAuto, ThinkingExpanded, ThinkingCollapsed:
+		return nil
+	}
+	return fmt.Errorf("unknown thinking display %q, expected auto, expanded, or collapsed", display)
+}
+
+// Desktop notification protocols of the terminals.
+const (
+	NotificationsAuto   = "auto"
+	NotificationsOSC9   = "osc9"
+	NotificationsOSC777 = "osc777"
+	NotificationsKitty  = "kitty"
+	NotificationsOff    = "off"
+)
+
+// Notifications picks the escape sequence of desktop notifications and
+// whether the bell rings with them.
+type Notifications struct {
+	// Desktop is the protocol of desktop notifications, detected from the
+	// terminal with auto.
+	Desktop string `json:"desktop,omitempty" jsonschema:"description=Escape sequence of desktop notifications; auto detects it from the terminal,enum=auto,enum=osc9,enum=osc777,enum=kitty,enum=off,default=auto"`
+	// Bell rings the terminal bell with the notifications. It always rings
+	// in tmux to flag the window.
+	Bell bool `json:"bell,omitempty" jsonschema:"description=Ring the terminal bell with the notifications,default=false"`
+}
+
+func (n *Notifications) validate() error {
+	switch n.Desktop {
+	case "", NotificationsAuto, NotificationsOSC9, NotificationsOSC777, NotificationsKitty, NotificationsOff:
+		return nil
+	}
+	return fmt.Errorf("unknown desktop notifications %q, expected auto, osc9, osc777, kitty, or off", n.Desktop)
 }
 
 type Permissions struct {
-	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
-	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+	AllowedTools     []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"`                                                              // Tools that don't require permission prompts
+	AllowDestructive bool     `json:"allow_destructive,omitempty" jsonschema:"description=Allow destructive commands (e.g. git push --force) to be approved without confirmation in YOLO mode or via allowed tools,default=false"` // Don't require confirmation for destructive commands
+	SkipRequests     bool     `json:"-"`                                                                                                                                                                                           // Automatically accept all permissions (YOLO mode)
+	// Rules allow, deny, or always ask for calls of tools by the commands
+	// they run and the paths they touch.
+	Rules []PermissionRule `json:"rules,omitempty" jsonschema:"description=Rules allowing or denying or always asking for tool calls by command and path; deny rules win over ask rules and ask rules over allow rules"`
+}
+
+// PermissionDecision is what a permission rule does with the calls it
+// matches.
+type PermissionDecision string
+
+const (
+	PermissionAllow PermissionDecision = "allow"
+	// PermissionAsk asks even in YOLO mode or for allowed tools.
+	PermissionAsk  PermissionDecision = "ask"
+	PermissionDeny PermissionDecision = "deny"
+)
+
+// PermissionRule matches tool calls by tool, and by command or path if set.
+type PermissionRule struct {
+	Tool     string             `json:"tool" jsonschema:"required,description=Tool the rule applies to as tool or tool:action; * matches any characters,example=bash,example=edit,example=mcp_github_*"`
+	Decision PermissionDecision `json:"decision" jsonschema:"required,description=What to do with the matching calls,enum=allow,enum=ask,enum=deny"`
+	// Commands match the start of the commands run by the call, word by
+	// word.
+	Commands []string `json:"commands,omitempty" jsonschema:"description=Commands matched word by word against the start of each command of the call; * matches any characters in a word,example=go test,example=rm -rf"`
+	// Paths match the files touched by the call, relative to the working
+	// directory.
+	Paths []string `json:"paths,omitempty" jsonschema:"description=Paths of the files touched by the call relative to the working directory; directories match the files under them and patterns without a slash match file names,example=src,example=.env,example=**/*.pem"`
+}
+
+// Serve configures crush serve. With users, each person of the team sharing
+// the server has a token, sees their own sessions, and has a budget and
+// permission policy.
+type Serve struct {
+	Users map[string]ServeUser `json:"users,omitempty" jsonschema:"description=Users of the server by name"`
+	// Roles override and add to the built-in viewer, contributor, and admin
+	// roles.
+	Roles map[string]ServeRole `json:"roles,omitempty" jsonschema:"description=Roles of the users by name; overrides the built-in viewer and contributor and admin roles"`
+}
+
+type ServeUser struct {
+	Token            string   `json:"token" jsonschema:"required,description=Token the user authenticates with; reference an environment variable or a command to keep it out of the config,example=$ALICE_CRUSH_TOKEN"`
+	Role             string   `json:"role,omitempty" jsonschema:"description=Role granting tools and approval authority; users without one answer the permission requests of their own sessions,example=viewer,example=contributor,example=admin"`
+	Admin            bool     `json:"admin,omitempty" jsonschema:"description=See the sessions of all users and answer their permission requests,default=false"`
+	Budget           float64  `json:"budget,omitempty" jsonschema:"description=Maximum cost in dollars of the sessions of the user; no limit when 0,minimum=0,example=50"`
+	AllowedTools     []string `json:"allowed_tools,omitempty" jsonschema:"description=Tools granted without asking as tool or tool:action,example=view,example=bash:execute"`
+	DeniedTools      []string `json:"denied_tools,omitempty" jsonschema:"description=Tools always denied as tool or tool:action,example=fetch"`
+	AllowDestructive bool     `json:"allow_destructive,omitempty" jsonschema:"description=Grant destructive commands of the allowed tools without asking,default=false"`
+}
+
+type ServeRole struct {
+	Tools            []string `json:"tools,omitempty" jsonschema:"description=Tools the members can use as tool or tool:action; calls of other tools are denied and all tools are allowed when empty,example=view,example=grep"`
+	AllowedTools     []string `json:"allowed_tools,omitempty" jsonschema:"description=Tools granted without asking as tool or tool:action,example=view,example=bash:execute"`
+	AllowDestructive bool     `json:"allow_destructive,omitempty" jsonschema:"description=Grant destructive commands of the allowed tools without asking,default=false"`
+	Approve          string   `json:"approve,omitempty" jsonschema:"description=Permission requests the members answer: none or the ones of their own sessions or all of them,enum=none,enum=own,enum=all,default=none"`
 }
 
 type Options struct {
-	ContextPaths         []string    `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
-	TUI                  *TUIOptions `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
-	Debug                bool        `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
-	DebugLSP             bool        `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
-	DisableAutoSummarize bool        `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
-	DataDirectory        string      `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
+	ContextPaths         []string             `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
+	TUI                  *TUIOptions          `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
+	Debug                bool                 `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
+	DebugLSP             bool                 `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
+	Record               bool                 `json:"record,omitempty" jsonschema:"description=Record the requests to the providers and their responses and the tool calls of the sessions for crush replay,default=false"`
+	DisableAutoSummarize bool                 `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable the automatic compaction of long conversations,default=false"`
+	DataDirectory        string               `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
+	Verify               *Verify              `json:"verify,omitempty" jsonschema:"description=Command that must pass before the agent can report a task as complete"`
+	ToolOutputDigest     *ToolOutputDigest    `json:"tool_output_digest,omitempty" jsonschema:"description=Summarize long tool outputs with the small model to save context"`
+	Storage              *Storage             `json:"storage,omitempty" jsonschema:"description=Where sessions and messages are stored"`
+	Budget               *Budget              `json:"budget,omitempty" jsonschema:"description=Limits on the tokens and dollars spent per session and per day"`
+	Routing              *Routing             `json:"routing,omitempty" jsonschema:"description=Model types the requests are sent to by kind of request"`
+	Proxy                *Proxy               `json:"proxy,omitempty" jsonschema:"description=Proxy of the requests to the providers unless they set their own; HTTP_PROXY and HTTPS_PROXY apply without it"`
+	TLS                  *TLS                 `json:"tls,omitempty" jsonschema:"description=CA bundle and client certificate of the connections to the providers and catwalk unless the providers set their own"`
+	Middleware           []Middleware         `json:"middleware,omitempty" jsonschema:"description=Middleware of the requests to all the providers in order; the first wraps the others"`
+	Sandbox              *Sandbox             `json:"sandbox,omitempty" jsonschema:"description=Isolation of the commands of the bash tool from the host"`
+	WebSearch            *WebSearch           `json:"web_search,omitempty" jsonschema:"description=Search engine of the websearch tool which is only available with it"`
+	Browser              *Browser             `json:"browser,omitempty" jsonschema:"description=Browser of the browser tool which drives Chrome or Chromium"`
+	CodeSearch           *CodeSearch          `json:"code_search,omitempty" jsonschema:"description=Semantic index of the project searched by the codesearch tool"`
+	Docker               *Docker              `json:"docker,omitempty" jsonschema:"description=Docker CLI of the docker tool which lists containers and runs compose services"`
+	Forge                *Forge               `json:"forge,omitempty" jsonschema:"description=GitHub or GitLab repository of the forge tool which reads issues and opens pull requests"`
+	Tests                *Tests               `json:"tests,omitempty" jsonschema:"description=Test runner of the test tool which runs the tests of the project and parses their failures"`
+	Checkpoints          *Checkpoints         `json:"checkpoints,omitempty" jsonschema:"description=Snapshots of the working directory before the turns changing files; restored with crush undo"`
+	DiagnosticsFeedback  *DiagnosticsFeedback `json:"diagnostics_feedback,omitempty" jsonschema:"description=LSP diagnostics of the changed files sent back to the agent after its edits"`
+	AutoLSP              *AutoLSP             `json:"auto_lsp,omitempty" jsonschema:"description=Language servers started and installed for the languages detected in the working directory"`
+	Compaction           *Compaction          `json:"compaction,omitempty" jsonschema:"description=When long conversations are compacted and how much of them is kept as is"`
+	Memory               *Memory              `json:"memory,omitempty" jsonschema:"description=Long-term memory of the facts the agent learns about the project; given back to it when relevant"`
+	Formatting           *Formatting          `json:"formatting,omitempty" jsonschema:"description=Formatters run on the files the agent changed"`
+	Tokenizers           *Tokenizers          `json:"tokenizers,omitempty" jsonschema:"description=Tokenizers counting the tokens of the conversations for the context fill and the costs"`
+	Logging              *Logging             `json:"logging,omitempty" jsonschema:"description=Levels of the logs by subsystem and their format and rotation"`
+	Bash                 *Bash                `json:"bash,omitempty" jsonschema:"description=Timeouts and output limits of the commands of the bash tool"`
+	ParallelTools        *ParallelTools       `json:"parallel_tools,omitempty" jsonschema:"description=Tool calls of a turn run at once; the read-only ones run concurrently and the edits and commands in order"`
+}
+
+// RequestKind is a kind of request to the models, routed to a model type by
+// Routing.
+type RequestKind string
+
+const (
+	// RequestCoder is a turn of the main agent.
+	RequestCoder RequestKind = "coder"
+	// RequestTask is a turn of a sub-agent, such as a search for context.
+	RequestTask RequestKind = "task"
+	// RequestTitle names a new session.
+	RequestTitle RequestKind = "title"
+	// RequestSummarize summarizes a session once it's too long.
+	RequestSummarize RequestKind = "summarize"
+	// RequestDigest summarizes a long tool output.
+	RequestDigest RequestKind = "digest"
+	// RequestCommit writes the message of crush commit.
+	RequestCommit RequestKind = "commit"
+)
+
+var defaultRouting = map[RequestKind]SelectedModelType{
+	RequestCoder:     SelectedModelTypeLarge,
+	RequestTask:      SelectedModelTypeLarge,
+	RequestTitle:     SelectedModelTypeSmall,
+	RequestSummarize: SelectedModelTypeSmall,
+	RequestDigest:    SelectedModelTypeSmall,
+	RequestCommit:    SelectedModelTypeSmall,
+}
+
+// Routing sends each kind of request to one of the models, by model type.
+// Besides large and small, any key of the models can be used, e.g. a local
+// model for the background requests.
+type Routing struct {
+	Coder     SelectedModelType `json:"coder,omitempty" jsonschema:"description=Model type of the turns of the main agent,default=large,example=large"`
+	Task      SelectedModelType `json:"task,omitempty" jsonschema:"description=Model type of the turns of the sub-agents searching for context,default=large,example=small"`
+	Title     SelectedModelType `json:"title,omitempty" jsonschema:"description=Model type naming the sessions,default=small,example=local"`
+	Summarize SelectedModelType `json:"summarize,omitempty" jsonschema:"description=Model type summarizing long sessions,default=small,example=local"`
+	Digest    SelectedModelType `json:"digest,omitempty" jsonschema:"description=Model type summarizing long tool outputs,default=small,example=local"`
+	Commit    SelectedModelType `json:"commit,omitempty" jsonschema:"description=Model type writing the commit messages of crush commit,default=small,example=large"`
+}
+
+func (r *Routing) modelType(kind RequestKind) SelectedModelType {
+	if r == nil {
+		return ""
+	}
+	switch kind {
+	case RequestCoder:
+		return r.Coder
+	case RequestTask:
+		return r.Task
+	case RequestTitle:
+		return r.Title
+	case RequestSummarize:
+		return r.Summarize
+	case RequestDigest:
+		return r.Digest
+	case RequestCommit:
+		return r.Commit
+	}
+	return ""
+}
+
+const (
+	BudgetExceededAsk       = "ask"
+	BudgetExceededDowngrade = "downgrade"
+)
+
+// Budget limits what the agents spend across all providers. A limit of 0 is
+// no limit. Sessions include their tasks, days start at local midnight.
+type Budget struct {
+	SessionCost   float64 `json:"session_cost,omitempty" jsonschema:"description=Maximum cost in dollars of a session,minimum=0,example=5"`
+	SessionTokens int64   `json:"session_tokens,omitempty" jsonschema:"description=Maximum number of tokens of a session,minimum=0,example=2000000"`
+	DailyCost     float64 `json:"daily_cost,omitempty" jsonschema:"description=Maximum cost in dollars of all sessions per day,minimum=0,example=20"`
+	DailyTokens   int64   `json:"daily_tokens,omitempty" jsonschema:"description=Maximum number of tokens of all sessions per day,minimum=0,example=10000000"`
+	// What the agent does once a limit is reached: ask to continue, or switch
+	// to the small model.
+	OnExceeded string `json:"on_exceeded,omitempty" jsonschema:"description=What the agent does once a limit is reached: ask to continue or switch to the small model,enum=ask,enum=downgrade,default=ask"`
+	// WarnAt is the share of a limit from which the budget_threshold webhook
+	// event is sent, before the one sent once it's reached.
+	WarnAt float64 `json:"warn_at,omitempty" jsonschema:"description=Share of a limit from which webhooks are warned before it's reached,minimum=0,maximum=1,default=0.8"`
+}
+
+// Webhook events.
+const (
+	WebhookTaskCompleted   = "task_completed"
+	WebhookApprovalNeeded  = "approval_needed"
+	WebhookBudgetThreshold = "budget_threshold"
+	WebhookError           = "error"
+)
+
+// Webhook receives session events as JSON posts, or runs a command on them.
+type Webhook struct {
+	URL string `json:"url,omitempty" jsonschema:"description=URL the events are posted to; can reference environment variables,example=https://hooks.example.com/crush"`
+	// Command is run by the shell on the events in place of posting them,
+	// with the event in the CRUSH_EVENT_* environment variables.
+	Command string `json:"command,omitempty" jsonschema:"description=Shell command run on the events in place of posting them; gets the event in the CRUSH_EVENT and CRUSH_EVENT_TEXT and CRUSH_EVENT_JSON environment variables,example=notify-send Crush \"$CRUSH_EVENT_TEXT\""`
+	// Events posted to the webhook, all of them when empty.
+	Events  []string          `json:"events,omitempty" jsonschema:"description=Events posted to the webhook; all of them when empty,enum=task_completed,enum=approval_needed,enum=budget_threshold,enum=error"`
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"description=HTTP headers sent with the events; values can reference environment variables"`
+	// Template is a Go template rendering the JSON body from the event. The
+	// event is posted as is when empty.
+	Template string `json:"template,omitempty" jsonschema:"description=Go template rendering the JSON body from the event; the event is posted as is when empty,example={\"text\": {{json .Text}}}"`
+	// Retries of a failed delivery, 3 when nil.
+	Retries *int `json:"retries,omitempty" jsonschema:"description=Retries of a failed delivery,minimum=0,default=3"`
+}
+
+// Storage selects the backend storing the sessions, messages and file
+// history.
+type Storage struct {
+	Backend string `json:"backend,omitempty" jsonschema:"description=Storage backend; sqlite is built in and other backends register themselves,default=sqlite,example=sqlite"`
+	DSN     string `json:"dsn,omitempty" jsonschema:"description=Data source name of the backend; for sqlite defaults to crush.db in the data directory,example=$CRUSH_DATABASE_URL"`
+}
+
+// ToolOutputDigest has the small model summarize long tool outputs, such as
+// build logs, in the background. The digest replaces the output in the
+// context of the large model, the full output stays visible to the user.
+type ToolOutputDigest struct {
+	MinLength         int `json:"min_length,omitempty" jsonschema:"description=Outputs longer than this many characters are summarized,default=20000"`
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" jsonschema:"description=Maximum number of outputs summarized per minute,default=10"`
+}
+
+// Verify configures the test-before-apply gate: after the agent changed files
+// it has to run the command successfully before finishing its turn.
+type Verify struct {
+	Command     string `json:"command" jsonschema:"required,description=Test or build command to run,example=go test ./...,example=npm test"`
+	MaxAttempts int    `json:"max_attempts,omitempty" jsonschema:"description=How many times the agent may try to fix a failing command before giving up,default=3"`
+	Timeout     int    `json:"timeout,omitempty" jsonschema:"description=Timeout for the command in seconds,default=600"`
 }
 
 type MCPs map[string]MCPConfig
@@ -240,6 +703,22 @@ type Agent struct {
 
 	// Overrides the context paths for this agent
 	ContextPaths []string `json:"context_paths,omitempty"`
+
+	// Instructions are added to the system prompt of the agent.
+	Instructions string `json:"instructions,omitempty"`
+
+	// PromptFile replaces the system prompt of the agent with its content.
+	PromptFile string `json:"prompt_file,omitempty"`
+}
+
+// Profile overrides parts of the config when selected. It's merged over the
+// config the same way project configs are merged over global ones.
+type Profile struct {
+	Models map[SelectedModelType]SelectedModel `json:"models,omitempty" jsonschema:"description=Model configurations for different model types"`
+
+	Providers map[string]ProviderConfig `json:"providers,omitempty" jsonschema:"description=AI provider configurations"`
+
+	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
 }
 
 // Config holds the configuration for crush.
@@ -258,8 +737,24 @@ type Config struct {
 
 	Permissions *Permissions `json:"permissions,omitempty" jsonschema:"description=Permission settings for tool usage"`
 
+	Serve *Serve `json:"serve,omitempty" jsonschema:"description=Settings of crush serve for teams sharing a server"`
+
+	Databases map[string]Database `json:"databases,omitempty" jsonschema:"description=Databases of the project the sql tool queries by name"`
+
+	SubAgents map[string]SubAgent `json:"sub_agents,omitempty" jsonschema:"description=Sub-agents the main agent hands tasks to with the agent tool by name"`
+
+	CustomAgents map[string]CustomAgent `json:"agents,omitempty" jsonschema:"description=Named agents with their own system prompt and tools switched to with /agent"`
+
+	Webhooks []Webhook `json:"webhooks,omitempty" jsonschema:"description=Webhooks receiving session events such as completed tasks and pending approvals"`
+
+	Hooks *Hooks `json:"hooks,omitempty" jsonschema:"description=Shell commands run on events of the agent such as before tool calls and after file edits"`
+
+	Profiles map[string]Profile `json:"profiles,omitempty" jsonschema:"description=Named profiles selected with --profile or CRUSH_PROFILE that override parts of the config,example={\"local\":{\"models\":{\"large\":{\"model\":\"qwen3\",\"provider\":\"ollama\"}}}}"`
+
 	// Internal
 	workingDir string `json:"-"`
+	// The name of the selected profile, if any.
+	profile string `json:"-"`
 	// TODO: most likely remove this concept when I come back to it
 	Agents map[string]Agent `json:"-"`
 	// TODO: find a better way to do this this should probably not be part of the config
@@ -272,6 +767,11 @@ func (c *Config) WorkingDir() string {
 	return c.workingDir
 }
 
+// Profile returns the name of the selected profile, or an empty string.
+func (c *Config) Profile() string {
+	return c.profile
+}
+
 func (c *Config) EnabledProviders() []ProviderConfig {
 	var enabled []ProviderConfig
 	for p := range c.Providers.Seq() {
@@ -317,6 +817,41 @@ func (c *Config) GetModelByType(modelType SelectedModelType) *catwalk.Model {
 	return c.GetModel(model.Provider, model.Model)
 }
 
+// ModelFor returns the model type the requests of the kind are routed to. It
+// falls back to the default one, large for the agents and small otherwise,
+// when the routed model isn't configured.
+func (c *Config) ModelFor(kind RequestKind) SelectedModelType {
+	fallback := defaultRouting[kind]
+	if c.Options == nil {
+		return fallback
+	}
+	modelType := c.Options.Routing.modelType(kind)
+	if modelType == "" || modelType == fallback {
+		return fallback
+	}
+	if c.GetModelByType(modelType) == nil {
+		slog.Warn("Routed model not configured, using the default one", "request", kind, "model_type", modelType, "default", fallback)
+		return fallback
+	}
+	return modelType
+}
+
+// GetPromptCache returns the prompt caching settings of the model, or nil to
+// use the defaults.
+func (c *Config) GetPromptCache(modelType SelectedModelType) *PromptCache {
+	model, ok := c.Models[modelType]
+	if !ok {
+		return nil
+	}
+	if model.PromptCache != nil {
+		return model.PromptCache
+	}
+	if providerCfg, ok := c.Providers.Get(model.Provider); ok {
+		return providerCfg.PromptCache
+	}
+	return nil
+}
+
 func (c *Config) LargeModel() *catwalk.Model {
 	model, ok := c.Models[SelectedModelTypeLarge]
 	if !ok {
@@ -333,6 +868,33 @@ func (c *Config) SmallModel() *catwalk.Model {
 	return c.GetModel(model.Provider, model.Model)
 }
 
+// EmbeddingModel returns the model used to compute embeddings and its
+// provider. If none is selected, the first embedding model of an enabled
+// provider is used. It returns nil if no embedding model is available.
+func (c *Config) EmbeddingModel() (*ProviderConfig, *catwalk.Model) {
+	if selected, ok := c.Models[SelectedModelTypeEmbedding]; ok {
+		if providerConfig, ok := c.Providers.Get(selected.Provider); ok {
+			for _, m := range providerConfig.EmbeddingModels {
+				if m.ID == selected.Model {
+					return &providerConfig, &m
+				}
+			}
+		}
+		slog.Warn("Selected embedding model not found", "provider", selected.Provider, "model", selected.Model)
+	}
+
+	providers := c.EnabledProviders()
+	slices.SortFunc(providers, func(a, b ProviderConfig) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	for _, p := range providers {
+		if len(p.EmbeddingModels) > 0 {
+			return &p, &p.EmbeddingModels[0]
+		}
+	}
+	return nil, nil
+}
+
 func (c *Config) SetCompactMode(enabled bool) error {
 	if c.Options == nil {
 		c.Options = &Options{}
@@ -341,6 +903,16 @@ func (c *Config) SetCompactMode(enabled bool) error {
 	return c.SetConfigField("options.tui.compact_mode", enabled)
 }
 
+// SetThinkingDisplay sets how the thinking of reasoning models is shown and
+// saves it.
+func (c *Config) SetThinkingDisplay(display string) error {
+	if c.Options == nil {
+		c.Options = &Options{}
+	}
+	c.Options.TUI.Thinking = display
+	return c.SetConfigField("options.tui.thinking", display)
+}
+
 func (c *Config) Resolve(key string) (string, error) {
 	if c.resolver == nil {
 		return "", fmt.Errorf("no variable resolver configured")
@@ -400,11 +972,17 @@ func (c *Config) SetProviderAPIKey(providerID, apiKey string) error {
 	}
 
 	if foundProvider != nil {
+		baseURL := foundProvider.APIEndpoint
+		if c.resolver != nil {
+			if baseURL, err = c.resolver.ResolveValue(baseURL); err != nil {
+				return fmt.Errorf("failed to resolve the API endpoint of provider %s: %w", providerID, err)
+			}
+		}
 		// Create new provider config based on known provider
 		providerConfig = ProviderConfig{
 			ID:           providerID,
 			Name:         foundProvider.Name,
-			BaseURL:      foundProvider.APIEndpoint,
+			BaseURL:      baseURL,
 			Type:         foundProvider.Type,
 			APIKey:       apiKey,
 			Disable:      false,
@@ -426,7 +1004,7 @@ func (c *Config) SetupAgents() {
 			ID:           "coder",
 			Name:         "Coder",
 			Description:  "An agent that helps with executing coding tasks.",
-			Model:        SelectedModelTypeLarge,
+			Model:        c.ModelFor(RequestCoder),
 			ContextPaths: c.Options.ContextPaths,
 			// All tools allowed
 		},
@@ -434,20 +1012,20 @@ func (c *Config) SetupAgents() {
 			ID:           "task",
 			Name:         "Task",
 			Description:  "An agent that helps with searching for context and finding implementation details.",
-			Model:        SelectedModelTypeLarge,
+			Model:        c.ModelFor(RequestTask),
 			ContextPaths: c.Options.ContextPaths,
-			AllowedTools: []string{
-				"glob",
-				"grep",
-				"ls",
-				"sourcegraph",
-				"view",
-			},
+			AllowedTools: taskTools,
 			// NO MCPs or LSPs by default
 			AllowedMCP: map[string][]string{},
 			AllowedLSP: []string{},
 		},
 	}
+	for name, subAgent := range c.SubAgents {
+		agents[name] = c.subAgent(name, subAgent)
+	}
+	for name, customAgent := range c.CustomAgents {
+		agents[name] = c.customAgent(name, customAgent)
+	}
 	c.Agents = agents
 }
 
@@ -484,7 +1062,10 @@ func (c *ProviderConfig) TestConnection(resolver VariableResolver) error {
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	client := &http.Client{}
+	client, err := discoveryClient(c.ID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to create client for provider %s: %w", c.ID, err)
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", testURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request for provider %s: %w", c.ID, err)
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_GetPromptCache(t *testing.T) {
+	providerCache := &PromptCache{Breakpoints: []string{CacheBreakpointSystem}}
+	modelCache := &PromptCache{Disable: true}
+	cfg := &Config{
+		Models: map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {Model: "claude-sonnet-4", Provider: "anthropic"},
+			SelectedModelTypeSmall: {Model: "claude-3-5-haiku", Provider: "anthropic", PromptCache: modelCache},
+		},
+		Providers: csync.NewMapFrom(map[string]ProviderConfig{
+			"anthropic": {ID: "anthropic", PromptCache: providerCache},
+		}),
+	}
+
+	require.Same(t, providerCache, cfg.GetPromptCache(SelectedModelTypeLarge))
+	require.Same(t, modelCache, cfg.GetPromptCache(SelectedModelTypeSmall))
+}
+
+func TestPromptCache_Caches(t *testing.T) {
+	var defaults *PromptCache
+	require.True(t, defaults.Caches(CacheBreakpointMessages))
+
+	systemOnly := &PromptCache{Breakpoints: []string{CacheBreakpointSystem}}
+	require.True(t, systemOnly.Caches(CacheBreakpointSystem))
+	require.False(t, systemOnly.Caches(CacheBreakpointTools))
+
+	disabled := &PromptCache{Disable: true, Breakpoints: []string{CacheBreakpointSystem}}
+	require.False(t, disabled.Caches(CacheBreakpointSystem))
+}
+
+func TestConfig_ModelFor(t *testing.T) {
+	cfg := &Config{
+		Options: &Options{
+			Routing: &Routing{
+				Task:      SelectedModelTypeSmall,
+				Title:     "local",
+				Summarize: "missing",
+			},
+		},
+		Models: map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {Model: "gpt-4o", Provider: "openai"},
+			SelectedModelTypeSmall: {Model: "gpt-4o-mini", Provider: "openai"},
+			"local":                {Model: "qwen3", Provider: "ollama"},
+		},
+		Providers: csync.NewMapFrom(map[string]ProviderConfig{
+			"openai": {ID: "openai", Models: []catwalk.Model{{ID: "gpt-4o"}, {ID: "gpt-4o-mini"}}},
+			"ollama": {ID: "ollama", Models: []catwalk.Model{{ID: "qwen3"}}},
+		}),
+	}
+
+	require.Equal(t, SelectedModelTypeLarge, cfg.ModelFor(RequestCoder))
+	require.Equal(t, SelectedModelTypeSmall, cfg.ModelFor(RequestTask))
+	require.Equal(t, SelectedModelType("local"), cfg.ModelFor(RequestTitle))
+	require.Equal(t, SelectedModelTypeSmall, cfg.ModelFor(RequestSummarize))
+	require.Equal(t, SelectedModelTypeSmall, cfg.ModelFor(RequestDigest))
+	require.Equal(t, SelectedModelTypeSmall, cfg.ModelFor(RequestCommit))
+
+	cfg.SetupAgents()
+	require.Equal(t, SelectedModelTypeLarge, cfg.Agents["coder"].Model)
+	require.Equal(t, SelectedModelTypeSmall, cfg.Agents["task"].Model)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+)
+
+// CustomAgent is a main agent with its own system prompt, tools, and model,
+// switched to with /agent in place of the coder agent.
+type CustomAgent struct {
+	Description  string            `json:"description,omitempty" jsonschema:"description=What the agent is for,example=Reviews the changes for bugs and missing tests"`
+	PromptFile   string            `json:"prompt_file" jsonschema:"required,description=Markdown file with the system prompt of the agent; relative paths are relative to the working directory,example=.crush/agents/reviewer.md"`
+	Model        SelectedModelType `json:"model,omitempty" jsonschema:"description=Model type of the agent; defaults to the one routed to coder,example=small,example=large"`
+	AllowedTools []string          `json:"allowed_tools,omitempty" jsonschema:"description=Tools the agent can use; defaults to all tools,example=view,example=grep,example=bash"`
+}
+
+func (a *CustomAgent) validate(name string) error {
+	if name == "coder" || name == "task" {
+		return fmt.Errorf("%q is the name of a built-in agent", name)
+	}
+	if a.PromptFile == "" {
+		return fmt.Errorf("prompt_file is required")
+	}
+	return nil
+}
+
+// MainAgents returns the names of the agents the user can talk to, the coder
+// agent first.
+func (c *Config) MainAgents() []string {
+	return append([]string{"coder"}, slices.Sorted(maps.Keys(c.CustomAgents))...)
+}
+
+// IsMainAgent reports whether the agent is the coder agent or a custom agent
+// used in its place.
+func (c *Config) IsMainAgent(id string) bool {
+	if id == "coder" {
+		return true
+	}
+	_, ok := c.CustomAgents[id]
+	return ok
+}
+
+// customAgent returns the agent of the custom agent.
+func (c *Config) customAgent(name string, a CustomAgent) Agent {
+	agent := Agent{
+		ID:           name,
+		Name:         name,
+		Description:  a.Description,
+		Model:        a.Model,
+		ContextPaths: c.Options.ContextPaths,
+		AllowedTools: a.AllowedTools,
+		PromptFile:   a.PromptFile,
+	}
+	if agent.Model == "" {
+		agent.Model = c.ModelFor(RequestCoder)
+	}
+	if !filepath.IsAbs(agent.PromptFile) {
+		agent.PromptFile = filepath.Join(c.WorkingDir(), agent.PromptFile)
+	}
+	return agent
+}
@@ -0,0 +1,31 @@
+package config
+
+import "fmt"
+
+// Database drivers of the sql tool.
+const (
+	DatabasePostgres = "postgres"
+	DatabaseMySQL    = "mysql"
+	DatabaseSQLite   = "sqlite"
+)
+
+// Database is a database of the project the sql tool queries. Its queries are
+// read-only unless the model asks to write and the user allows it.
+type Database struct {
+	Driver      string `json:"driver" jsonschema:"required,description=Kind of database; postgres and mysql are queried with the psql and mysql clients,enum=postgres,enum=mysql,enum=sqlite"`
+	DSN         string `json:"dsn" jsonschema:"required,description=Connection URL of postgres and mysql or path of the sqlite file,example=$DATABASE_URL,example=postgres://localhost:5432/app_dev,example=db/development.sqlite3"`
+	Description string `json:"description,omitempty" jsonschema:"description=What the database is for the model to pick the right one,example=Development database of the API"`
+	ReadOnly    bool   `json:"read_only,omitempty" jsonschema:"description=Deny writes even when the user would allow them,default=false"`
+}
+
+func (d *Database) validate() error {
+	switch d.Driver {
+	case DatabasePostgres, DatabaseMySQL, DatabaseSQLite:
+	default:
+		return fmt.Errorf("unknown driver %q, expected one of postgres, mysql, and sqlite", d.Driver)
+	}
+	if d.DSN == "" {
+		return fmt.Errorf("dsn is required")
+	}
+	return nil
+}
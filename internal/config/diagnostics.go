@@ -0,0 +1,31 @@
+package config
+
+import "fmt"
+
+// Severities of the diagnostics fed back to the agent.
+const (
+	DiagnosticSeverityError   = "error"
+	DiagnosticSeverityWarning = "warning"
+	DiagnosticSeverityInfo    = "info"
+	DiagnosticSeverityHint    = "hint"
+)
+
+// DiagnosticsFeedback configures the LSP diagnostics of the changed files
+// sent back to the agent after its edits, so it fixes the errors it made.
+type DiagnosticsFeedback struct {
+	Disabled       bool   `json:"disabled,omitempty" jsonschema:"description=Disable the diagnostics feedback,default=false"`
+	Severity       string `json:"severity,omitempty" jsonschema:"description=Lowest severity of the diagnostics sent back,enum=error,enum=warning,enum=info,enum=hint,default=error"`
+	MaxDiagnostics int    `json:"max_diagnostics,omitempty" jsonschema:"description=Maximum number of diagnostics sent back after an edit,default=20,minimum=1"`
+}
+
+func (d *DiagnosticsFeedback) validate() error {
+	switch d.Severity {
+	case "", DiagnosticSeverityError, DiagnosticSeverityWarning, DiagnosticSeverityInfo, DiagnosticSeverityHint:
+	default:
+		return fmt.Errorf("unknown severity %q, expected error, warning, info, or hint", d.Severity)
+	}
+	if d.MaxDiagnostics < 0 {
+		return fmt.Errorf("max_diagnostics must not be negative")
+	}
+	return nil
+}
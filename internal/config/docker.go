@@ -0,0 +1,8 @@
+package config
+
+// Docker configures the docker tool, available when the docker CLI is found.
+type Docker struct {
+	Disabled     bool     `json:"disabled,omitempty" jsonschema:"description=Disable the docker tool,default=false"`
+	Path         string   `json:"path,omitempty" jsonschema:"description=Path of the docker CLI; looked up in the PATH without it,example=/usr/local/bin/docker"`
+	ComposeFiles []string `json:"compose_files,omitempty" jsonschema:"description=Compose files of the project relative to the working directory; the default ones of docker compose without them,example=docker-compose.dev.yml"`
+}
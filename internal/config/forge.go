@@ -0,0 +1,28 @@
+package config
+
+import "fmt"
+
+// Forges hosting the repository.
+const (
+	ForgeGitHub = "github"
+	ForgeGitLab = "gitlab"
+)
+
+// Forge configures the forge tool, to read the issues of the repository and
+// open pull requests on GitHub or merge requests on GitLab.
+type Forge struct {
+	Disabled bool   `json:"disabled,omitempty" jsonschema:"description=Disable the forge tool,default=false"`
+	Type     string `json:"type,omitempty" jsonschema:"description=Forge hosting the repository; detected from the host of the remote without it,enum=github,enum=gitlab"`
+	Token    string `json:"token,omitempty" jsonschema:"description=Access token of the forge; $GITHUB_TOKEN or $GH_TOKEN or $GITLAB_TOKEN without it,example=keyring://crush/github,example=cmd://gh auth token"`
+	URL      string `json:"url,omitempty" jsonschema:"description=URL of the API of GitHub Enterprise Server or of a self-hosted GitLab if not derived from the remote,example=https://git.example.com/api/v4"`
+	Remote   string `json:"remote,omitempty" jsonschema:"description=Git remote of the repository on the forge,default=origin"`
+}
+
+func (f *Forge) validate() error {
+	switch f.Type {
+	case "", ForgeGitHub, ForgeGitLab:
+		return nil
+	default:
+		return fmt.Errorf("unknown type %q, expected github or gitlab", f.Type)
+	}
+}
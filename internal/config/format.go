@@ -0,0 +1,155 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a supported config file format.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// configExtensions are the supported config file extensions, in order of
+// precedence when several exist in the same directory (later ones win).
+var configExtensions = []string{".json", ".toml", ".yaml", ".yml"}
+
+// configFiles returns the paths of a config file with the given name in all
+// supported formats.
+func configFiles(dir, name string) []string {
+	paths := make([]string, 0, len(configExtensions))
+	for _, ext := range configExtensions {
+		paths = append(paths, filepath.Join(dir, name+ext))
+	}
+	return paths
+}
+
+// FormatFromPath detects the format of a config file from its extension.
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".toml":
+		return FormatTOML, nil
+	}
+	return "", fmt.Errorf("unsupported config format: %s", path)
+}
+
+// ConvertConfig converts config data from one format to another. Values are
+// kept as is, the schema is identical in all formats.
+func ConvertConfig(data []byte, from, to Format) ([]byte, error) {
+	normalized, err := decodeConfig(data, from)
+	if err != nil {
+		return nil, err
+	}
+	switch to {
+	case FormatJSON:
+		out, err := json.MarshalIndent(normalized, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode JSON: %w", err)
+		}
+		return append(out, '\n'), nil
+	case FormatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(normalized); err != nil {
+			return nil, fmt.Errorf("failed to encode YAML: %w", err)
+		}
+		return buf.Bytes(), nil
+	case FormatTOML:
+		out, err := toml.Marshal(normalized)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode TOML: %w", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported config format: %s", to)
+}
+
+// decodeConfig decodes config data into normalized values, see normalizeValue.
+func decodeConfig(data []byte, from Format) (map[string]any, error) {
+	var value map[string]any
+	switch from {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", from)
+	}
+	if value == nil {
+		value = map[string]any{}
+	}
+
+	normalized, _ := normalizeValue(value).(map[string]any)
+	return normalized, nil
+}
+
+// normalizeValue turns decoded values into types all encoders understand:
+// JSON numbers become ints or floats, nulls are dropped as TOML has no
+// representation for them.
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			if value == nil {
+				continue
+			}
+			m[key] = normalizeValue(value)
+		}
+		return m
+	case []any:
+		s := make([]any, 0, len(v))
+		for _, value := range v {
+			if value == nil {
+				continue
+			}
+			s = append(s, normalizeValue(value))
+		}
+		return s
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// readConfigFile converts the config file data to JSON, so all formats can
+// be merged and loaded the same way.
+func readConfigFile(path string, data []byte) ([]byte, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatJSON {
+		return data, nil
+	}
+	return ConvertConfig(data, format, FormatJSON)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertConfig_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	input := []byte(`{
+  "options": {
+    "debug": true,
+    "context_paths": ["CRUSH.md"]
+  },
+  "providers": {
+    "deepseek": {
+      "api_key": "$DEEPSEEK_API_KEY",
+      "models": [
+        {
+          "context_window": 64000,
+          "cost_per_1m_in": 0.27,
+          "id": "deepseek-chat"
+        }
+      ]
+    }
+  }
+}
+`)
+
+	for _, format := range []Format{FormatYAML, FormatTOML} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+			converted, err := ConvertConfig(input, FormatJSON, format)
+			require.NoError(t, err)
+			back, err := ConvertConfig(converted, format, FormatJSON)
+			require.NoError(t, err)
+			require.JSONEq(t, string(input), string(back))
+		})
+	}
+}
+
+func TestConvertConfig_DropsNulls(t *testing.T) {
+	t.Parallel()
+
+	out, err := ConvertConfig([]byte(`{"options": {"tui": null, "debug": true}}`), FormatJSON, FormatTOML)
+	require.NoError(t, err)
+	require.Equal(t, "[options]\ndebug = true\n", string(out))
+}
+
+func TestFormatFromPath(t *testing.T) {
+	t.Parallel()
+
+	for path, want := range map[string]Format{
+		"crush.json": FormatJSON,
+		".crush.yml": FormatYAML,
+		"crush.YAML": FormatYAML,
+		"crush.toml": FormatTOML,
+	} {
+		got, err := FormatFromPath(path)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := FormatFromPath("crush.ini")
+	require.Error(t, err)
+}
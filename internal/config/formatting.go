@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Formatting configures the formatters run on the files the agent changed, so
+// its diffs don't carry the formatting it got wrong.
+type Formatting struct {
+	Enabled bool `json:"enabled,omitempty" jsonschema:"description=Format the files changed by the tools after each turn of tool calls,default=false"`
+	// Formatters replace the built-in formatter of an extension; an empty
+	// command leaves the files of the extension as they are.
+	Formatters map[string][]string `json:"formatters,omitempty" jsonschema:"description=Formatter commands by file extension run with the path of the file appended; they replace the built-in gofmt and goimports and prettier and black and rustfmt and an empty command disables the extension"`
+}
+
+func (f *Formatting) validate() error {
+	for ext := range f.Formatters {
+		if !strings.HasPrefix(ext, ".") || len(ext) < 2 {
+			return fmt.Errorf("invalid extension %q, expected a dot and a suffix such as .go", ext)
+		}
+	}
+	return nil
+}
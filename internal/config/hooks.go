@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Hook events.
+const (
+	HookSessionStart = "session_start"
+	HookPreToolCall  = "pre_tool_call"
+	HookPostEdit     = "post_edit"
+	HookSessionEnd   = "session_end"
+)
+
+// Hooks are shell commands run on events of the agent, with the event as
+// JSON on their standard input.
+type Hooks struct {
+	SessionStart []Hook `json:"session_start,omitempty" jsonschema:"description=Commands run when the first prompt of a session is sent"`
+	// PreToolCall hooks veto the tool calls by failing.
+	PreToolCall []Hook `json:"pre_tool_call,omitempty" jsonschema:"description=Commands run before each tool call; a failing command vetoes the call and its output tells the model why"`
+	// PostEdit hooks run once per file changed by the tools.
+	PostEdit []Hook `json:"post_edit,omitempty" jsonschema:"description=Commands run on each file changed by the tools such as formatters; a failing command reports its output to the model"`
+	// SessionEnd hooks run on exit, for the sessions crush worked on.
+	SessionEnd []Hook `json:"session_end,omitempty" jsonschema:"description=Commands run on exit for each session prompted since start with its usage"`
+}
+
+// Hook is a shell command run on an event.
+type Hook struct {
+	Command string `json:"command" jsonschema:"required,description=Shell command run in the working directory with the event as JSON on its standard input,example=gofmt -w \"$CRUSH_FILE\""`
+	// Tools limits pre_tool_call hooks to the calls of these tools.
+	Tools []string `json:"tools,omitempty" jsonschema:"description=Tools whose calls run the pre_tool_call hook; all when empty and * matches any characters,example=bash,example=mcp_*"`
+	// Files limits post_edit hooks to the files matching these patterns.
+	Files []string `json:"files,omitempty" jsonschema:"description=Files the post_edit hook runs on relative to the working directory; all when empty and patterns without a slash match file names,example=*.go,example=web/**/*.ts"`
+	// Timeout in seconds, 60 when zero.
+	Timeout int `json:"timeout,omitempty" jsonschema:"description=Timeout of the command in seconds,minimum=0,default=60"`
+}
+
+// All returns the hooks of the event.
+func (h *Hooks) All(event string) []Hook {
+	if h == nil {
+		return nil
+	}
+	switch event {
+	case HookSessionStart:
+		return h.SessionStart
+	case HookPreToolCall:
+		return h.PreToolCall
+	case HookPostEdit:
+		return h.PostEdit
+	case HookSessionEnd:
+		return h.SessionEnd
+	}
+	return nil
+}
+
+func (h *Hooks) validate() error {
+	for _, event := range []string{HookSessionStart, HookPreToolCall, HookPostEdit, HookSessionEnd} {
+		for i, hook := range h.All(event) {
+			if err := hook.validate(event); err != nil {
+				return fmt.Errorf("%s hook %d: %w", event, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (h Hook) validate(event string) error {
+	if h.Command == "" {
+		return errors.New("no command")
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("negative timeout %d", h.Timeout)
+	}
+	if len(h.Tools) > 0 && event != HookPreToolCall {
+		return errors.New("tools only apply to pre_tool_call hooks")
+	}
+	if len(h.Files) > 0 && event != HookPostEdit {
+		return errors.New("files only apply to post_edit hooks")
+	}
+	for _, tool := range h.Tools {
+		if _, err := path.Match(tool, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q", tool)
+		}
+	}
+	for _, file := range h.Files {
+		if !doublestar.ValidatePattern(filepath.ToSlash(file)) {
+			return fmt.Errorf("invalid file pattern %q", file)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooksValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, (*Hooks)(nil).validate())
+	require.NoError(t, (&Hooks{
+		PreToolCall: []Hook{{Command: "./check.sh", Tools: []string{"bash", "mcp_*"}}},
+		PostEdit:    []Hook{{Command: "gofmt -w \"$CRUSH_FILE\"", Files: []string{"*.go", "web/**/*.ts"}}},
+	}).validate())
+	require.ErrorContains(t, (&Hooks{SessionStart: []Hook{{}}}).validate(), "session_start hook 0: no command")
+	require.ErrorContains(t, (&Hooks{PostEdit: []Hook{{Command: "true", Tools: []string{"bash"}}}}).validate(), "tools only apply to pre_tool_call hooks")
+	require.ErrorContains(t, (&Hooks{PreToolCall: []Hook{{Command: "true", Files: []string{"*.go"}}}}).validate(), "files only apply to post_edit hooks")
+	require.ErrorContains(t, (&Hooks{PreToolCall: []Hook{{Command: "true", Tools: []string{"[bash"}}}}).validate(), `invalid tool pattern "[bash"`)
+	require.ErrorContains(t, (&Hooks{SessionEnd: []Hook{{Command: "true", Timeout: -1}}}).validate(), "negative timeout -1")
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,8 +22,8 @@ type ProjectInitFlag struct {
 // TODO: we need to remove the global config instance keeping it now just until everything is migrated
 var instance atomic.Pointer[Config]
 
-func Init(workingDir string, debug bool) (*Config, error) {
-	cfg, err := Load(workingDir, debug)
+func Init(workingDir, profile string, debug bool) (*Config, error) {
+	cfg, err := Load(workingDir, profile, debug)
 	if err != nil {
 		return nil, err
 	}
@@ -33,6 +36,44 @@ func Get() *Config {
 	return cfg
 }
 
+// Set makes cfg the current config, for tests and programs that build the
+// config themselves instead of loading it.
+func Set(cfg *Config) {
+	instance.Store(cfg)
+}
+
+// Reload loads the config again from disk for the same working directory and
+// profile, re-reading the providers cache, and makes it the current config.
+// It reports whether the config changed.
+func Reload() (*Config, bool, error) {
+	current := Get()
+	if current == nil {
+		return nil, false, fmt.Errorf("config not loaded")
+	}
+	if err := reloadProviders(); err != nil {
+		slog.Warn("Failed to reload providers", "error", err)
+	}
+	cfg, err := Load(current.workingDir, current.profile, current.Options.Debug)
+	if err != nil {
+		return nil, false, err
+	}
+	// YOLO mode comes from a flag, not from the config files.
+	if current.Permissions != nil && current.Permissions.SkipRequests {
+		if cfg.Permissions == nil {
+			cfg.Permissions = &Permissions{}
+		}
+		cfg.Permissions.SkipRequests = true
+	}
+
+	before, _ := json.Marshal(current)
+	after, _ := json.Marshal(cfg)
+	if bytes.Equal(before, after) {
+		return current, false, nil
+	}
+	instance.Store(cfg)
+	return cfg, true, nil
+}
+
 func ProjectNeedsInitialization() (bool, error) {
 	cfg := Get()
 	if cfg == nil {
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+type KeymapMode string
+
+const (
+	KeymapDefault KeymapMode = "default"
+	// KeymapVim adds a normal mode to the editor, entered with esc, and the
+	// ctrl scrolling keys of vim to the lists.
+	KeymapVim KeymapMode = "vim"
+)
+
+// KeymapActions are the actions of the TUI whose keys can be bound.
+var KeymapActions = []string{
+	// Anywhere.
+	"quit", "help", "commands", "suspend", "sessions",
+	// The chat page.
+	"new_session", "add_attachment", "cancel", "change_focus", "toggle_details",
+	"toggle_live_panel", "zoom_live_panel",
+	// The editor.
+	"send", "newline", "open_editor", "paste_image",
+	// The lists, such as the messages and the dialogs.
+	"down", "up", "page_down", "page_up", "half_page_down", "half_page_up", "home", "end",
+}
+
+// Keymap changes the key bindings of the TUI.
+type Keymap struct {
+	Mode     KeymapMode          `json:"mode,omitempty" jsonschema:"description=Key bindings of the editor and the lists; vim adds a normal mode to the editor,enum=default,enum=vim,default=default"`
+	Bindings map[string][]string `json:"bindings,omitempty" jsonschema:"description=Keys of the actions such as quit or send or newline in place of their default ones"`
+}
+
+func (k *Keymap) validate() error {
+	if k.Mode != "" && k.Mode != KeymapDefault && k.Mode != KeymapVim {
+		return fmt.Errorf("unknown mode %q, expected default or vim", k.Mode)
+	}
+	for action, keys := range k.Bindings {
+		if !slices.Contains(KeymapActions, action) {
+			return fmt.Errorf("unknown action %q", action)
+		}
+		if len(keys) == 0 || slices.Contains(keys, "") {
+			return fmt.Errorf("action %q has no keys", action)
+		}
+	}
+	return nil
+}
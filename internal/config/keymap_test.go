@@ -0,0 +1,16 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeymapValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, (&Keymap{Mode: KeymapVim, Bindings: map[string][]string{"quit": {"ctrl+q"}}}).validate())
+	require.ErrorContains(t, (&Keymap{Mode: "emacs"}).validate(), `unknown mode "emacs"`)
+	require.ErrorContains(t, (&Keymap{Bindings: map[string][]string{"exit": {"ctrl+q"}}}).validate(), `unknown action "exit"`)
+	require.ErrorContains(t, (&Keymap{Bindings: map[string][]string{"quit": {}}}).validate(), `action "quit" has no keys`)
+}
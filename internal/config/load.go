@@ -1,6 +1,8 @@
 package config
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -35,23 +37,165 @@ func LoadReader(fd io.Reader) (*Config, error) {
 	return &config, err
 }
 
-// Load loads the configuration from the default paths.
-func Load(workingDir string, debug bool) (*Config, error) {
-	// uses default config paths
-	configPaths := []string{
-		globalConfig(),
-		GlobalConfigData(),
-		filepath.Join(workingDir, fmt.Sprintf("%s.json", appName)),
-		filepath.Join(workingDir, fmt.Sprintf(".%s.json", appName)),
-	}
+// ConfigPaths returns the paths of the config files loaded for workingDir,
+// in the order they are merged. They don't necessarily exist.
+func ConfigPaths(workingDir string) []string {
+	// project configs are merged over the global ones
+	configPaths := configFiles(filepath.Dir(globalConfig()), appName)
+	configPaths = append(configPaths, GlobalConfigData())
+	return append(configPaths, projectConfigPaths(workingDir)...)
+}
+
+// New returns a config for the working directory with the default options
+// and agents, without reading the config files nor fetching the providers.
+// Providers and models are added by the caller, values are resolved from the
+// environment.
+func New(workingDir string) *Config {
+	cfg := &Config{}
+	cfg.setDefaults(workingDir)
+	cfg.resolver = NewEnvironmentVariableResolver(env.New())
+	cfg.SetupAgents()
+	return cfg
+}
+
+// Load loads the configuration from the default paths. If profile is empty,
+// the profile named by CRUSH_PROFILE is used, if any.
+func Load(workingDir, profile string, debug bool) (*Config, error) {
+	configPaths := ConfigPaths(workingDir)
 	cfg, err := loadFromConfigPaths(configPaths)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load config from paths %v: %w", configPaths, err)
 	}
 
+	if profile := cmp.Or(profile, os.Getenv("CRUSH_PROFILE")); profile != "" {
+		if cfg, err = cfg.withProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+
 	cfg.dataConfigDir = GlobalConfigData()
 
 	cfg.setDefaults(workingDir)
+	if cfg.Options.Sandbox != nil {
+		if err := cfg.Options.Sandbox.validate(); err != nil {
+			return nil, fmt.Errorf("invalid sandbox: %w", err)
+		}
+	}
+	if cfg.Options.WebSearch != nil {
+		if err := cfg.Options.WebSearch.validate(); err != nil {
+			return nil, fmt.Errorf("invalid web_search: %w", err)
+		}
+	}
+	if cfg.Options.Forge != nil {
+		if err := cfg.Options.Forge.validate(); err != nil {
+			return nil, fmt.Errorf("invalid forge: %w", err)
+		}
+	}
+	if cfg.Options.Tests != nil {
+		if err := cfg.Options.Tests.validate(); err != nil {
+			return nil, fmt.Errorf("invalid tests: %w", err)
+		}
+	}
+	if cfg.Options.DiagnosticsFeedback != nil {
+		if err := cfg.Options.DiagnosticsFeedback.validate(); err != nil {
+			return nil, fmt.Errorf("invalid diagnostics_feedback: %w", err)
+		}
+	}
+	if cfg.Options.Compaction != nil {
+		if err := cfg.Options.Compaction.validate(); err != nil {
+			return nil, fmt.Errorf("invalid compaction: %w", err)
+		}
+	}
+	if cfg.Options.Bash != nil {
+		if err := cfg.Options.Bash.validate(); err != nil {
+			return nil, fmt.Errorf("invalid bash: %w", err)
+		}
+	}
+	if cfg.Options.ParallelTools != nil {
+		if err := cfg.Options.ParallelTools.validate(); err != nil {
+			return nil, fmt.Errorf("invalid parallel_tools: %w", err)
+		}
+	}
+	if cfg.Options.Memory != nil {
+		if err := cfg.Options.Memory.validate(); err != nil {
+			return nil, fmt.Errorf("invalid memory: %w", err)
+		}
+	}
+	if cfg.Options.Formatting != nil {
+		if err := cfg.Options.Formatting.validate(); err != nil {
+			return nil, fmt.Errorf("invalid formatting: %w", err)
+		}
+	}
+	if cfg.Options.Tokenizers != nil {
+		if err := cfg.Options.Tokenizers.validate(); err != nil {
+			return nil, fmt.Errorf("invalid tokenizers: %w", err)
+		}
+	}
+	if cfg.Options.Logging != nil {
+		if err := cfg.Options.Logging.validate(); err != nil {
+			return nil, fmt.Errorf("invalid logging: %w", err)
+		}
+	}
+	if err := validateMiddleware(cfg.Options.Middleware); err != nil {
+		return nil, fmt.Errorf("invalid middleware: %w", err)
+	}
+	for id, p := range cfg.Providers.Seq2() {
+		if err := validateMiddleware(p.Middleware); err != nil {
+			return nil, fmt.Errorf("invalid middleware of provider %q: %w", id, err)
+		}
+		if p.OpenAI != nil {
+			if err := p.OpenAI.validate(); err != nil {
+				return nil, fmt.Errorf("invalid openai of provider %q: %w", id, err)
+			}
+		}
+	}
+	for modelType, m := range cfg.Models {
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s model: %w", modelType, err)
+		}
+	}
+	if cfg.Options.TUI.Keymap != nil {
+		if err := cfg.Options.TUI.Keymap.validate(); err != nil {
+			return nil, fmt.Errorf("invalid keymap: %w", err)
+		}
+	}
+	if err := validateThinkingDisplay(cfg.Options.TUI.Thinking); err != nil {
+		return nil, fmt.Errorf("invalid tui options: %w", err)
+	}
+	if cfg.Options.TUI.Notifications != nil {
+		if err := cfg.Options.TUI.Notifications.validate(); err != nil {
+			return nil, fmt.Errorf("invalid notifications: %w", err)
+		}
+	}
+	if cfg.Hooks != nil {
+		if err := cfg.Hooks.validate(); err != nil {
+			return nil, fmt.Errorf("invalid hooks: %w", err)
+		}
+	}
+	for name, subAgent := range cfg.SubAgents {
+		if err := subAgent.validate(name); err != nil {
+			return nil, fmt.Errorf("invalid sub-agent %q: %w", name, err)
+		}
+	}
+	for name, customAgent := range cfg.CustomAgents {
+		if err := customAgent.validate(name); err != nil {
+			return nil, fmt.Errorf("invalid agent %q: %w", name, err)
+		}
+		if _, ok := cfg.SubAgents[name]; ok {
+			return nil, fmt.Errorf("invalid agent %q: a sub-agent has the same name", name)
+		}
+	}
+	for name, db := range cfg.Databases {
+		if err := db.validate(); err != nil {
+			return nil, fmt.Errorf("invalid database %q: %w", name, err)
+		}
+	}
+	cfg.addDetectedLSPs()
+	for name, lsp := range cfg.LSP {
+		if err := lsp.validate(); err != nil && !lsp.Disabled {
+			return nil, fmt.Errorf("invalid lsp %q: %w", name, err)
+		}
+	}
 
 	if debug {
 		cfg.Options.Debug = true
@@ -60,9 +204,13 @@ func Load(workingDir string, debug bool) (*Config, error) {
 	// Setup logs
 	log.Setup(
 		filepath.Join(cfg.Options.DataDirectory, "logs", fmt.Sprintf("%s.log", appName)),
-		cfg.Options.Debug,
+		cfg.Options.logOptions(),
 	)
 
+	// Discovery requests and the catwalk fetch go through the proxies and
+	// use the TLS settings too.
+	setDiscoveryTransports(cfg)
+
 	// Load known providers, this loads the config from catwalk
 	providers, err := Providers()
 	if err != nil || len(providers) == 0 {
@@ -74,6 +222,9 @@ func Load(workingDir string, debug bool) (*Config, error) {
 	// Configure providers
 	valueResolver := NewShellVariableResolver(env)
 	cfg.resolver = valueResolver
+	if err := cfg.resolveValues(valueResolver); err != nil {
+		return nil, fmt.Errorf("failed to resolve config values: %w", err)
+	}
 	if err := cfg.configureProviders(env, valueResolver, providers); err != nil {
 		return nil, fmt.Errorf("failed to configure providers: %w", err)
 	}
@@ -137,6 +288,17 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			}
 		}
 
+		// The base URL of the config is resolved already, the endpoints of
+		// the known providers may reference variables too. The clients use
+		// their default endpoints when they don't resolve.
+		if config.BaseURL == "" {
+			endpoint, err := resolver.ResolveValue(p.APIEndpoint)
+			if err != nil {
+				slog.Debug("Provider endpoint doesn't resolve", "provider", p.ID, "error", err)
+			}
+			p.APIEndpoint = endpoint
+		}
+
 		headers := map[string]string{}
 		if len(p.DefaultHeaders) > 0 {
 			maps.Copy(headers, p.DefaultHeaders)
@@ -156,33 +318,51 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			ExtraBody:          config.ExtraBody,
 			ExtraParams:        make(map[string]string),
 			Models:             p.Models,
+			EmbeddingModels:    config.EmbeddingModels,
+			OpenAI:             config.OpenAI,
+			Azure:              config.Azure,
+			Bedrock:            config.Bedrock,
+			VertexAI:           config.VertexAI,
+			PromptCache:        config.PromptCache,
+			Pricing:            config.Pricing,
+			Proxy:              config.Proxy,
+			TLS:                config.TLS,
+			RateLimit:          config.RateLimit,
+			Middleware:         config.Middleware,
+		}
+		if discovered, ok := localEmbeddingModels.Get(string(p.ID)); ok {
+			prepared.EmbeddingModels = append(prepared.EmbeddingModels, discovered...)
 		}
 
 		switch p.ID {
 		// Handle specific providers that require additional configuration
 		case catwalk.InferenceProviderVertexAI:
-			if !hasVertexCredentials(env) {
+			project, location := vertexProjectLocation(prepared.VertexAI, env)
+			if project == "" || location == "" {
 				if configExists {
-					slog.Warn("Skipping Vertex AI provider due to missing credentials")
+					slog.Warn("Skipping Vertex AI provider due to missing project or location")
 					c.Providers.Del(string(p.ID))
 				}
 				continue
 			}
-			prepared.ExtraParams["project"] = env.Get("VERTEXAI_PROJECT")
-			prepared.ExtraParams["location"] = env.Get("VERTEXAI_LOCATION")
+			prepared.ExtraParams["project"] = project
+			prepared.ExtraParams["location"] = location
 		case catwalk.InferenceProviderAzure:
-			endpoint, err := resolver.ResolveValue(p.APIEndpoint)
-			if err != nil || endpoint == "" {
+			if prepared.BaseURL == "" {
 				if configExists {
-					slog.Warn("Skipping Azure provider due to missing API endpoint", "provider", p.ID, "error", err)
+					slog.Warn("Skipping Azure provider due to missing API endpoint", "provider", p.ID)
 					c.Providers.Del(string(p.ID))
 				}
 				continue
 			}
-			prepared.BaseURL = endpoint
-			prepared.ExtraParams["apiVersion"] = env.Get("AZURE_OPENAI_API_VERSION")
+			if prepared.Azure == nil || prepared.Azure.APIVersion == "" {
+				prepared.ExtraParams["apiVersion"] = env.Get("AZURE_OPENAI_API_VERSION")
+			}
 		case catwalk.InferenceProviderBedrock:
-			if !hasAWSCredentials(env) {
+			// Credentials may also come from the shared config files or
+			// the instance metadata service, which the AWS SDK resolves
+			// when the provider is configured explicitly.
+			if !hasAWSCredentials(env) && prepared.Bedrock == nil {
 				if configExists {
 					slog.Warn("Skipping Bedrock provider due to missing AWS credentials")
 					c.Providers.Del(string(p.ID))
@@ -193,19 +373,13 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			if prepared.ExtraParams["region"] == "" {
 				prepared.ExtraParams["region"] = env.Get("AWS_DEFAULT_REGION")
 			}
-			for _, model := range p.Models {
-				if !strings.HasPrefix(model.ID, "anthropic.") {
-					return fmt.Errorf("bedrock provider only supports anthropic models for now, found: %s", model.ID)
-				}
-			}
 		default:
-			// Special handling for Ollama provider - auto-configure if available and no API key required
-			if string(p.ID) == "ollama" {
-				slog.Info("Auto-configuring Ollama provider", "models", len(p.Models))
+			// Special handling for local providers - auto-configure if available and no API key required
+			if string(p.ID) == "ollama" || string(p.ID) == llamaCppProviderID {
+				slog.Info("Auto-configuring local provider", "provider", p.ID, "models", len(p.Models))
 			} else {
 				// if the provider api or endpoint are missing we skip them
-				v, err := resolver.ResolveValue(p.APIKey)
-				if v == "" || err != nil {
+				if !hasValue(resolver, p.APIKey) {
 					if configExists {
 						slog.Warn("Skipping provider due to missing API key", "provider", p.ID)
 						c.Providers.Del(string(p.ID))
@@ -242,10 +416,15 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			c.Providers.Del(id)
 			continue
 		}
-		if providerConfig.APIKey == "" {
+		usesEntraID := providerConfig.Type == catwalk.TypeAzure && providerConfig.Azure != nil && providerConfig.Azure.EntraID
+		// Vertex AI authenticates with Google Cloud credentials instead.
+		usesVertexAI := providerConfig.Type == catwalk.TypeVertexAI
+		if providerConfig.APIKey == "" && !usesEntraID && !usesVertexAI {
 			slog.Warn("Provider is missing API key, this might be OK for local providers", "provider", id)
 		}
-		if providerConfig.BaseURL == "" {
+		// Gemini and Vertex AI have default endpoints.
+		hasDefaultEndpoint := providerConfig.Type == catwalk.TypeGemini || usesVertexAI
+		if providerConfig.BaseURL == "" && !hasDefaultEndpoint {
 			slog.Warn("Skipping custom provider due to missing API endpoint", "provider", id)
 			c.Providers.Del(id)
 			continue
@@ -255,25 +434,178 @@ func (c *Config) configureProviders(env env.Env, resolver VariableResolver, know
 			c.Providers.Del(id)
 			continue
 		}
-		if providerConfig.Type != catwalk.TypeOpenAI && providerConfig.Type != catwalk.TypeAnthropic {
+		if !slices.Contains([]catwalk.Type{catwalk.TypeOpenAI, catwalk.TypeAnthropic, catwalk.TypeAzure, catwalk.TypeGemini, catwalk.TypeVertexAI}, providerConfig.Type) {
 			slog.Warn("Skipping custom provider because the provider type is not supported", "provider", id, "type", providerConfig.Type)
 			c.Providers.Del(id)
 			continue
 		}
 
-		apiKey, err := resolver.ResolveValue(providerConfig.APIKey)
-		if apiKey == "" || err != nil {
+		if providerConfig.APIKey != "" && !hasValue(resolver, providerConfig.APIKey) && !usesEntraID && !usesVertexAI {
 			slog.Warn("Provider is missing API key, this might be OK for local providers", "provider", id)
 		}
-		baseURL, err := resolver.ResolveValue(providerConfig.BaseURL)
-		if baseURL == "" || err != nil {
-			slog.Warn("Skipping custom provider due to missing API endpoint", "provider", id, "error", err)
-			c.Providers.Del(id)
-			continue
+		if usesVertexAI {
+			project, location := vertexProjectLocation(providerConfig.VertexAI, env)
+			if project == "" || location == "" {
+				slog.Warn("Skipping custom provider due to missing Vertex AI project or location", "provider", id)
+				c.Providers.Del(id)
+				continue
+			}
+			providerConfig.ExtraParams = maps.Clone(providerConfig.ExtraParams)
+			if providerConfig.ExtraParams == nil {
+				providerConfig.ExtraParams = make(map[string]string)
+			}
+			providerConfig.ExtraParams["project"] = project
+			providerConfig.ExtraParams["location"] = location
 		}
 
 		c.Providers.Set(id, providerConfig)
 	}
+
+	for id, providerConfig := range c.Providers.Seq2() {
+		if len(providerConfig.Pricing) == 0 {
+			continue
+		}
+		for modelID := range providerConfig.Pricing {
+			if modelID != "*" && !slices.ContainsFunc(providerConfig.Models, func(m catwalk.Model) bool { return m.ID == modelID }) {
+				slog.Warn("Pricing set for an unknown model", "provider", id, "model", modelID)
+			}
+		}
+		providerConfig.Models = priced(providerConfig.Models, providerConfig.Pricing)
+		c.Providers.Set(id, providerConfig)
+	}
+	return nil
+}
+
+// hasValue reports whether the value resolves to something. The commands
+// and secret references aren't run here, only when the value is used.
+func hasValue(resolver VariableResolver, value string) bool {
+	if isSecretReference(value) || strings.Contains(value, "$(") {
+		return true
+	}
+	resolved, err := resolver.ResolveValue(value)
+	return err == nil && resolved != ""
+}
+
+// fieldResolver resolves the fields of a config entry. It keeps the first
+// error and stops there, so that the commands of an entry that is skipped
+// don't run.
+type fieldResolver struct {
+	resolver VariableResolver
+	prefix   string
+	err      error
+}
+
+func (r *fieldResolver) resolve(value *string, field string) {
+	if r.err != nil {
+		return
+	}
+	resolved, err := r.resolver.ResolveValue(*value)
+	if err != nil {
+		r.err = fmt.Errorf("%s.%s: %w", r.prefix, field, err)
+		return
+	}
+	*value = resolved
+}
+
+func (r *fieldResolver) resolveAll(values []string, field string) {
+	for i := range values {
+		r.resolve(&values[i], fmt.Sprintf("%s[%d]", field, i))
+	}
+}
+
+// resolveValues expands environment variables and command substitutions in
+// the config values that aren't secrets, so configs can be shared between
+// machines. API keys and headers are resolved when they are used instead.
+// The entries with a value that doesn't resolve are disabled with a warning,
+// only a storage DSN that doesn't resolve is an error.
+func (c *Config) resolveValues(resolver VariableResolver) error {
+	for id, p := range c.Providers.Seq2() {
+		if p.Disable {
+			continue
+		}
+		r := fieldResolver{resolver: resolver, prefix: "providers." + id}
+		r.resolve(&p.BaseURL, "base_url")
+		// Don't modify the slices of the merged config in place.
+		p.Models = slices.Clone(p.Models)
+		for i := range p.Models {
+			r.resolve(&p.Models[i].ID, fmt.Sprintf("models[%d].id", i))
+		}
+		p.EmbeddingModels = slices.Clone(p.EmbeddingModels)
+		for i := range p.EmbeddingModels {
+			r.resolve(&p.EmbeddingModels[i].ID, fmt.Sprintf("embedding_models[%d].id", i))
+		}
+		if p.VertexAI != nil {
+			vertexAI := *p.VertexAI
+			r.resolve(&vertexAI.Project, "vertexai.project")
+			r.resolve(&vertexAI.Location, "vertexai.location")
+			r.resolve(&vertexAI.CredentialsFile, "vertexai.credentials_file")
+			p.VertexAI = &vertexAI
+		}
+		if r.err != nil {
+			slog.Warn("Disabling provider, its config doesn't resolve", "provider", id, "error", r.err)
+			p.Disable = true
+		}
+		c.Providers.Set(id, p)
+	}
+
+	for tp, model := range c.Models {
+		r := fieldResolver{resolver: resolver, prefix: "models." + string(tp)}
+		r.resolve(&model.Model, "model")
+		r.resolve(&model.Provider, "provider")
+		if r.err != nil {
+			slog.Warn("Ignoring selected model, its config doesn't resolve", "type", tp, "error", r.err)
+			delete(c.Models, tp)
+			continue
+		}
+		c.Models[tp] = model
+	}
+
+	for name, m := range c.MCP {
+		if m.Disabled {
+			continue
+		}
+		r := fieldResolver{resolver: resolver, prefix: "mcp." + name}
+		r.resolve(&m.Command, "command")
+		r.resolve(&m.URL, "url")
+		m.Args = slices.Clone(m.Args)
+		r.resolveAll(m.Args, "args")
+		if m.OAuth != nil {
+			oauth := *m.OAuth
+			r.resolve(&oauth.ClientID, "oauth.client_id")
+			r.resolve(&oauth.ClientSecret, "oauth.client_secret")
+			m.OAuth = &oauth
+		}
+		if r.err != nil {
+			slog.Warn("Disabling MCP server, its config doesn't resolve", "name", name, "error", r.err)
+			m.Disabled = true
+		}
+		c.MCP[name] = m
+	}
+
+	if c.Options != nil && c.Options.Storage != nil {
+		storage := *c.Options.Storage
+		r := fieldResolver{resolver: resolver, prefix: "options.storage"}
+		r.resolve(&storage.DSN, "dsn")
+		if r.err != nil {
+			return r.err
+		}
+		c.Options.Storage = &storage
+	}
+
+	for name, l := range c.LSP {
+		if l.Disabled {
+			continue
+		}
+		r := fieldResolver{resolver: resolver, prefix: "lsp." + name}
+		r.resolve(&l.Command, "command")
+		l.Args = slices.Clone(l.Args)
+		r.resolveAll(l.Args, "args")
+		if r.err != nil {
+			slog.Warn("Disabling LSP, its config doesn't resolve", "name", name, "error", r.err)
+			l.Disabled = true
+		}
+		c.LSP[name] = l
+	}
 	return nil
 }
 
@@ -291,6 +623,12 @@ func (c *Config) setDefaults(workingDir string) {
 	if c.Options.DataDirectory == "" {
 		c.Options.DataDirectory = filepath.Join(workingDir, defaultDataDirectory)
 	}
+	if c.Options.Storage == nil {
+		c.Options.Storage = &Storage{}
+	}
+	if c.Options.Storage.Backend == "" {
+		c.Options.Storage.Backend = defaultStorageBackend
+	}
 	if c.Providers == nil {
 		c.Providers = csync.NewMap[string, ProviderConfig]()
 	}
@@ -304,10 +642,15 @@ func (c *Config) setDefaults(workingDir string) {
 		c.LSP = make(map[string]LSPConfig)
 	}
 
-	// Add the default context paths if they are not already present
-	c.Options.ContextPaths = append(defaultContextPaths, c.Options.ContextPaths...)
-	slices.Sort(c.Options.ContextPaths)
-	c.Options.ContextPaths = slices.Compact(c.Options.ContextPaths)
+	// Add the default context paths if they are not already present, before
+	// the configured ones so that those take precedence.
+	var contextPaths []string
+	for _, path := range append(slices.Clone(defaultContextPaths), c.Options.ContextPaths...) {
+		if !slices.Contains(contextPaths, path) {
+			contextPaths = append(contextPaths, path)
+		}
+	}
+	c.Options.ContextPaths = contextPaths
 }
 
 func (c *Config) defaultModelSelection(knownProviders []catwalk.Provider) (largeModel SelectedModel, smallModel SelectedModel, err error) {
@@ -320,7 +663,8 @@ func (c *Config) defaultModelSelection(knownProviders []catwalk.Provider) (large
 	// if no provider found that is known use the first provider configured
 	for _, p := range knownProviders {
 		providerConfig, ok := c.Providers.Get(string(p.ID))
-		if !ok || providerConfig.Disable {
+		// Local providers may only have embedding models.
+		if !ok || providerConfig.Disable || len(providerConfig.Models) == 0 {
 			continue
 		}
 		defaultLargeModel := c.GetModel(string(p.ID), p.DefaultLargeModelID)
@@ -350,20 +694,22 @@ func (c *Config) defaultModelSelection(knownProviders []catwalk.Provider) (large
 	}
 
 	enabledProviders := c.EnabledProviders()
-	slices.SortFunc(enabledProviders, func(a, b ProviderConfig) int {
-		return strings.Compare(a.ID, b.ID)
-	})
-
 	if len(enabledProviders) == 0 {
 		err = fmt.Errorf("no providers configured, please configure at least one provider")
 		return
 	}
-
-	providerConfig := enabledProviders[0]
-	if len(providerConfig.Models) == 0 {
-		err = fmt.Errorf("provider %s has no models configured", providerConfig.ID)
+	enabledProviders = slices.DeleteFunc(enabledProviders, func(p ProviderConfig) bool {
+		return len(p.Models) == 0
+	})
+	if len(enabledProviders) == 0 {
+		err = fmt.Errorf("no provider has chat models configured")
 		return
 	}
+	slices.SortFunc(enabledProviders, func(a, b ProviderConfig) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	providerConfig := enabledProviders[0]
 	defaultLargeModel := c.GetModel(providerConfig.ID, providerConfig.Models[0].ID)
 	largeModel = SelectedModel{
 		Provider:  providerConfig.ID,
@@ -412,6 +758,11 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 				large.ReasoningEffort = largeModelSelected.ReasoningEffort
 			}
 			large.Think = largeModelSelected.Think
+			large.ReasoningSummary = largeModelSelected.ReasoningSummary
+			large.API = largeModelSelected.API
+			large.Temperature = largeModelSelected.Temperature
+			large.ThinkingBudget = largeModelSelected.ThinkingBudget
+			large.ToolCalling = largeModelSelected.ToolCalling
 		}
 	}
 	smallModelSelected, smallModelConfigured := c.Models[SelectedModelTypeSmall]
@@ -439,27 +790,114 @@ func (c *Config) configureSelectedModels(knownProviders []catwalk.Provider) erro
 			}
 			small.ReasoningEffort = smallModelSelected.ReasoningEffort
 			small.Think = smallModelSelected.Think
+			small.ReasoningSummary = smallModelSelected.ReasoningSummary
+			small.API = smallModelSelected.API
+			small.Temperature = smallModelSelected.Temperature
+			small.ThinkingBudget = smallModelSelected.ThinkingBudget
+			small.ToolCalling = smallModelSelected.ToolCalling
 		}
 	}
 	c.Models[SelectedModelTypeLarge] = large
 	c.Models[SelectedModelTypeSmall] = small
+
+	// The other model types are the ones requests can be routed to.
+	for modelType, selected := range c.Models {
+		if modelType == SelectedModelTypeLarge || modelType == SelectedModelTypeSmall || modelType == SelectedModelTypeEmbedding {
+			continue
+		}
+		model := c.GetModel(selected.Provider, selected.Model)
+		if model == nil {
+			slog.Warn("Model not found, requests routed to it use the default models", "model_type", modelType, "provider", selected.Provider, "model", selected.Model)
+			continue
+		}
+		if selected.MaxTokens == 0 {
+			selected.MaxTokens = model.DefaultMaxTokens
+			c.Models[modelType] = selected
+		}
+	}
 	return nil
 }
 
+// projectConfigPaths returns the project config files found by walking up
+// from the working directory to the project root, which is the closest
+// directory containing a .git entry. Paths are ordered from the project root
+// to the working directory, so that configs closer to it take precedence.
+// Outside of a repository only the working directory is considered.
+func projectConfigPaths(workingDir string) []string {
+	workingDir = filepath.Clean(workingDir)
+	dirs := []string{workingDir}
+	if root, ok := projectRoot(workingDir); ok {
+		for dir := workingDir; dir != root; {
+			dir = filepath.Dir(dir)
+			dirs = append(dirs, dir)
+		}
+	}
+
+	var paths []string
+	for _, dir := range slices.Backward(dirs) {
+		// Within the same directory .crush.json takes precedence over
+		// crush.json.
+		paths = append(paths, configFiles(dir, appName)...)
+		paths = append(paths, configFiles(dir, "."+appName)...)
+	}
+	return paths
+}
+
+// projectRoot walks up from dir looking for a .git entry, stopping at the
+// home directory.
+func projectRoot(dir string) (string, bool) {
+	home := HomeDir()
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || dir == home {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// withProfile returns the config with the named profile merged over it.
+func (c *Config) withProfile(name string) (*Config, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found, available profiles: %s", name, strings.Join(slices.Sorted(maps.Keys(c.Profiles)), ", "))
+	}
+	base, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	overrides, err := json.Marshal(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal profile %q: %w", name, err)
+	}
+	cfg, err := loadFromReaders([]io.Reader{bytes.NewReader(base), bytes.NewReader(overrides)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply profile %q: %w", name, err)
+	}
+	cfg.profile = name
+	return cfg, nil
+}
+
 func loadFromConfigPaths(configPaths []string) (*Config, error) {
 	var configs []io.Reader
 
 	for _, path := range configPaths {
-		fd, err := os.Open(path)
+		data, err := os.ReadFile(path)
 		if err != nil {
 			if os.IsNotExist(err) {
 				continue
 			}
 			return nil, fmt.Errorf("failed to open config file %s: %w", path, err)
 		}
-		defer fd.Close()
+		data, err = readConfigFile(path, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
 
-		configs = append(configs, fd)
+		configs = append(configs, bytes.NewReader(data))
 	}
 
 	return loadFromReaders(configs)
@@ -478,10 +916,15 @@ func loadFromReaders(readers []io.Reader) (*Config, error) {
 	return LoadReader(merged)
 }
 
-func hasVertexCredentials(env env.Env) bool {
-	hasProject := env.Get("VERTEXAI_PROJECT") != ""
-	hasLocation := env.Get("VERTEXAI_LOCATION") != ""
-	return hasProject && hasLocation
+// vertexProjectLocation returns the Google Cloud project and location to
+// call Vertex AI in, from the provider options or the environment.
+func vertexProjectLocation(opts *VertexAIOptions, env env.Env) (project, location string) {
+	if opts != nil {
+		project, location = opts.Project, opts.Location
+	}
+	project = cmp.Or(project, env.Get("VERTEXAI_PROJECT"))
+	location = cmp.Or(location, env.Get("VERTEXAI_LOCATION"))
+	return project, location
 }
 
 func hasAWSCredentials(env env.Env) bool {
@@ -525,6 +968,11 @@ func globalConfig() string {
 	return filepath.Join(os.Getenv("HOME"), ".config", appName, fmt.Sprintf("%s.json", appName))
 }
 
+// GlobalConfigDir returns the directory of the global config files.
+func GlobalConfigDir() string {
+	return filepath.Dir(globalConfig())
+}
+
 // GlobalConfigData returns the path to the main data directory for the application.
 // this config is used when the app overrides configurations instead of updating the global config.
 func GlobalConfigData() string {
@@ -36,6 +36,200 @@ func TestConfig_LoadFromReaders(t *testing.T) {
 	require.Equal(t, "https://api.openai.com/v2", pc.BaseURL)
 }
 
+func TestNew(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "secret")
+	cfg := New("/tmp/project")
+
+	require.Equal(t, "/tmp/project", cfg.WorkingDir())
+	require.Equal(t, filepath.Join("/tmp/project", defaultDataDirectory), cfg.Options.DataDirectory)
+	require.Contains(t, cfg.Agents, "coder")
+	require.Contains(t, cfg.Agents, "task")
+
+	apiKey, err := cfg.Resolve("$TEST_API_KEY")
+	require.NoError(t, err)
+	require.Equal(t, "secret", apiKey)
+}
+
+func TestConfig_withProfile(t *testing.T) {
+	cfg, err := loadFromReaders([]io.Reader{strings.NewReader(`{
+  "providers": {
+    "azure": {"api_key": "work-key", "base_url": "https://example.openai.azure.com"}
+  },
+  "models": {"large": {"model": "gpt-4o", "provider": "azure"}},
+  "permissions": {"allowed_tools": ["view"]},
+  "profiles": {
+    "local": {
+      "providers": {
+        "azure": {"disable": true},
+        "ollama": {"type": "openai", "base_url": "http://localhost:11434/v1"}
+      },
+      "models": {"large": {"model": "qwen3", "provider": "ollama"}},
+      "permissions": {"allowed_tools": ["bash"]}
+    }
+  }
+}`)})
+	require.NoError(t, err)
+
+	_, err = cfg.withProfile("personal")
+	require.ErrorContains(t, err, `profile "personal" not found, available profiles: local`)
+
+	local, err := cfg.withProfile("local")
+	require.NoError(t, err)
+	require.Equal(t, "local", local.Profile())
+
+	azure, _ := local.Providers.Get("azure")
+	require.True(t, azure.Disable)
+	require.Equal(t, "work-key", azure.APIKey)
+	ollama, ok := local.Providers.Get("ollama")
+	require.True(t, ok)
+	require.Equal(t, "http://localhost:11434/v1", ollama.BaseURL)
+	require.Equal(t, SelectedModel{Model: "qwen3", Provider: "ollama"}, local.Models[SelectedModelTypeLarge])
+	require.Equal(t, []string{"view", "bash"}, local.Permissions.AllowedTools)
+
+	// The original config is left untouched.
+	require.Equal(t, "gpt-4o", cfg.Models[SelectedModelTypeLarge].Model)
+	require.Empty(t, cfg.Profile())
+}
+
+func TestConfig_projectConfigPaths(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", filepath.Dir(root))
+	sub := filepath.Join(root, "pkg", "sub")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	t.Run("outside a repository", func(t *testing.T) {
+		require.Equal(t, append(
+			configFiles(sub, "crush"),
+			configFiles(sub, ".crush")...,
+		), projectConfigPaths(sub))
+	})
+
+	t.Run("walks up to the repository root", func(t *testing.T) {
+		require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0o755))
+		paths := projectConfigPaths(sub)
+		require.Len(t, paths, 6*len(configExtensions))
+		require.Equal(t, filepath.Join(root, "crush.json"), paths[0])
+		require.Equal(t, filepath.Join(sub, ".crush.yml"), paths[len(paths)-1])
+	})
+}
+
+func TestConfig_loadProjectConfigOverGlobal(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0o755))
+	sub := filepath.Join(root, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+
+	global := filepath.Join(t.TempDir(), "crush.json")
+	require.NoError(t, os.WriteFile(global, []byte(`{"providers": {"openai": {"api_key": "personal"}}, "options": {"debug": true}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".crush.json"), []byte(`{"providers": {"openai": {"base_url": "https://proxy.example.com/v1"}}}`), 0o644))
+
+	cfg, err := loadFromConfigPaths(append([]string{global}, projectConfigPaths(sub)...))
+	require.NoError(t, err)
+	pc, ok := cfg.Providers.Get("openai")
+	require.True(t, ok)
+	require.Equal(t, "personal", pc.APIKey)
+	require.Equal(t, "https://proxy.example.com/v1", pc.BaseURL)
+	require.True(t, cfg.Options.Debug)
+}
+
+func TestConfig_loadYAMLAndTOML(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crush.toml"), []byte(`
+[providers.openai]
+api_key = "toml-key"
+base_url = "https://toml.example.com/v1"
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".crush.yaml"), []byte(`
+# Comments are allowed.
+providers:
+  openai:
+    api_key: yaml-key
+    models:
+      - id: gpt-4o
+        context_window: 128000
+`), 0o644))
+
+	cfg, err := loadFromConfigPaths(projectConfigPaths(dir))
+	require.NoError(t, err)
+	pc, ok := cfg.Providers.Get("openai")
+	require.True(t, ok)
+	require.Equal(t, "yaml-key", pc.APIKey)
+	require.Equal(t, "https://toml.example.com/v1", pc.BaseURL)
+	require.Len(t, pc.Models, 1)
+	require.Equal(t, int64(128000), pc.Models[0].ContextWindow)
+}
+
+func TestConfig_resolveValues(t *testing.T) {
+	cfg := &Config{
+		Models: map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {Model: "${LARGE_MODEL:-gpt-4o}", Provider: "local"},
+		},
+		MCP: MCPs{
+			"fs": {Command: "$NODE_BIN", Args: []string{"${MCP_DIR}/server.js"}},
+		},
+		LSP: LSPs{
+			"go": {Command: "gopls"},
+		},
+	}
+	cfg.setDefaults("/tmp")
+	cfg.Providers.Set("local", ProviderConfig{
+		BaseURL: "http://${LLM_HOST}:${LLM_PORT:-8080}/v1",
+		APIKey:  "$LOCAL_API_KEY",
+		Models:  []catwalk.Model{{ID: "$LOCAL_MODEL"}},
+	})
+	cfg.Providers.Set("disabled", ProviderConfig{
+		BaseURL: "$UNSET_VAR",
+		Disable: true,
+	})
+
+	resolver := NewShellVariableResolver(env.NewFromMap(map[string]string{
+		"LLM_HOST":      "gpu-box",
+		"LOCAL_MODEL":   "qwen3",
+		"LOCAL_API_KEY": "secret",
+		"NODE_BIN":      "/usr/bin/node",
+		"MCP_DIR":       "/opt/mcp",
+	}))
+	require.NoError(t, cfg.resolveValues(resolver))
+
+	pc, _ := cfg.Providers.Get("local")
+	require.Equal(t, "http://gpu-box:8080/v1", pc.BaseURL)
+	require.Equal(t, "qwen3", pc.Models[0].ID)
+	// API keys are resolved when used.
+	require.Equal(t, "$LOCAL_API_KEY", pc.APIKey)
+	require.Equal(t, "gpt-4o", cfg.Models[SelectedModelTypeLarge].Model)
+	require.Equal(t, "/usr/bin/node", cfg.MCP["fs"].Command)
+	require.Equal(t, []string{"/opt/mcp/server.js"}, cfg.MCP["fs"].Args)
+	require.Equal(t, "gopls", cfg.LSP["go"].Command)
+
+	// The entries that don't resolve are disabled, the others still load.
+	cfg.LSP["go"] = LSPConfig{Command: "$MISSING_LSP"}
+	cfg.LSP["rust"] = LSPConfig{Command: "rust-analyzer"}
+	cfg.MCP["fs"] = MCPConfig{Command: "$(exit 1)"}
+	cfg.Models[SelectedModelTypeSmall] = SelectedModel{Model: "$MISSING_MODEL", Provider: "local"}
+	cfg.Providers.Set("proxy", ProviderConfig{BaseURL: "$MISSING_PROXY", Models: []catwalk.Model{{ID: "$(exit 1)"}}})
+	require.NoError(t, cfg.resolveValues(resolver))
+	require.True(t, cfg.LSP["go"].Disabled)
+	require.False(t, cfg.LSP["rust"].Disabled)
+	require.True(t, cfg.MCP["fs"].Disabled)
+	require.NotContains(t, cfg.Models, SelectedModelTypeSmall)
+	pc, _ = cfg.Providers.Get("proxy")
+	require.True(t, pc.Disable)
+	require.Equal(t, "$(exit 1)", pc.Models[0].ID, "the resolution stops at the first error")
+
+	cfg.Options.Storage.DSN = "$MISSING_DSN"
+	require.ErrorContains(t, cfg.resolveValues(resolver), "options.storage.dsn")
+}
+
+func TestHasValue(t *testing.T) {
+	resolver := NewShellVariableResolver(env.NewFromMap(map[string]string{"API_KEY": "secret"}))
+	require.True(t, hasValue(resolver, "$API_KEY"))
+	require.False(t, hasValue(resolver, "$MISSING_KEY"))
+	require.False(t, hasValue(resolver, ""))
+	// The commands run when the key is used.
+	require.True(t, hasValue(resolver, "$(exit 1)"))
+	require.True(t, hasValue(resolver, "cmd://exit 1"))
+}
+
 func TestConfig_setDefaults(t *testing.T) {
 	cfg := &Config{}
 
@@ -109,6 +303,7 @@ func TestConfig_configureProvidersWithOverride(t *testing.T) {
 				ID: "another-model",
 			},
 		},
+		PromptCache: &PromptCache{Disable: true},
 	})
 	cfg.setDefaults("/tmp")
 
@@ -126,6 +321,61 @@ func TestConfig_configureProvidersWithOverride(t *testing.T) {
 	require.Equal(t, "https://api.openai.com/v2", pc.BaseURL)
 	require.Len(t, pc.Models, 2)
 	require.Equal(t, "Updated", pc.Models[0].Name)
+	require.Equal(t, &PromptCache{Disable: true}, pc.PromptCache)
+}
+
+func TestConfig_configureProvidersWithPricing(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	knownProviders := []catwalk.Provider{
+		{
+			ID:          "openai",
+			APIKey:      "$OPENAI_API_KEY",
+			APIEndpoint: "https://api.openai.com/v1",
+			Models: []catwalk.Model{
+				{ID: "gpt-4o", CostPer1MIn: 2.5, CostPer1MOut: 10, CostPer1MOutCached: 1.25},
+				{ID: "gpt-4o-mini", CostPer1MIn: 0.15, CostPer1MOut: 0.6},
+			},
+		},
+	}
+
+	cfg := &Config{
+		Providers: csync.NewMap[string, ProviderConfig](),
+	}
+	cfg.Providers.Set("openai", ProviderConfig{
+		Pricing: map[string]Pricing{
+			"gpt-4o": {CostPer1MIn: price(2), CostPer1MOutCached: price(0)},
+		},
+	})
+	cfg.Providers.Set("ollama-box", ProviderConfig{
+		BaseURL: "http://localhost:11434/v1",
+		Models:  []catwalk.Model{{ID: "qwen3:32b"}, {ID: "llama3.3:70b"}},
+		Pricing: map[string]Pricing{
+			"*":            {CostPer1MIn: price(0.01), CostPer1MOut: price(0.01)},
+			"llama3.3:70b": {CostPer1MOut: price(0.03)},
+		},
+	})
+	cfg.setDefaults("/tmp")
+
+	env := env.NewFromMap(map[string]string{
+		"OPENAI_API_KEY": "test-key",
+	})
+	resolver := NewEnvironmentVariableResolver(env)
+	err := cfg.configureProviders(env, resolver, knownProviders)
+	require.NoError(t, err)
+
+	gpt4o := cfg.GetModel("openai", "gpt-4o")
+	require.Equal(t, 2.0, gpt4o.CostPer1MIn)
+	require.Equal(t, 10.0, gpt4o.CostPer1MOut)
+	require.Zero(t, gpt4o.CostPer1MOutCached)
+	require.Equal(t, 0.15, cfg.GetModel("openai", "gpt-4o-mini").CostPer1MIn)
+	require.Equal(t, 2.5, knownProviders[0].Models[0].CostPer1MIn, "the catalog is left untouched")
+
+	qwen := cfg.GetModel("ollama-box", "qwen3:32b")
+	require.Equal(t, 0.01, qwen.CostPer1MIn)
+	require.Equal(t, 0.01, qwen.CostPer1MOut)
+	llama := cfg.GetModel("ollama-box", "llama3.3:70b")
+	require.Equal(t, 0.01, llama.CostPer1MIn)
+	require.Equal(t, 0.03, llama.CostPer1MOut)
 }
 
 func TestConfig_configureProvidersWithNewProvider(t *testing.T) {
@@ -226,14 +476,14 @@ func TestConfig_configureProvidersBedrockWithoutCredentials(t *testing.T) {
 	require.Equal(t, cfg.Providers.Len(), 0)
 }
 
-func TestConfig_configureProvidersBedrockWithoutUnsupportedModel(t *testing.T) {
+func TestConfig_configureProvidersBedrockWithNonAnthropicModel(t *testing.T) {
 	knownProviders := []catwalk.Provider{
 		{
 			ID:          catwalk.InferenceProviderBedrock,
 			APIKey:      "",
 			APIEndpoint: "",
 			Models: []catwalk.Model{{
-				ID: "some-random-model",
+				ID: "amazon.nova-pro-v1:0",
 			}},
 		},
 	}
@@ -246,7 +496,39 @@ func TestConfig_configureProvidersBedrockWithoutUnsupportedModel(t *testing.T) {
 	})
 	resolver := NewEnvironmentVariableResolver(env)
 	err := cfg.configureProviders(env, resolver, knownProviders)
-	require.Error(t, err)
+	require.NoError(t, err)
+	require.Equal(t, cfg.Providers.Len(), 1)
+}
+
+func TestConfig_configureProvidersBedrockWithOptions(t *testing.T) {
+	knownProviders := []catwalk.Provider{
+		{
+			ID:          catwalk.InferenceProviderBedrock,
+			APIKey:      "",
+			APIEndpoint: "",
+			Models: []catwalk.Model{{
+				ID: "anthropic.claude-sonnet-4-20250514-v1:0",
+			}},
+		},
+	}
+
+	// Credentials come from the profile, not the environment.
+	cfg := &Config{
+		Providers: csync.NewMapFrom(map[string]ProviderConfig{
+			"bedrock": {
+				Bedrock: &BedrockOptions{Region: "eu-west-1", Profile: "bedrock"},
+			},
+		}),
+	}
+	cfg.setDefaults("/tmp")
+	env := env.NewFromMap(map[string]string{})
+	resolver := NewEnvironmentVariableResolver(env)
+	err := cfg.configureProviders(env, resolver, knownProviders)
+	require.NoError(t, err)
+
+	bedrockProvider, ok := cfg.Providers.Get("bedrock")
+	require.True(t, ok, "Bedrock provider should be present")
+	require.Equal(t, "bedrock", bedrockProvider.Bedrock.Profile)
 }
 
 func TestConfig_configureProvidersVertexAIWithCredentials(t *testing.T) {
@@ -331,6 +613,40 @@ func TestConfig_configureProvidersVertexAIMissingProject(t *testing.T) {
 	require.Equal(t, cfg.Providers.Len(), 0)
 }
 
+func TestConfig_configureProvidersVertexAIWithOptions(t *testing.T) {
+	knownProviders := []catwalk.Provider{
+		{
+			ID:          catwalk.InferenceProviderVertexAI,
+			APIKey:      "",
+			APIEndpoint: "",
+			Models: []catwalk.Model{{
+				ID: "gemini-2.5-pro",
+			}},
+		},
+	}
+
+	cfg := &Config{
+		Providers: csync.NewMapFrom(map[string]ProviderConfig{
+			"vertexai": {
+				VertexAI: &VertexAIOptions{Project: "my-project", CredentialsFile: "/keys/vertex.json"},
+			},
+		}),
+	}
+	cfg.setDefaults("/tmp")
+	env := env.NewFromMap(map[string]string{
+		"VERTEXAI_LOCATION": "europe-west4",
+	})
+	resolver := NewEnvironmentVariableResolver(env)
+	err := cfg.configureProviders(env, resolver, knownProviders)
+	require.NoError(t, err)
+
+	vertexProvider, ok := cfg.Providers.Get("vertexai")
+	require.True(t, ok, "VertexAI provider should be present")
+	require.Equal(t, "my-project", vertexProvider.ExtraParams["project"])
+	require.Equal(t, "europe-west4", vertexProvider.ExtraParams["location"])
+	require.Equal(t, "/keys/vertex.json", vertexProvider.VertexAI.CredentialsFile)
+}
+
 func TestConfig_configureProvidersSetProviderID(t *testing.T) {
 	knownProviders := []catwalk.Provider{
 		{
@@ -641,6 +957,64 @@ func TestConfig_configureProvidersCustomProviderValidation(t *testing.T) {
 		require.Equal(t, catwalk.TypeAnthropic, customProvider.Type)
 	})
 
+	t.Run("custom gemini provider doesn't need a BaseURL", func(t *testing.T) {
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"custom-gemini": {
+					APIKey: "test-key",
+					Type:   catwalk.TypeGemini,
+					Models: []catwalk.Model{{
+						ID: "gemini-2.5-flash",
+					}},
+				},
+			}),
+		}
+		cfg.setDefaults("/tmp")
+
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, []catwalk.Provider{})
+		require.NoError(t, err)
+
+		customProvider, exists := cfg.Providers.Get("custom-gemini")
+		require.True(t, exists)
+		require.Equal(t, catwalk.TypeGemini, customProvider.Type)
+		require.Empty(t, customProvider.BaseURL)
+	})
+
+	t.Run("custom vertexai provider needs a project and location", func(t *testing.T) {
+		cfg := &Config{
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"vertex-eu": {
+					Type:     catwalk.TypeVertexAI,
+					VertexAI: &VertexAIOptions{Project: "my-project", Location: "europe-west4"},
+					Models: []catwalk.Model{{
+						ID: "gemini-2.5-pro",
+					}},
+				},
+				"vertex-no-location": {
+					Type:     catwalk.TypeVertexAI,
+					VertexAI: &VertexAIOptions{Project: "my-project"},
+					Models: []catwalk.Model{{
+						ID: "gemini-2.5-pro",
+					}},
+				},
+			}),
+		}
+		cfg.setDefaults("/tmp")
+
+		env := env.NewFromMap(map[string]string{})
+		resolver := NewEnvironmentVariableResolver(env)
+		err := cfg.configureProviders(env, resolver, []catwalk.Provider{})
+		require.NoError(t, err)
+
+		require.Equal(t, cfg.Providers.Len(), 1)
+		customProvider, exists := cfg.Providers.Get("vertex-eu")
+		require.True(t, exists)
+		require.Equal(t, "my-project", customProvider.ExtraParams["project"])
+		require.Equal(t, "europe-west4", customProvider.ExtraParams["location"])
+	})
+
 	t.Run("disabled custom provider is removed", func(t *testing.T) {
 		cfg := &Config{
 			Providers: csync.NewMapFrom(map[string]ProviderConfig{
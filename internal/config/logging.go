@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/log"
+)
+
+// Logging configures the logs of Crush: their levels by subsystem, their
+// format, and when they're rotated.
+type Logging struct {
+	Level string `json:"level,omitempty" jsonschema:"description=Level of the logs of the subsystems without their own level,enum=debug,enum=info,enum=warn,enum=error,default=info"`
+	// Levels are the levels of the logs of the subsystems, by subsystem.
+	Levels     map[string]string `json:"levels,omitempty" jsonschema:"description=Levels of the logs by subsystem; provider or tools or lsp or tui"`
+	Format     string            `json:"format,omitempty" jsonschema:"description=Format of the log file,enum=json,enum=text,default=json"`
+	MaxSize    int               `json:"max_size,omitempty" jsonschema:"description=Size in megabytes the log file is rotated at,minimum=1,default=10"`
+	MaxBackups int               `json:"max_backups,omitempty" jsonschema:"description=Number of rotated log files kept; all of them when 0,minimum=0,default=0"`
+	MaxAge     int               `json:"max_age,omitempty" jsonschema:"description=Number of days the rotated log files are kept,minimum=1,default=30"`
+}
+
+func (l *Logging) validate() error {
+	if _, err := parseLevel(l.Level); err != nil {
+		return err
+	}
+	for subsystem, level := range l.Levels {
+		if !slices.Contains(log.Subsystems, subsystem) {
+			return fmt.Errorf("unknown subsystem %q, expected one of %s", subsystem, strings.Join(log.Subsystems, ", "))
+		}
+		if _, err := parseLevel(level); err != nil {
+			return fmt.Errorf("subsystem %q: %w", subsystem, err)
+		}
+	}
+	switch l.Format {
+	case "", "json", "text":
+	default:
+		return fmt.Errorf("unknown format %q, expected json or text", l.Format)
+	}
+	if l.MaxSize < 0 || l.MaxBackups < 0 || l.MaxAge < 0 {
+		return fmt.Errorf("max_size, max_backups, and max_age can't be negative")
+	}
+	return nil
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown level %q, expected debug, info, warn, or error", level)
+}
+
+// logOptions returns the options of the logs. Debug lowers the level of all
+// the subsystems without their own to debug, and DebugLSP the one of lsp.
+func (o *Options) logOptions() log.Options {
+	var opts log.Options
+	l := o.Logging
+	if l == nil {
+		l = &Logging{}
+	}
+	opts.Level, _ = parseLevel(l.Level)
+	if o.Debug {
+		opts.Level = slog.LevelDebug
+	}
+	opts.Levels = make(map[string]slog.Level, len(l.Levels)+1)
+	for subsystem, level := range l.Levels {
+		opts.Levels[subsystem], _ = parseLevel(level)
+	}
+	if _, ok := opts.Levels[log.LSP]; !ok && o.DebugLSP {
+		opts.Levels[log.LSP] = slog.LevelDebug
+	}
+	opts.Text = l.Format == "text"
+	opts.MaxSize = l.MaxSize
+	opts.MaxBackups = l.MaxBackups
+	opts.MaxAge = l.MaxAge
+	return opts
+}
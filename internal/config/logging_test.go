@@ -0,0 +1,48 @@
+package config
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingValidate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, (&Logging{}).validate())
+	require.NoError(t, (&Logging{
+		Level:  "warn",
+		Levels: map[string]string{"provider": "debug", "lsp": "ERROR"},
+		Format: "text",
+	}).validate())
+	require.ErrorContains(t, (&Logging{Level: "verbose"}).validate(), "unknown level")
+	require.ErrorContains(t, (&Logging{Levels: map[string]string{"db": "debug"}}).validate(), "unknown subsystem")
+	require.ErrorContains(t, (&Logging{Levels: map[string]string{"tui": "loud"}}).validate(), `subsystem "tui"`)
+	require.ErrorContains(t, (&Logging{Format: "xml"}).validate(), "unknown format")
+	require.Error(t, (&Logging{MaxSize: -1}).validate())
+}
+
+func TestLogOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := (&Options{}).logOptions()
+	require.Equal(t, slog.LevelInfo, opts.Level)
+	require.False(t, opts.Text)
+
+	opts = (&Options{
+		Debug:    true,
+		DebugLSP: true,
+		Logging: &Logging{
+			Level:   "error",
+			Levels:  map[string]string{"tools": "warn"},
+			Format:  "text",
+			MaxSize: 50,
+		},
+	}).logOptions()
+	require.Equal(t, slog.LevelDebug, opts.Level, "debug lowers the level")
+	require.Equal(t, map[string]slog.Level{log.Tools: slog.LevelWarn, log.LSP: slog.LevelDebug}, opts.Levels)
+	require.True(t, opts.Text)
+	require.Equal(t, 50, opts.MaxSize)
+}
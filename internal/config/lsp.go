@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// AutoLSP configures the language servers started for the languages detected
+// in the working directory.
+type AutoLSP struct {
+	Disabled bool `json:"disabled,omitempty" jsonschema:"description=Don't start the language servers of the detected languages,default=false"`
+	Install  bool `json:"install,omitempty" jsonschema:"description=Install the missing language servers in the data directory of Crush,default=false"`
+}
+
+// KnownLSP is a language server Crush can start, and install, for the
+// language of a project.
+type KnownLSP struct {
+	Language string
+	Command  string
+	Args     []string
+	// Markers are the files at the root of a project in the language.
+	Markers []string
+}
+
+// KnownLSPs are the language servers started for the detected languages, by
+// language.
+var KnownLSPs = []KnownLSP{
+	{Language: "go", Command: "gopls", Markers: []string{"go.mod", "go.work"}},
+	{Language: "rust", Command: "rust-analyzer", Markers: []string{"Cargo.toml"}},
+	{Language: "typescript", Command: "typescript-language-server", Args: []string{"--stdio"}, Markers: []string{"tsconfig.json", "jsconfig.json", "package.json"}},
+	{Language: "python", Command: "pyright-langserver", Args: []string{"--stdio"}, Markers: []string{"pyproject.toml", "setup.py", "requirements.txt", "Pipfile"}},
+}
+
+// LookupKnownLSP returns the known language server of the language.
+func LookupKnownLSP(language string) (KnownLSP, bool) {
+	i := slices.IndexFunc(KnownLSPs, func(k KnownLSP) bool { return k.Language == language })
+	if i < 0 {
+		return KnownLSP{}, false
+	}
+	return KnownLSPs[i], true
+}
+
+// DetectLanguages returns the languages of the known language servers whose
+// markers are in the directory.
+func DetectLanguages(dir string) []string {
+	var languages []string
+	for _, known := range KnownLSPs {
+		if slices.ContainsFunc(known.Markers, func(marker string) bool {
+			_, err := os.Stat(filepath.Join(dir, marker))
+			return err == nil
+		}) {
+			languages = append(languages, known.Language)
+		}
+	}
+	return languages
+}
+
+// addDetectedLSPs adds the known language servers of the languages of the
+// working directory that aren't configured yet. The configured servers named
+// after a known language get its command and arguments unless they set them.
+func (c *Config) addDetectedLSPs() {
+	for name, lsp := range c.LSP {
+		if known, ok := LookupKnownLSP(name); ok && lsp.Command == "" {
+			lsp.Command = known.Command
+			if lsp.Args == nil {
+				lsp.Args = known.Args
+			}
+			c.LSP[name] = lsp
+		}
+	}
+	if c.Options.AutoLSP != nil && c.Options.AutoLSP.Disabled {
+		return
+	}
+	for _, language := range DetectLanguages(c.WorkingDir()) {
+		known, _ := LookupKnownLSP(language)
+		if _, ok := c.LSP[language]; ok {
+			continue
+		}
+		// A server configured under another name may already cover it.
+		if slices.ContainsFunc(slices.Collect(maps.Values(c.LSP)), func(lsp LSPConfig) bool {
+			return filepath.Base(lsp.Command) == known.Command
+		}) {
+			continue
+		}
+		c.LSP[language] = LSPConfig{Command: known.Command, Args: known.Args}
+	}
+}
+
+func (l LSPConfig) validate() error {
+	if l.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_addDetectedLSPs(t *testing.T) {
+	dir := t.TempDir()
+	for _, marker := range []string{"go.mod", "package.json", "pyproject.toml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, marker), nil, 0o644))
+	}
+
+	t.Run("adds the servers of the detected languages", func(t *testing.T) {
+		cfg := &Config{
+			LSP: LSPs{
+				// Already covers go under another name.
+				"gopls": {Command: "/opt/go/bin/gopls"},
+				"python": {InitOptions: map[string]any{"python": map[string]any{
+					"analysis": map[string]any{"typeCheckingMode": "strict"},
+				}}},
+				"rust": {Command: "ra-multiplex", Args: []string{"client"}},
+			},
+		}
+		cfg.setDefaults(dir)
+		cfg.addDetectedLSPs()
+
+		require.Len(t, cfg.LSP, 4)
+		require.NotContains(t, cfg.LSP, "go")
+		require.Equal(t, LSPConfig{Command: "typescript-language-server", Args: []string{"--stdio"}}, cfg.LSP["typescript"])
+		require.Equal(t, "pyright-langserver", cfg.LSP["python"].Command)
+		require.Equal(t, []string{"--stdio"}, cfg.LSP["python"].Args)
+		require.NotNil(t, cfg.LSP["python"].InitOptions)
+		require.Equal(t, LSPConfig{Command: "ra-multiplex", Args: []string{"client"}}, cfg.LSP["rust"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := &Config{Options: &Options{AutoLSP: &AutoLSP{Disabled: true}}}
+		cfg.setDefaults(dir)
+		cfg.addDetectedLSPs()
+		require.Empty(t, cfg.LSP)
+	})
+}
+
+func TestDetectLanguages(t *testing.T) {
+	dir := t.TempDir()
+	require.Empty(t, DetectLanguages(dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Cargo.toml"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.work"), nil, 0o644))
+	require.Equal(t, []string{"go", "rust"}, DetectLanguages(dir))
+}
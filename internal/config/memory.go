@@ -0,0 +1,30 @@
+package config
+
+import "fmt"
+
+// defaultMemoryResults is the number of memories given to the agent each turn
+// by default.
+const defaultMemoryResults = 5
+
+// Memory configures the long-term memory of the project, the facts the agent
+// learned in past sessions, found by keywords or by meaning when an embedding
+// model is available.
+type Memory struct {
+	Enabled    bool `json:"enabled,omitempty" jsonschema:"description=Keep the facts the agent learns in the memory of the project and give it the relevant ones each turn,default=false"`
+	MaxResults int  `json:"max_results,omitempty" jsonschema:"description=Number of memories given to the agent each turn at most,default=5,minimum=1"`
+}
+
+// Results returns the number of memories given each turn at most.
+func (m *Memory) Results() int {
+	if m.MaxResults == 0 {
+		return defaultMemoryResults
+	}
+	return m.MaxResults
+}
+
+func (m *Memory) validate() error {
+	if m.MaxResults < 0 {
+		return fmt.Errorf("max_results must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Middleware wraps the requests to the providers, to log, retry, or sign
+// them, or add headers for the gateway of an organization. Besides the
+// built-in ones, middleware registered in Go with provider.RegisterMiddleware
+// can be used by name.
+type Middleware struct {
+	Name    string         `json:"name" jsonschema:"description=Name of the middleware: log or retry or headers or request_id or hmac or one registered in Go,example=request_id,example=hmac"`
+	Options map[string]any `json:"options,omitempty" jsonschema:"description=Options of the middleware"`
+}
+
+// MiddlewareFor returns the middleware of the requests to the provider, the
+// default ones first and then its own, the first wrapping the others.
+func (c *Config) MiddlewareFor(p ProviderConfig) []Middleware {
+	if c.Options == nil {
+		return p.Middleware
+	}
+	return slices.Concat(c.Options.Middleware, p.Middleware)
+}
+
+func validateMiddleware(middleware []Middleware) error {
+	var errs []error
+	for i, m := range middleware {
+		if m.Name == "" {
+			errs = append(errs, fmt.Errorf("middleware %d has no name", i+1))
+		}
+	}
+	return errors.Join(errs...)
+}
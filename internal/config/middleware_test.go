@@ -0,0 +1,27 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareFor(t *testing.T) {
+	cfg := &Config{Options: &Options{Middleware: []Middleware{{Name: "request_id"}, {Name: "log"}}}}
+	p := ProviderConfig{ID: "gateway", Middleware: []Middleware{{Name: "hmac", Options: map[string]any{"secret": "$GATEWAY_SECRET"}}}}
+
+	names := func(middleware []Middleware) (names []string) {
+		for _, m := range middleware {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+	require.Equal(t, []string{"request_id", "log", "hmac"}, names(cfg.MiddlewareFor(p)))
+	require.Equal(t, []string{"request_id", "log"}, names(cfg.MiddlewareFor(ProviderConfig{ID: "openai"})))
+}
+
+func TestValidateMiddleware(t *testing.T) {
+	require.NoError(t, validateMiddleware(nil))
+	require.NoError(t, validateMiddleware([]Middleware{{Name: "retry"}}))
+	require.EqualError(t, validateMiddleware([]Middleware{{Name: "retry"}, {}}), "middleware 2 has no name")
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// The APIs of providers of the openai type.
+const (
+	OpenAIAPIChat      = "chat"
+	OpenAIAPIResponses = "responses"
+)
+
+type OpenAIOptions struct {
+	// The API the requests are sent to, Chat Completions by default.
+	API string `json:"api,omitempty" jsonschema:"description=API the requests are sent to: chat for Chat Completions or responses for the Responses API,enum=chat,enum=responses,default=chat"`
+}
+
+// GetOpenAIAPI returns the API the requests of the model type are sent to,
+// for models of providers of the openai type.
+func (c *Config) GetOpenAIAPI(modelType SelectedModelType) string {
+	model, ok := c.Models[modelType]
+	if !ok {
+		return OpenAIAPIChat
+	}
+	if model.API != "" {
+		return model.API
+	}
+	if providerCfg, ok := c.Providers.Get(model.Provider); ok && providerCfg.OpenAI != nil && providerCfg.OpenAI.API != "" {
+		return providerCfg.OpenAI.API
+	}
+	return OpenAIAPIChat
+}
+
+func validateOpenAIAPI(api string) error {
+	if api != "" && api != OpenAIAPIChat && api != OpenAIAPIResponses {
+		return fmt.Errorf("unknown api %q, expected %s or %s", api, OpenAIAPIChat, OpenAIAPIResponses)
+	}
+	return nil
+}
+
+func (o *OpenAIOptions) validate() error {
+	return validateOpenAIAPI(o.API)
+}
+
+// validate checks the settings of the model.
+func (m SelectedModel) validate() error {
+	if m.ThinkingBudget < 0 {
+		return fmt.Errorf("thinking_budget %d is negative", m.ThinkingBudget)
+	}
+	if err := validateOpenAIAPI(m.API); err != nil {
+		return err
+	}
+	if m.ReasoningSummary != "" && !slices.Contains([]string{"auto", "concise", "detailed"}, m.ReasoningSummary) {
+		return fmt.Errorf("unknown reasoning_summary %q, expected auto, concise, or detailed", m.ReasoningSummary)
+	}
+	if m.Temperature != nil && (*m.Temperature < 0 || *m.Temperature > 2) {
+		return fmt.Errorf("temperature %v is out of range, expected 0 to 2", *m.Temperature)
+	}
+	if m.ToolCalling != "" && m.ToolCalling != ToolCallingNative && m.ToolCalling != ToolCallingEmulated {
+		return fmt.Errorf("unknown tool_calling %q, expected %s or %s", m.ToolCalling, ToolCallingNative, ToolCallingEmulated)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOpenAIAPI(t *testing.T) {
+	cfg := &Config{
+		Providers: csync.NewMapFrom(map[string]ProviderConfig{
+			"openai":   {ID: "openai", OpenAI: &OpenAIOptions{API: OpenAIAPIResponses}},
+			"deepseek": {ID: "deepseek"},
+		}),
+		Models: map[SelectedModelType]SelectedModel{
+			SelectedModelTypeLarge: {Model: "o4-mini", Provider: "openai"},
+			SelectedModelTypeSmall: {Model: "gpt-4.1-mini", Provider: "openai", API: OpenAIAPIChat},
+			"summarize":            {Model: "deepseek-chat", Provider: "deepseek"},
+		},
+	}
+	require.Equal(t, OpenAIAPIResponses, cfg.GetOpenAIAPI(SelectedModelTypeLarge))
+	require.Equal(t, OpenAIAPIChat, cfg.GetOpenAIAPI(SelectedModelTypeSmall), "the model overrides the provider")
+	require.Equal(t, OpenAIAPIChat, cfg.GetOpenAIAPI("summarize"))
+	require.Equal(t, OpenAIAPIChat, cfg.GetOpenAIAPI("digest"))
+}
+
+func TestSelectedModelValidate(t *testing.T) {
+	temperature := 0.2
+	require.NoError(t, SelectedModel{API: OpenAIAPIResponses, ReasoningSummary: "auto", Temperature: &temperature}.validate())
+	require.EqualError(t, SelectedModel{API: "completions"}.validate(), `unknown api "completions", expected chat or responses`)
+	require.EqualError(t, SelectedModel{ReasoningSummary: "full"}.validate(), `unknown reasoning_summary "full", expected auto, concise, or detailed`)
+	temperature = 3
+	require.EqualError(t, SelectedModel{Temperature: &temperature}.validate(), "temperature 3 is out of range, expected 0 to 2")
+	require.NoError(t, SelectedModel{Think: true, ThinkingBudget: 8000}.validate())
+	require.EqualError(t, SelectedModel{ThinkingBudget: -1}.validate(), "thinking_budget -1 is negative")
+	require.NoError(t, SelectedModel{ToolCalling: ToolCallingEmulated}.validate())
+	require.EqualError(t, SelectedModel{ToolCalling: "json"}.validate(), `unknown tool_calling "json", expected native or emulated`)
+}
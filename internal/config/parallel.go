@@ -0,0 +1,18 @@
+package config
+
+import "fmt"
+
+// ParallelTools configures how the tool calls of a turn run: the calls of
+// the read-only tools, such as view, grep, and the LSP queries, run at once,
+// while the edits and the commands run one after the other in their order.
+type ParallelTools struct {
+	Disabled      bool `json:"disabled,omitempty" jsonschema:"description=Run all the tool calls of a turn one after the other,default=false"`
+	MaxConcurrent int  `json:"max_concurrent,omitempty" jsonschema:"description=Maximum number of tool calls of a session run at once,default=4,minimum=1,example=8"`
+}
+
+func (p *ParallelTools) validate() error {
+	if p.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent must not be negative")
+	}
+	return nil
+}
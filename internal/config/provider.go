@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/csync"
 )
 
 type ProviderClient interface {
@@ -23,14 +24,24 @@ type ProviderClient interface {
 
 var (
 	providerOnce sync.Once
+	providerMu   sync.RWMutex
 	providerList []catwalk.Provider
+
+	// localEmbeddingModels holds the embedding models discovered on local
+	// providers, keyed by provider ID.
+	localEmbeddingModels = csync.NewMap[string, []catwalk.Model]()
 )
 
 // file to cache provider data
 func providerCacheFileData() string {
+	return filepath.Join(GlobalDataDir(), "providers.json")
+}
+
+// GlobalDataDir is the data directory of Crush shared by the projects.
+func GlobalDataDir() string {
 	xdgDataHome := os.Getenv("XDG_DATA_HOME")
 	if xdgDataHome != "" {
-		return filepath.Join(xdgDataHome, appName, "providers.json")
+		return filepath.Join(xdgDataHome, appName)
 	}
 
 	// return the path to the main data directory
@@ -41,10 +52,10 @@ func providerCacheFileData() string {
 		if localAppData == "" {
 			localAppData = filepath.Join(os.Getenv("USERPROFILE"), "AppData", "Local")
 		}
-		return filepath.Join(localAppData, appName, "providers.json")
+		return filepath.Join(localAppData, appName)
 	}
 
-	return filepath.Join(os.Getenv("HOME"), ".local", "share", appName, "providers.json")
+	return filepath.Join(os.Getenv("HOME"), ".local", "share", appName)
 }
 
 func saveProvidersInCache(path string, providers []catwalk.Provider) error {
@@ -79,22 +90,71 @@ func loadProvidersFromCache(path string) ([]catwalk.Provider, error) {
 
 func Providers() ([]catwalk.Provider, error) {
 	catwalkURL := cmp.Or(os.Getenv("CATWALK_URL"), defaultCatwalkURL)
-	client := catwalk.NewWithURL(catwalkURL)
+	client := catwalkClient{baseURL: catwalkURL}
 	path := providerCacheFileData()
 	return loadProvidersOnce(client, path)
 }
 
+// catwalkClient fetches the providers as catwalk.Client does, with the
+// default proxy and TLS settings.
+type catwalkClient struct {
+	baseURL string
+}
+
+func (c catwalkClient) GetProviders() ([]catwalk.Provider, error) {
+	client, err := discoveryClient("", 0)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(c.baseURL + "/providers") //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var providers []catwalk.Provider
+	if err := json.NewDecoder(resp.Body).Decode(&providers); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return providers, nil
+}
+
 func loadProvidersOnce(client ProviderClient, path string) ([]catwalk.Provider, error) {
 	var err error
 	providerOnce.Do(func() {
-		providerList, err = loadProviders(client, path)
+		var list []catwalk.Provider
+		list, err = loadProviders(client, path)
+		providerMu.Lock()
+		providerList = list
+		providerMu.Unlock()
 	})
 	if err != nil {
 		return nil, err
 	}
+	providerMu.RLock()
+	defer providerMu.RUnlock()
 	return providerList, nil
 }
 
+// reloadProviders reads the providers cache again, picking up the providers
+// updated in the background since they were loaded.
+func reloadProviders() error {
+	if _, err := Providers(); err != nil {
+		return err
+	}
+	list, err := loadProvidersFromCache(providerCacheFileData())
+	if err != nil {
+		return err
+	}
+	list = withDynamicProviders(list)
+	providerMu.Lock()
+	providerList = list
+	providerMu.Unlock()
+	return nil
+}
+
 func loadProviders(client ProviderClient, path string) (providerList []catwalk.Provider, err error) {
 	// if cache is not stale, load from it
 	stale, exists := isCacheStale(path)
@@ -106,41 +166,29 @@ func loadProviders(client ProviderClient, path string) (providerList []catwalk.P
 				slog.Info("Updating provider cache in background")
 				updated, uerr := client.GetProviders()
 				if len(updated) > 0 && uerr == nil {
-					// Add dynamic Ollama provider to the updated list
-					if ollamaProvider, ollamaErr := createOllamaProvider(context.Background()); ollamaErr == nil {
-						updated = append(updated, *ollamaProvider)
-					}
 					_ = saveProvidersInCache(path, updated)
 				}
 			}()
-			// Try to add dynamic Ollama provider to cached list
-			if ollamaProvider, ollamaErr := createOllamaProvider(context.Background()); ollamaErr == nil {
-				providerList = append(providerList, *ollamaProvider)
-			}
+			providerList = withDynamicProviders(providerList)
 			return
 		}
 	}
 
 	slog.Info("Getting live provider data")
 	providerList, err = client.GetProviders()
-	
-	// Add dynamic Ollama provider if available
-	if ollamaProvider, ollamaErr := createOllamaProvider(context.Background()); ollamaErr == nil {
-		slog.Info("Adding Ollama provider with models", "model_count", len(ollamaProvider.Models))
-		providerList = append(providerList, *ollamaProvider)
-	} else {
-		slog.Debug("Ollama provider not available", "error", ollamaErr)
-	}
-	
 	if len(providerList) > 0 && err == nil {
+		// Local providers are discovered on every start, so they are never
+		// persisted in the cache.
 		err = saveProvidersInCache(path, providerList)
+		providerList = withDynamicProviders(providerList)
 		return
 	}
 	if !exists {
-		// If no cache exists and external providers failed, but we have Ollama, just use Ollama
-		if ollamaProvider, ollamaErr := createOllamaProvider(context.Background()); ollamaErr == nil {
-			slog.Info("No external providers available, using only Ollama provider")
-			providerList = []catwalk.Provider{*ollamaProvider}
+		// If no cache exists and external providers failed, fall back to
+		// whatever local providers are running.
+		if local := withDynamicProviders(nil); len(local) > 0 {
+			slog.Info("No external providers available, using only local providers")
+			providerList = local
 			err = nil
 			return
 		}
@@ -148,11 +196,29 @@ func loadProviders(client ProviderClient, path string) (providerList []catwalk.P
 		return
 	}
 	providerList, err = loadProvidersFromCache(path)
-	// Try to add dynamic Ollama provider to fallback cached list
-	if ollamaProvider, ollamaErr := createOllamaProvider(context.Background()); ollamaErr == nil {
+	providerList = withDynamicProviders(providerList)
+	return
+}
+
+// withDynamicProviders updates the OpenRouter models with its live catalog
+// and appends the dynamically discovered local providers (Ollama, llama.cpp)
+// that are currently reachable.
+func withDynamicProviders(providerList []catwalk.Provider) []catwalk.Provider {
+	ctx := context.Background()
+	providerList = withOpenRouterModels(ctx, providerList, openRouterCacheFile())
+	if ollamaProvider, err := createOllamaProvider(ctx); err == nil {
+		slog.Info("Adding Ollama provider with models", "model_count", len(ollamaProvider.Models))
 		providerList = append(providerList, *ollamaProvider)
+	} else {
+		slog.Debug("Ollama provider not available", "error", err)
 	}
-	return
+	if llamaCppProvider, err := createLlamaCppProvider(ctx); err == nil {
+		slog.Info("Adding llama.cpp provider with models", "model_count", len(llamaCppProvider.Models))
+		providerList = append(providerList, *llamaCppProvider)
+	} else {
+		slog.Debug("llama.cpp provider not available", "error", err)
+	}
+	return providerList
 }
 
 func isCacheStale(path string) (stale, exists bool) {
@@ -185,8 +251,9 @@ type OllamaTagsResponse struct {
 
 // fetchOllamaModels calls Ollama's /api/tags endpoint to get locally available models
 func fetchOllamaModels(ctx context.Context) ([]catwalk.Model, error) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	client, err := discoveryClient("ollama", 5*time.Second)
+	if err != nil {
+		return nil, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", "http://localhost:11434/api/tags", nil)
@@ -210,14 +277,39 @@ func fetchOllamaModels(ctx context.Context) ([]catwalk.Model, error) {
 	}
 
 	models := make([]catwalk.Model, 0, len(tagsResp.Models))
+	var embeddingModels []catwalk.Model
 	for _, ollamaModel := range tagsResp.Models {
 		catwalkModel := convertOllamaModel(ollamaModel)
+		// Embedding models can't chat, keep them out of the chat models
+		if isOllamaEmbeddingModel(ollamaModel) {
+			embeddingModels = append(embeddingModels, catwalkModel)
+			continue
+		}
 		models = append(models, catwalkModel)
 	}
+	localEmbeddingModels.Set("ollama", embeddingModels)
 
 	return models, nil
 }
 
+// isOllamaEmbeddingModel reports whether the model is an embedding-only
+// model, based on its family and name.
+func isOllamaEmbeddingModel(ollamaModel OllamaModel) bool {
+	families := append([]string{ollamaModel.Details.Family}, ollamaModel.Details.Families...)
+	for _, family := range families {
+		switch strings.ToLower(family) {
+		case "bert", "nomic-bert", "xlm-roberta", "xlmroberta":
+			return true
+		}
+	}
+	return isEmbeddingModelName(ollamaModel.Name)
+}
+
+func isEmbeddingModelName(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "embed") || strings.Contains(name, "minilm")
+}
+
 // convertOllamaModel converts an Ollama model to a catwalk.Model
 func convertOllamaModel(ollamaModel OllamaModel) catwalk.Model {
 	// Extract a more user-friendly display name
@@ -269,7 +361,9 @@ func createOllamaProvider(ctx context.Context) (*catwalk.Provider, error) {
 		return nil, fmt.Errorf("failed to fetch Ollama models: %w", err)
 	}
 
-	if len(models) == 0 {
+	// An installation with only embedding models still computes the
+	// embeddings, the provider is kept for them.
+	if embeddingModels, _ := localEmbeddingModels.Get("ollama"); len(models) == 0 && len(embeddingModels) == 0 {
 		return nil, fmt.Errorf("no models found in local Ollama installation")
 	}
 
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+// defaultCustomContextWindow is the context window of the models of custom
+// providers that don't tell theirs.
+const defaultCustomContextWindow = 128_000
+
+var errModelsNotFound = errors.New("models endpoint not found")
+
+// OpenAIModel represents a model returned by the /models endpoint of an
+// OpenAI-compatible API. Servers such as vLLM add the context window of the
+// model under different names.
+type OpenAIModel struct {
+	ID            string `json:"id"`
+	OwnedBy       string `json:"owned_by"`
+	MaxModelLen   int64  `json:"max_model_len"`
+	ContextLength int64  `json:"context_length"`
+	ContextWindow int64  `json:"context_window"`
+}
+
+// OpenAIModelsResponse represents the response from the /models endpoint of
+// an OpenAI-compatible API.
+type OpenAIModelsResponse struct {
+	Data []OpenAIModel `json:"data"`
+}
+
+// ProbeOpenAIProvider lists the models of an OpenAI-compatible API. The base
+// URL may omit the /v1 suffix, the base URL the models were found at is
+// returned.
+func ProbeOpenAIProvider(ctx context.Context, baseURL, apiKey string) (string, []catwalk.Model, error) {
+	baseURL = strings.TrimSuffix(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return "", nil, fmt.Errorf("base URL is required")
+	}
+
+	models, err := fetchOpenAIModels(ctx, baseURL, apiKey)
+	if errors.Is(err, errModelsNotFound) && !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1"
+		models, err = fetchOpenAIModels(ctx, baseURL, apiKey)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return baseURL, models, nil
+}
+
+// fetchOpenAIModels calls the /models endpoint of an OpenAI-compatible API.
+func fetchOpenAIModels(ctx context.Context, baseURL, apiKey string) ([]catwalk.Model, error) {
+	client, err := discoveryClient("", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errModelsNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("the API key was rejected (status %d)", resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("models endpoint returned status %d", resp.StatusCode)
+	}
+
+	var modelsResp OpenAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode models response: %w", err)
+	}
+
+	models := make([]catwalk.Model, 0, len(modelsResp.Data))
+	for _, openAIModel := range modelsResp.Data {
+		// Embedding models can't chat
+		if isEmbeddingModelName(openAIModel.ID) {
+			continue
+		}
+		contextWindow := cmp.Or(openAIModel.MaxModelLen, openAIModel.ContextLength, openAIModel.ContextWindow, defaultCustomContextWindow)
+		models = append(models, catwalk.Model{
+			ID:               openAIModel.ID,
+			Name:             openAIModel.ID,
+			ContextWindow:    contextWindow,
+			DefaultMaxTokens: contextWindow / 10,
+		})
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no chat models found at %s", baseURL)
+	}
+	return models, nil
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// CustomProviderID returns a provider ID for the provider name.
+func CustomProviderID(name string) string {
+	return strings.Trim(nonIDChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// AddCustomProvider adds the provider to the global config file and to the
+// configured providers. The API key and the base URL may reference
+// environment variables, they're saved as is and resolved for the current
+// session.
+func (c *Config) AddCustomProvider(providerConfig ProviderConfig) error {
+	if providerConfig.ID == "" {
+		return fmt.Errorf("provider ID is required")
+	}
+	if _, exists := c.Providers.Get(providerConfig.ID); exists {
+		return fmt.Errorf("provider %s already exists", providerConfig.ID)
+	}
+	if providerConfig.Type == "" {
+		providerConfig.Type = catwalk.TypeOpenAI
+	}
+	apiKey := providerConfig.APIKey
+	if apiKey != "" && c.resolver != nil {
+		resolved, err := c.resolver.ResolveValue(apiKey)
+		if err != nil {
+			return fmt.Errorf("failed to resolve API key: %w", err)
+		}
+		apiKey = resolved
+	}
+	baseURL := providerConfig.BaseURL
+	if c.resolver != nil {
+		resolved, err := c.resolver.ResolveValue(baseURL)
+		if err != nil {
+			return fmt.Errorf("failed to resolve base URL: %w", err)
+		}
+		baseURL = resolved
+	}
+	if err := c.SetConfigField("providers."+providerConfig.ID, providerConfig); err != nil {
+		return fmt.Errorf("failed to save provider: %w", err)
+	}
+	providerConfig.APIKey = apiKey
+	providerConfig.BaseURL = baseURL
+	if providerConfig.ExtraHeaders == nil {
+		providerConfig.ExtraHeaders = make(map[string]string)
+	}
+	if providerConfig.ExtraParams == nil {
+		providerConfig.ExtraParams = make(map[string]string)
+	}
+	c.Providers.Set(providerConfig.ID, providerConfig)
+	return nil
+}
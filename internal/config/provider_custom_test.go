@@ -0,0 +1,103 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeOpenAIProvider(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"data": [
+			{"id": "qwen3-coder", "max_model_len": 32768},
+			{"id": "llama-3.3-70b"},
+			{"id": "nomic-embed-text"}
+		]}`))
+	}))
+	defer server.Close()
+
+	baseURL, models, err := ProbeOpenAIProvider(t.Context(), server.URL+"/", "secret")
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/v1", baseURL)
+	require.Equal(t, "Bearer secret", authorization)
+	require.Equal(t, []catwalk.Model{
+		{ID: "qwen3-coder", Name: "qwen3-coder", ContextWindow: 32768, DefaultMaxTokens: 3276},
+		{ID: "llama-3.3-70b", Name: "llama-3.3-70b", ContextWindow: 128_000, DefaultMaxTokens: 12_800},
+	}, models)
+}
+
+func TestProbeOpenAIProviderErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unauthorized/models":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/embeddings/models":
+			w.Write([]byte(`{"data": [{"id": "text-embedding-3-small"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, _, err := ProbeOpenAIProvider(t.Context(), server.URL+"/unauthorized", "wrong")
+	require.ErrorContains(t, err, "API key was rejected")
+
+	_, _, err = ProbeOpenAIProvider(t.Context(), server.URL+"/embeddings", "")
+	require.ErrorContains(t, err, "no chat models found")
+
+	_, _, err = ProbeOpenAIProvider(t.Context(), server.URL, "")
+	require.ErrorIs(t, err, errModelsNotFound)
+
+	_, _, err = ProbeOpenAIProvider(t.Context(), " ", "")
+	require.ErrorContains(t, err, "base URL is required")
+}
+
+func TestCustomProviderID(t *testing.T) {
+	require.Equal(t, "my-vllm-server", CustomProviderID("My vLLM Server"))
+	require.Equal(t, "lm-studio", CustomProviderID("  LM Studio! "))
+}
+
+func TestAddCustomProvider(t *testing.T) {
+	dataConfig := filepath.Join(t.TempDir(), "crush.json")
+	cfg := &Config{
+		Providers:     csync.NewMap[string, ProviderConfig](),
+		dataConfigDir: dataConfig,
+		resolver: NewEnvironmentVariableResolver(env.NewFromMap(map[string]string{
+			"LOCAL_API_KEY": "secret",
+		})),
+	}
+
+	providerConfig := ProviderConfig{
+		ID:      "local",
+		Name:    "Local",
+		BaseURL: "http://localhost:8000/v1",
+		APIKey:  "$LOCAL_API_KEY",
+		Models:  []catwalk.Model{{ID: "qwen3-coder", Name: "qwen3-coder"}},
+	}
+	require.NoError(t, cfg.AddCustomProvider(providerConfig))
+
+	added, ok := cfg.Providers.Get("local")
+	require.True(t, ok)
+	require.Equal(t, catwalk.TypeOpenAI, added.Type)
+	require.Equal(t, "secret", added.APIKey)
+
+	data, err := os.ReadFile(dataConfig)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"api_key":"$LOCAL_API_KEY"`)
+	require.Contains(t, string(data), `"base_url":"http://localhost:8000/v1"`)
+
+	require.ErrorContains(t, cfg.AddCustomProvider(providerConfig), "already exists")
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+const (
+	llamaCppProviderID = "llamacpp"
+	defaultLlamaCppURL = "http://localhost:8080"
+)
+
+// LlamaCppModel represents a model returned by llama-server's /v1/models
+// endpoint.
+type LlamaCppModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	OwnedBy string `json:"owned_by"`
+	Created int64  `json:"created"`
+	Meta    *struct {
+		NCtxTrain int64 `json:"n_ctx_train"`
+		NParams   int64 `json:"n_params"`
+		Size      int64 `json:"size"`
+	} `json:"meta"`
+}
+
+// LlamaCppModelsResponse represents the response from llama-server's
+// /v1/models endpoint.
+type LlamaCppModelsResponse struct {
+	Data []LlamaCppModel `json:"data"`
+}
+
+// LlamaCppProps represents the response from llama-server's /props endpoint.
+type LlamaCppProps struct {
+	DefaultGenerationSettings struct {
+		NCtx     int64 `json:"n_ctx"`
+		NPredict int64 `json:"n_predict"`
+	} `json:"default_generation_settings"`
+	TotalSlots   int    `json:"total_slots"`
+	ModelPath    string `json:"model_path"`
+	ChatTemplate string `json:"chat_template"`
+	Modalities   struct {
+		Vision bool `json:"vision"`
+		Audio  bool `json:"audio"`
+	} `json:"modalities"`
+}
+
+// llamaCppURL returns the base URL of the llama.cpp server, without the /v1
+// suffix.
+func llamaCppURL() string {
+	return strings.TrimSuffix(cmp.Or(os.Getenv("LLAMACPP_URL"), defaultLlamaCppURL), "/")
+}
+
+func getLlamaCppJSON(ctx context.Context, url string, v any) error {
+	client, err := discoveryClient(llamaCppProviderID, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to llama.cpp server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("llama.cpp server returned status %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode llama.cpp response: %w", err)
+	}
+	return nil
+}
+
+// fetchLlamaCppModels probes the llama.cpp server's /v1/models and /props
+// endpoints to build the list of loaded models.
+func fetchLlamaCppModels(ctx context.Context, baseURL string) ([]catwalk.Model, error) {
+	var modelsResp LlamaCppModelsResponse
+	if err := getLlamaCppJSON(ctx, baseURL+"/v1/models", &modelsResp); err != nil {
+		return nil, err
+	}
+
+	// /props is only available on llama-server, and may be disabled, so we
+	// fall back to what /v1/models tells us if it fails.
+	var props *LlamaCppProps
+	var p LlamaCppProps
+	if err := getLlamaCppJSON(ctx, baseURL+"/props", &p); err != nil {
+		slog.Debug("llama.cpp /props not available", "error", err)
+	} else {
+		props = &p
+	}
+
+	models := make([]catwalk.Model, 0, len(modelsResp.Data))
+	var embeddingModels []catwalk.Model
+	for _, llamaCppModel := range modelsResp.Data {
+		model := convertLlamaCppModel(llamaCppModel, props)
+		if isEmbeddingModelName(llamaCppModel.ID) {
+			embeddingModels = append(embeddingModels, model)
+			continue
+		}
+		models = append(models, model)
+	}
+	localEmbeddingModels.Set(llamaCppProviderID, embeddingModels)
+	return models, nil
+}
+
+// convertLlamaCppModel converts a llama.cpp model to a catwalk.Model, using
+// the server properties, when available, for the actual context size and
+// capabilities of the loaded model.
+func convertLlamaCppModel(llamaCppModel LlamaCppModel, props *LlamaCppProps) catwalk.Model {
+	displayName := strings.TrimSuffix(filepath.Base(llamaCppModel.ID), ".gguf")
+
+	contextWindow := int64(4096) // Default context window
+	if llamaCppModel.Meta != nil && llamaCppModel.Meta.NCtxTrain > 0 {
+		contextWindow = llamaCppModel.Meta.NCtxTrain
+	}
+
+	var supportsImages, canReason bool
+	if props != nil {
+		// n_ctx is what the server was actually started with, which is what
+		// we can use, regardless of what the model was trained with.
+		if props.DefaultGenerationSettings.NCtx > 0 {
+			contextWindow = props.DefaultGenerationSettings.NCtx
+		}
+		supportsImages = props.Modalities.Vision
+		canReason = strings.Contains(props.ChatTemplate, "<think>") ||
+			strings.Contains(props.ChatTemplate, "enable_thinking") ||
+			strings.Contains(props.ChatTemplate, "reasoning_content")
+		if props.ChatTemplate != "" && !strings.Contains(props.ChatTemplate, "tools") {
+			slog.Warn("llama.cpp chat template does not seem to support tools, start llama-server with --jinja", "model", llamaCppModel.ID)
+		}
+	}
+
+	return catwalk.Model{
+		ID:               llamaCppModel.ID,
+		Name:             displayName,
+		ContextWindow:    contextWindow,
+		DefaultMaxTokens: contextWindow / 4,
+		SupportsImages:   supportsImages,
+		CanReason:        canReason,
+		// Local models have no API costs
+		CostPer1MIn:        0,
+		CostPer1MOut:       0,
+		CostPer1MInCached:  0,
+		CostPer1MOutCached: 0,
+	}
+}
+
+// createLlamaCppProvider creates a dynamic llama.cpp provider with the models
+// loaded by the local llama-server.
+func createLlamaCppProvider(ctx context.Context) (*catwalk.Provider, error) {
+	baseURL := llamaCppURL()
+	models, err := fetchLlamaCppModels(ctx, baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch llama.cpp models: %w", err)
+	}
+
+	// A server with only an embedding model still computes the embeddings,
+	// the provider is kept for them.
+	var defaultModelID string
+	if len(models) > 0 {
+		defaultModelID = models[0].ID
+	} else if embeddingModels, _ := localEmbeddingModels.Get(llamaCppProviderID); len(embeddingModels) == 0 {
+		return nil, fmt.Errorf("no models loaded in llama.cpp server")
+	}
+
+	// llama-server usually serves a single model, so it is both the large
+	// and the small model.
+	return &catwalk.Provider{
+		Name:                "llama.cpp (Local)",
+		ID:                  llamaCppProviderID,
+		APIKey:              "", // llama-server doesn't require an API key by default
+		APIEndpoint:         baseURL + "/v1",
+		Type:                catwalk.TypeOpenAI, // llama-server is OpenAI-compatible
+		DefaultLargeModelID: defaultModelID,
+		DefaultSmallModelID: defaultModelID,
+		Models:              models,
+	}, nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+func newLlamaCppServer(t *testing.T, props string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{
+				"object": "list",
+				"data": [{
+					"id": "/models/qwen3-8b-q4_k_m.gguf",
+					"object": "model",
+					"owned_by": "llamacpp",
+					"meta": {"n_ctx_train": 40960, "n_params": 8000000000}
+				}]
+			}`))
+		case "/props":
+			if props == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(props))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchLlamaCppModels(t *testing.T) {
+	t.Parallel()
+
+	t.Run("with props", func(t *testing.T) {
+		t.Parallel()
+		server := newLlamaCppServer(t, `{
+			"default_generation_settings": {"n_ctx": 16384},
+			"total_slots": 1,
+			"chat_template": "{% if tools %}...{% endif %}<think>",
+			"modalities": {"vision": true, "audio": false}
+		}`)
+
+		models, err := fetchLlamaCppModels(context.Background(), server.URL)
+		require.NoError(t, err)
+		require.Len(t, models, 1)
+
+		model := models[0]
+		require.Equal(t, "/models/qwen3-8b-q4_k_m.gguf", model.ID)
+		require.Equal(t, "qwen3-8b-q4_k_m", model.Name)
+		require.Equal(t, int64(16384), model.ContextWindow)
+		require.Equal(t, int64(4096), model.DefaultMaxTokens)
+		require.True(t, model.SupportsImages)
+		require.True(t, model.CanReason)
+		require.Zero(t, model.CostPer1MIn)
+	})
+
+	t.Run("without props", func(t *testing.T) {
+		t.Parallel()
+		server := newLlamaCppServer(t, "")
+
+		models, err := fetchLlamaCppModels(context.Background(), server.URL)
+		require.NoError(t, err)
+		require.Len(t, models, 1)
+		require.Equal(t, int64(40960), models[0].ContextWindow)
+		require.False(t, models[0].SupportsImages)
+		require.False(t, models[0].CanReason)
+	})
+}
+
+func TestCreateLlamaCppProvider(t *testing.T) {
+	server := newLlamaCppServer(t, "")
+	t.Setenv("LLAMACPP_URL", server.URL+"/")
+
+	provider, err := createLlamaCppProvider(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, llamaCppProviderID, string(provider.ID))
+	require.Equal(t, server.URL+"/v1", provider.APIEndpoint)
+	require.Equal(t, "/models/qwen3-8b-q4_k_m.gguf", provider.DefaultLargeModelID)
+	require.Equal(t, provider.DefaultLargeModelID, provider.DefaultSmallModelID)
+}
+
+func TestCreateLlamaCppProviderEmbeddingOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": "list", "data": [{"id": "/models/nomic-embed-text-v1.5.gguf", "object": "model"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("LLAMACPP_URL", server.URL)
+
+	// The provider is kept for its embedding model, but chats with another.
+	provider, err := createLlamaCppProvider(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, provider.Models)
+	knownProviders := []catwalk.Provider{
+		{
+			ID:                  "openai",
+			APIKey:              "$OPENAI_API_KEY",
+			APIEndpoint:         "https://api.openai.com/v1",
+			DefaultLargeModelID: "test-model",
+			DefaultSmallModelID: "test-model",
+			Models:              []catwalk.Model{{ID: "test-model"}},
+		},
+		*provider,
+	}
+	cfg := &Config{}
+	cfg.setDefaults("/tmp")
+	env := env.NewFromMap(map[string]string{"OPENAI_API_KEY": "test-key"})
+	require.NoError(t, cfg.configureProviders(env, NewEnvironmentVariableResolver(env), knownProviders))
+
+	providerCfg, model := cfg.EmbeddingModel()
+	require.NotNil(t, model)
+	require.Equal(t, llamaCppProviderID, providerCfg.ID)
+	require.Equal(t, "/models/nomic-embed-text-v1.5.gguf", model.ID)
+	large, _, err := cfg.defaultModelSelection(knownProviders)
+	require.NoError(t, err)
+	require.Equal(t, "openai", large.Provider)
+	cfg.Providers.Del("openai")
+	_, _, err = cfg.defaultModelSelection(knownProviders)
+	require.ErrorContains(t, err, "no provider has chat models configured")
+}
+
+func TestCreateLlamaCppProviderUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("LLAMACPP_URL", server.URL)
+
+	_, err := createLlamaCppProvider(context.Background())
+	require.Error(t, err)
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+)
+
+const defaultOpenRouterURL = "https://openrouter.ai/api/v1"
+
+// OpenRouterModel represents a model returned by OpenRouter's /models
+// endpoint. Prices are in dollars per token.
+type OpenRouterModel struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	ContextLength int64  `json:"context_length"`
+	Architecture  struct {
+		InputModalities []string `json:"input_modalities"`
+	} `json:"architecture"`
+	Pricing struct {
+		Prompt          string `json:"prompt"`
+		Completion      string `json:"completion"`
+		InputCacheRead  string `json:"input_cache_read"`
+		InputCacheWrite string `json:"input_cache_write"`
+	} `json:"pricing"`
+	TopProvider struct {
+		ContextLength       int64 `json:"context_length"`
+		MaxCompletionTokens int64 `json:"max_completion_tokens"`
+	} `json:"top_provider"`
+	SupportedParameters []string `json:"supported_parameters"`
+}
+
+// OpenRouterModelsResponse represents the response from OpenRouter's /models
+// endpoint.
+type OpenRouterModelsResponse struct {
+	Data []OpenRouterModel `json:"data"`
+}
+
+// openRouterCacheFile is where the OpenRouter catalog is cached, next to the
+// providers cache.
+func openRouterCacheFile() string {
+	return filepath.Join(filepath.Dir(providerCacheFileData()), "openrouter.json")
+}
+
+// fetchOpenRouterModels calls OpenRouter's /models endpoint to get the
+// current catalog, with the prices and context windows of the models.
+func fetchOpenRouterModels(ctx context.Context, baseURL string) ([]catwalk.Model, error) {
+	client, err := discoveryClient(string(catwalk.InferenceProviderOpenRouter), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(baseURL, "/")+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to OpenRouter: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenRouter API returned status %d", resp.StatusCode)
+	}
+
+	var modelsResp OpenRouterModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenRouter response: %w", err)
+	}
+
+	models := make([]catwalk.Model, 0, len(modelsResp.Data))
+	for _, openRouterModel := range modelsResp.Data {
+		if model, ok := convertOpenRouterModel(openRouterModel); ok {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models with tool support in the OpenRouter catalog")
+	}
+	return models, nil
+}
+
+// convertOpenRouterModel converts an OpenRouter model to a catwalk.Model. It
+// reports false for the models that can't be used by the agent, the ones
+// without tool calls or with variable prices, such as the auto router.
+func convertOpenRouterModel(openRouterModel OpenRouterModel) (catwalk.Model, bool) {
+	if !slices.Contains(openRouterModel.SupportedParameters, "tools") {
+		return catwalk.Model{}, false
+	}
+
+	prices := make([]float64, 4)
+	for i, price := range []string{
+		openRouterModel.Pricing.Prompt,
+		openRouterModel.Pricing.Completion,
+		openRouterModel.Pricing.InputCacheWrite,
+		openRouterModel.Pricing.InputCacheRead,
+	} {
+		if price == "" {
+			continue
+		}
+		perToken, err := strconv.ParseFloat(price, 64)
+		if err != nil || perToken < 0 {
+			return catwalk.Model{}, false
+		}
+		prices[i] = perToken * 1e6
+	}
+
+	contextWindow := openRouterModel.ContextLength
+	if contextWindow == 0 {
+		contextWindow = openRouterModel.TopProvider.ContextLength
+	}
+	defaultMaxTokens := contextWindow / 10
+	if maxTokens := openRouterModel.TopProvider.MaxCompletionTokens; maxTokens > 0 && maxTokens < defaultMaxTokens {
+		defaultMaxTokens = maxTokens
+	}
+
+	return catwalk.Model{
+		ID:               openRouterModel.ID,
+		Name:             openRouterModel.Name,
+		CostPer1MIn:      prices[0],
+		CostPer1MOut:     prices[1],
+		ContextWindow:    contextWindow,
+		DefaultMaxTokens: defaultMaxTokens,
+		// Cache writes and reads, as in the catwalk catalog.
+		CostPer1MInCached:  prices[2],
+		CostPer1MOutCached: prices[3],
+		CanReason:          slices.Contains(openRouterModel.SupportedParameters, "reasoning"),
+		SupportsImages:     slices.Contains(openRouterModel.Architecture.InputModalities, "image"),
+	}, true
+}
+
+// openRouterModels returns the OpenRouter catalog from the cache, fetching it
+// again once a day. A stale cache is used when the catalog can't be fetched.
+func openRouterModels(ctx context.Context, baseURL, cachePath string) ([]catwalk.Model, error) {
+	stale, exists := isCacheStale(cachePath)
+	if !stale {
+		if cached, err := loadProvidersFromCache(cachePath); err == nil && len(cached) == 1 {
+			return cached[0].Models, nil
+		}
+	}
+
+	models, err := fetchOpenRouterModels(ctx, baseURL)
+	if err == nil {
+		cached := []catwalk.Provider{{ID: catwalk.InferenceProviderOpenRouter, Models: models}}
+		if err := saveProvidersInCache(cachePath, cached); err != nil {
+			slog.Warn("Failed to cache the OpenRouter catalog", "error", err)
+		}
+		return models, nil
+	}
+	if exists {
+		if cached, cacheErr := loadProvidersFromCache(cachePath); cacheErr == nil && len(cached) == 1 {
+			slog.Debug("Using stale OpenRouter catalog", "error", err)
+			return cached[0].Models, nil
+		}
+	}
+	return nil, err
+}
+
+// withOpenRouterModels replaces the models of the OpenRouter provider with its
+// live catalog, which is more up to date than the catwalk listing.
+func withOpenRouterModels(ctx context.Context, providerList []catwalk.Provider, cachePath string) []catwalk.Provider {
+	i := slices.IndexFunc(providerList, func(p catwalk.Provider) bool {
+		return p.ID == catwalk.InferenceProviderOpenRouter
+	})
+	if i < 0 {
+		return providerList
+	}
+
+	openRouter := providerList[i]
+	baseURL := openRouter.APIEndpoint
+	if baseURL == "" {
+		baseURL = defaultOpenRouterURL
+	}
+	models, err := openRouterModels(ctx, baseURL, cachePath)
+	if err != nil {
+		slog.Debug("OpenRouter catalog not available, using the known models", "error", err)
+		return providerList
+	}
+
+	hasModel := func(id string) bool {
+		return slices.ContainsFunc(models, func(m catwalk.Model) bool { return m.ID == id })
+	}
+	if !hasModel(openRouter.DefaultLargeModelID) {
+		openRouter.DefaultLargeModelID = models[0].ID
+	}
+	if !hasModel(openRouter.DefaultSmallModelID) {
+		openRouter.DefaultSmallModelID = openRouter.DefaultLargeModelID
+	}
+	openRouter.Models = models
+	slog.Info("Using the OpenRouter catalog", "model_count", len(models))
+
+	// The list may be shared with the cached providers, don't modify it.
+	providerList = slices.Clone(providerList)
+	providerList[i] = openRouter
+	return providerList
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/stretchr/testify/require"
+)
+
+const openRouterCatalog = `{
+	"data": [
+		{
+			"id": "anthropic/claude-sonnet-4",
+			"name": "Anthropic: Claude Sonnet 4",
+			"context_length": 200000,
+			"architecture": {"input_modalities": ["image", "text"]},
+			"pricing": {
+				"prompt": "0.000003",
+				"completion": "0.000015",
+				"input_cache_read": "0.0000003",
+				"input_cache_write": "0.00000375"
+			},
+			"top_provider": {"context_length": 200000, "max_completion_tokens": 64000},
+			"supported_parameters": ["max_tokens", "reasoning", "tools"]
+		},
+		{
+			"id": "qwen/qwen3-coder",
+			"name": "Qwen: Qwen3 Coder",
+			"context_length": 262144,
+			"architecture": {"input_modalities": ["text"]},
+			"pricing": {"prompt": "0.0000002", "completion": "0.0000008"},
+			"top_provider": {"context_length": 262144, "max_completion_tokens": 0},
+			"supported_parameters": ["tools", "temperature"]
+		},
+		{
+			"id": "openrouter/auto",
+			"name": "Auto Router",
+			"context_length": 2000000,
+			"pricing": {"prompt": "-1", "completion": "-1"},
+			"supported_parameters": ["tools"]
+		},
+		{
+			"id": "some/completion-only",
+			"name": "Completion Only",
+			"context_length": 8192,
+			"pricing": {"prompt": "0", "completion": "0"},
+			"supported_parameters": ["temperature"]
+		}
+	]
+}`
+
+func newOpenRouterServer(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/models" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		_, _ = w.Write([]byte(openRouterCatalog))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestFetchOpenRouterModels(t *testing.T) {
+	t.Parallel()
+	server, _ := newOpenRouterServer(t)
+
+	models, err := fetchOpenRouterModels(context.Background(), server.URL+"/api/v1")
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+
+	sonnet := models[0]
+	require.Equal(t, "anthropic/claude-sonnet-4", sonnet.ID)
+	require.Equal(t, "Anthropic: Claude Sonnet 4", sonnet.Name)
+	require.InDelta(t, 3.0, sonnet.CostPer1MIn, 1e-9)
+	require.InDelta(t, 15.0, sonnet.CostPer1MOut, 1e-9)
+	require.InDelta(t, 3.75, sonnet.CostPer1MInCached, 1e-9)
+	require.InDelta(t, 0.3, sonnet.CostPer1MOutCached, 1e-9)
+	require.Equal(t, int64(200000), sonnet.ContextWindow)
+	require.Equal(t, int64(20000), sonnet.DefaultMaxTokens)
+	require.True(t, sonnet.CanReason)
+	require.True(t, sonnet.SupportsImages)
+
+	qwen := models[1]
+	require.Equal(t, int64(262144), qwen.ContextWindow)
+	require.Equal(t, int64(26214), qwen.DefaultMaxTokens)
+	require.Zero(t, qwen.CostPer1MInCached)
+	require.False(t, qwen.CanReason)
+	require.False(t, qwen.SupportsImages)
+}
+
+func TestWithOpenRouterModels(t *testing.T) {
+	t.Parallel()
+
+	known := []catwalk.Provider{
+		{ID: catwalk.InferenceProviderAnthropic, Models: []catwalk.Model{{ID: "claude-sonnet-4"}}},
+		{
+			ID:                  catwalk.InferenceProviderOpenRouter,
+			DefaultLargeModelID: "anthropic/claude-sonnet-4",
+			DefaultSmallModelID: "anthropic/claude-3.5-haiku",
+			Models:              []catwalk.Model{{ID: "anthropic/claude-sonnet-4"}, {ID: "anthropic/claude-3.5-haiku"}},
+		},
+	}
+
+	t.Run("fetches and caches the catalog", func(t *testing.T) {
+		t.Parallel()
+		server, requests := newOpenRouterServer(t)
+		providers := withOpenRouterEndpoint(known, server.URL+"/api/v1")
+		cachePath := filepath.Join(t.TempDir(), "openrouter.json")
+
+		updated := withOpenRouterModels(context.Background(), providers, cachePath)
+		require.Len(t, updated, 2)
+		require.Equal(t, providers[0], updated[0])
+		require.Len(t, updated[1].Models, 2)
+		require.Equal(t, "anthropic/claude-sonnet-4", updated[1].DefaultLargeModelID)
+		// The small model isn't in the catalog anymore.
+		require.Equal(t, "anthropic/claude-sonnet-4", updated[1].DefaultSmallModelID)
+		// The known providers are left untouched.
+		require.Len(t, providers[1].Models, 2)
+		require.Equal(t, "anthropic/claude-3.5-haiku", providers[1].Models[1].ID)
+
+		require.FileExists(t, cachePath)
+		updated = withOpenRouterModels(context.Background(), providers, cachePath)
+		require.Len(t, updated[1].Models, 2)
+		require.Equal(t, 1, *requests)
+	})
+
+	t.Run("keeps the known models when unavailable", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(server.Close)
+		providers := withOpenRouterEndpoint(known, server.URL+"/api/v1")
+
+		updated := withOpenRouterModels(context.Background(), providers, filepath.Join(t.TempDir(), "openrouter.json"))
+		require.Equal(t, providers, updated)
+	})
+}
+
+func withOpenRouterEndpoint(providers []catwalk.Provider, endpoint string) []catwalk.Provider {
+	cloned := make([]catwalk.Provider, len(providers))
+	for i, p := range providers {
+		if p.ID == catwalk.InferenceProviderOpenRouter {
+			p.APIEndpoint = endpoint
+		}
+		cloned[i] = p
+	}
+	return cloned
+}
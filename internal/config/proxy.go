@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyDirect is the proxy URL of providers reached without proxy, even
+// with a default proxy or one in the environment.
+const ProxyDirect = "direct"
+
+// Proxy routes the requests to providers through an HTTP, HTTPS, or SOCKS5
+// proxy.
+type Proxy struct {
+	URL     string   `json:"url,omitempty" jsonschema:"description=URL of the HTTP or HTTPS or SOCKS5 proxy; direct connects without proxy and empty uses the environment,example=http://proxy.example.com:3128,example=socks5://localhost:1080,example=direct"`
+	NoProxy []string `json:"no_proxy,omitempty" jsonschema:"description=Hosts reached without the proxy as in NO_PROXY: domains with their subdomains or IP addresses or CIDR ranges; localhost is always reached directly,example=.internal.example.com,example=10.0.0.0/8"`
+}
+
+// ProxyFor returns the proxy settings of the provider, its own or the
+// default ones, nil if neither is set.
+func (c *Config) ProxyFor(p ProviderConfig) *Proxy {
+	if p.Proxy != nil {
+		return p.Proxy
+	}
+	return c.Options.Proxy
+}
+
+// proxyFunc returns the function choosing the proxy of each request, as
+// http.Transport.Proxy.
+func (p *Proxy) proxyFunc(resolver VariableResolver) (func(*http.Request) (*url.URL, error), error) {
+	if p == nil {
+		return http.ProxyFromEnvironment, nil
+	}
+	if p.URL == ProxyDirect {
+		return nil, nil
+	}
+	proxies := httpproxy.FromEnvironment()
+	if p.URL != "" {
+		raw, err := resolver.ResolveValue(p.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve proxy URL: %w", err)
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", p.URL)
+		}
+		if !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) {
+			return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https, or socks5", u.Scheme)
+		}
+		// Exclusions of the environment are for its proxy.
+		proxies = &httpproxy.Config{HTTPProxy: raw, HTTPSProxy: raw}
+	}
+	if len(p.NoProxy) > 0 {
+		proxies.NoProxy = strings.Join(append([]string{proxies.NoProxy}, p.NoProxy...), ",")
+	}
+	proxyURL := proxies.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyURL(req.URL)
+	}, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyOf(t *testing.T, proxy *Proxy, target string) *url.URL {
+	t.Helper()
+	proxyFunc, err := proxy.proxyFunc(NewShellVariableResolver(env.NewFromMap(map[string]string{"PROXY_HOST": "proxy.example.com"})))
+	require.NoError(t, err)
+	if proxyFunc == nil {
+		return nil
+	}
+	req, err := http.NewRequest("GET", target, nil)
+	require.NoError(t, err)
+	u, err := proxyFunc(req)
+	require.NoError(t, err)
+	return u
+}
+
+func TestProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.example.com:8080")
+	t.Setenv("NO_PROXY", "")
+
+	proxy := &Proxy{URL: "socks5://${PROXY_HOST}:1080", NoProxy: []string{".internal.example.com", "10.0.0.0/8"}}
+	require.Equal(t, "socks5://proxy.example.com:1080", proxyOf(t, proxy, "https://api.openai.com/v1").String())
+	require.Nil(t, proxyOf(t, proxy, "https://llm.internal.example.com/v1"))
+	require.Nil(t, proxyOf(t, proxy, "http://10.1.2.3:8000/v1"))
+	require.Nil(t, proxyOf(t, proxy, "http://localhost:11434/v1"))
+
+	require.Nil(t, proxyOf(t, &Proxy{URL: ProxyDirect}, "https://api.openai.com/v1"))
+
+	// Without URL, the proxy of the environment applies with the exclusions.
+	exclusions := &Proxy{NoProxy: []string{"api.anthropic.com"}}
+	require.Equal(t, "http://env-proxy.example.com:8080", proxyOf(t, exclusions, "https://api.openai.com/v1").String())
+	require.Nil(t, proxyOf(t, exclusions, "https://api.anthropic.com/v1"))
+
+	_, err := (&Proxy{URL: "ftp://proxy.example.com"}).proxyFunc(NewEnvironmentVariableResolver(env.NewFromMap(nil)))
+	require.ErrorContains(t, err, "unsupported proxy scheme")
+}
+
+func TestConfig_ProxyFor(t *testing.T) {
+	defaultProxy := &Proxy{URL: "http://proxy.example.com:3128"}
+	cfg := &Config{Options: &Options{Proxy: defaultProxy}}
+	require.Equal(t, defaultProxy, cfg.ProxyFor(ProviderConfig{ID: "openai"}))
+	direct := &Proxy{URL: ProxyDirect}
+	require.Equal(t, direct, cfg.ProxyFor(ProviderConfig{ID: "ollama", Proxy: direct}))
+}
+
+func TestDiscoveryClient_Proxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.Write([]byte(`{"data": [{"id": "qwen3-coder"}]}`))
+	}))
+	defer proxy.Close()
+
+	setDiscoveryTransports(&Config{
+		Options:   &Options{Proxy: &Proxy{URL: proxy.URL}},
+		Providers: csync.NewMap[string, ProviderConfig](),
+	})
+	t.Cleanup(func() {
+		setDiscoveryTransports(&Config{Options: &Options{}, Providers: csync.NewMap[string, ProviderConfig]()})
+	})
+
+	_, models, err := ProbeOpenAIProvider(t.Context(), "http://models.example.com/v1", "")
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	require.Equal(t, "http://models.example.com/v1/models", requested)
+}
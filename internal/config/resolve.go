@@ -38,7 +38,15 @@ func NewShellVariableResolver(env env.Env) VariableResolver {
 // it will resolve shell-like variable substitution anywhere in the string, including:
 // - $(command) for command substitution
 // - $VAR or ${VAR} for environment variables
+// - ${VAR:-default} for environment variables with a fallback value
+//
+// Values starting with keyring:// or cmd:// are read from the OS keychain or
+// the output of a command instead, see resolveSecret.
 func (r *shellVariableResolver) ResolveValue(value string) (string, error) {
+	if isSecretReference(value) {
+		return resolveSecret(r.shell, value)
+	}
+
 	// Special case: lone $ is an error (backward compatibility)
 	if value == "$" {
 		return "", fmt.Errorf("invalid value format: %s", value)
@@ -108,6 +116,8 @@ func (r *shellVariableResolver) ResolveValue(value string) (string, error) {
 		}
 		var varName string
 		var end int
+		var defaultValue string
+		var hasDefault bool
 
 		if start+1 < len(result) && result[start+1] == '{' {
 			// Handle ${VAR} format
@@ -116,6 +126,7 @@ func (r *shellVariableResolver) ResolveValue(value string) (string, error) {
 				return "", fmt.Errorf("unmatched ${ in value: %s", value)
 			}
 			varName = result[start+2 : start+2+closeIdx]
+			varName, defaultValue, hasDefault = strings.Cut(varName, ":-")
 			end = start + 2 + closeIdx + 1
 		} else {
 			// Handle $VAR format - variable names must start with letter or underscore
@@ -140,7 +151,10 @@ func (r *shellVariableResolver) ResolveValue(value string) (string, error) {
 		}
 
 		envValue := r.env.Get(varName)
-		if envValue == "" {
+		if envValue == "" && hasDefault {
+			envValue = defaultValue
+		}
+		if envValue == "" && !hasDefault {
 			return "", fmt.Errorf("environment variable %q not set", varName)
 		}
 
@@ -7,6 +7,7 @@ import (
 
 	"github.com/charmbracelet/crush/internal/env"
 	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
 )
 
 // mockShell implements the Shell interface for testing
@@ -126,6 +127,17 @@ func TestShellVariableResolver_EnhancedResolveValue(t *testing.T) {
 			envVars:  map[string]string{"TOKEN": "sk-ant-456"},
 			expected: "Bearer sk-ant-456",
 		},
+		{
+			name:     "environment variable with default",
+			value:    "http://${HOST:-localhost}:${PORT:-11434}/v1",
+			envVars:  map[string]string{"PORT": "8080"},
+			expected: "http://localhost:8080/v1",
+		},
+		{
+			name:     "environment variable with empty default",
+			value:    "${SUFFIX:-}",
+			expected: "",
+		},
 		{
 			name:  "mixed command and environment substitution",
 			value: "$USER-$(date +%Y)-$HOST",
@@ -259,6 +271,49 @@ func TestShellVariableResolver_EnhancedResolveValue(t *testing.T) {
 	}
 }
 
+func TestShellVariableResolver_Secrets(t *testing.T) {
+	keyring.MockInit()
+	require.NoError(t, keyring.Set("crush", "openai", "sk-from-keyring"))
+
+	resolver := &shellVariableResolver{
+		shell: &mockShell{execFunc: func(ctx context.Context, command string) (stdout, stderr string, err error) {
+			switch command {
+			case "op read op://dev/openai/credential":
+				return "sk-from-op\n", "", nil
+			case "true":
+				return "", "", nil
+			}
+			return "", "", errors.New("command failed")
+		}},
+		env: env.NewFromMap(nil),
+	}
+
+	tests := []struct {
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{value: "keyring://crush/openai", expected: "sk-from-keyring"},
+		{value: "keyring://crush/anthropic", expectError: true},
+		{value: "keyring://crush", expectError: true},
+		{value: "cmd://op read op://dev/openai/credential", expected: "sk-from-op"},
+		{value: "cmd://false", expectError: true},
+		{value: "cmd://true", expectError: true},
+		{value: "cmd://", expectError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, err := resolver.ResolveValue(tt.value)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
 func TestEnvironmentVariableResolver_ResolveValue(t *testing.T) {
 	tests := []struct {
 		name        string
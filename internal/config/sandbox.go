@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Backends running the commands of the bash tool.
+const (
+	SandboxNone      = "none"
+	SandboxContainer = "container"
+	SandboxLandlock  = "landlock"
+	SandboxSeatbelt  = "seatbelt"
+)
+
+// Sandbox isolates the commands of the bash tool from the host: they may
+// only write in the working directory and a few others, and reach the
+// network if allowed.
+type Sandbox struct {
+	Backend  string   `json:"backend" jsonschema:"required,description=How commands are isolated: in a Docker or Podman container or with Linux landlock and seccomp or with macOS sandbox-exec,enum=none,enum=container,enum=landlock,enum=seatbelt"`
+	Network  bool     `json:"network,omitempty" jsonschema:"description=Let commands open network connections,default=false"`
+	Writable []string `json:"writable,omitempty" jsonschema:"description=Directories commands may write besides the working directory and the temporary directory,example=~/.cache/go-build,example=~/.npm"`
+	Runtime  string   `json:"runtime,omitempty" jsonschema:"description=Container runtime of the container backend; the first one found if empty,enum=docker,enum=podman"`
+	Image    string   `json:"image,omitempty" jsonschema:"description=Image the container backend runs commands in,example=golang:1.24,example=node:22"`
+}
+
+// Enabled reports whether commands are sandboxed.
+func (s *Sandbox) Enabled() bool {
+	return s != nil && s.Backend != "" && s.Backend != SandboxNone
+}
+
+// WritablePaths returns the absolute paths commands may write: the working
+// directory, the temporary directory, and the writable ones.
+func (s *Sandbox) WritablePaths(workingDir string) []string {
+	paths := []string{workingDir, os.TempDir()}
+	for _, path := range s.Writable {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			path = filepath.Join(HomeDir(), rest)
+		} else if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	return paths
+}
+
+func (s *Sandbox) validate() error {
+	switch s.Backend {
+	case SandboxNone, SandboxLandlock, SandboxSeatbelt:
+	case SandboxContainer:
+		if s.Image == "" {
+			return fmt.Errorf("image is required by the container backend")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q, expected one of none, container, landlock, and seatbelt", s.Backend)
+	}
+	if s.Runtime != "" && s.Runtime != "docker" && s.Runtime != "podman" {
+		return fmt.Errorf("unknown runtime %q, expected docker or podman", s.Runtime)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package config
+
+import "github.com/invopop/jsonschema"
+
+// Schema returns the JSON schema of the config file.
+func Schema() *jsonschema.Schema {
+	reflector := jsonschema.Reflector{
+		// Only fields explicitly marked as required are, most fields of e.g.
+		// catwalk.Model can be left out in config files.
+		RequiredFromJSONSchemaTags: true,
+	}
+	schema := reflector.Reflect(&Config{})
+	// Config files may reference the schema for editor support.
+	if def, ok := schema.Definitions["Config"]; ok {
+		def.Properties.Set("$schema", &jsonschema.Schema{
+			Type:        "string",
+			Description: "JSON schema of the config file",
+		})
+	}
+	return schema
+}
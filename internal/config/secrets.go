@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	// keyringPrefix reads a secret from the OS keychain, e.g.
+	// keyring://crush/openai for the "openai" account of the "crush" service.
+	keyringPrefix = "keyring://"
+	// commandPrefix reads a secret from the output of a command, e.g.
+	// cmd://op read op://vault/openai/credential.
+	commandPrefix = "cmd://"
+)
+
+// isSecretReference reports whether value refers to an external secret store.
+func isSecretReference(value string) bool {
+	return strings.HasPrefix(value, keyringPrefix) || strings.HasPrefix(value, commandPrefix)
+}
+
+// resolveSecret reads the secret value refers to, from the OS keychain (macOS
+// Keychain, Windows Credential Manager, or the Secret Service on Linux) or a
+// command such as a password manager CLI.
+func resolveSecret(sh Shell, value string) (string, error) {
+	if ref, ok := strings.CutPrefix(value, keyringPrefix); ok {
+		service, user, ok := strings.Cut(ref, "/")
+		if !ok || service == "" || user == "" {
+			return "", fmt.Errorf("invalid keyring reference %q: expected keyring://<service>/<user>", value)
+		}
+		secret, err := keyring.Get(service, user)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %q from keyring: %w", ref, err)
+		}
+		return secret, nil
+	}
+
+	command := strings.TrimSpace(strings.TrimPrefix(value, commandPrefix))
+	if command == "" {
+		return "", fmt.Errorf("invalid command reference %q: command is empty", value)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	stdout, _, err := sh.Exec(ctx, command)
+	if err != nil {
+		// Don't include the output, it might contain the secret.
+		return "", fmt.Errorf("secret command failed for '%s': %w", command, err)
+	}
+	secret := strings.TrimSpace(stdout)
+	if secret == "" {
+		return "", fmt.Errorf("secret command '%s' printed nothing", command)
+	}
+	return secret, nil
+}
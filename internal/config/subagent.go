@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+// taskTools are the read-only tools of the built-in task agent, and of the
+// sub-agents that don't list their own.
+var taskTools = []string{
+	"codesearch",
+	"definition",
+	"glob",
+	"grep",
+	"ls",
+	"outline",
+	"references",
+	"sourcegraph",
+	"symbols",
+	"view",
+}
+
+// SubAgent is an agent the main agent hands tasks to with the agent tool,
+// each task in a session of its own. Sub-agents can't start sub-agents.
+type SubAgent struct {
+	Description  string            `json:"description" jsonschema:"required,description=What the sub-agent is for; the main agent picks the sub-agent of a task from it,example=Reviews a diff for bugs and missing tests"`
+	Model        SelectedModelType `json:"model,omitempty" jsonschema:"description=Model type of the sub-agent; defaults to the one routed to task,example=small,example=large"`
+	AllowedTools []string          `json:"allowed_tools,omitempty" jsonschema:"description=Tools the sub-agent can use; defaults to the read-only tools of the task agent,example=view,example=grep,example=bash"`
+	Instructions string            `json:"instructions,omitempty" jsonschema:"description=Instructions added to the system prompt of the sub-agent,example=Only report issues you are sure about"`
+}
+
+func (s *SubAgent) validate(name string) error {
+	if name == "coder" || name == "task" {
+		return fmt.Errorf("%q is the name of a built-in agent", name)
+	}
+	if s.Description == "" {
+		return fmt.Errorf("description is required")
+	}
+	if slices.Contains(s.AllowedTools, "agent") {
+		return fmt.Errorf("sub-agents can't use the agent tool")
+	}
+	return nil
+}
+
+// subAgent returns the agent of the sub-agent.
+func (c *Config) subAgent(name string, s SubAgent) Agent {
+	agent := Agent{
+		ID:           name,
+		Name:         name,
+		Description:  s.Description,
+		Model:        s.Model,
+		ContextPaths: c.Options.ContextPaths,
+		AllowedTools: s.AllowedTools,
+		Instructions: s.Instructions,
+		AllowedMCP:   map[string][]string{},
+		AllowedLSP:   []string{},
+	}
+	if agent.Model == "" {
+		agent.Model = c.ModelFor(RequestTask)
+	}
+	if agent.AllowedTools == nil {
+		agent.AllowedTools = taskTools
+	}
+	return agent
+}
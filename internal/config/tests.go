@@ -0,0 +1,27 @@
+package config
+
+import "fmt"
+
+// Test runners of the test tool.
+const (
+	TestRunnerGo     = "go"
+	TestRunnerPytest = "pytest"
+	TestRunnerJest   = "jest"
+)
+
+// Tests configures the test tool, which runs the tests of the project and
+// parses their failures.
+type Tests struct {
+	Disabled bool     `json:"disabled,omitempty" jsonschema:"description=Disable the test tool,default=false"`
+	Runner   string   `json:"runner,omitempty" jsonschema:"description=Test runner of the project; detected from its files without it,enum=go,enum=pytest,enum=jest"`
+	Args     []string `json:"args,omitempty" jsonschema:"description=Extra arguments of the test runner,example=-race,example=--maxWorkers=2"`
+}
+
+func (t *Tests) validate() error {
+	switch t.Runner {
+	case "", TestRunnerGo, TestRunnerPytest, TestRunnerJest:
+		return nil
+	default:
+		return fmt.Errorf("unknown runner %q, expected go, pytest, or jest", t.Runner)
+	}
+}
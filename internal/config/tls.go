@@ -0,0 +1,87 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TLS configures the connections to providers behind gateways with
+// certificates of a private CA, or requiring client certificates.
+type TLS struct {
+	CACert     string `json:"ca_cert,omitempty" jsonschema:"description=Path of a PEM bundle of CA certificates trusted besides the system ones,example=/etc/ssl/certs/internal-ca.pem"`
+	ClientCert string `json:"client_cert,omitempty" jsonschema:"description=Path of the PEM client certificate for mutual TLS,example=~/.config/crush/client.pem"`
+	ClientKey  string `json:"client_key,omitempty" jsonschema:"description=Path of the PEM private key of the client certificate,example=~/.config/crush/client-key.pem"`
+}
+
+// TLSFor returns the TLS settings of the provider, its own or the default
+// ones, nil if neither is set.
+func (c *Config) TLSFor(p ProviderConfig) *TLS {
+	if p.TLS != nil {
+		return p.TLS
+	}
+	return c.Options.TLS
+}
+
+// config returns the TLS config of the transport, nil without settings.
+// The CA bundle is trusted along with the system certificates, so that the
+// provider can be reached through a TLS-intercepting proxy too.
+func (t *TLS) config(resolver VariableResolver) (*tls.Config, error) {
+	if t == nil {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.CACert != "" {
+		path, err := resolvePath(t.CACert, resolver)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates in CA bundle %s", path)
+		}
+		cfg.RootCAs = pool
+	}
+	if (t.ClientCert == "") != (t.ClientKey == "") {
+		return nil, fmt.Errorf("client_cert and client_key must be set together")
+	}
+	if t.ClientCert != "" {
+		certPath, err := resolvePath(t.ClientCert, resolver)
+		if err != nil {
+			return nil, err
+		}
+		keyPath, err := resolvePath(t.ClientKey, resolver)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// resolvePath resolves the variables of the path and expands ~ to the home
+// directory.
+func resolvePath(path string, resolver VariableResolver) (string, error) {
+	resolved, err := resolver.ResolveValue(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if rest, ok := strings.CutPrefix(resolved, "~/"); ok {
+		resolved = filepath.Join(HomeDir(), rest)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key.
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crush"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return cert, certPath, keyPath
+}
+
+func TestDiscoveryClient_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certPath, keyPath := writeClientCert(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"id": "qwen3-coder"}]}`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caPath := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	t.Cleanup(func() {
+		setDiscoveryTransports(&Config{Options: &Options{}, Providers: csync.NewMap[string, ProviderConfig]()})
+	})
+	setDiscovery := func(settings *TLS) {
+		setDiscoveryTransports(&Config{
+			Options: &Options{},
+			Providers: csync.NewMapFrom(map[string]ProviderConfig{
+				"gateway": {ID: "gateway", TLS: settings},
+			}),
+		})
+	}
+
+	setDiscovery(&TLS{CACert: caPath, ClientCert: certPath, ClientKey: keyPath})
+	client, err := discoveryClient("gateway", 5*time.Second)
+	require.NoError(t, err)
+	resp, err := client.Get(server.URL + "/v1/models")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Without the client certificate, the handshake fails.
+	setDiscovery(&TLS{CACert: caPath})
+	client, err = discoveryClient("gateway", 5*time.Second)
+	require.NoError(t, err)
+	_, err = client.Get(server.URL + "/v1/models")
+	require.Error(t, err)
+
+	// Without the CA, the server isn't trusted.
+	client, err = discoveryClient("other", 5*time.Second)
+	require.NoError(t, err)
+	_, err = client.Get(server.URL + "/v1/models")
+	require.ErrorContains(t, err, "certificate")
+}
+
+func TestTLS_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	_, certPath, _ := writeClientCert(t, dir)
+	resolver := NewShellVariableResolver(env.NewFromMap(map[string]string{"CERTS": dir}))
+
+	_, err := (&TLS{ClientCert: certPath}).config(resolver)
+	require.ErrorContains(t, err, "must be set together")
+
+	empty := filepath.Join(dir, "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	_, err = (&TLS{CACert: "${CERTS}/empty.pem"}).config(resolver)
+	require.ErrorContains(t, err, "no PEM certificates")
+
+	cfg, err := (&TLS{ClientCert: "${CERTS}/client.pem", ClientKey: "${CERTS}/client-key.pem"}).config(resolver)
+	require.NoError(t, err)
+	require.Len(t, cfg.Certificates, 1)
+}
+
+func TestConfig_HTTPClientFor(t *testing.T) {
+	cfg := &Config{Options: &Options{}, resolver: NewEnvironmentVariableResolver(env.NewFromMap(nil))}
+	client, err := cfg.HTTPClientFor(ProviderConfig{ID: "openai"}, 0)
+	require.NoError(t, err)
+	require.Nil(t, client)
+
+	_, err = cfg.HTTPClientFor(ProviderConfig{ID: "gateway", TLS: &TLS{CACert: filepath.Join(t.TempDir(), "missing.pem")}}, 0)
+	require.ErrorContains(t, err, "failed to read CA bundle")
+}
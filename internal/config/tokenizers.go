@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// Tokenizers of the models. The OpenAI ones are exact once downloaded, the
+// others approximate the tokenizers of their families.
+const (
+	TokenizerO200k  = "o200k_base"
+	TokenizerCl100k = "cl100k_base"
+	TokenizerClaude = "claude"
+	TokenizerLlama  = "llama"
+)
+
+// Tokenizers configures the tokenizers counting the tokens of the
+// conversations before they're sent, for the context fill and the costs.
+type Tokenizers struct {
+	Download bool `json:"download,omitempty" jsonschema:"description=Download the tokenizers of the OpenAI models in the data directory of Crush to count their tokens exactly,default=false"`
+	// Models pick the tokenizers of the models the built-in rules don't
+	// know, by model ID.
+	Models map[string]string `json:"models,omitempty" jsonschema:"description=Tokenizers of models by model ID; o200k_base or cl100k_base or claude or llama"`
+}
+
+func (t *Tokenizers) validate() error {
+	for model, tokenizer := range t.Models {
+		switch tokenizer {
+		case TokenizerO200k, TokenizerCl100k, TokenizerClaude, TokenizerLlama:
+		default:
+			return fmt.Errorf("unknown tokenizer %q of model %q, expected o200k_base, cl100k_base, claude, or llama", tokenizer, model)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/env"
+)
+
+// transport holds the network settings of the requests to a provider.
+type transport struct {
+	proxy *Proxy
+	tls   *TLS
+}
+
+// HTTPClientFor returns the client of the requests to the provider, going
+// through its proxy and with its TLS settings. It returns nil when neither
+// is set, for the default client of the SDKs to be used.
+func (c *Config) HTTPClientFor(p ProviderConfig, timeout time.Duration) (*http.Client, error) {
+	t := transport{proxy: c.ProxyFor(p), tls: c.TLSFor(p)}
+	if t.proxy == nil && t.tls == nil {
+		return nil, nil
+	}
+	return newHTTPClient(t, c.resolver, timeout)
+}
+
+// newHTTPClient returns a client with the network settings. Without proxy
+// settings, the proxy of the environment is used, as with the default
+// client.
+func newHTTPClient(t transport, resolver VariableResolver, timeout time.Duration) (*http.Client, error) {
+	proxyFunc, err := t.proxy.proxyFunc(resolver)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := t.tls.config(resolver)
+	if err != nil {
+		return nil, err
+	}
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = proxyFunc
+	if tlsConfig != nil {
+		base.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Timeout: timeout, Transport: base}, nil
+}
+
+// discoveryTransports are the network settings of the providers for the
+// requests listing their models, with the default ones under the empty ID.
+// They are set when the config is loaded, before the providers are.
+var discoveryTransports = csync.NewMap[string, transport]()
+
+func setDiscoveryTransports(cfg *Config) {
+	for id := range discoveryTransports.Seq2() {
+		discoveryTransports.Del(id)
+	}
+	discoveryTransports.Set("", transport{proxy: cfg.Options.Proxy, tls: cfg.Options.TLS})
+	for id, p := range cfg.Providers.Seq2() {
+		if p.Proxy != nil || p.TLS != nil {
+			discoveryTransports.Set(id, transport{proxy: cfg.ProxyFor(p), tls: cfg.TLSFor(p)})
+		}
+	}
+}
+
+// discoveryClient returns the client for the discovery requests to the
+// provider, or to a provider being added and to catwalk when the ID is
+// empty.
+func discoveryClient(providerID string, timeout time.Duration) (*http.Client, error) {
+	t, ok := discoveryTransports.Get(providerID)
+	if !ok {
+		t, _ = discoveryTransports.Get("")
+	}
+	return newHTTPClient(t, NewShellVariableResolver(env.New()), timeout)
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/invopop/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError is a problem found in a config file.
+type ValidationError struct {
+	// Path is the location of the offending value, e.g.
+	// providers.openai.models[0].id.
+	Path string
+	// Line is the line of the value in the config file, 0 if unknown.
+	Line    int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", path, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", e.Line, path, e.Message)
+}
+
+// ValidateConfig checks the config file at path with the given contents
+// against the config schema, reporting unknown keys, values of the wrong
+// type, and missing required fields. An error is returned if the file can't
+// be parsed at all.
+func ValidateConfig(path string, data []byte) ([]ValidationError, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeConfig(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := Schema()
+	v := &validator{
+		definitions: schema.Definitions,
+		lines:       keyLines(format, data),
+	}
+	v.validate(schema, value, "")
+	slices.SortStableFunc(v.errs, func(a, b ValidationError) int {
+		return a.Line - b.Line
+	})
+	return v.errs, nil
+}
+
+type validator struct {
+	definitions jsonschema.Definitions
+	lines       map[string]int
+	errs        []ValidationError
+}
+
+func (v *validator) addError(path, format string, args ...any) {
+	v.errs = append(v.errs, ValidationError{
+		Path:    path,
+		Line:    v.line(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// line returns the line of path, or of its closest parent with a known line.
+func (v *validator) line(path string) int {
+	for {
+		if line, ok := v.lines[path]; ok {
+			return line
+		}
+		idx := strings.LastIndexAny(path, ".[")
+		if idx == -1 {
+			return 0
+		}
+		path = path[:idx]
+	}
+}
+
+func (v *validator) resolve(s *jsonschema.Schema) *jsonschema.Schema {
+	for s != nil && s.Ref != "" {
+		s = v.definitions[strings.TrimPrefix(s.Ref, "#/$defs/")]
+	}
+	return s
+}
+
+func (v *validator) validate(s *jsonschema.Schema, value any, path string) {
+	s = v.resolve(s)
+	if s == nil || s == jsonschema.TrueSchema {
+		return
+	}
+	if !matchesType(s.Type, value) {
+		v.addError(path, "expected %s, got %s", s.Type, typeName(value))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool {
+		return fmt.Sprint(e) == fmt.Sprint(value)
+	}) {
+		v.addError(path, "invalid value %q, expected one of %v", fmt.Sprint(value), s.Enum)
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				v.addError(path, "missing required field %q", name)
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(value)) {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			if s.Properties != nil {
+				if prop, ok := s.Properties.Get(key); ok {
+					v.validate(prop, value[key], keyPath)
+					continue
+				}
+			}
+			if s.AdditionalProperties == jsonschema.FalseSchema {
+				if suggestion := suggestKey(key, s); suggestion != "" {
+					v.addError(keyPath, "unknown key %q, did you mean %q?", key, suggestion)
+				} else {
+					v.addError(keyPath, "unknown key %q", key)
+				}
+				continue
+			}
+			v.validate(s.AdditionalProperties, value[key], keyPath)
+		}
+	case []any:
+		for i, item := range value {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func matchesType(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		switch value := value.(type) {
+		case int, int64, uint64:
+			return true
+		case float64:
+			return value == math.Trunc(value)
+		}
+		return false
+	case "number":
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64, float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// suggestKey returns the known key of s closest to key, catching typos such
+// as baseURL instead of base_url.
+func suggestKey(key string, s *jsonschema.Schema) string {
+	if s.Properties == nil {
+		return ""
+	}
+	normalize := func(k string) string {
+		return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(k))
+	}
+	best, bestDistance := "", 3
+	for pair := s.Properties.Oldest(); pair != nil; pair = pair.Next() {
+		if normalize(pair.Key) == normalize(key) {
+			return pair.Key
+		}
+		if d := levenshtein(normalize(pair.Key), normalize(key)); d < bestDistance {
+			best, bestDistance = pair.Key, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// keyLines maps the paths of the keys and values in a config file to the
+// line they're on.
+func keyLines(format Format, data []byte) map[string]int {
+	switch format {
+	case FormatJSON:
+		return jsonKeyLines(data)
+	case FormatYAML:
+		return yamlKeyLines(data)
+	case FormatTOML:
+		return tomlKeyLines(data)
+	}
+	return nil
+}
+
+func jsonKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	lineAt := func(offset int64) int {
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var walk func(path string) error
+	walk = func(path string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if _, ok := lines[path]; !ok {
+			lines[path] = lineAt(dec.InputOffset())
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				tok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := tok.(string)
+				keyPath := key
+				if path != "" {
+					keyPath = path + "." + key
+				}
+				lines[keyPath] = lineAt(dec.InputOffset())
+				if err := walk(keyPath); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		}
+		return nil
+	}
+	_ = walk("")
+	return lines
+}
+
+func yamlKeyLines(data []byte) map[string]int {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	lines := map[string]int{}
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		if _, ok := lines[path]; !ok {
+			lines[path] = node.Line
+		}
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				keyPath := node.Content[i].Value
+				if path != "" {
+					keyPath = path + "." + keyPath
+				}
+				lines[keyPath] = node.Content[i].Line
+				walk(node.Content[i+1], keyPath)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				walk(child, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	walk(&doc, "")
+	return lines
+}
+
+// tomlKeyLines finds the lines of tables and keys in a TOML file. It doesn't
+// fully parse TOML, values in inline tables or multiline arrays are reported
+// at the line of their key.
+func tomlKeyLines(data []byte) map[string]int {
+	lines := map[string]int{}
+	arrayTables := map[string]int{}
+	var table string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		var path string
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			end := strings.LastIndex(line, "]")
+			if end == -1 {
+				continue
+			}
+			name := tomlPath(strings.Trim(line[:end], "[] "))
+			if strings.HasPrefix(line, "[[") {
+				idx := arrayTables[name]
+				arrayTables[name]++
+				name = fmt.Sprintf("%s[%d]", name, idx)
+			}
+			table, path = name, name
+		default:
+			key, _, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			path = tomlPath(key)
+			if table != "" {
+				path = table + "." + path
+			}
+		}
+		if _, ok := lines[path]; !ok {
+			lines[path] = i + 1
+		}
+	}
+	return lines
+}
+
+// tomlPath turns a dotted TOML key into a path, removing quotes.
+func tomlPath(key string) string {
+	var parts []string
+	var part strings.Builder
+	var quote rune
+	for _, r := range strings.TrimSpace(key) {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			part.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '.':
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+		default:
+			part.WriteRune(r)
+		}
+	}
+	parts = append(parts, strings.TrimSpace(part.String()))
+	return strings.Join(parts, ".")
+}
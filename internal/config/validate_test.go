@@ -0,0 +1,107 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path string
+		data string
+		want []string
+	}{
+		{
+			name: "valid",
+			path: "crush.json",
+			data: `{
+  "$schema": "https://charm.land/crush.json",
+  "providers": {
+    "local": {
+      "base_url": "http://localhost:8080/v1",
+      "models": [{ "id": "qwen3", "context_window": 32000 }]
+    }
+  },
+  "models": { "large": { "model": "qwen3", "provider": "local" } }
+}`,
+		},
+		{
+			name: "json",
+			path: "crush.json",
+			data: `{
+  "$schema": "https://charm.land/crush.json",
+  "providers": {
+    "local": {
+      "baseURL": "http://localhost:8080/v1",
+      "models": [{ "id": "qwen3", "context_window": "large" }]
+    }
+  },
+  "mcp": {
+    "fs": { "command": "mcp-fs" }
+  },
+  "options": { "debug": "yes" }
+}`,
+			want: []string{
+				`line 5: providers.local.baseURL: unknown key "baseURL", did you mean "base_url"?`,
+				`line 6: providers.local.models[0].context_window: expected integer, got string`,
+				`line 10: mcp.fs: missing required field "type"`,
+				`line 12: options.debug: expected boolean, got string`,
+			},
+		},
+		{
+			name: "yaml",
+			path: ".crush.yaml",
+			data: `options:
+  tui:
+    compactMode: true
+permissions:
+  allowed_tools: bash
+`,
+			want: []string{
+				`line 3: options.tui.compactMode: unknown key "compactMode", did you mean "compact_mode"?`,
+				`line 5: permissions.allowed_tools: expected array, got string`,
+			},
+		},
+		{
+			name: "toml",
+			path: "crush.toml",
+			data: `[options]
+debug = true
+
+[[providers.local.models]]
+id = "qwen3"
+context_window = "large"
+
+[mcp.fs]
+type = "websocket"
+`,
+			want: []string{
+				`line 6: providers.local.models[0].context_window: expected integer, got string`,
+				`line 9: mcp.fs.type: invalid value "websocket", expected one of [stdio sse http]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			errs, err := ValidateConfig(tt.path, []byte(tt.data))
+			require.NoError(t, err)
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Error())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateConfig_SyntaxError(t *testing.T) {
+	t.Parallel()
+
+	_, err := ValidateConfig("crush.json", []byte(`{"options": `))
+	require.Error(t, err)
+}
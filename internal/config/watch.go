@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long to wait for more changes before reloading, as
+// editors often save a file in several steps.
+const watchDebounce = 300 * time.Millisecond
+
+// Watch calls onChange when one of the config files of workingDir or the
+// providers cache changes, until ctx is done.
+func Watch(ctx context.Context, workingDir string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	// The directories are watched rather than the files, the files may not
+	// exist yet and editors often replace a file instead of writing to it.
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, path := range append(ConfigPaths(workingDir), providerCacheFileData()) {
+		path = filepath.Clean(path)
+		files[path] = true
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			slog.Warn("Failed to watch config directory", "dir", dir, "error", err)
+			continue
+		}
+		dirs[dir] = true
+	}
+
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !files[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+					continue
+				}
+				slog.Debug("Config file changed", "path", event.Name, "op", event.Op)
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDebounce, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("Config watcher error", "error", err)
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+		}
+	}()
+	return nil
+}
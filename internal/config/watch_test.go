@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	workingDir := t.TempDir()
+
+	var changes atomic.Int32
+	require.NoError(t, Watch(t.Context(), workingDir, func() {
+		changes.Add(1)
+	}))
+
+	// Files other than the config are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "main.go"), []byte("package main"), 0o644))
+	time.Sleep(2 * watchDebounce)
+	require.Zero(t, changes.Load())
+
+	// Several writes in a row trigger a single reload.
+	path := filepath.Join(workingDir, "crush.json")
+	for range 3 {
+		require.NoError(t, os.WriteFile(path, []byte(`{"options": {"debug": true}}`), 0o644))
+	}
+	require.Eventually(t, func() bool {
+		return changes.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(2 * watchDebounce)
+	require.EqualValues(t, 1, changes.Load())
+}
@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+// Web search providers.
+const (
+	WebSearchBrave   = "brave"
+	WebSearchSearXNG = "searxng"
+	WebSearchTavily  = "tavily"
+)
+
+// WebSearch configures the websearch tool, only available with it.
+type WebSearch struct {
+	Provider   string `json:"provider" jsonschema:"required,description=Search engine of the websearch tool,enum=brave,enum=searxng,enum=tavily"`
+	APIKey     string `json:"api_key,omitempty" jsonschema:"description=API key of Brave or Tavily,example=$BRAVE_API_KEY,example=$TAVILY_API_KEY"`
+	URL        string `json:"url,omitempty" jsonschema:"description=URL of the SearXNG instance; or of the API of Brave or Tavily if not the public one,example=http://localhost:8888"`
+	MaxResults int    `json:"max_results,omitempty" jsonschema:"description=Results returned by a search unless the model asks for fewer,default=5"`
+}
+
+func (w *WebSearch) validate() error {
+	switch w.Provider {
+	case WebSearchBrave, WebSearchTavily:
+		if w.APIKey == "" {
+			return fmt.Errorf("api_key is required by %s", w.Provider)
+		}
+	case WebSearchSearXNG:
+		if w.URL == "" {
+			return fmt.Errorf("url is required by searxng")
+		}
+	default:
+		return fmt.Errorf("unknown provider %q, expected one of brave, searxng, and tavily", w.Provider)
+	}
+	return nil
+}
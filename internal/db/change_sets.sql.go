@@ -0,0 +1,73 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: change_sets.sql
+
+package db
+
+import (
+	"context"
+)
+
+const createChangeSet = `-- name: CreateChangeSet :one
+INSERT INTO change_sets (
+    id,
+    session_id,
+    files,
+    created_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING id, session_id, files, reverted, created_at
+`
+
+type CreateChangeSetParams struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Files     string `json:"files"`
+}
+
+func (q *Queries) CreateChangeSet(ctx context.Context, arg CreateChangeSetParams) (ChangeSet, error) {
+	row := q.queryRow(ctx, q.createChangeSetStmt, createChangeSet, arg.ID, arg.SessionID, arg.Files)
+	var i ChangeSet
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Files,
+		&i.Reverted,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLatestChangeSet = `-- name: GetLatestChangeSet :one
+SELECT id, session_id, files, reverted, created_at
+FROM change_sets
+WHERE session_id = ? AND reverted = 0
+ORDER BY created_at DESC, rowid DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestChangeSet(ctx context.Context, sessionID string) (ChangeSet, error) {
+	row := q.queryRow(ctx, q.getLatestChangeSetStmt, getLatestChangeSet, sessionID)
+	var i ChangeSet
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Files,
+		&i.Reverted,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const markChangeSetReverted = `-- name: MarkChangeSetReverted :exec
+UPDATE change_sets
+SET reverted = 1
+WHERE id = ?
+`
+
+func (q *Queries) MarkChangeSetReverted(ctx context.Context, id string) error {
+	_, err := q.exec(ctx, q.markChangeSetRevertedStmt, markChangeSetReverted, id)
+	return err
+}
@@ -21,9 +21,13 @@ func Connect(ctx context.Context, dataDir string) (*sql.DB, error) {
 	if err := os.MkdirAll(dataDir, 0o700); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
-	dbPath := filepath.Join(dataDir, "crush.db")
-	// Open the SQLite database
-	db, err := sql.Open("sqlite3", dbPath)
+	return Open(ctx, filepath.Join(dataDir, "crush.db"))
+}
+
+// Open opens the SQLite database of the data source name, such as a file
+// path or an in-memory database, and applies the migrations.
+func Open(ctx context.Context, dataSourceName string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dataSourceName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
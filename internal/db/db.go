@@ -24,15 +24,24 @@ func New(db DBTX) *Queries {
 func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	q := Queries{db: db}
 	var err error
+	if q.createChangeSetStmt, err = db.PrepareContext(ctx, createChangeSet); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateChangeSet: %w", err)
+	}
 	if q.createFileStmt, err = db.PrepareContext(ctx, createFile); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateFile: %w", err)
 	}
 	if q.createMessageStmt, err = db.PrepareContext(ctx, createMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateMessage: %w", err)
 	}
+	if q.createRequestMetricStmt, err = db.PrepareContext(ctx, createRequestMetric); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateRequestMetric: %w", err)
+	}
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createUsageStmt, err = db.PrepareContext(ctx, createUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateUsage: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
@@ -60,6 +69,12 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
+	if q.getSessionUsageStmt, err = db.PrepareContext(ctx, getSessionUsage); err != nil {
+		return nil, fmt.Errorf("error preparing query GetSessionUsage: %w", err)
+	}
+	if q.getUsageSinceStmt, err = db.PrepareContext(ctx, getUsageSince); err != nil {
+		return nil, fmt.Errorf("error preparing query GetUsageSince: %w", err)
+	}
 	if q.listFilesByPathStmt, err = db.PrepareContext(ctx, listFilesByPath); err != nil {
 		return nil, fmt.Errorf("error preparing query ListFilesByPath: %w", err)
 	}
@@ -72,12 +87,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listMessagesBySessionStmt, err = db.PrepareContext(ctx, listMessagesBySession); err != nil {
 		return nil, fmt.Errorf("error preparing query ListMessagesBySession: %w", err)
 	}
+	if q.listModelStatsStmt, err = db.PrepareContext(ctx, listModelStats); err != nil {
+		return nil, fmt.Errorf("error preparing query ListModelStats: %w", err)
+	}
 	if q.listNewFilesStmt, err = db.PrepareContext(ctx, listNewFiles); err != nil {
 		return nil, fmt.Errorf("error preparing query ListNewFiles: %w", err)
 	}
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.listUsageSinceStmt, err = db.PrepareContext(ctx, listUsageSince); err != nil {
+		return nil, fmt.Errorf("error preparing query ListUsageSince: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
@@ -89,6 +110,11 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 
 func (q *Queries) Close() error {
 	var err error
+	if q.createChangeSetStmt != nil {
+		if cerr := q.createChangeSetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createChangeSetStmt: %w", cerr)
+		}
+	}
 	if q.createFileStmt != nil {
 		if cerr := q.createFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createFileStmt: %w", cerr)
@@ -99,11 +125,21 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createMessageStmt: %w", cerr)
 		}
 	}
+	if q.createRequestMetricStmt != nil {
+		if cerr := q.createRequestMetricStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createRequestMetricStmt: %w", cerr)
+		}
+	}
 	if q.createSessionStmt != nil {
 		if cerr := q.createSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createUsageStmt != nil {
+		if cerr := q.createUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createUsageStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
@@ -139,6 +175,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getFileByPathAndSessionStmt: %w", cerr)
 		}
 	}
+	if q.getLatestChangeSetStmt != nil {
+		if cerr := q.getLatestChangeSetStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getLatestChangeSetStmt: %w", cerr)
+		}
+	}
 	if q.getMessageStmt != nil {
 		if cerr := q.getMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getMessageStmt: %w", cerr)
@@ -149,6 +190,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
 		}
 	}
+	if q.getSessionUsageStmt != nil {
+		if cerr := q.getSessionUsageStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getSessionUsageStmt: %w", cerr)
+		}
+	}
+	if q.getUsageSinceStmt != nil {
+		if cerr := q.getUsageSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getUsageSinceStmt: %w", cerr)
+		}
+	}
 	if q.listFilesByPathStmt != nil {
 		if cerr := q.listFilesByPathStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listFilesByPathStmt: %w", cerr)
@@ -169,6 +220,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listMessagesBySessionStmt: %w", cerr)
 		}
 	}
+	if q.listModelStatsStmt != nil {
+		if cerr := q.listModelStatsStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listModelStatsStmt: %w", cerr)
+		}
+	}
 	if q.listNewFilesStmt != nil {
 		if cerr := q.listNewFilesStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing listNewFilesStmt: %w", cerr)
@@ -179,6 +235,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.listUsageSinceStmt != nil {
+		if cerr := q.listUsageSinceStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listUsageSinceStmt: %w", cerr)
+		}
+	}
+	if q.markChangeSetRevertedStmt != nil {
+		if cerr := q.markChangeSetRevertedStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing markChangeSetRevertedStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
@@ -228,9 +294,12 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 type Queries struct {
 	db                          DBTX
 	tx                          *sql.Tx
+	createChangeSetStmt         *sql.Stmt
 	createFileStmt              *sql.Stmt
 	createMessageStmt           *sql.Stmt
+	createRequestMetricStmt     *sql.Stmt
 	createSessionStmt           *sql.Stmt
+	createUsageStmt             *sql.Stmt
 	deleteFileStmt              *sql.Stmt
 	deleteMessageStmt           *sql.Stmt
 	deleteSessionStmt           *sql.Stmt
@@ -238,14 +307,20 @@ type Queries struct {
 	deleteSessionMessagesStmt   *sql.Stmt
 	getFileStmt                 *sql.Stmt
 	getFileByPathAndSessionStmt *sql.Stmt
+	getLatestChangeSetStmt      *sql.Stmt
 	getMessageStmt              *sql.Stmt
 	getSessionByIDStmt          *sql.Stmt
+	getSessionUsageStmt         *sql.Stmt
+	getUsageSinceStmt           *sql.Stmt
 	listFilesByPathStmt         *sql.Stmt
 	listFilesBySessionStmt      *sql.Stmt
 	listLatestSessionFilesStmt  *sql.Stmt
 	listMessagesBySessionStmt   *sql.Stmt
+	listModelStatsStmt          *sql.Stmt
 	listNewFilesStmt            *sql.Stmt
 	listSessionsStmt            *sql.Stmt
+	listUsageSinceStmt          *sql.Stmt
+	markChangeSetRevertedStmt   *sql.Stmt
 	updateMessageStmt           *sql.Stmt
 	updateSessionStmt           *sql.Stmt
 }
@@ -254,9 +329,12 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
 		db:                          tx,
 		tx:                          tx,
+		createChangeSetStmt:         q.createChangeSetStmt,
 		createFileStmt:              q.createFileStmt,
 		createMessageStmt:           q.createMessageStmt,
+		createRequestMetricStmt:     q.createRequestMetricStmt,
 		createSessionStmt:           q.createSessionStmt,
+		createUsageStmt:             q.createUsageStmt,
 		deleteFileStmt:              q.deleteFileStmt,
 		deleteMessageStmt:           q.deleteMessageStmt,
 		deleteSessionStmt:           q.deleteSessionStmt,
@@ -264,14 +342,20 @@ func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 		deleteSessionMessagesStmt:   q.deleteSessionMessagesStmt,
 		getFileStmt:                 q.getFileStmt,
 		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
+		getLatestChangeSetStmt:      q.getLatestChangeSetStmt,
 		getMessageStmt:              q.getMessageStmt,
 		getSessionByIDStmt:          q.getSessionByIDStmt,
+		getSessionUsageStmt:         q.getSessionUsageStmt,
+		getUsageSinceStmt:           q.getUsageSinceStmt,
 		listFilesByPathStmt:         q.listFilesByPathStmt,
 		listFilesBySessionStmt:      q.listFilesBySessionStmt,
 		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
 		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
+		listModelStatsStmt:          q.listModelStatsStmt,
 		listNewFilesStmt:            q.listNewFilesStmt,
 		listSessionsStmt:            q.listSessionsStmt,
+		listUsageSinceStmt:          q.listUsageSinceStmt,
+		markChangeSetRevertedStmt:   q.markChangeSetRevertedStmt,
 		updateMessageStmt:           q.updateMessageStmt,
 		updateSessionStmt:           q.updateSessionStmt,
 	}
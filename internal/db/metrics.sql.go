@@ -0,0 +1,119 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: metrics.sql
+
+package db
+
+import (
+	"context"
+)
+
+const createRequestMetric = `-- name: CreateRequestMetric :one
+INSERT INTO request_metrics (
+    id,
+    session_id,
+    provider,
+    model,
+    first_token_ms,
+    generation_ms,
+    output_tokens,
+    error,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+) RETURNING id, session_id, provider, model, first_token_ms, generation_ms, output_tokens, error, created_at
+`
+
+type CreateRequestMetricParams struct {
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	FirstTokenMs int64  `json:"first_token_ms"`
+	GenerationMs int64  `json:"generation_ms"`
+	OutputTokens int64  `json:"output_tokens"`
+	Error        string `json:"error"`
+}
+
+func (q *Queries) CreateRequestMetric(ctx context.Context, arg CreateRequestMetricParams) (RequestMetric, error) {
+	row := q.queryRow(ctx, q.createRequestMetricStmt, createRequestMetric,
+		arg.ID,
+		arg.SessionID,
+		arg.Provider,
+		arg.Model,
+		arg.FirstTokenMs,
+		arg.GenerationMs,
+		arg.OutputTokens,
+		arg.Error,
+	)
+	var i RequestMetric
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.Provider,
+		&i.Model,
+		&i.FirstTokenMs,
+		&i.GenerationMs,
+		&i.OutputTokens,
+		&i.Error,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listModelStats = `-- name: ListModelStats :many
+SELECT
+    provider,
+    model,
+    COUNT(*) AS requests,
+    CAST(COALESCE(SUM(CASE WHEN error != '' THEN 1 ELSE 0 END), 0) AS INTEGER) AS errors,
+    CAST(COALESCE(AVG(CASE WHEN error = '' AND first_token_ms > 0 THEN first_token_ms END), 0.0) AS REAL) AS avg_first_token_ms,
+    CAST(COALESCE(SUM(CASE WHEN error = '' THEN output_tokens ELSE 0 END), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(CASE WHEN error = '' THEN generation_ms ELSE 0 END), 0) AS INTEGER) AS generation_ms
+FROM request_metrics
+WHERE created_at >= ?
+GROUP BY provider, model
+ORDER BY provider, model
+`
+
+type ListModelStatsRow struct {
+	Provider        string  `json:"provider"`
+	Model           string  `json:"model"`
+	Requests        int64   `json:"requests"`
+	Errors          int64   `json:"errors"`
+	AvgFirstTokenMs float64 `json:"avg_first_token_ms"`
+	OutputTokens    int64   `json:"output_tokens"`
+	GenerationMs    int64   `json:"generation_ms"`
+}
+
+func (q *Queries) ListModelStats(ctx context.Context, createdAt int64) ([]ListModelStatsRow, error) {
+	rows, err := q.query(ctx, q.listModelStatsStmt, listModelStats, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListModelStatsRow{}
+	for rows.Next() {
+		var i ListModelStatsRow
+		if err := rows.Scan(
+			&i.Provider,
+			&i.Model,
+			&i.Requests,
+			&i.Errors,
+			&i.AvgFirstTokenMs,
+			&i.OutputTokens,
+			&i.GenerationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN cache_cost REAL NOT NULL DEFAULT 0.0 CHECK (cache_cost >= 0.0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN cache_cost;
+-- +goose StatementEnd
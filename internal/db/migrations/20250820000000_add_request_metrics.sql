@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS request_metrics (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    model TEXT NOT NULL,
+    first_token_ms INTEGER NOT NULL DEFAULT 0 CHECK (first_token_ms >= 0),  -- 0 if no token was received
+    generation_ms INTEGER NOT NULL DEFAULT 0 CHECK (generation_ms >= 0),  -- From the first token to the end
+    output_tokens INTEGER NOT NULL DEFAULT 0 CHECK (output_tokens >= 0),
+    error TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL  -- Unix timestamp in seconds
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_metrics_created_at ON request_metrics (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_request_metrics_created_at;
+DROP TABLE IF EXISTS request_metrics;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS change_sets (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    files TEXT NOT NULL DEFAULT '[]',  -- JSON array of the changed files and their versions
+    reverted INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_change_sets_session_id ON change_sets (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_change_sets_session_id;
+DROP TABLE IF EXISTS change_sets;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN forked_from_id TEXT;
+ALTER TABLE sessions ADD COLUMN fork_message_id TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN fork_message_id;
+ALTER TABLE sessions DROP COLUMN forked_from_id;
+-- +goose StatementEnd
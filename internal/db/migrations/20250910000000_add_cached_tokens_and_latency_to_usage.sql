@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE usage ADD COLUMN cached_tokens INTEGER NOT NULL DEFAULT 0 CHECK (cached_tokens >= 0);  -- Part of the input tokens read from the prompt cache
+ALTER TABLE usage ADD COLUMN latency_ms INTEGER NOT NULL DEFAULT 0 CHECK (latency_ms >= 0);  -- From the request to the end of the response
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE usage DROP COLUMN latency_ms;
+ALTER TABLE usage DROP COLUMN cached_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN reasoning_cost REAL NOT NULL DEFAULT 0.0 CHECK (reasoning_cost >= 0.0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN reasoning_cost;
+-- +goose StatementEnd
@@ -8,6 +8,14 @@ import (
 	"database/sql"
 )
 
+type ChangeSet struct {
+	ID        string `json:"id"`
+	SessionID string `json:"session_id"`
+	Files     string `json:"files"`
+	Reverted  int64  `json:"reverted"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 type File struct {
 	ID        string `json:"id"`
 	SessionID string `json:"session_id"`
@@ -30,6 +38,18 @@ type Message struct {
 	Provider   sql.NullString `json:"provider"`
 }
 
+type RequestMetric struct {
+	ID           string `json:"id"`
+	SessionID    string `json:"session_id"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	FirstTokenMs int64  `json:"first_token_ms"`
+	GenerationMs int64  `json:"generation_ms"`
+	OutputTokens int64  `json:"output_tokens"`
+	Error        string `json:"error"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
 type Session struct {
 	ID               string         `json:"id"`
 	ParentSessionID  sql.NullString `json:"parent_session_id"`
@@ -41,4 +61,23 @@ type Session struct {
 	UpdatedAt        int64          `json:"updated_at"`
 	CreatedAt        int64          `json:"created_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	CacheCost        float64        `json:"cache_cost"`
+	UserID           string         `json:"user_id"`
+	KeptMessageID    sql.NullString `json:"kept_message_id"`
+	ForkedFromID     sql.NullString `json:"forked_from_id"`
+	ForkMessageID    sql.NullString `json:"fork_message_id"`
+	ReasoningCost    float64        `json:"reasoning_cost"`
+}
+
+type Usage struct {
+	ID           string  `json:"id"`
+	SessionID    string  `json:"session_id"`
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	CreatedAt    int64   `json:"created_at"`
+	CachedTokens int64   `json:"cached_tokens"`
+	LatencyMs    int64   `json:"latency_ms"`
 }
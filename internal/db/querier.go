@@ -9,9 +9,12 @@ import (
 )
 
 type Querier interface {
+	CreateChangeSet(ctx context.Context, arg CreateChangeSetParams) (ChangeSet, error)
 	CreateFile(ctx context.Context, arg CreateFileParams) (File, error)
 	CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error)
+	CreateRequestMetric(ctx context.Context, arg CreateRequestMetricParams) (RequestMetric, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateUsage(ctx context.Context, arg CreateUsageParams) (Usage, error)
 	DeleteFile(ctx context.Context, id string) error
 	DeleteMessage(ctx context.Context, id string) error
 	DeleteSession(ctx context.Context, id string) error
@@ -19,14 +22,20 @@ type Querier interface {
 	DeleteSessionMessages(ctx context.Context, sessionID string) error
 	GetFile(ctx context.Context, id string) (File, error)
 	GetFileByPathAndSession(ctx context.Context, arg GetFileByPathAndSessionParams) (File, error)
+	GetLatestChangeSet(ctx context.Context, sessionID string) (ChangeSet, error)
 	GetMessage(ctx context.Context, id string) (Message, error)
 	GetSessionByID(ctx context.Context, id string) (Session, error)
+	GetSessionUsage(ctx context.Context, sessionID string) (GetSessionUsageRow, error)
+	GetUsageSince(ctx context.Context, createdAt int64) (GetUsageSinceRow, error)
 	ListFilesByPath(ctx context.Context, path string) ([]File, error)
 	ListFilesBySession(ctx context.Context, sessionID string) ([]File, error)
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	ListMessagesBySession(ctx context.Context, sessionID string) ([]Message, error)
+	ListModelStats(ctx context.Context, createdAt int64) ([]ListModelStatsRow, error)
 	ListNewFiles(ctx context.Context) ([]File, error)
 	ListSessions(ctx context.Context) ([]Session, error)
+	ListUsageSince(ctx context.Context, createdAt int64) ([]Usage, error)
+	MarkChangeSetReverted(ctx context.Context, id string) error
 	UpdateMessage(ctx context.Context, arg UpdateMessageParams) error
 	UpdateSession(ctx context.Context, arg UpdateSessionParams) (Session, error)
 }
@@ -20,6 +20,9 @@ INSERT INTO sessions (
     completion_tokens,
     cost,
     summary_message_id,
+    user_id,
+    forked_from_id,
+    fork_message_id,
     updated_at,
     created_at
 ) VALUES (
@@ -31,9 +34,12 @@ INSERT INTO sessions (
     ?,
     ?,
     null,
+    ?,
+    ?,
+    ?,
     strftime('%s', 'now'),
     strftime('%s', 'now')
-) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+) RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, cache_cost, user_id, kept_message_id, forked_from_id, fork_message_id, reasoning_cost
 `
 
 type CreateSessionParams struct {
@@ -44,6 +50,9 @@ type CreateSessionParams struct {
 	PromptTokens     int64          `json:"prompt_tokens"`
 	CompletionTokens int64          `json:"completion_tokens"`
 	Cost             float64        `json:"cost"`
+	UserID           string         `json:"user_id"`
+	ForkedFromID     sql.NullString `json:"forked_from_id"`
+	ForkMessageID    sql.NullString `json:"fork_message_id"`
 }
 
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
@@ -55,6 +64,9 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		arg.PromptTokens,
 		arg.CompletionTokens,
 		arg.Cost,
+		arg.UserID,
+		arg.ForkedFromID,
+		arg.ForkMessageID,
 	)
 	var i Session
 	err := row.Scan(
@@ -68,6 +80,12 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.CacheCost,
+		&i.UserID,
+		&i.KeptMessageID,
+		&i.ForkedFromID,
+		&i.ForkMessageID,
+		&i.ReasoningCost,
 	)
 	return i, err
 }
@@ -83,7 +101,7 @@ func (q *Queries) DeleteSession(ctx context.Context, id string) error {
 }
 
 const getSessionByID = `-- name: GetSessionByID :one
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, cache_cost, user_id, kept_message_id, forked_from_id, fork_message_id, reasoning_cost
 FROM sessions
 WHERE id = ? LIMIT 1
 `
@@ -102,12 +120,18 @@ func (q *Queries) GetSessionByID(ctx context.Context, id string) (Session, error
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.CacheCost,
+		&i.UserID,
+		&i.KeptMessageID,
+		&i.ForkedFromID,
+		&i.ForkMessageID,
+		&i.ReasoningCost,
 	)
 	return i, err
 }
 
 const listSessions = `-- name: ListSessions :many
-SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, cache_cost, user_id, kept_message_id, forked_from_id, fork_message_id, reasoning_cost
 FROM sessions
 WHERE parent_session_id is NULL
 ORDER BY created_at DESC
@@ -133,6 +157,12 @@ func (q *Queries) ListSessions(ctx context.Context) ([]Session, error) {
 			&i.UpdatedAt,
 			&i.CreatedAt,
 			&i.SummaryMessageID,
+			&i.CacheCost,
+			&i.UserID,
+			&i.KeptMessageID,
+			&i.ForkedFromID,
+			&i.ForkMessageID,
+			&i.ReasoningCost,
 		); err != nil {
 			return nil, err
 		}
@@ -154,9 +184,12 @@ SET
     prompt_tokens = ?,
     completion_tokens = ?,
     summary_message_id = ?,
-    cost = ?
+    kept_message_id = ?,
+    cost = ?,
+    cache_cost = ?,
+    reasoning_cost = ?
 WHERE id = ?
-RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id
+RETURNING id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, cache_cost, user_id, kept_message_id, forked_from_id, fork_message_id, reasoning_cost
 `
 
 type UpdateSessionParams struct {
@@ -164,7 +197,10 @@ type UpdateSessionParams struct {
 	PromptTokens     int64          `json:"prompt_tokens"`
 	CompletionTokens int64          `json:"completion_tokens"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
+	KeptMessageID    sql.NullString `json:"kept_message_id"`
 	Cost             float64        `json:"cost"`
+	CacheCost        float64        `json:"cache_cost"`
+	ReasoningCost    float64        `json:"reasoning_cost"`
 	ID               string         `json:"id"`
 }
 
@@ -174,7 +210,10 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		arg.PromptTokens,
 		arg.CompletionTokens,
 		arg.SummaryMessageID,
+		arg.KeptMessageID,
 		arg.Cost,
+		arg.CacheCost,
+		arg.ReasoningCost,
 		arg.ID,
 	)
 	var i Session
@@ -189,6 +228,12 @@ func (q *Queries) UpdateSession(ctx context.Context, arg UpdateSessionParams) (S
 		&i.UpdatedAt,
 		&i.CreatedAt,
 		&i.SummaryMessageID,
+		&i.CacheCost,
+		&i.UserID,
+		&i.KeptMessageID,
+		&i.ForkedFromID,
+		&i.ForkMessageID,
+		&i.ReasoningCost,
 	)
 	return i, err
 }
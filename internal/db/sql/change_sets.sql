@@ -0,0 +1,22 @@
+-- name: CreateChangeSet :one
+INSERT INTO change_sets (
+    id,
+    session_id,
+    files,
+    created_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now')
+)
+RETURNING *;
+
+-- name: GetLatestChangeSet :one
+SELECT *
+FROM change_sets
+WHERE session_id = ? AND reverted = 0
+ORDER BY created_at DESC, rowid DESC
+LIMIT 1;
+
+-- name: MarkChangeSetReverted :exec
+UPDATE change_sets
+SET reverted = 1
+WHERE id = ?;
@@ -0,0 +1,28 @@
+-- name: CreateRequestMetric :one
+INSERT INTO request_metrics (
+    id,
+    session_id,
+    provider,
+    model,
+    first_token_ms,
+    generation_ms,
+    output_tokens,
+    error,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+) RETURNING *;
+
+-- name: ListModelStats :many
+SELECT
+    provider,
+    model,
+    COUNT(*) AS requests,
+    CAST(COALESCE(SUM(CASE WHEN error != '' THEN 1 ELSE 0 END), 0) AS INTEGER) AS errors,
+    CAST(COALESCE(AVG(CASE WHEN error = '' AND first_token_ms > 0 THEN first_token_ms END), 0.0) AS REAL) AS avg_first_token_ms,
+    CAST(COALESCE(SUM(CASE WHEN error = '' THEN output_tokens ELSE 0 END), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(CASE WHEN error = '' THEN generation_ms ELSE 0 END), 0) AS INTEGER) AS generation_ms
+FROM request_metrics
+WHERE created_at >= ?
+GROUP BY provider, model
+ORDER BY provider, model;
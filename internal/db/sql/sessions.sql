@@ -8,6 +8,9 @@ INSERT INTO sessions (
     completion_tokens,
     cost,
     summary_message_id,
+    user_id,
+    forked_from_id,
+    fork_message_id,
     updated_at,
     created_at
 ) VALUES (
@@ -19,6 +22,9 @@ INSERT INTO sessions (
     ?,
     ?,
     null,
+    ?,
+    ?,
+    ?,
     strftime('%s', 'now'),
     strftime('%s', 'now')
 ) RETURNING *;
@@ -41,7 +47,10 @@ SET
     prompt_tokens = ?,
     completion_tokens = ?,
     summary_message_id = ?,
-    cost = ?
+    kept_message_id = ?,
+    cost = ?,
+    cache_cost = ?,
+    reasoning_cost = ?
 WHERE id = ?
 RETURNING *;
 
@@ -0,0 +1,38 @@
+-- name: CreateUsage :one
+INSERT INTO usage (
+    id,
+    session_id,
+    provider,
+    model,
+    input_tokens,
+    output_tokens,
+    cached_tokens,
+    cost,
+    latency_ms,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+) RETURNING *;
+
+-- name: GetSessionUsage :one
+SELECT
+    CAST(COALESCE(SUM(input_tokens + output_tokens), 0) AS INTEGER) AS tokens,
+    CAST(COALESCE(SUM(input_tokens), 0) AS INTEGER) AS input_tokens,
+    CAST(COALESCE(SUM(output_tokens), 0) AS INTEGER) AS output_tokens,
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost
+FROM usage
+WHERE session_id = sqlc.arg(session_id)
+    OR session_id IN (SELECT id FROM sessions WHERE parent_session_id = sqlc.arg(session_id));
+
+-- name: GetUsageSince :one
+SELECT
+    CAST(COALESCE(SUM(input_tokens + output_tokens), 0) AS INTEGER) AS tokens,
+    CAST(COALESCE(SUM(cost), 0.0) AS REAL) AS cost
+FROM usage
+WHERE created_at >= ?;
+
+-- name: ListUsageSince :many
+SELECT *
+FROM usage
+WHERE created_at >= ?
+ORDER BY created_at;
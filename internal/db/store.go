@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/csync"
+)
+
+// SQLiteBackend is the default storage backend, a SQLite database in the
+// data directory.
+const SQLiteBackend = "sqlite"
+
+// Store persists the sessions, messages and file history. Each storage
+// backend implements it over its database.
+type Store interface {
+	Querier
+
+	// WithTx runs fn in a transaction, committed when fn returns nil.
+	WithTx(ctx context.Context, fn func(q Querier) error) error
+
+	// Export returns all the rows of the store and Import inserts them in an
+	// empty store, keeping the IDs and the timestamps.
+	Export(ctx context.Context) (*Snapshot, error)
+	Import(ctx context.Context, snapshot *Snapshot) error
+
+	// Version returns the version of the schema migrations applied.
+	Version(ctx context.Context) (int64, error)
+
+	Close() error
+}
+
+// Snapshot is the content of a store.
+type Snapshot struct {
+	Sessions   []Session
+	Messages   []Message
+	Files      []File
+	Usage      []Usage
+	Metrics    []RequestMetric
+	ChangeSets []ChangeSet
+}
+
+// Empty reports whether the snapshot has no rows.
+func (s *Snapshot) Empty() bool {
+	return len(s.Sessions) == 0 && len(s.Messages) == 0 && len(s.Files) == 0 && len(s.Usage) == 0 && len(s.Metrics) == 0 && len(s.ChangeSets) == 0
+}
+
+// Backend opens the store of the data source name, applying the schema
+// migrations.
+type Backend func(ctx context.Context, dsn string) (Store, error)
+
+var backends = csync.NewMap[string, Backend]()
+
+func init() {
+	RegisterBackend(SQLiteBackend, OpenSQLiteStore)
+}
+
+// RegisterBackend makes the storage backend available under the name, as
+// selected by the options.storage.backend config.
+func RegisterBackend(name string, backend Backend) {
+	backends.Set(name, backend)
+}
+
+// Backends returns the names of the registered storage backends.
+func Backends() []string {
+	var names []string
+	for name := range backends.Seq2() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// OpenStore opens the store of the backend. The SQLite backend stores the
+// data in crush.db in the data directory when the data source name is empty.
+func OpenStore(ctx context.Context, backend, dsn, dataDir string) (Store, error) {
+	if backend == "" {
+		backend = SQLiteBackend
+	}
+	if backend == SQLiteBackend && dsn == "" {
+		conn, err := Connect(ctx, dataDir)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteStore(conn), nil
+	}
+
+	open, ok := backends.Get(backend)
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q, available backends: %s", backend, strings.Join(Backends(), ", "))
+	}
+	if dsn == "" {
+		return nil, fmt.Errorf("storage backend %s requires a data source name", backend)
+	}
+	return open(ctx, dsn)
+}
+
+// Copy copies all the data of src to dst, which must be empty, and returns
+// what was copied.
+func Copy(ctx context.Context, src, dst Store) (*Snapshot, error) {
+	existing, err := dst.Export(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the destination store: %w", err)
+	}
+	if !existing.Empty() {
+		return nil, fmt.Errorf("the destination store is not empty")
+	}
+
+	snapshot, err := src.Export(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export the source store: %w", err)
+	}
+	if err := dst.Import(ctx, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to import into the destination store: %w", err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+// sqliteStore is the Store of the SQLite backend.
+type sqliteStore struct {
+	Querier
+	db *sql.DB
+	q  *Queries
+}
+
+// NewSQLiteStore returns the store of a SQLite database opened with Open.
+// Closing the store closes the database.
+func NewSQLiteStore(conn *sql.DB) Store {
+	q := New(conn)
+	return &sqliteStore{Querier: q, db: conn, q: q}
+}
+
+// OpenSQLiteStore opens the SQLite database of the data source name, as Open
+// does.
+func OpenSQLiteStore(ctx context.Context, dsn string) (Store, error) {
+	conn, err := Open(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLiteStore(conn), nil
+}
+
+func (s *sqliteStore) WithTx(ctx context.Context, fn func(q Querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(s.q.WithTx(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Export(ctx context.Context) (*Snapshot, error) {
+	// Read in a transaction for a consistent snapshot.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	snapshot := &Snapshot{}
+	err = func() error {
+		rows, err := tx.QueryContext(ctx, `SELECT id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, cache_cost, user_id, kept_message_id, forked_from_id, fork_message_id, reasoning_cost FROM sessions ORDER BY created_at`)
+		if err != nil {
+			return err
+		}
+		snapshot.Sessions, err = scanRows(rows, func(i *Session) []any {
+			return []any{&i.ID, &i.ParentSessionID, &i.Title, &i.MessageCount, &i.PromptTokens, &i.CompletionTokens, &i.Cost, &i.UpdatedAt, &i.CreatedAt, &i.SummaryMessageID, &i.CacheCost, &i.UserID, &i.KeptMessageID, &i.ForkedFromID, &i.ForkMessageID, &i.ReasoningCost}
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT id, session_id, role, parts, model, created_at, updated_at, finished_at, provider FROM messages ORDER BY created_at`)
+		if err != nil {
+			return err
+		}
+		snapshot.Messages, err = scanRows(rows, func(i *Message) []any {
+			return []any{&i.ID, &i.SessionID, &i.Role, &i.Parts, &i.Model, &i.CreatedAt, &i.UpdatedAt, &i.FinishedAt, &i.Provider}
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT id, session_id, path, content, version, created_at, updated_at FROM files ORDER BY created_at`)
+		if err != nil {
+			return err
+		}
+		snapshot.Files, err = scanRows(rows, func(i *File) []any {
+			return []any{&i.ID, &i.SessionID, &i.Path, &i.Content, &i.Version, &i.CreatedAt, &i.UpdatedAt}
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT id, session_id, provider, model, input_tokens, output_tokens, cached_tokens, cost, latency_ms, created_at FROM usage ORDER BY created_at`)
+		if err != nil {
+			return err
+		}
+		snapshot.Usage, err = scanRows(rows, func(i *Usage) []any {
+			return []any{&i.ID, &i.SessionID, &i.Provider, &i.Model, &i.InputTokens, &i.OutputTokens, &i.CachedTokens, &i.Cost, &i.LatencyMs, &i.CreatedAt}
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT id, session_id, provider, model, first_token_ms, generation_ms, output_tokens, error, created_at FROM request_metrics ORDER BY created_at`)
+		if err != nil {
+			return err
+		}
+		snapshot.Metrics, err = scanRows(rows, func(i *RequestMetric) []any {
+			return []any{&i.ID, &i.SessionID, &i.Provider, &i.Model, &i.FirstTokenMs, &i.GenerationMs, &i.OutputTokens, &i.Error, &i.CreatedAt}
+		})
+		if err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(ctx, `SELECT id, session_id, files, reverted, created_at FROM change_sets ORDER BY created_at, rowid`)
+		if err != nil {
+			return err
+		}
+		snapshot.ChangeSets, err = scanRows(rows, func(i *ChangeSet) []any {
+			return []any{&i.ID, &i.SessionID, &i.Files, &i.Reverted, &i.CreatedAt}
+		})
+		return err
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (s *sqliteStore) Import(ctx context.Context, snapshot *Snapshot) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The triggers count the messages of the sessions and touch their
+	// updated_at, insert the messages first so the sessions keep the values
+	// of the snapshot.
+	if _, err := tx.ExecContext(ctx, `PRAGMA defer_foreign_keys = ON`); err != nil {
+		return err
+	}
+	for _, m := range snapshot.Messages {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (id, session_id, role, parts, model, created_at, updated_at, finished_at, provider) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, m.SessionID, m.Role, m.Parts, m.Model, m.CreatedAt, m.UpdatedAt, m.FinishedAt, m.Provider,
+		); err != nil {
+			return fmt.Errorf("failed to import message %s: %w", m.ID, err)
+		}
+	}
+	for _, ss := range snapshot.Sessions {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO sessions (id, parent_session_id, title, message_count, prompt_tokens, completion_tokens, cost, updated_at, created_at, summary_message_id, cache_cost, user_id, kept_message_id, forked_from_id, fork_message_id, reasoning_cost) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ss.ID, ss.ParentSessionID, ss.Title, ss.MessageCount, ss.PromptTokens, ss.CompletionTokens, ss.Cost, ss.UpdatedAt, ss.CreatedAt, ss.SummaryMessageID, ss.CacheCost, ss.UserID, ss.KeptMessageID, ss.ForkedFromID, ss.ForkMessageID, ss.ReasoningCost,
+		); err != nil {
+			return fmt.Errorf("failed to import session %s: %w", ss.ID, err)
+		}
+	}
+	for _, f := range snapshot.Files {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO files (id, session_id, path, content, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			f.ID, f.SessionID, f.Path, f.Content, f.Version, f.CreatedAt, f.UpdatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to import file %s: %w", f.ID, err)
+		}
+	}
+	for _, u := range snapshot.Usage {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO usage (id, session_id, provider, model, input_tokens, output_tokens, cached_tokens, cost, latency_ms, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			u.ID, u.SessionID, u.Provider, u.Model, u.InputTokens, u.OutputTokens, u.CachedTokens, u.Cost, u.LatencyMs, u.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to import usage %s: %w", u.ID, err)
+		}
+	}
+	for _, m := range snapshot.Metrics {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO request_metrics (id, session_id, provider, model, first_token_ms, generation_ms, output_tokens, error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ID, m.SessionID, m.Provider, m.Model, m.FirstTokenMs, m.GenerationMs, m.OutputTokens, m.Error, m.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to import request metric %s: %w", m.ID, err)
+		}
+	}
+	for _, c := range snapshot.ChangeSets {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO change_sets (id, session_id, files, reverted, created_at) VALUES (?, ?, ?, ?, ?)`,
+			c.ID, c.SessionID, c.Files, c.Reverted, c.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to import change set %s: %w", c.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Version(ctx context.Context) (int64, error) {
+	return goose.GetDBVersionContext(ctx, s.db)
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// scanRows scans all the rows into items, fields returns the destinations of
+// the columns of an item.
+func scanRows[T any](rows *sql.Rows, fields func(*T) []any) ([]T, error) {
+	defer rows.Close()
+	items := []T{}
+	for rows.Next() {
+		var i T
+		if err := rows.Scan(fields(&i)...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	store, err := OpenSQLiteStore(t.Context(), memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		store.Close()
+	})
+	return store
+}
+
+func TestCopy(t *testing.T) {
+	ctx := t.Context()
+	src := newTestStore(t)
+
+	session, err := src.CreateSession(ctx, CreateSessionParams{ID: "session", Title: "Fix the tests"})
+	require.NoError(t, err)
+	_, err = src.CreateSession(ctx, CreateSessionParams{
+		ID:              "task",
+		ParentSessionID: sql.NullString{String: "session", Valid: true},
+		Title:           "Find the tests",
+	})
+	require.NoError(t, err)
+	for _, id := range []string{"user", "assistant"} {
+		_, err = src.CreateMessage(ctx, CreateMessageParams{ID: id, SessionID: "session", Role: id, Parts: "[]"})
+		require.NoError(t, err)
+	}
+	_, err = src.CreateFile(ctx, CreateFileParams{ID: "file", SessionID: "session", Path: "main.go", Content: "package main"})
+	require.NoError(t, err)
+	_, err = src.CreateUsage(ctx, CreateUsageParams{ID: "usage", SessionID: "task", Provider: "anthropic", Model: "claude-sonnet-4", InputTokens: 1000, OutputTokens: 200, CachedTokens: 600, Cost: 0.006, LatencyMs: 4800})
+	require.NoError(t, err)
+	_, err = src.CreateRequestMetric(ctx, CreateRequestMetricParams{ID: "metric", SessionID: "task", Provider: "anthropic", Model: "claude-sonnet-4", FirstTokenMs: 800, GenerationMs: 4000, OutputTokens: 200})
+	require.NoError(t, err)
+	_, err = src.CreateChangeSet(ctx, CreateChangeSetParams{ID: "change", SessionID: "session", Files: "[]"})
+	require.NoError(t, err)
+
+	expected, err := src.Export(ctx)
+	require.NoError(t, err)
+	require.Len(t, expected.Sessions, 2)
+	require.Len(t, expected.Messages, 2)
+	require.Len(t, expected.Files, 1)
+	require.Len(t, expected.Usage, 1)
+	require.Len(t, expected.Metrics, 1)
+	require.Len(t, expected.ChangeSets, 1)
+
+	dst := newTestStore(t)
+	copied, err := Copy(ctx, src, dst)
+	require.NoError(t, err)
+	require.Equal(t, expected, copied)
+
+	actual, err := dst.Export(ctx)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	got, err := dst.GetSessionByID(ctx, "session")
+	require.NoError(t, err)
+	require.Equal(t, session.CreatedAt, got.CreatedAt)
+	require.EqualValues(t, 2, got.MessageCount)
+
+	usage, err := dst.GetSessionUsage(ctx, "session")
+	require.NoError(t, err)
+	require.Equal(t, GetSessionUsageRow{Tokens: 1200, InputTokens: 1000, OutputTokens: 200, Cost: 0.006}, usage)
+
+	ledger, err := dst.ListUsageSince(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, ledger, 1)
+	require.EqualValues(t, 600, ledger[0].CachedTokens)
+	require.EqualValues(t, 4800, ledger[0].LatencyMs)
+
+	_, err = Copy(ctx, src, dst)
+	require.ErrorContains(t, err, "not empty")
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := t.Context()
+	store := newTestStore(t)
+
+	err := store.WithTx(ctx, func(q Querier) error {
+		if _, err := q.CreateSession(ctx, CreateSessionParams{ID: "session"}); err != nil {
+			return err
+		}
+		return context.Canceled
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = store.GetSessionByID(ctx, "session")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestOpenStore(t *testing.T) {
+	ctx := t.Context()
+	dataDir := filepath.Join(t.TempDir(), "data")
+
+	store, err := OpenStore(ctx, "", "", dataDir)
+	require.NoError(t, err)
+	version, err := store.Version(ctx)
+	require.NoError(t, err)
+	require.Positive(t, version)
+	require.NoError(t, store.Close())
+	require.FileExists(t, filepath.Join(dataDir, "crush.db"))
+
+	_, err = OpenStore(ctx, "postgres", "postgres://localhost/crush", dataDir)
+	require.ErrorContains(t, err, `unknown storage backend "postgres", available backends: sqlite`)
+}
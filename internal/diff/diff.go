@@ -27,3 +27,26 @@ func GenerateDiff(beforeContent, afterContent, fileName string) (string, int, in
 
 	return unified, additions, removals
 }
+
+// Hunks returns the hunks changing the content before into the content after,
+// with their context lines.
+func Hunks(before, after string) []Hunk {
+	files, err := ParsePatch(udiff.Unified("a/file", "b/file", before, after))
+	if err != nil {
+		// No changes.
+		return nil
+	}
+	return files[0].Hunks
+}
+
+// ApplyAccepted returns the content before with the hunks of the change to the
+// content after that are accepted, by their index in [Hunks].
+func ApplyAccepted(before, after string, accepted []bool) (string, error) {
+	var hunks []Hunk
+	for i, hunk := range Hunks(before, after) {
+		if i < len(accepted) && accepted[i] {
+			hunks = append(hunks, hunk)
+		}
+	}
+	return ApplyHunks(before, hunks)
+}
@@ -0,0 +1,41 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyAccepted(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 30; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	before := strings.Join(lines, "\n") + "\n"
+	after := strings.Replace(before, "line 2\n", "line two\n", 1)
+	after = strings.Replace(after, "line 15\n", "", 1)
+	after = strings.Replace(after, "line 28\n", "line 28\nline 28.5\n", 1)
+
+	require.Len(t, Hunks(before, after), 3)
+	require.Empty(t, Hunks(before, before))
+
+	all, err := ApplyAccepted(before, after, []bool{true, true, true})
+	require.NoError(t, err)
+	require.Equal(t, after, all)
+
+	none, err := ApplyAccepted(before, after, nil)
+	require.NoError(t, err)
+	require.Equal(t, before, none)
+
+	some, err := ApplyAccepted(before, after, []bool{false, true, true})
+	require.NoError(t, err)
+	require.Contains(t, some, "line 2\n")
+	require.NotContains(t, some, "line 15\n")
+	require.Contains(t, some, "line 28\nline 28.5\n")
+
+	created, err := ApplyAccepted("", "package main\n", []bool{true})
+	require.NoError(t, err)
+	require.Equal(t, "package main\n", created)
+}
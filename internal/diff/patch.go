@@ -0,0 +1,391 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxFuzz is the number of context lines that may be ignored at each end of
+// a hunk whose context doesn't match, as with the fuzz factor of patch.
+const maxFuzz = 2
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// FilePatch is the change of one file in a patch. OldPath is empty for new
+// files, and NewPath for deleted ones.
+type FilePatch struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// IsNew reports whether the patch creates the file.
+func (f FilePatch) IsNew() bool {
+	return f.OldPath == ""
+}
+
+// IsDelete reports whether the patch deletes the file.
+func (f FilePatch) IsDelete() bool {
+	return f.NewPath == ""
+}
+
+// Path returns the path of the file after the patch, or the deleted one.
+func (f FilePatch) Path() string {
+	if f.IsDelete() {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// Hunk is a change of consecutive lines.
+type Hunk struct {
+	// OldStart is the 1-based line of the change in the file, 0 if unknown.
+	OldStart int
+	Lines    []HunkLine
+	// NoNewlineOld and NoNewlineNew are set when the file doesn't end with a
+	// newline before or after the change.
+	NoNewlineOld bool
+	NoNewlineNew bool
+}
+
+// HunkLine is a line of a hunk, of kind ' ' for context, '-' for a removed
+// line, or '+' for an added one.
+type HunkLine struct {
+	Kind byte
+	Text string
+}
+
+func (h Hunk) lines(kinds string) []string {
+	var lines []string
+	for _, l := range h.Lines {
+		if strings.IndexByte(kinds, l.Kind) >= 0 {
+			lines = append(lines, l.Text)
+		}
+	}
+	return lines
+}
+
+// ParsePatch parses a unified diff of one or more files, as printed by diff
+// -u or git diff. The line counts of the hunk headers are ignored, as models
+// often get them wrong, and so are the lines around the diff.
+func ParsePatch(patch string) ([]FilePatch, error) {
+	patch = strings.ReplaceAll(patch, "\r\n", "\n")
+	lines := strings.Split(patch, "\n")
+
+	var files []FilePatch
+	var file *FilePatch
+	// gitPaths are the paths of the diff --git line, for patches without
+	// --- and +++ lines such as new empty files.
+	var gitPaths [2]string
+	// hasPaths is set once the --- and +++ lines of the file are read.
+	var newFile, deletedFile, hasPaths bool
+
+	finish := func() error {
+		if file == nil {
+			return nil
+		}
+		if file.OldPath == "" && file.NewPath == "" {
+			file.OldPath, file.NewPath = gitPaths[0], gitPaths[1]
+		}
+		if newFile {
+			file.OldPath = ""
+		}
+		if deletedFile {
+			file.NewPath = ""
+		}
+		if file.OldPath == "" && file.NewPath == "" {
+			return errors.New("patch without the path of the file")
+		}
+		files = append(files, *file)
+		file, gitPaths, newFile, deletedFile, hasPaths = nil, [2]string{}, false, false, false
+		return nil
+	}
+	start := func() error {
+		if err := finish(); err != nil {
+			return err
+		}
+		file = &FilePatch{}
+		return nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if err := start(); err != nil {
+				return nil, err
+			}
+			if old, new, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				gitPaths = [2]string{strings.TrimPrefix(old, "a/"), new}
+			}
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if file == nil || hasPaths {
+				if err := start(); err != nil {
+					return nil, err
+				}
+			}
+			file.OldPath, file.NewPath = patchPaths(line[4:], lines[i+1][4:])
+			hasPaths = true
+			i++
+		case file != nil && strings.HasPrefix(line, "new file mode"):
+			newFile = true
+		case file != nil && strings.HasPrefix(line, "deleted file mode"):
+			deletedFile = true
+		case file != nil && strings.HasPrefix(line, "rename from "):
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case file != nil && strings.HasPrefix(line, "rename to "):
+			file.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk before the --- and +++ lines of its file", i+1)
+			}
+			hunk := Hunk{}
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				hunk.OldStart, _ = strconv.Atoi(m[1])
+				if m[2] == "0" {
+					// Lines are added after the line of the header.
+					hunk.OldStart++
+				}
+			}
+			i = parseHunk(lines, i+1, &hunk) - 1
+			if len(hunk.Lines) == 0 {
+				return nil, fmt.Errorf("line %d: empty hunk", i+1)
+			}
+			file.Hunks = append(file.Hunks, hunk)
+		}
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no file changes found in the patch, expected a unified diff with --- and +++ lines and @@ hunks")
+	}
+	return files, nil
+}
+
+// parseHunk parses the lines of a hunk starting at i, and returns the index
+// of the line after it.
+func parseHunk(lines []string, i int, hunk *Hunk) int {
+	// Empty lines are context lines that lost their space, unless they end
+	// the hunk.
+	blank := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			blank++
+			continue
+		}
+		kind := line[0]
+		if kind != ' ' && kind != '-' && kind != '+' && kind != '\\' {
+			break
+		}
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") {
+			break
+		}
+		for ; blank > 0; blank-- {
+			hunk.Lines = append(hunk.Lines, HunkLine{Kind: ' '})
+		}
+		if kind == '\\' {
+			// "\ No newline at end of file" is about the line before.
+			if n := len(hunk.Lines); n > 0 {
+				switch hunk.Lines[n-1].Kind {
+				case '-':
+					hunk.NoNewlineOld = true
+				case '+':
+					hunk.NoNewlineNew = true
+				default:
+					hunk.NoNewlineOld, hunk.NoNewlineNew = true, true
+				}
+			}
+			continue
+		}
+		hunk.Lines = append(hunk.Lines, HunkLine{Kind: kind, Text: line[1:]})
+	}
+	return i - blank
+}
+
+// patchPaths returns the paths of the --- and +++ lines, without their a/
+// and b/ prefixes, and empty for /dev/null.
+func patchPaths(old, new string) (string, string) {
+	clean := func(path string) string {
+		// diff -u separates the path from the time with a tab.
+		path, _, _ = strings.Cut(path, "\t")
+		path = strings.TrimSpace(path)
+		if path == "/dev/null" {
+			return ""
+		}
+		return path
+	}
+	old, new = clean(old), clean(new)
+	if (old == "" || strings.HasPrefix(old, "a/")) && (new == "" || strings.HasPrefix(new, "b/")) {
+		old, new = strings.TrimPrefix(old, "a/"), strings.TrimPrefix(new, "b/")
+	}
+	return old, new
+}
+
+// ApplyHunks applies the hunks to the content of a file. Hunks are looked for
+// near their line, then anywhere after the previous hunk, ignoring trailing
+// whitespace and then indentation if they don't match exactly, and with up to
+// maxFuzz lines of context ignored. Context lines keep the text of the file.
+func ApplyHunks(content string, hunks []Hunk) (string, error) {
+	crlf := strings.Contains(content, "\r\n")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	newline := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	// shift is how far the hunks moved from their lines, with the lines
+	// added and removed by the previous hunks.
+	shift, from := 0, 0
+	for i, hunk := range hunks {
+		expected := -1
+		if hunk.OldStart > 0 {
+			expected = hunk.OldStart - 1 + shift
+		}
+		pos, trimmed, err := findHunk(lines, hunk, from, expected)
+		if err != nil {
+			return "", fmt.Errorf("hunk %d: %w", i+1, err)
+		}
+
+		// The lines of the hunk without the ignored context.
+		hunkLines := hunk.Lines[trimmed[0] : len(hunk.Lines)-trimmed[1]]
+		var replacement []string
+		old := 0
+		for _, l := range hunkLines {
+			switch l.Kind {
+			case ' ':
+				replacement = append(replacement, lines[pos+old])
+				old++
+			case '-':
+				old++
+			case '+':
+				replacement = append(replacement, l.Text)
+			}
+		}
+		lines = append(lines[:pos], append(replacement, lines[pos+old:]...)...)
+		if pos+len(replacement) == len(lines) {
+			switch {
+			case hunk.NoNewlineNew:
+				newline = false
+			case hunk.NoNewlineOld:
+				newline = true
+			}
+		}
+
+		if hunk.OldStart > 0 {
+			shift = pos - (hunk.OldStart - 1 + trimmed[0]) + len(replacement) - old
+		} else {
+			shift += len(replacement) - old
+		}
+		from = pos + len(replacement)
+	}
+
+	result := strings.Join(lines, "\n")
+	if newline && len(lines) > 0 {
+		result += "\n"
+	}
+	if crlf {
+		result = strings.ReplaceAll(result, "\n", "\r\n")
+	}
+	return result, nil
+}
+
+// findHunk returns the line of the file where the old lines of the hunk are,
+// and how many context lines were ignored at its start and end.
+func findHunk(lines []string, hunk Hunk, from, expected int) (int, [2]int, error) {
+	old := hunk.lines(" -")
+	if len(old) == 0 {
+		// Only added lines, they go at the line of the hunk.
+		pos := len(lines)
+		if expected >= 0 {
+			pos = min(max(expected, from), len(lines))
+		}
+		return pos, [2]int{}, nil
+	}
+
+	leading, trailing := 0, 0
+	for _, l := range hunk.Lines {
+		if l.Kind != ' ' {
+			break
+		}
+		leading++
+	}
+	for i := len(hunk.Lines) - 1; i >= 0 && hunk.Lines[i].Kind == ' '; i-- {
+		trailing++
+	}
+
+	normalizers := []func(string) string{
+		func(s string) string { return s },
+		func(s string) string { return strings.TrimRight(s, " \t") },
+		strings.TrimSpace,
+	}
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		trimmed := [2]int{min(fuzz, leading), min(fuzz, trailing)}
+		if fuzz > 0 && trimmed == [2]int{} {
+			break
+		}
+		want := old[trimmed[0] : len(old)-trimmed[1]]
+		if len(want) == 0 {
+			break
+		}
+		for _, normalize := range normalizers {
+			var matches []int
+			for pos := from; pos+len(want) <= len(lines); pos++ {
+				if linesMatch(lines[pos:pos+len(want)], want, normalize) {
+					matches = append(matches, pos)
+				}
+			}
+			if len(matches) == 0 {
+				continue
+			}
+			if expected < 0 {
+				if len(matches) > 1 {
+					return 0, trimmed, fmt.Errorf("the lines to change appear %d times, add more context lines or the line numbers of the hunk:\n%s", len(matches), quoteLines(old))
+				}
+				return matches[0], trimmed, nil
+			}
+			// The match closest to the line of the hunk.
+			target := expected + trimmed[0]
+			best := matches[0]
+			for _, pos := range matches[1:] {
+				if abs(pos-target) < abs(best-target) {
+					best = pos
+				}
+			}
+			return best, trimmed, nil
+		}
+	}
+	return 0, [2]int{}, fmt.Errorf("the lines to change were not found in the file, read it again and make sure the context and removed lines match it:\n%s", quoteLines(old))
+}
+
+func linesMatch(lines, want []string, normalize func(string) string) bool {
+	for i := range want {
+		if normalize(lines[i]) != normalize(want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteLines returns the first lines for an error message.
+func quoteLines(lines []string) string {
+	const maxLines = 10
+	quoted := lines[:min(len(lines), maxLines)]
+	s := strings.Join(quoted, "\n")
+	if len(lines) > maxLines {
+		s += fmt.Sprintf("\n[%d more lines]", len(lines)-maxLines)
+	}
+	return s
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePatch(t *testing.T) {
+	t.Parallel()
+
+	patch := `Here is the patch:
+
+diff --git a/main.go b/main.go
+index 3b18e51..a2c4f2e 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-// old
++// new
+ func main() {}
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/empty.txt b/empty.txt
+new file mode 100644
+index 0000000..e69de29
+`
+	files, err := ParsePatch(patch)
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+
+	require.Equal(t, "main.go", files[0].OldPath)
+	require.Equal(t, "main.go", files[0].NewPath)
+	require.Equal(t, []Hunk{{
+		OldStart: 1,
+		Lines: []HunkLine{
+			{' ', "package main"},
+			{'-', "// old"},
+			{'+', "// new"},
+			{' ', "func main() {}"},
+		},
+	}}, files[0].Hunks)
+
+	require.True(t, files[1].IsNew())
+	require.Equal(t, "new.txt", files[1].Path())
+	require.Equal(t, 1, files[1].Hunks[0].OldStart)
+
+	require.True(t, files[2].IsDelete())
+	require.Equal(t, "old.txt", files[2].Path())
+
+	require.True(t, files[3].IsNew())
+	require.Equal(t, "empty.txt", files[3].Path())
+	require.Empty(t, files[3].Hunks)
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParsePatch("just some text")
+	require.ErrorContains(t, err, "no file changes")
+
+	_, err = ParsePatch("@@ -1 +1 @@\n-a\n+b\n")
+	require.ErrorContains(t, err, "hunk before")
+}
+
+func apply(t *testing.T, content, patch string) (string, error) {
+	t.Helper()
+	files, err := ParsePatch(patch)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	return ApplyHunks(content, files[0].Hunks)
+}
+
+func TestApplyHunks(t *testing.T) {
+	t.Parallel()
+
+	content := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+
+	t.Run("several hunks", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, content, `--- a/x
++++ b/x
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+@@ -8,3 +8,4 @@
+ h
+ i
++i2
+ j
+`)
+		require.NoError(t, err)
+		require.Equal(t, "a\nB\nc\nd\ne\nf\ng\nh\ni\ni2\nj\n", got)
+	})
+
+	t.Run("wrong line numbers", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, content, `--- a/x
++++ b/x
+@@ -2,3 +2,2 @@
+ e
+-f
+ g
+`)
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nd\ne\ng\nh\ni\nj\n", got)
+	})
+
+	t.Run("without line numbers", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, content, "--- a/x\n+++ b/x\n@@\n c\n-d\n+D\n e\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\n", got)
+	})
+
+	t.Run("ambiguous without line numbers", func(t *testing.T) {
+		t.Parallel()
+		_, err := apply(t, "x\ny\nx\ny\n", "--- a/x\n+++ b/x\n@@\n x\n-y\n+z\n")
+		require.ErrorContains(t, err, "appear 2 times")
+	})
+
+	t.Run("closest match", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, "x\ny\nx\ny\n", "--- a/x\n+++ b/x\n@@ -3,2 +3,2 @@\n x\n-y\n+z\n")
+		require.NoError(t, err)
+		require.Equal(t, "x\ny\nx\nz\n", got)
+	})
+
+	t.Run("different indentation", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, "func f() {\n\tif x {\n\t\treturn 1\n\t}\n}\n", `--- a/x
++++ b/x
+@@ -1,5 +1,5 @@
+ func f() {
+     if x {
+-        return 1
++		return 2
+     }
+ }
+`)
+		require.NoError(t, err)
+		require.Equal(t, "func f() {\n\tif x {\n\t\treturn 2\n\t}\n}\n", got)
+	})
+
+	t.Run("fuzzy context", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, content, "--- a/x\n+++ b/x\n@@ -3,5 +3,5 @@\n c\n-d\n+D\n e\n nope\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nD\ne\nf\ng\nh\ni\nj\n", got)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		t.Parallel()
+		_, err := apply(t, content, "--- a/x\n+++ b/x\n@@ -3,3 +3,3 @@\n c\n-x\n+y\n e\n")
+		require.ErrorContains(t, err, "hunk 1: the lines to change were not found")
+	})
+
+	t.Run("blank context lines without space", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, "a\n\nb\n", "--- a/x\n+++ b/x\n@@ -1,3 +1,3 @@\n a\n\n-b\n+c\n\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\n\nc\n", got)
+	})
+
+	t.Run("crlf", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, "a\r\nb\r\n", "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\r\nc\r\n", got)
+	})
+
+	t.Run("no newline at end of file", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, "a\nb\n", "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nc", got)
+
+		got, err = apply(t, "a\nb", "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nc\n", got)
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		t.Parallel()
+		got, err := apply(t, "", "--- /dev/null\n+++ b/x\n@@ -0,0 +1,2 @@\n+a\n+b\n")
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\n", got)
+	})
+}
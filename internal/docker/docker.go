@@ -0,0 +1,230 @@
+// Package docker runs the docker CLI for the docker tool, to list containers,
+// read their logs, and start and run commands in the compose services of the
+// project.
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// composeFiles are the files docker compose looks for by default.
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Find returns the path of the docker CLI, the one given or the one in the
+// PATH.
+func Find(path string) (string, error) {
+	path, err := exec.LookPath(cmp.Or(path, "docker"))
+	if err != nil {
+		return "", fmt.Errorf("docker not found: %w", err)
+	}
+	return path, nil
+}
+
+// Client runs the docker CLI in the directory of a project.
+type Client struct {
+	path         string
+	dir          string
+	composeFiles []string
+}
+
+// New returns a client of the docker CLI at path, running compose with the
+// compose files of the directory, the default ones without files.
+func New(path, dir string, composeFiles []string) *Client {
+	return &Client{path: path, dir: dir, composeFiles: composeFiles}
+}
+
+// Container is a container, as listed by docker ps.
+type Container struct {
+	ID     string
+	Name   string
+	Image  string
+	State  string
+	Status string
+	Ports  string
+	// Project and Service are the compose project and service of the
+	// container, if any.
+	Project string
+	Service string
+}
+
+// HasCompose reports whether the project has compose files.
+func (c *Client) HasCompose() bool {
+	files := c.composeFiles
+	if len(files) == 0 {
+		files = composeFiles
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(c.dir, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Containers lists the running containers, or all of them.
+func (c *Client) Containers(ctx context.Context, all bool) ([]Container, error) {
+	args := []string{"ps", "--no-trunc", "--format", "{{json .}}"}
+	if all {
+		args = append(args, "--all")
+	}
+	output, err := c.run(ctx, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []Container
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var ps struct {
+			ID     string
+			Names  string
+			Image  string
+			State  string
+			Status string
+			Ports  string
+			Labels string
+		}
+		if err := json.Unmarshal([]byte(line), &ps); err != nil {
+			return nil, fmt.Errorf("failed to parse the containers: %w", err)
+		}
+		container := Container{
+			ID:     ps.ID[:min(len(ps.ID), 12)],
+			Name:   ps.Names,
+			Image:  ps.Image,
+			State:  ps.State,
+			Status: ps.Status,
+			Ports:  ps.Ports,
+		}
+		for label := range strings.SplitSeq(ps.Labels, ",") {
+			key, value, _ := strings.Cut(label, "=")
+			switch key {
+			case "com.docker.compose.project":
+				container.Project = value
+			case "com.docker.compose.service":
+				container.Service = value
+			}
+		}
+		containers = append(containers, container)
+	}
+	return containers, scanner.Err()
+}
+
+// Target is a container, or a service of the compose project.
+type Target struct {
+	Container string
+	Service   string
+}
+
+func (t Target) validate() error {
+	if (t.Container == "") == (t.Service == "") {
+		return errors.New("either a container or a compose service is required")
+	}
+	return nil
+}
+
+// Logs returns the last lines of the logs of the target, since a duration
+// such as 10m or a timestamp if set.
+func (c *Client) Logs(ctx context.Context, target Target, tail int, since string) (string, error) {
+	if err := target.validate(); err != nil {
+		return "", err
+	}
+	args := []string{"logs", "--tail", fmt.Sprint(tail)}
+	if since != "" {
+		args = append(args, "--since", since)
+	}
+	if target.Service != "" {
+		return c.run(ctx, nil, c.compose(append(args, "--no-color", target.Service)...)...)
+	}
+	return c.run(ctx, nil, append(args, target.Container)...)
+}
+
+// Up starts the services of the compose project, or all of them, in the
+// background, building their images first if build is set. The output is
+// written to w as it comes too.
+func (c *Client) Up(ctx context.Context, services []string, build bool, w io.Writer) (string, error) {
+	if !c.HasCompose() {
+		return "", errors.New("the project has no compose file")
+	}
+	args := []string{"up", "--detach", "--wait"}
+	if build {
+		args = append(args, "--build")
+	}
+	return c.run(ctx, w, c.compose(append(args, services...)...)...)
+}
+
+// Exec runs the command with sh in the target. The output is written to w
+// as it comes too. A command exiting with an error returns an ExitError.
+func (c *Client) Exec(ctx context.Context, target Target, command string, w io.Writer) (string, error) {
+	if err := target.validate(); err != nil {
+		return "", err
+	}
+	if target.Service != "" {
+		return c.run(ctx, w, c.compose("exec", "--no-TTY", target.Service, "sh", "-c", command)...)
+	}
+	return c.run(ctx, w, "exec", target.Container, "sh", "-c", command)
+}
+
+// compose returns the arguments of the compose subcommand.
+func (c *Client) compose(args ...string) []string {
+	compose := []string{"compose"}
+	for _, file := range c.composeFiles {
+		compose = append(compose, "--file", file)
+	}
+	return append(compose, args...)
+}
+
+// ExitError is returned when docker or the command it runs exits with an
+// error, with their combined output.
+type ExitError struct {
+	Output   string
+	ExitCode int
+}
+
+func (e *ExitError) Error() string {
+	if output := strings.TrimSpace(e.Output); output != "" {
+		return output
+	}
+	return fmt.Sprintf("exit code %d", e.ExitCode)
+}
+
+// run runs docker in the directory and returns its combined output, writing
+// it to w too if set.
+func (c *Client) run(ctx context.Context, w io.Writer, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, c.path, args...)
+	cmd.Dir = c.dir
+	var output bytes.Buffer
+	var out io.Writer = &output
+	if w != nil {
+		out = io.MultiWriter(&output, w)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return output.String(), ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.String(), &ExitError{Output: output.String(), ExitCode: exitErr.ExitCode()}
+	}
+	if err != nil {
+		return output.String(), fmt.Errorf("failed to run docker: %w", err)
+	}
+	return output.String(), nil
+}
@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDocker writes a docker script printing its arguments, or containers
+// for ps, and failing for the missing container.
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake docker is a shell script")
+	}
+	bin := t.TempDir()
+	script := `#!/bin/sh
+case "$*" in
+*missing*) echo "Error response from daemon: No such container: missing" >&2; exit 1 ;;
+ps*)
+	echo '{"ID":"0123456789abcdef","Names":"app-db-1","Image":"postgres:16","State":"running","Status":"Up 2 minutes","Ports":"0.0.0.0:5432->5432/tcp","Labels":"com.docker.compose.project=app,com.docker.compose.service=db"}'
+	echo '{"ID":"fedcba9876543210","Names":"scratch","Image":"alpine","State":"exited","Status":"Exited (0) 1 hour ago","Ports":"","Labels":""}'
+	;;
+*) echo "$@" ;;
+esac
+`
+	path := filepath.Join(bin, "docker")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o755))
+	return path
+}
+
+func TestContainers(t *testing.T) {
+	client := New(fakeDocker(t), t.TempDir(), nil)
+	containers, err := client.Containers(t.Context(), true)
+	require.NoError(t, err)
+	require.Equal(t, []Container{
+		{ID: "0123456789ab", Name: "app-db-1", Image: "postgres:16", State: "running", Status: "Up 2 minutes", Ports: "0.0.0.0:5432->5432/tcp", Project: "app", Service: "db"},
+		{ID: "fedcba987654", Name: "scratch", Image: "alpine", State: "exited", Status: "Exited (0) 1 hour ago"},
+	}, containers)
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	client := New(fakeDocker(t), dir, nil)
+
+	output, err := client.Logs(t.Context(), Target{Container: "scratch"}, 100, "10m")
+	require.NoError(t, err)
+	require.Equal(t, "logs --tail 100 --since 10m scratch", strings.TrimSpace(output))
+	output, err = client.Logs(t.Context(), Target{Service: "db"}, 50, "")
+	require.NoError(t, err)
+	require.Equal(t, "compose logs --tail 50 --no-color db", strings.TrimSpace(output))
+	_, err = client.Logs(t.Context(), Target{}, 50, "")
+	require.Error(t, err)
+
+	var progress strings.Builder
+	output, err = client.Exec(t.Context(), Target{Service: "db"}, "psql -c 'SELECT 1'", &progress)
+	require.NoError(t, err)
+	require.Equal(t, "compose exec --no-TTY db sh -c psql -c 'SELECT 1'", strings.TrimSpace(output))
+	require.Equal(t, output, progress.String())
+
+	_, err = client.Exec(t.Context(), Target{Container: "missing"}, "ls", nil)
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitCode)
+	require.EqualError(t, err, "Error response from daemon: No such container: missing")
+
+	// Up needs a compose file, the given ones or the default ones.
+	require.False(t, client.HasCompose())
+	_, err = client.Up(t.Context(), nil, false, nil)
+	require.EqualError(t, err, "the project has no compose file")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte("services: {}\n"), 0o644))
+	output, err = client.Up(t.Context(), []string{"db", "cache"}, true, nil)
+	require.NoError(t, err)
+	require.Equal(t, "compose up --detach --wait --build db cache", strings.TrimSpace(output))
+
+	client = New(client.path, dir, []string{"compose.dev.yaml"})
+	require.False(t, client.HasCompose())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "compose.dev.yaml"), []byte("services: {}\n"), 0o644))
+	output, err = client.Up(t.Context(), nil, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, "compose --file compose.dev.yaml up --detach --wait", strings.TrimSpace(output))
+}
@@ -0,0 +1,258 @@
+// Package forge talks to the APIs of GitHub and GitLab for the forge tool, to
+// read the issues of the repository and open pull requests.
+package forge
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Issue is an issue of the repository, with its comments when read alone.
+type Issue struct {
+	Number   int       `json:"number"`
+	Title    string    `json:"title"`
+	State    string    `json:"state"`
+	Author   string    `json:"author"`
+	URL      string    `json:"url"`
+	Labels   []string  `json:"labels,omitempty"`
+	Body     string    `json:"body,omitempty"`
+	Comments []Comment `json:"comments,omitempty"`
+}
+
+// Comment is a comment of an issue.
+type Comment struct {
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IssueQuery selects the issues listed by Forge.Issues.
+type IssueQuery struct {
+	// State is open, closed, or all, open by default.
+	State  string
+	Search string
+	// Limit is at most 100, DefaultIssueLimit by default.
+	Limit int
+}
+
+// DefaultIssueLimit is the number of issues listed unless asked otherwise.
+const DefaultIssueLimit = 20
+
+func (q IssueQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultIssueLimit
+	}
+	return min(q.Limit, 100)
+}
+
+// NewPullRequest is a pull request to open, of the head branch into the
+// base one.
+type NewPullRequest struct {
+	Title string
+	Body  string
+	Head  string
+	Base  string
+	Draft bool
+}
+
+// PullRequest is a pull request, or a merge request on GitLab.
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Draft  bool   `json:"draft,omitempty"`
+}
+
+// Forge is the host of the repository.
+type Forge interface {
+	// Name is the name of the forge, such as GitHub.
+	Name() string
+	// Repository is the path of the repository, such as owner/name.
+	Repository() string
+	DefaultBranch(ctx context.Context) (string, error)
+	Issue(ctx context.Context, number int) (*Issue, error)
+	Issues(ctx context.Context, query IssueQuery) ([]Issue, error)
+	CreatePullRequest(ctx context.Context, pr NewPullRequest) (*PullRequest, error)
+	// PushAuthHeader returns the HTTP header authorizing pushes with the
+	// token, or nothing without a token.
+	PushAuthHeader() string
+}
+
+// New returns the forge hosting the repository of the remote URL, with the
+// token of the config or of the environment.
+func New(cfg config.Forge, resolver config.VariableResolver, remoteURL string) (Forge, error) {
+	host, path, err := ParseRemote(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := cfg.Type
+	if kind == "" {
+		switch {
+		case strings.Contains(host, "github"):
+			kind = config.ForgeGitHub
+		case strings.Contains(host, "gitlab"):
+			kind = config.ForgeGitLab
+		default:
+			return nil, fmt.Errorf("can't tell the forge of %s, set the type of the forge option", host)
+		}
+	}
+
+	token, err := resolveToken(cfg.Token, kind, resolver)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := strings.TrimSuffix(cfg.URL, "/")
+
+	switch kind {
+	case config.ForgeGitHub:
+		if apiURL == "" {
+			apiURL = "https://" + host + "/api/v3"
+			if host == "github.com" {
+				apiURL = "https://api.github.com"
+			}
+		}
+		return &github{apiURL: apiURL, token: token, repo: path}, nil
+	case config.ForgeGitLab:
+		return &gitlab{apiURL: cmp.Or(apiURL, "https://"+host+"/api/v4"), token: token, repo: path}, nil
+	default:
+		return nil, fmt.Errorf("unknown forge %q", kind)
+	}
+}
+
+// resolveToken resolves the token of the config, or reads the one of the
+// environment variables of the forge.
+func resolveToken(token, kind string, resolver config.VariableResolver) (string, error) {
+	if token != "" {
+		if resolver == nil {
+			return token, nil
+		}
+		resolved, err := resolver.ResolveValue(token)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve the token of %s: %w", kind, err)
+		}
+		return resolved, nil
+	}
+	if resolver == nil {
+		return "", nil
+	}
+	variables := []string{"$GITHUB_TOKEN", "$GH_TOKEN"}
+	if kind == config.ForgeGitLab {
+		variables = []string{"$GITLAB_TOKEN"}
+	}
+	for _, variable := range variables {
+		if resolved, err := resolver.ResolveValue(variable); err == nil && resolved != "" {
+			return resolved, nil
+		}
+	}
+	return "", nil
+}
+
+// ParseRemote returns the host and the path of the repository of a remote
+// URL, such as git@github.com:owner/name.git or https://gitlab.com/group/name.
+func ParseRemote(remote string) (host, path string, err error) {
+	remote = strings.TrimSpace(remote)
+	if !strings.Contains(remote, "://") {
+		// The scp-like syntax of ssh, user@host:path.
+		address, p, ok := strings.Cut(remote, ":")
+		if !ok || strings.Contains(address, "/") {
+			return "", "", fmt.Errorf("invalid remote %q", remote)
+		}
+		_, host, _ = strings.Cut(address, "@")
+		if host == "" {
+			host = address
+		}
+		path = p
+	} else {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote %q: %w", remote, err)
+		}
+		host, path = u.Hostname(), u.Path
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return "", "", fmt.Errorf("invalid remote %q", remote)
+	}
+	return host, path, nil
+}
+
+// doJSON sends a request to the API of the forge with the body encoded as
+// JSON, and decodes the JSON response into v.
+func doJSON(ctx context.Context, name, method, endpoint string, headers map[string]string, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "crush/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		msg := strings.TrimSpace(string(data))
+		var apiErr struct {
+			Message any    `json:"message"`
+			Error   string `json:"error"`
+			Errors  []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil {
+			switch m := apiErr.Message.(type) {
+			case string:
+				msg = m
+				// GitHub details validation errors.
+				for _, e := range apiErr.Errors {
+					if e.Message != "" {
+						msg += ": " + e.Message
+					}
+				}
+			case nil:
+				msg = cmp.Or(apiErr.Error, msg)
+			default:
+				// GitLab validation errors are objects or arrays.
+				encoded, _ := json.Marshal(m)
+				msg = string(encoded)
+			}
+		}
+		return fmt.Errorf("%s returned status %d: %s", name, resp.StatusCode, msg)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode the response of %s: %w", name, err)
+	}
+	return nil
+}
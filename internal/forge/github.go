@@ -0,0 +1,165 @@
+package forge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type github struct {
+	apiURL string
+	token  string
+	// repo is owner/name.
+	repo string
+}
+
+func (g *github) Name() string {
+	return "GitHub"
+}
+
+func (g *github) Repository() string {
+	return g.repo
+}
+
+func (g *github) PushAuthHeader() string {
+	if g.token == "" {
+		return ""
+	}
+	return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+g.token))
+}
+
+func (g *github) do(ctx context.Context, method, path string, body, v any) error {
+	headers := map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	if g.token != "" {
+		headers["Authorization"] = "Bearer " + g.token
+	}
+	return doJSON(ctx, g.Name(), method, g.apiURL+path, headers, body, v)
+}
+
+type githubIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url"`
+	Body    string `json:"body"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+func (i githubIssue) issue() Issue {
+	issue := Issue{
+		Number: i.Number,
+		Title:  i.Title,
+		State:  i.State,
+		Author: i.User.Login,
+		URL:    i.HTMLURL,
+		Body:   i.Body,
+	}
+	for _, label := range i.Labels {
+		issue.Labels = append(issue.Labels, label.Name)
+	}
+	return issue
+}
+
+func (g *github) DefaultBranch(ctx context.Context) (string, error) {
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.do(ctx, http.MethodGet, "/repos/"+g.repo, nil, &repo); err != nil {
+		return "", err
+	}
+	return repo.DefaultBranch, nil
+}
+
+func (g *github) Issue(ctx context.Context, number int) (*Issue, error) {
+	var i githubIssue
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", g.repo, number), nil, &i); err != nil {
+		return nil, err
+	}
+	issue := i.issue()
+
+	var comments []struct {
+		Body      string    `json:"body"`
+		CreatedAt time.Time `json:"created_at"`
+		User      struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100", g.repo, number), nil, &comments); err != nil {
+		return nil, err
+	}
+	for _, c := range comments {
+		issue.Comments = append(issue.Comments, Comment{Author: c.User.Login, Body: c.Body, CreatedAt: c.CreatedAt})
+	}
+	return &issue, nil
+}
+
+func (g *github) Issues(ctx context.Context, query IssueQuery) ([]Issue, error) {
+	state := query.State
+	if state == "" {
+		state = "open"
+	}
+	limit := query.limit()
+
+	var found []githubIssue
+	if query.Search != "" {
+		q := fmt.Sprintf("repo:%s is:issue %s", g.repo, query.Search)
+		if state != "all" {
+			q += " state:" + state
+		}
+		var result struct {
+			Items []githubIssue `json:"items"`
+		}
+		if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/search/issues?q=%s&per_page=%d", url.QueryEscape(q), limit), nil, &result); err != nil {
+			return nil, err
+		}
+		found = result.Items
+	} else {
+		// Pull requests are issues too, ask for more to fill the limit.
+		if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues?state=%s&per_page=%d", g.repo, url.QueryEscape(state), min(limit*2, 100)), nil, &found); err != nil {
+			return nil, err
+		}
+	}
+
+	issues := []Issue{}
+	for _, i := range found {
+		if i.PullRequest != nil || len(issues) == limit {
+			continue
+		}
+		issue := i.issue()
+		issue.Body = ""
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+func (g *github) CreatePullRequest(ctx context.Context, pr NewPullRequest) (*PullRequest, error) {
+	body := map[string]any{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.Head,
+		"base":  pr.Base,
+		"draft": pr.Draft,
+	}
+	var created struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Draft   bool   `json:"draft"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/repos/"+g.repo+"/pulls", body, &created); err != nil {
+		return nil, err
+	}
+	return &PullRequest{Number: created.Number, Title: created.Title, URL: created.HTMLURL, Draft: created.Draft}, nil
+}
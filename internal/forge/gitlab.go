@@ -0,0 +1,155 @@
+package forge
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type gitlab struct {
+	apiURL string
+	token  string
+	// repo is the path of the project, such as group/subgroup/name.
+	repo string
+}
+
+func (g *gitlab) Name() string {
+	return "GitLab"
+}
+
+func (g *gitlab) Repository() string {
+	return g.repo
+}
+
+func (g *gitlab) PushAuthHeader() string {
+	if g.token == "" {
+		return ""
+	}
+	return "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("oauth2:"+g.token))
+}
+
+func (g *gitlab) do(ctx context.Context, method, path string, body, v any) error {
+	var headers map[string]string
+	if g.token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + g.token}
+	}
+	return doJSON(ctx, g.Name(), method, g.apiURL+"/projects/"+url.PathEscape(g.repo)+path, headers, body, v)
+}
+
+type gitlabIssue struct {
+	IID         int      `json:"iid"`
+	Title       string   `json:"title"`
+	State       string   `json:"state"`
+	WebURL      string   `json:"web_url"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels"`
+	Author      struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+func (i gitlabIssue) issue() Issue {
+	state := i.State
+	if state == "opened" {
+		state = "open"
+	}
+	return Issue{
+		Number: i.IID,
+		Title:  i.Title,
+		State:  state,
+		Author: i.Author.Username,
+		URL:    i.WebURL,
+		Labels: i.Labels,
+		Body:   i.Description,
+	}
+}
+
+func (g *gitlab) DefaultBranch(ctx context.Context) (string, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := g.do(ctx, http.MethodGet, "", nil, &project); err != nil {
+		return "", err
+	}
+	return project.DefaultBranch, nil
+}
+
+func (g *gitlab) Issue(ctx context.Context, number int) (*Issue, error) {
+	var i gitlabIssue
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/issues/%d", number), nil, &i); err != nil {
+		return nil, err
+	}
+	issue := i.issue()
+
+	var notes []struct {
+		Body      string    `json:"body"`
+		CreatedAt time.Time `json:"created_at"`
+		System    bool      `json:"system"`
+		Author    struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/issues/%d/notes?sort=asc&per_page=100", number), nil, &notes); err != nil {
+		return nil, err
+	}
+	for _, n := range notes {
+		// System notes are events, such as label changes.
+		if n.System {
+			continue
+		}
+		issue.Comments = append(issue.Comments, Comment{Author: n.Author.Username, Body: n.Body, CreatedAt: n.CreatedAt})
+	}
+	return &issue, nil
+}
+
+func (g *gitlab) Issues(ctx context.Context, query IssueQuery) ([]Issue, error) {
+	params := url.Values{}
+	switch query.State {
+	case "", "open":
+		params.Set("state", "opened")
+	case "closed":
+		params.Set("state", "closed")
+	}
+	if query.Search != "" {
+		params.Set("search", query.Search)
+	}
+	params.Set("per_page", fmt.Sprint(query.limit()))
+
+	var found []gitlabIssue
+	if err := g.do(ctx, http.MethodGet, "/issues?"+params.Encode(), nil, &found); err != nil {
+		return nil, err
+	}
+	issues := []Issue{}
+	for _, i := range found {
+		issue := i.issue()
+		issue.Body = ""
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+func (g *gitlab) CreatePullRequest(ctx context.Context, pr NewPullRequest) (*PullRequest, error) {
+	title := pr.Title
+	if pr.Draft {
+		title = "Draft: " + title
+	}
+	body := map[string]any{
+		"title":         title,
+		"description":   pr.Body,
+		"source_branch": pr.Head,
+		"target_branch": pr.Base,
+	}
+	var created struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		WebURL string `json:"web_url"`
+		Draft  bool   `json:"draft"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/merge_requests", body, &created); err != nil {
+		return nil, err
+	}
+	return &PullRequest{Number: created.IID, Title: created.Title, URL: created.WebURL, Draft: created.Draft}, nil
+}
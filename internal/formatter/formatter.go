@@ -0,0 +1,138 @@
+// Package formatter runs the formatter of their language on the files the
+// agent changed: the one of the config, or the first built-in one installed.
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+const (
+	timeout = 30 * time.Second
+	// maxOutput is the length of the output of a failed formatter kept for
+	// the model.
+	maxOutput = 2000
+)
+
+var (
+	goFormatters       = [][]string{{"goimports", "-w"}, {"gofmt", "-w"}}
+	prettierFormatters = [][]string{{"prettier", "--write", "--log-level", "warn"}}
+	blackFormatters    = [][]string{{"black", "--quiet"}}
+	rustFormatters     = [][]string{{"rustfmt"}}
+)
+
+// builtins are the formatters of the extensions, in order of preference.
+var builtins = map[string][][]string{
+	".go":   goFormatters,
+	".js":   prettierFormatters,
+	".jsx":  prettierFormatters,
+	".mjs":  prettierFormatters,
+	".cjs":  prettierFormatters,
+	".ts":   prettierFormatters,
+	".tsx":  prettierFormatters,
+	".json": prettierFormatters,
+	".css":  prettierFormatters,
+	".scss": prettierFormatters,
+	".html": prettierFormatters,
+	".vue":  prettierFormatters,
+	".py":   blackFormatters,
+	".pyi":  blackFormatters,
+	".rs":   rustFormatters,
+}
+
+// Result is the outcome of formatting a file.
+type Result struct {
+	File    string
+	Command string
+	// Changed is whether the formatter changed the file.
+	Changed bool
+	// Err is the output of the formatter when it failed.
+	Err error
+}
+
+// Command returns the formatter of the file, nil if it has none.
+func Command(cfg *config.Formatting, workingDir, file string) []string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if cfg != nil {
+		if command, ok := cfg.Formatters[ext]; ok {
+			return command
+		}
+	}
+	for _, command := range builtins[ext] {
+		if path, ok := lookPath(workingDir, command[0]); ok {
+			return append([]string{path}, command[1:]...)
+		}
+	}
+	return nil
+}
+
+// lookPath finds the executable in the node_modules of the project, where
+// prettier usually is, then in the PATH.
+func lookPath(workingDir, name string) (string, bool) {
+	local := filepath.Join(workingDir, "node_modules", ".bin", name)
+	if info, err := os.Stat(local); err == nil && !info.IsDir() {
+		return local, true
+	}
+	path, err := exec.LookPath(name)
+	return path, err == nil
+}
+
+// Format formats the files, skipping the ones without a formatter.
+func Format(ctx context.Context, cfg *config.Formatting, workingDir string, files []string) []Result {
+	var results []Result
+	for _, file := range files {
+		command := Command(cfg, workingDir, file)
+		if len(command) == 0 {
+			continue
+		}
+		results = append(results, run(ctx, workingDir, command, file))
+	}
+	return results
+}
+
+func run(ctx context.Context, workingDir string, command []string, file string) Result {
+	result := Result{File: file, Command: filepath.Base(command[0])}
+	before, err := os.ReadFile(file)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], append(command[1:], file)...)
+	cmd.Dir = workingDir
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		text := strings.TrimSpace(output.String())
+		if ctx.Err() != nil {
+			text = fmt.Sprintf("timed out after %s", timeout)
+		}
+		if text == "" {
+			text = err.Error()
+		}
+		if len(text) > maxOutput {
+			text = text[:maxOutput] + "\n... (truncated)"
+		}
+		result.Err = errors.New(text)
+		return result
+	}
+
+	after, err := os.ReadFile(file)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Changed = !bytes.Equal(before, after)
+	return result
+}
@@ -0,0 +1,74 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript writes an executable shell script in the directory.
+func writeScript(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+	return path
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	prettier := writeScript(t, filepath.Join(dir, "node_modules", ".bin"), "prettier", "exit 0\n")
+	cfg := &config.Formatting{Formatters: map[string][]string{
+		".py": {"ruff", "format"},
+		".rs": {},
+	}}
+
+	require.Equal(t, []string{"ruff", "format"}, Command(cfg, dir, "app/main.py"))
+	require.Empty(t, Command(cfg, dir, "src/main.rs"))
+	require.Nil(t, Command(cfg, dir, "notes.txt"))
+	require.Equal(t, []string{prettier, "--write", "--log-level", "warn"}, Command(nil, dir, "web/App.TSX"))
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	upper := writeScript(t, dir, "upper.sh", `tr a-z A-Z < "$1" > "$1.tmp" && mv "$1.tmp" "$1"`+"\n")
+	broken := writeScript(t, dir, "broken.sh", `echo "$1:1:1: expected ';'" >&2; exit 2`+"\n")
+	cfg := &config.Formatting{Formatters: map[string][]string{
+		".up":  {upper},
+		".bad": {broken},
+		".ok":  {"true"},
+	}}
+	files := map[string]string{"a.up": "hello\n", "b.bad": "x", "c.ok": "same\n", "d.txt": "skipped"}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		paths = append(paths, path)
+	}
+
+	results := Format(t.Context(), cfg, dir, paths)
+	require.Len(t, results, 3)
+	for _, result := range results {
+		switch filepath.Base(result.File) {
+		case "a.up":
+			require.NoError(t, result.Err)
+			require.True(t, result.Changed)
+			data, err := os.ReadFile(result.File)
+			require.NoError(t, err)
+			require.Equal(t, "HELLO\n", string(data))
+		case "b.bad":
+			require.EqualError(t, result.Err, result.File+":1:1: expected ';'")
+			require.Equal(t, "broken.sh", result.Command)
+		case "c.ok":
+			require.NoError(t, result.Err)
+			require.False(t, result.Changed)
+		}
+	}
+}
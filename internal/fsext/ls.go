@@ -3,6 +3,7 @@ package fsext
 import (
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/charlievieth/fastwalk"
 	ignore "github.com/sabhiram/go-gitignore"
@@ -134,9 +135,18 @@ func (dl *DirectoryLister) shouldIgnore(path string, ignorePatterns []string) bo
 
 // ListDirectory lists files and directories in the specified path,
 func ListDirectory(initialPath string, ignorePatterns []string, limit int) ([]string, bool, error) {
+	// Watched directories are listed without walking them.
+	if w := Watched(initialPath); w != nil {
+		if results, truncated, ok := w.list(initialPath, ignorePatterns, limit); ok {
+			return results, truncated, nil
+		}
+	}
+
 	var results []string
 	truncated := false
 	dl := NewDirectoryLister(initialPath)
+	// The walk function is called concurrently.
+	var mu sync.Mutex
 
 	conf := fastwalk.Config{
 		Follow: true,
@@ -156,6 +166,13 @@ func ListDirectory(initialPath string, ignorePatterns []string, limit int) ([]st
 			return nil
 		}
 
+		mu.Lock()
+		defer mu.Unlock()
+		if limit > 0 && len(results) >= limit {
+			truncated = true
+			return filepath.SkipAll
+		}
+
 		if path != initialPath {
 			if d.IsDir() {
 				path = path + string(filepath.Separator)
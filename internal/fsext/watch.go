@@ -0,0 +1,400 @@
+package fsext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charlievieth/fastwalk"
+	"github.com/fsnotify/fsnotify"
+)
+
+// maxWatchedEntries bounds the files and directories a watcher keeps, past
+// which ListDirectory walks the directories again.
+const maxWatchedEntries = 200_000
+
+var errTooManyEntries = fmt.Errorf("more than %d files and directories", maxWatchedEntries)
+
+// Watcher keeps the list of the files and directories under a root up to
+// date by watching the file system, for ListDirectory not to walk the root
+// on every call. The ones ignored by CommonIgnorePatterns, and the
+// .gitignore and .crushignore of the root, are left out.
+type Watcher struct {
+	root    string
+	watcher *fsnotify.Watcher
+
+	mu      sync.RWMutex
+	lister  *DirectoryLister
+	entries map[string]watchedEntry
+	removed map[string]uint64
+	version uint64
+	// rescanned is the version of the last full scan, the changes before it
+	// aren't known.
+	rescanned uint64
+	ready     bool
+}
+
+// watchedEntry is a file or directory, by its path relative to the root.
+type watchedEntry struct {
+	dir bool
+	// version is the version of the watcher it was created or modified at.
+	version uint64
+}
+
+var (
+	watchersMu sync.RWMutex
+	watchers   []*Watcher
+)
+
+// Watch lists the files and directories under root in the background and
+// keeps the list up to date until ctx is done.
+func Watch(ctx context.Context, root string) (*Watcher, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w := &Watcher{root: root, watcher: fw}
+
+	watchersMu.Lock()
+	watchers = append(watchers, w)
+	watchersMu.Unlock()
+
+	go w.run(ctx)
+	return w, nil
+}
+
+// Watched returns the watcher of the root containing path, or nil.
+func Watched(path string) *Watcher {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	watchersMu.RLock()
+	defer watchersMu.RUnlock()
+	for _, w := range watchers {
+		if HasPrefix(path, w.root) {
+			return w
+		}
+	}
+	return nil
+}
+
+// Changes returns the files created or modified, and the ones removed, since
+// the version, with the current version. Paths are relative to the root,
+// with forward slashes. ok is false when the changes aren't known, before
+// the files are listed or after events were missed, and any file may have
+// changed.
+func (w *Watcher) Changes(since uint64) (changed, removed []string, version uint64, ok bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.ready || since < w.rescanned {
+		return nil, nil, w.version, false
+	}
+	for path, e := range w.entries {
+		if !e.dir && e.version > since {
+			changed = append(changed, filepath.ToSlash(path))
+		}
+	}
+	for path, v := range w.removed {
+		if v > since {
+			removed = append(removed, filepath.ToSlash(path))
+		}
+	}
+	slices.Sort(changed)
+	slices.Sort(removed)
+	return changed, removed, w.version, true
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer w.stop()
+
+	start := time.Now()
+	if err := w.scan(); err != nil {
+		slog.Warn("Not watching the project files, they will be listed on every use", "root", w.root, "error", err)
+		return
+	}
+	slog.Debug("Watching the project files", "root", w.root, "took", time.Since(start))
+
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if err := w.handle(event); err != nil {
+				slog.Warn("Stopped watching the project files", "root", w.root, "error", err)
+				return
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				slog.Error("File watcher error", "error", err)
+				continue
+			}
+			// Events were dropped, list the files again.
+			slog.Debug("File watcher overflowed, listing the project files again", "root", w.root)
+			if err := w.scan(); err != nil {
+				slog.Warn("Stopped watching the project files", "root", w.root, "error", err)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *Watcher) stop() {
+	watchersMu.Lock()
+	watchers = slices.DeleteFunc(watchers, func(other *Watcher) bool { return other == w })
+	watchersMu.Unlock()
+
+	w.watcher.Close()
+	w.mu.Lock()
+	w.ready = false
+	w.entries, w.removed = nil, nil
+	w.mu.Unlock()
+}
+
+// scan lists the files and directories under the root again and watches
+// the directories.
+func (w *Watcher) scan() error {
+	lister := NewDirectoryLister(w.root)
+	entries := make(map[string]watchedEntry)
+	if err := w.walk(lister, w.root, entries); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.version++
+	for path, e := range entries {
+		e.version = w.version
+		entries[path] = e
+	}
+	w.lister = lister
+	w.entries = entries
+	w.removed = make(map[string]uint64)
+	w.rescanned = w.version
+	w.ready = true
+	return nil
+}
+
+// walk adds the entries under dir, except the ignored ones, and watches the
+// directories.
+func (w *Watcher) walk(lister *DirectoryLister, dir string, entries map[string]watchedEntry) error {
+	if err := w.watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	var mu sync.Mutex
+	var walkErr error
+	conf := fastwalk.Config{Follow: true}
+	err := fastwalk.Walk(&conf, dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return nil
+		}
+		if lister.shouldIgnore(path, nil) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		isDir := d.IsDir()
+		if d.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			isDir = err == nil && info.IsDir()
+		}
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if isDir {
+			if err := w.watcher.Add(path); err != nil {
+				walkErr = fmt.Errorf("failed to watch %s: %w", path, err)
+				return filepath.SkipAll
+			}
+		}
+		if len(entries) >= maxWatchedEntries {
+			walkErr = errTooManyEntries
+			return filepath.SkipAll
+		}
+		entries[rel] = watchedEntry{dir: isDir}
+		return nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	return err
+}
+
+// handle applies the event to the entries. It returns an error when the
+// entries can't be kept up to date anymore.
+func (w *Watcher) handle(event fsnotify.Event) error {
+	if event.Op == fsnotify.Chmod {
+		return nil
+	}
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	if rel == ".gitignore" || rel == ".crushignore" {
+		// The ignored files changed.
+		return w.scan()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Directories ignored since they were watched are still watched.
+	if parent := filepath.Dir(rel); parent != "." && !w.entries[parent].dir {
+		return nil
+	}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		w.remove(rel)
+		return nil
+	}
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		w.remove(rel)
+		return nil
+	}
+	if w.lister.shouldIgnore(event.Name, nil) {
+		return nil
+	}
+	w.version++
+	if !info.IsDir() {
+		w.entries[rel] = watchedEntry{version: w.version}
+		delete(w.removed, rel)
+		return nil
+	}
+	if _, ok := w.entries[rel]; ok {
+		return nil
+	}
+	// Files may have been created in the directory before it was watched,
+	// such as by a checkout or a copy.
+	entries := map[string]watchedEntry{rel: {dir: true}}
+	if err := w.walk(w.lister, event.Name, entries); err != nil {
+		return err
+	}
+	if len(w.entries)+len(entries) > maxWatchedEntries {
+		return errTooManyEntries
+	}
+	for path, e := range entries {
+		e.version = w.version
+		w.entries[path] = e
+		delete(w.removed, path)
+	}
+	return nil
+}
+
+// remove removes the entry and, for a directory, the entries under it.
+func (w *Watcher) remove(rel string) {
+	e, ok := w.entries[rel]
+	if !ok {
+		return
+	}
+	w.version++
+	delete(w.entries, rel)
+	if !e.dir {
+		w.removed[rel] = w.version
+		return
+	}
+	// The watches of a moved directory follow it, drop them.
+	_ = w.watcher.Remove(filepath.Join(w.root, rel))
+	prefix := rel + string(filepath.Separator)
+	for path, e := range w.entries {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		delete(w.entries, path)
+		if e.dir {
+			_ = w.watcher.Remove(filepath.Join(w.root, path))
+		} else {
+			w.removed[path] = w.version
+		}
+	}
+}
+
+// list lists the entries under path like ListDirectory. It returns false if
+// they aren't known, such as before the first scan or for ignored
+// directories.
+func (w *Watcher) list(path string, ignorePatterns []string, limit int) ([]string, bool, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false, false
+	}
+	rel, err := filepath.Rel(w.root, abs)
+	if err != nil {
+		return nil, false, false
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.ready {
+		return nil, false, false
+	}
+	prefix := ""
+	if rel != "." {
+		if !w.entries[rel].dir {
+			return nil, false, false
+		}
+		prefix = rel + string(filepath.Separator)
+	}
+
+	var paths []string
+	for entry := range w.entries {
+		if !strings.HasPrefix(entry, prefix) || matchesAny(entry[len(prefix):], ignorePatterns) {
+			continue
+		}
+		paths = append(paths, entry)
+	}
+	slices.Sort(paths)
+	truncated := false
+	if limit > 0 && len(paths) >= limit {
+		truncated = true
+		paths = paths[:limit]
+	}
+
+	base := path
+	if !strings.HasSuffix(base, string(filepath.Separator)) {
+		base += string(filepath.Separator)
+	}
+	results := make([]string, len(paths))
+	for i, entry := range paths {
+		results[i] = base + entry[len(prefix):]
+		if w.entries[entry].dir {
+			results[i] += string(filepath.Separator)
+		}
+	}
+	return results, truncated, true
+}
+
+// matchesAny reports whether an element of the relative path matches one of
+// the patterns.
+func matchesAny(rel string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	for name := range strings.SplitSeq(rel, string(filepath.Separator)) {
+		for _, pattern := range patterns {
+			if matched, err := filepath.Match(pattern, name); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
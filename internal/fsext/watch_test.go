@@ -0,0 +1,105 @@
+package fsext
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, filepath.FromSlash(path))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write(".gitignore", "*.log\n")
+	write("main.go", "package main")
+	write("old.go", "package main")
+	write("pkg/util.go", "package pkg")
+	write("pkg/debug.log", "")
+	write("node_modules/dep/index.js", "")
+
+	w, err := Watch(t.Context(), root)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		return w.ready
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Same(t, w, Watched(filepath.Join(root, "pkg")))
+	require.Nil(t, Watched(t.TempDir()))
+
+	list := func(path string, ignore []string, limit int) []string {
+		t.Helper()
+		results, _, err := ListDirectory(path, ignore, limit)
+		require.NoError(t, err)
+		for i, result := range results {
+			results[i] = filepath.ToSlash(result)
+		}
+		return results
+	}
+	slashRoot := filepath.ToSlash(root)
+	require.Equal(t, []string{
+		slashRoot + "/.gitignore",
+		slashRoot + "/main.go",
+		slashRoot + "/old.go",
+		slashRoot + "/pkg/",
+		slashRoot + "/pkg/util.go",
+	}, list(root, nil, 0))
+	require.Equal(t, []string{slashRoot + "/pkg/util.go"}, list(filepath.Join(root, "pkg"), nil, 0))
+	require.Equal(t, []string{slashRoot + "/.gitignore", slashRoot + "/main.go", slashRoot + "/old.go"}, list(root, []string{"pkg"}, 0))
+	results, truncated, err := ListDirectory(root, nil, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.True(t, truncated)
+	// Ignored directories are walked.
+	require.Equal(t, []string{slashRoot + "/node_modules/dep/index.js"}, list(filepath.Join(root, "node_modules", "dep"), nil, 0))
+
+	_, _, version, ok := w.Changes(w.rescanned)
+	require.True(t, ok)
+	_, _, _, ok = w.Changes(0)
+	require.False(t, ok)
+
+	write("main.go", "package main\n\nfunc main() {}")
+	write("cmd/tool/main.go", "package main")
+	write("trace.log", "")
+	require.NoError(t, os.Remove(filepath.Join(root, "old.go")))
+	require.Eventually(t, func() bool {
+		changed, removed, _, ok := w.Changes(version)
+		return ok &&
+			slices.Equal(changed, []string{"cmd/tool/main.go", "main.go"}) &&
+			slices.Equal(removed, []string{"old.go"})
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Moved directories are listed under their new name.
+	require.NoError(t, os.Rename(filepath.Join(root, "cmd"), filepath.Join(root, "tools")))
+	require.Eventually(t, func() bool {
+		return slices.Equal(list(root, nil, 0), []string{
+			slashRoot + "/.gitignore",
+			slashRoot + "/main.go",
+			slashRoot + "/pkg/",
+			slashRoot + "/pkg/util.go",
+			slashRoot + "/tools/",
+			slashRoot + "/tools/tool/",
+			slashRoot + "/tools/tool/main.go",
+		})
+	}, 5*time.Second, 10*time.Millisecond)
+	write("tools/tool/flags.go", "package main")
+	require.Eventually(t, func() bool {
+		return slices.Contains(list(root, nil, 0), slashRoot+"/tools/tool/flags.go")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Changing the ignored files lists the files again.
+	_, _, version, _ = w.Changes(version)
+	write(".gitignore", "*.log\ntools\n")
+	require.Eventually(t, func() bool {
+		_, _, _, ok := w.Changes(version)
+		return !ok && !slices.Contains(list(root, nil, 0), slashRoot+"/tools/")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,506 @@
+// Package git runs the git CLI for the git tool and crush commit, and parses
+// its output into structured results.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Repo is a git repository, or a directory inside one.
+type Repo struct {
+	dir string
+}
+
+// Open returns the repository of the directory, or an error if it isn't
+// inside one or git isn't installed.
+func Open(ctx context.Context, dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found: %w", err)
+	}
+	r := &Repo{dir: dir}
+	if _, err := r.run(ctx, "rev-parse", "--git-dir"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FileChange is a change of a file in the index or the working tree.
+type FileChange struct {
+	Path string `json:"path"`
+	// OrigPath is the path of a renamed or copied file before the change.
+	OrigPath string `json:"orig_path,omitempty"`
+	Status   string `json:"status"`
+}
+
+// Status is the state of the working tree and its branch.
+type Status struct {
+	// Branch is empty when the HEAD is detached.
+	Branch   string `json:"branch,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+	Ahead    int    `json:"ahead,omitempty"`
+	Behind   int    `json:"behind,omitempty"`
+
+	Staged     []FileChange `json:"staged,omitempty"`
+	Unstaged   []FileChange `json:"unstaged,omitempty"`
+	Untracked  []string     `json:"untracked,omitempty"`
+	Conflicted []string     `json:"conflicted,omitempty"`
+}
+
+// Clean reports whether the working tree has no changes.
+func (s *Status) Clean() bool {
+	return len(s.Staged) == 0 && len(s.Unstaged) == 0 && len(s.Untracked) == 0 && len(s.Conflicted) == 0
+}
+
+// Status returns the status of the working tree.
+func (r *Repo) Status(ctx context.Context) (*Status, error) {
+	output, err := r.run(ctx, "status", "--porcelain=v2", "--branch", "--untracked-files=all", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(output), nil
+}
+
+// parseStatus parses the output of git status --porcelain=v2 --branch -z.
+func parseStatus(output string) *Status {
+	status := &Status{}
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+		kind, rest, _ := strings.Cut(entry, " ")
+		switch kind {
+		case "#":
+			header, value, _ := strings.Cut(rest, " ")
+			switch header {
+			case "branch.oid":
+				if value != "(initial)" {
+					status.Commit = value
+				}
+			case "branch.head":
+				if value != "(detached)" {
+					status.Branch = value
+				}
+			case "branch.upstream":
+				status.Upstream = value
+			case "branch.ab":
+				ahead, behind, _ := strings.Cut(value, " ")
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(ahead, "+"))
+				status.Behind, _ = strconv.Atoi(strings.TrimPrefix(behind, "-"))
+			}
+		case "1", "2":
+			// XY sub mH mI mW hH hI [score] path, with the original path
+			// of renames and copies in the next entry.
+			fields := strings.SplitN(rest, " ", 8)
+			if kind == "2" {
+				fields = strings.SplitN(rest, " ", 9)
+			}
+			if len(fields) < 8 {
+				continue
+			}
+			change := FileChange{Path: fields[len(fields)-1]}
+			if kind == "2" && i+1 < len(entries) {
+				i++
+				change.OrigPath = entries[i]
+			}
+			if x := fields[0][0]; x != '.' {
+				staged := change
+				staged.Status = changeStatus(x)
+				status.Staged = append(status.Staged, staged)
+			}
+			if y := fields[0][1]; y != '.' {
+				unstaged := change
+				unstaged.Status = changeStatus(y)
+				unstaged.OrigPath = ""
+				status.Unstaged = append(status.Unstaged, unstaged)
+			}
+		case "u":
+			fields := strings.SplitN(rest, " ", 10)
+			status.Conflicted = append(status.Conflicted, fields[len(fields)-1])
+		case "?":
+			status.Untracked = append(status.Untracked, rest)
+		}
+	}
+	return status
+}
+
+func changeStatus(code byte) string {
+	switch code {
+	case 'M':
+		return "modified"
+	case 'T':
+		return "type changed"
+	case 'A':
+		return "added"
+	case 'D':
+		return "deleted"
+	case 'R':
+		return "renamed"
+	case 'C':
+		return "copied"
+	default:
+		return string(code)
+	}
+}
+
+// DiffOptions selects the changes of a diff.
+type DiffOptions struct {
+	// Staged diffs the index with the HEAD rather than the working tree
+	// with the index.
+	Staged bool
+	// Ref diffs the working tree, or the index if Staged, with a commit or
+	// a range such as main...HEAD.
+	Ref   string
+	Paths []string
+}
+
+// DiffFile is a changed file of a diff, with its numbers of lines added and
+// deleted.
+type DiffFile struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Deleted int    `json:"deleted"`
+	Binary  bool   `json:"binary,omitempty"`
+}
+
+// Diff is the changed files of a diff, and its patch.
+type Diff struct {
+	Files []DiffFile `json:"files"`
+	Patch string     `json:"patch,omitempty"`
+}
+
+// Diff returns the changes selected by the options.
+func (r *Repo) Diff(ctx context.Context, opts DiffOptions) (*Diff, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff"}
+	if opts.Staged {
+		args = append(args, "--cached")
+	}
+	if opts.Ref != "" {
+		if strings.HasPrefix(opts.Ref, "-") {
+			return nil, fmt.Errorf("invalid ref %q", opts.Ref)
+		}
+		args = append(args, opts.Ref)
+	}
+	paths := append([]string{"--"}, opts.Paths...)
+
+	numstat, err := r.run(ctx, append(append(args, "--numstat", "-z"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := r.run(ctx, append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Diff{Files: parseNumstat(numstat), Patch: patch}, nil
+}
+
+// parseNumstat parses the output of git diff --numstat -z.
+func parseNumstat(output string) []DiffFile {
+	files := []DiffFile{}
+	entries := strings.Split(output, "\x00")
+	for i := 0; i < len(entries); i++ {
+		fields := strings.SplitN(entries[i], "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		file := DiffFile{Path: fields[2]}
+		if file.Path == "" && i+2 < len(entries) {
+			// Renames are followed by their original and new paths.
+			file.Path = entries[i+2]
+			i += 2
+		}
+		if fields[0] == "-" {
+			file.Binary = true
+		} else {
+			file.Added, _ = strconv.Atoi(fields[0])
+			file.Deleted, _ = strconv.Atoi(fields[1])
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// Stage adds the changes of the paths to the index, the removals included.
+func (r *Repo) Stage(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("no paths to stage")
+	}
+	_, err := r.run(ctx, append([]string{"add", "--all", "--"}, paths...)...)
+	return err
+}
+
+// Unstage removes the changes of the paths from the index, keeping them in
+// the working tree.
+func (r *Repo) Unstage(ctx context.Context, paths []string) error {
+	if len(paths) == 0 {
+		return errors.New("no paths to unstage")
+	}
+	_, err := r.run(ctx, append([]string{"reset", "--quiet", "--"}, paths...)...)
+	return err
+}
+
+// Commit is a commit, as created by Repo.Commit.
+type Commit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+	Body    string `json:"body,omitempty"`
+}
+
+// CommitOptions changes how Repo.Commit commits.
+type CommitOptions struct {
+	// All stages the changes of the tracked files first.
+	All bool
+	// Edit opens the editor of git on the terminal to edit the message.
+	Edit bool
+}
+
+// Commit commits the staged changes with the message.
+func (r *Repo) Commit(ctx context.Context, message string, opts CommitOptions) (*Commit, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, errors.New("the commit message is empty")
+	}
+	file, err := os.CreateTemp("", "crush-commit-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the commit message: %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(message)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the commit message: %w", err)
+	}
+
+	args := []string{"commit", "--file", file.Name()}
+	if opts.All {
+		args = append(args, "--all")
+	}
+	if opts.Edit {
+		args = append(args, "--edit")
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = r.dir
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("git commit failed: %w", err)
+		}
+	} else if _, err := r.run(ctx, args...); err != nil {
+		return nil, err
+	}
+
+	commits, err := r.log(ctx, "-1", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, errors.New("the commit wasn't created")
+	}
+	return &commits[0], nil
+}
+
+// Log lists the commits of the range, such as origin/main..HEAD, the oldest
+// first.
+func (r *Repo) Log(ctx context.Context, revRange string) ([]Commit, error) {
+	if revRange == "" || strings.HasPrefix(revRange, "-") {
+		return nil, fmt.Errorf("invalid range %q", revRange)
+	}
+	return r.log(ctx, "--reverse", revRange)
+}
+
+func (r *Repo) log(ctx context.Context, args ...string) ([]Commit, error) {
+	output, err := r.run(ctx, append([]string{"log", "--format=%H%x00%s%x00%b%x1e"}, append(args, "--")...)...)
+	if err != nil {
+		return nil, err
+	}
+	commits := []Commit{}
+	for record := range strings.SplitSeq(output, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x00", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Subject: fields[1],
+			Body:    strings.TrimSpace(fields[2]),
+		})
+	}
+	return commits, nil
+}
+
+// RemoteURL returns the URL of the remote.
+func (r *Repo) RemoteURL(ctx context.Context, remote string) (string, error) {
+	output, err := r.run(ctx, "remote", "get-url", "--", remote)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// PushOptions changes how Repo.Push pushes.
+type PushOptions struct {
+	// AuthHeader is sent with the HTTPS requests of the push, such as the
+	// authorization of a token. It's passed in the environment rather than
+	// the command line, visible to other users.
+	AuthHeader string
+}
+
+// Push pushes the branch to the remote, and sets it as the upstream of the
+// branch.
+func (r *Repo) Push(ctx context.Context, remote, branch string, opts PushOptions) error {
+	if strings.HasPrefix(remote, "-") || branch == "" || strings.HasPrefix(branch, "-") {
+		return fmt.Errorf("invalid remote %q or branch %q", remote, branch)
+	}
+	var env []string
+	if opts.AuthHeader != "" {
+		env = []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=" + opts.AuthHeader,
+		}
+	}
+	_, err := r.runEnv(ctx, env, "push", "--set-upstream", remote, branch)
+	return err
+}
+
+// Branch is a local branch.
+type Branch struct {
+	Name     string `json:"name"`
+	Current  bool   `json:"current,omitempty"`
+	Commit   string `json:"commit"`
+	Upstream string `json:"upstream,omitempty"`
+	Subject  string `json:"subject"`
+}
+
+// Branches lists the local branches.
+func (r *Repo) Branches(ctx context.Context) ([]Branch, error) {
+	output, err := r.run(ctx, "for-each-ref", "--format=%(HEAD)%00%(refname:short)%00%(objectname:short)%00%(upstream:short)%00%(subject)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	branches := []Branch{}
+	for line := range strings.SplitSeq(strings.TrimSpace(output), "\n") {
+		fields := strings.SplitN(line, "\x00", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		branches = append(branches, Branch{
+			Name:     fields[1],
+			Current:  fields[0] == "*",
+			Commit:   fields[2],
+			Upstream: fields[3],
+			Subject:  fields[4],
+		})
+	}
+	return branches, nil
+}
+
+// Switch switches to the branch, creating it from the HEAD first if create
+// is set.
+func (r *Repo) Switch(ctx context.Context, branch string, create bool) error {
+	if branch == "" || strings.HasPrefix(branch, "-") {
+		return fmt.Errorf("invalid branch %q", branch)
+	}
+	args := []string{"switch"}
+	if create {
+		args = append(args, "--create")
+	}
+	_, err := r.run(ctx, append(args, branch)...)
+	return err
+}
+
+// Stash is an entry of the stash.
+type Stash struct {
+	Ref     string `json:"ref"`
+	Message string `json:"message"`
+}
+
+// Stashes lists the entries of the stash, the latest first.
+func (r *Repo) Stashes(ctx context.Context) ([]Stash, error) {
+	output, err := r.run(ctx, "stash", "list", "--format=%gd%x00%gs")
+	if err != nil {
+		return nil, err
+	}
+	stashes := []Stash{}
+	for line := range strings.SplitSeq(strings.TrimSpace(output), "\n") {
+		ref, message, ok := strings.Cut(line, "\x00")
+		if !ok {
+			continue
+		}
+		stashes = append(stashes, Stash{Ref: ref, Message: message})
+	}
+	return stashes, nil
+}
+
+// StashPush stashes the changes of the working tree and the index, and the
+// untracked files too if untracked is set.
+func (r *Repo) StashPush(ctx context.Context, message string, untracked bool) error {
+	args := []string{"stash", "push"}
+	if message != "" {
+		args = append(args, "--message", message)
+	}
+	if untracked {
+		args = append(args, "--include-untracked")
+	}
+	output, err := r.run(ctx, args...)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(output, "No local changes to save") {
+		return errors.New("no local changes to stash")
+	}
+	return nil
+}
+
+// StashPop applies the entry of the stash, the latest one if ref is empty,
+// and drops it.
+func (r *Repo) StashPop(ctx context.Context, ref string) error {
+	args := []string{"stash", "pop"}
+	if ref != "" {
+		if !strings.HasPrefix(ref, "stash@{") {
+			return fmt.Errorf("invalid stash %q, expected a ref such as stash@{0}", ref)
+		}
+		args = append(args, ref)
+	}
+	_, err := r.run(ctx, args...)
+	return err
+}
+
+// run runs git in the directory and returns its output, or its error
+// output as the error if it fails.
+func (r *Repo) run(ctx context.Context, args ...string) (string, error) {
+	return r.runEnv(ctx, nil, args...)
+}
+
+// runEnv runs git like run, with the variables added to its environment.
+func (r *Repo) runEnv(ctx context.Context, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "core.quotepath=off"}, args...)...)
+	cmd.Dir = r.dir
+	// Never wait for credentials or an editor.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true")
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		if msg := strings.TrimSpace(stdout.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,223 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newRepo returns a repository with a first commit of a.txt and b.txt.
+func newRepo(t *testing.T) (*Repo, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, ".gitconfig"))
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	write(t, dir, "a.txt", "a\n")
+	write(t, dir, "b.txt", "b\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "Initial commit")
+
+	repo, err := Open(t.Context(), dir)
+	require.NoError(t, err)
+	return repo, dir
+}
+
+func write(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	_, err := Open(t.Context(), t.TempDir())
+	require.ErrorContains(t, err, "not a git repository")
+}
+
+func TestStatusAndDiff(t *testing.T) {
+	repo, dir := newRepo(t)
+	status, err := repo.Status(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "main", status.Branch)
+	require.NotEmpty(t, status.Commit)
+	require.True(t, status.Clean())
+
+	write(t, dir, "a.txt", "a\nmore\n")
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.txt")))
+	write(t, dir, "new dir/c.txt", "c\n")
+	write(t, dir, "d.txt", "d\n")
+	require.NoError(t, repo.Stage(t.Context(), []string{"d.txt", "b.txt"}))
+	write(t, dir, "d.txt", "d\nchanged\n")
+
+	status, err = repo.Status(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []FileChange{
+		{Path: "b.txt", Status: "deleted"},
+		{Path: "d.txt", Status: "added"},
+	}, status.Staged)
+	require.Equal(t, []FileChange{
+		{Path: "a.txt", Status: "modified"},
+		{Path: "d.txt", Status: "modified"},
+	}, status.Unstaged)
+	require.Equal(t, []string{"new dir/c.txt"}, status.Untracked)
+
+	diff, err := repo.Diff(t.Context(), DiffOptions{Staged: true})
+	require.NoError(t, err)
+	require.Equal(t, []DiffFile{
+		{Path: "b.txt", Deleted: 1},
+		{Path: "d.txt", Added: 1},
+	}, diff.Files)
+	require.Contains(t, diff.Patch, "+d\n")
+
+	diff, err = repo.Diff(t.Context(), DiffOptions{Paths: []string{"a.txt"}})
+	require.NoError(t, err)
+	require.Equal(t, []DiffFile{{Path: "a.txt", Added: 1}}, diff.Files)
+	require.Contains(t, diff.Patch, "+more\n")
+
+	require.NoError(t, repo.Unstage(t.Context(), []string{"d.txt"}))
+	status, err = repo.Status(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []FileChange{{Path: "b.txt", Status: "deleted"}}, status.Staged)
+	require.Contains(t, status.Untracked, "d.txt")
+
+	_, err = repo.Diff(t.Context(), DiffOptions{Ref: "--output=/tmp/x"})
+	require.Error(t, err)
+}
+
+func TestRename(t *testing.T) {
+	repo, dir := newRepo(t)
+	require.NoError(t, os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "renamed.txt")))
+	require.NoError(t, repo.Stage(t.Context(), []string{"."}))
+
+	status, err := repo.Status(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []FileChange{{Path: "renamed.txt", OrigPath: "a.txt", Status: "renamed"}}, status.Staged)
+
+	diff, err := repo.Diff(t.Context(), DiffOptions{Staged: true})
+	require.NoError(t, err)
+	require.Equal(t, []DiffFile{{Path: "renamed.txt"}}, diff.Files)
+}
+
+func TestCommitBranchesAndStash(t *testing.T) {
+	repo, dir := newRepo(t)
+
+	_, err := repo.Commit(t.Context(), "  ", CommitOptions{})
+	require.EqualError(t, err, "the commit message is empty")
+
+	write(t, dir, "a.txt", "changed\n")
+	commit, err := repo.Commit(t.Context(), "feat: change a\n\nWith a body.\n", CommitOptions{All: true})
+	require.NoError(t, err)
+	require.Equal(t, "feat: change a", commit.Subject)
+	require.Len(t, commit.Hash, 40)
+
+	require.NoError(t, repo.Switch(t.Context(), "feature", true))
+	branches, err := repo.Branches(t.Context())
+	require.NoError(t, err)
+	require.Len(t, branches, 2)
+	require.Equal(t, "feature", branches[0].Name)
+	require.True(t, branches[0].Current)
+	require.Equal(t, "feat: change a", branches[0].Subject)
+	require.False(t, branches[1].Current)
+	require.Error(t, repo.Switch(t.Context(), "--orphan", false))
+
+	require.EqualError(t, repo.StashPush(t.Context(), "", false), "no local changes to stash")
+	write(t, dir, "b.txt", "stashed\n")
+	write(t, dir, "untracked.txt", "u\n")
+	require.NoError(t, repo.StashPush(t.Context(), "wip", true))
+	status, err := repo.Status(t.Context())
+	require.NoError(t, err)
+	require.True(t, status.Clean())
+	stashes, err := repo.Stashes(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, []Stash{{Ref: "stash@{0}", Message: "On feature: wip"}}, stashes)
+
+	require.NoError(t, repo.StashPop(t.Context(), ""))
+	content, err := os.ReadFile(filepath.Join(dir, "b.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "stashed\n", string(content))
+	require.FileExists(t, filepath.Join(dir, "untracked.txt"))
+	require.Error(t, repo.StashPop(t.Context(), "HEAD"))
+}
+
+func TestParseStatus(t *testing.T) {
+	output := strings.Join([]string{
+		"# branch.oid 1234567",
+		"# branch.head main",
+		"# branch.upstream origin/main",
+		"# branch.ab +2 -1",
+		"u UU N... 100644 100644 100644 100644 a b c conflict.txt",
+		"",
+	}, "\x00")
+	require.Equal(t, &Status{
+		Branch:     "main",
+		Commit:     "1234567",
+		Upstream:   "origin/main",
+		Ahead:      2,
+		Behind:     1,
+		Conflicted: []string{"conflict.txt"},
+	}, parseStatus(output))
+}
+
+func TestPushAndLog(t *testing.T) {
+	repo, dir := newRepo(t)
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	cmd := exec.Command("git", "init", "--quiet", "--bare", remote)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	cmd = exec.Command("git", "remote", "add", "origin", remote)
+	cmd.Dir = dir
+	output, err = cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	url, err := repo.RemoteURL(t.Context(), "origin")
+	require.NoError(t, err)
+	require.Equal(t, remote, url)
+	_, err = repo.RemoteURL(t.Context(), "upstream")
+	require.Error(t, err)
+
+	require.NoError(t, repo.Push(t.Context(), "origin", "main", PushOptions{}))
+	require.NoError(t, repo.Switch(t.Context(), "fix", true))
+	write(t, dir, "a.txt", "fixed\n")
+	_, err = repo.Commit(t.Context(), "fix: a\n\nIt was broken.", CommitOptions{All: true})
+	require.NoError(t, err)
+	write(t, dir, "b.txt", "fixed\n")
+	_, err = repo.Commit(t.Context(), "fix: b", CommitOptions{All: true})
+	require.NoError(t, err)
+	require.NoError(t, repo.Push(t.Context(), "origin", "fix", PushOptions{AuthHeader: "Authorization: Basic eDp5"}))
+
+	status, err := repo.Status(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "origin/fix", status.Upstream)
+
+	commits, err := repo.Log(t.Context(), "origin/main..HEAD")
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	require.Equal(t, "fix: a", commits[0].Subject)
+	require.Equal(t, "It was broken.", commits[0].Body)
+	require.Equal(t, "fix: b", commits[1].Subject)
+	require.Empty(t, commits[1].Body)
+
+	require.Error(t, repo.Push(t.Context(), "origin", "--force", PushOptions{}))
+	_, err = repo.Log(t.Context(), "--all")
+	require.Error(t, err)
+}
@@ -0,0 +1,221 @@
+// Package glossary checks prose against the project glossary, the preferred
+// spellings of product names and acronyms kept in the project memory file.
+//
+// The glossary is the list under a "Glossary" heading of a context file such
+// as CRUSH.md:
+//
+//	## Glossary
+//
+//	- GitHub (not Github, Git Hub)
+//	- MCP: Model Context Protocol
+//
+// Terms listed with variants flag those variants. Terms listed alone flag
+// any spelling that differs in case. Text after a colon or dash is a
+// description and is ignored.
+package glossary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Term is a preferred spelling and the spellings it replaces.
+type Term struct {
+	Term     string
+	Variants []string
+}
+
+type Glossary []Term
+
+// Issue is a spelling in the text that the glossary replaces.
+type Issue struct {
+	Line  int
+	Found string
+	Want  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("line %d: use %q instead of %q", i.Line, i.Want, i.Found)
+}
+
+// Load reads the glossary from the markdown context files among paths,
+// relative to workingDir. Missing files are skipped.
+func Load(workingDir string, paths []string) Glossary {
+	var g Glossary
+	for _, path := range paths {
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		g = append(g, Parse(string(content))...)
+	}
+	return g
+}
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	itemRe    = regexp.MustCompile(`^\s*[-*+]\s+(.+)$`)
+	variantRe = regexp.MustCompile(`\(\s*(?i:not|avoid)\s*:?\s*([^)]*)\)`)
+)
+
+// Parse returns the terms listed under the glossary heading of a markdown
+// document.
+func Parse(content string) Glossary {
+	var (
+		g     Glossary
+		level int
+	)
+	for line := range strings.SplitSeq(content, "\n") {
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case strings.EqualFold(m[2], "glossary"):
+				level = len(m[1])
+			case level > 0 && len(m[1]) <= level:
+				level = 0
+			}
+			continue
+		}
+		if level == 0 {
+			continue
+		}
+		m := itemRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if term, ok := parseTerm(m[1]); ok {
+			g = append(g, term)
+		}
+	}
+	return g
+}
+
+func parseTerm(item string) (Term, bool) {
+	var t Term
+	if m := variantRe.FindStringSubmatchIndex(item); m != nil {
+		for v := range strings.SplitSeq(item[m[2]:m[3]], ",") {
+			if v = cleanTerm(v); v != "" {
+				t.Variants = append(t.Variants, v)
+			}
+		}
+		item = item[:m[0]] + item[m[1]:]
+	}
+	for _, sep := range []string{": ", " - ", " – ", " — "} {
+		if before, _, ok := strings.Cut(item, sep); ok {
+			item = before
+		}
+	}
+	t.Term = cleanTerm(strings.TrimSuffix(item, ":"))
+	return t, t.Term != ""
+}
+
+func cleanTerm(s string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(s), "*_`\"'"))
+}
+
+// Check returns the spellings in text that the glossary replaces. Fenced code
+// blocks, inline code, URLs, and the glossary itself are skipped.
+func (g Glossary) Check(text string) []Issue {
+	if len(g) == 0 {
+		return nil
+	}
+	var (
+		issues []Issue
+		fence  string
+		level  int
+	)
+	for i, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			switch {
+			case strings.EqualFold(m[2], "glossary"):
+				level = len(m[1])
+				continue
+			case level > 0 && len(m[1]) <= level:
+				level = 0
+			}
+		}
+		if level > 0 {
+			continue
+		}
+		line = stripCode(line)
+		for _, term := range g {
+			for _, found := range term.find(line) {
+				issues = append(issues, Issue{Line: i + 1, Found: found, Want: term.Term})
+			}
+		}
+	}
+	return issues
+}
+
+var (
+	inlineCodeRe = regexp.MustCompile("`[^`]*`")
+	urlRe        = regexp.MustCompile(`\w+://\S+`)
+)
+
+// stripCode blanks out inline code and URLs, which must keep their spelling.
+func stripCode(line string) string {
+	blank := func(s string) string { return strings.Repeat(" ", len(s)) }
+	line = inlineCodeRe.ReplaceAllStringFunc(line, blank)
+	return urlRe.ReplaceAllStringFunc(line, blank)
+}
+
+// find returns the spellings of the term in line that should be replaced.
+func (t Term) find(line string) []string {
+	var found []string
+	if len(t.Variants) == 0 {
+		for _, match := range findWord(line, t.Term, true) {
+			if match != t.Term {
+				found = append(found, match)
+			}
+		}
+		return found
+	}
+	for _, v := range t.Variants {
+		found = append(found, findWord(line, v, false)...)
+	}
+	return found
+}
+
+// findWord returns the occurrences of word in line that aren't part of a
+// longer word.
+func findWord(line, word string, ignoreCase bool) []string {
+	pattern := regexp.QuoteMeta(word)
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	var found []string
+	for _, m := range regexp.MustCompile(pattern).FindAllStringIndex(line, -1) {
+		before, _ := utf8.DecodeLastRuneInString(line[:m[0]])
+		after, _ := utf8.DecodeRuneInString(line[m[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		found = append(found, line[m[0]:m[1]])
+	}
+	return found
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,92 @@
+package glossary
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const memory = `# Project
+
+Build with go build.
+
+## Glossary
+
+- GitHub (not Github, Git Hub)
+- **MCP**: Model Context Protocol
+- Crush — the product name
+- Kubernetes (avoid: k8s)
+
+## Style
+
+- Use tabs
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, Glossary{
+		{Term: "GitHub", Variants: []string{"Github", "Git Hub"}},
+		{Term: "MCP"},
+		{Term: "Crush"},
+		{Term: "Kubernetes", Variants: []string{"k8s"}},
+	}, Parse(memory))
+	require.Empty(t, Parse("# Project\n\n- not a glossary\n"))
+}
+
+func TestGlossary_Check(t *testing.T) {
+	t.Parallel()
+
+	g := Parse(memory)
+	tests := []struct {
+		name string
+		text string
+		want []Issue
+	}{
+		{
+			name: "variants",
+			text: "Open a PR on Github.\nDeploy to k8s and Git Hub.",
+			want: []Issue{
+				{Line: 1, Found: "Github", Want: "GitHub"},
+				{Line: 2, Found: "Git Hub", Want: "GitHub"},
+				{Line: 2, Found: "k8s", Want: "Kubernetes"},
+			},
+		},
+		{
+			name: "case",
+			text: "crush now talks to mcp servers, CRUSH too.",
+			want: []Issue{
+				{Line: 1, Found: "mcp", Want: "MCP"},
+				{Line: 1, Found: "crush", Want: "Crush"},
+				{Line: 1, Found: "CRUSH", Want: "Crush"},
+			},
+		},
+		{name: "preferred", text: "Crush supports MCP on GitHub."},
+		{name: "longer words", text: "The Githubber crushed mcps."},
+		{
+			name: "code and urls",
+			text: "Run `crush serve` from https://github.com/Github/crush.\n```\ncrush run\n```\n~~~\nGithub\n~~~",
+		},
+		{name: "glossary", text: memory},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, g.Check(tt.text))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CRUSH.md"), []byte(memory), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".cursorrules"), []byte("## Glossary\n- Ignored\n"), 0o644))
+
+	g := Load(dir, []string{"CRUSH.md", ".cursorrules", "AGENTS.md"})
+	require.Len(t, g, 4)
+	require.Equal(t, "GitHub", g[0].Term)
+}
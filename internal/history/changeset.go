@@ -0,0 +1,81 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// ErrNoChangeSet is returned when a session has no change set left to undo.
+var ErrNoChangeSet = errors.New("no change set to undo")
+
+// ChangeSet is a group of file changes applied together, which can be
+// undone together.
+type ChangeSet struct {
+	ID        string
+	SessionID string
+	Files     []ChangeSetFile
+	Reverted  bool
+	CreatedAt int64
+}
+
+// ChangeSetFile is the change of a file in a change set, as the IDs of its
+// versions in the file history before and after the change.
+type ChangeSetFile struct {
+	Path     string `json:"path"`
+	BeforeID string `json:"before_id"`
+	AfterID  string `json:"after_id"`
+	// Created and Deleted tell whether the file didn't exist before or
+	// after the change, as its version is empty then.
+	Created bool `json:"created,omitempty"`
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+func (s *service) CreateChangeSet(ctx context.Context, sessionID string, files []ChangeSetFile) (ChangeSet, error) {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("failed to encode change set files: %w", err)
+	}
+	dbChangeSet, err := s.q.CreateChangeSet(ctx, db.CreateChangeSetParams{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		Files:     string(data),
+	})
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	return s.fromDBChangeSet(dbChangeSet)
+}
+
+func (s *service) LatestChangeSet(ctx context.Context, sessionID string) (ChangeSet, error) {
+	dbChangeSet, err := s.q.GetLatestChangeSet(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ChangeSet{}, ErrNoChangeSet
+	}
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	return s.fromDBChangeSet(dbChangeSet)
+}
+
+func (s *service) MarkChangeSetReverted(ctx context.Context, id string) error {
+	return s.q.MarkChangeSetReverted(ctx, id)
+}
+
+func (s *service) fromDBChangeSet(item db.ChangeSet) (ChangeSet, error) {
+	changeSet := ChangeSet{
+		ID:        item.ID,
+		SessionID: item.SessionID,
+		Reverted:  item.Reverted != 0,
+		CreatedAt: item.CreatedAt,
+	}
+	if err := json.Unmarshal([]byte(item.Files), &changeSet.Files); err != nil {
+		return ChangeSet{}, fmt.Errorf("failed to decode change set files: %w", err)
+	}
+	return changeSet, nil
+}
@@ -2,8 +2,6 @@ package history
 
 import (
 	"context"
-	"database/sql"
-	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/crush/internal/db"
@@ -35,19 +33,26 @@ type Service interface {
 	ListLatestSessionFiles(ctx context.Context, sessionID string) ([]File, error)
 	Delete(ctx context.Context, id string) error
 	DeleteSessionFiles(ctx context.Context, sessionID string) error
+
+	// CreateChangeSet records files changed together, LatestChangeSet
+	// returns the last one of the session not reverted yet, or
+	// ErrNoChangeSet.
+	CreateChangeSet(ctx context.Context, sessionID string, files []ChangeSetFile) (ChangeSet, error)
+	LatestChangeSet(ctx context.Context, sessionID string) (ChangeSet, error)
+	MarkChangeSetReverted(ctx context.Context, id string) error
 }
 
 type service struct {
 	*pubsub.Broker[File]
-	db *sql.DB
-	q  *db.Queries
+	store db.Store
+	q     db.Querier
 }
 
-func NewService(q *db.Queries, db *sql.DB) Service {
+func NewService(store db.Store) Service {
 	return &service{
 		Broker: pubsub.NewBroker[File](),
-		q:      q,
-		db:     db,
+		store:  store,
+		q:      store,
 	}
 }
 
@@ -82,41 +87,29 @@ func (s *service) createWithVersion(ctx context.Context, sessionID, path, conten
 
 	// Retry loop for transaction conflicts
 	for attempt := range maxRetries {
-		// Start a transaction
-		tx, txErr := s.db.BeginTx(ctx, nil)
-		if txErr != nil {
-			return File{}, fmt.Errorf("failed to begin transaction: %w", txErr)
-		}
-
-		// Create a new queries instance with the transaction
-		qtx := s.q.WithTx(tx)
-
-		// Try to create the file within the transaction
-		dbFile, txErr := qtx.CreateFile(ctx, db.CreateFileParams{
-			ID:        uuid.New().String(),
-			SessionID: sessionID,
-			Path:      path,
-			Content:   content,
-			Version:   version,
+		var dbFile db.File
+		err = s.store.WithTx(ctx, func(q db.Querier) error {
+			var txErr error
+			dbFile, txErr = q.CreateFile(ctx, db.CreateFileParams{
+				ID:        uuid.New().String(),
+				SessionID: sessionID,
+				Path:      path,
+				Content:   content,
+				Version:   version,
+			})
+			return txErr
 		})
-		if txErr != nil {
-			// Rollback the transaction
-			tx.Rollback()
-
-			// Check if this is a uniqueness constraint violation
-			if strings.Contains(txErr.Error(), "UNIQUE constraint failed") {
+		if err != nil {
+			// Check if this is a uniqueness constraint violation, as reported
+			// by the storage backends.
+			if strings.Contains(strings.ToLower(err.Error()), "unique constraint") {
 				if attempt < maxRetries-1 {
 					// If we have retries left, increment version and try again
 					version++
 					continue
 				}
 			}
-			return File{}, txErr
-		}
-
-		// Commit the transaction
-		if txErr = tx.Commit(); txErr != nil {
-			return File{}, fmt.Errorf("failed to commit transaction: %w", txErr)
+			return File{}, err
 		}
 
 		file = s.fromDBItem(dbFile)
@@ -0,0 +1,225 @@
+// Package hooks runs the shell commands of the config on events of the agent,
+// such as before tool calls and after file edits, with the event as JSON on
+// their standard input.
+package hooks
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+const (
+	defaultTimeout = 60 * time.Second
+	// maxOutput is the length of the output of a hook kept for the model.
+	maxOutput = 4000
+)
+
+// Event is passed to the hooks as JSON on their standard input.
+type Event struct {
+	Event      string `json:"event"`
+	SessionID  string `json:"session_id"`
+	WorkingDir string `json:"working_dir"`
+	// ToolName, ToolCallID, and ToolInput are the call of pre_tool_call.
+	ToolName   string          `json:"tool_name,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	ToolInput  json.RawMessage `json:"tool_input,omitempty"`
+	// File is the absolute path of the file changed, for post_edit.
+	File string `json:"file,omitempty"`
+	// Session is the usage of the session, for session_end.
+	Session *Usage `json:"session,omitempty"`
+}
+
+// Usage is what a session spent.
+type Usage struct {
+	Title            string  `json:"title"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Failure is a hook that failed, with its output.
+type Failure struct {
+	Command string
+	Output  string
+}
+
+// Runner runs the hooks of the config. A nil Runner runs none.
+type Runner struct {
+	hooks      *config.Hooks
+	workingDir string
+}
+
+// New returns the runner of the hooks of the config, nil when there are none.
+func New(cfg *config.Config) *Runner {
+	if cfg.Hooks == nil {
+		return nil
+	}
+	return &Runner{hooks: cfg.Hooks, workingDir: cfg.WorkingDir()}
+}
+
+// PreToolCall runs the pre_tool_call hooks of the tool, returning the first
+// one failing, which vetoes the call.
+func (r *Runner) PreToolCall(ctx context.Context, sessionID, toolCallID, toolName, input string) *Failure {
+	if r == nil {
+		return nil
+	}
+	event := Event{
+		Event:      config.HookPreToolCall,
+		SessionID:  sessionID,
+		ToolName:   toolName,
+		ToolCallID: toolCallID,
+	}
+	if json.Valid([]byte(input)) {
+		event.ToolInput = json.RawMessage(input)
+	}
+	for _, hook := range r.hooks.PreToolCall {
+		if !matchTool(hook.Tools, toolName) {
+			continue
+		}
+		if failure := r.run(ctx, hook, event); failure != nil {
+			return failure
+		}
+	}
+	return nil
+}
+
+// PostEdit runs the post_edit hooks on each of the changed files matching
+// them, returning the ones failing.
+func (r *Runner) PostEdit(ctx context.Context, sessionID string, files []string) []Failure {
+	if r == nil {
+		return nil
+	}
+	var failures []Failure
+	for _, file := range files {
+		event := Event{Event: config.HookPostEdit, SessionID: sessionID, File: file}
+		for _, hook := range r.hooks.PostEdit {
+			if !r.matchFile(hook.Files, file) {
+				continue
+			}
+			if failure := r.run(ctx, hook, event); failure != nil {
+				failures = append(failures, *failure)
+			}
+		}
+	}
+	return failures
+}
+
+// SessionStart runs the session_start hooks.
+func (r *Runner) SessionStart(ctx context.Context, sessionID string) {
+	if r == nil {
+		return
+	}
+	r.runAll(ctx, r.hooks.SessionStart, Event{Event: config.HookSessionStart, SessionID: sessionID})
+}
+
+// SessionEnd runs the session_end hooks with the usage of the session.
+func (r *Runner) SessionEnd(ctx context.Context, sessionID string, usage Usage) {
+	if r == nil {
+		return
+	}
+	r.runAll(ctx, r.hooks.SessionEnd, Event{Event: config.HookSessionEnd, SessionID: sessionID, Session: &usage})
+}
+
+// runAll runs the hooks of an event that can't fail, logging the failures.
+func (r *Runner) runAll(ctx context.Context, hooks []config.Hook, event Event) {
+	for _, hook := range hooks {
+		if failure := r.run(ctx, hook, event); failure != nil {
+			slog.Warn("Hook failed", "event", event.Event, "command", failure.Command, "output", failure.Output)
+		}
+	}
+}
+
+// run runs the command of the hook on the event, returning its failure, if
+// it fails.
+func (r *Runner) run(ctx context.Context, hook config.Hook, event Event) *Failure {
+	event.WorkingDir = r.workingDir
+	input, err := json.Marshal(event)
+	if err != nil {
+		return &Failure{Command: hook.Command, Output: err.Error()}
+	}
+
+	timeout := defaultTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	sh := shell.NewShell(&shell.Options{
+		WorkingDir: r.workingDir,
+		Env: append(os.Environ(),
+			"CRUSH_HOOK_EVENT="+event.Event,
+			"CRUSH_SESSION_ID="+event.SessionID,
+			"CRUSH_TOOL_NAME="+event.ToolName,
+			"CRUSH_FILE="+event.File,
+		),
+		Stdin: bytes.NewReader(input),
+	})
+	stdout, stderr, err := sh.Exec(ctx, hook.Command)
+	if err == nil {
+		return nil
+	}
+	output := strings.TrimSpace(cmp.Or(strings.TrimSpace(stderr), stdout))
+	if ctx.Err() != nil {
+		output = fmt.Sprintf("timed out after %s", timeout)
+	}
+	if output == "" {
+		output = err.Error()
+	}
+	if len(output) > maxOutput {
+		output = output[:maxOutput] + "\n... (truncated)"
+	}
+	return &Failure{Command: hook.Command, Output: output}
+}
+
+// matchTool reports whether the tool is one of the patterns, any tool when
+// there are none.
+func matchTool(patterns []string, tool string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFile reports whether the file matches one of the patterns, any file
+// when there are none. Patterns without a slash match the name of the file,
+// the others its path from the working directory.
+func (r *Runner) matchFile(patterns []string, file string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	rel, err := filepath.Rel(r.workingDir, file)
+	if err != nil {
+		rel = file
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(rel)
+		}
+		if ok, _ := doublestar.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
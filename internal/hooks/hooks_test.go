@@ -0,0 +1,89 @@
+package hooks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newRunner(t *testing.T, hooks config.Hooks) *Runner {
+	t.Helper()
+	return &Runner{hooks: &hooks, workingDir: t.TempDir()}
+}
+
+func TestNilRunner(t *testing.T) {
+	t.Parallel()
+
+	var r *Runner
+	require.Nil(t, r.PreToolCall(t.Context(), "s", "c", "bash", "{}"))
+	require.Nil(t, r.PostEdit(t.Context(), "s", []string{"main.go"}))
+	r.SessionStart(t.Context(), "s")
+	r.SessionEnd(t.Context(), "s", Usage{})
+}
+
+func TestPreToolCall(t *testing.T) {
+	t.Parallel()
+
+	r := newRunner(t, config.Hooks{
+		PreToolCall: []config.Hook{
+			{Command: "cat > event.json"},
+			{Command: `echo "no $CRUSH_TOOL_NAME" >&2; exit 1`, Tools: []string{"bash"}},
+		},
+	})
+
+	require.Nil(t, r.PreToolCall(t.Context(), "session", "call", "view", `{"file_path":"main.go"}`))
+	data, err := os.ReadFile(filepath.Join(r.workingDir, "event.json"))
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(data, &event))
+	require.Equal(t, config.HookPreToolCall, event.Event)
+	require.Equal(t, "session", event.SessionID)
+	require.Equal(t, "call", event.ToolCallID)
+	require.Equal(t, "view", event.ToolName)
+	require.Equal(t, r.workingDir, event.WorkingDir)
+	require.JSONEq(t, `{"file_path":"main.go"}`, string(event.ToolInput))
+
+	failure := r.PreToolCall(t.Context(), "session", "call", "bash", `{"command":"rm -rf /"}`)
+	require.NotNil(t, failure)
+	require.Equal(t, "no bash", failure.Output)
+}
+
+func TestPostEdit(t *testing.T) {
+	t.Parallel()
+
+	r := newRunner(t, config.Hooks{
+		PostEdit: []config.Hook{
+			{Command: `echo "$CRUSH_FILE" >> edited.txt`, Files: []string{"*.go"}},
+			{Command: "echo broken; exit 2", Files: []string{"web/**/*.ts"}},
+		},
+	})
+	goFile := filepath.Join(r.workingDir, "cmd", "main.go")
+	tsFile := filepath.Join(r.workingDir, "web", "src", "app.ts")
+
+	failures := r.PostEdit(t.Context(), "session", []string{goFile, tsFile, filepath.Join(r.workingDir, "README.md")})
+	require.Equal(t, []Failure{{Command: "echo broken; exit 2", Output: "broken"}}, failures)
+
+	data, err := os.ReadFile(filepath.Join(r.workingDir, "edited.txt"))
+	require.NoError(t, err)
+	require.Equal(t, goFile+"\n", string(data))
+}
+
+func TestSessionEnd(t *testing.T) {
+	t.Parallel()
+
+	r := newRunner(t, config.Hooks{
+		SessionEnd: []config.Hook{{Command: "cat > event.json"}},
+	})
+	r.SessionEnd(t.Context(), "session", Usage{Title: "Fix the tests", PromptTokens: 1200, CompletionTokens: 300, Cost: 0.02})
+
+	data, err := os.ReadFile(filepath.Join(r.workingDir, "event.json"))
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(data, &event))
+	require.Equal(t, config.HookSessionEnd, event.Event)
+	require.Equal(t, &Usage{Title: "Fix the tests", PromptTokens: 1200, CompletionTokens: 300, Cost: 0.02}, event.Session)
+}
@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+const (
+	aiderHistoryFile = ".aider.chat.history.md"
+	aiderStarted     = "# aider chat started at "
+	aiderAppliedEdit = "Applied edit to "
+)
+
+// aiderChatModes are the commands of aider prompting the model, the other
+// commands are left out.
+var aiderChatModes = []string{"/ask ", "/code ", "/architect "}
+
+// parseAider reads the markdown chat history of aider, which holds every
+// conversation of the project. Prompts are headed with ####, the output of
+// aider itself is quoted, and the rest are the answers of the model.
+func parseAider(r io.Reader) ([]Conversation, error) {
+	var (
+		convs   []Conversation
+		b       *builder
+		started time.Time
+		prompt  []string
+		answer  []string
+		inCode  bool
+		edits   int
+	)
+	flush := func() {
+		if b == nil {
+			return
+		}
+		if len(prompt) > 0 {
+			b.user(aiderPrompt(strings.Join(prompt, "\n")))
+			prompt = nil
+		}
+		if len(answer) > 0 {
+			b.text(strings.Join(answer, "\n"), started)
+			answer = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+		}
+		switch {
+		case !inCode && strings.HasPrefix(line, aiderStarted):
+			flush()
+			if b != nil {
+				convs = append(convs, b.conversation(""))
+			}
+			b = newBuilder("")
+			started, _ = time.ParseInLocation(time.DateTime, strings.TrimPrefix(line, aiderStarted), time.Local)
+		case b == nil:
+			// Nothing before the first conversation.
+		case !inCode && (line == "####" || strings.HasPrefix(line, "#### ")):
+			if len(answer) > 0 {
+				flush()
+			}
+			prompt = append(prompt, strings.TrimPrefix(strings.TrimPrefix(line, "####"), " "))
+		case !inCode && (line == ">" || strings.HasPrefix(line, "> ")):
+			flush()
+			output := strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ")
+			if model, ok := aiderModel(output); ok {
+				b.model = model
+			}
+			if file, ok := strings.CutPrefix(output, aiderAppliedEdit); ok {
+				edits++
+				id := fmt.Sprintf("aider-edit-%d", edits)
+				input, _ := json.Marshal(tools.EditParams{FilePath: file})
+				b.toolCall(message.ToolCall{ID: id, Name: tools.EditToolName, Input: string(input)}, started)
+				b.result(message.ToolResult{ToolCallID: id, Content: output})
+			}
+		default:
+			if len(prompt) > 0 {
+				flush()
+			}
+			answer = append(answer, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	if b != nil {
+		convs = append(convs, b.conversation(""))
+	}
+	return convs, nil
+}
+
+// aiderPrompt returns the prompt given to the model, empty for the commands
+// that don't prompt it.
+func aiderPrompt(prompt string) string {
+	if !strings.HasPrefix(prompt, "/") {
+		return prompt
+	}
+	for _, mode := range aiderChatModes {
+		if rest, ok := strings.CutPrefix(prompt, mode); ok {
+			return rest
+		}
+	}
+	return ""
+}
+
+// aiderModel returns the model aider announces when it starts, e.g.
+// "Main model: gpt-4o with diff edit format".
+func aiderModel(output string) (string, bool) {
+	for _, prefix := range []string{"Main model: ", "Model: "} {
+		if rest, ok := strings.CutPrefix(output, prefix); ok {
+			model, _, _ := strings.Cut(rest, " ")
+			return model, model != ""
+		}
+	}
+	return "", false
+}
+
+func aiderSources(workingDir string) ([]string, error) {
+	path := filepath.Join(workingDir, aiderHistoryFile)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no aider history in %s: %w", workingDir, err)
+	}
+	return []string{path}, nil
+}
@@ -0,0 +1,231 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// claudeCodeTools are the crush tools taking the same input as the Claude
+// Code ones.
+var claudeCodeTools = map[string]string{
+	"Bash":      tools.BashToolName,
+	"Read":      tools.ViewToolName,
+	"Edit":      tools.EditToolName,
+	"MultiEdit": tools.MultiEditToolName,
+	"Write":     tools.WriteToolName,
+	"Glob":      tools.GlobToolName,
+	"Grep":      tools.GrepToolName,
+	"LS":        tools.LSToolName,
+	"WebFetch":  tools.FetchToolName,
+}
+
+// claudeCodeLine is a line of the JSONL transcripts of Claude Code.
+type claudeCodeLine struct {
+	Type string `json:"type"`
+	// Summary is the title of a conversation, on summary lines.
+	Summary     string    `json:"summary"`
+	IsSidechain bool      `json:"isSidechain"`
+	IsMeta      bool      `json:"isMeta"`
+	Timestamp   time.Time `json:"timestamp"`
+	Message     struct {
+		Model   string          `json:"model"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+type claudeCodeBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+	// Tool results
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// parseClaudeCode reads a transcript of Claude Code, one conversation per
+// file. Sub-agent turns and thinking are left out.
+func parseClaudeCode(r io.Reader) ([]Conversation, error) {
+	b := newBuilder("anthropic")
+	var title string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var line claudeCodeLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if line.Type == "summary" && title == "" {
+			title = line.Summary
+		}
+		if (line.Type != "user" && line.Type != "assistant") || line.IsSidechain || line.IsMeta {
+			continue
+		}
+		blocks, err := claudeCodeBlocks(line.Message.Content)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if line.Type == "assistant" {
+			b.model = line.Message.Model
+		}
+		for _, block := range blocks {
+			switch block.Type {
+			case "text":
+				if line.Type == "assistant" {
+					b.text(block.Text, line.Timestamp)
+				} else if !isClaudeCodeCommand(block.Text) {
+					b.user(block.Text)
+				}
+			case "tool_use":
+				b.toolCall(claudeCodeToolCall(block), line.Timestamp)
+			case "tool_result":
+				b.result(claudeCodeToolResult(block, b.pending))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return []Conversation{b.conversation(title)}, nil
+}
+
+// claudeCodeBlocks decodes content given as a string or as content blocks.
+func claudeCodeBlocks(content json.RawMessage) ([]claudeCodeBlock, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return []claudeCodeBlock{{Type: "text", Text: text}}, nil
+	}
+	var blocks []claudeCodeBlock
+	if err := json.Unmarshal(content, &blocks); err != nil {
+		return nil, fmt.Errorf("invalid message content: %w", err)
+	}
+	return blocks, nil
+}
+
+// isClaudeCodeCommand reports whether the user text is a slash command of
+// Claude Code or its output, rather than a prompt.
+func isClaudeCodeCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "<command-") || strings.HasPrefix(text, "<local-command-")
+}
+
+func claudeCodeToolCall(block claudeCodeBlock) message.ToolCall {
+	name := block.Name
+	if mapped, ok := claudeCodeTools[name]; ok {
+		name = mapped
+	}
+	input := string(block.Input)
+	if input == "" {
+		input = "{}"
+	}
+	return message.ToolCall{ID: block.ID, Name: name, Input: input}
+}
+
+// claudeCodeToolResult converts the result, adding the metadata crush shows
+// the edits with.
+func claudeCodeToolResult(block claudeCodeBlock, pending []message.ToolCall) message.ToolResult {
+	result := message.ToolResult{
+		ToolCallID: block.ToolUseID,
+		Content:    claudeCodeResultText(block.Content),
+		IsError:    block.IsError,
+	}
+	if result.IsError {
+		return result
+	}
+	for _, call := range pending {
+		if call.ID == block.ToolUseID {
+			result.Metadata = editMetadata(call)
+		}
+	}
+	return result
+}
+
+func claudeCodeResultText(content json.RawMessage) string {
+	blocks, err := claudeCodeBlocks(content)
+	if err != nil {
+		return string(content)
+	}
+	var texts []string
+	for _, block := range blocks {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// editMetadata returns the metadata of the edit and write tool calls, empty
+// for the other tools.
+func editMetadata(call message.ToolCall) string {
+	var meta any
+	switch call.Name {
+	case tools.EditToolName:
+		var params tools.EditParams
+		if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+			return ""
+		}
+		_, additions, removals := diff.GenerateDiff(params.OldString, params.NewString, params.FilePath)
+		meta = tools.EditResponseMetadata{
+			Additions:  additions,
+			Removals:   removals,
+			OldContent: params.OldString,
+			NewContent: params.NewString,
+		}
+	case tools.WriteToolName:
+		var params tools.WriteParams
+		if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+			return ""
+		}
+		patch, additions, removals := diff.GenerateDiff("", params.Content, params.FilePath)
+		meta = tools.WriteResponseMetadata{Diff: patch, Additions: additions, Removals: removals}
+	default:
+		return ""
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// claudeCodeSources returns the transcripts Claude Code keeps for the
+// project, under a directory named after its path.
+func claudeCodeSources(workingDir string) ([]string, error) {
+	abs, err := filepath.Abs(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(config.HomeDir(), ".claude", "projects", nonAlphanumeric.ReplaceAllString(abs, "-"))
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("no Claude Code history for %s: %w", abs, err)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,226 @@
+package importer
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// codexContextPrefixes start the user messages Codex adds to give context,
+// rather than prompts.
+var codexContextPrefixes = []string{"<environment_context>", "<user_instructions>", "# AGENTS.md instructions"}
+
+// codexLine is a line of the rollout files of Codex. Recent versions wrap
+// the items in a payload, older ones have them at the top level.
+type codexLine struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+type codexItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	// Tool calls and their output
+	Name      string          `json:"name"`
+	Arguments string          `json:"arguments"`
+	Input     string          `json:"input"`
+	CallID    string          `json:"call_id"`
+	Output    json.RawMessage `json:"output"`
+	// Session metadata and turn context
+	Cwd   string `json:"cwd"`
+	Model string `json:"model"`
+}
+
+// parseCodex reads a rollout file of Codex, one conversation per file.
+// Reasoning is left out.
+func parseCodex(r io.Reader) ([]Conversation, error) {
+	b := newBuilder("openai")
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var line codexLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		data := scanner.Bytes()
+		if len(line.Payload) > 0 {
+			data = line.Payload
+		}
+		var item codexItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if line.Type == "turn_context" {
+			b.model = item.Model
+			continue
+		}
+		if len(line.Payload) > 0 && line.Type != "response_item" {
+			continue
+		}
+
+		switch item.Type {
+		case "message":
+			var texts []string
+			for _, content := range item.Content {
+				texts = append(texts, content.Text)
+			}
+			text := strings.Join(texts, "\n")
+			switch item.Role {
+			case "user":
+				if !isCodexContext(text) {
+					b.user(text)
+				}
+			case "assistant":
+				b.text(text, line.Timestamp)
+			}
+		case "function_call":
+			b.toolCall(codexToolCall(item.CallID, item.Name, item.Arguments), line.Timestamp)
+		case "custom_tool_call":
+			input, _ := json.Marshal(map[string]string{"input": item.Input})
+			b.toolCall(message.ToolCall{ID: item.CallID, Name: item.Name, Input: string(input)}, line.Timestamp)
+		case "function_call_output", "custom_tool_call_output":
+			content, isError := codexOutput(item.Output)
+			b.result(message.ToolResult{ToolCallID: item.CallID, Content: content, IsError: isError})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return []Conversation{b.conversation("")}, nil
+}
+
+func isCodexContext(text string) bool {
+	text = strings.TrimSpace(text)
+	return slices.ContainsFunc(codexContextPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(text, prefix)
+	})
+}
+
+// codexToolCall converts the shell calls of Codex to the bash tool, other
+// calls are kept as is.
+func codexToolCall(id, name, arguments string) message.ToolCall {
+	call := message.ToolCall{ID: id, Name: name, Input: cmp.Or(arguments, "{}")}
+	if name != "shell" {
+		return call
+	}
+	var args struct {
+		Command   []string `json:"command"`
+		TimeoutMS int      `json:"timeout_ms"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil || len(args.Command) == 0 {
+		return call
+	}
+	command := strings.Join(args.Command, " ")
+	// Commands are usually run as bash -lc "<script>".
+	if len(args.Command) == 3 && filepath.Base(args.Command[0]) == "bash" && args.Command[1] == "-lc" {
+		command = args.Command[2]
+	}
+	input, err := json.Marshal(tools.BashParams{Command: command, Timeout: args.TimeoutMS})
+	if err != nil {
+		return call
+	}
+	call.Name = tools.BashToolName
+	call.Input = string(input)
+	return call
+}
+
+// codexOutput returns the text of a tool output, a string holding either
+// the text or JSON with the text and the exit code, and whether it failed.
+func codexOutput(output json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(output, &text); err != nil {
+		var legacy struct {
+			Content string `json:"content"`
+			Success *bool  `json:"success"`
+		}
+		if err := json.Unmarshal(output, &legacy); err != nil {
+			return string(output), false
+		}
+		return legacy.Content, legacy.Success != nil && !*legacy.Success
+	}
+	var structured struct {
+		Output   *string `json:"output"`
+		Metadata struct {
+			ExitCode int `json:"exit_code"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(text), &structured); err != nil || structured.Output == nil {
+		return text, false
+	}
+	return *structured.Output, structured.Metadata.ExitCode != 0
+}
+
+// codexSources returns the rollout files of Codex started in the project,
+// by the working directory in their metadata.
+func codexSources(workingDir string) ([]string, error) {
+	abs, err := filepath.Abs(workingDir)
+	if err != nil {
+		return nil, err
+	}
+	home := os.Getenv("CODEX_HOME")
+	if home == "" {
+		home = filepath.Join(config.HomeDir(), ".codex")
+	}
+	dir := filepath.Join(home, "sessions")
+	var paths []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), "rollout-") || filepath.Ext(path) != ".jsonl" {
+			return nil
+		}
+		if cwd, err := codexWorkingDir(path); err == nil && cwd == abs {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no Codex history: %w", err)
+	}
+	return paths, nil
+}
+
+// codexWorkingDir returns the working directory in the session metadata,
+// the first line of the rollout file.
+func codexWorkingDir(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	if !scanner.Scan() {
+		return "", cmp.Or(scanner.Err(), io.EOF)
+	}
+	var line codexLine
+	if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+		return "", err
+	}
+	var meta codexItem
+	if err := json.Unmarshal(line.Payload, &meta); err != nil {
+		return "", err
+	}
+	return meta.Cwd, nil
+}
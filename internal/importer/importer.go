@@ -0,0 +1,231 @@
+// Package importer converts the history of other coding agent CLIs into
+// sessions, so conversations started elsewhere can go on in crush.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Format is the history format of another CLI.
+type Format string
+
+const (
+	FormatClaudeCode Format = "claude-code"
+	FormatAider      Format = "aider"
+	FormatCodex      Format = "codex"
+)
+
+// Formats are the supported formats.
+var Formats = []Format{FormatClaudeCode, FormatAider, FormatCodex}
+
+const maxTitleLength = 50
+
+// Conversation is a session of another CLI.
+type Conversation struct {
+	Title    string
+	Messages []message.CreateMessageParams
+}
+
+// Parse reads the conversations of a history file in the format. Empty
+// conversations are left out.
+func Parse(format Format, r io.Reader) ([]Conversation, error) {
+	var (
+		convs []Conversation
+		err   error
+	)
+	switch format {
+	case FormatClaudeCode:
+		convs, err = parseClaudeCode(r)
+	case FormatAider:
+		convs, err = parseAider(r)
+	case FormatCodex:
+		convs, err = parseCodex(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(convs, func(conv Conversation) bool {
+		return len(conv.Messages) == 0
+	}), nil
+}
+
+// Sources returns the history files of the format for the project in
+// workingDir, the ones imported when no file is given.
+func Sources(format Format, workingDir string) ([]string, error) {
+	switch format {
+	case FormatClaudeCode:
+		return claudeCodeSources(workingDir)
+	case FormatAider:
+		return aiderSources(workingDir)
+	case FormatCodex:
+		return codexSources(workingDir)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// Import creates a session for each conversation, with its messages.
+func Import(ctx context.Context, sessions session.Service, messages message.Service, convs []Conversation) ([]session.Session, error) {
+	var imported []session.Session
+	for _, conv := range convs {
+		sess, err := sessions.Create(ctx, conv.Title)
+		if err != nil {
+			return imported, fmt.Errorf("failed to create session: %w", err)
+		}
+		for _, msg := range conv.Messages {
+			if _, err := messages.Create(ctx, sess.ID, msg); err != nil {
+				return imported, fmt.Errorf("failed to create message in session %s: %w", sess.ID, err)
+			}
+		}
+		imported = append(imported, sess)
+	}
+	return imported, nil
+}
+
+// builder assembles the messages of a conversation in the shape the agent
+// produces: tool calls in assistant messages, each answered in the tool
+// message that follows.
+type builder struct {
+	msgs  []message.CreateMessageParams
+	title string
+	// Model and provider of the assistant messages.
+	model    string
+	provider string
+
+	// open is the index of the assistant message not finished yet, -1 if
+	// none, and last the time of its latest part.
+	open int
+	last time.Time
+	// pending are the tool calls without a result yet.
+	pending []message.ToolCall
+}
+
+func newBuilder(provider string) *builder {
+	return &builder{provider: provider, open: -1}
+}
+
+func (b *builder) user(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	b.closeTurn()
+	if b.title == "" {
+		b.title = titleFrom(text)
+	}
+	b.msgs = append(b.msgs, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: text}},
+	})
+}
+
+func (b *builder) text(text string, at time.Time) {
+	if text = strings.TrimSpace(text); text != "" {
+		b.assistant(message.TextContent{Text: text}, at)
+	}
+}
+
+func (b *builder) toolCall(call message.ToolCall, at time.Time) {
+	call.Finished = true
+	b.assistant(call, at)
+	b.pending = append(b.pending, call)
+}
+
+func (b *builder) assistant(part message.ContentPart, at time.Time) {
+	if b.open == -1 {
+		b.closeTurn()
+		b.open = len(b.msgs)
+		b.msgs = append(b.msgs, message.CreateMessageParams{
+			Role:     message.Assistant,
+			Model:    b.model,
+			Provider: b.provider,
+		})
+	}
+	b.msgs[b.open].Parts = append(b.msgs[b.open].Parts, part)
+	if !at.IsZero() {
+		b.last = at
+	}
+}
+
+// result answers a tool call. Results of unknown calls are dropped.
+func (b *builder) result(result message.ToolResult) {
+	i := slices.IndexFunc(b.pending, func(call message.ToolCall) bool {
+		return call.ID == result.ToolCallID
+	})
+	if i == -1 {
+		return
+	}
+	result.Name = b.pending[i].Name
+	b.pending = slices.Delete(b.pending, i, i+1)
+	b.finish()
+	if n := len(b.msgs); n > 0 && b.msgs[n-1].Role == message.Tool {
+		b.msgs[n-1].Parts = append(b.msgs[n-1].Parts, result)
+		return
+	}
+	b.msgs = append(b.msgs, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{result},
+	})
+}
+
+// closeTurn finishes the assistant message, answering the tool calls left
+// without a result, which providers reject.
+func (b *builder) closeTurn() {
+	for len(b.pending) > 0 {
+		b.result(message.ToolResult{
+			ToolCallID: b.pending[0].ID,
+			Content:    "No result was recorded for this tool call.",
+			IsError:    true,
+		})
+	}
+	b.finish()
+}
+
+func (b *builder) finish() {
+	if b.open == -1 {
+		return
+	}
+	msg := &b.msgs[b.open]
+	reason := message.FinishReasonEndTurn
+	if slices.ContainsFunc(msg.Parts, func(part message.ContentPart) bool {
+		_, ok := part.(message.ToolCall)
+		return ok
+	}) {
+		reason = message.FinishReasonToolUse
+	}
+	at := b.last
+	if at.IsZero() {
+		at = time.Now()
+	}
+	msg.Parts = append(msg.Parts, message.Finish{Reason: reason, Time: at.Unix()})
+	b.open = -1
+}
+
+func (b *builder) conversation(title string) Conversation {
+	b.closeTurn()
+	if title == "" {
+		title = b.title
+	}
+	return Conversation{Title: title, Messages: b.msgs}
+}
+
+// titleFrom returns the first line of the prompt, shortened to fit a title.
+func titleFrom(prompt string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(prompt), "\n")
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) <= maxTitleLength {
+		return title
+	}
+	runes := []rune(title)
+	return strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+}
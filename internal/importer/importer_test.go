@@ -0,0 +1,183 @@
+package importer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+const claudeCodeTranscript = `{"type":"summary","summary":"Fix the failing parser test","leafUuid":"c"}
+{"type":"user","isMeta":true,"message":{"role":"user","content":"Caveat: the messages below were generated by local commands."},"timestamp":"2025-08-01T10:00:00Z"}
+{"type":"user","message":{"role":"user","content":"The parser test fails, fix it"},"timestamp":"2025-08-01T10:00:01Z"}
+{"type":"assistant","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"thinking","thinking":"Let me look.","signature":"sig"}]},"timestamp":"2025-08-01T10:00:02Z"}
+{"type":"assistant","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Let me fix the off-by-one."}]},"timestamp":"2025-08-01T10:00:03Z"}
+{"type":"assistant","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_1","name":"Edit","input":{"file_path":"parser.go","old_string":"i <= n","new_string":"i < n"}}]},"timestamp":"2025-08-01T10:00:04Z"}
+{"type":"assistant","isSidechain":true,"message":{"id":"msg_s","role":"assistant","content":[{"type":"text","text":"Sub-agent work"}]},"timestamp":"2025-08-01T10:00:04Z"}
+{"type":"user","message":{"role":"user","content":[{"tool_use_id":"toolu_1","type":"tool_result","content":"The file parser.go has been updated."}]},"timestamp":"2025-08-01T10:00:05Z"}
+{"type":"assistant","message":{"id":"msg_2","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_2","name":"Bash","input":{"command":"go test ./...","description":"Run the tests"}}]},"timestamp":"2025-08-01T10:00:06Z"}
+{"type":"user","message":{"role":"user","content":"<command-name>/clear</command-name>"},"timestamp":"2025-08-01T10:00:07Z"}
+`
+
+const aiderHistory = "# aider chat started at 2025-08-01 10:00:00\n" +
+	"\n" +
+	"> Aider v0.85.0\n" +
+	"> Main model: gpt-4o with diff edit format\n" +
+	"\n" +
+	"#### /add parser.go\n" +
+	"\n" +
+	"> Added parser.go to the chat\n" +
+	"\n" +
+	"#### fix the off-by-one\n" +
+	"#### in the parser\n" +
+	"\n" +
+	"Here is the fix:\n" +
+	"\n" +
+	"parser.go\n" +
+	"```go\n" +
+	"<<<<<<< SEARCH\n" +
+	"> not aider output\n" +
+	"=======\n" +
+	">>>>>>> REPLACE\n" +
+	"```\n" +
+	"\n" +
+	"> Applied edit to parser.go\n" +
+	"> Commit 1a2b3c4 fix: Off-by-one in the parser\n" +
+	"\n" +
+	"# aider chat started at 2025-08-02 09:00:00\n" +
+	"\n" +
+	"#### /ask what does the lexer do?\n" +
+	"\n" +
+	"It splits the input into tokens.\n"
+
+const codexRollout = `{"timestamp":"2025-08-01T10:00:00Z","type":"session_meta","payload":{"id":"abc","cwd":"/work/project"}}
+{"timestamp":"2025-08-01T10:00:00Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>\n  <cwd>/work/project</cwd>\n</environment_context>"}]}}
+{"timestamp":"2025-08-01T10:00:01Z","type":"turn_context","payload":{"cwd":"/work/project","model":"gpt-5"}}
+{"timestamp":"2025-08-01T10:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"Run the tests"}]}}
+{"timestamp":"2025-08-01T10:00:02Z","type":"response_item","payload":{"type":"reasoning","summary":[]}}
+{"timestamp":"2025-08-01T10:00:03Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"bash\",\"-lc\",\"go test ./...\"],\"timeout_ms\":120000}","call_id":"call_1"}}
+{"timestamp":"2025-08-01T10:00:04Z","type":"response_item","payload":{"type":"function_call_output","call_id":"call_1","output":"{\"output\":\"FAIL parser\",\"metadata\":{\"exit_code\":1}}"}}
+{"timestamp":"2025-08-01T10:00:05Z","type":"event_msg","payload":{"type":"agent_message","message":"The parser test fails."}}
+{"timestamp":"2025-08-01T10:00:05Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"The parser test fails."}]}}
+`
+
+func TestParse_ClaudeCode(t *testing.T) {
+	convs, err := Parse(FormatClaudeCode, strings.NewReader(claudeCodeTranscript))
+	require.NoError(t, err)
+	require.Len(t, convs, 1)
+	conv := convs[0]
+	require.Equal(t, "Fix the failing parser test", conv.Title)
+	require.Equal(t, []message.MessageRole{message.User, message.Assistant, message.Tool, message.Assistant, message.Tool}, roles(conv))
+
+	assistant := conv.Messages[1]
+	require.Equal(t, "claude-sonnet-4-20250514", assistant.Model)
+	require.Equal(t, "anthropic", assistant.Provider)
+	require.Equal(t, message.TextContent{Text: "Let me fix the off-by-one."}, assistant.Parts[0])
+	call := assistant.Parts[1].(message.ToolCall)
+	require.Equal(t, tools.EditToolName, call.Name)
+	require.Equal(t, message.FinishReasonToolUse, assistant.Parts[2].(message.Finish).Reason)
+
+	result := conv.Messages[2].Parts[0].(message.ToolResult)
+	require.Equal(t, "toolu_1", result.ToolCallID)
+	require.Equal(t, tools.EditToolName, result.Name)
+	var meta tools.EditResponseMetadata
+	require.NoError(t, json.Unmarshal([]byte(result.Metadata), &meta))
+	require.Equal(t, tools.EditResponseMetadata{Additions: 1, Removals: 1, OldContent: "i <= n", NewContent: "i < n"}, meta)
+
+	// The last call was interrupted, it gets a result anyway.
+	require.Equal(t, tools.BashToolName, conv.Messages[3].Parts[0].(message.ToolCall).Name)
+	require.True(t, conv.Messages[4].Parts[0].(message.ToolResult).IsError)
+}
+
+func TestParse_Aider(t *testing.T) {
+	convs, err := Parse(FormatAider, strings.NewReader(aiderHistory))
+	require.NoError(t, err)
+	require.Len(t, convs, 2)
+
+	first := convs[0]
+	require.Equal(t, "fix the off-by-one", first.Title)
+	require.Equal(t, []message.MessageRole{message.User, message.Assistant, message.Tool}, roles(first))
+	require.Equal(t, message.TextContent{Text: "fix the off-by-one\nin the parser"}, first.Messages[0].Parts[0])
+	answer := first.Messages[1]
+	require.Equal(t, "gpt-4o", answer.Model)
+	require.Contains(t, answer.Parts[0].(message.TextContent).Text, "> not aider output")
+	call := answer.Parts[1].(message.ToolCall)
+	require.Equal(t, tools.EditToolName, call.Name)
+	require.JSONEq(t, `{"file_path": "parser.go", "old_string": "", "new_string": ""}`, call.Input)
+	require.Equal(t, "Applied edit to parser.go", first.Messages[2].Parts[0].(message.ToolResult).Content)
+
+	second := convs[1]
+	require.Equal(t, "what does the lexer do?", second.Title)
+	require.Equal(t, []message.MessageRole{message.User, message.Assistant}, roles(second))
+	require.Equal(t, message.TextContent{Text: "It splits the input into tokens."}, second.Messages[1].Parts[0])
+}
+
+func TestParse_Codex(t *testing.T) {
+	convs, err := Parse(FormatCodex, strings.NewReader(codexRollout))
+	require.NoError(t, err)
+	require.Len(t, convs, 1)
+	conv := convs[0]
+	require.Equal(t, "Run the tests", conv.Title)
+	require.Equal(t, []message.MessageRole{message.User, message.Assistant, message.Tool, message.Assistant}, roles(conv))
+
+	call := conv.Messages[1].Parts[0].(message.ToolCall)
+	require.Equal(t, tools.BashToolName, call.Name)
+	require.JSONEq(t, `{"command": "go test ./...", "timeout": 120000}`, call.Input)
+	require.Equal(t, "gpt-5", conv.Messages[1].Model)
+	result := conv.Messages[2].Parts[0].(message.ToolResult)
+	require.Equal(t, "FAIL parser", result.Content)
+	require.True(t, result.IsError)
+	require.Equal(t, message.TextContent{Text: "The parser test fails."}, conv.Messages[3].Parts[0])
+}
+
+func TestSources_Codex(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CODEX_HOME", home)
+	dir := filepath.Join(home, "sessions", "2025", "08", "01")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	other := filepath.Join(dir, "rollout-2025-08-01T10-00-00-abc.jsonl")
+	require.NoError(t, os.WriteFile(other, []byte(strings.ReplaceAll(codexRollout, "/work/project", filepath.ToSlash(t.TempDir()))), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rollout-2025-08-01T11-00-00-def.jsonl"), []byte(codexRollout), 0o644))
+
+	paths, err := Sources(FormatCodex, "/work/project")
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "rollout-2025-08-01T11-00-00-def.jsonl")}, paths)
+}
+
+func TestImport(t *testing.T) {
+	store, err := db.OpenSQLiteStore(t.Context(), memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		store.Close()
+	})
+	sessions, messages := session.NewService(store), message.NewService(store)
+
+	convs, err := Parse(FormatClaudeCode, strings.NewReader(claudeCodeTranscript))
+	require.NoError(t, err)
+	imported, err := Import(t.Context(), sessions, messages, convs)
+	require.NoError(t, err)
+	require.Len(t, imported, 1)
+
+	msgs, err := messages.List(t.Context(), imported[0].ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 5)
+	require.Equal(t, "The parser test fails, fix it", msgs[0].Content().String())
+	require.Equal(t, "toolu_1", msgs[1].ToolCalls()[0].ID)
+	require.Equal(t, "toolu_1", msgs[2].ToolResults()[0].ToolCallID)
+}
+
+func roles(conv Conversation) []message.MessageRole {
+	var roles []message.MessageRole
+	for _, msg := range conv.Messages {
+		roles = append(roles, msg.Role)
+	}
+	return roles
+}
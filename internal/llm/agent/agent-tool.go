@@ -4,51 +4,145 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/session"
 )
 
 type agentTool struct {
-	agent    Service
+	// agents are the sub-agents by name, the built-in task agent included.
+	agents   map[string]Service
 	sessions session.Service
 	messages message.Service
 }
 
 const (
 	AgentToolName = "agent"
+
+	defaultSubAgent = "task"
+	maxAgentTasks   = 8
 )
 
 type AgentParams struct {
+	Prompt   string      `json:"prompt,omitempty"`
+	Agent    string      `json:"agent,omitempty"`
+	Tasks    []AgentTask `json:"tasks,omitempty"`
+	Parallel bool        `json:"parallel,omitempty"`
+}
+
+// AgentTask is a task handed to a sub-agent.
+type AgentTask struct {
+	Agent  string `json:"agent,omitempty"`
 	Prompt string `json:"prompt"`
 }
 
+// AllTasks returns the tasks of the call, the single prompt first.
+func (p AgentParams) AllTasks() []AgentTask {
+	if p.Prompt == "" {
+		return p.Tasks
+	}
+	return append([]AgentTask{{Agent: p.Agent, Prompt: p.Prompt}}, p.Tasks...)
+}
+
+// TaskSessionID returns the ID of the session the i-th task of the agent tool
+// call runs in. The first one is the ID of the call, for the UI to find it.
+func TaskSessionID(toolCallID string, i int) string {
+	if i == 0 {
+		return toolCallID
+	}
+	return fmt.Sprintf("%s~%d", toolCallID, i+1)
+}
+
+// TaskToolCallID returns the ID of the agent tool call that started the task
+// session.
+func TaskToolCallID(sessionID string) string {
+	toolCallID, _, _ := strings.Cut(sessionID, "~")
+	return toolCallID
+}
+
 func (b *agentTool) Name() string {
 	return AgentToolName
 }
 
 func (b *agentTool) Info() tools.ToolInfo {
+	var agents strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(b.agents)) {
+		agentCfg := config.Get().Agents[name]
+		fmt.Fprintf(&agents, "- %s: %s Tools: %s\n", name, agentCfg.Description, strings.Join(agentCfg.AllowedTools, ", "))
+	}
 	return tools.ToolInfo{
 		Name:        AgentToolName,
-		Description: "Launch a new agent that has access to the following tools: GlobTool, GrepTool, LS, View. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the Agent tool to perform the search for you. For example:\n\n- If you are searching for a keyword like \"config\" or \"logger\", or for questions like \"which file does X?\", the Agent tool is strongly recommended\n- If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly\n- If you are searching for a specific class definition like \"class Foo\", use the GlobTool tool instead, to find the match more quickly\n\nUsage notes:\n1. Launch multiple agents concurrently whenever possible, to maximize performance; to do that, use a single message with multiple tool uses\n2. When the agent is done, it will return a single message back to you. The result returned by the agent is not visible to the user. To show the user the result, you should send a text message back to the user with a concise summary of the result.\n3. Each agent invocation is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.\n4. The agent's outputs should generally be trusted\n5. IMPORTANT: The agent can not use Bash, Replace, Edit, so can not modify files. If you want to use these tools, use them directly instead of going through the agent.",
+		Description: "Launch sub-agents, each working on a task in a context of its own with its own model and tools. The available agents are:\n\n" + agents.String() + "\nThe task agent is the default. When you are searching for a keyword or file and are not confident that you will find the right match on the first try, use the task agent to perform the search for you. For example:\n\n- If you are searching for a keyword like \"config\" or \"logger\", or for questions like \"which file does X?\", the Agent tool is strongly recommended\n- If you want to read a specific file path, use the View or GlobTool tool instead of the Agent tool, to find the match more quickly\n- If you are searching for a specific class definition like \"class Foo\", use the GlobTool tool instead, to find the match more quickly\n\nUsage notes:\n1. Give a single task with prompt and agent, or several with tasks. Tasks run one after the other, each given the results of the ones before it, such as a plan to carry out; set parallel to run independent tasks at the same time. The results of the tasks are returned together.\n2. When the agents are done, their results are returned to you. The results returned by the agents are not visible to the user. To show the user the results, you should send a text message back to the user with a concise summary of the results.\n3. Each task is stateless. You will not be able to send additional messages to the agent, nor will the agent be able to communicate with you outside of its final report. Therefore, your prompt should contain a highly detailed task description for the agent to perform autonomously and you should specify exactly what information the agent should return back to you in its final and only message to you.\n4. The agent's outputs should generally be trusted\n5. IMPORTANT: Agents can only use the tools listed for them. The task agent can not use Bash, Replace, Edit, so can not modify files. If you want to use these tools, use them directly instead of going through the task agent.",
 		Parameters: map[string]any{
 			"prompt": map[string]any{
 				"type":        "string",
 				"description": "The task for the agent to perform",
 			},
+			"agent": map[string]any{
+				"type":        "string",
+				"description": "The agent performing the task, defaults to task",
+			},
+			"tasks": map[string]any{
+				"type":        "array",
+				"description": fmt.Sprintf("Several tasks to hand to agents, up to %d", maxAgentTasks),
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"agent": map[string]any{
+							"type":        "string",
+							"description": "The agent performing the task, defaults to task",
+						},
+						"prompt": map[string]any{
+							"type":        "string",
+							"description": "The task for the agent to perform",
+						},
+					},
+					"required": []string{"prompt"},
+				},
+			},
+			"parallel": map[string]any{
+				"type":        "boolean",
+				"description": "Run the tasks at the same time instead of one after the other",
+			},
 		},
-		Required: []string{"prompt"},
 	}
 }
 
+// taskResult is the outcome of a task.
+type taskResult struct {
+	agent  string
+	prompt string
+	text   string
+	err    error
+}
+
 func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolResponse, error) {
 	var params AgentParams
 	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
 		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
 	}
-	if params.Prompt == "" {
-		return tools.NewTextErrorResponse("prompt is required"), nil
+	tasks := params.AllTasks()
+	if len(tasks) == 0 {
+		return tools.NewTextErrorResponse("prompt or tasks is required"), nil
+	}
+	if len(tasks) > maxAgentTasks {
+		return tools.NewTextErrorResponse(fmt.Sprintf("at most %d tasks can be given", maxAgentTasks)), nil
+	}
+	for i, task := range tasks {
+		if task.Prompt == "" {
+			return tools.NewTextErrorResponse(fmt.Sprintf("task %d has no prompt", i+1)), nil
+		}
+		if task.Agent == "" {
+			tasks[i].Agent = defaultSubAgent
+		} else if _, ok := b.agents[task.Agent]; !ok {
+			return tools.NewTextErrorResponse(fmt.Sprintf("unknown agent %q, expected one of %s", task.Agent, strings.Join(slices.Sorted(maps.Keys(b.agents)), ", "))), nil
+		}
 	}
 
 	sessionID, messageID := tools.GetContextValues(ctx)
@@ -56,51 +150,126 @@ func (b *agentTool) Run(ctx context.Context, call tools.ToolCall) (tools.ToolRes
 		return tools.ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
 
-	session, err := b.sessions.CreateTaskSession(ctx, call.ID, sessionID, "New Agent Session")
-	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error creating session: %s", err)
+	results := make([]taskResult, len(tasks))
+	if params.Parallel {
+		var wg sync.WaitGroup
+		for i, task := range tasks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = b.runTask(ctx, TaskSessionID(call.ID, i), sessionID, task, task.Prompt)
+			}()
+		}
+		wg.Wait()
+	} else {
+		for i, task := range tasks {
+			if err := ctx.Err(); err != nil {
+				results[i] = taskResult{agent: task.Agent, prompt: task.Prompt, err: err}
+				continue
+			}
+			prompt := task.Prompt
+			if i > 0 {
+				prompt += "\n\n<previous_results>\n" + formatResults(results[:i]) + "\n</previous_results>"
+			}
+			results[i] = b.runTask(ctx, TaskSessionID(call.ID, i), sessionID, task, prompt)
+		}
 	}
 
-	done, err := b.agent.Run(ctx, session.ID, params.Prompt)
+	// The costs of the tasks are added once they're all done, parallel
+	// tasks would otherwise overwrite each other's.
+	parentSession, err := b.sessions.Get(ctx, sessionID)
 	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error generating agent: %s", err)
+		return tools.ToolResponse{}, fmt.Errorf("error getting parent session: %s", err)
+	}
+	for i := range tasks {
+		updatedSession, err := b.sessions.Get(ctx, TaskSessionID(call.ID, i))
+		if err != nil {
+			continue
+		}
+		parentSession.Cost += updatedSession.Cost
+		parentSession.CacheCost += updatedSession.CacheCost
+		parentSession.ReasoningCost += updatedSession.ReasoningCost
 	}
-	result := <-done
-	if result.Error != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error generating agent: %s", result.Error)
+	_, err = b.sessions.Save(ctx, parentSession)
+	if err != nil {
+		return tools.ToolResponse{}, fmt.Errorf("error saving parent session: %s", err)
 	}
 
-	response := result.Message
-	if response.Role != message.Assistant {
-		return tools.NewTextErrorResponse("no response"), nil
+	if len(results) == 1 {
+		if results[0].err != nil {
+			return tools.ToolResponse{}, results[0].err
+		}
+		if results[0].text == "" {
+			return tools.NewTextErrorResponse("no response"), nil
+		}
+		return tools.NewTextResponse(results[0].text), nil
 	}
+	return tools.NewTextResponse(formatResults(results)), nil
+}
 
-	updatedSession, err := b.sessions.Get(ctx, session.ID)
-	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error getting session: %s", err)
+// runTask runs the task in a new session, the prompt may carry the results of
+// the tasks before it.
+func (b *agentTool) runTask(ctx context.Context, taskSessionID, parentSessionID string, task AgentTask, prompt string) taskResult {
+	result := taskResult{agent: task.Agent, prompt: task.Prompt}
+	title := "New Agent Session"
+	if task.Agent != defaultSubAgent {
+		title = fmt.Sprintf("New %s Session", task.Agent)
 	}
-	parentSession, err := b.sessions.Get(ctx, sessionID)
+	session, err := b.sessions.CreateTaskSession(ctx, taskSessionID, parentSessionID, title)
 	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error getting parent session: %s", err)
+		result.err = fmt.Errorf("error creating session: %s", err)
+		return result
 	}
 
-	parentSession.Cost += updatedSession.Cost
-
-	_, err = b.sessions.Save(ctx, parentSession)
+	done, err := b.agents[task.Agent].Run(ctx, session.ID, prompt)
 	if err != nil {
-		return tools.ToolResponse{}, fmt.Errorf("error saving parent session: %s", err)
+		result.err = fmt.Errorf("error generating agent: %s", err)
+		return result
+	}
+	agentResult := <-done
+	if agentResult.Error != nil {
+		result.err = fmt.Errorf("error generating agent: %s", agentResult.Error)
+		return result
+	}
+	if agentResult.Message.Role == message.Assistant {
+		result.text = agentResult.Message.Content().String()
 	}
-	return tools.NewTextResponse(response.Content().String()), nil
+	return result
+}
+
+// formatResults aggregates the results of the tasks.
+func formatResults(results []taskResult) string {
+	var sb strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "## Task %d (%s agent): %s\n\n", i+1, result.agent, firstLine(result.prompt))
+		switch {
+		case result.err != nil:
+			fmt.Fprintf(&sb, "Error: %s", result.err)
+		case result.text == "":
+			sb.WriteString("No response")
+		default:
+			sb.WriteString(result.text)
+		}
+	}
+	return sb.String()
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
 }
 
 func NewAgentTool(
-	agent Service,
+	agents map[string]Service,
 	sessions session.Service,
 	messages message.Service,
 ) tools.BaseTool {
 	return &agentTool{
 		sessions: sessions,
 		messages: messages,
-		agent:    agent,
+		agents:   agents,
 	}
 }
@@ -1,17 +1,24 @@
 package agent
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"os"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/checkpoint"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/docker"
+	"github.com/charmbracelet/crush/internal/git"
 	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/llm/prompt"
 	"github.com/charmbracelet/crush/internal/llm/provider"
@@ -21,8 +28,12 @@ import (
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/recorder"
 	"github.com/charmbracelet/crush/internal/session"
-	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/sqlquery"
+	"github.com/charmbracelet/crush/internal/testrunner"
+	"github.com/charmbracelet/crush/internal/websearch"
+	"golang.org/x/time/rate"
 )
 
 // Common errors
@@ -52,33 +63,78 @@ type AgentEvent struct {
 
 type Service interface {
 	pubsub.Suscriber[AgentEvent]
+	SubscribeProgress(ctx context.Context) <-chan pubsub.Event[tools.Progress]
+	// SubscribeLoop subscribes to the state changes of the agent loop.
+	SubscribeLoop(ctx context.Context) <-chan pubsub.Event[LoopEvent]
 	Model() catwalk.Model
 	Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error)
+	// RerunInterrupted runs the prompt of the turn of the session interrupted
+	// when Crush stopped again, see RecoverInterrupted.
+	RerunInterrupted(ctx context.Context, sessionID string) (<-chan AgentEvent, error)
 	Cancel(sessionID string)
 	CancelAll()
+	// EndSessions runs the session_end hooks of the sessions prompted since
+	// the agent started.
+	EndSessions(ctx context.Context)
 	IsSessionBusy(sessionID string) bool
 	IsBusy() bool
 	Summarize(ctx context.Context, sessionID string) error
 	UpdateModel() error
+	// Reload recreates the providers from the current config, picking up
+	// changed provider settings such as API keys.
+	Reload() error
 }
 
 type agent struct {
 	*pubsub.Broker[AgentEvent]
-	agentCfg config.Agent
-	sessions session.Service
-	messages message.Service
-	mcpTools []McpTool
+	progress *pubsub.Broker[tools.Progress]
+	// loopEvents publishes the events of the agent loop, see loop.go.
+	loopEvents  *pubsub.Broker[LoopEvent]
+	permissions permission.Service
+	agentCfg    config.Agent
+	sessions    session.Service
+	messages    message.Service
+	mcpTools    []McpTool
 
 	tools *csync.LazySlice[tools.BaseTool]
 
+	// The provider is recreated while the loops of other sessions run, they
+	// get a snapshot of it, see mainProvider.
 	provider   provider.Provider
 	providerID string
+	// The environment facts in the system prompt of the provider.
+	envFacts   prompt.EnvironmentFacts
+	providerMu sync.RWMutex
 
 	titleProvider       provider.Provider
 	summarizeProvider   provider.Provider
 	summarizeProviderID string
 
+	// digestProvider summarizes long tool outputs, see digest.go.
+	digestProvider provider.Provider
+	digestLimiter  *rate.Limiter
+	digestMu       sync.Mutex
+
 	activeRequests *csync.Map[string, context.CancelFunc]
+	// budgetApprovals are the budget limits the user agreed to go over, by
+	// root session ID.
+	budgetApprovals *csync.Map[string, string]
+
+	// checkpoints snapshots the working directory before the turns changing
+	// files, see checkpoint.go.
+	checkpoints *checkpoint.Store
+
+	// lspClients report the diagnostics of the changed files back to the
+	// agent, see diagnostics.go.
+	lspClients map[string]*lsp.Client
+
+	// nestedContext are the subdirectories whose instructions were given,
+	// by session ID and directory, see nested.go.
+	nestedContext *csync.Map[string, bool]
+
+	// prompted are the sessions prompted since the agent started, for the
+	// session_end hooks, see hooks.go.
+	prompted *csync.Map[string, bool]
 }
 
 var agentPromptMap = map[string]prompt.PromptID{
@@ -86,6 +142,21 @@ var agentPromptMap = map[string]prompt.PromptID{
 	"task":  prompt.PromptTask,
 }
 
+type options struct {
+	tools []tools.BaseTool
+}
+
+// Option configures the agent.
+type Option func(*options)
+
+// WithTools adds tools to the built-in ones, such as the tools of programs
+// embedding the agent. The allowed tools of the agent still apply.
+func WithTools(extraTools ...tools.BaseTool) Option {
+	return func(o *options) {
+		o.tools = append(o.tools, extraTools...)
+	}
+}
+
 func NewAgent(
 	ctx context.Context,
 	agentCfg config.Agent,
@@ -95,21 +166,30 @@ func NewAgent(
 	messages message.Service,
 	history history.Service,
 	lspClients map[string]*lsp.Client,
+	agentOpts ...Option,
 ) (Service, error) {
 	cfg := config.Get()
+	var o options
+	for _, opt := range agentOpts {
+		opt(&o)
+	}
 
 	var agentTool tools.BaseTool
-	if agentCfg.ID == "coder" {
+	if cfg.IsMainAgent(agentCfg.ID) {
 		taskAgentCfg := config.Get().Agents["task"]
 		if taskAgentCfg.ID == "" {
 			return nil, fmt.Errorf("task agent not found in config")
 		}
-		taskAgent, err := NewAgent(ctx, taskAgentCfg, permissions, sessions, messages, history, lspClients)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create task agent: %w", err)
+		subAgents := map[string]Service{}
+		for _, name := range append([]string{"task"}, slices.Sorted(maps.Keys(cfg.SubAgents))...) {
+			subAgent, err := NewAgent(ctx, cfg.Agents[name], permissions, sessions, messages, history, lspClients, agentOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create %s agent: %w", name, err)
+			}
+			subAgents[name] = subAgent
 		}
 
-		agentTool = NewAgentTool(taskAgent, sessions, messages)
+		agentTool = NewAgentTool(subAgents, sessions, messages)
 	}
 
 	providerCfg := config.Get().GetProviderForModel(agentCfg.Model)
@@ -122,52 +202,19 @@ func NewAgent(
 		return nil, fmt.Errorf("model not found for agent %s", agentCfg.Name)
 	}
 
-	promptID := agentPromptMap[agentCfg.ID]
-	if promptID == "" {
-		promptID = prompt.PromptDefault
+	systemMessage, err := systemPrompt(cfg, agentCfg, providerCfg.ID)
+	if err != nil {
+		return nil, err
 	}
 	opts := []provider.ProviderClientOption{
 		provider.WithModel(agentCfg.Model),
-		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, config.Get().Options.ContextPaths...)),
+		provider.WithSystemMessage(systemMessage),
 	}
 	agentProvider, err := provider.NewProvider(*providerCfg, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	smallModelCfg := cfg.Models[config.SelectedModelTypeSmall]
-	var smallModelProviderCfg *config.ProviderConfig
-	if smallModelCfg.Provider == providerCfg.ID {
-		smallModelProviderCfg = providerCfg
-	} else {
-		smallModelProviderCfg = cfg.GetProviderForModel(config.SelectedModelTypeSmall)
-
-		if smallModelProviderCfg.ID == "" {
-			return nil, fmt.Errorf("provider %s not found in config", smallModelCfg.Provider)
-		}
-	}
-	smallModel := cfg.GetModelByType(config.SelectedModelTypeSmall)
-	if smallModel.ID == "" {
-		return nil, fmt.Errorf("model %s not found in provider %s", smallModelCfg.Model, smallModelProviderCfg.ID)
-	}
-
-	titleOpts := []provider.ProviderClientOption{
-		provider.WithModel(config.SelectedModelTypeSmall),
-		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptTitle, smallModelProviderCfg.ID)),
-	}
-	titleProvider, err := provider.NewProvider(*smallModelProviderCfg, titleOpts...)
-	if err != nil {
-		return nil, err
-	}
-	summarizeOpts := []provider.ProviderClientOption{
-		provider.WithModel(config.SelectedModelTypeSmall),
-		provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, smallModelProviderCfg.ID)),
-	}
-	summarizeProvider, err := provider.NewProvider(*smallModelProviderCfg, summarizeOpts...)
-	if err != nil {
-		return nil, err
-	}
-
 	toolFn := func() []tools.BaseTool {
 		slog.Info("Initializing agent tools", "agent", agentCfg.ID)
 		defer func() {
@@ -177,13 +224,18 @@ func NewAgent(
 		cwd := cfg.WorkingDir()
 		allTools := []tools.BaseTool{
 			tools.NewBashTool(permissions, cwd),
+			tools.NewJobsTool(),
+			tools.NewTerminalTool(permissions, cwd),
 			tools.NewDownloadTool(permissions, cwd),
 			tools.NewEditTool(lspClients, permissions, history, cwd),
 			tools.NewMultiEditTool(lspClients, permissions, history, cwd),
+			tools.NewApplyPatchTool(lspClients, permissions, history, cwd),
+			tools.NewTransactionTool(lspClients, permissions, history, cwd),
 			tools.NewFetchTool(permissions, cwd),
 			tools.NewGlobTool(cwd),
 			tools.NewGrepTool(cwd),
 			tools.NewLsTool(permissions, cwd),
+			tools.NewMemoryTool(permissions, cwd, getMemoryStore(cfg)),
 			tools.NewSourcegraphTool(),
 			tools.NewViewTool(lspClients, permissions, cwd),
 			tools.NewWriteTool(lspClients, permissions, history, cwd),
@@ -191,9 +243,90 @@ func NewAgent(
 
 		mcpTools := GetMCPTools(ctx, permissions, cfg)
 		allTools = append(allTools, mcpTools...)
+		allTools = append(allTools, o.tools...)
+
+		if ws := cfg.Options.WebSearch; ws != nil {
+			searchProvider, err := websearch.New(*ws, cfg.Resolver())
+			if err != nil {
+				slog.Error("Failed to set up web search", "provider", ws.Provider, "error", err)
+			} else {
+				allTools = append(allTools, tools.NewWebSearchTool(permissions, searchProvider, ws.MaxResults, cwd))
+			}
+		}
+
+		if len(cfg.Databases) > 0 {
+			var databases []*sqlquery.DB
+			for _, name := range slices.Sorted(maps.Keys(cfg.Databases)) {
+				db, err := sqlquery.New(name, cfg.Databases[name], cfg.Resolver(), cwd)
+				if err != nil {
+					slog.Error("Failed to set up database", "database", name, "error", err)
+					continue
+				}
+				databases = append(databases, db)
+			}
+			if len(databases) > 0 {
+				allTools = append(allTools, tools.NewSQLTool(permissions, databases, cwd))
+			}
+		}
+
+		if index := getCodeSearchIndex(cfg); index != nil {
+			allTools = append(allTools, tools.NewCodeSearchTool(index))
+		}
+
+		if launcher := getBrowserLauncher(cfg); launcher != nil {
+			allTools = append(allTools, tools.NewBrowserTool(permissions, launcher, cwd))
+		}
+
+		if _, err := git.Open(ctx, cwd); err != nil {
+			slog.Debug("Git tool unavailable", "error", err)
+		} else {
+			allTools = append(allTools, tools.NewGitTool(permissions, cwd))
+		}
+
+		if f, remote := newForge(ctx, cfg, cwd); f != nil {
+			allTools = append(allTools, tools.NewForgeTool(permissions, f, remote, cwd))
+		}
+
+		if opts := cfg.Options.Tests; opts == nil || !opts.Disabled {
+			var runner string
+			var args []string
+			if opts != nil {
+				runner, args = opts.Runner, opts.Args
+			}
+			var err error
+			if runner == "" {
+				runner, err = testrunner.Detect(cwd)
+			}
+			if err != nil {
+				slog.Debug("Test tool unavailable", "error", err)
+			} else {
+				allTools = append(allTools, tools.NewTestTool(permissions, runner, args, cwd))
+			}
+		}
+
+		if opts := cfg.Options.Docker; opts == nil || !opts.Disabled {
+			var path string
+			var composeFiles []string
+			if opts != nil {
+				path, composeFiles = opts.Path, opts.ComposeFiles
+			}
+			if path, err := docker.Find(path); err != nil {
+				slog.Debug("Docker tool unavailable", "error", err)
+			} else {
+				allTools = append(allTools, tools.NewDockerTool(permissions, docker.New(path, cwd, composeFiles), cwd))
+			}
+		}
 
 		if len(lspClients) > 0 {
-			allTools = append(allTools, tools.NewDiagnosticsTool(lspClients))
+			allTools = append(allTools,
+				tools.NewDiagnosticsTool(lspClients),
+				tools.NewOutlineTool(lspClients, cwd),
+				tools.NewSymbolsTool(lspClients, cwd),
+				tools.NewDefinitionTool(lspClients, cwd),
+				tools.NewReferencesTool(lspClients, cwd),
+				tools.NewRenameTool(lspClients, permissions, history, cwd),
+				tools.NewCodeActionTool(lspClients, permissions, history, cwd),
+			)
 		}
 
 		if agentTool != nil {
@@ -213,19 +346,108 @@ func NewAgent(
 		return filteredTools
 	}
 
-	return &agent{
-		Broker:              pubsub.NewBroker[AgentEvent](),
-		agentCfg:            agentCfg,
-		provider:            agentProvider,
-		providerID:          string(providerCfg.ID),
-		messages:            messages,
-		sessions:            sessions,
-		titleProvider:       titleProvider,
-		summarizeProvider:   summarizeProvider,
-		summarizeProviderID: string(smallModelProviderCfg.ID),
-		activeRequests:      csync.NewMap[string, context.CancelFunc](),
-		tools:               csync.NewLazySlice(toolFn),
-	}, nil
+	a := &agent{
+		Broker:          pubsub.NewBroker[AgentEvent](),
+		progress:        pubsub.NewBroker[tools.Progress](),
+		loopEvents:      pubsub.NewBroker[LoopEvent](),
+		permissions:     permissions,
+		agentCfg:        agentCfg,
+		provider:        agentProvider,
+		providerID:      string(providerCfg.ID),
+		envFacts:        prompt.GetEnvironmentFacts(cfg.WorkingDir()),
+		messages:        messages,
+		sessions:        sessions,
+		digestLimiter:   rate.NewLimiter(rate.Every(time.Minute/defaultDigestRequestsPerMinute), 1),
+		activeRequests:  csync.NewMap[string, context.CancelFunc](),
+		budgetApprovals: csync.NewMap[string, string](),
+		tools:           csync.NewLazySlice(toolFn),
+		checkpoints:     newCheckpointStore(cfg, agentCfg),
+		lspClients:      lspClients,
+		nestedContext:   csync.NewMap[string, bool](),
+		prompted:        csync.NewMap[string, bool](),
+	}
+	if err := a.setRoutedProviders(cfg); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// systemPrompt returns the system prompt of the agent for the provider: the
+// prompt file of custom agents, the built-in prompt of the others.
+func systemPrompt(cfg *config.Config, agentCfg config.Agent, providerID string) (string, error) {
+	var systemMessage string
+	if agentCfg.PromptFile != "" {
+		content, err := os.ReadFile(agentCfg.PromptFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the prompt of agent %s: %w", agentCfg.Name, err)
+		}
+		systemMessage = prompt.CustomPrompt(string(content), cfg.Options.ContextPaths...)
+	} else {
+		promptID := agentPromptMap[agentCfg.ID]
+		if _, ok := cfg.SubAgents[agentCfg.ID]; ok {
+			promptID = prompt.PromptTask
+		}
+		if promptID == "" {
+			promptID = prompt.PromptDefault
+		}
+		systemMessage = prompt.GetPrompt(promptID, providerID, cfg.Options.ContextPaths...)
+	}
+	if agentCfg.Instructions != "" {
+		systemMessage += "\n\n" + agentCfg.Instructions
+	}
+	return systemMessage, nil
+}
+
+// newRoutedProvider creates the provider of the kind of requests, for the
+// model type they are routed to, returning its provider ID too.
+func newRoutedProvider(cfg *config.Config, kind config.RequestKind, promptID prompt.PromptID, opts ...provider.ProviderClientOption) (provider.Provider, string, error) {
+	modelType := cfg.ModelFor(kind)
+	selected := cfg.Models[modelType]
+	providerCfg := cfg.GetProviderForModel(modelType)
+	if providerCfg == nil {
+		return nil, "", fmt.Errorf("provider %s not found in config", selected.Provider)
+	}
+	if cfg.GetModelByType(modelType) == nil {
+		return nil, "", fmt.Errorf("model %s not found in provider %s", selected.Model, providerCfg.ID)
+	}
+	opts = append([]provider.ProviderClientOption{
+		provider.WithModel(modelType),
+		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID)),
+	}, opts...)
+	p, err := provider.NewProvider(*providerCfg, opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create %s provider: %w", kind, err)
+	}
+	return p, providerCfg.ID, nil
+}
+
+// setRoutedProviders creates the providers of the titles, summaries and
+// digests, see config.Routing.
+func (a *agent) setRoutedProviders(cfg *config.Config) error {
+	// We want the title to be short, so we limit the max tokens
+	titleProvider, _, err := newRoutedProvider(cfg, config.RequestTitle, prompt.PromptTitle,
+		provider.WithMaxTokens(40), provider.WithResponseSchema(titleSchema))
+	if err != nil {
+		return err
+	}
+	summarizeProvider, summarizeProviderID, err := newRoutedProvider(cfg, config.RequestSummarize, prompt.PromptSummarizer)
+	if err != nil {
+		return err
+	}
+	digestProvider, _, err := newRoutedProvider(cfg, config.RequestDigest, prompt.PromptDigest)
+	if err != nil {
+		return err
+	}
+	a.titleProvider = titleProvider
+	a.summarizeProvider = summarizeProvider
+	a.summarizeProviderID = summarizeProviderID
+	a.digestProvider = digestProvider
+	return nil
+}
+
+// SubscribeProgress subscribes to progress updates of running tool calls.
+func (a *agent) SubscribeProgress(ctx context.Context) <-chan pubsub.Event[tools.Progress] {
+	return a.progress.Subscribe(ctx)
 }
 
 func (a *agent) Model() catwalk.Model {
@@ -301,7 +523,7 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 		return fmt.Errorf("no response received from title provider")
 	}
 
-	title := strings.TrimSpace(strings.ReplaceAll(finalResponse.Content, "\n", " "))
+	title := strings.TrimSpace(strings.ReplaceAll(sessionTitle(finalResponse.Content), "\n", " "))
 	if title == "" {
 		return nil
 	}
@@ -311,6 +533,31 @@ func (a *agent) generateTitle(ctx context.Context, sessionID string, content str
 	return err
 }
 
+// titleSchema constrains the title of the models that support it.
+var titleSchema = provider.ResponseSchema{
+	Name:        "session_title",
+	Description: "The title of the session.",
+	Properties: map[string]any{
+		"title": map[string]any{
+			"type":        "string",
+			"description": "The title, at most 50 characters long",
+		},
+	},
+	Required: []string{"title"},
+}
+
+// sessionTitle returns the title of the answer, in JSON or, from the models
+// that don't support the schema, in plain text.
+func sessionTitle(content string) string {
+	var answer struct {
+		Title string `json:"title"`
+	}
+	if err := provider.DecodeResponse(content, &answer); err != nil || answer.Title == "" {
+		return content
+	}
+	return answer.Title
+}
+
 func (a *agent) err(err error) AgentEvent {
 	return AgentEvent{
 		Type:  AgentEventTypeError,
@@ -319,9 +566,6 @@ func (a *agent) err(err error) AgentEvent {
 }
 
 func (a *agent) Run(ctx context.Context, sessionID string, content string, attachments ...message.Attachment) (<-chan AgentEvent, error) {
-	if !a.Model().SupportsImages && attachments != nil {
-		attachments = nil
-	}
 	events := make(chan AgentEvent)
 	if a.IsSessionBusy(sessionID) {
 		return nil, ErrSessionBusy
@@ -354,7 +598,6 @@ func (a *agent) Run(ctx context.Context, sessionID string, content string, attac
 }
 
 func (a *agent) processGeneration(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) AgentEvent {
-	cfg := config.Get()
 	// List existing messages; if none, start title generation asynchronously.
 	msgs, err := a.messages.List(ctx, sessionID)
 	if err != nil {
@@ -375,64 +618,74 @@ func (a *agent) processGeneration(ctx context.Context, sessionID, content string
 	if err != nil {
 		return a.err(fmt.Errorf("failed to get session: %w", err))
 	}
-	if session.SummaryMessageID != "" {
-		summaryMsgInex := -1
-		for i, msg := range msgs {
-			if msg.ID == session.SummaryMessageID {
-				summaryMsgInex = i
-				break
-			}
-		}
-		if summaryMsgInex != -1 {
-			msgs = msgs[summaryMsgInex:]
-			msgs[0].Role = message.User
-		}
+	if session.ParentSessionID == "" {
+		a.startSession(ctx, sessionID, len(msgs) == 0)
 	}
+	msgs, start := compactedHistory(session, msgs)
+
+	a.refreshSystemPrompt()
 
 	userMsg, err := a.createUserMessage(ctx, sessionID, content, attachmentParts)
 	if err != nil {
 		return a.err(fmt.Errorf("failed to create user message: %w", err))
 	}
-	// Append the new user message to the conversation history.
+	defer startTurn(config.Get().Options.DataDirectory, InterruptedTurn{
+		SessionID:     sessionID,
+		UserMessageID: userMsg.ID,
+		Started:       time.Now(),
+	})()
+	// Append the new user message to the conversation history, after the
+	// memories relevant to it.
+	if memoriesMsg, ok := a.memoriesMessage(ctx, sessionID, content); ok {
+		msgs = append(msgs, memoriesMsg)
+	}
 	msgHistory := append(msgs, userMsg)
+	l := a.newLoop(sessionID, userMsg, msgHistory)
+	l.recorder.Prompt(sessionID, content)
+	l.historyStart = start
+	l.contextTokens = session.PromptTokens + session.CompletionTokens
+	return l.run(ctx)
+}
 
-	for {
-		// Check for cancellation before each iteration
-		select {
-		case <-ctx.Done():
-			return a.err(ctx.Err())
-		default:
-			// Continue processing
-		}
-		agentMessage, toolResults, err := a.streamAndHandleEvents(ctx, sessionID, msgHistory)
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				agentMessage.AddFinish(message.FinishReasonCanceled, "Request cancelled", "")
-				a.messages.Update(context.Background(), agentMessage)
-				return a.err(ErrRequestCancelled)
-			}
-			return a.err(fmt.Errorf("failed to process events: %w", err))
-		}
-		if cfg.Options.Debug {
-			slog.Info("Result", "message", agentMessage.FinishReason(), "toolResults", toolResults)
-		}
-		if (agentMessage.FinishReason() == message.FinishReasonToolUse) && toolResults != nil {
-			// We are not done, we need to respond with the tool response
-			msgHistory = append(msgHistory, agentMessage, *toolResults)
-			continue
-		}
-		if agentMessage.FinishReason() == "" {
-			// Kujtim: could not track down where this is happening but this means its cancelled
-			agentMessage.AddFinish(message.FinishReasonCanceled, "Request cancelled", "")
-			_ = a.messages.Update(context.Background(), agentMessage)
-			return a.err(ErrRequestCancelled)
-		}
-		return AgentEvent{
-			Type:    AgentEventTypeResponse,
-			Message: agentMessage,
-			Done:    true,
-		}
+// refreshSystemPrompt recreates the provider with an up to date system
+// prompt if the environment facts in the current one are stale.
+func (a *agent) refreshSystemPrompt() {
+	cfg := config.Get()
+	current := prompt.GetEnvironmentFacts(cfg.WorkingDir())
+	a.providerMu.Lock()
+	defer a.providerMu.Unlock()
+	if !a.envFacts.Stale(current) {
+		return
+	}
+	providerCfg := cfg.GetProviderForModel(a.agentCfg.Model)
+	if providerCfg == nil {
+		return
+	}
+	systemMessage, err := systemPrompt(cfg, a.agentCfg, providerCfg.ID)
+	if err != nil {
+		slog.Error("Failed to refresh system prompt", "error", err)
+		return
 	}
+	opts := []provider.ProviderClientOption{
+		provider.WithModel(a.agentCfg.Model),
+		provider.WithSystemMessage(systemMessage),
+	}
+	newProvider, err := provider.NewProvider(*providerCfg, opts...)
+	if err != nil {
+		slog.Error("Failed to refresh system prompt", "error", err)
+		return
+	}
+	slog.Debug("Refreshed stale environment facts in system prompt", "agent", a.agentCfg.ID)
+	a.provider = newProvider
+	a.providerID = string(providerCfg.ID)
+	a.envFacts = current
+}
+
+// mainProvider returns the provider of the agent and its ID.
+func (a *agent) mainProvider() (provider.Provider, string) {
+	a.providerMu.RLock()
+	defer a.providerMu.RUnlock()
+	return a.provider, a.providerID
 }
 
 func (a *agent) createUserMessage(ctx context.Context, sessionID, content string, attachmentParts []message.ContentPart) (message.Message, error) {
@@ -444,155 +697,263 @@ func (a *agent) createUserMessage(ctx context.Context, sessionID, content string
 	})
 }
 
-func (a *agent) streamAndHandleEvents(ctx context.Context, sessionID string, msgHistory []message.Message) (message.Message, *message.Message, error) {
-	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
-	eventChan := a.provider.StreamResponse(ctx, msgHistory, slices.Collect(a.tools.Seq()))
+// createFeedbackMessage saves feedback for the model in a user message
+// marked as synthetic, so it isn't shown as typed by the user.
+func (l *loop) createFeedbackMessage(ctx context.Context, content string) (message.Message, error) {
+	return l.messages.Create(ctx, l.sessionID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: content, Synthetic: true}},
+	})
+}
 
-	assistantMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+// streamResponse streams the response of the model to the history into a new
+// assistant message.
+func (l *loop) streamResponse(ctx context.Context) error {
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, l.sessionID)
+	timer := newStreamTimer()
+	l.requestStarted = timer.started
+	history := l.withDigests(ctx, l.history)
+	agentTools := slices.Collect(l.tools.Seq())
+	var events []recorder.Event
+	if l.recorder != nil {
+		toolNames := make([]string, len(agentTools))
+		for i, tool := range agentTools {
+			toolNames[i] = tool.Name()
+		}
+		l.recorder.Request(l.sessionID, l.providerID, l.Model().ID, history, toolNames)
+		defer func() { l.recorder.Response(l.sessionID, events) }()
+	}
+	eventChan := l.provider.StreamResponse(ctx, history, agentTools)
+
+	assistantMsg, err := l.messages.Create(ctx, l.sessionID, message.CreateMessageParams{
 		Role:     message.Assistant,
 		Parts:    []message.ContentPart{},
-		Model:    a.Model().ID,
-		Provider: a.providerID,
+		Model:    l.Model().ID,
+		Provider: l.providerID,
 	})
+	l.assistantMsg = assistantMsg
 	if err != nil {
-		return assistantMsg, nil, fmt.Errorf("failed to create assistant message: %w", err)
+		return fmt.Errorf("failed to create assistant message: %w", err)
 	}
 
-	// Add the session and message ID into the context if needed by tools.
-	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
-
 	// Process each event in the stream.
 	for event := range eventChan {
-		if processErr := a.processEvent(ctx, sessionID, &assistantMsg, event); processErr != nil {
+		timer.observe(event)
+		if l.recorder != nil {
+			events = append(events, recorder.NewEvent(event, timer.started))
+		}
+		if processErr := l.processEvent(ctx, event); processErr != nil {
+			l.recordMetric(ctx, timer, processErr)
 			if errors.Is(processErr, context.Canceled) {
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+				l.finishMessage(context.Background(), &l.assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
 			} else {
-				a.finishMessage(ctx, &assistantMsg, message.FinishReasonError, "API Error", processErr.Error())
+				l.finishMessage(ctx, &l.assistantMsg, message.FinishReasonError, "API Error", processErr.Error())
 			}
-			return assistantMsg, nil, processErr
+			return processErr
 		}
 		if ctx.Err() != nil {
-			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			return assistantMsg, nil, ctx.Err()
+			l.finishMessage(context.Background(), &l.assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+			return ctx.Err()
 		}
 	}
+	l.recordMetric(ctx, timer, nil)
+	return nil
+}
 
-	toolResults := make([]message.ToolResult, len(assistantMsg.ToolCalls()))
-	toolCalls := assistantMsg.ToolCalls()
-	for i, toolCall := range toolCalls {
-		select {
-		case <-ctx.Done():
-			a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-			// Make all future tool calls cancelled
-			for j := i; j < len(toolCalls); j++ {
-				toolResults[j] = message.ToolResult{
-					ToolCallID: toolCalls[j].ID,
-					Content:    "Tool execution canceled by user",
-					IsError:    true,
-				}
-			}
-			goto out
-		default:
-			// Continue processing
-			var tool tools.BaseTool
-			for availableTool := range a.tools.Seq() {
-				if availableTool.Info().Name == toolCall.Name {
-					tool = availableTool
-					break
-				}
-			}
-
-			// Tool not found
-			if tool == nil {
-				toolResults[i] = message.ToolResult{
-					ToolCallID: toolCall.ID,
-					Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
-					IsError:    true,
-				}
-				continue
-			}
+// runToolCalls runs the tool calls of the assistant message, and returns the
+// message with their results.
+func (l *loop) runToolCalls(ctx context.Context) (*message.Message, error) {
+	sessionID := l.sessionID
+	assistantMsg := &l.assistantMsg
+	// Add the session and message ID into the context if needed by tools.
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+	ctx = context.WithValue(ctx, tools.MessageIDContextKey, assistantMsg.ID)
 
-			// Run tool in goroutine to allow cancellation
-			type toolExecResult struct {
-				response tools.ToolResponse
-				err      error
-			}
-			resultChan := make(chan toolExecResult, 1)
-
-			go func() {
-				response, err := tool.Run(ctx, tools.ToolCall{
-					ID:    toolCall.ID,
-					Name:  toolCall.Name,
-					Input: toolCall.Input,
-				})
-				resultChan <- toolExecResult{response: response, err: err}
-			}()
-
-			var toolResponse tools.ToolResponse
-			var toolErr error
-
-			select {
-			case <-ctx.Done():
-				a.finishMessage(context.Background(), &assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
-				// Mark remaining tool calls as cancelled
-				for j := i; j < len(toolCalls); j++ {
-					toolResults[j] = message.ToolResult{
-						ToolCallID: toolCalls[j].ID,
-						Content:    "Tool execution canceled by user",
-						IsError:    true,
-					}
-				}
-				goto out
-			case result := <-resultChan:
-				toolResponse = result.response
-				toolErr = result.err
-			}
+	defer l.watchPermissions(ctx)()
 
-			if toolErr != nil {
-				slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", toolErr)
-				if errors.Is(toolErr, permission.ErrorPermissionDenied) {
-					toolResults[i] = message.ToolResult{
-						ToolCallID: toolCall.ID,
-						Content:    "Permission denied",
-						IsError:    true,
-					}
-					for j := i + 1; j < len(toolCalls); j++ {
-						toolResults[j] = message.ToolResult{
-							ToolCallID: toolCalls[j].ID,
-							Content:    "Tool execution canceled by user",
-							IsError:    true,
-						}
-					}
-					a.finishMessage(ctx, &assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
-					break
-				}
-			}
-			toolResults[i] = message.ToolResult{
-				ToolCallID: toolCall.ID,
-				Content:    toolResponse.Content,
-				Metadata:   toolResponse.Metadata,
-				IsError:    toolResponse.IsError,
-			}
+	toolCalls := assistantMsg.ToolCalls()
+	toolResults := make([]message.ToolResult, len(toolCalls))
+	limit := maxConcurrentTools()
+	for start := 0; start < len(toolCalls); {
+		end := toolCallGroupEnd(toolCalls, start)
+		denied := l.runToolCallGroup(ctx, toolCalls[start:end], toolResults[start:end], limit)
+		if ctx.Err() != nil {
+			l.finishMessage(context.Background(), assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+			cancelToolCalls(toolCalls, toolResults)
+			break
 		}
+		if denied {
+			cancelToolCalls(toolCalls, toolResults)
+			l.finishMessage(ctx, assistantMsg, message.FinishReasonPermissionDenied, "Permission denied", "")
+			break
+		}
+		start = end
 	}
-out:
+
 	if len(toolResults) == 0 {
-		return assistantMsg, nil, nil
+		return nil, nil
 	}
 	parts := make([]message.ContentPart, 0)
 	for _, tr := range toolResults {
 		parts = append(parts, tr)
 	}
-	msg, err := a.messages.Create(context.Background(), assistantMsg.SessionID, message.CreateMessageParams{
+	msg, err := l.messages.Create(context.Background(), assistantMsg.SessionID, message.CreateMessageParams{
 		Role:     message.Tool,
 		Parts:    parts,
-		Provider: a.providerID,
+		Provider: l.providerID,
 	})
 	if err != nil {
-		return assistantMsg, nil, fmt.Errorf("failed to create cancelled tool message: %w", err)
+		return nil, fmt.Errorf("failed to create cancelled tool message: %w", err)
+	}
+
+	return &msg, err
+}
+
+// cancelToolCalls sets the results of the calls that didn't run.
+func cancelToolCalls(toolCalls []message.ToolCall, toolResults []message.ToolResult) {
+	for i, toolCall := range toolCalls {
+		if toolResults[i].ToolCallID == "" {
+			toolResults[i] = message.ToolResult{
+				ToolCallID: toolCall.ID,
+				Content:    "Tool execution canceled by user",
+				IsError:    true,
+			}
+		}
+	}
+}
+
+// runToolCall runs a tool call and returns its result, with the error of the
+// tool when the user denied it a permission or the context was done.
+func (l *loop) runToolCall(ctx context.Context, toolCall message.ToolCall) (message.ToolResult, error) {
+	sessionID := l.sessionID
+	requested := l.event(LoopEventToolRequested)
+	requested.ToolCall = &toolCall
+	l.publish(requested)
+	l.recorder.ToolCall(sessionID, toolCall)
+
+	var tool tools.BaseTool
+	for availableTool := range l.tools.Seq() {
+		if availableTool.Info().Name == toolCall.Name {
+			tool = availableTool
+			break
+		}
+	}
+
+	// Tool not found
+	if tool == nil {
+		result := message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    fmt.Sprintf("Tool not found: %s", toolCall.Name),
+			IsError:    true,
+		}
+		l.publishToolResult(result)
+		return result, nil
 	}
 
-	return assistantMsg, &msg, err
+	// Calls denied by a rule fail, for the model to try another way.
+	if verdict := l.permissions.Evaluate(sessionID, toolCall.ID, toolCall.Name, toolCall.Input); verdict.Decision == config.PermissionDeny {
+		result := message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    fmt.Sprintf("Denied by the permission rule %q", verdict.Rule),
+			IsError:    true,
+		}
+		l.publishToolResult(result)
+		return result, nil
+	}
+	if veto := l.preToolCallVeto(ctx, toolCall); veto != "" {
+		result := message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    veto,
+			IsError:    true,
+		}
+		l.publishToolResult(result)
+		return result, nil
+	}
+
+	// Run tool in goroutine to allow cancellation
+	type toolExecResult struct {
+		response tools.ToolResponse
+		err      error
+	}
+	resultChan := make(chan toolExecResult, 1)
+
+	go func() {
+		ctx := tools.WithProgressFunc(ctx, l.progressFunc(sessionID, toolCall.ID))
+		response, err := tool.Run(ctx, tools.ToolCall{
+			ID:    toolCall.ID,
+			Name:  toolCall.Name,
+			Input: toolCall.Input,
+		})
+		resultChan <- toolExecResult{response: response, err: err}
+	}()
+
+	var toolResponse tools.ToolResponse
+	select {
+	case <-ctx.Done():
+		return message.ToolResult{
+			ToolCallID: toolCall.ID,
+			Content:    "Tool execution canceled by user",
+			IsError:    true,
+		}, ctx.Err()
+	case result := <-resultChan:
+		toolResponse = result.response
+		if result.err != nil {
+			slog.Error("Tool execution error", "toolCall", toolCall.ID, "error", result.err)
+			if errors.Is(result.err, permission.ErrorPermissionDenied) {
+				denied := message.ToolResult{
+					ToolCallID: toolCall.ID,
+					Content:    "Permission denied",
+					IsError:    true,
+				}
+				l.publishToolResult(denied)
+				return denied, result.err
+			}
+		}
+	}
+
+	result := message.ToolResult{
+		ToolCallID: toolCall.ID,
+		Content:    toolResponse.Content,
+		Metadata:   toolResponse.Metadata,
+		IsError:    toolResponse.IsError,
+		Citations:  toolResponse.Citations,
+	}
+	l.publishToolResult(result)
+	return result, nil
+}
+
+func (l *loop) publishToolResult(result message.ToolResult) {
+	l.toolCallFinished(result.ToolCallID)
+	l.recorder.ToolResult(l.sessionID, result)
+	finished := l.event(LoopEventToolFinished)
+	finished.ToolResult = &result
+	l.publish(finished)
+}
+
+// progressInterval limits how often progress updates of a tool call are
+// published, tools may report them much more often. The output of the
+// updates not published is kept for the next one.
+const progressInterval = 100 * time.Millisecond
+
+func (a *agent) progressFunc(sessionID, toolCallID string) tools.ProgressFunc {
+	var mu sync.Mutex
+	var last time.Time
+	var output strings.Builder
+	return func(p tools.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		output.WriteString(p.Output)
+		done := p.Total > 0 && p.Current >= p.Total
+		if !done && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		p.Output = output.String()
+		output.Reset()
+		p.SessionID = sessionID
+		p.ToolCallID = toolCallID
+		a.progress.Publish(pubsub.UpdatedEvent, p)
+	}
 }
 
 func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishReason message.FinishReason, message, details string) {
@@ -600,7 +961,7 @@ func (a *agent) finishMessage(ctx context.Context, msg *message.Message, finishR
 	_ = a.messages.Update(ctx, *msg)
 }
 
-func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg *message.Message, event provider.ProviderEvent) error {
+func (l *loop) processEvent(ctx context.Context, event provider.ProviderEvent) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -608,66 +969,117 @@ func (a *agent) processEvent(ctx context.Context, sessionID string, assistantMsg
 		// Continue processing.
 	}
 
+	assistantMsg := &l.assistantMsg
 	switch event.Type {
 	case provider.EventThinkingDelta:
 		assistantMsg.AppendReasoningContent(event.Thinking)
-		return a.messages.Update(ctx, *assistantMsg)
+		l.publishDelta("", event.Thinking)
+		return l.messages.Update(ctx, *assistantMsg)
 	case provider.EventSignatureDelta:
 		assistantMsg.AppendReasoningSignature(event.Signature)
-		return a.messages.Update(ctx, *assistantMsg)
+		return l.messages.Update(ctx, *assistantMsg)
+	case provider.EventEncryptedReasoning:
+		assistantMsg.AppendEncryptedReasoning(*event.EncryptedReasoning)
+		return l.messages.Update(ctx, *assistantMsg)
 	case provider.EventContentDelta:
 		assistantMsg.FinishThinking()
 		assistantMsg.AppendContent(event.Content)
-		return a.messages.Update(ctx, *assistantMsg)
+		l.publishDelta(event.Content, "")
+		return l.messages.Update(ctx, *assistantMsg)
 	case provider.EventToolUseStart:
 		assistantMsg.FinishThinking()
 		slog.Info("Tool call started", "toolCall", event.ToolCall)
 		assistantMsg.AddToolCall(*event.ToolCall)
-		return a.messages.Update(ctx, *assistantMsg)
+		return l.messages.Update(ctx, *assistantMsg)
 	case provider.EventToolUseDelta:
 		assistantMsg.AppendToolCallInput(event.ToolCall.ID, event.ToolCall.Input)
-		return a.messages.Update(ctx, *assistantMsg)
+		return l.messages.Update(ctx, *assistantMsg)
 	case provider.EventToolUseStop:
 		slog.Info("Finished tool call", "toolCall", event.ToolCall)
 		assistantMsg.FinishToolCall(event.ToolCall.ID)
-		return a.messages.Update(ctx, *assistantMsg)
+		return l.messages.Update(ctx, *assistantMsg)
 	case provider.EventError:
 		return event.Error
 	case provider.EventComplete:
 		assistantMsg.FinishThinking()
 		assistantMsg.SetToolCalls(event.Response.ToolCalls)
 		assistantMsg.AddFinish(event.Response.FinishReason, "", "")
-		if err := a.messages.Update(ctx, *assistantMsg); err != nil {
+		if err := l.messages.Update(ctx, *assistantMsg); err != nil {
 			return fmt.Errorf("failed to update message: %w", err)
 		}
-		return a.TrackUsage(ctx, sessionID, a.Model(), event.Response.Usage)
+		return l.trackUsage(ctx, event.Response.Usage)
 	}
 
 	return nil
 }
 
-func (a *agent) TrackUsage(ctx context.Context, sessionID string, model catwalk.Model, usage provider.TokenUsage) error {
-	sess, err := a.sessions.Get(ctx, sessionID)
+func (l *loop) publishDelta(content, thinking string) {
+	delta := l.event(LoopEventStreamDelta)
+	delta.Content = content
+	delta.Thinking = thinking
+	l.publish(delta)
+}
+
+// trackUsage adds the usage of a response to the session and the usage
+// ledger.
+func (l *loop) trackUsage(ctx context.Context, usage provider.TokenUsage) error {
+	sess, err := l.sessions.Get(ctx, l.sessionID)
 	if err != nil {
 		return fmt.Errorf("failed to get session: %w", err)
 	}
 
-	cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-		model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-
+	model := l.Model()
+	cost, cacheCost, reasoningCost := usageCost(model, config.Get().GetPromptCache(l.modelType), usage)
 	sess.Cost += cost
+	sess.CacheCost += cacheCost
+	sess.ReasoningCost += reasoningCost
 	sess.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
 	sess.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
+	l.contextTokens = sess.PromptTokens + sess.CompletionTokens
 
-	_, err = a.sessions.Save(ctx, sess)
+	_, err = l.sessions.Save(ctx, sess)
 	if err != nil {
 		return fmt.Errorf("failed to save session: %w", err)
 	}
+	return l.recordUsage(ctx, l.sessionID, l.providerID, model.ID, usage, cost, time.Since(l.requestStarted))
+}
+
+// recordUsage adds a response, which took latency from its request, to the
+// usage ledger.
+func (a *agent) recordUsage(ctx context.Context, sessionID, providerID, modelID string, usage provider.TokenUsage, cost float64, latency time.Duration) error {
+	err := a.sessions.RecordUsage(ctx, session.Usage{
+		SessionID:    sessionID,
+		Provider:     providerID,
+		Model:        modelID,
+		InputTokens:  usage.InputTokens + usage.CacheCreationTokens + usage.CacheReadTokens,
+		OutputTokens: usage.OutputTokens,
+		CachedTokens: usage.CacheReadTokens,
+		Cost:         cost,
+		Latency:      latency,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
 	return nil
 }
 
+// usageCost returns the cost of the usage, the part of it spent on writing
+// and reading the prompt cache, and the part spent on reasoning.
+func usageCost(model catwalk.Model, promptCache *config.PromptCache, usage provider.TokenUsage) (cost, cacheCost, reasoningCost float64) {
+	writeCost, readCost := model.CostPer1MInCached, model.CostPer1MOutCached
+	if promptCache != nil {
+		writeCost = cmp.Or(promptCache.CostPer1MWrite, writeCost)
+		readCost = cmp.Or(promptCache.CostPer1MRead, readCost)
+	}
+	cacheCost = writeCost/1e6*float64(usage.CacheCreationTokens) +
+		readCost/1e6*float64(usage.CacheReadTokens)
+	cost = cacheCost +
+		model.CostPer1MIn/1e6*float64(usage.InputTokens) +
+		model.CostPer1MOut/1e6*float64(usage.OutputTokens)
+	reasoningCost = model.CostPer1MOut / 1e6 * float64(usage.ReasoningTokens)
+	return cost, cacheCost, reasoningCost
+}
+
 func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 	if a.summarizeProvider == nil {
 		return fmt.Errorf("summarize provider not available")
@@ -704,138 +1116,53 @@ func (a *agent) Summarize(ctx context.Context, sessionID string) error {
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
-		summarizeCtx = context.WithValue(summarizeCtx, tools.SessionIDContextKey, sessionID)
-
-		if len(msgs) == 0 {
+		sess, err := a.sessions.Get(summarizeCtx, sessionID)
+		if err != nil {
 			event = AgentEvent{
 				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("no messages to summarize"),
+				Error: fmt.Errorf("failed to get session: %w", err),
 				Done:  true,
 			}
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
+		history, start := compactedHistory(sess, msgs)
 
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Analyzing conversation...",
-		}
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Add a system message to guide the summarization
-		summarizePrompt := "Provide a detailed but concise summary of our conversation above. Focus on information that would be helpful for continuing the conversation, including what we did, what we're doing, which files we're working on, and what we're going to do next."
-
-		// Create a new message with the summarize prompt
-		promptMsg := message.Message{
-			Role:  message.User,
-			Parts: []message.ContentPart{message.TextContent{Text: summarizePrompt}},
-		}
-
-		// Append the prompt to the messages
-		msgsWithPrompt := append(msgs, promptMsg)
-
-		event = AgentEvent{
-			Type:     AgentEventTypeSummarize,
-			Progress: "Generating summary...",
-		}
-
-		a.Publish(pubsub.CreatedEvent, event)
-
-		// Send the messages to the summarize provider
-		response := a.summarizeProvider.StreamResponse(
-			summarizeCtx,
-			msgsWithPrompt,
-			nil,
-		)
-		var finalResponse *provider.ProviderResponse
-		for r := range response {
-			if r.Error != nil {
-				event = AgentEvent{
-					Type:  AgentEventTypeError,
-					Error: fmt.Errorf("failed to summarize: %w", err),
-					Done:  true,
-				}
-				a.Publish(pubsub.CreatedEvent, event)
-				return
-			}
-			finalResponse = r.Response
-		}
-
-		summary := strings.TrimSpace(finalResponse.Content)
-		if summary == "" {
+		if len(history) == 0 {
 			event = AgentEvent{
 				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("empty summary returned"),
+				Error: fmt.Errorf("no messages to summarize"),
 				Done:  true,
 			}
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
-		shell := shell.GetPersistentShell(config.Get().WorkingDir())
-		summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
+
 		event = AgentEvent{
 			Type:     AgentEventTypeSummarize,
-			Progress: "Creating new session...",
+			Progress: "Generating summary...",
 		}
-
 		a.Publish(pubsub.CreatedEvent, event)
-		oldSession, err := a.sessions.Get(summarizeCtx, sessionID)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to get session: %w", err),
-				Done:  true,
-			}
 
-			a.Publish(pubsub.CreatedEvent, event)
-			return
+		// The most recent messages are kept as they are, unless they are all
+		// there is.
+		_, err = a.compact(summarizeCtx, sessionID, history, start, false)
+		if errors.Is(err, errNothingToCompact) {
+			_, err = a.compact(summarizeCtx, sessionID, history, start, true)
 		}
-		// Create a message in the new session with the summary
-		msg, err := a.messages.Create(summarizeCtx, oldSession.ID, message.CreateMessageParams{
-			Role: message.Assistant,
-			Parts: []message.ContentPart{
-				message.TextContent{Text: summary},
-				message.Finish{
-					Reason: message.FinishReasonEndTurn,
-					Time:   time.Now().Unix(),
-				},
-			},
-			Model:    a.summarizeProvider.Model().ID,
-			Provider: a.summarizeProviderID,
-		})
 		if err != nil {
 			event = AgentEvent{
 				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to create summary message: %w", err),
+				Error: err,
 				Done:  true,
 			}
-
 			a.Publish(pubsub.CreatedEvent, event)
 			return
 		}
-		oldSession.SummaryMessageID = msg.ID
-		oldSession.CompletionTokens = finalResponse.Usage.OutputTokens
-		oldSession.PromptTokens = 0
-		model := a.summarizeProvider.Model()
-		usage := finalResponse.Usage
-		cost := model.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
-			model.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
-			model.CostPer1MIn/1e6*float64(usage.InputTokens) +
-			model.CostPer1MOut/1e6*float64(usage.OutputTokens)
-		oldSession.Cost += cost
-		_, err = a.sessions.Save(summarizeCtx, oldSession)
-		if err != nil {
-			event = AgentEvent{
-				Type:  AgentEventTypeError,
-				Error: fmt.Errorf("failed to save session: %w", err),
-				Done:  true,
-			}
-			a.Publish(pubsub.CreatedEvent, event)
-		}
 
 		event = AgentEvent{
 			Type:      AgentEventTypeSummarize,
-			SessionID: oldSession.ID,
+			SessionID: sessionID,
 			Progress:  "Summary complete",
 			Done:      true,
 		}
@@ -866,6 +1193,17 @@ func (a *agent) CancelAll() {
 }
 
 func (a *agent) UpdateModel() error {
+	return a.updateModel(false)
+}
+
+func (a *agent) Reload() error {
+	return a.updateModel(true)
+}
+
+// updateModel recreates the provider of the agent if its provider changed in
+// the config, or if force is set, and the providers of the background
+// requests.
+func (a *agent) updateModel(force bool) error {
 	cfg := config.Get()
 
 	// Get current provider configuration
@@ -875,21 +1213,22 @@ func (a *agent) UpdateModel() error {
 	}
 
 	// Check if provider has changed
-	if string(currentProviderCfg.ID) != a.providerID {
+	a.providerMu.Lock()
+	defer a.providerMu.Unlock()
+	if force || string(currentProviderCfg.ID) != a.providerID {
 		// Provider changed, need to recreate the main provider
 		model := cfg.GetModelByType(a.agentCfg.Model)
 		if model.ID == "" {
 			return fmt.Errorf("model not found for agent %s", a.agentCfg.Name)
 		}
 
-		promptID := agentPromptMap[a.agentCfg.ID]
-		if promptID == "" {
-			promptID = prompt.PromptDefault
+		systemMessage, err := systemPrompt(cfg, a.agentCfg, currentProviderCfg.ID)
+		if err != nil {
+			return err
 		}
-
 		opts := []provider.ProviderClientOption{
 			provider.WithModel(a.agentCfg.Model),
-			provider.WithSystemMessage(prompt.GetPrompt(promptID, currentProviderCfg.ID, cfg.Options.ContextPaths...)),
+			provider.WithSystemMessage(systemMessage),
 		}
 
 		newProvider, err := provider.NewProvider(*currentProviderCfg, opts...)
@@ -900,57 +1239,10 @@ func (a *agent) UpdateModel() error {
 		// Update the provider and provider ID
 		a.provider = newProvider
 		a.providerID = string(currentProviderCfg.ID)
+		a.envFacts = prompt.GetEnvironmentFacts(cfg.WorkingDir())
 	}
 
-	// Check if small model provider has changed (affects title and summarize providers)
-	smallModelCfg := cfg.Models[config.SelectedModelTypeSmall]
-	var smallModelProviderCfg config.ProviderConfig
-
-	for p := range cfg.Providers.Seq() {
-		if p.ID == smallModelCfg.Provider {
-			smallModelProviderCfg = p
-			break
-		}
-	}
-
-	if smallModelProviderCfg.ID == "" {
-		return fmt.Errorf("provider %s not found in config", smallModelCfg.Provider)
-	}
-
-	// Check if summarize provider has changed
-	if string(smallModelProviderCfg.ID) != a.summarizeProviderID {
-		smallModel := cfg.GetModelByType(config.SelectedModelTypeSmall)
-		if smallModel == nil {
-			return fmt.Errorf("model %s not found in provider %s", smallModelCfg.Model, smallModelProviderCfg.ID)
-		}
-
-		// Recreate title provider
-		titleOpts := []provider.ProviderClientOption{
-			provider.WithModel(config.SelectedModelTypeSmall),
-			provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptTitle, smallModelProviderCfg.ID)),
-			// We want the title to be short, so we limit the max tokens
-			provider.WithMaxTokens(40),
-		}
-		newTitleProvider, err := provider.NewProvider(smallModelProviderCfg, titleOpts...)
-		if err != nil {
-			return fmt.Errorf("failed to create new title provider: %w", err)
-		}
-
-		// Recreate summarize provider
-		summarizeOpts := []provider.ProviderClientOption{
-			provider.WithModel(config.SelectedModelTypeSmall),
-			provider.WithSystemMessage(prompt.GetPrompt(prompt.PromptSummarizer, smallModelProviderCfg.ID)),
-		}
-		newSummarizeProvider, err := provider.NewProvider(smallModelProviderCfg, summarizeOpts...)
-		if err != nil {
-			return fmt.Errorf("failed to create new summarize provider: %w", err)
-		}
-
-		// Update the providers and provider ID
-		a.titleProvider = newTitleProvider
-		a.summarizeProvider = newSummarizeProvider
-		a.summarizeProviderID = string(smallModelProviderCfg.ID)
-	}
-
-	return nil
+	// The background providers are cheap to create, and their routed models
+	// may have changed with the one of the agent.
+	return a.setRoutedProviders(cfg)
 }
@@ -0,0 +1,29 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageCost(t *testing.T) {
+	model := catwalk.Model{CostPer1MIn: 3, CostPer1MOut: 15, CostPer1MInCached: 3.75, CostPer1MOutCached: 0.3}
+	usage := provider.TokenUsage{
+		InputTokens:         1_000_000,
+		OutputTokens:        200_000,
+		CacheCreationTokens: 100_000,
+		CacheReadTokens:     1_000_000,
+		ReasoningTokens:     120_000,
+	}
+
+	cost, cacheCost, reasoningCost := usageCost(model, nil, usage)
+	require.InDelta(t, 3+3+0.375+0.3, cost, 1e-9)
+	require.InDelta(t, 0.675, cacheCost, 1e-9)
+	require.InDelta(t, 1.8, reasoningCost, 1e-9, "the reasoning is billed as output")
+
+	_, cacheCost, _ = usageCost(model, &config.PromptCache{CostPer1MRead: 0.5}, usage)
+	require.InDelta(t, 0.875, cacheCost, 1e-9)
+}
@@ -0,0 +1,281 @@
+// Package agenttest runs the agent end to end against scripted fake providers,
+// an in-memory database and a temporary working directory, so the behavior
+// of the agent can be tested without network access.
+//
+//	h := agenttest.New(t)
+//	h.Large.Script(
+//		agenttest.ToolCall("write", map[string]string{"file_path": h.Path("hello.txt"), "content": "hi"}),
+//		agenttest.Text("Done."),
+//	)
+//	turn, err := h.Run("Write hello.txt")
+package agenttest
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// ProviderID is the ID of the fake provider in the config.
+	ProviderID = "agenttest"
+	// ProviderType is the type registered for the fake providers.
+	ProviderType catwalk.Type = "agenttest"
+)
+
+var (
+	// LargeModel is the model of the agents.
+	LargeModel = catwalk.Model{
+		ID:               "agenttest-large",
+		Name:             "Agent Test Large",
+		ContextWindow:    200_000,
+		DefaultMaxTokens: 8_000,
+	}
+	// SmallModel is the model of the titles, summaries and digests.
+	SmallModel = catwalk.Model{
+		ID:               "agenttest-small",
+		Name:             "Agent Test Small",
+		ContextWindow:    100_000,
+		DefaultMaxTokens: 4_000,
+	}
+)
+
+// current is the harness the providers are created for. The config is
+// global, so harnesses can't run in parallel anyway.
+var current atomic.Pointer[Harness]
+
+func init() {
+	provider.Register(ProviderType, func(cfg config.ProviderConfig, modelType config.SelectedModelType) (provider.Provider, error) {
+		h := current.Load()
+		if h == nil {
+			return nil, errors.New("agenttest: no harness running")
+		}
+		if modelType == config.SelectedModelTypeSmall {
+			return h.Small, nil
+		}
+		return h.Large, nil
+	})
+}
+
+// Harness is an agent wired to fake providers and in-memory services.
+type Harness struct {
+	t   testing.TB
+	ctx context.Context
+
+	// WorkingDir is the temporary working directory of the agent.
+	WorkingDir string
+	Config     *config.Config
+
+	// Large answers the requests of the agents, Small the ones for titles,
+	// summaries and digests. Small answers with a fallback title by default.
+	Large *Provider
+	Small *Provider
+
+	Sessions    session.Service
+	Messages    message.Service
+	History     history.Service
+	Permissions permission.Service
+	Agent       agent.Service
+
+	// Session is the session the prompts are run in.
+	Session session.Session
+}
+
+type options struct {
+	permissionRequests bool
+	configure          []func(*config.Config)
+	agentName          string
+	agentOpts          []agent.Option
+}
+
+// Option configures the harness.
+type Option func(*options)
+
+// WithPermissionRequests makes the tools request permissions instead of
+// allowing everything. Requests are granted or denied with Permissions.
+func WithPermissionRequests() Option {
+	return func(o *options) {
+		o.permissionRequests = true
+	}
+}
+
+// WithConfig changes the config before the agent is created.
+func WithConfig(fn func(cfg *config.Config)) Option {
+	return func(o *options) {
+		o.configure = append(o.configure, fn)
+	}
+}
+
+// WithAgent runs the named main agent instead of the coder agent.
+func WithAgent(name string) Option {
+	return func(o *options) {
+		o.agentName = name
+	}
+}
+
+// WithAgentOptions passes the options to the agent, such as extra tools.
+func WithAgentOptions(agentOpts ...agent.Option) Option {
+	return func(o *options) {
+		o.agentOpts = append(o.agentOpts, agentOpts...)
+	}
+}
+
+// New returns a harness running the coder agent, or the one of WithAgent, in
+// a new session. Everything is torn down when the test ends.
+func New(t testing.TB, opts ...Option) *Harness {
+	t.Helper()
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx := t.Context()
+
+	workingDir := t.TempDir()
+	cfg := config.New(workingDir)
+	cfg.Options.DataDirectory = filepath.Join(t.TempDir(), "data")
+	cfg.Providers.Set(ProviderID, config.ProviderConfig{
+		ID:     ProviderID,
+		Name:   "Agent Test",
+		Type:   ProviderType,
+		Models: []catwalk.Model{LargeModel, SmallModel},
+	})
+	cfg.Models[config.SelectedModelTypeLarge] = config.SelectedModel{Model: LargeModel.ID, Provider: ProviderID}
+	cfg.Models[config.SelectedModelTypeSmall] = config.SelectedModel{Model: SmallModel.ID, Provider: ProviderID}
+	cfg.Permissions = &config.Permissions{SkipRequests: !o.permissionRequests}
+	for _, fn := range o.configure {
+		fn(cfg)
+	}
+
+	previous := config.Get()
+	config.Set(cfg)
+	t.Cleanup(func() {
+		agent.CloseMemory()
+		config.Set(previous)
+	})
+
+	store, err := db.OpenSQLiteStore(ctx, memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		store.Close()
+	})
+
+	h := &Harness{
+		t:           t,
+		ctx:         ctx,
+		WorkingDir:  workingDir,
+		Config:      cfg,
+		Large:       NewProvider(LargeModel),
+		Small:       NewProvider(SmallModel),
+		Sessions:    session.NewService(store),
+		Messages:    message.NewService(store),
+		History:     history.NewService(store),
+		Permissions: permission.NewPermissionService(workingDir, cfg.Permissions.SkipRequests, cfg.Permissions.AllowedTools, false, permission.WithRules(cfg.Permissions.Rules)),
+	}
+	h.Small.SetFallback(Text("Test session"))
+
+	current.Store(h)
+	t.Cleanup(func() {
+		current.CompareAndSwap(h, nil)
+	})
+	h.Agent, err = agent.NewAgent(ctx, cfg.Agents[cmp.Or(o.agentName, "coder")], h.Permissions, h.Sessions, h.Messages, h.History, nil, o.agentOpts...)
+	require.NoError(t, err)
+	t.Cleanup(h.Agent.CancelAll)
+
+	h.Session, err = h.Sessions.Create(ctx, "Test session")
+	require.NoError(t, err)
+	return h
+}
+
+// Turn is the outcome of a prompt.
+type Turn struct {
+	// Message is the final response of the agent.
+	Message message.Message
+	// Events are the events published by the agent loop during the turn.
+	Events []agent.LoopEvent
+}
+
+// Run runs the prompt in the session and waits for the turn to finish.
+func (h *Harness) Run(prompt string) (Turn, error) {
+	h.t.Helper()
+	ctx, cancel := context.WithCancel(h.ctx)
+	defer cancel()
+
+	loopEvents := h.Agent.SubscribeLoop(ctx)
+	done, err := h.Agent.Run(ctx, h.Session.ID, prompt)
+	if err != nil {
+		return Turn{}, err
+	}
+
+	var turn Turn
+	collect := func(event pubsub.Event[agent.LoopEvent]) bool {
+		if event.Payload.SessionID != h.Session.ID {
+			return false
+		}
+		turn.Events = append(turn.Events, event.Payload)
+		return event.Payload.Type == agent.LoopEventTurnFinished
+	}
+	for {
+		select {
+		case event := <-loopEvents:
+			collect(event)
+		case result := <-done:
+			// The turn is finished, the last events may still be buffered.
+			for finished := false; !finished; {
+				select {
+				case event := <-loopEvents:
+					finished = collect(event)
+				default:
+					finished = true
+				}
+			}
+			turn.Message = result.Message
+			return turn, result.Error
+		}
+	}
+}
+
+// Path returns the path of the file in the working directory.
+func (h *Harness) Path(name string) string {
+	return filepath.Join(h.WorkingDir, name)
+}
+
+// WriteFile writes the file in the working directory.
+func (h *Harness) WriteFile(name, content string) {
+	h.t.Helper()
+	path := h.Path(name)
+	require.NoError(h.t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(h.t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// ReadFile returns the content of the file in the working directory.
+func (h *Harness) ReadFile(name string) string {
+	h.t.Helper()
+	data, err := os.ReadFile(h.Path(name))
+	require.NoError(h.t, err)
+	return string(data)
+}
+
+// SessionMessages returns the messages of the session.
+func (h *Harness) SessionMessages() []message.Message {
+	h.t.Helper()
+	msgs, err := h.Messages.List(h.ctx, h.Session.ID)
+	require.NoError(h.t, err)
+	return msgs
+}
@@ -0,0 +1,75 @@
+package agenttest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHarnessText(t *testing.T) {
+	h := agenttest.New(t)
+	h.Large.Script(agenttest.Text("Hello!"))
+
+	turn, err := h.Run("Hi")
+	require.NoError(t, err)
+	require.Equal(t, "Hello!", turn.Message.Content().Text)
+	require.Equal(t, message.FinishReasonEndTurn, turn.Message.FinishReason())
+	require.Zero(t, h.Large.Remaining())
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 1)
+	require.Equal(t, "Hi", requests[0].Messages[0].Content().Text)
+	require.Contains(t, requests[0].Tools, "write")
+
+	require.Equal(t, agent.LoopEventTurnStarted, turn.Events[0].Type)
+	require.Equal(t, agent.LoopEventTurnFinished, turn.Events[len(turn.Events)-1].Type)
+	require.Len(t, h.SessionMessages(), 2)
+}
+
+func TestHarnessToolCalls(t *testing.T) {
+	h := agenttest.New(t)
+	h.WriteFile("notes.txt", "first line\n")
+	h.Large.Script(
+		agenttest.ToolCall("view", map[string]string{"file_path": h.Path("notes.txt")}),
+		agenttest.ToolCall("write", map[string]string{"file_path": h.Path("hello.txt"), "content": "hello\n"}),
+		agenttest.Text("Done."),
+	)
+
+	turn, err := h.Run("Read the notes and write hello.txt")
+	require.NoError(t, err)
+	require.Equal(t, "Done.", turn.Message.Content().Text)
+	require.Equal(t, "hello\n", h.ReadFile("hello.txt"))
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 3)
+	// The tool results are sent back to the model.
+	results := requests[1].Messages[len(requests[1].Messages)-1].ToolResults()
+	require.Len(t, results, 1)
+	require.Equal(t, "call_1", results[0].ToolCallID)
+	require.Contains(t, results[0].Content, "first line")
+	require.False(t, results[0].IsError)
+
+	var finished []string
+	for _, event := range turn.Events {
+		if event.Type == agent.LoopEventToolFinished {
+			finished = append(finished, event.ToolResult.ToolCallID)
+		}
+	}
+	require.Equal(t, []string{"call_1", "call_2"}, finished)
+}
+
+func TestHarnessProviderError(t *testing.T) {
+	h := agenttest.New(t)
+	h.Large.Script(agenttest.Step{Err: errors.New("overloaded")})
+
+	_, err := h.Run("Hi")
+	require.ErrorContains(t, err, "overloaded")
+
+	// Nothing is left in the script.
+	_, err = h.Run("Hi again")
+	require.ErrorContains(t, err, "no scripted response left")
+}
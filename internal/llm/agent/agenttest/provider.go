@@ -0,0 +1,209 @@
+package agenttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// Step is one scripted response of the fake provider.
+type Step struct {
+	Thinking  string
+	Content   string
+	ToolCalls []message.ToolCall
+	Usage     provider.TokenUsage
+	// Defaults to tool use when there are tool calls, end turn otherwise.
+	FinishReason message.FinishReason
+	// Err fails the request instead of responding.
+	Err error
+}
+
+// Text returns a step answering with the content.
+func Text(content string) Step {
+	return Step{Content: content}
+}
+
+// ToolCall returns a step calling the tool with the input encoded as JSON.
+// The ID of the call is generated when the step is sent.
+func ToolCall(name string, input any) Step {
+	data, err := json.Marshal(input)
+	if err != nil {
+		panic(fmt.Sprintf("agenttest: invalid input for tool %s: %v", name, err))
+	}
+	return Step{ToolCalls: []message.ToolCall{{Name: name, Input: string(data)}}}
+}
+
+// Request is a request received by the fake provider.
+type Request struct {
+	Messages []message.Message
+	Tools    []string
+}
+
+// Provider is a provider.Provider answering with scripted steps, one per
+// request, in order.
+type Provider struct {
+	model catwalk.Model
+
+	mu       sync.Mutex
+	steps    []Step
+	fallback *Step
+	requests []Request
+	calls    int
+}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// NewProvider returns a fake provider of the model answering with the steps.
+func NewProvider(model catwalk.Model, steps ...Step) *Provider {
+	return &Provider{model: model, steps: steps}
+}
+
+// Script adds steps to the script.
+func (p *Provider) Script(steps ...Step) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append(p.steps, steps...)
+}
+
+// SetFallback sets the step answered once the script is over. Without one,
+// the requests fail.
+func (p *Provider) SetFallback(step Step) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fallback = &step
+}
+
+// Remaining returns the number of steps left in the script.
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.steps)
+}
+
+// Requests returns the requests received so far.
+func (p *Provider) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	requests := make([]Request, len(p.requests))
+	copy(requests, p.requests)
+	return requests
+}
+
+func (p *Provider) Model() catwalk.Model {
+	return p.model
+}
+
+// next records the request and returns the step answering it.
+func (p *Provider) next(messages []message.Message, baseTools []tools.BaseTool) (Step, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	request := Request{Messages: make([]message.Message, len(messages))}
+	copy(request.Messages, messages)
+	for _, tool := range baseTools {
+		request.Tools = append(request.Tools, tool.Name())
+	}
+	p.requests = append(p.requests, request)
+
+	var step Step
+	switch {
+	case len(p.steps) > 0:
+		step = p.steps[0]
+		p.steps = p.steps[1:]
+	case p.fallback != nil:
+		step = *p.fallback
+	default:
+		return Step{}, fmt.Errorf("agenttest: no scripted response left for request %d", len(p.requests))
+	}
+	if step.Err != nil {
+		return Step{}, step.Err
+	}
+
+	toolCalls := make([]message.ToolCall, len(step.ToolCalls))
+	for i, call := range step.ToolCalls {
+		p.calls++
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", p.calls)
+		}
+		call.Type = "function"
+		call.Finished = true
+		toolCalls[i] = call
+	}
+	step.ToolCalls = toolCalls
+	if step.FinishReason == "" {
+		step.FinishReason = message.FinishReasonEndTurn
+		if len(toolCalls) > 0 {
+			step.FinishReason = message.FinishReasonToolUse
+		}
+	}
+	return step, nil
+}
+
+func (p *Provider) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*provider.ProviderResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	step, err := p.next(messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	return step.response(), nil
+}
+
+func (p *Provider) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan provider.ProviderEvent {
+	eventChan := make(chan provider.ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		send := func(event provider.ProviderEvent) bool {
+			select {
+			case eventChan <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		step, err := p.next(messages, tools)
+		if err != nil {
+			send(provider.ProviderEvent{Type: provider.EventError, Error: err})
+			return
+		}
+		if step.Thinking != "" && !send(provider.ProviderEvent{Type: provider.EventThinkingDelta, Thinking: step.Thinking}) {
+			return
+		}
+		if step.Content != "" {
+			if !send(provider.ProviderEvent{Type: provider.EventContentStart}) ||
+				!send(provider.ProviderEvent{Type: provider.EventContentDelta, Content: step.Content}) ||
+				!send(provider.ProviderEvent{Type: provider.EventContentStop}) {
+				return
+			}
+		}
+		for _, call := range step.ToolCalls {
+			start := call
+			start.Input = ""
+			start.Finished = false
+			if !send(provider.ProviderEvent{Type: provider.EventToolUseStart, ToolCall: &start}) ||
+				!send(provider.ProviderEvent{Type: provider.EventToolUseDelta, ToolCall: &message.ToolCall{ID: call.ID, Input: call.Input}}) ||
+				!send(provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCall: &call}) {
+				return
+			}
+		}
+		send(provider.ProviderEvent{Type: provider.EventComplete, Response: step.response()})
+	}()
+	return eventChan
+}
+
+func (s Step) response() *provider.ProviderResponse {
+	return &provider.ProviderResponse{
+		Content:      s.Content,
+		ToolCalls:    s.ToolCalls,
+		Usage:        s.Usage,
+		FinishReason: s.FinishReason,
+	}
+}
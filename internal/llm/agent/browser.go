@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/browser"
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+var (
+	browserMu       sync.Mutex
+	browserLauncher *browser.Launcher
+)
+
+// getBrowserLauncher returns the launcher of the browser shared by the
+// agents, or nil if the browser tool is disabled or no browser was found.
+func getBrowserLauncher(cfg *config.Config) *browser.Launcher {
+	opts := cfg.Options.Browser
+	if opts == nil {
+		opts = &config.Browser{}
+	}
+	if opts.Disabled {
+		return nil
+	}
+
+	browserMu.Lock()
+	defer browserMu.Unlock()
+	if browserLauncher != nil {
+		return browserLauncher
+	}
+	path, err := browser.Find(opts.Path)
+	if err != nil {
+		slog.Debug("Browser tool unavailable", "error", err)
+		return nil
+	}
+	browserLauncher = browser.NewLauncher(browser.Options{
+		Path:   path,
+		Headed: opts.Headed,
+		Args:   opts.Args,
+	})
+	return browserLauncher
+}
+
+// CloseBrowser closes the browser of the agents if launched.
+func CloseBrowser() {
+	browserMu.Lock()
+	l := browserLauncher
+	browserMu.Unlock()
+	if l != nil {
+		if err := l.Close(); err != nil {
+			slog.Error("Failed to close the browser", "error", err)
+		}
+	}
+}
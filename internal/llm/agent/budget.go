@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/google/uuid"
+)
+
+// BudgetToolName is the tool name of the permission requests to continue
+// once the budget is reached.
+const BudgetToolName = "budget"
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// BudgetUsage is what a session and all sessions of the day spent, against
+// the budget of the config.
+type BudgetUsage struct {
+	Budget config.Budget
+	// The root session, task sessions count towards their parent.
+	SessionID string
+	Session   session.UsageTotals
+	Day       string
+	Today     session.UsageTotals
+}
+
+// GetBudgetUsage returns the usage of the session, if any, and of the day. It
+// returns nil when no budget is configured.
+func GetBudgetUsage(ctx context.Context, sessions session.Service, sessionID string) (*BudgetUsage, error) {
+	budget := config.Get().Options.Budget
+	if budget == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	usage := &BudgetUsage{
+		Budget: *budget,
+		Day:    midnight.Format(time.DateOnly),
+	}
+	var err error
+	if usage.Today, err = sessions.UsageSince(ctx, midnight); err != nil {
+		return nil, fmt.Errorf("failed to get the usage of the day: %w", err)
+	}
+	if sessionID == "" {
+		return usage, nil
+	}
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	usage.SessionID = cmp.Or(sess.ParentSessionID, sess.ID)
+	if usage.Session, err = sessions.Usage(ctx, usage.SessionID); err != nil {
+		return nil, fmt.Errorf("failed to get the usage of the session: %w", err)
+	}
+	return usage, nil
+}
+
+// BudgetLimit is a limit of the budget and how much of it was used.
+type BudgetLimit struct {
+	// Name is session_cost, session_tokens, daily_cost, or daily_tokens.
+	Name string
+	// Key identifies the limit, a new day has new daily limits.
+	Key  string
+	Used float64
+	Max  float64
+}
+
+// Limits returns the limits set by the budget.
+func (u *BudgetUsage) Limits() []BudgetLimit {
+	b := u.Budget
+	var limits []BudgetLimit
+	add := func(name, key string, used, maxUsed float64) {
+		if maxUsed > 0 {
+			limits = append(limits, BudgetLimit{Name: name, Key: key, Used: used, Max: maxUsed})
+		}
+	}
+	add("session_cost", "session_cost", u.Session.Cost, b.SessionCost)
+	add("session_tokens", "session_tokens", float64(u.Session.Tokens), float64(b.SessionTokens))
+	add("daily_cost", "daily_cost:"+u.Day, u.Today.Cost, b.DailyCost)
+	add("daily_tokens", "daily_tokens:"+u.Day, float64(u.Today.Tokens), float64(b.DailyTokens))
+	return limits
+}
+
+// Describe describes the use of the limit for the user.
+func (l BudgetLimit) Describe() string {
+	switch l.Name {
+	case "session_cost":
+		return fmt.Sprintf("This session spent $%.2f of its $%.2f budget", l.Used, l.Max)
+	case "session_tokens":
+		return fmt.Sprintf("This session used %d of its %d tokens budget", int64(l.Used), int64(l.Max))
+	case "daily_cost":
+		return fmt.Sprintf("Today's sessions spent $%.2f of the $%.2f daily budget", l.Used, l.Max)
+	default:
+		return fmt.Sprintf("Today's sessions used %d of the %d tokens daily budget", int64(l.Used), int64(l.Max))
+	}
+}
+
+// Exceeded returns the first limit of the budget reached, if any, as a key
+// identifying it and a description for the user.
+func (u *BudgetUsage) Exceeded() (key, description string) {
+	for _, limit := range u.Limits() {
+		if limit.Used >= limit.Max {
+			return limit.Key, limit.Describe()
+		}
+	}
+	return "", ""
+}
+
+// checkBudget runs before each request to the model. Once a limit of the
+// budget is reached, it switches the turn to the small model or asks the user
+// to continue, as configured. It reports false when the turn is over.
+func (l *loop) checkBudget(ctx context.Context) bool {
+	if l.downgraded {
+		return true
+	}
+	usage, err := GetBudgetUsage(ctx, l.sessions, l.sessionID)
+	if err != nil {
+		slog.Error("Failed to check the budget", "session_id", l.sessionID, "error", err)
+		return true
+	}
+	if usage == nil {
+		return true
+	}
+	key, description := usage.Exceeded()
+	if key == "" {
+		return true
+	}
+	if approved, ok := l.budgetApprovals.Get(usage.SessionID); ok && approved == key {
+		return true
+	}
+
+	if usage.Budget.OnExceeded == config.BudgetExceededDowngrade {
+		err := l.downgrade()
+		if err == nil {
+			slog.Info("Budget reached, switched to the small model", "session_id", l.sessionID, "limit", key)
+			return true
+		}
+		slog.Warn("Failed to switch to the small model, asking to continue", "session_id", l.sessionID, "error", err)
+	}
+
+	if l.permissions == nil {
+		l.finish(l.err(fmt.Errorf("%w: %s", ErrBudgetExceeded, description)))
+		return false
+	}
+	defer l.watchPermissions(ctx)()
+	granted := l.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   l.sessionID,
+		ToolCallID:  BudgetToolName + "-" + uuid.NewString(),
+		ToolName:    BudgetToolName,
+		Description: description + ". Continue anyway?",
+		Action:      "continue",
+		Path:        config.Get().WorkingDir(),
+		// Going over budget always needs a person to agree.
+		DestructiveReason: description,
+	})
+	if !granted {
+		l.finish(l.err(fmt.Errorf("%w: %s", ErrBudgetExceeded, description)))
+		return false
+	}
+	l.budgetApprovals.Set(usage.SessionID, key)
+	return true
+}
+
+// downgrade switches the rest of the turn to the small model.
+func (l *loop) downgrade() error {
+	if l.modelType == config.SelectedModelTypeSmall {
+		return errors.New("already using the small model")
+	}
+	cfg := config.Get()
+	current, small := cfg.Models[l.modelType], cfg.Models[config.SelectedModelTypeSmall]
+	if current.Provider == small.Provider && current.Model == small.Model {
+		return errors.New("the small model is the same as the current one")
+	}
+	providerCfg := cfg.GetProviderForModel(config.SelectedModelTypeSmall)
+	if providerCfg == nil {
+		return errors.New("provider of the small model not found in config")
+	}
+	promptID := agentPromptMap[l.agentCfg.ID]
+	if promptID == "" {
+		promptID = prompt.PromptDefault
+	}
+	smallProvider, err := provider.NewProvider(*providerCfg,
+		provider.WithModel(config.SelectedModelTypeSmall),
+		provider.WithSystemMessage(prompt.GetPrompt(promptID, providerCfg.ID, cfg.Options.ContextPaths...)),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create the provider of the small model: %w", err)
+	}
+	l.provider = smallProvider
+	l.providerID = providerCfg.ID
+	l.modelType = config.SelectedModelTypeSmall
+	l.downgraded = true
+	return nil
+}
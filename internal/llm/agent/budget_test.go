@@ -0,0 +1,102 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func withBudget(budget config.Budget) agenttest.Option {
+	return agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Options.Budget = &budget
+	})
+}
+
+func spend(t *testing.T, h *agenttest.Harness, tokens int64) {
+	t.Helper()
+	require.NoError(t, h.Sessions.RecordUsage(t.Context(), session.Usage{
+		SessionID:   h.Session.ID,
+		Provider:    agenttest.ProviderID,
+		Model:       agenttest.LargeModel.ID,
+		InputTokens: tokens,
+	}))
+}
+
+func TestBudget_AskDenied(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithPermissionRequests(), withBudget(config.Budget{SessionTokens: 1000}))
+	h.Large.SetFallback(agenttest.Text("Done."))
+	spend(t, h, 1000)
+
+	requests := h.Permissions.Subscribe(t.Context())
+	go func() {
+		event := <-requests
+		h.Permissions.Deny(event.Payload)
+	}()
+
+	_, err := h.Run("Fix the tests")
+	require.ErrorIs(t, err, agent.ErrBudgetExceeded)
+	require.ErrorContains(t, err, "This session used 1000 of its 1000 tokens budget")
+	require.Empty(t, h.Large.Requests())
+}
+
+func TestBudget_AskGranted(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithPermissionRequests(), withBudget(config.Budget{SessionTokens: 1000}))
+	h.Large.SetFallback(agenttest.Text("Done."))
+	spend(t, h, 1000)
+
+	requests := h.Permissions.Subscribe(t.Context())
+	asked := make(chan string, 2)
+	go func() {
+		for event := range requests {
+			asked <- event.Payload.ToolName
+			h.Permissions.Grant(event.Payload)
+		}
+	}()
+
+	turn, err := h.Run("Fix the tests")
+	require.NoError(t, err)
+	require.Equal(t, "Done.", turn.Message.Content().Text)
+	require.Equal(t, agent.BudgetToolName, <-asked)
+
+	// The same limit isn't asked again in the session.
+	_, err = h.Run("Fix the other tests")
+	require.NoError(t, err)
+	require.Empty(t, asked)
+}
+
+func TestBudget_Downgrade(t *testing.T) {
+	h := agenttest.New(t, withBudget(config.Budget{
+		DailyTokens: 500,
+		OnExceeded:  config.BudgetExceededDowngrade,
+	}))
+	h.Large.SetFallback(agenttest.Text("Expensive answer."))
+	h.Small.SetFallback(agenttest.Text("Cheap answer."))
+	spend(t, h, 1000)
+
+	turn, err := h.Run("Fix the tests")
+	require.NoError(t, err)
+	require.Equal(t, "Cheap answer.", turn.Message.Content().Text)
+	require.Empty(t, h.Large.Requests())
+}
+
+func TestBudget_Usage(t *testing.T) {
+	h := agenttest.New(t, withBudget(config.Budget{SessionCost: 2}))
+	task, err := h.Sessions.CreateTaskSession(t.Context(), "task-call", h.Session.ID, "Search")
+	require.NoError(t, err)
+	require.NoError(t, h.Sessions.RecordUsage(t.Context(), session.Usage{SessionID: h.Session.ID, InputTokens: 100, Cost: 1}))
+	require.NoError(t, h.Sessions.RecordUsage(t.Context(), session.Usage{SessionID: task.ID, OutputTokens: 50, Cost: 1.5}))
+
+	usage, err := agent.GetBudgetUsage(t.Context(), h.Sessions, task.ID)
+	require.NoError(t, err)
+	require.Equal(t, h.Session.ID, usage.SessionID)
+	require.Equal(t, session.UsageTotals{Tokens: 150, InputTokens: 100, OutputTokens: 50, Cost: 2.5}, usage.Session)
+	require.Equal(t, session.UsageTotals{Tokens: 150, Cost: 2.5}, usage.Today)
+
+	key, description := usage.Exceeded()
+	require.Equal(t, "session_cost", key)
+	require.Equal(t, "This session spent $2.50 of its $2.00 budget", description)
+}
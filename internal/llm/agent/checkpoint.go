@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+const maxCheckpointMessageLength = 72
+
+// checkpointTools may change the files of the working directory, the
+// checkpoint of a turn is taken before the first of them runs.
+var checkpointTools = append([]string{tools.BashToolName, tools.GitToolName}, modifyingTools...)
+
+// newCheckpointStore returns the checkpoints of the working directory for
+// the main agents, nil for the sub-agents.
+func newCheckpointStore(cfg *config.Config, agentCfg config.Agent) *checkpoint.Store {
+	if !cfg.IsMainAgent(agentCfg.ID) {
+		return nil
+	}
+	return checkpoint.OpenProject(cfg)
+}
+
+// checkpoint snapshots the working directory before the first tool call of
+// the turn that may change it.
+func (l *loop) checkpoint(ctx context.Context) {
+	if l.checkpoints == nil || l.checkpointed {
+		return
+	}
+	if !slices.ContainsFunc(l.assistantMsg.ToolCalls(), func(tc message.ToolCall) bool {
+		return slices.Contains(checkpointTools, tc.Name)
+	}) {
+		return
+	}
+	l.checkpointed = true
+	checkpoint, created, err := l.checkpoints.Create(ctx, l.sessionID, l.checkpointMessage())
+	if err != nil {
+		slog.Warn("Failed to create checkpoint", "session_id", l.sessionID, "error", err)
+		return
+	}
+	if created {
+		slog.Debug("Created checkpoint", "session_id", l.sessionID, "checkpoint", checkpoint.ShortID())
+	}
+}
+
+// checkpointMessage is the first line of the prompt of the turn.
+func (l *loop) checkpointMessage() string {
+	var prompt string
+	for _, msg := range slices.Backward(l.history) {
+		if msg.ID == l.userMsgID {
+			prompt = msg.Content().Text
+			break
+		}
+	}
+	prompt, _, _ = strings.Cut(strings.TrimSpace(prompt), "\n")
+	if len(prompt) > maxCheckpointMessageLength {
+		prompt = strings.TrimSpace(prompt[:maxCheckpointMessageLength-3]) + "..."
+	}
+	if prompt == "" {
+		return "Turn of the agent"
+	}
+	return prompt
+}
@@ -0,0 +1,71 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/codesearch"
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+var (
+	codeSearchMu     sync.Mutex
+	codeSearchOpened bool
+	codeSearchIndex  *codesearch.Index
+	codeSearchCancel context.CancelFunc
+	codeSearchWG     sync.WaitGroup
+)
+
+// getCodeSearchIndex returns the index of the project shared by the agents,
+// opening it on first use and indexing the project in the background. It
+// returns nil if code search is disabled or unavailable.
+func getCodeSearchIndex(cfg *config.Config) *codesearch.Index {
+	if cfg.Options.CodeSearch == nil || !cfg.Options.CodeSearch.Enabled {
+		return nil
+	}
+
+	codeSearchMu.Lock()
+	defer codeSearchMu.Unlock()
+	if codeSearchOpened {
+		return codeSearchIndex
+	}
+	codeSearchOpened = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	index, err := codesearch.OpenProject(ctx, cfg)
+	if err != nil {
+		cancel()
+		slog.Warn("Code search unavailable", "error", err)
+		return nil
+	}
+	codeSearchIndex, codeSearchCancel = index, cancel
+
+	codeSearchWG.Add(1)
+	go func() {
+		defer codeSearchWG.Done()
+		stats, err := index.Update(ctx, nil)
+		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, codesearch.ErrUpdating) {
+			slog.Error("Failed to index the project", "error", err)
+			return
+		}
+		slog.Info("Indexed the project", "files", stats.Files, "chunks", stats.Chunks, "embedded", stats.Embedded, "removed", stats.Removed)
+	}()
+	return index
+}
+
+// CloseCodeSearch stops indexing the project and closes its index.
+func CloseCodeSearch() {
+	codeSearchMu.Lock()
+	defer codeSearchMu.Unlock()
+	if codeSearchIndex == nil {
+		return
+	}
+	codeSearchCancel()
+	codeSearchWG.Wait()
+	if err := codeSearchIndex.Close(); err != nil {
+		slog.Error("Failed to close the code search index", "error", err)
+	}
+	codeSearchIndex = nil
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/git"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// maxCommitPatch caps the patch sent to the model. Every changed file is
+// listed regardless.
+const maxCommitPatch = 100_000
+
+// commitSchema constrains the answer of the models that support it, small
+// local ones otherwise wrap the message in commentary.
+var commitSchema = provider.ResponseSchema{
+	Name:        "commit_message",
+	Description: "The commit message of the staged changes.",
+	Properties: map[string]any{
+		"subject": map[string]any{
+			"type":        "string",
+			"description": "The subject line, type(scope): summary",
+		},
+		"body": map[string]any{
+			"type":        "string",
+			"description": "The body wrapped at 72 characters, empty for trivial changes",
+		},
+	},
+	Required: []string{"subject", "body"},
+}
+
+// GenerateCommitMessage writes a Conventional Commits message for the diff
+// with the model of the commit requests, the small one by default.
+func GenerateCommitMessage(ctx context.Context, cfg *config.Config, diff *git.Diff) (string, error) {
+	p, _, err := newRoutedProvider(cfg, config.RequestCommit, prompt.PromptCommit,
+		provider.WithMaxTokens(1000), provider.WithResponseSchema(commitSchema))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Write the commit message of the following staged changes.\n\n<files>\n")
+	for _, f := range diff.Files {
+		if f.Binary {
+			fmt.Fprintf(&sb, "%s (binary)\n", f.Path)
+		} else {
+			fmt.Fprintf(&sb, "%s (+%d -%d)\n", f.Path, f.Added, f.Deleted)
+		}
+	}
+	patch := diff.Patch
+	if len(patch) > maxCommitPatch {
+		patch = strings.ToValidUTF8(patch[:maxCommitPatch], "") + "\n[...]"
+	}
+	fmt.Fprintf(&sb, "</files>\n\n<diff>\n%s\n</diff>", patch)
+
+	response, err := p.SendMessages(ctx, []message.Message{
+		{
+			Role:  message.User,
+			Parts: []message.ContentPart{message.TextContent{Text: sb.String()}},
+		},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	msg := commitMessage(response.Content)
+	if msg == "" {
+		return "", errors.New("the model returned an empty commit message")
+	}
+	return msg, nil
+}
+
+// commitMessage returns the message of the answer, in JSON or, from the models
+// that don't support the schema, in plain text.
+func commitMessage(content string) string {
+	var answer struct {
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+	}
+	if err := provider.DecodeResponse(content, &answer); err != nil || strings.TrimSpace(answer.Subject) == "" {
+		return cleanCommitMessage(content)
+	}
+	msg := strings.TrimSpace(answer.Subject)
+	if body := strings.TrimSpace(answer.Body); body != "" {
+		msg += "\n\n" + body
+	}
+	return msg
+}
+
+// cleanCommitMessage removes the code fences and quotes models sometimes
+// wrap the message in.
+func cleanCommitMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	if strings.HasPrefix(msg, "```") {
+		_, msg, _ = strings.Cut(msg, "\n")
+		msg = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(msg), "```"))
+	}
+	if len(msg) > 1 && msg[0] == '"' && msg[len(msg)-1] == '"' {
+		msg = strings.TrimSpace(msg[1 : len(msg)-1])
+	}
+	return msg
+}
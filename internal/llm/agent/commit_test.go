@@ -0,0 +1,41 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/git"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateCommitMessage(t *testing.T) {
+	h := agenttest.New(t)
+	h.Small.Script(agenttest.Text("```\nfeat(api): add the users endpoint\n\nList the users page by page.\n```"))
+
+	msg, err := agent.GenerateCommitMessage(t.Context(), h.Config, &git.Diff{
+		Files: []git.DiffFile{{Path: "api/users.go", Added: 12, Deleted: 1}, {Path: "logo.png", Binary: true}},
+		Patch: "diff --git a/api/users.go b/api/users.go\n+func listUsers() {}\n",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "feat(api): add the users endpoint\n\nList the users page by page.", msg)
+
+	requests := h.Small.Requests()
+	require.Len(t, requests, 1)
+	prompt := requests[0].Messages[0].Content().String()
+	require.Contains(t, prompt, "api/users.go (+12 -1)\nlogo.png (binary)\n")
+	require.Contains(t, prompt, "+func listUsers() {}")
+	require.Empty(t, h.Large.Requests())
+}
+
+func TestGenerateCommitMessage_JSON(t *testing.T) {
+	h := agenttest.New(t)
+	h.Small.Script(agenttest.Text(`{"subject": "fix(db): close the rows", "body": ""}`))
+
+	msg, err := agent.GenerateCommitMessage(t.Context(), h.Config, &git.Diff{
+		Files: []git.DiffFile{{Path: "db/query.go", Added: 1}},
+		Patch: "+defer rows.Close()\n",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "fix(db): close the rows", msg)
+}
@@ -0,0 +1,247 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/charmbracelet/crush/internal/tokenizer"
+)
+
+const (
+	defaultCompactionThreshold = 0.8
+	defaultKeepRecentTokens    = 20_000
+	// maxSummarizedToolResult caps the tool results sent to the summarize
+	// model, which may have a smaller context window than the agent's.
+	maxSummarizedToolResult = 4000
+)
+
+var errNothingToCompact = errors.New("nothing to compact")
+
+const compactPrompt = `The conversation above is about to be removed from your context to free up space; the most recent messages are kept as they are and follow your summary. Write the summary you will continue the work from.
+
+Start with a "Pinned facts" section listing the facts that must not be lost, word for word when it matters: the requirements and constraints the user gave, the decisions made, and the file paths, commands, identifiers, and error messages still relevant. Carry over the pinned facts of any earlier summary that still hold.
+
+Then summarize what was done, what is being worked on, which files are being changed, and what is left to do.`
+
+func compactionConfig() config.Compaction {
+	if cfg := config.Get().Options.Compaction; cfg != nil {
+		return *cfg
+	}
+	return config.Compaction{}
+}
+
+// compactedHistory returns the messages of the session the model sees: the
+// summary of the last compaction followed by the messages kept as they are,
+// or all of them if the session was never compacted. start is the index of
+// the first message of the history created after the summary.
+func compactedHistory(sess session.Session, msgs []message.Message) (history []message.Message, start int) {
+	if sess.SummaryMessageID == "" {
+		return msgs, 0
+	}
+	summaryIndex := slices.IndexFunc(msgs, func(msg message.Message) bool { return msg.ID == sess.SummaryMessageID })
+	if summaryIndex == -1 {
+		return msgs, 0
+	}
+	summary := msgs[summaryIndex]
+	summary.Role = message.User
+	history = []message.Message{summary}
+	if sess.KeptMessageID != "" {
+		keptIndex := slices.IndexFunc(msgs, func(msg message.Message) bool { return msg.ID == sess.KeptMessageID })
+		if keptIndex != -1 && keptIndex < summaryIndex {
+			history = append(history, msgs[keptIndex:summaryIndex]...)
+		}
+	}
+	return append(history, msgs[summaryIndex+1:]...), len(history)
+}
+
+// EstimateTokens estimates the tokens of the messages with the tokenizer of
+// the model. Binary contents are counted at four bytes a token.
+func EstimateTokens(model string, msgs []message.Message) int {
+	var cfg *config.Tokenizers
+	if c := config.Get(); c != nil && c.Options != nil {
+		cfg = c.Options.Tokenizers
+	}
+	return countTokens(tokenizer.ForModel(model, cfg), msgs)
+}
+
+func countTokens(tok tokenizer.Tokenizer, msgs []message.Message) int {
+	tokens := 0
+	for _, msg := range msgs {
+		for _, part := range msg.Parts {
+			switch part := part.(type) {
+			case message.TextContent:
+				tokens += tok.Count(part.Text)
+			case message.ReasoningContent:
+				tokens += tok.Count(part.Thinking)
+			case message.ToolCall:
+				tokens += tok.Count(part.Name) + tok.Count(part.Input)
+			case message.ToolResult:
+				tokens += tok.Count(part.Content)
+			case message.BinaryContent:
+				tokens += len(part.Data) / 4
+			}
+		}
+	}
+	return tokens
+}
+
+// modelTokenizer returns the tokenizer of the model of the agent.
+func (a *agent) modelTokenizer() tokenizer.Tokenizer {
+	provider, _ := a.mainProvider()
+	return tokenizer.ForModel(provider.Model().ID, config.Get().Options.Tokenizers)
+}
+
+// compactionSplit returns the index of the first message kept as is, so the
+// kept messages hold about keepTokens, or 0 if no message would be
+// summarized. Messages from start on are the only ones that can be kept,
+// the ones before it were created before the last summary. Tool results are
+// kept with the tool calls they answer.
+func compactionSplit(tok tokenizer.Tokenizer, history []message.Message, start, keepTokens int) int {
+	keep, tokens := len(history), 0
+	for keep > 0 {
+		tokens += countTokens(tok, history[keep-1:keep])
+		if tokens > keepTokens {
+			break
+		}
+		keep--
+	}
+	keep = max(keep, start)
+	for keep < len(history) && history[keep].Role == message.Tool {
+		keep++
+	}
+	return keep
+}
+
+// trimForSummary returns the messages with the long tool results cut, they
+// are summarized anyway.
+func trimForSummary(msgs []message.Message) []message.Message {
+	trimmed := make([]message.Message, len(msgs))
+	for i, msg := range msgs {
+		trimmed[i] = msg
+		if msg.Role != message.Tool {
+			continue
+		}
+		parts := make([]message.ContentPart, len(msg.Parts))
+		for j, part := range msg.Parts {
+			if tr, ok := part.(message.ToolResult); ok && len(tr.Content) > maxSummarizedToolResult {
+				tr.Content = strings.ToValidUTF8(tr.Content[:maxSummarizedToolResult], "") + "\n[...]"
+				part = tr
+			}
+			parts[j] = part
+		}
+		trimmed[i].Parts = parts
+	}
+	return trimmed
+}
+
+// compact summarizes the older messages of the history with the summarize
+// model, keeping the most recent ones as they are, and returns the new
+// history. Unless all is set, it returns errNothingToCompact when the whole
+// history would be kept.
+func (a *agent) compact(ctx context.Context, sessionID string, history []message.Message, start int, all bool) ([]message.Message, error) {
+	if a.summarizeProvider == nil {
+		return nil, fmt.Errorf("summarize provider not available")
+	}
+	keep := len(history)
+	if !all {
+		keep = compactionSplit(a.modelTokenizer(), history, start, cmp.Or(compactionConfig().KeepRecentTokens, defaultKeepRecentTokens))
+	}
+	if keep == 0 || (keep == 1 && start == 1) {
+		return nil, errNothingToCompact
+	}
+
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+	older := trimForSummary(a.withDigests(ctx, history[:keep]))
+	started := time.Now()
+	response, err := a.summarizeProvider.SendMessages(ctx, append(older, message.Message{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: compactPrompt}},
+	}), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize: %w", err)
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return nil, errors.New("empty summary returned")
+	}
+	shell := shell.GetPersistentShell(config.Get().WorkingDir())
+	summary += "\n\n**Current working directory of the persistent shell**\n\n" + shell.GetWorkingDir()
+
+	summaryMsg, err := a.messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role: message.Assistant,
+		Parts: []message.ContentPart{
+			message.TextContent{Text: summary},
+			message.Finish{
+				Reason: message.FinishReasonEndTurn,
+				Time:   time.Now().Unix(),
+			},
+		},
+		Model:    a.summarizeProvider.Model().ID,
+		Provider: a.summarizeProviderID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create summary message: %w", err)
+	}
+
+	sess, err := a.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	kept := history[keep:]
+	sess.SummaryMessageID = summaryMsg.ID
+	sess.KeptMessageID = ""
+	if len(kept) > 0 {
+		sess.KeptMessageID = kept[0].ID
+	}
+	sess.PromptTokens = int64(countTokens(a.modelTokenizer(), kept))
+	sess.CompletionTokens = response.Usage.OutputTokens
+	model := a.summarizeProvider.Model()
+	cost, cacheCost, reasoningCost := usageCost(model, config.Get().GetPromptCache(config.Get().ModelFor(config.RequestSummarize)), response.Usage)
+	sess.Cost += cost
+	sess.CacheCost += cacheCost
+	sess.ReasoningCost += reasoningCost
+	if err := a.recordUsage(ctx, sessionID, a.summarizeProviderID, model.ID, response.Usage, cost, time.Since(started)); err != nil {
+		slog.Error("Failed to record the usage of the summary", "error", err)
+	}
+	if _, err := a.sessions.Save(ctx, sess); err != nil {
+		return nil, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	summaryMsg.Role = message.User
+	return append([]message.Message{summaryMsg}, kept...), nil
+}
+
+// compactIfNeeded compacts the history of the turn once it fills most of the
+// context window of the model.
+func (l *loop) compactIfNeeded(ctx context.Context) {
+	if config.Get().Options.DisableAutoSummarize || l.summarizeProvider == nil {
+		return
+	}
+	window := l.Model().ContextWindow
+	tokens := max(l.contextTokens, int64(EstimateTokens(l.Model().ID, l.history)))
+	if window == 0 || float64(tokens) < cmp.Or(compactionConfig().Threshold, defaultCompactionThreshold)*float64(window) {
+		return
+	}
+	history, err := l.compact(ctx, l.sessionID, l.history, l.historyStart, false)
+	if err != nil {
+		if !errors.Is(err, errNothingToCompact) && !errors.Is(err, context.Canceled) {
+			slog.Error("Failed to compact the conversation", "session_id", l.sessionID, "error", err)
+		}
+		return
+	}
+	slog.Info("Compacted the conversation", "session_id", l.sessionID, "tokens", tokens, "context_window", window, "kept_messages", len(history)-1)
+	l.history = history
+	l.historyStart = len(history)
+	l.contextTokens = 0
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+func textMessage(id string, role message.MessageRole, text string) message.Message {
+	return message.Message{ID: id, Role: role, Parts: []message.ContentPart{message.TextContent{Text: text}}}
+}
+
+func toolMessage(id, content string) message.Message {
+	return message.Message{ID: id, Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call", Content: content}}}
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func TestCompactedHistory(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{
+		textMessage("1", message.User, "first"),
+		textMessage("2", message.Assistant, "answer"),
+		textMessage("3", message.User, "second"),
+		textMessage("4", message.Assistant, "answer"),
+		textMessage("summary", message.Assistant, "summary"),
+		textMessage("5", message.User, "third"),
+	}
+
+	history, start := compactedHistory(session.Session{}, msgs)
+	require.Equal(t, msgs, history)
+	require.Zero(t, start)
+
+	history, start = compactedHistory(session.Session{SummaryMessageID: "summary", KeptMessageID: "3"}, msgs)
+	require.Equal(t, []string{"summary", "3", "4", "5"}, messageIDs(history))
+	require.Equal(t, message.User, history[0].Role)
+	require.Equal(t, 3, start)
+	require.Equal(t, message.Assistant, msgs[4].Role, "the messages are left as they are")
+
+	history, start = compactedHistory(session.Session{SummaryMessageID: "summary"}, msgs)
+	require.Equal(t, []string{"summary", "5"}, messageIDs(history))
+	require.Equal(t, 1, start)
+}
+
+func TestCompactionSplit(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", 400) // 100 tokens
+	history := []message.Message{
+		textMessage("1", message.User, long),
+		textMessage("2", message.Assistant, long),
+		toolMessage("3", long),
+		textMessage("4", message.Assistant, long),
+		toolMessage("5", long),
+		textMessage("6", message.Assistant, long),
+	}
+
+	require.Equal(t, 3, compactionSplit(tokenizer.ForModel("", nil), history, 0, 300))
+	require.Equal(t, 5, compactionSplit(tokenizer.ForModel("", nil), history, 0, 250), "tool results stay with their calls")
+	require.Equal(t, 0, compactionSplit(tokenizer.ForModel("", nil), history, 0, 1000), "everything is kept")
+	require.Equal(t, 6, compactionSplit(tokenizer.ForModel("", nil), history, 0, 0), "nothing is kept")
+	require.Equal(t, 5, compactionSplit(tokenizer.ForModel("", nil), history, 5, 1000), "messages from before the last summary are not kept")
+}
+
+func TestTrimForSummary(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{
+		toolMessage("1", strings.Repeat("x", maxSummarizedToolResult+100)),
+		textMessage("2", message.Assistant, strings.Repeat("x", maxSummarizedToolResult+100)),
+	}
+	trimmed := trimForSummary(msgs)
+	require.Len(t, trimmed[0].ToolResults()[0].Content, maxSummarizedToolResult+len("\n[...]"))
+	require.Len(t, trimmed[1].Content().Text, maxSummarizedToolResult+100)
+	require.Len(t, msgs[0].ToolResults()[0].Content, maxSummarizedToolResult+100, "the messages are left as they are")
+}
@@ -0,0 +1,59 @@
+package agent_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func withReviewer(t *testing.T) func(cfg *config.Config) {
+	return func(cfg *config.Config) {
+		prompt := filepath.Join(cfg.WorkingDir(), ".crush", "agents", "reviewer.md")
+		require.NoError(t, os.MkdirAll(filepath.Dir(prompt), 0o755))
+		require.NoError(t, os.WriteFile(prompt, []byte("You review diffs."), 0o644))
+		cfg.CustomAgents = map[string]config.CustomAgent{
+			"reviewer": {
+				PromptFile:   ".crush/agents/reviewer.md",
+				AllowedTools: []string{"view", "agent"},
+			},
+		}
+		cfg.SetupAgents()
+	}
+}
+
+func TestCustomAgent(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithConfig(withReviewer(t)), agenttest.WithAgent("reviewer"))
+	h.Large.Script(
+		agenttest.ToolCall(agent.AgentToolName, map[string]any{"prompt": "Find the diff"}),
+		agenttest.Text("main.go"),
+		agenttest.Text("Looks good."),
+	)
+
+	turn, err := h.Run("Review the diff")
+	require.NoError(t, err)
+	require.Equal(t, "Looks good.", turn.Message.Content().Text)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 3)
+	require.Equal(t, []string{"view", "agent"}, requests[0].Tools)
+	require.Contains(t, requests[1].Tools, "grep", "custom agents hand tasks to the sub-agents")
+}
+
+func TestCustomAgent_MissingPromptFile(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.CustomAgents = map[string]config.CustomAgent{
+			"reviewer": {PromptFile: "reviewer.md"},
+		}
+		cfg.SetupAgents()
+	}))
+	require.Equal(t, []string{"coder", "reviewer"}, h.Config.MainAgents())
+	require.Equal(t, filepath.Join(h.WorkingDir, "reviewer.md"), h.Config.Agents["reviewer"].PromptFile)
+
+	_, err := agent.NewAgent(t.Context(), h.Config.Agents["reviewer"], h.Permissions, h.Sessions, h.Messages, h.History, nil)
+	require.ErrorContains(t, err, "failed to read the prompt of agent reviewer")
+}
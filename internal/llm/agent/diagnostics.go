@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+const defaultMaxFeedbackDiagnostics = 20
+
+var feedbackSeverities = map[string]protocol.DiagnosticSeverity{
+	config.DiagnosticSeverityError:   protocol.SeverityError,
+	config.DiagnosticSeverityWarning: protocol.SeverityWarning,
+	config.DiagnosticSeverityInfo:    protocol.SeverityInformation,
+	config.DiagnosticSeverityHint:    protocol.SeverityHint,
+}
+
+// diagnosticsFeedback returns the prompt listing the diagnostics of the files
+// changed by the tool calls, or "" if there are none or they were already
+// sent. The tools changing files wait for the fresh diagnostics before they
+// return.
+func (l *loop) diagnosticsFeedback(toolCalls []message.ToolCall, toolResults []message.ToolResult) string {
+	cfg := config.Get().Options.DiagnosticsFeedback
+	if len(l.lspClients) == 0 || (cfg != nil && cfg.Disabled) {
+		return ""
+	}
+	severity, maxDiagnostics := protocol.SeverityError, defaultMaxFeedbackDiagnostics
+	if cfg != nil {
+		severity = cmp.Or(feedbackSeverities[cfg.Severity], severity)
+		maxDiagnostics = cmp.Or(cfg.MaxDiagnostics, maxDiagnostics)
+	}
+
+	paths := changedFiles(toolCalls, toolResults, config.Get().WorkingDir())
+	if len(paths) == 0 {
+		return ""
+	}
+	diagnostics := tools.FileDiagnostics(l.lspClients, paths, severity)
+	listed := strings.Join(diagnostics, "\n")
+	if listed == l.lastDiagnostics {
+		return ""
+	}
+	l.lastDiagnostics = listed
+	if len(diagnostics) == 0 {
+		return ""
+	}
+	if len(diagnostics) > maxDiagnostics {
+		listed = fmt.Sprintf("%s\n... and %d more diagnostics", strings.Join(diagnostics[:maxDiagnostics], "\n"), len(diagnostics)-maxDiagnostics)
+	}
+	return fmt.Sprintf(`The language server reports problems in the files you just changed. Fix them before going on, unless your next changes already will.
+
+<diagnostics>
+%s
+</diagnostics>`, listed)
+}
+
+// changedFiles returns the paths of the files written by the successful tool
+// calls.
+func changedFiles(toolCalls []message.ToolCall, toolResults []message.ToolResult, workingDir string) []string {
+	results := make(map[string]message.ToolResult, len(toolResults))
+	for _, tr := range toolResults {
+		results[tr.ToolCallID] = tr
+	}
+	var paths []string
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		if !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	for _, tc := range toolCalls {
+		result, ok := results[tc.ID]
+		if !ok || result.IsError {
+			continue
+		}
+		switch tc.Name {
+		case tools.ApplyPatchToolName, tools.TransactionToolName, tools.RenameToolName, tools.CodeActionToolName:
+			var meta tools.ChangeSetResponseMetadata
+			if err := json.Unmarshal([]byte(result.Metadata), &meta); err != nil {
+				continue
+			}
+			for _, file := range meta.Files {
+				if !file.Deleted {
+					add(file.FilePath)
+				}
+			}
+		case tools.EditToolName, tools.MultiEditToolName, tools.WriteToolName:
+			var params struct {
+				FilePath string `json:"file_path"`
+			}
+			if err := json.Unmarshal([]byte(tc.Input), &params); err == nil {
+				add(params.FilePath)
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangedFiles(t *testing.T) {
+	toolCalls := []message.ToolCall{
+		{ID: "1", Name: tools.EditToolName, Input: `{"file_path": "/work/a.go"}`},
+		{ID: "2", Name: tools.WriteToolName, Input: `{"file_path": "b.go"}`},
+		{ID: "3", Name: tools.TransactionToolName},
+		{ID: "4", Name: tools.EditToolName, Input: `{"file_path": "/work/failed.go"}`},
+		{ID: "5", Name: tools.ViewToolName, Input: `{"file_path": "/work/read.go"}`},
+	}
+	toolResults := []message.ToolResult{
+		{ToolCallID: "1"},
+		{ToolCallID: "2"},
+		{ToolCallID: "3", Metadata: `{"files": [{"file_path": "/work/a.go"}, {"file_path": "/work/c.go"}, {"file_path": "/work/gone.go", "deleted": true}]}`},
+		{ToolCallID: "4", IsError: true},
+		{ToolCallID: "5"},
+	}
+	require.Equal(t, []string{"/work/a.go", "/work/b.go", "/work/c.go"}, changedFiles(toolCalls, toolResults, "/work"))
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/log"
+	"github.com/charmbracelet/crush/internal/message"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultDigestMinLength         = 20_000
+	defaultDigestRequestsPerMinute = 10
+	// maxDigestInput caps the output sent to the small model. The start and
+	// the end of the output are kept, where logs have the interesting parts.
+	maxDigestInput = 200_000
+	digestTimeout  = 5 * time.Minute
+)
+
+// digestConfig returns the digest settings, or nil if digests are disabled.
+func (a *agent) digestConfig() *config.ToolOutputDigest {
+	return config.Get().Options.ToolOutputDigest
+}
+
+func digestMinLength(cfg *config.ToolOutputDigest) int {
+	return cmp.Or(cfg.MinLength, defaultDigestMinLength)
+}
+
+// needsDigest reports whether the result is long enough to be digested and
+// hasn't been yet.
+func needsDigest(cfg *config.ToolOutputDigest, tr message.ToolResult) bool {
+	return !tr.IsError && tr.Digest == "" && len(tr.Content) > digestMinLength(cfg)
+}
+
+// digestToolResults summarizes the long results of the tool message in the
+// background, at most RequestsPerMinute at a time, and stores the digests in
+// the message.
+func (a *agent) digestToolResults(msg message.Message) {
+	cfg := a.digestConfig()
+	if cfg == nil || a.digestProvider == nil {
+		return
+	}
+	perMinute := cmp.Or(cfg.RequestsPerMinute, defaultDigestRequestsPerMinute)
+	a.digestLimiter.SetLimit(rate.Every(time.Minute / time.Duration(perMinute)))
+
+	for _, tr := range msg.ToolResults() {
+		if !needsDigest(cfg, tr) {
+			continue
+		}
+		go func() {
+			defer log.RecoverPanic("agent.digestToolResults", func() {
+				slog.Error("panic while digesting tool output")
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), digestTimeout)
+			defer cancel()
+			if err := a.digestToolResult(ctx, msg.ID, tr); err != nil && !errors.Is(err, context.Canceled) {
+				slog.Error("Failed to digest tool output", "tool_call_id", tr.ToolCallID, "error", err)
+			}
+		}()
+	}
+}
+
+func (a *agent) digestToolResult(ctx context.Context, messageID string, tr message.ToolResult) error {
+	if err := a.digestLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	output := tr.Content
+	if len(output) > maxDigestInput {
+		output = strings.ToValidUTF8(output[:maxDigestInput/2]+"\n[...]\n"+output[len(output)-maxDigestInput/2:], "")
+	}
+	response, err := a.digestProvider.SendMessages(ctx, []message.Message{
+		{
+			Role: message.User,
+			Parts: []message.ContentPart{message.TextContent{
+				Text: fmt.Sprintf("Write a digest of the following tool output:\n\n<output>\n%s\n</output>", output),
+			}},
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	digest := strings.TrimSpace(response.Content)
+	if digest == "" {
+		return errors.New("empty digest")
+	}
+
+	// Digests of the results of the same message are saved one at a time so
+	// they don't overwrite each other.
+	a.digestMu.Lock()
+	defer a.digestMu.Unlock()
+	msg, err := a.messages.Get(ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get tool message: %w", err)
+	}
+	for i, part := range msg.Parts {
+		if result, ok := part.(message.ToolResult); ok && result.ToolCallID == tr.ToolCallID {
+			result.Digest = digest
+			msg.Parts[i] = result
+		}
+	}
+	return a.messages.Update(ctx, msg)
+}
+
+// withDigests returns the messages with the long tool results replaced by
+// their digest, when there is one. The digests of the latest results may
+// have been saved since the messages were read.
+func (a *agent) withDigests(ctx context.Context, msgs []message.Message) []message.Message {
+	cfg := a.digestConfig()
+	if cfg == nil {
+		return msgs
+	}
+	digested := make([]message.Message, len(msgs))
+	for i, msg := range msgs {
+		digested[i] = msg
+		if msg.Role != message.Tool {
+			continue
+		}
+		for _, tr := range msg.ToolResults() {
+			if needsDigest(cfg, tr) {
+				if latest, err := a.messages.Get(ctx, msg.ID); err == nil {
+					msg = latest
+				}
+				break
+			}
+		}
+		parts := make([]message.ContentPart, len(msg.Parts))
+		for j, part := range msg.Parts {
+			if tr, ok := part.(message.ToolResult); ok && tr.Digest != "" {
+				tr.Content = fmt.Sprintf("This output of %d characters was condensed, the full output is shown to the user. Digest:\n\n%s", len(tr.Content), tr.Digest)
+				part = tr
+			}
+			parts[j] = part
+		}
+		msg.Parts = parts
+		digested[i] = msg
+	}
+	return digested
+}
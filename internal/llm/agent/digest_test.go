@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// fakeDigestProvider answers every request with the digest, and keeps the
+// prompts it was sent.
+type fakeDigestProvider struct {
+	digest  string
+	prompts []string
+}
+
+func (p *fakeDigestProvider) SendMessages(ctx context.Context, messages []message.Message, _ []tools.BaseTool) (*provider.ProviderResponse, error) {
+	p.prompts = append(p.prompts, messages[len(messages)-1].Content().Text)
+	return &provider.ProviderResponse{Content: p.digest}, nil
+}
+
+func (p *fakeDigestProvider) StreamResponse(context.Context, []message.Message, []tools.BaseTool) <-chan provider.ProviderEvent {
+	panic("not used by the digests")
+}
+
+func (p *fakeDigestProvider) Model() catwalk.Model {
+	return catwalk.Model{ID: "digest"}
+}
+
+func newDigestTest(t *testing.T, digest *config.ToolOutputDigest) (*agent, *fakeDigestProvider, string) {
+	t.Helper()
+	previous := config.Get()
+	config.Set(&config.Config{Options: &config.Options{ToolOutputDigest: digest}})
+	t.Cleanup(func() {
+		config.Set(previous)
+	})
+
+	store, err := db.OpenSQLiteStore(t.Context(), memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		store.Close()
+	})
+	sess, err := session.NewService(store).Create(t.Context(), "Digests")
+	require.NoError(t, err)
+
+	p := &fakeDigestProvider{digest: "  The build failed in db.go.\n"}
+	a := &agent{
+		messages:       message.NewService(store),
+		digestProvider: p,
+		digestLimiter:  rate.NewLimiter(rate.Inf, 1),
+	}
+	return a, p, sess.ID
+}
+
+func TestNeedsDigest(t *testing.T) {
+	cfg := &config.ToolOutputDigest{MinLength: 10}
+	require.True(t, needsDigest(cfg, message.ToolResult{Content: "more than ten characters"}))
+	require.False(t, needsDigest(cfg, message.ToolResult{Content: "short"}))
+	require.False(t, needsDigest(cfg, message.ToolResult{Content: "more than ten characters", IsError: true}), "the errors are kept whole")
+	require.False(t, needsDigest(cfg, message.ToolResult{Content: "more than ten characters", Digest: "done"}))
+	require.False(t, needsDigest(&config.ToolOutputDigest{}, message.ToolResult{Content: strings.Repeat("x", defaultDigestMinLength)}))
+}
+
+func TestDigestToolResult(t *testing.T) {
+	a, p, sessionID := newDigestTest(t, &config.ToolOutputDigest{MinLength: 10})
+	msg, err := a.messages.Create(t.Context(), sessionID, message.CreateMessageParams{
+		Role: message.Tool,
+		Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call_1", Name: "bash", Content: "long output"},
+			message.ToolResult{ToolCallID: "call_2", Name: "bash", Content: "other output"},
+		},
+	})
+	require.NoError(t, err)
+
+	output := "start" + strings.Repeat("x", maxDigestInput) + "end"
+	require.NoError(t, a.digestToolResult(t.Context(), msg.ID, message.ToolResult{ToolCallID: "call_1", Content: output}))
+
+	// The middle of the output is cut, its start and end are kept.
+	require.Len(t, p.prompts, 1)
+	require.Contains(t, p.prompts[0], "<output>\nstart")
+	require.Contains(t, p.prompts[0], "\n[...]\n")
+	require.Contains(t, p.prompts[0], "end\n</output>")
+	require.Less(t, len(p.prompts[0]), maxDigestInput+200)
+
+	saved, err := a.messages.Get(t.Context(), msg.ID)
+	require.NoError(t, err)
+	results := saved.ToolResults()
+	require.Equal(t, "The build failed in db.go.", results[0].Digest)
+	require.Equal(t, "long output", results[0].Content, "the full output is kept for the user")
+	require.Empty(t, results[1].Digest)
+}
+
+func TestWithDigests(t *testing.T) {
+	a, _, sessionID := newDigestTest(t, &config.ToolOutputDigest{MinLength: 10})
+	long := strings.Repeat("x", 20)
+	msg, err := a.messages.Create(t.Context(), sessionID, message.CreateMessageParams{
+		Role: message.Tool,
+		Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call_1", Name: "bash", Content: long},
+			message.ToolResult{ToolCallID: "call_2", Name: "bash", Content: "short"},
+		},
+	})
+	require.NoError(t, err)
+	user := message.Message{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: strings.Repeat("y", 20)}}}
+
+	// The digest isn't there yet, the output is sent whole.
+	history := []message.Message{user, msg}
+	require.Equal(t, long, a.withDigests(t.Context(), history)[1].ToolResults()[0].Content)
+
+	// It was saved since the history was read, the latest message is used.
+	require.NoError(t, a.digestToolResult(t.Context(), msg.ID, msg.ToolResults()[0]))
+	digested := a.withDigests(t.Context(), history)
+	require.Equal(t, user, digested[0])
+	results := digested[1].ToolResults()
+	require.Equal(t, "This output of 20 characters was condensed, the full output is shown to the user. Digest:\n\nThe build failed in db.go.", results[0].Content)
+	require.Equal(t, "short", results[1].Content)
+	require.Equal(t, long, history[1].ToolResults()[0].Content, "the history isn't modified")
+
+	// Without the config the outputs are sent whole.
+	config.Get().Options.ToolOutputDigest = nil
+	require.Equal(t, history, a.withDigests(t.Context(), history))
+}
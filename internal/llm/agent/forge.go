@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/forge"
+	"github.com/charmbracelet/crush/internal/git"
+)
+
+// newForge returns the forge hosting the repository of the working
+// directory with the name of its remote, or nil if the forge tool is
+// disabled or the repository isn't on a known forge.
+func newForge(ctx context.Context, cfg *config.Config, workingDir string) (forge.Forge, string) {
+	opts := cfg.Options.Forge
+	if opts == nil {
+		opts = &config.Forge{}
+	}
+	if opts.Disabled {
+		return nil, ""
+	}
+	remote := cmp.Or(opts.Remote, "origin")
+	repo, err := git.Open(ctx, workingDir)
+	if err != nil {
+		return nil, ""
+	}
+	url, err := repo.RemoteURL(ctx, remote)
+	if err != nil {
+		slog.Debug("Forge tool unavailable", "remote", remote, "error", err)
+		return nil, ""
+	}
+	f, err := forge.New(*opts, cfg.Resolver(), url)
+	if err != nil {
+		slog.Debug("Forge tool unavailable", "remote", remote, "error", err)
+		return nil, ""
+	}
+	return f, remote
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/formatter"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// formatFeedback formats the files changed by the tool calls, and returns the
+// prompt telling the model which files the formatters changed and which
+// failed, or "" if none did.
+func (l *loop) formatFeedback(ctx context.Context, toolCalls []message.ToolCall, toolResults []message.ToolResult) string {
+	cfg := config.Get().Options.Formatting
+	if cfg == nil || !cfg.Enabled {
+		return ""
+	}
+	files := changedFiles(toolCalls, toolResults, config.Get().WorkingDir())
+	if len(files) == 0 {
+		return ""
+	}
+
+	var changed, failed []string
+	for _, result := range formatter.Format(ctx, cfg, config.Get().WorkingDir(), files) {
+		switch {
+		case result.Err != nil:
+			failed = append(failed, fmt.Sprintf("%s failed on %s:\n%s", result.Command, fsext.PrettyPath(result.File), result.Err))
+		case result.Changed:
+			changed = append(changed, result.File)
+		}
+	}
+	var feedback []string
+	if len(changed) > 0 {
+		feedback = append(feedback, fmt.Sprintf("These files were formatted after you changed them, view them before changing them again:\n%s", strings.Join(changed, "\n")))
+	}
+	for _, failure := range failed {
+		feedback = append(feedback, "The formatter "+failure)
+	}
+	return strings.Join(feedback, "\n\n")
+}
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/hooks"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// startSession runs the session_start hooks on the first prompt of the main
+// sessions, and remembers the session for the session_end hooks.
+func (a *agent) startSession(ctx context.Context, sessionID string, first bool) {
+	a.prompted.Set(sessionID, true)
+	if first {
+		hooks.New(config.Get()).SessionStart(ctx, sessionID)
+	}
+}
+
+// EndSessions runs the session_end hooks of the sessions prompted since the
+// agent started.
+func (a *agent) EndSessions(ctx context.Context) {
+	runner := hooks.New(config.Get())
+	if runner == nil {
+		return
+	}
+	for sessionID := range a.prompted.Seq2() {
+		sess, err := a.sessions.Get(ctx, sessionID)
+		if err != nil {
+			slog.Error("Failed to get the session for the session_end hooks", "session_id", sessionID, "error", err)
+			continue
+		}
+		runner.SessionEnd(ctx, sessionID, hooks.Usage{
+			Title:            sess.Title,
+			PromptTokens:     sess.PromptTokens,
+			CompletionTokens: sess.CompletionTokens,
+			Cost:             sess.Cost,
+		})
+	}
+}
+
+// preToolCallVeto returns why a pre_tool_call hook vetoed the call, or "".
+func (l *loop) preToolCallVeto(ctx context.Context, toolCall message.ToolCall) string {
+	failure := hooks.New(config.Get()).PreToolCall(ctx, l.sessionID, toolCall.ID, toolCall.Name, toolCall.Input)
+	if failure == nil {
+		return ""
+	}
+	return fmt.Sprintf("Vetoed by the pre_tool_call hook `%s`:\n%s", failure.Command, failure.Output)
+}
+
+// postEditFeedback runs the post_edit hooks on the files changed by the tool
+// calls, and returns the prompt telling the model which files the hooks
+// changed and which hooks failed, or "" if none did.
+func (l *loop) postEditFeedback(ctx context.Context, toolCalls []message.ToolCall, toolResults []message.ToolResult) string {
+	runner := hooks.New(config.Get())
+	if runner == nil {
+		return ""
+	}
+	files := changedFiles(toolCalls, toolResults, config.Get().WorkingDir())
+	if len(files) == 0 {
+		return ""
+	}
+	before := make(map[string][]byte, len(files))
+	for _, file := range files {
+		before[file], _ = os.ReadFile(file)
+	}
+	failures := runner.PostEdit(ctx, l.sessionID, files)
+
+	var changed []string
+	for _, file := range files {
+		if after, _ := os.ReadFile(file); !bytes.Equal(before[file], after) {
+			changed = append(changed, file)
+		}
+	}
+	var feedback []string
+	if len(changed) > 0 {
+		feedback = append(feedback, fmt.Sprintf("The post_edit hooks changed these files after you did, view them before changing them again:\n%s", strings.Join(changed, "\n")))
+	}
+	for _, failure := range failures {
+		feedback = append(feedback, fmt.Sprintf("The post_edit hook `%s` failed:\n%s", failure.Command, failure.Output))
+	}
+	return strings.Join(feedback, "\n\n")
+}
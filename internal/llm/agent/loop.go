@@ -0,0 +1,423 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/recorder"
+)
+
+// LoopState is the state of the agent loop while it handles a prompt.
+type LoopState string
+
+const (
+	// LoopStateStreaming streams a response from the model.
+	LoopStateStreaming LoopState = "streaming"
+	// LoopStateRunningTools runs the tools the model called.
+	LoopStateRunningTools LoopState = "running_tools"
+	// LoopStateAwaitingPermission waits for the user to allow a tool call,
+	// while running the tools or before streaming when over budget.
+	LoopStateAwaitingPermission LoopState = "awaiting_permission"
+	// LoopStateVerifying runs the verify command after files were modified.
+	LoopStateVerifying LoopState = "verifying"
+	// LoopStateFinished is the final state, the turn is over.
+	LoopStateFinished LoopState = "finished"
+)
+
+type LoopEventType string
+
+const (
+	LoopEventTurnStarted        LoopEventType = "turn_started"
+	LoopEventStreamDelta        LoopEventType = "stream_delta"
+	LoopEventToolRequested      LoopEventType = "tool_requested"
+	LoopEventAwaitingPermission LoopEventType = "awaiting_permission"
+	LoopEventToolFinished       LoopEventType = "tool_finished"
+	LoopEventTurnFinished       LoopEventType = "turn_finished"
+)
+
+// LoopEvent is published as the agent loop handles a prompt, so its progress
+// can be followed outside of the agent.
+type LoopEvent struct {
+	Type      LoopEventType `json:"type"`
+	State     LoopState     `json:"state"`
+	SessionID string        `json:"session_id"`
+	// The number of requests made to the model in this turn.
+	Step int `json:"step"`
+	// The user message for turn events, the assistant message otherwise.
+	MessageID string `json:"message_id,omitempty"`
+
+	// When streaming
+	Content  string `json:"content,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+
+	// When running tools
+	ToolCall   *message.ToolCall             `json:"tool_call,omitempty"`
+	ToolResult *message.ToolResult           `json:"tool_result,omitempty"`
+	Permission *permission.PermissionRequest `json:"permission,omitempty"`
+
+	// When the turn is finished
+	FinishReason message.FinishReason `json:"finish_reason,omitempty"`
+	Error        string               `json:"error,omitempty"`
+}
+
+// loop is the state machine handling one prompt. Each state runs a step and
+// returns the next state until the turn is finished.
+type loop struct {
+	*agent
+	sessionID string
+	userMsgID string
+	state     LoopState
+	step      int
+	history   []message.Message
+	// historyStart is the index of the first message of the history created
+	// after the summary of the last compaction, see compactedHistory.
+	historyStart int
+	// contextTokens are the tokens of the context of the latest request.
+	contextTokens int64
+
+	// The model of the turn, the one of the agent unless the budget
+	// downgraded it to the small model.
+	provider   provider.Provider
+	providerID string
+	modelType  config.SelectedModelType
+	downgraded bool
+
+	// The latest response of the model, and when it was requested.
+	assistantMsg   message.Message
+	requestStarted time.Time
+
+	// Sources retrieved by tools during this turn, cited in the final answer.
+	citations []message.Citation
+
+	// The verify command has to pass after files were modified, before the
+	// agent can finish.
+	verify         *config.Verify
+	needsVerify    bool
+	verifyAttempts int
+	lastVerify     *verifyResult
+
+	// The working directory was snapshotted in this turn.
+	checkpointed bool
+
+	// The diagnostics last sent back to the model, not sent again while
+	// they don't change.
+	lastDiagnostics string
+
+	// recorder records the turn when the config asks to, see crush replay.
+	recorder *recorder.Recorder
+
+	// The state is awaiting_permission while the tool calls in awaiting
+	// wait for a permission, it is set back to resume after them.
+	stateMu  sync.Mutex
+	resume   LoopState
+	awaiting map[string]bool
+
+	result AgentEvent
+}
+
+func (a *agent) newLoop(sessionID string, userMsg message.Message, history []message.Message) *loop {
+	provider, providerID := a.mainProvider()
+	return &loop{
+		agent:      a,
+		sessionID:  sessionID,
+		userMsgID:  userMsg.ID,
+		state:      LoopStateStreaming,
+		history:    history,
+		provider:   provider,
+		providerID: providerID,
+		modelType:  a.agentCfg.Model,
+		verify:     a.verifyConfig(),
+		recorder:   recorder.New(config.Get()),
+	}
+}
+
+// Model returns the model of the turn.
+func (l *loop) Model() catwalk.Model {
+	return *config.Get().GetModelByType(l.modelType)
+}
+
+// SubscribeLoop subscribes to the events of the agent loop.
+func (a *agent) SubscribeLoop(ctx context.Context) <-chan pubsub.Event[LoopEvent] {
+	return a.loopEvents.Subscribe(ctx)
+}
+
+func (l *loop) currentState() LoopState {
+	l.stateMu.Lock()
+	defer l.stateMu.Unlock()
+	return l.state
+}
+
+func (l *loop) setState(state LoopState) {
+	l.stateMu.Lock()
+	defer l.stateMu.Unlock()
+	l.state = state
+}
+
+// event returns an event of the current state of the loop.
+func (l *loop) event(tp LoopEventType) LoopEvent {
+	return LoopEvent{
+		Type:      tp,
+		State:     l.currentState(),
+		SessionID: l.sessionID,
+		Step:      l.step,
+		MessageID: l.assistantMsg.ID,
+	}
+}
+
+func (l *loop) publish(event LoopEvent) {
+	l.loopEvents.Publish(pubsub.CreatedEvent, event)
+}
+
+func (l *loop) run(ctx context.Context) AgentEvent {
+	started := l.event(LoopEventTurnStarted)
+	started.MessageID = l.userMsgID
+	l.publish(started)
+	for state := l.currentState(); state != LoopStateFinished; state = l.currentState() {
+		// Check for cancellation before each step
+		if ctx.Err() != nil {
+			l.setState(l.finish(l.err(ctx.Err())))
+			break
+		}
+		next := LoopStateFinished
+		switch state {
+		case LoopStateStreaming:
+			next = l.stream(ctx)
+		case LoopStateRunningTools:
+			next = l.runTools(ctx)
+		case LoopStateVerifying:
+			next = l.runVerify(ctx)
+		default:
+			next = l.finish(l.err(fmt.Errorf("unexpected agent loop state: %s", state)))
+		}
+		slog.Debug("Agent loop transition", "session_id", l.sessionID, "from", state, "to", next)
+		l.setState(next)
+	}
+
+	finished := l.event(LoopEventTurnFinished)
+	if l.result.Error != nil {
+		finished.Error = l.result.Error.Error()
+	} else {
+		finished.FinishReason = l.result.Message.FinishReason()
+	}
+	l.publish(finished)
+	return l.result
+}
+
+// finish ends the turn with the result.
+func (l *loop) finish(result AgentEvent) LoopState {
+	l.result = result
+	return LoopStateFinished
+}
+
+func (l *loop) stream(ctx context.Context) LoopState {
+	if !l.checkBudget(ctx) {
+		return LoopStateFinished
+	}
+	l.compactIfNeeded(ctx)
+	l.step++
+	if err := l.streamResponse(ctx); err != nil {
+		if errors.Is(err, context.Canceled) {
+			l.finishMessage(context.Background(), &l.assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+			return l.finish(l.err(ErrRequestCancelled))
+		}
+		return l.finish(l.err(fmt.Errorf("failed to process events: %w", err)))
+	}
+	if config.Get().Options.Debug {
+		slog.Info("Result", "message", l.assistantMsg.FinishReason())
+	}
+
+	switch l.assistantMsg.FinishReason() {
+	case message.FinishReasonToolUse:
+		return LoopStateRunningTools
+	case "":
+		// Kujtim: could not track down where this is happening but this means its cancelled
+		l.finishMessage(context.Background(), &l.assistantMsg, message.FinishReasonCanceled, "Request cancelled", "")
+		return l.finish(l.err(ErrRequestCancelled))
+	case message.FinishReasonEndTurn:
+		if l.needsVerify {
+			return LoopStateVerifying
+		}
+	}
+	return l.done(ctx)
+}
+
+func (l *loop) runTools(ctx context.Context) LoopState {
+	l.checkpoint(ctx)
+	toolResults, err := l.runToolCalls(ctx)
+	if err != nil {
+		return l.finish(l.err(fmt.Errorf("failed to process events: %w", err)))
+	}
+	if config.Get().Options.Debug {
+		slog.Info("Result", "message", l.assistantMsg.FinishReason(), "toolResults", toolResults)
+	}
+	// A denied permission ends the turn.
+	if toolResults == nil || l.assistantMsg.FinishReason() != message.FinishReasonToolUse {
+		return l.done(ctx)
+	}
+
+	// We are not done, we need to respond with the tool response
+	for _, tr := range toolResults.ToolResults() {
+		if !tr.IsError {
+			l.citations = append(l.citations, tr.Citations...)
+		}
+	}
+	if l.verify != nil && modifiedFiles(l.assistantMsg.ToolCalls(), toolResults.ToolResults()) {
+		l.needsVerify = true
+	}
+	l.digestToolResults(*toolResults)
+	l.history = append(l.history, l.assistantMsg, *toolResults)
+	if feedback := l.formatFeedback(ctx, l.assistantMsg.ToolCalls(), toolResults.ToolResults()); feedback != "" {
+		feedbackMsg, err := l.createFeedbackMessage(ctx, feedback)
+		if err != nil {
+			return l.finish(l.err(fmt.Errorf("failed to create formatting message: %w", err)))
+		}
+		l.history = append(l.history, feedbackMsg)
+	}
+	if feedback := l.postEditFeedback(ctx, l.assistantMsg.ToolCalls(), toolResults.ToolResults()); feedback != "" {
+		feedbackMsg, err := l.createFeedbackMessage(ctx, feedback)
+		if err != nil {
+			return l.finish(l.err(fmt.Errorf("failed to create hooks message: %w", err)))
+		}
+		l.history = append(l.history, feedbackMsg)
+	}
+	if feedback := l.diagnosticsFeedback(l.assistantMsg.ToolCalls(), toolResults.ToolResults()); feedback != "" {
+		feedbackMsg, err := l.createFeedbackMessage(ctx, feedback)
+		if err != nil {
+			return l.finish(l.err(fmt.Errorf("failed to create diagnostics message: %w", err)))
+		}
+		l.history = append(l.history, feedbackMsg)
+	}
+	if instructions := l.nestedContextFeedback(l.assistantMsg.ToolCalls(), toolResults.ToolResults()); instructions != "" {
+		instructionsMsg, err := l.createFeedbackMessage(ctx, instructions)
+		if err != nil {
+			return l.finish(l.err(fmt.Errorf("failed to create instructions message: %w", err)))
+		}
+		l.history = append(l.history, instructionsMsg)
+	}
+	return LoopStateStreaming
+}
+
+func (l *loop) runVerify(ctx context.Context) LoopState {
+	l.needsVerify = false
+	l.verifyAttempts++
+	maxAttempts := cmp.Or(l.verify.MaxAttempts, defaultVerifyMaxAttempts)
+	result := runVerify(ctx, l.verify)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return l.finish(l.err(ErrRequestCancelled))
+	}
+	l.lastVerify = &result
+	if !result.passed() && l.verifyAttempts < maxAttempts {
+		feedbackMsg, err := l.createFeedbackMessage(ctx, result.prompt(l.verifyAttempts, maxAttempts))
+		if err != nil {
+			return l.finish(l.err(fmt.Errorf("failed to create verify message: %w", err)))
+		}
+		l.history = append(l.history, l.assistantMsg, feedbackMsg)
+		return LoopStateStreaming
+	}
+	return l.done(ctx)
+}
+
+// done ends the turn with the latest response, adding the citations and the
+// verify summary to it.
+func (l *loop) done(ctx context.Context) LoopState {
+	if len(l.citations) > 0 || l.lastVerify != nil {
+		if len(l.citations) > 0 {
+			l.assistantMsg.AddCitations(l.citations...)
+		}
+		if l.lastVerify != nil {
+			l.assistantMsg.AppendContent("\n\n" + l.lastVerify.summary())
+		}
+		if err := l.messages.Update(ctx, l.assistantMsg); err != nil {
+			slog.Error("Failed to update final message", "error", err)
+		}
+	}
+	return l.finish(AgentEvent{
+		Type:    AgentEventTypeResponse,
+		Message: l.assistantMsg,
+		Done:    true,
+	})
+}
+
+// watchPermissions publishes the permission requests of the tool calls of the
+// session, and keeps the loop in LoopStateAwaitingPermission until the tool
+// calls asking for them finish. The returned function stops watching and
+// restores the state.
+func (l *loop) watchPermissions(ctx context.Context) (stop func()) {
+	if l.permissions == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	requests := l.permissions.Subscribe(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-requests:
+				if !ok {
+					return
+				}
+				request := event.Payload
+				if request.SessionID != l.sessionID {
+					continue
+				}
+				l.awaitPermission(request.ToolCallID)
+				awaiting := l.event(LoopEventAwaitingPermission)
+				awaiting.Permission = &request
+				l.publish(awaiting)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		l.stateMu.Lock()
+		defer l.stateMu.Unlock()
+		if len(l.awaiting) > 0 {
+			clear(l.awaiting)
+			l.state = l.resume
+		}
+	}
+}
+
+// awaitPermission moves the loop to LoopStateAwaitingPermission while the
+// tool call waits for the permission.
+func (l *loop) awaitPermission(toolCallID string) {
+	l.stateMu.Lock()
+	defer l.stateMu.Unlock()
+	if len(l.awaiting) == 0 {
+		l.resume = l.state
+	}
+	if l.awaiting == nil {
+		l.awaiting = make(map[string]bool)
+	}
+	l.awaiting[toolCallID] = true
+	l.state = LoopStateAwaitingPermission
+}
+
+// toolCallFinished resumes the loop once no tool call waits for a permission
+// anymore.
+func (l *loop) toolCallFinished(toolCallID string) {
+	l.stateMu.Lock()
+	defer l.stateMu.Unlock()
+	if !l.awaiting[toolCallID] {
+		return
+	}
+	delete(l.awaiting, toolCallID)
+	if len(l.awaiting) == 0 {
+		l.state = l.resume
+	}
+}
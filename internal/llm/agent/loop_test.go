@@ -0,0 +1,137 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+// answerPermissions grants or denies the permission requests once the loop
+// published that it awaits them.
+func answerPermissions(t *testing.T, h *agenttest.Harness, grant bool) {
+	events := h.Agent.SubscribeLoop(t.Context())
+	go func() {
+		for event := range events {
+			if event.Payload.Type != agent.LoopEventAwaitingPermission {
+				continue
+			}
+			if grant {
+				h.Permissions.Grant(*event.Payload.Permission)
+			} else {
+				h.Permissions.Deny(*event.Payload.Permission)
+			}
+		}
+	}()
+}
+
+// transitions returns the types and the states of the events, without the
+// repeated ones.
+func transitions(events []agent.LoopEvent) ([]agent.LoopEventType, []agent.LoopState) {
+	var types []agent.LoopEventType
+	var states []agent.LoopState
+	for _, event := range events {
+		if len(types) == 0 || types[len(types)-1] != event.Type {
+			types = append(types, event.Type)
+		}
+		if len(states) == 0 || states[len(states)-1] != event.State {
+			states = append(states, event.State)
+		}
+	}
+	return types, states
+}
+
+func TestLoopEvents(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithPermissionRequests())
+	answerPermissions(t, h, true)
+	write := agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("hello.txt"), "content": "hello\n"})
+	write.Content = "Writing hello.txt."
+	h.Large.Script(write, agenttest.Text("Done."))
+
+	turn, err := h.Run("Write hello.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", h.ReadFile("hello.txt"))
+
+	types, states := transitions(turn.Events)
+	require.Equal(t, []agent.LoopEventType{
+		agent.LoopEventTurnStarted,
+		agent.LoopEventStreamDelta,
+		agent.LoopEventToolRequested,
+		agent.LoopEventAwaitingPermission,
+		agent.LoopEventToolFinished,
+		agent.LoopEventStreamDelta,
+		agent.LoopEventTurnFinished,
+	}, types)
+	require.Equal(t, []agent.LoopState{
+		agent.LoopStateStreaming,
+		agent.LoopStateRunningTools,
+		agent.LoopStateAwaitingPermission,
+		agent.LoopStateRunningTools,
+		agent.LoopStateStreaming,
+		agent.LoopStateFinished,
+	}, states)
+
+	for _, event := range turn.Events {
+		require.Equal(t, h.Session.ID, event.SessionID)
+		switch event.Type {
+		case agent.LoopEventAwaitingPermission:
+			require.Equal(t, "call_1", event.Permission.ToolCallID)
+			require.Equal(t, 1, event.Step)
+		case agent.LoopEventToolFinished:
+			require.False(t, event.ToolResult.IsError, event.ToolResult.Content)
+		case agent.LoopEventTurnFinished:
+			require.Equal(t, 2, event.Step)
+			require.Equal(t, message.FinishReasonEndTurn, event.FinishReason)
+		}
+	}
+}
+
+func TestLoopPermissionDenied(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithPermissionRequests())
+	answerPermissions(t, h, false)
+	h.Large.Script(
+		agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("hello.txt"), "content": "hello\n"}),
+		agenttest.Text("Not reached."),
+	)
+
+	turn, err := h.Run("Write hello.txt")
+	require.NoError(t, err)
+	require.Equal(t, message.FinishReasonPermissionDenied, turn.Message.FinishReason())
+	require.Equal(t, 1, h.Large.Remaining(), "a denied permission ends the turn")
+
+	types, states := transitions(turn.Events)
+	require.Equal(t, []agent.LoopEventType{
+		agent.LoopEventTurnStarted,
+		agent.LoopEventToolRequested,
+		agent.LoopEventAwaitingPermission,
+		agent.LoopEventToolFinished,
+		agent.LoopEventTurnFinished,
+	}, types)
+	require.Equal(t, []agent.LoopState{
+		agent.LoopStateStreaming,
+		agent.LoopStateRunningTools,
+		agent.LoopStateAwaitingPermission,
+		agent.LoopStateRunningTools,
+		agent.LoopStateFinished,
+	}, states)
+	last := turn.Events[len(turn.Events)-1]
+	require.Equal(t, message.FinishReasonPermissionDenied, last.FinishReason)
+}
+
+func TestLoopWithoutPermissions(t *testing.T) {
+	h := agenttest.New(t)
+	h.WriteFile("notes.txt", "notes\n")
+	h.Large.Script(
+		agenttest.ToolCall(tools.ViewToolName, map[string]string{"file_path": h.Path("notes.txt")}),
+		agenttest.Text("Read it."),
+	)
+
+	turn, err := h.Run("Read notes.txt")
+	require.NoError(t, err)
+	_, states := transitions(turn.Events)
+	require.NotContains(t, states, agent.LoopStateAwaitingPermission)
+	require.Equal(t, agent.LoopStateFinished, states[len(states)-1])
+}
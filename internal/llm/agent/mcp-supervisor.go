@@ -0,0 +1,481 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/version"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MCPStatus is the health of an MCP server.
+type MCPStatus string
+
+const (
+	// MCPIdle servers whose tools are known start on the first call of one
+	// of them.
+	MCPIdle     MCPStatus = "idle"
+	MCPStarting MCPStatus = "starting"
+	MCPRunning  MCPStatus = "running"
+	// MCPFailed servers are restarted with a backoff.
+	MCPFailed   MCPStatus = "failed"
+	MCPDisabled MCPStatus = "disabled"
+)
+
+// MCPState is the health of an MCP server, published when it changes.
+type MCPState struct {
+	Name     string    `json:"name"`
+	Status   MCPStatus `json:"status"`
+	Tools    int       `json:"tools"`
+	Restarts int       `json:"restarts"`
+	Error    string    `json:"error,omitempty"`
+	// RetryAt is when a failed server is restarted, zero once it's given up
+	// on until its tools are used again.
+	RetryAt time.Time `json:"retry_at,omitzero"`
+}
+
+const (
+	// mcpMaxFailures is the number of failures in a row after which a
+	// server isn't restarted in the background anymore.
+	mcpMaxFailures    = 5
+	mcpMaxBackoff     = time.Minute
+	mcpHealthInterval = 30 * time.Second
+	mcpPingTimeout    = 5 * time.Second
+	// MCPToolsFile caches the tools of the MCP servers in the data
+	// directory, for them to start lazily.
+	MCPToolsFile = "mcp-tools.json"
+)
+
+var (
+	mcpStates = pubsub.NewBroker[MCPState]()
+
+	mcpSupervisorMu sync.Mutex
+	mcpSupervisor   *mcpServers
+)
+
+// SubscribeMCPStates returns the changes of the health of MCP servers.
+func SubscribeMCPStates(ctx context.Context) <-chan pubsub.Event[MCPState] {
+	return mcpStates.Subscribe(ctx)
+}
+
+// MCPStates returns the health of the MCP servers of the agents, sorted by
+// name, or nil before the agents load them.
+func MCPStates() []MCPState {
+	mcpSupervisorMu.Lock()
+	s := mcpSupervisor
+	mcpSupervisorMu.Unlock()
+	if s == nil {
+		return nil
+	}
+	return s.states()
+}
+
+// RestartMCPServers restarts the MCP servers of the agents, all of them if
+// no name is given.
+func RestartMCPServers(names ...string) error {
+	mcpSupervisorMu.Lock()
+	s := mcpSupervisor
+	mcpSupervisorMu.Unlock()
+	if s == nil {
+		return errors.New("MCP servers aren't loaded yet")
+	}
+	return s.restart(names...)
+}
+
+// CloseMCPServers stops the MCP servers of the agents.
+func CloseMCPServers() {
+	mcpSupervisorMu.Lock()
+	s := mcpSupervisor
+	mcpSupervisorMu.Unlock()
+	if s != nil {
+		s.close()
+	}
+}
+
+// CheckMCPServers starts the enabled MCP servers of the configuration, the
+// given ones if any, to report their health and refresh the cache of their
+// tools. They are stopped before returning.
+func CheckMCPServers(ctx context.Context, cfg *config.Config, names ...string) ([]MCPState, error) {
+	for _, name := range names {
+		if _, ok := cfg.MCP[name]; !ok {
+			return nil, fmt.Errorf("no MCP server named %s", name)
+		}
+	}
+	s := newMCPServers(ctx, cfg, nil)
+	defer s.close()
+	var wg sync.WaitGroup
+	for _, m := range cfg.MCP.Sorted() {
+		if len(names) > 0 && !slices.Contains(names, m.Name) {
+			continue
+		}
+		srv := s.add(m.Name, m.MCP)
+		if m.MCP.Disabled {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			srv.client(ctx)
+		}()
+	}
+	wg.Wait()
+	return s.states(), nil
+}
+
+// mcpServers supervises the MCP servers of a configuration.
+type mcpServers struct {
+	// ctx outlives the calls, as the transports of some servers are bound
+	// to the context they're started with.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	connect func(ctx context.Context, name string, m config.MCPConfig) (MCPClient, error)
+	backoff time.Duration
+	cache   *mcpToolCache
+	servers *csync.Map[string, *mcpServer]
+	// published, if set, gets the states as they change.
+	published *pubsub.Broker[MCPState]
+}
+
+func newMCPServers(ctx context.Context, cfg *config.Config, published *pubsub.Broker[MCPState]) *mcpServers {
+	ctx, cancel := context.WithCancel(ctx)
+	return &mcpServers{
+		ctx:    ctx,
+		cancel: cancel,
+		connect: func(ctx context.Context, name string, m config.MCPConfig) (MCPClient, error) {
+			return newMCPClient(ctx, cfg, name, m)
+		},
+		backoff:   time.Second,
+		cache:     &mcpToolCache{path: filepath.Join(cfg.Options.DataDirectory, MCPToolsFile)},
+		servers:   csync.NewMap[string, *mcpServer](),
+		published: published,
+	}
+}
+
+func (s *mcpServers) add(name string, m config.MCPConfig) *mcpServer {
+	srv := &mcpServer{
+		servers: s,
+		name:    name,
+		cfg:     m,
+		state:   MCPState{Name: name, Status: MCPIdle},
+	}
+	if m.Disabled {
+		srv.state.Status = MCPDisabled
+	}
+	s.servers.Set(name, srv)
+	return srv
+}
+
+func (s *mcpServers) states() []MCPState {
+	var states []MCPState
+	for _, srv := range s.servers.Seq2() {
+		srv.mu.Lock()
+		states = append(states, srv.state)
+		srv.mu.Unlock()
+	}
+	slices.SortFunc(states, func(a, b MCPState) int { return strings.Compare(a.Name, b.Name) })
+	return states
+}
+
+func (s *mcpServers) restart(names ...string) error {
+	for _, name := range names {
+		if _, ok := s.servers.Get(name); !ok {
+			return fmt.Errorf("no MCP server named %s", name)
+		}
+	}
+	var errs []error
+	for name, srv := range s.servers.Seq2() {
+		if (len(names) > 0 && !slices.Contains(names, name)) || srv.cfg.Disabled {
+			continue
+		}
+		if err := srv.restart(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// monitor pings the running servers, to restart those that died while
+// nobody used them.
+func (s *mcpServers) monitor() {
+	ticker := time.NewTicker(mcpHealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, srv := range s.servers.Seq2() {
+				srv.check()
+			}
+		}
+	}
+}
+
+func (s *mcpServers) close() {
+	s.cancel()
+	for _, srv := range s.servers.Seq2() {
+		srv.stop()
+	}
+}
+
+// mcpServer is the connection to an MCP server, started on first use and
+// restarted when it fails.
+type mcpServer struct {
+	servers *mcpServers
+	name    string
+	cfg     config.MCPConfig
+
+	mu    sync.Mutex
+	conn  MCPClient
+	tools []mcp.Tool
+	state MCPState
+	// failures in a row, for the backoff.
+	failures int
+	// wanted servers ran once, and are restarted in the background when
+	// they fail.
+	wanted       bool
+	restartTimer *time.Timer
+}
+
+// client returns the connection to the server, starting it if needed.
+func (srv *mcpServer) client(ctx context.Context) (MCPClient, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.conn != nil {
+		return srv.conn, nil
+	}
+	if srv.state.Status == MCPDisabled {
+		return nil, fmt.Errorf("mcp server %s is disabled", srv.name)
+	}
+	if srv.state.Status == MCPFailed && time.Now().Before(srv.state.RetryAt) {
+		return nil, fmt.Errorf("mcp server %s failed, retrying in %s: %s", srv.name, time.Until(srv.state.RetryAt).Round(time.Second), srv.state.Error)
+	}
+	if err := srv.start(ctx); err != nil {
+		return nil, err
+	}
+	return srv.conn, nil
+}
+
+// start connects to the server and lists its tools. The lock must be held.
+func (srv *mcpServer) start(ctx context.Context) error {
+	srv.setStatus(MCPStarting, "")
+	c, err := srv.servers.connect(srv.servers.ctx, srv.name, srv.cfg)
+	var tools []mcp.Tool
+	if err == nil {
+		tools, err = initializeMCP(ctx, c)
+		if err != nil {
+			c.Close()
+		}
+	}
+	if err != nil {
+		logMCPError(srv.name, "error starting mcp server", err)
+		srv.failed(err)
+		return err
+	}
+	srv.conn, srv.tools = c, tools
+	srv.failures = 0
+	srv.wanted = true
+	srv.state.Tools = len(tools)
+	srv.state.RetryAt = time.Time{}
+	srv.setStatus(MCPRunning, "")
+	if err := srv.servers.cache.set(srv.name, srv.cfg, tools); err != nil {
+		slog.Warn("Failed to cache mcp tools", "name", srv.name, "error", err)
+	}
+	return nil
+}
+
+// failed records a failure to start or a crash, and schedules a restart.
+// The lock must be held.
+func (srv *mcpServer) failed(err error) {
+	srv.failures++
+	srv.state.RetryAt = time.Time{}
+	if srv.failures <= mcpMaxFailures {
+		backoff := min(srv.servers.backoff<<(srv.failures-1), mcpMaxBackoff)
+		srv.state.RetryAt = time.Now().Add(backoff)
+		if srv.wanted {
+			srv.restartTimer = time.AfterFunc(backoff, srv.retry)
+		}
+	}
+	srv.setStatus(MCPFailed, err.Error())
+}
+
+func (srv *mcpServer) retry() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.conn != nil || srv.servers.ctx.Err() != nil {
+		return
+	}
+	srv.state.Restarts++
+	_ = srv.start(srv.servers.ctx)
+}
+
+// crashed closes a connection that failed, unless it was replaced already.
+func (srv *mcpServer) crashed(c MCPClient, err error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.conn != c {
+		return
+	}
+	slog.Error("mcp server failed", "name", srv.name, "error", err)
+	srv.conn.Close()
+	srv.conn = nil
+	srv.failed(err)
+}
+
+// check pings the server if it's running, handling it as a crash if it
+// doesn't answer.
+func (srv *mcpServer) check() {
+	srv.mu.Lock()
+	c := srv.conn
+	srv.mu.Unlock()
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(srv.servers.ctx, mcpPingTimeout)
+	defer cancel()
+	if err := c.Ping(ctx); err != nil && srv.servers.ctx.Err() == nil {
+		srv.crashed(c, fmt.Errorf("not responding: %w", err))
+	}
+}
+
+func (srv *mcpServer) restart() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.stopLocked()
+	srv.failures = 0
+	if srv.wanted {
+		srv.state.Restarts++
+	}
+	return srv.start(srv.servers.ctx)
+}
+
+func (srv *mcpServer) stop() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.stopLocked()
+}
+
+func (srv *mcpServer) stopLocked() {
+	if srv.restartTimer != nil {
+		srv.restartTimer.Stop()
+		srv.restartTimer = nil
+	}
+	if srv.conn != nil {
+		srv.conn.Close()
+		srv.conn = nil
+	}
+}
+
+// call runs a tool of the server. When the call fails and the server
+// doesn't answer a ping anymore, it's restarted.
+func (srv *mcpServer) call(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c, err := srv.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.CallTool(ctx, request)
+	if err != nil && ctx.Err() == nil {
+		pingCtx, cancel := context.WithTimeout(ctx, mcpPingTimeout)
+		defer cancel()
+		if pingErr := c.Ping(pingCtx); pingErr != nil {
+			srv.crashed(c, err)
+			return nil, fmt.Errorf("mcp server %s failed and will be restarted: %w", srv.name, err)
+		}
+	}
+	return result, err
+}
+
+// setStatus publishes the state. The lock must be held.
+func (srv *mcpServer) setStatus(status MCPStatus, errMsg string) {
+	srv.state.Status = status
+	srv.state.Error = errMsg
+	if srv.servers.published != nil {
+		srv.servers.published.Publish(pubsub.UpdatedEvent, srv.state)
+	}
+}
+
+func initializeMCP(ctx context.Context, c MCPClient) ([]mcp.Tool, error) {
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{
+		Name:    "Crush",
+		Version: version.Version,
+	}
+	if _, err := c.Initialize(ctx, initRequest); err != nil {
+		return nil, err
+	}
+	result, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tools: %w", err)
+	}
+	return result.Tools, nil
+}
+
+// mcpToolCache keeps the tools of the servers with the hash of their
+// config, for a server to start lazily when its config didn't change.
+type mcpToolCache struct {
+	path string
+	mu   sync.Mutex
+}
+
+type mcpCachedTools struct {
+	Key   string     `json:"key"`
+	Tools []mcp.Tool `json:"tools"`
+}
+
+func mcpConfigKey(m config.MCPConfig) string {
+	data, _ := json.Marshal(m)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *mcpToolCache) load() map[string]mcpCachedTools {
+	cached := map[string]mcpCachedTools{}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return cached
+	}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		slog.Warn("Ignoring invalid mcp tools cache", "path", c.path, "error", err)
+	}
+	return cached
+}
+
+func (c *mcpToolCache) get(name string, m config.MCPConfig) ([]mcp.Tool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.load()[name]
+	if !ok || entry.Key != mcpConfigKey(m) {
+		return nil, false
+	}
+	return entry.Tools, true
+}
+
+func (c *mcpToolCache) set(name string, m config.MCPConfig, tools []mcp.Tool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := c.load()
+	cached[name] = mcpCachedTools{Key: mcpConfigKey(m), Tools: tools}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o600)
+}
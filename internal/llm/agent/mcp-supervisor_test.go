@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMCPClient is a server with one tool, which dies when told to.
+type fakeMCPClient struct {
+	dead   atomic.Bool
+	closed atomic.Bool
+}
+
+func (c *fakeMCPClient) Initialize(context.Context, mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	return &mcp.InitializeResult{}, nil
+}
+
+func (c *fakeMCPClient) ListTools(context.Context, mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	return &mcp.ListToolsResult{Tools: []mcp.Tool{mcp.NewTool("echo")}}, nil
+}
+
+func (c *fakeMCPClient) CallTool(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if c.dead.Load() {
+		return nil, errors.New("broken pipe")
+	}
+	return mcp.NewToolResultText(request.Params.Name), nil
+}
+
+func (c *fakeMCPClient) Ping(context.Context) error {
+	if c.dead.Load() {
+		return errors.New("broken pipe")
+	}
+	return nil
+}
+
+func (c *fakeMCPClient) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// fakeConnector connects to fake servers, failing while failing is set.
+type fakeConnector struct {
+	clients *csync.Slice[*fakeMCPClient]
+	failing atomic.Bool
+}
+
+func (f *fakeConnector) connect(context.Context, string, config.MCPConfig) (MCPClient, error) {
+	if f.failing.Load() {
+		return nil, errors.New("command not found")
+	}
+	c := &fakeMCPClient{}
+	f.clients.Append(c)
+	return c, nil
+}
+
+func newTestMCPServers(t *testing.T) (*mcpServers, *fakeConnector) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	connector := &fakeConnector{clients: csync.NewSlice[*fakeMCPClient]()}
+	s := &mcpServers{
+		ctx:     ctx,
+		cancel:  cancel,
+		connect: connector.connect,
+		backoff: 10 * time.Millisecond,
+		cache:   &mcpToolCache{path: filepath.Join(t.TempDir(), MCPToolsFile)},
+		servers: csync.NewMap[string, *mcpServer](),
+	}
+	t.Cleanup(s.close)
+	return s, connector
+}
+
+func callEcho(t *testing.T, srv *mcpServer) (*mcp.CallToolResult, error) {
+	t.Helper()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "echo"
+	return srv.call(t.Context(), request)
+}
+
+func TestMCPServer_LazyStart(t *testing.T) {
+	s, connector := newTestMCPServers(t)
+	srv := s.add("fake", config.MCPConfig{Command: "fake"})
+	require.Equal(t, MCPIdle, s.states()[0].Status)
+	require.Zero(t, connector.clients.Len())
+
+	result, err := callEcho(t, srv)
+	require.NoError(t, err)
+	require.Equal(t, "echo", result.Content[0].(mcp.TextContent).Text)
+	_, err = callEcho(t, srv)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, connector.clients.Len())
+	state := s.states()[0]
+	require.Equal(t, MCPRunning, state.Status)
+	require.Equal(t, 1, state.Tools)
+}
+
+func TestMCPServer_RestartAfterCrash(t *testing.T) {
+	s, connector := newTestMCPServers(t)
+	srv := s.add("fake", config.MCPConfig{Command: "fake"})
+	_, err := callEcho(t, srv)
+	require.NoError(t, err)
+
+	first, _ := connector.clients.Get(0)
+	first.dead.Store(true)
+	_, err = callEcho(t, srv)
+	require.ErrorContains(t, err, "will be restarted")
+	require.True(t, first.closed.Load())
+
+	require.Eventually(t, func() bool {
+		return s.states()[0].Status == MCPRunning
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, 1, s.states()[0].Restarts)
+	_, err = callEcho(t, srv)
+	require.NoError(t, err)
+	require.Equal(t, 2, connector.clients.Len())
+}
+
+func TestMCPServer_Backoff(t *testing.T) {
+	s, connector := newTestMCPServers(t)
+	connector.failing.Store(true)
+	srv := s.add("fake", config.MCPConfig{Command: "fake"})
+
+	_, err := callEcho(t, srv)
+	require.ErrorContains(t, err, "command not found")
+	state := s.states()[0]
+	require.Equal(t, MCPFailed, state.Status)
+	require.Equal(t, "command not found", state.Error)
+
+	// Calls fail fast until the backoff elapses.
+	connector.failing.Store(false)
+	_, err = callEcho(t, srv)
+	require.ErrorContains(t, err, "retrying in")
+	require.Eventually(t, func() bool {
+		_, err := callEcho(t, srv)
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMCPServer_Monitor(t *testing.T) {
+	s, connector := newTestMCPServers(t)
+	srv := s.add("fake", config.MCPConfig{Command: "fake"})
+	_, err := callEcho(t, srv)
+	require.NoError(t, err)
+
+	first, _ := connector.clients.Get(0)
+	first.dead.Store(true)
+	srv.check()
+	require.Equal(t, MCPFailed, s.states()[0].Status)
+	require.Contains(t, s.states()[0].Error, "not responding")
+	require.Eventually(t, func() bool {
+		return s.states()[0].Status == MCPRunning
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestMCPServers_Restart(t *testing.T) {
+	s, connector := newTestMCPServers(t)
+	srv := s.add("fake", config.MCPConfig{Command: "fake"})
+	s.add("off", config.MCPConfig{Command: "off", Disabled: true})
+	_, err := callEcho(t, srv)
+	require.NoError(t, err)
+
+	require.ErrorContains(t, s.restart("nope"), "no MCP server named nope")
+	require.NoError(t, s.restart())
+	first, _ := connector.clients.Get(0)
+	require.True(t, first.closed.Load())
+	require.Equal(t, 2, connector.clients.Len())
+
+	states := s.states()
+	require.Equal(t, MCPRunning, states[0].Status)
+	require.Equal(t, 1, states[0].Restarts)
+	require.Equal(t, MCPDisabled, states[1].Status)
+}
+
+func TestMCPToolCache(t *testing.T) {
+	s, _ := newTestMCPServers(t)
+	m := config.MCPConfig{Command: "fake"}
+	_, ok := s.cache.get("fake", m)
+	require.False(t, ok)
+
+	_, err := callEcho(t, s.add("fake", m))
+	require.NoError(t, err)
+	tools, ok := s.cache.get("fake", m)
+	require.True(t, ok)
+	require.Equal(t, "echo", tools[0].Name)
+
+	// Tools of servers whose config changed are listed again.
+	_, ok = s.cache.get("fake", config.MCPConfig{Command: "fake", Args: []string{"--v2"}})
+	require.False(t, ok)
+}
@@ -5,15 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"slices"
 	"sync"
 
 	"github.com/charmbracelet/crush/internal/config"
-	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/mcpauth"
+	"github.com/charmbracelet/crush/internal/message"
 
 	"github.com/charmbracelet/crush/internal/permission"
-	"github.com/charmbracelet/crush/internal/version"
 
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/client/transport"
@@ -23,7 +22,7 @@ import (
 type McpTool struct {
 	mcpName     string
 	tool        mcp.Tool
-	client      MCPClient
+	server      *mcpServer
 	mcpConfig   config.MCPConfig
 	permissions permission.Service
 	workingDir  string
@@ -36,6 +35,7 @@ type MCPClient interface {
 	) (*mcp.InitializeResult, error)
 	ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error)
 	CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+	Ping(ctx context.Context) error
 	Close() error
 }
 
@@ -56,41 +56,44 @@ func (b *McpTool) Info() tools.ToolInfo {
 	}
 }
 
-func runTool(ctx context.Context, c MCPClient, toolName string, input string) (tools.ToolResponse, error) {
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{
-		Name:    "Crush",
-		Version: version.Version,
-	}
-
-	_, err := c.Initialize(ctx, initRequest)
-	if err != nil {
-		return tools.NewTextErrorResponse(err.Error()), nil
-	}
-
+func runTool(ctx context.Context, s *mcpServer, toolName string, input string) (tools.ToolResponse, error) {
 	toolRequest := mcp.CallToolRequest{}
 	toolRequest.Params.Name = toolName
 	var args map[string]any
-	if err = json.Unmarshal([]byte(input), &args); err != nil {
+	if err := json.Unmarshal([]byte(input), &args); err != nil {
 		return tools.NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
 	}
 	toolRequest.Params.Arguments = args
-	result, err := c.CallTool(ctx, toolRequest)
+	result, err := s.call(ctx, toolRequest)
 	if err != nil {
 		return tools.NewTextErrorResponse(err.Error()), nil
 	}
 
 	output := ""
+	var citations []message.Citation
 	for _, v := range result.Content {
-		if v, ok := v.(mcp.TextContent); ok {
+		switch v := v.(type) {
+		case mcp.TextContent:
 			output = v.Text
-		} else {
+		case mcp.EmbeddedResource:
+			// Resources carry a URI, which lets us cite where the content
+			// came from.
+			switch r := v.Resource.(type) {
+			case mcp.TextResourceContents:
+				output = r.Text
+				citations = append(citations, message.Citation{Source: r.URI})
+			case mcp.BlobResourceContents:
+				output = fmt.Sprintf("%v", v)
+				citations = append(citations, message.Citation{Source: r.URI})
+			default:
+				output = fmt.Sprintf("%v", v)
+			}
+		default:
 			output = fmt.Sprintf("%v", v)
 		}
 	}
 
-	return tools.NewTextResponse(output), nil
+	return tools.WithResponseCitations(tools.NewTextResponse(output), citations...), nil
 }
 
 func (b *McpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolResponse, error) {
@@ -114,13 +117,13 @@ func (b *McpTool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolRes
 		return tools.ToolResponse{}, permission.ErrorPermissionDenied
 	}
 
-	return runTool(ctx, b.client, b.tool.Name, params.Input)
+	return runTool(ctx, b.server, b.tool.Name, params.Input)
 }
 
-func NewMcpTool(name string, c MCPClient, tool mcp.Tool, permissions permission.Service, mcpConfig config.MCPConfig, workingDir string) tools.BaseTool {
+func NewMcpTool(name string, s *mcpServer, tool mcp.Tool, permissions permission.Service, mcpConfig config.MCPConfig, workingDir string) tools.BaseTool {
 	return &McpTool{
 		mcpName:     name,
-		client:      c,
+		server:      s,
 		tool:        tool,
 		mcpConfig:   mcpConfig,
 		permissions: permissions,
@@ -128,32 +131,6 @@ func NewMcpTool(name string, c MCPClient, tool mcp.Tool, permissions permission.
 	}
 }
 
-func getTools(ctx context.Context, name string, m config.MCPConfig, permissions permission.Service, c MCPClient, workingDir string) []tools.BaseTool {
-	var stdioTools []tools.BaseTool
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{
-		Name:    "Crush",
-		Version: version.Version,
-	}
-
-	_, err := c.Initialize(ctx, initRequest)
-	if err != nil {
-		slog.Error("error initializing mcp client", "error", err)
-		return stdioTools
-	}
-	toolsRequest := mcp.ListToolsRequest{}
-	tools, err := c.ListTools(ctx, toolsRequest)
-	if err != nil {
-		slog.Error("error listing tools", "error", err)
-		return stdioTools
-	}
-	for _, t := range tools.Tools {
-		stdioTools = append(stdioTools, NewMcpTool(name, c, t, permissions, m, workingDir))
-	}
-	return stdioTools
-}
-
 var (
 	mcpToolsOnce sync.Once
 	mcpTools     []tools.BaseTool
@@ -166,53 +143,116 @@ func GetMCPTools(ctx context.Context, permissions permission.Service, cfg *confi
 	return mcpTools
 }
 
+// doGetMCPTools returns the tools of the MCP servers. Servers whose tools
+// are cached from a previous run start on first use, the others right away
+// to list their tools.
 func doGetMCPTools(ctx context.Context, permissions permission.Service, cfg *config.Config) []tools.BaseTool {
+	supervisor := newMCPServers(ctx, cfg, mcpStates)
+	mcpSupervisorMu.Lock()
+	mcpSupervisor = supervisor
+	mcpSupervisorMu.Unlock()
+	go supervisor.monitor()
+
 	var wg sync.WaitGroup
-	result := csync.NewSlice[tools.BaseTool]()
-	for name, m := range cfg.MCP {
-		if m.Disabled {
-			slog.Debug("skipping disabled mcp", "name", name)
+	for _, m := range cfg.MCP.Sorted() {
+		srv := supervisor.add(m.Name, m.MCP)
+		if m.MCP.Disabled {
+			slog.Debug("skipping disabled mcp", "name", m.Name)
+			continue
+		}
+		if cached, ok := supervisor.cache.get(m.Name, m.MCP); ok {
+			srv.mu.Lock()
+			srv.tools = cached
+			srv.state.Tools = len(cached)
+			srv.mu.Unlock()
 			continue
 		}
 		wg.Add(1)
-		go func(name string, m config.MCPConfig) {
+		go func() {
 			defer wg.Done()
-			switch m.Type {
-			case config.MCPStdio:
-				c, err := client.NewStdioMCPClient(
-					m.Command,
-					m.ResolvedEnv(),
-					m.Args...,
-				)
-				if err != nil {
-					slog.Error("error creating mcp client", "error", err)
-					return
-				}
-
-				result.Append(getTools(ctx, name, m, permissions, c, cfg.WorkingDir())...)
-			case config.MCPHttp:
-				c, err := client.NewStreamableHttpClient(
-					m.URL,
-					transport.WithHTTPHeaders(m.ResolvedHeaders()),
-				)
-				if err != nil {
-					slog.Error("error creating mcp client", "error", err)
-					return
-				}
-				result.Append(getTools(ctx, name, m, permissions, c, cfg.WorkingDir())...)
-			case config.MCPSse:
-				c, err := client.NewSSEMCPClient(
-					m.URL,
-					client.WithHeaders(m.ResolvedHeaders()),
-				)
-				if err != nil {
-					slog.Error("error creating mcp client", "error", err)
-					return
-				}
-				result.Append(getTools(ctx, name, m, permissions, c, cfg.WorkingDir())...)
-			}
-		}(name, m)
+			srv.client(ctx)
+		}()
 	}
 	wg.Wait()
-	return slices.Collect(result.Seq())
+
+	var result []tools.BaseTool
+	for _, m := range cfg.MCP.Sorted() {
+		srv, ok := supervisor.servers.Get(m.Name)
+		if !ok || m.MCP.Disabled {
+			continue
+		}
+		srv.mu.Lock()
+		for _, t := range srv.tools {
+			result = append(result, NewMcpTool(m.Name, srv, t, permissions, m.MCP, cfg.WorkingDir()))
+		}
+		srv.mu.Unlock()
+	}
+	return result
+}
+
+// newMCPClient connects to an MCP server.
+func newMCPClient(ctx context.Context, cfg *config.Config, name string, m config.MCPConfig) (MCPClient, error) {
+	switch m.Type {
+	case config.MCPStdio:
+		return client.NewStdioMCPClient(
+			m.Command,
+			m.ResolvedEnv(),
+			m.Args...,
+		)
+	case config.MCPHttp:
+		opts := []transport.StreamableHTTPCOption{
+			transport.WithHTTPHeaders(m.ResolvedHeaders()),
+		}
+		if m.OAuth != nil {
+			opts = append(opts, transport.WithHTTPOAuth(mcpOAuthConfig(cfg, name, *m.OAuth)))
+		}
+		c, err := client.NewStreamableHttpClient(m.URL, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, startMCPClient(ctx, c)
+	case config.MCPSse:
+		opts := []transport.ClientOption{
+			client.WithHeaders(m.ResolvedHeaders()),
+		}
+		if m.OAuth != nil {
+			opts = append(opts, transport.WithOAuth(mcpOAuthConfig(cfg, name, *m.OAuth)))
+		}
+		c, err := client.NewSSEMCPClient(m.URL, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return c, startMCPClient(ctx, c)
+	default:
+		return nil, fmt.Errorf("unknown mcp type %q", m.Type)
+	}
+}
+
+// mcpOAuthConfig authenticates to a remote MCP server with the token saved
+// by 'crush mcp login', refreshing it as needed.
+func mcpOAuthConfig(cfg *config.Config, name string, oauth config.MCPOAuth) transport.OAuthConfig {
+	return transport.OAuthConfig{
+		ClientID:              oauth.ClientID,
+		ClientSecret:          oauth.ClientSecret,
+		Scopes:                oauth.Scopes,
+		TokenStore:            mcpauth.NewStore(cfg.Options.DataDirectory).For(name),
+		AuthServerMetadataURL: oauth.MetadataURL,
+	}
+}
+
+// startMCPClient starts the transport of a remote MCP server.
+func startMCPClient(ctx context.Context, c *client.Client) error {
+	if err := c.Start(ctx); err != nil {
+		c.Close()
+		return err
+	}
+	return nil
+}
+
+func logMCPError(name, msg string, err error) {
+	if client.IsOAuthAuthorizationRequiredError(err) {
+		slog.Error("mcp server requires authorization, run 'crush mcp login "+name+"'", "name", name)
+		return
+	}
+	slog.Error(msg, "name", name, "error", err)
 }
@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/memory"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+var (
+	memoryMu     sync.Mutex
+	memoryOpened bool
+	memoryStore  *memory.Store
+)
+
+// getMemoryStore returns the long-term memory of the project shared by the
+// agents, opening it on first use. It returns nil if the memory is disabled
+// or unavailable.
+func getMemoryStore(cfg *config.Config) *memory.Store {
+	if cfg.Options.Memory == nil || !cfg.Options.Memory.Enabled {
+		return nil
+	}
+
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	if memoryOpened {
+		return memoryStore
+	}
+	memoryOpened = true
+
+	store, err := memory.OpenProject(context.Background(), cfg)
+	if err != nil {
+		slog.Warn("Memory unavailable", "error", err)
+		return nil
+	}
+	memoryStore = store
+	return store
+}
+
+// CloseMemory closes the long-term memory of the project, it's opened again
+// on next use.
+func CloseMemory() {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+	memoryOpened = false
+	if memoryStore == nil {
+		return
+	}
+	if err := memoryStore.Close(); err != nil {
+		slog.Error("Failed to close the memory", "error", err)
+	}
+	memoryStore = nil
+}
+
+// memoriesMessage returns the message giving the memories relevant to the
+// prompt, before it in the history of the turn. It isn't stored, the
+// memories are found again each turn. ok is false if there are none.
+func (a *agent) memoriesMessage(ctx context.Context, sessionID, prompt string) (msg message.Message, ok bool) {
+	cfg := config.Get()
+	store := getMemoryStore(cfg)
+	if store == nil {
+		return message.Message{}, false
+	}
+	memories, err := store.Relevant(ctx, prompt, cfg.Options.Memory.Results())
+	if err != nil {
+		slog.Error("Failed to find the relevant memories", "error", err)
+		return message.Message{}, false
+	}
+	if len(memories) == 0 {
+		return message.Message{}, false
+	}
+	var sb strings.Builder
+	for _, m := range memories {
+		fmt.Fprintf(&sb, "- %s\n", m.Content)
+	}
+	return message.Message{
+		Role:      message.User,
+		SessionID: sessionID,
+		Parts: []message.ContentPart{message.TextContent{
+			Text: "Memories of past sessions about this project that may help with the next request:\n\n<memories>\n" + sb.String() + "</memories>",
+		}},
+	}, true
+}
@@ -0,0 +1,50 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Options.Memory = &config.Memory{Enabled: true}
+	}))
+	ctx := t.Context()
+	store, err := memory.Open(ctx, h.Config.Options.DataDirectory, nil)
+	require.NoError(t, err)
+	defer store.Close()
+	_, _, err = store.Add(ctx, "Migrations are numbered and live in internal/db/migrations")
+	require.NoError(t, err)
+	_, _, err = store.Add(ctx, "The changelog is generated from the commits")
+	require.NoError(t, err)
+
+	h.Large.Script(
+		agenttest.ToolCall(tools.MemoryToolName, map[string]string{"fact": "Run the migrations with task migrate"}),
+		agenttest.Text("Done."),
+	)
+	_, err = h.Run("Add a migration for the users table")
+	require.NoError(t, err)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 2)
+	messages := requests[0].Messages
+	require.Len(t, messages, 2)
+	require.Contains(t, messages[0].Content().Text, "- Migrations are numbered and live in internal/db/migrations\n")
+	require.NotContains(t, messages[0].Content().Text, "changelog")
+	require.Equal(t, "Add a migration for the users table", messages[1].Content().Text)
+
+	memories, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, memories, 3)
+	require.Equal(t, "Run the migrations with task migrate", memories[2].Content)
+
+	// The memories aren't stored in the session.
+	stored, err := h.Messages.List(ctx, h.Session.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Add a migration for the users table", stored[0].Content().Text)
+}
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// streamTimer measures a streamed request for the model stats, see crush
+// stats.
+type streamTimer struct {
+	started      time.Time
+	firstToken   time.Time
+	outputTokens int64
+}
+
+func newStreamTimer() *streamTimer {
+	return &streamTimer{started: time.Now()}
+}
+
+func (t *streamTimer) observe(event provider.ProviderEvent) {
+	switch event.Type {
+	case provider.EventThinkingDelta, provider.EventContentDelta, provider.EventToolUseStart:
+		if t.firstToken.IsZero() {
+			t.firstToken = time.Now()
+		}
+	case provider.EventComplete:
+		if event.Response != nil {
+			t.outputTokens = event.Response.Usage.OutputTokens
+		}
+	}
+}
+
+// recordMetric records the timing of the request, which failed with err if
+// not nil. Cancelled requests aren't recorded.
+func (l *loop) recordMetric(ctx context.Context, t *streamTimer, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	metric := session.Metric{
+		SessionID:    l.sessionID,
+		Provider:     l.providerID,
+		Model:        l.Model().ID,
+		OutputTokens: t.outputTokens,
+	}
+	if !t.firstToken.IsZero() {
+		metric.FirstToken = t.firstToken.Sub(t.started)
+		metric.Generation = time.Since(t.firstToken)
+	}
+	if err != nil {
+		metric.Error = err.Error()
+	}
+	if err := l.sessions.RecordMetric(ctx, metric); err != nil {
+		slog.Error("Failed to record the request metrics", "session_id", l.sessionID, "error", err)
+	}
+}
@@ -0,0 +1,35 @@
+package agent_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Recorded(t *testing.T) {
+	h := agenttest.New(t)
+	h.Large.Script(
+		agenttest.Step{Content: "Done.", Usage: provider.TokenUsage{OutputTokens: 120}},
+		agenttest.Step{Err: errors.New("overloaded")},
+	)
+	h.Small.SetFallback(agenttest.Text("Title"))
+
+	_, err := h.Run("Fix the tests")
+	require.NoError(t, err)
+	_, err = h.Run("Fix the other tests")
+	require.Error(t, err)
+
+	stats, err := h.Sessions.ModelStats(t.Context(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	require.Equal(t, agenttest.ProviderID, stats[0].Provider)
+	require.Equal(t, agenttest.LargeModel.ID, stats[0].Model)
+	require.Equal(t, int64(2), stats[0].Requests)
+	require.Equal(t, int64(1), stats[0].Errors)
+	require.Equal(t, int64(120), stats[0].OutputTokens)
+	require.Equal(t, 0.5, stats[0].ErrorRate())
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// nestedContextFeedback returns the prompt with the instructions of the
+// subdirectories of the files the tool calls read or changed, or "" if there
+// are none. The instructions of a directory are given once per session.
+func (l *loop) nestedContextFeedback(toolCalls []message.ToolCall, toolResults []message.ToolResult) string {
+	workingDir := config.Get().WorkingDir()
+	files := append(changedFiles(toolCalls, toolResults, workingDir), viewedFiles(toolCalls, toolResults, workingDir)...)
+
+	var instructions []string
+	for _, dir := range prompt.NestedContextDirs(workingDir, files) {
+		key := l.sessionID + "\x00" + dir
+		if _, ok := l.nestedContext.Get(key); ok {
+			continue
+		}
+		l.nestedContext.Set(key, true)
+		if content := prompt.NestedContext(dir); content != "" {
+			instructions = append(instructions, content)
+		}
+	}
+	if len(instructions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`The directories of the files you just worked on have instructions of their own. Follow them for the files of these directories: they take precedence over the project-wide instructions, and the ones of a subdirectory over the ones of its parents.
+
+<instructions>
+%s
+</instructions>`, strings.Join(instructions, "\n"))
+}
+
+// viewedFiles returns the paths of the files read by the successful tool
+// calls.
+func viewedFiles(toolCalls []message.ToolCall, toolResults []message.ToolResult, workingDir string) []string {
+	failed := make(map[string]bool, len(toolResults))
+	for _, tr := range toolResults {
+		failed[tr.ToolCallID] = tr.IsError
+	}
+	var paths []string
+	for _, tc := range toolCalls {
+		if tc.Name != tools.ViewToolName || failed[tc.ID] {
+			continue
+		}
+		var params tools.ViewParams
+		if err := json.Unmarshal([]byte(tc.Input), &params); err != nil || params.FilePath == "" {
+			continue
+		}
+		path := params.FilePath
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
@@ -0,0 +1,40 @@
+package agent_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNestedContext(t *testing.T) {
+	h := agenttest.New(t)
+	h.WriteFile("AGENTS.md", "Root instructions.")
+	h.WriteFile("internal/AGENTS.md", "Internal instructions.")
+	h.WriteFile("internal/db/CRUSH.md", "Use transactions.")
+	h.WriteFile("internal/db/db.go", "package db\n")
+	h.WriteFile("internal/db/query.go", "package db\n")
+	h.Large.Script(
+		agenttest.ToolCall(tools.ViewToolName, map[string]string{"file_path": h.Path("internal/db/db.go")}),
+		agenttest.ToolCall(tools.ViewToolName, map[string]string{"file_path": h.Path("internal/db/query.go")}),
+		agenttest.Text("Done."),
+	)
+
+	_, err := h.Run("Read the db package")
+	require.NoError(t, err)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 3)
+	instructions := requests[1].Messages[len(requests[1].Messages)-1].Content().Text
+	require.NotContains(t, instructions, "Root instructions.", "the instructions of the root are in the system prompt")
+	require.Contains(t, instructions, "Internal instructions.")
+	require.Contains(t, instructions, "Use transactions.")
+	require.Less(t, strings.Index(instructions, "Internal instructions."), strings.Index(instructions, "Use transactions."), "the instructions of the parents come first")
+
+	// The instructions are given once per session.
+	last := requests[2].Messages[len(requests[2].Messages)-1]
+	require.Empty(t, last.Content().Text)
+	require.NotEmpty(t, last.ToolResults())
+}
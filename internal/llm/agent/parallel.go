@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+// defaultMaxConcurrentTools is the number of tool calls of a session run at
+// once unless parallel_tools sets it.
+const defaultMaxConcurrentTools = 4
+
+// parallelTools only read, their calls can run at once. The calls of the
+// other tools, which edit files or run commands, run alone, the calls after
+// them see their changes.
+var parallelTools = []string{
+	tools.CodeSearchToolName,
+	tools.DefinitionToolName,
+	tools.DiagnosticsToolName,
+	tools.FetchToolName,
+	tools.GlobToolName,
+	tools.GrepToolName,
+	tools.LSToolName,
+	tools.OutlineToolName,
+	tools.ReferencesToolName,
+	tools.SourcegraphToolName,
+	tools.SymbolsToolName,
+	tools.ViewToolName,
+	tools.WebSearchToolName,
+}
+
+// maxConcurrentTools returns the number of tool calls of a session run at
+// once, 1 when they run one after the other.
+func maxConcurrentTools() int {
+	cfg := config.Get().Options.ParallelTools
+	switch {
+	case cfg == nil:
+		return defaultMaxConcurrentTools
+	case cfg.Disabled:
+		return 1
+	case cfg.MaxConcurrent > 0:
+		return cfg.MaxConcurrent
+	}
+	return defaultMaxConcurrentTools
+}
+
+// toolCallGroupEnd returns the end of the group of calls starting at start
+// that can run at once: the following calls of parallel tools, or the call
+// alone.
+func toolCallGroupEnd(toolCalls []message.ToolCall, start int) int {
+	end := start + 1
+	if !slices.Contains(parallelTools, toolCalls[start].Name) {
+		return end
+	}
+	for end < len(toolCalls) && slices.Contains(parallelTools, toolCalls[end].Name) {
+		end++
+	}
+	return end
+}
+
+// runToolCallGroup runs the calls of a group, up to limit at once, into
+// their results. The results of the calls not started before the context
+// was done are left empty. It reports whether a permission was denied.
+func (l *loop) runToolCallGroup(ctx context.Context, toolCalls []message.ToolCall, toolResults []message.ToolResult, limit int) (denied bool) {
+	if len(toolCalls) == 1 || limit <= 1 {
+		for i, toolCall := range toolCalls {
+			if ctx.Err() != nil {
+				return false
+			}
+			var err error
+			toolResults[i], err = l.runToolCall(ctx, toolCall)
+			if errors.Is(err, permission.ErrorPermissionDenied) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	for i, toolCall := range toolCalls {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return denied
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result, err := l.runToolCall(ctx, toolCall)
+			toolResults[i] = result
+			if errors.Is(err, permission.ErrorPermissionDenied) {
+				mu.Lock()
+				denied = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return denied
+}
@@ -0,0 +1,54 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func parallelToolCallsTest(t *testing.T, h *agenttest.Harness) {
+	t.Helper()
+	h.WriteFile("a.txt", "alpha\n")
+	h.WriteFile("b.txt", "beta\n")
+	var calls []message.ToolCall
+	for _, step := range []agenttest.Step{
+		agenttest.ToolCall("view", map[string]string{"file_path": h.Path("a.txt")}),
+		agenttest.ToolCall("ls", map[string]string{"path": h.Path(".")}),
+		agenttest.ToolCall("view", map[string]string{"file_path": h.Path("b.txt")}),
+		agenttest.ToolCall("write", map[string]string{"file_path": h.Path("c.txt"), "content": "gamma\n"}),
+		agenttest.ToolCall("view", map[string]string{"file_path": h.Path("c.txt")}),
+	} {
+		calls = append(calls, step.ToolCalls...)
+	}
+	h.Large.Script(agenttest.Step{ToolCalls: calls}, agenttest.Text("Done."))
+
+	_, err := h.Run("Read the files and write c.txt")
+	require.NoError(t, err)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 2)
+	results := requests[1].Messages[len(requests[1].Messages)-1].ToolResults()
+	require.Len(t, results, 5)
+	for i, result := range results {
+		require.Equal(t, requests[1].Messages[len(requests[1].Messages)-2].ToolCalls()[i].ID, result.ToolCallID, "the results are in the order of the calls")
+		require.False(t, result.IsError, result.Content)
+	}
+	require.Contains(t, results[0].Content, "alpha")
+	require.Contains(t, results[1].Content, "b.txt")
+	require.Contains(t, results[2].Content, "beta")
+	// The view after the write sees the file.
+	require.Contains(t, results[4].Content, "gamma")
+}
+
+func TestParallelToolCalls(t *testing.T) {
+	parallelToolCallsTest(t, agenttest.New(t))
+}
+
+func TestParallelToolCalls_Disabled(t *testing.T) {
+	parallelToolCallsTest(t, agenttest.New(t, agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Options.ParallelTools = &config.ParallelTools{Disabled: true}
+	})))
+}
@@ -0,0 +1,38 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionRules(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithPermissionRequests(), agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Permissions.Rules = []config.PermissionRule{
+			{Tool: "*", Decision: config.PermissionDeny, Paths: []string{".env"}},
+			{Tool: "write", Decision: config.PermissionAllow, Paths: []string{"./notes"}},
+		}
+	}))
+	h.WriteFile(".env", "SECRET=1\n")
+	h.Large.Script(
+		agenttest.ToolCall("view", map[string]string{"file_path": h.Path(".env")}),
+		agenttest.ToolCall("write", map[string]string{"file_path": h.Path("notes/todo.md"), "content": "hello\n"}),
+		agenttest.Text("Done."),
+	)
+
+	// Nobody answers permission requests, the rules decide.
+	turn, err := h.Run("Print the secret")
+	require.NoError(t, err)
+	require.Equal(t, "Done.", turn.Message.Content().Text)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 3)
+	denied := requests[1].Messages[len(requests[1].Messages)-1].ToolResults()
+	require.True(t, denied[0].IsError)
+	require.Contains(t, denied[0].Content, `permission rule "deny * .env"`)
+	allowed := requests[2].Messages[len(requests[2].Messages)-1].ToolResults()
+	require.False(t, allowed[0].IsError)
+	require.Equal(t, "hello\n", h.ReadFile("notes/todo.md"))
+}
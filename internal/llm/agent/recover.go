@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// TurnsDir is the directory of the data directory with the journals of the
+// turns in progress, removed once they finish. The journals left behind are
+// of the turns interrupted when Crush was killed or crashed.
+const TurnsDir = "turns"
+
+// ErrNoInterruptedTurn is returned when re-running a session whose last turn
+// wasn't interrupted.
+var ErrNoInterruptedTurn = errors.New("no interrupted turn in this session")
+
+// InterruptedTurn is the journal of a turn, an interrupted one once Crush
+// starts again.
+type InterruptedTurn struct {
+	SessionID string `json:"session_id"`
+	// UserMessageID is the prompt of the turn.
+	UserMessageID string    `json:"user_message_id"`
+	Started       time.Time `json:"started"`
+}
+
+func turnPath(dataDir, sessionID string) string {
+	return filepath.Join(dataDir, TurnsDir, sessionID+".json")
+}
+
+// startTurn writes the journal of the turn, and returns the function removing
+// it once the turn is finished.
+func startTurn(dataDir string, turn InterruptedTurn) (finish func()) {
+	path := turnPath(dataDir, turn.SessionID)
+	data, err := json.Marshal(turn)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		slog.Warn("Failed to write the journal of the turn", "session_id", turn.SessionID, "error", err)
+		return func() {}
+	}
+	return func() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove the journal of the turn", "session_id", turn.SessionID, "error", err)
+		}
+	}
+}
+
+// FindInterruptedTurn returns the turn of the session interrupted when Crush
+// stopped, or the one running in it.
+func FindInterruptedTurn(dataDir, sessionID string) (InterruptedTurn, bool) {
+	var turn InterruptedTurn
+	data, err := os.ReadFile(turnPath(dataDir, sessionID))
+	if err != nil || json.Unmarshal(data, &turn) != nil {
+		return InterruptedTurn{}, false
+	}
+	return turn, true
+}
+
+// RecoverInterrupted marks the responses and the tool calls of the turns
+// interrupted when Crush stopped as such, keeping what was streamed, for
+// their sessions to be continued or the turns re-run. It runs before any turn
+// does, and returns the interrupted turns of the sessions of the user; the
+// ones of the sub-agents are only marked.
+func RecoverInterrupted(ctx context.Context, dataDir string, sessions session.Service, messages message.Service) ([]InterruptedTurn, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, TurnsDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var turns []InterruptedTurn
+	var errs []error
+	for _, entry := range entries {
+		sessionID, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		turn, ok := FindInterruptedTurn(dataDir, sessionID)
+		sess, err := sessions.Get(ctx, sessionID)
+		if !ok || err != nil {
+			// The journal is invalid, or the session was deleted since.
+			os.Remove(turnPath(dataDir, sessionID))
+			continue
+		}
+		recovered, err := recoverSession(ctx, messages, sessionID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to recover session %s: %w", sessionID, err))
+			continue
+		}
+		if recovered {
+			slog.Info("Recovered interrupted turn", "session_id", sessionID, "started", turn.Started)
+		}
+		if sess.ParentSessionID != "" {
+			os.Remove(turnPath(dataDir, sessionID))
+			continue
+		}
+		turns = append(turns, turn)
+	}
+	return turns, errors.Join(errs...)
+}
+
+// recoverSession finishes the last response of the session if it was
+// interrupted, and fails its tool calls left without results. The tool calls
+// whose input wasn't streamed in full are dropped.
+func recoverSession(ctx context.Context, messages message.Service, sessionID string) (bool, error) {
+	msgs, err := messages.List(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if len(msgs) == 0 {
+		return false, nil
+	}
+	last := msgs[len(msgs)-1]
+	if last.Role != message.Assistant || last.IsFinished() && last.FinishReason() != message.FinishReasonToolUse {
+		return false, nil
+	}
+
+	parts := make([]message.ContentPart, 0, len(last.Parts))
+	for _, part := range last.Parts {
+		if call, ok := part.(message.ToolCall); ok && !call.Finished {
+			continue
+		}
+		// The thinking ended with the last update of the response.
+		if reasoning, ok := part.(message.ReasoningContent); ok && reasoning.StartedAt > 0 && reasoning.FinishedAt == 0 {
+			reasoning.FinishedAt = last.UpdatedAt
+			part = reasoning
+		}
+		parts = append(parts, part)
+	}
+	last.Parts = parts
+	last.AddFinish(message.FinishReasonInterrupted, "Interrupted", "Crush stopped before the turn finished")
+	if err := messages.Update(ctx, last); err != nil {
+		return false, err
+	}
+
+	calls := last.ToolCalls()
+	if len(calls) == 0 {
+		return true, nil
+	}
+	results := make([]message.ContentPart, len(calls))
+	for i, call := range calls {
+		results[i] = message.ToolResult{
+			ToolCallID: call.ID,
+			Content:    "Interrupted: Crush stopped before the tool call finished, it may have been done in part",
+			IsError:    true,
+		}
+	}
+	_, err = messages.Create(ctx, sessionID, message.CreateMessageParams{
+		Role:     message.Tool,
+		Parts:    results,
+		Provider: last.Provider,
+	})
+	return true, err
+}
+
+// RerunInterrupted runs the prompt of the interrupted turn of the session
+// again, with its attachments.
+func (a *agent) RerunInterrupted(ctx context.Context, sessionID string) (<-chan AgentEvent, error) {
+	if a.IsSessionBusy(sessionID) {
+		return nil, ErrSessionBusy
+	}
+	turn, ok := FindInterruptedTurn(config.Get().Options.DataDirectory, sessionID)
+	if !ok {
+		return nil, ErrNoInterruptedTurn
+	}
+	prompt, err := a.messages.Get(ctx, turn.UserMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the prompt of the interrupted turn: %w", err)
+	}
+	var attachments []message.Attachment
+	for _, content := range prompt.BinaryContent() {
+		attachments = append(attachments, message.Attachment{
+			FilePath: content.Path,
+			FileName: filepath.Base(content.Path),
+			MimeType: content.MIMEType,
+			Content:  content.Data,
+		})
+	}
+	return a.Run(ctx, sessionID, prompt.Content().Text, attachments...)
+}
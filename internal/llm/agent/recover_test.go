@@ -0,0 +1,92 @@
+package agent_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+// crashMidTurn leaves the session as Crush does when it's killed while the
+// model streams a tool call, and returns the prompt of the turn.
+func crashMidTurn(t *testing.T, h *agenttest.Harness) message.Message {
+	t.Helper()
+	prompt, err := h.Messages.Create(t.Context(), h.Session.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Fix the parser"}},
+	})
+	require.NoError(t, err)
+	response, err := h.Messages.Create(t.Context(), h.Session.ID, message.CreateMessageParams{
+		Role:  message.Assistant,
+		Parts: []message.ContentPart{},
+	})
+	require.NoError(t, err)
+	response.AppendContent("Let me look at the parser.")
+	response.AddToolCall(message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"parser.go"}`, Finished: true})
+	response.AddToolCall(message.ToolCall{ID: "call_2", Name: "view", Input: `{"file_pa`})
+	require.NoError(t, h.Messages.Update(t.Context(), response))
+
+	journal, err := json.Marshal(agent.InterruptedTurn{SessionID: h.Session.ID, UserMessageID: prompt.ID, Started: time.Now()})
+	require.NoError(t, err)
+	dir := filepath.Join(h.Config.Options.DataDirectory, agent.TurnsDir)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, h.Session.ID+".json"), journal, 0o644))
+	return prompt
+}
+
+func TestRecoverInterrupted(t *testing.T) {
+	h := agenttest.New(t)
+	prompt := crashMidTurn(t, h)
+
+	turns, err := agent.RecoverInterrupted(t.Context(), h.Config.Options.DataDirectory, h.Sessions, h.Messages)
+	require.NoError(t, err)
+	require.Len(t, turns, 1)
+	require.Equal(t, prompt.ID, turns[0].UserMessageID)
+
+	msgs := h.SessionMessages()
+	require.Len(t, msgs, 3)
+	response := msgs[1]
+	require.Equal(t, message.FinishReasonInterrupted, response.FinishReason())
+	require.Equal(t, "Let me look at the parser.", response.Content().Text, "the partial output is kept")
+	require.Len(t, response.ToolCalls(), 1, "the tool call streamed in part is dropped")
+	results := msgs[2].ToolResults()
+	require.Len(t, results, 1)
+	require.Equal(t, "call_1", results[0].ToolCallID)
+	require.True(t, results[0].IsError)
+
+	// Recovering again changes nothing.
+	_, err = agent.RecoverInterrupted(t.Context(), h.Config.Options.DataDirectory, h.Sessions, h.Messages)
+	require.NoError(t, err)
+	require.Len(t, h.SessionMessages(), 3)
+}
+
+func TestRerunInterrupted(t *testing.T) {
+	h := agenttest.New(t)
+	crashMidTurn(t, h)
+	_, err := agent.RecoverInterrupted(t.Context(), h.Config.Options.DataDirectory, h.Sessions, h.Messages)
+	require.NoError(t, err)
+
+	h.Large.Script(agenttest.Text("Fixed."))
+	done, err := h.Agent.RerunInterrupted(t.Context(), h.Session.ID)
+	require.NoError(t, err)
+	result := <-done
+	require.NoError(t, result.Error)
+	require.Equal(t, "Fixed.", result.Message.Content().Text)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 1)
+	history := requests[0].Messages
+	require.Equal(t, "Let me look at the parser.", history[1].Content().Text, "the interrupted turn is in the history")
+	require.Equal(t, "Fix the parser", history[len(history)-1].Content().Text)
+
+	_, ok := agent.FindInterruptedTurn(h.Config.Options.DataDirectory, h.Session.ID)
+	require.False(t, ok, "the journal is removed once the turn finishes")
+	_, err = h.Agent.RerunInterrupted(t.Context(), h.Session.ID)
+	require.ErrorIs(t, err, agent.ErrNoInterruptedTurn)
+}
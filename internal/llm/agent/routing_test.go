@@ -0,0 +1,24 @@
+package agent_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouting_Title(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Options.Routing = &config.Routing{Title: config.SelectedModelTypeLarge}
+	}))
+	h.Large.SetFallback(agenttest.Text("Done."))
+
+	_, err := h.Run("Fix the tests")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(h.Large.Requests()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, h.Small.Requests())
+}
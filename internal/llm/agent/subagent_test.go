@@ -0,0 +1,84 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func withPlanner(cfg *config.Config) {
+	cfg.SubAgents = map[string]config.SubAgent{
+		"planner": {
+			Description:  "Plans the changes.",
+			AllowedTools: []string{"view"},
+			Instructions: "Answer with a numbered plan.",
+		},
+	}
+	cfg.SetupAgents()
+}
+
+func TestSubAgents_Sequential(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithConfig(withPlanner))
+	h.Large.Script(
+		agenttest.ToolCall(agent.AgentToolName, map[string]any{
+			"tasks": []map[string]string{
+				{"agent": "planner", "prompt": "Plan the fix"},
+				{"prompt": "Find the files of the plan"},
+			},
+		}),
+		agenttest.Text("1. Fix the parser"),
+		agenttest.Text("parser.go"),
+		agenttest.Text("Done."),
+	)
+
+	turn, err := h.Run("Fix the parser")
+	require.NoError(t, err)
+	require.Equal(t, "Done.", turn.Message.Content().Text)
+
+	requests := h.Large.Requests()
+	require.Len(t, requests, 4)
+	require.Equal(t, []string{"view"}, requests[1].Tools)
+	require.Equal(t, "Plan the fix", requests[1].Messages[0].Content().Text)
+	require.Contains(t, requests[2].Tools, "grep", "the default is the task agent")
+	require.Contains(t, requests[2].Messages[0].Content().Text, "1. Fix the parser", "the task is given the results of the tasks before it")
+
+	result := requests[3].Messages[len(requests[3].Messages)-1].ToolResults()[0]
+	require.Contains(t, result.Content, "## Task 1 (planner agent): Plan the fix\n\n1. Fix the parser")
+	require.Contains(t, result.Content, "## Task 2 (task agent): Find the files of the plan\n\nparser.go")
+
+	// Each task runs in a session of its own.
+	ctx := t.Context()
+	for i, want := range []string{"1. Fix the parser", "parser.go"} {
+		msgs, err := h.Messages.List(ctx, agent.TaskSessionID(result.ToolCallID, i))
+		require.NoError(t, err)
+		require.Equal(t, want, msgs[len(msgs)-1].Content().Text)
+	}
+}
+
+func TestSubAgents_UnknownAgent(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithConfig(withPlanner))
+	h.Large.Script(
+		agenttest.ToolCall(agent.AgentToolName, map[string]any{"agent": "reviewer", "prompt": "Review"}),
+		agenttest.Text("Done."),
+	)
+
+	_, err := h.Run("Review the diff")
+	require.NoError(t, err)
+	requests := h.Large.Requests()
+	require.Len(t, requests, 2)
+	result := requests[1].Messages[len(requests[1].Messages)-1].ToolResults()[0]
+	require.True(t, result.IsError)
+	require.Equal(t, `unknown agent "reviewer", expected one of planner, task`, result.Content)
+}
+
+func TestTaskSessionID(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "call_1", agent.TaskSessionID("call_1", 0))
+	require.Equal(t, "call_1~3", agent.TaskSessionID("call_1", 2))
+	require.Equal(t, "call_1", agent.TaskToolCallID("call_1~3"))
+	require.Equal(t, "call_1", agent.TaskToolCallID("call_1"))
+}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+const (
+	defaultVerifyMaxAttempts = 3
+	defaultVerifyTimeout     = 10 * time.Minute
+	maxVerifyOutputLines     = 50
+)
+
+// modifyingTools are the tools that change files, and therefore require the
+// verify command to be run again.
+var modifyingTools = []string{
+	tools.EditToolName,
+	tools.MultiEditToolName,
+	tools.ApplyPatchToolName,
+	tools.TransactionToolName,
+	tools.RenameToolName,
+	tools.CodeActionToolName,
+	tools.WriteToolName,
+}
+
+type verifyResult struct {
+	command  string
+	output   string
+	exitCode int
+	err      error
+}
+
+func (r verifyResult) passed() bool {
+	return r.err == nil && r.exitCode == 0
+}
+
+// Summary is embedded in the final message of the agent.
+func (r verifyResult) summary() string {
+	if r.passed() {
+		return fmt.Sprintf("Verification: `%s` passed.", r.command)
+	}
+	if r.err != nil {
+		return fmt.Sprintf("Verification: `%s` did not complete: %v", r.command, r.err)
+	}
+	return fmt.Sprintf("Verification: `%s` failed with exit code %d.", r.command, r.exitCode)
+}
+
+// prompt is sent back to the agent when the command failed.
+func (r verifyResult) prompt(attempt, maxAttempts int) string {
+	return fmt.Sprintf(`The verification command %q failed (attempt %d of %d). Fix the problem before reporting the task as complete.
+
+<output>
+%s
+</output>`, r.command, attempt, maxAttempts, r.output)
+}
+
+// verifyConfig returns the verify settings, or nil if the gate is disabled
+// for this agent. Only the main agents are gated, the sub-agents run as part
+// of their turns.
+func (a *agent) verifyConfig() *config.Verify {
+	cfg := config.Get()
+	verify := cfg.Options.Verify
+	if !cfg.IsMainAgent(a.agentCfg.ID) || verify == nil || strings.TrimSpace(verify.Command) == "" {
+		return nil
+	}
+	return verify
+}
+
+func modifiedFiles(toolCalls []message.ToolCall, toolResults []message.ToolResult) bool {
+	failed := make(map[string]bool, len(toolResults))
+	for _, tr := range toolResults {
+		failed[tr.ToolCallID] = tr.IsError
+	}
+	for _, tc := range toolCalls {
+		if !failed[tc.ID] && slices.Contains(modifyingTools, tc.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func runVerify(ctx context.Context, verify *config.Verify) verifyResult {
+	timeout := defaultVerifyTimeout
+	if verify.Timeout > 0 {
+		timeout = time.Duration(verify.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	slog.Info("Running verify command", "command", verify.Command)
+	// It runs the code of the agent, sandboxed like its commands.
+	sandbox, err := tools.NewSandbox(config.Get().WorkingDir())
+	if err != nil {
+		return verifyResult{command: verify.Command, err: fmt.Errorf("sandbox is not available: %w", err)}
+	}
+	sh := shell.NewShell(&shell.Options{WorkingDir: config.Get().WorkingDir(), Sandbox: sandbox})
+	stdout, stderr, err := sh.Exec(ctx, verify.Command)
+	result := verifyResult{
+		command:  verify.Command,
+		output:   lastLines(strings.TrimSpace(stdout+"\n"+stderr), maxVerifyOutputLines),
+		exitCode: shell.ExitCode(err),
+	}
+	if shell.IsInterrupt(err) {
+		result.err = err
+	}
+	return result
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if len(lines) <= n {
+		return s
+	}
+	return fmt.Sprintf("... (%d lines truncated)\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
@@ -0,0 +1,91 @@
+package agent_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func withVerify(verify *config.Verify) agenttest.Option {
+	return agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Options.Verify = verify
+	})
+}
+
+func TestVerifyPasses(t *testing.T) {
+	h := agenttest.New(t, withVerify(&config.Verify{Command: "test -f ok.txt"}))
+	h.Large.Script(
+		agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("ok.txt"), "content": "ok"}),
+		agenttest.Text("Done."),
+	)
+
+	turn, err := h.Run("Write ok.txt")
+	require.NoError(t, err)
+	require.Len(t, h.Large.Requests(), 2)
+	require.Equal(t, "Done.\n\nVerification: `test -f ok.txt` passed.", turn.Message.Content().Text)
+}
+
+func TestVerifyRetries(t *testing.T) {
+	h := agenttest.New(t, withVerify(&config.Verify{Command: "test -f fixed.txt"}))
+	h.Large.Script(
+		agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("broken.txt"), "content": "broken"}),
+		agenttest.Text("Done."),
+		agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("fixed.txt"), "content": "fixed"}),
+		agenttest.Text("Fixed."),
+	)
+
+	turn, err := h.Run("Fix the build")
+	require.NoError(t, err)
+	require.Equal(t, "Fixed.\n\nVerification: `test -f fixed.txt` passed.", turn.Message.Content().Text)
+
+	// The failure is sent back as feedback, not as a prompt of the user.
+	requests := h.Large.Requests()
+	require.Len(t, requests, 4)
+	feedback := requests[2].Messages[len(requests[2].Messages)-1]
+	require.Equal(t, message.User, feedback.Role)
+	require.True(t, feedback.IsSynthetic())
+	require.Contains(t, feedback.Content().Text, `The verification command "test -f fixed.txt" failed (attempt 1 of 3).`)
+
+	var synthetic int
+	for _, msg := range h.SessionMessages() {
+		if msg.IsSynthetic() {
+			synthetic++
+		}
+	}
+	require.Equal(t, 1, synthetic, "the prompt of the user isn't synthetic")
+}
+
+func TestVerifyGivesUp(t *testing.T) {
+	h := agenttest.New(t, withVerify(&config.Verify{Command: "echo still broken; exit 2", MaxAttempts: 2}))
+	h.Large.Script(
+		agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("a.txt"), "content": "a"}),
+		agenttest.Text("Done."),
+		agenttest.ToolCall(tools.WriteToolName, map[string]string{"file_path": h.Path("b.txt"), "content": "b"}),
+		agenttest.Text("Done again."),
+	)
+
+	turn, err := h.Run("Fix the build")
+	require.NoError(t, err)
+	require.Zero(t, h.Large.Remaining())
+	require.Len(t, h.Large.Requests(), 4, "the agent isn't asked again after the last attempt")
+	require.Equal(t, "Done again.\n\nVerification: `echo still broken; exit 2` failed with exit code 2.", turn.Message.Content().Text)
+	feedback := h.Large.Requests()[2].Messages
+	require.Contains(t, feedback[len(feedback)-1].Content().Text, "still broken")
+}
+
+func TestVerifyOnlyAfterChanges(t *testing.T) {
+	h := agenttest.New(t, withVerify(&config.Verify{Command: "false"}))
+	h.WriteFile("notes.txt", "notes")
+	h.Large.Script(
+		agenttest.ToolCall(tools.ViewToolName, map[string]string{"file_path": h.Path("notes.txt")}),
+		agenttest.Text("Read it."),
+	)
+
+	turn, err := h.Run("Read notes.txt")
+	require.NoError(t, err)
+	require.Equal(t, "Read it.", turn.Message.Content().Text)
+}
@@ -0,0 +1,174 @@
+// Package embeddings provides a client to compute text embeddings with the
+// configured embedding model, e.g. for semantic search.
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+var ErrNoEmbeddingModel = errors.New("no embedding model available")
+
+// Client computes embeddings for a batch of inputs.
+type Client interface {
+	Embed(ctx context.Context, inputs []string) ([][]float32, error)
+	Model() catwalk.Model
+}
+
+// New creates a client for the embedding model selected in the config.
+func New() (Client, error) {
+	providerCfg, model := config.Get().EmbeddingModel()
+	if providerCfg == nil || model == nil {
+		return nil, ErrNoEmbeddingModel
+	}
+	return NewClient(*providerCfg, *model)
+}
+
+// NewClient creates a client for the given provider and embedding model.
+func NewClient(cfg config.ProviderConfig, model catwalk.Model) (Client, error) {
+	resolver := config.Get().Resolver()
+	apiKey, err := resolver.ResolveValue(cfg.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve API key for provider %s: %w", cfg.ID, err)
+	}
+	headers := make(map[string]string, len(cfg.ExtraHeaders))
+	for key, value := range cfg.ExtraHeaders {
+		resolved, err := resolver.ResolveValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve extra header %s for provider %s: %w", key, cfg.ID, err)
+		}
+		headers[key] = resolved
+	}
+
+	base := httpClient{
+		client:  &http.Client{Timeout: 2 * time.Minute},
+		apiKey:  apiKey,
+		headers: headers,
+		model:   model,
+	}
+	// The base URL is resolved when the config is loaded.
+	baseURL := cfg.BaseURL
+	switch {
+	case cfg.ID == "ollama":
+		base.baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/v1")
+		return &ollamaClient{base}, nil
+	case cfg.Type == catwalk.TypeOpenAI:
+		if baseURL == "" {
+			baseURL = "https://api.openai.com/v1"
+		}
+		base.baseURL = strings.TrimSuffix(baseURL, "/")
+		return &openaiClient{base}, nil
+	}
+	return nil, fmt.Errorf("embeddings not supported for provider type: %s", cfg.Type)
+}
+
+type httpClient struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	headers map[string]string
+	model   catwalk.Model
+}
+
+func (c *httpClient) Model() catwalk.Model {
+	return c.model
+}
+
+func (c *httpClient) post(ctx context.Context, path string, body, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to request embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("embeddings request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode embeddings response: %w", err)
+	}
+	return nil
+}
+
+// ollamaClient uses Ollama's native /api/embed endpoint.
+type ollamaClient struct {
+	httpClient
+}
+
+func (c *ollamaClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	err := c.post(ctx, "/api/embed", map[string]any{
+		"model": c.model.ID,
+		"input": inputs,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Embeddings))
+	}
+	return resp.Embeddings, nil
+}
+
+// openaiClient uses the OpenAI-compatible /embeddings endpoint, which is
+// also implemented by llama.cpp and most other local servers.
+type openaiClient struct {
+	httpClient
+}
+
+func (c *openaiClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	err := c.post(ctx, "/embeddings", map[string]any{
+		"model":           c.model.ID,
+		"input":           inputs,
+		"encoding_format": "float",
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Data))
+	}
+	embeddings := make([][]float32, len(inputs))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(inputs) {
+			return nil, fmt.Errorf("invalid embedding index %d", d.Index)
+		}
+		embeddings[d.Index] = d.Embedding
+	}
+	return embeddings, nil
+}
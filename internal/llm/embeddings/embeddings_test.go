@@ -0,0 +1,71 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, path string, handler func(req map[string]any) any) httpClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, path, r.URL.Path)
+		require.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		var req map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.NoError(t, json.NewEncoder(w).Encode(handler(req)))
+	}))
+	t.Cleanup(server.Close)
+	return httpClient{
+		client:  server.Client(),
+		baseURL: server.URL,
+		apiKey:  "key",
+		model:   catwalk.Model{ID: "nomic-embed-text"},
+	}
+}
+
+func TestOllamaClientEmbed(t *testing.T) {
+	t.Parallel()
+
+	c := &ollamaClient{newTestClient(t, "/api/embed", func(req map[string]any) any {
+		require.Equal(t, "nomic-embed-text", req["model"])
+		return map[string]any{"embeddings": [][]float32{{0.1, 0.2}, {0.3, 0.4}}}
+	})}
+
+	got, err := c.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, got)
+}
+
+func TestOpenAIClientEmbed(t *testing.T) {
+	t.Parallel()
+
+	c := &openaiClient{newTestClient(t, "/embeddings", func(req map[string]any) any {
+		require.Equal(t, []any{"a", "b"}, req["input"])
+		// Results may come back in any order.
+		return map[string]any{"data": []map[string]any{
+			{"index": 1, "embedding": []float32{0.3}},
+			{"index": 0, "embedding": []float32{0.1}},
+		}}
+	})}
+
+	got, err := c.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{{0.1}, {0.3}}, got)
+}
+
+func TestOpenAIClientEmbedMismatch(t *testing.T) {
+	t.Parallel()
+
+	c := &openaiClient{newTestClient(t, "/embeddings", func(map[string]any) any {
+		return map[string]any{"data": []map[string]any{}}
+	})}
+
+	_, err := c.Embed(context.Background(), []string{"a"})
+	require.Error(t, err)
+}
@@ -5,9 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
@@ -29,11 +28,21 @@ func CoderPrompt(p string, contextFiles ...string) string {
 	}
 	envInfo := getEnvironmentInfo()
 
-	basePrompt = fmt.Sprintf("%s\n\n%s\n%s", basePrompt, envInfo, lspInformation())
+	basePrompt = fmt.Sprintf("%s\n\n%s\n%s\n%s%s", basePrompt, envInfo, lspInformation(), citationInformation(), verifyInformation())
+	return withProjectContext(basePrompt, contextFiles)
+}
+
+// CustomPrompt returns the system prompt of a custom agent, its own prompt
+// followed by the environment and the project context the coder prompt has.
+func CustomPrompt(agentPrompt string, contextFiles ...string) string {
+	basePrompt := fmt.Sprintf("%s\n\n%s", strings.TrimSpace(agentPrompt), getEnvironmentInfo())
+	return withProjectContext(basePrompt, contextFiles)
+}
 
+func withProjectContext(basePrompt string, contextFiles []string) string {
 	contextContent := getContextFromPaths(config.Get().WorkingDir(), contextFiles)
 	if contextContent != "" {
-		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below\n%s", basePrompt, contextContent)
+		return fmt.Sprintf("%s\n\n# Project-Specific Context\n Make sure to follow the instructions in the context below. Where they disagree, the later ones take precedence, and the instructions of a subdirectory, given to you when you work on its files, take precedence over them for the files of that subdirectory.\n%s", basePrompt, contextContent)
 	}
 	return basePrompt
 }
@@ -52,21 +61,14 @@ var coderV2Prompt []byte
 
 func getEnvironmentInfo() string {
 	cwd := config.Get().WorkingDir()
-	isGit := isGitRepo(cwd)
-	platform := runtime.GOOS
-	date := time.Now().Format("1/2/2006")
 	output, _ := tools.ListDirectoryTree(cwd, nil)
 	return fmt.Sprintf(`Here is useful information about the environment you are running in:
 <env>
-Working directory: %s
-Is directory a git repo: %s
-Platform: %s
-Today's date: %s
-</env>
+%s</env>
 <project>
 %s
 </project>
-		`, cwd, boolToYesNo(isGit), platform, date, output)
+		`, GetEnvironmentFacts(cwd), output)
 }
 
 func isGitRepo(dir string) bool {
@@ -94,6 +96,25 @@ Tools that support it will also include useful diagnostics such as linting and t
 `
 }
 
+func citationInformation() string {
+	return `# Citing Sources
+When your answer relies on content you retrieved from URLs, Sourcegraph, MCP resources, or files you read, cite where each claim comes from inline, using the URL or the file path with line numbers (e.g. main.go:12-20).
+- Only cite sources you actually retrieved during the conversation.
+- The full list of retrieved sources is shown to the user below your answer, so you don't need to repeat it.
+`
+}
+
+func verifyInformation() string {
+	verify := config.Get().Options.Verify
+	if verify == nil || verify.Command == "" {
+		return ""
+	}
+	return fmt.Sprintf(`
+# Verification
+After you modify files, the command %q is run automatically when you finish your turn. If it fails you will receive its output and must fix the problem. You may run it yourself with the bash tool to check your work earlier.
+`, verify.Command)
+}
+
 func boolToYesNo(b bool) string {
 	if b {
 		return "Yes"
@@ -0,0 +1,10 @@
+package prompt
+
+import _ "embed"
+
+//go:embed commit.md
+var commitPrompt []byte
+
+func CommitPrompt() string {
+	return string(commitPrompt)
+}
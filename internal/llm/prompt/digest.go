@@ -0,0 +1,10 @@
+package prompt
+
+import _ "embed"
+
+//go:embed digest.md
+var digestPrompt []byte
+
+func DigestPrompt() string {
+	return string(digestPrompt)
+}
@@ -0,0 +1,197 @@
+package prompt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// environmentStaleAfter is how long the environment facts of a system prompt
+// are considered fresh. Facts that change often, such as the git status, are
+// only refreshed after this, to not invalidate prompt caches on every turn.
+const environmentStaleAfter = 30 * time.Minute
+
+// gitFactsTTL is how long the git branch and status are reused before git
+// runs again. The facts are gathered before every turn.
+const gitFactsTTL = time.Minute
+
+// toolchain is a language toolchain whose version is reported to the model.
+type toolchain struct {
+	name    string
+	command string
+	args    []string
+}
+
+var toolchains = []toolchain{
+	{"Go", "go", []string{"version"}},
+	{"Node.js", "node", []string{"--version"}},
+	{"Python", "python3", []string{"--version"}},
+	{"Rust", "rustc", []string{"--version"}},
+	{"Java", "java", []string{"-version"}},
+	{"Ruby", "ruby", []string{"--version"}},
+}
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?`)
+
+// toolchainVersions are looked up once, they don't change while running.
+var toolchainVersions = sync.OnceValue(func() []string {
+	versions := make([]string, len(toolchains))
+	var wg sync.WaitGroup
+	for i, tc := range toolchains {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := exec.LookPath(tc.command); err != nil {
+				return
+			}
+			out := commandOutput(tc.command, tc.args...)
+			if version := versionPattern.FindString(out); version != "" {
+				versions[i] = fmt.Sprintf("%s %s", tc.name, version)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var found []string
+	for _, v := range versions {
+		if v != "" {
+			found = append(found, v)
+		}
+	}
+	return found
+})
+
+// EnvironmentFacts describes the environment the agent runs in.
+type EnvironmentFacts struct {
+	WorkingDir string
+	OS         string
+	Arch       string
+	Shell      string
+	Toolchains []string
+	Time       time.Time
+	IsGitRepo  bool
+	GitBranch  string
+	GitStatus  string
+}
+
+// GetEnvironmentFacts gathers the current environment facts of workingDir.
+func GetEnvironmentFacts(workingDir string) EnvironmentFacts {
+	facts := EnvironmentFacts{
+		WorkingDir: workingDir,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Shell:      userShell(),
+		Toolchains: toolchainVersions(),
+		Time:       time.Now(),
+		IsGitRepo:  isGitRepo(workingDir),
+	}
+	if facts.IsGitRepo {
+		facts.GitBranch, facts.GitStatus = cachedGitFacts(workingDir, facts.Time)
+	}
+	return facts
+}
+
+type gitFacts struct {
+	branch string
+	status string
+	at     time.Time
+}
+
+var (
+	gitFactsMu    sync.Mutex
+	gitFactsCache = make(map[string]gitFacts)
+)
+
+// cachedGitFacts returns the git branch and status of dir, running git at
+// most once per gitFactsTTL.
+func cachedGitFacts(dir string, now time.Time) (branch, status string) {
+	gitFactsMu.Lock()
+	defer gitFactsMu.Unlock()
+	if cached, ok := gitFactsCache[dir]; ok && now.Sub(cached.at) < gitFactsTTL {
+		return cached.branch, cached.status
+	}
+	branch = commandOutput("git", "-C", dir, "branch", "--show-current")
+	if branch == "" {
+		branch = "(detached HEAD)"
+	}
+	status = gitStatusSummary(dir)
+	gitFactsCache[dir] = gitFacts{branch: branch, status: status, at: now}
+	return branch, status
+}
+
+// Stale reports whether the facts are outdated compared to current ones, in
+// ways that matter to the model: the date or the git branch changed, or they
+// are simply old.
+func (f EnvironmentFacts) Stale(current EnvironmentFacts) bool {
+	return f.Time.Format(time.DateOnly) != current.Time.Format(time.DateOnly) ||
+		f.GitBranch != current.GitBranch ||
+		current.Time.Sub(f.Time) > environmentStaleAfter
+}
+
+func (f EnvironmentFacts) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Working directory: %s\n", f.WorkingDir)
+	fmt.Fprintf(&sb, "Platform: %s\n", f.OS)
+	fmt.Fprintf(&sb, "Architecture: %s\n", f.Arch)
+	if f.Shell != "" {
+		fmt.Fprintf(&sb, "Shell: %s\n", f.Shell)
+	}
+	if len(f.Toolchains) > 0 {
+		fmt.Fprintf(&sb, "Toolchains: %s\n", strings.Join(f.Toolchains, ", "))
+	}
+	zone, _ := f.Time.Zone()
+	fmt.Fprintf(&sb, "Today's date: %s (%s)\n", f.Time.Format("Monday, 2006-01-02"), f.Time.Format("1/2/2006"))
+	fmt.Fprintf(&sb, "Time zone: %s\n", zone)
+	fmt.Fprintf(&sb, "Is directory a git repo: %s\n", boolToYesNo(f.IsGitRepo))
+	if f.IsGitRepo {
+		fmt.Fprintf(&sb, "Git branch: %s\n", f.GitBranch)
+		fmt.Fprintf(&sb, "Git status: %s\n", f.GitStatus)
+	}
+	return sb.String()
+}
+
+func userShell() string {
+	shell := os.Getenv("SHELL")
+	if shell == "" && runtime.GOOS == "windows" {
+		shell = os.Getenv("ComSpec")
+	}
+	if shell == "" {
+		return ""
+	}
+	// Commands are run with a built-in POSIX shell emulation regardless.
+	return fmt.Sprintf("%s (commands run in a POSIX-compatible shell)", filepath.Base(shell))
+}
+
+func gitStatusSummary(dir string) string {
+	out := commandOutput("git", "-C", dir, "status", "--porcelain")
+	if out == "" {
+		return "clean"
+	}
+	var modified, untracked int
+	for line := range strings.SplitSeq(out, "\n") {
+		if strings.HasPrefix(line, "??") {
+			untracked++
+		} else if line != "" {
+			modified++
+		}
+	}
+	return fmt.Sprintf("%d changed, %d untracked files", modified, untracked)
+}
+
+func commandOutput(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// Some tools, such as java, print their version to stderr.
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentFactsStale(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 7, 30, 10, 0, 0, 0, time.UTC)
+	facts := EnvironmentFacts{Time: now, GitBranch: "main", GitStatus: "clean"}
+
+	changed := facts
+	changed.Time = now.Add(time.Minute)
+	changed.GitStatus = "1 changed, 0 untracked files"
+	require.False(t, facts.Stale(changed), "git status changes alone don't make facts stale")
+
+	changed.GitBranch = "feature"
+	require.True(t, facts.Stale(changed))
+
+	later := facts
+	later.Time = now.Add(environmentStaleAfter + time.Second)
+	require.True(t, facts.Stale(later))
+
+	lateEvening := EnvironmentFacts{Time: time.Date(2025, 7, 30, 23, 50, 0, 0, time.UTC)}
+	nextDay := EnvironmentFacts{Time: time.Date(2025, 7, 31, 0, 5, 0, 0, time.UTC)}
+	require.True(t, lateEvening.Stale(nextDay), "the date changed")
+}
+
+func TestEnvironmentFactsString(t *testing.T) {
+	t.Parallel()
+
+	facts := EnvironmentFacts{
+		WorkingDir: "/src/project",
+		OS:         "linux",
+		Arch:       "amd64",
+		Toolchains: []string{"Go 1.24.3", "Node.js 22.1.0"},
+		Time:       time.Date(2025, 7, 30, 10, 0, 0, 0, time.UTC),
+		IsGitRepo:  true,
+		GitBranch:  "main",
+		GitStatus:  "clean",
+	}
+	s := facts.String()
+	require.Contains(t, s, "Working directory: /src/project\n")
+	require.Contains(t, s, "Toolchains: Go 1.24.3, Node.js 22.1.0\n")
+	require.Contains(t, s, "Today's date: Wednesday, 2025-07-30 (7/30/2025)\n")
+	require.Contains(t, s, "Time zone: UTC\n")
+	require.Contains(t, s, "Git branch: main\n")
+	require.NotContains(t, s, "Shell:")
+}
+
+func TestCachedGitFacts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, exec.Command("git", "init", "-q", "-b", "main", dir).Run())
+	now := time.Now()
+	branch, status := cachedGitFacts(dir, now)
+	require.Equal(t, "main", branch)
+	require.Equal(t, "clean", status)
+
+	// git doesn't run again until the facts expire.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new"), 0o644))
+	_, status = cachedGitFacts(dir, now.Add(gitFactsTTL/2))
+	require.Equal(t, "clean", status)
+	_, status = cachedGitFacts(dir, now.Add(gitFactsTTL))
+	require.Equal(t, "0 changed, 1 untracked files", status)
+}
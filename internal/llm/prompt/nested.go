@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// nestedContextFiles are the instruction files of the subdirectories of the
+// project, loaded when the agent works on the files in them. The later ones
+// take precedence.
+var nestedContextFiles = []string{
+	".cursorrules",
+	"AGENTS.md",
+	"agents.md",
+	"CRUSH.md",
+	"crush.md",
+	"CRUSH.local.md",
+	"crush.local.md",
+}
+
+// NestedContextDirs returns the directories between the working directory,
+// excluded since its files are in the system prompt, and the directories of
+// the files, parents first. Files outside the working directory have none.
+func NestedContextDirs(workingDir string, files []string) []string {
+	var dirs []string
+	for _, file := range files {
+		rel, err := filepath.Rel(workingDir, filepath.Dir(file))
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		dir := workingDir
+		for part := range strings.SplitSeq(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, part)
+			if !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	slices.SortStableFunc(dirs, func(a, b string) int {
+		return strings.Count(a, string(filepath.Separator)) - strings.Count(b, string(filepath.Separator))
+	})
+	return dirs
+}
+
+// NestedContext returns the content of the instruction files of the
+// directory, or "" if it has none.
+func NestedContext(dir string) string {
+	var results []string
+	seen := make(map[string]bool)
+	for _, name := range nestedContextFiles {
+		// The same file on case-insensitive file systems.
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		if result := processFile(filepath.Join(dir, name)); result != "" {
+			seen[strings.ToLower(name)] = true
+			results = append(results, result)
+		}
+	}
+	return strings.Join(results, "\n")
+}
@@ -3,6 +3,7 @@ package prompt
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 
@@ -18,6 +19,8 @@ const (
 	PromptTitle      PromptID = "title"
 	PromptTask       PromptID = "task"
 	PromptSummarizer PromptID = "summarizer"
+	PromptDigest     PromptID = "digest"
+	PromptCommit     PromptID = "commit"
 	PromptDefault    PromptID = "default"
 )
 
@@ -32,6 +35,10 @@ func GetPrompt(promptID PromptID, provider string, contextPaths ...string) strin
 		basePrompt = TaskPrompt()
 	case PromptSummarizer:
 		basePrompt = SummarizerPrompt()
+	case PromptDigest:
+		basePrompt = DigestPrompt()
+	case PromptCommit:
+		basePrompt = CommitPrompt()
 	default:
 		basePrompt = "You are a helpful assistant"
 	}
@@ -68,18 +75,18 @@ func expandPath(path string) string {
 	return path
 }
 
+// processContextPaths returns the content of the context files in the order
+// of the paths, the later ones take precedence.
 func processContextPaths(workDir string, paths []string) string {
-	var (
-		wg       sync.WaitGroup
-		resultCh = make(chan string)
-	)
+	var wg sync.WaitGroup
+	results := make([][]string, len(paths))
 
 	// Track processed files to avoid duplicates
 	processedFiles := csync.NewMap[string, bool]()
 
-	for _, path := range paths {
+	for i, path := range paths {
 		wg.Add(1)
-		go func(p string) {
+		go func(i int, p string) {
 			defer wg.Done()
 
 			// Expand ~ and environment variables before processing
@@ -109,7 +116,7 @@ func processContextPaths(workDir string, paths []string) string {
 						if alreadyProcessed, _ := processedFiles.Get(lowerPath); !alreadyProcessed {
 							processedFiles.Set(lowerPath, true)
 							if result := processFile(path); result != "" {
-								resultCh <- result
+								results[i] = append(results[i], result)
 							}
 						}
 					}
@@ -122,26 +129,16 @@ func processContextPaths(workDir string, paths []string) string {
 
 				if alreadyProcessed, _ := processedFiles.Get(lowerPath); !alreadyProcessed {
 					processedFiles.Set(lowerPath, true)
-					result := processFile(fullPath)
-					if result != "" {
-						resultCh <- result
+					if result := processFile(fullPath); result != "" {
+						results[i] = append(results[i], result)
 					}
 				}
 			}
-		}(path)
+		}(i, path)
 	}
+	wg.Wait()
 
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
-
-	results := make([]string, 0)
-	for result := range resultCh {
-		results = append(results, result)
-	}
-
-	return strings.Join(results, "\n")
+	return strings.Join(slices.Concat(results...), "\n")
 }
 
 func processFile(filePath string) string {
@@ -0,0 +1,18 @@
+package prompt
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed remember.md
+var rememberPrompt []byte
+
+// Remember returns the prompt asking the agent to add what it learned in the
+// session to the project memory, or the note of the user if given.
+func Remember(note string) string {
+	if note = strings.TrimSpace(note); note != "" {
+		return "Add this to the project memory with the memory tool: " + note
+	}
+	return string(rememberPrompt)
+}
@@ -7,22 +7,28 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"cloud.google.com/go/auth/oauth2adapt"
 	"github.com/anthropics/anthropic-sdk-go"
-	"github.com/anthropics/anthropic-sdk-go/bedrock"
 	"github.com/anthropics/anthropic-sdk-go/option"
 	"github.com/anthropics/anthropic-sdk-go/vertex"
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/tokenizer"
 )
 
 // Pre-compiled regex for parsing context limit errors.
+// defaultCachedMessages is the number of latest messages with a cache
+// breakpoint.
+const defaultCachedMessages = 2
+
 var contextLimitRegex = regexp.MustCompile(`input length and ` + "`max_tokens`" + ` exceed context limit: (\d+) \+ (\d+) > (\d+)`)
 
 type anthropicClient struct {
@@ -37,9 +43,8 @@ type AnthropicClient ProviderClient
 type AnthropicClientType string
 
 const (
-	AnthropicClientTypeNormal  AnthropicClientType = "normal"
-	AnthropicClientTypeBedrock AnthropicClientType = "bedrock"
-	AnthropicClientTypeVertex  AnthropicClientType = "vertex"
+	AnthropicClientTypeNormal AnthropicClientType = "normal"
+	AnthropicClientTypeVertex AnthropicClientType = "vertex"
 )
 
 func newAnthropicClient(opts providerClientOptions, tp AnthropicClientType) AnthropicClient {
@@ -78,12 +83,27 @@ func createAnthropicClient(opts providerClientOptions, tp AnthropicClientType) a
 		slog.Debug("Skipping X-Api-Key header because Authorization header is provided")
 	}
 	switch tp {
-	case AnthropicClientTypeBedrock:
-		anthropicClientOptions = append(anthropicClientOptions, bedrock.WithLoadDefaultConfig(context.Background()))
 	case AnthropicClientTypeVertex:
 		project := opts.extraParams["project"]
 		location := opts.extraParams["location"]
-		anthropicClientOptions = append(anthropicClientOptions, vertex.WithGoogleAuth(context.Background(), location, project))
+		creds, err := vertexCredentials(opts.config.VertexAI)
+		if err != nil {
+			slog.Error("Failed to create Vertex AI client", "error", err)
+			break
+		}
+		anthropicClientOptions = append(anthropicClientOptions, vertex.WithCredentials(context.Background(), location, project, oauth2adapt.Oauth2CredentialsFromAuthCredentials(creds)))
+		if opts.httpClient != nil {
+			client, err := vertexHTTPClient(creds, opts.httpClient)
+			if err != nil {
+				slog.Error("Failed to create Vertex AI client", "error", err)
+				break
+			}
+			anthropicClientOptions = append(anthropicClientOptions, option.WithHTTPClient(client))
+		}
+	default:
+		if opts.httpClient != nil {
+			anthropicClientOptions = append(anthropicClientOptions, option.WithHTTPClient(opts.httpClient))
+		}
 	}
 	for key, header := range opts.extraHeaders {
 		anthropicClientOptions = append(anthropicClientOptions, option.WithHeaderAdd(key, header))
@@ -94,16 +114,30 @@ func createAnthropicClient(opts providerClientOptions, tp AnthropicClientType) a
 	return anthropic.NewClient(anthropicClientOptions...)
 }
 
+// promptCache returns the prompt caching settings of the model.
+func (a *anthropicClient) promptCache() *config.PromptCache {
+	if a.providerOptions.disableCache {
+		return &config.PromptCache{Disable: true}
+	}
+	return config.Get().GetPromptCache(a.providerOptions.modelType)
+}
+
 func (a *anthropicClient) convertMessages(messages []message.Message) (anthropicMessages []anthropic.MessageParam) {
-	for i, msg := range messages {
-		cache := false
-		if i > len(messages)-3 {
-			cache = true
+	// Anthropic takes up to 4 breakpoints, the system prompt and the tools
+	// take one each.
+	cachedMessages := 0
+	if promptCache := a.promptCache(); promptCache.Caches(config.CacheBreakpointMessages) {
+		cachedMessages = defaultCachedMessages
+		if promptCache != nil && promptCache.Messages > 0 {
+			cachedMessages = min(promptCache.Messages, defaultCachedMessages)
 		}
+	}
+	for i, msg := range messages {
+		cache := i >= len(messages)-cachedMessages
 		switch msg.Role {
 		case message.User:
 			content := anthropic.NewTextBlock(msg.Content().String())
-			if cache && !a.providerOptions.disableCache {
+			if cache {
 				content.OfText.CacheControl = anthropic.CacheControlEphemeralParam{
 					Type: "ephemeral",
 				}
@@ -128,7 +162,7 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 
 			if msg.Content().String() != "" {
 				content := anthropic.NewTextBlock(msg.Content().String())
-				if cache && !a.providerOptions.disableCache {
+				if cache {
 					content.OfText.CacheControl = anthropic.CacheControlEphemeralParam{
 						Type: "ephemeral",
 					}
@@ -164,6 +198,7 @@ func (a *anthropicClient) convertMessages(messages []message.Message) (anthropic
 
 func (a *anthropicClient) convertTools(tools []tools.BaseTool) []anthropic.ToolUnionParam {
 	anthropicTools := make([]anthropic.ToolUnionParam, len(tools))
+	cacheTools := a.promptCache().Caches(config.CacheBreakpointTools)
 
 	for i, tool := range tools {
 		info := tool.Info()
@@ -176,7 +211,7 @@ func (a *anthropicClient) convertTools(tools []tools.BaseTool) []anthropic.ToolU
 			},
 		}
 
-		if i == len(tools)-1 && !a.providerOptions.disableCache {
+		if i == len(tools)-1 && cacheTools {
 			toolParam.CacheControl = anthropic.CacheControlEphemeralParam{
 				Type: "ephemeral",
 			}
@@ -195,6 +230,10 @@ func (a *anthropicClient) finishReason(reason string) message.FinishReason {
 	case "max_tokens":
 		return message.FinishReasonMaxTokens
 	case "tool_use":
+		// The call of the schema tool is the answer.
+		if a.providerOptions.responseSchema != nil {
+			return message.FinishReasonEndTurn
+		}
 		return message.FinishReasonToolUse
 	case "stop_sequence":
 		return message.FinishReasonEndTurn
@@ -204,22 +243,54 @@ func (a *anthropicClient) finishReason(reason string) message.FinishReason {
 }
 
 func (a *anthropicClient) isThinkingEnabled() bool {
-	cfg := config.Get()
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if a.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
+	// Thinking doesn't go with a forced tool choice, see schemaTool.
+	if a.providerOptions.responseSchema != nil {
+		return false
 	}
+	cfg := config.Get()
+	modelConfig := cfg.Models[a.providerOptions.modelType]
 	return a.Model().CanReason && modelConfig.Think
 }
 
+// schemaTool returns the tool the model is made to call with the response as
+// its input, Anthropic has no JSON mode.
+func (a *anthropicClient) schemaTool(schema ResponseSchema) anthropic.ToolUnionParam {
+	return anthropic.ToolUnionParam{OfTool: &anthropic.ToolParam{
+		Name:        schema.Name,
+		Description: anthropic.String(schema.Description),
+		InputSchema: anthropic.ToolInputSchemaParam{
+			Properties: schema.Properties,
+			Required:   schema.Required,
+		},
+	}}
+}
+
+// isSchemaTool reports whether the tool is the one of the response schema.
+func (a *anthropicClient) isSchemaTool(name string) bool {
+	return a.providerOptions.responseSchema != nil && name == a.providerOptions.responseSchema.Name
+}
+
+// content returns the text of the message, or the input of the schema tool.
+func (a *anthropicClient) content(msg anthropic.Message) string {
+	content := ""
+	for _, block := range msg.Content {
+		switch block := block.AsAny().(type) {
+		case anthropic.TextBlock:
+			content += block.Text
+		case anthropic.ToolUseBlock:
+			if a.isSchemaTool(block.Name) {
+				return string(block.Input)
+			}
+		}
+	}
+	return content
+}
+
 func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, tools []anthropic.ToolUnionParam) anthropic.MessageNewParams {
 	model := a.providerOptions.model(a.providerOptions.modelType)
 	var thinkingParam anthropic.ThinkingConfigParamUnion
 	cfg := config.Get()
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if a.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := cfg.Models[a.providerOptions.modelType]
 	temperature := anthropic.Float(0)
 
 	maxTokens := model.DefaultMaxTokens
@@ -227,7 +298,12 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		maxTokens = modelConfig.MaxTokens
 	}
 	if a.isThinkingEnabled() {
-		thinkingParam = anthropic.ThinkingConfigParamOfEnabled(int64(float64(maxTokens) * 0.8))
+		budget := int64(float64(maxTokens) * 0.8)
+		// The budget has to be below the max tokens.
+		if modelConfig.ThinkingBudget > 0 {
+			budget = min(modelConfig.ThinkingBudget, maxTokens-1)
+		}
+		thinkingParam = anthropic.ThinkingConfigParamOfEnabled(budget)
 		temperature = anthropic.Float(1)
 	}
 	// Override max tokens if set in provider options
@@ -246,20 +322,20 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 	if a.providerOptions.systemPromptPrefix != "" {
 		systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
 			Text: a.providerOptions.systemPromptPrefix,
-			CacheControl: anthropic.CacheControlEphemeralParam{
-				Type: "ephemeral",
-			},
 		})
 	}
 
 	systemBlocks = append(systemBlocks, anthropic.TextBlockParam{
 		Text: a.providerOptions.systemMessage,
-		CacheControl: anthropic.CacheControlEphemeralParam{
-			Type: "ephemeral",
-		},
 	})
+	// The breakpoint on the last block caches the prefix too.
+	if a.promptCache().Caches(config.CacheBreakpointSystem) {
+		systemBlocks[len(systemBlocks)-1].CacheControl = anthropic.CacheControlEphemeralParam{
+			Type: "ephemeral",
+		}
+	}
 
-	return anthropic.MessageNewParams{
+	params := anthropic.MessageNewParams{
 		Model:       anthropic.Model(model.ID),
 		MaxTokens:   maxTokens,
 		Temperature: temperature,
@@ -268,6 +344,11 @@ func (a *anthropicClient) preparedMessages(messages []anthropic.MessageParam, to
 		Thinking:    thinkingParam,
 		System:      systemBlocks,
 	}
+	if schema := a.providerOptions.responseSchema; schema != nil {
+		params.Tools = append(params.Tools, a.schemaTool(*schema))
+		params.ToolChoice = anthropic.ToolChoiceParamOfTool(schema.Name)
+	}
+	return params
 }
 
 func (a *anthropicClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (response *ProviderResponse, err error) {
@@ -276,6 +357,9 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 	attempts := 0
 	for {
 		attempts++
+		if err := a.providerOptions.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
 		// Prepare messages on each attempt in case max_tokens was adjusted
 		preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
 		if cfg.Options.Debug {
@@ -311,15 +395,8 @@ func (a *anthropicClient) send(ctx context.Context, messages []message.Message,
 			return nil, retryErr
 		}
 
-		content := ""
-		for _, block := range anthropicResponse.Content {
-			if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-				content += text.Text
-			}
-		}
-
 		return &ProviderResponse{
-			Content:   content,
+			Content:   a.content(*anthropicResponse),
 			ToolCalls: a.toolCalls(*anthropicResponse),
 			Usage:     a.usage(*anthropicResponse),
 		}, nil
@@ -333,6 +410,11 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 	go func() {
 		for {
 			attempts++
+			if err := a.providerOptions.limiter.wait(ctx); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				close(eventChan)
+				return
+			}
 			// Prepare messages on each attempt in case max_tokens was adjusted
 			preparedMessages := a.preparedMessages(a.convertMessages(messages), a.convertTools(tools))
 			if cfg.Options.Debug {
@@ -353,6 +435,8 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 			accumulatedMessage := anthropic.Message{}
 
 			currentToolCallID := ""
+			// The input of the schema tool is streamed as the content.
+			inSchemaTool := false
 			for anthropicStream.Next() {
 				event := anthropicStream.Current()
 				err := accumulatedMessage.Accumulate(event)
@@ -367,6 +451,11 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 					case "text":
 						eventChan <- ProviderEvent{Type: EventContentStart}
 					case "tool_use":
+						if a.isSchemaTool(event.ContentBlock.Name) {
+							inSchemaTool = true
+							eventChan <- ProviderEvent{Type: EventContentStart}
+							break
+						}
 						currentToolCallID = event.ContentBlock.ID
 						eventChan <- ProviderEvent{
 							Type: EventToolUseStart,
@@ -395,7 +484,12 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 							Content: event.Delta.Text,
 						}
 					} else if event.Delta.Type == "input_json_delta" {
-						if currentToolCallID != "" {
+						if inSchemaTool && event.Delta.PartialJSON != "" {
+							eventChan <- ProviderEvent{
+								Type:    EventContentDelta,
+								Content: event.Delta.PartialJSON,
+							}
+						} else if currentToolCallID != "" {
 							eventChan <- ProviderEvent{
 								Type: EventToolUseDelta,
 								ToolCall: &message.ToolCall{
@@ -416,17 +510,12 @@ func (a *anthropicClient) stream(ctx context.Context, messages []message.Message
 						}
 						currentToolCallID = ""
 					} else {
+						inSchemaTool = false
 						eventChan <- ProviderEvent{Type: EventContentStop}
 					}
 
 				case anthropic.MessageStopEvent:
-					content := ""
-					for _, block := range accumulatedMessage.Content {
-						if text, ok := block.AsAny().(anthropic.TextBlock); ok {
-							content += text.Text
-						}
-					}
-
+					content := a.content(accumulatedMessage)
 					eventChan <- ProviderEvent{
 						Type: EventComplete,
 						Response: &ProviderResponse{
@@ -511,18 +600,11 @@ func (a *anthropicClient) shouldRetry(attempts int, err error) (bool, int64, err
 		return false, 0, err
 	}
 
-	retryMs := 0
-	retryAfterValues := apiErr.Response.Header.Values("Retry-After")
-
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
-		}
+	var header http.Header
+	if apiErr.Response != nil {
+		header = apiErr.Response.Header
 	}
-	return true, int64(retryMs), nil
+	return true, a.providerOptions.limiter.retryDelay(attempts, header), nil
 }
 
 // handleContextLimitError parses context limit error and returns adjusted max_tokens
@@ -558,6 +640,9 @@ func (a *anthropicClient) toolCalls(msg anthropic.Message) []message.ToolCall {
 	for _, block := range msg.Content {
 		switch variant := block.AsAny().(type) {
 		case anthropic.ToolUseBlock:
+			if a.isSchemaTool(variant.Name) {
+				continue
+			}
 			toolCall := message.ToolCall{
 				ID:       variant.ID,
 				Name:     variant.Name,
@@ -572,12 +657,27 @@ func (a *anthropicClient) toolCalls(msg anthropic.Message) []message.ToolCall {
 	return toolCalls
 }
 
+// usage returns the usage of the message. Anthropic doesn't report the
+// thinking tokens apart from the output tokens, they are counted from the
+// thinking of the message.
 func (a *anthropicClient) usage(msg anthropic.Message) TokenUsage {
+	var thinking strings.Builder
+	for _, block := range msg.Content {
+		if block, ok := block.AsAny().(anthropic.ThinkingBlock); ok {
+			thinking.WriteString(block.Thinking)
+		}
+	}
+	var reasoningTokens int64
+	if thinking.Len() > 0 {
+		tok := tokenizer.ForModel(string(msg.Model), config.Get().Options.Tokenizers)
+		reasoningTokens = min(int64(tok.Count(thinking.String())), msg.Usage.OutputTokens)
+	}
 	return TokenUsage{
 		InputTokens:         msg.Usage.InputTokens,
 		OutputTokens:        msg.Usage.OutputTokens,
 		CacheCreationTokens: msg.Usage.CacheCreationInputTokens,
 		CacheReadTokens:     msg.Usage.CacheReadInputTokens,
+		ReasoningTokens:     reasoningTokens,
 	}
 }
 
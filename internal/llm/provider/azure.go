@@ -1,32 +1,89 @@
 package provider
 
 import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/azure"
 	"github.com/openai/openai-go/option"
 )
 
+const defaultAzureAPIVersion = "2025-01-01-preview"
+
 type azureClient struct {
 	*openaiClient
 }
 
 type AzureClient ProviderClient
 
-func newAzureClient(opts providerClientOptions) AzureClient {
-	apiVersion := opts.extraParams["apiVersion"]
-	if apiVersion == "" {
-		apiVersion = "2025-01-01-preview"
+func newAzureClient(opts providerClientOptions) (AzureClient, error) {
+	azureOpts := opts.config.Azure
+	if azureOpts == nil {
+		azureOpts = &config.AzureOptions{}
 	}
+	apiVersion := cmp.Or(azureOpts.APIVersion, opts.extraParams["apiVersion"], defaultAzureAPIVersion)
 
 	reqOpts := []option.RequestOption{
 		azure.WithEndpoint(opts.baseURL, apiVersion),
 	}
+	// Registered after the endpoint so it sees the path with the deployment
+	// named after the model.
+	if len(azureOpts.Deployments) > 0 {
+		reqOpts = append(reqOpts, withAzureDeployments(azureOpts.Deployments))
+	}
+
+	if azureOpts.EntraID {
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Entra ID credential: %w", err)
+		}
+		reqOpts = append(reqOpts, azure.WithTokenCredential(cred))
+	} else {
+		reqOpts = append(reqOpts, azure.WithAPIKey(opts.apiKey))
+	}
+
+	for key, value := range opts.extraHeaders {
+		reqOpts = append(reqOpts, option.WithHeader(key, value))
+	}
+	for extraKey, extraValue := range opts.extraBody {
+		reqOpts = append(reqOpts, option.WithJSONSet(extraKey, extraValue))
+	}
+	if opts.httpClient != nil {
+		reqOpts = append(reqOpts, option.WithHTTPClient(opts.httpClient))
+	}
 
-	reqOpts = append(reqOpts, azure.WithAPIKey(opts.apiKey))
 	base := &openaiClient{
 		providerOptions: opts,
 		client:          openai.NewClient(reqOpts...),
 	}
 
-	return &azureClient{openaiClient: base}
+	return &azureClient{openaiClient: base}, nil
+}
+
+// withAzureDeployments sends requests for the models in deployments to their
+// deployment rather than to the deployment named after the model.
+func withAzureDeployments(deployments map[string]string) option.RequestOption {
+	return option.WithMiddleware(func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		const prefix = "/openai/deployments/"
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			return next(r)
+		}
+		escaped, route, _ := strings.Cut(rest, "/")
+		model, err := url.PathUnescape(escaped)
+		if err != nil {
+			return next(r)
+		}
+		if deployment, ok := deployments[model]; ok {
+			r.URL.Path = prefix + deployment + "/" + route
+			r.URL.RawPath = ""
+		}
+		return next(r)
+	})
 }
@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzureClientDeployments(t *testing.T) {
+	tests := []struct {
+		model    string
+		wantPath string
+	}{
+		{model: "gpt-4o", wantPath: "/openai/deployments/my-gpt-4o/chat/completions"},
+		{model: "gpt-4.1", wantPath: "/openai/deployments/gpt-4.1/chat/completions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			var gotPath, gotVersion, gotKey string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+				gotVersion = r.URL.Query().Get("api-version")
+				gotKey = r.Header.Get("Api-Key")
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+
+			client, err := newAzureClient(providerClientOptions{
+				baseURL: server.URL,
+				apiKey:  "test-key",
+				config: config.ProviderConfig{
+					Azure: &config.AzureOptions{
+						APIVersion:  "2024-10-21",
+						Deployments: map[string]string{"gpt-4o": "my-gpt-4o"},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, _ = client.(*azureClient).client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+				Model:    tt.model,
+				Messages: []openai.ChatCompletionMessageParamUnion{openai.UserMessage("Hello")},
+			})
+
+			require.Equal(t, tt.wantPath, gotPath)
+			require.Equal(t, "2024-10-21", gotVersion)
+			require.Equal(t, "test-key", gotKey)
+		})
+	}
+}
@@ -1,11 +1,20 @@
 package provider
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/llm/tools"
@@ -14,78 +23,453 @@ import (
 
 type bedrockClient struct {
 	providerOptions providerClientOptions
-	childProvider   ProviderClient
+	options         config.BedrockOptions
+	client          *bedrockruntime.Client
 }
 
 type BedrockClient ProviderClient
 
-func newBedrockClient(opts providerClientOptions) BedrockClient {
-	// Get AWS region from environment
-	region := opts.extraParams["region"]
-	if region == "" {
-		region = "us-east-1" // default region
+func newBedrockClient(opts providerClientOptions) (BedrockClient, error) {
+	var options config.BedrockOptions
+	if opts.config.Bedrock != nil {
+		options = *opts.config.Bedrock
 	}
-	if len(region) < 2 {
-		return &bedrockClient{
-			providerOptions: opts,
-			childProvider:   nil, // Will cause an error when used
-		}
+	options.Region = cmp.Or(options.Region, opts.extraParams["region"], "us-east-1")
+
+	// Credentials are resolved by the AWS SDK from the environment, the
+	// shared config files, or the instance metadata service, and requests
+	// are signed with SigV4.
+	loadOpts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(options.Region),
+	}
+	if options.Profile != "" {
+		loadOpts = append(loadOpts, awsconfig.WithSharedConfigProfile(options.Profile))
+	}
+	if opts.httpClient != nil {
+		loadOpts = append(loadOpts, awsconfig.WithHTTPClient(opts.httpClient))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	opts.model = func(modelType config.SelectedModelType) catwalk.Model {
-		model := config.Get().GetModelByType(modelType)
+	return &bedrockClient{
+		providerOptions: opts,
+		options:         options,
+		client:          bedrockruntime.NewFromConfig(awsCfg),
+	}, nil
+}
 
-		// Prefix the model name with region
-		regionPrefix := region[:2]
-		modelName := model.ID
-		model.ID = fmt.Sprintf("%s.%s", regionPrefix, modelName)
-		return *model
+// modelID returns the Bedrock model ID, inference profile, or ARN to call for
+// the model.
+func (b *bedrockClient) modelID(model catwalk.Model) string {
+	if id, ok := b.options.Models[model.ID]; ok {
+		return id
+	}
+	if strings.HasPrefix(model.ID, "arn:") || len(b.options.Region) < 2 {
+		return model.ID
 	}
+	// Use the cross-region inference profile, which newer models require.
+	return fmt.Sprintf("%s.%s", b.options.Region[:2], model.ID)
+}
 
-	model := opts.model(opts.modelType)
+func (b *bedrockClient) convertMessages(messages []message.Message) []types.Message {
+	var bedrockMessages []types.Message
+	add := func(role types.ConversationRole, content []types.ContentBlock) {
+		if len(content) == 0 {
+			return
+		}
+		// Bedrock requires roles to alternate, tool results are sent by
+		// the user.
+		if n := len(bedrockMessages); n > 0 && bedrockMessages[n-1].Role == role {
+			bedrockMessages[n-1].Content = append(bedrockMessages[n-1].Content, content...)
+			return
+		}
+		bedrockMessages = append(bedrockMessages, types.Message{Role: role, Content: content})
+	}
+
+	for _, msg := range messages {
+		var content []types.ContentBlock
+		switch msg.Role {
+		case message.User:
+			if text := msg.Content().String(); text != "" {
+				content = append(content, &types.ContentBlockMemberText{Value: text})
+			}
+			for _, binaryContent := range msg.BinaryContent() {
+				_, format, _ := strings.Cut(binaryContent.MIMEType, "/")
+				content = append(content, &types.ContentBlockMemberImage{Value: types.ImageBlock{
+					Format: types.ImageFormat(format),
+					Source: &types.ImageSourceMemberBytes{Value: binaryContent.Data},
+				}})
+			}
+			add(types.ConversationRoleUser, content)
 
-	// Determine which provider to use based on the model
-	if strings.Contains(string(model.ID), "anthropic") {
-		// Create Anthropic client with Bedrock configuration
-		anthropicOpts := opts
-		// TODO: later find a way to check if the AWS account has caching enabled
-		opts.disableCache = true // Disable cache for Bedrock
-		return &bedrockClient{
-			providerOptions: opts,
-			childProvider:   newAnthropicClient(anthropicOpts, AnthropicClientTypeBedrock),
+		case message.Assistant:
+			if reasoning := msg.ReasoningContent(); reasoning.Thinking != "" && reasoning.Signature != "" {
+				content = append(content, &types.ContentBlockMemberReasoningContent{
+					Value: &types.ReasoningContentBlockMemberReasoningText{Value: types.ReasoningTextBlock{
+						Text:      aws.String(reasoning.Thinking),
+						Signature: aws.String(reasoning.Signature),
+					}},
+				})
+			}
+			if text := msg.Content().String(); text != "" {
+				content = append(content, &types.ContentBlockMemberText{Value: text})
+			}
+			for _, call := range msg.ToolCalls() {
+				input, _ := parseJSONToMap(call.Input)
+				if input == nil {
+					input = map[string]any{}
+				}
+				content = append(content, &types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+					ToolUseId: aws.String(call.ID),
+					Name:      aws.String(call.Name),
+					Input:     document.NewLazyDocument(input),
+				}})
+			}
+			add(types.ConversationRoleAssistant, content)
+
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				status := types.ToolResultStatusSuccess
+				if result.IsError {
+					status = types.ToolResultStatusError
+				}
+				content = append(content, &types.ContentBlockMemberToolResult{Value: types.ToolResultBlock{
+					ToolUseId: aws.String(result.ToolCallID),
+					Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: result.Content}},
+					Status:    status,
+				}})
+			}
+			add(types.ConversationRoleUser, content)
 		}
 	}
+	return bedrockMessages
+}
 
-	// Return client with nil childProvider if model is not supported
-	// This will cause an error when used
-	return &bedrockClient{
-		providerOptions: opts,
-		childProvider:   nil,
+func (b *bedrockClient) convertTools(tools []tools.BaseTool) *types.ToolConfiguration {
+	if len(tools) == 0 {
+		return nil
+	}
+	bedrockTools := make([]types.Tool, 0, len(tools))
+	for _, tool := range tools {
+		info := tool.Info()
+		schema := map[string]any{
+			"type":       "object",
+			"properties": info.Parameters,
+			"required":   info.Required,
+		}
+		bedrockTools = append(bedrockTools, &types.ToolMemberToolSpec{Value: types.ToolSpecification{
+			Name:        aws.String(info.Name),
+			Description: aws.String(info.Description),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(schema)},
+		}})
+	}
+	return &types.ToolConfiguration{Tools: bedrockTools}
+}
+
+func (b *bedrockClient) finishReason(reason types.StopReason) message.FinishReason {
+	switch reason {
+	case types.StopReasonEndTurn, types.StopReasonStopSequence:
+		return message.FinishReasonEndTurn
+	case types.StopReasonMaxTokens:
+		return message.FinishReasonMaxTokens
+	case types.StopReasonToolUse:
+		return message.FinishReasonToolUse
+	default:
+		return message.FinishReasonUnknown
+	}
+}
+
+func (b *bedrockClient) isThinkingEnabled() bool {
+	cfg := config.Get()
+	modelConfig := cfg.Models[b.providerOptions.modelType]
+	// Only Anthropic models take the thinking budget.
+	return b.Model().CanReason && modelConfig.Think && strings.Contains(b.Model().ID, "anthropic.")
+}
+
+// converseInput is the common part of the Converse and ConverseStream
+// requests.
+type converseInput struct {
+	modelID          string
+	messages         []types.Message
+	system           []types.SystemContentBlock
+	inferenceConfig  *types.InferenceConfiguration
+	toolConfig       *types.ToolConfiguration
+	additionalFields document.Interface
+}
+
+func (b *bedrockClient) preparedInput(messages []message.Message, tools []tools.BaseTool) converseInput {
+	model := b.providerOptions.model(b.providerOptions.modelType)
+	cfg := config.Get()
+	modelConfig := cfg.Models[b.providerOptions.modelType]
+
+	maxTokens := model.DefaultMaxTokens
+	if modelConfig.MaxTokens > 0 {
+		maxTokens = modelConfig.MaxTokens
+	}
+	// Override max tokens if set in provider options
+	if b.providerOptions.maxTokens > 0 {
+		maxTokens = b.providerOptions.maxTokens
+	}
+
+	systemMessage := b.providerOptions.systemMessage
+	if b.providerOptions.systemPromptPrefix != "" {
+		systemMessage = b.providerOptions.systemPromptPrefix + "\n" + systemMessage
+	}
+
+	input := converseInput{
+		modelID:    b.modelID(model),
+		messages:   b.convertMessages(messages),
+		system:     []types.SystemContentBlock{&types.SystemContentBlockMemberText{Value: systemMessage}},
+		toolConfig: b.convertTools(tools),
+	}
+	if maxTokens > 0 {
+		input.inferenceConfig = &types.InferenceConfiguration{MaxTokens: aws.Int32(int32(maxTokens))}
+	}
+	if b.isThinkingEnabled() {
+		input.additionalFields = document.NewLazyDocument(map[string]any{
+			"thinking": map[string]any{
+				"type":          "enabled",
+				"budget_tokens": int64(float64(maxTokens) * 0.8),
+			},
+		})
+	}
+	if cfg.Options.Debug {
+		jsonData, _ := json.Marshal(messages)
+		slog.Debug("Prepared messages", "model", input.modelID, "messages", string(jsonData))
 	}
+	return input
 }
 
 func (b *bedrockClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
-	if b.childProvider == nil {
-		return nil, errors.New("unsupported model for bedrock provider")
+	input := b.preparedInput(messages, tools)
+	attempts := 0
+	for {
+		attempts++
+		if err := b.providerOptions.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		output, err := b.client.Converse(ctx, &bedrockruntime.ConverseInput{
+			ModelId:                      aws.String(input.modelID),
+			Messages:                     input.messages,
+			System:                       input.system,
+			InferenceConfig:              input.inferenceConfig,
+			ToolConfig:                   input.toolConfig,
+			AdditionalModelRequestFields: input.additionalFields,
+		})
+		if err != nil {
+			retry, after, retryErr := b.shouldRetry(attempts, err)
+			if retryErr != nil {
+				return nil, retryErr
+			}
+			if retry {
+				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(after) * time.Millisecond):
+					continue
+				}
+			}
+			return nil, retryErr
+		}
+
+		response := &ProviderResponse{
+			Usage:        b.usage(output.Usage),
+			FinishReason: b.finishReason(output.StopReason),
+		}
+		if msg, ok := output.Output.(*types.ConverseOutputMemberMessage); ok {
+			for _, block := range msg.Value.Content {
+				switch block := block.(type) {
+				case *types.ContentBlockMemberText:
+					response.Content += block.Value
+				case *types.ContentBlockMemberToolUse:
+					response.ToolCalls = append(response.ToolCalls, b.toolCall(block.Value))
+				}
+			}
+		}
+		return response, nil
 	}
-	return b.childProvider.send(ctx, messages, tools)
 }
 
 func (b *bedrockClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	input := b.preparedInput(messages, tools)
+	attempts := 0
 	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		for {
+			attempts++
+			if err := b.providerOptions.limiter.wait(ctx); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			output, err := b.client.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+				ModelId:                      aws.String(input.modelID),
+				Messages:                     input.messages,
+				System:                       input.system,
+				InferenceConfig:              input.inferenceConfig,
+				ToolConfig:                   input.toolConfig,
+				AdditionalModelRequestFields: input.additionalFields,
+			})
+			if err == nil {
+				err = b.readStream(output.GetStream(), eventChan)
+			}
+			if err == nil {
+				return
+			}
+
+			retry, after, retryErr := b.shouldRetry(attempts, err)
+			if retryErr != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+				return
+			}
+			if !retry {
+				return
+			}
+			slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
+			select {
+			case <-ctx.Done():
+				eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+				return
+			case <-time.After(time.Duration(after) * time.Millisecond):
+			}
+		}
+	}()
+	return eventChan
+}
+
+// readStream forwards the events of a ConverseStream response until it
+// completes.
+func (b *bedrockClient) readStream(stream *bedrockruntime.ConverseStreamEventStream, eventChan chan<- ProviderEvent) error {
+	defer stream.Close()
+
+	var (
+		response = &ProviderResponse{}
+		// toolCalls are the tool calls being streamed by content block.
+		toolCalls = map[int32]*message.ToolCall{}
+		started   bool
+	)
+	for event := range stream.Events() {
+		switch event := event.(type) {
+		case *types.ConverseStreamOutputMemberContentBlockStart:
+			start, ok := event.Value.Start.(*types.ContentBlockStartMemberToolUse)
+			if !ok {
+				continue
+			}
+			call := &message.ToolCall{
+				ID:   aws.ToString(start.Value.ToolUseId),
+				Name: aws.ToString(start.Value.Name),
+				Type: "function",
+			}
+			toolCalls[aws.ToInt32(event.Value.ContentBlockIndex)] = call
+			eventChan <- ProviderEvent{Type: EventToolUseStart, ToolCall: &message.ToolCall{ID: call.ID, Name: call.Name}}
+
+		case *types.ConverseStreamOutputMemberContentBlockDelta:
+			switch delta := event.Value.Delta.(type) {
+			case *types.ContentBlockDeltaMemberText:
+				if !started {
+					started = true
+					eventChan <- ProviderEvent{Type: EventContentStart}
+				}
+				response.Content += delta.Value
+				eventChan <- ProviderEvent{Type: EventContentDelta, Content: delta.Value}
+			case *types.ContentBlockDeltaMemberReasoningContent:
+				switch reasoning := delta.Value.(type) {
+				case *types.ReasoningContentBlockDeltaMemberText:
+					eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: reasoning.Value}
+				case *types.ReasoningContentBlockDeltaMemberSignature:
+					eventChan <- ProviderEvent{Type: EventSignatureDelta, Signature: reasoning.Value}
+				}
+			case *types.ContentBlockDeltaMemberToolUse:
+				call, ok := toolCalls[aws.ToInt32(event.Value.ContentBlockIndex)]
+				if !ok {
+					continue
+				}
+				call.Input += aws.ToString(delta.Value.Input)
+				eventChan <- ProviderEvent{
+					Type:     EventToolUseDelta,
+					ToolCall: &message.ToolCall{ID: call.ID, Input: aws.ToString(delta.Value.Input)},
+				}
+			}
 
-	if b.childProvider == nil {
-		go func() {
-			eventChan <- ProviderEvent{
-				Type:  EventError,
-				Error: errors.New("unsupported model for bedrock provider"),
+		case *types.ConverseStreamOutputMemberContentBlockStop:
+			call, ok := toolCalls[aws.ToInt32(event.Value.ContentBlockIndex)]
+			if !ok {
+				continue
+			}
+			call.Finished = true
+			if call.Input == "" {
+				call.Input = "{}"
 			}
-			close(eventChan)
-		}()
-		return eventChan
+			response.ToolCalls = append(response.ToolCalls, *call)
+			eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &message.ToolCall{ID: call.ID}}
+
+		case *types.ConverseStreamOutputMemberMessageStop:
+			response.FinishReason = b.finishReason(event.Value.StopReason)
+
+		case *types.ConverseStreamOutputMemberMetadata:
+			response.Usage = b.usage(event.Value.Usage)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+
+	if started {
+		eventChan <- ProviderEvent{Type: EventContentStop}
+	}
+	eventChan <- ProviderEvent{
+		Type:     EventComplete,
+		Response: response,
+		Content:  response.Content,
+	}
+	return nil
+}
+
+func (b *bedrockClient) toolCall(block types.ToolUseBlock) message.ToolCall {
+	input := "{}"
+	if block.Input != nil {
+		if data, err := block.Input.MarshalSmithyDocument(); err == nil {
+			input = string(data)
+		}
+	}
+	return message.ToolCall{
+		ID:       aws.ToString(block.ToolUseId),
+		Name:     aws.ToString(block.Name),
+		Input:    input,
+		Type:     "function",
+		Finished: true,
 	}
+}
 
-	return b.childProvider.stream(ctx, messages, tools)
+func (b *bedrockClient) shouldRetry(attempts int, err error) (bool, int64, error) {
+	var (
+		throttling  *types.ThrottlingException
+		unavailable *types.ServiceUnavailableException
+		notReady    *types.ModelNotReadyException
+	)
+	if !errors.As(err, &throttling) && !errors.As(err, &unavailable) && !errors.As(err, &notReady) {
+		return false, 0, err
+	}
+	if attempts > maxRetries {
+		return false, 0, fmt.Errorf("maximum retry attempts reached for rate limit: %d retries", maxRetries)
+	}
+
+	return true, b.providerOptions.limiter.retryDelay(attempts, nil), nil
+}
+
+func (b *bedrockClient) usage(usage *types.TokenUsage) TokenUsage {
+	if usage == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{
+		InputTokens:         int64(aws.ToInt32(usage.InputTokens)),
+		OutputTokens:        int64(aws.ToInt32(usage.OutputTokens)),
+		CacheCreationTokens: int64(aws.ToInt32(usage.CacheWriteInputTokens)),
+		CacheReadTokens:     int64(aws.ToInt32(usage.CacheReadInputTokens)),
+	}
 }
 
 func (b *bedrockClient) Model() catwalk.Model {
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBedrockClientModelID(t *testing.T) {
+	client := &bedrockClient{options: config.BedrockOptions{
+		Region: "eu-west-1",
+		Models: map[string]string{
+			"anthropic.claude-sonnet-4-20250514-v1:0": "arn:aws:bedrock:eu-west-1:123456789012:application-inference-profile/abc123",
+		},
+	}}
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"anthropic.claude-sonnet-4-20250514-v1:0", "arn:aws:bedrock:eu-west-1:123456789012:application-inference-profile/abc123"},
+		{"amazon.nova-pro-v1:0", "eu.amazon.nova-pro-v1:0"},
+		{"arn:aws:bedrock:eu-west-1::foundation-model/amazon.nova-pro-v1:0", "arn:aws:bedrock:eu-west-1::foundation-model/amazon.nova-pro-v1:0"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, client.modelID(catwalk.Model{ID: tt.model}))
+	}
+}
+
+func TestBedrockClientConvertMessages(t *testing.T) {
+	client := &bedrockClient{}
+	messages := client.convertMessages([]message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "List the files"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{ID: "call_1", Name: "ls", Input: `{"path":"."}`, Finished: true},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call_1", Content: "main.go"},
+		}},
+		// Sent after the tool result, it has to be merged into the same
+		// user message.
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Thanks"}}},
+	})
+
+	require.Len(t, messages, 3)
+	require.Equal(t, types.ConversationRoleUser, messages[0].Role)
+	require.Equal(t, types.ConversationRoleAssistant, messages[1].Role)
+	require.Equal(t, types.ConversationRoleUser, messages[2].Role)
+
+	toolUse, ok := messages[1].Content[0].(*types.ContentBlockMemberToolUse)
+	require.True(t, ok)
+	require.Equal(t, "call_1", *toolUse.Value.ToolUseId)
+	require.Equal(t, `{"path":"."}`, client.toolCall(toolUse.Value).Input)
+
+	require.Len(t, messages[2].Content, 2)
+	result, ok := messages[2].Content[0].(*types.ContentBlockMemberToolResult)
+	require.True(t, ok)
+	require.Equal(t, types.ToolResultStatusSuccess, result.Value.Status)
+	require.Equal(t, &types.ContentBlockMemberText{Value: "Thanks"}, messages[2].Content[1])
+}
@@ -0,0 +1,431 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/google/uuid"
+)
+
+// maxToolCallRetries is how many times a model emulating tool calls is asked
+// to write again the calls that couldn't be parsed.
+const maxToolCallRetries = 2
+
+const (
+	toolCallOpenTag  = "<tool_call>"
+	toolCallCloseTag = "</tool_call>"
+	// toolCallFence opens the code fences some models write the calls in
+	// instead of the tags.
+	toolCallFence      = "```tool_call"
+	toolCallFenceClose = "```"
+)
+
+// emulatesTools reports whether the tools are described in the prompt of
+// the model instead of being sent to it, see config.ToolCallingEmulated.
+func emulatesTools(modelType config.SelectedModelType, tools []tools.BaseTool) bool {
+	return len(tools) > 0 && config.Get().Models[modelType].ToolCalling == config.ToolCallingEmulated
+}
+
+// toolsPrompt describes the tools and how to call them.
+func toolsPrompt(tools []tools.BaseTool) string {
+	var sb strings.Builder
+	sb.WriteString(`You can call tools. To call one, write a <tool_call> block with the name of the tool and its arguments as a JSON object:
+
+<tool_call>
+{"name": "tool_name", "arguments": {"parameter": "value"}}
+</tool_call>
+
+Write a block for each call. After the calls, stop and wait for their results, they are sent back in <tool_result> blocks. Answer without blocks once you're done.
+
+The tools are:
+
+<tools>
+`)
+	for _, tool := range tools {
+		info := tool.Info()
+		data, err := json.Marshal(map[string]any{
+			"name":        info.Name,
+			"description": info.Description,
+			"parameters": map[string]any{
+				"type":       "object",
+				"properties": info.Parameters,
+				"required":   info.Required,
+			},
+		})
+		if err != nil {
+			slog.Warn("Failed to describe tool", "tool", info.Name, "error", err)
+			continue
+		}
+		sb.Write(data)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</tools>")
+	return sb.String()
+}
+
+// emulatedMessages rewrites the conversation for a model emulating tool
+// calls: the tools are described before the first user message, and the
+// calls and their results are written as text.
+func emulatedMessages(messages []message.Message, tools []tools.BaseTool) []message.Message {
+	emulated := make([]message.Message, 0, len(messages))
+	prompted := false
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			if !prompted {
+				prompted = true
+				msg = withText(msg, toolsPrompt(tools)+"\n\n"+msg.Content().Text)
+			}
+		case message.Assistant:
+			calls := msg.ToolCalls()
+			if len(calls) == 0 {
+				break
+			}
+			var sb strings.Builder
+			sb.WriteString(msg.Content().Text)
+			for _, call := range calls {
+				if sb.Len() > 0 {
+					sb.WriteString("\n\n")
+				}
+				input := call.Input
+				if !json.Valid([]byte(input)) {
+					input = "{}"
+				}
+				fmt.Fprintf(&sb, "%s\n{\"name\": %q, \"arguments\": %s}\n%s", toolCallOpenTag, call.Name, input, toolCallCloseTag)
+			}
+			msg = withText(msg, sb.String())
+		case message.Tool:
+			var sb strings.Builder
+			for i, result := range msg.ToolResults() {
+				if i > 0 {
+					sb.WriteString("\n\n")
+				}
+				status := ""
+				if result.IsError {
+					status = ` error="true"`
+				}
+				fmt.Fprintf(&sb, "<tool_result name=%q%s>\n%s\n</tool_result>", result.Name, status, result.Content)
+			}
+			msg = message.Message{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: sb.String()}}}
+		}
+		emulated = append(emulated, msg)
+	}
+	return emulated
+}
+
+// withText returns the message with its text replaced and without tool
+// calls, leaving the parts of the original message untouched.
+func withText(msg message.Message, text string) message.Message {
+	parts := []message.ContentPart{message.TextContent{Text: text}}
+	for _, part := range msg.Parts {
+		switch part.(type) {
+		case message.TextContent, message.ToolCall:
+		default:
+			parts = append(parts, part)
+		}
+	}
+	msg.Parts = parts
+	return msg
+}
+
+// emulatedCall is a call written by the model. Models write the arguments
+// under other names too, or as a string.
+type emulatedCall struct {
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Parameters json.RawMessage `json:"parameters"`
+	Input      json.RawMessage `json:"input"`
+}
+
+// parseToolCalls returns the text of the answer without its tool call
+// blocks and the calls of the blocks. The calls that can't be used are
+// left out and reported in the error.
+func parseToolCalls(content string, tools []tools.BaseTool) (text string, calls []message.ToolCall, err error) {
+	var sb strings.Builder
+	var errs []error
+	for content != "" {
+		i, openTag, closeTag := toolCallStart(content)
+		if i < 0 {
+			sb.WriteString(content)
+			break
+		}
+		sb.WriteString(content[:i])
+		block := content[i+len(openTag):]
+		content = ""
+		// Blocks cut off at the end of the answer are parsed all the same.
+		if end := strings.Index(block, closeTag); end >= 0 {
+			block, content = block[:end], block[end+len(closeTag):]
+		}
+		blockCalls, err := parseToolCallBlock(block, tools)
+		calls = append(calls, blockCalls...)
+		errs = append(errs, err)
+	}
+	return strings.TrimSpace(sb.String()), calls, errors.Join(errs...)
+}
+
+// toolCallStart returns the index of the first tool call block of the text,
+// with its tags, or -1.
+func toolCallStart(text string) (int, string, string) {
+	tag, fence := strings.Index(text, toolCallOpenTag), strings.Index(text, toolCallFence)
+	switch {
+	case tag >= 0 && (fence < 0 || tag < fence):
+		return tag, toolCallOpenTag, toolCallCloseTag
+	case fence >= 0:
+		return fence, toolCallFence, toolCallFenceClose
+	}
+	return -1, "", ""
+}
+
+// parseToolCallBlock parses the call of a block, or the array of calls some
+// models write in a single block.
+func parseToolCallBlock(block string, available []tools.BaseTool) ([]message.ToolCall, error) {
+	block = strings.TrimSpace(block)
+	var written []emulatedCall
+	if strings.HasPrefix(block, "[") {
+		if err := json.Unmarshal([]byte(block), &written); err != nil {
+			return nil, fmt.Errorf("invalid JSON in tool call: %w", err)
+		}
+	} else {
+		var call emulatedCall
+		if err := DecodeResponse(block, &call); err != nil {
+			return nil, fmt.Errorf("invalid tool call: %w", err)
+		}
+		written = append(written, call)
+	}
+
+	var calls []message.ToolCall
+	var errs []error
+	for _, call := range written {
+		if !slices.ContainsFunc(available, func(tool tools.BaseTool) bool { return tool.Name() == call.Name }) {
+			errs = append(errs, fmt.Errorf("unknown tool %q", call.Name))
+			continue
+		}
+		input, err := callInput(call)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid arguments of tool %s: %w", call.Name, err))
+			continue
+		}
+		calls = append(calls, message.ToolCall{
+			ID:       "call_" + uuid.New().String(),
+			Name:     call.Name,
+			Input:    input,
+			Type:     "function",
+			Finished: true,
+		})
+	}
+	return calls, errors.Join(errs...)
+}
+
+// callInput returns the arguments of the call as a JSON object.
+func callInput(call emulatedCall) (string, error) {
+	args := call.Arguments
+	for _, other := range []json.RawMessage{call.Parameters, call.Input} {
+		if len(args) == 0 || string(args) == "null" {
+			args = other
+		}
+	}
+	if len(args) == 0 || string(args) == "null" {
+		return "{}", nil
+	}
+	var encoded string
+	if err := json.Unmarshal(args, &encoded); err == nil {
+		args = json.RawMessage(encoded)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(args, &object); err != nil {
+		return "", errors.New("not a JSON object")
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, args); err != nil {
+		return "", err
+	}
+	return compact.String(), nil
+}
+
+// toolCallSplitter holds the tool call blocks back from the streamed
+// content, they are parsed from the whole answer.
+type toolCallSplitter struct {
+	inCall   bool
+	closeTag string
+	// pending is the text held back until it's known whether it's a tag.
+	pending string
+}
+
+// split returns the content of the chunk outside of tool call blocks.
+func (s *toolCallSplitter) split(chunk string) string {
+	text := s.pending + chunk
+	s.pending = ""
+	var sb strings.Builder
+	for text != "" {
+		if s.inCall {
+			i := strings.Index(text, s.closeTag)
+			if i < 0 {
+				n := partialTagLen(text, s.closeTag)
+				s.pending = text[len(text)-n:]
+				break
+			}
+			text = text[i+len(s.closeTag):]
+			s.inCall = false
+			continue
+		}
+		i, openTag, closeTag := toolCallStart(text)
+		if i < 0 {
+			n := max(partialTagLen(text, toolCallOpenTag), partialTagLen(text, toolCallFence))
+			sb.WriteString(text[:len(text)-n])
+			s.pending = text[len(text)-n:]
+			break
+		}
+		sb.WriteString(text[:i])
+		text = text[i+len(openTag):]
+		s.inCall, s.closeTag = true, closeTag
+	}
+	return sb.String()
+}
+
+// flush returns the content held back at the end of the stream.
+func (s *toolCallSplitter) flush() string {
+	pending := s.pending
+	s.pending = ""
+	if s.inCall {
+		return ""
+	}
+	return pending
+}
+
+// retryMessages returns the conversation asking the model to write again the
+// calls of its answer that couldn't be used.
+func retryMessages(messages []message.Message, content string, err error) []message.Message {
+	return append(slices.Clip(messages),
+		message.Message{Role: message.Assistant, Parts: []message.ContentPart{message.TextContent{Text: content}}},
+		message.Message{Role: message.User, Parts: []message.ContentPart{message.TextContent{
+			Text: fmt.Sprintf("Some of your tool calls couldn't be used: %v. Write all the calls again, each in a <tool_call> block with a JSON object of its name and arguments.", err),
+		}}},
+	)
+}
+
+// emulatedResponse sets the text and the calls of the answer on the response,
+// or returns the error of the calls when none of them can be used.
+func emulatedResponse(response *ProviderResponse, text string, calls []message.ToolCall, err error) error {
+	if err != nil {
+		if len(calls) == 0 {
+			return fmt.Errorf("the model wrote invalid tool calls: %w", err)
+		}
+		slog.Warn("Leaving out invalid emulated tool calls", "error", err)
+	}
+	response.Content, response.ToolCalls = text, calls
+	if len(calls) > 0 {
+		response.FinishReason = message.FinishReasonToolUse
+	}
+	return nil
+}
+
+func addUsage(a, b TokenUsage) TokenUsage {
+	return TokenUsage{
+		InputTokens:         a.InputTokens + b.InputTokens,
+		OutputTokens:        a.OutputTokens + b.OutputTokens,
+		CacheCreationTokens: a.CacheCreationTokens + b.CacheCreationTokens,
+		CacheReadTokens:     a.CacheReadTokens + b.CacheReadTokens,
+		ReasoningTokens:     a.ReasoningTokens + b.ReasoningTokens,
+	}
+}
+
+type (
+	sendFunc   func(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
+	streamFunc func(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
+)
+
+// emulatedSend returns send for a model emulating tool calls, which asks it
+// again for the calls that couldn't be parsed.
+func emulatedSend(send sendFunc) sendFunc {
+	return func(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+		return sendEmulated(ctx, send, messages, tools)
+	}
+}
+
+func sendEmulated(ctx context.Context, send sendFunc, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	messages = emulatedMessages(messages, tools)
+	var usage TokenUsage
+	for attempt := 0; ; attempt++ {
+		response, err := send(ctx, messages, nil)
+		if err != nil {
+			return nil, err
+		}
+		usage = addUsage(usage, response.Usage)
+		text, calls, err := parseToolCalls(response.Content, tools)
+		if err != nil && attempt < maxToolCallRetries {
+			slog.Warn("Retrying invalid emulated tool calls", "error", err, "attempt", attempt+1)
+			messages = retryMessages(messages, response.Content, err)
+			continue
+		}
+		response.Usage = usage
+		if err := emulatedResponse(response, text, calls, err); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+}
+
+// emulatedStream returns stream for a model emulating tool calls, which holds
+// the calls back from the content and parses them once it's complete.
+func emulatedStream(stream streamFunc) streamFunc {
+	return func(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+		return streamEmulated(ctx, stream, messages, tools)
+	}
+}
+
+func streamEmulated(ctx context.Context, stream streamFunc, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		messages = emulatedMessages(messages, tools)
+		var usage TokenUsage
+		for attempt := 0; ; attempt++ {
+			var splitter toolCallSplitter
+			var response *ProviderResponse
+			for event := range stream(ctx, messages, nil) {
+				switch event.Type {
+				case EventContentDelta:
+					if content := splitter.split(event.Content); content != "" {
+						eventChan <- ProviderEvent{Type: EventContentDelta, Content: content}
+					}
+				case EventComplete:
+					response = event.Response
+				default:
+					eventChan <- event
+				}
+			}
+			if content := splitter.flush(); content != "" {
+				eventChan <- ProviderEvent{Type: EventContentDelta, Content: content}
+			}
+			// The stream ended with an error.
+			if response == nil {
+				return
+			}
+
+			usage = addUsage(usage, response.Usage)
+			text, calls, err := parseToolCalls(response.Content, tools)
+			if err != nil && attempt < maxToolCallRetries {
+				slog.Warn("Retrying invalid emulated tool calls", "error", err, "attempt", attempt+1)
+				messages = retryMessages(messages, response.Content, err)
+				continue
+			}
+			response.Usage = usage
+			if err := emulatedResponse(response, text, calls, err); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			eventChan <- ProviderEvent{Type: EventComplete, Response: response, Content: text}
+			return
+		}
+	}()
+	return eventChan
+}
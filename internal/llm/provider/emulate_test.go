@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+type testTool struct{ name string }
+
+func (t testTool) Info() tools.ToolInfo {
+	return tools.ToolInfo{
+		Name:        t.name,
+		Description: "Views a file.",
+		Parameters:  map[string]any{"file_path": map[string]any{"type": "string"}},
+		Required:    []string{"file_path"},
+	}
+}
+
+func (t testTool) Name() string { return t.name }
+
+func (t testTool) Run(context.Context, tools.ToolCall) (tools.ToolResponse, error) {
+	return tools.ToolResponse{}, nil
+}
+
+var testTools = []tools.BaseTool{testTool{"view"}, testTool{"ls"}}
+
+func TestParseToolCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		text    string
+		inputs  []string
+		err     string
+	}{
+		{
+			name:    "tags",
+			content: "Let me look.\n\n<tool_call>\n{\"name\": \"view\", \"arguments\": {\"file_path\": \"main.go\"}}\n</tool_call>",
+			text:    "Let me look.",
+			inputs:  []string{`{"file_path":"main.go"}`},
+		},
+		{
+			name:    "fence and string arguments",
+			content: "```tool_call\n{\"name\": \"view\", \"arguments\": \"{\\\"file_path\\\": \\\"go.mod\\\"}\"}\n```\nThen: ```tool_call\n{\"name\": \"ls\", \"parameters\": {}}\n```",
+			text:    "Then:",
+			inputs:  []string{`{"file_path":"go.mod"}`, `{}`},
+		},
+		{
+			name:    "array cut off",
+			content: `<tool_call>[{"name": "ls"}, {"name": "view", "input": {"file_path": "a.go"}}]`,
+			inputs:  []string{`{}`, `{"file_path":"a.go"}`},
+		},
+		{
+			name:    "invalid calls",
+			content: "<tool_call>{\"name\": \"rm\", \"arguments\": {}}</tool_call><tool_call>{name: view}</tool_call><tool_call>{\"name\": \"view\", \"arguments\": [1]}</tool_call>",
+			err:     `unknown tool "rm"`,
+		},
+		{
+			name:    "no calls",
+			content: "The <b>answer</b>.",
+			text:    "The <b>answer</b>.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, calls, err := parseToolCalls(tt.content, testTools)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				require.ErrorContains(t, err, "invalid tool call")
+				require.ErrorContains(t, err, "invalid arguments of tool view")
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.text, text)
+			var inputs []string
+			for _, call := range calls {
+				require.True(t, strings.HasPrefix(call.ID, "call_"))
+				require.True(t, call.Finished)
+				inputs = append(inputs, call.Input)
+			}
+			require.Equal(t, tt.inputs, inputs)
+		})
+	}
+}
+
+func TestToolCallSplitter(t *testing.T) {
+	var s toolCallSplitter
+	var content string
+	for _, chunk := range []string{"Let me look.<tool", "_call>{\"name\": ", "\"ls\"}</tool_c", "all> Done ```", "tool_call\n{}\n``", "`<b>"} {
+		content += s.split(chunk)
+	}
+	content += s.flush()
+	require.Equal(t, "Let me look. Done <b>", content)
+}
+
+func TestEmulatedMessages(t *testing.T) {
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Read main.go"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.TextContent{Text: "Sure."},
+			message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"main.go"}`, Finished: true},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call_1", Name: "view", Content: "package main", IsError: true},
+		}},
+	}
+	emulated := emulatedMessages(messages, testTools)
+
+	require.Len(t, emulated, 3)
+	prompt := emulated[0].Content().Text
+	require.Contains(t, prompt, `{"description":"Views a file.","name":"view"`)
+	require.True(t, strings.HasSuffix(prompt, "</tools>\n\nRead main.go"))
+	require.Equal(t, "Sure.\n\n<tool_call>\n{\"name\": \"view\", \"arguments\": {\"file_path\":\"main.go\"}}\n</tool_call>", emulated[1].Content().Text)
+	require.Empty(t, emulated[1].ToolCalls())
+	require.Equal(t, message.User, emulated[2].Role)
+	require.Equal(t, "<tool_result name=\"view\" error=\"true\">\npackage main\n</tool_result>", emulated[2].Content().Text)
+
+	// The messages of the session are left untouched.
+	require.Equal(t, "Read main.go", messages[0].Content().Text)
+	require.Len(t, messages[1].ToolCalls(), 1)
+}
+
+func TestStreamEmulated(t *testing.T) {
+	answers := []string{
+		"Checking.<tool_call>{\"name\": \"cat\"}</tool_call>",
+		"<tool_call>{\"name\": \"view\", \"arguments\": {\"file_path\": \"main.go\"}}</tool_call>",
+	}
+	var requests [][]message.Message
+	stream := func(_ context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+		require.Nil(t, tools, "the tools are in the prompt")
+		requests = append(requests, messages)
+		answer := answers[len(requests)-1]
+		eventChan := make(chan ProviderEvent, 3)
+		eventChan <- ProviderEvent{Type: EventContentDelta, Content: answer[:12]}
+		eventChan <- ProviderEvent{Type: EventContentDelta, Content: answer[12:]}
+		eventChan <- ProviderEvent{Type: EventComplete, Response: &ProviderResponse{
+			Content:      answer,
+			Usage:        TokenUsage{InputTokens: 100, OutputTokens: 10},
+			FinishReason: message.FinishReasonEndTurn,
+		}}
+		close(eventChan)
+		return eventChan
+	}
+
+	var content string
+	var response *ProviderResponse
+	for event := range emulatedStream(stream)(t.Context(), []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Read main.go"}}},
+	}, testTools) {
+		require.NoError(t, event.Error)
+		switch event.Type {
+		case EventContentDelta:
+			content += event.Content
+		case EventComplete:
+			response = event.Response
+		}
+	}
+
+	require.Equal(t, "Checking.", content)
+	require.Len(t, requests, 2, "the invalid call is retried")
+	require.Contains(t, requests[1][2].Content().Text, `unknown tool "cat"`)
+	require.NotNil(t, response)
+	require.Len(t, response.ToolCalls, 1)
+	require.Equal(t, `{"file_path":"main.go"}`, response.ToolCalls[0].Input)
+	require.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	require.Equal(t, TokenUsage{InputTokens: 200, OutputTokens: 20}, response.Usage)
+}
+
+func TestSendEmulated_InvalidCalls(t *testing.T) {
+	attempts := 0
+	send := func(context.Context, []message.Message, []tools.BaseTool) (*ProviderResponse, error) {
+		attempts++
+		return &ProviderResponse{Content: "<tool_call>not JSON</tool_call>"}, nil
+	}
+	_, err := emulatedSend(send)(t.Context(), []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Hi"}}},
+	}, testTools)
+	require.ErrorContains(t, err, "the model wrote invalid tool calls")
+	require.Equal(t, maxToolCallRetries+1, attempts)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 
@@ -25,28 +26,49 @@ type geminiClient struct {
 
 type GeminiClient ProviderClient
 
-func newGeminiClient(opts providerClientOptions) GeminiClient {
+func newGeminiClient(opts providerClientOptions) (GeminiClient, error) {
 	client, err := createGeminiClient(opts)
 	if err != nil {
-		slog.Error("Failed to create Gemini client", "error", err)
-		return nil
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 
 	return &geminiClient{
 		providerOptions: opts,
 		client:          client,
-	}
+	}, nil
 }
 
 func createGeminiClient(opts providerClientOptions) (*genai.Client, error) {
-	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{APIKey: opts.apiKey, Backend: genai.BackendGeminiAPI})
-	if err != nil {
-		return nil, err
+	return genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      opts.apiKey,
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: geminiHTTPOptions(opts),
+		HTTPClient:  opts.httpClient,
+	})
+}
+
+// geminiHTTPOptions returns the endpoint and the headers shared by the
+// Gemini API and Vertex AI clients.
+func geminiHTTPOptions(opts providerClientOptions) genai.HTTPOptions {
+	var httpOptions genai.HTTPOptions
+	httpOptions.BaseURL = opts.baseURL
+	if len(opts.extraHeaders) > 0 {
+		httpOptions.Headers = make(http.Header, len(opts.extraHeaders))
+		for key, value := range opts.extraHeaders {
+			httpOptions.Headers.Set(key, value)
+		}
 	}
-	return client, nil
+	return httpOptions
 }
 
 func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Content {
+	toolCallNames := make(map[string]string)
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls() {
+			toolCallNames[call.ID] = call.Name
+		}
+	}
+
 	var history []*genai.Content
 	for _, msg := range messages {
 		switch msg.Role {
@@ -54,15 +76,14 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 			var parts []*genai.Part
 			parts = append(parts, &genai.Part{Text: msg.Content().String()})
 			for _, binaryContent := range msg.BinaryContent() {
-				imageFormat := strings.Split(binaryContent.MIMEType, "/")
 				parts = append(parts, &genai.Part{InlineData: &genai.Blob{
-					MIMEType: imageFormat[1],
+					MIMEType: binaryContent.MIMEType,
 					Data:     binaryContent.Data,
 				}})
 			}
 			history = append(history, &genai.Content{
 				Parts: parts,
-				Role:  "user",
+				Role:  genai.RoleUser,
 			})
 		case message.Assistant:
 			var assistantParts []*genai.Part
@@ -71,55 +92,45 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 				assistantParts = append(assistantParts, &genai.Part{Text: msg.Content().String()})
 			}
 
-			if len(msg.ToolCalls()) > 0 {
-				for _, call := range msg.ToolCalls() {
-					args, _ := parseJSONToMap(call.Input)
-					assistantParts = append(assistantParts, &genai.Part{
-						FunctionCall: &genai.FunctionCall{
-							Name: call.Name,
-							Args: args,
-						},
-					})
-				}
+			for _, call := range msg.ToolCalls() {
+				args, _ := parseJSONToMap(call.Input)
+				assistantParts = append(assistantParts, &genai.Part{
+					FunctionCall: &genai.FunctionCall{
+						Name: call.Name,
+						Args: args,
+					},
+				})
 			}
 
 			if len(assistantParts) > 0 {
 				history = append(history, &genai.Content{
-					Role:  "model",
+					Role:  genai.RoleModel,
 					Parts: assistantParts,
 				})
 			}
 
 		case message.Tool:
+			// The responses to parallel function calls have to be sent
+			// together, in the order of the calls.
+			var parts []*genai.Part
 			for _, result := range msg.ToolResults() {
-				response := map[string]any{"result": result.Content}
-				parsed, err := parseJSONToMap(result.Content)
-				if err == nil {
+				response := map[string]any{"output": result.Content}
+				if result.IsError {
+					response = map[string]any{"error": result.Content}
+				} else if parsed, err := parseJSONToMap(result.Content); err == nil {
 					response = parsed
 				}
-
-				var toolCall message.ToolCall
-				for _, m := range messages {
-					if m.Role == message.Assistant {
-						for _, call := range m.ToolCalls() {
-							if call.ID == result.ToolCallID {
-								toolCall = call
-								break
-							}
-						}
-					}
-				}
-
-				history = append(history, &genai.Content{
-					Parts: []*genai.Part{
-						{
-							FunctionResponse: &genai.FunctionResponse{
-								Name:     toolCall.Name,
-								Response: response,
-							},
-						},
+				parts = append(parts, &genai.Part{
+					FunctionResponse: &genai.FunctionResponse{
+						Name:     toolCallNames[result.ToolCallID],
+						Response: response,
 					},
-					Role: "function",
+				})
+			}
+			if len(parts) > 0 {
+				history = append(history, &genai.Content{
+					Parts: parts,
+					Role:  genai.RoleUser,
 				})
 			}
 		}
@@ -129,6 +140,9 @@ func (g *geminiClient) convertMessages(messages []message.Message) []*genai.Cont
 }
 
 func (g *geminiClient) convertTools(tools []tools.BaseTool) []*genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
 	geminiTool := &genai.Tool{}
 	geminiTool.FunctionDeclarations = make([]*genai.FunctionDeclaration, 0, len(tools))
 
@@ -161,9 +175,19 @@ func (g *geminiClient) finishReason(reason genai.FinishReason) message.FinishRea
 	}
 }
 
-func (g *geminiClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
-	// Convert messages
+func (g *geminiClient) isThinkingEnabled() bool {
+	cfg := config.Get()
+	modelConfig := cfg.Models[g.providerOptions.modelType]
+	return g.Model().CanReason && modelConfig.Think
+}
+
+// preparedChat returns a chat with the history of the messages, and the parts
+// of the last message to send to it.
+func (g *geminiClient) preparedChat(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*genai.Chat, []genai.Part, error) {
 	geminiMessages := g.convertMessages(messages)
+	if len(geminiMessages) == 0 {
+		return nil, nil, errors.New("no messages to send")
+	}
 	model := g.providerOptions.model(g.providerOptions.modelType)
 	cfg := config.Get()
 	if cfg.Options.Debug {
@@ -171,39 +195,85 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 		slog.Debug("Prepared messages", "messages", string(jsonData))
 	}
 
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if g.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
-
+	modelConfig := cfg.Models[g.providerOptions.modelType]
 	maxTokens := model.DefaultMaxTokens
 	if modelConfig.MaxTokens > 0 {
 		maxTokens = modelConfig.MaxTokens
 	}
+
+	// Override max tokens if set in provider options
+	if g.providerOptions.maxTokens > 0 {
+		maxTokens = g.providerOptions.maxTokens
+	}
 	systemMessage := g.providerOptions.systemMessage
 	if g.providerOptions.systemPromptPrefix != "" {
 		systemMessage = g.providerOptions.systemPromptPrefix + "\n" + systemMessage
 	}
 	history := geminiMessages[:len(geminiMessages)-1] // All but last message
 	lastMsg := geminiMessages[len(geminiMessages)-1]
-	config := &genai.GenerateContentConfig{
+	generateConfig := &genai.GenerateContentConfig{
 		MaxOutputTokens: int32(maxTokens),
 		SystemInstruction: &genai.Content{
 			Parts: []*genai.Part{{Text: systemMessage}},
 		},
+		Tools: g.convertTools(tools),
+	}
+	if schema := g.providerOptions.responseSchema; schema != nil {
+		generateConfig.ResponseMIMEType = "application/json"
+		generateConfig.ResponseSchema = &genai.Schema{
+			Type:        genai.TypeObject,
+			Description: schema.Description,
+			Properties:  convertSchemaProperties(schema.Properties),
+			Required:    schema.Required,
+		}
+	}
+	if g.isThinkingEnabled() {
+		generateConfig.ThinkingConfig = &genai.ThinkingConfig{IncludeThoughts: true}
+		if modelConfig.ThinkingBudget > 0 {
+			generateConfig.ThinkingConfig.ThinkingBudget = genai.Ptr(int32(modelConfig.ThinkingBudget))
+		}
+	}
+	chat, err := g.client.Chats.Create(ctx, model.ID, generateConfig, history)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create chat: %w", err)
+	}
+
+	var lastMsgParts []genai.Part
+	for _, part := range lastMsg.Parts {
+		lastMsgParts = append(lastMsgParts, *part)
+	}
+	return chat, lastMsgParts, nil
+}
+
+func (g *geminiClient) toolCall(call *genai.FunctionCall) message.ToolCall {
+	id := call.ID
+	if id == "" {
+		id = "call_" + uuid.New().String()
+	}
+	args, _ := json.Marshal(call.Args)
+	return message.ToolCall{
+		ID:       id,
+		Name:     call.Name,
+		Input:    string(args),
+		Type:     "function",
+		Finished: true,
+	}
+}
+
+func (g *geminiClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	chat, lastMsgParts, err := g.preparedChat(ctx, messages, tools)
+	if err != nil {
+		return nil, err
 	}
-	config.Tools = g.convertTools(tools)
-	chat, _ := g.client.Chats.Create(ctx, model.ID, config, history)
 
 	attempts := 0
 	for {
 		attempts++
+		if err := g.providerOptions.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
 		var toolCalls []message.ToolCall
 
-		var lastMsgParts []genai.Part
-		for _, part := range lastMsg.Parts {
-			lastMsgParts = append(lastMsgParts, *part)
-		}
 		resp, err := chat.SendMessage(ctx, lastMsgParts...)
 		// If there is an error we are going to see if we can retry the call
 		if err != nil {
@@ -223,23 +293,16 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 			return nil, retryErr
 		}
 
-		content := ""
-
+		var content strings.Builder
 		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
 			for _, part := range resp.Candidates[0].Content.Parts {
 				switch {
+				case part.Thought:
+					// Thought summaries aren't part of the response.
 				case part.Text != "":
-					content = string(part.Text)
+					content.WriteString(part.Text)
 				case part.FunctionCall != nil:
-					id := "call_" + uuid.New().String()
-					args, _ := json.Marshal(part.FunctionCall.Args)
-					toolCalls = append(toolCalls, message.ToolCall{
-						ID:       id,
-						Name:     part.FunctionCall.Name,
-						Input:    string(args),
-						Type:     "function",
-						Finished: true,
-					})
+					toolCalls = append(toolCalls, g.toolCall(part.FunctionCall))
 				}
 			}
 		}
@@ -252,7 +315,7 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 		}
 
 		return &ProviderResponse{
-			Content:      content,
+			Content:      content.String(),
 			ToolCalls:    toolCalls,
 			Usage:        g.usage(resp),
 			FinishReason: finishReason,
@@ -261,87 +324,36 @@ func (g *geminiClient) send(ctx context.Context, messages []message.Message, too
 }
 
 func (g *geminiClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
-	// Convert messages
-	geminiMessages := g.convertMessages(messages)
-
-	model := g.providerOptions.model(g.providerOptions.modelType)
-	cfg := config.Get()
-	if cfg.Options.Debug {
-		jsonData, _ := json.Marshal(geminiMessages)
-		slog.Debug("Prepared messages", "messages", string(jsonData))
-	}
-
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if g.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
-	maxTokens := model.DefaultMaxTokens
-	if modelConfig.MaxTokens > 0 {
-		maxTokens = modelConfig.MaxTokens
-	}
-
-	// Override max tokens if set in provider options
-	if g.providerOptions.maxTokens > 0 {
-		maxTokens = g.providerOptions.maxTokens
-	}
-	systemMessage := g.providerOptions.systemMessage
-	if g.providerOptions.systemPromptPrefix != "" {
-		systemMessage = g.providerOptions.systemPromptPrefix + "\n" + systemMessage
-	}
-	history := geminiMessages[:len(geminiMessages)-1] // All but last message
-	lastMsg := geminiMessages[len(geminiMessages)-1]
-	config := &genai.GenerateContentConfig{
-		MaxOutputTokens: int32(maxTokens),
-		SystemInstruction: &genai.Content{
-			Parts: []*genai.Part{{Text: systemMessage}},
-		},
-	}
-	config.Tools = g.convertTools(tools)
-	chat, _ := g.client.Chats.Create(ctx, model.ID, config, history)
-
-	attempts := 0
 	eventChan := make(chan ProviderEvent)
 
 	go func() {
 		defer close(eventChan)
 
+		chat, lastMsgParts, err := g.preparedChat(ctx, messages, tools)
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+
+		attempts := 0
 		for {
 			attempts++
+			if err := g.providerOptions.limiter.wait(ctx); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
 
 			currentContent := ""
 			toolCalls := []message.ToolCall{}
 			var finalResp *genai.GenerateContentResponse
+			var streamErr error
 
 			eventChan <- ProviderEvent{Type: EventContentStart}
 
-			var lastMsgParts []genai.Part
-
-			for _, part := range lastMsg.Parts {
-				lastMsgParts = append(lastMsgParts, *part)
-			}
 			for resp, err := range chat.SendMessageStream(ctx, lastMsgParts...) {
 				if err != nil {
-					retry, after, retryErr := g.shouldRetry(attempts, err)
-					if retryErr != nil {
-						eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
-						return
-					}
-					if retry {
-						slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
-						select {
-						case <-ctx.Done():
-							if ctx.Err() != nil {
-								eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
-							}
-
-							return
-						case <-time.After(time.Duration(after) * time.Millisecond):
-							break
-						}
-					} else {
-						eventChan <- ProviderEvent{Type: EventError, Error: err}
-						return
-					}
+					streamErr = err
+					break
 				}
 
 				finalResp = resp
@@ -349,25 +361,19 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 				if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
 					for _, part := range resp.Candidates[0].Content.Parts {
 						switch {
+						case part.Thought && part.Text != "":
+							eventChan <- ProviderEvent{
+								Type:     EventThinkingDelta,
+								Thinking: part.Text,
+							}
 						case part.Text != "":
-							delta := string(part.Text)
-							if delta != "" {
-								eventChan <- ProviderEvent{
-									Type:    EventContentDelta,
-									Content: delta,
-								}
-								currentContent += delta
+							eventChan <- ProviderEvent{
+								Type:    EventContentDelta,
+								Content: part.Text,
 							}
+							currentContent += part.Text
 						case part.FunctionCall != nil:
-							id := "call_" + uuid.New().String()
-							args, _ := json.Marshal(part.FunctionCall.Args)
-							newCall := message.ToolCall{
-								ID:       id,
-								Name:     part.FunctionCall.Name,
-								Input:    string(args),
-								Type:     "function",
-								Finished: true,
-							}
+							newCall := g.toolCall(part.FunctionCall)
 
 							isNew := true
 							for _, existing := range toolCalls {
@@ -385,27 +391,45 @@ func (g *geminiClient) stream(ctx context.Context, messages []message.Message, t
 				}
 			}
 
-			eventChan <- ProviderEvent{Type: EventContentStop}
-
-			if finalResp != nil {
-				finishReason := message.FinishReasonEndTurn
-				if len(finalResp.Candidates) > 0 {
-					finishReason = g.finishReason(finalResp.Candidates[0].FinishReason)
+			if streamErr != nil {
+				retry, after, retryErr := g.shouldRetry(attempts, streamErr)
+				if retryErr != nil {
+					eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+					return
 				}
-				if len(toolCalls) > 0 {
-					finishReason = message.FinishReasonToolUse
+				if !retry {
+					eventChan <- ProviderEvent{Type: EventError, Error: streamErr}
+					return
 				}
-				eventChan <- ProviderEvent{
-					Type: EventComplete,
-					Response: &ProviderResponse{
-						Content:      currentContent,
-						ToolCalls:    toolCalls,
-						Usage:        g.usage(finalResp),
-						FinishReason: finishReason,
-					},
+				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
+				select {
+				case <-ctx.Done():
+					eventChan <- ProviderEvent{Type: EventError, Error: ctx.Err()}
+					return
+				case <-time.After(time.Duration(after) * time.Millisecond):
+					continue
 				}
-				return
 			}
+
+			eventChan <- ProviderEvent{Type: EventContentStop}
+
+			finishReason := message.FinishReasonEndTurn
+			if finalResp != nil && len(finalResp.Candidates) > 0 {
+				finishReason = g.finishReason(finalResp.Candidates[0].FinishReason)
+			}
+			if len(toolCalls) > 0 {
+				finishReason = message.FinishReasonToolUse
+			}
+			eventChan <- ProviderEvent{
+				Type: EventComplete,
+				Response: &ProviderResponse{
+					Content:      currentContent,
+					ToolCalls:    toolCalls,
+					Usage:        g.usage(finalResp),
+					FinishReason: finishReason,
+				},
+			}
+			return
 		}
 	}()
 
@@ -428,7 +452,8 @@ func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 	isRateLimit := contains(errMsg, "rate limit", "quota exceeded", "too many requests")
 
 	// Check for token expiration (401 Unauthorized)
-	if contains(errMsg, "unauthorized", "invalid api key", "api key expired") {
+	// Vertex AI credentials refresh on their own.
+	if g.providerOptions.config.Type != catwalk.TypeVertexAI && contains(errMsg, "unauthorized", "invalid api key", "api key expired") {
 		g.providerOptions.apiKey, err = config.Get().Resolve(g.providerOptions.config.APIKey)
 		if err != nil {
 			return false, 0, fmt.Errorf("failed to resolve API key: %w", err)
@@ -446,12 +471,7 @@ func (g *geminiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 		return false, 0, err
 	}
 
-	// Calculate backoff with jitter
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs := backoffMs + jitterMs
-
-	return true, int64(retryMs), nil
+	return true, g.providerOptions.limiter.retryDelay(attempts, nil), nil
 }
 
 func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
@@ -460,10 +480,12 @@ func (g *geminiClient) usage(resp *genai.GenerateContentResponse) TokenUsage {
 	}
 
 	return TokenUsage{
-		InputTokens:         int64(resp.UsageMetadata.PromptTokenCount),
-		OutputTokens:        int64(resp.UsageMetadata.CandidatesTokenCount),
+		InputTokens: int64(resp.UsageMetadata.PromptTokenCount),
+		// Thinking tokens are billed as output tokens.
+		OutputTokens:        int64(resp.UsageMetadata.CandidatesTokenCount + resp.UsageMetadata.ThoughtsTokenCount),
 		CacheCreationTokens: 0, // Not directly provided by Gemini
 		CacheReadTokens:     int64(resp.UsageMetadata.CachedContentTokenCount),
+		ReasoningTokens:     int64(resp.UsageMetadata.ThoughtsTokenCount),
 	}
 }
 
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestGeminiClientConvertMessages(t *testing.T) {
+	client := &geminiClient{}
+	contents := client.convertMessages([]message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Read both files"}}},
+		{Role: message.Assistant, Parts: []message.ContentPart{
+			message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"a.go"}`, Finished: true},
+			message.ToolCall{ID: "call_2", Name: "ls", Input: `{"path":"."}`, Finished: true},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call_1", Content: "package a"},
+			message.ToolResult{ToolCallID: "call_2", Content: "permission denied", IsError: true},
+		}},
+	})
+
+	require.Len(t, contents, 3)
+	require.Equal(t, genai.RoleModel, contents[1].Role)
+	require.Equal(t, map[string]any{"file_path": "a.go"}, contents[1].Parts[0].FunctionCall.Args)
+
+	// The responses to parallel calls are sent in a single content.
+	require.Equal(t, genai.RoleUser, contents[2].Role)
+	require.Len(t, contents[2].Parts, 2)
+	require.Equal(t, &genai.FunctionResponse{
+		Name:     "view",
+		Response: map[string]any{"output": "package a"},
+	}, contents[2].Parts[0].FunctionResponse)
+	require.Equal(t, &genai.FunctionResponse{
+		Name:     "ls",
+		Response: map[string]any{"error": "permission denied"},
+	}, contents[2].Parts[1].FunctionResponse)
+}
+
+func TestGeminiClientUsage(t *testing.T) {
+	client := &geminiClient{}
+	usage := client.usage(&genai.GenerateContentResponse{
+		UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+			PromptTokenCount:        1000,
+			CandidatesTokenCount:    200,
+			ThoughtsTokenCount:      300,
+			CachedContentTokenCount: 400,
+		},
+	})
+	require.Equal(t, TokenUsage{InputTokens: 1000, OutputTokens: 500, CacheReadTokens: 400, ReasoningTokens: 300}, usage)
+}
+
+func TestGeminiHTTPOptions(t *testing.T) {
+	httpOptions := geminiHTTPOptions(providerClientOptions{
+		baseURL:      "https://gateway.example.com/gemini",
+		extraHeaders: map[string]string{"x-goog-user-project": "my-project"},
+	})
+	require.Equal(t, "https://gateway.example.com/gemini", httpOptions.BaseURL)
+	require.Equal(t, "my-project", httpOptions.Headers.Get("X-Goog-User-Project"))
+}
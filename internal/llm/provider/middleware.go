@@ -0,0 +1,295 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/google/uuid"
+)
+
+// Middleware wraps the transport of the requests to a provider.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// MiddlewareFactory creates a middleware of the provider from its options in
+// the config. The values of the options are resolved with the resolver, when
+// created or for each request.
+type MiddlewareFactory func(cfg config.ProviderConfig, options map[string]any, resolver config.VariableResolver) (Middleware, error)
+
+// roundTripperFunc is a function used as a transport.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var middlewareFactories = csync.NewMap[string, MiddlewareFactory]()
+
+// builtinMiddleware are the middleware available without registering them.
+var builtinMiddleware = map[string]MiddlewareFactory{
+	"log":        newLogMiddleware,
+	"retry":      newRetryMiddleware,
+	"headers":    newHeadersMiddleware,
+	"request_id": newRequestIDMiddleware,
+	"hmac":       newHMACMiddleware,
+}
+
+// RegisterMiddleware makes the middleware usable by name in the config. It
+// takes precedence over the built-in middleware of the name.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareFactories.Set(name, factory)
+}
+
+// decodeMiddlewareOptions decodes the options of a middleware into v, failing
+// on unknown options.
+func decodeMiddlewareOptions(options map[string]any, v any) error {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// NewHTTPClient returns the client of the requests to the provider, through
+// its proxy, with its TLS settings, and wrapped by its middleware. It returns
+// nil without any of them, for the default client of the SDKs to be used.
+// The factories registered with Register use it for their requests to go
+// through the same.
+func NewHTTPClient(cfg config.ProviderConfig) (*http.Client, error) {
+	client, err := config.Get().HTTPClientFor(cfg, 0)
+	if err != nil {
+		return nil, err
+	}
+	return withMiddleware(client, cfg, config.Get().MiddlewareFor(cfg), config.Get().Resolver())
+}
+
+// withMiddleware returns the client with its transport wrapped by the
+// middleware, the first one wrapping the others. The client is returned as
+// is without middleware.
+func withMiddleware(client *http.Client, cfg config.ProviderConfig, middleware []config.Middleware, resolver config.VariableResolver) (*http.Client, error) {
+	if len(middleware) == 0 {
+		return client, nil
+	}
+	wrapped := &http.Client{}
+	if client != nil {
+		*wrapped = *client
+	}
+	transport := wrapped.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for _, m := range slices.Backward(middleware) {
+		factory, ok := middlewareFactories.Get(m.Name)
+		if !ok {
+			factory, ok = builtinMiddleware[m.Name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware %q", m.Name)
+		}
+		mw, err := factory(cfg, m.Options, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %w", m.Name, err)
+		}
+		transport = mw(transport)
+	}
+	wrapped.Transport = transport
+	return wrapped, nil
+}
+
+// newLogMiddleware logs the requests with their status and duration. The
+// query of the URLs is left out, it may hold keys.
+func newLogMiddleware(cfg config.ProviderConfig, options map[string]any, _ config.VariableResolver) (Middleware, error) {
+	if err := decodeMiddlewareOptions(options, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			attrs := []any{
+				"provider", cfg.ID,
+				"method", req.Method,
+				"url", req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+				"duration", time.Since(start),
+			}
+			if id := req.Header.Get("X-Request-ID"); id != "" {
+				attrs = append(attrs, "request_id", id)
+			}
+			if err != nil {
+				slog.Warn("Provider request failed", append(attrs, "error", err)...)
+				return nil, err
+			}
+			slog.Info("Provider request", append(attrs, "status", resp.StatusCode)...)
+			return resp, nil
+		})
+	}, nil
+}
+
+type retryOptions struct {
+	MaxRetries int    `json:"max_retries"`
+	Statuses   []int  `json:"statuses"`
+	Backoff    string `json:"backoff"`
+}
+
+// newRetryMiddleware retries the requests failing with the statuses the
+// providers don't retry themselves, as the ones of gateways, after the delay
+// the response asks for or with an exponential backoff.
+func newRetryMiddleware(_ config.ProviderConfig, options map[string]any, _ config.VariableResolver) (Middleware, error) {
+	opts := retryOptions{
+		MaxRetries: 3,
+		Statuses:   []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		Backoff:    "1s",
+	}
+	if err := decodeMiddlewareOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	backoff, err := time.ParseDuration(opts.Backoff)
+	if err != nil || backoff <= 0 {
+		return nil, fmt.Errorf("invalid backoff %q", opts.Backoff)
+	}
+	if opts.MaxRetries < 0 {
+		return nil, fmt.Errorf("invalid max_retries %d", opts.MaxRetries)
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for attempt := 0; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+				// The body can only be sent again when it can be read again.
+				if err != nil || attempt == opts.MaxRetries || !slices.Contains(opts.Statuses, resp.StatusCode) || req.Body != nil && req.GetBody == nil {
+					return resp, err
+				}
+				delay, ok := retryAfter(resp.Header, time.Now())
+				if !ok {
+					delay = backoff << attempt
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				slog.Warn("Retrying provider request", "status", resp.StatusCode, "attempt", attempt+1, "delay", delay)
+				select {
+				case <-time.After(delay):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+			}
+		})
+	}, nil
+}
+
+// newHeadersMiddleware sets headers on the requests. Unlike the extra
+// headers of the provider, their values are resolved for each request, for
+// tokens that expire to be fetched again with $(command).
+func newHeadersMiddleware(_ config.ProviderConfig, options map[string]any, resolver config.VariableResolver) (Middleware, error) {
+	var headers map[string]string
+	if err := decodeMiddlewareOptions(options, &headers); err != nil {
+		return nil, err
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for name, value := range headers {
+				if strings.Contains(value, "$") {
+					resolved, err := resolver.ResolveValue(value)
+					if err != nil {
+						return nil, fmt.Errorf("failed to resolve header %s: %w", name, err)
+					}
+					value = resolved
+				}
+				req.Header.Set(name, value)
+			}
+			return next.RoundTrip(req)
+		})
+	}, nil
+}
+
+type requestIDOptions struct {
+	Header string `json:"header"`
+}
+
+// newRequestIDMiddleware sets a new ID on each request, unless it has one.
+func newRequestIDMiddleware(_ config.ProviderConfig, options map[string]any, _ config.VariableResolver) (Middleware, error) {
+	opts := requestIDOptions{Header: "X-Request-ID"}
+	if err := decodeMiddlewareOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(opts.Header) == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set(opts.Header, uuid.NewString())
+			}
+			return next.RoundTrip(req)
+		})
+	}, nil
+}
+
+type hmacOptions struct {
+	Secret          string `json:"secret"`
+	Header          string `json:"header"`
+	TimestampHeader string `json:"timestamp_header"`
+}
+
+// newHMACMiddleware signs the requests with HMAC-SHA256. The signature, in
+// hex, is of the Unix time of the request, its method, its path, and the
+// SHA-256 in hex of its body, separated by newlines.
+func newHMACMiddleware(_ config.ProviderConfig, options map[string]any, resolver config.VariableResolver) (Middleware, error) {
+	opts := hmacOptions{Header: "X-Signature", TimestampHeader: "X-Timestamp"}
+	if err := decodeMiddlewareOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	secret, err := resolver.ResolveValue(opts.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret: %w", err)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("secret is required")
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			var body []byte
+			if req.Body != nil {
+				var err error
+				if body, err = io.ReadAll(req.Body); err != nil {
+					return nil, err
+				}
+				req.Body.Close()
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(body)), nil
+				}
+			}
+			bodyHash := sha256.Sum256(body)
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(secret))
+			fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, req.Method, req.URL.Path, hex.EncodeToString(bodyHash[:]))
+			req.Header.Set(opts.TimestampHeader, timestamp)
+			req.Header.Set(opts.Header, hex.EncodeToString(mac.Sum(nil)))
+			return next.RoundTrip(req)
+		})
+	}, nil
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, middleware ...config.Middleware) *http.Client {
+	t.Helper()
+	resolver := config.NewEnvironmentVariableResolver(env.NewFromMap(map[string]string{
+		"GATEWAY_SECRET": "s3cret",
+		"GATEWAY_TOKEN":  "Bearer token-1",
+	}))
+	client, err := withMiddleware(nil, config.ProviderConfig{ID: "gateway"}, middleware, resolver)
+	require.NoError(t, err)
+	return client
+}
+
+func TestWithMiddleware_Order(t *testing.T) {
+	var order []string
+	RegisterMiddleware("test_trace", func(_ config.ProviderConfig, options map[string]any, _ config.VariableResolver) (Middleware, error) {
+		var opts struct {
+			Name string `json:"name"`
+		}
+		if err := decodeMiddlewareOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, opts.Name)
+				return next.RoundTrip(req)
+			})
+		}, nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "server")
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t,
+		config.Middleware{Name: "test_trace", Options: map[string]any{"name": "first"}},
+		config.Middleware{Name: "test_trace", Options: map[string]any{"name": "second"}},
+	)
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, []string{"first", "second", "server"}, order)
+}
+
+func TestWithMiddleware_Invalid(t *testing.T) {
+	resolver := config.NewEnvironmentVariableResolver(env.NewFromMap(nil))
+	_, err := withMiddleware(nil, config.ProviderConfig{}, []config.Middleware{{Name: "missing"}}, resolver)
+	require.ErrorContains(t, err, `unknown middleware "missing"`)
+
+	_, err = withMiddleware(nil, config.ProviderConfig{}, []config.Middleware{{Name: "retry", Options: map[string]any{"max_retry": 2}}}, resolver)
+	require.ErrorContains(t, err, `middleware retry: invalid options`)
+
+	client := &http.Client{}
+	wrapped, err := withMiddleware(client, config.ProviderConfig{}, nil, resolver)
+	require.NoError(t, err)
+	require.Same(t, client, wrapped, "the client is kept without middleware")
+}
+
+func TestRetryMiddleware(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, `{"model":"gpt-4o"}`, string(body), "the body is sent again")
+		if attempts.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, config.Middleware{Name: "retry", Options: map[string]any{"backoff": "1ms"}})
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.EqualValues(t, 3, attempts.Load())
+
+	attempts.Store(-10)
+	client = newTestClient(t, config.Middleware{Name: "retry", Options: map[string]any{"backoff": "1ms", "max_retries": 1}})
+	resp, err = client.Post(srv.URL, "application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode, "the last response is returned once out of retries")
+	require.EqualValues(t, -8, attempts.Load())
+}
+
+func TestHeadersAndRequestIDMiddleware(t *testing.T) {
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t,
+		config.Middleware{Name: "request_id"},
+		config.Middleware{Name: "headers", Options: map[string]any{"Authorization": "$GATEWAY_TOKEN", "X-Team": "search"}},
+	)
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "Bearer token-1", headers.Get("Authorization"))
+	require.Equal(t, "search", headers.Get("X-Team"))
+	id := headers.Get("X-Request-ID")
+	require.Len(t, id, 36)
+
+	resp, err = client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NotEqual(t, id, headers.Get("X-Request-ID"), "each request has its own ID")
+}
+
+func TestHMACMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodyHash := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		fmt.Fprintf(mac, "%s\n%s\n%s\n%s", r.Header.Get("X-Timestamp"), r.Method, r.URL.Path, hex.EncodeToString(bodyHash[:]))
+		if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(r.Header.Get("X-Signature"))) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, config.Middleware{Name: "hmac", Options: map[string]any{"secret": "$GATEWAY_SECRET"}})
+	resp, err := client.Post(srv.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"gpt-4o"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = withMiddleware(nil, config.ProviderConfig{}, []config.Middleware{{Name: "hmac"}}, config.NewEnvironmentVariableResolver(env.NewFromMap(nil)))
+	require.ErrorContains(t, err, "secret is required")
+}
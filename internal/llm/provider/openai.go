@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 
@@ -40,10 +41,7 @@ func createOpenAIClient(opts providerClientOptions) openai.Client {
 		openaiClientOptions = append(openaiClientOptions, option.WithAPIKey(opts.apiKey))
 	}
 	if opts.baseURL != "" {
-		resolvedBaseURL, err := config.Get().Resolve(opts.baseURL)
-		if err == nil {
-			openaiClientOptions = append(openaiClientOptions, option.WithBaseURL(resolvedBaseURL))
-		}
+		openaiClientOptions = append(openaiClientOptions, option.WithBaseURL(opts.baseURL))
 	}
 
 	for key, value := range opts.extraHeaders {
@@ -53,6 +51,9 @@ func createOpenAIClient(opts providerClientOptions) openai.Client {
 	for extraKey, extraValue := range opts.extraBody {
 		openaiClientOptions = append(openaiClientOptions, option.WithJSONSet(extraKey, extraValue))
 	}
+	if opts.httpClient != nil {
+		openaiClientOptions = append(openaiClientOptions, option.WithHTTPClient(opts.httpClient))
+	}
 
 	return openai.NewClient(openaiClientOptions...)
 }
@@ -207,10 +208,7 @@ func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessagePar
 	model := o.providerOptions.model(o.providerOptions.modelType)
 	cfg := config.Get()
 
-	modelConfig := cfg.Models[config.SelectedModelTypeLarge]
-	if o.providerOptions.modelType == config.SelectedModelTypeSmall {
-		modelConfig = cfg.Models[config.SelectedModelTypeSmall]
-	}
+	modelConfig := cfg.Models[o.providerOptions.modelType]
 
 	reasoningEffort := modelConfig.ReasoningEffort
 
@@ -243,6 +241,22 @@ func (o *openaiClient) preparedParams(messages []openai.ChatCompletionMessagePar
 		}
 	} else {
 		params.MaxTokens = openai.Int(maxTokens)
+		if modelConfig.Temperature != nil {
+			params.Temperature = openai.Float(*modelConfig.Temperature)
+		}
+	}
+
+	if schema := o.providerOptions.responseSchema; schema != nil {
+		params.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:        schema.Name,
+					Description: openai.String(schema.Description),
+					Schema:      schema.jsonSchema(),
+					Strict:      openai.Bool(true),
+				},
+			},
+		}
 	}
 
 	return params
@@ -258,6 +272,9 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 	attempts := 0
 	for {
 		attempts++
+		if err := o.providerOptions.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
 		openaiResponse, err := o.client.Chat.Completions.New(
 			ctx,
 			params,
@@ -284,10 +301,7 @@ func (o *openaiClient) send(ctx context.Context, messages []message.Message, too
 			return nil, fmt.Errorf("received empty response from OpenAI API - check endpoint configuration")
 		}
 
-		content := ""
-		if openaiResponse.Choices[0].Message.Content != "" {
-			content = openaiResponse.Choices[0].Message.Content
-		}
+		_, content := splitThinking(openaiResponse.Choices[0].Message.Content)
 
 		toolCalls := o.toolCalls(*openaiResponse)
 		finishReason := o.finishReason(string(openaiResponse.Choices[0].FinishReason))
@@ -323,6 +337,11 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 	go func() {
 		for {
 			attempts++
+			if err := o.providerOptions.limiter.wait(ctx); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				close(eventChan)
+				return
+			}
 			// Kujtim: fixes an issue with anthropig models on openrouter
 			if len(params.Tools) == 0 {
 				params.Tools = nil
@@ -335,6 +354,7 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 			acc := openai.ChatCompletionAccumulator{}
 			currentContent := ""
 			toolCalls := make([]message.ToolCall, 0)
+			var think thinkSplitter
 
 			var currentToolCallID string
 			var currentToolCall openai.ChatCompletionMessageToolCall
@@ -350,12 +370,16 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 				acc.AddChunk(chunk)
 				// This fixes multiple tool calls for some providers
 				for _, choice := range chunk.Choices {
-					if choice.Delta.Content != "" {
+					if reasoning := deltaReasoning(choice.Delta); reasoning != "" {
 						eventChan <- ProviderEvent{
-							Type:    EventContentDelta,
-							Content: choice.Delta.Content,
+							Type:     EventThinkingDelta,
+							Thinking: reasoning,
 						}
-						currentContent += choice.Delta.Content
+					}
+					if choice.Delta.Content != "" {
+						thinking, content := think.split(choice.Delta.Content)
+						o.sendContent(eventChan, thinking, content)
+						currentContent += content
 					} else if len(choice.Delta.ToolCalls) > 0 {
 						toolCall := choice.Delta.ToolCalls[0]
 						// Detect tool use start
@@ -420,6 +444,9 @@ func (o *openaiClient) stream(ctx context.Context, messages []message.Message, t
 
 			err := openaiStream.Err()
 			if err == nil || errors.Is(err, io.EOF) {
+				thinking, content := think.flush()
+				o.sendContent(eventChan, thinking, content)
+				currentContent += content
 				if cfg.Options.Debug {
 					jsonData, _ := json.Marshal(acc.ChatCompletion)
 					slog.Debug("Response", "messages", string(jsonData))
@@ -499,7 +526,6 @@ func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 		return false, 0, err
 	}
 	var apiErr *openai.Error
-	retryMs := 0
 	retryAfterValues := []string{}
 	if errors.As(err, &apiErr) {
 		// Check for token expiration (401 Unauthorized)
@@ -528,15 +554,11 @@ func (o *openaiClient) shouldRetry(attempts int, err error) (bool, int64, error)
 		slog.Warn("OpenAI API error", "error", err.Error())
 	}
 
-	backoffMs := 2000 * (1 << (attempts - 1))
-	jitterMs := int(float64(backoffMs) * 0.2)
-	retryMs = backoffMs + jitterMs
-	if len(retryAfterValues) > 0 {
-		if _, err := fmt.Sscanf(retryAfterValues[0], "%d", &retryMs); err == nil {
-			retryMs = retryMs * 1000
-		}
+	var header http.Header
+	if apiErr != nil && apiErr.Response != nil {
+		header = apiErr.Response.Header
 	}
-	return true, int64(retryMs), nil
+	return true, o.providerOptions.limiter.retryDelay(attempts, header), nil
 }
 
 func (o *openaiClient) toolCalls(completion openai.ChatCompletion) []message.ToolCall {
@@ -558,6 +580,22 @@ func (o *openaiClient) toolCalls(completion openai.ChatCompletion) []message.Too
 	return toolCalls
 }
 
+// sendContent sends the deltas of the thinking and the content of a chunk.
+func (o *openaiClient) sendContent(eventChan chan<- ProviderEvent, thinking, content string) {
+	if thinking != "" {
+		eventChan <- ProviderEvent{
+			Type:     EventThinkingDelta,
+			Thinking: thinking,
+		}
+	}
+	if content != "" {
+		eventChan <- ProviderEvent{
+			Type:    EventContentDelta,
+			Content: content,
+		}
+	}
+}
+
 func (o *openaiClient) usage(completion openai.ChatCompletion) TokenUsage {
 	cachedTokens := completion.Usage.PromptTokensDetails.CachedTokens
 	inputTokens := completion.Usage.PromptTokens - cachedTokens
@@ -567,6 +605,7 @@ func (o *openaiClient) usage(completion openai.ChatCompletion) TokenUsage {
 		OutputTokens:        completion.Usage.CompletionTokens,
 		CacheCreationTokens: 0, // OpenAI doesn't provide this directly
 		CacheReadTokens:     cachedTokens,
+		ReasoningTokens:     completion.Usage.CompletionTokensDetails.ReasoningTokens,
 	}
 }
 
@@ -0,0 +1,354 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"github.com/openai/openai-go/shared"
+)
+
+// openaiResponsesClient sends the requests to the Responses API rather than
+// to Chat Completions. Nothing is stored by OpenAI: the reasoning of the
+// models is sent back encrypted with the following requests instead.
+type openaiResponsesClient struct {
+	*openaiClient
+}
+
+func newOpenAIResponsesClient(opts providerClientOptions) OpenAIClient {
+	return &openaiResponsesClient{
+		openaiClient: &openaiClient{
+			providerOptions: opts,
+			client:          createOpenAIClient(opts),
+		},
+	}
+}
+
+func (o *openaiResponsesClient) convertMessages(messages []message.Message) (instructions string, input responses.ResponseInputParam) {
+	instructions = o.providerOptions.systemMessage
+	if o.providerOptions.systemPromptPrefix != "" {
+		instructions = o.providerOptions.systemPromptPrefix + "\n" + instructions
+	}
+
+	model := o.Model()
+	for _, msg := range messages {
+		switch msg.Role {
+		case message.User:
+			content := responses.ResponseInputMessageContentListParam{
+				responses.ResponseInputContentParamOfInputText(msg.Content().String()),
+			}
+			for _, binaryContent := range msg.BinaryContent() {
+				content = append(content, responses.ResponseInputContentUnionParam{
+					OfInputImage: &responses.ResponseInputImageParam{
+						Detail:   responses.ResponseInputImageDetailAuto,
+						ImageURL: openai.String(binaryContent.String(catwalk.InferenceProviderOpenAI)),
+					},
+				})
+			}
+			input = append(input, responses.ResponseInputItemParamOfMessage(content, responses.EasyInputMessageRoleUser))
+
+		case message.Assistant:
+			// The reasoning is encrypted for the model that reasoned.
+			if msg.Provider == o.providerOptions.config.ID && msg.Model == model.ID {
+				for _, reasoning := range msg.ReasoningContent().Encrypted {
+					input = append(input, responses.ResponseInputItemUnionParam{
+						OfReasoning: &responses.ResponseReasoningItemParam{
+							ID:               reasoning.ID,
+							Summary:          []responses.ResponseReasoningItemSummaryParam{},
+							EncryptedContent: openai.String(reasoning.Content),
+						},
+					})
+				}
+			}
+			if text := msg.Content().String(); text != "" {
+				input = append(input, responses.ResponseInputItemParamOfMessage(text, responses.EasyInputMessageRoleAssistant))
+			}
+			for _, call := range msg.ToolCalls() {
+				input = append(input, responses.ResponseInputItemParamOfFunctionCall(call.Input, call.ID, call.Name))
+			}
+
+		case message.Tool:
+			for _, result := range msg.ToolResults() {
+				input = append(input, responses.ResponseInputItemParamOfFunctionCallOutput(result.ToolCallID, result.Content))
+			}
+		}
+	}
+	return instructions, input
+}
+
+func (o *openaiResponsesClient) convertTools(tools []tools.BaseTool) []responses.ToolUnionParam {
+	responsesTools := make([]responses.ToolUnionParam, len(tools))
+	for i, tool := range tools {
+		info := tool.Info()
+		function := responses.ToolParamOfFunction(info.Name, map[string]any{
+			"type":       "object",
+			"properties": info.Parameters,
+			"required":   info.Required,
+		}, false)
+		function.OfFunction.Description = openai.String(info.Description)
+		responsesTools[i] = function
+	}
+	return responsesTools
+}
+
+// preparedParams returns the request. Models that can reason take neither
+// a temperature nor a max tokens that includes the reasoning, only a max
+// of output tokens.
+func (o *openaiResponsesClient) preparedParams(messages []message.Message, tools []tools.BaseTool) responses.ResponseNewParams {
+	model := o.providerOptions.model(o.providerOptions.modelType)
+	modelConfig := config.Get().Models[o.providerOptions.modelType]
+
+	instructions, input := o.convertMessages(messages)
+	params := responses.ResponseNewParams{
+		Model:        model.ID,
+		Instructions: openai.String(instructions),
+		Input:        responses.ResponseNewParamsInputUnion{OfInputItemList: input},
+		Tools:        o.convertTools(tools),
+		Store:        openai.Bool(false),
+	}
+
+	maxTokens := model.DefaultMaxTokens
+	if modelConfig.MaxTokens > 0 {
+		maxTokens = modelConfig.MaxTokens
+	}
+	if o.providerOptions.maxTokens > 0 {
+		maxTokens = o.providerOptions.maxTokens
+	}
+	if maxTokens > 0 {
+		params.MaxOutputTokens = openai.Int(maxTokens)
+	}
+
+	if model.CanReason {
+		params.Reasoning = shared.ReasoningParam{
+			Effort:  shared.ReasoningEffort(modelConfig.ReasoningEffort),
+			Summary: shared.ReasoningSummary(modelConfig.ReasoningSummary),
+		}
+		params.Include = []responses.ResponseIncludable{responses.ResponseIncludableReasoningEncryptedContent}
+	} else if modelConfig.Temperature != nil {
+		params.Temperature = openai.Float(*modelConfig.Temperature)
+	}
+
+	if schema := o.providerOptions.responseSchema; schema != nil {
+		format := responses.ResponseFormatTextConfigParamOfJSONSchema(schema.Name, schema.jsonSchema())
+		format.OfJSONSchema.Description = openai.String(schema.Description)
+		format.OfJSONSchema.Strict = openai.Bool(true)
+		params.Text = responses.ResponseTextConfigParam{Format: format}
+	}
+	return params
+}
+
+func (o *openaiResponsesClient) send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
+	params := o.preparedParams(messages, tools)
+	cfg := config.Get()
+	if cfg.Options.Debug {
+		jsonData, _ := json.Marshal(params)
+		slog.Debug("Prepared messages", "messages", string(jsonData))
+	}
+	attempts := 0
+	for {
+		attempts++
+		if err := o.providerOptions.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := o.client.Responses.New(ctx, params)
+		if err != nil {
+			retry, after, retryErr := o.shouldRetry(attempts, err)
+			if retryErr != nil {
+				return nil, retryErr
+			}
+			if retry {
+				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(after) * time.Millisecond):
+					continue
+				}
+			}
+			return nil, retryErr
+		}
+
+		var toolCalls []message.ToolCall
+		for _, item := range resp.Output {
+			if item.Type == "function_call" {
+				toolCalls = append(toolCalls, responseToolCall(item))
+			}
+		}
+		return &ProviderResponse{
+			Content:      resp.OutputText(),
+			ToolCalls:    toolCalls,
+			Usage:        responseUsage(*resp),
+			FinishReason: responseFinishReason(*resp, toolCalls),
+		}, nil
+	}
+}
+
+func (o *openaiResponsesClient) stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
+	params := o.preparedParams(messages, tools)
+	cfg := config.Get()
+	if cfg.Options.Debug {
+		jsonData, _ := json.Marshal(params)
+		slog.Debug("Prepared messages", "messages", string(jsonData))
+	}
+
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		attempts := 0
+		for {
+			attempts++
+			if err := o.providerOptions.limiter.wait(ctx); err != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: err}
+				return
+			}
+			stream := o.client.Responses.NewStreaming(ctx, params)
+
+			var content string
+			var toolCalls []message.ToolCall
+			var final *responses.Response
+			var streamErr error
+			// The argument deltas name the item of the call, not the call.
+			callIDs := map[string]string{}
+			for stream.Next() {
+				event := stream.Current()
+				switch event.Type {
+				case "response.output_text.delta":
+					content += event.Delta.OfString
+					eventChan <- ProviderEvent{Type: EventContentDelta, Content: event.Delta.OfString}
+				case "response.reasoning_summary_text.delta":
+					eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: event.Delta.OfString}
+				case "response.reasoning_summary_part.added":
+					if event.SummaryIndex > 0 {
+						eventChan <- ProviderEvent{Type: EventThinkingDelta, Thinking: "\n\n"}
+					}
+				case "response.output_item.added":
+					if event.Item.Type == "function_call" {
+						callIDs[event.Item.ID] = event.Item.CallID
+						eventChan <- ProviderEvent{
+							Type:     EventToolUseStart,
+							ToolCall: &message.ToolCall{ID: event.Item.CallID, Name: event.Item.Name, Type: "function"},
+						}
+					}
+				case "response.function_call_arguments.delta":
+					if callID, ok := callIDs[event.ItemID]; ok {
+						eventChan <- ProviderEvent{
+							Type:     EventToolUseDelta,
+							ToolCall: &message.ToolCall{ID: callID, Input: event.Delta.OfString},
+						}
+					}
+				case "response.output_item.done":
+					switch event.Item.Type {
+					case "function_call":
+						call := responseToolCall(event.Item)
+						toolCalls = append(toolCalls, call)
+						eventChan <- ProviderEvent{Type: EventToolUseStop, ToolCall: &call}
+					case "reasoning":
+						if event.Item.EncryptedContent != "" {
+							eventChan <- ProviderEvent{
+								Type:               EventEncryptedReasoning,
+								EncryptedReasoning: &message.EncryptedReasoning{ID: event.Item.ID, Content: event.Item.EncryptedContent},
+							}
+						}
+					}
+				case "response.completed", "response.incomplete":
+					final = &event.Response
+				case "response.failed":
+					streamErr = fmt.Errorf("response failed: %s", event.Response.Error.Message)
+				case "error":
+					streamErr = fmt.Errorf("response failed: %s", event.Message)
+				}
+			}
+			if streamErr != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: streamErr}
+				return
+			}
+
+			err := stream.Err()
+			if err == nil || errors.Is(err, io.EOF) {
+				if final == nil {
+					eventChan <- ProviderEvent{
+						Type:  EventError,
+						Error: fmt.Errorf("received incomplete streaming response from OpenAI Responses API - check endpoint configuration"),
+					}
+					return
+				}
+				if cfg.Options.Debug {
+					jsonData, _ := json.Marshal(final)
+					slog.Debug("Response", "messages", string(jsonData))
+				}
+				eventChan <- ProviderEvent{
+					Type: EventComplete,
+					Response: &ProviderResponse{
+						Content:      content,
+						ToolCalls:    toolCalls,
+						Usage:        responseUsage(*final),
+						FinishReason: responseFinishReason(*final, toolCalls),
+					},
+				}
+				return
+			}
+
+			retry, after, retryErr := o.shouldRetry(attempts, err)
+			if retryErr != nil {
+				eventChan <- ProviderEvent{Type: EventError, Error: retryErr}
+				return
+			}
+			if retry {
+				slog.Warn("Retrying due to rate limit", "attempt", attempts, "max_retries", maxRetries)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Duration(after) * time.Millisecond):
+					continue
+				}
+			}
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+	}()
+	return eventChan
+}
+
+func responseToolCall(item responses.ResponseOutputItemUnion) message.ToolCall {
+	return message.ToolCall{
+		ID:       item.CallID,
+		Name:     item.Name,
+		Input:    item.Arguments,
+		Type:     "function",
+		Finished: true,
+	}
+}
+
+func responseFinishReason(resp responses.Response, toolCalls []message.ToolCall) message.FinishReason {
+	if len(toolCalls) > 0 {
+		return message.FinishReasonToolUse
+	}
+	switch resp.IncompleteDetails.Reason {
+	case "":
+		return message.FinishReasonEndTurn
+	case "max_output_tokens":
+		return message.FinishReasonMaxTokens
+	default:
+		return message.FinishReasonUnknown
+	}
+}
+
+func responseUsage(resp responses.Response) TokenUsage {
+	cachedTokens := resp.Usage.InputTokensDetails.CachedTokens
+	return TokenUsage{
+		InputTokens:     resp.Usage.InputTokens - cachedTokens,
+		OutputTokens:    resp.Usage.OutputTokens,
+		CacheReadTokens: cachedTokens,
+		ReasoningTokens: resp.Usage.OutputTokensDetails.ReasoningTokens,
+	}
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponsesClient(t *testing.T, serverURL string, model catwalk.Model, selected config.SelectedModel) *openaiResponsesClient {
+	t.Helper()
+	selected.Model, selected.Provider = model.ID, "openai"
+	previous := config.Get()
+	config.Set(&config.Config{
+		Options: &config.Options{},
+		Models:  map[config.SelectedModelType]config.SelectedModel{config.SelectedModelTypeLarge: selected},
+	})
+	t.Cleanup(func() { config.Set(previous) })
+
+	return &openaiResponsesClient{openaiClient: &openaiClient{
+		providerOptions: providerClientOptions{
+			config:        config.ProviderConfig{ID: "openai"},
+			modelType:     config.SelectedModelTypeLarge,
+			systemMessage: "You are Crush.",
+			model:         func(config.SelectedModelType) catwalk.Model { return model },
+		},
+		client: openai.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(serverURL)),
+	}}
+}
+
+func writeEvents(w http.ResponseWriter, events ...map[string]any) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event["type"], data)
+	}
+}
+
+func TestOpenAIResponsesClientStream(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/responses", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &request))
+		writeEvents(w,
+			map[string]any{"type": "response.reasoning_summary_text.delta", "item_id": "rs_2", "delta": "Looking at the parser."},
+			map[string]any{"type": "response.output_item.done", "output_index": 0, "item": map[string]any{"type": "reasoning", "id": "rs_2", "encrypted_content": "gAAAA2", "summary": []any{}}},
+			map[string]any{"type": "response.output_text.delta", "item_id": "msg_1", "delta": "Let me look."},
+			map[string]any{"type": "response.output_item.added", "output_index": 2, "item": map[string]any{"type": "function_call", "id": "fc_1", "call_id": "call_2", "name": "view", "arguments": ""}},
+			map[string]any{"type": "response.function_call_arguments.delta", "item_id": "fc_1", "delta": `{"file_path":`},
+			map[string]any{"type": "response.output_item.done", "output_index": 2, "item": map[string]any{"type": "function_call", "id": "fc_1", "call_id": "call_2", "name": "view", "arguments": `{"file_path":"parser.go"}`}},
+			map[string]any{"type": "response.completed", "response": map[string]any{
+				"id":     "resp_1",
+				"status": "completed",
+				"usage":  map[string]any{"input_tokens": 120, "input_tokens_details": map[string]any{"cached_tokens": 20}, "output_tokens": 30},
+			}},
+		)
+	}))
+	defer server.Close()
+
+	temperature := 0.2
+	client := newTestResponsesClient(t, server.URL, catwalk.Model{ID: "o4-mini", CanReason: true, DefaultMaxTokens: 1000}, config.SelectedModel{
+		ReasoningEffort:  "high",
+		ReasoningSummary: "auto",
+		Temperature:      &temperature,
+	})
+
+	previous := message.Message{Role: message.Assistant, Provider: "openai", Model: "o4-mini", Parts: []message.ContentPart{
+		message.ReasoningContent{Thinking: "Reading the tests.", Encrypted: []message.EncryptedReasoning{{ID: "rs_1", Content: "gAAAA1"}}},
+		message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"parser_test.go"}`, Finished: true},
+	}}
+	messages := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Fix the parser"}}},
+		previous,
+		{Role: message.Tool, Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_1", Content: "package parser_test"}}},
+	}
+
+	var events []ProviderEvent
+	for event := range client.stream(t.Context(), messages, nil) {
+		events = append(events, event)
+	}
+
+	require.Equal(t, "o4-mini", request["model"])
+	require.Equal(t, "You are Crush.", request["instructions"])
+	require.Equal(t, false, request["store"])
+	require.EqualValues(t, 1000, request["max_output_tokens"])
+	require.NotContains(t, request, "temperature", "models that can reason don't take a temperature")
+	require.NotContains(t, request, "max_tokens")
+	require.Equal(t, map[string]any{"effort": "high", "summary": "auto"}, request["reasoning"])
+	require.Equal(t, []any{"reasoning.encrypted_content"}, request["include"])
+	input := request["input"].([]any)
+	require.Len(t, input, 4)
+	require.Equal(t, map[string]any{"type": "reasoning", "id": "rs_1", "encrypted_content": "gAAAA1", "summary": []any{}}, input[1], "the reasoning is sent back")
+	require.Equal(t, "function_call", input[2].(map[string]any)["type"])
+	require.Equal(t, "call_1", input[2].(map[string]any)["call_id"])
+	require.Equal(t, "function_call_output", input[3].(map[string]any)["type"])
+
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []EventType{EventThinkingDelta, EventEncryptedReasoning, EventContentDelta, EventToolUseStart, EventToolUseDelta, EventToolUseStop, EventComplete}, types)
+	require.Equal(t, &message.EncryptedReasoning{ID: "rs_2", Content: "gAAAA2"}, events[1].EncryptedReasoning)
+	require.Equal(t, "call_2", events[4].ToolCall.ID)
+	response := events[len(events)-1].Response
+	require.Equal(t, message.FinishReasonToolUse, response.FinishReason)
+	require.Equal(t, "Let me look.", response.Content)
+	require.Equal(t, `{"file_path":"parser.go"}`, response.ToolCalls[0].Input)
+	require.Equal(t, TokenUsage{InputTokens: 100, OutputTokens: 30, CacheReadTokens: 20}, response.Usage)
+}
+
+func TestOpenAIResponsesClientParams(t *testing.T) {
+	temperature := 0.2
+	client := newTestResponsesClient(t, "http://localhost", catwalk.Model{ID: "gpt-4.1", DefaultMaxTokens: 1000}, config.SelectedModel{Temperature: &temperature})
+
+	// The reasoning of other models can't be sent to this one.
+	msgs := []message.Message{{Role: message.Assistant, Provider: "openai", Model: "o4-mini", Parts: []message.ContentPart{
+		message.ReasoningContent{Encrypted: []message.EncryptedReasoning{{ID: "rs_1", Content: "gAAAA1"}}},
+		message.TextContent{Text: "Done."},
+	}}}
+	params := client.preparedParams(msgs, nil)
+	data, err := json.Marshal(params)
+	require.NoError(t, err)
+	var request map[string]any
+	require.NoError(t, json.Unmarshal(data, &request))
+	require.Equal(t, 0.2, request["temperature"])
+	require.NotContains(t, request, "reasoning")
+	require.NotContains(t, request, "include")
+	require.Len(t, request["input"], 1)
+}
+
+func TestOpenAIResponsesClientFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeEvents(w, map[string]any{"type": "response.failed", "response": map[string]any{
+			"id":     "resp_1",
+			"status": "failed",
+			"error":  map[string]any{"code": "server_error", "message": "The model failed"},
+		}})
+	}))
+	defer server.Close()
+	client := newTestResponsesClient(t, server.URL, catwalk.Model{ID: "gpt-4.1"}, config.SelectedModel{})
+
+	var last ProviderEvent
+	for event := range client.stream(t.Context(), []message.Message{{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Hi"}}}}, nil) {
+		last = event
+	}
+	require.Equal(t, EventError, last.Type)
+	require.EqualError(t, last.Error, "response failed: The model failed")
+}
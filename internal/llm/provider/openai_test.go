@@ -17,12 +17,19 @@ import (
 )
 
 func TestMain(m *testing.M) {
-	_, err := config.Init(".", true)
+	// The data directory, with the logs, is in the working directory.
+	dir, err := os.MkdirTemp("", "crush-provider-test")
 	if err != nil {
+		panic("Failed to create working directory: " + err.Error())
+	}
+	if _, err := config.Init(dir, "", true); err != nil {
+		os.RemoveAll(dir)
 		panic("Failed to initialize config: " + err.Error())
 	}
 
-	os.Exit(m.Run())
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
 }
 
 func TestOpenAIClientStreamChoices(t *testing.T) {
@@ -3,9 +3,11 @@ package provider
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
 )
@@ -26,6 +28,9 @@ const (
 	EventComplete       EventType = "complete"
 	EventError          EventType = "error"
 	EventWarning        EventType = "warning"
+
+	// EventEncryptedReasoning carries a reasoning item of the Responses API.
+	EventEncryptedReasoning EventType = "encrypted_reasoning"
 )
 
 type TokenUsage struct {
@@ -33,6 +38,9 @@ type TokenUsage struct {
 	OutputTokens        int64
 	CacheCreationTokens int64
 	CacheReadTokens     int64
+	// ReasoningTokens are the part of the output tokens the model spent on
+	// reasoning.
+	ReasoningTokens int64
 }
 
 type ProviderResponse struct {
@@ -51,6 +59,8 @@ type ProviderEvent struct {
 	Response  *ProviderResponse
 	ToolCall  *message.ToolCall
 	Error     error
+
+	EncryptedReasoning *message.EncryptedReasoning
 }
 type Provider interface {
 	SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
@@ -73,10 +83,28 @@ type providerClientOptions struct {
 	extraHeaders       map[string]string
 	extraBody          map[string]any
 	extraParams        map[string]string
+	limiter            *rateLimiter
+	// httpClient goes through the proxy of the provider, uses its TLS
+	// settings, and is wrapped by its middleware, nil without any of them.
+	httpClient *http.Client
+	// responseSchema constrains the responses to JSON, see ResponseSchema.
+	responseSchema *ResponseSchema
 }
 
 type ProviderClientOption func(*providerClientOptions)
 
+// Factory creates a provider for the model type, for provider types
+// implemented outside of this package.
+type Factory func(cfg config.ProviderConfig, modelType config.SelectedModelType) (Provider, error)
+
+var factories = csync.NewMap[catwalk.Type, Factory]()
+
+// Register makes NewProvider use the factory for the providers of the type.
+// It takes precedence over the built-in providers.
+func Register(tp catwalk.Type, factory Factory) {
+	factories.Set(tp, factory)
+}
+
 type ProviderClient interface {
 	send(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error)
 	stream(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent
@@ -95,6 +123,10 @@ func (p *baseProvider[C]) cleanMessages(messages []message.Message) (cleaned []m
 		if len(msg.Parts) == 0 {
 			continue
 		}
+		// The providers send the text of the user messages and their images.
+		if msg.Role == message.User {
+			msg = msg.InlineAttachments(p.Model().SupportsImages)
+		}
 		cleaned = append(cleaned, msg)
 	}
 	return
@@ -102,12 +134,42 @@ func (p *baseProvider[C]) cleanMessages(messages []message.Message) (cleaned []m
 
 func (p *baseProvider[C]) SendMessages(ctx context.Context, messages []message.Message, tools []tools.BaseTool) (*ProviderResponse, error) {
 	messages = p.cleanMessages(messages)
-	return p.client.send(ctx, messages, tools)
+	release, err := p.options.limiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	send := p.client.send
+	if emulatesTools(p.options.modelType, tools) {
+		send = emulatedSend(send)
+	}
+	return send(ctx, messages, tools)
 }
 
 func (p *baseProvider[C]) StreamResponse(ctx context.Context, messages []message.Message, tools []tools.BaseTool) <-chan ProviderEvent {
 	messages = p.cleanMessages(messages)
-	return p.client.stream(ctx, messages, tools)
+	stream := p.client.stream
+	if emulatesTools(p.options.modelType, tools) {
+		stream = emulatedStream(stream)
+	}
+	if p.options.limiter == nil || p.options.limiter.slots == nil {
+		return stream(ctx, messages, tools)
+	}
+	// Hold the slot until the stream ends.
+	eventChan := make(chan ProviderEvent)
+	go func() {
+		defer close(eventChan)
+		release, err := p.options.limiter.acquire(ctx)
+		if err != nil {
+			eventChan <- ProviderEvent{Type: EventError, Error: err}
+			return
+		}
+		defer release()
+		for event := range stream(ctx, messages, tools) {
+			eventChan <- event
+		}
+	}()
+	return eventChan
 }
 
 func (p *baseProvider[C]) Model() catwalk.Model {
@@ -139,6 +201,14 @@ func WithMaxTokens(maxTokens int64) ProviderClientOption {
 }
 
 func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provider, error) {
+	if factory, ok := factories.Get(cfg.Type); ok {
+		clientOptions := providerClientOptions{}
+		for _, o := range opts {
+			o(&clientOptions)
+		}
+		return factory(cfg, clientOptions.modelType)
+	}
+
 	resolvedAPIKey, err := config.Get().Resolve(cfg.APIKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve API key for provider %s: %w", cfg.ID, err)
@@ -162,10 +232,15 @@ func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provi
 		extraBody:          cfg.ExtraBody,
 		extraParams:        cfg.ExtraParams,
 		systemPromptPrefix: cfg.SystemPromptPrefix,
+		limiter:            limiterFor(cfg),
 		model: func(tp config.SelectedModelType) catwalk.Model {
 			return *config.Get().GetModelByType(tp)
 		},
 	}
+	clientOptions.httpClient, err = NewHTTPClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client for provider %s: %w", cfg.ID, err)
+	}
 	for _, o := range opts {
 		o(&clientOptions)
 	}
@@ -176,29 +251,51 @@ func NewProvider(cfg config.ProviderConfig, opts ...ProviderClientOption) (Provi
 			client:  newAnthropicClient(clientOptions, AnthropicClientTypeNormal),
 		}, nil
 	case catwalk.TypeOpenAI:
+		if config.Get().GetOpenAIAPI(clientOptions.modelType) == config.OpenAIAPIResponses {
+			return &baseProvider[OpenAIClient]{
+				options: clientOptions,
+				client:  newOpenAIResponsesClient(clientOptions),
+			}, nil
+		}
 		return &baseProvider[OpenAIClient]{
 			options: clientOptions,
 			client:  newOpenAIClient(clientOptions),
 		}, nil
 	case catwalk.TypeGemini:
+		client, err := newGeminiClient(clientOptions)
+		if err != nil {
+			return nil, err
+		}
 		return &baseProvider[GeminiClient]{
 			options: clientOptions,
-			client:  newGeminiClient(clientOptions),
+			client:  client,
 		}, nil
 	case catwalk.TypeBedrock:
+		client, err := newBedrockClient(clientOptions)
+		if err != nil {
+			return nil, err
+		}
 		return &baseProvider[BedrockClient]{
 			options: clientOptions,
-			client:  newBedrockClient(clientOptions),
+			client:  client,
 		}, nil
 	case catwalk.TypeAzure:
+		client, err := newAzureClient(clientOptions)
+		if err != nil {
+			return nil, err
+		}
 		return &baseProvider[AzureClient]{
 			options: clientOptions,
-			client:  newAzureClient(clientOptions),
+			client:  client,
 		}, nil
 	case catwalk.TypeVertexAI:
+		client, err := newVertexAIClient(clientOptions)
+		if err != nil {
+			return nil, err
+		}
 		return &baseProvider[VertexAIClient]{
 			options: clientOptions,
-			client:  newVertexAIClient(clientOptions),
+			client:  client,
 		}, nil
 	}
 	return nil, fmt.Errorf("provider not supported: %s", cfg.Type)
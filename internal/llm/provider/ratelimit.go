@@ -0,0 +1,148 @@
+package provider
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// rateLimiter queues the requests to a provider, shared by all the clients
+// of the provider so that parallel agents and background tasks stay within
+// the limits together.
+type rateLimiter struct {
+	limits config.RateLimit
+	// slots holds a token per request in flight, nil without a cap.
+	slots chan struct{}
+	// window is the period requests per minute are counted over.
+	window time.Duration
+
+	mu     sync.Mutex
+	starts []time.Time
+	// pausedUntil is when the provider accepts requests again, after
+	// asking to retry later.
+	pausedUntil time.Time
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = map[string]*rateLimiter{}
+)
+
+// limiterFor returns the limiter of the provider, a new one when its limits
+// changed.
+func limiterFor(cfg config.ProviderConfig) *rateLimiter {
+	var limits config.RateLimit
+	if cfg.RateLimit != nil {
+		limits = *cfg.RateLimit
+	}
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if l, ok := limiters[cfg.ID]; ok && l.limits == limits {
+		return l
+	}
+	l := newRateLimiter(limits, time.Minute)
+	limiters[cfg.ID] = l
+	return l
+}
+
+func newRateLimiter(limits config.RateLimit, window time.Duration) *rateLimiter {
+	l := &rateLimiter{limits: limits, window: window}
+	if limits.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, limits.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a slot for a request, held until release is called.
+func (l *rateLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil || l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return sync.OnceFunc(func() { <-l.slots }), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wait waits until an attempt can be sent: the provider isn't paused and
+// fewer than the requests per minute were started in the last minute.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		delay := l.pausedUntil.Sub(now)
+		if rpm := l.limits.RequestsPerMinute; delay <= 0 && rpm > 0 {
+			for len(l.starts) > 0 && now.Sub(l.starts[0]) >= l.window {
+				l.starts = l.starts[1:]
+			}
+			if len(l.starts) >= rpm {
+				delay = l.starts[0].Add(l.window).Sub(now)
+			}
+		}
+		if delay <= 0 {
+			if l.limits.RequestsPerMinute > 0 {
+				l.starts = append(l.starts, now)
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay returns how many milliseconds to wait before retrying: the
+// delay the provider asked for with the headers if any, or an exponential
+// backoff with jitter. When the provider asked for a delay, the other
+// requests to it wait as well.
+func (l *rateLimiter) retryDelay(attempts int, header http.Header) int64 {
+	if delay, ok := retryAfter(header, time.Now()); ok {
+		if l != nil {
+			l.mu.Lock()
+			if until := time.Now().Add(delay); until.After(l.pausedUntil) {
+				l.pausedUntil = until
+			}
+			l.mu.Unlock()
+		}
+		return delay.Milliseconds()
+	}
+	backoff := 2000 * (1 << (attempts - 1))
+	return int64(backoff + rand.IntN(backoff/5+1))
+}
+
+// retryAfter parses the retry-after-ms header, sent by OpenAI and
+// Anthropic, and the standard Retry-After header, in seconds or as a date.
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if header == nil {
+		return 0, false
+	}
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
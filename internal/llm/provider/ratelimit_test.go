@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_RequestsPerMinute(t *testing.T) {
+	l := newRateLimiter(config.RateLimit{RequestsPerMinute: 2}, 200*time.Millisecond)
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, l.wait(t.Context()))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	require.NoError(t, l.wait(ctx))
+	require.ErrorIs(t, l.wait(ctx), context.DeadlineExceeded)
+}
+
+func TestRateLimiter_MaxConcurrent(t *testing.T) {
+	l := newRateLimiter(config.RateLimit{MaxConcurrent: 1}, time.Minute)
+	release, err := l.acquire(t.Context())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release()
+	release, err = l.acquire(t.Context())
+	require.NoError(t, err)
+	release()
+}
+
+func TestRateLimiter_RetryAfterPausesProvider(t *testing.T) {
+	l := newRateLimiter(config.RateLimit{}, time.Minute)
+	header := http.Header{}
+	header.Set("retry-after-ms", "100")
+	require.Equal(t, int64(100), l.retryDelay(1, header))
+
+	start := time.Now()
+	require.NoError(t, l.wait(t.Context()))
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{"none", http.Header{}, 0, false},
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second, true},
+		{"milliseconds first", http.Header{"Retry-After": {"3"}, "Retry-After-Ms": {"1500"}}, 1500 * time.Millisecond, true},
+		{"date", http.Header{"Retry-After": {now.Add(5 * time.Second).Format(http.TimeFormat)}}, 5 * time.Second, true},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.header, now)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRetryDelay_Backoff(t *testing.T) {
+	var l *rateLimiter
+	for range 10 {
+		delay := l.retryDelay(2, nil)
+		require.GreaterOrEqual(t, delay, int64(4000))
+		require.LessOrEqual(t, delay, int64(4800))
+	}
+}
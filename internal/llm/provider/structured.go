@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ResponseSchema constrains the responses to a JSON object, for requests
+// without tools. OpenAI and the OpenAI-compatible servers take it as a JSON
+// schema, which Ollama and llama.cpp turn into a grammar, Gemini as the
+// schema of the response, and Anthropic as the input of a tool the model is
+// made to call. Other providers answer as they would without it, see
+// DecodeResponse.
+type ResponseSchema struct {
+	// Name is the name of the object, of letters, digits, underscores and
+	// dashes.
+	Name        string
+	Description string
+	// Properties and Required are the ones of the object, as in the info of
+	// the tools. Strict schemas of OpenAI need all the properties required.
+	Properties map[string]any
+	Required   []string
+}
+
+// WithResponseSchema constrains the responses of the provider to the schema.
+func WithResponseSchema(schema ResponseSchema) ProviderClientOption {
+	return func(options *providerClientOptions) {
+		options.responseSchema = &schema
+	}
+}
+
+// jsonSchema returns the JSON schema of the object, without other properties
+// as strict schemas need.
+func (s ResponseSchema) jsonSchema() map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"properties":           s.Properties,
+		"required":             s.Required,
+		"additionalProperties": false,
+	}
+}
+
+// DecodeResponse decodes the JSON object of a response into v. The responses
+// of models that weren't constrained may have it in code fences or among
+// text, it's looked for there too.
+func DecodeResponse(content string, v any) error {
+	content = strings.TrimSpace(content)
+	if err := json.Unmarshal([]byte(content), v); err == nil {
+		return nil
+	}
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return errors.New("no JSON object in the response")
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), v); err != nil {
+		return fmt.Errorf("invalid JSON object in the response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = ResponseSchema{
+	Name:        "session_title",
+	Description: "The title of the session.",
+	Properties:  map[string]any{"title": map[string]any{"type": "string"}},
+	Required:    []string{"title"},
+}
+
+func TestDecodeResponse(t *testing.T) {
+	for _, content := range []string{
+		`{"title":"Fix the parser"}`,
+		"```json\n{\"title\": \"Fix the parser\"}\n```",
+		`Here is the title: {"title":"Fix the parser"} Hope it helps.`,
+	} {
+		var answer struct {
+			Title string `json:"title"`
+		}
+		require.NoError(t, DecodeResponse(content, &answer), content)
+		require.Equal(t, "Fix the parser", answer.Title)
+	}
+
+	var answer map[string]any
+	require.Error(t, DecodeResponse("Fix the parser", &answer))
+	require.Error(t, DecodeResponse("{title: Fix the parser}", &answer))
+}
+
+func TestOpenAIResponsesClientResponseSchema(t *testing.T) {
+	client := newTestResponsesClient(t, "http://localhost", catwalk.Model{ID: "gpt-4.1", DefaultMaxTokens: 1000}, config.SelectedModel{})
+	client.providerOptions.responseSchema = &testSchema
+
+	data, err := json.Marshal(client.preparedParams(nil, nil))
+	require.NoError(t, err)
+	var request struct {
+		Text struct {
+			Format map[string]any `json:"format"`
+		} `json:"text"`
+	}
+	require.NoError(t, json.Unmarshal(data, &request))
+	require.Equal(t, "json_schema", request.Text.Format["type"])
+	require.Equal(t, "session_title", request.Text.Format["name"])
+	require.Equal(t, true, request.Text.Format["strict"])
+	require.Equal(t, false, request.Text.Format["schema"].(map[string]any)["additionalProperties"])
+}
+
+func TestAnthropicClientSchemaTool(t *testing.T) {
+	client := &anthropicClient{providerOptions: providerClientOptions{responseSchema: &testSchema}}
+
+	var msg anthropic.Message
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"stop_reason": "tool_use",
+		"content": [
+			{"type": "tool_use", "id": "toolu_1", "name": "session_title", "input": {"title": "Fix the parser"}}
+		]
+	}`), &msg))
+	require.JSONEq(t, `{"title":"Fix the parser"}`, client.content(msg))
+	require.Empty(t, client.toolCalls(msg), "the schema tool isn't a tool call")
+	require.Equal(t, message.FinishReasonEndTurn, client.finishReason(string(msg.StopReason)))
+	require.False(t, client.isThinkingEnabled())
+}
@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// thinkSplitter splits the content streamed by models that reason in their
+// content, as DeepSeek-R1 served by Ollama, into the thinking between
+// <think> and </think> at its start and the content after it.
+type thinkSplitter struct {
+	thinking bool
+	// done is set once the content is past the thinking, or didn't start
+	// with it.
+	done bool
+	// afterThinking trims the space between the thinking and the content.
+	afterThinking bool
+	// pending is the text held back until it's known whether it's a tag.
+	pending string
+}
+
+// split returns the thinking and the content of the chunk, holding back the
+// end of it that may be the start of a tag.
+func (s *thinkSplitter) split(chunk string) (thinking, content string) {
+	text := s.pending + chunk
+	s.pending = ""
+	if !s.done && !s.thinking {
+		trimmed := strings.TrimLeft(text, " \t\r\n")
+		switch {
+		case strings.HasPrefix(trimmed, thinkOpenTag):
+			s.thinking = true
+			text = trimmed[len(thinkOpenTag):]
+		case strings.HasPrefix(thinkOpenTag, trimmed):
+			s.pending = text
+			return "", ""
+		default:
+			s.done = true
+		}
+	}
+	if s.thinking {
+		i := strings.Index(text, thinkCloseTag)
+		if i < 0 {
+			n := partialTagLen(text, thinkCloseTag)
+			s.pending = text[len(text)-n:]
+			return text[:len(text)-n], ""
+		}
+		thinking, text = text[:i], text[i+len(thinkCloseTag):]
+		s.thinking, s.done, s.afterThinking = false, true, true
+	}
+	if s.afterThinking {
+		text = strings.TrimLeft(text, " \t\r\n")
+		s.afterThinking = text == ""
+	}
+	return thinking, text
+}
+
+// flush returns the text held back at the end of the stream.
+func (s *thinkSplitter) flush() (thinking, content string) {
+	pending := s.pending
+	s.pending = ""
+	if s.thinking {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// partialTagLen returns the length of the longest end of the text that is a
+// start of the tag.
+func partialTagLen(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// splitThinking splits a whole response into its thinking and its content.
+func splitThinking(text string) (thinking, content string) {
+	var s thinkSplitter
+	thinking, content = s.split(text)
+	restThinking, restContent := s.flush()
+	return thinking + restThinking, content + restContent
+}
+
+// deltaReasoning returns the reasoning streamed apart from the content, as
+// reasoning_content by DeepSeek and as reasoning by Ollama and OpenRouter.
+func deltaReasoning(delta openai.ChatCompletionChunkChoiceDelta) string {
+	for _, name := range []string{"reasoning_content", "reasoning"} {
+		// The extra fields are never valid, they aren't fields of the delta.
+		field, ok := delta.JSON.ExtraFields[name]
+		if !ok {
+			continue
+		}
+		var reasoning string
+		if err := json.Unmarshal([]byte(field.Raw()), &reasoning); err == nil && reasoning != "" {
+			return reasoning
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThinkSplitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		thinking string
+		content  string
+	}{
+		{
+			name:     "tags split across chunks",
+			chunks:   []string{"<thi", "nk>\nThe user wants", " a test.</th", "ink>", "\n\n", "Here it is."},
+			thinking: "\nThe user wants a test.",
+			content:  "Here it is.",
+		},
+		{
+			name:     "whole response",
+			chunks:   []string{"  <think>Short.</think>\nDone."},
+			thinking: "Short.",
+			content:  "Done.",
+		},
+		{
+			name:    "no thinking",
+			chunks:  []string{"<", "b>Bold</b> and <think> in the text"},
+			content: "<b>Bold</b> and <think> in the text",
+		},
+		{
+			name:     "unfinished thinking",
+			chunks:   []string{"<think>Still going</"},
+			thinking: "Still going</",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s thinkSplitter
+			var thinking, content string
+			for _, chunk := range tt.chunks {
+				th, c := s.split(chunk)
+				thinking, content = thinking+th, content+c
+			}
+			th, c := s.flush()
+			require.Equal(t, tt.thinking, thinking+th)
+			require.Equal(t, tt.content, content+c)
+		})
+	}
+
+	thinking, content := splitThinking("<think>Checking.</think>\n\nAll good.")
+	require.Equal(t, "Checking.", thinking)
+	require.Equal(t, "All good.", content)
+}
+
+func TestDeltaReasoning(t *testing.T) {
+	for raw, want := range map[string]string{
+		`{"content":"","reasoning_content":"Let me check."}`: "Let me check.",
+		`{"content":"","reasoning":"Let me see."}`:           "Let me see.",
+		`{"content":"Hi","reasoning_content":null}`:          "",
+	} {
+		var delta openai.ChatCompletionChunkChoiceDelta
+		require.NoError(t, delta.UnmarshalJSON([]byte(raw)))
+		require.Equal(t, want, deltaReasoning(delta))
+	}
+}
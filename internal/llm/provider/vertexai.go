@@ -2,33 +2,84 @@ package provider
 
 import (
 	"context"
-	"log/slog"
+	"fmt"
+	"net/http"
 	"strings"
 
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+	"cloud.google.com/go/auth/httptransport"
+	"github.com/charmbracelet/crush/internal/config"
 	"google.golang.org/genai"
 )
 
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
 type VertexAIClient ProviderClient
 
-func newVertexAIClient(opts providerClientOptions) VertexAIClient {
-	project := opts.extraParams["project"]
-	location := opts.extraParams["location"]
-	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
-		Project:  project,
-		Location: location,
-		Backend:  genai.BackendVertexAI,
-	})
+func newVertexAIClient(opts providerClientOptions) (VertexAIClient, error) {
+	creds, err := vertexCredentials(opts.config.VertexAI)
 	if err != nil {
-		slog.Error("Failed to create VertexAI client", "error", err)
-		return nil
+		return nil, err
 	}
 
 	model := opts.model(opts.modelType)
-	if strings.Contains(model.ID, "anthropic") || strings.Contains(model.ID, "claude-sonnet") {
-		return newAnthropicClient(opts, AnthropicClientTypeVertex)
+	if strings.Contains(model.ID, "anthropic") || strings.Contains(model.ID, "claude") {
+		return newAnthropicClient(opts, AnthropicClientTypeVertex), nil
+	}
+	var httpClient *http.Client
+	if opts.httpClient != nil {
+		if httpClient, err = vertexHTTPClient(creds, opts.httpClient); err != nil {
+			return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
+		}
+	}
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		Project:     opts.extraParams["project"],
+		Location:    opts.extraParams["location"],
+		Backend:     genai.BackendVertexAI,
+		Credentials: creds,
+		HTTPOptions: geminiHTTPOptions(opts),
+		HTTPClient:  httpClient,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
 	}
 	return &geminiClient{
 		providerOptions: opts,
 		client:          client,
+	}, nil
+}
+
+// vertexCredentials returns the credentials of the service account key file,
+// or the Application Default Credentials when there's none.
+func vertexCredentials(opts *config.VertexAIOptions) (*auth.Credentials, error) {
+	detectOptions := &credentials.DetectOptions{Scopes: []string{cloudPlatformScope}}
+	if opts != nil {
+		detectOptions.CredentialsFile = opts.CredentialsFile
+	}
+	creds, err := credentials.DetectDefault(detectOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find Google Cloud credentials: %w", err)
+	}
+	return creds, nil
+}
+
+// vertexHTTPClient returns a client authenticated with the credentials,
+// sending the requests with the transport of base, which goes through the
+// proxy.
+func vertexHTTPClient(creds *auth.Credentials, base *http.Client) (*http.Client, error) {
+	quotaProjectID, err := creds.QuotaProjectID(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quota project ID: %w", err)
+	}
+	client, err := httptransport.NewClient(&httptransport.Options{
+		Credentials:      creds,
+		Headers:          http.Header{"X-Goog-User-Project": []string{quotaProjectID}},
+		BaseRoundTripper: base.Transport,
+	})
+	if err != nil {
+		return nil, err
 	}
+	client.Timeout = base.Timeout
+	return client, nil
 }
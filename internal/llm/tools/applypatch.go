@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type ApplyPatchParams struct {
+	Patch string `json:"patch"`
+}
+
+type ApplyPatchPermissionsParams struct {
+	FilePath   string `json:"file_path"`
+	OldContent string `json:"old_content,omitempty"`
+	NewContent string `json:"new_content,omitempty"`
+}
+
+type applyPatchTool struct {
+	lspClients  map[string]*lsp.Client
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+const (
+	ApplyPatchToolName    = "applypatch"
+	applyPatchDescription = `Applies a patch in the unified diff format to one or more files at once. Prefer this tool over the Edit and MultiEdit tools for changes spanning several places of a file or several files.
+
+Before using this tool:
+- Use the View tool to read the files to change, so that the context lines of the patch match them
+
+HOW TO USE:
+- Write the patch like git diff does: a --- a/path and a +++ b/path line for each file, followed by its hunks
+- Each hunk starts with a @@ -line,count +line,count @@ header and has lines starting with a space for context, - for removed lines, and + for added lines
+- Give about 3 lines of context around each change so the hunk can be located
+- Create a file with --- /dev/null, and delete one with +++ /dev/null
+- Paths are relative to the working directory, or absolute
+
+FEATURES:
+- Hunks are located near their line numbers, so wrong line numbers and counts are tolerated
+- Context lines may differ from the file in whitespace and indentation, and up to 2 context lines at each end of a hunk may not match at all
+- The patch is applied atomically: if any hunk of any file doesn't apply, no file is changed, and files already written are restored if writing another fails
+- The line endings of the files are kept
+
+LIMITATIONS:
+- Binary patches aren't supported
+- Removed lines must match the file, ignoring whitespace
+
+TIPS:
+- If a hunk doesn't apply, read the file again and regenerate the patch from its current content
+- Keep unrelated changes in separate hunks rather than one large hunk`
+)
+
+func NewApplyPatchTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &applyPatchTool{
+		lspClients:  lspClients,
+		permissions: permissions,
+		files:       files,
+		workingDir:  workingDir,
+	}
+}
+
+func (a *applyPatchTool) Name() string {
+	return ApplyPatchToolName
+}
+
+func (a *applyPatchTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        ApplyPatchToolName,
+		Description: applyPatchDescription,
+		Parameters: map[string]any{
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "The patch to apply, in the unified diff format",
+			},
+		},
+		Required: []string{"patch"},
+	}
+}
+
+func (a *applyPatchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ApplyPatchParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	if strings.TrimSpace(params.Patch) == "" {
+		return NewTextErrorResponse("patch is required"), nil
+	}
+
+	patches, err := diff.ParsePatch(params.Patch)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("invalid patch: %s", err)), nil
+	}
+
+	// Apply every hunk in memory first, so nothing is written if any fails.
+	var changes []fileChange
+	seen := map[string]bool{}
+	for _, patch := range patches {
+		change, err := a.prepare(patch)
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("%s: %s", patch.Path(), err)), nil
+		}
+		for _, path := range []string{change.FilePath, change.OldPath} {
+			if path == "" {
+				continue
+			}
+			if seen[path] {
+				return NewTextErrorResponse(fmt.Sprintf("%s is changed twice by the patch, merge its hunks", path)), nil
+			}
+			seen[path] = true
+		}
+		changes = append(changes, change)
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for applying a patch")
+	}
+
+	// Every file is asked for, so that the rules of each path apply.
+	request := func(path, description string, change fileChange) bool {
+		if len(changes) > 1 {
+			description += fmt.Sprintf(" (%d files in the patch)", len(changes))
+		}
+		return a.permissions.Request(permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        fsext.PathOrPrefix(path, a.workingDir),
+			ToolCallID:  call.ID,
+			ToolName:    ApplyPatchToolName,
+			Action:      "write",
+			Description: description,
+			Params: ApplyPatchPermissionsParams{
+				FilePath:   path,
+				OldContent: change.OldContent,
+				NewContent: change.NewContent,
+			},
+		})
+	}
+	for _, change := range changes {
+		var granted bool
+		switch {
+		case change.Created:
+			granted = request(change.FilePath, fmt.Sprintf("Create file %s", change.FilePath), change)
+		case change.Deleted:
+			granted = request(change.FilePath, fmt.Sprintf("Delete file %s", change.FilePath), change)
+		case change.OldPath != "":
+			granted = request(change.OldPath, fmt.Sprintf("Rename file %s to %s", change.OldPath, change.FilePath), change) &&
+				request(change.FilePath, fmt.Sprintf("Rename file %s to %s", change.OldPath, change.FilePath), change)
+		default:
+			granted = request(change.FilePath, fmt.Sprintf("Apply patch to file %s", change.FilePath), change)
+		}
+		if !granted {
+			return ToolResponse{}, permission.ErrorPermissionDenied
+		}
+	}
+
+	if err := writeChanges(changes); err != nil {
+		return ToolResponse{}, err
+	}
+
+	recordChangeSet(ctx, a.files, sessionID, changes)
+
+	return changeSetResponse(ctx, fmt.Sprintf("Applied the patch to %d files", len(changes)), changes, a.lspClients, a.workingDir), nil
+}
+
+// prepare reads the file of the patch and applies its hunks.
+func (a *applyPatchTool) prepare(patch diff.FilePatch) (fileChange, error) {
+	abs := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(a.workingDir, path)
+	}
+	oldPath, newPath := abs(patch.OldPath), abs(patch.NewPath)
+
+	change := fileChange{}
+	if patch.IsNew() {
+		if _, err := os.Stat(newPath); err == nil {
+			return change, errors.New("the file already exists, patch it instead of creating it")
+		} else if !os.IsNotExist(err) {
+			return change, fmt.Errorf("failed to access file: %w", err)
+		}
+		content, err := diff.ApplyHunks("", patch.Hunks)
+		if err != nil {
+			return change, err
+		}
+		change.FilePath, change.NewContent, change.Created = newPath, content, true
+	} else {
+		content, err := readFileToChange(oldPath)
+		if err != nil {
+			return change, err
+		}
+		change.FilePath, change.OldContent, change.existed = oldPath, content, true
+		newContent, err := diff.ApplyHunks(content, patch.Hunks)
+		if err != nil {
+			return change, err
+		}
+		switch {
+		case patch.IsDelete():
+			change.Deleted = true
+		case newPath != oldPath:
+			if _, err := os.Stat(newPath); err == nil {
+				return change, fmt.Errorf("can't rename it to %s, which already exists", patch.NewPath)
+			}
+			change.FilePath, change.OldPath, change.existed = newPath, oldPath, false
+			change.NewContent = newContent
+		default:
+			if newContent == content {
+				return change, errors.New("the patch doesn't change the file")
+			}
+			change.NewContent = newContent
+		}
+	}
+
+	_, change.Additions, change.Removals = diff.GenerateDiff(change.OldContent, change.NewContent, strings.TrimPrefix(change.FilePath, a.workingDir))
+	return change, nil
+}
+
+// readFileToChange reads a file to change, which must have been read since it
+// last changed.
+func readFileToChange(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.New("file not found")
+		}
+		return "", fmt.Errorf("failed to access file: %w", err)
+	}
+	if info.IsDir() {
+		return "", errors.New("path is a directory, not a file")
+	}
+	lastRead := getLastReadTime(path)
+	if lastRead.IsZero() {
+		return "", errors.New("you must read the file before changing it. Use the View tool first")
+	}
+	if info.ModTime().After(lastRead) {
+		return "", fmt.Errorf("file has been modified since it was last read (mod time: %s, last read: %s)",
+			info.ModTime().Format(time.RFC3339), lastRead.Format(time.RFC3339))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+// newFileToolTest writes the files, read by the agent, in a temporary
+// directory, and returns the services and context of the tools changing them.
+func newFileToolTest(t *testing.T, files map[string]string) (context.Context, string, permission.Service, history.Service) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		recordFileRead(path)
+	}
+
+	store, err := db.OpenSQLiteStore(t.Context(), memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	sess, err := session.NewService(store).Create(t.Context(), "Test session")
+	require.NoError(t, err)
+
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, sess.ID)
+	ctx = context.WithValue(ctx, MessageIDContextKey, "message")
+	return ctx, dir, permission.NewPermissionService(dir, true, nil, false), history.NewService(store)
+}
+
+func newApplyPatchTest(t *testing.T, files map[string]string) (BaseTool, context.Context, string) {
+	t.Helper()
+	ctx, dir, permissions, history := newFileToolTest(t, files)
+	return NewApplyPatchTool(nil, permissions, history, dir), ctx, dir
+}
+
+func runApplyPatch(t *testing.T, tool BaseTool, ctx context.Context, patch string) ToolResponse {
+	t.Helper()
+	input, err := json.Marshal(ApplyPatchParams{Patch: patch})
+	require.NoError(t, err)
+	resp, err := tool.Run(ctx, ToolCall{ID: "call", Name: ApplyPatchToolName, Input: string(input)})
+	require.NoError(t, err)
+	return resp
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestApplyPatch(t *testing.T) {
+	tool, ctx, dir := newApplyPatchTest(t, map[string]string{
+		"a.txt":   "one\ntwo\nthree\n",
+		"old.txt": "bye\n",
+		"mv.txt":  "moved\n",
+	})
+
+	resp := runApplyPatch(t, tool, ctx, `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++2
+ three
+diff --git a/new/b.txt b/new/b.txt
+new file mode 100644
+--- /dev/null
++++ b/new/b.txt
+@@ -0,0 +1 @@
++created
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/mv.txt b/renamed.txt
+similarity index 100%
+rename from mv.txt
+rename to renamed.txt
+`)
+	require.False(t, resp.IsError, resp.Content)
+	require.Contains(t, resp.Content, "Applied the patch to 4 files")
+
+	require.Equal(t, "one\n2\nthree\n", readFile(t, filepath.Join(dir, "a.txt")))
+	require.Equal(t, "created\n", readFile(t, filepath.Join(dir, "new", "b.txt")))
+	require.NoFileExists(t, filepath.Join(dir, "old.txt"))
+	require.NoFileExists(t, filepath.Join(dir, "mv.txt"))
+	require.Equal(t, "moved\n", readFile(t, filepath.Join(dir, "renamed.txt")))
+
+	var meta ChangeSetResponseMetadata
+	require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &meta))
+	require.Len(t, meta.Files, 4)
+	require.Equal(t, 2, meta.Additions)
+	require.Equal(t, 2, meta.Removals)
+}
+
+func TestApplyPatchIsAtomic(t *testing.T) {
+	tool, ctx, dir := newApplyPatchTest(t, map[string]string{
+		"a.txt": "one\ntwo\n",
+		"b.txt": "three\nfour\n",
+	})
+
+	resp := runApplyPatch(t, tool, ctx, `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++2
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ three
+-five
++5
+`)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "b.txt: hunk 1: the lines to change were not found")
+	require.Equal(t, "one\ntwo\n", readFile(t, filepath.Join(dir, "a.txt")))
+}
+
+func TestApplyPatchRequiresRead(t *testing.T) {
+	tool, ctx, dir := newApplyPatchTest(t, nil)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unread.txt"), []byte("a\n"), 0o644))
+
+	resp := runApplyPatch(t, tool, ctx, "--- a/unread.txt\n+++ b/unread.txt\n@@ -1 +1 @@\n-a\n+b\n")
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "you must read the file")
+}
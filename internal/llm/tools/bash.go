@@ -3,22 +3,29 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/shell"
 )
 
 type BashParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command    string `json:"command"`
+	Timeout    int    `json:"timeout"`
+	Background bool   `json:"background,omitempty"`
 }
 
 type BashPermissionsParams struct {
-	Command string `json:"command"`
-	Timeout int    `json:"timeout"`
+	Command    string `json:"command"`
+	Timeout    int    `json:"timeout"`
+	Background bool   `json:"background,omitempty"`
 }
 
 type BashResponseMetadata struct {
@@ -30,6 +37,10 @@ type BashResponseMetadata struct {
 type bashTool struct {
 	permissions permission.Service
 	workingDir  string
+	// sandboxErr is why the configured sandbox can't run commands, which
+	// then fail rather than run on the host.
+	sandboxErr  error
+	sandboxNote string
 }
 
 const (
@@ -39,6 +50,10 @@ const (
 	MaxTimeout      = 10 * 60 * 1000 // 10 minutes in milliseconds
 	MaxOutputLength = 30000
 	BashNoOutput    = "no output"
+
+	// backgroundStartWait is how long a background command runs before its
+	// first output is returned.
+	backgroundStartWait = time.Second
 )
 
 var bannedCommands = []string{
@@ -116,6 +131,7 @@ var bannedCommands = []string{
 
 func bashDescription() string {
 	bannedCommandsStr := strings.Join(bannedCommands, ", ")
+	defaultTimeout, maxTimeout := bashTimeouts()
 	return fmt.Sprintf(`Executes a given bash command in a persistent shell session with optional timeout, ensuring proper handling and security measures.
 
 CROSS-PLATFORM SHELL SUPPORT:
@@ -141,7 +157,7 @@ Before executing the command, please follow these steps:
  - Capture the output of the command.
 
 4. Output Processing:
- - If the output exceeds %d characters, output will be truncated before being returned to you.
+ - If the output exceeds %d characters, the middle of it will be cut before being returned to you, and the full output saved to a file whose path the result gives.
  - Prepare the output for display to the user.
 
 5. Return Result:
@@ -151,7 +167,8 @@ Before executing the command, please follow these steps:
 
 Usage notes:
 - The command argument is required.
-- You can specify an optional timeout in milliseconds (up to 600000ms / 10 minutes). If not specified, commands will timeout after 30 minutes.
+- You can specify an optional timeout in milliseconds (up to %dms / %s). If not specified, commands will timeout after %s.
+- Set background to true to run commands that don't end on their own, like dev servers and watchers, or that take longer than the timeout. The result gives the ID of the job, use the jobs tool to read its output and stop it.
 - VERY IMPORTANT: You MUST avoid using search commands like 'find' and 'grep'. Instead use Grep, Glob, or Agent tools to search. You MUST avoid read tools like 'cat', 'head', 'tail', and 'ls', and use FileRead and LS tools to read files.
 - When issuing multiple commands, use the ';' or '&&' operator to separate them. DO NOT use newlines (newlines are ok in quoted strings).
 - IMPORTANT: All commands share the same shell session. Shell state (environment variables, virtual environments, current directory, etc.) persist between commands. For example, if you set an environment variable as part of a command, the environment variable will persist for subsequent commands.
@@ -269,7 +286,7 @@ EOF
 
 Important:
 - Return an empty response - the user will see the gh output directly
-- Never update git config`, bannedCommandsStr, MaxOutputLength)
+- Never update git config`, bannedCommandsStr, bashMaxOutput(), maxTimeout.Milliseconds(), maxTimeout, defaultTimeout)
 }
 
 func blockFuncs() []shell.BlockFunc {
@@ -308,10 +325,95 @@ func NewBashTool(permission permission.Service, workingDir string) BaseTool {
 	persistentShell := shell.GetPersistentShell(workingDir)
 	persistentShell.SetBlockFuncs(blockFuncs())
 
-	return &bashTool{
+	tool := &bashTool{
 		permissions: permission,
 		workingDir:  workingDir,
 	}
+	sandbox, err := NewSandbox(workingDir)
+	if err != nil {
+		slog.Error("Failed to set up the sandbox of the bash tool", "error", err)
+		tool.sandboxErr = err
+	} else if sandbox != nil {
+		tool.sandboxNote = sandboxNote(config.Get().Options.Sandbox, workingDir)
+	}
+	persistentShell.SetSandbox(sandbox)
+	return tool
+}
+
+// sandboxNote tells the model what sandboxed commands may do.
+func sandboxNote(sandbox *config.Sandbox, workingDir string) string {
+	note := "\n\nSANDBOX:\n* Commands run in a sandbox: they can only write in " + strings.Join(sandbox.WritablePaths(workingDir), ", ") + "."
+	if !sandbox.Network {
+		note += "\n* They can't reach the network, so don't try to download anything."
+	}
+	return note
+}
+
+// bashTimeouts returns the timeout of the commands and the longest timeout
+// the model can set, from the config or the defaults.
+func bashTimeouts() (time.Duration, time.Duration) {
+	defaultTimeout, maxTimeout := time.Duration(DefaultTimeout)*time.Millisecond, time.Duration(MaxTimeout)*time.Millisecond
+	cfg := config.Get()
+	if cfg == nil || cfg.Options == nil || cfg.Options.Bash == nil {
+		return defaultTimeout, maxTimeout
+	}
+	if cfg.Options.Bash.MaxTimeout > 0 {
+		maxTimeout = time.Duration(cfg.Options.Bash.MaxTimeout) * time.Second
+	}
+	if cfg.Options.Bash.Timeout > 0 {
+		defaultTimeout = time.Duration(cfg.Options.Bash.Timeout) * time.Second
+	}
+	return min(defaultTimeout, maxTimeout), maxTimeout
+}
+
+// bashMaxOutput returns the number of characters of an output sent to the
+// model.
+func bashMaxOutput() int {
+	cfg := config.Get()
+	if cfg == nil || cfg.Options == nil || cfg.Options.Bash == nil || cfg.Options.Bash.MaxOutput == 0 {
+		return MaxOutputLength
+	}
+	return cfg.Options.Bash.MaxOutput
+}
+
+// outputsDir returns the directory the full outputs of the tool calls of the
+// session are saved in.
+func outputsDir(sessionID string) string {
+	dir := os.TempDir()
+	if cfg := config.Get(); cfg != nil && cfg.Options != nil && cfg.Options.DataDirectory != "" {
+		dir = cfg.Options.DataDirectory
+	}
+	return filepath.Join(dir, "outputs", sessionID)
+}
+
+// outputPath returns the file the full output of the tool call is saved to.
+func outputPath(sessionID, callID string) string {
+	return filepath.Join(outputsDir(sessionID), callID+".log")
+}
+
+// RemoveOutputs deletes the saved outputs of the session, once it's deleted.
+func RemoveOutputs(sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return os.RemoveAll(outputsDir(sessionID))
+}
+
+// NewSandbox returns the sandbox of the config running the commands of the
+// bash tool, nil if they aren't sandboxed.
+func NewSandbox(workingDir string) (shell.Sandbox, error) {
+	cfg := config.Get()
+	if cfg == nil || cfg.Options == nil || !cfg.Options.Sandbox.Enabled() {
+		return nil, nil
+	}
+	sandbox := cfg.Options.Sandbox
+	return shell.NewSandbox(shell.SandboxOptions{
+		Backend:  sandbox.Backend,
+		Writable: sandbox.WritablePaths(workingDir),
+		Network:  sandbox.Network,
+		Runtime:  sandbox.Runtime,
+		Image:    sandbox.Image,
+	})
 }
 
 func (b *bashTool) Name() string {
@@ -319,9 +421,10 @@ func (b *bashTool) Name() string {
 }
 
 func (b *bashTool) Info() ToolInfo {
+	_, maxTimeout := bashTimeouts()
 	return ToolInfo{
 		Name:        BashToolName,
-		Description: bashDescription(),
+		Description: bashDescription() + b.sandboxNote,
 		Parameters: map[string]any{
 			"command": map[string]any{
 				"type":        "string",
@@ -329,7 +432,11 @@ func (b *bashTool) Info() ToolInfo {
 			},
 			"timeout": map[string]any{
 				"type":        "number",
-				"description": "Optional timeout in milliseconds (max 600000)",
+				"description": fmt.Sprintf("Optional timeout in milliseconds (max %d)", maxTimeout.Milliseconds()),
+			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command in the background and return its job ID, to read its output with the jobs tool",
 			},
 		},
 		Required: []string{"command"},
@@ -342,15 +449,20 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return NewTextErrorResponse("invalid parameters"), nil
 	}
 
-	if params.Timeout > MaxTimeout {
-		params.Timeout = MaxTimeout
-	} else if params.Timeout <= 0 {
-		params.Timeout = DefaultTimeout
+	defaultTimeout, maxTimeout := bashTimeouts()
+	timeout := time.Duration(params.Timeout) * time.Millisecond
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	} else if timeout <= 0 {
+		timeout = defaultTimeout
 	}
 
 	if params.Command == "" {
 		return NewTextErrorResponse("missing command"), nil
 	}
+	if b.sandboxErr != nil {
+		return NewTextErrorResponse(fmt.Sprintf("The sandbox of the bash tool is not available: %v", b.sandboxErr)), nil
+	}
 
 	isSafeReadOnly := false
 	cmdLower := strings.ToLower(params.Command)
@@ -364,6 +476,13 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		}
 	}
 
+	// Destructive commands always need to be confirmed, even if they look
+	// safe, e.g. git branch -D.
+	destructiveReason, destructive := permission.CheckDestructiveCommand(params.Command, b.workingDir)
+	if destructive {
+		isSafeReadOnly = false
+	}
+
 	sessionID, messageID := GetContextValues(ctx)
 	if sessionID == "" || messageID == "" {
 		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for creating a new file")
@@ -378,23 +497,28 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 				Action:      "execute",
 				Description: fmt.Sprintf("Execute command: %s", params.Command),
 				Params: BashPermissionsParams{
-					Command: params.Command,
+					Command:    params.Command,
+					Background: params.Background,
 				},
+				DestructiveReason: destructiveReason,
 			},
 		)
 		if !p {
 			return ToolResponse{}, permission.ErrorPermissionDenied
 		}
 	}
-	startTime := time.Now()
-	if params.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Millisecond)
-		defer cancel()
+	if params.Background {
+		return b.runBackground(ctx, sessionID, call.ID, params.Command)
 	}
 
+	startTime := time.Now()
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	persistentShell := shell.GetPersistentShell(b.workingDir)
-	stdout, stderr, err := persistentShell.Exec(ctx, params.Command)
+	stdout, stderr, err := persistentShell.ExecStream(ctx, params.Command, &outputProgressWriter{ctx: ctx})
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
 
 	// Get the current working directory after command execution
 	currentWorkingDir := persistentShell.GetWorkingDir()
@@ -404,15 +528,27 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return ToolResponse{}, fmt.Errorf("error executing command: %w", err)
 	}
 
-	stdout = truncateOutput(stdout)
-	stderr = truncateOutput(stderr)
+	// Too long outputs are cut in proportion to their length, and saved
+	// whole for the model to read the middle of.
+	var saved string
+	if limit, total := bashMaxOutput(), len(stdout)+len(stderr); total > limit {
+		saved = saveOutput(sessionID, call.ID, stdout, stderr)
+		stdoutLimit := limit * len(stdout) / total
+		stdout = truncateOutputTo(stdout, stdoutLimit)
+		stderr = truncateOutputTo(stderr, limit-stdoutLimit)
+	}
 
 	errorMessage := stderr
 	if errorMessage == "" && err != nil {
 		errorMessage = err.Error()
 	}
 
-	if interrupted {
+	if timedOut {
+		if errorMessage != "" {
+			errorMessage += "\n"
+		}
+		errorMessage += fmt.Sprintf("Command timed out after %s, run it with background set to true to let it run longer", timeout)
+	} else if interrupted {
 		if errorMessage != "" {
 			errorMessage += "\n"
 		}
@@ -433,6 +569,9 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	if errorMessage != "" {
 		stdout += "\n" + errorMessage
 	}
+	if saved != "" {
+		stdout += fmt.Sprintf("\n\n[The output was cut, the full output is in %s]", saved)
+	}
 
 	metadata := BashResponseMetadata{
 		StartTime:        startTime.UnixMilli(),
@@ -440,19 +579,75 @@ func (b *bashTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		Output:           stdout,
 		WorkingDirectory: currentWorkingDir,
 	}
+	glossaryIssues := checkCommandGlossary(b.workingDir, params.Command)
 	if stdout == "" {
-		return WithResponseMetadata(NewTextResponse(BashNoOutput), metadata), nil
+		return WithResponseMetadata(NewTextResponse(BashNoOutput+glossaryIssues), metadata), nil
 	}
 	stdout += fmt.Sprintf("\n\n<cwd>%s</cwd>", currentWorkingDir)
+	stdout += glossaryIssues
 	return WithResponseMetadata(NewTextResponse(stdout), metadata), nil
 }
 
+// runBackground starts the command as a job in a clone of the persistent
+// shell, and returns its first output.
+func (b *bashTool) runBackground(ctx context.Context, sessionID, callID, command string) (ToolResponse, error) {
+	j, err := startJob(sessionID, callID, command, shell.GetPersistentShell(b.workingDir).Clone())
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("error starting background command: %w", err)
+	}
+	select {
+	case <-j.done:
+	case <-time.After(backgroundStartWait):
+	case <-ctx.Done():
+	}
+
+	output, err := j.unread()
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to read the output of %s: %v", j.ID, err)), nil
+	}
+	if output == "" {
+		output = BashNoOutput
+	}
+	response := fmt.Sprintf("Started job %s in the background, use the jobs tool with its ID to read its output or kill it. It's %s.\n\n%s", j.ID, j.status(), output)
+	return WithResponseMetadata(NewTextResponse(response), BashResponseMetadata{
+		StartTime:        j.StartTime.UnixMilli(),
+		EndTime:          time.Now().UnixMilli(),
+		Output:           output,
+		WorkingDirectory: shell.GetPersistentShell(b.workingDir).GetWorkingDir(),
+	}), nil
+}
+
+// saveOutput saves the whole output of the tool call, and returns the path
+// of the file, empty when it couldn't be saved. Outputs can hold secrets,
+// only the user can read them.
+func saveOutput(sessionID, callID, stdout, stderr string) string {
+	path := outputPath(sessionID, callID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		slog.Error("Failed to create the output directory", "error", err)
+		return ""
+	}
+	output := stdout
+	if stdout != "" && stderr != "" {
+		output += "\n"
+	}
+	if err := os.WriteFile(path, []byte(output+stderr), 0o600); err != nil {
+		slog.Error("Failed to save the output of the command", "error", err)
+		return ""
+	}
+	return path
+}
+
 func truncateOutput(content string) string {
-	if len(content) <= MaxOutputLength {
+	return truncateOutputTo(content, MaxOutputLength)
+}
+
+// truncateOutputTo cuts the middle of the content longer than limit.
+func truncateOutputTo(content string, limit int) string {
+	if len(content) <= limit {
 		return content
 	}
 
-	halfLength := MaxOutputLength / 2
+	halfLength := limit / 2
 	start := content[:halfLength]
 	end := content[len(content)-halfLength:]
 
@@ -0,0 +1,278 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/browser"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type BrowserParams struct {
+	Action   string `json:"action"`
+	URL      string `json:"url,omitempty"`
+	Ref      int64  `json:"ref,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Selector string `json:"selector,omitempty"`
+}
+
+type BrowserPermissionsParams struct {
+	Action string `json:"action"`
+	URL    string `json:"url,omitempty"`
+	Ref    int64  `json:"ref,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+type browserTool struct {
+	launcher    *browser.Launcher
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	BrowserToolName = "browser"
+
+	BrowserActionNavigate = "navigate"
+	BrowserActionSnapshot = "snapshot"
+	BrowserActionClick    = "click"
+	BrowserActionType     = "type"
+	BrowserActionPress    = "press"
+	BrowserActionText     = "text"
+	BrowserActionClose    = "close"
+
+	browserToolDescription = `Drives a real web browser (Chrome or Chromium) to load pages and interact with them like a user.
+
+WHEN TO USE THIS TOOL:
+- Use to check that a web app you changed actually renders and works, such as after editing a component or a route
+- Helpful for pages that need JavaScript to render, which the fetch tool can't show
+- Useful to reproduce a bug in the UI by clicking through the app
+
+HOW TO USE:
+- Start the development server first, for instance with the bash tool, then navigate to it
+- "navigate" opens a URL; "click", "type", and "press" act on the page; all of them return a snapshot of the page afterwards
+- "snapshot" returns the accessibility tree of the page: the role and name of the elements, with the ref to act on them
+- "click" clicks the element with the ref
+- "type" replaces the text of the field with the ref with the text; "press" then sends a key such as Enter to submit it
+- "text" returns the visible text of the element matching the CSS selector, or of the whole page
+- "close" closes the browser; it's launched again by the next action
+
+FEATURES:
+- Reports the HTTP status of the page when it's an error
+- Reports the console errors, uncaught exceptions, and failed requests of the page since the last action
+- Waits for the page to load and for scripts to finish rendering before returning
+
+LIMITATIONS:
+- Refs change when the page is reloaded or rerendered; take a new snapshot when one isn't found
+- Only one page is open at a time
+- Cannot see images or the layout; rely on the snapshot and the text
+
+TIPS:
+- Check the console errors after each action, they often explain a blank page
+- Prefer the snapshot over the text to find what to click`
+)
+
+func NewBrowserTool(permissions permission.Service, launcher *browser.Launcher, workingDir string) BaseTool {
+	return &browserTool{
+		launcher:    launcher,
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *browserTool) Name() string {
+	return BrowserToolName
+}
+
+func (t *browserTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        BrowserToolName,
+		Description: browserToolDescription,
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum": []string{
+					BrowserActionNavigate,
+					BrowserActionSnapshot,
+					BrowserActionClick,
+					BrowserActionType,
+					BrowserActionPress,
+					BrowserActionText,
+					BrowserActionClose,
+				},
+			},
+			"url": map[string]any{
+				"type":        "string",
+				"description": "The URL to navigate to",
+			},
+			"ref": map[string]any{
+				"type":        "number",
+				"description": "The ref of the element to click or type in, from the snapshot",
+			},
+			"text": map[string]any{
+				"type":        "string",
+				"description": "The text to type",
+			},
+			"key": map[string]any{
+				"type":        "string",
+				"description": "The key to press",
+				"enum":        browser.Keys(),
+			},
+			"selector": map[string]any{
+				"type":        "string",
+				"description": "Optional CSS selector of the element to get the text of",
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *browserTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params BrowserParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse browser parameters: " + err.Error()), nil
+	}
+
+	var description string
+	switch params.Action {
+	case BrowserActionNavigate:
+		if !strings.HasPrefix(params.URL, "http://") && !strings.HasPrefix(params.URL, "https://") && !strings.HasPrefix(params.URL, "file://") {
+			return NewTextErrorResponse("URL must start with http://, https://, or file://"), nil
+		}
+		description = fmt.Sprintf("Open URL in the browser: %s", params.URL)
+	case BrowserActionClick:
+		if params.Ref == 0 {
+			return NewTextErrorResponse("ref parameter is required to click"), nil
+		}
+		description = fmt.Sprintf("Click element %d in the browser", params.Ref)
+	case BrowserActionType:
+		if params.Ref == 0 {
+			return NewTextErrorResponse("ref parameter is required to type"), nil
+		}
+		description = fmt.Sprintf("Type in element %d in the browser: %s", params.Ref, params.Text)
+	case BrowserActionPress:
+		if params.Key == "" {
+			return NewTextErrorResponse("key parameter is required to press"), nil
+		}
+		description = fmt.Sprintf("Press %s in the browser", params.Key)
+	case BrowserActionSnapshot, BrowserActionText:
+	case BrowserActionClose:
+		if err := t.launcher.Close(); err != nil {
+			return NewTextErrorResponse("Failed to close the browser: " + err.Error()), nil
+		}
+		return NewTextResponse("Browser closed"), nil
+	default:
+		return NewTextErrorResponse("Action must be one of: navigate, snapshot, click, type, press, text, close"), nil
+	}
+
+	if description != "" {
+		sessionID, messageID := GetContextValues(ctx)
+		if sessionID == "" || messageID == "" {
+			return ToolResponse{}, fmt.Errorf("session ID and message ID are required for using the browser")
+		}
+		// Opening pages is asked separately from acting on them, as it
+		// sends data out.
+		action := "interact"
+		if params.Action == BrowserActionNavigate {
+			action = BrowserActionNavigate
+		}
+		p := t.permissions.Request(
+			permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        t.workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    BrowserToolName,
+				Action:      action,
+				Description: description,
+				Params: BrowserPermissionsParams{
+					Action: params.Action,
+					URL:    params.URL,
+					Ref:    params.Ref,
+					Text:   params.Text,
+					Key:    params.Key,
+				},
+			},
+		)
+		if !p {
+			return ToolResponse{}, permission.ErrorPermissionDenied
+		}
+	}
+
+	b, err := t.launcher.Browser(ctx)
+	if err != nil {
+		return NewTextErrorResponse("Failed to launch the browser: " + err.Error()), nil
+	}
+
+	var actionErr error
+	switch params.Action {
+	case BrowserActionNavigate:
+		actionErr = b.Navigate(ctx, params.URL)
+	case BrowserActionClick:
+		actionErr = b.Click(ctx, params.Ref)
+	case BrowserActionType:
+		actionErr = b.Type(ctx, params.Ref, params.Text)
+	case BrowserActionPress:
+		actionErr = b.Press(ctx, params.Key)
+	case BrowserActionText:
+		text, err := b.Text(ctx, params.Selector)
+		if err != nil {
+			return t.errorResponse(err), nil
+		}
+		return NewTextResponse(truncateBrowserOutput(text)), nil
+	}
+	if errors.Is(actionErr, browser.ErrClosed) {
+		return t.errorResponse(actionErr), nil
+	}
+
+	page, err := b.Page(ctx)
+	if err != nil {
+		return t.errorResponse(err), nil
+	}
+	snapshot, err := b.Snapshot(ctx)
+	if err != nil {
+		return t.errorResponse(err), nil
+	}
+
+	var sb strings.Builder
+	if actionErr != nil {
+		fmt.Fprintf(&sb, "Error: %s\n\n", actionErr)
+	}
+	fmt.Fprintf(&sb, "Page: %s (%s)\n", page.Title, page.URL)
+	if page.Status >= 400 {
+		fmt.Fprintf(&sb, "Status: %d\n", page.Status)
+	}
+	if messages := b.Messages(); len(messages) > 0 {
+		sb.WriteString("\nConsole errors:\n")
+		for _, msg := range messages {
+			fmt.Fprintf(&sb, "- %s\n", msg)
+		}
+	}
+	sb.WriteString("\n")
+	sb.WriteString(snapshot)
+
+	response := NewTextResponse(truncateBrowserOutput(sb.String()))
+	if params.Action == BrowserActionNavigate && actionErr == nil {
+		response = WithResponseCitations(response, message.Citation{Source: page.URL, Title: page.Title})
+	}
+	return response, nil
+}
+
+func (t *browserTool) errorResponse(err error) ToolResponse {
+	if errors.Is(err, browser.ErrClosed) {
+		return NewTextErrorResponse("The browser was closed or crashed; it will be launched again by the next action")
+	}
+	return NewTextErrorResponse(err.Error())
+}
+
+func truncateBrowserOutput(content string) string {
+	if len(content) > MaxReadSize {
+		content = content[:MaxReadSize] + fmt.Sprintf("\n\n[Content truncated to %d bytes]", MaxReadSize)
+	}
+	return content
+}
@@ -0,0 +1,369 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/lsp/util"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+// ChangedFile is the change of a file by a tool changing several files at
+// once, recorded as a change set.
+type ChangedFile struct {
+	FilePath string `json:"file_path"`
+	// OldPath is set when the file was renamed.
+	OldPath    string `json:"old_path,omitempty"`
+	OldContent string `json:"old_content,omitempty"`
+	NewContent string `json:"new_content,omitempty"`
+	Created    bool   `json:"created,omitempty"`
+	Deleted    bool   `json:"deleted,omitempty"`
+	Additions  int    `json:"additions"`
+	Removals   int    `json:"removals"`
+}
+
+type ChangeSetResponseMetadata struct {
+	Files     []ChangedFile `json:"files"`
+	Additions int           `json:"additions"`
+	Removals  int           `json:"removals"`
+}
+
+// changeSetWriter writes the changes of the tools changing several files at
+// once, once the user reviewed them together.
+type changeSetWriter struct {
+	lspClients  map[string]*lsp.Client
+	permissions permission.Service
+	files       history.Service
+	workingDir  string
+}
+
+// write asks for the permission to make the changes, writes them, and
+// records them as a change set.
+func (w changeSetWriter) write(ctx context.Context, call ToolCall, description, summary string, changes []fileChange) (ToolResponse, error) {
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for editing files")
+	}
+
+	// A single request shows the combined diff, the rules apply to each of
+	// its files.
+	permissionPath := w.workingDir
+	files := make([]ChangedFile, len(changes))
+	for i, change := range changes {
+		files[i] = change.ChangedFile
+		if !fsext.HasPrefix(change.FilePath, w.workingDir) {
+			permissionPath = filepath.Dir(change.FilePath)
+		}
+	}
+	granted := w.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   sessionID,
+		Path:        permissionPath,
+		ToolCallID:  call.ID,
+		ToolName:    call.Name,
+		Action:      "write",
+		Description: description,
+		Params:      TransactionPermissionsParams{Files: files},
+	})
+	if !granted {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	if err := writeChanges(changes); err != nil {
+		return ToolResponse{}, err
+	}
+	recordChangeSet(ctx, w.files, sessionID, changes)
+	return changeSetResponse(ctx, summary, changes, w.lspClients, w.workingDir), nil
+}
+
+// fileChange is the change of a file, computed before writing any.
+type fileChange struct {
+	ChangedFile
+	// existed is whether the file at FilePath existed before the change.
+	existed bool
+}
+
+// writeChanges writes the changes, restoring the files already changed if
+// one fails.
+func writeChanges(changes []fileChange) (err error) {
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				slog.Error("Failed to restore file after a failed change", "error", undoErr)
+			}
+		}
+	}()
+
+	restore := func(path, content string, existed bool) func() error {
+		if !existed {
+			return func() error { return os.Remove(path) }
+		}
+		return func() error { return os.WriteFile(path, []byte(content), 0o644) }
+	}
+	for _, change := range changes {
+		if change.Deleted {
+			if err := os.Remove(change.FilePath); err != nil {
+				return fmt.Errorf("failed to delete file: %w", err)
+			}
+			undo = append(undo, restore(change.FilePath, change.OldContent, true))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(change.FilePath), 0o755); err != nil {
+			return fmt.Errorf("failed to create parent directories: %w", err)
+		}
+		// A failed write may leave the file half written, restore it too.
+		undo = append(undo, restore(change.FilePath, change.OldContent, change.existed))
+		if err := os.WriteFile(change.FilePath, []byte(change.NewContent), 0o644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		if change.OldPath != "" {
+			if err := os.Remove(change.OldPath); err != nil {
+				return fmt.Errorf("failed to remove renamed file: %w", err)
+			}
+			undo = append(undo, restore(change.OldPath, change.OldContent, true))
+		}
+	}
+	return nil
+}
+
+// workspaceEditChanges applies the edits of a language server to the files
+// in memory. Creating, renaming, and deleting files isn't supported.
+func workspaceEditChanges(edit protocol.WorkspaceEdit, workingDir string) ([]fileChange, error) {
+	byPath := map[string]*fileChange{}
+	var paths []string
+	apply := func(uri protocol.DocumentURI, edits []protocol.TextEdit) error {
+		path, err := uri.Path()
+		if err != nil {
+			return fmt.Errorf("invalid URI: %w", err)
+		}
+		change, ok := byPath[path]
+		if !ok {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			change = &fileChange{
+				ChangedFile: ChangedFile{FilePath: path, OldContent: string(content), NewContent: string(content)},
+				existed:     true,
+			}
+			byPath[path] = change
+			paths = append(paths, path)
+		}
+		content, err := util.ApplyTextEditsToContent([]byte(change.NewContent), edits)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		change.NewContent = string(content)
+		return nil
+	}
+
+	for _, uri := range slices.Sorted(maps.Keys(edit.Changes)) {
+		if err := apply(uri, edit.Changes[uri]); err != nil {
+			return nil, err
+		}
+	}
+	for _, documentChange := range edit.DocumentChanges {
+		if documentChange.TextDocumentEdit == nil {
+			return nil, errors.New("the language server wants to create, rename, or delete files, which isn't supported")
+		}
+		edits := make([]protocol.TextEdit, len(documentChange.TextDocumentEdit.Edits))
+		for i, e := range documentChange.TextDocumentEdit.Edits {
+			var err error
+			if edits[i], err = e.AsTextEdit(); err != nil {
+				return nil, fmt.Errorf("invalid edit: %w", err)
+			}
+		}
+		if err := apply(documentChange.TextDocumentEdit.TextDocument.URI, edits); err != nil {
+			return nil, err
+		}
+	}
+
+	var changes []fileChange
+	for _, path := range paths {
+		change := byPath[path]
+		if change.NewContent == change.OldContent {
+			continue
+		}
+		_, change.Additions, change.Removals = diff.GenerateDiff(change.OldContent, change.NewContent, strings.TrimPrefix(path, workingDir))
+		changes = append(changes, *change)
+	}
+	return changes, nil
+}
+
+// changeSetResponse lists the changed files after the summary, with the
+// diagnostics of the files written.
+func changeSetResponse(ctx context.Context, summary string, changes []fileChange, lspClients map[string]*lsp.Client, workingDir string) ToolResponse {
+	meta := ChangeSetResponseMetadata{}
+	var sb strings.Builder
+	sb.WriteString(summary + ":\n")
+	var lastWritten string
+	for _, change := range changes {
+		if !change.Deleted {
+			recordFileWrite(change.FilePath)
+			recordFileRead(change.FilePath)
+		}
+
+		status := "M"
+		switch {
+		case change.Created:
+			status = "A"
+		case change.Deleted:
+			status = "D"
+		case change.OldPath != "":
+			status = "R " + change.OldPath + " ->"
+		}
+		fmt.Fprintf(&sb, "%s %s (+%d -%d)\n", status, change.FilePath, change.Additions, change.Removals)
+
+		meta.Files = append(meta.Files, change.ChangedFile)
+		meta.Additions += change.Additions
+		meta.Removals += change.Removals
+		if !change.Deleted {
+			lastWritten = change.FilePath
+		}
+	}
+
+	text := fmt.Sprintf("<result>\n%s</result>\n", sb.String())
+	if lastWritten != "" {
+		for _, change := range changes {
+			if !change.Deleted {
+				waitForLspDiagnostics(ctx, change.FilePath, lspClients)
+			}
+		}
+		// The diagnostics of the other files are among those of the project.
+		text += getDiagnostics(lastWritten, lspClients)
+		for _, change := range changes {
+			if !change.Deleted {
+				text += checkFileGlossary(workingDir, change.FilePath)
+			}
+		}
+	}
+	return WithResponseMetadata(NewTextResponse(text), meta)
+}
+
+// recordChangeSet stores the versions of the changed files in the history,
+// grouped in a change set so they can be undone together.
+func recordChangeSet(ctx context.Context, files history.Service, sessionID string, changes []fileChange) {
+	var changed []history.ChangeSetFile
+	record := func(path, oldContent, newContent string, created, deleted bool) error {
+		before, after, err := recordVersions(ctx, files, sessionID, path, oldContent, newContent)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, history.ChangeSetFile{
+			Path:     path,
+			BeforeID: before.ID,
+			AfterID:  after.ID,
+			Created:  created,
+			Deleted:  deleted,
+		})
+		return nil
+	}
+	for _, change := range changes {
+		var err error
+		if change.OldPath != "" {
+			err = record(change.OldPath, change.OldContent, "", false, true)
+			if err == nil {
+				err = record(change.FilePath, "", change.NewContent, true, false)
+			}
+		} else {
+			err = record(change.FilePath, change.OldContent, change.NewContent, change.Created, change.Deleted)
+		}
+		if err != nil {
+			// A partial change set couldn't be undone as a whole.
+			slog.Debug("Error creating file history", "error", err)
+			return
+		}
+	}
+	if _, err := files.CreateChangeSet(ctx, sessionID, changed); err != nil {
+		slog.Debug("Error creating change set", "error", err)
+	}
+}
+
+// recordVersions stores the versions of a changed file before and after the
+// change.
+func recordVersions(ctx context.Context, files history.Service, sessionID, path, oldContent, newContent string) (before, after history.File, err error) {
+	file, err := files.GetByPathAndSession(ctx, path, sessionID)
+	switch {
+	case err != nil:
+		before, err = files.Create(ctx, sessionID, path, oldContent)
+	case file.Content != oldContent:
+		// User manually changed the content, store an intermediate version
+		before, err = files.CreateVersion(ctx, sessionID, path, oldContent)
+	default:
+		before = file
+	}
+	if err != nil {
+		return before, after, err
+	}
+	after, err = files.CreateVersion(ctx, sessionID, path, newContent)
+	return before, after, err
+}
+
+// UndoChangeSet restores the files of the last change set of the session
+// from the file history. Nothing is changed if a file of the change set was
+// changed since.
+func UndoChangeSet(ctx context.Context, files history.Service, sessionID string) (history.ChangeSet, error) {
+	changeSet, err := files.LatestChangeSet(ctx, sessionID)
+	if err != nil {
+		return changeSet, err
+	}
+
+	var changes []fileChange
+	for _, file := range changeSet.Files {
+		before, err := files.Get(ctx, file.BeforeID)
+		if err != nil {
+			return changeSet, fmt.Errorf("failed to get the previous version of %s: %w", file.Path, err)
+		}
+		after, err := files.Get(ctx, file.AfterID)
+		if err != nil {
+			return changeSet, fmt.Errorf("failed to get the changed version of %s: %w", file.Path, err)
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return changeSet, fmt.Errorf("failed to read file: %w", err)
+		}
+		exists := err == nil
+		if exists == file.Deleted || string(content) != after.Content {
+			return changeSet, fmt.Errorf("%s was changed after the change set, undo it by hand", file.Path)
+		}
+		// Undoing a change is applying the reverse change.
+		changes = append(changes, fileChange{
+			ChangedFile: ChangedFile{
+				FilePath:   file.Path,
+				OldContent: after.Content,
+				NewContent: before.Content,
+				Created:    file.Deleted,
+				Deleted:    file.Created,
+			},
+			existed: exists,
+		})
+	}
+
+	if err := writeChanges(changes); err != nil {
+		return changeSet, err
+	}
+	for _, change := range changes {
+		if _, _, err := recordVersions(ctx, files, sessionID, change.FilePath, change.OldContent, change.NewContent); err != nil {
+			slog.Debug("Error creating file history", "error", err)
+		}
+	}
+	if err := files.MarkChangeSetReverted(ctx, changeSet.ID); err != nil {
+		return changeSet, fmt.Errorf("failed to mark the change set as reverted: %w", err)
+	}
+	return changeSet, nil
+}
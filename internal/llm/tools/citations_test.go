@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewCitations(t *testing.T) {
+	ctx, dir, permissions, _ := newFileToolTest(t, map[string]string{
+		"notes.txt": "one\ntwo\nthree\nfour\n",
+	})
+	tool := NewViewTool(nil, permissions, dir)
+
+	resp, err := tool.Run(ctx, ToolCall{ID: "call", Name: ViewToolName, Input: `{"file_path": "notes.txt", "offset": 1, "limit": 2}`})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+	require.Equal(t, []message.Citation{{
+		Source:   filepath.Join(dir, "notes.txt"),
+		Location: "lines 2-3",
+	}}, resp.Citations)
+}
+
+func TestFetchCitations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "release notes")
+	}))
+	t.Cleanup(server.Close)
+	ctx, dir, permissions, _ := newFileToolTest(t, nil)
+	tool := NewFetchTool(permissions, dir)
+
+	url := server.URL + "/notes.txt"
+	resp, err := tool.Run(ctx, ToolCall{ID: "call", Name: FetchToolName, Input: fmt.Sprintf(`{"url": %q, "format": "text"}`, url)})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+	require.Equal(t, []message.Citation{{Source: url}}, resp.Citations)
+}
+
+func TestSourcegraphCitations(t *testing.T) {
+	const response = `{"data": {"search": {"results": {"matchCount": 2, "resultCount": 2, "results": [
+		{"__typename": "FileMatch", "repository": {"name": "github.com/charmbracelet/crush"},
+		 "file": {"path": "main.go", "url": "/github.com/charmbracelet/crush/-/blob/main.go", "content": "package main\n\nfunc main() {}\n"},
+		 "lineMatches": [{"preview": "func main() {}", "lineNumber": 2}]},
+		{"__typename": "FileMatch", "repository": {"name": "github.com/charmbracelet/crush"},
+		 "file": {"path": "go.mod", "url": "", "content": ""},
+		 "lineMatches": [{"preview": "module github.com/charmbracelet/crush", "lineNumber": 0}]}
+	]}}}}`
+	var result map[string]any
+	require.NoError(t, json.Unmarshal([]byte(response), &result))
+
+	output, citations, err := formatSourcegraphResults(result, 1)
+	require.NoError(t, err)
+	// The lines count from 1 in the output and the citations.
+	require.Contains(t, output, "2| \n3|  func main() {}\n")
+	require.Contains(t, output, "1| module github.com/charmbracelet/crush\n")
+	// The results without a URL have nothing to cite.
+	require.Equal(t, []message.Citation{{
+		Source:   "https://sourcegraph.com/github.com/charmbracelet/crush/-/blob/main.go",
+		Location: "line 3",
+		Title:    "github.com/charmbracelet/crush/main.go",
+	}}, citations)
+}
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type CodeActionParams struct {
+	FilePath        string `json:"file_path"`
+	Line            int    `json:"line,omitempty"`
+	EndLine         int    `json:"end_line,omitempty"`
+	Title           string `json:"title,omitempty"`
+	OrganizeImports bool   `json:"organize_imports,omitempty"`
+}
+
+type codeActionTool struct {
+	changeSetWriter
+}
+
+const (
+	CodeActionToolName    = "code_action"
+	codeActionDescription = `Lists and applies the code actions of the language server, like the quick fixes and refactorings of an editor.
+
+WHEN TO USE THIS TOOL:
+- Use it to fix the diagnostics the language server has a quick fix for, like a missing import or an unused variable
+- Use it for the refactorings of the language server, like extracting a function or a variable, inlining a call, or filling a struct
+- Use it to organize the imports of a file after editing it
+
+HOW TO USE:
+- Give the file and the line, starting at 1, and end_line for a range of lines, to list the code actions available there with their kind
+- Give the title of one of the listed actions to apply it
+- Set organize_imports to organize the imports of the file, without line or title
+- The user reviews the changes of all the files at once, and can undo them together
+
+LIMITATIONS:
+- Only works for the languages with a language server configured
+- The actions running a command of the language server instead of changing files can't be applied
+`
+)
+
+func NewCodeActionTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &codeActionTool{
+		changeSetWriter: changeSetWriter{
+			lspClients:  lspClients,
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (c *codeActionTool) Name() string {
+	return CodeActionToolName
+}
+
+func (c *codeActionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        CodeActionToolName,
+		Description: codeActionDescription,
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path of the file",
+			},
+			"line": map[string]any{
+				"type":        "integer",
+				"description": "The line to list or apply the code actions of, starting at 1",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "The last line of the range, defaults to line",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "The title of the code action to apply, as listed",
+			},
+			"organize_imports": map[string]any{
+				"type":        "boolean",
+				"description": "Organize the imports of the file",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (c *codeActionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params CodeActionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if len(c.lspClients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+	params.FilePath = absPath(params.FilePath, c.workingDir)
+	content, err := os.ReadFile(params.FilePath)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to read file: %s", err)), nil
+	}
+	lines := strings.Count(string(content), "\n") + 1
+
+	// The range covers whole lines: from the start of the first to the start
+	// of the line after the last.
+	var only []protocol.CodeActionKind
+	start, end := 1, lines
+	if params.OrganizeImports {
+		only = []protocol.CodeActionKind{protocol.SourceOrganizeImports}
+	} else {
+		start, end = params.Line, max(params.EndLine, params.Line)
+		if start < 1 || end > lines {
+			return NewTextErrorResponse(fmt.Sprintf("line %d is out of the file, which has %d lines", max(start, end), lines)), nil
+		}
+	}
+	uri := protocol.URIFromPath(params.FilePath)
+	codeRange := protocol.Range{
+		Start: protocol.Position{Line: uint32(start - 1)},
+		End:   protocol.Position{Line: uint32(end)},
+	}
+
+	actions, err := queryClients(ctx, c.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.CodeAction, error) {
+		var diagnostics []protocol.Diagnostic
+		for _, diagnostic := range client.GetFileDiagnostics(uri) {
+			if diagnostic.Range.Start.Line < codeRange.End.Line && diagnostic.Range.End.Line >= codeRange.Start.Line {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+		result, err := client.CodeAction(ctx, protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Range:        codeRange,
+			Context:      protocol.CodeActionContext{Diagnostics: diagnostics, Only: only},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Bare commands change nothing the user could review.
+		var actions []protocol.CodeAction
+		for _, elem := range result {
+			if action, ok := elem.Value.(protocol.CodeAction); ok {
+				actions = append(actions, action)
+			}
+		}
+		return actions, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to get the code actions: %s", err)), nil
+	}
+
+	switch {
+	case params.OrganizeImports:
+		if len(actions) == 0 {
+			return NewTextResponse("The imports are already organized"), nil
+		}
+		return c.apply(ctx, call, actions[0])
+	case params.Title != "":
+		for _, action := range actions {
+			if action.Title == params.Title {
+				return c.apply(ctx, call, action)
+			}
+		}
+		return NewTextErrorResponse(fmt.Sprintf("no code action %q here, %s", params.Title, formatCodeActions(actions))), nil
+	}
+	return NewTextResponse(formatCodeActions(actions)), nil
+}
+
+// apply writes the changes of the action, resolving them first when the
+// server computes them lazily.
+func (c *codeActionTool) apply(ctx context.Context, call ToolCall, action protocol.CodeAction) (ToolResponse, error) {
+	if action.Disabled != nil {
+		return NewTextErrorResponse(fmt.Sprintf("%q can't be applied: %s", action.Title, action.Disabled.Reason)), nil
+	}
+	if action.Edit == nil && action.Data != nil {
+		for _, name := range slices.Sorted(maps.Keys(c.lspClients)) {
+			resolved, err := c.lspClients[name].ResolveCodeAction(ctx, action)
+			if err == nil && resolved.Edit != nil {
+				action = resolved
+				break
+			}
+		}
+	}
+	if action.Edit == nil {
+		if action.Command != nil {
+			return NewTextErrorResponse(fmt.Sprintf("%q runs the %s command of the language server, which isn't supported", action.Title, action.Command.Command)), nil
+		}
+		return NewTextErrorResponse(fmt.Sprintf("%q doesn't change any file", action.Title)), nil
+	}
+
+	changes, err := workspaceEditChanges(*action.Edit, c.workingDir)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to apply %q: %s", action.Title, err)), nil
+	}
+	if len(changes) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("%q doesn't change any file", action.Title)), nil
+	}
+	return c.write(ctx, call,
+		fmt.Sprintf("Apply %q to %d files", action.Title, len(changes)),
+		fmt.Sprintf("Applied %q to %d files", action.Title, len(changes)),
+		changes)
+}
+
+func formatCodeActions(actions []protocol.CodeAction) string {
+	if len(actions) == 0 {
+		return "no code actions available"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d code actions available:", len(actions))
+	for _, action := range actions {
+		fmt.Fprintf(&sb, "\n- %s", action.Title)
+		if action.Kind != "" {
+			fmt.Fprintf(&sb, " (%s)", action.Kind)
+		}
+		if action.IsPreferred {
+			sb.WriteString(" [preferred]")
+		}
+		if action.Disabled != nil {
+			fmt.Fprintf(&sb, " [disabled: %s]", action.Disabled.Reason)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,139 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/codesearch"
+)
+
+type CodeSearchParams struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type codeSearchTool struct {
+	index *codesearch.Index
+}
+
+const (
+	CodeSearchToolName = "codesearch"
+
+	codeSearchDefaultLimit = 10
+	codeSearchMaxLimit     = 30
+	// codeSearchSnippetLines is the number of lines shown of each result.
+	codeSearchSnippetLines = 20
+
+	codeSearchToolDescription = `Searches the code of the project by meaning, using an index of its embeddings.
+
+WHEN TO USE THIS TOOL:
+- Use when you don't know the names to grep for, such as where a feature is implemented
+- Helpful for questions like "where are users authenticated" or "how are retries handled"
+- Useful to find code similar to what you're about to write, to follow its conventions
+
+HOW TO USE:
+- Describe what the code does in plain words, or paste a snippet similar to what you look for
+- Optionally set the number of results to return
+- Read the files of the best results with the view tool
+
+FEATURES:
+- Returns the file and lines of the closest chunks of code, the closest first, with their beginning
+- Indexes the files modified since the last search before searching
+
+LIMITATIONS:
+- Results are approximate; the best ones may not contain what you look for
+- Files ignored by .gitignore and .crushignore, binary files, and files over 1MB aren't indexed
+
+TIPS:
+- Use grep rather than this tool when you know an exact name or string
+- Rephrase the query with other words when the results don't fit`
+)
+
+func NewCodeSearchTool(index *codesearch.Index) BaseTool {
+	return &codeSearchTool{index: index}
+}
+
+func (t *codeSearchTool) Name() string {
+	return CodeSearchToolName
+}
+
+func (t *codeSearchTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        CodeSearchToolName,
+		Description: codeSearchToolDescription,
+		Parameters: map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "What the code looks for does, or a snippet similar to it",
+			},
+			"limit": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("Optional number of results to return (default %d, max %d)", codeSearchDefaultLimit, codeSearchMaxLimit),
+			},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (t *codeSearchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params CodeSearchParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse codesearch parameters: " + err.Error()), nil
+	}
+
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return NewTextErrorResponse("Query parameter is required"), nil
+	}
+	if params.Limit <= 0 {
+		params.Limit = codeSearchDefaultLimit
+	}
+	params.Limit = min(params.Limit, codeSearchMaxLimit)
+
+	var note string
+	_, err := t.index.Update(ctx, func(done, total int) {
+		ReportProgress(ctx, Progress{
+			Message: "Indexing the project",
+			Current: int64(done),
+			Total:   int64(total),
+		})
+	})
+	switch {
+	case errors.Is(err, codesearch.ErrUpdating):
+		note = "The project is being indexed, results may be incomplete."
+	case err != nil:
+		if ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+		note = "The index may be out of date: " + err.Error()
+	}
+
+	results, err := t.index.Search(ctx, params.Query, params.Limit)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var sb strings.Builder
+	if note != "" {
+		sb.WriteString(note + "\n\n")
+	}
+	if len(results) == 0 {
+		sb.WriteString("No results found")
+		return NewTextResponse(sb.String()), nil
+	}
+
+	fmt.Fprintf(&sb, "Found %d results:\n", len(results))
+	for _, r := range results {
+		fmt.Fprintf(&sb, "\n%s:%d-%d (score %.2f)\n", r.Path, r.StartLine, r.EndLine, r.Score)
+		lines := strings.Split(r.Content, "\n")
+		if len(lines) > codeSearchSnippetLines {
+			lines = append(lines[:codeSearchSnippetLines], "...")
+		}
+		sb.WriteString("```\n" + strings.Join(lines, "\n") + "\n```\n")
+	}
+
+	return NewTextResponse(strings.TrimSuffix(sb.String(), "\n")), nil
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type DefinitionParams = SymbolPositionParams
+
+type definitionTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	DefinitionToolName    = "definition"
+	definitionDescription = `Finds where a symbol used in a file is defined, with the language server, like go to definition in an editor.
+
+WHEN TO USE THIS TOOL:
+- Use it to jump from a call, type, or variable to its declaration, even in another package or a dependency
+- Prefer it over grep, which can't tell apart the symbols of the same name
+
+HOW TO USE:
+- Give the file, the line the symbol is on, starting at 1, and the symbol as written on the line
+- The result is the path and line of each definition with the text of the line, view the file there to read it
+
+LIMITATIONS:
+- Only works for the languages with a language server configured
+`
+)
+
+func NewDefinitionTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &definitionTool{lspClients: lspClients, workingDir: workingDir}
+}
+
+func (d *definitionTool) Name() string {
+	return DefinitionToolName
+}
+
+func (d *definitionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        DefinitionToolName,
+		Description: definitionDescription,
+		Parameters:  symbolPositionParameters,
+		Required:    []string{"file_path", "line", "symbol"},
+	}
+}
+
+func (d *definitionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params DefinitionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if len(d.lspClients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+	params.FilePath = absPath(params.FilePath, d.workingDir)
+	position, err := symbolPosition(params)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	definitions, err := queryClients(ctx, d.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.Location, error) {
+		result, err := client.Definition(ctx, protocol.DefinitionParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+				Position:     position,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		switch value := result.Value.(type) {
+		case protocol.Definition:
+			switch locations := value.Value.(type) {
+			case protocol.Location:
+				return []protocol.Location{locations}, nil
+			case []protocol.Location:
+				return locations, nil
+			}
+		case []protocol.DefinitionLink:
+			locations := make([]protocol.Location, len(value))
+			for i, link := range value {
+				locations[i] = protocol.Location{URI: link.TargetURI, Range: link.TargetSelectionRange}
+			}
+			return locations, nil
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to find the definition: %s", err)), nil
+	}
+	if len(definitions) == 0 {
+		return NewTextResponse(fmt.Sprintf("No definition found for %s", params.Symbol)), nil
+	}
+
+	formatter := newLocationFormatter(d.workingDir)
+	lines := make([]string, len(definitions))
+	for i, location := range definitions {
+		lines[i] = formatter.format(location.URI, location.Range)
+	}
+	return NewTextResponse(strings.Join(lines, "\n")), nil
+}
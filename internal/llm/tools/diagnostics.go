@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"maps"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -169,56 +170,6 @@ func getDiagnostics(filePath string, lsps map[string]*lsp.Client) string {
 	fileDiagnostics := []string{}
 	projectDiagnostics := []string{}
 
-	formatDiagnostic := func(pth string, diagnostic protocol.Diagnostic, source string) string {
-		severity := "Info"
-		switch diagnostic.Severity {
-		case protocol.SeverityError:
-			severity = "Error"
-		case protocol.SeverityWarning:
-			severity = "Warn"
-		case protocol.SeverityHint:
-			severity = "Hint"
-		}
-
-		location := fmt.Sprintf("%s:%d:%d", pth, diagnostic.Range.Start.Line+1, diagnostic.Range.Start.Character+1)
-
-		sourceInfo := ""
-		if diagnostic.Source != "" {
-			sourceInfo = diagnostic.Source
-		} else if source != "" {
-			sourceInfo = source
-		}
-
-		codeInfo := ""
-		if diagnostic.Code != nil {
-			codeInfo = fmt.Sprintf("[%v]", diagnostic.Code)
-		}
-
-		tagsInfo := ""
-		if len(diagnostic.Tags) > 0 {
-			tags := []string{}
-			for _, tag := range diagnostic.Tags {
-				switch tag {
-				case protocol.Unnecessary:
-					tags = append(tags, "unnecessary")
-				case protocol.Deprecated:
-					tags = append(tags, "deprecated")
-				}
-			}
-			if len(tags) > 0 {
-				tagsInfo = fmt.Sprintf(" (%s)", strings.Join(tags, ", "))
-			}
-		}
-
-		return fmt.Sprintf("%s: %s [%s]%s%s %s",
-			severity,
-			location,
-			sourceInfo,
-			codeInfo,
-			tagsInfo,
-			diagnostic.Message)
-	}
-
 	for lspName, client := range lsps {
 		diagnostics := client.GetDiagnostics()
 		if len(diagnostics) > 0 {
@@ -243,23 +194,8 @@ func getDiagnostics(filePath string, lsps map[string]*lsp.Client) string {
 		}
 	}
 
-	sort.Slice(fileDiagnostics, func(i, j int) bool {
-		iIsError := strings.HasPrefix(fileDiagnostics[i], "Error")
-		jIsError := strings.HasPrefix(fileDiagnostics[j], "Error")
-		if iIsError != jIsError {
-			return iIsError // Errors come first
-		}
-		return fileDiagnostics[i] < fileDiagnostics[j] // Then alphabetically
-	})
-
-	sort.Slice(projectDiagnostics, func(i, j int) bool {
-		iIsError := strings.HasPrefix(projectDiagnostics[i], "Error")
-		jIsError := strings.HasPrefix(projectDiagnostics[j], "Error")
-		if iIsError != jIsError {
-			return iIsError
-		}
-		return projectDiagnostics[i] < projectDiagnostics[j]
-	})
+	sortDiagnostics(fileDiagnostics)
+	sortDiagnostics(projectDiagnostics)
 
 	var output strings.Builder
 
@@ -300,6 +236,89 @@ func getDiagnostics(filePath string, lsps map[string]*lsp.Client) string {
 	return output.String()
 }
 
+func formatDiagnostic(pth string, diagnostic protocol.Diagnostic, source string) string {
+	severity := "Info"
+	switch diagnostic.Severity {
+	case protocol.SeverityError:
+		severity = "Error"
+	case protocol.SeverityWarning:
+		severity = "Warn"
+	case protocol.SeverityHint:
+		severity = "Hint"
+	}
+
+	location := fmt.Sprintf("%s:%d:%d", pth, diagnostic.Range.Start.Line+1, diagnostic.Range.Start.Character+1)
+
+	sourceInfo := ""
+	if diagnostic.Source != "" {
+		sourceInfo = diagnostic.Source
+	} else if source != "" {
+		sourceInfo = source
+	}
+
+	codeInfo := ""
+	if diagnostic.Code != nil {
+		codeInfo = fmt.Sprintf("[%v]", diagnostic.Code)
+	}
+
+	tagsInfo := ""
+	if len(diagnostic.Tags) > 0 {
+		tags := []string{}
+		for _, tag := range diagnostic.Tags {
+			switch tag {
+			case protocol.Unnecessary:
+				tags = append(tags, "unnecessary")
+			case protocol.Deprecated:
+				tags = append(tags, "deprecated")
+			}
+		}
+		if len(tags) > 0 {
+			tagsInfo = fmt.Sprintf(" (%s)", strings.Join(tags, ", "))
+		}
+	}
+
+	return fmt.Sprintf("%s: %s [%s]%s%s %s",
+		severity,
+		location,
+		sourceInfo,
+		codeInfo,
+		tagsInfo,
+		diagnostic.Message)
+}
+
+// FileDiagnostics returns the diagnostics of the files with at least the
+// severity, the errors first.
+func FileDiagnostics(lsps map[string]*lsp.Client, paths []string, severity protocol.DiagnosticSeverity) []string {
+	var diagnostics []string
+	for lspName, client := range lsps {
+		for location, diags := range client.GetDiagnostics() {
+			path, err := location.Path()
+			if err != nil || !slices.Contains(paths, path) {
+				continue
+			}
+			for _, diag := range diags {
+				// A missing severity is up to the client, count it as an error.
+				if diag.Severity == 0 || diag.Severity <= severity {
+					diagnostics = append(diagnostics, formatDiagnostic(path, diag, lspName))
+				}
+			}
+		}
+	}
+	sortDiagnostics(diagnostics)
+	return diagnostics
+}
+
+func sortDiagnostics(diagnostics []string) {
+	sort.Slice(diagnostics, func(i, j int) bool {
+		iIsError := strings.HasPrefix(diagnostics[i], "Error")
+		jIsError := strings.HasPrefix(diagnostics[j], "Error")
+		if iIsError != jIsError {
+			return iIsError // Errors come first
+		}
+		return diagnostics[i] < diagnostics[j] // Then alphabetically
+	})
+}
+
 func countSeverity(diagnostics []string, severity string) int {
 	count := 0
 	for _, diag := range diagnostics {
@@ -0,0 +1,288 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/docker"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type DockerParams struct {
+	Action    string   `json:"action"`
+	Container string   `json:"container,omitempty"`
+	Service   string   `json:"service,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	All       bool     `json:"all,omitempty"`
+	Tail      int      `json:"tail,omitempty"`
+	Since     string   `json:"since,omitempty"`
+	Build     bool     `json:"build,omitempty"`
+	Timeout   int      `json:"timeout,omitempty"`
+}
+
+type DockerPermissionsParams struct {
+	Action    string   `json:"action"`
+	Container string   `json:"container,omitempty"`
+	Service   string   `json:"service,omitempty"`
+	Services  []string `json:"services,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	Build     bool     `json:"build,omitempty"`
+}
+
+type dockerTool struct {
+	client      *docker.Client
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	DockerToolName = "docker"
+
+	dockerActionPs   = "ps"
+	dockerActionLogs = "logs"
+	dockerActionUp   = "up"
+	dockerActionExec = "exec"
+
+	dockerDefaultTail = 100
+	dockerMaxTail     = 1000
+	dockerUpTimeout   = 10 * time.Minute
+
+	dockerToolDescription = `Inspects the containers of the development environment, reads their logs, and starts and runs commands in the docker compose services of the project.
+
+WHEN TO USE THIS TOOL:
+- Use to check which containers are running, and whether they are healthy
+- Helpful to read the logs of a service while debugging it
+- Useful to start the services an application depends on, such as its database
+- Use to run a command inside a container, such as a database shell or a test
+
+HOW TO USE:
+- Use the ps action to list the running containers, or all of them with all
+- Use the logs action to read the last lines of the logs of a container or a compose service, optionally since a duration such as 10m
+- Use the up action to start the compose services of the project, or only some, in the background; set build to rebuild their images
+- Use the exec action to run a shell command in a running container or compose service
+
+FEATURES:
+- Containers of compose projects are listed with their project and service
+- Starting services and running commands need the permission of the user
+- Up waits for the services to be running, or healthy with a healthcheck
+
+LIMITATIONS:
+- Commands run with sh without a terminal, so interactive commands don't work
+- The exec timeout defaults to 1 minute and can't exceed 10 minutes
+- Logs are limited to the last %d lines, and outputs longer than %d characters are cut
+
+TIPS:
+- Check the logs of a failing service before restarting it
+- Prefer exec over running docker through the bash tool, which needs the permission of the user for every command`
+)
+
+func NewDockerTool(permissions permission.Service, client *docker.Client, workingDir string) BaseTool {
+	return &dockerTool{
+		client:      client,
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *dockerTool) Name() string {
+	return DockerToolName
+}
+
+func (t *dockerTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        DockerToolName,
+		Description: fmt.Sprintf(dockerToolDescription, dockerMaxTail, MaxOutputLength),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum":        []string{dockerActionPs, dockerActionLogs, dockerActionUp, dockerActionExec},
+			},
+			"container": map[string]any{
+				"type":        "string",
+				"description": "The name or ID of the container (logs, exec)",
+			},
+			"service": map[string]any{
+				"type":        "string",
+				"description": "The compose service, instead of a container (logs, exec)",
+			},
+			"services": map[string]any{
+				"type":        "array",
+				"description": "The compose services to start, all of them without it (up)",
+				"items": map[string]any{
+					"type": "string",
+				},
+			},
+			"command": map[string]any{
+				"type":        "string",
+				"description": "The shell command to run (exec)",
+			},
+			"all": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to list the stopped containers too (ps)",
+			},
+			"tail": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("The number of lines to read from the end of the logs, %d by default (logs)", dockerDefaultTail),
+			},
+			"since": map[string]any{
+				"type":        "string",
+				"description": "Only read the logs since a duration such as 10m or a timestamp (logs)",
+			},
+			"build": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to build the images of the services before starting them (up)",
+			},
+			"timeout": map[string]any{
+				"type":        "number",
+				"description": "Optional timeout of the command in milliseconds, max 600000 (exec)",
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *dockerTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params DockerParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse docker parameters: " + err.Error()), nil
+	}
+	target := docker.Target{
+		Container: strings.TrimSpace(params.Container),
+		Service:   strings.TrimSpace(params.Service),
+	}
+
+	var output string
+	var err error
+	switch params.Action {
+	case dockerActionPs:
+		return t.containers(ctx, params.All)
+	case dockerActionLogs:
+		tail := params.Tail
+		if tail <= 0 {
+			tail = dockerDefaultTail
+		}
+		output, err = t.client.Logs(ctx, target, min(tail, dockerMaxTail), params.Since)
+		if err == nil && strings.TrimSpace(output) == "" {
+			output = "No logs found"
+		}
+	case dockerActionUp:
+		if err := t.requestPermission(ctx, call, params, fmt.Sprintf("Start the compose services %s", dockerServices(params.Services))); err != nil {
+			return ToolResponse{}, err
+		}
+		upCtx, cancel := context.WithTimeout(ctx, dockerUpTimeout)
+		defer cancel()
+		output, err = t.client.Up(upCtx, params.Services, params.Build, &outputProgressWriter{ctx: ctx})
+	case dockerActionExec:
+		if strings.TrimSpace(params.Command) == "" {
+			return NewTextErrorResponse("Command parameter is required"), nil
+		}
+		in := target.Container
+		if target.Service != "" {
+			in = "the compose service " + target.Service
+		}
+		if err := t.requestPermission(ctx, call, params, fmt.Sprintf("Run in %s: %s", in, params.Command)); err != nil {
+			return ToolResponse{}, err
+		}
+		timeout := params.Timeout
+		if timeout > MaxTimeout {
+			timeout = MaxTimeout
+		} else if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+		defer cancel()
+		output, err = t.client.Exec(execCtx, target, params.Command, &outputProgressWriter{ctx: ctx})
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return NewTextErrorResponse(truncateOutput(output) + "\n\nCommand was aborted before completion"), nil
+		}
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("Unknown action %q, expected ps, logs, up, or exec", params.Action)), nil
+	}
+
+	var exitErr *docker.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return NewTextErrorResponse(fmt.Sprintf("%s\n\nExit code %d", truncateOutput(strings.TrimSpace(exitErr.Output)), exitErr.ExitCode)), nil
+	case err != nil:
+		if ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	output = strings.TrimSpace(output)
+	if output == "" {
+		output = "no output"
+	}
+	return NewTextResponse(truncateOutput(output)), nil
+}
+
+func (t *dockerTool) containers(ctx context.Context, all bool) (ToolResponse, error) {
+	containers, err := t.client.Containers(ctx, all)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(containers) == 0 {
+		if all {
+			return NewTextResponse("No containers found"), nil
+		}
+		return NewTextResponse("No running containers, set all to true to list the stopped ones"), nil
+	}
+
+	var sb strings.Builder
+	for _, c := range containers {
+		fmt.Fprintf(&sb, "%s %s (%s): %s", c.ID, c.Name, c.Image, c.Status)
+		if c.Service != "" {
+			fmt.Fprintf(&sb, ", service %s of %s", c.Service, c.Project)
+		}
+		if c.Ports != "" {
+			fmt.Fprintf(&sb, ", ports %s", c.Ports)
+		}
+		sb.WriteString("\n")
+	}
+	return NewTextResponse(truncateOutput(strings.TrimSuffix(sb.String(), "\n"))), nil
+}
+
+func (t *dockerTool) requestPermission(ctx context.Context, call ToolCall, params DockerParams, description string) error {
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return fmt.Errorf("session ID and message ID are required for running docker")
+	}
+	p := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        t.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    DockerToolName,
+			Action:      params.Action,
+			Description: description,
+			Params: DockerPermissionsParams{
+				Action:    params.Action,
+				Container: params.Container,
+				Service:   params.Service,
+				Services:  params.Services,
+				Command:   params.Command,
+				Build:     params.Build,
+			},
+		},
+	)
+	if !p {
+		return permission.ErrorPermissionDenied
+	}
+	return nil
+}
+
+func dockerServices(services []string) string {
+	if len(services) == 0 {
+		return "of the project"
+	}
+	return strings.Join(services, ", ")
+}
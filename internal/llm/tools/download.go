@@ -201,7 +201,12 @@ func (t *downloadTool) Run(ctx context.Context, call ToolCall) (ToolResponse, er
 
 	// Copy data with size limit
 	limitedReader := io.LimitReader(resp.Body, maxSize)
-	bytesWritten, err := io.Copy(outFile, limitedReader)
+	bytesWritten, err := io.Copy(outFile, &progressReader{
+		ctx:     ctx,
+		r:       limitedReader,
+		message: fmt.Sprintf("Downloading %s", params.URL),
+		total:   max(resp.ContentLength, 0),
+	})
 	if err != nil {
 		return ToolResponse{}, fmt.Errorf("failed to write file: %w", err)
 	}
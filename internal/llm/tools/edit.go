@@ -186,6 +186,7 @@ func (e *editTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	waitForLspDiagnostics(ctx, params.FilePath, e.lspClients)
 	text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
 	text += getDiagnostics(params.FilePath, e.lspClients)
+	text += checkFileGlossary(e.workingDir, params.FilePath)
 	response.Content = text
 	return response, nil
 }
@@ -355,6 +356,10 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	if !p {
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
+	newContent, partial := reviewedContent(e.permissions, call.ID, newContent)
+	if partial {
+		_, additions, removals = diff.GenerateDiff(oldContent, newContent, strings.TrimPrefix(filePath, e.workingDir))
+	}
 
 	err = os.WriteFile(filePath, []byte(newContent), 0o644)
 	if err != nil {
@@ -387,7 +392,7 @@ func (e *editTool) deleteContent(ctx context.Context, filePath, oldString string
 	recordFileRead(filePath)
 
 	return WithResponseMetadata(
-		NewTextResponse("Content deleted from file: "+filePath),
+		NewTextResponse("Content deleted from file: "+filePath+rejectedNote(partial)),
 		EditResponseMetadata{
 			OldContent: oldContent,
 			NewContent: newContent,
@@ -486,6 +491,10 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	if !p {
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
+	newContent, partial := reviewedContent(e.permissions, call.ID, newContent)
+	if partial {
+		_, additions, removals = diff.GenerateDiff(oldContent, newContent, strings.TrimPrefix(filePath, e.workingDir))
+	}
 
 	err = os.WriteFile(filePath, []byte(newContent), 0o644)
 	if err != nil {
@@ -518,7 +527,7 @@ func (e *editTool) replaceContent(ctx context.Context, filePath, oldString, newS
 	recordFileRead(filePath)
 
 	return WithResponseMetadata(
-		NewTextResponse("Content replaced in file: "+filePath),
+		NewTextResponse("Content replaced in file: "+filePath+rejectedNote(partial)),
 		EditResponseMetadata{
 			OldContent: oldContent,
 			NewContent: newContent,
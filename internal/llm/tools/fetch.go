@@ -12,6 +12,7 @@ import (
 
 	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 )
 
@@ -49,6 +50,7 @@ HOW TO USE:
 
 FEATURES:
 - Supports three output formats: text, markdown, and html
+- Text and markdown keep the main content of HTML pages, without navigation, scripts, and the like
 - Automatically handles HTTP redirects
 - Sets reasonable timeouts to prevent hanging
 - Validates input parameters before making requests
@@ -62,7 +64,7 @@ LIMITATIONS:
 TIPS:
 - Use text format for plain text content or simple API responses
 - Use markdown format for content that should be rendered with formatting
-- Use html format when you need the raw HTML structure
+- Use html format when you need the raw HTML structure, or when the text format misses content
 - Set appropriate timeouts for potentially slow websites`
 )
 
@@ -236,22 +238,54 @@ func (t *fetchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		content += fmt.Sprintf("\n\n[Content truncated to %d bytes]", MaxReadSize)
 	}
 
-	return NewTextResponse(content), nil
+	return WithResponseCitations(
+		NewTextResponse(content),
+		message.Citation{Source: params.URL},
+	), nil
 }
 
-func extractTextFromHTML(html string) (string, error) {
+// boilerplateSelector matches the parts of a page around its content.
+const boilerplateSelector = "script, style, noscript, template, iframe, svg, form, nav, header, footer, aside, [role=navigation], [role=banner], [role=contentinfo], [aria-hidden=true]"
+
+// readableContent returns the main content of the page, without navigation,
+// scripts, and the like, so pages don't fill the context with boilerplate.
+func readableContent(html string) (*goquery.Selection, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+	doc.Find(boilerplateSelector).Remove()
+
+	for _, selector := range []string{"main", "[role=main]", "article"} {
+		if content := doc.Find(selector).First(); strings.TrimSpace(content.Text()) != "" {
+			return content, nil
+		}
+	}
+	return doc.Find("body"), nil
+}
+
+func extractTextFromHTML(html string) (string, error) {
+	content, err := readableContent(html)
 	if err != nil {
 		return "", err
 	}
 
-	text := doc.Find("body").Text()
+	text := content.Text()
 	text = strings.Join(strings.Fields(text), " ")
 
 	return text, nil
 }
 
 func convertHTMLToMarkdown(html string) (string, error) {
+	content, err := readableContent(html)
+	if err != nil {
+		return "", err
+	}
+	html, err = content.Html()
+	if err != nil {
+		return "", err
+	}
+
 	converter := md.NewConverter("", true, nil)
 
 	markdown, err := converter.ConvertString(html)
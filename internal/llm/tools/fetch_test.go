@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const fetchTestPage = `<html>
+<head><title>Docs</title><style>body { color: red; }</style></head>
+<body>
+<header><nav><a href="/">Home</a> <a href="/blog">Blog</a></nav></header>
+<main>
+<h1>Getting started</h1>
+<p>Install the <code>crush</code> package.</p>
+<script>track();</script>
+</main>
+<aside>Related posts</aside>
+<footer>Copyright</footer>
+</body>
+</html>`
+
+func TestExtractTextFromHTML(t *testing.T) {
+	text, err := extractTextFromHTML(fetchTestPage)
+	require.NoError(t, err)
+	require.Equal(t, "Getting started Install the crush package.", text)
+
+	// Pages without a main element keep their body.
+	text, err = extractTextFromHTML(`<body><nav>Menu</nav><div>Content</div></body>`)
+	require.NoError(t, err)
+	require.Equal(t, "Content", text)
+}
+
+func TestConvertHTMLToMarkdown(t *testing.T) {
+	markdown, err := convertHTMLToMarkdown(fetchTestPage)
+	require.NoError(t, err)
+	require.Equal(t, "# Getting started\n\nInstall the `crush` package.", markdown)
+}
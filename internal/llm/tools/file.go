@@ -3,6 +3,8 @@ package tools
 import (
 	"sync"
 	"time"
+
+	"github.com/charmbracelet/crush/internal/permission"
 )
 
 // File record to track when files were read/written
@@ -40,6 +42,25 @@ func getLastReadTime(path string) time.Time {
 	return record.readTime
 }
 
+// rejectedNote tells the model that the user rejected some hunks of a file
+// change, if partial.
+func rejectedNote(partial bool) string {
+	if !partial {
+		return ""
+	}
+	return "\n\nThe user rejected some hunks of the change, the file has only the ones they accepted. View the file before changing it again."
+}
+
+// reviewedContent returns the content of a granted file change, with only the
+// hunks the user accepted if they rejected some, and whether they did.
+func reviewedContent(permissions permission.Service, callID, content string) (string, bool) {
+	reviewed, ok := permissions.Reviewed(callID)
+	if !ok || reviewed == content {
+		return content, false
+	}
+	return reviewed, true
+}
+
 func recordFileWrite(path string) {
 	fileRecordMutex.Lock()
 	defer fileRecordMutex.Unlock()
@@ -0,0 +1,373 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/crush/internal/forge"
+	"github.com/charmbracelet/crush/internal/git"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type ForgeParams struct {
+	Action string `json:"action"`
+	Issue  int    `json:"issue,omitempty"`
+	State  string `json:"state,omitempty"`
+	Search string `json:"search,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Branch string `json:"branch,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Base   string `json:"base,omitempty"`
+	Draft  bool   `json:"draft,omitempty"`
+}
+
+type ForgePermissionsParams struct {
+	Action string `json:"action"`
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+	Title  string `json:"title,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Base   string `json:"base,omitempty"`
+	Draft  bool   `json:"draft,omitempty"`
+}
+
+type forgeTool struct {
+	forge       forge.Forge
+	remote      string
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	ForgeToolName = "forge"
+
+	forgeActionIssue       = "issue"
+	forgeActionIssues      = "issues"
+	forgeActionBranch      = "branch"
+	forgeActionPush        = "push"
+	forgeActionPullRequest = "pull_request"
+
+	forgeToolDescription = `Works with the %[1]s repository %[2]s of the project: reads its issues, creates branches for them, pushes branches, and opens %[3]s.
+
+WHEN TO USE THIS TOOL:
+- Use to read an issue the user refers to, such as "fix issue #123", with its comments
+- Use to find the issues of the repository matching a search
+- Use to go from a fixed issue to an open %[4]s: branch, commit, push, and open it
+
+HOW TO USE:
+- issue: read the issue with its description, labels, and comments
+- issues: list the open issues, or the closed or all ones with state, optionally matching a search
+- branch: create a branch from the HEAD and switch to it, named after the issue when no branch is given
+- push: push the current branch to the %[5]s remote and track it
+- pull_request: push the current branch if needed and open a %[4]s of it into the base branch, the default branch of the repository without base
+
+FEATURES:
+- Branches, pushes, and %[3]s need the permission of the user
+- Without a body, the description of the %[4]s lists the commits of the branch
+- The %[4]s closes the issue given with it
+
+TIPS:
+- Read the issue and its comments before changing anything
+- Commit the changes with the git tool before opening the %[4]s
+- Write a title and a body summarizing what changed and why, for reviewers
+- Never open a %[4]s unless the user asked for it`
+)
+
+func NewForgeTool(permissions permission.Service, f forge.Forge, remote, workingDir string) BaseTool {
+	return &forgeTool{
+		forge:       f,
+		remote:      remote,
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *forgeTool) Name() string {
+	return ForgeToolName
+}
+
+// pullRequest is what the forge calls pull requests.
+func (t *forgeTool) pullRequest() string {
+	if t.forge.Name() == "GitLab" {
+		return "merge request"
+	}
+	return "pull request"
+}
+
+func (t *forgeTool) Info() ToolInfo {
+	pr := t.pullRequest()
+	return ToolInfo{
+		Name:        ForgeToolName,
+		Description: fmt.Sprintf(forgeToolDescription, t.forge.Name(), t.forge.Repository(), pr+"s", pr, t.remote),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum":        []string{forgeActionIssue, forgeActionIssues, forgeActionBranch, forgeActionPush, forgeActionPullRequest},
+			},
+			"issue": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("The number of the issue to read (issue), to name the branch after (branch), or closed by the %s (pull_request)", pr),
+			},
+			"state": map[string]any{
+				"type":        "string",
+				"description": "The state of the issues to list, open by default (issues)",
+				"enum":        []string{"open", "closed", "all"},
+			},
+			"search": map[string]any{
+				"type":        "string",
+				"description": "Words the issues must contain (issues)",
+			},
+			"limit": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("The maximum number of issues to list, %d by default (issues)", forge.DefaultIssueLimit),
+			},
+			"branch": map[string]any{
+				"type":        "string",
+				"description": "The name of the branch to create (branch)",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": fmt.Sprintf("The title of the %s (pull_request)", pr),
+			},
+			"body": map[string]any{
+				"type":        "string",
+				"description": fmt.Sprintf("The description of the %s in Markdown (pull_request)", pr),
+			},
+			"base": map[string]any{
+				"type":        "string",
+				"description": "The branch to merge into, the default branch by default (pull_request)",
+			},
+			"draft": map[string]any{
+				"type":        "boolean",
+				"description": fmt.Sprintf("Set to true to open a draft %s (pull_request)", pr),
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *forgeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ForgeParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse forge parameters: " + err.Error()), nil
+	}
+
+	var result any
+	var err error
+	switch params.Action {
+	case forgeActionIssue:
+		if params.Issue <= 0 {
+			return NewTextErrorResponse("Issue parameter is required"), nil
+		}
+		result, err = t.forge.Issue(ctx, params.Issue)
+	case forgeActionIssues:
+		result, err = t.forge.Issues(ctx, forge.IssueQuery{State: params.State, Search: params.Search, Limit: params.Limit})
+	case forgeActionBranch:
+		result, err = t.createBranch(ctx, call, params)
+	case forgeActionPush:
+		result, err = t.push(ctx, call, params)
+	case forgeActionPullRequest:
+		result, err = t.openPullRequest(ctx, call, params)
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("Unknown action %q, expected issue, issues, branch, push, or pull_request", params.Action)), nil
+	}
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, permission.ErrorPermissionDenied) {
+			return ToolResponse{}, err
+		}
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to encode the forge result: %w", err)
+	}
+	return NewTextResponse(string(output)), nil
+}
+
+func (t *forgeTool) createBranch(ctx context.Context, call ToolCall, params ForgeParams) (any, error) {
+	repo, err := git.Open(ctx, t.workingDir)
+	if err != nil {
+		return nil, err
+	}
+	branch := strings.TrimSpace(params.Branch)
+	if branch == "" {
+		if params.Issue <= 0 {
+			return nil, fmt.Errorf("either a branch or an issue is required")
+		}
+		issue, err := t.forge.Issue(ctx, params.Issue)
+		if err != nil {
+			return nil, err
+		}
+		branch = issueBranch(issue.Number, issue.Title)
+	}
+	if err := t.requestPermission(ctx, call, params, branch, "Create and switch to the branch "+branch); err != nil {
+		return nil, err
+	}
+	if err := repo.Switch(ctx, branch, true); err != nil {
+		return nil, err
+	}
+	return map[string]string{"branch": branch}, nil
+}
+
+func (t *forgeTool) push(ctx context.Context, call ToolCall, params ForgeParams) (any, error) {
+	repo, branch, err := t.currentBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.requestPermission(ctx, call, params, branch, fmt.Sprintf("Push the branch %s to %s", branch, t.remote)); err != nil {
+		return nil, err
+	}
+	if err := t.pushBranch(ctx, repo, branch); err != nil {
+		return nil, err
+	}
+	return map[string]string{"branch": branch, "remote": t.remote}, nil
+}
+
+func (t *forgeTool) openPullRequest(ctx context.Context, call ToolCall, params ForgeParams) (any, error) {
+	if strings.TrimSpace(params.Title) == "" {
+		return nil, fmt.Errorf("title parameter is required")
+	}
+	repo, branch, err := t.currentBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if params.Base == "" {
+		if params.Base, err = t.forge.DefaultBranch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if branch == params.Base {
+		return nil, fmt.Errorf("the current branch is the base branch %s, create a branch for the changes first", branch)
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		commits, err := repo.Log(ctx, t.remote+"/"+params.Base+"..HEAD")
+		if err != nil {
+			return nil, err
+		}
+		params.Body = describeCommits(commits)
+	}
+	if params.Issue > 0 && !strings.Contains(params.Body, fmt.Sprintf("#%d", params.Issue)) {
+		params.Body = strings.TrimSpace(fmt.Sprintf("%s\n\nCloses #%d", params.Body, params.Issue))
+	}
+
+	description := fmt.Sprintf("Push the branch %s to %s and open a %s into %s:\n\n%s\n\n%s", branch, t.remote, t.pullRequest(), params.Base, params.Title, params.Body)
+	if err := t.requestPermission(ctx, call, params, branch, description); err != nil {
+		return nil, err
+	}
+	status, err := repo.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if status.Upstream == "" || status.Ahead > 0 {
+		if err := t.pushBranch(ctx, repo, branch); err != nil {
+			return nil, err
+		}
+	}
+	return t.forge.CreatePullRequest(ctx, forge.NewPullRequest{
+		Title: params.Title,
+		Body:  params.Body,
+		Head:  branch,
+		Base:  params.Base,
+		Draft: params.Draft,
+	})
+}
+
+func (t *forgeTool) currentBranch(ctx context.Context) (*git.Repo, string, error) {
+	repo, err := git.Open(ctx, t.workingDir)
+	if err != nil {
+		return nil, "", err
+	}
+	status, err := repo.Status(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if status.Branch == "" {
+		return nil, "", fmt.Errorf("the HEAD is detached, switch to a branch first")
+	}
+	return repo, status.Branch, nil
+}
+
+func (t *forgeTool) pushBranch(ctx context.Context, repo *git.Repo, branch string) error {
+	var opts git.PushOptions
+	// The token only authorizes pushes over HTTPS, ssh has its own keys.
+	if url, err := repo.RemoteURL(ctx, t.remote); err == nil && strings.HasPrefix(url, "https://") {
+		opts.AuthHeader = t.forge.PushAuthHeader()
+	}
+	return repo.Push(ctx, t.remote, branch, opts)
+}
+
+func (t *forgeTool) requestPermission(ctx context.Context, call ToolCall, params ForgeParams, branch, description string) error {
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return fmt.Errorf("session ID and message ID are required for changing the repository")
+	}
+	p := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        t.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    ForgeToolName,
+			Action:      params.Action,
+			Description: description,
+			Params: ForgePermissionsParams{
+				Action: params.Action,
+				Remote: t.remote,
+				Branch: branch,
+				Title:  params.Title,
+				Body:   params.Body,
+				Base:   params.Base,
+				Draft:  params.Draft,
+			},
+		},
+	)
+	if !p {
+		return permission.ErrorPermissionDenied
+	}
+	return nil
+}
+
+// issueBranch names the branch of an issue after its number and title, such
+// as 123-fix-the-login.
+func issueBranch(number int, title string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d", number)
+	dash := true
+	for _, r := range strings.ToLower(title) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash {
+				sb.WriteByte('-')
+				dash = false
+			}
+			sb.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	branch := sb.String()
+	if len(branch) > 50 {
+		// Cut the title between words.
+		branch = branch[:50]
+		branch = branch[:strings.LastIndex(branch, "-")]
+	}
+	return branch
+}
+
+// describeCommits describes a pull request by its commits: the body of a
+// single commit, or the list of their subjects.
+func describeCommits(commits []git.Commit) string {
+	if len(commits) == 1 {
+		return commits[0].Body
+	}
+	var sb strings.Builder
+	for _, c := range commits {
+		fmt.Fprintf(&sb, "- %s\n", c.Subject)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,20 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/git"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueBranch(t *testing.T) {
+	require.Equal(t, "123-fix-the-login-on-safari", issueBranch(123, "Fix the login on Safari!"))
+	require.Equal(t, "7-crash-in-parser-go", issueBranch(7, "  Crash in `parser.go` "))
+	require.Equal(t, "9", issueBranch(9, "🔥"))
+	require.Equal(t, "42-a-very-long-title-that-goes-on-and-on-and-on", issueBranch(42, "A very long title that goes on and on and on forever"))
+}
+
+func TestDescribeCommits(t *testing.T) {
+	require.Equal(t, "Because it was broken.", describeCommits([]git.Commit{{Subject: "fix: login", Body: "Because it was broken."}}))
+	require.Equal(t, "- fix: login\n- test: login", describeCommits([]git.Commit{{Subject: "fix: login"}, {Subject: "test: login"}}))
+}
@@ -0,0 +1,281 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/git"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type GitParams struct {
+	Action           string   `json:"action"`
+	Paths            []string `json:"paths,omitempty"`
+	Staged           bool     `json:"staged,omitempty"`
+	Ref              string   `json:"ref,omitempty"`
+	Message          string   `json:"message,omitempty"`
+	All              bool     `json:"all,omitempty"`
+	Branch           string   `json:"branch,omitempty"`
+	Create           bool     `json:"create,omitempty"`
+	Stash            string   `json:"stash,omitempty"`
+	IncludeUntracked bool     `json:"include_untracked,omitempty"`
+}
+
+type GitPermissionsParams struct {
+	Action  string   `json:"action"`
+	Paths   []string `json:"paths,omitempty"`
+	Message string   `json:"message,omitempty"`
+	All     bool     `json:"all,omitempty"`
+	Branch  string   `json:"branch,omitempty"`
+	Create  bool     `json:"create,omitempty"`
+	Stash   string   `json:"stash,omitempty"`
+	Ref     string   `json:"ref,omitempty"`
+}
+
+type gitTool struct {
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	GitToolName = "git"
+
+	gitActionStatus  = "status"
+	gitActionDiff    = "diff"
+	gitActionStage   = "stage"
+	gitActionUnstage = "unstage"
+	gitActionCommit  = "commit"
+	gitActionBranch  = "branch"
+	gitActionStash   = "stash"
+
+	gitStashList = "list"
+	gitStashPush = "push"
+	gitStashPop  = "pop"
+
+	gitToolDescription = `Runs common git operations on the repository of the project, and returns their results as JSON.
+
+WHEN TO USE THIS TOOL:
+- Use to check which files changed, are staged, or are untracked
+- Use to review the changes before committing them, or the changes of a branch
+- Use to stage and commit changes, switch branches, or stash work in progress
+
+HOW TO USE:
+- status: the branch, its upstream, and the staged, unstaged, untracked, and conflicted files
+- diff: the changed files with their added and deleted lines, and the patch; set staged for the staged changes, ref to compare with a commit or a range such as main...HEAD, and paths to limit it
+- stage and unstage: add the changes of the paths to the index, or remove them from it
+- commit: commit the staged changes with the message, or the changes of all tracked files with all
+- branch: list the branches, or switch to the branch, creating it with create
+- stash: list the stash, push the changes to it with an optional message, or pop an entry, the latest one without ref
+
+FEATURES:
+- Status, diffs, and listings run without asking
+- Staging, committing, switching branches, and stashing need the permission of the user
+
+LIMITATIONS:
+- Remote operations such as fetch, pull, and push aren't supported, use the bash tool for them
+- Patches longer than %d characters are cut, limit the diff to some paths for the rest
+
+TIPS:
+- Check the status and the staged diff before committing
+- Write commit messages in the style of the history of the repository
+- Never commit unless the user asked for it`
+)
+
+func NewGitTool(permissions permission.Service, workingDir string) BaseTool {
+	return &gitTool{
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *gitTool) Name() string {
+	return GitToolName
+}
+
+func (t *gitTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        GitToolName,
+		Description: fmt.Sprintf(gitToolDescription, MaxOutputLength),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The operation to run",
+				"enum":        []string{gitActionStatus, gitActionDiff, gitActionStage, gitActionUnstage, gitActionCommit, gitActionBranch, gitActionStash},
+			},
+			"paths": map[string]any{
+				"type":        "array",
+				"description": "The paths to diff, stage, or unstage (diff, stage, unstage)",
+				"items": map[string]any{
+					"type": "string",
+				},
+			},
+			"staged": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to diff the staged changes (diff)",
+			},
+			"ref": map[string]any{
+				"type":        "string",
+				"description": "The commit or range to diff with (diff), or the stash entry to pop such as stash@{1} (stash)",
+			},
+			"message": map[string]any{
+				"type":        "string",
+				"description": "The commit message (commit), or the message of the stash entry (stash)",
+			},
+			"all": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to commit the changes of all tracked files, staged or not (commit)",
+			},
+			"branch": map[string]any{
+				"type":        "string",
+				"description": "The branch to switch to, the branches are listed without it (branch)",
+			},
+			"create": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to create the branch from the HEAD (branch)",
+			},
+			"stash": map[string]any{
+				"type":        "string",
+				"description": "The stash operation, list by default (stash)",
+				"enum":        []string{gitStashList, gitStashPush, gitStashPop},
+			},
+			"include_untracked": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to stash the untracked files too (stash)",
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *gitTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params GitParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse git parameters: " + err.Error()), nil
+	}
+
+	repo, err := git.Open(ctx, t.workingDir)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	var result any
+	switch params.Action {
+	case gitActionStatus:
+		result, err = repo.Status(ctx)
+	case gitActionDiff:
+		var diff *git.Diff
+		diff, err = repo.Diff(ctx, git.DiffOptions{Staged: params.Staged, Ref: params.Ref, Paths: params.Paths})
+		if err == nil {
+			diff.Patch = truncateOutput(diff.Patch)
+			result = diff
+		}
+	case gitActionStage, gitActionUnstage:
+		if len(params.Paths) == 0 {
+			return NewTextErrorResponse("Paths parameter is required"), nil
+		}
+		if err := t.requestPermission(ctx, call, params, fmt.Sprintf("%s %s", strings.ToUpper(params.Action[:1])+params.Action[1:], strings.Join(params.Paths, ", "))); err != nil {
+			return ToolResponse{}, err
+		}
+		if params.Action == gitActionStage {
+			err = repo.Stage(ctx, params.Paths)
+		} else {
+			err = repo.Unstage(ctx, params.Paths)
+		}
+		if err == nil {
+			result, err = repo.Status(ctx)
+		}
+	case gitActionCommit:
+		if strings.TrimSpace(params.Message) == "" {
+			return NewTextErrorResponse("Message parameter is required"), nil
+		}
+		description := "Commit the staged changes with the message:\n\n" + params.Message
+		if params.All {
+			description = "Commit the changes of all tracked files with the message:\n\n" + params.Message
+		}
+		if err := t.requestPermission(ctx, call, params, description); err != nil {
+			return ToolResponse{}, err
+		}
+		result, err = repo.Commit(ctx, params.Message, git.CommitOptions{All: params.All})
+	case gitActionBranch:
+		if params.Branch != "" {
+			description := "Switch to the branch " + params.Branch
+			if params.Create {
+				description = "Create and switch to the branch " + params.Branch
+			}
+			if err := t.requestPermission(ctx, call, params, description); err != nil {
+				return ToolResponse{}, err
+			}
+			err = repo.Switch(ctx, params.Branch, params.Create)
+		}
+		if err == nil {
+			result, err = repo.Branches(ctx)
+		}
+	case gitActionStash:
+		switch params.Stash {
+		case "", gitStashList:
+		case gitStashPush:
+			if err := t.requestPermission(ctx, call, params, "Stash the changes of the working tree"); err != nil {
+				return ToolResponse{}, err
+			}
+			err = repo.StashPush(ctx, params.Message, params.IncludeUntracked)
+		case gitStashPop:
+			if err := t.requestPermission(ctx, call, params, "Pop "+cmp.Or(params.Ref, "the latest stash entry")); err != nil {
+				return ToolResponse{}, err
+			}
+			err = repo.StashPop(ctx, params.Ref)
+		default:
+			return NewTextErrorResponse(fmt.Sprintf("Unknown stash operation %q, expected list, push, or pop", params.Stash)), nil
+		}
+		if err == nil {
+			result, err = repo.Stashes(ctx)
+		}
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("Unknown action %q, expected status, diff, stage, unstage, commit, branch, or stash", params.Action)), nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("failed to encode the git result: %w", err)
+	}
+	return NewTextResponse(string(output)), nil
+}
+
+func (t *gitTool) requestPermission(ctx context.Context, call ToolCall, params GitParams, description string) error {
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return fmt.Errorf("session ID and message ID are required for running git")
+	}
+	p := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        t.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    GitToolName,
+			Action:      params.Action,
+			Description: description,
+			Params: GitPermissionsParams{
+				Action:  params.Action,
+				Paths:   params.Paths,
+				Message: params.Message,
+				All:     params.All,
+				Branch:  params.Branch,
+				Create:  params.Create,
+				Stash:   params.Stash,
+				Ref:     params.Ref,
+			},
+		},
+	)
+	if !p {
+		return permission.ErrorPermissionDenied
+	}
+	return nil
+}
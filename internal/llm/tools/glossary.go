@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/glossary"
+)
+
+// proseExtensions are the files checked against the project glossary.
+var proseExtensions = map[string]bool{
+	".md":       true,
+	".mdx":      true,
+	".markdown": true,
+	".txt":      true,
+	".rst":      true,
+	".adoc":     true,
+}
+
+// proseCommandRe matches commands that publish prose, such as pull request
+// descriptions and commit messages.
+var proseCommandRe = regexp.MustCompile(`\b(gh\s+(pr|issue|release)\s+(create|edit|comment)|git\s+commit)\b`)
+
+func loadGlossary(workingDir string) glossary.Glossary {
+	cfg := config.Get()
+	if cfg == nil || cfg.Options == nil {
+		return nil
+	}
+	return glossary.Load(workingDir, cfg.Options.ContextPaths)
+}
+
+// checkFileGlossary checks a prose file against the project glossary.
+func checkFileGlossary(workingDir, filePath string) string {
+	if !proseExtensions[strings.ToLower(filepath.Ext(filePath))] {
+		return ""
+	}
+	g := loadGlossary(workingDir)
+	if len(g) == 0 {
+		return ""
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	return formatGlossaryIssues(g.Check(string(content)))
+}
+
+// checkCommandGlossary checks the prose passed to commands such as
+// gh pr create against the project glossary.
+func checkCommandGlossary(workingDir, command string) string {
+	if !proseCommandRe.MatchString(command) {
+		return ""
+	}
+	return formatGlossaryIssues(loadGlossary(workingDir).Check(command))
+}
+
+func formatGlossaryIssues(issues []glossary.Issue) string {
+	if len(issues) == 0 {
+		return ""
+	}
+	var output strings.Builder
+	output.WriteString("\n<glossary>\n")
+	output.WriteString("The text doesn't follow the project glossary, fix these spellings:\n")
+	for _, issue := range issues {
+		fmt.Fprintf(&output, "%s\n", issue)
+	}
+	output.WriteString("</glossary>\n")
+	return output.String()
+}
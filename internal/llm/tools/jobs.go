@@ -0,0 +1,323 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+type JobsParams struct {
+	Action string `json:"action"`
+	ID     string `json:"id,omitempty"`
+	Wait   int    `json:"wait,omitempty"`
+}
+
+type jobsTool struct{}
+
+const (
+	JobsToolName = "jobs"
+
+	jobsActionList   = "list"
+	jobsActionOutput = "output"
+	jobsActionKill   = "kill"
+
+	// jobMaxWait caps the seconds the output action waits for a job to end.
+	jobMaxWait = 120
+	// jobKillWait is how long a killed job has to end.
+	jobKillWait = 5 * time.Second
+
+	jobsToolDescription = `Lists the commands the bash tool runs in the background, reads their output, and stops them.
+
+WHEN TO USE THIS TOOL:
+- Use to check on a command started with background set to true, such as a dev server, a watcher, or a long build
+- Use to stop a background command once it's no longer needed
+
+HOW TO USE:
+- Use the list action to list the background commands of the session with their status
+- Use the output action with the ID of a job to read its output since the last read, and whether it's still running
+- Set wait to wait up to that many seconds for the job to end before reading its output, up to %d
+- Use the kill action with the ID of a job to stop it
+
+LIMITATIONS:
+- The middle of outputs longer than %d characters is cut, the full output is in the file the result points to
+- The commands can't read input
+
+TIPS:
+- Wait for long builds rather than reading their output over and over
+- Kill the servers you started once you're done with them`
+)
+
+// job is a command of the bash tool running in the background. Its output
+// goes to a file, read from where the last read stopped.
+type job struct {
+	ID        string
+	SessionID string
+	Command   string
+	StartTime time.Time
+	// Path is the file of the output.
+	Path string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	endTime time.Time
+	err     error
+	// read is the length of the output already read.
+	read int64
+}
+
+// jobs are the background commands of all the sessions.
+var jobs = struct {
+	sync.Mutex
+	byID map[string]*job
+	next int
+}{byID: make(map[string]*job)}
+
+// startJob runs the command in a clone of the shell in the background, with
+// its output saved to the file of the tool call.
+func startJob(sessionID, callID, command string, sh *shell.Shell) (*job, error) {
+	path := outputPath(sessionID, callID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create the output directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the output file: %w", err)
+	}
+
+	jobs.Lock()
+	jobs.next++
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		ID:        fmt.Sprintf("job_%d", jobs.next),
+		SessionID: sessionID,
+		Command:   command,
+		StartTime: time.Now(),
+		Path:      path,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	jobs.byID[j.ID] = j
+	jobs.Unlock()
+
+	go func() {
+		defer close(j.done)
+		defer f.Close()
+		err := sh.ExecTo(ctx, command, &syncWriter{w: f})
+		j.mu.Lock()
+		j.endTime, j.err = time.Now(), err
+		j.mu.Unlock()
+		slog.Info("Background job finished", "job", j.ID, "error", err)
+	}()
+	return j, nil
+}
+
+// syncWriter serializes the writes of stdout and stderr to the file.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// sessionJob returns the job of the session with the ID.
+func sessionJob(sessionID, id string) (*job, bool) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	j, ok := jobs.byID[id]
+	if !ok || j.SessionID != sessionID {
+		return nil, false
+	}
+	return j, true
+}
+
+// KillJobs stops the background commands of all the sessions, when crush
+// exits.
+func KillJobs() {
+	killJobs(func(*job) bool { return true })
+}
+
+// KillSessionJobs stops the background commands of the session and forgets
+// them, once it's deleted.
+func KillSessionJobs(sessionID string) {
+	killJobs(func(j *job) bool { return j.SessionID == sessionID })
+}
+
+// killJobs stops the matching jobs, waiting a bit for them to end, and
+// forgets them.
+func killJobs(match func(*job) bool) {
+	jobs.Lock()
+	var running []*job
+	for id, j := range jobs.byID {
+		if match(j) {
+			running = append(running, j)
+			delete(jobs.byID, id)
+		}
+	}
+	jobs.Unlock()
+	for _, j := range running {
+		j.cancel()
+	}
+	for _, j := range running {
+		select {
+		case <-j.done:
+		case <-time.After(jobKillWait):
+		}
+	}
+}
+
+// status describes whether the job is running or how it ended.
+func (j *job) status() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch {
+	case j.endTime.IsZero():
+		return fmt.Sprintf("running for %s", time.Since(j.StartTime).Round(time.Second))
+	case shell.IsInterrupt(j.err):
+		return "killed"
+	case j.err != nil && shell.ExitCode(j.err) != 0:
+		return fmt.Sprintf("exited with code %d", shell.ExitCode(j.err))
+	case j.err != nil:
+		return fmt.Sprintf("failed: %v", j.err)
+	}
+	return "exited with code 0"
+}
+
+// unread returns the output written since the last read, the middle of it
+// cut when it's too long.
+func (j *job) unread() (string, error) {
+	f, err := os.Open(j.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := f.Seek(j.read, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	j.read += int64(len(data))
+	output := string(data)
+	if limit := bashMaxOutput(); len(output) > limit {
+		output = truncateOutputTo(output, limit) + fmt.Sprintf("\n\n[The full output is in %s]", j.Path)
+	}
+	return output, nil
+}
+
+func NewJobsTool() BaseTool {
+	return &jobsTool{}
+}
+
+func (t *jobsTool) Name() string {
+	return JobsToolName
+}
+
+func (t *jobsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        JobsToolName,
+		Description: fmt.Sprintf(jobsToolDescription, jobMaxWait, bashMaxOutput()),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum":        []string{jobsActionList, jobsActionOutput, jobsActionKill},
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "The ID of the job (output, kill)",
+			},
+			"wait": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("Seconds to wait for the job to end before reading its output, up to %d (output)", jobMaxWait),
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *jobsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params JobsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	sessionID, _ := GetContextValues(ctx)
+	if sessionID == "" {
+		return ToolResponse{}, errors.New("session ID is required for the jobs tool")
+	}
+
+	if params.Action == jobsActionList {
+		return t.list(sessionID), nil
+	}
+	if params.Action != jobsActionOutput && params.Action != jobsActionKill {
+		return NewTextErrorResponse(fmt.Sprintf("unknown action %q", params.Action)), nil
+	}
+	j, ok := sessionJob(sessionID, params.ID)
+	if !ok {
+		return NewTextErrorResponse(fmt.Sprintf("no job %q, list the jobs of the session with the list action", params.ID)), nil
+	}
+
+	wait := time.Duration(min(max(params.Wait, 0), jobMaxWait)) * time.Second
+	if params.Action == jobsActionKill {
+		j.cancel()
+		wait = jobKillWait
+	}
+	if wait > 0 {
+		select {
+		case <-j.done:
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ToolResponse{}, ctx.Err()
+		}
+	}
+
+	output, err := j.unread()
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to read the output of %s: %v", j.ID, err)), nil
+	}
+	if output == "" {
+		output = "no new output"
+	}
+	return NewTextResponse(fmt.Sprintf("Job %s is %s.\n\n%s", j.ID, j.status(), output)), nil
+}
+
+func (t *jobsTool) list(sessionID string) ToolResponse {
+	jobs.Lock()
+	var session []*job
+	for _, j := range jobs.byID {
+		if j.SessionID == sessionID {
+			session = append(session, j)
+		}
+	}
+	jobs.Unlock()
+	slices.SortFunc(session, func(a, b *job) int { return a.StartTime.Compare(b.StartTime) })
+	if len(session) == 0 {
+		return NewTextResponse("No background jobs")
+	}
+
+	var sb strings.Builder
+	for _, j := range session {
+		fmt.Fprintf(&sb, "%s: %s (%s)\n", j.ID, j.Command, j.status())
+	}
+	return NewTextResponse(strings.TrimSuffix(sb.String(), "\n"))
+}
@@ -0,0 +1,156 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/shell"
+	"github.com/stretchr/testify/require"
+)
+
+func setBashConfig(t *testing.T, bash *config.Bash) string {
+	t.Helper()
+	dataDir := t.TempDir()
+	previous := config.Get()
+	config.Set(&config.Config{Options: &config.Options{DataDirectory: dataDir, Bash: bash}})
+	t.Cleanup(func() { config.Set(previous) })
+	return dataDir
+}
+
+func runJobs(t *testing.T, ctx context.Context, params JobsParams) ToolResponse {
+	t.Helper()
+	input, err := json.Marshal(params)
+	require.NoError(t, err)
+	resp, err := NewJobsTool().Run(ctx, ToolCall{ID: "call", Name: JobsToolName, Input: string(input)})
+	require.NoError(t, err)
+	return resp
+}
+
+func TestJobs(t *testing.T) {
+	dataDir := setBashConfig(t, nil)
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "jobs-session")
+	sh := shell.NewShell(&shell.Options{WorkingDir: t.TempDir()})
+
+	done, err := startJob("jobs-session", "call_done", "echo one; sleep 0.2; echo two >&2", sh)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dataDir, "outputs", "jobs-session", "call_done.log"), done.Path)
+	info, err := os.Stat(done.Path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	running, err := startJob("jobs-session", "call_running", "sleep 30", sh.Clone())
+	require.NoError(t, err)
+	t.Cleanup(running.cancel)
+
+	resp := runJobs(t, ctx, JobsParams{Action: jobsActionOutput, ID: done.ID, Wait: 10})
+	require.False(t, resp.IsError, resp.Content)
+	require.Contains(t, resp.Content, "is exited with code 0")
+	require.Contains(t, resp.Content, "one\ntwo")
+
+	resp = runJobs(t, ctx, JobsParams{Action: jobsActionOutput, ID: done.ID})
+	require.Contains(t, resp.Content, "no new output")
+
+	resp = runJobs(t, ctx, JobsParams{Action: jobsActionList})
+	lines := strings.Split(resp.Content, "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, done.ID+": echo one; sleep 0.2; echo two >&2 (exited with code 0)", lines[0])
+	require.Contains(t, lines[1], running.ID+": sleep 30 (running for")
+
+	resp = runJobs(t, ctx, JobsParams{Action: jobsActionKill, ID: running.ID})
+	require.Contains(t, resp.Content, "is killed")
+
+	// The jobs of other sessions are hidden.
+	other := context.WithValue(t.Context(), SessionIDContextKey, "other-session")
+	resp = runJobs(t, other, JobsParams{Action: jobsActionOutput, ID: done.ID})
+	require.True(t, resp.IsError)
+	resp = runJobs(t, other, JobsParams{Action: jobsActionList})
+	require.Equal(t, "No background jobs", resp.Content)
+}
+
+func TestKillSessionJobs(t *testing.T) {
+	setBashConfig(t, nil)
+	sh := shell.NewShell(&shell.Options{WorkingDir: t.TempDir()})
+
+	deleted, err := startJob("deleted-session", "call_deleted", "sleep 30", sh)
+	require.NoError(t, err)
+	kept, err := startJob("kept-session", "call_kept", "sleep 30", sh.Clone())
+	require.NoError(t, err)
+	t.Cleanup(kept.cancel)
+
+	KillSessionJobs("deleted-session")
+	require.Equal(t, "killed", deleted.status())
+	_, ok := sessionJob("deleted-session", deleted.ID)
+	require.False(t, ok)
+	_, ok = sessionJob("kept-session", kept.ID)
+	require.True(t, ok)
+	require.Contains(t, kept.status(), "running")
+}
+
+func TestJobs_LongOutput(t *testing.T) {
+	setBashConfig(t, &config.Bash{MaxOutput: 1000})
+	ctx := context.WithValue(t.Context(), SessionIDContextKey, "long-session")
+	sh := shell.NewShell(&shell.Options{WorkingDir: t.TempDir()})
+
+	j, err := startJob("long-session", "call_long", "for i in $(seq 1 500); do echo line $i; done", sh)
+	require.NoError(t, err)
+
+	resp := runJobs(t, ctx, JobsParams{Action: jobsActionOutput, ID: j.ID, Wait: 10})
+	require.Contains(t, resp.Content, "line 1\n")
+	require.Contains(t, resp.Content, "line 500")
+	require.NotContains(t, resp.Content, "line 250\n")
+	require.Contains(t, resp.Content, "lines truncated")
+	require.Contains(t, resp.Content, "[The full output is in "+j.Path+"]")
+
+	data, err := os.ReadFile(j.Path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "line 250\n")
+}
+
+func TestSaveOutput(t *testing.T) {
+	dataDir := setBashConfig(t, nil)
+
+	path := saveOutput("output-session", "call_save", "out", "err")
+	require.Equal(t, filepath.Join(dataDir, "outputs", "output-session", "call_save.log"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "out\nerr", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	other := saveOutput("other-session", "call_other", "out", "")
+
+	// The outputs are deleted with their session.
+	require.NoError(t, RemoveOutputs("output-session"))
+	require.NoDirExists(t, filepath.Dir(path))
+	require.FileExists(t, other)
+	require.NoError(t, RemoveOutputs(""))
+	require.DirExists(t, filepath.Join(dataDir, "outputs"))
+}
+
+func TestTruncateOutputTo(t *testing.T) {
+	require.Equal(t, "short", truncateOutputTo("short", 10))
+	require.Equal(t, "a\nb\n\n\n... [2 lines truncated] ...\n\n\ne\nf", truncateOutputTo("a\nb\nc\nd\ne\nf", 8))
+}
+
+func TestBashTimeouts(t *testing.T) {
+	setBashConfig(t, nil)
+	defaultTimeout, maxTimeout := bashTimeouts()
+	require.Equal(t, "1m0s", defaultTimeout.String())
+	require.Equal(t, "10m0s", maxTimeout.String())
+	require.Equal(t, MaxOutputLength, bashMaxOutput())
+
+	setBashConfig(t, &config.Bash{Timeout: 120, MaxTimeout: 1800, MaxOutput: 5000})
+	defaultTimeout, maxTimeout = bashTimeouts()
+	require.Equal(t, "2m0s", defaultTimeout.String())
+	require.Equal(t, "30m0s", maxTimeout.String())
+	require.Equal(t, 5000, bashMaxOutput())
+
+	// A max_timeout shorter than the default caps it.
+	setBashConfig(t, &config.Bash{MaxTimeout: 30})
+	defaultTimeout, _ = bashTimeouts()
+	require.Equal(t, "30s", defaultTimeout.String())
+}
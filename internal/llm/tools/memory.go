@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/memory"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type MemoryParams struct {
+	Fact string `json:"fact"`
+}
+
+type MemoryPermissionsParams struct {
+	FilePath string `json:"file_path"`
+	Fact     string `json:"fact"`
+}
+
+type memoryTool struct {
+	permissions permission.Service
+	workingDir  string
+	// store keeps the facts instead of the memory file when the long-term
+	// memory is enabled.
+	store *memory.Store
+}
+
+const (
+	MemoryToolName = "memory"
+	// MemoryFile is the project memory the facts are added to, loaded into
+	// the system prompt of the next sessions.
+	MemoryFile = "CRUSH.md"
+	// memorySection is the section of the memory file the facts are added
+	// to, created the first time.
+	memorySection     = "## Learned Facts"
+	memoryDescription = `Adds a fact you learned about the project to its memory, the CRUSH.md file loaded into the system prompt of every session.
+
+WHEN TO USE THIS TOOL:
+- When the user asks you to remember something
+- When you learned something about the project that will matter in the next sessions, such as a command to run the tests, a convention the code follows, or a pitfall to avoid
+
+HOW TO USE:
+- Give one fact per call, as a short sentence standing on its own
+- Don't add facts already in the project instructions, or ones only true for the current task`
+	memoryStoreDescription = `Adds a fact you learned about the project to its long-term memory. The memories relevant to the request are given to you at the start of every turn, in this and the next sessions.
+
+WHEN TO USE THIS TOOL:
+- When the user asks you to remember something
+- When you learned something about the project that will matter in the next sessions, such as a command to run the tests, a convention the code follows, or a pitfall to avoid
+
+HOW TO USE:
+- Give one fact per call, as a short sentence standing on its own with the words you would search it by
+- Don't add facts already in the project instructions or in the memories given to you, or ones only true for the current task`
+)
+
+// NewMemoryTool returns the memory tool, adding the facts to the memory file
+// of the project, or to the store if not nil.
+func NewMemoryTool(permissions permission.Service, workingDir string, store *memory.Store) BaseTool {
+	return &memoryTool{
+		permissions: permissions,
+		workingDir:  workingDir,
+		store:       store,
+	}
+}
+
+func (m *memoryTool) Name() string {
+	return MemoryToolName
+}
+
+func (m *memoryTool) Info() ToolInfo {
+	description := memoryDescription
+	if m.store != nil {
+		description = memoryStoreDescription
+	}
+	return ToolInfo{
+		Name:        MemoryToolName,
+		Description: description,
+		Parameters: map[string]any{
+			"fact": map[string]any{
+				"type":        "string",
+				"description": "The fact to remember",
+			},
+		},
+		Required: []string{"fact"},
+	}
+}
+
+func (m *memoryTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params MemoryParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	fact := strings.Join(strings.Fields(params.Fact), " ")
+	if fact == "" {
+		return NewTextErrorResponse("fact is required"), nil
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session_id and message_id are required")
+	}
+
+	if m.store != nil {
+		// The memories can be listed and deleted with crush memory.
+		stored, added, err := m.store.Add(ctx, fact)
+		if err != nil {
+			return ToolResponse{}, err
+		}
+		if !added {
+			return NewTextResponse("The fact is already in the project memory."), nil
+		}
+		return NewTextResponse(fmt.Sprintf("Added the fact to the project memory as %s", stored.ID)), nil
+	}
+
+	filePath := filepath.Join(m.workingDir, MemoryFile)
+	content, err := os.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return ToolResponse{}, fmt.Errorf("error reading the memory file: %w", err)
+	}
+	if strings.Contains(string(content), "- "+fact+"\n") {
+		return NewTextResponse("The fact is already in the project memory."), nil
+	}
+
+	p := m.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        m.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    MemoryToolName,
+			Action:      "write",
+			Description: fmt.Sprintf("Add to the project memory in %s: %s", MemoryFile, fact),
+			Params: MemoryPermissionsParams{
+				FilePath: filePath,
+				Fact:     fact,
+			},
+		},
+	)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	if err := os.WriteFile(filePath, []byte(addFact(string(content), fact)), 0o644); err != nil {
+		return ToolResponse{}, fmt.Errorf("error writing the memory file: %w", err)
+	}
+	recordFileWrite(filePath)
+	return NewTextResponse(fmt.Sprintf("Added the fact to %s", filePath)), nil
+}
+
+// addFact adds the fact at the end of the learned facts section, creating it
+// at the end of the file if needed.
+func addFact(content, fact string) string {
+	item := "- " + fact + "\n"
+	start := strings.Index(content, memorySection+"\n")
+	if start < 0 {
+		if content != "" {
+			content = strings.TrimRight(content, "\n") + "\n\n"
+		}
+		return content + memorySection + "\n\n" + item
+	}
+
+	// The section ends at the next heading of the same level or above.
+	bodyStart := start + len(memorySection) + 1
+	end := len(content)
+	for _, heading := range []string{"\n# ", "\n## "} {
+		if i := strings.Index(content[bodyStart-1:], heading); i >= 0 {
+			end = min(end, bodyStart+i)
+		}
+	}
+	body := strings.TrimRight(content[bodyStart:end], "\n")
+	if body == "" {
+		body = "\n"
+	} else {
+		body += "\n"
+	}
+	rest := content[end:]
+	if rest != "" {
+		rest = "\n" + rest
+	}
+	return content[:start] + memorySection + "\n" + body + item + rest
+}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddFact(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name: "new file",
+			want: "## Learned Facts\n\n- Run task test\n",
+		},
+		{
+			name:    "new section",
+			content: "# Project\n\nUse gofumpt.\n",
+			want:    "# Project\n\nUse gofumpt.\n\n## Learned Facts\n\n- Run task test\n",
+		},
+		{
+			name:    "last section",
+			content: "# Project\n\n## Learned Facts\n\n- Use gofumpt\n",
+			want:    "# Project\n\n## Learned Facts\n\n- Use gofumpt\n- Run task test\n",
+		},
+		{
+			name:    "section before another",
+			content: "## Learned Facts\n\n- Use gofumpt\n\n## Glossary\n\n- GitHub\n",
+			want:    "## Learned Facts\n\n- Use gofumpt\n- Run task test\n\n## Glossary\n\n- GitHub\n",
+		},
+		{
+			name:    "empty section",
+			content: "## Learned Facts\n## Glossary\n",
+			want:    "## Learned Facts\n\n- Run task test\n\n## Glossary\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, addFact(tt.content, "Run task test"))
+		})
+	}
+}
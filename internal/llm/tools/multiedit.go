@@ -166,7 +166,7 @@ func (m *multiEditTool) Run(ctx context.Context, call ToolCall) (ToolResponse, e
 	}
 
 	// Validate all edits before applying any
-	if err := m.validateEdits(params.Edits); err != nil {
+	if err := validateEdits(params.Edits); err != nil {
 		return NewTextErrorResponse(err.Error()), nil
 	}
 
@@ -192,11 +192,12 @@ func (m *multiEditTool) Run(ctx context.Context, call ToolCall) (ToolResponse, e
 	waitForLspDiagnostics(ctx, params.FilePath, m.lspClients)
 	text := fmt.Sprintf("<result>\n%s\n</result>\n", response.Content)
 	text += getDiagnostics(params.FilePath, m.lspClients)
+	text += checkFileGlossary(m.workingDir, params.FilePath)
 	response.Content = text
 	return response, nil
 }
 
-func (m *multiEditTool) validateEdits(edits []MultiEditOperation) error {
+func validateEdits(edits []MultiEditOperation) error {
 	for i, edit := range edits {
 		if edit.OldString == edit.NewString {
 			return fmt.Errorf("edit %d: old_string and new_string are identical", i+1)
@@ -235,7 +236,7 @@ func (m *multiEditTool) processMultiEditWithCreation(ctx context.Context, params
 	// Apply remaining edits to the content
 	for i := 1; i < len(params.Edits); i++ {
 		edit := params.Edits[i]
-		newContent, err := m.applyEditToContent(currentContent, edit)
+		newContent, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			return NewTextErrorResponse(fmt.Sprintf("edit %d failed: %s", i+1, err.Error())), nil
 		}
@@ -340,7 +341,7 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 
 	// Apply all edits sequentially
 	for i, edit := range params.Edits {
-		newContent, err := m.applyEditToContent(currentContent, edit)
+		newContent, err := applyEditToContent(currentContent, edit)
 		if err != nil {
 			return NewTextErrorResponse(fmt.Sprintf("edit %d failed: %s", i+1, err.Error())), nil
 		}
@@ -376,6 +377,10 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 	if !p {
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
+	currentContent, partial := reviewedContent(m.permissions, call.ID, currentContent)
+	if partial {
+		_, additions, removals = diff.GenerateDiff(oldContent, currentContent, strings.TrimPrefix(params.FilePath, m.workingDir))
+	}
 
 	// Write the updated content
 	err = os.WriteFile(params.FilePath, []byte(currentContent), 0o644)
@@ -409,7 +414,7 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 	recordFileRead(params.FilePath)
 
 	return WithResponseMetadata(
-		NewTextResponse(fmt.Sprintf("Applied %d edits to file: %s", len(params.Edits), params.FilePath)),
+		NewTextResponse(fmt.Sprintf("Applied %d edits to file: %s", len(params.Edits), params.FilePath)+rejectedNote(partial)),
 		MultiEditResponseMetadata{
 			OldContent:   oldContent,
 			NewContent:   currentContent,
@@ -420,7 +425,7 @@ func (m *multiEditTool) processMultiEditExistingFile(ctx context.Context, params
 	), nil
 }
 
-func (m *multiEditTool) applyEditToContent(content string, edit MultiEditOperation) (string, error) {
+func applyEditToContent(content string, edit MultiEditOperation) (string, error) {
 	if edit.OldString == "" && edit.NewString == "" {
 		return content, nil
 	}
@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+// The navigation tools ask the language servers about the structure of the
+// code, so the model reads the parts it needs instead of whole files.
+
+const maxNavigationResults = 100
+
+var symbolKindNames = map[protocol.SymbolKind]string{
+	protocol.File:          "file",
+	protocol.Module:        "module",
+	protocol.Namespace:     "namespace",
+	protocol.Package:       "package",
+	protocol.Class:         "class",
+	protocol.Method:        "method",
+	protocol.Property:      "property",
+	protocol.Field:         "field",
+	protocol.Constructor:   "constructor",
+	protocol.Enum:          "enum",
+	protocol.Interface:     "interface",
+	protocol.Function:      "function",
+	protocol.Variable:      "variable",
+	protocol.Constant:      "constant",
+	protocol.String:        "string",
+	protocol.Number:        "number",
+	protocol.Boolean:       "boolean",
+	protocol.Array:         "array",
+	protocol.Object:        "object",
+	protocol.Key:           "key",
+	protocol.Null:          "null",
+	protocol.EnumMember:    "enum member",
+	protocol.Struct:        "struct",
+	protocol.Event:         "event",
+	protocol.Operator:      "operator",
+	protocol.TypeParameter: "type parameter",
+}
+
+func symbolKindName(kind protocol.SymbolKind) string {
+	if name, ok := symbolKindNames[kind]; ok {
+		return name
+	}
+	return "symbol"
+}
+
+// SymbolPositionParams locate a symbol by the line it's on, as models don't
+// count columns reliably.
+type SymbolPositionParams struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Symbol   string `json:"symbol"`
+}
+
+var symbolPositionParameters = map[string]any{
+	"file_path": map[string]any{
+		"type":        "string",
+		"description": "The path of the file where the symbol is used or declared",
+	},
+	"line": map[string]any{
+		"type":        "integer",
+		"description": "The line of the symbol in the file, starting at 1",
+	},
+	"symbol": map[string]any{
+		"type":        "string",
+		"description": "The name of the symbol as written on the line",
+	},
+}
+
+// queryClients calls query with each LSP client, in the order of their names,
+// until one returns results. The servers of other languages fail or return
+// nothing.
+func queryClients[T any](ctx context.Context, lsps map[string]*lsp.Client, path string, query func(*lsp.Client) ([]T, error)) ([]T, error) {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(lsps)) {
+		client := lsps[name]
+		if path != "" {
+			if err := client.OpenFileOnDemand(ctx, path); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+		}
+		results, err := query(client)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if len(results) > 0 {
+			return results, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// symbolPosition returns the position of the symbol on the line of the file,
+// preferring the occurrences that are whole words.
+func symbolPosition(params SymbolPositionParams) (protocol.Position, error) {
+	if params.Symbol == "" {
+		return protocol.Position{}, errors.New("symbol is required")
+	}
+	content, err := os.ReadFile(params.FilePath)
+	if err != nil {
+		return protocol.Position{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	lines := strings.Split(string(content), "\n")
+	if params.Line < 1 || params.Line > len(lines) {
+		return protocol.Position{}, fmt.Errorf("line %d is out of the file, which has %d lines", params.Line, len(lines))
+	}
+	line := lines[params.Line-1]
+
+	column := -1
+	for offset := 0; ; {
+		i := strings.Index(line[offset:], params.Symbol)
+		if i < 0 {
+			break
+		}
+		i += offset
+		if column < 0 {
+			column = i
+		}
+		before, _ := utf8.DecodeLastRuneInString(line[:i])
+		after, _ := utf8.DecodeRuneInString(line[i+len(params.Symbol):])
+		if !isIdentifierRune(before) && !isIdentifierRune(after) {
+			column = i
+			break
+		}
+		offset = i + len(params.Symbol)
+	}
+	if column < 0 {
+		return protocol.Position{}, fmt.Errorf("%q not found on line %d: %s", params.Symbol, params.Line, strings.TrimSpace(line))
+	}
+	// Positions count UTF-16 code units.
+	return protocol.Position{
+		Line:      uint32(params.Line - 1),
+		Character: uint32(len(utf16.Encode([]rune(line[:column])))),
+	}, nil
+}
+
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// locationFormatter formats locations as the path relative to the working
+// directory and the line, with the text of the line.
+type locationFormatter struct {
+	workingDir string
+	files      map[string][]string
+}
+
+func newLocationFormatter(workingDir string) *locationFormatter {
+	return &locationFormatter{workingDir: workingDir, files: map[string][]string{}}
+}
+
+func (f *locationFormatter) path(uri protocol.DocumentURI) string {
+	path, err := uri.Path()
+	if err != nil {
+		return string(uri)
+	}
+	if rel, err := filepath.Rel(f.workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func (f *locationFormatter) format(uri protocol.DocumentURI, r protocol.Range) string {
+	location := fmt.Sprintf("%s:%d", f.path(uri), r.Start.Line+1)
+	if text := f.lineText(uri, int(r.Start.Line)); text != "" {
+		location += ": " + text
+	}
+	return location
+}
+
+func (f *locationFormatter) lineText(uri protocol.DocumentURI, line int) string {
+	path, err := uri.Path()
+	if err != nil {
+		return ""
+	}
+	lines, ok := f.files[path]
+	if !ok {
+		if content, err := os.ReadFile(path); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		f.files[path] = lines
+	}
+	if line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line])
+}
+
+// absPath returns the path relative to the working directory as absolute.
+func absPath(path, workingDir string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workingDir, path)
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbolPosition(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "main.go")
+	content := "package main\n\nfunc handle(h Handler) { h.handleAll() }\nvar s = \"é\" + handle\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	tests := []struct {
+		name     string
+		line     int
+		symbol   string
+		position protocol.Position
+		err      string
+	}{
+		{name: "first occurrence", line: 3, symbol: "handle", position: protocol.Position{Line: 2, Character: 5}},
+		{name: "whole word", line: 3, symbol: "h", position: protocol.Position{Line: 2, Character: 12}},
+		{name: "partial match fallback", line: 3, symbol: "handleA", position: protocol.Position{Line: 2, Character: 27}},
+		{name: "utf-16 columns", line: 4, symbol: "handle", position: protocol.Position{Line: 3, Character: 14}},
+		{name: "not found", line: 1, symbol: "handle", err: `"handle" not found on line 1`},
+		{name: "out of the file", line: 9, symbol: "handle", err: "line 9 is out of the file"},
+		{name: "no symbol", line: 1, err: "symbol is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			position, err := symbolPosition(SymbolPositionParams{FilePath: path, Line: tt.line, Symbol: tt.symbol})
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.position, position)
+		})
+	}
+}
+
+func TestOutlineDocumentSymbols(t *testing.T) {
+	t.Parallel()
+
+	lines := outlineDocumentSymbols(nil, []protocol.DocumentSymbol{
+		{
+			Name:  "Server",
+			Kind:  protocol.Struct,
+			Range: protocol.Range{Start: protocol.Position{Line: 9}, End: protocol.Position{Line: 14}},
+			Children: []protocol.DocumentSymbol{
+				{Name: "addr", Kind: protocol.Field, Detail: "string", Range: protocol.Range{Start: protocol.Position{Line: 10}, End: protocol.Position{Line: 10}}},
+			},
+		},
+		{Name: "Run", Kind: protocol.Method, Detail: "func() error", Range: protocol.Range{Start: protocol.Position{Line: 16}, End: protocol.Position{Line: 20}}},
+	}, 0)
+	require.Equal(t, []string{
+		"struct Server  L10-15",
+		"  field addr string  L11-11",
+		"method Run func() error  L17-21",
+	}, lines)
+}
+
+func TestLocationFormatter(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pkg", "a.go")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("package pkg\n\n\tfunc A() {}\n"), 0o644))
+
+	formatter := newLocationFormatter(dir)
+	location := formatter.format(protocol.URIFromPath(path), protocol.Range{Start: protocol.Position{Line: 2}})
+	require.Equal(t, filepath.Join("pkg", "a.go")+":3: func A() {}", location)
+}
+
+func TestNavigationToolsWithoutLSP(t *testing.T) {
+	t.Parallel()
+
+	for _, tool := range []BaseTool{
+		NewOutlineTool(nil, t.TempDir()),
+		NewSymbolsTool(nil, t.TempDir()),
+		NewDefinitionTool(nil, t.TempDir()),
+		NewReferencesTool(nil, t.TempDir()),
+		NewRenameTool(nil, nil, nil, t.TempDir()),
+		NewCodeActionTool(nil, nil, nil, t.TempDir()),
+	} {
+		response, err := tool.Run(t.Context(), ToolCall{Input: `{"file_path": "a.go", "line": 1, "symbol": "A", "query": "A"}`})
+		require.NoError(t, err)
+		require.True(t, response.IsError, tool.Name())
+		require.Equal(t, "no LSP clients available", response.Content)
+	}
+}
+
+func TestSymbolReference(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.go")
+	content := "package main\n\n// Serve serves.\nfunc (s *Server) Serve() error {\n\treturn nil\n}\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	symbol := Symbol{
+		BaseSymbolInformation: protocol.BaseSymbolInformation{Name: "Serve", Kind: protocol.Method, ContainerName: "Server"},
+		URI:                   protocol.URIFromPath(path),
+		Range:                 &protocol.Range{Start: protocol.Position{Line: 3}, End: protocol.Position{Line: 5, Character: 1}},
+	}
+	require.Equal(t, "Serve (method in Server) server.go:4", symbol.Label(dir))
+
+	reference, err := symbol.Reference(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "method Serve in Server, lines 4-6:\nfunc (s *Server) Serve() error {\n\treturn nil\n}", reference)
+
+	// Without a server to outline the file, only the line of the name.
+	symbol.Range = &protocol.Range{Start: protocol.Position{Line: 3, Character: 17}, End: protocol.Position{Line: 3, Character: 22}}
+	reference, err = symbol.Reference(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "method Serve in Server, line 4:\nfunc (s *Server) Serve() error {", reference)
+}
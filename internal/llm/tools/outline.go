@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type OutlineParams struct {
+	FilePath string `json:"file_path"`
+}
+
+type outlineTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	OutlineToolName    = "outline"
+	outlineDescription = `Lists the symbols of a file with their line ranges, as reported by the language server: types, functions, methods, fields, and so on, nested in their parents.
+
+WHEN TO USE THIS TOOL:
+- Use it to find your way in a large file before reading it, then view only the lines you need
+- Use it to see the API of a file: its types and their methods
+
+HOW TO USE:
+- Give the path of the file
+- Each line is the kind and name of a symbol, its detail such as a signature when the server gives one, and its lines
+
+LIMITATIONS:
+- Only works for the languages with a language server configured
+`
+)
+
+func NewOutlineTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &outlineTool{lspClients: lspClients, workingDir: workingDir}
+}
+
+func (o *outlineTool) Name() string {
+	return OutlineToolName
+}
+
+func (o *outlineTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        OutlineToolName,
+		Description: outlineDescription,
+		Parameters: map[string]any{
+			"file_path": map[string]any{
+				"type":        "string",
+				"description": "The path of the file to outline",
+			},
+		},
+		Required: []string{"file_path"},
+	}
+}
+
+func (o *outlineTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params OutlineParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if params.FilePath == "" {
+		return NewTextErrorResponse("file_path is required"), nil
+	}
+	if len(o.lspClients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+	path := absPath(params.FilePath, o.workingDir)
+
+	lines, err := queryClients(ctx, o.lspClients, path, func(client *lsp.Client) ([]string, error) {
+		result, err := client.DocumentSymbol(ctx, protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(path)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		switch symbols := result.Value.(type) {
+		case []protocol.DocumentSymbol:
+			lines = outlineDocumentSymbols(lines, symbols, 0)
+		case []protocol.SymbolInformation:
+			for _, symbol := range symbols {
+				line := fmt.Sprintf("%s %s", symbolKindName(symbol.Kind), symbol.Name)
+				if symbol.ContainerName != "" {
+					line += " in " + symbol.ContainerName
+				}
+				lines = append(lines, fmt.Sprintf("%s  L%d-%d", line, symbol.Location.Range.Start.Line+1, symbol.Location.Range.End.Line+1))
+			}
+		}
+		return lines, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to outline the file: %s", err)), nil
+	}
+	if len(lines) == 0 {
+		return NewTextResponse("No symbols found"), nil
+	}
+	if len(lines) > maxNavigationResults {
+		lines = append(lines[:maxNavigationResults], fmt.Sprintf("... and %d more symbols", len(lines)-maxNavigationResults))
+	}
+	return NewTextResponse(fmt.Sprintf("<outline>\n%s\n</outline>", strings.Join(lines, "\n"))), nil
+}
+
+// outlineDocumentSymbols appends the lines of the symbols and their children,
+// indented by their depth.
+func outlineDocumentSymbols(lines []string, symbols []protocol.DocumentSymbol, depth int) []string {
+	for _, symbol := range symbols {
+		line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", depth), symbolKindName(symbol.Kind), symbol.Name)
+		if detail := strings.TrimSpace(symbol.Detail); detail != "" && !strings.Contains(detail, "\n") {
+			line += " " + detail
+		}
+		lines = append(lines, fmt.Sprintf("%s  L%d-%d", line, symbol.Range.Start.Line+1, symbol.Range.End.Line+1))
+		lines = outlineDocumentSymbols(lines, symbol.Children, depth+1)
+	}
+	return lines
+}
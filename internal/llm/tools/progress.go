@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Progress is a progress update of a running tool call.
+type Progress struct {
+	SessionID  string `json:"session_id"`
+	ToolCallID string `json:"tool_call_id"`
+	// Message describes what the tool is currently doing.
+	Message string `json:"message,omitempty"`
+	// Current and Total are the units of work done and to do, e.g. bytes or
+	// tests. Total is 0 if unknown.
+	Current int64 `json:"current,omitempty"`
+	Total   int64 `json:"total,omitempty"`
+	// Output is the output of a command since its last update, in complete
+	// lines.
+	Output string `json:"output,omitempty"`
+}
+
+// Percent returns the completed percentage, or -1 if it's unknown.
+func (p Progress) Percent() float64 {
+	if p.Total <= 0 {
+		return -1
+	}
+	return min(100, float64(p.Current)/float64(p.Total)*100)
+}
+
+// ProgressFunc receives progress updates of a tool call.
+type ProgressFunc func(Progress)
+
+type progressContextKey struct{}
+
+// WithProgressFunc returns a context tools use to report their progress.
+func WithProgressFunc(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressContextKey{}, fn)
+}
+
+// ReportProgress reports the progress of the tool call running with ctx. It's
+// a no-op when nobody listens.
+func ReportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressContextKey{}).(ProgressFunc); ok && fn != nil {
+		fn(p)
+	}
+}
+
+// progressReader reports the progress of reading from r, e.g. a download.
+type progressReader struct {
+	ctx     context.Context
+	r       io.Reader
+	message string
+	current int64
+	total   int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.current += int64(n)
+	ReportProgress(pr.ctx, Progress{
+		Message: pr.message,
+		Current: pr.current,
+		Total:   pr.total,
+	})
+	return n, err
+}
+
+var (
+	// Matches "42%", as printed by many build tools and test runners.
+	percentPattern = regexp.MustCompile(`\b(\d{1,3})(?:\.\d+)?%`)
+	// Matches "[12/40]" or "(12/40)", as printed by e.g. ninja or pytest-xdist.
+	fractionPattern = regexp.MustCompile(`[\[(](\d+)\s*/\s*(\d+)[\])]`)
+)
+
+// outputProgressWriter reports the last line of command output as progress,
+// and extracts a percentage from it when there's one.
+type outputProgressWriter struct {
+	ctx  context.Context
+	mu   sync.Mutex
+	line []byte
+}
+
+func (w *outputProgressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.line = append(w.line, p...)
+	idx := bytes.LastIndexAny(w.line, "\r\n")
+	if idx == -1 {
+		return len(p), nil
+	}
+	// Report the last complete line, keep the rest for the next write.
+	complete := string(w.line[:idx])
+	w.line = append([]byte{}, w.line[idx+1:]...)
+	lines := strings.FieldsFunc(complete, func(r rune) bool {
+		return r == '\r' || r == '\n'
+	})
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			p := parseOutputProgress(line)
+			p.Output = strings.Join(lines, "\n") + "\n"
+			ReportProgress(w.ctx, p)
+			break
+		}
+	}
+	return len(p), nil
+}
+
+func parseOutputProgress(line string) Progress {
+	p := Progress{Message: line}
+	if m := fractionPattern.FindStringSubmatch(line); m != nil {
+		current, _ := strconv.ParseInt(m[1], 10, 64)
+		total, _ := strconv.ParseInt(m[2], 10, 64)
+		if total > 0 && current <= total {
+			p.Current, p.Total = current, total
+			return p
+		}
+	}
+	if m := percentPattern.FindStringSubmatch(line); m != nil {
+		percent, _ := strconv.ParseInt(m[1], 10, 64)
+		if percent <= 100 {
+			p.Current, p.Total = percent, 100
+		}
+	}
+	return p
+}
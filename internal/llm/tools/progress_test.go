@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		line    string
+		current int64
+		total   int64
+	}{
+		{"Compiling foo v0.1.0", 0, 0},
+		{"tests/test_api.py ....  [ 42%]", 42, 100},
+		{"[12/40] Building CXX object main.o", 12, 40},
+		{"Downloading 100.0%", 100, 100},
+		{"ratio 150%", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			t.Parallel()
+			p := parseOutputProgress(tt.line)
+			require.Equal(t, tt.line, p.Message)
+			require.Equal(t, tt.current, p.Current)
+			require.Equal(t, tt.total, p.Total)
+		})
+	}
+}
+
+func TestOutputProgressWriter(t *testing.T) {
+	t.Parallel()
+
+	var reported []Progress
+	ctx := WithProgressFunc(context.Background(), func(p Progress) {
+		reported = append(reported, p)
+	})
+	w := &outputProgressWriter{ctx: ctx}
+
+	fmt.Fprint(w, "first line\nsecond")
+	fmt.Fprint(w, " line\r 50%\rpartial")
+	fmt.Fprint(w, "\n\n")
+
+	require.Equal(t, []Progress{
+		{Message: "first line", Output: "first line\n"},
+		{Message: "50%", Current: 50, Total: 100, Output: "second line\n 50%\n"},
+		{Message: "partial", Output: "partial\n"},
+	}, reported)
+}
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type ReferencesParams struct {
+	SymbolPositionParams
+	IncludeDeclaration bool `json:"include_declaration,omitempty"`
+}
+
+type referencesTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	ReferencesToolName    = "references"
+	referencesDescription = `Finds the references to a symbol across the project with the language server, like find usages in an editor.
+
+WHEN TO USE THIS TOOL:
+- Use it to find the callers of a function or the uses of a type before changing it
+- Prefer it over grep, which also matches comments, strings, and other symbols of the same name
+
+HOW TO USE:
+- Give the file, the line the symbol is on, starting at 1, and the symbol as written on the line
+- Set include_declaration to list the declaration too
+- The result lists the path and line of each reference with the text of the line
+
+LIMITATIONS:
+- Only works for the languages with a language server configured
+- At most 100 references are listed
+`
+)
+
+func NewReferencesTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &referencesTool{lspClients: lspClients, workingDir: workingDir}
+}
+
+func (r *referencesTool) Name() string {
+	return ReferencesToolName
+}
+
+func (r *referencesTool) Info() ToolInfo {
+	parameters := map[string]any{
+		"include_declaration": map[string]any{
+			"type":        "boolean",
+			"description": "List the declaration of the symbol too, defaults to false",
+		},
+	}
+	for name, parameter := range symbolPositionParameters {
+		parameters[name] = parameter
+	}
+	return ToolInfo{
+		Name:        ReferencesToolName,
+		Description: referencesDescription,
+		Parameters:  parameters,
+		Required:    []string{"file_path", "line", "symbol"},
+	}
+}
+
+func (r *referencesTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params ReferencesParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if len(r.lspClients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+	params.FilePath = absPath(params.FilePath, r.workingDir)
+	position, err := symbolPosition(params.SymbolPositionParams)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	references, err := queryClients(ctx, r.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.Location, error) {
+		return client.References(ctx, protocol.ReferenceParams{
+			Context: protocol.ReferenceContext{IncludeDeclaration: params.IncludeDeclaration},
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+				Position:     position,
+			},
+		})
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to find the references: %s", err)), nil
+	}
+	if len(references) == 0 {
+		return NewTextResponse(fmt.Sprintf("No references found for %s", params.Symbol)), nil
+	}
+
+	formatter := newLocationFormatter(r.workingDir)
+	files := map[protocol.DocumentURI]bool{}
+	var lines []string
+	for _, location := range references {
+		files[location.URI] = true
+		if len(lines) < maxNavigationResults {
+			lines = append(lines, formatter.format(location.URI, location.Range))
+		}
+	}
+	if len(references) > maxNavigationResults {
+		lines = append(lines, fmt.Sprintf("... and %d more references", len(references)-maxNavigationResults))
+	}
+	return NewTextResponse(fmt.Sprintf("%d references in %d files:\n%s", len(references), len(files), strings.Join(lines, "\n"))), nil
+}
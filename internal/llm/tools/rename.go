@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type RenameParams struct {
+	SymbolPositionParams
+	NewName string `json:"new_name"`
+}
+
+type renameTool struct {
+	changeSetWriter
+}
+
+const (
+	RenameToolName    = "rename"
+	renameDescription = `Renames a symbol across the project with the language server, like rename in an editor: the declaration and every reference are changed, and nothing else.
+
+WHEN TO USE THIS TOOL:
+- Use it to rename a function, type, method, variable, or field everywhere it's used
+- Prefer it over editing the files one by one or replacing text, which misses references and changes comments, strings, and other symbols of the same name
+
+HOW TO USE:
+- Give the file, the line the symbol is on, starting at 1, and the symbol as written on the line, at its declaration or any of its uses
+- Give the new name of the symbol
+- The user reviews the changes of all the files at once, and can undo them together
+- The result lists the changed files with the diagnostics of the project
+
+LIMITATIONS:
+- Only works for the languages with a language server configured
+- The files aren't renamed, only their content is changed
+`
+)
+
+func NewRenameTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &renameTool{
+		changeSetWriter: changeSetWriter{
+			lspClients:  lspClients,
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (r *renameTool) Name() string {
+	return RenameToolName
+}
+
+func (r *renameTool) Info() ToolInfo {
+	parameters := map[string]any{
+		"new_name": map[string]any{
+			"type":        "string",
+			"description": "The new name of the symbol",
+		},
+	}
+	for name, parameter := range symbolPositionParameters {
+		parameters[name] = parameter
+	}
+	return ToolInfo{
+		Name:        RenameToolName,
+		Description: renameDescription,
+		Parameters:  parameters,
+		Required:    []string{"file_path", "line", "symbol", "new_name"},
+	}
+}
+
+func (r *renameTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params RenameParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if len(r.lspClients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+	if params.NewName == "" {
+		return NewTextErrorResponse("new_name is required"), nil
+	}
+	if params.NewName == params.Symbol {
+		return NewTextErrorResponse("new_name is the current name of the symbol"), nil
+	}
+	params.FilePath = absPath(params.FilePath, r.workingDir)
+	position, err := symbolPosition(params.SymbolPositionParams)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	edits, err := queryClients(ctx, r.lspClients, params.FilePath, func(client *lsp.Client) ([]protocol.WorkspaceEdit, error) {
+		edit, err := client.Rename(ctx, protocol.RenameParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: protocol.URIFromPath(params.FilePath)},
+			Position:     position,
+			NewName:      params.NewName,
+		})
+		if err != nil || (len(edit.Changes) == 0 && len(edit.DocumentChanges) == 0) {
+			return nil, err
+		}
+		return []protocol.WorkspaceEdit{edit}, nil
+	})
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to rename %s: %s", params.Symbol, err)), nil
+	}
+	if len(edits) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("the language server can't rename %s", params.Symbol)), nil
+	}
+	changes, err := workspaceEditChanges(edits[0], r.workingDir)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to rename %s: %s", params.Symbol, err)), nil
+	}
+	if len(changes) == 0 {
+		return NewTextErrorResponse(fmt.Sprintf("renaming %s doesn't change any file", params.Symbol)), nil
+	}
+
+	return r.write(ctx, call,
+		fmt.Sprintf("Rename %s to %s in %d files", params.Symbol, params.NewName, len(changes)),
+		fmt.Sprintf("Renamed %s to %s in %d files", params.Symbol, params.NewName, len(changes)),
+		changes)
+}
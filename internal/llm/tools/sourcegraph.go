@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
 )
 
 type SourcegraphParams struct {
@@ -255,16 +257,17 @@ func (t *sourcegraphTool) Run(ctx context.Context, call ToolCall) (ToolResponse,
 		return ToolResponse{}, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	formattedResults, err := formatSourcegraphResults(result, params.ContextWindow)
+	formattedResults, citations, err := formatSourcegraphResults(result, params.ContextWindow)
 	if err != nil {
 		return NewTextErrorResponse("Failed to format results: " + err.Error()), nil
 	}
 
-	return NewTextResponse(formattedResults), nil
+	return WithResponseCitations(NewTextResponse(formattedResults), citations...), nil
 }
 
-func formatSourcegraphResults(result map[string]any, contextWindow int) (string, error) {
+func formatSourcegraphResults(result map[string]any, contextWindow int) (string, []message.Citation, error) {
 	var buffer strings.Builder
+	var citations []message.Citation
 
 	if errors, ok := result["errors"].([]any); ok && len(errors) > 0 {
 		buffer.WriteString("## Sourcegraph API Error\n\n")
@@ -275,22 +278,22 @@ func formatSourcegraphResults(result map[string]any, contextWindow int) (string,
 				}
 			}
 		}
-		return buffer.String(), nil
+		return buffer.String(), nil, nil
 	}
 
 	data, ok := result["data"].(map[string]any)
 	if !ok {
-		return "", fmt.Errorf("invalid response format: missing data field")
+		return "", nil, fmt.Errorf("invalid response format: missing data field")
 	}
 
 	search, ok := data["search"].(map[string]any)
 	if !ok {
-		return "", fmt.Errorf("invalid response format: missing search field")
+		return "", nil, fmt.Errorf("invalid response format: missing search field")
 	}
 
 	searchResults, ok := search["results"].(map[string]any)
 	if !ok {
-		return "", fmt.Errorf("invalid response format: missing results field")
+		return "", nil, fmt.Errorf("invalid response format: missing results field")
 	}
 
 	matchCount, _ := searchResults["matchCount"].(float64)
@@ -309,7 +312,7 @@ func formatSourcegraphResults(result map[string]any, contextWindow int) (string,
 	results, ok := searchResults["results"].([]any)
 	if !ok || len(results) == 0 {
 		buffer.WriteString("No results found. Try a different query.\n")
-		return buffer.String(), nil
+		return buffer.String(), nil, nil
 	}
 
 	maxResults := 10
@@ -347,6 +350,20 @@ func formatSourcegraphResults(result map[string]any, contextWindow int) (string,
 			buffer.WriteString(fmt.Sprintf("URL: %s\n\n", fileURL))
 		}
 
+		if fileURL != "" {
+			citation := message.Citation{
+				Source: "https://sourcegraph.com" + fileURL,
+				Title:  repoName + "/" + filePath,
+			}
+			if len(lineMatches) > 0 {
+				if lineMatch, ok := lineMatches[0].(map[string]any); ok {
+					lineNumber, _ := lineMatch["lineNumber"].(float64)
+					citation.Location = fmt.Sprintf("line %d", int(lineNumber)+1)
+				}
+			}
+			citations = append(citations, citation)
+		}
+
 		if len(lineMatches) > 0 {
 			for _, lm := range lineMatches {
 				lineMatch, ok := lm.(map[string]any)
@@ -354,7 +371,9 @@ func formatSourcegraphResults(result map[string]any, contextWindow int) (string,
 					continue
 				}
 
+				// Sourcegraph counts the lines from 0.
 				lineNumber, _ := lineMatch["lineNumber"].(float64)
+				line := int(lineNumber) + 1
 				preview, _ := lineMatch["preview"].(string)
 
 				if fileContent != "" {
@@ -362,19 +381,19 @@ func formatSourcegraphResults(result map[string]any, contextWindow int) (string,
 
 					buffer.WriteString("```\n")
 
-					startLine := max(1, int(lineNumber)-contextWindow)
+					startLine := max(1, line-contextWindow)
 
-					for j := startLine - 1; j < int(lineNumber)-1 && j < len(lines); j++ {
+					for j := startLine - 1; j < line-1 && j < len(lines); j++ {
 						if j >= 0 {
 							buffer.WriteString(fmt.Sprintf("%d| %s\n", j+1, lines[j]))
 						}
 					}
 
-					buffer.WriteString(fmt.Sprintf("%d|  %s\n", int(lineNumber), preview))
+					buffer.WriteString(fmt.Sprintf("%d|  %s\n", line, preview))
 
-					endLine := int(lineNumber) + contextWindow
+					endLine := line + contextWindow
 
-					for j := int(lineNumber); j < endLine && j < len(lines); j++ {
+					for j := line; j < endLine && j < len(lines); j++ {
 						if j < len(lines) {
 							buffer.WriteString(fmt.Sprintf("%d| %s\n", j+1, lines[j]))
 						}
@@ -383,12 +402,12 @@ func formatSourcegraphResults(result map[string]any, contextWindow int) (string,
 					buffer.WriteString("```\n\n")
 				} else {
 					buffer.WriteString("```\n")
-					buffer.WriteString(fmt.Sprintf("%d| %s\n", int(lineNumber), preview))
+					buffer.WriteString(fmt.Sprintf("%d| %s\n", line, preview))
 					buffer.WriteString("```\n\n")
 				}
 			}
 		}
 	}
 
-	return buffer.String(), nil
+	return buffer.String(), citations, nil
 }
@@ -0,0 +1,209 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/sqlquery"
+)
+
+type SQLParams struct {
+	Action   string `json:"action"`
+	Database string `json:"database,omitempty"`
+	Query    string `json:"query,omitempty"`
+	Table    string `json:"table,omitempty"`
+	Write    bool   `json:"write,omitempty"`
+}
+
+type SQLPermissionsParams struct {
+	Database string `json:"database"`
+	Query    string `json:"query"`
+}
+
+type sqlTool struct {
+	databases   []*sqlquery.DB
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	SQLToolName = "sql"
+
+	sqlActionSchema = "schema"
+	sqlActionQuery  = "query"
+
+	sqlToolDescription = `Queries the databases of the project, and describes their tables.
+
+WHEN TO USE THIS TOOL:
+- Use to check the actual schema of a table before writing a migration or a query
+- Helpful to look at the data an application stores while debugging it
+- Useful to check that a migration was applied as expected
+
+HOW TO USE:
+- Use the schema action to list the tables and their columns, or set a table to describe it with its indexes and constraints
+- Use the query action to run a SQL statement, in the dialect of the database
+- Set the database when the project has several
+
+FEATURES:
+- Queries run in read-only transactions, so they can't modify the database
+- Set write to true to run statements modifying the database, after the user allows it
+- Results are returned as a table of at most %d rows
+
+LIMITATIONS:
+- A query without write must be a single SELECT, WITH, SHOW, EXPLAIN, DESCRIBE, or PRAGMA statement
+- Backslash commands of psql and mysql, like \d, aren't supported, only SQL statements
+- Long values are cut
+
+TIPS:
+- Add a LIMIT to queries of large tables
+- Prefer writing migrations in the project over modifying the database with this tool
+- Check the schema rather than guessing the names of tables and columns
+
+DATABASES:
+%s`
+)
+
+func NewSQLTool(permissions permission.Service, databases []*sqlquery.DB, workingDir string) BaseTool {
+	return &sqlTool{
+		databases:   databases,
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *sqlTool) Name() string {
+	return SQLToolName
+}
+
+func (t *sqlTool) Info() ToolInfo {
+	var databases strings.Builder
+	names := make([]string, 0, len(t.databases))
+	for _, db := range t.databases {
+		names = append(names, db.Name)
+		fmt.Fprintf(&databases, "- %s (%s)", db.Name, db.Driver)
+		if db.Description != "" {
+			fmt.Fprintf(&databases, ": %s", db.Description)
+		}
+		databases.WriteString("\n")
+	}
+	return ToolInfo{
+		Name:        SQLToolName,
+		Description: fmt.Sprintf(sqlToolDescription, sqlquery.MaxRows, strings.TrimSuffix(databases.String(), "\n")),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum":        []string{sqlActionSchema, sqlActionQuery},
+			},
+			"database": map[string]any{
+				"type":        "string",
+				"description": "The name of the database, required when the project has several",
+				"enum":        names,
+			},
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The SQL statement to run (query)",
+			},
+			"table": map[string]any{
+				"type":        "string",
+				"description": "The table to describe, optionally qualified by its schema (schema)",
+			},
+			"write": map[string]any{
+				"type":        "boolean",
+				"description": "Set to true to run a statement modifying the database (query)",
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *sqlTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SQLParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse sql parameters: " + err.Error()), nil
+	}
+
+	db, err := t.database(params.Database)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	switch params.Action {
+	case sqlActionSchema:
+		schema, err := db.Schema(ctx, params.Table)
+		if err != nil {
+			return NewTextErrorResponse(err.Error()), nil
+		}
+		return NewTextResponse(schema), nil
+	case sqlActionQuery:
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("Unknown action %q, expected schema or query", params.Action)), nil
+	}
+
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return NewTextErrorResponse("Query parameter is required"), nil
+	}
+
+	if params.Write {
+		// Writes to read-only databases would fail once approved.
+		if db.ReadOnly() {
+			return NewTextErrorResponse(sqlquery.ErrReadOnly.Error()), nil
+		}
+		sessionID, messageID := GetContextValues(ctx)
+		if sessionID == "" || messageID == "" {
+			return ToolResponse{}, fmt.Errorf("session ID and message ID are required for writing to a database")
+		}
+		p := t.permissions.Request(
+			permission.CreatePermissionRequest{
+				SessionID:   sessionID,
+				Path:        t.workingDir,
+				ToolCallID:  call.ID,
+				ToolName:    SQLToolName,
+				Action:      "write",
+				Description: fmt.Sprintf("Modify the %s database %s with: %s", db.Driver, db.Name, params.Query),
+				Params: SQLPermissionsParams{
+					Database: db.Name,
+					Query:    params.Query,
+				},
+			},
+		)
+		if !p {
+			return ToolResponse{}, permission.ErrorPermissionDenied
+		}
+	}
+
+	result, err := db.Query(ctx, params.Query, params.Write)
+	switch {
+	case errors.Is(err, sqlquery.ErrWrite):
+		return NewTextErrorResponse(err.Error() + ", set write to true to run statements modifying the database"), nil
+	case err != nil:
+		if ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	return NewTextResponse(result.String()), nil
+}
+
+func (t *sqlTool) database(name string) (*sqlquery.DB, error) {
+	if name == "" && len(t.databases) == 1 {
+		return t.databases[0], nil
+	}
+	if i := slices.IndexFunc(t.databases, func(db *sqlquery.DB) bool { return db.Name == name }); i >= 0 {
+		return t.databases[i], nil
+	}
+	names := make([]string, 0, len(t.databases))
+	for _, db := range t.databases {
+		names = append(names, db.Name)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("database parameter is required, one of %s", strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("unknown database %q, expected one of %s", name, strings.Join(names, ", "))
+}
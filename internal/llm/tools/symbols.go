@@ -0,0 +1,227 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+)
+
+type SymbolsParams struct {
+	Query string `json:"query"`
+}
+
+type symbolsTool struct {
+	lspClients map[string]*lsp.Client
+	workingDir string
+}
+
+const (
+	SymbolsToolName    = "symbols"
+	symbolsDescription = `Searches the symbols of the whole project by name with the language servers, and tells where they are declared.
+
+WHEN TO USE THIS TOOL:
+- Use it to find where a type, function, or method is declared when you know its name or part of it
+- Prefer it over grep to find declarations: it skips the comments, strings, and uses
+
+HOW TO USE:
+- Give the name, or a part of it, to search
+- Each line is the kind and name of a symbol, its container such as the type of a method, and where it's declared
+
+LIMITATIONS:
+- Only works for the languages with a language server configured
+- Servers match the query in their own way, usually fuzzily
+`
+)
+
+func NewSymbolsTool(lspClients map[string]*lsp.Client, workingDir string) BaseTool {
+	return &symbolsTool{lspClients: lspClients, workingDir: workingDir}
+}
+
+func (s *symbolsTool) Name() string {
+	return SymbolsToolName
+}
+
+func (s *symbolsTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        SymbolsToolName,
+		Description: symbolsDescription,
+		Parameters: map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The name of the symbols to search",
+			},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (s *symbolsTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params SymbolsParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("error parsing parameters: %s", err)), nil
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return NewTextErrorResponse("query is required"), nil
+	}
+	if len(s.lspClients) == 0 {
+		return NewTextErrorResponse("no LSP clients available"), nil
+	}
+
+	locations := newLocationFormatter(s.workingDir)
+	var lines []string
+	for _, symbol := range FindSymbols(ctx, s.lspClients, params.Query) {
+		location := locations.path(symbol.URI)
+		if symbol.Range != nil {
+			location = locations.format(symbol.URI, *symbol.Range)
+		}
+		lines = append(lines, formatSymbol(symbol.BaseSymbolInformation, location))
+	}
+	if len(lines) == 0 {
+		return NewTextResponse(fmt.Sprintf("No symbols matching %q found", params.Query)), nil
+	}
+	if len(lines) > maxNavigationResults {
+		lines = append(lines[:maxNavigationResults], fmt.Sprintf("... and %d more symbols, refine the query", len(lines)-maxNavigationResults))
+	}
+	return NewTextResponse(fmt.Sprintf("<symbols>\n%s\n</symbols>", strings.Join(lines, "\n"))), nil
+}
+
+func formatSymbol(symbol protocol.BaseSymbolInformation, location string) string {
+	line := fmt.Sprintf("%s %s", symbolKindName(symbol.Kind), symbol.Name)
+	if symbol.ContainerName != "" {
+		line += " in " + symbol.ContainerName
+	}
+	return line + " - " + location
+}
+
+// Symbol is a symbol of the project found by the language servers.
+type Symbol struct {
+	protocol.BaseSymbolInformation
+	URI protocol.DocumentURI
+	// Range is nil when the server only tells the file of the symbol.
+	Range *protocol.Range
+}
+
+// FindSymbols returns the symbols of the project matching the query. Each
+// server knows the symbols of its language, the results of all of them are
+// returned.
+func FindSymbols(ctx context.Context, lspClients map[string]*lsp.Client, query string) []Symbol {
+	var found []Symbol
+	for _, name := range slices.Sorted(maps.Keys(lspClients)) {
+		result, err := lspClients[name].Symbol(ctx, protocol.WorkspaceSymbolParams{Query: query})
+		if err != nil {
+			continue
+		}
+		switch symbols := result.Value.(type) {
+		case []protocol.SymbolInformation:
+			for _, symbol := range symbols {
+				found = append(found, Symbol{
+					BaseSymbolInformation: protocol.BaseSymbolInformation{Name: symbol.Name, Kind: symbol.Kind, ContainerName: symbol.ContainerName},
+					URI:                   symbol.Location.URI,
+					Range:                 &symbol.Location.Range,
+				})
+			}
+		case []protocol.WorkspaceSymbol:
+			for _, symbol := range symbols {
+				f := Symbol{BaseSymbolInformation: symbol.BaseSymbolInformation}
+				switch l := symbol.Location.Value.(type) {
+				case protocol.Location:
+					f.URI, f.Range = l.URI, &l.Range
+				case protocol.LocationUriOnly:
+					f.URI = l.URI
+				}
+				found = append(found, f)
+			}
+		}
+	}
+	return found
+}
+
+// maxReferenceLines is the number of lines of the declaration of a symbol
+// given in its reference.
+const maxReferenceLines = 200
+
+// Label returns the name, kind, and location of the symbol.
+func (s Symbol) Label(workingDir string) string {
+	label := fmt.Sprintf("%s (%s", s.Name, symbolKindName(s.Kind))
+	if s.ContainerName != "" {
+		label += " in " + s.ContainerName
+	}
+	label += ") " + newLocationFormatter(workingDir).path(s.URI)
+	if s.Range != nil {
+		label += fmt.Sprintf(":%d", s.Range.Start.Line+1)
+	}
+	return label
+}
+
+// Reference returns the symbol and the code of its declaration, for a
+// prompt to refer to it. The servers giving the range of the name only are
+// asked for the range of the whole declaration.
+func (s Symbol) Reference(ctx context.Context, lspClients map[string]*lsp.Client) (string, error) {
+	path, err := s.URI.Path()
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(string(content), "\n")
+	start, end := 0, len(lines)-1
+	if s.Range != nil {
+		start, end = int(s.Range.Start.Line), int(s.Range.End.Line)
+		if start == end {
+			if declaration, ok := s.declaration(ctx, lspClients, path); ok {
+				start, end = int(declaration.Start.Line), int(declaration.End.Line)
+			}
+		}
+	}
+	end = min(end, start+maxReferenceLines-1, len(lines)-1)
+	if start > end {
+		return "", fmt.Errorf("%s is not in %s anymore", s.Name, path)
+	}
+
+	reference := fmt.Sprintf("%s %s", symbolKindName(s.Kind), s.Name)
+	if s.ContainerName != "" {
+		reference += " in " + s.ContainerName
+	}
+	if start == end {
+		reference += fmt.Sprintf(", line %d:", start+1)
+	} else {
+		reference += fmt.Sprintf(", lines %d-%d:", start+1, end+1)
+	}
+	return reference + "\n" + strings.Join(lines[start:end+1], "\n"), nil
+}
+
+// declaration returns the range of the declaration of the symbol in the
+// outline of its file.
+func (s Symbol) declaration(ctx context.Context, lspClients map[string]*lsp.Client, path string) (protocol.Range, bool) {
+	ranges, _ := queryClients(ctx, lspClients, path, func(client *lsp.Client) ([]protocol.Range, error) {
+		result, err := client.DocumentSymbol(ctx, protocol.DocumentSymbolParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: s.URI},
+		})
+		if err != nil {
+			return nil, err
+		}
+		symbols, _ := result.Value.([]protocol.DocumentSymbol)
+		var ranges []protocol.Range
+		for len(symbols) > 0 {
+			symbol := symbols[0]
+			symbols = append(symbols[1:], symbol.Children...)
+			if symbol.Name == s.Name && symbol.SelectionRange.Start.Line == s.Range.Start.Line {
+				ranges = append(ranges, symbol.Range)
+			}
+		}
+		return ranges, nil
+	})
+	if len(ranges) == 0 {
+		return protocol.Range{}, false
+	}
+	return ranges[0], true
+}
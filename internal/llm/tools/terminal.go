@@ -0,0 +1,246 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+type TerminalParams struct {
+	Action string `json:"action"`
+	Input  string `json:"input,omitempty"`
+	Wait   int    `json:"wait,omitempty"`
+}
+
+type TerminalPermissionsParams struct {
+	Input string `json:"input"`
+}
+
+type terminalTool struct {
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	TerminalToolName = "terminal"
+
+	terminalActionRun   = "run"
+	terminalActionSend  = "send"
+	terminalActionRead  = "read"
+	terminalActionClose = "close"
+
+	// terminalDefaultWait is the seconds an action waits for output unless
+	// the model sets wait.
+	terminalDefaultWait = 10
+	// terminalMaxWait caps the seconds an action waits for output.
+	terminalMaxWait = 120
+	// terminalIdle is how long the output has to stop before it's returned.
+	terminalIdle = 500 * time.Millisecond
+
+	terminalToolDescription = `Runs commands in an interactive shell in a terminal, which stays open for the session.
+
+WHEN TO USE THIS TOOL:
+- Use when the commands depend on each other's state, like activating a virtualenv or sourcing a script before running more commands
+- Use to answer the prompts of programs, like y/N confirmations, passwords of test accounts, or the questions of project generators
+- Use to drive REPLs and interactive programs
+- Use the bash tool for the other commands, it's faster and safer
+
+HOW TO USE:
+- The run action types the input and presses Enter, then returns the output
+- The send action types the input as is, for keys without Enter: use "\u0003" for Ctrl-C, "\u0004" for Ctrl-D, "\u0012" for Ctrl-R, and "\n" for Enter
+- The read action returns the output that came since the last action, e.g. of a long command
+- The close action stops the shell and the programs it runs, the next run starts a new one
+- Actions return once the output stopped for a moment, or after wait seconds (default %d, up to %d)
+
+LIMITATIONS:
+- The terminal is a real shell: the commands aren't checked like those of the bash tool, and every input needs permission
+- The output has no colors, and full-screen programs like editors and pagers don't work
+- The middle of outputs longer than %d characters is cut
+
+TIPS:
+- Read the output of long commands until the prompt "$ " comes back before sending more input
+- Close the terminal once you're done with it`
+)
+
+// terminals are the terminals of the sessions.
+var terminals = struct {
+	sync.Mutex
+	bySession map[string]*shell.Terminal
+}{bySession: make(map[string]*shell.Terminal)}
+
+// CloseTerminals stops the terminals of all the sessions, when crush exits.
+func CloseTerminals() {
+	terminals.Lock()
+	open := terminals.bySession
+	terminals.bySession = make(map[string]*shell.Terminal)
+	terminals.Unlock()
+	for sessionID, t := range open {
+		if err := t.Close(); err != nil {
+			slog.Error("Failed to close the terminal", "session", sessionID, "error", err)
+		}
+	}
+}
+
+func NewTerminalTool(permissions permission.Service, workingDir string) BaseTool {
+	return &terminalTool{
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *terminalTool) Name() string {
+	return TerminalToolName
+}
+
+func (t *terminalTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        TerminalToolName,
+		Description: fmt.Sprintf(terminalToolDescription, terminalDefaultWait, terminalMaxWait, bashMaxOutput()),
+		Parameters: map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"description": "The action to perform",
+				"enum":        []string{terminalActionRun, terminalActionSend, terminalActionRead, terminalActionClose},
+			},
+			"input": map[string]any{
+				"type":        "string",
+				"description": "The command to run, or the keys to send (run, send)",
+			},
+			"wait": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("Seconds to wait for output, up to %d (run, send, read)", terminalMaxWait),
+			},
+		},
+		Required: []string{"action"},
+	}
+}
+
+func (t *terminalTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params TerminalParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	sessionID, _ := GetContextValues(ctx)
+	if sessionID == "" {
+		return ToolResponse{}, errors.New("session ID is required for the terminal tool")
+	}
+
+	switch params.Action {
+	case terminalActionClose:
+		terminals.Lock()
+		term, ok := terminals.bySession[sessionID]
+		delete(terminals.bySession, sessionID)
+		terminals.Unlock()
+		if !ok {
+			return NewTextResponse("No terminal is open"), nil
+		}
+		if err := term.Close(); err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("failed to close the terminal: %v", err)), nil
+		}
+		return NewTextResponse("Closed the terminal"), nil
+	case terminalActionRead:
+		terminals.Lock()
+		term, ok := terminals.bySession[sessionID]
+		terminals.Unlock()
+		if !ok {
+			return NewTextErrorResponse("no terminal is open, start one with the run action"), nil
+		}
+		return t.read(ctx, sessionID, call.ID, term, params.Wait), nil
+	case terminalActionRun, terminalActionSend:
+	default:
+		return NewTextErrorResponse(fmt.Sprintf("unknown action %q", params.Action)), nil
+	}
+
+	if params.Input == "" && params.Action == terminalActionRun {
+		return NewTextErrorResponse("missing input"), nil
+	}
+	input := params.Input
+	if params.Action == terminalActionRun {
+		input += "\n"
+	}
+	destructiveReason, _ := permission.CheckDestructiveCommand(params.Input, t.workingDir)
+	if !t.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:         sessionID,
+		Path:              t.workingDir,
+		ToolCallID:        call.ID,
+		ToolName:          TerminalToolName,
+		Action:            "execute",
+		Description:       fmt.Sprintf("Send to the terminal: %q", input),
+		Params:            TerminalPermissionsParams{Input: input},
+		DestructiveReason: destructiveReason,
+	}) {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	term, err := t.terminal(sessionID)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to start the terminal: %v", err)), nil
+	}
+	if err := term.Write(input); err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("failed to write to the terminal: %v", err)), nil
+	}
+	return t.read(ctx, sessionID, call.ID, term, params.Wait), nil
+}
+
+// terminal returns the terminal of the session, started in the directory
+// and with the environment of the persistent shell when there's none.
+func (t *terminalTool) terminal(sessionID string) (*shell.Terminal, error) {
+	terminals.Lock()
+	defer terminals.Unlock()
+	if term, ok := terminals.bySession[sessionID]; ok {
+		return term, nil
+	}
+	sandbox, err := NewSandbox(t.workingDir)
+	if err != nil {
+		return nil, err
+	}
+	persistentShell := shell.GetPersistentShell(t.workingDir)
+	term, err := shell.StartTerminal(shell.TerminalOptions{
+		WorkingDir: persistentShell.GetWorkingDir(),
+		Env:        persistentShell.GetEnv(),
+		Sandbox:    sandbox,
+	})
+	if err != nil {
+		return nil, err
+	}
+	terminals.bySession[sessionID] = term
+	return term, nil
+}
+
+// read returns the new output of the terminal, and forgets the terminal
+// once its shell exited.
+func (t *terminalTool) read(ctx context.Context, sessionID, callID string, term *shell.Terminal, wait int) ToolResponse {
+	if wait <= 0 {
+		wait = terminalDefaultWait
+	}
+	output := term.Read(ctx, time.Duration(min(wait, terminalMaxWait))*time.Second, terminalIdle)
+	if limit := bashMaxOutput(); len(output) > limit {
+		saved := saveOutput(sessionID, callID, output, "")
+		output = truncateOutputTo(output, limit)
+		if saved != "" {
+			output += fmt.Sprintf("\n\n[The output was cut, the full output is in %s]", saved)
+		}
+	}
+	if output == "" {
+		output = "no new output"
+	}
+
+	select {
+	case <-term.Done():
+		terminals.Lock()
+		if terminals.bySession[sessionID] == term {
+			delete(terminals.bySession, sessionID)
+		}
+		terminals.Unlock()
+		output += fmt.Sprintf("\n\n[The terminal exited with code %d, the next run starts a new one]", shell.ExitCode(term.Err()))
+	default:
+	}
+	return NewTextResponse(output)
+}
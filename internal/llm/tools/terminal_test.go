@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runTerminal(t *testing.T, tool BaseTool, ctx context.Context, params TerminalParams) ToolResponse {
+	t.Helper()
+	if params.Wait == 0 {
+		params.Wait = 5
+	}
+	input, err := json.Marshal(params)
+	require.NoError(t, err)
+	resp, err := tool.Run(ctx, ToolCall{ID: "call", Name: TerminalToolName, Input: string(input)})
+	require.NoError(t, err)
+	require.False(t, resp.IsError, resp.Content)
+	return resp
+}
+
+func TestTerminalTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("terminals need a Unix pseudo-terminal")
+	}
+	ctx, dir, permissions, _ := newFileToolTest(t, nil)
+	tool := NewTerminalTool(permissions, dir)
+	t.Cleanup(CloseTerminals)
+
+	runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionRun, Input: "export STAGE=test"})
+	resp := runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionRun, Input: "echo stage=$STAGE"})
+	require.Contains(t, resp.Content, "stage=test\n")
+
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionRun, Input: `read -p "Overwrite? [y/N] " answer; echo "got $answer"`})
+	require.Contains(t, resp.Content, "Overwrite? [y/N]")
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionSend, Input: "y\n"})
+	require.Contains(t, resp.Content, "got y\n")
+
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionRead, Wait: 1})
+	require.Equal(t, "no new output", resp.Content)
+
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionRun, Input: "exit"})
+	require.Contains(t, resp.Content, "[The terminal exited with code 0")
+
+	// The next run starts a new terminal, without the state of the last one.
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionRun, Input: "echo stage=$STAGE."})
+	require.Contains(t, resp.Content, "stage=.\n")
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionClose})
+	require.Equal(t, "Closed the terminal", resp.Content)
+	resp = runTerminal(t, tool, ctx, TerminalParams{Action: terminalActionClose})
+	require.Equal(t, "No terminal is open", resp.Content)
+}
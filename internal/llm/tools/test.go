@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/testrunner"
+)
+
+type TestParams struct {
+	Path    string `json:"path,omitempty"`
+	Run     string `json:"run,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
+}
+
+type TestPermissionsParams struct {
+	Runner string `json:"runner"`
+	Path   string `json:"path,omitempty"`
+	Run    string `json:"run,omitempty"`
+}
+
+type testTool struct {
+	runner      string
+	args        []string
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	TestToolName = "test"
+
+	testDefaultTimeout = 5 * 60 * 1000 // 5 minutes in milliseconds
+
+	testToolDescription = `Runs the tests of the project with %s and reports the failing tests with their file, line, and message, rather than the whole output of the runner.
+
+WHEN TO USE THIS TOOL:
+- Use to check that changes didn't break anything, instead of running the tests through the bash tool
+- Use after fixing a failure to rerun only the failing tests, until they pass
+
+HOW TO USE:
+- Without parameters, all the tests of the project run
+- Set path to a %s to only run its tests
+- Set run to %s to only run the matching tests
+
+FEATURES:
+- Failures include build errors and errors collecting tests, with their location
+- Only the failing tests are reported, along with the number of passing and skipped ones
+- Running tests needs the permission of the user, which can be given for the session
+
+LIMITATIONS:
+- The timeout defaults to 5 minutes and can't exceed 10 minutes
+- Messages of failures are cut after a few lines
+
+TIPS:
+- Read the code at the file and line of a failure before fixing it
+- Rerun the whole suite once the failing tests pass`
+)
+
+// testRunnerHelp describes the path and run parameters for each runner.
+var testRunnerHelp = map[string][2]string{
+	config.TestRunnerGo:     {"package or directory, such as ./internal/foo", "a -run regular expression of go test, such as TestFoo"},
+	config.TestRunnerPytest: {"test file or directory, optionally with ::test_name", "a -k expression of pytest, such as test_login"},
+	config.TestRunnerJest:   {"test file or a pattern of test files", "a -t pattern of jest, such as the name of a describe block"},
+}
+
+func NewTestTool(permissions permission.Service, runner string, args []string, workingDir string) BaseTool {
+	return &testTool{
+		runner:      runner,
+		args:        args,
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *testTool) Name() string {
+	return TestToolName
+}
+
+func (t *testTool) Info() ToolInfo {
+	help := testRunnerHelp[t.runner]
+	runner := t.runner
+	if runner == config.TestRunnerGo {
+		runner = "go test"
+	}
+	return ToolInfo{
+		Name:        TestToolName,
+		Description: fmt.Sprintf(testToolDescription, runner, help[0], help[1]),
+		Parameters: map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "The " + help[0] + ", all the tests without it",
+			},
+			"run": map[string]any{
+				"type":        "string",
+				"description": "Only run the tests matching " + help[1],
+			},
+			"timeout": map[string]any{
+				"type":        "number",
+				"description": "Optional timeout in milliseconds, max 600000",
+			},
+		},
+		Required: []string{},
+	}
+}
+
+func (t *testTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params TestParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse test parameters: " + err.Error()), nil
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for running tests")
+	}
+	description := "Run the tests of the project"
+	if params.Path != "" {
+		description = "Run the tests of " + params.Path
+	}
+	if params.Run != "" {
+		description += " matching " + params.Run
+	}
+	p := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        t.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    TestToolName,
+			Action:      "run",
+			Description: description,
+			Params: TestPermissionsParams{
+				Runner: t.runner,
+				Path:   params.Path,
+				Run:    params.Run,
+			},
+		},
+	)
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	timeout := params.Timeout
+	if timeout > MaxTimeout {
+		timeout = MaxTimeout
+	} else if timeout <= 0 {
+		timeout = testDefaultTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+
+	result, err := testrunner.Run(runCtx, t.workingDir, testrunner.Options{
+		Runner: t.runner,
+		Args:   t.args,
+		Path:   params.Path,
+		Run:    params.Run,
+	}, &outputProgressWriter{ctx: ctx})
+	var exitErr *testrunner.ExitError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		return NewTextErrorResponse(fmt.Sprintf("The tests were aborted after %s", time.Duration(timeout)*time.Millisecond)), nil
+	case errors.As(err, &exitErr):
+		return NewTextErrorResponse(fmt.Sprintf("%s\n\nExit code %d", truncateOutput(strings.TrimSpace(exitErr.Output)), exitErr.ExitCode)), nil
+	case err != nil:
+		if ctx.Err() != nil {
+			return ToolResponse{}, ctx.Err()
+		}
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	return NewTextResponse(truncateOutput(formatTestResult(result))), nil
+}
+
+// formatTestResult summarizes the run, and lists the failures with their
+// location and message.
+func formatTestResult(result *testrunner.Result) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: ", result.Command)
+	if len(result.Failures) > 0 {
+		fmt.Fprintf(&sb, "%d failed, ", len(result.Failures))
+	}
+	fmt.Fprintf(&sb, "%d passed", result.Passed)
+	if result.Skipped > 0 {
+		fmt.Fprintf(&sb, ", %d skipped", result.Skipped)
+	}
+	if len(result.Failures) == 0 && result.Passed == 0 {
+		sb.WriteString("\n\nNo tests ran")
+	}
+
+	for _, f := range result.Failures {
+		sb.WriteString("\n\nFAIL")
+		if f.Test != "" {
+			fmt.Fprintf(&sb, " %s", f.Test)
+		}
+		switch {
+		case f.File != "" && f.Line > 0:
+			fmt.Fprintf(&sb, " at %s:%d", f.File, f.Line)
+		case f.File != "":
+			fmt.Fprintf(&sb, " in %s", f.File)
+		}
+		for line := range strings.SplitSeq(f.Message, "\n") {
+			sb.WriteString("\n  " + line)
+		}
+	}
+	return sb.String()
+}
@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"encoding/json"
+
+	"github.com/charmbracelet/crush/internal/message"
 )
 
 type ToolInfo struct {
@@ -28,10 +30,11 @@ const (
 )
 
 type ToolResponse struct {
-	Type     toolResponseType `json:"type"`
-	Content  string           `json:"content"`
-	Metadata string           `json:"metadata,omitempty"`
-	IsError  bool             `json:"is_error"`
+	Type      toolResponseType   `json:"type"`
+	Content   string             `json:"content"`
+	Metadata  string             `json:"metadata,omitempty"`
+	IsError   bool               `json:"is_error"`
+	Citations []message.Citation `json:"citations,omitempty"`
 }
 
 func NewTextResponse(content string) ToolResponse {
@@ -52,6 +55,13 @@ func WithResponseMetadata(response ToolResponse, metadata any) ToolResponse {
 	return response
 }
 
+// WithResponseCitations records where the content of the response came
+// from, so the sources can be cited in the final answer.
+func WithResponseCitations(response ToolResponse, citations ...message.Citation) ToolResponse {
+	response.Citations = append(response.Citations, citations...)
+	return response
+}
+
 func NewTextErrorResponse(content string) ToolResponse {
 	return ToolResponse{
 		Type:    ToolResponseTypeText,
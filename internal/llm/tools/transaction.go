@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+type TransactionFileEdits struct {
+	FilePath string               `json:"file_path"`
+	Edits    []MultiEditOperation `json:"edits"`
+}
+
+type TransactionParams struct {
+	Files []TransactionFileEdits `json:"files"`
+}
+
+type TransactionPermissionsParams struct {
+	Files []ChangedFile `json:"files"`
+}
+
+type transactionTool struct {
+	changeSetWriter
+}
+
+const (
+	TransactionToolName    = "transaction"
+	transactionDescription = `Edits several files as one transaction: either all the edits are applied or none is. Prefer this tool over several Edit and MultiEdit calls when the changes only make sense together, like renaming a function and its callers, so the project is never left half changed. The user reviews all the changes at once, and can undo them together.
+
+Before using this tool:
+- Use the View tool to read every file to change
+
+HOW TO USE:
+- files: the files to change, each listed once, with:
+  - file_path: the absolute path of the file
+  - edits: the edits of the file, like those of the MultiEdit tool, applied in sequence:
+    - old_string: the text to replace, matching the file exactly, including whitespace and indentation
+    - new_string: the text to replace it with
+    - replace_all: replace all the occurrences of old_string, defaults to false
+- To create a file, give an empty old_string and the content of the file as new_string in its first edit
+
+IMPORTANT:
+- All the edits of all the files are checked before any file is written: if one doesn't apply, no file is changed
+- If writing a file fails, the files already written are restored
+- old_string must be unique in the file unless replace_all is set
+
+TIPS:
+- Group the changes of one logical step in a transaction, and make unrelated changes separately
+- If an edit fails, read the file again and retry the whole transaction`
+)
+
+func NewTransactionTool(lspClients map[string]*lsp.Client, permissions permission.Service, files history.Service, workingDir string) BaseTool {
+	return &transactionTool{
+		changeSetWriter: changeSetWriter{
+			lspClients:  lspClients,
+			permissions: permissions,
+			files:       files,
+			workingDir:  workingDir,
+		},
+	}
+}
+
+func (t *transactionTool) Name() string {
+	return TransactionToolName
+}
+
+func (t *transactionTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        TransactionToolName,
+		Description: transactionDescription,
+		Parameters: map[string]any{
+			"files": map[string]any{
+				"type":        "array",
+				"description": "The files to change, each with its edits",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"file_path": map[string]any{
+							"type":        "string",
+							"description": "The absolute path to the file to modify",
+						},
+						"edits": map[string]any{
+							"type":        "array",
+							"description": "The edits of the file, applied in sequence",
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"old_string": map[string]any{
+										"type":        "string",
+										"description": "The text to replace",
+									},
+									"new_string": map[string]any{
+										"type":        "string",
+										"description": "The text to replace it with",
+									},
+									"replace_all": map[string]any{
+										"type":        "boolean",
+										"default":     false,
+										"description": "Replace all occurrences of old_string (default false).",
+									},
+								},
+								"required":             []string{"old_string", "new_string"},
+								"additionalProperties": false,
+							},
+							"minItems": 1,
+						},
+					},
+					"required":             []string{"file_path", "edits"},
+					"additionalProperties": false,
+				},
+				"minItems": 1,
+			},
+		},
+		Required: []string{"files"},
+	}
+}
+
+func (t *transactionTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params TransactionParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("invalid parameters"), nil
+	}
+	if len(params.Files) == 0 {
+		return NewTextErrorResponse("at least one file is required"), nil
+	}
+
+	// Apply every edit in memory first, so nothing is written if any fails.
+	var changes []fileChange
+	seen := map[string]bool{}
+	for _, file := range params.Files {
+		if file.FilePath == "" {
+			return NewTextErrorResponse("file_path is required for every file"), nil
+		}
+		if !filepath.IsAbs(file.FilePath) {
+			file.FilePath = filepath.Join(t.workingDir, file.FilePath)
+		}
+		if seen[file.FilePath] {
+			return NewTextErrorResponse(fmt.Sprintf("%s is listed twice, give all its edits at once", file.FilePath)), nil
+		}
+		seen[file.FilePath] = true
+
+		change, err := t.prepare(file)
+		if err != nil {
+			return NewTextErrorResponse(fmt.Sprintf("%s: %s", file.FilePath, err)), nil
+		}
+		changes = append(changes, change)
+	}
+
+	return t.write(ctx, call, fmt.Sprintf("Edit %d files in one transaction", len(changes)), fmt.Sprintf("Edited %d files", len(changes)), changes)
+}
+
+// prepare applies the edits of a file to its content.
+func (t *transactionTool) prepare(file TransactionFileEdits) (fileChange, error) {
+	if len(file.Edits) == 0 {
+		return fileChange{}, errors.New("at least one edit is required")
+	}
+	if err := validateEdits(file.Edits); err != nil {
+		return fileChange{}, err
+	}
+
+	change := fileChange{ChangedFile: ChangedFile{FilePath: file.FilePath}}
+	edits := file.Edits
+	if edits[0].OldString == "" {
+		if _, err := os.Stat(file.FilePath); err == nil {
+			return change, errors.New("the file already exists, edit it instead of creating it")
+		} else if !os.IsNotExist(err) {
+			return change, fmt.Errorf("failed to access file: %w", err)
+		}
+		change.NewContent, change.Created = edits[0].NewString, true
+		edits = edits[1:]
+	} else {
+		content, err := readFileToChange(file.FilePath)
+		if err != nil {
+			return change, err
+		}
+		change.OldContent, change.NewContent, change.existed = content, content, true
+	}
+
+	for i, edit := range edits {
+		newContent, err := applyEditToContent(change.NewContent, edit)
+		if err != nil {
+			return change, fmt.Errorf("edit %d failed: %s", len(file.Edits)-len(edits)+i+1, err)
+		}
+		change.NewContent = newContent
+	}
+	if change.existed && change.NewContent == change.OldContent {
+		return change, errors.New("the edits don't change the file")
+	}
+
+	_, change.Additions, change.Removals = diff.GenerateDiff(change.OldContent, change.NewContent, strings.TrimPrefix(change.FilePath, t.workingDir))
+	return change, nil
+}
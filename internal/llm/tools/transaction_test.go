@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func runTransaction(t *testing.T, tool BaseTool, ctx context.Context, files ...TransactionFileEdits) ToolResponse {
+	t.Helper()
+	input, err := json.Marshal(TransactionParams{Files: files})
+	require.NoError(t, err)
+	resp, err := tool.Run(ctx, ToolCall{ID: "call", Name: TransactionToolName, Input: string(input)})
+	require.NoError(t, err)
+	return resp
+}
+
+func TestTransaction(t *testing.T) {
+	ctx, dir, permissions, files := newFileToolTest(t, map[string]string{
+		"a.go": "func Old() {}\n",
+		"b.go": "func main() {\n\tOld()\n\tOld()\n}\n",
+	})
+	tool := NewTransactionTool(nil, permissions, files, dir)
+
+	resp := runTransaction(t, tool, ctx,
+		TransactionFileEdits{FilePath: filepath.Join(dir, "a.go"), Edits: []MultiEditOperation{{OldString: "Old", NewString: "New"}}},
+		TransactionFileEdits{FilePath: "b.go", Edits: []MultiEditOperation{{OldString: "Old", NewString: "New", ReplaceAll: true}}},
+		TransactionFileEdits{FilePath: "c.go", Edits: []MultiEditOperation{{NewString: "package c\n"}}},
+	)
+	require.False(t, resp.IsError, resp.Content)
+	require.Contains(t, resp.Content, "Edited 3 files")
+	require.Equal(t, "func New() {}\n", readFile(t, filepath.Join(dir, "a.go")))
+	require.Equal(t, "func main() {\n\tNew()\n\tNew()\n}\n", readFile(t, filepath.Join(dir, "b.go")))
+	require.Equal(t, "package c\n", readFile(t, filepath.Join(dir, "c.go")))
+
+	var meta ChangeSetResponseMetadata
+	require.NoError(t, json.Unmarshal([]byte(resp.Metadata), &meta))
+	require.Len(t, meta.Files, 3)
+	require.True(t, meta.Files[2].Created)
+
+	sessionID, _ := GetContextValues(ctx)
+	changeSet, err := UndoChangeSet(ctx, files, sessionID)
+	require.NoError(t, err)
+	require.Len(t, changeSet.Files, 3)
+	require.Equal(t, "func Old() {}\n", readFile(t, filepath.Join(dir, "a.go")))
+	require.Equal(t, "func main() {\n\tOld()\n\tOld()\n}\n", readFile(t, filepath.Join(dir, "b.go")))
+	require.NoFileExists(t, filepath.Join(dir, "c.go"))
+
+	_, err = UndoChangeSet(ctx, files, sessionID)
+	require.ErrorIs(t, err, history.ErrNoChangeSet)
+}
+
+func TestTransactionIsAtomic(t *testing.T) {
+	ctx, dir, permissions, files := newFileToolTest(t, map[string]string{
+		"a.txt": "one\n",
+		"b.txt": "two\n",
+	})
+	tool := NewTransactionTool(nil, permissions, files, dir)
+
+	resp := runTransaction(t, tool, ctx,
+		TransactionFileEdits{FilePath: "a.txt", Edits: []MultiEditOperation{{OldString: "one", NewString: "1"}}},
+		TransactionFileEdits{FilePath: "b.txt", Edits: []MultiEditOperation{{OldString: "three", NewString: "3"}}},
+	)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "b.txt: edit 1 failed: old_string not found")
+	require.Equal(t, "one\n", readFile(t, filepath.Join(dir, "a.txt")))
+
+	resp = runTransaction(t, tool, ctx,
+		TransactionFileEdits{FilePath: "a.txt", Edits: []MultiEditOperation{{OldString: "one", NewString: "1"}}},
+		TransactionFileEdits{FilePath: "a.txt", Edits: []MultiEditOperation{{OldString: "1", NewString: "2"}}},
+	)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "listed twice")
+}
+
+func TestUndoChangeSet(t *testing.T) {
+	ctx, dir, permissions, files := newFileToolTest(t, map[string]string{
+		"a.txt":  "one\n",
+		"mv.txt": "moved\n",
+	})
+	sessionID, _ := GetContextValues(ctx)
+
+	t.Run("restores renamed files", func(t *testing.T) {
+		resp := runApplyPatch(t, NewApplyPatchTool(nil, permissions, files, dir), ctx, "diff --git a/mv.txt b/renamed.txt\nrename from mv.txt\nrename to renamed.txt\n")
+		require.False(t, resp.IsError, resp.Content)
+
+		_, err := UndoChangeSet(ctx, files, sessionID)
+		require.NoError(t, err)
+		require.Equal(t, "moved\n", readFile(t, filepath.Join(dir, "mv.txt")))
+		require.NoFileExists(t, filepath.Join(dir, "renamed.txt"))
+	})
+
+	t.Run("keeps files changed since", func(t *testing.T) {
+		tool := NewTransactionTool(nil, permissions, files, dir)
+		resp := runTransaction(t, tool, ctx,
+			TransactionFileEdits{FilePath: "a.txt", Edits: []MultiEditOperation{{OldString: "one", NewString: "1"}}},
+			TransactionFileEdits{FilePath: "b.txt", Edits: []MultiEditOperation{{NewString: "two\n"}}},
+		)
+		require.False(t, resp.IsError, resp.Content)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("2\n"), 0o644))
+
+		_, err := UndoChangeSet(ctx, files, sessionID)
+		require.ErrorContains(t, err, "b.txt was changed after the change set")
+		require.Equal(t, "1\n", readFile(t, filepath.Join(dir, "a.txt")))
+		require.Equal(t, "2\n", readFile(t, filepath.Join(dir, "b.txt")))
+	})
+}
+
+func TestWorkspaceEditChanges(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	require.NoError(t, os.WriteFile(a, []byte("func Old() {}\n"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("func main() {\n\tOld()\n}\n"), 0o644))
+	rename := func(line, character uint32) protocol.TextEdit {
+		return protocol.TextEdit{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: line, Character: character},
+				End:   protocol.Position{Line: line, Character: character + 3},
+			},
+			NewText: "New",
+		}
+	}
+
+	changes, err := workspaceEditChanges(protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentURI][]protocol.TextEdit{
+			protocol.URIFromPath(b): {rename(1, 1)},
+			protocol.URIFromPath(a): {rename(0, 5)},
+		},
+	}, dir)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, a, changes[0].FilePath)
+	require.Equal(t, "func New() {}\n", changes[0].NewContent)
+	require.Equal(t, "func main() {\n\tNew()\n}\n", changes[1].NewContent)
+	require.Equal(t, 1, changes[1].Additions)
+	require.Equal(t, 1, changes[1].Removals)
+
+	// Nothing is written before the changes are reviewed.
+	content, err := os.ReadFile(a)
+	require.NoError(t, err)
+	require.Equal(t, "func Old() {}\n", string(content))
+
+	_, err = workspaceEditChanges(protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.DocumentChange{{DeleteFile: &protocol.DeleteFile{URI: protocol.URIFromPath(a)}}},
+	}, dir)
+	require.ErrorContains(t, err, "isn't supported")
+}
@@ -8,23 +8,28 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/syntax"
 )
 
 type ViewParams struct {
 	FilePath string `json:"file_path"`
 	Offset   int    `json:"offset"`
 	Limit    int    `json:"limit"`
+	Symbol   string `json:"symbol,omitempty"`
 }
 
 type ViewPermissionsParams struct {
 	FilePath string `json:"file_path"`
 	Offset   int    `json:"offset"`
 	Limit    int    `json:"limit"`
+	Symbol   string `json:"symbol,omitempty"`
 }
 
 type viewTool struct {
@@ -36,6 +41,8 @@ type viewTool struct {
 type ViewResponseMetadata struct {
 	FilePath string `json:"file_path"`
 	Content  string `json:"content"`
+	// Offset is the line before the content, for views of symbols.
+	Offset int `json:"offset,omitempty"`
 }
 
 const (
@@ -54,11 +61,13 @@ HOW TO USE:
 - Provide the path to the file you want to view
 - Optionally specify an offset to start reading from a specific line
 - Optionally specify a limit to control how many lines are read
+- Optionally specify a symbol, such as a function, type, or Type.Method, to read only its code and the signatures of the declarations of the file it uses
 - Do not use this for directories use the ls tool instead
 
 FEATURES:
 - Displays file contents with line numbers for easy reference
 - Can read from any position in a file using the offset parameter
+- Can read a single declaration of a Go file using the symbol parameter, much shorter than the whole file
 - Handles large files by limiting the number of lines read
 - Automatically truncates very long lines for better display
 - Suggests similar file names when the requested file isn't found
@@ -78,7 +87,8 @@ WINDOWS NOTES:
 TIPS:
 - Use with Glob tool to first find files you want to view
 - For code exploration, first use Grep to find relevant files, then View to examine them
-- When viewing large files, use the offset parameter to read specific sections`
+- When viewing large files, use the offset parameter to read specific sections
+- To read a function of a large Go file, give its name as the symbol rather than reading the whole file`
 )
 
 func NewViewTool(lspClients map[string]*lsp.Client, permissions permission.Service, workingDir string) BaseTool {
@@ -110,6 +120,10 @@ func (v *viewTool) Info() ToolInfo {
 				"type":        "integer",
 				"description": "The number of lines to read (defaults to 2000)",
 			},
+			"symbol": map[string]any{
+				"type":        "string",
+				"description": "The name of a declaration to read instead of lines, e.g. a function or Type.Method (Go files only)",
+			},
 		},
 		Required: []string{"file_path"},
 	}
@@ -223,6 +237,10 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 		return NewTextErrorResponse(fmt.Sprintf("This is an image file of type: %s\n", imageType)), nil
 	}
 
+	if params.Symbol != "" {
+		return v.viewSymbol(ctx, filePath, params.Symbol)
+	}
+
 	// Read the file content
 	content, lineCount, err := readTextFile(filePath, params.Offset, params.Limit)
 	isValidUt8 := utf8.ValidString(content)
@@ -246,13 +264,80 @@ func (v *viewTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error)
 	output += "\n</file>\n"
 	output += getDiagnostics(filePath, v.lspClients)
 	recordFileRead(filePath)
-	return WithResponseMetadata(
+	response := WithResponseMetadata(
+		NewTextResponse(output),
+		ViewResponseMetadata{
+			FilePath: filePath,
+			Content:  content,
+		},
+	)
+	return WithResponseCitations(response, message.Citation{
+		Source:   filePath,
+		Location: fmt.Sprintf("lines %d-%d", params.Offset+1, params.Offset+len(strings.Split(content, "\n"))),
+	}), nil
+}
+
+// viewSymbol returns the code of the declaration of the symbol and the
+// signatures of the declarations of the file it uses.
+func (v *viewTool) viewSymbol(ctx context.Context, filePath, symbol string) (ToolResponse, error) {
+	if !syntax.Supported(filePath) {
+		return NewTextErrorResponse(fmt.Sprintf("Viewing symbols is not supported for %s files, use offset and limit instead", filepath.Ext(filePath))), nil
+	}
+	src, err := os.ReadFile(filePath)
+	if err != nil {
+		return ToolResponse{}, fmt.Errorf("error reading file: %w", err)
+	}
+	if !utf8.Valid(src) {
+		return NewTextErrorResponse("File content is not valid UTF-8"), nil
+	}
+	declarations, err := syntax.Parse(filePath, src)
+	if err != nil {
+		return NewTextErrorResponse(fmt.Sprintf("Failed to parse the file: %s", err)), nil
+	}
+	excerpt, err := syntax.Extract(declarations, []string{symbol})
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+
+	lines := strings.Split(string(src), "\n")
+	var bodies, numbered []string
+	for _, declaration := range excerpt.Declarations {
+		body := slices.Clone(lines[declaration.StartLine-1 : declaration.EndLine])
+		for i, line := range body {
+			if len(line) > MaxLineLength {
+				body[i] = line[:MaxLineLength] + "..."
+			}
+		}
+		bodies = append(bodies, strings.Join(body, "\n"))
+		numbered = append(numbered, addLineNumbers(bodies[len(bodies)-1], declaration.StartLine))
+	}
+	content := strings.Join(bodies, "\n\n")
+
+	notifyLspOpenFile(ctx, filePath, v.lspClients)
+	output := "<file>\n" + strings.Join(numbered, "\n\n") + "\n</file>\n"
+	if len(excerpt.Dependencies) > 0 {
+		signatures := make([]string, len(excerpt.Dependencies))
+		for i, dependency := range excerpt.Dependencies {
+			signatures[i] = addLineNumbers(dependency.Signature, dependency.StartLine)
+		}
+		output += "<dependencies>\n" + strings.Join(signatures, "\n") + "\n</dependencies>\n"
+	}
+	output += getDiagnostics(filePath, v.lspClients)
+	recordFileRead(filePath)
+
+	first, last := excerpt.Declarations[0], excerpt.Declarations[len(excerpt.Declarations)-1]
+	response := WithResponseMetadata(
 		NewTextResponse(output),
 		ViewResponseMetadata{
 			FilePath: filePath,
 			Content:  content,
+			Offset:   first.StartLine - 1,
 		},
-	), nil
+	)
+	return WithResponseCitations(response, message.Citation{
+		Source:   filePath,
+		Location: fmt.Sprintf("lines %d-%d", first.StartLine, last.EndLine),
+	}), nil
 }
 
 func addLineNumbers(content string, startLine int) string {
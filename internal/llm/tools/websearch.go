@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/websearch"
+)
+
+type WebSearchParams struct {
+	Query string `json:"query"`
+	Count int    `json:"count,omitempty"`
+}
+
+type WebSearchPermissionsParams struct {
+	Query    string `json:"query"`
+	Count    int    `json:"count,omitempty"`
+	Provider string `json:"provider"`
+}
+
+type webSearchTool struct {
+	provider    websearch.Provider
+	maxResults  int
+	permissions permission.Service
+	workingDir  string
+}
+
+const (
+	WebSearchToolName        = "websearch"
+	webSearchToolDescription = `Searches the web and returns the title, URL, and a snippet of the pages found.
+
+WHEN TO USE THIS TOOL:
+- Use when you need current information that may have changed since your training
+- Helpful for finding the documentation of a library, its latest version, or the exact signature of an API
+- Useful for looking up error messages, changelogs, and release notes
+
+HOW TO USE:
+- Provide a focused query, such as the library name followed by what you are looking for
+- Optionally set how many results to return
+- Fetch the most relevant results with the fetch tool to read them in full
+
+LIMITATIONS:
+- Snippets are short and may be out of context; read the page before relying on it
+- Results depend on the configured search engine
+
+TIPS:
+- Prefer official documentation and source repositories over blog posts
+- Include a version number in the query when the API changed between versions
+- Check APIs you are unsure about instead of guessing them`
+)
+
+func NewWebSearchTool(permissions permission.Service, provider websearch.Provider, maxResults int, workingDir string) BaseTool {
+	if maxResults <= 0 {
+		maxResults = websearch.DefaultMaxResults
+	}
+	return &webSearchTool{
+		provider:    provider,
+		maxResults:  maxResults,
+		permissions: permissions,
+		workingDir:  workingDir,
+	}
+}
+
+func (t *webSearchTool) Name() string {
+	return WebSearchToolName
+}
+
+func (t *webSearchTool) Info() ToolInfo {
+	return ToolInfo{
+		Name:        WebSearchToolName,
+		Description: webSearchToolDescription,
+		Parameters: map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The search query",
+			},
+			"count": map[string]any{
+				"type":        "number",
+				"description": fmt.Sprintf("Optional number of results to return (max %d)", t.maxResults),
+			},
+		},
+		Required: []string{"query"},
+	}
+}
+
+func (t *webSearchTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error) {
+	var params WebSearchParams
+	if err := json.Unmarshal([]byte(call.Input), &params); err != nil {
+		return NewTextErrorResponse("Failed to parse websearch parameters: " + err.Error()), nil
+	}
+
+	params.Query = strings.TrimSpace(params.Query)
+	if params.Query == "" {
+		return NewTextErrorResponse("Query parameter is required"), nil
+	}
+	if params.Count <= 0 || params.Count > t.maxResults {
+		params.Count = t.maxResults
+	}
+
+	sessionID, messageID := GetContextValues(ctx)
+	if sessionID == "" || messageID == "" {
+		return ToolResponse{}, fmt.Errorf("session ID and message ID are required for searching the web")
+	}
+
+	p := t.permissions.Request(
+		permission.CreatePermissionRequest{
+			SessionID:   sessionID,
+			Path:        t.workingDir,
+			ToolCallID:  call.ID,
+			ToolName:    WebSearchToolName,
+			Action:      "search",
+			Description: fmt.Sprintf("Search the web with %s for: %s", t.provider.Name(), params.Query),
+			Params: WebSearchPermissionsParams{
+				Query:    params.Query,
+				Count:    params.Count,
+				Provider: t.provider.Name(),
+			},
+		},
+	)
+
+	if !p {
+		return ToolResponse{}, permission.ErrorPermissionDenied
+	}
+
+	results, err := t.provider.Search(ctx, params.Query, params.Count)
+	if err != nil {
+		return NewTextErrorResponse(err.Error()), nil
+	}
+	if len(results) == 0 {
+		return NewTextResponse("No results found"), nil
+	}
+
+	var sb strings.Builder
+	citations := make([]message.Citation, 0, len(results))
+	for i, r := range results {
+		fmt.Fprintf(&sb, "%d. [%s](%s)\n", i+1, r.Title, r.URL)
+		if snippet := strings.Join(strings.Fields(r.Snippet), " "); snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", snippet)
+		}
+		citations = append(citations, message.Citation{Source: r.URL, Title: r.Title})
+	}
+
+	return WithResponseCitations(NewTextResponse(strings.TrimSuffix(sb.String(), "\n")), citations...), nil
+}
@@ -167,7 +167,7 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 		return ToolResponse{}, fmt.Errorf("session_id and message_id are required")
 	}
 
-	diff, additions, removals := diff.GenerateDiff(
+	unified, additions, removals := diff.GenerateDiff(
 		oldContent,
 		params.Content,
 		strings.TrimPrefix(filePath, w.workingDir),
@@ -191,6 +191,11 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	if !p {
 		return ToolResponse{}, permission.ErrorPermissionDenied
 	}
+	content, partial := reviewedContent(w.permissions, call.ID, params.Content)
+	if partial {
+		params.Content = content
+		unified, additions, removals = diff.GenerateDiff(oldContent, params.Content, strings.TrimPrefix(filePath, w.workingDir))
+	}
 
 	err = os.WriteFile(filePath, []byte(params.Content), 0o644)
 	if err != nil {
@@ -223,12 +228,13 @@ func (w *writeTool) Run(ctx context.Context, call ToolCall) (ToolResponse, error
 	recordFileRead(filePath)
 	waitForLspDiagnostics(ctx, filePath, w.lspClients)
 
-	result := fmt.Sprintf("File successfully written: %s", filePath)
+	result := fmt.Sprintf("File successfully written: %s", filePath) + rejectedNote(partial)
 	result = fmt.Sprintf("<result>\n%s\n</result>", result)
 	result += getDiagnostics(filePath, w.lspClients)
+	result += checkFileGlossary(w.workingDir, filePath)
 	return WithResponseMetadata(NewTextResponse(result),
 		WriteResponseMetadata{
-			Diff:      diff,
+			Diff:      unified,
 			Additions: additions,
 			Removals:  removals,
 		},
@@ -1,10 +1,14 @@
 package log
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"runtime"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -17,31 +21,145 @@ var (
 	initialized atomic.Bool
 )
 
-func Setup(logFile string, debug bool) {
+// Subsystems of the logs, by the packages logging.
+const (
+	Provider = "provider"
+	Tools    = "tools"
+	LSP      = "lsp"
+	TUI      = "tui"
+)
+
+// Subsystems are the subsystems the levels can be set of.
+var Subsystems = []string{Provider, Tools, LSP, TUI}
+
+// subsystemDirs are the directories of the packages of the subsystems.
+var subsystemDirs = []struct{ dir, subsystem string }{
+	{"/internal/llm/provider/", Provider},
+	{"/internal/llm/tools/", Tools},
+	{"/internal/lsp/", LSP},
+	{"/internal/tui/", TUI},
+}
+
+// Options configures the logs.
+type Options struct {
+	// Level is the level of the logs of no subsystem and of the subsystems
+	// missing from Levels.
+	Level slog.Level
+	// Levels are the levels of the subsystems.
+	Levels map[string]slog.Level
+	// Text writes the logs as key=value pairs instead of JSON.
+	Text bool
+	// MaxSize is the size of the log file in megabytes it's rotated at.
+	MaxSize int
+	// MaxBackups is the number of rotated files kept, all of them when 0.
+	MaxBackups int
+	// MaxAge is the number of days the rotated files are kept.
+	MaxAge int
+}
+
+func Setup(logFile string, opts Options) {
 	initOnce.Do(func() {
 		logRotator := &lumberjack.Logger{
 			Filename:   logFile,
-			MaxSize:    10,    // Max size in MB
-			MaxBackups: 0,     // Number of backups
-			MaxAge:     30,    // Days
-			Compress:   false, // Enable compression
-		}
-
-		level := slog.LevelInfo
-		if debug {
-			level = slog.LevelDebug
+			MaxSize:    cmp.Or(opts.MaxSize, 10), // Max size in MB
+			MaxBackups: opts.MaxBackups,          // Number of backups
+			MaxAge:     cmp.Or(opts.MaxAge, 30),  // Days
+			Compress:   false,                    // Enable compression
 		}
 
-		logger := slog.NewJSONHandler(logRotator, &slog.HandlerOptions{
-			Level:     level,
+		handlerOpts := &slog.HandlerOptions{
+			Level:     slog.LevelDebug,
 			AddSource: true,
-		})
+		}
+		var inner slog.Handler = slog.NewJSONHandler(logRotator, handlerOpts)
+		if opts.Text {
+			inner = slog.NewTextHandler(logRotator, handlerOpts)
+		}
 
-		slog.SetDefault(slog.New(logger))
+		slog.SetDefault(slog.New(newHandler(inner, opts.Level, opts.Levels)))
 		initialized.Store(true)
 	})
 }
 
+// handler filters the records by the levels of their subsystems, and tags
+// them with their subsystems.
+type handler struct {
+	slog.Handler
+	level  slog.Level
+	levels map[string]slog.Level
+	// min is the lowest of the levels, the records below it are dropped
+	// before they're made.
+	min slog.Level
+}
+
+func newHandler(inner slog.Handler, level slog.Level, levels map[string]slog.Level) *handler {
+	h := &handler{Handler: inner, level: level, levels: levels, min: level}
+	for _, l := range levels {
+		h.min = min(h.min, l)
+	}
+	return h
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.min
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	subsystem := subsystemOf(r.PC)
+	level := h.level
+	if l, ok := h.levels[subsystem]; ok {
+		level = l
+	}
+	if r.Level < level {
+		return nil
+	}
+	if subsystem != "" {
+		r.AddAttrs(slog.String("subsystem", subsystem))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.Handler = h.Handler.WithAttrs(attrs)
+	return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.Handler = h.Handler.WithGroup(name)
+	return &c
+}
+
+// subsystems caches the subsystems of the program counters of the records.
+var subsystems sync.Map
+
+// subsystemOf returns the subsystem of the package logging at the program
+// counter, "" when it's of no subsystem.
+func subsystemOf(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	if subsystem, ok := subsystems.Load(pc); ok {
+		return subsystem.(string)
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	subsystem := subsystemOfFile(frame.File)
+	subsystems.Store(pc, subsystem)
+	return subsystem
+}
+
+// subsystemOfFile returns the subsystem of the package of the source file,
+// whose path has forward slashes on all the systems.
+func subsystemOfFile(file string) string {
+	for _, s := range subsystemDirs {
+		if strings.Contains(file, s.dir) {
+			return s.subsystem
+		}
+	}
+	return ""
+}
+
 func Initialized() bool {
 	return initialized.Load()
 }
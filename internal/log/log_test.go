@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubsystemOfFile(t *testing.T) {
+	t.Parallel()
+
+	for file, subsystem := range map[string]string{
+		"/src/crush/internal/llm/provider/openai.go":                   Provider,
+		"github.com/charmbracelet/crush/internal/llm/tools/bash.go":    Tools,
+		"/src/crush/internal/lsp/client.go":                            LSP,
+		"/src/crush/internal/tui/components/chat/chat.go":              TUI,
+		"/src/crush/internal/llm/agent/agent.go":                       "",
+		"/src/crush/internal/app/app.go":                               "",
+		"/home/user/go/pkg/mod/github.com/other/internal/logs/logs.go": "",
+	} {
+		require.Equal(t, subsystem, subsystemOfFile(file), file)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	// Program counters standing for calls of the provider and the lsp
+	// subsystems.
+	var pcs [3]uintptr
+	runtime.Callers(0, pcs[:])
+	providerPC, lspPC := pcs[0], pcs[1]
+	subsystems.Store(providerPC, Provider)
+	subsystems.Store(lspPC, LSP)
+
+	var buf bytes.Buffer
+	inner := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	h := newHandler(inner, slog.LevelWarn, map[string]slog.Level{Provider: slog.LevelDebug})
+	require.True(t, h.Enabled(t.Context(), slog.LevelDebug), "provider logs at debug")
+
+	logs := func(pc uintptr, level slog.Level) bool {
+		buf.Reset()
+		require.NoError(t, h.Handle(context.Background(), slog.NewRecord(time.Now(), level, "msg", pc)))
+		return buf.Len() > 0
+	}
+	require.True(t, logs(providerPC, slog.LevelDebug))
+	require.Contains(t, buf.String(), "subsystem=provider")
+	require.False(t, logs(lspPC, slog.LevelInfo))
+	require.True(t, logs(lspPC, slog.LevelError))
+	require.Contains(t, buf.String(), "subsystem=lsp")
+	require.False(t, logs(0, slog.LevelInfo))
+	require.True(t, logs(0, slog.LevelWarn))
+	require.NotContains(t, buf.String(), "subsystem")
+
+	withAttrs := h.WithAttrs([]slog.Attr{slog.String("session", "s1")})
+	require.False(t, withAttrs.Enabled(t.Context(), slog.LevelDebug-1))
+	buf.Reset()
+	require.NoError(t, withAttrs.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelDebug, "msg", providerPC)))
+	require.Contains(t, buf.String(), "session=s1")
+}
@@ -127,7 +127,9 @@ func (c *Client) RegisterServerRequestHandler(method string, handler ServerReque
 	c.serverRequestHandlers[method] = handler
 }
 
-func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (*protocol.InitializeResult, error) {
+// InitializeLSPClient initializes the server for the workspace. The
+// initialization options replace the default ones when given.
+func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string, initOptions map[string]any) (*protocol.InitializeResult, error) {
 	initParams := &protocol.InitializeParams{
 		WorkspaceFoldersInitializeParams: protocol.WorkspaceFoldersInitializeParams{
 			WorkspaceFolders: []protocol.WorkspaceFolder{
@@ -175,7 +177,14 @@ func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (
 								ValueSet: []protocol.CodeActionKind{},
 							},
 						},
+						IsPreferredSupport: true,
+						DisabledSupport:    true,
+						DataSupport:        true,
+						ResolveSupport: &protocol.ClientCodeActionResolveOptions{
+							Properties: []string{"edit"},
+						},
 					},
+					Rename: &protocol.RenameClientCapabilities{},
 					PublishDiagnostics: protocol.PublishDiagnosticsClientCapabilities{
 						VersionSupport: true,
 					},
@@ -205,6 +214,10 @@ func (c *Client) InitializeLSPClient(ctx context.Context, workspaceDir string) (
 		},
 	}
 
+	if initOptions != nil {
+		initParams.InitializationOptions = initOptions
+	}
+
 	var result protocol.InitializeResult
 	if err := c.Call(ctx, "initialize", initParams, &result); err != nil {
 		return nil, fmt.Errorf("initialize failed: %w", err)
@@ -0,0 +1,160 @@
+// Package install finds and installs the known language servers of the
+// languages Crush detects, keeping the installed ones in the global data
+// directory so all the projects share them.
+package install
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// ErrNotInstalled is returned by Command when the server isn't installed and
+// can't be installed automatically.
+var ErrNotInstalled = errors.New("language server not installed")
+
+const rustAnalyzerURL = "https://github.com/rust-lang/rust-analyzer/releases/latest/download/rust-analyzer-%s-%s.gz"
+
+// Dir is the directory the language servers are installed in.
+func Dir() string {
+	return filepath.Join(config.GlobalDataDir(), "lsp")
+}
+
+// Path returns the path of the known server of the language once installed
+// in the directory.
+func Path(dir string, known config.KnownLSP) string {
+	switch known.Language {
+	case "typescript", "python":
+		name := known.Command
+		if runtime.GOOS == "windows" {
+			name += ".cmd"
+		}
+		return filepath.Join(dir, known.Language, "node_modules", ".bin", name)
+	default:
+		name := known.Command
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		return filepath.Join(dir, "bin", name)
+	}
+}
+
+// Command returns the command starting the configured server. The known
+// server of a language is looked up in the PATH and then among the installed
+// ones, and installed when it's missing if install is set.
+func Command(ctx context.Context, name string, lsp config.LSPConfig, install bool) (string, error) {
+	if path, err := exec.LookPath(lsp.Command); err == nil {
+		return path, nil
+	}
+	known, ok := config.LookupKnownLSP(name)
+	if !ok || lsp.Command != known.Command {
+		return "", fmt.Errorf("%s not found", lsp.Command)
+	}
+	path := Path(Dir(), known)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if !install {
+		return "", fmt.Errorf("%s: %w, install it with crush lsp install %s", known.Command, ErrNotInstalled, known.Language)
+	}
+	return Install(ctx, Dir(), known)
+}
+
+// Install installs the known server in the directory, returning its path.
+func Install(ctx context.Context, dir string, known config.KnownLSP) (string, error) {
+	slog.Info("Installing language server", "language", known.Language, "command", known.Command)
+	path := Path(dir, known)
+	var err error
+	switch known.Language {
+	case "go":
+		err = run(ctx, []string{"GOBIN=" + filepath.Dir(path)}, "go", "install", "golang.org/x/tools/gopls@latest")
+	case "rust":
+		err = downloadRustAnalyzer(ctx, path)
+	case "typescript":
+		err = run(ctx, nil, "npm", "install", "--prefix", filepath.Join(dir, known.Language), "typescript", "typescript-language-server")
+	case "python":
+		err = run(ctx, nil, "npm", "install", "--prefix", filepath.Join(dir, known.Language), "pyright")
+	default:
+		return "", fmt.Errorf("don't know how to install the %s language server", known.Language)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to install %s: %w", known.Command, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%s was installed but is missing: %w", known.Command, err)
+	}
+	slog.Info("Installed language server", "language", known.Language, "path", path)
+	return path, nil
+}
+
+// run runs the command of the package manager installing a server.
+func run(ctx context.Context, env []string, name string, args ...string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return fmt.Errorf("%s is needed to install it: %w", name, err)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w\n%s", name, args[0], err, output)
+	}
+	return nil
+}
+
+// downloadRustAnalyzer downloads the latest release of rust-analyzer for the
+// platform.
+func downloadRustAnalyzer(ctx context.Context, path string) error {
+	arch := map[string]string{"amd64": "x86_64", "arm64": "aarch64"}[runtime.GOARCH]
+	target := map[string]string{"linux": "unknown-linux-gnu", "darwin": "apple-darwin"}[runtime.GOOS]
+	if arch == "" || target == "" {
+		return fmt.Errorf("no release for %s/%s, install it with rustup component add rust-analyzer", runtime.GOOS, runtime.GOARCH)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(rustAnalyzerURL, arch, target), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Written aside and renamed, so a failed download isn't taken for the
+	// server.
+	tmp := path + ".download"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, gz)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
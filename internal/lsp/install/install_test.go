@@ -0,0 +1,32 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	known, _ := config.LookupKnownLSP("rust")
+	lsp := config.LSPConfig{Command: known.Command}
+
+	_, err := Command(t.Context(), "rust", lsp, false)
+	require.ErrorIs(t, err, ErrNotInstalled)
+	require.ErrorContains(t, err, "crush lsp install rust")
+
+	path := Path(Dir(), known)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+	command, err := Command(t.Context(), "rust", lsp, false)
+	require.NoError(t, err)
+	require.Equal(t, path, command)
+
+	// Other commands aren't installed.
+	_, err = Command(t.Context(), "rust", config.LSPConfig{Command: "ra-multiplex"}, true)
+	require.ErrorContains(t, err, "ra-multiplex not found")
+}
@@ -0,0 +1,188 @@
+package mcpauth
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+const deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Metadata are the endpoints of the authorization server used by the device
+// flow.
+type Metadata struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+// DeviceCode is the code the user enters at the verification URI to let
+// Crush in.
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// Discover finds the authorization server of an MCP server, from the
+// protected resource metadata of the server, or its origin.
+func Discover(ctx context.Context, serverURL string, oauth config.MCPOAuth) (*Metadata, error) {
+	if oauth.MetadataURL != "" {
+		return fetchMetadata(ctx, oauth.MetadataURL)
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid MCP server URL %q", serverURL)
+	}
+	issuer := u.Scheme + "://" + u.Host
+
+	var resource struct {
+		AuthorizationServers []string `json:"authorization_servers"`
+	}
+	if err := getJSON(ctx, issuer+"/.well-known/oauth-protected-resource", &resource); err == nil && len(resource.AuthorizationServers) > 0 {
+		issuer = strings.TrimSuffix(resource.AuthorizationServers[0], "/")
+	}
+	metadata, err := fetchMetadata(ctx, issuer+"/.well-known/oauth-authorization-server")
+	if err != nil {
+		// Fall back to OpenID Connect discovery, reporting the first error
+		// if it fails too.
+		if oidc, oidcErr := fetchMetadata(ctx, issuer+"/.well-known/openid-configuration"); oidcErr == nil {
+			metadata, err = oidc, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover the authorization server of %s: %w", serverURL, err)
+	}
+	return metadata, nil
+}
+
+func fetchMetadata(ctx context.Context, metadataURL string) (*Metadata, error) {
+	var metadata Metadata
+	if err := getJSON(ctx, metadataURL, &metadata); err != nil {
+		return nil, err
+	}
+	if metadata.DeviceAuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, fmt.Errorf("the authorization server at %s doesn't support the device flow", metadataURL)
+	}
+	return &metadata, nil
+}
+
+// Login runs the device flow: it asks for a code, calls prompt for the user
+// to enter it, and waits until they do.
+func Login(ctx context.Context, metadata *Metadata, oauth config.MCPOAuth, prompt func(DeviceCode)) (*transport.Token, error) {
+	form := url.Values{"client_id": {oauth.ClientID}}
+	if len(oauth.Scopes) > 0 {
+		form.Set("scope", strings.Join(oauth.Scopes, " "))
+	}
+	var code DeviceCode
+	if err := postForm(ctx, metadata.DeviceAuthorizationEndpoint, form, &code); err != nil {
+		return nil, fmt.Errorf("failed to request a device code: %w", err)
+	}
+	prompt(code)
+
+	interval := time.Duration(cmp.Or(code.Interval, 5)) * time.Second
+	expires := time.Now().Add(time.Duration(cmp.Or(code.ExpiresIn, 900)) * time.Second)
+	form = url.Values{
+		"grant_type":  {deviceCodeGrant},
+		"device_code": {code.DeviceCode},
+		"client_id":   {oauth.ClientID},
+	}
+	if oauth.ClientSecret != "" {
+		form.Set("client_secret", oauth.ClientSecret)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if time.Now().After(expires) {
+			return nil, errors.New("the device code expired")
+		}
+
+		var token transport.Token
+		err := postForm(ctx, metadata.TokenEndpoint, form, &token)
+		var oauthErr *tokenError
+		switch {
+		case errors.As(err, &oauthErr) && oauthErr.Code == "authorization_pending":
+			continue
+		case errors.As(err, &oauthErr) && oauthErr.Code == "slow_down":
+			interval += 5 * time.Second
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to get a token: %w", err)
+		}
+		if token.ExpiresIn > 0 {
+			token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		}
+		return &token, nil
+	}
+}
+
+// tokenError is an error response of the authorization server.
+type tokenError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *tokenError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+func getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	return doJSON(req, v)
+}
+
+func postForm(ctx context.Context, u string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doJSON(req, v)
+}
+
+func doJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr tokenError
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Code != "" {
+			return &oauthErr
+		}
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", req.URL, err)
+	}
+	return nil
+}
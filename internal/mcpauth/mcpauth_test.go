@@ -0,0 +1,123 @@
+package mcpauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+	_, err := store.For("github").GetToken()
+	require.ErrorIs(t, err, ErrNoToken)
+
+	require.NoError(t, store.For("github").SaveToken(&transport.Token{AccessToken: "a1", RefreshToken: "r1"}))
+	require.NoError(t, store.Save("linear", &transport.Token{AccessToken: "a2"}))
+
+	// Tokens survive restarts, readable by the user only.
+	token, err := NewStore(dir).For("github").GetToken()
+	require.NoError(t, err)
+	require.Equal(t, "r1", token.RefreshToken)
+	info, err := os.Stat(filepath.Join(dir, TokensFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	require.NoError(t, store.Delete("github"))
+	require.ErrorIs(t, store.Delete("github"), ErrNoToken)
+	token, err = store.Get("linear")
+	require.NoError(t, err)
+	require.Equal(t, "a2", token.AccessToken)
+}
+
+// newAuthServer serves an MCP server protected by an authorization server
+// granting a token once polled twice, or denying it.
+func newAuthServer(t *testing.T, deny bool) *httptest.Server {
+	t.Helper()
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("GET /.well-known/oauth-protected-resource", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"authorization_servers": []string{server.URL + "/auth"}})
+	})
+	mux.HandleFunc("GET /auth/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Metadata{
+			DeviceAuthorizationEndpoint: server.URL + "/auth/device",
+			TokenEndpoint:               server.URL + "/auth/token",
+		})
+	})
+	mux.HandleFunc("POST /auth/device", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "crush", r.FormValue("client_id"))
+		require.Equal(t, "read write", r.FormValue("scope"))
+		writeJSON(w, http.StatusOK, DeviceCode{DeviceCode: "dc", UserCode: "ABCD-1234", VerificationURI: server.URL + "/activate", Interval: 1})
+	})
+	mux.HandleFunc("POST /auth/token", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, deviceCodeGrant, r.FormValue("grant_type"))
+		require.Equal(t, "dc", r.FormValue("device_code"))
+		switch {
+		case polls.Add(1) < 2:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+		case deny:
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access_denied", "error_description": "The user said no"})
+		default:
+			writeJSON(w, http.StatusOK, map[string]any{"access_token": "at", "refresh_token": "rt", "token_type": "Bearer", "expires_in": 3600})
+		}
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLogin(t *testing.T) {
+	server := newAuthServer(t, false)
+	oauth := config.MCPOAuth{ClientID: "crush", Scopes: []string{"read", "write"}}
+
+	metadata, err := Discover(t.Context(), server.URL+"/mcp", oauth)
+	require.NoError(t, err)
+	require.Equal(t, server.URL+"/auth/token", metadata.TokenEndpoint)
+
+	var prompted DeviceCode
+	token, err := Login(t.Context(), metadata, oauth, func(code DeviceCode) { prompted = code })
+	require.NoError(t, err)
+	require.Equal(t, "ABCD-1234", prompted.UserCode)
+	require.Equal(t, "at", token.AccessToken)
+	require.Equal(t, "rt", token.RefreshToken)
+	require.False(t, token.ExpiresAt.IsZero())
+}
+
+func TestLogin_Denied(t *testing.T) {
+	server := newAuthServer(t, true)
+	oauth := config.MCPOAuth{ClientID: "crush", Scopes: []string{"read", "write"}, MetadataURL: server.URL + "/auth/.well-known/oauth-authorization-server"}
+
+	metadata, err := Discover(t.Context(), server.URL+"/mcp", oauth)
+	require.NoError(t, err)
+	_, err = Login(t.Context(), metadata, oauth, func(DeviceCode) {})
+	require.ErrorContains(t, err, "access_denied: The user said no")
+}
+
+func TestDiscover_NoDeviceFlow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/oauth-authorization-server" {
+			w.Write([]byte(`{"token_endpoint": "https://example.com/token"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	_, err := Discover(t.Context(), server.URL+"/mcp", config.MCPOAuth{ClientID: "crush"})
+	require.ErrorContains(t, err, "doesn't support the device flow")
+}
@@ -0,0 +1,125 @@
+// Package mcpauth authenticates Crush to remote MCP servers with OAuth.
+package mcpauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+)
+
+// TokensFile is the file of the data directory holding the tokens.
+const TokensFile = "mcp-tokens.json"
+
+// ErrNoToken is returned for servers nobody logged in to.
+var ErrNoToken = errors.New("no token, run 'crush mcp login'")
+
+// Store keeps the tokens of the MCP servers in the data directory, readable
+// by the user only.
+type Store struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewStore(dataDir string) *Store {
+	return &Store{path: filepath.Join(dataDir, TokensFile)}
+}
+
+// For returns the token store of an MCP server, for its transport.
+func (s *Store) For(name string) transport.TokenStore {
+	return &serverTokens{store: s, name: name}
+}
+
+// Get returns the token of an MCP server.
+func (s *Store) Get(name string) (*transport.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[name]
+	if !ok {
+		return nil, ErrNoToken
+	}
+	return token, nil
+}
+
+// Save sets the token of an MCP server.
+func (s *Store) Save(name string, token *transport.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[name] = token
+	return s.write(tokens)
+}
+
+// Delete forgets the token of an MCP server.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[name]; !ok {
+		return ErrNoToken
+	}
+	delete(tokens, name)
+	return s.write(tokens)
+}
+
+func (s *Store) load() (map[string]*transport.Token, error) {
+	tokens := map[string]*transport.Token{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MCP tokens: %w", err)
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse MCP tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *Store) write(tokens map[string]*transport.Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode MCP tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	// Write and rename, not to lose the tokens of other servers midway.
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write MCP tokens: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write MCP tokens: %w", err)
+	}
+	return nil
+}
+
+// serverTokens is the token store of one server.
+type serverTokens struct {
+	store *Store
+	name  string
+}
+
+func (t *serverTokens) GetToken() (*transport.Token, error) {
+	return t.store.Get(t.name)
+}
+
+func (t *serverTokens) SaveToken(token *transport.Token) error {
+	return t.store.Save(t.name, token)
+}
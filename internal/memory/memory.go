@@ -0,0 +1,345 @@
+// Package memory stores the facts learned about a project, such as its build
+// commands, code conventions, and gotchas, and finds the ones relevant to a
+// prompt by keywords, or by meaning when an embedding model is available.
+package memory
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/embeddings"
+	"github.com/google/uuid"
+
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// StoreFile is the name of the store in the data directory.
+const StoreFile = "memory.db"
+
+// minSimilarity is the cosine similarity of a memory and a prompt below which
+// the memory isn't relevant.
+const minSimilarity = 0.3
+
+// ErrNotFound is returned when deleting a memory that doesn't exist.
+var ErrNotFound = errors.New("memory not found")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS memories (
+	id TEXT PRIMARY KEY,
+	content TEXT NOT NULL UNIQUE,
+	created_at INTEGER NOT NULL,
+	model TEXT NOT NULL DEFAULT '',
+	embedding BLOB
+);
+`
+
+// Memory is a fact learned about the project.
+type Memory struct {
+	ID        string
+	Content   string
+	CreatedAt time.Time
+}
+
+// Store is the memory of a project.
+type Store struct {
+	db *sql.DB
+	// embedder is nil without an embedding model, the memories are then
+	// found by keywords.
+	embedder embeddings.Client
+}
+
+// Open opens the store in the data directory. The embedder may be nil.
+func Open(ctx context.Context, dataDir string, embedder embeddings.Client) (*Store, error) {
+	if err := os.MkdirAll(dataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dataDir, StoreFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the memory: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL;", "PRAGMA busy_timeout = 5000;", schema} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set up the memory: %w", err)
+		}
+	}
+	return &Store{db: db, embedder: embedder}, nil
+}
+
+// OpenProject opens the store of the project of the configuration, with its
+// embedding model if there is one.
+func OpenProject(ctx context.Context, cfg *config.Config) (*Store, error) {
+	embedder, err := embeddings.New()
+	if err != nil {
+		slog.Debug("Memories are found by keywords", "error", err)
+		embedder = nil
+	}
+	return Open(ctx, cfg.Options.DataDirectory, embedder)
+}
+
+// Close closes the store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add stores the fact, reporting false if it was already stored.
+func (s *Store) Add(ctx context.Context, content string) (Memory, bool, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Memory{}, false, fmt.Errorf("the memory is empty")
+	}
+	var existing Memory
+	var createdAt int64
+	err := s.db.QueryRowContext(ctx, "SELECT id, content, created_at FROM memories WHERE content = ?", content).
+		Scan(&existing.ID, &existing.Content, &createdAt)
+	if err == nil {
+		existing.CreatedAt = time.UnixMilli(createdAt)
+		return existing, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Memory{}, false, fmt.Errorf("failed to read the memory: %w", err)
+	}
+
+	m := Memory{ID: uuid.New().String()[:8], Content: content, CreatedAt: time.Now()}
+	model, embedding := "", []byte(nil)
+	if s.embedder != nil {
+		// Memories not embedded now are embedded when searched.
+		if vectors, err := s.embedder.Embed(ctx, []string{content}); err != nil {
+			slog.Warn("Failed to embed the memory", "error", err)
+		} else {
+			model, embedding = s.embedder.Model().ID, encodeVector(normalize(vectors[0]))
+		}
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO memories (id, content, created_at, model, embedding) VALUES (?, ?, ?, ?, ?)",
+		m.ID, m.Content, m.CreatedAt.UnixMilli(), model, embedding)
+	if err != nil {
+		return Memory{}, false, fmt.Errorf("failed to write the memory: %w", err)
+	}
+	return m, true, nil
+}
+
+// List returns the memories, the oldest first.
+func (s *Store) List(ctx context.Context) ([]Memory, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, content, created_at FROM memories ORDER BY rowid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the memory: %w", err)
+	}
+	defer rows.Close()
+	var memories []Memory
+	for rows.Next() {
+		var m Memory
+		var createdAt int64
+		if err := rows.Scan(&m.ID, &m.Content, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read the memory: %w", err)
+		}
+		m.CreatedAt = time.UnixMilli(createdAt)
+		memories = append(memories, m)
+	}
+	return memories, rows.Err()
+}
+
+// Delete deletes the memory with the ID.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM memories WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete the memory: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Relevant returns the memories relevant to the prompt, the most relevant
+// first, up to the limit. They're found by meaning with an embedding model,
+// by keywords otherwise or if the embedding fails.
+func (s *Store) Relevant(ctx context.Context, prompt string, limit int) ([]Memory, error) {
+	if s.embedder != nil {
+		memories, err := s.similar(ctx, prompt, limit)
+		if err == nil {
+			return memories, nil
+		}
+		slog.Warn("Failed to find memories by meaning, finding them by keywords", "error", err)
+	}
+	memories, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return byKeywords(memories, prompt, limit), nil
+}
+
+type scored struct {
+	Memory
+	score float64
+}
+
+// similar returns the memories whose embeddings are the closest to the one
+// of the prompt, embedding the ones stored without one first.
+func (s *Store) similar(ctx context.Context, prompt string, limit int) ([]Memory, error) {
+	model := s.embedder.Model().ID
+	rows, err := s.db.QueryContext(ctx, "SELECT id, content, created_at, model, embedding FROM memories")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the memory: %w", err)
+	}
+	var all []scored
+	var vectors [][]float32
+	var missing []int
+	for rows.Next() {
+		var m scored
+		var createdAt int64
+		var embeddedWith string
+		var embedding []byte
+		if err := rows.Scan(&m.ID, &m.Content, &createdAt, &embeddedWith, &embedding); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read the memory: %w", err)
+		}
+		m.CreatedAt = time.UnixMilli(createdAt)
+		if embeddedWith != model || embedding == nil {
+			missing = append(missing, len(all))
+		}
+		all = append(all, m)
+		vectors = append(vectors, decodeVector(embedding))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the memory: %w", err)
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+
+	inputs := []string{prompt}
+	for _, i := range missing {
+		inputs = append(inputs, all[i].Content)
+	}
+	embedded, err := s.embedder.Embed(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	for k, i := range missing {
+		vectors[i] = normalize(embedded[k+1])
+		if _, err := s.db.ExecContext(ctx, "UPDATE memories SET model = ?, embedding = ? WHERE id = ?", model, encodeVector(vectors[i]), all[i].ID); err != nil {
+			return nil, fmt.Errorf("failed to write the memory: %w", err)
+		}
+	}
+	query := normalize(embedded[0])
+	for i := range all {
+		all[i].score = dot(query, vectors[i])
+	}
+	return top(all, minSimilarity, limit), nil
+}
+
+// byKeywords returns the memories sharing the most words with the prompt.
+func byKeywords(memories []Memory, prompt string, limit int) []Memory {
+	words := keywords(prompt)
+	all := make([]scored, len(memories))
+	for i, m := range memories {
+		all[i].Memory = m
+		for word := range keywords(m.Content) {
+			if words[word] {
+				all[i].score++
+			}
+		}
+	}
+	return top(all, 1, limit)
+}
+
+// top returns the memories scoring at least the minimum, the best first and
+// the newest of equal ones.
+func top(all []scored, minScore float64, limit int) []Memory {
+	all = slices.DeleteFunc(all, func(m scored) bool {
+		return m.score < minScore
+	})
+	slices.SortStableFunc(all, func(a, b scored) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), b.CreatedAt.Compare(a.CreatedAt))
+	})
+	memories := make([]Memory, 0, min(limit, len(all)))
+	for _, m := range all[:min(limit, len(all))] {
+		memories = append(memories, m.Memory)
+	}
+	return memories
+}
+
+// stopWords are left out of the keywords, they match most memories.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true,
+	"you": true, "all": true, "can": true, "was": true, "use": true, "with": true,
+	"this": true, "that": true, "from": true, "have": true, "when": true, "what": true,
+	"into": true, "them": true, "then": true, "they": true, "there": true, "which": true,
+	"should": true, "would": true, "could": true, "please": true,
+}
+
+// keywords returns the lowercase words of the text in the singular, leaving
+// out the short ones and the stop words.
+func keywords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for word := range strings.FieldsFuncSeq(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 3 || stopWords[word] {
+			continue
+		}
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = word[:len(word)-1]
+		}
+		words[word] = true
+	}
+	return words
+}
+
+// normalize scales the vector to unit length, for the dot product of two
+// vectors to be their cosine similarity.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for k, x := range v {
+		out[k] = x / norm
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for k := range min(len(a), len(b)) {
+		sum += float64(a[k]) * float64(b[k])
+	}
+	return sum
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for k, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*k:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for k := range v {
+		v[k] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*k:]))
+	}
+	return v
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"hash/fnv"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/llm/embeddings"
+	"github.com/stretchr/testify/require"
+)
+
+// wordsEmbedder embeds texts as bags of words, for texts sharing words to be
+// similar.
+type wordsEmbedder struct{}
+
+func (wordsEmbedder) Embed(_ context.Context, inputs []string) ([][]float32, error) {
+	vectors := make([][]float32, len(inputs))
+	for k, input := range inputs {
+		v := make([]float32, 64)
+		for word := range keywords(input) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%64]++
+		}
+		vectors[k] = v
+	}
+	return vectors, nil
+}
+
+func (wordsEmbedder) Model() catwalk.Model {
+	return catwalk.Model{ID: "words"}
+}
+
+var facts = []string{
+	"Run the tests with task test, not go test",
+	"The config is merged from the global and the project files",
+	"Migrations live in internal/db/migrations and are numbered",
+}
+
+func openStore(t *testing.T, dataDir string, embedder embeddings.Client) *Store {
+	t.Helper()
+	store, err := Open(t.Context(), dataDir, embedder)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	store := openStore(t, t.TempDir(), nil)
+	for _, fact := range facts {
+		_, added, err := store.Add(ctx, fact)
+		require.NoError(t, err)
+		require.True(t, added)
+	}
+	first, added, err := store.Add(ctx, " "+facts[0]+"\n")
+	require.NoError(t, err)
+	require.False(t, added, "the same fact is stored once")
+
+	memories, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, memories, 3)
+	require.Equal(t, facts[0], memories[0].Content)
+
+	require.NoError(t, store.Delete(ctx, first.ID))
+	require.ErrorIs(t, store.Delete(ctx, first.ID), ErrNotFound)
+	memories, err = store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, memories, 2)
+}
+
+func TestStore_Relevant(t *testing.T) {
+	t.Parallel()
+
+	for name, embedder := range map[string]embeddings.Client{"keywords": nil, "embeddings": wordsEmbedder{}} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			dataDir := t.TempDir()
+			// Facts stored without an embedding model are embedded once
+			// one is configured.
+			store := openStore(t, dataDir, nil)
+			for _, fact := range facts {
+				_, _, err := store.Add(ctx, fact)
+				require.NoError(t, err)
+			}
+			store.Close()
+			store = openStore(t, dataDir, embedder)
+
+			memories, err := store.Relevant(ctx, "Add a migration to internal for the users", 5)
+			require.NoError(t, err)
+			require.Len(t, memories, 1)
+			require.True(t, strings.HasPrefix(memories[0].Content, "Migrations live"))
+
+			memories, err = store.Relevant(ctx, "Why is the weather nice?", 5)
+			require.NoError(t, err)
+			require.Empty(t, memories)
+		})
+	}
+}
@@ -1,8 +1,134 @@
 package message
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxAttachmentSize is the size of the largest file that can be attached.
+const MaxAttachmentSize = int64(5 * 1024 * 1024) // 5MB
+
+// imageTypes are the MIME types of the images the providers accept.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
 type Attachment struct {
 	FilePath string
 	FileName string
 	MimeType string
 	Content  []byte
 }
+
+// IsImage reports whether the attachment is an image, sent as is to the
+// models that support images.
+func (a Attachment) IsImage() bool {
+	return slices.Contains(imageTypes, a.MimeType)
+}
+
+// NewAttachment returns the content as an attachment, an image or a text
+// file, named after the path.
+func NewAttachment(path string, content []byte) (Attachment, error) {
+	attachment := Attachment{FilePath: path, FileName: filepath.Base(path), Content: content}
+	mimeType := http.DetectContentType(content[:min(512, len(content))])
+	switch {
+	case slices.Contains(imageTypes, mimeType):
+		attachment.MimeType = mimeType
+	case utf8.Valid(content) && !strings.ContainsRune(string(content), 0):
+		attachment.MimeType = "text/plain"
+	default:
+		return Attachment{}, fmt.Errorf("%s is neither an image nor a text file", attachment.FileName)
+	}
+	return attachment, nil
+}
+
+// ReadAttachment reads the file at the path as an attachment.
+func ReadAttachment(path string) (Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if info.IsDir() {
+		return Attachment{}, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > MaxAttachmentSize {
+		return Attachment{}, fmt.Errorf("%s is larger than %d MB", filepath.Base(path), MaxAttachmentSize/1024/1024)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return NewAttachment(path, content)
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// MentionedAttachments returns the files mentioned in the prompt as @path,
+// relative to the working directory, as attachments. Mentions of paths that
+// don't exist, such as @someone, are left out.
+func MentionedAttachments(prompt, workingDir string) ([]Attachment, error) {
+	var attachments []Attachment
+	var errs []error
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(prompt, -1) {
+		path := match[1]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			// The mention may be followed by punctuation.
+			path = strings.TrimRight(path, ".,;:!?)'\"")
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		attachment, err := ReadAttachment(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot attach %s: %w", match[1], err))
+			continue
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, errors.Join(errs...)
+}
+
+// InlineAttachments returns the user message with its text attachments
+// added to its text, and its images too, as a note, if the model doesn't
+// support them, for the providers to only send images.
+func (m *Message) InlineAttachments(images bool) Message {
+	if len(m.BinaryContent()) == 0 {
+		return *m
+	}
+	var text strings.Builder
+	text.WriteString(m.Content().Text)
+	var parts []ContentPart
+	for _, part := range m.Parts {
+		switch c := part.(type) {
+		case TextContent:
+			// Rebuilt after the other parts.
+		case BinaryContent:
+			switch {
+			case c.IsImage() && images:
+				parts = append(parts, c)
+			case c.IsImage():
+				fmt.Fprintf(&text, "\n\n[The image %s is attached, but you can't see images.]", filepath.Base(c.Path))
+			default:
+				fmt.Fprintf(&text, "\n\n<file path=%q>\n%s\n</file>", c.Path, strings.TrimSuffix(string(c.Data), "\n"))
+			}
+		default:
+			parts = append(parts, part)
+		}
+	}
+	inlined := *m
+	inlined.Parts = append([]ContentPart{TextContent{Text: text.String()}}, parts...)
+	return inlined
+}
@@ -0,0 +1,50 @@
+package message
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// png is the start of a PNG file, enough to detect its type.
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestMentionedAttachments(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "plan.md"), []byte("# Plan\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "screen.png"), png, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.bin"), []byte{0, 1, 2}, 0o644))
+
+	attachments, err := MentionedAttachments("Follow @docs/plan.md, see @screen.png and ask @someone. Again @docs/plan.md", dir)
+	require.NoError(t, err)
+	require.Len(t, attachments, 2)
+	require.Equal(t, filepath.Join(dir, "docs", "plan.md"), attachments[0].FilePath)
+	require.Equal(t, "text/plain", attachments[0].MimeType)
+	require.False(t, attachments[0].IsImage())
+	require.Equal(t, "screen.png", attachments[1].FileName)
+	require.True(t, attachments[1].IsImage())
+
+	_, err = MentionedAttachments("Run @app.bin", dir)
+	require.ErrorContains(t, err, "neither an image nor a text file")
+}
+
+func TestInlineAttachments(t *testing.T) {
+	msg := Message{Role: User, Parts: []ContentPart{
+		TextContent{Text: "Implement the plan"},
+		BinaryContent{Path: "/project/plan.md", MIMEType: "text/plain", Data: []byte("# Plan\n")},
+		BinaryContent{Path: "/project/screen.png", MIMEType: "image/png", Data: png},
+	}}
+
+	inlined := msg.InlineAttachments(true)
+	require.Equal(t, "Implement the plan\n\n<file path=\"/project/plan.md\">\n# Plan\n</file>", inlined.Content().Text)
+	require.Len(t, inlined.BinaryContent(), 1)
+	require.Equal(t, "image/png", inlined.BinaryContent()[0].MIMEType)
+
+	inlined = msg.InlineAttachments(false)
+	require.Contains(t, inlined.Content().Text, "[The image screen.png is attached, but you can't see images.]")
+	require.Empty(t, inlined.BinaryContent())
+	require.Len(t, msg.Parts, 3, "the message is left as is")
+}
@@ -26,6 +26,9 @@ const (
 	FinishReasonCanceled         FinishReason = "canceled"
 	FinishReasonError            FinishReason = "error"
 	FinishReasonPermissionDenied FinishReason = "permission_denied"
+	// FinishReasonInterrupted ends the responses Crush stopped during,
+	// killed or crashed, found when it starts again.
+	FinishReasonInterrupted FinishReason = "interrupted"
 
 	// Should never happen
 	FinishReasonUnknown FinishReason = "unknown"
@@ -40,6 +43,16 @@ type ReasoningContent struct {
 	Signature  string `json:"signature"`
 	StartedAt  int64  `json:"started_at,omitempty"`
 	FinishedAt int64  `json:"finished_at,omitempty"`
+	// Encrypted is the reasoning of OpenAI models with the Responses API,
+	// sent back to them with the following requests.
+	Encrypted []EncryptedReasoning `json:"encrypted,omitempty"`
+}
+
+// EncryptedReasoning is a reasoning item of the Responses API, encrypted for
+// the reasoning to be kept between requests without OpenAI storing it.
+type EncryptedReasoning struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
 }
 
 func (tc ReasoningContent) String() string {
@@ -49,6 +62,9 @@ func (ReasoningContent) isPart() {}
 
 type TextContent struct {
 	Text string `json:"text"`
+	// Synthetic is set on the feedback crush sends to the model in a user
+	// message, such as the diagnostics or a failed verification.
+	Synthetic bool `json:"synthetic,omitempty"`
 }
 
 func (tc TextContent) String() string {
@@ -82,6 +98,12 @@ func (bc BinaryContent) String(p catwalk.InferenceProvider) string {
 	return base64Encoded
 }
 
+// IsImage reports whether the content is an image, the other attachments are
+// text files.
+func (bc BinaryContent) IsImage() bool {
+	return slices.Contains(imageTypes, bc.MIMEType)
+}
+
 func (BinaryContent) isPart() {}
 
 type ToolCall struct {
@@ -95,15 +117,47 @@ type ToolCall struct {
 func (ToolCall) isPart() {}
 
 type ToolResult struct {
-	ToolCallID string `json:"tool_call_id"`
-	Name       string `json:"name"`
-	Content    string `json:"content"`
-	Metadata   string `json:"metadata"`
-	IsError    bool   `json:"is_error"`
+	ToolCallID string     `json:"tool_call_id"`
+	Name       string     `json:"name"`
+	Content    string     `json:"content"`
+	Metadata   string     `json:"metadata"`
+	IsError    bool       `json:"is_error"`
+	Citations  []Citation `json:"citations,omitempty"`
+	// Digest is a summary of a long Content written by the small model. It
+	// replaces Content in the context of the large model, the user still
+	// sees the full output.
+	Digest string `json:"digest,omitempty"`
 }
 
 func (ToolResult) isPart() {}
 
+// Citation identifies where a piece of retrieved context came from, e.g. a
+// fetched URL or a range of lines in a file.
+type Citation struct {
+	Source   string `json:"source"`
+	Location string `json:"location,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+func (c Citation) String() string {
+	s := c.Source
+	if c.Location != "" {
+		s += " (" + c.Location + ")"
+	}
+	if c.Title != "" {
+		s = c.Title + ": " + s
+	}
+	return s
+}
+
+// Citations holds the sources that were retrieved while producing an
+// assistant message.
+type Citations struct {
+	Sources []Citation `json:"sources"`
+}
+
+func (Citations) isPart() {}
+
 type Finish struct {
 	Reason  FinishReason `json:"reason"`
 	Time    int64        `json:"time"`
@@ -182,6 +236,36 @@ func (m *Message) ToolResults() []ToolResult {
 	return toolResults
 }
 
+func (m *Message) Citations() []Citation {
+	for _, part := range m.Parts {
+		if c, ok := part.(Citations); ok {
+			return c.Sources
+		}
+	}
+	return nil
+}
+
+// AddCitations attaches the given sources to the message, dropping
+// duplicates while keeping the order in which they were first seen.
+func (m *Message) AddCitations(citations ...Citation) {
+	sources := m.Citations()
+	for _, c := range citations {
+		if !slices.Contains(sources, c) {
+			sources = append(sources, c)
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+	for i, part := range m.Parts {
+		if _, ok := part.(Citations); ok {
+			m.Parts[i] = Citations{Sources: sources}
+			return
+		}
+	}
+	m.Parts = append(m.Parts, Citations{Sources: sources})
+}
+
 func (m *Message) IsFinished() bool {
 	for _, part := range m.Parts {
 		if _, ok := part.(Finish); ok {
@@ -209,6 +293,11 @@ func (m *Message) FinishReason() FinishReason {
 	return ""
 }
 
+// IsSynthetic reports whether crush wrote the message rather than the user.
+func (m *Message) IsSynthetic() bool {
+	return m.Role == User && m.Content().Synthetic
+}
+
 func (m *Message) IsThinking() bool {
 	if m.ReasoningContent().Thinking != "" && m.Content().Text == "" && !m.IsFinished() {
 		return true
@@ -238,6 +327,7 @@ func (m *Message) AppendReasoningContent(delta string) {
 				Signature:  c.Signature,
 				StartedAt:  c.StartedAt,
 				FinishedAt: c.FinishedAt,
+				Encrypted:  c.Encrypted,
 			}
 			found = true
 		}
@@ -258,6 +348,7 @@ func (m *Message) AppendReasoningSignature(signature string) {
 				Signature:  c.Signature + signature,
 				StartedAt:  c.StartedAt,
 				FinishedAt: c.FinishedAt,
+				Encrypted:  c.Encrypted,
 			}
 			return
 		}
@@ -265,6 +356,19 @@ func (m *Message) AppendReasoningSignature(signature string) {
 	m.Parts = append(m.Parts, ReasoningContent{Signature: signature})
 }
 
+// AppendEncryptedReasoning adds an encrypted reasoning item to the reasoning
+// of the message.
+func (m *Message) AppendEncryptedReasoning(reasoning EncryptedReasoning) {
+	for i, part := range m.Parts {
+		if c, ok := part.(ReasoningContent); ok {
+			c.Encrypted = append(slices.Clip(c.Encrypted), reasoning)
+			m.Parts[i] = c
+			return
+		}
+	}
+	m.Parts = append(m.Parts, ReasoningContent{Encrypted: []EncryptedReasoning{reasoning}})
+}
+
 func (m *Message) FinishThinking() {
 	for i, part := range m.Parts {
 		if c, ok := part.(ReasoningContent); ok {
@@ -274,6 +378,7 @@ func (m *Message) FinishThinking() {
 					Signature:  c.Signature,
 					StartedAt:  c.StartedAt,
 					FinishedAt: time.Now().Unix(),
+					Encrypted:  c.Encrypted,
 				}
 			}
 			return
@@ -172,6 +172,7 @@ const (
 	toolCallType   partType = "tool_call"
 	toolResultType partType = "tool_result"
 	finishType     partType = "finish"
+	citationsType  partType = "citations"
 )
 
 type partWrapper struct {
@@ -200,6 +201,8 @@ func marshallParts(parts []ContentPart) ([]byte, error) {
 			typ = toolResultType
 		case Finish:
 			typ = finishType
+		case Citations:
+			typ = citationsType
 		default:
 			return nil, fmt.Errorf("unknown part type: %T", part)
 		}
@@ -273,6 +276,12 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 				return nil, err
 			}
 			parts = append(parts, part)
+		case citationsType:
+			part := Citations{}
+			if err := json.Unmarshal(wrapper.Data, &part); err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
 		default:
 			return nil, fmt.Errorf("unknown part type: %s", wrapper.Type)
 		}
@@ -280,3 +289,13 @@ func unmarshallParts(data []byte) ([]ContentPart, error) {
 
 	return parts, nil
 }
+
+// MarshalParts encodes the parts as they are stored, with their type.
+func MarshalParts(parts []ContentPart) ([]byte, error) {
+	return marshallParts(parts)
+}
+
+// UnmarshalParts decodes the parts encoded by MarshalParts.
+func UnmarshalParts(data []byte) ([]ContentPart, error) {
+	return unmarshallParts(data)
+}
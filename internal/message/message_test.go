@@ -0,0 +1,46 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCitationsRoundTrip(t *testing.T) {
+	parts := []ContentPart{
+		TextContent{Text: "The release is on Friday."},
+		ToolResult{
+			ToolCallID: "call_1",
+			Name:       "fetch",
+			Content:    "release notes",
+			Citations:  []Citation{{Source: "https://example.com/notes"}},
+		},
+		Citations{Sources: []Citation{
+			{Source: "https://example.com/notes"},
+			{Source: "/work/CHANGELOG.md", Location: "lines 1-20", Title: "Changelog"},
+		}},
+	}
+	data, err := marshallParts(parts)
+	require.NoError(t, err)
+	decoded, err := unmarshallParts(data)
+	require.NoError(t, err)
+	require.Equal(t, parts, decoded)
+
+	msg := Message{Parts: decoded}
+	require.Equal(t, []Citation{
+		{Source: "https://example.com/notes"},
+		{Source: "/work/CHANGELOG.md", Location: "lines 1-20", Title: "Changelog"},
+	}, msg.Citations())
+}
+
+func TestSynthetic(t *testing.T) {
+	data, err := marshallParts([]ContentPart{TextContent{Text: "The tests fail.", Synthetic: true}})
+	require.NoError(t, err)
+	parts, err := unmarshallParts(data)
+	require.NoError(t, err)
+
+	msg := Message{Role: User, Parts: parts}
+	require.True(t, msg.IsSynthetic())
+	msg = Message{Role: User, Parts: []ContentPart{TextContent{Text: "Fix the tests."}}}
+	require.False(t, msg.IsSynthetic())
+}
@@ -0,0 +1,74 @@
+package permission
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditFile is the file of the data directory logging the permission
+// decisions.
+const AuditFile = "permission-audit.log"
+
+// Who or what decided on a tool call, in the audit log, besides rules.
+const (
+	byUser         = "user"
+	bySessionGrant = "session_grant"
+	bySkipRequests = "skip_requests"
+	byAllowedTools = "allowed_tools"
+	byAutoApproval = "auto_approval"
+	byProjectGrant = "project_grant"
+)
+
+// AuditEntry is a line of the audit log.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	SessionID  string    `json:"session_id,omitempty"`
+	ToolCallID string    `json:"tool_call_id,omitempty"`
+	ToolName   string    `json:"tool_name"`
+	Action     string    `json:"action,omitempty"`
+	// Target is the commands or paths of the call.
+	Target  string `json:"target,omitempty"`
+	Granted bool   `json:"granted"`
+	// By is who or what decided, such as the user or a rule.
+	By string `json:"by"`
+}
+
+// auditLog appends the decisions to a JSON lines file, opened on first use.
+type auditLog struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (a *auditLog) write(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Failed to marshal permission audit entry", "error", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		if err := os.MkdirAll(filepath.Dir(a.path), 0o700); err != nil {
+			slog.Error("Failed to create permission audit log directory", "error", err)
+			return
+		}
+		f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			slog.Error("Failed to open permission audit log", "path", a.path, "error", err)
+			return
+		}
+		a.file = f
+	}
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		slog.Error("Failed to write permission audit log", "error", err)
+	}
+}
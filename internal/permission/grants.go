@@ -0,0 +1,205 @@
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/google/uuid"
+)
+
+// GrantsFile is the file of the data directory holding the grants of the
+// project.
+const GrantsFile = "permission-grants.json"
+
+// Grant allows a tool action on some commands or paths in a project without
+// asking, until it's revoked.
+type Grant struct {
+	ID       string   `json:"id"`
+	ToolName string   `json:"tool_name"`
+	Action   string   `json:"action,omitempty"`
+	Commands []string `json:"commands,omitempty"`
+	Paths    []string `json:"paths,omitempty"`
+	// CreatedAt is when the user granted it.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Target returns the commands or paths of the grant.
+func (g Grant) Target() string {
+	if len(g.Commands) > 0 {
+		return strings.Join(g.Commands, "; ")
+	}
+	return strings.Join(g.Paths, ", ")
+}
+
+func (g Grant) rule() config.PermissionRule {
+	tool := g.ToolName
+	if g.Action != "" {
+		tool += ":" + g.Action
+	}
+	return config.PermissionRule{Tool: tool, Decision: config.PermissionAllow, Paths: g.Paths}
+}
+
+// allows reports whether the grant allows the call. Granted commands match
+// whole commands, not their first words like the commands of rules, and
+// paths match like the paths of rules.
+func (g Grant) allows(toolName, action string, targets callTargets) bool {
+	if !ruleMatches(g.rule(), toolName, action, targets) {
+		return false
+	}
+	if len(g.Commands) == 0 {
+		return true
+	}
+	return matchesTargets(targets.commands, true, func(i int) bool {
+		return matchesAny(g.Commands, func(pattern string) bool { return matchGrantedCommand(pattern, targets.commands[i]) })
+	})
+}
+
+// matchGrantedCommand matches the words of a granted command against all the
+// words of the command, which must have no substitutions or redirections.
+func matchGrantedCommand(pattern, command string) bool {
+	if hiddenCommand.MatchString(command) {
+		return false
+	}
+	return slices.EqualFunc(strings.Fields(pattern), strings.Fields(command), func(p, word string) bool {
+		ok, _ := path.Match(p, word)
+		return ok
+	})
+}
+
+func (g Grant) same(other Grant) bool {
+	return g.ToolName == other.ToolName && g.Action == other.Action &&
+		slices.Equal(g.Commands, other.Commands) && slices.Equal(g.Paths, other.Paths)
+}
+
+// Grants keeps the grants of a project in its data directory. They're read
+// on every request, so revoking them applies to running sessions.
+type Grants struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewGrants(dataDir string) *Grants {
+	return &Grants{path: filepath.Join(dataDir, GrantsFile)}
+}
+
+// List returns the grants, oldest first.
+func (g *Grants) List() ([]Grant, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.load()
+}
+
+// Add saves a grant, unless the same one exists.
+func (g *Grants) Add(grant Grant) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	grants, err := g.load()
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(grants, grant.same) {
+		return nil
+	}
+	if grant.ID == "" {
+		grant.ID = uuid.New().String()[:8]
+	}
+	if grant.CreatedAt.IsZero() {
+		grant.CreatedAt = time.Now()
+	}
+	return g.write(append(grants, grant))
+}
+
+// Revoke deletes the grants with the given IDs, all of them if none is
+// given.
+func (g *Grants) Revoke(ids ...string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	grants, err := g.load()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if !slices.ContainsFunc(grants, func(grant Grant) bool { return grant.ID == id }) {
+			return fmt.Errorf("no grant with ID %s", id)
+		}
+	}
+	remaining := slices.DeleteFunc(grants, func(grant Grant) bool {
+		return len(ids) == 0 || slices.Contains(ids, grant.ID)
+	})
+	return g.write(remaining)
+}
+
+// allows reports whether a grant allows the call.
+func (g *Grants) allows(toolName, action string, targets callTargets) bool {
+	grants, err := g.List()
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(grants, func(grant Grant) bool {
+		return grant.allows(toolName, action, targets)
+	})
+}
+
+func (g *Grants) load() ([]Grant, error) {
+	data, err := os.ReadFile(g.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permission grants: %w", err)
+	}
+	var grants []Grant
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("failed to parse permission grants: %w", err)
+	}
+	return grants, nil
+}
+
+func (g *Grants) write(grants []Grant) error {
+	data, err := json.MarshalIndent(grants, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode permission grants: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(g.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(g.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write permission grants: %w", err)
+	}
+	return nil
+}
+
+// globEscaper escapes the commands and paths of grants, used as patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "{", `\{`)
+
+// grantOf returns the grant of the commands or paths of a request, the
+// whole tool action when it has none.
+func grantOf(req PermissionRequest, workingDir string) Grant {
+	targets := targetsOf(req.Params, workingDir)
+	grant := Grant{ToolName: req.ToolName, Action: req.Action}
+	for _, c := range targets.commands {
+		grant.Commands = append(grant.Commands, globEscaper.Replace(c))
+	}
+	if len(grant.Commands) == 0 {
+		for i, p := range targets.paths {
+			// Paths outside the project are granted by absolute path, the
+			// others from the working directory.
+			if p == ".." || strings.HasPrefix(p, "../") {
+				p = targets.absolute[i]
+			} else {
+				p = "./" + p
+			}
+			grant.Paths = append(grant.Paths, globEscaper.Replace(p))
+		}
+	}
+	return grant
+}
@@ -0,0 +1,101 @@
+package permission
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type destructivePattern struct {
+	re     *regexp.Regexp
+	reason string
+}
+
+// destructivePatterns are commands that can irreversibly lose work or data.
+// They always require an explicit, double confirmation from the user.
+var destructivePatterns = []destructivePattern{
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*push\b.*(?:\s--force\b|\s-[a-zA-Z]*f\b|\s\+\S+)`), "force push rewrites remote history"},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*reset\b.*\s--hard\b`), "hard reset discards uncommitted changes"},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*clean\b.*\s-[a-zA-Z]*f`), "git clean deletes untracked files"},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*branch\b.*\s(?:-D\b|--delete\s+--force\b|--force\s+--delete\b)`), "force deleting a branch may lose unmerged commits"},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*(?:checkout|restore)\b.*\s(?:--\s+)?\.(?:\s|$)`), "discards all uncommitted changes"},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*stash\s+(?:clear|drop)\b`), "drops stashed changes"},
+	{regexp.MustCompile(`\bgit\s+(?:\S+\s+)*filter-(?:branch|repo)\b`), "rewrites repository history"},
+	{regexp.MustCompile(`(?i)\bdrop\s+(?:table|database|schema)\b`), "drops database objects"},
+	{regexp.MustCompile(`(?i)\btruncate\s+table\b`), "deletes all rows of a table"},
+	{regexp.MustCompile(`\bmkfs(?:\.\w+)?\b`), "formats a filesystem"},
+	{regexp.MustCompile(`\bdd\b.*\bof=/dev/`), "overwrites a device"},
+}
+
+// commandSeparators splits a shell command line into individual commands,
+// including the ones sent to the background with &.
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+
+// CheckDestructiveCommand reports whether the given shell command matches a
+// known destructive pattern, and why. Recursive removals are only considered
+// destructive when they target something outside the working directory.
+func CheckDestructiveCommand(command, workingDir string) (reason string, destructive bool) {
+	for _, p := range destructivePatterns {
+		if p.re.MatchString(command) {
+			return p.reason, true
+		}
+	}
+	for _, cmd := range commandSeparators.Split(command, -1) {
+		if isRecursiveRemovalOutside(strings.Fields(cmd), workingDir) {
+			return "recursively deletes files outside the working directory", true
+		}
+	}
+	return "", false
+}
+
+func isRecursiveRemovalOutside(fields []string, workingDir string) bool {
+	// Skip wrappers such as sudo or xargs, and env assignments.
+	program := programWords(fields)
+	if len(program) == 0 || filepath.Base(strings.Trim(program[0], `"'`)) != "rm" {
+		return false
+	}
+	// The targets given by xargs can't be checked.
+	fromInput := slices.ContainsFunc(fields[:len(fields)-len(program)], func(f string) bool {
+		return filepath.Base(f) == "xargs"
+	})
+	fields = program
+
+	var recursive, force bool
+	var targets []string
+	for _, f := range fields[1:] {
+		switch {
+		case f == "--recursive":
+			recursive = true
+		case f == "--force":
+			force = true
+		case strings.HasPrefix(f, "-") && !strings.HasPrefix(f, "--"):
+			recursive = recursive || strings.ContainsAny(f, "rR")
+			force = force || strings.Contains(f, "f")
+		default:
+			targets = append(targets, strings.Trim(f, `"'`))
+		}
+	}
+	if !recursive || !force {
+		return false
+	}
+	if fromInput {
+		return true
+	}
+
+	for _, target := range targets {
+		if target == "" || strings.HasPrefix(target, "~") || strings.HasPrefix(target, "$") || target == "*" || target == "/*" {
+			return true
+		}
+		path := target
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		path = filepath.Clean(path)
+		rel, err := filepath.Rel(workingDir, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
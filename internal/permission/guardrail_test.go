@@ -0,0 +1,84 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDestructiveCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		command     string
+		destructive bool
+	}{
+		{"git push --force origin main", true},
+		{"git push -f", true},
+		{"git push origin +main", true},
+		{"git push --force-with-lease", true},
+		{"git push origin main", false},
+		{"git reset --hard HEAD~1", true},
+		{"git reset --soft HEAD~1", false},
+		{"git clean -fdx", true},
+		{"git branch -D feature", true},
+		{"git branch -d feature", false},
+		{"git checkout -- .", true},
+		{"git checkout main", false},
+		{"git -C repo stash clear", true},
+		{"psql -c 'DROP TABLE users'", true},
+		{"echo 'select * from users'", false},
+		{"rm -rf build", false},
+		{"rm -rf ./node_modules && npm i", false},
+		{"rm -rf .", true},
+		{"rm -rf ../other", true},
+		{"rm -rf /", true},
+		{"cd x && sudo rm -fr /etc/foo", true},
+		{"rm -rf ~/projects", true},
+		{"/bin/rm -rf ~/x", true},
+		{"/usr/bin/rm -rf ..", true},
+		{"env -i rm -rf ..", true},
+		{"find . -name '*.tmp' | xargs rm -rf", true},
+		{"/bin/rm -rf build", false},
+		{"xargs rm -r", false},
+		{"rm -r ../other", false},
+		{"go test ./...", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			t.Parallel()
+			reason, destructive := CheckDestructiveCommand(tt.command, "/home/user/project")
+			require.Equal(t, tt.destructive, destructive)
+			if destructive {
+				require.NotEmpty(t, reason)
+			}
+		})
+	}
+}
+
+func TestPermissionService_DestructiveNotSkipped(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yolo denies in auto approved sessions", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", true, []string{"bash"}, false)
+		service.AutoApproveSession("session")
+		require.False(t, service.Request(CreatePermissionRequest{
+			SessionID:         "session",
+			ToolName:          "bash",
+			Action:            "execute",
+			DestructiveReason: "force push",
+		}))
+	})
+
+	t.Run("allowed when configured", func(t *testing.T) {
+		t.Parallel()
+		service := NewPermissionService("/tmp", true, []string{}, true)
+		require.True(t, service.Request(CreatePermissionRequest{
+			SessionID:         "session",
+			ToolName:          "bash",
+			Action:            "execute",
+			DestructiveReason: "force push",
+		}))
+	})
+}
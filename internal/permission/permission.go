@@ -3,11 +3,13 @@ package permission
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
 	"sync"
 
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/google/uuid"
@@ -23,6 +25,9 @@ type CreatePermissionRequest struct {
 	Action      string `json:"action"`
 	Params      any    `json:"params"`
 	Path        string `json:"path"`
+	// Why the action is destructive, if it is. Destructive actions always
+	// need to be explicitly confirmed by the user.
+	DestructiveReason string `json:"destructive_reason,omitempty"`
 }
 
 type PermissionNotification struct {
@@ -32,26 +37,51 @@ type PermissionNotification struct {
 }
 
 type PermissionRequest struct {
-	ID          string `json:"id"`
-	SessionID   string `json:"session_id"`
-	ToolCallID  string `json:"tool_call_id"`
-	ToolName    string `json:"tool_name"`
-	Description string `json:"description"`
-	Action      string `json:"action"`
-	Params      any    `json:"params"`
-	Path        string `json:"path"`
+	ID                string `json:"id"`
+	SessionID         string `json:"session_id"`
+	ToolCallID        string `json:"tool_call_id"`
+	ToolName          string `json:"tool_name"`
+	Description       string `json:"description"`
+	Action            string `json:"action"`
+	Params            any    `json:"params"`
+	Path              string `json:"path"`
+	DestructiveReason string `json:"destructive_reason,omitempty"`
 }
 
 type Service interface {
 	pubsub.Suscriber[PermissionRequest]
 	GrantPersistent(permission PermissionRequest)
+	// GrantAlways grants the request and remembers it for the project, see
+	// [WithGrants].
+	GrantAlways(permission PermissionRequest)
 	Grant(permission PermissionRequest)
+	// GrantReviewed grants a file change with only the hunks the user
+	// accepted, the content of the file the tool gets with [Service.Reviewed].
+	GrantReviewed(permission PermissionRequest, content string)
+	// Reviewed returns, once, the content of the file granted with
+	// [Service.GrantReviewed] for a tool call.
+	Reviewed(toolCallID string) (string, bool)
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
 	AutoApproveSession(sessionID string)
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
+	// Evaluate applies the rules to a tool call before it runs, given its
+	// JSON input. Denied calls are logged.
+	Evaluate(sessionID, toolCallID, toolName, input string) Verdict
+	// SetSessionRules sets rules overriding the ones of the config for a
+	// session, except their deny rules.
+	SetSessionRules(sessionID string, rules []config.PermissionRule) error
+	SessionRules(sessionID string) []config.PermissionRule
+	// SetPolicy sets the policy checked before any rule, grant, or approval
+	// lets a tool call through.
+	SetPolicy(policy Policy)
 }
 
+// Policy denies the tool calls of a session whatever the rules, grants, and
+// approvals say. It returns why the call is denied, or "" to let them
+// decide. The action is empty when a call is evaluated before it runs.
+type Policy func(sessionID, toolName, action string) string
+
 type permissionService struct {
 	*pubsub.Broker[PermissionRequest]
 
@@ -60,14 +90,23 @@ type permissionService struct {
 	sessionPermissions    []PermissionRequest
 	sessionPermissionsMu  sync.RWMutex
 	pendingRequests       *csync.Map[string, chan bool]
+	reviewed              *csync.Map[string, string]
 	autoApproveSessions   map[string]bool
 	autoApproveSessionsMu sync.RWMutex
 	skip                  bool
 	allowedTools          []string
+	allowDestructive      bool
+	rules                 []config.PermissionRule
+	sessionRules          *csync.Map[string, []config.PermissionRule]
+	audit                 *auditLog
+	grants                *Grants
+	policy                Policy
+	policyMu              sync.RWMutex
 
 	// used to make sure we only process one request at a time
-	requestMu     sync.Mutex
-	activeRequest *PermissionRequest
+	requestMu       sync.Mutex
+	activeRequestMu sync.Mutex
+	activeRequest   *PermissionRequest
 }
 
 func (s *permissionService) GrantPersistent(permission PermissionRequest) {
@@ -80,13 +119,25 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 		respCh <- true
 	}
 
-	s.sessionPermissionsMu.Lock()
-	s.sessionPermissions = append(s.sessionPermissions, permission)
-	s.sessionPermissionsMu.Unlock()
+	// Destructive actions are never remembered, they need to be confirmed
+	// every time.
+	if permission.DestructiveReason == "" {
+		s.sessionPermissionsMu.Lock()
+		s.sessionPermissions = append(s.sessionPermissions, permission)
+		s.sessionPermissionsMu.Unlock()
+	}
 
-	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
-		s.activeRequest = nil
+	s.clearActiveRequest(permission.ID)
+}
+
+func (s *permissionService) GrantAlways(permission PermissionRequest) {
+	// Like in the session, destructive actions are never remembered.
+	if s.grants != nil && permission.DestructiveReason == "" {
+		if err := s.grants.Add(grantOf(permission, s.workingDir)); err != nil {
+			slog.Error("Failed to save permission grant", "error", err)
+		}
 	}
+	s.GrantPersistent(permission)
 }
 
 func (s *permissionService) Grant(permission PermissionRequest) {
@@ -99,9 +150,16 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 		respCh <- true
 	}
 
-	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
-		s.activeRequest = nil
-	}
+	s.clearActiveRequest(permission.ID)
+}
+
+func (s *permissionService) GrantReviewed(permission PermissionRequest, content string) {
+	s.reviewed.Set(permission.ToolCallID, content)
+	s.Grant(permission)
+}
+
+func (s *permissionService) Reviewed(toolCallID string) (string, bool) {
+	return s.reviewed.Take(toolCallID)
 }
 
 func (s *permissionService) Deny(permission PermissionRequest) {
@@ -115,14 +173,55 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 		respCh <- false
 	}
 
-	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
+	s.clearActiveRequest(permission.ID)
+}
+
+// clearActiveRequest clears the active request once answered. Answers come
+// from other goroutines than the request.
+func (s *permissionService) clearActiveRequest(id string) {
+	s.activeRequestMu.Lock()
+	defer s.activeRequestMu.Unlock()
+	if s.activeRequest != nil && s.activeRequest.ID == id {
 		s.activeRequest = nil
 	}
 }
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
-	if s.skip {
-		return true
+	if s.allowDestructive {
+		opts.DestructiveReason = ""
+	}
+	destructive := opts.DestructiveReason != ""
+	targets := targetsOf(opts.Params, s.workingDir)
+	decide := func(granted bool, by string) bool {
+		s.audit.write(AuditEntry{
+			SessionID:  opts.SessionID,
+			ToolCallID: opts.ToolCallID,
+			ToolName:   opts.ToolName,
+			Action:     opts.Action,
+			Target:     targets.String(),
+			Granted:    granted,
+			By:         by,
+		})
+		return granted
+	}
+
+	// The policy comes before anything that could allow the call.
+	if reason := s.denial(opts.SessionID, opts.ToolName, opts.Action); reason != "" {
+		return decide(false, "policy: "+reason)
+	}
+
+	// Rules come first: allow rules don't skip the confirmation of
+	// destructive actions, and ask rules that of any action.
+	verdict := s.evaluate(opts.SessionID, opts.ToolName, opts.Action, targets)
+	switch {
+	case verdict.Decision == config.PermissionDeny:
+		return decide(false, "rule: "+verdict.Rule)
+	case verdict.Decision == config.PermissionAllow && !destructive:
+		return decide(true, "rule: "+verdict.Rule)
+	}
+	confirm := destructive || verdict.Decision == config.PermissionAsk
+	if s.skip && !confirm {
+		return decide(true, bySkipRequests)
 	}
 
 	// tell the UI that a permission was requested
@@ -134,8 +233,11 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 
 	// Check if the tool/action combination is in the allowlist
 	commandKey := opts.ToolName + ":" + opts.Action
-	if slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName) {
-		return true
+	if !confirm && (slices.Contains(s.allowedTools, commandKey) || slices.Contains(s.allowedTools, opts.ToolName)) {
+		return decide(true, byAllowedTools)
+	}
+	if !confirm && s.grants != nil && s.grants.allows(opts.ToolName, opts.Action, targets) {
+		return decide(true, byProjectGrant)
 	}
 
 	s.autoApproveSessionsMu.RLock()
@@ -143,7 +245,9 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
-		return true
+		// Auto approved sessions have nobody to confirm destructive
+		// actions, so they are denied.
+		return decide(!confirm, byAutoApproval)
 	}
 
 	fileInfo, err := os.Stat(opts.Path)
@@ -160,35 +264,31 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 		dir = s.workingDir
 	}
 	permission := PermissionRequest{
-		ID:          uuid.New().String(),
-		Path:        dir,
-		SessionID:   opts.SessionID,
-		ToolCallID:  opts.ToolCallID,
-		ToolName:    opts.ToolName,
-		Description: opts.Description,
-		Action:      opts.Action,
-		Params:      opts.Params,
-	}
-
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
-		}
+		ID:                uuid.New().String(),
+		Path:              dir,
+		SessionID:         opts.SessionID,
+		ToolCallID:        opts.ToolCallID,
+		ToolName:          opts.ToolName,
+		Description:       opts.Description,
+		Action:            opts.Action,
+		Params:            opts.Params,
+		DestructiveReason: opts.DestructiveReason,
 	}
-	s.sessionPermissionsMu.RUnlock()
 
-	s.sessionPermissionsMu.RLock()
-	for _, p := range s.sessionPermissions {
-		if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
-			s.sessionPermissionsMu.RUnlock()
-			return true
+	if !confirm {
+		s.sessionPermissionsMu.RLock()
+		for _, p := range s.sessionPermissions {
+			if p.ToolName == permission.ToolName && p.Action == permission.Action && p.SessionID == permission.SessionID && p.Path == permission.Path {
+				s.sessionPermissionsMu.RUnlock()
+				return decide(true, bySessionGrant)
+			}
 		}
+		s.sessionPermissionsMu.RUnlock()
 	}
-	s.sessionPermissionsMu.RUnlock()
 
+	s.activeRequestMu.Lock()
 	s.activeRequest = &permission
+	s.activeRequestMu.Unlock()
 
 	respCh := make(chan bool, 1)
 	s.pendingRequests.Set(permission.ID, respCh)
@@ -197,7 +297,76 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	// Publish the request
 	s.Publish(pubsub.CreatedEvent, permission)
 
-	return <-respCh
+	return decide(<-respCh, byUser)
+}
+
+func (s *permissionService) Evaluate(sessionID, toolCallID, toolName, input string) Verdict {
+	targets := targetsOf(input, s.workingDir)
+	verdict := s.evaluate(sessionID, toolName, "", targets)
+	by := "rule: " + verdict.Rule
+	if reason := s.denial(sessionID, toolName, ""); reason != "" {
+		verdict = Verdict{Decision: config.PermissionDeny, Rule: "policy: " + reason}
+		by = verdict.Rule
+	}
+	if verdict.Decision == config.PermissionDeny {
+		s.audit.write(AuditEntry{
+			SessionID:  sessionID,
+			ToolCallID: toolCallID,
+			ToolName:   toolName,
+			Target:     targets.String(),
+			By:         by,
+		})
+	}
+	return verdict
+}
+
+// evaluate returns the verdict of the rules of the session, or of the
+// config if none matches, but deny rules of the config always apply.
+func (s *permissionService) evaluate(sessionID, toolName, action string, targets callTargets) Verdict {
+	verdict := evaluateRules(s.rules, toolName, action, targets)
+	if verdict.Decision == config.PermissionDeny {
+		return verdict
+	}
+	rules, _ := s.sessionRules.Get(sessionID)
+	if sessionVerdict := evaluateRules(rules, toolName, action, targets); sessionVerdict.Decision != "" {
+		sessionVerdict.Rule = "session " + sessionVerdict.Rule
+		return sessionVerdict
+	}
+	return verdict
+}
+
+// denial returns why the policy denies the call, if it does.
+func (s *permissionService) denial(sessionID, toolName, action string) string {
+	s.policyMu.RLock()
+	policy := s.policy
+	s.policyMu.RUnlock()
+	if policy == nil {
+		return ""
+	}
+	return policy(sessionID, toolName, action)
+}
+
+func (s *permissionService) SetPolicy(policy Policy) {
+	s.policyMu.Lock()
+	s.policy = policy
+	s.policyMu.Unlock()
+}
+
+func (s *permissionService) SetSessionRules(sessionID string, rules []config.PermissionRule) error {
+	if err := ValidateRules(rules); err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		s.sessionRules.Del(sessionID)
+		return nil
+	}
+	s.sessionRules.Set(sessionID, slices.Clone(rules))
+	return nil
+}
+
+func (s *permissionService) SessionRules(sessionID string) []config.PermissionRule {
+	rules, _ := s.sessionRules.Get(sessionID)
+	return slices.Clone(rules)
 }
 
 func (s *permissionService) AutoApproveSession(sessionID string) {
@@ -210,8 +379,37 @@ func (s *permissionService) SubscribeNotifications(ctx context.Context) <-chan p
 	return s.notificationBroker.Subscribe(ctx)
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
-	return &permissionService{
+// Option configures the permission service.
+type Option func(*permissionService)
+
+// WithRules applies permission rules to the tool calls. They must be valid,
+// see [ValidateRules].
+func WithRules(rules []config.PermissionRule) Option {
+	return func(s *permissionService) {
+		s.rules = rules
+	}
+}
+
+// WithAuditLog logs the decisions on tool calls to a JSON lines file.
+func WithAuditLog(path string) Option {
+	return func(s *permissionService) {
+		s.audit = &auditLog{path: path}
+	}
+}
+
+// WithGrants remembers the requests granted with [Service.GrantAlways] in
+// the project.
+func WithGrants(grants *Grants) Option {
+	return func(s *permissionService) {
+		s.grants = grants
+	}
+}
+
+// NewPermissionService creates the permission service. Destructive actions
+// (see [CheckDestructiveCommand]) always prompt the user, even when skip is
+// set or the tool is allowed, unless allowDestructive is set.
+func NewPermissionService(workingDir string, skip bool, allowedTools []string, allowDestructive bool, opts ...Option) Service {
+	s := &permissionService{
 		Broker:              pubsub.NewBroker[PermissionRequest](),
 		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
 		workingDir:          workingDir,
@@ -219,6 +417,13 @@ func NewPermissionService(workingDir string, skip bool, allowedTools []string) S
 		autoApproveSessions: make(map[string]bool),
 		skip:                skip,
 		allowedTools:        allowedTools,
+		allowDestructive:    allowDestructive,
 		pendingRequests:     csync.NewMap[string, chan bool](),
+		reviewed:            csync.NewMap[string, string](),
+		sessionRules:        csync.NewMap[string, []config.PermissionRule](),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
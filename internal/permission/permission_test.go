@@ -54,7 +54,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			service := NewPermissionService("/tmp", false, tt.allowedTools)
+			service := NewPermissionService("/tmp", false, tt.allowedTools, false)
 
 			// Create a channel to capture the permission request
 			// Since we're testing the allowlist logic, we need to simulate the request
@@ -79,7 +79,7 @@ func TestPermissionService_AllowedCommands(t *testing.T) {
 }
 
 func TestPermissionService_SkipMode(t *testing.T) {
-	service := NewPermissionService("/tmp", true, []string{})
+	service := NewPermissionService("/tmp", true, []string{}, false)
 
 	result := service.Request(CreatePermissionRequest{
 		SessionID:   "test-session",
@@ -96,7 +96,7 @@ func TestPermissionService_SkipMode(t *testing.T) {
 
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, false)
 
 		req1 := CreatePermissionRequest{
 			SessionID:   "session1",
@@ -140,7 +140,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result2, "Second request should be auto-approved")
 	})
 	t.Run("Sequential requests with temporary grants", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, false)
 
 		req := CreatePermissionRequest{
 			SessionID:   "session2",
@@ -184,7 +184,7 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.False(t, result2, "Second request should be denied")
 	})
 	t.Run("Concurrent requests with different outcomes", func(t *testing.T) {
-		service := NewPermissionService("/tmp", false, []string{})
+		service := NewPermissionService("/tmp", false, []string{}, false)
 
 		events := service.Subscribe(t.Context())
 
@@ -249,3 +249,33 @@ func TestPermissionService_SequentialProperties(t *testing.T) {
 		assert.True(t, result, "Repeated request should be auto-approved due to persistent permission")
 	})
 }
+
+func TestPermissionService_GrantReviewed(t *testing.T) {
+	service := NewPermissionService("/tmp", false, []string{}, false)
+	events := service.Subscribe(t.Context())
+
+	var granted bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		granted = service.Request(CreatePermissionRequest{
+			SessionID:  "session1",
+			ToolCallID: "call1",
+			ToolName:   "edit",
+			Action:     "write",
+			Path:       "/tmp/main.go",
+		})
+	}()
+
+	event := <-events
+	service.GrantReviewed(event.Payload, "package main\n")
+	wg.Wait()
+	assert.True(t, granted)
+
+	content, ok := service.Reviewed("call1")
+	assert.True(t, ok)
+	assert.Equal(t, "package main\n", content)
+	_, ok = service.Reviewed("call1")
+	assert.False(t, ok, "the content is returned once")
+}
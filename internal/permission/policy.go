@@ -0,0 +1,368 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// Verdict is the decision of the permission rules on a tool call.
+type Verdict struct {
+	// Decision is empty when no rule matches.
+	Decision config.PermissionDecision
+	// Rule describes the rule that decided, for messages and the audit log.
+	Rule string
+}
+
+// strictness orders the decisions, for the strictest matching rule to win.
+var strictness = map[config.PermissionDecision]int{
+	config.PermissionAllow: 1,
+	config.PermissionAsk:   2,
+	config.PermissionDeny:  3,
+}
+
+// ValidateRules reports the first invalid rule.
+func ValidateRules(rules []config.PermissionRule) error {
+	for i, r := range rules {
+		if _, ok := strictness[r.Decision]; !ok {
+			return fmt.Errorf("permission rule %d: decision must be allow, ask, or deny, got %q", i+1, r.Decision)
+		}
+		tool, _, _ := strings.Cut(r.Tool, ":")
+		if tool == "" {
+			return fmt.Errorf("permission rule %d: tool is required", i+1)
+		}
+		if _, err := path.Match(r.Tool, ""); err != nil {
+			return fmt.Errorf("permission rule %d: invalid tool pattern %q", i+1, r.Tool)
+		}
+		for _, p := range r.Paths {
+			if !doublestar.ValidatePattern(filepath.ToSlash(p)) {
+				return fmt.Errorf("permission rule %d: invalid path pattern %q", i+1, p)
+			}
+		}
+		for _, c := range r.Commands {
+			if len(strings.Fields(c)) == 0 {
+				return fmt.Errorf("permission rule %d: empty command", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// callTargets are the commands and paths of a tool call.
+type callTargets struct {
+	commands []string
+	// paths are relative to the working directory, with slashes, and
+	// absolute is the absolute path of each.
+	paths    []string
+	absolute []string
+}
+
+// targetsOf finds the commands and paths in the parameters of a call, given
+// as JSON or as the params of a permission request.
+func targetsOf(params any, workingDir string) callTargets {
+	var fields map[string]any
+	switch p := params.(type) {
+	case nil:
+	case string:
+		_ = json.Unmarshal([]byte(p), &fields)
+	default:
+		data, err := json.Marshal(p)
+		if err == nil {
+			_ = json.Unmarshal(data, &fields)
+		}
+	}
+
+	var t callTargets
+	if command, ok := fields["command"].(string); ok {
+		for _, c := range commandSeparators.Split(command, -1) {
+			if c = strings.TrimSpace(c); c != "" {
+				t.commands = append(t.commands, c)
+			}
+		}
+	}
+	var paths []any
+	for _, key := range []string{"file_path", "path"} {
+		paths = append(paths, fields[key])
+	}
+	// Tools changing several files at once list them in files.
+	if files, ok := fields["files"].([]any); ok {
+		for _, file := range files {
+			if file, ok := file.(map[string]any); ok {
+				paths = append(paths, file["file_path"])
+			}
+		}
+	}
+	for _, p := range paths {
+		p, ok := p.(string)
+		if !ok || p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(workingDir, p)
+		}
+		p = filepath.Clean(p)
+		rel, err := filepath.Rel(workingDir, p)
+		if err != nil {
+			rel = p
+		}
+		t.paths = append(t.paths, filepath.ToSlash(rel))
+		t.absolute = append(t.absolute, filepath.ToSlash(p))
+	}
+	return t
+}
+
+func (t callTargets) String() string {
+	if len(t.commands) > 0 {
+		return strings.Join(t.commands, "; ")
+	}
+	return strings.Join(t.paths, ", ")
+}
+
+// evaluateRules returns the verdict of the strictest rule matching the call.
+func evaluateRules(rules []config.PermissionRule, toolName, action string, t callTargets) Verdict {
+	var verdict Verdict
+	for _, r := range rules {
+		if strictness[r.Decision] > strictness[verdict.Decision] && ruleMatches(r, toolName, action, t) {
+			verdict = Verdict{Decision: r.Decision, Rule: describeRule(r)}
+		}
+	}
+	return verdict
+}
+
+func ruleMatches(r config.PermissionRule, toolName, action string, t callTargets) bool {
+	tool, ruleAction, scoped := strings.Cut(r.Tool, ":")
+	if ok, _ := path.Match(tool, toolName); !ok {
+		return false
+	}
+	if scoped {
+		if ok, _ := path.Match(ruleAction, action); !ok || action == "" {
+			return false
+		}
+	}
+	// Allow rules must match all the targets of the call, so that one
+	// allowed command doesn't let others through, while deny and ask rules
+	// apply to any of them.
+	all := r.Decision == config.PermissionAllow
+	if len(r.Commands) > 0 && !matchesTargets(t.commands, all, func(i int) bool {
+		return matchesAny(r.Commands, func(pattern string) bool { return matchCommand(pattern, t.commands[i], all) })
+	}) {
+		return false
+	}
+	if len(r.Paths) > 0 && !matchesTargets(t.paths, all, func(i int) bool {
+		return matchesAny(r.Paths, func(pattern string) bool { return matchPath(pattern, t.paths[i], t.absolute[i]) })
+	}) {
+		return false
+	}
+	return true
+}
+
+func matchesTargets(targets []string, all bool, match func(i int) bool) bool {
+	if len(targets) == 0 {
+		return false
+	}
+	for i := range targets {
+		if match(i) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+func matchesAny(patterns []string, match func(string) bool) bool {
+	for _, p := range patterns {
+		if match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenCommand matches the command substitutions and redirections, which
+// run or write more than the words of a command show.
+var hiddenCommand = regexp.MustCompile("\\$\\(|`|[<>]")
+
+// matchCommand matches the words of the pattern against the first words of
+// the command, skipping its environment assignments. A last * word matches
+// any further words. Allow patterns only match commands without
+// substitutions or redirections, and let further arguments through but not
+// options, such as go test -exec. Deny and ask patterns match the program
+// run by wrappers such as sudo, by its name, and its options in any order.
+func matchCommand(pattern, command string, allow bool) bool {
+	if allow && hiddenCommand.MatchString(command) {
+		return false
+	}
+	words := strings.Fields(command)
+	patternWords := strings.Fields(pattern)
+	rest := len(patternWords) > 0 && patternWords[len(patternWords)-1] == "*"
+	if rest {
+		patternWords = patternWords[:len(patternWords)-1]
+	}
+	if !allow {
+		return matchProgram(patternWords, programWords(words))
+	}
+	for len(words) > 0 && strings.Contains(words[0], "=") {
+		words = words[1:]
+	}
+	if len(words) < len(patternWords) {
+		return false
+	}
+	for i, p := range patternWords {
+		if ok, _ := path.Match(p, strings.Trim(words[i], `"'`)); !ok {
+			return false
+		}
+	}
+	if !rest {
+		for _, word := range words[len(patternWords):] {
+			if strings.HasPrefix(word, "-") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// wrapperOptions lists the commands running the command in their
+// arguments, with the letters of their options taking a value.
+var wrapperOptions = map[string]string{
+	"command": "",
+	"doas":    "uC",
+	"env":     "uCS",
+	"exec":    "a",
+	"nice":    "n",
+	"nohup":   "",
+	"sudo":    "ugCDhprtTU",
+	"time":    "fo",
+	"xargs":   "aEdIiLlnPs",
+}
+
+// programWords returns the words of the command from the program it runs,
+// skipping its environment assignments and the wrappers running it, with
+// their options.
+func programWords(words []string) []string {
+	for len(words) > 0 {
+		if strings.Contains(words[0], "=") {
+			words = words[1:]
+			continue
+		}
+		valued, ok := wrapperOptions[filepath.Base(strings.Trim(words[0], `"'`))]
+		if !ok {
+			return words
+		}
+		words = words[1:]
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+			option := words[0]
+			words = words[1:]
+			if option == "--" {
+				break
+			}
+			if len(option) == 2 && strings.Contains(valued, option[1:]) && len(words) > 0 {
+				words = words[1:]
+			}
+		}
+	}
+	return words
+}
+
+// shortOptions matches a word of short options, like -rf.
+var shortOptions = regexp.MustCompile(`^-[[:alnum:]]+$`)
+
+// matchProgram matches the words of a deny or ask pattern against the words
+// of a command from its program, which matches by its name unless the
+// pattern has a path. The options of the pattern match anywhere in the
+// command, and its short options split or merged, so that rm -rf matches
+// rm -r -f x.
+func matchProgram(patternWords, words []string) bool {
+	var args, options []string
+	for _, word := range words {
+		word = strings.Trim(word, `"'`)
+		if strings.HasPrefix(word, "-") && word != "-" {
+			options = append(options, word)
+		} else {
+			args = append(args, word)
+		}
+	}
+	if len(args) > 0 && len(patternWords) > 0 && !strings.Contains(patternWords[0], "/") {
+		args[0] = filepath.Base(args[0])
+	}
+	var argPatterns []string
+	for _, p := range patternWords {
+		if !strings.HasPrefix(p, "-") || p == "-" {
+			argPatterns = append(argPatterns, p)
+		} else if !hasOption(options, p) {
+			return false
+		}
+	}
+	if len(args) < len(argPatterns) {
+		return false
+	}
+	for i, p := range argPatterns {
+		if ok, _ := path.Match(p, args[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasOption reports whether the option pattern matches one of the options,
+// or for short options, whether all its letters are among the short ones.
+func hasOption(options []string, pattern string) bool {
+	for _, option := range options {
+		if ok, _ := path.Match(pattern, option); ok {
+			return true
+		}
+	}
+	if !shortOptions.MatchString(pattern) {
+		return false
+	}
+	var letters strings.Builder
+	for _, option := range options {
+		if shortOptions.MatchString(option) {
+			letters.WriteString(option[1:])
+		}
+	}
+	for _, r := range pattern[1:] {
+		if !strings.ContainsRune(letters.String(), r) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath matches a path like .gitignore does: patterns without a slash
+// match the names of files and directories at any depth, the others match
+// from the working directory, or the root if absolute. Directories match the
+// files under them.
+func matchPath(pattern, rel, abs string) bool {
+	pattern = filepath.ToSlash(pattern)
+	if !strings.Contains(pattern, "/") {
+		for name := range strings.SplitSeq(rel, "/") {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	target := rel
+	if strings.HasPrefix(pattern, "/") {
+		target = abs
+	}
+	pattern = strings.TrimSuffix(path.Clean(pattern), "/")
+	if ok, _ := doublestar.Match(pattern, target); ok {
+		return true
+	}
+	ok, _ := doublestar.Match(pattern+"/**", target)
+	return ok
+}
+
+func describeRule(r config.PermissionRule) string {
+	parts := []string{string(r.Decision), r.Tool}
+	parts = append(parts, r.Commands...)
+	parts = append(parts, r.Paths...)
+	return strings.Join(parts, " ")
+}
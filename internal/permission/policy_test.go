@@ -0,0 +1,174 @@
+package permission
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+var testRules = []config.PermissionRule{
+	{Tool: "bash", Decision: config.PermissionAllow, Commands: []string{"go test", "go vet"}},
+	{Tool: "bash", Decision: config.PermissionAllow, Commands: []string{"make *"}},
+	{Tool: "bash", Decision: config.PermissionDeny, Commands: []string{"rm -rf"}},
+	{Tool: "bash", Decision: config.PermissionAsk, Commands: []string{"git push"}},
+	{Tool: "edit", Decision: config.PermissionAllow, Paths: []string{"./src"}},
+	{Tool: "*", Decision: config.PermissionDeny, Paths: []string{".env", ".env.*"}},
+	{Tool: "mcp_github_*", Decision: config.PermissionDeny},
+	{Tool: "write:create", Decision: config.PermissionAsk},
+}
+
+func TestEvaluateRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		tool     string
+		action   string
+		params   any
+		expected config.PermissionDecision
+	}{
+		{"allowed command", "bash", "", `{"command": "go test ./..."}`, config.PermissionAllow},
+		{"allowed commands", "bash", "", `{"command": "go vet ./... && go test ./..."}`, config.PermissionAllow},
+		{"allowed and other command", "bash", "", `{"command": "go test ./... && curl example.com"}`, ""},
+		{"denied command in a line", "bash", "", `{"command": "go test ./... ; rm -rf build"}`, config.PermissionDeny},
+		{"denied command with env", "bash", "", `{"command": "FOO=1 rm -rf build"}`, config.PermissionDeny},
+		{"denied command with sudo", "bash", "", `{"command": "sudo -u root rm -rf build"}`, config.PermissionDeny},
+		{"denied command by path", "bash", "", `{"command": "/bin/rm -rf build"}`, config.PermissionDeny},
+		{"denied command with command", "bash", "", `{"command": "command rm -rf build"}`, config.PermissionDeny},
+		{"denied command with env command", "bash", "", `{"command": "env -i FOO=1 rm -rf build"}`, config.PermissionDeny},
+		{"denied command with nice", "bash", "", `{"command": "nice -n 10 rm -rf build"}`, config.PermissionDeny},
+		{"denied command with xargs", "bash", "", `{"command": "find . -name build | xargs -I {} rm -rf {}"}`, config.PermissionDeny},
+		{"denied command with split flags", "bash", "", `{"command": "rm -r -f build"}`, config.PermissionDeny},
+		{"denied command with flags after", "bash", "", `{"command": "rm build -fr"}`, config.PermissionDeny},
+		{"other flags", "bash", "", `{"command": "rm -r build"}`, ""},
+		{"ask command", "bash", "", `{"command": "git push origin main"}`, config.PermissionAsk},
+		{"prefix word only", "bash", "", `{"command": "go testify"}`, ""},
+		{"background command", "bash", "", `{"command": "go test ./... & curl example.com"}`, ""},
+		{"denied background command", "bash", "", `{"command": "go test ./... & rm -rf ~"}`, config.PermissionDeny},
+		{"command substitution", "bash", "", `{"command": "go test ./... $(rm -rf ~)"}`, ""},
+		{"backticks", "bash", "", "{\"command\": \"go test `curl example.com`\"}", ""},
+		{"process substitution", "bash", "", `{"command": "go vet <(curl example.com)"}`, ""},
+		{"redirection", "bash", "", `{"command": "go test ./... > ~/.bashrc"}`, ""},
+		{"further option", "bash", "", `{"command": "go test ./... -exec /bin/evil"}`, ""},
+		{"any further words", "bash", "", `{"command": "make -j4 build"}`, config.PermissionAllow},
+		{"allowed directory", "edit", "", `{"file_path": "/project/src/main.go"}`, config.PermissionAllow},
+		{"relative path", "edit", "", `{"file_path": "src/pkg/util.go"}`, config.PermissionAllow},
+		{"outside directory", "edit", "", `{"file_path": "/project/docs/README.md"}`, ""},
+		{"escaping directory", "edit", "", `{"file_path": "/project/src/../../etc/passwd"}`, ""},
+		{"denied file name", "edit", "", `{"file_path": "/project/src/.env"}`, config.PermissionDeny},
+		{"denied file pattern", "view", "", `{"file_path": "/project/.env.local"}`, config.PermissionDeny},
+		{"path of the params", "ls", "", struct {
+			FilePath string `json:"file_path"`
+		}{"/project/config/.env"}, config.PermissionDeny},
+		{"allowed files", "edit", "", `{"files": [{"file_path": "src/a.go"}, {"file_path": "/project/src/b.go"}]}`, config.PermissionAllow},
+		{"allowed and other file", "edit", "", `{"files": [{"file_path": "src/a.go"}, {"file_path": "docs/b.md"}]}`, ""},
+		{"denied file among files", "transaction", "", `{"files": [{"file_path": "src/a.go"}, {"file_path": ".env"}]}`, config.PermissionDeny},
+		{"tool pattern", "mcp_github_create_issue", "", `{}`, config.PermissionDeny},
+		{"action", "write", "create", `{"file_path": "/project/new.go"}`, config.PermissionAsk},
+		{"no action", "write", "", `{"file_path": "/project/new.go"}`, ""},
+		{"no rule", "fetch", "", `{"url": "https://example.com"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := evaluateRules(testRules, tt.tool, tt.action, targetsOf(tt.params, "/project"))
+			require.Equal(t, tt.expected, verdict.Decision, verdict.Rule)
+		})
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	require.NoError(t, ValidateRules(testRules))
+	require.ErrorContains(t, ValidateRules([]config.PermissionRule{{Tool: "bash", Decision: "maybe"}}), "decision must be")
+	require.ErrorContains(t, ValidateRules([]config.PermissionRule{{Decision: config.PermissionDeny}}), "tool is required")
+	require.ErrorContains(t, ValidateRules([]config.PermissionRule{{Tool: "edit", Decision: config.PermissionDeny, Paths: []string{"src/["}}}), "invalid path pattern")
+}
+
+func TestPermissionService_Rules(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), AuditFile)
+	service := NewPermissionService("/project", false, nil, false, WithRules(testRules), WithAuditLog(auditPath))
+	request := func(sessionID, command string) bool {
+		return service.Request(CreatePermissionRequest{
+			SessionID:  sessionID,
+			ToolCallID: "call",
+			ToolName:   "bash",
+			Action:     "execute",
+			Path:       "/project",
+			Params:     map[string]string{"command": command},
+		})
+	}
+
+	// Allowed and denied without asking anybody.
+	require.True(t, request("s1", "go test ./..."))
+	require.False(t, request("s1", "rm -rf build"))
+	require.Equal(t, config.PermissionDeny, service.Evaluate("s1", "call", "bash", `{"command": "rm -rf build"}`).Decision)
+
+	// Sessions override the rules of the config, but not its deny rules.
+	require.NoError(t, service.SetSessionRules("s2", []config.PermissionRule{
+		{Tool: "bash", Decision: config.PermissionDeny, Commands: []string{"go test"}},
+		{Tool: "bash", Decision: config.PermissionAllow, Commands: []string{"rm"}},
+	}))
+	require.False(t, request("s2", "go test ./..."))
+	require.False(t, request("s2", "rm -rf build"))
+	require.True(t, request("s1", "go test ./..."))
+	require.Len(t, service.SessionRules("s2"), 2)
+	require.Error(t, service.SetSessionRules("s2", []config.PermissionRule{{Tool: "bash"}}))
+	require.NoError(t, service.SetSessionRules("s2", nil))
+	require.True(t, request("s2", "go test ./..."))
+
+	f, err := os.Open(auditPath)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 7)
+	require.Equal(t, "go test ./...", entries[0].Target)
+	require.True(t, entries[0].Granted)
+	require.Equal(t, "rule: allow bash go test go vet", entries[0].By)
+	require.False(t, entries[1].Granted)
+	require.Equal(t, "rule: deny bash rm -rf", entries[2].By)
+	require.Equal(t, "rule: session deny bash go test", entries[3].By)
+}
+
+func TestPermissionService_AskRules(t *testing.T) {
+	// Ask rules override skipping requests and allowed tools.
+	service := NewPermissionService("/project", true, []string{"bash"}, false, WithRules(testRules))
+	ps := service.(*permissionService)
+	done := make(chan bool)
+	go func() {
+		done <- service.Request(CreatePermissionRequest{
+			SessionID: "s1",
+			ToolName:  "bash",
+			Action:    "execute",
+			Path:      "/project",
+			Params:    map[string]string{"command": "git push"},
+		})
+	}()
+	require.Eventually(t, func() bool {
+		ps.activeRequestMu.Lock()
+		defer ps.activeRequestMu.Unlock()
+		return ps.activeRequest != nil
+	}, time.Second, 5*time.Millisecond)
+	ps.activeRequestMu.Lock()
+	req := *ps.activeRequest
+	ps.activeRequestMu.Unlock()
+	service.Deny(req)
+	require.False(t, <-done)
+
+	// Auto approved sessions have nobody to ask.
+	service.AutoApproveSession("s2")
+	require.False(t, service.Request(CreatePermissionRequest{
+		SessionID: "s2",
+		ToolName:  "bash",
+		Action:    "execute",
+		Params:    map[string]string{"command": "git push"},
+	}))
+}
@@ -0,0 +1,237 @@
+// Package recorder records sessions to bundles for the bug reports about the
+// behavior of the providers: the requests to the providers with the
+// credentials redacted, their responses as they were streamed, and the calls
+// of the tools with their results. crush replay renders the bundles again.
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/report"
+)
+
+// Dir is the directory of the data directory the bundles are written in,
+// one per session.
+const Dir = "recordings"
+
+// EntryType is the type of an entry of a bundle.
+type EntryType string
+
+const (
+	EntryPrompt     EntryType = "prompt"
+	EntryRequest    EntryType = "request"
+	EntryResponse   EntryType = "response"
+	EntryToolCall   EntryType = "tool_call"
+	EntryToolResult EntryType = "tool_result"
+)
+
+// Entry is a line of a bundle.
+type Entry struct {
+	Type      EntryType `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"session_id"`
+
+	Prompt     string              `json:"prompt,omitempty"`
+	Request    *Request            `json:"request,omitempty"`
+	Response   *Response           `json:"response,omitempty"`
+	ToolCall   *message.ToolCall   `json:"tool_call,omitempty"`
+	ToolResult *message.ToolResult `json:"tool_result,omitempty"`
+}
+
+// Request is a request to a provider.
+type Request struct {
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Tools    []string  `json:"tools,omitempty"`
+}
+
+// Message is a message of a request. The data of the attachments is left
+// out.
+type Message struct {
+	Role  message.MessageRole `json:"role"`
+	Parts []Part              `json:"parts"`
+}
+
+// Part is a part of a message, with its type.
+type Part struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Response is the response of a provider, as it was streamed.
+type Response struct {
+	Events []Event `json:"events"`
+}
+
+// Event is an event of a streamed response.
+type Event struct {
+	// Elapsed is the time since the request.
+	Elapsed      time.Duration        `json:"elapsed"`
+	Type         provider.EventType   `json:"type"`
+	Content      string               `json:"content,omitempty"`
+	Thinking     string               `json:"thinking,omitempty"`
+	ToolCall     *message.ToolCall    `json:"tool_call,omitempty"`
+	FinishReason message.FinishReason `json:"finish_reason,omitempty"`
+	Usage        *provider.TokenUsage `json:"usage,omitempty"`
+	Error        string               `json:"error,omitempty"`
+}
+
+// NewEvent returns the event of a response requested at the time.
+func NewEvent(event provider.ProviderEvent, requested time.Time) Event {
+	e := Event{
+		Elapsed:  time.Since(requested),
+		Type:     event.Type,
+		Content:  event.Content,
+		Thinking: event.Thinking,
+		ToolCall: event.ToolCall,
+	}
+	if event.Response != nil {
+		e.FinishReason = event.Response.FinishReason
+		e.Usage = &event.Response.Usage
+	}
+	if event.Error != nil {
+		e.Error = event.Error.Error()
+	}
+	return e
+}
+
+// Recorder writes the bundles of the sessions. A nil one records nothing.
+type Recorder struct {
+	dataDir  string
+	redactor *strings.Replacer
+}
+
+// mu serializes the writes of the bundles, the sub-agents record theirs
+// while their parents do.
+var mu sync.Mutex
+
+// New returns the recorder of the config, nil unless it records the
+// sessions.
+func New(cfg *config.Config) *Recorder {
+	if cfg == nil || cfg.Options == nil || !cfg.Options.Record {
+		return nil
+	}
+	return &Recorder{
+		dataDir:  cfg.Options.DataDirectory,
+		redactor: report.NewRedactor(cfg),
+	}
+}
+
+// Path returns the bundle of the session.
+func Path(dataDir, sessionID string) string {
+	return filepath.Join(dataDir, Dir, sessionID+".jsonl")
+}
+
+// Prompt records the prompt of a turn.
+func (r *Recorder) Prompt(sessionID, prompt string) {
+	r.write(Entry{Type: EntryPrompt, SessionID: sessionID, Prompt: prompt})
+}
+
+// Request records a request to the provider.
+func (r *Recorder) Request(sessionID, providerID, model string, msgs []message.Message, tools []string) {
+	if r == nil {
+		return
+	}
+	request := &Request{Provider: providerID, Model: model, Tools: tools}
+	for _, msg := range msgs {
+		request.Messages = append(request.Messages, newMessage(msg))
+	}
+	r.write(Entry{Type: EntryRequest, SessionID: sessionID, Request: request})
+}
+
+// Response records the events of the response to the last request.
+func (r *Recorder) Response(sessionID string, events []Event) {
+	r.write(Entry{Type: EntryResponse, SessionID: sessionID, Response: &Response{Events: events}})
+}
+
+// ToolCall records a tool call about to run.
+func (r *Recorder) ToolCall(sessionID string, call message.ToolCall) {
+	r.write(Entry{Type: EntryToolCall, SessionID: sessionID, ToolCall: &call})
+}
+
+// ToolResult records the result of a tool call.
+func (r *Recorder) ToolResult(sessionID string, result message.ToolResult) {
+	r.write(Entry{Type: EntryToolResult, SessionID: sessionID, ToolResult: &result})
+}
+
+func (r *Recorder) write(entry Entry) {
+	if r == nil {
+		return
+	}
+	entry.Time = time.Now()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// Escaped characters would keep the credentials holding them from being
+	// redacted.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(entry); err != nil {
+		slog.Warn("Failed to record the session", "session_id", entry.SessionID, "error", err)
+		return
+	}
+	line := r.redactor.Replace(buf.String())
+
+	mu.Lock()
+	defer mu.Unlock()
+	path := Path(r.dataDir, entry.SessionID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		slog.Warn("Failed to record the session", "session_id", entry.SessionID, "error", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		slog.Warn("Failed to record the session", "session_id", entry.SessionID, "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line); err != nil {
+		slog.Warn("Failed to record the session", "session_id", entry.SessionID, "error", err)
+	}
+}
+
+// newMessage returns the message with the types of its parts, without the
+// data of its attachments.
+func newMessage(msg message.Message) Message {
+	m := Message{Role: msg.Role, Parts: make([]Part, 0, len(msg.Parts))}
+	for _, part := range msg.Parts {
+		var tp string
+		switch p := part.(type) {
+		case message.TextContent:
+			tp = "text"
+		case message.ReasoningContent:
+			tp = "reasoning"
+		case message.ImageURLContent:
+			tp = "image_url"
+			if strings.HasPrefix(p.URL, "data:") {
+				p.URL, _, _ = strings.Cut(p.URL, ",")
+				part = p
+			}
+		case message.BinaryContent:
+			tp = "binary"
+			p.Data = nil
+			part = p
+		case message.ToolCall:
+			tp = "tool_call"
+		case message.ToolResult:
+			tp = "tool_result"
+		case message.Finish:
+			tp = "finish"
+		case message.Citations:
+			tp = "citations"
+		default:
+			continue
+		}
+		m.Parts = append(m.Parts, Part{Type: tp, Data: part})
+	}
+	return m
+}
@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	require.Nil(t, New(&config.Config{Options: &config.Options{}}))
+	require.Nil(t, New(nil))
+
+	// A nil recorder records nothing.
+	var r *Recorder
+	r.Prompt("s1", "Fix the parser")
+	r.Request("s1", "openai", "gpt-4o", nil, nil)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Setenv("RECORDER_TEST_API_KEY", "env-secret-value")
+	dataDir := t.TempDir()
+	r := New(&config.Config{
+		Providers: csync.NewMapFrom(map[string]config.ProviderConfig{
+			"openai": {ID: "openai", APIKey: "sk-config-secret"},
+		}),
+		Options: &config.Options{DataDirectory: dataDir, Record: true},
+	})
+	require.NotNil(t, r)
+
+	prompt := message.Message{Role: message.User, Parts: []message.ContentPart{
+		message.TextContent{Text: "Fix the parser, my key is sk-config-secret"},
+		message.BinaryContent{Path: "screenshot.png", MIMEType: "image/png", Data: []byte("image data")},
+	}}
+	call := message.ToolCall{ID: "call_1", Name: "view", Input: `{"file_path":"parser.go"}`, Finished: true}
+	requested := time.Now()
+
+	r.Prompt("s1", "Fix the parser, my key is sk-config-secret")
+	r.Request("s1", "openai", "gpt-4o", []message.Message{prompt}, []string{"view", "edit"})
+	r.Response("s1", []Event{
+		NewEvent(provider.ProviderEvent{Type: provider.EventThinkingDelta, Thinking: "The parser is in parser.go."}, requested),
+		NewEvent(provider.ProviderEvent{Type: provider.EventContentDelta, Content: "Let me look."}, requested),
+		NewEvent(provider.ProviderEvent{Type: provider.EventToolUseStop, ToolCall: &call}, requested),
+		NewEvent(provider.ProviderEvent{Type: provider.EventComplete, Response: &provider.ProviderResponse{
+			FinishReason: message.FinishReasonToolUse,
+			Usage:        provider.TokenUsage{InputTokens: 120, OutputTokens: 30},
+		}}, requested),
+	})
+	r.ToolCall("s1", call)
+	r.ToolResult("s1", message.ToolResult{ToolCallID: "call_1", Content: "package parser\nfunc Parse() {}\nenv-secret-value"})
+	r.Request("s1", "openai", "gpt-4o", nil, nil)
+	r.Response("s1", []Event{
+		NewEvent(provider.ProviderEvent{Type: provider.EventError, Error: errors.New("rate limited")}, requested),
+	})
+
+	data, err := os.ReadFile(Path(dataDir, "s1"))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "sk-config-secret")
+	require.NotContains(t, string(data), "env-secret-value")
+	require.NotContains(t, string(data), "aW1hZ2UgZGF0YQ==", "the data of the attachments is left out")
+
+	entries, err := Load(Path(dataDir, "s1"))
+	require.NoError(t, err)
+	require.Len(t, entries, 7)
+	require.Equal(t, EntryRequest, entries[1].Type)
+	require.Equal(t, []string{"view", "edit"}, entries[1].Request.Tools)
+	require.Equal(t, "binary", entries[1].Request.Messages[0].Parts[1].Type)
+
+	var out bytes.Buffer
+	require.NoError(t, Replay(t.Context(), &out, entries, ReplayOptions{}))
+	replay := out.String()
+	require.Contains(t, replay, "> Fix the parser, my key is [REDACTED]\n")
+	require.Contains(t, replay, "-- request 1 to openai/gpt-4o: 1 messages, 2 tools\n")
+	require.Contains(t, replay, "[thinking] The parser is in parser.go.\n[/thinking]\nLet me look.\n")
+	require.Contains(t, replay, `-> view {"file_path":"parser.go"} (call_1)`)
+	require.Contains(t, replay, "-- tool_use, 120 input and 30 output tokens after ")
+	require.Contains(t, replay, "-- running view (call_1)\n<- result of call_1:\n   package parser\n   func Parse() {}\n   [REDACTED]\n")
+	require.Contains(t, replay, "-- request 2 to openai/gpt-4o: 0 messages, 0 tools\n")
+	require.Contains(t, replay, "!! error after ")
+	require.Contains(t, replay, ": rate limited\n")
+
+	// Replays are the same every time.
+	var again bytes.Buffer
+	require.NoError(t, Replay(t.Context(), &again, entries, ReplayOptions{}))
+	require.Equal(t, replay, again.String())
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"type\":\"prompt\"}\nnot json\n"), 0o600))
+	_, err := Load(path)
+	require.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,182 @@
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/provider"
+)
+
+// maxResultLines are the lines of the tool results replayed, the others are
+// counted.
+const maxResultLines = 20
+
+// maxPause is the longest pause between the entries of realtime replays, so
+// the time the user took to write a prompt isn't waited for.
+const maxPause = 5 * time.Second
+
+// Load reads the entries of the bundle.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	// The requests hold the whole conversations.
+	scanner.Buffer(nil, 256*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// ReplayOptions configures a replay.
+type ReplayOptions struct {
+	// Realtime streams the responses at the pace they were, and pauses
+	// between the entries as long as the tools ran.
+	Realtime bool
+}
+
+// Replay renders the entries of a bundle as the session went, the same every
+// time.
+func Replay(ctx context.Context, w io.Writer, entries []Entry, opts ReplayOptions) error {
+	r := replayer{w: w, opts: opts}
+	for i, entry := range entries {
+		// The responses are recorded once streamed, their events pause
+		// instead.
+		if i > 0 && opts.Realtime && entry.Type != EntryResponse {
+			if err := sleep(ctx, min(entry.Time.Sub(entries[i-1].Time), maxPause)); err != nil {
+				return err
+			}
+		}
+		if err := r.entry(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return r.err
+}
+
+// replayer renders the entries, keeping the first write error.
+type replayer struct {
+	w        io.Writer
+	opts     ReplayOptions
+	requests int
+	err      error
+}
+
+func (r *replayer) printf(format string, args ...any) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.w, format, args...)
+	}
+}
+
+func (r *replayer) entry(ctx context.Context, entry Entry) error {
+	switch entry.Type {
+	case EntryPrompt:
+		r.printf("\n> %s\n", strings.ReplaceAll(entry.Prompt, "\n", "\n> "))
+	case EntryRequest:
+		r.requests++
+		if req := entry.Request; req != nil {
+			r.printf("\n-- request %d to %s/%s: %d messages, %d tools\n", r.requests, req.Provider, req.Model, len(req.Messages), len(req.Tools))
+		}
+	case EntryResponse:
+		if entry.Response != nil {
+			return r.response(ctx, entry.Response.Events)
+		}
+	case EntryToolCall:
+		if call := entry.ToolCall; call != nil {
+			r.printf("\n-- running %s (%s)\n", call.Name, call.ID)
+		}
+	case EntryToolResult:
+		if result := entry.ToolResult; result != nil {
+			status := "result"
+			if result.IsError {
+				status = "error"
+			}
+			r.printf("<- %s of %s:\n%s\n", status, result.ToolCallID, indent(truncateLines(result.Content, maxResultLines)))
+		}
+	}
+	return r.err
+}
+
+// response renders the events of a response, at their pace in realtime.
+func (r *replayer) response(ctx context.Context, events []Event) error {
+	var elapsed time.Duration
+	var thinking bool
+	for _, event := range events {
+		if r.opts.Realtime {
+			if err := sleep(ctx, event.Elapsed-elapsed); err != nil {
+				return err
+			}
+			elapsed = event.Elapsed
+		}
+		if thinking && event.Type != provider.EventThinkingDelta && event.Type != provider.EventSignatureDelta {
+			r.printf("\n[/thinking]\n")
+			thinking = false
+		}
+		switch event.Type {
+		case provider.EventThinkingDelta:
+			if !thinking {
+				r.printf("[thinking] ")
+				thinking = true
+			}
+			r.printf("%s", event.Thinking)
+		case provider.EventContentDelta:
+			r.printf("%s", event.Content)
+		case provider.EventToolUseStop:
+			if event.ToolCall != nil {
+				r.printf("\n-> %s %s (%s)", event.ToolCall.Name, event.ToolCall.Input, event.ToolCall.ID)
+			}
+		case provider.EventComplete:
+			r.printf("\n-- %s", event.FinishReason)
+			if event.Usage != nil {
+				r.printf(", %d input and %d output tokens", event.Usage.InputTokens, event.Usage.OutputTokens)
+			}
+			r.printf(" after %s\n", event.Elapsed.Round(time.Millisecond))
+		case provider.EventWarning:
+			r.printf("\n!! warning: %s\n", event.Error)
+		case provider.EventError:
+			r.printf("\n!! error after %s: %s\n", event.Elapsed.Round(time.Millisecond), event.Error)
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func truncateLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:n], "\n") + fmt.Sprintf("\n... %d more lines", len(lines)-n)
+}
+
+func indent(s string) string {
+	return "   " + strings.ReplaceAll(s, "\n", "\n   ")
+}
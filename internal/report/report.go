@@ -0,0 +1,357 @@
+// Package report gathers the diagnostics attached to bug reports: the
+// environment, the config, the recent logs, and the session metadata, with
+// the credentials redacted.
+package report
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/version"
+)
+
+// Redacted replaces the credentials in the report.
+const Redacted = "[REDACTED]"
+
+// Files of the archive.
+const (
+	EnvironmentFile = "environment.json"
+	ConfigFile      = "config.json"
+	LogFile         = "crush.log"
+	SessionsFile    = "sessions.json"
+)
+
+// redactedKeys are the config keys holding credentials.
+var redactedKeys = []string{"api_key", "client_secret", "extra_headers", "headers", "env", "token"}
+
+// terminalVars are the environment variables describing the terminal,
+// reported with their values.
+var terminalVars = []string{"TERM", "TERM_PROGRAM", "TERM_PROGRAM_VERSION", "COLORTERM", "SHELL", "LANG"}
+
+// reportedVars match the environment variables crush reads, reported by
+// name only.
+var reportedVars = regexp.MustCompile(`^CRUSH_|_API_KEY$|_TOKEN$|^(?i:https?_proxy|all_proxy|no_proxy)$|^(AWS|AZURE|GOOGLE|VERTEXAI|OLLAMA)_`)
+
+// secretVars match the environment variables whose values are redacted
+// wherever they show up.
+var secretVars = regexp.MustCompile(`(?i)key|token|secret|password|credential`)
+
+// minSecretLength keeps short values, such as empty or placeholder ones,
+// from being redacted all over the report.
+const minSecretLength = 8
+
+// Input is what the report is made of. Config is nil when it failed to
+// load, ConfigErr holds why, and SessionsErr why the sessions couldn't be
+// listed.
+type Input struct {
+	Config      *config.Config
+	ConfigErr   error
+	Sessions    []session.Session
+	SessionsErr error
+	// LogPath is the log file, its last LogLines lines are included.
+	LogPath  string
+	LogLines int
+}
+
+// Environment describes where crush runs.
+type Environment struct {
+	Version       string            `json:"version"`
+	GoVersion     string            `json:"go_version"`
+	OS            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Terminal      map[string]string `json:"terminal,omitempty"`
+	Variables     []string          `json:"variables,omitempty"`
+	Profile       string            `json:"profile,omitempty"`
+	ConfigError   string            `json:"config_error,omitempty"`
+	SessionsError string            `json:"sessions_error,omitempty"`
+	Models        map[string]string `json:"models,omitempty"`
+	Providers     []string          `json:"providers,omitempty"`
+	LSP           []string          `json:"lsp,omitempty"`
+	MCP           []string          `json:"mcp,omitempty"`
+}
+
+// Session is the metadata of a session. Titles are left out, they are
+// generated from the prompts.
+type Session struct {
+	ID               string    `json:"id"`
+	ParentSessionID  string    `json:"parent_session_id,omitempty"`
+	MessageCount     int64     `json:"message_count"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Write writes the report to w as a zip archive and returns the names of
+// its files.
+func Write(w io.Writer, in Input) ([]string, error) {
+	redactor := NewRedactor(in.Config)
+	files := []struct {
+		name string
+		data func() ([]byte, error)
+	}{
+		{EnvironmentFile, func() ([]byte, error) { return json.MarshalIndent(environment(in), "", "  ") }},
+		{ConfigFile, func() ([]byte, error) { return redactedConfig(in.Config) }},
+		{LogFile, func() ([]byte, error) { return tail(in.LogPath, in.LogLines) }},
+		{SessionsFile, func() ([]byte, error) { return json.MarshalIndent(sessions(in.Sessions), "", "  ") }},
+	}
+
+	zw := zip.NewWriter(w)
+	var names []string
+	for _, file := range files {
+		data, err := file.data()
+		if err != nil {
+			return nil, fmt.Errorf("failed to gather %s: %w", file.name, err)
+		}
+		if data == nil {
+			continue
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write([]byte(redactor.Replace(string(data)))); err != nil {
+			return nil, err
+		}
+		names = append(names, file.name)
+	}
+	return names, zw.Close()
+}
+
+func environment(in Input) Environment {
+	env := Environment{
+		Version:   version.Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		CreatedAt: time.Now().UTC(),
+		Terminal:  map[string]string{},
+	}
+	for _, name := range terminalVars {
+		if value := os.Getenv(name); value != "" {
+			env.Terminal[name] = value
+		}
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if reportedVars.MatchString(name) {
+			env.Variables = append(env.Variables, name)
+		}
+	}
+	sort.Strings(env.Variables)
+	if in.ConfigErr != nil {
+		env.ConfigError = in.ConfigErr.Error()
+	}
+	if in.SessionsErr != nil {
+		env.SessionsError = in.SessionsErr.Error()
+	}
+
+	cfg := in.Config
+	if cfg == nil {
+		return env
+	}
+	env.Profile = cfg.Profile()
+	env.Models = map[string]string{}
+	for kind, model := range cfg.Models {
+		env.Models[string(kind)] = model.Provider + "/" + model.Model
+	}
+	for _, p := range cfg.EnabledProviders() {
+		env.Providers = append(env.Providers, p.ID)
+	}
+	sort.Strings(env.Providers)
+	env.LSP = slices.Sorted(maps.Keys(cfg.LSP))
+	env.MCP = slices.Sorted(maps.Keys(cfg.MCP))
+	return env
+}
+
+// redactedConfig returns the resolved config with the values of the
+// credential keys and the passwords of URLs redacted.
+func redactedConfig(cfg *config.Config) ([]byte, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var value map[string]any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue("", value), "", "  ")
+}
+
+func redactValue(key string, value any) any {
+	if slices.Contains(redactedKeys, key) {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				return v
+			}
+			return Redacted
+		case map[string]any:
+			for k := range v {
+				v[k] = Redacted
+			}
+			return v
+		}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = redactValue(k, child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(key, child)
+		}
+	case string:
+		return redactURL(v)
+	}
+	return value
+}
+
+// redactURL redacts the password of URLs such as proxy ones.
+func redactURL(value string) string {
+	if !strings.Contains(value, "://") || !strings.Contains(value, "@") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
+
+// NewRedactor replaces the values of the credentials wherever they show up
+// in the report, such as in the logs, and in the recordings of the sessions.
+func NewRedactor(cfg *config.Config) *strings.Replacer {
+	var secrets []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if secretVars.MatchString(name) && len(value) >= minSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+	// Config values are redacted both as written and resolved.
+	add := func(values ...string) {
+		for _, value := range values {
+			if resolved, err := cfg.Resolve(value); err == nil && len(resolved) >= minSecretLength {
+				secrets = append(secrets, resolved)
+			}
+			if len(value) >= minSecretLength && !strings.HasPrefix(value, "$") {
+				secrets = append(secrets, value)
+			}
+		}
+	}
+	if cfg != nil {
+		proxies := []*config.Proxy{cfg.Options.Proxy}
+		for p := range cfg.Providers.Seq() {
+			add(p.APIKey)
+			add(slices.Collect(maps.Values(p.ExtraHeaders))...)
+			proxies = append(proxies, p.Proxy)
+		}
+		for _, proxy := range proxies {
+			if proxy == nil {
+				continue
+			}
+			if u, err := url.Parse(proxy.URL); err == nil && u.User != nil {
+				password, _ := u.User.Password()
+				add(password)
+			}
+		}
+		for _, mcp := range cfg.MCP {
+			add(slices.Collect(maps.Values(mcp.Env))...)
+			add(slices.Collect(maps.Values(mcp.Headers))...)
+			if mcp.OAuth != nil {
+				add(mcp.OAuth.ClientSecret)
+			}
+		}
+		for _, hook := range cfg.Webhooks {
+			add(hook.URL)
+			add(slices.Collect(maps.Values(hook.Headers))...)
+		}
+		if cfg.Serve != nil {
+			for _, user := range cfg.Serve.Users {
+				add(user.Token)
+			}
+		}
+	}
+	// Longer secrets first, in case one holds another.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	var oldnew []string
+	for _, secret := range slices.Compact(secrets) {
+		oldnew = append(oldnew, secret, Redacted)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+// tail returns the last lines of the file, nil when it doesn't exist.
+func tail(path string, lines int) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		last = append(last, scanner.Text())
+		if lines > 0 && len(last) > lines {
+			last = last[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(last) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(last, "\n") + "\n"), nil
+}
+
+func sessions(list []session.Session) []Session {
+	out := make([]Session, 0, len(list))
+	for _, s := range list {
+		out = append(out, Session{
+			ID:               s.ID,
+			ParentSessionID:  s.ParentSessionID,
+			MessageCount:     s.MessageCount,
+			PromptTokens:     s.PromptTokens,
+			CompletionTokens: s.CompletionTokens,
+			Cost:             s.Cost,
+			CreatedAt:        time.Unix(s.CreatedAt, 0).UTC(),
+			UpdatedAt:        time.Unix(s.UpdatedAt, 0).UTC(),
+		})
+	}
+	return out
+}
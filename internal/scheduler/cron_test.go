@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_Next(t *testing.T) {
+	t.Parallel()
+
+	// A Wednesday.
+	from := time.Date(2025, time.July, 30, 10, 17, 42, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, time.July, 30, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.July, 30, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2025, time.July, 30, 10, 25, 0, 0, time.UTC)},
+		{"0 7 * * 1", time.Date(2025, time.August, 4, 7, 0, 0, 0, time.UTC)},
+		{"0 7 * * 7", time.Date(2025, time.August, 3, 7, 0, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2025, time.July, 30, 11, 0, 0, 0, time.UTC)},
+		{"30 8 1,15 * *", time.Date(2025, time.August, 1, 8, 30, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted.
+		{"0 0 1 * 4", time.Date(2025, time.July, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, time.July, 31, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+			spec, err := ParseSpec(tt.spec)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, spec.Next(from))
+		})
+	}
+}
+
+func TestParseSpec_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"a * * * *",
+	} {
+		_, err := ParseSpec(spec)
+		require.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,237 @@
+// Package scheduler runs prompts and custom commands headless, on cron
+// schedules or once in the background, while crush runs.
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// Scheduler runs the tasks of the project. Tasks are read from disk every
+// minute, so tasks added while it runs are picked up. Both crush and crush
+// serve run one, a task is claimed in the file before it runs so only one of
+// them runs it.
+type Scheduler struct {
+	*pubsub.Broker[Task]
+	app  *app.App
+	path string
+	// running are the IDs of the tasks running, a task isn't started again
+	// while its previous run is still going.
+	running *csync.Map[string, bool]
+	// wake starts the queued tasks without waiting for the next minute.
+	wake chan struct{}
+}
+
+func New(app *app.App) *Scheduler {
+	return &Scheduler{
+		Broker:  pubsub.NewBroker[Task](),
+		app:     app,
+		path:    TasksPath(app.Config()),
+		running: csync.NewMap[string, bool](),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Enqueue adds the task and, if it's queued, starts it right away.
+func (s *Scheduler) Enqueue(task Task) error {
+	tasks, err := LoadTasks(s.path)
+	if err != nil {
+		return err
+	}
+	if err := SaveTasks(s.path, append(tasks, task)); err != nil {
+		return err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run runs tasks as they are due until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	last := time.Now().Truncate(time.Minute)
+	// Run the tasks queued while crush wasn't running.
+	s.runDue(ctx, last, last)
+	for {
+		next := last.Add(time.Minute)
+		select {
+		case <-time.After(time.Until(next)):
+		case <-s.wake:
+			// Only the queued tasks are due.
+			s.runDue(ctx, last, last)
+			continue
+		case <-ctx.Done():
+			return
+		}
+		// If the machine slept through several runs, they're only run once.
+		now := time.Now().Truncate(time.Minute)
+		s.runDue(ctx, last, now)
+		last = now
+	}
+}
+
+// runDue claims and starts the tasks due after last, up to now.
+func (s *Scheduler) runDue(ctx context.Context, last, now time.Time) {
+	tasks, err := LoadTasks(s.path)
+	if err != nil {
+		slog.Error("Failed to load scheduled tasks", "error", err)
+		return
+	}
+	var claimed []Task
+	for i, task := range tasks {
+		_, due, err := task.due(last, now)
+		if err != nil {
+			slog.Error("Invalid scheduled task", "id", task.ID, "error", err)
+			continue
+		}
+		if !due {
+			continue
+		}
+		if _, ok := s.running.Get(task.ID); ok {
+			slog.Warn("Skipping scheduled task, previous run still going", "id", task.ID)
+			continue
+		}
+		tasks[i].LastRun = &Run{StartedAt: time.Now()}
+		claimed = append(claimed, tasks[i])
+	}
+	if len(claimed) == 0 {
+		return
+	}
+	if err := SaveTasks(s.path, tasks); err != nil {
+		slog.Error("Failed to claim scheduled tasks", "error", err)
+		return
+	}
+	for _, task := range claimed {
+		s.running.Set(task.ID, true)
+		go func() {
+			defer s.running.Del(task.ID)
+			s.runTask(ctx, task)
+		}()
+	}
+}
+
+func (s *Scheduler) runTask(ctx context.Context, task Task) {
+	slog.Info("Running scheduled task", "id", task.ID, "name", task.Name())
+	run := *task.LastRun
+	result, err := s.run(ctx, task, &run)
+	run.FinishedAt = time.Now()
+	if err != nil {
+		slog.Error("Scheduled task failed", "id", task.ID, "error", err)
+		run.Error = err.Error()
+	}
+
+	if err := s.saveRun(task.ID, run); err != nil {
+		slog.Error("Failed to save scheduled task run", "id", task.ID, "error", err)
+	}
+	task.LastRun = &run
+	s.Publish(pubsub.UpdatedEvent, task)
+	if task.Webhook != "" {
+		if err := notify(ctx, task, run, result); err != nil {
+			slog.Error("Failed to notify scheduled task webhook", "id", task.ID, "error", err)
+		}
+	}
+}
+
+// run runs the task in a new session and returns the agent's answer.
+func (s *Scheduler) run(ctx context.Context, task Task, run *Run) (string, error) {
+	if s.app.CoderAgent == nil {
+		return "", errors.New("no providers configured")
+	}
+	prompt, err := task.ResolvePrompt(config.Get())
+	if err != nil {
+		return "", err
+	}
+	title := "Scheduled: " + task.Name()
+	if task.Queued() {
+		title = "Background: " + task.Name()
+	}
+	sess, err := s.app.Sessions.Create(ctx, title)
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	run.SessionID = sess.ID
+	// Nobody is there to answer permission requests.
+	s.app.Permissions.AutoApproveSession(sess.ID)
+
+	done, err := s.app.CoderAgent.Run(ctx, sess.ID, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to start agent: %w", err)
+	}
+	result := <-done
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return result.Message.Content().String(), nil
+}
+
+// saveRun records the run of the task, re-reading the tasks so changes made
+// while it ran aren't lost.
+func (s *Scheduler) saveRun(id string, run Run) error {
+	tasks, err := LoadTasks(s.path)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		if tasks[i].ID == id {
+			tasks[i].LastRun = &run
+			return SaveTasks(s.path, tasks)
+		}
+	}
+	// The task was removed while it ran.
+	return nil
+}
+
+type notification struct {
+	// Text is a summary, for chat webhooks such as Slack's.
+	Text      string `json:"text"`
+	TaskID    string `json:"task_id"`
+	SessionID string `json:"session_id,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func notify(ctx context.Context, task Task, run Run, result string) error {
+	n := notification{
+		Text:      fmt.Sprintf("Task %s finished", task.Name()),
+		TaskID:    task.ID,
+		SessionID: run.SessionID,
+		Result:    result,
+		Error:     run.Error,
+	}
+	if run.Error != "" {
+		n.Text = fmt.Sprintf("Task %s failed: %s", task.Name(), run.Error)
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+const tasksFilename = "schedules.json"
+
+// Task is a prompt, or a custom command, run headless on a schedule, or once
+// in the background when it has no schedule.
+type Task struct {
+	ID string `json:"id"`
+	// Spec is the cron schedule of the task, empty for a queued task.
+	Spec string `json:"spec,omitempty"`
+	// Command is the ID of a custom command, e.g. user:weekly-deps-report.
+	// The command is read when the task runs, so edits to it apply.
+	Command string `json:"command,omitempty"`
+	Prompt  string `json:"prompt,omitempty"`
+	// Args fill the $NAME placeholders of the command.
+	Args map[string]string `json:"args,omitempty"`
+	// Webhook is notified with the result of every run.
+	Webhook   string    `json:"webhook,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastRun   *Run      `json:"last_run,omitempty"`
+}
+
+// Run is the outcome of a task run, FinishedAt is zero while it runs.
+type Run struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	SessionID  string    `json:"session_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Name returns a short description of the task.
+func (t Task) Name() string {
+	if t.Command != "" {
+		return t.Command
+	}
+	const maxLength = 50
+	if len(t.Prompt) > maxLength {
+		return t.Prompt[:maxLength] + "..."
+	}
+	return t.Prompt
+}
+
+// Queued reports whether the task runs once, in the background, rather than
+// on a schedule.
+func (t Task) Queued() bool {
+	return t.Spec == ""
+}
+
+// Running reports whether the last run of the task hasn't finished.
+func (t Task) Running() bool {
+	return t.LastRun != nil && t.LastRun.FinishedAt.IsZero()
+}
+
+// due returns when the task was due after last, up to now, and whether it is
+// due. A queued task is due until it's run, a scheduled task is due unless it
+// was already run since, by another crush.
+func (t Task) due(last, now time.Time) (time.Time, bool, error) {
+	if t.Queued() {
+		return t.CreatedAt, t.LastRun == nil, nil
+	}
+	spec, err := ParseSpec(t.Spec)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	next := spec.Next(last)
+	if next.IsZero() || next.After(now) {
+		return time.Time{}, false, nil
+	}
+	if t.LastRun != nil && !t.LastRun.StartedAt.Before(next) {
+		return next, false, nil
+	}
+	return next, true, nil
+}
+
+var namedArgPattern = regexp.MustCompile(`\$([A-Z][A-Z0-9_]*)`)
+
+// ResolvePrompt returns the prompt the task runs, reading its command and
+// filling in its arguments.
+func (t Task) ResolvePrompt(cfg *config.Config) (string, error) {
+	if t.Command == "" {
+		return t.Prompt, nil
+	}
+	content, err := readCommand(cfg, t.Command)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	content = namedArgPattern.ReplaceAllStringFunc(content, func(placeholder string) string {
+		name := placeholder[1:]
+		if value, ok := t.Args[name]; ok {
+			return value
+		}
+		if !slices.Contains(missing, name) {
+			missing = append(missing, name)
+		}
+		return placeholder
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("command %s is missing arguments: %s", t.Command, strings.Join(missing, ", "))
+	}
+	return content, nil
+}
+
+// readCommand reads the custom command with the given ID. Without a prefix,
+// project commands take precedence over user commands.
+func readCommand(cfg *config.Config, id string) (string, error) {
+	for _, source := range slices.Backward(cfg.CommandSources()) {
+		name, ok := strings.CutPrefix(id, source.Prefix)
+		if !ok && (strings.HasPrefix(id, config.UserCommandPrefix) || strings.HasPrefix(id, config.ProjectCommandPrefix)) {
+			continue
+		}
+		path := filepath.Join(source.Path, filepath.Join(strings.Split(name, ":")...)+".md")
+		content, err := os.ReadFile(path)
+		if err == nil {
+			return string(content), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read command %s: %w", id, err)
+		}
+	}
+	return "", fmt.Errorf("command %s not found", id)
+}
+
+// TasksPath returns the path of the file the scheduled tasks of the project
+// are stored in.
+func TasksPath(cfg *config.Config) string {
+	return filepath.Join(cfg.Options.DataDirectory, tasksFilename)
+}
+
+// LoadTasks reads the scheduled tasks from path.
+func LoadTasks(path string) ([]Task, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduled tasks: %w", err)
+	}
+	var tasks []Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduled tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// SaveTasks writes the scheduled tasks to path.
+func SaveTasks(path string, tasks []Task) error {
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled tasks: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for scheduled tasks: %w", err)
+	}
+	// The daemon and the CLI both write the file, replace it atomically so
+	// neither reads it half written.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write scheduled tasks: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write scheduled tasks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_ResolvePrompt(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cfg := &config.Config{Options: &config.Options{DataDirectory: t.TempDir()}}
+
+	userDir := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "crush", "commands")
+	projectDir := filepath.Join(cfg.Options.DataDirectory, "commands")
+	for path, content := range map[string]string{
+		filepath.Join(userDir, "report.md"):           "user report",
+		filepath.Join(userDir, "git", "triage.md"):    "triage issues labeled $LABEL since $SINCE",
+		filepath.Join(projectDir, "report.md"):        "project report",
+		filepath.Join(projectDir, "deps", "check.md"): "check deps",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	tests := []struct {
+		name    string
+		task    Task
+		want    string
+		wantErr string
+	}{
+		{name: "prompt", task: Task{Prompt: "hello"}, want: "hello"},
+		{name: "project command wins", task: Task{Command: "report"}, want: "project report"},
+		{name: "user command", task: Task{Command: "user:report"}, want: "user report"},
+		{name: "nested command", task: Task{Command: "project:deps:check"}, want: "check deps"},
+		{
+			name: "arguments",
+			task: Task{Command: "git:triage", Args: map[string]string{"LABEL": "bug", "SINCE": "monday"}},
+			want: "triage issues labeled bug since monday",
+		},
+		{
+			name:    "missing arguments",
+			task:    Task{Command: "git:triage", Args: map[string]string{"LABEL": "bug"}},
+			wantErr: "command git:triage is missing arguments: SINCE",
+		},
+		{name: "unknown command", task: Task{Command: "project:git:triage"}, wantErr: "command project:git:triage not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.task.ResolvePrompt(cfg)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSaveTasks(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "crush", tasksFilename)
+	tasks, err := LoadTasks(path)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+
+	want := []Task{
+		{ID: "a", Spec: "@daily", Prompt: "hello"},
+		{ID: "b", Spec: "0 7 * * 1", Command: "report", Args: map[string]string{"SINCE": "monday"}},
+	}
+	require.NoError(t, SaveTasks(path, want))
+	tasks, err = LoadTasks(path)
+	require.NoError(t, err)
+	require.Equal(t, want, tasks)
+}
+
+func TestTask_due(t *testing.T) {
+	t.Parallel()
+
+	last := time.Date(2025, time.July, 30, 6, 59, 0, 0, time.UTC)
+	now := last.Add(time.Minute)
+	tests := []struct {
+		name string
+		task Task
+		want bool
+	}{
+		{name: "queued", task: Task{Prompt: "lint"}, want: true},
+		{name: "queued and run", task: Task{Prompt: "lint", LastRun: &Run{StartedAt: last}}},
+		{name: "scheduled", task: Task{Spec: "0 7 * * *"}, want: true},
+		{name: "not scheduled yet", task: Task{Spec: "0 8 * * *"}},
+		{name: "run before", task: Task{Spec: "0 7 * * *", LastRun: &Run{StartedAt: now.AddDate(0, 0, -1)}}, want: true},
+		{name: "claimed by another crush", task: Task{Spec: "0 7 * * *", LastRun: &Run{StartedAt: now.Add(time.Second)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, due, err := tt.task.due(last, now)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, due)
+		})
+	}
+
+	_, _, err := Task{Spec: "every day"}.due(last, now)
+	require.Error(t, err)
+}
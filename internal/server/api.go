@@ -0,0 +1,492 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+type sessionView struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	MessageCount     int64   `json:"message_count"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	CacheCost        float64 `json:"cache_cost"`
+	ReasoningCost    float64 `json:"reasoning_cost"`
+	UserID           string  `json:"user_id,omitempty"`
+	// Controller is the user driving the session, the owner unless they
+	// handed over control.
+	Controller string   `json:"controller,omitempty"`
+	Watchers   []string `json:"watchers,omitempty"`
+	Busy       bool     `json:"busy"`
+	CreatedAt  int64    `json:"created_at"`
+	UpdatedAt  int64    `json:"updated_at"`
+}
+
+type toolCallView struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Input    string `json:"input"`
+	Finished bool   `json:"finished"`
+}
+
+type toolResultView struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error"`
+}
+
+type messageView struct {
+	ID          string           `json:"id"`
+	SessionID   string           `json:"session_id"`
+	Role        string           `json:"role"`
+	Content     string           `json:"content,omitempty"`
+	Reasoning   string           `json:"reasoning,omitempty"`
+	ToolCalls   []toolCallView   `json:"tool_calls,omitempty"`
+	ToolResults []toolResultView `json:"tool_results,omitempty"`
+	Model       string           `json:"model,omitempty"`
+	Finished    bool             `json:"finished"`
+	CreatedAt   int64            `json:"created_at"`
+}
+
+// event is sent to the dashboard over server-sent events.
+type event struct {
+	Type    string `json:"type"`
+	Payload any    `json:"payload"`
+}
+
+func (s *Server) sessionView(sess session.Session) sessionView {
+	controller, watchers := s.live.state(sess.ID)
+	return sessionView{
+		ID:               sess.ID,
+		Title:            sess.Title,
+		MessageCount:     sess.MessageCount,
+		PromptTokens:     sess.PromptTokens,
+		CompletionTokens: sess.CompletionTokens,
+		Cost:             sess.Cost,
+		CacheCost:        sess.CacheCost,
+		ReasoningCost:    sess.ReasoningCost,
+		UserID:           sess.UserID,
+		Controller:       cmp.Or(controller, sess.UserID),
+		Watchers:         watchers,
+		Busy:             s.app.CoderAgent != nil && s.app.CoderAgent.IsSessionBusy(sess.ID),
+		CreatedAt:        sess.CreatedAt,
+		UpdatedAt:        sess.UpdatedAt,
+	}
+}
+
+func newMessageView(msg message.Message) messageView {
+	view := messageView{
+		ID:        msg.ID,
+		SessionID: msg.SessionID,
+		Role:      string(msg.Role),
+		Content:   msg.Content().String(),
+		Reasoning: msg.ReasoningContent().Thinking,
+		Model:     msg.Model,
+		Finished:  msg.IsFinished(),
+		CreatedAt: msg.CreatedAt,
+	}
+	for _, tc := range msg.ToolCalls() {
+		view.ToolCalls = append(view.ToolCalls, toolCallView{
+			ID:       tc.ID,
+			Name:     tc.Name,
+			Input:    tc.Input,
+			Finished: tc.Finished,
+		})
+	}
+	for _, tr := range msg.ToolResults() {
+		view.ToolResults = append(view.ToolResults, toolResultView{
+			ToolCallID: tr.ToolCallID,
+			Name:       tr.Name,
+			Content:    tr.Content,
+			IsError:    tr.IsError,
+		})
+	}
+	return view
+}
+
+type meView struct {
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"`
+	// Watching is the session of a read-only viewer.
+	Watching string  `json:"watching,omitempty"`
+	Admin    bool    `json:"admin"`
+	Budget   float64 `json:"budget,omitempty"`
+	Spent    float64 `json:"spent"`
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	spent, err := s.spent(r.Context(), user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list sessions: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, meView{
+		Name:     user.Name,
+		Role:     user.Role,
+		Watching: user.link,
+		Admin:    user.admin(),
+		Budget:   user.Budget,
+		Spent:    spent,
+	})
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	sessions, err := s.app.Sessions.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list sessions: %v", err))
+		return
+	}
+	views := make([]sessionView, 0, len(sessions))
+	for _, sess := range sessions {
+		// Task and title sessions are shown as part of their parent.
+		if sess.ParentSessionID != "" {
+			continue
+		}
+		if !s.canAccess(r.Context(), user, sess.ID) {
+			continue
+		}
+		views = append(views, s.sessionView(sess))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+type createSessionRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	if s.app.CoderAgent == nil {
+		writeError(w, http.StatusServiceUnavailable, "no providers configured")
+		return
+	}
+	var req createSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	user := userFrom(r.Context())
+	if user.link != "" {
+		writeError(w, http.StatusForbidden, "viewers can't start sessions")
+		return
+	}
+	if !s.withinBudget(w, r, user) {
+		return
+	}
+
+	title := sessionTitle(req.Prompt)
+	sess, err := s.app.Sessions.CreateForUser(r.Context(), title, user.Name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to create session: %v", err))
+		return
+	}
+	s.owners.Set(sess.ID, user.Name)
+	s.audit(user, "session_created", sess.ID, title)
+	if err := s.run(sess.ID, req.Prompt); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to start agent: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.sessionView(sess))
+}
+
+func sessionTitle(prompt string) string {
+	const maxTitleLength = 100
+	if runes := []rune(prompt); len(runes) > maxTitleLength {
+		return string(runes[:maxTitleLength]) + "..."
+	}
+	return prompt
+}
+
+// withinBudget reports whether the user can start another run, writing the
+// error response when not.
+func (s *Server) withinBudget(w http.ResponseWriter, r *http.Request, user *User) bool {
+	if status, msg := s.budgetError(r.Context(), user); status != 0 {
+		writeError(w, status, msg)
+		return false
+	}
+	return true
+}
+
+// budgetError returns the status and message of the error response when
+// the user can't start another run, 0 when they can.
+func (s *Server) budgetError(ctx context.Context, user *User) (int, string) {
+	if user.Budget <= 0 {
+		return 0, ""
+	}
+	spent, err := s.spent(ctx, user)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Sprintf("failed to list sessions: %v", err)
+	}
+	if spent >= user.Budget {
+		s.audit(user, "budget_exceeded", "", fmt.Sprintf("spent $%.2f of $%.2f", spent, user.Budget))
+		return http.StatusPaymentRequired, fmt.Sprintf("budget of $%.2f reached, $%.2f spent", user.Budget, spent)
+	}
+	return 0, ""
+}
+
+// run starts the agent on the prompt in the session. The run outlives the
+// request, it's supervised through the dashboard.
+func (s *Server) run(sessionID, prompt string) error {
+	done, err := s.app.CoderAgent.Run(s.ctx, sessionID, prompt)
+	if err != nil {
+		return err
+	}
+	go func() {
+		result := <-done
+		if result.Error != nil && !errors.Is(result.Error, agent.ErrRequestCancelled) {
+			slog.Error("Server: agent run failed", "session_id", sessionID, "error", result.Error)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) handleListMessages(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if !s.canAccess(r.Context(), userFrom(r.Context()), sessionID) {
+		writeError(w, http.StatusNotFound, "no session with this ID")
+		return
+	}
+	msgs, err := s.app.Messages.List(r.Context(), sessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to list messages: %v", err))
+		return
+	}
+	views := make([]messageView, 0, len(msgs))
+	for _, msg := range msgs {
+		views = append(views, newMessageView(msg))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *Server) handleListPermissions(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	pending := slices.DeleteFunc(slices.Collect(s.pending.Seq()), func(perm permission.PermissionRequest) bool {
+		return !s.canAccess(r.Context(), user, perm.SessionID)
+	})
+	if pending == nil {
+		pending = []permission.PermissionRequest{}
+	}
+	writeJSON(w, http.StatusOK, pending)
+}
+
+type answerPermissionRequest struct {
+	// Allow grants the permission, otherwise it's denied.
+	Allow bool `json:"allow"`
+}
+
+func (s *Server) handleAnswerPermission(w http.ResponseWriter, r *http.Request) {
+	var req answerPermissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	user := userFrom(r.Context())
+	perm, ok := s.pending.Get(r.PathValue("id"))
+	if !ok || !s.canAccess(r.Context(), user, perm.SessionID) {
+		writeError(w, http.StatusNotFound, "no pending permission request with this ID")
+		return
+	}
+	if !s.canApprove(r.Context(), user, perm.SessionID) {
+		writeError(w, http.StatusForbidden, "your role can't answer this permission request")
+		return
+	}
+	if _, ok := s.pending.Take(perm.ToolCallID); !ok {
+		writeError(w, http.StatusNotFound, "no pending permission request with this ID")
+		return
+	}
+	if req.Allow {
+		s.app.Permissions.Grant(perm)
+		s.audit(user, "permission_granted", perm.SessionID, permissionDetail(perm))
+	} else {
+		s.app.Permissions.Deny(perm)
+		s.audit(user, "permission_denied", perm.SessionID, permissionDetail(perm))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sessionRulesRequest struct {
+	Rules []config.PermissionRule `json:"rules"`
+}
+
+// handleGetSessionRules returns the permission rules overriding the ones of
+// the config for the session.
+func (s *Server) handleGetSessionRules(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	sessionID := r.PathValue("id")
+	if !s.canAccess(r.Context(), user, sessionID) {
+		writeError(w, http.StatusNotFound, "no session with this ID")
+		return
+	}
+	rules := s.app.Permissions.SessionRules(sessionID)
+	if rules == nil {
+		rules = []config.PermissionRule{}
+	}
+	writeJSON(w, http.StatusOK, sessionRulesRequest{Rules: rules})
+}
+
+// handleSetSessionRules replaces the permission rules of the session. Only
+// users controlling it and answering its permission requests can, within
+// their policy.
+func (s *Server) handleSetSessionRules(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	user := userFrom(r.Context())
+	if !s.canApprove(r.Context(), user, sessionID) {
+		writeError(w, http.StatusForbidden, "your role can't answer the permission requests of this session")
+		return
+	}
+	var req sessionRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	// Session rules are checked before the policy of the user, so they
+	// can't allow what it denies.
+	for _, rule := range req.Rules {
+		if !user.allowsRule(rule) {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("your policy doesn't allow %s", rule.Tool))
+			return
+		}
+	}
+	if err := s.app.Permissions.SetSessionRules(sessionID, req.Rules); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	detail, _ := json.Marshal(req.Rules)
+	s.audit(user, "permission_rules_set", sessionID, string(detail))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEvents streams session, message, and permission changes, and the
+// events of the agent loop, as server-sent events. Users only get the events
+// of their sessions.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	ctx := r.Context()
+	user := userFrom(ctx)
+	// Notifications only carry the tool call ID, remember the permission
+	// requests sent.
+	sentPermissions := map[string]bool{}
+	sessions := s.app.Sessions.Subscribe(ctx)
+	messages := s.app.Messages.Subscribe(ctx)
+	permissions := s.app.Permissions.Subscribe(ctx)
+	notifications := s.app.Permissions.SubscribeNotifications(ctx)
+	var loopEvents <-chan pubsub.Event[agent.LoopEvent]
+	if s.app.CoderAgent != nil {
+		loopEvents = s.app.CoderAgent.SubscribeLoop(ctx)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(e event) bool {
+		data, err := json.Marshal(e)
+		if err != nil {
+			slog.Error("Server: failed to marshal event", "error", err)
+			return true
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	for {
+		var e event
+		select {
+		case ev, ok := <-sessions:
+			if !ok {
+				return
+			}
+			if !s.canAccess(ctx, user, ev.Payload.ID) {
+				continue
+			}
+			e = event{Type: "session_" + string(ev.Type), Payload: s.sessionView(ev.Payload)}
+		case ev, ok := <-messages:
+			if !ok {
+				return
+			}
+			if !s.canAccess(ctx, user, ev.Payload.SessionID) {
+				continue
+			}
+			e = event{Type: "message_" + string(ev.Type), Payload: newMessageView(ev.Payload)}
+		case ev, ok := <-permissions:
+			if !ok {
+				return
+			}
+			if !s.canAccess(ctx, user, ev.Payload.SessionID) {
+				continue
+			}
+			sentPermissions[ev.Payload.ToolCallID] = true
+			e = event{Type: "permission_requested", Payload: ev.Payload}
+		case ev, ok := <-notifications:
+			if !ok {
+				return
+			}
+			if !ev.Payload.Granted && !ev.Payload.Denied {
+				continue
+			}
+			if !sentPermissions[ev.Payload.ToolCallID] {
+				continue
+			}
+			delete(sentPermissions, ev.Payload.ToolCallID)
+			e = event{Type: "permission_answered", Payload: ev.Payload}
+		case ev, ok := <-loopEvents:
+			if !ok {
+				return
+			}
+			if !s.canAccess(ctx, user, ev.Payload.SessionID) {
+				continue
+			}
+			e = event{Type: "agent_" + string(ev.Payload.Type), Payload: ev.Payload}
+		case <-ctx.Done():
+			return
+		}
+		if !send(e) {
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Server: failed to write response", "error", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
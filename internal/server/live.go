@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+)
+
+// live tracks the sessions shared for live collaboration: the users watching
+// them, the read-only links, and who drives them once control was handed
+// over. Sessions are shared as a whole, by root session ID.
+type live struct {
+	mu sync.Mutex
+	// watchers are the users besides the owner who see the sessions.
+	watchers map[string][]string
+	// controllers of the sessions handed over. Others are driven by their
+	// owner.
+	controllers map[string]string
+	// links are the tokens of read-only viewers, to the session they watch.
+	links map[string]string
+}
+
+func newLive() *live {
+	return &live{
+		watchers:    map[string][]string{},
+		controllers: map[string]string{},
+		links:       map[string]string{},
+	}
+}
+
+// state returns who controls the session, if handed over, and who watches
+// it.
+func (l *live) state(sessionID string) (controller string, watchers []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.controllers[sessionID], slices.Clone(l.watchers[sessionID])
+}
+
+func (l *live) watching(sessionID, name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Contains(l.watchers[sessionID], name)
+}
+
+func (l *live) addWatcher(sessionID, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !slices.Contains(l.watchers[sessionID], name) {
+		l.watchers[sessionID] = append(l.watchers[sessionID], name)
+	}
+}
+
+func (l *live) removeWatcher(sessionID, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.watchers[sessionID] = slices.DeleteFunc(l.watchers[sessionID], func(watcher string) bool {
+		return watcher == name
+	})
+}
+
+func (l *live) setController(sessionID, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.controllers[sessionID] = name
+}
+
+func (l *live) controller(sessionID string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.controllers[sessionID]
+}
+
+func (l *live) addLink(token, sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.links[token] = sessionID
+}
+
+func (l *live) revokeLinks(sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	maps.DeleteFunc(l.links, func(_, linked string) bool {
+		return linked == sessionID
+	})
+}
+
+// linkSession returns the session the link token gives access to, if any.
+func (l *live) linkSession(token string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for linkToken, sessionID := range l.links {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(linkToken)) == 1 {
+			return sessionID
+		}
+	}
+	return ""
+}
+
+// root returns the session shown for task and title sessions, their parent.
+func (s *Server) root(ctx context.Context, sessionID string) (string, error) {
+	if root, ok := s.roots.Get(sessionID); ok {
+		return root, nil
+	}
+	sess, err := s.app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	root := sess.ID
+	if sess.ParentSessionID != "" {
+		if root, err = s.root(ctx, sess.ParentSessionID); err != nil {
+			return "", err
+		}
+	}
+	s.roots.Set(sessionID, root)
+	return root, nil
+}
+
+// controller returns the user driving the session: whoever it was handed
+// over to, or its owner.
+func (s *Server) controller(ctx context.Context, sessionID string) (string, error) {
+	root, err := s.root(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	if controller := s.live.controller(root); controller != "" {
+		return controller, nil
+	}
+	return s.owner(ctx, sessionID)
+}
+
+// canControl reports whether the user drives the session: sends prompts,
+// shares it, and hands over control.
+func (s *Server) canControl(ctx context.Context, user *User, sessionID string) bool {
+	if user.admin() {
+		return true
+	}
+	if user.link != "" {
+		return false
+	}
+	controller, err := s.controller(ctx, sessionID)
+	return err == nil && controller == user.Name
+}
+
+// controlledSession returns the root of the session of the request if the
+// user controls it, writing the error response otherwise.
+func (s *Server) controlledSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	ctx := r.Context()
+	user := userFrom(ctx)
+	sessionID := r.PathValue("id")
+	if !s.canAccess(ctx, user, sessionID) {
+		writeError(w, http.StatusNotFound, "no session with this ID")
+		return "", false
+	}
+	if !s.canControl(ctx, user, sessionID) {
+		writeError(w, http.StatusForbidden, "only the user in control of the session can do this")
+		return "", false
+	}
+	root, err := s.root(ctx, sessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get session: %v", err))
+		return "", false
+	}
+	return root, true
+}
+
+type promptRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+// handlePrompt continues the session with another prompt.
+func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	if s.app.CoderAgent == nil {
+		writeError(w, http.StatusServiceUnavailable, "no providers configured")
+		return
+	}
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	var req promptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	// The cost of the session counts towards its owner.
+	owner, err := s.owner(r.Context(), sessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get session: %v", err))
+		return
+	}
+	if ownerUser := s.userByName(owner); ownerUser != nil && !s.withinBudget(w, r, ownerUser) {
+		return
+	}
+	user := userFrom(r.Context())
+	if err := s.run(sessionID, req.Prompt); err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to start agent: %v", err))
+		return
+	}
+	s.audit(user, "session_prompted", sessionID, req.Prompt)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type watcherRequest struct {
+	User string `json:"user"`
+}
+
+// handleAddWatcher lets another user watch the session.
+func (s *Server) handleAddWatcher(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	var req watcherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if s.userByName(req.User) == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("no user named %q", req.User))
+		return
+	}
+	s.live.addWatcher(sessionID, req.User)
+	s.audit(userFrom(r.Context()), "watcher_added", sessionID, req.User)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleRemoveWatcher(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("user")
+	if s.live.controller(sessionID) == name {
+		writeError(w, http.StatusConflict, "the user is in control of the session, hand over control first")
+		return
+	}
+	s.live.removeWatcher(sessionID, name)
+	s.audit(userFrom(r.Context()), "watcher_removed", sessionID, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type linkView struct {
+	Token string `json:"token"`
+	// URL opens the dashboard on the session, relative to the server.
+	URL string `json:"url"`
+}
+
+// handleCreateLink creates a link for a read-only viewer of the session.
+func (s *Server) handleCreateLink(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to generate token: %v", err))
+		return
+	}
+	token := hex.EncodeToString(b)
+	s.live.addLink(token, sessionID)
+	s.audit(userFrom(r.Context()), "link_created", sessionID, "")
+	writeJSON(w, http.StatusCreated, linkView{Token: token, URL: "/#watch=" + token})
+}
+
+func (s *Server) handleRevokeLinks(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	s.live.revokeLinks(sessionID)
+	s.audit(userFrom(r.Context()), "links_revoked", sessionID, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHandover hands over control of the session to its owner or one of
+// its watchers, who all keep watching.
+func (s *Server) handleHandover(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.controlledSession(w, r)
+	if !ok {
+		return
+	}
+	var req watcherRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	ctx := r.Context()
+	owner, err := s.owner(ctx, sessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get session: %v", err))
+		return
+	}
+	if req.User != owner && !s.live.watching(sessionID, req.User) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s isn't watching the session", req.User))
+		return
+	}
+	previous, err := s.controller(ctx, sessionID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get session: %v", err))
+		return
+	}
+	s.live.setController(sessionID, req.User)
+	s.audit(userFrom(ctx), "control_handed_over", sessionID, fmt.Sprintf("from %s to %s", previous, req.User))
+	w.WriteHeader(http.StatusNoContent)
+}
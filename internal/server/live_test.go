@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Spectating(t *testing.T) {
+	var audit bytes.Buffer
+	s, h := newUsersTestServer(t, &audit, agenttest.WithPermissionRequests())
+	h.Large.SetFallback(agenttest.Text("Done."))
+
+	alice, err := h.Sessions.CreateForUser(t.Context(), "Pairing", "alice")
+	require.NoError(t, err)
+	other, err := h.Sessions.CreateForUser(t.Context(), "Private", "alice")
+	require.NoError(t, err)
+	messages := "/api/sessions/" + alice.ID + "/messages"
+
+	// Bob watches once invited, but can't drive.
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodGet, messages, "").Code)
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodPost, "/api/sessions/"+alice.ID+"/watchers", `{"user": "bob"}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, s, "alice-token", http.MethodPost, "/api/sessions/"+alice.ID+"/watchers", `{"user": "eve"}`).Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "alice-token", http.MethodPost, "/api/sessions/"+alice.ID+"/watchers", `{"user": "bob"}`).Code)
+	require.Equal(t, http.StatusOK, serve(t, s, "bob-token", http.MethodGet, messages, "").Code)
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodGet, "/api/sessions/"+other.ID+"/messages", "").Code)
+	require.Equal(t, http.StatusForbidden, serve(t, s, "bob-token", http.MethodPost, "/api/sessions/"+alice.ID+"/prompt", `{"prompt": "Hi"}`).Code)
+
+	rec := serve(t, s, "bob-token", http.MethodGet, "/api/sessions", "")
+	var views []sessionView
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&views))
+	require.Len(t, views, 1)
+	require.Equal(t, "alice", views[0].Controller)
+	require.Equal(t, []string{"bob"}, views[0].Watchers)
+
+	// Read-only viewers only see the session of their link.
+	rec = serve(t, s, "alice-token", http.MethodPost, "/api/sessions/"+alice.ID+"/links", "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var link linkView
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&link))
+	require.Equal(t, "/#watch="+link.Token, link.URL)
+	require.Equal(t, http.StatusOK, serve(t, s, link.Token, http.MethodGet, messages, "").Code)
+	require.Equal(t, http.StatusNotFound, serve(t, s, link.Token, http.MethodGet, "/api/sessions/"+other.ID+"/messages", "").Code)
+	require.Equal(t, http.StatusForbidden, serve(t, s, link.Token, http.MethodPost, "/api/sessions", `{"prompt": "Hi"}`).Code)
+	require.Equal(t, http.StatusForbidden, serve(t, s, link.Token, http.MethodPost, "/api/sessions/"+alice.ID+"/prompt", `{"prompt": "Hi"}`).Code)
+	rec = serve(t, s, link.Token, http.MethodGet, "/api/me", "")
+	require.JSONEq(t, `{"name": "viewer", "watching": "`+alice.ID+`", "admin": false, "spent": 0}`, rec.Body.String())
+
+	// Control is handed over to a watcher, who then prompts and answers the
+	// permission requests.
+	require.Equal(t, http.StatusBadRequest, serve(t, s, "alice-token", http.MethodPost, "/api/sessions/"+alice.ID+"/handover", `{"user": "eve"}`).Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "alice-token", http.MethodPost, "/api/sessions/"+alice.ID+"/handover", `{"user": "bob"}`).Code)
+	require.Equal(t, http.StatusForbidden, serve(t, s, "alice-token", http.MethodPost, "/api/sessions/"+alice.ID+"/prompt", `{"prompt": "Hi"}`).Code)
+	require.Equal(t, http.StatusConflict, serve(t, s, "bob-token", http.MethodDelete, "/api/sessions/"+alice.ID+"/watchers/bob", "").Code)
+	require.Equal(t, http.StatusAccepted, serve(t, s, "bob-token", http.MethodPost, "/api/sessions/"+alice.ID+"/prompt", `{"prompt": "Hi"}`).Code)
+	require.Eventually(t, func() bool {
+		return !h.Agent.IsSessionBusy(alice.ID)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	granted := make(chan bool, 1)
+	go func() {
+		granted <- h.Permissions.Request(permission.CreatePermissionRequest{
+			SessionID:  alice.ID,
+			ToolCallID: "bash-call",
+			ToolName:   "bash",
+			Action:     "execute",
+			Path:       ".",
+		})
+	}()
+	require.Eventually(t, func() bool {
+		_, ok := s.pending.Get("bash-call")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusForbidden, serve(t, s, "alice-token", http.MethodPost, "/api/permissions/bash-call", `{"allow": true}`).Code)
+	require.Equal(t, http.StatusForbidden, serve(t, s, link.Token, http.MethodPost, "/api/permissions/bash-call", `{"allow": true}`).Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "bob-token", http.MethodPost, "/api/permissions/bash-call", `{"allow": true}`).Code)
+	select {
+	case got := <-granted:
+		require.True(t, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("permission request wasn't answered")
+	}
+
+	// Bob hands control back and the links stop working once revoked.
+	require.Equal(t, http.StatusNoContent, serve(t, s, "bob-token", http.MethodPost, "/api/sessions/"+alice.ID+"/handover", `{"user": "alice"}`).Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "alice-token", http.MethodDelete, "/api/sessions/"+alice.ID+"/links", "").Code)
+	require.Equal(t, http.StatusUnauthorized, serve(t, s, link.Token, http.MethodGet, messages, "").Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "alice-token", http.MethodDelete, "/api/sessions/"+alice.ID+"/watchers/bob", "").Code)
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodGet, messages, "").Code)
+
+	require.Contains(t, audit.String(), `"user":"alice","action":"control_handed_over","session_id":"`+alice.ID+`","detail":"from alice to bob"`)
+	require.Contains(t, audit.String(), `"user":"bob","action":"session_prompted"`)
+}
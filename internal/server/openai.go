@@ -0,0 +1,515 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// OpenAIModel is the model the OpenAI-compatible API exposes the agent as.
+// Any model name is accepted in requests.
+const OpenAIModel = "crush"
+
+// SessionHeader carries the session of a chat completion, in responses and
+// in requests continuing a given session.
+const SessionHeader = "X-Crush-Session-ID"
+
+// chatMessage is a message of a chat completion request. Content is a
+// string or an array of content parts, only the text ones are kept.
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model         string        `json:"model"`
+	Messages      []chatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type chatUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type chatChoiceMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int                `json:"index"`
+	Message      *chatChoiceMessage `json:"message,omitempty"`
+	Delta        *chatChoiceMessage `json:"delta,omitempty"`
+	FinishReason *string            `json:"finish_reason"`
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// turn is a prompt of the history of a chat completion request.
+type turn struct {
+	role string
+	text string
+}
+
+func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data": []map[string]any{
+			{"id": OpenAIModel, "object": "model", "created": 0, "owned_by": "crush"},
+		},
+	})
+}
+
+// handleChatCompletions runs the agent on the last user message of the
+// request, tools included, and answers with the text of the agent. The
+// conversation continues the session given in SessionHeader, or the one
+// that produced the history of the request. Otherwise a session is started
+// with the history as context.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if s.app.CoderAgent == nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "no providers configured")
+		return
+	}
+	var req chatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	turns, err := chatTurns(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(turns) == 0 || turns[len(turns)-1].role != "user" || strings.TrimSpace(turns[len(turns)-1].text) == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "the last message must be a user message")
+		return
+	}
+	ctx := r.Context()
+	user := userFrom(ctx)
+	if user.link != "" {
+		writeOpenAIError(w, http.StatusForbidden, "viewers can't start sessions")
+		return
+	}
+	history, last := turns[:len(turns)-1], turns[len(turns)-1]
+	sessionID := r.Header.Get(SessionHeader)
+	if sessionID == "" {
+		sessionID, _ = s.conversations.get(conversationKey(user.Name, history))
+	}
+	prompt := last.text
+	if sessionID != "" {
+		if _, err := s.app.Sessions.Get(ctx, sessionID); err != nil || !s.canAccess(ctx, user, sessionID) {
+			writeOpenAIError(w, http.StatusNotFound, "no session with this ID")
+			return
+		}
+		if !s.canControl(ctx, user, sessionID) {
+			writeOpenAIError(w, http.StatusForbidden, "only the user in control of the session can do this")
+			return
+		}
+		// The cost of the session counts towards its owner.
+		owner, err := s.owner(ctx, sessionID)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get session: %v", err))
+			return
+		}
+		if ownerUser := s.userByName(owner); ownerUser != nil {
+			if status, msg := s.budgetError(ctx, ownerUser); status != 0 {
+				writeOpenAIError(w, status, msg)
+				return
+			}
+		}
+		s.audit(user, "session_prompted", sessionID, prompt)
+	} else {
+		if status, msg := s.budgetError(ctx, user); status != 0 {
+			writeOpenAIError(w, status, msg)
+			return
+		}
+		prompt = promptWithHistory(history, last)
+		sess, err := s.app.Sessions.CreateForUser(ctx, sessionTitle(last.text), user.Name)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to create session: %v", err))
+			return
+		}
+		sessionID = sess.ID
+		s.owners.Set(sess.ID, user.Name)
+		s.audit(user, "session_created", sess.ID, sess.Title)
+	}
+
+	before, err := s.app.Sessions.Usage(ctx, sessionID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to get session usage: %v", err))
+		return
+	}
+	// Subscribe before running, not to miss the first messages.
+	subCtx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	messages := s.app.Messages.Subscribe(subCtx)
+	done, err := s.app.CoderAgent.Run(s.ctx, sessionID, prompt)
+	if err != nil {
+		if errors.Is(err, agent.ErrSessionBusy) {
+			writeOpenAIError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeOpenAIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to start agent: %v", err))
+		return
+	}
+
+	completion := chatCompletion{
+		ID:      "chatcmpl-" + sessionID + "-" + fmt.Sprint(time.Now().UnixNano()),
+		Created: time.Now().Unix(),
+		Model:   OpenAIModel,
+	}
+	w.Header().Set(SessionHeader, sessionID)
+	var stream *chatStream
+	if req.Stream {
+		if stream = newChatStream(w, completion); stream == nil {
+			s.app.CoderAgent.Cancel(sessionID)
+			writeOpenAIError(w, http.StatusInternalServerError, "streaming not supported")
+			return
+		}
+	}
+
+	answer := newAnswer(sessionID)
+	var result agent.AgentEvent
+wait:
+	for {
+		select {
+		case event := <-messages:
+			stream.delta(answer.add(event.Payload))
+		case result = <-done:
+			break wait
+		case <-ctx.Done():
+			// The client went away, the run is of no use.
+			s.app.CoderAgent.Cancel(sessionID)
+			return
+		}
+	}
+	if result.Error != nil {
+		if stream != nil {
+			stream.fail(result.Error)
+			return
+		}
+		writeOpenAIError(w, http.StatusInternalServerError, result.Error.Error())
+		return
+	}
+	stream.delta(answer.add(result.Message))
+
+	text := answer.text()
+	s.conversations.set(conversationKey(user.Name, append(turns, turn{role: "assistant", text: text})), sessionID)
+	usage := s.runUsage(sessionID, before)
+	if stream != nil {
+		stream.finish(usage, req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+		return
+	}
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []chatChoice{{Message: &chatChoiceMessage{Role: "assistant", Content: text}, FinishReason: &stop}}
+	completion.Usage = usage
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// runUsage returns the tokens the session spent since before.
+func (s *Server) runUsage(sessionID string, before session.UsageTotals) *chatUsage {
+	after, err := s.app.Sessions.Usage(context.WithoutCancel(s.ctx), sessionID)
+	if err != nil {
+		slog.Error("Server: failed to get session usage", "session_id", sessionID, "error", err)
+		return &chatUsage{}
+	}
+	usage := &chatUsage{
+		PromptTokens:     after.InputTokens - before.InputTokens,
+		CompletionTokens: after.OutputTokens - before.OutputTokens,
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// chatTurns returns the text of the system, user, and assistant messages.
+// Tool messages are left out, tools run on the server.
+func chatTurns(messages []chatMessage) ([]turn, error) {
+	var turns []turn
+	for i, msg := range messages {
+		role := msg.Role
+		switch role {
+		case "developer":
+			role = "system"
+		case "system", "user", "assistant":
+		default:
+			continue
+		}
+		text, err := chatText(msg.Content)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		turns = append(turns, turn{role: role, text: text})
+	}
+	return turns, nil
+}
+
+func chatText(content json.RawMessage) (string, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", fmt.Errorf("invalid content: %w", err)
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// conversationKey identifies the history of a user, so that a session can be
+// found from the history clients send back with the next message.
+func conversationKey(userName string, turns []turn) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", userName)
+	for _, t := range turns {
+		fmt.Fprintf(h, "%s\x00%s\x00", t.role, strings.TrimSpace(t.text))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// maxConversations bounds the histories remembered, the oldest are forgotten
+// first. A forgotten history starts a new session with it as context.
+const maxConversations = 1000
+
+// conversations are the sessions of the chat completions API, by the key of
+// their history.
+type conversations struct {
+	mu       sync.Mutex
+	sessions map[string]string
+	// keys are in the order they were set, the oldest first.
+	keys []string
+}
+
+func newConversations() *conversations {
+	return &conversations{sessions: make(map[string]string)}
+}
+
+func (c *conversations) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sessionID, ok := c.sessions[key]
+	return sessionID, ok
+}
+
+func (c *conversations) set(key, sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sessions[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.sessions[key] = sessionID
+	if len(c.keys) > maxConversations {
+		for _, key := range c.keys[:len(c.keys)-maxConversations] {
+			delete(c.sessions, key)
+		}
+		c.keys = slices.Clone(c.keys[len(c.keys)-maxConversations:])
+	}
+}
+
+// forget forgets the histories of the session.
+func (c *conversations) forget(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = slices.DeleteFunc(c.keys, func(key string) bool {
+		if c.sessions[key] != sessionID {
+			return false
+		}
+		delete(c.sessions, key)
+		return true
+	})
+}
+
+// promptWithHistory returns the prompt starting a session, with the
+// instructions and the conversation of the client before the message.
+func promptWithHistory(history []turn, last turn) string {
+	if len(history) == 0 {
+		return last.text
+	}
+	var b strings.Builder
+	var conversation []turn
+	for _, t := range history {
+		if t.role == "system" {
+			fmt.Fprintf(&b, "Instructions from the client:\n%s\n\n", t.text)
+			continue
+		}
+		conversation = append(conversation, t)
+	}
+	if len(conversation) > 0 {
+		b.WriteString("The conversation so far:\n\n")
+		for _, t := range conversation {
+			fmt.Fprintf(&b, "%s: %s\n\n", strings.ToUpper(t.role[:1])+t.role[1:], t.text)
+		}
+		b.WriteString("The new message:\n\n")
+	}
+	b.WriteString(last.text)
+	return b.String()
+}
+
+// answer collects the text of the assistant messages of a run, separated
+// by blank lines.
+type answer struct {
+	sessionID string
+	order     []string
+	texts     map[string]string
+}
+
+func newAnswer(sessionID string) *answer {
+	return &answer{sessionID: sessionID, texts: map[string]string{}}
+}
+
+// add updates the text of the message, returning what it adds to the
+// answer.
+func (a *answer) add(msg message.Message) string {
+	if msg.SessionID != a.sessionID || msg.Role != message.Assistant {
+		return ""
+	}
+	text := msg.Content().Text
+	previous, seen := a.texts[msg.ID]
+	if !seen {
+		a.order = append(a.order, msg.ID)
+		a.texts[msg.ID] = ""
+	}
+	if len(text) <= len(previous) {
+		return ""
+	}
+	a.texts[msg.ID] = text
+	delta := text[len(previous):]
+	if previous == "" && a.hasTextBefore(msg.ID) {
+		delta = "\n\n" + delta
+	}
+	return delta
+}
+
+func (a *answer) hasTextBefore(id string) bool {
+	for _, other := range a.order {
+		if other == id {
+			return false
+		}
+		if a.texts[other] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *answer) text() string {
+	var texts []string
+	for _, id := range a.order {
+		if text := a.texts[id]; text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// chatStream writes a chat completion as server-sent chunks.
+type chatStream struct {
+	w          http.ResponseWriter
+	flusher    http.Flusher
+	completion chatCompletion
+}
+
+// newChatStream starts the stream, nil if the writer can't stream.
+func newChatStream(w http.ResponseWriter, completion chatCompletion) *chatStream {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil
+	}
+	completion.Object = "chat.completion.chunk"
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	s := &chatStream{w: w, flusher: flusher, completion: completion}
+	s.chunk([]chatChoice{{Delta: &chatChoiceMessage{Role: "assistant"}}}, nil)
+	return s
+}
+
+func (s *chatStream) chunk(choices []chatChoice, usage *chatUsage) {
+	chunk := s.completion
+	chunk.Choices = choices
+	chunk.Usage = usage
+	s.write(chunk)
+}
+
+func (s *chatStream) write(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Server: failed to marshal chunk", "error", err)
+		return
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	s.flusher.Flush()
+}
+
+// delta writes a chunk of the answer, nothing when not streaming.
+func (s *chatStream) delta(text string) {
+	if s == nil || text == "" {
+		return
+	}
+	s.chunk([]chatChoice{{Delta: &chatChoiceMessage{Content: text}}}, nil)
+}
+
+// finish ends the stream, with a last chunk holding the usage if asked.
+func (s *chatStream) finish(usage *chatUsage, includeUsage bool) {
+	stop := "stop"
+	s.chunk([]chatChoice{{Delta: &chatChoiceMessage{}, FinishReason: &stop}}, nil)
+	if includeUsage {
+		s.chunk([]chatChoice{}, usage)
+	}
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	s.flusher.Flush()
+}
+
+// fail ends the stream with an error, as OpenAI does once it has started.
+func (s *chatStream) fail(err error) {
+	s.write(openAIError(err.Error()))
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	s.flusher.Flush()
+}
+
+func openAIError(msg string) map[string]any {
+	return map[string]any{"error": map[string]string{"message": msg, "type": "crush_error"}}
+}
+
+// writeOpenAIError writes the error in the format of the OpenAI API, which
+// its clients show.
+func writeOpenAIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, openAIError(msg))
+}
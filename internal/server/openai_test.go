@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func newChatServer(t *testing.T) (*agenttest.Harness, *Server) {
+	t.Helper()
+	h := agenttest.New(t)
+	a := &app.App{
+		Sessions:    h.Sessions,
+		Messages:    h.Messages,
+		Permissions: h.Permissions,
+		CoderAgent:  h.Agent,
+	}
+	return h, New(t.Context(), a, Options{Token: "secret"})
+}
+
+func postChat(t *testing.T, s *Server, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	for key, values := range header {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func lastPrompt(t *testing.T, p *agenttest.Provider) string {
+	t.Helper()
+	requests := p.Requests()
+	msgs := requests[len(requests)-1].Messages
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == message.User {
+			return msgs[i].Content().Text
+		}
+	}
+	return ""
+}
+
+func TestChatCompletions(t *testing.T) {
+	h, s := newChatServer(t)
+	h.Large.Script(
+		agenttest.Step{Content: "Let me look.", ToolCalls: agenttest.ToolCall(tools.LSToolName, map[string]string{"path": "."}).ToolCalls, Usage: provider.TokenUsage{InputTokens: 100, OutputTokens: 10}},
+		agenttest.Step{Content: "The project is empty.", Usage: provider.TokenUsage{InputTokens: 120, OutputTokens: 5}},
+	)
+
+	rec := postChat(t, s, `{"model": "crush", "messages": [
+		{"role": "system", "content": "Answer briefly."},
+		{"role": "user", "content": [{"type": "text", "text": "What is in the project?"}]}
+	]}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var completion chatCompletion
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &completion))
+	require.Equal(t, "chat.completion", completion.Object)
+	require.Equal(t, "Let me look.\n\nThe project is empty.", completion.Choices[0].Message.Content)
+	require.Equal(t, "stop", *completion.Choices[0].FinishReason)
+	require.Equal(t, &chatUsage{PromptTokens: 220, CompletionTokens: 15, TotalTokens: 235}, completion.Usage)
+	sessionID := rec.Header().Get(SessionHeader)
+	require.NotEmpty(t, sessionID)
+	require.Equal(t, "Instructions from the client:\nAnswer briefly.\n\nWhat is in the project?", lastPrompt(t, h.Large))
+
+	// Sending the history back continues the session with the new message.
+	h.Large.Script(agenttest.Text("You're welcome."))
+	rec = postChat(t, s, `{"model": "crush", "messages": [
+		{"role": "system", "content": "Answer briefly."},
+		{"role": "user", "content": "What is in the project?"},
+		{"role": "assistant", "content": "Let me look.\n\nThe project is empty."},
+		{"role": "user", "content": "Thanks"}
+	]}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, sessionID, rec.Header().Get(SessionHeader))
+	require.Equal(t, "Thanks", lastPrompt(t, h.Large))
+
+	// An unknown history starts a session with it as context.
+	h.Large.Script(agenttest.Text("Sure."))
+	rec = postChat(t, s, `{"messages": [
+		{"role": "user", "content": "Hi"},
+		{"role": "assistant", "content": "Hello!"},
+		{"role": "user", "content": "Help me"}
+	]}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotEqual(t, sessionID, rec.Header().Get(SessionHeader))
+	require.Equal(t, "The conversation so far:\n\nUser: Hi\n\nAssistant: Hello!\n\nThe new message:\n\nHelp me", lastPrompt(t, h.Large))
+
+	// The session can be given explicitly.
+	h.Large.Script(agenttest.Text("Again."))
+	rec = postChat(t, s, `{"messages": [{"role": "user", "content": "Once more"}]}`, http.Header{SessionHeader: {sessionID}})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, sessionID, rec.Header().Get(SessionHeader))
+}
+
+func TestChatCompletions_Stream(t *testing.T) {
+	h, s := newChatServer(t)
+	h.Large.Script(agenttest.Step{Content: "Hello there.", Usage: provider.TokenUsage{InputTokens: 50, OutputTokens: 3}})
+
+	rec := postChat(t, s, `{"stream": true, "stream_options": {"include_usage": true}, "messages": [{"role": "user", "content": "Hi"}]}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	var chunks []chatCompletion
+	var done bool
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk chatCompletion
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		require.Equal(t, "chat.completion.chunk", chunk.Object)
+		chunks = append(chunks, chunk)
+	}
+	require.True(t, done)
+	require.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	var text strings.Builder
+	for _, chunk := range chunks {
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	require.Equal(t, "Hello there.", text.String())
+	last := chunks[len(chunks)-1]
+	require.Empty(t, last.Choices)
+	require.Equal(t, &chatUsage{PromptTokens: 50, CompletionTokens: 3, TotalTokens: 53}, last.Usage)
+	require.Equal(t, "stop", *chunks[len(chunks)-2].Choices[0].FinishReason)
+}
+
+func TestChatCompletions_Invalid(t *testing.T) {
+	_, s := newChatServer(t)
+
+	rec := postChat(t, s, `{"messages": [{"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello"}]}`, nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `"message":"the last message must be a user message"`)
+
+	rec = postChat(t, s, `{"messages": [{"role": "user", "content": "Hi"}]}`, http.Header{SessionHeader: {"missing"}})
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestChatCompletions_Conversations(t *testing.T) {
+	s, h := newUsersTestServer(t, &bytes.Buffer{})
+	alice := http.Header{"Authorization": {"Bearer alice-token"}}
+	bob := http.Header{"Authorization": {"Bearer bob-token"}}
+	h.Large.SetFallback(agenttest.Text("Hello!"))
+
+	rec := postChat(t, s, `{"messages": [{"role": "system", "content": "Be brief."}, {"role": "user", "content": "Hi"}]}`, alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	aliceSession := rec.Header().Get(SessionHeader)
+
+	// The same history of another user is another conversation.
+	history := `{"role": "system", "content": "Be brief."}, {"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello!"}, {"role": "user", "content": "Next"}`
+	rec = postChat(t, s, `{"messages": [`+history+`]}`, bob)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotEqual(t, aliceSession, rec.Header().Get(SessionHeader))
+
+	// So is the same conversation with other instructions.
+	rec = postChat(t, s, `{"messages": [{"role": "system", "content": "Be verbose."}, {"role": "user", "content": "Hi"}, {"role": "assistant", "content": "Hello!"}, {"role": "user", "content": "Next"}]}`, alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NotEqual(t, aliceSession, rec.Header().Get(SessionHeader))
+
+	rec = postChat(t, s, `{"messages": [`+history+`]}`, alice)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Equal(t, aliceSession, rec.Header().Get(SessionHeader))
+
+	// The histories of a deleted session are forgotten.
+	require.NoError(t, h.Sessions.Delete(t.Context(), aliceSession))
+	require.Eventually(t, func() bool {
+		s.conversations.mu.Lock()
+		defer s.conversations.mu.Unlock()
+		return !slices.Contains(slices.Collect(maps.Values(s.conversations.sessions)), aliceSession)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConversations(t *testing.T) {
+	c := newConversations()
+	for i := range maxConversations + 10 {
+		c.set(fmt.Sprint("key", i), fmt.Sprint("session", i%2))
+	}
+	// The oldest are forgotten first.
+	_, ok := c.get("key9")
+	require.False(t, ok)
+	sessionID, ok := c.get("key10")
+	require.True(t, ok)
+	require.Equal(t, "session0", sessionID)
+	require.Len(t, c.sessions, maxConversations)
+
+	c.forget("session0")
+	_, ok = c.get("key10")
+	require.False(t, ok)
+	require.Len(t, c.sessions, maxConversations/2)
+	require.Len(t, c.keys, maxConversations/2)
+}
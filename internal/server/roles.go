@@ -0,0 +1,74 @@
+package server
+
+import (
+	"maps"
+	"slices"
+)
+
+// Approval is the scope of the permission requests a role answers.
+type Approval string
+
+const (
+	// ApproveNone members wait for someone else to answer their requests.
+	ApproveNone Approval = "none"
+	// ApproveOwn members answer the requests of their own sessions.
+	ApproveOwn Approval = "own"
+	// ApproveAll members see the sessions of all users and answer their
+	// requests.
+	ApproveAll Approval = "all"
+)
+
+// Names of the built-in roles.
+const (
+	RoleViewer      = "viewer"
+	RoleContributor = "contributor"
+	RoleAdmin       = "admin"
+)
+
+// Role grants tools and approval authority to the users it's given to.
+type Role struct {
+	// Tools the members can use, as tool or tool:action. Calls of other tools
+	// are denied. All tools when empty.
+	Tools []string
+	// AllowedTools are granted without asking. Destructive calls are still
+	// asked unless AllowDestructive.
+	AllowedTools     []string
+	AllowDestructive bool
+	Approve          Approval
+}
+
+// readOnlyTools can't change the working tree.
+var readOnlyTools = []string{"view", "ls", "glob", "grep", "sourcegraph", "diagnostics", "fetch"}
+
+// DefaultRoles returns the built-in roles: viewers run read-only analysis,
+// contributors also write and answer their own requests, and admins answer
+// everyone's.
+func DefaultRoles() map[string]Role {
+	return map[string]Role{
+		RoleViewer: {
+			Tools:        readOnlyTools,
+			AllowedTools: readOnlyTools,
+			Approve:      ApproveNone,
+		},
+		RoleContributor: {
+			AllowedTools: readOnlyTools,
+			Approve:      ApproveOwn,
+		},
+		RoleAdmin: {
+			Approve: ApproveAll,
+		},
+	}
+}
+
+// roles returns the built-in roles overridden by the ones of the options.
+func (o Options) roles() map[string]Role {
+	roles := DefaultRoles()
+	maps.Copy(roles, o.Roles)
+	return roles
+}
+
+// matchesTool reports whether the tool or tool:action of the request is in
+// the list.
+func matchesTool(tools []string, toolName, action string) bool {
+	return slices.Contains(tools, toolName) || slices.Contains(tools, toolName+":"+action)
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Roles(t *testing.T) {
+	var audit bytes.Buffer
+	h := agenttest.New(t, agenttest.WithPermissionRequests())
+	s := New(t.Context(), &app.App{
+		Sessions:    h.Sessions,
+		Messages:    h.Messages,
+		Permissions: h.Permissions,
+		CoderAgent:  h.Agent,
+	}, Options{
+		Users: []User{
+			{Name: "vera", Token: "vera-token", Role: RoleViewer},
+			{Name: "jun", Token: "jun-token", Role: "junior"},
+			{Name: "carol", Token: "carol-token", Role: RoleContributor},
+			{Name: "sam", Token: "sam-token", Role: RoleAdmin},
+		},
+		Roles: map[string]Role{
+			"junior": {AllowedTools: []string{"view"}, Approve: ApproveNone},
+		},
+		Audit: &audit,
+	})
+
+	request := func(userID, toolCallID, toolName string) chan bool {
+		sess, err := h.Sessions.CreateForUser(t.Context(), toolCallID, userID)
+		require.NoError(t, err)
+		granted := make(chan bool, 1)
+		go func() {
+			granted <- h.Permissions.Request(permission.CreatePermissionRequest{
+				SessionID:  sess.ID,
+				ToolCallID: toolCallID,
+				ToolName:   toolName,
+				Action:     "execute",
+				Path:       ".",
+			})
+		}()
+		return granted
+	}
+	answer := func(granted chan bool) bool {
+		select {
+		case got := <-granted:
+			return got
+		case <-time.After(5 * time.Second):
+			t.Fatal("permission request wasn't answered")
+			return false
+		}
+	}
+
+	// Viewers only run read-only tools.
+	require.True(t, answer(request("vera", "vera-view", "view")))
+	require.False(t, answer(request("vera", "vera-bash", "bash")))
+
+	// Juniors write once someone with approval authority agrees.
+	granted := request("jun", "jun-bash", "bash")
+	require.Eventually(t, func() bool {
+		_, ok := s.pending.Get("jun-bash")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusForbidden, serve(t, s, "jun-token", http.MethodPost, "/api/permissions/jun-bash", `{"allow": true}`).Code)
+	require.Equal(t, http.StatusNotFound, serve(t, s, "carol-token", http.MethodPost, "/api/permissions/jun-bash", `{"allow": true}`).Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "sam-token", http.MethodPost, "/api/permissions/jun-bash", `{"allow": true}`).Code)
+	require.True(t, answer(granted))
+
+	// Contributors answer their own requests.
+	granted = request("carol", "carol-bash", "bash")
+	require.Eventually(t, func() bool {
+		_, ok := s.pending.Get("carol-bash")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "carol-token", http.MethodPost, "/api/permissions/carol-bash", `{"allow": false}`).Code)
+	require.False(t, answer(granted))
+
+	rec := serve(t, s, "sam-token", http.MethodGet, "/api/me", "")
+	require.JSONEq(t, `{"name": "sam", "role": "admin", "admin": true, "spent": 0}`, rec.Body.String())
+	require.Contains(t, audit.String(), `"user":"sam","action":"permission_granted"`)
+}
@@ -0,0 +1,223 @@
+// Package server runs crush headless behind an HTTP API, optionally with a
+// web dashboard to supervise sessions from a browser.
+package server
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+type Options struct {
+	// Token authenticates API requests as AdminUser. Either it or Users is
+	// required, the API exposes transcripts and can approve tool calls.
+	Token string
+	// Users share the server, each with their own token.
+	Users []User
+	// Roles of the users, overriding and adding to DefaultRoles.
+	Roles map[string]Role
+	// Audit receives a JSON line per action of the users, if set.
+	Audit io.Writer
+	// Dashboard serves the web dashboard at /.
+	Dashboard bool
+}
+
+type Server struct {
+	app  *app.App
+	opts Options
+	mux  *http.ServeMux
+
+	// ctx outlives requests, agent runs started through the API use it.
+	ctx context.Context
+	// pending are the permission requests waiting for an answer, by tool
+	// call ID as notifications only carry that.
+	pending *csync.Map[string, permission.PermissionRequest]
+
+	users []*User
+	// owners caches the users who own the sessions, by session ID.
+	owners *csync.Map[string, string]
+	// roots caches the root sessions of task and title sessions, by session
+	// ID.
+	roots         *csync.Map[string, string]
+	conversations *conversations
+	live          *live
+	auditMu       sync.Mutex
+}
+
+// New creates a server for app. It tracks pending permission requests until
+// ctx is done.
+func New(ctx context.Context, app *app.App, opts Options) *Server {
+	s := &Server{
+		app:     app,
+		opts:    opts,
+		mux:     http.NewServeMux(),
+		ctx:     ctx,
+		pending: csync.NewMap[string, permission.PermissionRequest](),
+		owners:  csync.NewMap[string, string](),
+		roots:   csync.NewMap[string, string](),
+		live:    newLive(),
+
+		conversations: newConversations(),
+	}
+	if opts.Token != "" {
+		s.users = append(s.users, &User{Name: AdminUser, Token: opts.Token, Admin: true})
+	}
+	roles := opts.roles()
+	for _, user := range opts.Users {
+		role, ok := roles[user.Role]
+		switch {
+		case user.Role == "":
+			user.role = Role{Approve: ApproveOwn}
+		case ok:
+			user.role = role
+		default:
+			slog.Warn("Server: unknown role, falling back to viewer", "user", user.Name, "role", user.Role)
+			user.role = roles[RoleViewer]
+		}
+		s.users = append(s.users, &user)
+	}
+	s.routes()
+	app.Permissions.SetPolicy(s.denial)
+	// Subscribe before returning so no request is missed.
+	requests := app.Permissions.Subscribe(ctx)
+	notifications := app.Permissions.SubscribeNotifications(ctx)
+	go s.trackPermissions(ctx, requests, notifications)
+	if app.Sessions != nil {
+		go s.forgetDeletedSessions(ctx, app.Sessions.Subscribe(ctx))
+	}
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux.Handle("GET /api/me", s.auth(s.handleMe))
+	s.mux.Handle("GET /api/sessions", s.auth(s.handleListSessions))
+	s.mux.Handle("POST /api/sessions", s.auth(s.handleCreateSession))
+	s.mux.Handle("GET /api/sessions/{id}/messages", s.auth(s.handleListMessages))
+	s.mux.Handle("POST /api/sessions/{id}/prompt", s.auth(s.handlePrompt))
+	s.mux.Handle("POST /api/sessions/{id}/watchers", s.auth(s.handleAddWatcher))
+	s.mux.Handle("DELETE /api/sessions/{id}/watchers/{user}", s.auth(s.handleRemoveWatcher))
+	s.mux.Handle("POST /api/sessions/{id}/links", s.auth(s.handleCreateLink))
+	s.mux.Handle("DELETE /api/sessions/{id}/links", s.auth(s.handleRevokeLinks))
+	s.mux.Handle("POST /api/sessions/{id}/handover", s.auth(s.handleHandover))
+	s.mux.Handle("GET /api/sessions/{id}/permissions", s.auth(s.handleGetSessionRules))
+	s.mux.Handle("PUT /api/sessions/{id}/permissions", s.auth(s.handleSetSessionRules))
+	s.mux.Handle("GET /api/permissions", s.auth(s.handleListPermissions))
+	s.mux.Handle("POST /api/permissions/{id}", s.auth(s.handleAnswerPermission))
+	s.mux.Handle("GET /api/events", s.auth(s.handleEvents))
+	s.mux.Handle("GET /v1/models", s.auth(s.handleListModels))
+	s.mux.Handle("POST /v1/chat/completions", s.auth(s.handleChatCompletions))
+	if s.opts.Dashboard {
+		s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(dashboardHTML)
+		})
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Failed to shut down server", "error", err)
+		}
+	}()
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// auth only lets requests with the token of a user through, either as a
+// bearer token or, for EventSource which can't set headers, a token query
+// parameter. The user is added to the request context.
+func (s *Server) auth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("token")
+		}
+		user := s.userByToken(token)
+		if user == nil {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next(w, r.WithContext(withUser(r.Context(), user)))
+	})
+}
+
+// forgetDeletedSessions drops the chat completions histories of the deleted
+// sessions.
+func (s *Server) forgetDeletedSessions(ctx context.Context, events <-chan pubsub.Event[session.Session]) {
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == pubsub.DeletedEvent {
+				s.conversations.forget(event.Payload.ID)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Server) trackPermissions(
+	ctx context.Context,
+	requests <-chan pubsub.Event[permission.PermissionRequest],
+	notifications <-chan pubsub.Event[permission.PermissionNotification],
+) {
+	for {
+		select {
+		case event, ok := <-requests:
+			if !ok {
+				return
+			}
+			if !s.applyPolicy(ctx, event.Payload) {
+				s.pending.Set(event.Payload.ToolCallID, event.Payload)
+			}
+		case event, ok := <-notifications:
+			if !ok {
+				return
+			}
+			// A notification is also sent when a permission is requested,
+			// only answers resolve it.
+			if event.Payload.Granted || event.Payload.Denied {
+				s.pending.Del(event.Payload.ToolCallID)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, opts Options) *Server {
+	t.Helper()
+	a := &app.App{
+		Permissions: permission.NewPermissionService(t.TempDir(), false, nil, false),
+	}
+	return New(t.Context(), a, opts)
+}
+
+func TestServer_Auth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		query  string
+		want   int
+	}{
+		{name: "no token", token: "secret", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "bearer token", token: "secret", header: "Bearer secret", want: http.StatusOK},
+		{name: "query token", token: "secret", query: "?token=secret", want: http.StatusOK},
+		{name: "empty server token", token: "", header: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := newTestServer(t, Options{Token: tt.token})
+			req := httptest.NewRequest(http.MethodGet, "/api/permissions"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	newTestServer(t, Options{Token: "secret"}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	newTestServer(t, Options{Token: "secret", Dashboard: true}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "<title>Crush</title>")
+}
+
+func TestServer_AnswerPermission(t *testing.T) {
+	t.Parallel()
+
+	for _, allow := range []bool{true, false} {
+		s := newTestServer(t, Options{Token: "secret"})
+
+		granted := make(chan bool, 1)
+		go func() {
+			granted <- s.app.Permissions.Request(permission.CreatePermissionRequest{
+				SessionID:  "session",
+				ToolCallID: "call",
+				ToolName:   "bash",
+				Action:     "execute",
+				Path:       ".",
+			})
+		}()
+		require.Eventually(t, func() bool {
+			_, ok := s.pending.Get("call")
+			return ok
+		}, 5*time.Second, 10*time.Millisecond)
+
+		body := `{"allow": false}`
+		if allow {
+			body = `{"allow": true}`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/permissions/call", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNoContent, rec.Code)
+
+		select {
+		case got := <-granted:
+			require.Equal(t, allow, got)
+		case <-time.After(5 * time.Second):
+			t.Fatal("permission request wasn't answered")
+		}
+
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodPost, "/api/permissions/call", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		s.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestSessionTitle(t *testing.T) {
+	require.Equal(t, "Fix the tests", sessionTitle("Fix the tests"))
+	title := sessionTitle(strings.Repeat("é", 150))
+	require.True(t, utf8.ValidString(title))
+	require.Equal(t, strings.Repeat("é", 100)+"...", title)
+}
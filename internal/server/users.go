@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+const (
+	// AdminUser is the name of the user authenticated by the server token.
+	AdminUser = "admin"
+	// ViewerUser is the name of the read-only viewers authenticated by a
+	// share link.
+	ViewerUser = "viewer"
+)
+
+// User is a member of the team sharing the server.
+type User struct {
+	Name  string
+	Token string
+	// Role grants tools and approval authority, see DefaultRoles. Users
+	// without one answer the requests of their own sessions.
+	Role string
+	// Admin sees the sessions of all users and answers their permission
+	// requests, whatever the role.
+	Admin bool
+	// Budget caps the cost in dollars of the sessions of the user, checked
+	// when a session starts. No limit when 0.
+	Budget float64
+	// AllowedTools are granted and DeniedTools denied without asking, as
+	// tool or tool:action. Destructive calls of allowed tools are still
+	// asked unless AllowDestructive.
+	AllowedTools     []string
+	DeniedTools      []string
+	AllowDestructive bool
+
+	role Role
+	// link is the session a read-only viewer authenticated by a share link
+	// watches.
+	link string
+}
+
+// admin reports whether the user sees the sessions of all users.
+func (u *User) admin() bool {
+	return u.Admin || u.role.Approve == ApproveAll
+}
+
+type userKey struct{}
+
+func withUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// userFrom returns the user of an authenticated request.
+func userFrom(ctx context.Context) *User {
+	user, _ := ctx.Value(userKey{}).(*User)
+	return user
+}
+
+// userByToken returns the user with the token, if any.
+func (s *Server) userByToken(token string) *User {
+	if token == "" {
+		return nil
+	}
+	for _, user := range s.users {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(user.Token)) == 1 {
+			return user
+		}
+	}
+	if sessionID := s.live.linkSession(token); sessionID != "" {
+		return &User{Name: ViewerUser, link: sessionID, role: Role{Approve: ApproveNone}}
+	}
+	return nil
+}
+
+func (s *Server) userByName(name string) *User {
+	for _, user := range s.users {
+		if user.Name == name {
+			return user
+		}
+	}
+	return nil
+}
+
+// owner returns the user who created the session, resolving task and title
+// sessions to their parent. Local sessions have no owner.
+func (s *Server) owner(ctx context.Context, sessionID string) (string, error) {
+	if owner, ok := s.owners.Get(sessionID); ok {
+		return owner, nil
+	}
+	sess, err := s.app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+	owner := sess.UserID
+	if sess.ParentSessionID != "" {
+		if owner, err = s.owner(ctx, sess.ParentSessionID); err != nil {
+			return "", err
+		}
+	}
+	s.owners.Set(sessionID, owner)
+	return owner, nil
+}
+
+// canAccess reports whether the user can see the session and its permission
+// requests, as its owner or watching it.
+func (s *Server) canAccess(ctx context.Context, user *User, sessionID string) bool {
+	if user.admin() {
+		return true
+	}
+	root, err := s.root(ctx, sessionID)
+	if err != nil {
+		return false
+	}
+	if user.link != "" {
+		return root == user.link
+	}
+	if owner, err := s.owner(ctx, sessionID); err == nil && owner == user.Name {
+		return true
+	}
+	return s.live.watching(root, user.Name)
+}
+
+// canApprove reports whether the role of the user lets them answer the
+// permission requests of the session.
+func (s *Server) canApprove(ctx context.Context, user *User, sessionID string) bool {
+	if user.admin() {
+		return true
+	}
+	return user.role.Approve == ApproveOwn && s.canControl(ctx, user, sessionID)
+}
+
+// spent returns the cost of the sessions of the user. The costs of task and
+// title sessions are added to their parent.
+func (s *Server) spent(ctx context.Context, user *User) (float64, error) {
+	sessions, err := s.app.Sessions.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, sess := range sessions {
+		if sess.UserID == user.Name {
+			total += sess.Cost
+		}
+	}
+	return total, nil
+}
+
+// applyPolicy grants the permission request with the policy and role of the
+// user who controls the session. It reports false when someone has to answer.
+// Calls the user can't make are denied before, see [Server.denial].
+func (s *Server) applyPolicy(ctx context.Context, req permission.PermissionRequest) bool {
+	if len(s.opts.Users) == 0 {
+		return false
+	}
+	controller, err := s.controller(ctx, req.SessionID)
+	if err != nil {
+		slog.Warn("Server: failed to find the controller of a session", "session_id", req.SessionID, "error", err)
+		return false
+	}
+	user := s.userByName(controller)
+	if user == nil {
+		return false
+	}
+
+	matches := func(tools []string) bool {
+		return matchesTool(tools, req.ToolName, req.Action)
+	}
+	role := user.role
+	if (matches(user.AllowedTools) || matches(role.AllowedTools)) &&
+		(req.DestructiveReason == "" || user.AllowDestructive || role.AllowDestructive) {
+		s.app.Permissions.Grant(req)
+		s.audit(user, "permission_granted_by_policy", req.SessionID, permissionDetail(req))
+		return true
+	}
+	return false
+}
+
+// denial is the policy of the permission service: it denies the calls of
+// the tools the user who controls the session can't use, before any rule,
+// grant, approval, or skipped request lets them through.
+func (s *Server) denial(sessionID, toolName, action string) string {
+	if len(s.opts.Users) == 0 {
+		return ""
+	}
+	controller, err := s.controller(s.ctx, sessionID)
+	if err != nil {
+		slog.Warn("Server: failed to find the controller of a session", "session_id", sessionID, "error", err)
+		return "the user of the session is unknown"
+	}
+	user := s.userByName(controller)
+	if user == nil || user.canUse(toolName, action) {
+		return ""
+	}
+	s.audit(user, "permission_denied_by_policy", sessionID, toolName+":"+action)
+	return fmt.Sprintf("%s can't use %s", user.Name, toolName)
+}
+
+// canUse reports whether the policy and role of the user let them use the
+// tool action or, without one, some action of the tool.
+func (u *User) canUse(toolName, action string) bool {
+	if action != "" {
+		return !matchesTool(u.DeniedTools, toolName, action) &&
+			(len(u.role.Tools) == 0 || matchesTool(u.role.Tools, toolName, action))
+	}
+	if slices.Contains(u.DeniedTools, toolName) {
+		return false
+	}
+	return len(u.role.Tools) == 0 || slices.ContainsFunc(u.role.Tools, func(tool string) bool {
+		return tool == toolName || strings.HasPrefix(tool, toolName+":")
+	})
+}
+
+// allowsRule reports whether the policy and role of the user let through
+// all the calls the rule can grant. Allow rules must match none of the
+// denied tools and, when the role lists its tools, exactly one of them.
+func (u *User) allowsRule(rule config.PermissionRule) bool {
+	if rule.Decision != config.PermissionAllow {
+		return true
+	}
+	tool, action, scoped := strings.Cut(rule.Tool, ":")
+	for _, denied := range u.DeniedTools {
+		deniedTool, deniedAction, deniedScoped := strings.Cut(denied, ":")
+		if ok, _ := path.Match(tool, deniedTool); !ok {
+			continue
+		}
+		if !scoped || !deniedScoped {
+			return false
+		}
+		if ok, _ := path.Match(action, deniedAction); ok {
+			return false
+		}
+	}
+	if len(u.role.Tools) == 0 {
+		return true
+	}
+	return slices.Contains(u.role.Tools, tool) || scoped && slices.Contains(u.role.Tools, rule.Tool)
+}
+
+func permissionDetail(req permission.PermissionRequest) string {
+	return fmt.Sprintf("%s:%s %s", req.ToolName, req.Action, req.Path)
+}
+
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Action    string    `json:"action"`
+	SessionID string    `json:"session_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// audit records an action of the user in the audit log.
+func (s *Server) audit(user *User, action, sessionID, detail string) {
+	slog.Info("Server: audit", "user", user.Name, "action", action, "session_id", sessionID)
+	if s.opts.Audit == nil {
+		return
+	}
+	data, err := json.Marshal(auditEntry{
+		Time:      time.Now().UTC(),
+		User:      user.Name,
+		Action:    action,
+		SessionID: sessionID,
+		Detail:    detail,
+	})
+	if err != nil {
+		slog.Error("Server: failed to marshal audit entry", "error", err)
+		return
+	}
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	if _, err := s.opts.Audit.Write(append(data, '\n')); err != nil {
+		slog.Error("Server: failed to write audit entry", "error", err)
+	}
+}
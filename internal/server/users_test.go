@@ -0,0 +1,267 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func newUsersTestServer(t *testing.T, audit *bytes.Buffer, opts ...agenttest.Option) (*Server, *agenttest.Harness) {
+	t.Helper()
+	h := agenttest.New(t, opts...)
+	a := &app.App{
+		Sessions:    h.Sessions,
+		Messages:    h.Messages,
+		Permissions: h.Permissions,
+		CoderAgent:  h.Agent,
+	}
+	s := New(t.Context(), a, Options{
+		Token: "admin-token",
+		Users: []User{
+			{Name: "alice", Token: "alice-token", Budget: 1, AllowedTools: []string{"view"}, DeniedTools: []string{"fetch"}},
+			{Name: "bob", Token: "bob-token"},
+		},
+		Audit: audit,
+	})
+	return s, h
+}
+
+func serve(t *testing.T, s *Server, token, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_UserSessions(t *testing.T) {
+	var audit bytes.Buffer
+	s, h := newUsersTestServer(t, &audit)
+	h.Large.SetFallback(agenttest.Text("Done."))
+
+	rec := serve(t, s, "alice-token", http.MethodPost, "/api/sessions", `{"prompt": "Fix the tests"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var alice sessionView
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&alice))
+	require.Equal(t, "alice", alice.UserID)
+	require.Eventually(t, func() bool {
+		return !h.Agent.IsSessionBusy(alice.ID)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	bob, err := h.Sessions.CreateForUser(t.Context(), "Bob's session", "bob")
+	require.NoError(t, err)
+
+	listed := func(token string) []string {
+		rec := serve(t, s, token, http.MethodGet, "/api/sessions", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var views []sessionView
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&views))
+		var ids []string
+		for _, view := range views {
+			ids = append(ids, view.ID)
+		}
+		return ids
+	}
+	require.Equal(t, []string{alice.ID}, listed("alice-token"))
+	require.Equal(t, []string{bob.ID}, listed("bob-token"))
+	require.ElementsMatch(t, []string{h.Session.ID, alice.ID, bob.ID}, listed("admin-token"))
+
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodGet, "/api/sessions/"+alice.ID+"/messages", "").Code)
+	require.Equal(t, http.StatusOK, serve(t, s, "alice-token", http.MethodGet, "/api/sessions/"+alice.ID+"/messages", "").Code)
+	require.Equal(t, http.StatusOK, serve(t, s, "admin-token", http.MethodGet, "/api/sessions/"+alice.ID+"/messages", "").Code)
+
+	var entry auditEntry
+	require.NoError(t, json.NewDecoder(&audit).Decode(&entry))
+	require.Equal(t, "alice", entry.User)
+	require.Equal(t, "session_created", entry.Action)
+	require.Equal(t, alice.ID, entry.SessionID)
+}
+
+func TestServer_UserBudget(t *testing.T) {
+	var audit bytes.Buffer
+	s, h := newUsersTestServer(t, &audit)
+
+	sess, err := h.Sessions.CreateForUser(t.Context(), "Expensive", "alice")
+	require.NoError(t, err)
+	sess.Cost = 1.5
+	_, err = h.Sessions.Save(t.Context(), sess)
+	require.NoError(t, err)
+
+	rec := serve(t, s, "alice-token", http.MethodGet, "/api/me", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"name": "alice", "admin": false, "budget": 1, "spent": 1.5}`, rec.Body.String())
+
+	rec = serve(t, s, "alice-token", http.MethodPost, "/api/sessions", `{"prompt": "Fix the tests"}`)
+	require.Equal(t, http.StatusPaymentRequired, rec.Code)
+	require.Contains(t, rec.Body.String(), "budget of $1.00 reached")
+	require.Contains(t, audit.String(), `"action":"budget_exceeded"`)
+}
+
+func TestServer_UserPermissionPolicy(t *testing.T) {
+	var audit bytes.Buffer
+	s, h := newUsersTestServer(t, &audit, agenttest.WithPermissionRequests())
+
+	alice, err := h.Sessions.CreateForUser(t.Context(), "Alice", "alice")
+	require.NoError(t, err)
+	task, err := h.Sessions.CreateTaskSession(t.Context(), "task-call", alice.ID, "Search")
+	require.NoError(t, err)
+
+	request := func(sess session.Session, toolCallID, toolName string) chan bool {
+		granted := make(chan bool, 1)
+		go func() {
+			granted <- h.Permissions.Request(permission.CreatePermissionRequest{
+				SessionID:  sess.ID,
+				ToolCallID: toolCallID,
+				ToolName:   toolName,
+				Action:     "read",
+				Path:       ".",
+			})
+		}()
+		return granted
+	}
+	answer := func(granted chan bool) bool {
+		select {
+		case got := <-granted:
+			return got
+		case <-time.After(5 * time.Second):
+			t.Fatal("permission request wasn't answered")
+			return false
+		}
+	}
+
+	require.True(t, answer(request(task, "view-call", "view")))
+	require.False(t, answer(request(alice, "fetch-call", "fetch")))
+
+	granted := request(alice, "bash-call", "bash")
+	require.Eventually(t, func() bool {
+		_, ok := s.pending.Get("bash-call")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	require.JSONEq(t, `[]`, serve(t, s, "bob-token", http.MethodGet, "/api/permissions", "").Body.String())
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodPost, "/api/permissions/bash-call", `{"allow": true}`).Code)
+	require.Equal(t, http.StatusNoContent, serve(t, s, "alice-token", http.MethodPost, "/api/permissions/bash-call", `{"allow": true}`).Code)
+	require.True(t, answer(granted))
+
+	require.Contains(t, audit.String(), `"action":"permission_granted_by_policy"`)
+	require.Contains(t, audit.String(), `"action":"permission_denied_by_policy"`)
+	require.Contains(t, audit.String(), `"user":"alice","action":"permission_granted","session_id":"`+alice.ID)
+}
+
+func TestServer_SessionPermissionRules(t *testing.T) {
+	var audit bytes.Buffer
+	s, h := newUsersTestServer(t, &audit, agenttest.WithPermissionRequests())
+	alice, err := h.Sessions.CreateForUser(t.Context(), "Alice", "alice")
+	require.NoError(t, err)
+	rules := "/api/sessions/" + alice.ID + "/permissions"
+
+	require.JSONEq(t, `{"rules": []}`, serve(t, s, "alice-token", http.MethodGet, rules, "").Body.String())
+	require.Equal(t, http.StatusNotFound, serve(t, s, "bob-token", http.MethodPut, rules, `{"rules": []}`).Code)
+	require.Equal(t, http.StatusBadRequest, serve(t, s, "alice-token", http.MethodPut, rules, `{"rules": [{"tool": "bash", "decision": "maybe"}]}`).Code)
+	body := `{"rules": [{"tool": "bash", "decision": "allow", "commands": ["go test"]}]}`
+	require.Equal(t, http.StatusNoContent, serve(t, s, "alice-token", http.MethodPut, rules, body).Code)
+	require.JSONEq(t, body, serve(t, s, "alice-token", http.MethodGet, rules, "").Body.String())
+
+	require.True(t, h.Permissions.Request(permission.CreatePermissionRequest{
+		SessionID: alice.ID,
+		ToolName:  "bash",
+		Action:    "execute",
+		Params:    map[string]string{"command": "go test ./..."},
+	}))
+	require.Contains(t, audit.String(), `"user":"alice","action":"permission_rules_set","session_id":"`+alice.ID)
+}
+
+func TestServer_SessionRulesWithinPolicy(t *testing.T) {
+	h := agenttest.New(t, agenttest.WithPermissionRequests())
+	s := New(t.Context(), &app.App{
+		Sessions:    h.Sessions,
+		Messages:    h.Messages,
+		Permissions: h.Permissions,
+		CoderAgent:  h.Agent,
+	}, Options{
+		Users: []User{
+			{Name: "dana", Token: "dana-token", DeniedTools: []string{"bash"}},
+			{Name: "jun", Token: "jun-token", Role: "junior"},
+		},
+		Roles: map[string]Role{
+			"junior": {Tools: []string{"view", "edit:write"}, Approve: ApproveOwn},
+		},
+		Audit: &bytes.Buffer{},
+	})
+	setRules := func(user, body string) int {
+		sess, err := h.Sessions.CreateForUser(t.Context(), "Rules", user)
+		require.NoError(t, err)
+		return serve(t, s, user+"-token", http.MethodPut, "/api/sessions/"+sess.ID+"/permissions", body).Code
+	}
+
+	require.Equal(t, http.StatusForbidden, setRules("dana", `{"rules": [{"tool": "bash", "decision": "allow"}]}`))
+	require.Equal(t, http.StatusForbidden, setRules("dana", `{"rules": [{"tool": "*", "decision": "allow", "paths": ["src"]}]}`))
+	require.Equal(t, http.StatusForbidden, setRules("dana", `{"rules": [{"tool": "bash:execute", "decision": "allow", "commands": ["go test"]}]}`))
+	require.Equal(t, http.StatusNoContent, setRules("dana", `{"rules": [{"tool": "bash", "decision": "deny"}, {"tool": "edit", "decision": "allow"}]}`))
+
+	require.Equal(t, http.StatusForbidden, setRules("jun", `{"rules": [{"tool": "bash", "decision": "allow"}]}`))
+	require.Equal(t, http.StatusForbidden, setRules("jun", `{"rules": [{"tool": "edit", "decision": "allow"}]}`), "the role only has edit:write")
+	require.Equal(t, http.StatusForbidden, setRules("jun", `{"rules": [{"tool": "v*", "decision": "allow"}]}`))
+	require.Equal(t, http.StatusNoContent, setRules("jun", `{"rules": [{"tool": "view", "decision": "allow"}, {"tool": "edit:write", "decision": "allow"}]}`))
+}
+
+func TestServer_RoleBeforeRulesAndGrants(t *testing.T) {
+	h := agenttest.New(t)
+	grants := permission.NewGrants(t.TempDir())
+	require.NoError(t, grants.Add(permission.Grant{ToolName: "edit", Action: "write", Paths: []string{"./main.go"}}))
+	rules := []config.PermissionRule{{Tool: "bash", Decision: config.PermissionAllow, Commands: []string{"go test"}}}
+
+	// Requests are skipped like on a --yolo server, or answered by the rule
+	// and the grant.
+	for _, skip := range []bool{false, true} {
+		permissions := permission.NewPermissionService(h.WorkingDir, skip, nil, false, permission.WithRules(rules), permission.WithGrants(grants))
+		var audit bytes.Buffer
+		New(t.Context(), &app.App{
+			Sessions:    h.Sessions,
+			Messages:    h.Messages,
+			Permissions: permissions,
+			CoderAgent:  h.Agent,
+		}, Options{
+			Users: []User{
+				{Name: "vic", Token: "vic-token", Role: RoleViewer},
+				{Name: "cam", Token: "cam-token", Role: RoleContributor},
+			},
+			Audit: &audit,
+		})
+		vic, err := h.Sessions.CreateForUser(t.Context(), "Viewer", "vic")
+		require.NoError(t, err)
+		cam, err := h.Sessions.CreateForUser(t.Context(), "Contributor", "cam")
+		require.NoError(t, err)
+		request := func(sess session.Session, toolName, action string, params any) bool {
+			return permissions.Request(permission.CreatePermissionRequest{
+				SessionID: sess.ID,
+				ToolName:  toolName,
+				Action:    action,
+				Params:    params,
+			})
+		}
+
+		require.False(t, request(vic, "bash", "execute", map[string]string{"command": "go test ./..."}))
+		require.False(t, request(vic, "edit", "write", map[string]string{"file_path": "main.go"}))
+		require.True(t, request(cam, "bash", "execute", map[string]string{"command": "go test ./..."}))
+		require.True(t, request(cam, "edit", "write", map[string]string{"file_path": "main.go"}))
+
+		verdict := permissions.Evaluate(vic.ID, "bash-call", "bash", `{"command": "go test ./..."}`)
+		require.Equal(t, config.PermissionDeny, verdict.Decision)
+		require.Equal(t, "policy: vic can't use bash", verdict.Rule)
+		require.Empty(t, permissions.Evaluate(vic.ID, "view-call", "view", `{"file_path": "main.go"}`).Decision)
+		require.Empty(t, permissions.Evaluate(cam.ID, "bash-call", "bash", `{"command": "go vet ./..."}`).Decision)
+		require.Contains(t, audit.String(), `"user":"vic","action":"permission_denied_by_policy"`)
+	}
+}
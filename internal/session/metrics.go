@@ -0,0 +1,86 @@
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// Metric is the timing of a request streamed from a model.
+type Metric struct {
+	SessionID string
+	Provider  string
+	Model     string
+	// FirstToken is the time to the first token, 0 if none was received.
+	FirstToken time.Duration
+	// Generation is the time from the first token to the end of the
+	// response.
+	Generation   time.Duration
+	OutputTokens int64
+	// Error is what the request failed with, empty if it succeeded.
+	Error string
+}
+
+// ModelStats adds up the metrics of the requests to a model. Timings and
+// tokens only count the successful requests.
+type ModelStats struct {
+	Provider      string
+	Model         string
+	Requests      int64
+	Errors        int64
+	AvgFirstToken time.Duration
+	OutputTokens  int64
+	Generation    time.Duration
+}
+
+// TokensPerSecond is the rate the model outputs tokens at once it started.
+func (s ModelStats) TokensPerSecond() float64 {
+	if s.Generation <= 0 {
+		return 0
+	}
+	return float64(s.OutputTokens) / s.Generation.Seconds()
+}
+
+// ErrorRate is the share of the requests that failed.
+func (s ModelStats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+func (s *service) RecordMetric(ctx context.Context, metric Metric) error {
+	_, err := s.q.CreateRequestMetric(ctx, db.CreateRequestMetricParams{
+		ID:           uuid.New().String(),
+		SessionID:    metric.SessionID,
+		Provider:     metric.Provider,
+		Model:        metric.Model,
+		FirstTokenMs: metric.FirstToken.Milliseconds(),
+		GenerationMs: metric.Generation.Milliseconds(),
+		OutputTokens: metric.OutputTokens,
+		Error:        metric.Error,
+	})
+	return err
+}
+
+func (s *service) ModelStats(ctx context.Context, t time.Time) ([]ModelStats, error) {
+	rows, err := s.q.ListModelStats(ctx, t.Unix())
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]ModelStats, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, ModelStats{
+			Provider:      row.Provider,
+			Model:         row.Model,
+			Requests:      row.Requests,
+			Errors:        row.Errors,
+			AvgFirstToken: time.Duration(row.AvgFirstTokenMs * float64(time.Millisecond)),
+			OutputTokens:  row.OutputTokens,
+			Generation:    time.Duration(row.GenerationMs) * time.Millisecond,
+		})
+	}
+	return stats, nil
+}
@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -17,20 +18,53 @@ type Session struct {
 	PromptTokens     int64
 	CompletionTokens int64
 	SummaryMessageID string
-	Cost             float64
-	CreatedAt        int64
-	UpdatedAt        int64
+	// KeptMessageID is the first message kept as is when the conversation
+	// was compacted, the messages before it are replaced by the summary.
+	KeptMessageID string
+	Cost          float64
+	CacheCost     float64
+	// ReasoningCost is the part of the cost spent on the reasoning of the
+	// models.
+	ReasoningCost float64
+	// UserID is the user of a shared server who created the session, empty
+	// for local sessions.
+	UserID string
+	// ForkedFromID is the session this one was forked from, and
+	// ForkMessageID the last message of it the fork started with.
+	ForkedFromID  string
+	ForkMessageID string
+	CreatedAt     int64
+	UpdatedAt     int64
 }
 
 type Service interface {
 	pubsub.Suscriber[Session]
 	Create(ctx context.Context, title string) (Session, error)
+	CreateForUser(ctx context.Context, title, userID string) (Session, error)
 	CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error)
 	CreateTaskSession(ctx context.Context, toolCallID, parentSessionID, title string) (Session, error)
+	// CreateFork creates a session forked from the origin after the message,
+	// empty to fork before the first one.
+	CreateFork(ctx context.Context, origin Session, forkMessageID string) (Session, error)
 	Get(ctx context.Context, id string) (Session, error)
 	List(ctx context.Context) ([]Session, error)
 	Save(ctx context.Context, session Session) (Session, error)
 	Delete(ctx context.Context, id string) error
+
+	// RecordUsage adds a response of a model to the usage ledger.
+	RecordUsage(ctx context.Context, usage Usage) error
+	// Usage returns the usage of the session and its task sessions.
+	Usage(ctx context.Context, sessionID string) (UsageTotals, error)
+	// UsageSince returns the usage of all the sessions since t.
+	UsageSince(ctx context.Context, t time.Time) (UsageTotals, error)
+	// ListUsage returns the responses of the usage ledger since t, oldest
+	// first.
+	ListUsage(ctx context.Context, t time.Time) ([]UsageRecord, error)
+
+	// RecordMetric records the timing of a streamed request.
+	RecordMetric(ctx context.Context, metric Metric) error
+	// ModelStats returns the metrics of the requests since t, by model.
+	ModelStats(ctx context.Context, t time.Time) ([]ModelStats, error)
 }
 
 type service struct {
@@ -39,9 +73,14 @@ type service struct {
 }
 
 func (s *service) Create(ctx context.Context, title string) (Session, error) {
+	return s.CreateForUser(ctx, title, "")
+}
+
+func (s *service) CreateForUser(ctx context.Context, title, userID string) (Session, error) {
 	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
-		ID:    uuid.New().String(),
-		Title: title,
+		ID:     uuid.New().String(),
+		Title:  title,
+		UserID: userID,
 	})
 	if err != nil {
 		return Session{}, err
@@ -65,6 +104,24 @@ func (s *service) CreateTaskSession(ctx context.Context, toolCallID, parentSessi
 	return session, nil
 }
 
+func (s *service) CreateFork(ctx context.Context, origin Session, forkMessageID string) (Session, error) {
+	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
+		ID:               uuid.New().String(),
+		Title:            origin.Title + " (fork)",
+		PromptTokens:     origin.PromptTokens,
+		CompletionTokens: origin.CompletionTokens,
+		UserID:           origin.UserID,
+		ForkedFromID:     sql.NullString{String: origin.ID, Valid: true},
+		ForkMessageID:    sql.NullString{String: forkMessageID, Valid: forkMessageID != ""},
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	session := s.fromDBItem(dbSession)
+	s.Publish(pubsub.CreatedEvent, session)
+	return session, nil
+}
+
 func (s *service) CreateTitleSession(ctx context.Context, parentSessionID string) (Session, error) {
 	dbSession, err := s.q.CreateSession(ctx, db.CreateSessionParams{
 		ID:              "title-" + parentSessionID,
@@ -110,7 +167,13 @@ func (s *service) Save(ctx context.Context, session Session) (Session, error) {
 			String: session.SummaryMessageID,
 			Valid:  session.SummaryMessageID != "",
 		},
-		Cost: session.Cost,
+		KeptMessageID: sql.NullString{
+			String: session.KeptMessageID,
+			Valid:  session.KeptMessageID != "",
+		},
+		Cost:          session.Cost,
+		CacheCost:     session.CacheCost,
+		ReasoningCost: session.ReasoningCost,
 	})
 	if err != nil {
 		return Session{}, err
@@ -141,7 +204,13 @@ func (s service) fromDBItem(item db.Session) Session {
 		PromptTokens:     item.PromptTokens,
 		CompletionTokens: item.CompletionTokens,
 		SummaryMessageID: item.SummaryMessageID.String,
+		KeptMessageID:    item.KeptMessageID.String,
 		Cost:             item.Cost,
+		CacheCost:        item.CacheCost,
+		ReasoningCost:    item.ReasoningCost,
+		UserID:           item.UserID,
+		ForkedFromID:     item.ForkedFromID.String,
+		ForkMessageID:    item.ForkMessageID.String,
 		CreatedAt:        item.CreatedAt,
 		UpdatedAt:        item.UpdatedAt,
 	}
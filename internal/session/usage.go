@@ -0,0 +1,176 @@
+package session
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/google/uuid"
+)
+
+// Usage is a response of a model, recorded in the usage ledger. Unlike the
+// token counts of the session, which are the size of the latest exchange,
+// the ledger adds up what was spent.
+type Usage struct {
+	SessionID    string
+	Provider     string
+	Model        string
+	InputTokens  int64
+	OutputTokens int64
+	// CachedTokens are the part of the input tokens read from the prompt
+	// cache.
+	CachedTokens int64
+	Cost         float64
+	// Latency is the time from the request to the end of the response.
+	Latency time.Duration
+}
+
+// Groupings of the usage reports.
+const (
+	UsageByModel    = "model"
+	UsageByProvider = "provider"
+	UsageBySession  = "session"
+	UsageByDay      = "day"
+)
+
+// UsageRecord is a response of the usage ledger.
+type UsageRecord struct {
+	Usage
+	CreatedAt time.Time
+}
+
+// UsageGroup adds up the responses of the usage ledger sharing a model,
+// provider, session, or day.
+type UsageGroup struct {
+	Key          string
+	Requests     int64
+	InputTokens  int64
+	OutputTokens int64
+	CachedTokens int64
+	Cost         float64
+	Latency      time.Duration
+}
+
+// AvgLatency is the average time the responses took.
+func (g UsageGroup) AvgLatency() time.Duration {
+	if g.Requests == 0 {
+		return 0
+	}
+	return g.Latency / time.Duration(g.Requests)
+}
+
+// GroupUsage adds up the records by model, provider, session, or day. Days
+// are in order, the other groups the most expensive first.
+func GroupUsage(records []UsageRecord, by string) ([]UsageGroup, error) {
+	var key func(UsageRecord) string
+	switch by {
+	case UsageByModel:
+		key = func(r UsageRecord) string { return r.Model }
+	case UsageByProvider:
+		key = func(r UsageRecord) string { return r.Provider }
+	case UsageBySession:
+		key = func(r UsageRecord) string { return r.SessionID }
+	case UsageByDay:
+		key = func(r UsageRecord) string { return r.CreatedAt.Local().Format(time.DateOnly) }
+	default:
+		return nil, fmt.Errorf("unknown grouping %q, expected model, provider, session, or day", by)
+	}
+
+	var groups []UsageGroup
+	index := make(map[string]int)
+	for _, r := range records {
+		k := key(r)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, UsageGroup{Key: k})
+		}
+		g := &groups[i]
+		g.Requests++
+		g.InputTokens += r.InputTokens
+		g.OutputTokens += r.OutputTokens
+		g.CachedTokens += r.CachedTokens
+		g.Cost += r.Cost
+		g.Latency += r.Latency
+	}
+	slices.SortFunc(groups, func(a, b UsageGroup) int {
+		if by == UsageByDay {
+			return cmp.Compare(a.Key, b.Key)
+		}
+		return cmp.Or(cmp.Compare(b.Cost, a.Cost), cmp.Compare(a.Key, b.Key))
+	})
+	return groups, nil
+}
+
+// UsageTotals adds up the usage ledger.
+type UsageTotals struct {
+	Tokens int64
+	// InputTokens and OutputTokens split the tokens of a session.
+	InputTokens  int64
+	OutputTokens int64
+	Cost         float64
+}
+
+func (s *service) RecordUsage(ctx context.Context, usage Usage) error {
+	_, err := s.q.CreateUsage(ctx, db.CreateUsageParams{
+		ID:           uuid.New().String(),
+		SessionID:    usage.SessionID,
+		Provider:     usage.Provider,
+		Model:        usage.Model,
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		CachedTokens: usage.CachedTokens,
+		Cost:         usage.Cost,
+		LatencyMs:    usage.Latency.Milliseconds(),
+	})
+	return err
+}
+
+func (s *service) Usage(ctx context.Context, sessionID string) (UsageTotals, error) {
+	row, err := s.q.GetSessionUsage(ctx, sessionID)
+	if err != nil {
+		return UsageTotals{}, err
+	}
+	return UsageTotals{
+		Tokens:       row.Tokens,
+		InputTokens:  row.InputTokens,
+		OutputTokens: row.OutputTokens,
+		Cost:         row.Cost,
+	}, nil
+}
+
+func (s *service) UsageSince(ctx context.Context, t time.Time) (UsageTotals, error) {
+	row, err := s.q.GetUsageSince(ctx, t.Unix())
+	if err != nil {
+		return UsageTotals{}, err
+	}
+	return UsageTotals{Tokens: row.Tokens, Cost: row.Cost}, nil
+}
+
+func (s *service) ListUsage(ctx context.Context, t time.Time) ([]UsageRecord, error) {
+	rows, err := s.q.ListUsageSince(ctx, t.Unix())
+	if err != nil {
+		return nil, err
+	}
+	records := make([]UsageRecord, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, UsageRecord{
+			Usage: Usage{
+				SessionID:    row.SessionID,
+				Provider:     row.Provider,
+				Model:        row.Model,
+				InputTokens:  row.InputTokens,
+				OutputTokens: row.OutputTokens,
+				CachedTokens: row.CachedTokens,
+				Cost:         row.Cost,
+				Latency:      time.Duration(row.LatencyMs) * time.Millisecond,
+			},
+			CreatedAt: time.Unix(row.CreatedAt, 0),
+		})
+	}
+	return records, nil
+}
@@ -0,0 +1,278 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/interp"
+)
+
+// Sandbox backends.
+const (
+	SandboxContainer = "container"
+	SandboxLandlock  = "landlock"
+	SandboxSeatbelt  = "seatbelt"
+)
+
+// SandboxHelperArg is the first argument of the executable when the landlock
+// sandbox runs it to restrict itself, see [RunSandboxHelper].
+const SandboxHelperArg = "__sandbox"
+
+// Sandbox runs the programs started by a shell isolated from the host. The
+// shell checks its own redirections with CanWrite, and doesn't run its core
+// utilities in process, so that everything goes through the sandbox.
+type Sandbox interface {
+	// Command returns the command running args in dir, with env.
+	Command(ctx context.Context, dir string, env, args []string) (*exec.Cmd, error)
+	// CanWrite reports whether programs may write path.
+	CanWrite(path string) bool
+}
+
+// SandboxOptions configures a sandbox.
+type SandboxOptions struct {
+	Backend string
+	// Writable are the absolute paths programs may write, with the files
+	// under them.
+	Writable []string
+	// Network lets programs open network connections.
+	Network bool
+	// Runtime is the container runtime, docker or podman, the first one
+	// found if empty.
+	Runtime string
+	// Image is the image the container backend runs programs in.
+	Image string
+}
+
+// NewSandbox returns the sandbox of the backend, or an error if the backend
+// isn't available here.
+func NewSandbox(opts SandboxOptions) (Sandbox, error) {
+	policy := sandboxPolicy{network: opts.Network}
+	for _, path := range opts.Writable {
+		policy.writable = append(policy.writable, resolvePath(path))
+	}
+	switch opts.Backend {
+	case SandboxContainer:
+		return newContainerSandbox(policy, opts.Runtime, opts.Image)
+	case SandboxLandlock:
+		return newLandlockSandbox(policy)
+	case SandboxSeatbelt:
+		return newSeatbeltSandbox(policy)
+	}
+	return nil, fmt.Errorf("unknown sandbox backend %q", opts.Backend)
+}
+
+// sandboxDevices are the devices programs may always write.
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/tty"}
+
+// sandboxPolicy is what the programs of a sandbox may do.
+type sandboxPolicy struct {
+	writable []string
+	network  bool
+}
+
+func (p sandboxPolicy) CanWrite(path string) bool {
+	path = resolvePath(path)
+	if slices.Contains(sandboxDevices, path) {
+		return true
+	}
+	for _, dir := range p.writable {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath returns the path without symbolic links, so that links in
+// writable directories can't point out of them. Missing files are resolved
+// from their closest existing parent.
+func resolvePath(path string) string {
+	path = filepath.Clean(path)
+	var missing []string
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...)
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(append([]string{path}, missing...)...)
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+// containerSandbox runs every program in a new container, with the writable
+// directories mounted at the same paths.
+type containerSandbox struct {
+	sandboxPolicy
+	runtime string
+	image   string
+}
+
+func newContainerSandbox(policy sandboxPolicy, runtime, image string) (Sandbox, error) {
+	if image == "" {
+		return nil, errors.New("the container backend needs an image")
+	}
+	if runtime == "" {
+		for _, candidate := range []string{"docker", "podman"} {
+			if _, err := exec.LookPath(candidate); err == nil {
+				runtime = candidate
+				break
+			}
+		}
+		if runtime == "" {
+			return nil, errors.New("the container backend needs docker or podman, neither was found")
+		}
+	} else if _, err := exec.LookPath(runtime); err != nil {
+		return nil, fmt.Errorf("container runtime %s not found: %w", runtime, err)
+	}
+	return &containerSandbox{sandboxPolicy: policy, runtime: runtime, image: image}, nil
+}
+
+func (s *containerSandbox) Command(ctx context.Context, dir string, env, args []string) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, s.runtime, s.runArgs(dir, env, args)...)
+	// Interrupting the client stops the container, killing it wouldn't.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = 5 * time.Second
+	return cmd, nil
+}
+
+func (s *containerSandbox) runArgs(dir string, env, args []string) []string {
+	runArgs := []string{"run", "--rm", "-i", "-w", dir}
+	if !s.network {
+		runArgs = append(runArgs, "--network", "none")
+	}
+	// Files are written as the user, not root.
+	if uid := os.Getuid(); uid >= 0 {
+		if s.runtime == "podman" {
+			runArgs = append(runArgs, "--userns=keep-id")
+		} else {
+			runArgs = append(runArgs, "--user", fmt.Sprintf("%d:%d", uid, os.Getgid()))
+		}
+	}
+	for _, path := range s.writable {
+		runArgs = append(runArgs, "-v", path+":"+path)
+	}
+	// The environment of the host doesn't fit the image, only the variables
+	// set in the shell are passed.
+	host := os.Environ()
+	for _, kv := range env {
+		if !slices.Contains(host, kv) {
+			runArgs = append(runArgs, "-e", kv)
+		}
+	}
+	runArgs = append(runArgs, s.image)
+	return append(runArgs, args...)
+}
+
+// seatbeltSandbox runs programs with the sandbox-exec of macOS.
+type seatbeltSandbox struct {
+	sandboxPolicy
+	profile string
+}
+
+func newSeatbeltSandbox(policy sandboxPolicy) (Sandbox, error) {
+	if runtime.GOOS != "darwin" {
+		return nil, errors.New("the seatbelt backend needs macOS")
+	}
+	return &seatbeltSandbox{sandboxPolicy: policy, profile: seatbeltProfile(policy)}, nil
+}
+
+func (s *seatbeltSandbox) Command(ctx context.Context, dir string, env, args []string) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, "/usr/bin/sandbox-exec", append([]string{"-p", s.profile}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	return cmd, nil
+}
+
+// seatbeltProfile returns the sandbox profile of the policy.
+func seatbeltProfile(p sandboxPolicy) string {
+	var b strings.Builder
+	b.WriteString("(version 1)\n(allow default)\n(deny file-write*)\n(allow file-write*\n")
+	for _, path := range p.writable {
+		fmt.Fprintf(&b, "  (subpath %s)\n", strconv.Quote(path))
+	}
+	for _, device := range sandboxDevices {
+		fmt.Fprintf(&b, "  (literal %s)\n", strconv.Quote(device))
+	}
+	b.WriteString(")\n")
+	if !p.network {
+		b.WriteString("(deny network*)\n")
+	}
+	return b.String()
+}
+
+// sandboxHandler runs the programs in the sandbox.
+func (s *Shell) sandboxHandler() func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+	return func(next interp.ExecHandlerFunc) interp.ExecHandlerFunc {
+		return func(ctx context.Context, args []string) error {
+			hc := interp.HandlerCtx(ctx)
+			cmd, err := s.sandbox.Command(ctx, hc.Dir, execEnv(hc.Env), args)
+			if err != nil {
+				fmt.Fprintln(hc.Stderr, err)
+				return interp.NewExitStatus(126)
+			}
+			cmd.Stdin = hc.Stdin
+			cmd.Stdout = hc.Stdout
+			cmd.Stderr = hc.Stderr
+
+			err = cmd.Run()
+			var exitErr *exec.ExitError
+			switch {
+			case err == nil:
+				return nil
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case errors.As(err, &exitErr):
+				// Programs killed by a signal exit with 255.
+				return interp.NewExitStatus(uint8(exitErr.ExitCode()))
+			default:
+				fmt.Fprintln(hc.Stderr, err)
+				return interp.NewExitStatus(127)
+			}
+		}
+	}
+}
+
+// sandboxOpenHandler denies the redirections to files the sandbox doesn't
+// let programs write.
+func (s *Shell) sandboxOpenHandler() interp.OpenHandlerFunc {
+	open := interp.DefaultOpenHandler()
+	return func(ctx context.Context, path string, flag int, perm os.FileMode) (io.ReadWriteCloser, error) {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+			abs := path
+			if !filepath.IsAbs(abs) {
+				abs = filepath.Join(interp.HandlerCtx(ctx).Dir, path)
+			}
+			if !s.sandbox.CanWrite(abs) {
+				return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+			}
+		}
+		return open(ctx, path, flag, perm)
+	}
+}
+
+// execEnv returns the exported variables of env, as programs get them.
+func execEnv(env expand.Environ) []string {
+	var list []string
+	for name, vr := range env.Each {
+		if vr.IsSet() && vr.Exported && vr.Kind == expand.String {
+			list = append(list, name+"="+vr.String())
+		}
+	}
+	return list
+}
@@ -0,0 +1,261 @@
+//go:build linux
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// landlockSandbox runs programs through the executable of the app, which
+// restricts itself with landlock and seccomp before running them, see
+// [RunSandboxHelper].
+type landlockSandbox struct {
+	sandboxPolicy
+	executable string
+}
+
+func newLandlockSandbox(policy sandboxPolicy) (Sandbox, error) {
+	if _, err := landlockABI(); err != nil {
+		return nil, err
+	}
+	if _, ok := auditArch[runtime.GOARCH]; !ok && !policy.network {
+		return nil, fmt.Errorf("the landlock backend can't deny the network on %s", runtime.GOARCH)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the executable: %w", err)
+	}
+	return &landlockSandbox{sandboxPolicy: policy, executable: executable}, nil
+}
+
+func (s *landlockSandbox) Command(ctx context.Context, dir string, env, args []string) (*exec.Cmd, error) {
+	helperArgs := []string{SandboxHelperArg}
+	if s.network {
+		helperArgs = append(helperArgs, "--network")
+	}
+	for _, path := range s.writable {
+		helperArgs = append(helperArgs, "--write", path)
+	}
+	helperArgs = append(helperArgs, "--")
+	cmd := exec.CommandContext(ctx, s.executable, append(helperArgs, args...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	return cmd, nil
+}
+
+// RunSandboxHelper restricts the process as args tell, and runs the program
+// at their end in its place. The landlock sandbox runs the executable of the
+// app with SandboxHelperArg and args, which must then call it before
+// anything else.
+func RunSandboxHelper(args []string) {
+	var writable []string
+	network := false
+	for len(args) > 0 && args[0] != "--" {
+		switch {
+		case args[0] == "--network":
+			network = true
+			args = args[1:]
+		case args[0] == "--write" && len(args) > 1:
+			writable = append(writable, args[1])
+			args = args[2:]
+		default:
+			sandboxHelperExit(126, fmt.Errorf("invalid argument %s", args[0]))
+		}
+	}
+	if len(args) < 2 {
+		sandboxHelperExit(126, errors.New("no program to run"))
+	}
+	args = args[1:]
+
+	// The environment is the one the shell passed: the app may have
+	// changed its own by now.
+	env := initialEnviron()
+	path, err := lookPath(args[0], env)
+	if err != nil {
+		sandboxHelperExit(127, err)
+	}
+
+	// Restrictions apply to the thread, which then becomes the program.
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		sandboxHelperExit(126, fmt.Errorf("failed to set no_new_privs: %w", err))
+	}
+	if err := restrictWrites(writable); err != nil {
+		sandboxHelperExit(126, err)
+	}
+	if !network {
+		if err := denySockets(); err != nil {
+			sandboxHelperExit(126, err)
+		}
+	}
+	err = unix.Exec(path, args, env)
+	sandboxHelperExit(126, fmt.Errorf("failed to run %s: %w", args[0], err))
+}
+
+func sandboxHelperExit(code int, err error) {
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(code)
+}
+
+func initialEnviron() []string {
+	data, err := os.ReadFile("/proc/self/environ")
+	if err != nil {
+		return os.Environ()
+	}
+	var env []string
+	for kv := range bytes.SplitSeq(data, []byte{0}) {
+		if len(kv) > 0 {
+			env = append(env, string(kv))
+		}
+	}
+	return env
+}
+
+// lookPath finds the program in the PATH of env.
+func lookPath(file string, env []string) (string, error) {
+	if strings.Contains(file, "/") {
+		if _, err := os.Stat(file); err != nil {
+			return "", err
+		}
+		return file, nil
+	}
+	for _, kv := range env {
+		value, ok := strings.CutPrefix(kv, "PATH=")
+		if !ok {
+			continue
+		}
+		for dir := range strings.SplitSeq(value, string(filepath.ListSeparator)) {
+			if dir == "" {
+				continue
+			}
+			path := filepath.Join(dir, file)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%q: executable file not found in $PATH", file)
+}
+
+// landlockABI returns the version of the landlock ABI of the kernel.
+func landlockABI() (int, error) {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0, fmt.Errorf("landlock isn't available: %w", errno)
+	}
+	return int(abi), nil
+}
+
+// landlockWriteAccess returns the rights to write files that the ABI
+// handles.
+func landlockWriteAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return access
+}
+
+// restrictWrites denies writing anything but the writable paths and the
+// devices to the thread.
+func restrictWrites(writable []string) error {
+	abi, err := landlockABI()
+	if err != nil {
+		return err
+	}
+	access := landlockWriteAccess(abi)
+	attr := unix.LandlockRulesetAttr{Access_fs: access}
+	ruleset, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create the landlock ruleset: %w", errno)
+	}
+	defer unix.Close(int(ruleset))
+
+	for _, path := range append(writable, sandboxDevices...) {
+		fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			// Missing paths can't be written anyway.
+			continue
+		}
+		allowed := access
+		var stat unix.Stat_t
+		if err := unix.Fstat(fd, &stat); err == nil && stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+			allowed &= unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+		}
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: allowed, Parent_fd: int32(fd)}
+		_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, ruleset, unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+		unix.Close(fd)
+		if errno != 0 {
+			return fmt.Errorf("failed to allow writing %s: %w", path, errno)
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, ruleset, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to apply the landlock ruleset: %w", errno)
+	}
+	return nil
+}
+
+// auditArch is the audit architecture of the supported GOARCH, which
+// seccomp filters check.
+var auditArch = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+// denySockets makes creating sockets fail for the thread. Socket pairs still
+// work.
+func denySockets() error {
+	arch, ok := auditArch[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("can't deny the network on %s", runtime.GOARCH)
+	}
+	filter := []unix.SockFilter{
+		// Other architectures, such as 32-bit calls on amd64, are denied.
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 4),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+		// So are the x32 calls of amd64.
+		bpfStmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, 0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, 0x40000000, 2, 0),
+		bpfJump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_SOCKET, 1, 0),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+		bpfStmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.EACCES)),
+	}
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := unix.Syscall6(unix.SYS_PRCTL, unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("failed to install the seccomp filter: %w", errno)
+	}
+	return nil
+}
+
+func bpfStmt(code uint16, k uint32) unix.SockFilter {
+	return unix.SockFilter{Code: code, K: k}
+}
+
+func bpfJump(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+	return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
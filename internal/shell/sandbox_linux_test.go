@@ -0,0 +1,48 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// The landlock sandbox runs the test binary as its helper.
+	if len(os.Args) > 1 && os.Args[1] == SandboxHelperArg {
+		RunSandboxHelper(os.Args[2:])
+	}
+	os.Exit(m.Run())
+}
+
+func TestLandlockSandbox(t *testing.T) {
+	if _, err := landlockABI(); err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	sandbox, err := NewSandbox(SandboxOptions{Backend: SandboxLandlock, Writable: []string{dir}})
+	require.NoError(t, err)
+	shell := NewShell(&Options{WorkingDir: dir, Sandbox: sandbox})
+
+	_, stderr, err := shell.Exec(t.Context(), "touch inside.txt && mkdir -p sub && touch sub/inside.txt")
+	require.NoError(t, err, stderr)
+	require.FileExists(t, filepath.Join(dir, "sub", "inside.txt"))
+
+	_, stderr, err = shell.Exec(t.Context(), "touch "+filepath.Join(outside, "outside.txt"))
+	require.Error(t, err)
+	require.Contains(t, stderr, "Permission denied")
+	require.NoFileExists(t, filepath.Join(outside, "outside.txt"))
+
+	_, stderr, err = shell.Exec(t.Context(), "no-such-program")
+	require.Equal(t, 127, ExitCode(err))
+	require.Contains(t, stderr, "executable file not found")
+
+	if _, err := exec.LookPath("bash"); err == nil {
+		_, stderr, err = shell.Exec(t.Context(), `bash -c 'exec 3<>/dev/tcp/127.0.0.1/9'`)
+		require.Error(t, err)
+		require.Contains(t, stderr, "Permission denied")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func newLandlockSandbox(sandboxPolicy) (Sandbox, error) {
+	return nil, errors.New("the landlock backend needs Linux")
+}
+
+// RunSandboxHelper is only supported on Linux, where the landlock sandbox
+// uses it.
+func RunSandboxHelper(args []string) {
+	fmt.Fprintln(os.Stderr, "sandbox: the landlock backend needs Linux")
+	os.Exit(126)
+}
@@ -0,0 +1,97 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSandbox runs programs on the host, recording them.
+type recordingSandbox struct {
+	sandboxPolicy
+	commands [][]string
+}
+
+func (s *recordingSandbox) Command(ctx context.Context, dir string, env, args []string) (*exec.Cmd, error) {
+	s.commands = append(s.commands, args)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env
+	return cmd, nil
+}
+
+func TestSandboxPolicy_CanWrite(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symbolic links need privileges on Windows")
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "out")))
+	policy := sandboxPolicy{writable: []string{resolvePath(dir)}}
+
+	require.True(t, policy.CanWrite(dir))
+	require.True(t, policy.CanWrite(filepath.Join(dir, "new", "file.go")))
+	require.True(t, policy.CanWrite("/dev/null"))
+	require.False(t, policy.CanWrite(outside))
+	require.False(t, policy.CanWrite(dir+"-other"))
+	require.False(t, policy.CanWrite(filepath.Join(dir, "..", "file.go")))
+	require.False(t, policy.CanWrite(filepath.Join(dir, "out", "file.go")))
+}
+
+func TestShell_Sandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Programs of the test are POSIX ones")
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	sandbox := &recordingSandbox{sandboxPolicy: sandboxPolicy{writable: []string{resolvePath(dir)}}}
+	shell := NewShell(&Options{WorkingDir: dir, Sandbox: sandbox})
+
+	// Core utilities go through the sandbox too.
+	stdout, _, err := shell.Exec(t.Context(), "export GREETING=hello; echo $GREETING > greeting.txt && cat greeting.txt; sh -c 'exit 3'")
+	require.Equal(t, 3, ExitCode(err))
+	require.Equal(t, "hello\n", stdout)
+	require.Equal(t, [][]string{{"cat", "greeting.txt"}, {"sh", "-c", "exit 3"}}, sandbox.commands)
+
+	_, stderr, err := shell.Exec(t.Context(), "echo hello > "+filepath.Join(outside, "greeting.txt"))
+	require.Error(t, err)
+	require.Contains(t, stderr, "permission denied")
+	require.NoFileExists(t, filepath.Join(outside, "greeting.txt"))
+	_, _, err = shell.Exec(t.Context(), "echo hello > /dev/null")
+	require.NoError(t, err)
+}
+
+func TestContainerSandbox_RunArgs(t *testing.T) {
+	t.Setenv("CRUSH_SANDBOX_TEST", "host")
+	s := &containerSandbox{
+		sandboxPolicy: sandboxPolicy{writable: []string{"/project", "/tmp"}},
+		runtime:       "podman",
+		image:         "golang:1.24",
+	}
+	args := s.runArgs("/project/cmd", []string{"CRUSH_SANDBOX_TEST=host", "CRUSH_SANDBOX_SET=1"}, []string{"go", "test", "./..."})
+	joined := strings.Join(args, " ")
+	require.True(t, strings.HasPrefix(joined, "run --rm -i -w /project/cmd --network none"), joined)
+	require.Contains(t, joined, "-v /project:/project -v /tmp:/tmp")
+	require.Contains(t, joined, "-e CRUSH_SANDBOX_SET=1")
+	require.NotContains(t, joined, "CRUSH_SANDBOX_TEST")
+	require.True(t, strings.HasSuffix(joined, "golang:1.24 go test ./..."), joined)
+
+	s.network = true
+	require.NotContains(t, strings.Join(s.runArgs("/project", nil, []string{"true"}), " "), "--network")
+}
+
+func TestSeatbeltProfile(t *testing.T) {
+	profile := seatbeltProfile(sandboxPolicy{writable: []string{"/Users/me/project", `/tmp/a "b"`}})
+	require.Contains(t, profile, "(deny file-write*)")
+	require.Contains(t, profile, `(subpath "/Users/me/project")`)
+	require.Contains(t, profile, `(subpath "/tmp/a \"b\"")`)
+	require.Contains(t, profile, `(literal "/dev/null")`)
+	require.Contains(t, profile, "(deny network*)")
+	require.NotContains(t, seatbeltProfile(sandboxPolicy{network: true}), "network")
+}
@@ -15,7 +15,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 
@@ -53,6 +56,8 @@ type Shell struct {
 	mu         sync.Mutex
 	logger     Logger
 	blockFuncs []BlockFunc
+	sandbox    Sandbox
+	stdin      io.Reader
 }
 
 // Options for creating a new shell
@@ -61,6 +66,10 @@ type Options struct {
 	Env        []string
 	Logger     Logger
 	BlockFuncs []BlockFunc
+	// Sandbox runs the programs, if set.
+	Sandbox Sandbox
+	// Stdin is the standard input of the commands, none if nil.
+	Stdin io.Reader
 }
 
 // NewShell creates a new shell instance with the given options
@@ -89,6 +98,8 @@ func NewShell(opts *Options) *Shell {
 		env:        env,
 		logger:     logger,
 		blockFuncs: opts.BlockFuncs,
+		sandbox:    opts.Sandbox,
+		stdin:      opts.Stdin,
 	}
 }
 
@@ -97,7 +108,31 @@ func (s *Shell) Exec(ctx context.Context, command string) (string, string, error
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return s.execPOSIX(ctx, command)
+	return s.execPOSIX(ctx, command, nil)
+}
+
+// ExecStream executes a command in the shell, and also writes its combined
+// output to w while it runs.
+func (s *Shell) ExecStream(ctx context.Context, command string, w io.Writer) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.execPOSIX(ctx, command, w)
+}
+
+// Clone returns a shell with the working directory, the environment, the
+// blockers, and the sandbox of the shell, whose commands run apart from it,
+// e.g. in the background.
+func (s *Shell) Clone() *Shell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &Shell{
+		cwd:        s.cwd,
+		env:        slices.Clone(s.env),
+		logger:     s.logger,
+		blockFuncs: s.blockFuncs,
+		sandbox:    s.sandbox,
+	}
 }
 
 // GetWorkingDir returns the current working directory
@@ -154,6 +189,13 @@ func (s *Shell) SetBlockFuncs(blockFuncs []BlockFunc) {
 	s.blockFuncs = blockFuncs
 }
 
+// SetSandbox sets the sandbox running the programs, none if nil.
+func (s *Shell) SetSandbox(sandbox Sandbox) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = sandbox
+}
+
 // CommandsBlocker creates a BlockFunc that blocks exact command matches
 func CommandsBlocker(bannedCommands []string) BlockFunc {
 	bannedSet := make(map[string]bool)
@@ -209,22 +251,50 @@ func (s *Shell) blockHandler() func(next interp.ExecHandlerFunc) interp.ExecHand
 }
 
 // execPOSIX executes commands using POSIX shell emulation (cross-platform)
-func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string, error) {
+func (s *Shell) execPOSIX(ctx context.Context, command string, w io.Writer) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	var stdoutW, stderrW io.Writer = &stdout, &stderr
+	if w != nil {
+		stdoutW = io.MultiWriter(&stdout, w)
+		stderrW = io.MultiWriter(&stderr, w)
+	}
+	err := s.run(ctx, command, stdoutW, stderrW)
+	return stdout.String(), stderr.String(), err
+}
+
+// ExecTo executes a command in the shell with its combined output written
+// to w only, for commands whose output is too long to be kept.
+func (s *Shell) ExecTo(ctx context.Context, command string, w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.run(ctx, command, w, w)
+}
+
+func (s *Shell) run(ctx context.Context, command string, stdoutW, stderrW io.Writer) error {
 	line, err := syntax.NewParser().Parse(strings.NewReader(command), "")
 	if err != nil {
-		return "", "", fmt.Errorf("could not parse command: %w", err)
+		return fmt.Errorf("could not parse command: %w", err)
 	}
 
-	var stdout, stderr bytes.Buffer
-	runner, err := interp.New(
-		interp.StdIO(nil, &stdout, &stderr),
+	opts := []interp.RunnerOption{
+		interp.StdIO(s.stdin, stdoutW, stderrW),
 		interp.Interactive(false),
 		interp.Env(expand.ListEnviron(s.env...)),
 		interp.Dir(s.cwd),
-		interp.ExecHandlers(s.blockHandler(), s.coreUtilsHandler()),
-	)
+	}
+	if s.sandbox != nil {
+		// Core utilities would run in process, out of the sandbox.
+		opts = append(opts,
+			interp.ExecHandlers(s.blockHandler(), s.sandboxHandler()),
+			interp.OpenHandler(s.sandboxOpenHandler()),
+		)
+	} else {
+		opts = append(opts, interp.ExecHandlers(s.blockHandler(), s.coreUtilsHandler()))
+	}
+	runner, err := interp.New(opts...)
 	if err != nil {
-		return "", "", fmt.Errorf("could not run command: %w", err)
+		return fmt.Errorf("could not run command: %w", err)
 	}
 
 	err = runner.Run(ctx, line)
@@ -234,7 +304,7 @@ func (s *Shell) execPOSIX(ctx context.Context, command string) (string, string,
 		s.env = append(s.env, fmt.Sprintf("%s=%s", name, vr.Str))
 	}
 	s.logger.InfoPersist("POSIX command finished", "command", command, "err", err)
-	return stdout.String(), stderr.String(), err
+	return err
 }
 
 // IsInterrupt checks if an error is due to interruption
@@ -252,5 +322,9 @@ func ExitCode(err error) int {
 	if errors.As(err, &exitErr) {
 		return int(exitErr)
 	}
+	var execErr *exec.ExitError
+	if errors.As(err, &execErr) && execErr.ExitCode() > 0 {
+		return execErr.ExitCode()
+	}
 	return 1
 }
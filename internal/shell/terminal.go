@@ -0,0 +1,203 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/creack/pty"
+)
+
+const (
+	// maxTerminalOutput is the unread output a terminal keeps, the start of
+	// longer outputs is dropped.
+	maxTerminalOutput = 1 << 20
+	// terminalCloseWait is how long a closed terminal has to exit.
+	terminalCloseWait = 5 * time.Second
+)
+
+// ErrTerminalExited is returned when writing to a terminal whose shell
+// exited.
+var ErrTerminalExited = errors.New("the terminal exited")
+
+// TerminalOptions configures a terminal.
+type TerminalOptions struct {
+	WorkingDir string
+	Env        []string
+	// Sandbox runs the shell, if set.
+	Sandbox Sandbox
+	// Args are the shell and its arguments, bash or sh without their startup
+	// files if empty.
+	Args []string
+}
+
+// Terminal is a real shell in a pseudo-terminal. Unlike Shell, its state
+// lives in the shell process between the inputs, and the programs it runs
+// see a terminal, so they can prompt for input.
+type Terminal struct {
+	pty    *os.File
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+
+	mu     sync.Mutex
+	output []byte
+	// dropped is the length of the unread output dropped.
+	dropped int
+	// changed is closed, and replaced, when output comes.
+	changed chan struct{}
+}
+
+// StartTerminal starts a shell in a new pseudo-terminal.
+func StartTerminal(opts TerminalOptions) (*Terminal, error) {
+	args := opts.Args
+	if len(args) == 0 {
+		args = []string{"sh"}
+		if _, err := exec.LookPath("bash"); err == nil {
+			args = []string{"bash", "--noprofile", "--norc"}
+		}
+	}
+	// A plain prompt and no pagers or colors, as the output is read as
+	// text.
+	env := slices.Concat(opts.Env, []string{"TERM=dumb", "PS1=$ ", "PROMPT_COMMAND=", "PAGER=cat", "GIT_PAGER=cat", "NO_COLOR=1"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var cmd *exec.Cmd
+	if opts.Sandbox != nil {
+		var err error
+		cmd, err = opts.Sandbox.Command(ctx, opts.WorkingDir, env, args)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = opts.WorkingDir
+		cmd.Env = env
+	}
+
+	f, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 50, Cols: 200})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("could not start the terminal: %w", err)
+	}
+	t := &Terminal{
+		pty:     f,
+		cmd:     cmd,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	go t.readLoop()
+	return t, nil
+}
+
+func (t *Terminal) readLoop() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.pty.Read(buf)
+		if n > 0 {
+			t.mu.Lock()
+			t.output = append(t.output, buf[:n]...)
+			if over := len(t.output) - maxTerminalOutput; over > 0 {
+				t.output = t.output[over:]
+				t.dropped += over
+			}
+			close(t.changed)
+			t.changed = make(chan struct{})
+			t.mu.Unlock()
+		}
+		if err != nil {
+			break
+		}
+	}
+	t.err = t.cmd.Wait()
+	t.cancel()
+	close(t.done)
+}
+
+// Write sends the input to the terminal as typed, e.g. "\n" for Enter or
+// "\x03" for Ctrl-C.
+func (t *Terminal) Write(input string) error {
+	select {
+	case <-t.done:
+		return ErrTerminalExited
+	default:
+	}
+	_, err := t.pty.Write([]byte(input))
+	return err
+}
+
+// Read returns the output not read yet as plain text. It waits up to wait
+// for output, and then until none came for idle, so that a command's output
+// is read whole.
+func (t *Terminal) Read(ctx context.Context, wait, idle time.Duration) string {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	idleTimer := time.NewTimer(idle)
+	defer idleTimer.Stop()
+	for {
+		t.mu.Lock()
+		changed, hasOutput := t.changed, len(t.output) > 0
+		t.mu.Unlock()
+
+		var idleC <-chan time.Time
+		if hasOutput {
+			idleTimer.Reset(idle)
+			idleC = idleTimer.C
+		}
+		select {
+		case <-changed:
+			continue
+		case <-idleC:
+		case <-deadline.C:
+		case <-t.done:
+		case <-ctx.Done():
+		}
+		return t.take()
+	}
+}
+
+func (t *Terminal) take() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	output := ansi.Strip(string(t.output))
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	output = strings.ReplaceAll(output, "\r", "")
+	if t.dropped > 0 {
+		output = fmt.Sprintf("[%d bytes of output dropped]\n%s", t.dropped, output)
+	}
+	t.output, t.dropped = nil, 0
+	return output
+}
+
+// Done is closed when the shell exits.
+func (t *Terminal) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns how the shell exited, once Done is closed.
+func (t *Terminal) Err() error {
+	<-t.done
+	return t.err
+}
+
+// Close stops the shell and the programs it runs.
+func (t *Terminal) Close() error {
+	err := t.pty.Close()
+	t.cancel()
+	select {
+	case <-t.done:
+	case <-time.After(terminalCloseWait):
+		return errors.New("the terminal didn't exit")
+	}
+	return err
+}
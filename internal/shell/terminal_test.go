@@ -0,0 +1,64 @@
+package shell
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerminal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("terminals need a Unix pseudo-terminal")
+	}
+	term, err := StartTerminal(TerminalOptions{WorkingDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() { term.Close() })
+	read := func() string {
+		return term.Read(t.Context(), 5*time.Second, 300*time.Millisecond)
+	}
+	read()
+
+	// The state lives between the inputs.
+	require.NoError(t, term.Write("export GREETING=hello\n"))
+	read()
+	require.NoError(t, term.Write("echo $GREETING-world\n"))
+	require.Contains(t, read(), "hello-world\n")
+
+	// Programs can prompt for input.
+	require.NoError(t, term.Write(`read -p "Continue? [y/N] " answer`+"\n"))
+	require.Contains(t, read(), "Continue? [y/N]")
+	require.NoError(t, term.Write("y\n"))
+	read()
+	require.NoError(t, term.Write(`echo "answered $answer"`+"\n"))
+	output := read()
+	require.Contains(t, output, "answered y\n")
+	require.NotContains(t, output, "\r")
+
+	require.NoError(t, term.Write("exit 3\n"))
+	select {
+	case <-term.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the terminal didn't exit")
+	}
+	require.Equal(t, 3, ExitCode(term.Err()))
+	require.ErrorIs(t, term.Write("echo\n"), ErrTerminalExited)
+}
+
+func TestTerminal_Close(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("terminals need a Unix pseudo-terminal")
+	}
+	term, err := StartTerminal(TerminalOptions{WorkingDir: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, term.Write("sleep 60\n"))
+	term.Read(t.Context(), time.Second, 200*time.Millisecond)
+
+	require.NoError(t, term.Close())
+	select {
+	case <-term.Done():
+	default:
+		t.Fatal("the terminal is still running")
+	}
+}
@@ -0,0 +1,158 @@
+package sqlquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// Schema describes the tables of the database, or only the table, with
+// their columns. The table may be qualified by its schema, as in
+// public.users. SQLite databases and MySQL tables are described by their
+// CREATE statements, Postgres tables by their columns, indexes, and
+// constraints.
+func (d *DB) Schema(ctx context.Context, table string) (string, error) {
+	table = strings.TrimSpace(table)
+	var describe func(context.Context, string) (string, error)
+	switch d.Driver {
+	case config.DatabasePostgres:
+		describe = d.postgresSchema
+	case config.DatabaseMySQL:
+		describe = d.mysqlSchema
+	default:
+		describe = d.sqliteSchema
+	}
+	schema, err := describe(ctx, table)
+	if err != nil {
+		return "", err
+	}
+	if schema == "" {
+		if table != "" {
+			return "", fmt.Errorf("table %s not found", table)
+		}
+		return "The database has no tables.", nil
+	}
+	return schema, nil
+}
+
+// schemaQuery runs a query of the catalog of the database.
+func (d *DB) schemaQuery(ctx context.Context, query string) (*Result, error) {
+	return d.querier()(ctx, d.dsn, query, false, maxSchemaRows)
+}
+
+func (d *DB) sqliteSchema(ctx context.Context, table string) (string, error) {
+	query := `SELECT sql FROM sqlite_schema WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite!_%' ESCAPE '!'`
+	if table != "" {
+		query += " AND tbl_name = " + quoteString(table)
+	}
+	query += " ORDER BY tbl_name, type <> 'table', name"
+	result, err := d.schemaQuery(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	var statements []string
+	for _, row := range result.Rows {
+		statements = append(statements, row[0]+";")
+	}
+	return strings.Join(statements, "\n\n"), nil
+}
+
+func (d *DB) postgresSchema(ctx context.Context, table string) (string, error) {
+	filter := "table_schema NOT IN ('pg_catalog', 'information_schema')"
+	if table != "" {
+		if schema, name, ok := strings.Cut(table, "."); ok {
+			filter += fmt.Sprintf(" AND table_schema = %s AND table_name = %s", quoteString(schema), quoteString(name))
+		} else {
+			filter += " AND table_name = " + quoteString(table)
+		}
+	}
+	columns, err := d.schemaQuery(ctx, `SELECT table_schema || '.' || table_name, column_name, data_type, is_nullable, coalesce(column_default, '')
+FROM information_schema.columns WHERE `+filter+`
+ORDER BY table_schema, table_name, ordinal_position`)
+	if err != nil {
+		return "", err
+	}
+	schema := formatColumns(columns)
+	if table == "" || schema == "" {
+		return schema, nil
+	}
+
+	// The indexes and constraints of the tables of the columns.
+	relations := "SELECT (quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass FROM information_schema.tables WHERE " + filter
+	details, err := d.schemaQuery(ctx, `SELECT 'CONSTRAINT ' || conname || ' ' || pg_get_constraintdef(oid) FROM pg_constraint WHERE conrelid IN (`+relations+`)
+UNION ALL
+SELECT pg_get_indexdef(indexrelid) FROM pg_index WHERE indrelid IN (`+relations+`)`)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(schema)
+	for _, row := range details.Rows {
+		sb.WriteString("\n  " + row[0])
+	}
+	return sb.String(), nil
+}
+
+func (d *DB) mysqlSchema(ctx context.Context, table string) (string, error) {
+	if table == "" {
+		columns, err := d.schemaQuery(ctx, `SELECT table_name, column_name, TRIM(CONCAT(column_type, ' ', extra)), is_nullable, COALESCE(column_default, '')
+FROM information_schema.columns WHERE table_schema = DATABASE()
+ORDER BY table_name, ordinal_position`)
+		if err != nil {
+			return "", err
+		}
+		return formatColumns(columns), nil
+	}
+
+	identifier := func(name string) string {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	name := identifier(table)
+	if schema, table, ok := strings.Cut(table, "."); ok {
+		name = identifier(schema) + "." + identifier(table)
+	}
+	result, err := d.schemaQuery(ctx, "SHOW CREATE TABLE "+name)
+	if err != nil {
+		if strings.Contains(err.Error(), "doesn't exist") {
+			return "", nil
+		}
+		return "", err
+	}
+	if len(result.Rows) == 0 || len(result.Rows[0]) < 2 {
+		return "", nil
+	}
+	return result.Rows[0][1] + ";", nil
+}
+
+// formatColumns formats rows of table, column, type, nullable, and default
+// as the tables with their columns.
+func formatColumns(result *Result) string {
+	var sb strings.Builder
+	var table string
+	for _, row := range result.Rows {
+		if len(row) < 5 {
+			continue
+		}
+		if row[0] != table {
+			if table != "" {
+				sb.WriteString("\n")
+			}
+			table = row[0]
+			sb.WriteString(table + "\n")
+		}
+		sb.WriteString("  " + row[1] + " " + row[2])
+		if row[3] == "NO" {
+			sb.WriteString(" NOT NULL")
+		}
+		if row[4] != "" {
+			sb.WriteString(" DEFAULT " + row[4])
+		}
+		sb.WriteString("\n")
+	}
+	if result.Truncated {
+		fmt.Fprintf(&sb, "\nOnly the first %d columns are listed, describe a table to see its columns.", len(result.Rows))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,277 @@
+// Package sqlquery runs the queries of the sql tool against the databases of
+// the project. SQLite is built in, Postgres and MySQL are queried with their
+// psql and mysql clients.
+package sqlquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+const (
+	// MaxRows is the number of rows of a result returned at most.
+	MaxRows = 200
+	// maxSchemaRows bounds the columns, indexes, and constraints listed by
+	// Schema.
+	maxSchemaRows = 5000
+	// maxValueLength is the length of the longest value of a result, longer
+	// ones are cut.
+	maxValueLength = 500
+)
+
+var (
+	// ErrWrite is returned when a read-only query would modify the database.
+	ErrWrite = errors.New("only single SELECT, WITH, SHOW, EXPLAIN, DESCRIBE, and PRAGMA statements run without write")
+	// ErrReadOnly is returned for writes to a database configured read-only.
+	ErrReadOnly = errors.New("the database is read-only")
+	// ErrMetaCommand is returned for queries with backslash commands, which
+	// the psql and mysql clients run themselves, such as \! running a shell
+	// command.
+	ErrMetaCommand = errors.New("backslash commands of the database clients aren't supported, only SQL statements")
+)
+
+// DB is a database of the project.
+type DB struct {
+	Name        string
+	Driver      string
+	Description string
+	dsn         string
+	readOnly    bool
+}
+
+// Result is the result of a query.
+type Result struct {
+	Columns []string
+	// Rows are the values of the columns, NULL values as NULL.
+	Rows [][]string
+	// Truncated is set when the query returned more than the rows.
+	Truncated bool
+}
+
+// querier runs a query with a driver, returning at most maxRows rows.
+type querier func(ctx context.Context, dsn, query string, write bool, maxRows int) (*Result, error)
+
+func (d *DB) querier() querier {
+	switch d.Driver {
+	case config.DatabasePostgres:
+		return queryPostgres
+	case config.DatabaseMySQL:
+		return queryMySQL
+	default:
+		return querySQLite
+	}
+}
+
+// New returns the database of the configuration, resolving the variables of
+// its DSN. The path of a SQLite database is relative to the working
+// directory.
+func New(name string, cfg config.Database, resolver config.VariableResolver, workingDir string) (*DB, error) {
+	dsn, err := resolver.ResolveValue(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the dsn of %s: %w", name, err)
+	}
+	if cfg.Driver == config.DatabaseSQLite && !strings.HasPrefix(dsn, "file:") && !filepath.IsAbs(dsn) {
+		dsn = filepath.Join(workingDir, dsn)
+	}
+	return &DB{
+		Name:        name,
+		Driver:      cfg.Driver,
+		Description: cfg.Description,
+		dsn:         dsn,
+		readOnly:    cfg.ReadOnly,
+	}, nil
+}
+
+// ReadOnly reports whether the database is configured read-only, so writes
+// fail with ErrReadOnly.
+func (d *DB) ReadOnly() bool {
+	return d.readOnly
+}
+
+// Query runs the query. Without write, the query must be a single statement
+// reading the database, and runs in a read-only transaction.
+func (d *DB) Query(ctx context.Context, query string, write bool) (*Result, error) {
+	query = strings.TrimSpace(query)
+	if write && d.readOnly {
+		return nil, ErrReadOnly
+	}
+	if hasMetaCommand(query) {
+		return nil, ErrMetaCommand
+	}
+	if !write && !isReadQuery(query) {
+		return nil, ErrWrite
+	}
+	return d.querier()(ctx, d.dsn, query, write, MaxRows)
+}
+
+// String formats the result as a Markdown table.
+func (r *Result) String() string {
+	if len(r.Columns) == 0 {
+		return "The statement returned no rows."
+	}
+	var sb strings.Builder
+	row := func(values []string) {
+		sb.WriteString("|")
+		for _, v := range values {
+			sb.WriteString(" " + formatValue(v) + " |")
+		}
+		sb.WriteString("\n")
+	}
+	row(r.Columns)
+	sb.WriteString("|" + strings.Repeat(" --- |", len(r.Columns)) + "\n")
+	for _, values := range r.Rows {
+		row(values)
+	}
+	switch {
+	case r.Truncated:
+		fmt.Fprintf(&sb, "\nOnly the first %d rows are shown, add a LIMIT or narrow the query to see others.", len(r.Rows))
+	case len(r.Rows) == 1:
+		sb.WriteString("\n1 row")
+	default:
+		fmt.Fprintf(&sb, "\n%d rows", len(r.Rows))
+	}
+	return sb.String()
+}
+
+func formatValue(v string) string {
+	if utf8.RuneCountInString(v) > maxValueLength {
+		v = string([]rune(v)[:maxValueLength]) + "…"
+	}
+	v = strings.ReplaceAll(v, "|", `\|`)
+	v = strings.ReplaceAll(v, "\r\n", "\n")
+	return strings.ReplaceAll(v, "\n", "<br>")
+}
+
+// readKeywords are the first keywords of statements reading the database.
+var readKeywords = []string{"SELECT", "WITH", "SHOW", "EXPLAIN", "DESCRIBE", "DESC", "PRAGMA", "VALUES", "TABLE"}
+
+// isReadQuery reports whether the query is a single statement starting with
+// a keyword of reads. The read-only transaction the query runs in still
+// refuses the writes of statements like WITH ... DELETE.
+func isReadQuery(query string) bool {
+	statements := splitStatements(query)
+	if len(statements) != 1 {
+		return false
+	}
+	// The keyword must come first, not after characters the clients may
+	// read as commands.
+	first := statements[0]
+	if end := strings.IndexFunc(first, func(r rune) bool { return !isKeywordRune(r) }); end >= 0 {
+		first = first[:end]
+	}
+	first = strings.ToUpper(first)
+	for _, keyword := range readKeywords {
+		if first == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+func isKeywordRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+// hasMetaCommand reports whether the query has a backslash outside of
+// strings, quoted identifiers, and comments, which psql and mysql read as
+// the start of one of their commands.
+func hasMetaCommand(query string) bool {
+	found := false
+	scanQuery(query, func(code string) {
+		found = found || strings.Contains(code, `\`)
+	}, nil)
+	return found
+}
+
+// splitStatements splits the query on its semicolons outside of strings,
+// quoted identifiers, and comments, leaving out the empty statements and
+// the comments.
+func splitStatements(query string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+	scanQuery(query, func(code string) {
+		for {
+			before, after, found := strings.Cut(code, ";")
+			current.WriteString(before)
+			if !found {
+				return
+			}
+			flush()
+			code = after
+		}
+	}, func(quoted string) {
+		current.WriteString(quoted)
+	})
+	flush()
+	return statements
+}
+
+// scanQuery calls code with the parts of the query outside of strings,
+// quoted identifiers, and comments, a comment being a space, and quoted, if
+// set, with the strings and quoted identifiers, in order.
+func scanQuery(query string, code, quoted func(string)) {
+	start := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			code(query[start:i])
+			end := i + 1
+			for end < len(query) {
+				if query[end] == '\\' && c == '\'' {
+					end += 2
+					continue
+				}
+				if query[end] == c {
+					if end+1 < len(query) && query[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			end = min(end, len(query)-1)
+			if quoted != nil {
+				quoted(query[i : end+1])
+			}
+			i = end
+			start = i + 1
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			code(query[start:i] + " ")
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			start = i
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			code(query[start:i] + " ")
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			start = min(i+1, len(query))
+		}
+	}
+	if start < len(query) {
+		code(query[start:])
+	}
+}
+
+// quoteString quotes the value as a SQL string literal.
+func quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,129 @@
+package syntax
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// maxSignatureLines bounds the signatures of variables and constants, whose
+// values can be long literals.
+const maxSignatureLines = 5
+
+func parseGo(src []byte) ([]Declaration, error) {
+	fset := token.NewFileSet()
+	// Files with syntax errors are parsed as far as possible, the agent is
+	// often viewing code it's in the middle of changing.
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+
+	source := func(from, to token.Pos) string {
+		return string(src[fset.Position(from).Offset:fset.Position(to).Offset])
+	}
+	line := func(pos token.Pos) int {
+		return fset.Position(pos).Line
+	}
+
+	var declarations []Declaration
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			kind := "func"
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				name = receiverType(decl.Recv.List[0].Type) + "." + name
+				kind = "method"
+			}
+			signature := source(decl.Pos(), decl.End())
+			if decl.Body != nil {
+				signature = strings.TrimSpace(source(decl.Pos(), decl.Body.Lbrace))
+			}
+			declarations = append(declarations, Declaration{
+				Name:      name,
+				Kind:      kind,
+				Signature: signature,
+				StartLine: line(decl.Pos()),
+				EndLine:   line(decl.End()),
+				Uses:      identifiers(decl, decl.Name.Name),
+			})
+		case *ast.GenDecl:
+			if decl.Tok == token.IMPORT {
+				continue
+			}
+			grouped := decl.Lparen.IsValid()
+			for _, spec := range decl.Specs {
+				// Specs of groups are declared on their own.
+				from, to := decl.Pos(), decl.End()
+				prefix := ""
+				if grouped {
+					from, to = spec.Pos(), spec.End()
+					prefix = decl.Tok.String() + " "
+				}
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					declarations = append(declarations, Declaration{
+						Name:      spec.Name.Name,
+						Kind:      "type",
+						Signature: prefix + source(from, to),
+						StartLine: line(from),
+						EndLine:   line(to),
+						Uses:      identifiers(spec, spec.Name.Name),
+					})
+				case *ast.ValueSpec:
+					signature := prefix + source(from, to)
+					if lines := strings.Split(signature, "\n"); len(lines) > maxSignatureLines {
+						signature = strings.Join(lines[:maxSignatureLines], "\n") + "\n..."
+					}
+					for _, name := range spec.Names {
+						if name.Name == "_" {
+							continue
+						}
+						declarations = append(declarations, Declaration{
+							Name:      name.Name,
+							Kind:      decl.Tok.String(),
+							Signature: signature,
+							StartLine: line(from),
+							EndLine:   line(to),
+							Uses:      identifiers(spec, name.Name),
+						})
+					}
+				}
+			}
+		}
+	}
+	return declarations, nil
+}
+
+// receiverType returns the name of the type of a method receiver, without
+// the pointer and type parameters.
+func receiverType(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(expr.X)
+	case *ast.IndexExpr:
+		return receiverType(expr.X)
+	case *ast.IndexListExpr:
+		return receiverType(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// identifiers returns the distinct identifiers used in the node, but the
+// name it declares.
+func identifiers(node ast.Node, name string) []string {
+	seen := map[string]bool{name: true}
+	var uses []string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && !seen[ident.Name] {
+			seen[ident.Name] = true
+			uses = append(uses, ident.Name)
+		}
+		return true
+	})
+	return uses
+}
@@ -0,0 +1,106 @@
+// Package syntax parses source files into their top-level declarations, so
+// tools can show the code of a symbol and the signatures of what it uses
+// instead of whole files.
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsupported is returned for files of languages without a parser.
+var ErrUnsupported = errors.New("language not supported")
+
+// Declaration is a top-level declaration of a file.
+type Declaration struct {
+	// Name is the name of the declaration, Type.Method for methods.
+	Name string
+	Kind string
+	// Signature is the source of the declaration without its body, e.g. the
+	// signature of a function or the whole declaration of a type.
+	Signature string
+	// StartLine and EndLine are the lines of the declaration, starting at 1,
+	// without its doc comment.
+	StartLine int
+	EndLine   int
+	// Uses are the identifiers the declaration refers to.
+	Uses []string
+}
+
+// parseFunc returns the top-level declarations of the source of a file.
+type parseFunc func(src []byte) ([]Declaration, error)
+
+var parsers = map[string]parseFunc{
+	".go": parseGo,
+}
+
+// Supported reports whether the declarations of the file can be parsed.
+func Supported(path string) bool {
+	_, ok := parsers[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Parse returns the top-level declarations of the file in source order.
+func Parse(path string, src []byte) ([]Declaration, error) {
+	parse, ok := parsers[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, filepath.Ext(path))
+	}
+	return parse(src)
+}
+
+// Excerpt is the code of the requested declarations and the signatures of
+// the other declarations of the file they use.
+type Excerpt struct {
+	Declarations []Declaration
+	Dependencies []Declaration
+}
+
+// Extract returns the declarations with the names, matching methods by their
+// name alone too, and the declarations they use. An error lists the
+// declarations when a name matches none.
+func Extract(declarations []Declaration, names []string) (Excerpt, error) {
+	var excerpt Excerpt
+	selected := map[int]bool{}
+	for _, name := range names {
+		found := false
+		for i, declaration := range declarations {
+			if declaration.Name == name || shortName(declaration.Name) == name {
+				selected[i] = true
+				found = true
+			}
+		}
+		if !found {
+			available := make([]string, len(declarations))
+			for i, declaration := range declarations {
+				available[i] = declaration.Name
+			}
+			return Excerpt{}, fmt.Errorf("%q is not declared in the file, its declarations are: %s", name, strings.Join(available, ", "))
+		}
+	}
+
+	uses := map[string]bool{}
+	for i, declaration := range declarations {
+		if selected[i] {
+			excerpt.Declarations = append(excerpt.Declarations, declaration)
+			for _, use := range declaration.Uses {
+				uses[use] = true
+			}
+		}
+	}
+	for i, declaration := range declarations {
+		if !selected[i] && (uses[declaration.Name] || uses[shortName(declaration.Name)]) {
+			excerpt.Dependencies = append(excerpt.Dependencies, declaration)
+		}
+	}
+	return excerpt, nil
+}
+
+func shortName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,105 @@
+package syntax
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const goSource = `package server
+
+import "net/http"
+
+// Server serves the API.
+type Server struct {
+	addr    string
+	handler http.Handler
+}
+
+const (
+	defaultAddr = ":8080"
+	maxConns    = 100
+)
+
+var errClosed = newError("closed")
+
+func newError(msg string) error { return nil }
+
+// New returns a server listening on the default address.
+func New(h http.Handler) *Server {
+	return &Server{addr: defaultAddr, handler: h}
+}
+
+func (s *Server) Run() error {
+	if s.closed() {
+		return errClosed
+	}
+	return http.ListenAndServe(s.addr, s.handler)
+}
+
+func (s *Server) closed() bool { return false }
+`
+
+func TestParseGo(t *testing.T) {
+	t.Parallel()
+
+	declarations, err := Parse("server.go", []byte(goSource))
+	require.NoError(t, err)
+
+	var names []string
+	for _, declaration := range declarations {
+		names = append(names, declaration.Kind+" "+declaration.Name)
+	}
+	require.Equal(t, []string{
+		"type Server",
+		"const defaultAddr",
+		"const maxConns",
+		"var errClosed",
+		"func newError",
+		"func New",
+		"method Server.Run",
+		"method Server.closed",
+	}, names)
+
+	run := declarations[6]
+	require.Equal(t, "func (s *Server) Run() error", run.Signature)
+	require.Equal(t, 25, run.StartLine)
+	require.Equal(t, 30, run.EndLine)
+	require.Equal(t, "const defaultAddr = \":8080\"", declarations[1].Signature)
+	require.Equal(t, 21, declarations[5].StartLine, "the doc comment is left out")
+}
+
+func TestParseUnsupported(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, Supported("main.zig"))
+	_, err := Parse("main.zig", []byte("const std = @import(\"std\");"))
+	require.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	declarations, err := Parse("server.go", []byte(goSource))
+	require.NoError(t, err)
+
+	names := func(declarations []Declaration) []string {
+		var names []string
+		for _, declaration := range declarations {
+			names = append(names, declaration.Name)
+		}
+		return names
+	}
+
+	excerpt, err := Extract(declarations, []string{"Run"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Server.Run"}, names(excerpt.Declarations))
+	require.Equal(t, []string{"Server", "errClosed", "Server.closed"}, names(excerpt.Dependencies))
+
+	excerpt, err = Extract(declarations, []string{"New"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Server", "defaultAddr"}, names(excerpt.Dependencies))
+
+	_, err = Extract(declarations, []string{"Stop"})
+	require.ErrorContains(t, err, `"Stop" is not declared in the file`)
+}
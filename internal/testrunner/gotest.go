@@ -0,0 +1,308 @@
+package testrunner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+var (
+	// Matches "    foo_test.go:12: message", as logged by t.Error.
+	goTestLocation = regexp.MustCompile(`^\s+([^\s:]+\.go):(\d+): ?(.*)$`)
+	// Matches "foo/foo.go:12:5: message", as printed by the compiler and vet.
+	goBuildLocation = regexp.MustCompile(`^([^\s:]+\.go):(\d+)(?::\d+)?: (.*)$`)
+	// Matches "\t/path/foo_test.go:12 +0x28", a frame of a stack trace.
+	goStackFrame = regexp.MustCompile(`^\t(\S+\.go):(\d+)`)
+)
+
+type goTestEvent struct {
+	Action     string
+	Package    string
+	Test       string
+	Output     string
+	ImportPath string
+}
+
+func runGo(ctx context.Context, dir string, opts Options, w io.Writer) (*Result, error) {
+	args := []string{"test", "-json"}
+	args = append(args, opts.Args...)
+	if opts.Run != "" {
+		args = append(args, "-run", opts.Run)
+	}
+	args = append(args, goPackage(dir, opts.Path))
+
+	var stdout, stderr bytes.Buffer
+	code, err := run(ctx, dir, &goOutputWriter{events: &stdout, w: w}, io.MultiWriter(&stderr, w), "go", args...)
+	if err != nil {
+		return nil, err
+	}
+	result := parseGoTest(stdout.Bytes(), stderr.String(), dir)
+	result.Command = command("go", args...)
+	if code != 0 && len(result.Failures) == 0 {
+		return nil, &ExitError{Output: stderr.String(), ExitCode: code}
+	}
+	return result, nil
+}
+
+// goPackage returns the package pattern of the path: all the packages
+// without one, and the package of a directory or file.
+func goPackage(dir, p string) string {
+	if p == "" {
+		return "./..."
+	}
+	if strings.HasSuffix(p, ".go") {
+		p = path.Dir(filepath.ToSlash(p))
+	}
+	if info, err := os.Stat(filepath.Join(dir, p)); err == nil && info.IsDir() && !filepath.IsAbs(p) && !strings.HasPrefix(p, ".") {
+		return "./" + filepath.ToSlash(p)
+	}
+	return p
+}
+
+// goOutputWriter buffers the events of go test -json and writes their
+// output to w.
+type goOutputWriter struct {
+	events *bytes.Buffer
+	w      io.Writer
+	line   []byte
+}
+
+func (g *goOutputWriter) Write(p []byte) (int, error) {
+	g.events.Write(p)
+	g.line = append(g.line, p...)
+	for {
+		i := bytes.IndexByte(g.line, '\n')
+		if i < 0 {
+			break
+		}
+		var event goTestEvent
+		if json.Unmarshal(g.line[:i], &event) == nil && event.Output != "" && g.w != nil {
+			_, _ = io.WriteString(g.w, event.Output)
+		}
+		g.line = g.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// parseGoTest parses the events of go test -json, and the build errors
+// older versions of go print to the standard error.
+func parseGoTest(events []byte, stderr, dir string) *Result {
+	result := &Result{Runner: config.TestRunnerGo}
+	type goTest struct {
+		pkg, name string
+		output    []string
+	}
+	var (
+		tests       = map[string]*goTest{}
+		failed      []*goTest
+		pkgOutput   = map[string][]string{}
+		pkgFailed   []string
+		buildOutput = map[string][]string{}
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(events))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var event goTestEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			continue
+		}
+		if event.Action == "build-output" {
+			buildOutput[event.ImportPath] = append(buildOutput[event.ImportPath], strings.TrimSuffix(event.Output, "\n"))
+			continue
+		}
+		if event.Test == "" {
+			switch event.Action {
+			case "output":
+				pkgOutput[event.Package] = append(pkgOutput[event.Package], strings.TrimSuffix(event.Output, "\n"))
+			case "fail":
+				pkgFailed = append(pkgFailed, event.Package)
+			}
+			continue
+		}
+
+		key := event.Package + " " + event.Test
+		test := tests[key]
+		if test == nil {
+			test = &goTest{pkg: event.Package, name: event.Test}
+			tests[key] = test
+		}
+		switch event.Action {
+		case "output":
+			test.output = append(test.output, strings.TrimSuffix(event.Output, "\n"))
+		case "pass":
+			result.Passed++
+		case "skip":
+			result.Skipped++
+		case "fail":
+			failed = append(failed, test)
+		}
+	}
+
+	pkgDir := goPackageDirs(dir)
+	failedPkgs := map[string]bool{}
+	for _, test := range failed {
+		// Parents fail with their subtests, only the subtests are reported.
+		if slices.ContainsFunc(failed, func(other *goTest) bool {
+			return other.pkg == test.pkg && strings.HasPrefix(other.name, test.name+"/")
+		}) {
+			continue
+		}
+		failure := goTestFailure(test.output, dir)
+		failure.Test = test.name
+		if failure.File != "" && !strings.Contains(failure.File, "/") {
+			failure.File = relPath(dir, filepath.Join(pkgDir(test.pkg), failure.File))
+		}
+		result.Failures = append(result.Failures, failure)
+		failedPkgs[test.pkg] = true
+	}
+
+	// Build errors are reported once, even if several packages import the
+	// package failing to build.
+	var build []string
+	for _, importPath := range slices.Sorted(maps.Keys(buildOutput)) {
+		build = append(build, buildOutput[importPath]...)
+	}
+	if len(build) == 0 {
+		build = strings.Split(stderr, "\n")
+	}
+	buildFailures := goBuildFailures(build)
+
+	// Packages failing outside of their tests, e.g. in TestMain.
+	for _, pkg := range pkgFailed {
+		if failedPkgs[pkg] {
+			continue
+		}
+		var lines []string
+		buildFailed := false
+		for _, line := range pkgOutput[pkg] {
+			buildFailed = buildFailed || strings.HasSuffix(line, "[build failed]") || strings.HasSuffix(line, "[setup failed]")
+			if line != "FAIL" && !strings.HasPrefix(line, "FAIL\t") {
+				lines = append(lines, line)
+			}
+		}
+		if buildFailed && len(buildFailures) > 0 {
+			continue
+		}
+		message := strings.Join(lines, "\n")
+		if strings.TrimSpace(message) == "" {
+			message = "package failed"
+		}
+		result.Failures = append(result.Failures, Failure{Message: pkg + ": " + clipMessage(message)})
+	}
+	result.Failures = append(result.Failures, buildFailures...)
+	return result
+}
+
+// goTestFailure returns the location and message of the first error logged
+// by a failing test, or of its panic.
+func goTestFailure(output []string, dir string) Failure {
+	var failure Failure
+	var message []string
+	for i, line := range output {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+		if strings.HasPrefix(line, "panic: ") {
+			message = append(message, strings.TrimSuffix(line, " [recovered, repanicked]"))
+			// The first frame in the project is where it panicked.
+			for _, frame := range output[i+1:] {
+				m := goStackFrame.FindStringSubmatch(frame)
+				if m == nil || !strings.HasPrefix(m[1], dir+string(filepath.Separator)) {
+					continue
+				}
+				failure.File = relPath(dir, m[1])
+				failure.Line, _ = strconv.Atoi(m[2])
+				break
+			}
+			break
+		}
+		if m := goTestLocation.FindStringSubmatch(line); m != nil {
+			if failure.File == "" {
+				failure.File = m[1]
+				failure.Line, _ = strconv.Atoi(m[2])
+				message = append(message, m[3])
+			} else {
+				message = append(message, m[1]+":"+m[2]+": "+m[3])
+			}
+			continue
+		}
+		if trimmed != "" {
+			message = append(message, trimmed)
+		}
+	}
+	failure.Message = clipMessage(strings.Join(message, "\n"))
+	if failure.Message == "" {
+		failure.Message = "test failed"
+	}
+	return failure
+}
+
+// goBuildFailures parses the errors of the compiler and vet.
+func goBuildFailures(lines []string) []Failure {
+	var failures []Failure
+	for _, line := range lines {
+		m := goBuildLocation.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(m[2])
+		failure := Failure{
+			File:    filepath.ToSlash(filepath.Clean(m[1])),
+			Line:    lineNumber,
+			Message: m[3],
+		}
+		if !slices.Contains(failures, failure) {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// goPackageDirs returns a function returning the directory of the packages
+// of the module of dir.
+func goPackageDirs(dir string) func(pkg string) string {
+	root, module := dir, ""
+	for d := dir; ; {
+		if data, err := os.ReadFile(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			for line := range strings.SplitSeq(string(data), "\n") {
+				if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+					module = strings.Trim(strings.TrimSpace(rest), `"`)
+					break
+				}
+			}
+			break
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return func(pkg string) string {
+		if module == "" {
+			return dir
+		}
+		if pkg == module {
+			return root
+		}
+		if rest, ok := strings.CutPrefix(pkg, module+"/"); ok {
+			return filepath.Join(root, filepath.FromSlash(rest))
+		}
+		return dir
+	}
+}
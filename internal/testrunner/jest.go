@@ -0,0 +1,148 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Matches "at foo (/path/foo.test.js:12:5)" and "at /path/foo.test.js:12:5",
+// a frame of a stack trace of node.
+var jestStackFrame = regexp.MustCompile(`^\s*at (?:.*\()?([^\s()]+):(\d+):\d+\)?$`)
+
+func runJest(ctx context.Context, dir string, opts Options, w io.Writer) (*Result, error) {
+	name, err := findJest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reportFile, err := os.CreateTemp("", "crush-jest-*.json")
+	if err != nil {
+		return nil, err
+	}
+	reportFile.Close()
+	defer os.Remove(reportFile.Name())
+
+	selection := slices.Clone(opts.Args)
+	if opts.Run != "" {
+		selection = append(selection, "-t", opts.Run)
+	}
+	if opts.Path != "" {
+		selection = append(selection, opts.Path)
+	}
+	report := []string{"--json", "--outputFile=" + reportFile.Name(), "--testLocationInResults", "--ci"}
+
+	var output bytes.Buffer
+	out := io.MultiWriter(&output, w)
+	code, err := run(ctx, dir, out, out, name, slices.Concat(report, selection)...)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(reportFile.Name())
+	if err != nil || len(data) == 0 {
+		return nil, &ExitError{Output: output.String(), ExitCode: code}
+	}
+	result, err := parseJest(data, dir)
+	if err != nil || (code != 0 && len(result.Failures) == 0) {
+		return nil, &ExitError{Output: output.String(), ExitCode: code}
+	}
+	result.Command = command("jest", selection...)
+	return result, nil
+}
+
+// findJest returns the jest of the project, or the one installed globally.
+func findJest(dir string) (string, error) {
+	path := filepath.Join(dir, "node_modules", ".bin", "jest")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if path, err := exec.LookPath("jest"); err == nil {
+		return path, nil
+	}
+	return "", fmt.Errorf("jest not found, install the dependencies of the project")
+}
+
+// parseJest parses the JSON report of jest.
+func parseJest(data []byte, dir string) (*Result, error) {
+	var report struct {
+		TestResults []struct {
+			Name             string `json:"name"`
+			Status           string `json:"status"`
+			Message          string `json:"message"`
+			AssertionResults []struct {
+				FullName        string   `json:"fullName"`
+				Status          string   `json:"status"`
+				FailureMessages []string `json:"failureMessages"`
+				Location        *struct {
+					Line int `json:"line"`
+				} `json:"location"`
+			} `json:"assertionResults"`
+		} `json:"testResults"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse the report of jest: %w", err)
+	}
+
+	result := &Result{Runner: config.TestRunnerJest}
+	for _, suite := range report.TestResults {
+		failed := false
+		for _, test := range suite.AssertionResults {
+			switch test.Status {
+			case "passed":
+				result.Passed++
+				continue
+			case "failed":
+			default:
+				// pending, skipped, todo, and disabled.
+				result.Skipped++
+				continue
+			}
+			failed = true
+			failure := jestFailure(strings.Join(test.FailureMessages, "\n"), suite.Name, dir)
+			failure.Test = test.FullName
+			if failure.Line == 0 && test.Location != nil {
+				failure.Line = test.Location.Line
+			}
+			result.Failures = append(result.Failures, failure)
+		}
+		// Suites failing to run, e.g. with a syntax error.
+		if suite.Status == "failed" && !failed {
+			result.Failures = append(result.Failures, jestFailure(suite.Message, suite.Name, dir))
+		}
+	}
+	return result, nil
+}
+
+// jestFailure returns the message of a failure without its stack trace, and
+// the location of the test file in the trace.
+func jestFailure(message, file, dir string) Failure {
+	failure := Failure{File: relPath(dir, file)}
+	var lines []string
+	for line := range strings.SplitSeq(ansi.Strip(message), "\n") {
+		m := jestStackFrame.FindStringSubmatch(line)
+		if m == nil {
+			lines = append(lines, strings.TrimRight(line, " "))
+			continue
+		}
+		if failure.Line == 0 && m[1] == file {
+			failure.Line, _ = strconv.Atoi(m[2])
+		}
+	}
+	failure.Message = clipMessage(strings.Join(lines, "\n"))
+	if failure.Message == "" {
+		failure.Message = "test failed"
+	}
+	return failure
+}
@@ -0,0 +1,171 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// Matches "tests/test_foo.py:12: AssertionError", a location of a
+// traceback of pytest.
+var pytestLocation = regexp.MustCompile(`(?m)^(\S+\.py):(\d+): `)
+
+// pytest exits with this code when no tests were collected.
+const pytestNoTests = 5
+
+func runPytest(ctx context.Context, dir string, opts Options, w io.Writer) (*Result, error) {
+	name, args, err := findPytest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	reportFile, err := os.CreateTemp("", "crush-pytest-*.xml")
+	if err != nil {
+		return nil, err
+	}
+	reportFile.Close()
+	defer os.Remove(reportFile.Name())
+
+	selection := slices.Clone(opts.Args)
+	if opts.Run != "" {
+		selection = append(selection, "-k", opts.Run)
+	}
+	if opts.Path != "" {
+		selection = append(selection, opts.Path)
+	}
+	// The xunit1 report has the file and line of the tests.
+	report := []string{"--junitxml=" + reportFile.Name(), "-o", "junit_family=xunit1", "--tb=short", "-q"}
+
+	var output bytes.Buffer
+	out := io.MultiWriter(&output, w)
+	code, err := run(ctx, dir, out, out, name, slices.Concat(args, report, selection)...)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := os.ReadFile(reportFile.Name())
+	result, err := parsePytest(data, dir)
+	if err != nil || (code != 0 && code != pytestNoTests && len(result.Failures) == 0) {
+		return nil, &ExitError{Output: output.String(), ExitCode: code}
+	}
+	result.Command = command(name, slices.Concat(args, selection)...)
+	return result, nil
+}
+
+// findPytest returns the command running pytest, preferring the one of the
+// virtual environment of the project.
+func findPytest(dir string) (string, []string, error) {
+	for _, venv := range []string{".venv", "venv"} {
+		path := filepath.Join(dir, venv, "bin", "pytest")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil, nil
+		}
+	}
+	if path, err := exec.LookPath("pytest"); err == nil {
+		return path, nil, nil
+	}
+	for _, python := range []string{"python3", "python"} {
+		if path, err := exec.LookPath(python); err == nil {
+			return path, []string{"-m", "pytest"}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("pytest not found, install it in the virtual environment of the project")
+}
+
+type junitCase struct {
+	Name    string `xml:"name,attr"`
+	File    string `xml:"file,attr"`
+	Line    int    `xml:"line,attr"`
+	Failure *struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	} `xml:"failure"`
+	Error *struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	} `xml:"error"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+// parsePytest parses the JUnit XML report of pytest.
+func parsePytest(data []byte, dir string) (*Result, error) {
+	type junitSuite struct {
+		Cases []junitCase `xml:"testcase"`
+	}
+	var report struct {
+		XMLName xml.Name
+		Suites  []junitSuite `xml:"testsuite"`
+		Cases   []junitCase  `xml:"testcase"`
+	}
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse the report of pytest: %w", err)
+	}
+	if report.XMLName.Local == "testsuite" {
+		// Older versions of pytest don't wrap the suite in testsuites.
+		report.Suites = []junitSuite{{Cases: report.Cases}}
+	}
+
+	result := &Result{Runner: config.TestRunnerPytest}
+	for _, suite := range report.Suites {
+		for _, c := range suite.Cases {
+			var message, text string
+			switch {
+			case c.Failure != nil:
+				message, text = c.Failure.Message, c.Failure.Text
+			case c.Error != nil:
+				message, text = c.Error.Message, c.Error.Text
+			case c.Skipped != nil:
+				result.Skipped++
+				continue
+			default:
+				result.Passed++
+				continue
+			}
+
+			failure := Failure{Test: c.Name, File: c.File}
+			if c.File != "" {
+				// The line of the test is 0-based.
+				failure.Line = c.Line + 1
+			} else {
+				// Errors collecting a module are named after it.
+				failure.Test = ""
+			}
+			// The last location of the traceback is where it failed.
+			if m := pytestLocation.FindAllStringSubmatch(text, -1); m != nil {
+				last := m[len(m)-1]
+				failure.File = last[1]
+				failure.Line, _ = strconv.Atoi(last[2])
+			}
+			if failure.File != "" {
+				failure.File = relPath(dir, failure.File)
+			}
+			// The E lines of the traceback explain the failure better than
+			// the message of errors.
+			var explanation []string
+			for line := range strings.SplitSeq(text, "\n") {
+				if rest, ok := strings.CutPrefix(line, "E "); ok {
+					explanation = append(explanation, strings.TrimSpace(rest))
+				}
+			}
+			if len(explanation) > 0 && (c.Failure == nil || message == "") {
+				message = strings.Join(explanation, "\n")
+			}
+			failure.Message = clipMessage(message)
+			if failure.Message == "" {
+				failure.Message = "test failed"
+			}
+			result.Failures = append(result.Failures, failure)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,194 @@
+// Package testrunner runs the tests of a project with go test, pytest, or
+// jest for the test tool, and parses their failures into the test, file, and
+// line they come from.
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// maxMessageLines caps the lines of the message of a failure, long diffs
+// and stack traces are cut.
+const maxMessageLines = 20
+
+// Options selects the tests to run.
+type Options struct {
+	// Runner is detected from the files of the project without it.
+	Runner string
+	// Args are extra arguments of the runner.
+	Args []string
+	// Path is the package, directory, or file of the tests, all of them
+	// without it.
+	Path string
+	// Run only runs the tests matching it, a -run pattern of go test, a -k
+	// expression of pytest, or a -t pattern of jest.
+	Run string
+}
+
+// Failure is a failing test, or an error preventing tests from running such
+// as a build error.
+type Failure struct {
+	// Test is empty for errors outside of tests.
+	Test    string `json:"test,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Result is the outcome of a test run.
+type Result struct {
+	Runner string `json:"runner"`
+	// Command is the command that ran, for display.
+	Command  string    `json:"command"`
+	Passed   int       `json:"passed"`
+	Skipped  int       `json:"skipped"`
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// ExitError is returned when the runner failed without a report of the
+// tests, e.g. when it isn't installed in the project.
+type ExitError struct {
+	Output   string
+	ExitCode int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("tests exited with code %d: %s", e.ExitCode, strings.TrimSpace(e.Output))
+}
+
+// Detect returns the test runner of the project in dir.
+func Detect(dir string) (string, error) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	contains := func(name, s string) bool {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		return err == nil && bytes.Contains(data, []byte(s))
+	}
+
+	switch {
+	case exists("go.mod"):
+		return config.TestRunnerGo, nil
+	case usesJest(dir):
+		return config.TestRunnerJest, nil
+	case exists("pytest.ini"), exists("conftest.py"),
+		contains("pyproject.toml", "[tool.pytest"),
+		contains("setup.cfg", "[tool:pytest]"),
+		contains("tox.ini", "[pytest]"):
+		return config.TestRunnerPytest, nil
+	case exists("pyproject.toml"), exists("setup.py"), exists("requirements.txt"):
+		// pytest runs the tests of unittest too.
+		return config.TestRunnerPytest, nil
+	}
+	return "", fmt.Errorf("no go, pytest, or jest project found in %s, set the runner of the tests option", dir)
+}
+
+// usesJest reports whether the package.json of dir depends on jest or runs
+// it.
+func usesJest(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg struct {
+		Scripts         map[string]string `json:"scripts"`
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+		Jest            json.RawMessage   `json:"jest"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return false
+	}
+	_, dep := pkg.Dependencies["jest"]
+	_, devDep := pkg.DevDependencies["jest"]
+	return dep || devDep || pkg.Jest != nil || strings.Contains(pkg.Scripts["test"], "jest")
+}
+
+// Run runs the tests of the project in dir, writing their output to w as
+// they run.
+func Run(ctx context.Context, dir string, opts Options, w io.Writer) (*Result, error) {
+	runner := opts.Runner
+	if runner == "" {
+		var err error
+		if runner, err = Detect(dir); err != nil {
+			return nil, err
+		}
+	}
+	switch runner {
+	case config.TestRunnerGo:
+		return runGo(ctx, dir, opts, w)
+	case config.TestRunnerPytest:
+		return runPytest(ctx, dir, opts, w)
+	case config.TestRunnerJest:
+		return runJest(ctx, dir, opts, w)
+	default:
+		return nil, fmt.Errorf("unknown test runner %q", runner)
+	}
+}
+
+// run runs the command in dir, returning its standard output and error and
+// its exit code. Only failing to start it is an error.
+func run(ctx context.Context, dir string, stdout io.Writer, stderr io.Writer, name string, args ...string) (int, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case ctx.Err() != nil:
+		return -1, ctx.Err()
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), nil
+	default:
+		return -1, fmt.Errorf("failed to run %s: %w", name, err)
+	}
+}
+
+// command formats a command for display.
+func command(name string, args ...string) string {
+	parts := []string{filepath.Base(name)}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$*?[]|&;<>()") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// relPath returns the path relative to dir with forward slashes, or as is
+// outside of dir.
+func relPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(path)
+		}
+		path = rel
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// clipMessage trims the message and cuts it to maxMessageLines.
+func clipMessage(message string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) > maxMessageLines {
+		lines = append(lines[:maxMessageLines], fmt.Sprintf("[%d more lines]", len(lines)-maxMessageLines))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,194 @@
+package testrunner
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"go", map[string]string{"go.mod": "module example.com/x\n"}, config.TestRunnerGo},
+		{"jest dependency", map[string]string{"package.json": `{"devDependencies": {"jest": "^29.0.0"}}`}, config.TestRunnerJest},
+		{"jest script", map[string]string{"package.json": `{"scripts": {"test": "jest --coverage"}}`}, config.TestRunnerJest},
+		{"pytest section", map[string]string{"pyproject.toml": "[tool.pytest.ini_options]\n"}, config.TestRunnerPytest},
+		{"python project", map[string]string{"requirements.txt": "requests\n"}, config.TestRunnerPytest},
+		{"mocha", map[string]string{"package.json": `{"scripts": {"test": "mocha"}}`}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			runner, err := Detect(dir)
+			if tt.want == "" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, runner)
+		})
+	}
+}
+
+func TestRunGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	t.Setenv("GOPROXY", "off")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/x\n\ngo 1.24\n",
+		"x_test.go": `package x
+
+import "testing"
+
+func TestPass(t *testing.T) {}
+
+func TestFail(t *testing.T) {
+	t.Errorf("expected %d,\ngot %d", 1, 2)
+}
+
+func TestSub(t *testing.T) {
+	t.Run("one", func(t *testing.T) { t.Fatal("boom") })
+	t.Run("two", func(t *testing.T) {})
+}
+
+func TestSkip(t *testing.T) { t.Skip("later") }
+`,
+		"panics/panics_test.go": `package panics
+
+import "testing"
+
+func TestPanic(t *testing.T) {
+	var m map[string]int
+	m["a"] = 1
+}
+`,
+		"broken/broken_test.go": `package broken
+
+import "testing"
+
+func TestBroken(t *testing.T) { undefined() }
+`,
+	})
+
+	result, err := Run(t.Context(), dir, Options{}, io.Discard)
+	require.NoError(t, err)
+	require.Equal(t, config.TestRunnerGo, result.Runner)
+	require.Equal(t, "go test -json ./...", result.Command)
+	require.Equal(t, 2, result.Passed)
+	require.Equal(t, 1, result.Skipped)
+	require.ElementsMatch(t, []Failure{
+		{Test: "TestFail", File: "x_test.go", Line: 8, Message: "expected 1,\ngot 2"},
+		{Test: "TestSub/one", File: "x_test.go", Line: 12, Message: "boom"},
+		{Test: "TestPanic", File: "panics/panics_test.go", Line: 7, Message: "panic: assignment to entry in nil map"},
+		{File: "broken/broken_test.go", Line: 5, Message: "undefined: undefined"},
+	}, result.Failures)
+
+	result, err = Run(t.Context(), dir, Options{Path: "x_test.go", Run: "TestPass"}, io.Discard)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Passed)
+	require.Empty(t, result.Failures)
+}
+
+func TestParseGoTestBuildErrorsOnStderr(t *testing.T) {
+	t.Parallel()
+
+	events := `{"Action":"output","Package":"example.com/x","Output":"FAIL\texample.com/x [build failed]\n"}
+{"Action":"fail","Package":"example.com/x"}
+`
+	stderr := "# example.com/x\n./x.go:3:2: undefined: y\n"
+	result := parseGoTest([]byte(events), stderr, t.TempDir())
+	require.Equal(t, []Failure{{File: "x.go", Line: 3, Message: "undefined: y"}}, result.Failures)
+}
+
+func TestParsePytest(t *testing.T) {
+	t.Parallel()
+
+	report := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" errors="1" failures="1" skipped="1" tests="4">
+<testcase classname="tests.test_math" name="test_add" file="tests/test_math.py" line="2" time="0.001" />
+<testcase classname="tests.test_math" name="test_sub" file="tests/test_math.py" line="5" time="0.001"><failure message="assert 1 == 2&#10; +  where 1 = sub(3, 2)">def test_sub():
+&gt;       assert sub(3, 2) == 2
+E       assert 1 == 2
+E        +  where 1 = sub(3, 2)
+
+tests/test_math.py:7: AssertionError</failure></testcase>
+<testcase classname="tests.test_math" name="test_later" file="tests/test_math.py" line="9" time="0.000"><skipped type="pytest.skip" message="later" /></testcase>
+<testcase classname="" name="tests.test_broken" time="0.000"><error message="collection failure">tests/test_broken.py:1: in &lt;module&gt;
+    import missing
+E   ModuleNotFoundError: No module named 'missing'</error></testcase>
+</testsuite></testsuites>`
+
+	result, err := parsePytest([]byte(report), "/project")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Passed)
+	require.Equal(t, 1, result.Skipped)
+	require.Equal(t, []Failure{
+		{Test: "test_sub", File: "tests/test_math.py", Line: 7, Message: "assert 1 == 2\n +  where 1 = sub(3, 2)"},
+		{File: "tests/test_broken.py", Line: 1, Message: "ModuleNotFoundError: No module named 'missing'"},
+	}, result.Failures)
+}
+
+func TestParseJest(t *testing.T) {
+	t.Parallel()
+
+	report := `{
+  "numFailedTests": 1,
+  "numPassedTests": 1,
+  "testResults": [
+    {
+      "name": "/project/src/sum.test.js",
+      "status": "failed",
+      "message": "",
+      "assertionResults": [
+        {"fullName": "sum adds", "status": "passed", "failureMessages": []},
+        {
+          "fullName": "sum subtracts",
+          "status": "failed",
+          "location": {"line": 7, "column": 3},
+          "failureMessages": ["Error: \u001b[2mexpect(\u001b[22mreceived\u001b[2m).toBe(\u001b[22mexpected\u001b[2m)\u001b[22m\n\nExpected: 1\nReceived: 5\n    at Object.toBe (/project/src/sum.test.js:8:24)\n    at Promise.then.completed (/project/node_modules/jest-circus/build/utils.js:298:28)"]
+        },
+        {"fullName": "sum later", "status": "pending", "failureMessages": []}
+      ]
+    },
+    {
+      "name": "/project/src/broken.test.js",
+      "status": "failed",
+      "message": "  ● Test suite failed to run\n\n    SyntaxError: Unexpected token (3:4)\n",
+      "assertionResults": []
+    }
+  ]
+}`
+
+	result, err := parseJest([]byte(report), "/project")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Passed)
+	require.Equal(t, 1, result.Skipped)
+	require.Equal(t, []Failure{
+		{Test: "sum subtracts", File: "src/sum.test.js", Line: 8, Message: "Error: expect(received).toBe(expected)\n\nExpected: 1\nReceived: 5"},
+		{File: "src/broken.test.js", Message: "● Test suite failed to run\n\n    SyntaxError: Unexpected token (3:4)"},
+	}, result.Failures)
+}
@@ -0,0 +1,78 @@
+package tokenizer
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// approxPattern splits the texts in words, with the space before them, runs
+// of digits, of punctuation, and of spaces, as the BPE tokenizers do first.
+var approxPattern = regexp.MustCompile(` ?\p{L}[\p{L}\p{M}]*| ?\p{N}+|[^\s\p{L}\p{N}]+|\s+`)
+
+// approx approximates a tokenizer from the average length of its tokens.
+type approx struct {
+	// wordBytes is the length of the tokens of words, in bytes.
+	wordBytes float64
+	// digits is the length of the tokens of numbers.
+	digits int
+	// punctuation is the length of the tokens of runs of punctuation.
+	punctuation float64
+}
+
+// approximations are the approximate tokenizers, by name; "" is the one of
+// the unknown families.
+var approximations = map[string]approx{
+	"":                     {wordBytes: 4, digits: 3, punctuation: 2},
+	config.TokenizerO200k:  {wordBytes: 4.4, digits: 3, punctuation: 2},
+	config.TokenizerCl100k: {wordBytes: 4, digits: 3, punctuation: 2},
+	config.TokenizerClaude: {wordBytes: 3.5, digits: 3, punctuation: 1.5},
+	// Llama 2 and Mistral split the numbers in digits.
+	config.TokenizerLlama: {wordBytes: 3.8, digits: 1, punctuation: 1.5},
+}
+
+func (a approx) Count(text string) int {
+	tokens := 0
+	for _, piece := range approxPattern.FindAllString(text, -1) {
+		word := strings.TrimPrefix(piece, " ")
+		r, _ := utf8.DecodeRuneInString(word)
+		switch {
+		case unicode.IsLetter(r):
+			tokens += a.countWord(word)
+		case unicode.IsNumber(r):
+			tokens += int(math.Ceil(float64(utf8.RuneCountInString(word)) / float64(a.digits)))
+		case unicode.IsSpace(r):
+			tokens++
+		default:
+			tokens += share(utf8.RuneCountInString(word), a.punctuation)
+		}
+	}
+	return tokens
+}
+
+// countWord counts the tokens of a word. The ideograms and syllables of CJK
+// are about a token each, the other letters take their share of the bytes of
+// a token.
+func (a approx) countWord(word string) int {
+	cjk, other := 0, 0
+	for _, r := range word {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	if other == 0 {
+		return cjk
+	}
+	return cjk + share(other, a.wordBytes)
+}
+
+// share returns the number of tokens of the length, at least one.
+func share(n int, tokenLength float64) int {
+	return max(1, int(math.Round(float64(n)/tokenLength)))
+}
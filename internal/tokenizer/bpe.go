@@ -0,0 +1,110 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/dlclark/regexp2"
+)
+
+// maxPiece is the length of the pieces of text merged at once, so a long run
+// of the same character doesn't take quadratic time.
+const maxPiece = 512
+
+// patterns split the texts in the pieces the BPE encodings merge.
+var patterns = map[string]string{
+	config.TokenizerCl100k: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	config.TokenizerO200k: `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+}
+
+// bpe is a byte pair encoding in the format of tiktoken.
+type bpe struct {
+	ranks   map[string]int
+	pattern *regexp2.Regexp
+}
+
+// loadBPE loads the encoding of the file, a token in base64 and its rank per
+// line.
+func loadBPE(path, pattern string) (*bpe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ranks := make(map[string]int, bytes.Count(data, []byte("\n")))
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		token, rank, ok := bytes.Cut(scanner.Bytes(), []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a token and its rank", line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no tokens in %s", path)
+	}
+	re, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+	return &bpe{ranks: ranks, pattern: re}, nil
+}
+
+func (b *bpe) Count(text string) int {
+	tokens := 0
+	match, _ := b.pattern.FindStringMatch(text)
+	for match != nil {
+		piece := []byte(match.String())
+		for len(piece) > maxPiece {
+			tokens += b.countPiece(piece[:maxPiece])
+			piece = piece[maxPiece:]
+		}
+		tokens += b.countPiece(piece)
+		match, _ = b.pattern.FindNextMatch(match)
+	}
+	return tokens
+}
+
+// countPiece merges the bytes of the piece by pairs, the pair of the lowest
+// rank first, and returns the number of tokens left.
+func (b *bpe) countPiece(piece []byte) int {
+	if _, ok := b.ranks[string(piece)]; ok {
+		return 1
+	}
+	// bounds are the starts of the tokens, and the end of the piece.
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := b.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
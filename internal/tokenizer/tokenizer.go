@@ -0,0 +1,159 @@
+// Package tokenizer counts the tokens of texts with the tokenizer of a model:
+// the BPE encodings of the OpenAI models once downloaded, and approximations
+// of the tokenizers of the other families.
+package tokenizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// Tokenizer counts the tokens of texts.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// encodingURLs are where the BPE encodings are downloaded from.
+var encodingURLs = map[string]string{
+	config.TokenizerO200k:  "https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken",
+	config.TokenizerCl100k: "https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken",
+}
+
+var (
+	o200kPrefixes  = []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-oss", "chatgpt-4o", "o1", "o3", "o4"}
+	cl100kPrefixes = []string{"gpt-4", "gpt-3.5", "text-embedding-3", "text-embedding-ada"}
+	llamaFamilies  = []string{"llama", "mistral", "mixtral", "codestral", "devstral", "qwen", "deepseek", "gemma", "phi", "kimi", "glm"}
+)
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*bpe{}
+)
+
+// Dir is the directory the BPE encodings are downloaded in.
+func Dir() string {
+	return filepath.Join(config.GlobalDataDir(), "tokenizers")
+}
+
+// Name returns the tokenizer of the model, "" when its family is unknown.
+func Name(model string, cfg *config.Tokenizers) string {
+	if cfg != nil {
+		if name, ok := cfg.Models[model]; ok {
+			return name
+		}
+	}
+	id := strings.ToLower(model)
+	// Routers prefix the models with their providers, like openai/gpt-4o.
+	base := id[strings.LastIndex(id, "/")+1:]
+	hasPrefix := func(prefix string) bool { return strings.HasPrefix(base, prefix) }
+	contains := func(family string) bool { return strings.Contains(id, family) }
+	switch {
+	case contains("claude"):
+		return config.TokenizerClaude
+	case anyOf(o200kPrefixes, hasPrefix):
+		return config.TokenizerO200k
+	case anyOf(cl100kPrefixes, hasPrefix):
+		return config.TokenizerCl100k
+	case anyOf(llamaFamilies, contains):
+		return config.TokenizerLlama
+	}
+	return ""
+}
+
+func anyOf(values []string, match func(string) bool) bool {
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForModel returns the tokenizer of the model: its BPE encoding when it was
+// downloaded, an approximation of it otherwise.
+func ForModel(model string, cfg *config.Tokenizers) Tokenizer {
+	name := Name(model, cfg)
+	if _, ok := encodingURLs[name]; ok {
+		if encoding := loadEncoding(name); encoding != nil {
+			return encoding
+		}
+	}
+	return approximations[name]
+}
+
+// loadEncoding returns the downloaded BPE encoding, nil when it wasn't.
+func loadEncoding(name string) *bpe {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if encoding, ok := encodings[name]; ok {
+		return encoding
+	}
+	encoding, err := loadBPE(filepath.Join(Dir(), name+".tiktoken"), patterns[name])
+	if err != nil {
+		return nil
+	}
+	encodings[name] = encoding
+	return encoding
+}
+
+// Download downloads the BPE encodings missing from the directory.
+func Download(ctx context.Context, dir string) error {
+	var errs []error
+	for name, url := range encodingURLs {
+		path := filepath.Join(dir, name+".tiktoken")
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		if err := download(ctx, url, path, patterns[name]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to download %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func download(ctx context.Context, url, path, pattern string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Written aside and renamed once it loads, so a failed download isn't
+	// taken for the encoding.
+	tmp := path + ".download"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		_, err = loadBPE(tmp, pattern)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,121 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestName(t *testing.T) {
+	t.Parallel()
+
+	for model, name := range map[string]string{
+		"gpt-4o-mini":                  config.TokenizerO200k,
+		"openai/gpt-4.1":               config.TokenizerO200k,
+		"o3-mini":                      config.TokenizerO200k,
+		"gpt-4-turbo":                  config.TokenizerCl100k,
+		"gpt-3.5-turbo":                config.TokenizerCl100k,
+		"claude-sonnet-4-20250514":     config.TokenizerClaude,
+		"anthropic.claude-3-5-haiku":   config.TokenizerClaude,
+		"meta-llama/llama-3.3-70b":     config.TokenizerLlama,
+		"qwen2.5-coder:32b":            config.TokenizerLlama,
+		"gemini-2.5-pro":               "",
+		"some-model-nobody-ever-heard": "",
+	} {
+		require.Equal(t, name, Name(model, nil), model)
+	}
+
+	cfg := &config.Tokenizers{Models: map[string]string{"gemini-2.5-pro": config.TokenizerCl100k}}
+	require.Equal(t, config.TokenizerCl100k, Name("gemini-2.5-pro", cfg))
+}
+
+func TestApprox(t *testing.T) {
+	t.Parallel()
+
+	generic := approximations[""]
+	require.Zero(t, generic.Count(""))
+	require.Equal(t, 4, generic.Count("Hello, world!"))
+	require.Equal(t, 100, generic.Count(strings.Repeat("word", 100)))
+	require.Equal(t, 2, generic.Count("123456"))
+	require.Equal(t, 4, generic.Count("日本語で"))
+
+	require.Equal(t, 6, approximations[config.TokenizerLlama].Count("123456"), "llama splits the digits")
+	require.Greater(t, approximations[config.TokenizerClaude].Count(strings.Repeat("tokenizer ", 50)), generic.Count(strings.Repeat("tokenizer ", 50)))
+}
+
+// writeEncoding writes an encoding of the bytes of the text and the merges,
+// ranked in order.
+func writeEncoding(t *testing.T, path, alphabet string, merges ...string) {
+	t.Helper()
+	var lines []string
+	for i, token := range append(strings.Split(alphabet, ""), merges...) {
+		lines = append(lines, fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(token)), i))
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644))
+}
+
+func TestBPE(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	writeEncoding(t, path, " !dehlorw", "he", "ll", "llo", " w", "or", "hello")
+	encoding, err := loadBPE(path, patterns[config.TokenizerCl100k])
+	require.NoError(t, err)
+
+	require.Equal(t, 1, encoding.Count("hello"))
+	// " w", "or", "l", "d"
+	require.Equal(t, 4, encoding.Count(" world"))
+	// "he", "l"
+	require.Equal(t, 2, encoding.Count("hel"))
+	// "hello", "l"
+	require.Equal(t, 2, encoding.Count("hellol"))
+	require.Equal(t, 6, encoding.Count("hello world!"))
+}
+
+func TestLoadBPEErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	_, err := loadBPE(filepath.Join(dir, "missing.tiktoken"), patterns[config.TokenizerCl100k])
+	require.Error(t, err)
+
+	invalid := filepath.Join(dir, "invalid.tiktoken")
+	require.NoError(t, os.WriteFile(invalid, []byte("<html>Not found</html>\n"), 0o644))
+	_, err = loadBPE(invalid, patterns[config.TokenizerCl100k])
+	require.ErrorContains(t, err, "line 1")
+}
+
+func TestDownload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	encoding := filepath.Join(dir, "encoding.tiktoken")
+	writeEncoding(t, encoding, "ab", "ab")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/encoding.tiktoken" {
+			http.Error(w, "<html>Not found</html>", http.StatusOK)
+			return
+		}
+		http.ServeFile(w, r, encoding)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(dir, "tokenizers", "downloaded.tiktoken")
+	require.NoError(t, download(t.Context(), server.URL+"/encoding.tiktoken", path, patterns[config.TokenizerO200k]))
+	downloaded, err := loadBPE(path, patterns[config.TokenizerO200k])
+	require.NoError(t, err)
+	require.Equal(t, 1, downloaded.Count("ab"))
+
+	broken := filepath.Join(dir, "tokenizers", "broken.tiktoken")
+	require.Error(t, download(t.Context(), server.URL+"/missing", broken, patterns[config.TokenizerO200k]))
+	require.NoFileExists(t, broken)
+	require.NoFileExists(t, broken+".download")
+}
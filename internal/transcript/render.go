@@ -0,0 +1,188 @@
+package transcript
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Markdown renders the transcript: the cost summary, the conversation with
+// the tool calls and their results, and the diffs of the changed files.
+func Markdown(t *Transcript) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", cmp.Or(t.Session.Title, "Untitled"))
+
+	var models []string
+	for _, msg := range t.Messages {
+		if msg.Role != message.Assistant || msg.Model == "" {
+			continue
+		}
+		model := msg.Model
+		if msg.Provider != "" {
+			model = msg.Provider + "/" + model
+		}
+		if !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	fmt.Fprintf(&sb, "- **Created:** %s\n", time.Unix(t.Session.CreatedAt, 0).UTC().Format(time.DateTime+" MST"))
+	if len(models) > 0 {
+		fmt.Fprintf(&sb, "- **Models:** %s\n", strings.Join(models, ", "))
+	}
+	fmt.Fprintf(&sb, "- **Messages:** %d\n", len(t.Messages))
+	fmt.Fprintf(&sb, "- **Tokens:** %d prompt, %d completion\n", t.Session.PromptTokens, t.Session.CompletionTokens)
+	cost := fmt.Sprintf("$%.4f", t.Session.Cost)
+	if t.Session.CacheCost > 0 {
+		cost += fmt.Sprintf(" ($%.4f cache)", t.Session.CacheCost)
+	}
+	if t.Session.ReasoningCost > 0 {
+		cost += fmt.Sprintf(" ($%.4f thinking)", t.Session.ReasoningCost)
+	}
+	fmt.Fprintf(&sb, "- **Cost:** %s\n", cost)
+	if len(t.Files) > 0 {
+		fmt.Fprintf(&sb, "- **Changed files:** %d\n", len(t.Files))
+	}
+
+	for _, msg := range t.Messages {
+		writeMessage(&sb, msg, msg.ID == t.Session.SummaryMessageID)
+	}
+
+	if len(t.Files) > 0 {
+		sb.WriteString("\n## Changes\n")
+		for _, file := range t.Files {
+			unified, additions, removals := diff.GenerateDiff(file.Before, file.After, file.Path)
+			fmt.Fprintf(&sb, "\n### %s (+%d -%d)\n\n%s", file.Path, additions, removals, fence("diff", unified))
+		}
+	}
+	return sb.String()
+}
+
+func writeMessage(sb *strings.Builder, msg Message, summary bool) {
+	switch {
+	case summary:
+		sb.WriteString("\n## Summary of the conversation before\n")
+	case isSynthetic(msg):
+		sb.WriteString("\n## Feedback from crush\n")
+	case msg.Role == message.User:
+		sb.WriteString("\n## User\n")
+	case msg.Role == message.Assistant && msg.Model != "":
+		fmt.Fprintf(sb, "\n## Assistant (%s)\n", msg.Model)
+	case msg.Role == message.Assistant:
+		sb.WriteString("\n## Assistant\n")
+	}
+
+	for _, part := range msg.Parts {
+		switch part := part.(type) {
+		case message.TextContent:
+			if text := strings.TrimSpace(part.Text); text != "" {
+				fmt.Fprintf(sb, "\n%s\n", text)
+			}
+		case message.ReasoningContent:
+			if thinking := strings.TrimSpace(part.Thinking); thinking != "" {
+				fmt.Fprintf(sb, "\n> **Thinking**\n>\n> %s\n", strings.ReplaceAll(thinking, "\n", "\n> "))
+			}
+		case message.ImageURLContent:
+			fmt.Fprintf(sb, "\n*Image: %s*\n", part.URL)
+		case message.BinaryContent:
+			fmt.Fprintf(sb, "\n*Attachment: %s (%s)*\n", cmp.Or(part.Path, "file"), part.MIMEType)
+		case message.ToolCall:
+			fmt.Fprintf(sb, "\n**Tool call:** `%s`\n\n%s", part.Name, fence("json", indentJSON(part.Input)))
+		case message.ToolResult:
+			label := "Result"
+			if part.IsError {
+				label = "Error"
+			}
+			fmt.Fprintf(sb, "\n**%s of** `%s`\n\n%s", label, part.Name, fence("", part.Content))
+		case message.Finish:
+			switch part.Reason {
+			case message.FinishReasonCanceled:
+				sb.WriteString("\n*Canceled by the user*\n")
+			case message.FinishReasonPermissionDenied:
+				sb.WriteString("\n*Permission denied*\n")
+			case message.FinishReasonInterrupted:
+				sb.WriteString("\n*Interrupted*\n")
+			case message.FinishReasonError:
+				fmt.Fprintf(sb, "\n*Error: %s*\n", cmp.Or(part.Message, "the request failed"))
+			}
+		}
+	}
+}
+
+// fence returns the text in a code block, with a fence longer than any run
+// of backticks in it.
+func fence(lang, text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return fmt.Sprintf("%s%s\n%s\n%s\n", marker, lang, strings.TrimRight(text, "\n"), marker)
+}
+
+// isSynthetic reports whether crush wrote the message for the model rather
+// than the user.
+func isSynthetic(msg Message) bool {
+	m := message.Message{Role: msg.Role, Parts: msg.Parts}
+	return m.IsSynthetic()
+}
+
+func indentJSON(input string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(input), "", "  "); err != nil {
+		return input
+	}
+	return out.String()
+}
+
+var page = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 960px; margin: 2rem auto; padding: 0 1rem; font-family: system-ui, sans-serif; line-height: 1.5; color: #1f2328; }
+h2 { border-bottom: 1px solid #d1d9e0; padding-bottom: .3rem; margin-top: 2rem; }
+pre { background: #f6f8fa; padding: .75rem; overflow-x: auto; border-radius: 6px; }
+code { font-family: ui-monospace, monospace; font-size: .9em; }
+blockquote { margin: 0; padding: 0 1rem; color: #59636e; border-left: .25rem solid #d1d9e0; }
+</style>
+</head>
+<body>
+{{.Body}}
+</body>
+</html>
+`))
+
+// writeHTML renders the Markdown of the transcript as a standalone page. The
+// HTML in the messages is escaped.
+func writeHTML(w io.Writer, t *Transcript) error {
+	var body bytes.Buffer
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	if err := md.Convert([]byte(Markdown(t)), &body); err != nil {
+		return fmt.Errorf("failed to render the transcript: %w", err)
+	}
+	return page.Execute(w, struct {
+		Title string
+		Body  template.HTML
+	}{
+		Title: cmp.Or(t.Session.Title, "Untitled"),
+		Body:  template.HTML(body.String()),
+	})
+}
@@ -0,0 +1,295 @@
+// Package transcript exports sessions as shareable transcripts, in Markdown,
+// HTML, or JSON, and imports the JSON ones back into a session store.
+package transcript
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// Format is the format of an export.
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatJSON     Format = "json"
+	FormatHTML     Format = "html"
+)
+
+// Formats are the supported formats.
+var Formats = []Format{FormatMarkdown, FormatJSON, FormatHTML}
+
+// Version is the version of the JSON format, raised when older versions of
+// crush can't import the transcripts anymore.
+const Version = 1
+
+// Transcript is the conversation of a session with the changes it made to
+// files and what it cost.
+type Transcript struct {
+	Version  int       `json:"version"`
+	Session  Session   `json:"session"`
+	Messages []Message `json:"messages"`
+	Files    []File    `json:"files,omitempty"`
+}
+
+// Session is the metadata of the exported session.
+type Session struct {
+	ID               string  `json:"id"`
+	Title            string  `json:"title"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	CacheCost        float64 `json:"cache_cost,omitempty"`
+	ReasoningCost    float64 `json:"reasoning_cost,omitempty"`
+	SummaryMessageID string  `json:"summary_message_id,omitempty"`
+	KeptMessageID    string  `json:"kept_message_id,omitempty"`
+	CreatedAt        int64   `json:"created_at"`
+	UpdatedAt        int64   `json:"updated_at"`
+}
+
+// Message is a message of the session, with its parts encoded as they are
+// stored.
+type Message struct {
+	ID        string                `json:"id"`
+	Role      message.MessageRole   `json:"role"`
+	Model     string                `json:"model,omitempty"`
+	Provider  string                `json:"provider,omitempty"`
+	Parts     []message.ContentPart `json:"-"`
+	CreatedAt int64                 `json:"created_at"`
+}
+
+type messageJSON struct {
+	ID        string              `json:"id"`
+	Role      message.MessageRole `json:"role"`
+	Model     string              `json:"model,omitempty"`
+	Provider  string              `json:"provider,omitempty"`
+	Parts     json.RawMessage     `json:"parts"`
+	CreatedAt int64               `json:"created_at"`
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	parts, err := message.MarshalParts(m.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(messageJSON{
+		ID:        m.ID,
+		Role:      m.Role,
+		Model:     m.Model,
+		Provider:  m.Provider,
+		Parts:     parts,
+		CreatedAt: m.CreatedAt,
+	})
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var raw messageJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw.Parts) == 0 {
+		raw.Parts = json.RawMessage("[]")
+	}
+	parts, err := message.UnmarshalParts(raw.Parts)
+	if err != nil {
+		return fmt.Errorf("invalid parts of message %s: %w", raw.ID, err)
+	}
+	*m = Message{
+		ID:        raw.ID,
+		Role:      raw.Role,
+		Model:     raw.Model,
+		Provider:  raw.Provider,
+		Parts:     parts,
+		CreatedAt: raw.CreatedAt,
+	}
+	return nil
+}
+
+// File is a file the session changed, with its content before the first
+// change and after the last one. The path is relative to the working
+// directory when the file is in it.
+type File struct {
+	Path   string `json:"path"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Build collects the transcript of the session.
+func Build(ctx context.Context, sessions session.Service, messages message.Service, files history.Service, sessionID, workingDir string) (*Transcript, error) {
+	sess, err := sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	msgs, err := messages.List(ctx, sess.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the messages: %w", err)
+	}
+	versions, err := files.ListBySession(ctx, sess.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the file history: %w", err)
+	}
+
+	t := &Transcript{
+		Version: Version,
+		Session: Session{
+			ID:               sess.ID,
+			Title:            sess.Title,
+			PromptTokens:     sess.PromptTokens,
+			CompletionTokens: sess.CompletionTokens,
+			Cost:             sess.Cost,
+			CacheCost:        sess.CacheCost,
+			ReasoningCost:    sess.ReasoningCost,
+			SummaryMessageID: sess.SummaryMessageID,
+			KeptMessageID:    sess.KeptMessageID,
+			CreatedAt:        sess.CreatedAt,
+			UpdatedAt:        sess.UpdatedAt,
+		},
+	}
+	for _, msg := range msgs {
+		t.Messages = append(t.Messages, Message{
+			ID:        msg.ID,
+			Role:      msg.Role,
+			Model:     msg.Model,
+			Provider:  msg.Provider,
+			Parts:     msg.Parts,
+			CreatedAt: msg.CreatedAt,
+		})
+	}
+
+	// The first version of a file is its content before the session changed
+	// it, the last one its content now.
+	byPath := make(map[string][]history.File)
+	for _, version := range versions {
+		byPath[version.Path] = append(byPath[version.Path], version)
+	}
+	for path, versions := range byPath {
+		slices.SortFunc(versions, func(a, b history.File) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+		before, after := versions[0].Content, versions[len(versions)-1].Content
+		if before == after {
+			continue
+		}
+		t.Files = append(t.Files, File{
+			Path:   relativePath(path, workingDir),
+			Before: before,
+			After:  after,
+		})
+	}
+	slices.SortFunc(t.Files, func(a, b File) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return t, nil
+}
+
+func relativePath(path, workingDir string) string {
+	if workingDir == "" {
+		return path
+	}
+	rel, err := filepath.Rel(workingDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Write writes the transcript in the format.
+func Write(w io.Writer, t *Transcript, format Format) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t)
+	case FormatMarkdown:
+		_, err := io.WriteString(w, Markdown(t))
+		return err
+	case FormatHTML:
+		return writeHTML(w, t)
+	}
+	return fmt.Errorf("unknown format %q, expected one of %v", format, Formats)
+}
+
+// Read reads a transcript exported in JSON.
+func Read(r io.Reader) (*Transcript, error) {
+	var t Transcript
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, fmt.Errorf("invalid transcript: %w", err)
+	}
+	if t.Version == 0 {
+		return nil, fmt.Errorf("invalid transcript: missing version, only the JSON exports can be imported")
+	}
+	if t.Version > Version {
+		return nil, fmt.Errorf("the transcript has version %d, this version of crush imports up to version %d", t.Version, Version)
+	}
+	return &t, nil
+}
+
+// Import creates a session with the messages and the file history of the
+// transcript, and the tokens and cost it recorded. The relative paths of
+// the files are in workingDir.
+func Import(ctx context.Context, sessions session.Service, messages message.Service, files history.Service, t *Transcript, workingDir string) (session.Session, error) {
+	sess, err := sessions.Create(ctx, t.Session.Title)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// The messages get new IDs, the summary and the first message kept after
+	// a compaction are looked up by the old ones.
+	ids := make(map[string]string, len(t.Messages))
+	for _, msg := range t.Messages {
+		parts := msg.Parts
+		if msg.Role != message.Assistant {
+			// Creating the message adds the finish part back.
+			parts = slices.DeleteFunc(slices.Clone(parts), func(part message.ContentPart) bool {
+				_, ok := part.(message.Finish)
+				return ok
+			})
+		}
+		created, err := messages.Create(ctx, sess.ID, message.CreateMessageParams{
+			Role:     msg.Role,
+			Parts:    parts,
+			Model:    msg.Model,
+			Provider: msg.Provider,
+		})
+		if err != nil {
+			return sess, fmt.Errorf("failed to create message in session %s: %w", sess.ID, err)
+		}
+		ids[msg.ID] = created.ID
+	}
+
+	for _, file := range t.Files {
+		path := filepath.FromSlash(file.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
+		if _, err := files.Create(ctx, sess.ID, path, file.Before); err != nil {
+			return sess, fmt.Errorf("failed to record the history of %s: %w", file.Path, err)
+		}
+		if _, err := files.CreateVersion(ctx, sess.ID, path, file.After); err != nil {
+			return sess, fmt.Errorf("failed to record the history of %s: %w", file.Path, err)
+		}
+	}
+
+	sess.PromptTokens = t.Session.PromptTokens
+	sess.CompletionTokens = t.Session.CompletionTokens
+	sess.Cost = t.Session.Cost
+	sess.CacheCost = t.Session.CacheCost
+	sess.ReasoningCost = t.Session.ReasoningCost
+	sess.SummaryMessageID = ids[t.Session.SummaryMessageID]
+	sess.KeptMessageID = ids[t.Session.KeptMessageID]
+	saved, err := sessions.Save(ctx, sess)
+	if err != nil {
+		return sess, fmt.Errorf("failed to save session %s: %w", sess.ID, err)
+	}
+	return saved, nil
+}
@@ -0,0 +1,128 @@
+package transcript
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/ncruces/go-sqlite3/vfs/memdb"
+	"github.com/stretchr/testify/require"
+)
+
+type services struct {
+	sessions session.Service
+	messages message.Service
+	files    history.Service
+}
+
+func newServices(t *testing.T) services {
+	t.Helper()
+	store, err := db.OpenSQLiteStore(t.Context(), memdb.TestDB(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		store.Close()
+	})
+	return services{session.NewService(store), message.NewService(store), history.NewService(store)}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := t.Context()
+	src := newServices(t)
+	workingDir := filepath.Join(t.TempDir(), "project")
+	path := filepath.Join(workingDir, "main.go")
+
+	sess, err := src.sessions.Create(ctx, "Fix the greeting")
+	require.NoError(t, err)
+	_, err = src.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.User,
+		Parts: []message.ContentPart{message.TextContent{Text: "Say hello, not <b>hi</b>"}},
+	})
+	require.NoError(t, err)
+	_, err = src.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:     message.Assistant,
+		Model:    "claude-sonnet-4",
+		Provider: "anthropic",
+		Parts: []message.ContentPart{
+			message.TextContent{Text: "Fixing it."},
+			message.ToolCall{ID: "call_1", Name: "edit", Input: `{"file_path":"main.go"}`, Finished: true},
+			message.Finish{Reason: message.FinishReasonToolUse},
+		},
+	})
+	require.NoError(t, err)
+	_, err = src.messages.Create(ctx, sess.ID, message.CreateMessageParams{
+		Role:  message.Tool,
+		Parts: []message.ContentPart{message.ToolResult{ToolCallID: "call_1", Name: "edit", Content: "Edited main.go"}},
+	})
+	require.NoError(t, err)
+	_, err = src.files.Create(ctx, sess.ID, path, "println(\"hi\")\n")
+	require.NoError(t, err)
+	_, err = src.files.CreateVersion(ctx, sess.ID, path, "println(\"hello\")\n")
+	require.NoError(t, err)
+	sess.Cost, sess.PromptTokens, sess.CompletionTokens = 0.0123, 1200, 300
+	_, err = src.sessions.Save(ctx, sess)
+	require.NoError(t, err)
+
+	exported, err := Build(ctx, src.sessions, src.messages, src.files, sess.ID, workingDir)
+	require.NoError(t, err)
+	require.Len(t, exported.Messages, 3)
+	require.Equal(t, []File{{Path: "main.go", Before: "println(\"hi\")\n", After: "println(\"hello\")\n"}}, exported.Files)
+
+	md := Markdown(exported)
+	require.Contains(t, md, "# Fix the greeting")
+	require.Contains(t, md, "- **Models:** anthropic/claude-sonnet-4")
+	require.Contains(t, md, "- **Cost:** $0.0123")
+	require.Contains(t, md, "**Tool call:** `edit`")
+	require.Contains(t, md, "**Result of** `edit`\n\n```\nEdited main.go\n```")
+	require.Contains(t, md, "### main.go (+1 -1)")
+	require.Contains(t, md, "+println(\"hello\")")
+
+	var page bytes.Buffer
+	require.NoError(t, Write(&page, exported, FormatHTML))
+	require.Contains(t, page.String(), "<title>Fix the greeting</title>")
+	require.NotContains(t, page.String(), "<b>hi</b>")
+
+	// The JSON export moves to another store, with the files in its project.
+	var data bytes.Buffer
+	require.NoError(t, Write(&data, exported, FormatJSON))
+	read, err := Read(&data)
+	require.NoError(t, err)
+	dst := newServices(t)
+	otherDir := t.TempDir()
+	imported, err := Import(ctx, dst.sessions, dst.messages, dst.files, read, otherDir)
+	require.NoError(t, err)
+	require.Equal(t, "Fix the greeting", imported.Title)
+	require.Equal(t, 0.0123, imported.Cost)
+	require.Equal(t, int64(1200), imported.PromptTokens)
+
+	msgs, err := dst.messages.List(ctx, imported.ID)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, "Say hello, not <b>hi</b>", msgs[0].Content().String())
+	require.Len(t, msgs[0].Parts, 2)
+	require.Equal(t, "call_1", msgs[1].ToolCalls()[0].ID)
+	require.Equal(t, "Edited main.go", msgs[2].ToolResults()[0].Content)
+
+	file, err := dst.files.GetByPathAndSession(ctx, filepath.Join(otherDir, "main.go"), imported.ID)
+	require.NoError(t, err)
+	require.Equal(t, "println(\"hello\")\n", file.Content)
+
+	reexported, err := Build(ctx, dst.sessions, dst.messages, dst.files, imported.ID, otherDir)
+	require.NoError(t, err)
+	require.Equal(t, exported.Files, reexported.Files)
+}
+
+func TestRead(t *testing.T) {
+	_, err := Read(bytes.NewBufferString(`{"session":{"title":"x"}}`))
+	require.ErrorContains(t, err, "missing version")
+	_, err = Read(bytes.NewBufferString(`{"version":99}`))
+	require.ErrorContains(t, err, "version 99")
+}
+
+func TestFence(t *testing.T) {
+	require.Equal(t, "```go\nx\n```\n", fence("go", "x\n"))
+	require.Equal(t, "````\nuse ``` here\n````\n", fence("", "use ``` here"))
+}
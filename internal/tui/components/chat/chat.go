@@ -2,12 +2,14 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -42,6 +44,8 @@ type MessageListCmp interface {
 
 	SetSession(session.Session) tea.Cmd
 	GoToBottom() tea.Cmd
+	// SetThinkingDisplay sets how the thinking of all the messages is shown.
+	SetThinkingDisplay(display string) tea.Cmd
 }
 
 // messageListCmp implements MessageListCmp, providing a virtualized list
@@ -88,6 +92,8 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case pubsub.Event[permission.PermissionNotification]:
 		return m, m.handlePermissionRequest(msg.Payload)
+	case pubsub.Event[tools.Progress]:
+		return m, m.handleToolProgress(msg.Payload)
 	case SessionSelectedMsg:
 		if msg.ID != m.session.ID {
 			cmd := m.SetSession(msg)
@@ -115,6 +121,22 @@ func (m *messageListCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// SetThinkingDisplay sets how the thinking of all the messages is shown,
+// overriding the ones toggled on each message.
+func (m *messageListCmp) SetThinkingDisplay(display string) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, item := range m.listCmp.Items() {
+		if msg, ok := item.(messages.MessageCmp); ok {
+			if m := msg.GetMessage(); m.ReasoningContent().Thinking == "" {
+				continue
+			}
+			msg.SetThinkingDisplay(display)
+			cmds = append(cmds, m.listCmp.UpdateItem(msg.ID(), msg))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
 // View renders the message list or an initial screen if empty.
 func (m *messageListCmp) View() string {
 	t := styles.CurrentTheme()
@@ -140,6 +162,19 @@ func (m *messageListCmp) handlePermissionRequest(permission permission.Permissio
 	return nil
 }
 
+func (m *messageListCmp) handleToolProgress(progress tools.Progress) tea.Cmd {
+	if progress.SessionID != m.session.ID {
+		return nil
+	}
+	items := m.listCmp.Items()
+	if toolCallIndex := m.findToolCallByID(items, progress.ToolCallID); toolCallIndex != NotFound {
+		toolCall := items[toolCallIndex].(messages.ToolCallCmp)
+		toolCall.SetProgress(progress)
+		m.listCmp.UpdateItem(toolCall.ID(), toolCall)
+	}
+	return nil
+}
+
 // handleChildSession handles messages from child sessions (agent tools).
 func (m *messageListCmp) handleChildSession(event pubsub.Event[message.Message]) tea.Cmd {
 	var cmds []tea.Cmd
@@ -151,7 +186,7 @@ func (m *messageListCmp) handleChildSession(event pubsub.Event[message.Message])
 	var toolCall messages.ToolCallCmp
 	for i := len(items) - 1; i >= 0; i-- {
 		if msg, ok := items[i].(messages.ToolCallCmp); ok {
-			if msg.GetToolCall().ID == event.Payload.SessionID {
+			if msg.GetToolCall().ID == agent.TaskToolCallID(event.Payload.SessionID) {
 				toolCallInx = i
 				toolCall = msg
 			}
@@ -498,7 +533,13 @@ func (m *messageListCmp) convertAssistantMessage(msg message.Message, toolResult
 		uiMessages = append(uiMessages, messages.NewToolCallCmp(msg.ID, tc, m.app.Permissions, options...))
 		// If this tool call is the agent tool, fetch nested tool calls
 		if tc.Name == agent.AgentToolName {
-			nestedMessages, _ := m.app.Messages.List(context.Background(), tc.ID)
+			var params agent.AgentParams
+			_ = json.Unmarshal([]byte(tc.Input), &params)
+			var nestedMessages []message.Message
+			for i := range max(1, len(params.AllTasks())) {
+				taskMessages, _ := m.app.Messages.List(context.Background(), agent.TaskSessionID(tc.ID, i))
+				nestedMessages = append(nestedMessages, taskMessages...)
+			}
 			nestedToolResultMap := m.buildToolResultMap(nestedMessages)
 			nestedUIMessages := m.convertMessagesToUI(nestedMessages, nestedToolResultMap)
 			nestedToolCalls := make([]messages.ToolCallCmp, 0, len(nestedUIMessages))
@@ -0,0 +1,66 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
+)
+
+// pasteImage attaches the image in the clipboard, if any. The text in the
+// clipboard is pasted by the textarea.
+func pasteImage() tea.Msg {
+	content, err := clipboardImage()
+	if err != nil || len(content) == 0 {
+		return nil
+	}
+	attachment, err := message.NewAttachment(fmt.Sprintf("pasted-%s.png", time.Now().Format("150405")), content)
+	if err != nil || !attachment.IsImage() {
+		return nil
+	}
+	return filepicker.FilePickedMsg{Attachment: attachment}
+}
+
+// clipboardImage returns the image in the clipboard as a PNG, read with the
+// clipboard tool of the system.
+func clipboardImage() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	switch runtime.GOOS {
+	case "darwin":
+		// The image is printed as «data PNGf89504E47...».
+		out, err := exec.CommandContext(ctx, "osascript", "-e", "the clipboard as «class PNGf»").Output()
+		if err != nil {
+			return nil, err
+		}
+		data := strings.TrimSuffix(strings.TrimSpace(string(out)), "»")
+		_, data, ok := strings.Cut(data, "«data PNGf")
+		if !ok {
+			return nil, fmt.Errorf("no image in the clipboard")
+		}
+		return hex.DecodeString(data)
+	case "windows":
+		script := `Add-Type -AssemblyName System.Windows.Forms; $i = [System.Windows.Forms.Clipboard]::GetImage(); ` +
+			`if ($i) { $s = New-Object System.IO.MemoryStream; $i.Save($s, [System.Drawing.Imaging.ImageFormat]::Png); [Convert]::ToBase64String($s.ToArray()) }`
+		out, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script).Output()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(out)))
+	default:
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			return exec.CommandContext(ctx, "wl-paste", "--no-newline", "--type", "image/png").Output()
+		}
+		return exec.CommandContext(ctx, "xclip", "-selection", "clipboard", "-target", "image/png", "-out").Output()
+	}
+}
@@ -4,13 +4,13 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/charmbracelet/bubbles/v2/key"
@@ -18,6 +18,8 @@ import (
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/llm/prompt"
+	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
@@ -27,6 +29,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/quit"
+	"github.com/charmbracelet/crush/internal/tui/keymap"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
 	"github.com/charmbracelet/lipgloss/v2"
@@ -49,6 +52,19 @@ type FileCompletionItem struct {
 	Path string // The file path
 }
 
+// SymbolCompletionItem is a symbol of the project, mentioned as @Name with
+// its declaration attached.
+type SymbolCompletionItem struct {
+	Symbol tools.Symbol
+}
+
+// symbolCompletionsMsg has the completions of the files and of the symbols
+// matching the query of an @ mention.
+type symbolCompletionsMsg struct {
+	query       string
+	completions []completions.Completion
+}
+
 type editorCmp struct {
 	width              int
 	height             int
@@ -67,6 +83,16 @@ type editorCmp struct {
 	currentQuery          string
 	completionsStartIndex int
 	isCompletionsOpen     bool
+	// completionsPrefix is kept before the completed path, "@" to attach the
+	// file.
+	completionsPrefix string
+
+	// vim is set with the vim keymap, the editor is then in normal mode or
+	// in insert mode.
+	vim        bool
+	normalMode bool
+	// vimPending is the first key of a command of two, such as dd.
+	vimPending string
 }
 
 var DeleteKeyMaps = DeleteAttachmentKeyMaps{
@@ -86,6 +112,10 @@ var DeleteKeyMaps = DeleteAttachmentKeyMaps{
 
 const (
 	maxAttachments = 5
+	// minSymbolQuery is the length of the query of an @ mention from which
+	// the language servers are asked for the matching symbols.
+	minSymbolQuery       = 2
+	maxSymbolCompletions = 50
 )
 
 type OpenEditorMsg struct {
@@ -141,35 +171,83 @@ func (m *editorCmp) send() tea.Cmd {
 	if m.app.CoderAgent == nil {
 		return util.ReportError(fmt.Errorf("coder agent is not initialized"))
 	}
-	if m.app.CoderAgent.IsSessionBusy(m.session.ID) {
-		return util.ReportWarn("Agent is working, please wait...")
-	}
 
 	value := m.textarea.Value()
 	value = strings.TrimSpace(value)
 
+	// Queued prompts run in their own session, they don't wait for this one.
+	if prompt, ok := strings.CutPrefix(value, "/queue"); ok && (prompt == "" || prompt[0] == ' ' || prompt[0] == '\n') {
+		prompt = strings.TrimSpace(prompt)
+		if prompt == "" {
+			return util.ReportWarn("Usage: /queue <prompt>")
+		}
+		m.textarea.Reset()
+		return util.CmdHandler(commands.EnqueueTaskMsg{Prompt: prompt})
+	}
+	if m.app.CoderAgent.IsSessionBusy(m.session.ID) {
+		return util.ReportWarn("Agent is working, please wait...")
+	}
+
 	switch value {
 	case "exit", "quit":
 		m.textarea.Reset()
 		return util.CmdHandler(dialogs.OpenDialogMsg{Model: quit.NewQuitDialog()})
+	case "/compact":
+		m.textarea.Reset()
+		if m.session.ID == "" {
+			return util.ReportWarn("No session to compact")
+		}
+		return util.CmdHandler(commands.CompactMsg{SessionID: m.session.ID})
+	}
+	if args, ok := strings.CutPrefix(value, "/memory"); ok && (args == "" || args[0] == ' ') {
+		note, ok := strings.CutPrefix(strings.TrimSpace(args), "edit")
+		if !ok || (note != "" && note[0] != ' ' && note[0] != '\n') {
+			return util.ReportWarn("Usage: /memory edit [fact to remember]")
+		}
+		m.textarea.Reset()
+		return util.CmdHandler(chat.SendMsg{Text: prompt.Remember(note)})
+	}
+	if name, ok := strings.CutPrefix(value, "/agent"); ok && (name == "" || name[0] == ' ') {
+		m.textarea.Reset()
+		return util.CmdHandler(commands.SwitchAgentMsg{Name: strings.TrimSpace(name)})
+	}
+	if notes, ok := strings.CutPrefix(value, "/merge"); ok && (notes == "" || notes[0] == ' ' || notes[0] == '\n') {
+		m.textarea.Reset()
+		if m.session.ID == "" {
+			return util.ReportWarn("No session to merge")
+		}
+		return util.CmdHandler(commands.MergeNotesMsg{SessionID: m.session.ID, Notes: notes})
 	}
 
-	m.textarea.Reset()
-	attachments := m.attachments
-
-	m.attachments = nil
 	if value == "" {
+		m.textarea.Reset()
+		m.attachments = nil
 		return nil
 	}
+	mentioned, err := message.MentionedAttachments(value, m.app.Config().WorkingDir())
+	if err != nil {
+		return util.ReportError(err)
+	}
+
+	m.textarea.Reset()
+	m.normalMode = false
+	attachments := append(m.attachments, mentioned...)
+
+	m.attachments = nil
 
 	// Change the placeholder when sending a new message.
 	m.randomizePlaceholders()
 
+	var warn tea.Cmd
+	if !m.app.CoderAgent.Model().SupportsImages && slices.ContainsFunc(attachments, message.Attachment.IsImage) {
+		warn = util.ReportWarn("The model doesn't support images, it's only told they're attached")
+	}
 	return tea.Batch(
 		util.CmdHandler(chat.SendMsg{
 			Text:        value,
 			Attachments: attachments,
 		}),
+		warn,
 	)
 }
 
@@ -186,7 +264,7 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.repositionCompletions
 	case filepicker.FilePickedMsg:
 		if len(m.attachments) >= maxAttachments {
-			return m, util.ReportError(fmt.Errorf("cannot add more than %d images", maxAttachments))
+			return m, util.ReportError(fmt.Errorf("cannot add more than %d attachments", maxAttachments))
 		}
 		m.attachments = append(m.attachments, msg.Attachment)
 		return m, nil
@@ -205,7 +283,7 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// If the selected item is a file, insert its path into the textarea
 			value := m.textarea.Value()
 			value = value[:m.completionsStartIndex] + // Remove the current query
-				item.Path + // Insert the file path
+				m.completionsPrefix + item.Path + // Insert the file path
 				value[m.completionsStartIndex+len(word):] // Append the rest of the value
 			// XXX: This will always move the cursor to the end of the textarea.
 			m.textarea.SetValue(value)
@@ -216,6 +294,40 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.completionsStartIndex = 0
 			}
 		}
+		if item, ok := msg.Value.(SymbolCompletionItem); ok {
+			word := m.textarea.Word()
+			value := m.textarea.Value()
+			value = value[:m.completionsStartIndex] + "@" + item.Symbol.Name + value[m.completionsStartIndex+len(word):]
+			m.textarea.SetValue(value)
+			m.textarea.MoveToEnd()
+			if !msg.Insert {
+				m.isCompletionsOpen = false
+				m.currentQuery = ""
+				m.completionsStartIndex = 0
+				return m, m.attachSymbol(item.Symbol)
+			}
+		}
+	case symbolCompletionsMsg:
+		// The mention may have changed while the servers searched.
+		if m.textarea.Word() != "@"+msg.query {
+			return m, nil
+		}
+		x, y := m.completionsPosition()
+		x -= len(msg.query)
+		m.isCompletionsOpen = true
+		return m, tea.Sequence(
+			util.CmdHandler(completions.OpenCompletionsMsg{
+				Completions: msg.completions,
+				X:           x,
+				Y:           y,
+			}),
+			util.CmdHandler(completions.FilterCompletionsMsg{
+				Query:  msg.query,
+				Reopen: true,
+				X:      x,
+				Y:      y,
+			}),
+		)
 
 	case commands.OpenExternalEditorMsg:
 		if m.app.CoderAgent.IsSessionBusy(m.session.ID) {
@@ -226,58 +338,39 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.textarea.SetValue(msg.Text)
 		m.textarea.MoveToEnd()
 	case tea.PasteMsg:
-		path := strings.ReplaceAll(string(msg), "\\ ", " ")
-		// try to get an image
-		path, err := filepath.Abs(path)
-		if err != nil {
-			m.textarea, cmd = m.textarea.Update(msg)
-			return m, cmd
-		}
-		isAllowedType := false
-		for _, ext := range filepicker.AllowedTypes {
-			if strings.HasSuffix(path, ext) {
-				isAllowedType = true
-				break
-			}
-		}
-		if !isAllowedType {
-			m.textarea, cmd = m.textarea.Update(msg)
-			return m, cmd
-		}
-		tooBig, _ := filepicker.IsFileTooBig(path, filepicker.MaxAttachmentSize)
-		if tooBig {
-			m.textarea, cmd = m.textarea.Update(msg)
-			return m, cmd
+		// Files dropped in the terminal are pasted as their path.
+		if attachment, ok := droppedFile(string(msg)); ok {
+			return m, util.CmdHandler(filepicker.FilePickedMsg{
+				Attachment: attachment,
+			})
 		}
-
-		content, err := os.ReadFile(path)
-		if err != nil {
-			m.textarea, cmd = m.textarea.Update(msg)
-			return m, cmd
-		}
-		mimeBufferSize := min(512, len(content))
-		mimeType := http.DetectContentType(content[:mimeBufferSize])
-		fileName := filepath.Base(path)
-		attachment := message.Attachment{FilePath: path, FileName: fileName, MimeType: mimeType, Content: content}
-		return m, util.CmdHandler(filepicker.FilePickedMsg{
-			Attachment: attachment,
-		})
+		m.textarea, cmd = m.textarea.Update(msg)
+		return m, cmd
 
 	case tea.KeyPressMsg:
+		if m.vim {
+			if handled, cmd := m.vimKey(msg); handled {
+				return m, cmd
+			}
+		}
 		cur := m.textarea.Cursor()
 		curIdx := m.textarea.Width()*cur.Y + cur.X
 		switch {
 		// Completions
-		case msg.String() == "/" && !m.isCompletionsOpen &&
+		case (msg.String() == "/" || msg.String() == "@") && !m.isCompletionsOpen &&
 			// only show if beginning of prompt, or if previous char is a space or newline:
 			(len(m.textarea.Value()) == 0 || unicode.IsSpace(rune(m.textarea.Value()[len(m.textarea.Value())-1]))):
 			m.isCompletionsOpen = true
 			m.currentQuery = ""
 			m.completionsStartIndex = curIdx
+			m.completionsPrefix = strings.TrimPrefix(msg.String(), "/")
 			cmds = append(cmds, m.startCompletions)
 		case m.isCompletionsOpen && curIdx <= m.completionsStartIndex:
 			cmds = append(cmds, util.CmdHandler(completions.CloseCompletionsMsg{}))
 		}
+		if key.Matches(msg, m.keyMap.PasteImage) {
+			cmds = append(cmds, pasteImage)
+		}
 		if key.Matches(msg, DeleteKeyMaps.AttachmentDeleteMode) {
 			m.deleteMode = true
 			return m, nil
@@ -340,10 +433,11 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				cmds = append(cmds, util.CmdHandler(completions.CloseCompletionsMsg{}))
 			} else {
 				word := m.textarea.Word()
-				if strings.HasPrefix(word, "/") {
+				if strings.HasPrefix(word, "/") || strings.HasPrefix(word, "@") {
 					// XXX: wont' work if editing in the middle of the field.
 					m.completionsStartIndex = strings.LastIndex(m.textarea.Value(), word)
 					m.currentQuery = word[1:]
+					m.completionsPrefix = strings.TrimPrefix(word[:1], "/")
 					x, y := m.completionsPosition()
 					x -= len(m.currentQuery)
 					m.isCompletionsOpen = true
@@ -355,6 +449,9 @@ func (m *editorCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							Y:      y,
 						}),
 					)
+					if m.completionsPrefix == "@" && len(m.currentQuery) >= minSymbolQuery && len(m.app.LSPClients) > 0 {
+						cmds = append(cmds, m.findSymbols(m.currentQuery))
+					}
 				} else if m.isCompletionsOpen {
 					m.isCompletionsOpen = false
 					m.currentQuery = ""
@@ -466,6 +563,32 @@ func (m *editorCmp) attachmentsContent() string {
 	return content
 }
 
+// droppedFile returns the file of the pasted path as an attachment, if the
+// path is absolute, as when the file is dropped, or of an image.
+func droppedFile(pasted string) (message.Attachment, bool) {
+	path := strings.TrimSpace(pasted)
+	if strings.ContainsRune(path, '\n') {
+		return message.Attachment{}, false
+	}
+	path = strings.TrimPrefix(strings.Trim(path, `'"`), "file://")
+	path = strings.ReplaceAll(path, "\\ ", " ")
+	isImage := slices.ContainsFunc(filepicker.AllowedTypes, func(ext string) bool {
+		return strings.HasSuffix(strings.ToLower(path), ext)
+	})
+	if !filepath.IsAbs(path) && !isImage {
+		return message.Attachment{}, false
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return message.Attachment{}, false
+	}
+	attachment, err := message.ReadAttachment(path)
+	if err != nil {
+		return message.Attachment{}, false
+	}
+	return attachment, true
+}
+
 func (m *editorCmp) SetPosition(x, y int) tea.Cmd {
 	m.x = x
 	m.y = y
@@ -473,6 +596,15 @@ func (m *editorCmp) SetPosition(x, y int) tea.Cmd {
 }
 
 func (m *editorCmp) startCompletions() tea.Msg {
+	x, y := m.completionsPosition()
+	return completions.OpenCompletionsMsg{
+		Completions: fileCompletions(),
+		X:           x,
+		Y:           y,
+	}
+}
+
+func fileCompletions() []completions.Completion {
 	files, _, _ := fsext.ListDirectory(".", []string{}, 0)
 	completionItems := make([]completions.Completion, 0, len(files))
 	for _, file := range files {
@@ -484,12 +616,49 @@ func (m *editorCmp) startCompletions() tea.Msg {
 			},
 		})
 	}
+	return completionItems
+}
 
-	x, y := m.completionsPosition()
-	return completions.OpenCompletionsMsg{
-		Completions: completionItems,
-		X:           x,
-		Y:           y,
+// findSymbols returns the completions of the files and of the symbols the
+// language servers find for the query.
+func (m *editorCmp) findSymbols(query string) tea.Cmd {
+	lspClients := m.app.LSPClients
+	workingDir := m.app.Config().WorkingDir()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		symbols := tools.FindSymbols(ctx, lspClients, query)
+		if len(symbols) == 0 {
+			return nil
+		}
+		completionItems := fileCompletions()
+		for _, symbol := range symbols[:min(len(symbols), maxSymbolCompletions)] {
+			completionItems = append(completionItems, completions.Completion{
+				Title: symbol.Label(workingDir),
+				Value: SymbolCompletionItem{Symbol: symbol},
+			})
+		}
+		return symbolCompletionsMsg{query: query, completions: completionItems}
+	}
+}
+
+// attachSymbol attaches the declaration of the symbol.
+func (m *editorCmp) attachSymbol(symbol tools.Symbol) tea.Cmd {
+	lspClients := m.app.LSPClients
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		reference, err := symbol.Reference(ctx, lspClients)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: fmt.Sprintf("cannot attach %s: %s", symbol.Name, err)}
+		}
+		path, _ := symbol.URI.Path()
+		return filepicker.FilePickedMsg{Attachment: message.Attachment{
+			FilePath: path,
+			FileName: symbol.Name,
+			MimeType: "text/plain",
+			Content:  []byte(reference),
+		}}
 	}
 }
 
@@ -533,8 +702,17 @@ func New(app *app.App) Editor {
 	t := styles.CurrentTheme()
 	ta := textarea.New()
 	ta.SetStyles(t.S().TextArea)
+	e := &editorCmp{
+		// TODO: remove the app instance from here
+		app:    app,
+		keyMap: DefaultEditorKeyMap(),
+		vim:    keymap.Vim(),
+	}
 	ta.SetPromptFunc(4, func(info textarea.PromptInfo) string {
 		if info.LineNumber == 0 {
+			if e.normalMode {
+				return t.S().Base.Foreground(t.GreenDark).Render("  N ")
+			}
 			return "  > "
 		}
 		if info.Focused {
@@ -548,12 +726,7 @@ func New(app *app.App) Editor {
 	ta.SetVirtualCursor(false)
 	ta.Focus()
 
-	e := &editorCmp{
-		// TODO: remove the app instance from here
-		app:      app,
-		textarea: ta,
-		keyMap:   DefaultEditorKeyMap(),
-	}
+	e.textarea = ta
 
 	e.randomizePlaceholders()
 	e.textarea.Placeholder = e.readyPlaceholder
@@ -2,6 +2,7 @@ package editor
 
 import (
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/tui/keymap"
 )
 
 type EditorKeyMap struct {
@@ -9,6 +10,7 @@ type EditorKeyMap struct {
 	SendMessage key.Binding
 	OpenEditor  key.Binding
 	Newline     key.Binding
+	PasteImage  key.Binding
 }
 
 func DefaultEditorKeyMap() EditorKeyMap {
@@ -17,21 +19,25 @@ func DefaultEditorKeyMap() EditorKeyMap {
 			key.WithKeys("/"),
 			key.WithHelp("/", "add file"),
 		),
-		SendMessage: key.NewBinding(
+		SendMessage: keymap.Bind("send", key.NewBinding(
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "send"),
-		),
-		OpenEditor: key.NewBinding(
+		)),
+		OpenEditor: keymap.Bind("open_editor", key.NewBinding(
 			key.WithKeys("ctrl+o"),
 			key.WithHelp("ctrl+o", "open editor"),
-		),
-		Newline: key.NewBinding(
+		)),
+		Newline: keymap.Bind("newline", key.NewBinding(
 			key.WithKeys("shift+enter", "ctrl+j"),
 			// "ctrl+j" is a common keybinding for newline in many editors. If
 			// the terminal supports "shift+enter", we substitute the help text
 			// to reflect that.
 			key.WithHelp("ctrl+j", "newline"),
-		),
+		)),
+		PasteImage: keymap.Bind("paste_image", key.NewBinding(
+			key.WithKeys("ctrl+v"),
+			key.WithHelp("ctrl+v", "paste image"),
+		)),
 	}
 }
 
@@ -42,6 +48,7 @@ func (k EditorKeyMap) KeyBindings() []key.Binding {
 		k.SendMessage,
 		k.OpenEditor,
 		k.Newline,
+		k.PasteImage,
 		AttachmentsKeyMaps.AttachmentDeleteMode,
 		AttachmentsKeyMaps.DeleteAllAttachments,
 		AttachmentsKeyMaps.Escape,
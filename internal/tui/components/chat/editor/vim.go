@@ -0,0 +1,128 @@
+package editor
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+)
+
+// vimMotions are the keys of the normal mode of vim moving the cursor or
+// editing the text, with the keys of the textarea doing the same.
+var vimMotions = map[string][]tea.KeyPressMsg{
+	"h":  {{Code: tea.KeyLeft}},
+	"l":  {{Code: tea.KeyRight}},
+	"j":  {{Code: tea.KeyDown}},
+	"k":  {{Code: tea.KeyUp}},
+	"w":  {{Code: 'f', Mod: tea.ModAlt}},
+	"e":  {{Code: 'f', Mod: tea.ModAlt}},
+	"b":  {{Code: 'b', Mod: tea.ModAlt}},
+	"0":  {{Code: tea.KeyHome}},
+	"^":  {{Code: tea.KeyHome}},
+	"$":  {{Code: tea.KeyEnd}},
+	"gg": {{Code: tea.KeyHome, Mod: tea.ModCtrl}},
+	"G":  {{Code: tea.KeyEnd, Mod: tea.ModCtrl}},
+	"x":  {{Code: tea.KeyDelete}},
+	"X":  {{Code: tea.KeyBackspace}},
+	"D":  {{Code: 'k', Mod: tea.ModCtrl}},
+	"dw": {{Code: 'd', Mod: tea.ModAlt}},
+	"db": {{Code: 'w', Mod: tea.ModCtrl}},
+}
+
+// vimInserts are the keys of the normal mode of vim entering the insert
+// mode, with the keys of the textarea to press first.
+var vimInserts = map[string][]tea.KeyPressMsg{
+	"i":  nil,
+	"a":  {{Code: tea.KeyRight}},
+	"A":  {{Code: tea.KeyEnd}},
+	"I":  {{Code: tea.KeyHome}},
+	"C":  {{Code: 'k', Mod: tea.ModCtrl}},
+	"cw": {{Code: 'd', Mod: tea.ModAlt}},
+	"cc": {{Code: tea.KeyHome}, {Code: 'k', Mod: tea.ModCtrl}},
+	"S":  {{Code: tea.KeyHome}, {Code: 'k', Mod: tea.ModCtrl}},
+}
+
+// vimKey handles the key in vim mode, reporting whether it did. Esc enters
+// the normal mode, where the letters move the cursor and edit the text like
+// in vim, and the other keys, such as enter to send, work as usual.
+func (m *editorCmp) vimKey(msg tea.KeyPressMsg) (bool, tea.Cmd) {
+	if !m.normalMode {
+		if msg.Code == tea.KeyEscape && msg.Mod == 0 && !m.deleteMode && !m.isCompletionsOpen {
+			m.normalMode = true
+			m.vimPending = ""
+			return true, nil
+		}
+		return false, nil
+	}
+	if msg.Text == "" || msg.Mod&(tea.ModCtrl|tea.ModAlt) != 0 {
+		m.vimPending = ""
+		return false, nil
+	}
+
+	command := m.vimPending + msg.Text
+	m.vimPending = ""
+	if keys, ok := vimMotions[command]; ok {
+		return true, m.pressKeys(keys...)
+	}
+	if keys, ok := vimInserts[command]; ok {
+		m.normalMode = false
+		return true, m.pressKeys(keys...)
+	}
+	switch command {
+	case "o", "O":
+		row := m.textarea.Line()
+		if command == "O" {
+			row--
+		}
+		m.insertLine(row)
+		m.normalMode = false
+	case "dd":
+		m.deleteLine()
+	case "d", "c", "g":
+		// The first key of a command of two.
+		m.vimPending = command
+	}
+	// The other letters don't insert text in normal mode.
+	return true, nil
+}
+
+// pressKeys presses the keys in the textarea.
+func (m *editorCmp) pressKeys(keys ...tea.KeyPressMsg) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, k := range keys {
+		var cmd tea.Cmd
+		m.textarea, cmd = m.textarea.Update(k)
+		cmds = append(cmds, cmd)
+	}
+	return tea.Batch(cmds...)
+}
+
+// insertLine inserts an empty line after the row, or first if the row is -1,
+// and moves the cursor to it.
+func (m *editorCmp) insertLine(row int) {
+	lines := strings.Split(m.textarea.Value(), "\n")
+	lines = append(lines[:row+1], append([]string{""}, lines[row+1:]...)...)
+	m.setLines(lines, row+1)
+}
+
+// deleteLine deletes the line of the cursor.
+func (m *editorCmp) deleteLine() {
+	lines := strings.Split(m.textarea.Value(), "\n")
+	row := m.textarea.Line()
+	if len(lines) == 1 {
+		m.textarea.Reset()
+		return
+	}
+	lines = append(lines[:row], lines[row+1:]...)
+	m.setLines(lines, min(row, len(lines)-1))
+}
+
+// setLines sets the text of the textarea and moves the cursor to the start
+// of the row.
+func (m *editorCmp) setLines(lines []string, row int) {
+	m.textarea.SetValue(strings.Join(lines, "\n"))
+	m.textarea.MoveToBegin()
+	for range row {
+		m.textarea.CursorDown()
+	}
+	m.textarea.CursorStart()
+}
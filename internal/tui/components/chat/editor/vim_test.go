@@ -0,0 +1,47 @@
+package editor
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/v2/textarea"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVimKey(t *testing.T) {
+	m := &editorCmp{textarea: textarea.New(), vim: true}
+	m.textarea.Focus()
+	m.textarea.SetValue("first line\nsecond line")
+
+	press := func(keys ...string) {
+		t.Helper()
+		for _, k := range keys {
+			handled, _ := m.vimKey(tea.KeyPressMsg{Code: []rune(k)[0], Text: k})
+			require.True(t, handled, k)
+		}
+	}
+
+	handled, _ := m.vimKey(tea.KeyPressMsg{Code: 'x', Text: "x"})
+	require.False(t, handled, "insert mode")
+
+	handled, _ = m.vimKey(tea.KeyPressMsg{Code: tea.KeyEscape})
+	require.True(t, handled)
+	require.True(t, m.normalMode)
+
+	press("g", "g", "d", "d")
+	require.Equal(t, "second line", m.textarea.Value())
+
+	press("$", "X", "X")
+	require.Equal(t, "second li", m.textarea.Value())
+
+	press("o")
+	require.False(t, m.normalMode)
+	require.Equal(t, "second li\n", m.textarea.Value())
+	require.Equal(t, 1, m.textarea.Line())
+
+	handled, _ = m.vimKey(tea.KeyPressMsg{Code: tea.KeyEscape})
+	require.True(t, handled)
+	press("k", "0", "D", "A")
+	require.False(t, m.normalMode)
+	require.Equal(t, "\n", m.textarea.Value())
+}
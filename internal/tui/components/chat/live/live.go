@@ -0,0 +1,267 @@
+// Package live shows what the agent is doing while it works: the output of
+// its last tool call as it runs, and the diagnostics of the LSPs.
+package live
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/lsp"
+	"github.com/charmbracelet/crush/internal/lsp/protocol"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxOutputLines is the number of lines of output kept for a tool call.
+const maxOutputLines = 500
+
+// inputKeys are the parameters of the tools shown to describe their calls,
+// in order of preference.
+var inputKeys = []string{"command", "file_path", "path", "url", "pattern", "query", "prompt"}
+
+type Panel interface {
+	util.Model
+	layout.Sizeable
+	SetSession(session session.Session) tea.Cmd
+}
+
+// toolRun is a tool call of the session and its output.
+type toolRun struct {
+	name   string
+	input  string
+	output []string
+	// status is the last progress message, for tools without output.
+	status string
+	done   bool
+	failed bool
+}
+
+type panel struct {
+	width, height int
+	sessionID     string
+	lspClients    map[string]*lsp.Client
+
+	runs    map[string]*toolRun
+	current string
+}
+
+func New(lspClients map[string]*lsp.Client) Panel {
+	return &panel{
+		lspClients: lspClients,
+		runs:       make(map[string]*toolRun),
+	}
+}
+
+func (p *panel) Init() tea.Cmd {
+	return nil
+}
+
+func (p *panel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case pubsub.Event[message.Message]:
+		if msg.Payload.SessionID == p.sessionID {
+			p.handleMessage(msg.Payload)
+		}
+	case pubsub.Event[tools.Progress]:
+		if msg.Payload.SessionID == p.sessionID {
+			p.handleProgress(msg.Payload)
+		}
+	}
+	return p, nil
+}
+
+// handleMessage follows the tool calls of the assistant messages, whose input
+// streams in with the message, and their results.
+func (p *panel) handleMessage(msg message.Message) {
+	for _, call := range msg.ToolCalls() {
+		run, ok := p.runs[call.ID]
+		if !ok {
+			run = &toolRun{}
+			p.runs[call.ID] = run
+			p.current = call.ID
+		}
+		run.name, run.input = call.Name, describeInput(call.Input)
+	}
+	for _, result := range msg.ToolResults() {
+		run, ok := p.runs[result.ToolCallID]
+		if !ok {
+			continue
+		}
+		run.done, run.failed = true, result.IsError
+		if len(run.output) == 0 {
+			run.appendOutput(result.Content)
+		}
+	}
+}
+
+func (p *panel) handleProgress(progress tools.Progress) {
+	run, ok := p.runs[progress.ToolCallID]
+	if !ok {
+		run = &toolRun{}
+		p.runs[progress.ToolCallID] = run
+	}
+	p.current = progress.ToolCallID
+	run.appendOutput(progress.Output)
+	run.status = progress.Message
+}
+
+func (r *toolRun) appendOutput(output string) {
+	output = strings.TrimSuffix(output, "\n")
+	if output == "" {
+		return
+	}
+	r.output = append(r.output, strings.Split(output, "\n")...)
+	if len(r.output) > maxOutputLines {
+		r.output = slices.Clone(r.output[len(r.output)-maxOutputLines:])
+	}
+}
+
+// describeInput returns the main parameter of a tool call.
+func describeInput(input string) string {
+	var params map[string]any
+	if err := json.Unmarshal([]byte(input), &params); err != nil {
+		return input
+	}
+	for _, key := range inputKeys {
+		if value, ok := params[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return input
+}
+
+func (p *panel) View() string {
+	t := styles.CurrentTheme()
+	width := max(0, p.width-2)
+
+	diagnostics := p.renderDiagnostics(width, max(1, p.height/3))
+	outputHeight := p.height - lipgloss.Height(diagnostics) - 1
+	parts := []string{p.renderRun(width, outputHeight)}
+	if diagnostics != "" {
+		parts = append(parts, "", diagnostics)
+	}
+
+	return t.S().Base.
+		Width(p.width).
+		Height(p.height).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
+}
+
+// renderRun renders the current tool call, with the end of its output.
+func (p *panel) renderRun(width, height int) string {
+	t := styles.CurrentTheme()
+	run, ok := p.runs[p.current]
+	if !ok {
+		return lipgloss.JoinVertical(lipgloss.Left,
+			core.Section("Live", width),
+			t.S().Subtle.Render("No tool running"),
+		)
+	}
+
+	state := t.S().Base.Foreground(t.Info).Render("running")
+	switch {
+	case run.failed:
+		state = t.S().Base.Foreground(t.Error).Render("failed")
+	case run.done:
+		state = t.S().Base.Foreground(t.Success).Render("done")
+	}
+	title := core.SectionWithInfo(cmp.Or(run.name, "Tool"), width, state)
+	lines := []string{title}
+	if run.input != "" {
+		input := strings.ReplaceAll(run.input, "\n", " ")
+		lines = append(lines, t.S().Muted.Render(ansi.Truncate(input, width, "…")))
+	}
+
+	output := run.output
+	if len(output) == 0 && run.status != "" && !run.done {
+		output = []string{run.status}
+	}
+	if rows := height - len(lines); rows > 0 && len(output) > rows {
+		output = output[len(output)-rows:]
+	}
+	for _, line := range output {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		lines = append(lines, t.S().Text.Render(ansi.Truncate(ansi.Strip(line), width, "…")))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// diagnostic is a diagnostic of a file, as reported by an LSP.
+type diagnostic struct {
+	path string
+	protocol.Diagnostic
+}
+
+// renderDiagnostics renders the errors and warnings of the LSPs, the errors
+// first, in at most height lines.
+func (p *panel) renderDiagnostics(width, height int) string {
+	t := styles.CurrentTheme()
+	var diagnostics []diagnostic
+	for _, client := range p.lspClients {
+		for uri, fileDiagnostics := range client.GetDiagnostics() {
+			path, err := uri.Path()
+			if err != nil {
+				continue
+			}
+			for _, d := range fileDiagnostics {
+				if d.Severity == protocol.SeverityError || d.Severity == protocol.SeverityWarning {
+					diagnostics = append(diagnostics, diagnostic{path: fsext.PrettyPath(path), Diagnostic: d})
+				}
+			}
+		}
+	}
+	if len(diagnostics) == 0 {
+		return ""
+	}
+	slices.SortFunc(diagnostics, func(a, b diagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.Severity, b.Severity),
+			cmp.Compare(a.path, b.path),
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+		)
+	})
+
+	lines := []string{core.SectionWithInfo("Diagnostics", width, t.S().Subtle.Render(fmt.Sprintf("%d", len(diagnostics))))}
+	for _, d := range diagnostics[:min(len(diagnostics), max(0, height-1))] {
+		icon := t.S().Base.Foreground(t.Error).Render(styles.ErrorIcon)
+		if d.Severity == protocol.SeverityWarning {
+			icon = t.S().Base.Foreground(t.Warning).Render(styles.WarningIcon)
+		}
+		location := fmt.Sprintf("%s:%d:%d", d.path, d.Range.Start.Line+1, d.Range.Start.Character+1)
+		text := fmt.Sprintf("%s %s", location, strings.ReplaceAll(d.Message, "\n", " "))
+		lines = append(lines, icon+" "+t.S().Muted.Render(ansi.Truncate(text, width-2, "…")))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (p *panel) SetSession(session session.Session) tea.Cmd {
+	if session.ID != p.sessionID {
+		p.sessionID = session.ID
+		p.runs = make(map[string]*toolRun)
+		p.current = ""
+	}
+	return nil
+}
+
+func (p *panel) GetSize() (int, int) {
+	return p.width, p.height
+}
+
+func (p *panel) SetSize(width, height int) tea.Cmd {
+	p.width, p.height = width, height
+	return nil
+}
@@ -0,0 +1,61 @@
+package live
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeInput(t *testing.T) {
+	require.Equal(t, "go test ./...", describeInput(`{"command":"go test ./...","timeout":60}`))
+	require.Equal(t, "main.go", describeInput(`{"file_path":"main.go","old_string":"a"}`))
+	require.Equal(t, `{"command":"go te`, describeInput(`{"command":"go te`))
+	require.Equal(t, `{"limit":10}`, describeInput(`{"limit":10}`))
+}
+
+func TestPanelFollowsToolRuns(t *testing.T) {
+	p := New(nil).(*panel)
+	p.SetSession(session.Session{ID: "session"})
+
+	call := message.Message{
+		SessionID: "session",
+		Role:      message.Assistant,
+		Parts:     []message.ContentPart{message.ToolCall{ID: "call", Name: "bash", Input: `{"command":"make"}`}},
+	}
+	p.Update(pubsub.Event[message.Message]{Payload: call})
+	p.Update(pubsub.Event[tools.Progress]{Payload: tools.Progress{SessionID: "session", ToolCallID: "call", Output: "building\n"}})
+	p.Update(pubsub.Event[tools.Progress]{Payload: tools.Progress{SessionID: "other", ToolCallID: "call", Output: "ignored\n"}})
+	p.Update(pubsub.Event[tools.Progress]{Payload: tools.Progress{SessionID: "session", ToolCallID: "call", Output: "linking\ndone\n"}})
+
+	run := p.runs[p.current]
+	require.Equal(t, "bash", run.name)
+	require.Equal(t, "make", run.input)
+	require.Equal(t, []string{"building", "linking", "done"}, run.output)
+	require.False(t, run.done)
+
+	result := message.Message{
+		SessionID: "session",
+		Role:      message.Tool,
+		Parts:     []message.ContentPart{message.ToolResult{ToolCallID: "call", Content: "exit status 2", IsError: true}},
+	}
+	p.Update(pubsub.Event[message.Message]{Payload: result})
+	require.True(t, run.done)
+	require.True(t, run.failed)
+	require.Equal(t, []string{"building", "linking", "done"}, run.output)
+
+	p.SetSession(session.Session{ID: "next"})
+	require.Empty(t, p.runs)
+}
+
+func TestToolRunKeepsLastLines(t *testing.T) {
+	var run toolRun
+	run.appendOutput(strings.Repeat("line\n", maxOutputLines))
+	run.appendOutput("last\n")
+	require.Len(t, run.output, maxOutputLines)
+	require.Equal(t, "last", run.output[maxOutputLines-1])
+}
@@ -0,0 +1,153 @@
+package messages
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/glamour/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// markdownRenderer renders the markdown of a message, keeping the result. As
+// the message streams, the blocks before its last blank line out of code
+// blocks are complete: they are rendered once, and only the last block is
+// rendered again with each token, with its code block closed if still open.
+// Finished messages are rendered as a whole.
+type markdownRenderer struct {
+	width    int
+	renderer *glamour.TermRenderer
+
+	// The complete blocks of the streaming message, and their rendering.
+	source string
+	blocks []string
+
+	// The last content rendered as a whole.
+	content  string
+	rendered string
+}
+
+func (r *markdownRenderer) render(content string, width int, streaming bool) string {
+	if width != r.width || r.renderer == nil {
+		*r = markdownRenderer{width: width, renderer: styles.GetMarkdownRenderer(width)}
+	}
+	if !streaming {
+		if content != r.content || r.rendered == "" {
+			rendered, _ := r.renderer.Render(content)
+			r.content, r.rendered = content, strings.TrimSuffix(rendered, "\n")
+		}
+		return r.rendered
+	}
+
+	if !strings.HasPrefix(content, r.source) {
+		r.source, r.blocks = "", nil
+	}
+	blocks, end := splitBlocks(content[len(r.source):])
+	for _, block := range blocks {
+		r.blocks = append(r.blocks, r.renderBlock(block))
+	}
+	r.source += content[len(r.source) : len(r.source)+end]
+
+	parts := r.blocks
+	if last := strings.Trim(content[len(r.source):], "\n"); strings.TrimSpace(last) != "" {
+		if fence := openFence(last); fence != "" {
+			last += "\n" + fence
+		}
+		parts = append(slices.Clip(parts), r.renderBlock(last))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// renderBlock renders a block without the blank lines around it.
+func (r *markdownRenderer) renderBlock(block string) string {
+	rendered, err := r.renderer.Render(block)
+	if err != nil {
+		return block
+	}
+	lines := strings.Split(rendered, "\n")
+	blank := func(line string) bool {
+		return strings.TrimSpace(ansi.Strip(line)) == ""
+	}
+	for len(lines) > 0 && blank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && blank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitBlocks returns the complete blocks of the markdown, followed by a blank
+// line out of code blocks, and the end of the last one.
+func splitBlocks(content string) ([]string, int) {
+	var blocks []string
+	start, fence := 0, ""
+	for pos := 0; pos < len(content); {
+		n := strings.IndexByte(content[pos:], '\n')
+		if n < 0 {
+			// The last line may not be complete.
+			break
+		}
+		line := content[pos : pos+n]
+		if fence == "" && strings.TrimSpace(line) == "" {
+			if block := strings.Trim(content[start:pos], "\n"); block != "" {
+				blocks = append(blocks, block)
+			}
+			start = pos + n + 1
+		}
+		fence = nextFence(line, fence)
+		pos += n + 1
+	}
+	return blocks, start
+}
+
+// nextFence returns the fence of the code block open after the line, given
+// the one open before it.
+func nextFence(line, open string) string {
+	trimmed := strings.TrimSpace(line)
+	if open != "" {
+		if strings.HasPrefix(trimmed, open) && strings.Trim(trimmed, open[:1]) == "" {
+			return ""
+		}
+		return open
+	}
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(trimmed, marker) {
+			return trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, marker[:1]))]
+		}
+	}
+	return ""
+}
+
+// openFence returns the fence of the code block still open at the end of the
+// markdown, if any.
+func openFence(content string) string {
+	fence := ""
+	for line := range strings.SplitSeq(content, "\n") {
+		fence = nextFence(line, fence)
+	}
+	return fence
+}
+
+// codeBlocks returns the code of the fenced code blocks of the markdown.
+func codeBlocks(content string) []string {
+	var blocks []string
+	var code []string
+	fence := ""
+	for line := range strings.SplitSeq(content, "\n") {
+		next := nextFence(line, fence)
+		switch {
+		case fence == "" && next != "":
+			code = nil
+		case fence != "" && next == "":
+			blocks = append(blocks, strings.Join(code, "\n"))
+		case fence != "":
+			code = append(code, line)
+		}
+		fence = next
+	}
+	if fence != "" {
+		blocks = append(blocks, strings.Join(code, "\n"))
+	}
+	return blocks
+}
@@ -0,0 +1,55 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/stretchr/testify/require"
+)
+
+const markdownMessage = "# Plan\n\nFirst *read* the file.\n\n```go\nfunc main() {\n\n\tfmt.Println(\"hi\")\n}\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |\n\n~~~\nmake test\n~~~\n"
+
+func TestSplitBlocks(t *testing.T) {
+	blocks, end := splitBlocks(markdownMessage)
+	require.Equal(t, []string{
+		"# Plan",
+		"First *read* the file.",
+		"```go\nfunc main() {\n\n\tfmt.Println(\"hi\")\n}\n```",
+		"| a | b |\n|---|---|\n| 1 | 2 |",
+	}, blocks)
+	require.Equal(t, "~~~\nmake test\n~~~\n", markdownMessage[end:])
+
+	blocks, end = splitBlocks("Some text\n\n```go\nfunc main() {\n\n")
+	require.Equal(t, []string{"Some text"}, blocks)
+	require.Equal(t, "```go\nfunc main() {\n\n", "Some text\n\n```go\nfunc main() {\n\n"[end:])
+}
+
+func TestCodeBlocks(t *testing.T) {
+	require.Equal(t, []string{
+		"func main() {\n\n\tfmt.Println(\"hi\")\n}",
+		"make test",
+	}, codeBlocks(markdownMessage))
+	require.Equal(t, []string{"go test"}, codeBlocks("Run:\n```sh\ngo test"))
+	require.Empty(t, codeBlocks("No code"))
+}
+
+func TestMarkdownRendererStreaming(t *testing.T) {
+	var r markdownRenderer
+	// The message streams a few characters at a time.
+	for i := 0; i < len(markdownMessage); i += 7 {
+		r.render(markdownMessage[:i], 60, true)
+	}
+	streamed := ansi.Strip(r.render(markdownMessage, 60, true))
+	for _, text := range []string{"Plan", "First read the file.", "fmt.Println(\"hi\")", "make test"} {
+		require.Contains(t, streamed, text)
+	}
+	require.Len(t, r.blocks, 4, "the complete blocks are kept")
+
+	// Open code blocks are closed while streaming.
+	var open markdownRenderer
+	require.NotContains(t, ansi.Strip(open.render("```go\nx := 1\n", 60, true)), "```")
+
+	finished := r.render(markdownMessage, 60, false)
+	require.Contains(t, ansi.Strip(finished), "make test")
+	require.Equal(t, finished, r.render(markdownMessage, 60, false))
+}
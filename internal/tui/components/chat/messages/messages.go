@@ -25,7 +25,11 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/util"
 )
 
-var copyKey = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y", "copy"))
+var (
+	copyKey     = key.NewBinding(key.WithKeys("c", "y", "C", "Y"), key.WithHelp("c/y", "copy"))
+	copyCodeKey = key.NewBinding(key.WithKeys("x", "X"), key.WithHelp("x", "copy code"))
+	thinkingKey = key.NewBinding(key.WithKeys("t", "T"), key.WithHelp("t", "toggle thinking"))
+)
 
 // MessageCmp defines the interface for message components in the chat interface.
 // It combines standard UI model interfaces with message-specific functionality.
@@ -37,6 +41,8 @@ type MessageCmp interface {
 	SetMessage(msg message.Message) // Update the message content
 	Spinning() bool                 // Animation state for loading messages
 	ID() string
+	ToggleThinking()                   // Expand or collapse the thinking
+	SetThinkingDisplay(display string) // Auto, expanded, or collapsed thinking
 }
 
 // messageCmp implements the MessageCmp interface for displaying chat messages.
@@ -53,6 +59,14 @@ type messageCmp struct {
 
 	// Thinking viewport for displaying reasoning content
 	thinkingViewport viewport.Model
+	// thinkingDisplay is how the thinking is shown, one of the displays of
+	// the config.
+	thinkingDisplay string
+
+	markdown markdownRenderer
+	// copiedBlock is the number of code blocks copied, each copy takes the
+	// next one.
+	copiedBlock int
 }
 
 var focusedMessageBorder = lipgloss.Border{
@@ -76,6 +90,7 @@ func NewMessageCmp(msg message.Message) MessageCmp {
 			CycleColors: true,
 		}),
 		thinkingViewport: thinkingViewport,
+		thinkingDisplay:  config.Get().Options.TUI.Thinking,
 	}
 	return m
 }
@@ -106,6 +121,13 @@ func (m *messageCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, util.ReportInfo("Message copied to clipboard")
 		}
+		if key.Matches(msg, copyCodeKey) {
+			return m, m.copyCodeBlock()
+		}
+		if key.Matches(msg, thinkingKey) && m.message.ReasoningContent().Thinking != "" {
+			m.ToggleThinking()
+			return m, nil
+		}
 	}
 	return m, nil
 }
@@ -153,7 +175,10 @@ func (msg *messageCmp) style() lipgloss.Style {
 	}
 
 	style := t.S().Text
-	if msg.message.Role == message.User {
+	if msg.message.IsSynthetic() {
+		// The feedback of crush to the model, not typed by the user.
+		style = t.S().Muted.PaddingLeft(1).BorderLeft(true).BorderStyle(borderStyle).BorderForeground(t.FgMuted)
+	} else if msg.message.Role == message.User {
 		style = style.PaddingLeft(1).BorderLeft(true).BorderStyle(borderStyle).BorderForeground(t.Primary)
 	} else {
 		if msg.focused {
@@ -191,6 +216,9 @@ func (m *messageCmp) renderAssistantMessage() string {
 		errorContent := fmt.Sprintf("%s\n\n%s", title, details)
 		return m.style().Render(errorContent)
 	}
+	if finished && finishedData.Reason == message.FinishReasonInterrupted {
+		content = strings.TrimSpace(content + "\n\n*Interrupted*")
+	}
 
 	if thinkingContent != "" {
 		parts = append(parts, thinkingContent)
@@ -200,19 +228,39 @@ func (m *messageCmp) renderAssistantMessage() string {
 		if thinkingContent != "" {
 			parts = append(parts, "")
 		}
-		parts = append(parts, m.toMarkdown(content))
+		parts = append(parts, m.markdown.render(content, m.textWidth(), !finished))
+	}
+
+	if citations := m.renderCitations(); citations != "" {
+		parts = append(parts, "", citations)
 	}
 
 	joined := lipgloss.JoinVertical(lipgloss.Left, parts...)
 	return m.style().Render(joined)
 }
 
+// renderCitations renders the numbered list of sources that were retrieved
+// while producing the message.
+func (m *messageCmp) renderCitations() string {
+	citations := m.message.Citations()
+	if len(citations) == 0 {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	lines := []string{t.S().Subtle.Render("Sources")}
+	for i, c := range citations {
+		line := fmt.Sprintf("%d. %s", i+1, c.String())
+		lines = append(lines, t.S().Muted.Render(ansi.Truncate(line, m.textWidth()-2, "…")))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
 // renderUserMessage renders user messages with file attachments. It displays
 // message content and any attached files with appropriate icons.
 func (m *messageCmp) renderUserMessage() string {
 	t := styles.CurrentTheme()
 	parts := []string{
-		m.toMarkdown(m.message.Content().String()),
+		m.markdown.render(m.message.Content().String(), m.textWidth(), false),
 	}
 
 	attachmentStyles := t.S().Text.
@@ -245,12 +293,34 @@ func (m *messageCmp) toMarkdown(content string) string {
 	return strings.TrimSuffix(rendered, "\n")
 }
 
+// copyCodeBlock copies the next code block of the message, from the first
+// one again after the last.
+func (m *messageCmp) copyCodeBlock() tea.Cmd {
+	blocks := codeBlocks(m.message.Content().Text)
+	if len(blocks) == 0 {
+		return util.ReportWarn("No code block in the message")
+	}
+	i := m.copiedBlock % len(blocks)
+	if err := clipboard.WriteAll(blocks[i]); err != nil {
+		return util.ReportError(fmt.Errorf("failed to copy code block to clipboard: %w", err))
+	}
+	m.copiedBlock++
+	return util.ReportInfo(fmt.Sprintf("Code block %d of %d copied to clipboard", i+1, len(blocks)))
+}
+
 func (m *messageCmp) renderThinkingContent() string {
 	t := styles.CurrentTheme()
 	reasoningContent := m.message.ReasoningContent()
 	if reasoningContent.Thinking == "" {
 		return ""
 	}
+	footer := m.thinkingFooter()
+	if !m.thinkingExpanded() {
+		if footer == "" {
+			footer = t.S().Base.PaddingLeft(1).Render(core.Status(core.StatusOpts{Title: "Thought", NoIcon: true}, m.textWidth()-1))
+		}
+		return footer
+	}
 	lines := strings.Split(reasoningContent.Thinking, "\n")
 	var content strings.Builder
 	lineStyle := t.S().Subtle.Background(t.BgBaseLighter)
@@ -264,30 +334,75 @@ func (m *messageCmp) renderThinkingContent() string {
 		}
 	}
 	fullContent := content.String()
-	height := util.Clamp(lipgloss.Height(fullContent), 1, 10)
-	m.thinkingViewport.SetHeight(height)
-	m.thinkingViewport.SetWidth(m.textWidth())
-	m.thinkingViewport.SetContent(fullContent)
-	m.thinkingViewport.GotoBottom()
+	if reasoningContent.FinishedAt > 0 {
+		// The whole thinking is shown once it's done.
+		fullContent = lineStyle.Width(m.textWidth()).Padding(0, 1).Render(fullContent)
+	} else {
+		height := util.Clamp(lipgloss.Height(fullContent), 1, 10)
+		m.thinkingViewport.SetHeight(height)
+		m.thinkingViewport.SetWidth(m.textWidth())
+		m.thinkingViewport.SetContent(fullContent)
+		m.thinkingViewport.GotoBottom()
+		fullContent = lineStyle.Width(m.textWidth()).Padding(0, 1).Render(m.thinkingViewport.View())
+	}
+	if footer == "" {
+		return fullContent
+	}
+	return fullContent + "\n\n" + footer
+}
+
+// thinkingFooter returns the line under the thinking: how long the model
+// thought, or the animation while it thinks.
+func (m *messageCmp) thinkingFooter() string {
+	t := styles.CurrentTheme()
+	reasoningContent := m.message.ReasoningContent()
+	if reasoningContent.StartedAt == 0 {
+		return ""
+	}
 	finishReason := m.message.FinishPart()
-	var footer string
-	if reasoningContent.StartedAt > 0 {
-		duration := m.message.ThinkingDuration()
-		if reasoningContent.FinishedAt > 0 {
-			m.anim.SetLabel("")
-			opts := core.StatusOpts{
-				Title:       "Thought for",
-				Description: duration.String(),
-				NoIcon:      true,
-			}
-			return t.S().Base.PaddingLeft(1).Render(core.Status(opts, m.textWidth()-1))
-		} else if finishReason != nil && finishReason.Reason == message.FinishReasonCanceled {
-			footer = t.S().Base.PaddingLeft(1).Render(m.toMarkdown("*Canceled*"))
-		} else {
-			footer = m.anim.View()
+	switch {
+	case reasoningContent.FinishedAt > 0:
+		m.anim.SetLabel("")
+		opts := core.StatusOpts{
+			Title:       "Thought for",
+			Description: m.message.ThinkingDuration().String(),
+			NoIcon:      true,
 		}
+		return t.S().Base.PaddingLeft(1).Render(core.Status(opts, m.textWidth()-1))
+	case finishReason != nil && finishReason.Reason == message.FinishReasonCanceled:
+		return t.S().Base.PaddingLeft(1).Render(m.toMarkdown("*Canceled*"))
+	default:
+		return m.anim.View()
 	}
-	return lineStyle.Width(m.textWidth()).Padding(0, 1).Render(m.thinkingViewport.View()) + "\n\n" + footer
+}
+
+// thinkingExpanded reports whether the thinking is shown rather than
+// collapsed to its footer. With the auto display it's shown while the model
+// thinks.
+func (m *messageCmp) thinkingExpanded() bool {
+	switch m.thinkingDisplay {
+	case config.ThinkingExpanded:
+		return true
+	case config.ThinkingCollapsed:
+		return false
+	default:
+		return m.message.ReasoningContent().FinishedAt == 0
+	}
+}
+
+// ToggleThinking expands the thinking of the message when it's collapsed,
+// collapses it otherwise.
+func (m *messageCmp) ToggleThinking() {
+	if m.thinkingExpanded() {
+		m.thinkingDisplay = config.ThinkingCollapsed
+	} else {
+		m.thinkingDisplay = config.ThinkingExpanded
+	}
+}
+
+// SetThinkingDisplay sets how the thinking of the message is shown.
+func (m *messageCmp) SetThinkingDisplay(display string) {
+	m.thinkingDisplay = display
 }
 
 // shouldSpin determines whether the message should show a loading animation.
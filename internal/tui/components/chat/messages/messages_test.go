@@ -0,0 +1,29 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThinkingExpanded(t *testing.T) {
+	m := &messageCmp{message: message.Message{Role: message.Assistant, Parts: []message.ContentPart{
+		message.ReasoningContent{Thinking: "Reading the tests.", StartedAt: 1},
+	}}}
+	require.True(t, m.thinkingExpanded(), "the thinking is shown while the model thinks")
+
+	m.message.Parts[0] = message.ReasoningContent{Thinking: "Reading the tests.", StartedAt: 1, FinishedAt: 3}
+	require.False(t, m.thinkingExpanded(), "the thinking is collapsed once done")
+
+	m.ToggleThinking()
+	require.True(t, m.thinkingExpanded())
+	m.ToggleThinking()
+	require.False(t, m.thinkingExpanded())
+
+	m.SetThinkingDisplay(config.ThinkingExpanded)
+	require.True(t, m.thinkingExpanded())
+	m.SetThinkingDisplay(config.ThinkingAuto)
+	require.False(t, m.thinkingExpanded())
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/crush/internal/ansiext"
+	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/llm/tools"
@@ -167,13 +168,31 @@ func init() {
 	registry.register(tools.ViewToolName, func() renderer { return viewRenderer{} })
 	registry.register(tools.EditToolName, func() renderer { return editRenderer{} })
 	registry.register(tools.MultiEditToolName, func() renderer { return multiEditRenderer{} })
+	registry.register(tools.ApplyPatchToolName, func() renderer { return applyPatchRenderer{} })
+	registry.register(tools.TransactionToolName, func() renderer { return transactionRenderer{} })
+	registry.register(tools.RenameToolName, func() renderer { return renameRenderer{} })
+	registry.register(tools.CodeActionToolName, func() renderer { return codeActionRenderer{} })
 	registry.register(tools.WriteToolName, func() renderer { return writeRenderer{} })
 	registry.register(tools.FetchToolName, func() renderer { return fetchRenderer{} })
 	registry.register(tools.GlobToolName, func() renderer { return globRenderer{} })
 	registry.register(tools.GrepToolName, func() renderer { return grepRenderer{} })
 	registry.register(tools.LSToolName, func() renderer { return lsRenderer{} })
 	registry.register(tools.SourcegraphToolName, func() renderer { return sourcegraphRenderer{} })
+	registry.register(tools.WebSearchToolName, func() renderer { return webSearchRenderer{} })
+	registry.register(tools.CodeSearchToolName, func() renderer { return codeSearchRenderer{} })
+	registry.register(tools.SQLToolName, func() renderer { return sqlRenderer{} })
+	registry.register(tools.DockerToolName, func() renderer { return dockerRenderer{} })
+	registry.register(tools.JobsToolName, func() renderer { return jobsRenderer{} })
+	registry.register(tools.TerminalToolName, func() renderer { return terminalRenderer{} })
+	registry.register(tools.GitToolName, func() renderer { return gitRenderer{} })
+	registry.register(tools.ForgeToolName, func() renderer { return forgeRenderer{} })
+	registry.register(tools.TestToolName, func() renderer { return testRenderer{} })
+	registry.register(tools.BrowserToolName, func() renderer { return browserRenderer{} })
 	registry.register(tools.DiagnosticsToolName, func() renderer { return diagnosticsRenderer{} })
+	registry.register(tools.OutlineToolName, func() renderer { return outlineRenderer{} })
+	registry.register(tools.SymbolsToolName, func() renderer { return symbolsRenderer{} })
+	registry.register(tools.DefinitionToolName, func() renderer { return definitionRenderer{} })
+	registry.register(tools.ReferencesToolName, func() renderer { return referencesRenderer{} })
 	registry.register(agent.AgentToolName, func() renderer { return agentRenderer{} })
 }
 
@@ -211,7 +230,7 @@ func (br bashRenderer) Render(v *toolCallCmp) string {
 
 	cmd := strings.ReplaceAll(params.Command, "\n", " ")
 	cmd = strings.ReplaceAll(cmd, "\t", "    ")
-	args := newParamBuilder().addMain(cmd).build()
+	args := newParamBuilder().addMain(cmd).addFlag("background", params.Background).build()
 
 	return br.renderWithParams(v, "Bash", args, func() string {
 		var meta tools.BashResponseMetadata
@@ -251,6 +270,7 @@ func (vr viewRenderer) Render(v *toolCallCmp) string {
 		addMain(file).
 		addKeyValue("limit", formatNonZero(params.Limit)).
 		addKeyValue("offset", formatNonZero(params.Offset)).
+		addKeyValue("symbol", params.Symbol).
 		build()
 
 	return vr.renderWithParams(v, "View", args, func() string {
@@ -258,7 +278,11 @@ func (vr viewRenderer) Render(v *toolCallCmp) string {
 		if err := vr.unmarshalParams(v.result.Metadata, &meta); err != nil {
 			return renderPlainContent(v, v.result.Content)
 		}
-		return renderCodeContent(v, meta.FilePath, meta.Content, params.Offset)
+		offset := params.Offset
+		if params.Symbol != "" {
+			offset = meta.Offset
+		}
+		return renderCodeContent(v, meta.FilePath, meta.Content, offset)
 	})
 }
 
@@ -368,6 +392,162 @@ func (mer multiEditRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Apply patch renderer
+// -----------------------------------------------------------------------------
+
+// applyPatchRenderer handles patches with the diff of each changed file
+type applyPatchRenderer struct {
+	baseRenderer
+}
+
+// Render displays the changed files and their diffs
+func (apr applyPatchRenderer) Render(v *toolCallCmp) string {
+	var params tools.ApplyPatchParams
+	var args []string
+	if err := apr.unmarshalParams(v.call.Input, &params); err == nil {
+		if files, err := diff.ParsePatch(params.Patch); err == nil {
+			main := fsext.PrettyPath(files[0].Path())
+			if len(files) > 1 {
+				main = fmt.Sprintf("%s and %d more", main, len(files)-1)
+			}
+			args = newParamBuilder().
+				addMain(main).
+				addKeyValue("files", formatNonZero(len(files))).
+				build()
+		}
+	}
+
+	return apr.renderWithParams(v, "Apply Patch", args, func() string {
+		return renderChangedFiles(v)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Transaction renderer
+// -----------------------------------------------------------------------------
+
+// transactionRenderer handles edits of several files with the diff of each
+type transactionRenderer struct {
+	baseRenderer
+}
+
+// Render displays the edited files and their diffs
+func (tr transactionRenderer) Render(v *toolCallCmp) string {
+	var params tools.TransactionParams
+	var args []string
+	if err := tr.unmarshalParams(v.call.Input, &params); err == nil && len(params.Files) > 0 {
+		main := fsext.PrettyPath(params.Files[0].FilePath)
+		if len(params.Files) > 1 {
+			main = fmt.Sprintf("%s and %d more", main, len(params.Files)-1)
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("files", formatNonZero(len(params.Files))).
+			build()
+	}
+
+	return tr.renderWithParams(v, "Transaction", args, func() string {
+		return renderChangedFiles(v)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Rename renderer
+// -----------------------------------------------------------------------------
+
+// renameRenderer handles symbol renames with the diff of each changed file
+type renameRenderer struct {
+	baseRenderer
+}
+
+// Render displays the old and new names with the diffs
+func (rr renameRenderer) Render(v *toolCallCmp) string {
+	var params tools.RenameParams
+	var args []string
+	if err := rr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(fmt.Sprintf("%s → %s", params.Symbol, params.NewName)).
+			addKeyValue("file", fsext.PrettyPath(params.FilePath)).
+			build()
+	}
+
+	return rr.renderWithParams(v, "Rename", args, func() string {
+		return renderChangedFiles(v)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Code action renderer
+// -----------------------------------------------------------------------------
+
+// codeActionRenderer handles code actions, listed or applied
+type codeActionRenderer struct {
+	baseRenderer
+}
+
+// Render displays the file with the applied action and its diffs
+func (cr codeActionRenderer) Render(v *toolCallCmp) string {
+	var params tools.CodeActionParams
+	var args []string
+	if err := cr.unmarshalParams(v.call.Input, &params); err == nil {
+		action := params.Title
+		if params.OrganizeImports {
+			action = "organize imports"
+		}
+		line := ""
+		if params.Line > 0 {
+			line = fmt.Sprintf("%d", params.Line)
+		}
+		args = newParamBuilder().
+			addMain(fsext.PrettyPath(params.FilePath)).
+			addKeyValue("line", line).
+			addKeyValue("action", action).
+			build()
+	}
+
+	return cr.renderWithParams(v, "Code Action", args, func() string {
+		return renderChangedFiles(v)
+	})
+}
+
+// renderChangedFiles stacks the diffs of the files of a change set result
+func renderChangedFiles(v *toolCallCmp) string {
+	t := styles.CurrentTheme()
+	var meta tools.ChangeSetResponseMetadata
+	if err := json.Unmarshal([]byte(v.result.Metadata), &meta); err != nil || len(meta.Files) == 0 {
+		return renderPlainContent(v, v.result.Content)
+	}
+
+	var diffs []string
+	for _, f := range meta.Files {
+		before := f.FilePath
+		if f.OldPath != "" {
+			before = f.OldPath
+		}
+		formatter := core.DiffFormatter().
+			Before(fsext.PrettyPath(before), f.OldContent).
+			After(fsext.PrettyPath(f.FilePath), f.NewContent).
+			Width(v.textWidth() - 2) // -2 for padding
+		if v.textWidth() > 120 {
+			formatter = formatter.Split()
+		}
+		diffs = append(diffs, formatter.String())
+	}
+	// add a message to the bottom if the content was truncated
+	formatted := strings.Join(diffs, "\n")
+	if lipgloss.Height(formatted) > responseContextHeight {
+		contentLines := strings.Split(formatted, "\n")
+		truncateMessage := t.S().Muted.
+			Background(t.BgBaseLighter).
+			PaddingLeft(2).
+			Width(v.textWidth() - 4).
+			Render(fmt.Sprintf("… (%d lines)", len(contentLines)-responseContextHeight))
+		formatted = strings.Join(contentLines[:responseContextHeight], "\n") + "\n" + truncateMessage
+	}
+	return formatted
+}
+
 // -----------------------------------------------------------------------------
 //  Write renderer
 // -----------------------------------------------------------------------------
@@ -571,6 +751,319 @@ func (sr sourcegraphRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Web search renderer
+// -----------------------------------------------------------------------------
+
+// webSearchRenderer handles web searches with the count of results
+type webSearchRenderer struct {
+	baseRenderer
+}
+
+// Render displays the search query with the optional count of results
+func (wr webSearchRenderer) Render(v *toolCallCmp) string {
+	var params tools.WebSearchParams
+	var args []string
+	if err := wr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(params.Query).
+			addKeyValue("count", formatNonZero(params.Count)).
+			build()
+	}
+
+	return wr.renderWithParams(v, "Web Search", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Code search renderer
+// -----------------------------------------------------------------------------
+
+// codeSearchRenderer handles code searches with the limit of results
+type codeSearchRenderer struct {
+	baseRenderer
+}
+
+// Render displays the search query with the optional limit of results
+func (cr codeSearchRenderer) Render(v *toolCallCmp) string {
+	var params tools.CodeSearchParams
+	var args []string
+	if err := cr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(params.Query).
+			addKeyValue("limit", formatNonZero(params.Limit)).
+			build()
+	}
+
+	return cr.renderWithParams(v, "Code Search", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  SQL renderer
+// -----------------------------------------------------------------------------
+
+// sqlRenderer handles database queries and schemas
+type sqlRenderer struct {
+	baseRenderer
+}
+
+// Render displays the query, or the described table, with the database
+func (sr sqlRenderer) Render(v *toolCallCmp) string {
+	var params tools.SQLParams
+	var args []string
+	if err := sr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Query
+		if params.Action != "query" {
+			main = strings.TrimSpace("schema " + params.Table)
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("database", params.Database).
+			addFlag("write", params.Write).
+			build()
+	}
+
+	return sr.renderWithParams(v, "SQL", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Docker renderer
+// -----------------------------------------------------------------------------
+
+// dockerRenderer handles container listings, logs, and compose commands
+type dockerRenderer struct {
+	baseRenderer
+}
+
+// Render displays the docker action, or the command, with its target
+func (dr dockerRenderer) Render(v *toolCallCmp) string {
+	var params tools.DockerParams
+	var args []string
+	if err := dr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Action
+		if params.Action == "exec" {
+			main = params.Command
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("container", params.Container).
+			addKeyValue("service", params.Service).
+			addKeyValue("services", strings.Join(params.Services, " ")).
+			addKeyValue("since", params.Since).
+			addFlag("all", params.All).
+			addFlag("build", params.Build).
+			build()
+	}
+
+	return dr.renderWithParams(v, "Docker", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Jobs renderer
+// -----------------------------------------------------------------------------
+
+// jobsRenderer handles the background commands of the bash tool
+type jobsRenderer struct {
+	baseRenderer
+}
+
+// Render displays the jobs action with the job and the wait
+func (jr jobsRenderer) Render(v *toolCallCmp) string {
+	var params tools.JobsParams
+	var args []string
+	if err := jr.unmarshalParams(v.call.Input, &params); err == nil {
+		wait := ""
+		if params.Wait > 0 {
+			wait = fmt.Sprintf("%ds", params.Wait)
+		}
+		args = newParamBuilder().
+			addMain(params.Action).
+			addKeyValue("id", params.ID).
+			addKeyValue("wait", wait).
+			build()
+	}
+
+	return jr.renderWithParams(v, "Jobs", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Terminal renderer
+// -----------------------------------------------------------------------------
+
+// terminalRenderer handles the inputs sent to the interactive shell
+type terminalRenderer struct {
+	baseRenderer
+}
+
+// Render displays the terminal action with its input
+func (tr terminalRenderer) Render(v *toolCallCmp) string {
+	var params tools.TerminalParams
+	var args []string
+	if err := tr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Action
+		if params.Input != "" {
+			main = fmt.Sprintf("%q", params.Input)
+			if params.Action == "run" {
+				main = strings.ReplaceAll(params.Input, "\n", " ")
+			}
+		}
+		args = newParamBuilder().addMain(main).build()
+	}
+
+	return tr.renderWithParams(v, "Terminal", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Git renderer
+// -----------------------------------------------------------------------------
+
+// gitRenderer handles git operations with their paths, branch, or message
+type gitRenderer struct {
+	baseRenderer
+}
+
+// Render displays the git operation with its main argument
+func (gr gitRenderer) Render(v *toolCallCmp) string {
+	var params tools.GitParams
+	var args []string
+	if err := gr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Action
+		switch params.Action {
+		case "commit":
+			main, _, _ = strings.Cut(params.Message, "\n")
+		case "branch":
+			main = strings.TrimSpace("branch " + params.Branch)
+		case "stash":
+			main = strings.TrimSpace("stash " + params.Stash)
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("paths", strings.Join(params.Paths, " ")).
+			addKeyValue("ref", params.Ref).
+			addFlag("staged", params.Staged).
+			addFlag("all", params.All).
+			addFlag("create", params.Create).
+			build()
+	}
+
+	return gr.renderWithParams(v, "Git", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Forge renderer
+// -----------------------------------------------------------------------------
+
+// forgeRenderer handles issues, branches, and pull requests of the forge
+type forgeRenderer struct {
+	baseRenderer
+}
+
+// Render displays the forge action with its issue, branch, or title
+func (fr forgeRenderer) Render(v *toolCallCmp) string {
+	var params tools.ForgeParams
+	var args []string
+	if err := fr.unmarshalParams(v.call.Input, &params); err == nil {
+		main, issue := params.Action, params.Issue
+		switch params.Action {
+		case "issue":
+			main, issue = fmt.Sprintf("issue #%d", params.Issue), 0
+		case "issues":
+			main = strings.TrimSpace("issues " + params.Search)
+		case "pull_request":
+			main = params.Title
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("issue", formatNonZero(issue)).
+			addKeyValue("branch", params.Branch).
+			addKeyValue("state", params.State).
+			addKeyValue("base", params.Base).
+			addFlag("draft", params.Draft).
+			build()
+	}
+
+	return fr.renderWithParams(v, "Forge", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Test renderer
+// -----------------------------------------------------------------------------
+
+// testRenderer handles test runs with their failures
+type testRenderer struct {
+	baseRenderer
+}
+
+// Render displays the tests that ran and the failures
+func (tr testRenderer) Render(v *toolCallCmp) string {
+	var params tools.TestParams
+	var args []string
+	if err := tr.unmarshalParams(v.call.Input, &params); err == nil {
+		main := params.Path
+		if main == "" {
+			main = "all tests"
+		}
+		args = newParamBuilder().
+			addMain(main).
+			addKeyValue("run", params.Run).
+			build()
+	}
+
+	return tr.renderWithParams(v, "Test", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// -----------------------------------------------------------------------------
+//  Browser renderer
+// -----------------------------------------------------------------------------
+
+// browserRenderer handles browser actions with their target
+type browserRenderer struct {
+	baseRenderer
+}
+
+// Render displays the browser action with its URL, element, text, or key
+func (br browserRenderer) Render(v *toolCallCmp) string {
+	var params tools.BrowserParams
+	var args []string
+	if err := br.unmarshalParams(v.call.Input, &params); err == nil {
+		builder := newParamBuilder().addMain(params.Action)
+		switch params.Action {
+		case tools.BrowserActionNavigate:
+			builder.addKeyValue("url", params.URL)
+		case tools.BrowserActionClick:
+			builder.addKeyValue("ref", formatNonZero(int(params.Ref)))
+		case tools.BrowserActionType:
+			builder.addKeyValue("ref", formatNonZero(int(params.Ref))).addKeyValue("text", params.Text)
+		case tools.BrowserActionPress:
+			builder.addKeyValue("key", params.Key)
+		case tools.BrowserActionText:
+			builder.addKeyValue("selector", params.Selector)
+		}
+		args = builder.build()
+	}
+
+	return br.renderWithParams(v, "Browser", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Diagnostics renderer
 // -----------------------------------------------------------------------------
@@ -589,6 +1082,89 @@ func (dr diagnosticsRenderer) Render(v *toolCallCmp) string {
 	})
 }
 
+// -----------------------------------------------------------------------------
+//  Navigation renderers
+// -----------------------------------------------------------------------------
+
+// outlineRenderer handles the symbol outlines of files
+type outlineRenderer struct {
+	baseRenderer
+}
+
+// Render displays the outlined file and the symbols
+func (ol outlineRenderer) Render(v *toolCallCmp) string {
+	var params tools.OutlineParams
+	var args []string
+	if err := ol.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().addMain(fsext.PrettyPath(params.FilePath)).build()
+	}
+
+	return ol.renderWithParams(v, "Outline", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// symbolsRenderer handles the workspace symbol searches
+type symbolsRenderer struct {
+	baseRenderer
+}
+
+// Render displays the query and the matching symbols
+func (sr symbolsRenderer) Render(v *toolCallCmp) string {
+	var params tools.SymbolsParams
+	var args []string
+	if err := sr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().addMain(params.Query).build()
+	}
+
+	return sr.renderWithParams(v, "Symbols", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// definitionRenderer handles the go to definition lookups
+type definitionRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol and its definitions
+func (dr definitionRenderer) Render(v *toolCallCmp) string {
+	var params tools.DefinitionParams
+	var args []string
+	if err := dr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(params.Symbol).
+			addKeyValue("file", fmt.Sprintf("%s:%d", fsext.PrettyPath(params.FilePath), params.Line)).
+			build()
+	}
+
+	return dr.renderWithParams(v, "Definition", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
+// referencesRenderer handles the find references lookups
+type referencesRenderer struct {
+	baseRenderer
+}
+
+// Render displays the symbol and its references
+func (rr referencesRenderer) Render(v *toolCallCmp) string {
+	var params tools.ReferencesParams
+	var args []string
+	if err := rr.unmarshalParams(v.call.Input, &params); err == nil {
+		args = newParamBuilder().
+			addMain(params.Symbol).
+			addKeyValue("file", fmt.Sprintf("%s:%d", fsext.PrettyPath(params.FilePath), params.Line)).
+			addFlag("declaration", params.IncludeDeclaration).
+			build()
+	}
+
+	return rr.renderWithParams(v, "References", args, func() string {
+		return renderPlainContent(v, v.result.Content)
+	})
+}
+
 // -----------------------------------------------------------------------------
 //  Task renderer
 // -----------------------------------------------------------------------------
@@ -611,27 +1187,37 @@ func (tr agentRenderer) Render(v *toolCallCmp) string {
 	var params agent.AgentParams
 	tr.unmarshalParams(v.call.Input, &params)
 
-	prompt := params.Prompt
-	prompt = strings.ReplaceAll(prompt, "\n", " ")
-
 	header := tr.makeHeader(v, "Agent", v.textWidth())
 	if res, done := earlyState(header, v); v.cancelled && done {
 		return res
 	}
-	taskTag := t.S().Base.Padding(0, 1).MarginLeft(1).Background(t.BlueLight).Foreground(t.White).Render("Task")
-	remainingWidth := v.textWidth() - lipgloss.Width(header) - lipgloss.Width(taskTag) - 2 // -2 for padding
-	prompt = t.S().Muted.Width(remainingWidth).Render(prompt)
-	header = lipgloss.JoinVertical(
-		lipgloss.Left,
-		header,
-		"",
-		lipgloss.JoinHorizontal(
+	tasks := params.AllTasks()
+	if len(tasks) == 0 {
+		// The input is still streaming.
+		tasks = []agent.AgentTask{{}}
+	}
+	lines := []string{header, ""}
+	for _, task := range tasks {
+		tag := "Task"
+		if task.Agent != "" && task.Agent != "task" {
+			tag = task.Agent
+		}
+		taskTag := t.S().Base.Padding(0, 1).MarginLeft(1).Background(t.BlueLight).Foreground(t.White).Render(tag)
+		remainingWidth := v.textWidth() - lipgloss.Width(header) - lipgloss.Width(taskTag) - 2 // -2 for padding
+		prompt := strings.ReplaceAll(task.Prompt, "\n", " ")
+		if len(tasks) > 1 {
+			// One line per task
+			prompt = ansi.Truncate(prompt, remainingWidth, "…")
+		}
+		prompt = t.S().Muted.Width(remainingWidth).Render(prompt)
+		lines = append(lines, lipgloss.JoinHorizontal(
 			lipgloss.Left,
 			taskTag,
 			" ",
 			prompt,
-		),
-	)
+		))
+	}
+	header = lipgloss.JoinVertical(lipgloss.Left, lines...)
 	childTools := tree.Root(header)
 
 	for _, call := range v.nestedToolCalls {
@@ -726,6 +1312,8 @@ func earlyState(header string, v *toolCallCmp) (string, bool) {
 	case v.result.ToolCallID == "":
 		if v.permissionRequested && !v.permissionGranted {
 			message = t.S().Base.Foreground(t.FgSubtle).Render("Requesting for permission...")
+		} else if v.progress != nil {
+			message = renderProgress(*v.progress, v.textWidth()-2) // -2 for padding
 		} else {
 			message = t.S().Base.Foreground(t.FgSubtle).Render("Waiting for tool response...")
 		}
@@ -737,6 +1325,22 @@ func earlyState(header string, v *toolCallCmp) (string, bool) {
 	return lipgloss.JoinVertical(lipgloss.Left, header, "", message), true
 }
 
+// renderProgress renders a progress bar if the total is known, followed by
+// the progress message
+func renderProgress(p tools.Progress, width int) string {
+	t := styles.CurrentTheme()
+	const barWidth = 20
+	var bar string
+	if percent := p.Percent(); percent >= 0 {
+		filled := int(percent / 100 * barWidth)
+		bar = t.S().Base.Foreground(t.Green).Render(strings.Repeat("█", filled)) +
+			t.S().Base.Foreground(t.FgSubtle).Render(strings.Repeat("░", barWidth-filled)) +
+			t.S().Base.Foreground(t.FgHalfMuted).Render(fmt.Sprintf(" %3.0f%% ", percent))
+	}
+	message := t.S().Base.Foreground(t.FgSubtle).Render(p.Message)
+	return ansi.Truncate(bar+message, width, "…")
+}
+
 func joinHeaderBody(header, body string) string {
 	t := styles.CurrentTheme()
 	if body == "" {
@@ -872,6 +1476,14 @@ func prettifyToolName(name string) string {
 		return "Edit"
 	case tools.MultiEditToolName:
 		return "Multi-Edit"
+	case tools.ApplyPatchToolName:
+		return "Apply Patch"
+	case tools.TransactionToolName:
+		return "Transaction"
+	case tools.RenameToolName:
+		return "Rename"
+	case tools.CodeActionToolName:
+		return "Code Action"
 	case tools.FetchToolName:
 		return "Fetch"
 	case tools.GlobToolName:
@@ -882,6 +1494,34 @@ func prettifyToolName(name string) string {
 		return "List"
 	case tools.SourcegraphToolName:
 		return "Sourcegraph"
+	case tools.WebSearchToolName:
+		return "Web Search"
+	case tools.CodeSearchToolName:
+		return "Code Search"
+	case tools.SQLToolName:
+		return "SQL"
+	case tools.DockerToolName:
+		return "Docker"
+	case tools.JobsToolName:
+		return "Jobs"
+	case tools.TerminalToolName:
+		return "Terminal"
+	case tools.GitToolName:
+		return "Git"
+	case tools.ForgeToolName:
+		return "Forge"
+	case tools.TestToolName:
+		return "Test"
+	case tools.BrowserToolName:
+		return "Browser"
+	case tools.OutlineToolName:
+		return "Outline"
+	case tools.SymbolsToolName:
+		return "Symbols"
+	case tools.DefinitionToolName:
+		return "Definition"
+	case tools.ReferencesToolName:
+		return "References"
 	case tools.ViewToolName:
 		return "View"
 	case tools.WriteToolName:
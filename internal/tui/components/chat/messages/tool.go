@@ -1,6 +1,7 @@
 package messages
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"path/filepath"
@@ -41,8 +42,9 @@ type ToolCallCmp interface {
 	SetNestedToolCalls([]ToolCallCmp)  // Set nested tool calls
 	SetIsNested(bool)                  // Set whether this tool call is nested
 	ID() string
-	SetPermissionRequested() // Mark permission request
-	SetPermissionGranted()   // Mark permission granted
+	SetPermissionRequested()    // Mark permission request
+	SetPermissionGranted()      // Mark permission granted
+	SetProgress(tools.Progress) // Update progress of the running tool
 }
 
 // toolCallCmp implements the ToolCallCmp interface for displaying tool calls.
@@ -59,6 +61,7 @@ type toolCallCmp struct {
 	cancelled           bool               // Whether the tool call was cancelled
 	permissionRequested bool
 	permissionGranted   bool
+	progress            *tools.Progress // Latest progress reported by the running tool
 
 	// Animation state for pending tool calls
 	spinning bool       // Whether to show loading animation
@@ -248,8 +251,32 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
 			cmd := strings.ReplaceAll(params.Command, "\n", " ")
 			cmd = strings.ReplaceAll(cmd, "\t", "    ")
+			if params.Background {
+				return fmt.Sprintf("**Command:** %s\n**Background:** true", cmd)
+			}
 			return fmt.Sprintf("**Command:** %s", cmd)
 		}
+	case tools.TerminalToolName:
+		var params tools.TerminalParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			parts := []string{fmt.Sprintf("**Action:** %s", params.Action)}
+			if params.Input != "" {
+				parts = append(parts, fmt.Sprintf("**Input:** %q", params.Input))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.JobsToolName:
+		var params tools.JobsParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			parts := []string{fmt.Sprintf("**Action:** %s", params.Action)}
+			if params.ID != "" {
+				parts = append(parts, fmt.Sprintf("**ID:** %s", params.ID))
+			}
+			if params.Wait > 0 {
+				parts = append(parts, fmt.Sprintf("**Wait:** %ds", params.Wait))
+			}
+			return strings.Join(parts, "\n")
+		}
 	case tools.ViewToolName:
 		var params tools.ViewParams
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
@@ -261,6 +288,9 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 			if params.Offset > 0 {
 				parts = append(parts, fmt.Sprintf("**Offset:** %d", params.Offset))
 			}
+			if params.Symbol != "" {
+				parts = append(parts, fmt.Sprintf("**Symbol:** %s", params.Symbol))
+			}
 			return strings.Join(parts, "\n")
 		}
 	case tools.EditToolName:
@@ -276,6 +306,48 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 			parts = append(parts, fmt.Sprintf("**Edits:** %d", len(params.Edits)))
 			return strings.Join(parts, "\n")
 		}
+	case tools.ApplyPatchToolName:
+		var params tools.ApplyPatchParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			return fmt.Sprintf("**Patch:**\n```diff\n%s\n```", strings.TrimSpace(params.Patch))
+		}
+	case tools.TransactionToolName:
+		var params tools.TransactionParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var files []string
+			edits := 0
+			for _, f := range params.Files {
+				files = append(files, fsext.PrettyPath(f.FilePath))
+				edits += len(f.Edits)
+			}
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Files:** %s", strings.Join(files, ", ")))
+			parts = append(parts, fmt.Sprintf("**Edits:** %d", edits))
+			return strings.Join(parts, "\n")
+		}
+	case tools.RenameToolName:
+		var params tools.RenameParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**File:** %s:%d", fsext.PrettyPath(params.FilePath), params.Line))
+			parts = append(parts, fmt.Sprintf("**Rename:** %s to %s", params.Symbol, params.NewName))
+			return strings.Join(parts, "\n")
+		}
+	case tools.CodeActionToolName:
+		var params tools.CodeActionParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			parts := []string{fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath))}
+			if params.Line > 0 {
+				parts = append(parts, fmt.Sprintf("**Lines:** %d-%d", params.Line, max(params.Line, params.EndLine)))
+			}
+			if params.Title != "" {
+				parts = append(parts, fmt.Sprintf("**Action:** %s", params.Title))
+			}
+			if params.OrganizeImports {
+				parts = append(parts, "**Action:** organize imports")
+			}
+			return strings.Join(parts, "\n")
+		}
 	case tools.WriteToolName:
 		var params tools.WriteParams
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
@@ -353,12 +425,176 @@ func (m *toolCallCmp) formatParametersForCopy() string {
 			}
 			return strings.Join(parts, "\n")
 		}
+	case tools.WebSearchToolName:
+		var params tools.WebSearchParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Query:** %s", params.Query))
+			if params.Count > 0 {
+				parts = append(parts, fmt.Sprintf("**Count:** %d", params.Count))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.CodeSearchToolName:
+		var params tools.CodeSearchParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Query:** %s", params.Query))
+			if params.Limit > 0 {
+				parts = append(parts, fmt.Sprintf("**Limit:** %d", params.Limit))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.SQLToolName:
+		var params tools.SQLParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Action:** %s", params.Action))
+			if params.Database != "" {
+				parts = append(parts, fmt.Sprintf("**Database:** %s", params.Database))
+			}
+			if params.Query != "" {
+				parts = append(parts, fmt.Sprintf("**Query:** %s", params.Query))
+			}
+			if params.Table != "" {
+				parts = append(parts, fmt.Sprintf("**Table:** %s", params.Table))
+			}
+			if params.Write {
+				parts = append(parts, "**Write:** true")
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.DockerToolName:
+		var params tools.DockerParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Action:** %s", params.Action))
+			if params.Container != "" {
+				parts = append(parts, fmt.Sprintf("**Container:** %s", params.Container))
+			}
+			if params.Service != "" {
+				parts = append(parts, fmt.Sprintf("**Service:** %s", params.Service))
+			}
+			if len(params.Services) > 0 {
+				parts = append(parts, fmt.Sprintf("**Services:** %s", strings.Join(params.Services, ", ")))
+			}
+			if params.Command != "" {
+				parts = append(parts, fmt.Sprintf("**Command:** %s", params.Command))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.GitToolName:
+		var params tools.GitParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Action:** %s", params.Action))
+			if len(params.Paths) > 0 {
+				parts = append(parts, fmt.Sprintf("**Paths:** %s", strings.Join(params.Paths, ", ")))
+			}
+			if params.Ref != "" {
+				parts = append(parts, fmt.Sprintf("**Ref:** %s", params.Ref))
+			}
+			if params.Branch != "" {
+				parts = append(parts, fmt.Sprintf("**Branch:** %s", params.Branch))
+			}
+			if params.Stash != "" {
+				parts = append(parts, fmt.Sprintf("**Stash:** %s", params.Stash))
+			}
+			if params.Message != "" {
+				parts = append(parts, fmt.Sprintf("**Message:**\n%s", params.Message))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.ForgeToolName:
+		var params tools.ForgeParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Action:** %s", params.Action))
+			if params.Issue > 0 {
+				parts = append(parts, fmt.Sprintf("**Issue:** #%d", params.Issue))
+			}
+			if params.Search != "" {
+				parts = append(parts, fmt.Sprintf("**Search:** %s", params.Search))
+			}
+			if params.Branch != "" {
+				parts = append(parts, fmt.Sprintf("**Branch:** %s", params.Branch))
+			}
+			if params.Title != "" {
+				parts = append(parts, fmt.Sprintf("**Title:** %s", params.Title))
+			}
+			if params.Body != "" {
+				parts = append(parts, fmt.Sprintf("**Body:**\n%s", params.Body))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.TestToolName:
+		var params tools.TestParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			path := params.Path
+			if path == "" {
+				path = "all tests"
+			}
+			parts := []string{fmt.Sprintf("**Path:** %s", path)}
+			if params.Run != "" {
+				parts = append(parts, fmt.Sprintf("**Run:** %s", params.Run))
+			}
+			return strings.Join(parts, "\n")
+		}
+	case tools.BrowserToolName:
+		var params tools.BrowserParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			var parts []string
+			parts = append(parts, fmt.Sprintf("**Action:** %s", params.Action))
+			if params.URL != "" {
+				parts = append(parts, fmt.Sprintf("**URL:** %s", params.URL))
+			}
+			if params.Ref != 0 {
+				parts = append(parts, fmt.Sprintf("**Ref:** %d", params.Ref))
+			}
+			if params.Text != "" {
+				parts = append(parts, fmt.Sprintf("**Text:** %s", params.Text))
+			}
+			if params.Key != "" {
+				parts = append(parts, fmt.Sprintf("**Key:** %s", params.Key))
+			}
+			if params.Selector != "" {
+				parts = append(parts, fmt.Sprintf("**Selector:** %s", params.Selector))
+			}
+			return strings.Join(parts, "\n")
+		}
 	case tools.DiagnosticsToolName:
 		return "**Project:** diagnostics"
+	case tools.OutlineToolName:
+		var params tools.OutlineParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			return fmt.Sprintf("**File:** %s", fsext.PrettyPath(params.FilePath))
+		}
+	case tools.SymbolsToolName:
+		var params tools.SymbolsParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			return fmt.Sprintf("**Query:** %s", params.Query)
+		}
+	case tools.DefinitionToolName, tools.ReferencesToolName:
+		var params tools.ReferencesParams
+		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
+			parts := []string{
+				fmt.Sprintf("**Symbol:** %s", params.Symbol),
+				fmt.Sprintf("**File:** %s:%d", fsext.PrettyPath(params.FilePath), params.Line),
+			}
+			if params.IncludeDeclaration {
+				parts = append(parts, "**Include Declaration:** true")
+			}
+			return strings.Join(parts, "\n")
+		}
 	case agent.AgentToolName:
 		var params agent.AgentParams
 		if json.Unmarshal([]byte(m.call.Input), &params) == nil {
-			return fmt.Sprintf("**Task:**\n%s", params.Prompt)
+			var parts []string
+			for _, task := range params.AllTasks() {
+				agentName := cmp.Or(task.Agent, "task")
+				parts = append(parts, fmt.Sprintf("**Task (%s):**\n%s", agentName, task.Prompt))
+			}
+			return strings.Join(parts, "\n\n")
 		}
 	}
 
@@ -388,13 +624,15 @@ func (m *toolCallCmp) formatResultForCopy() string {
 		return m.formatEditResultForCopy()
 	case tools.MultiEditToolName:
 		return m.formatMultiEditResultForCopy()
+	case tools.ApplyPatchToolName, tools.TransactionToolName, tools.RenameToolName, tools.CodeActionToolName:
+		return m.formatChangeSetResultForCopy()
 	case tools.WriteToolName:
 		return m.formatWriteResultForCopy()
 	case tools.FetchToolName:
 		return m.formatFetchResultForCopy()
 	case agent.AgentToolName:
 		return m.formatAgentResultForCopy()
-	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.DiagnosticsToolName:
+	case tools.DownloadToolName, tools.GrepToolName, tools.GlobToolName, tools.LSToolName, tools.SourcegraphToolName, tools.WebSearchToolName, tools.CodeSearchToolName, tools.SQLToolName, tools.DockerToolName, tools.JobsToolName, tools.TerminalToolName, tools.GitToolName, tools.ForgeToolName, tools.TestToolName, tools.BrowserToolName, tools.DiagnosticsToolName, tools.OutlineToolName, tools.SymbolsToolName, tools.DefinitionToolName, tools.ReferencesToolName:
 		return fmt.Sprintf("```\n%s\n```", m.result.Content)
 	default:
 		return m.result.Content
@@ -539,6 +777,23 @@ func (m *toolCallCmp) formatMultiEditResultForCopy() string {
 	return result.String()
 }
 
+func (m *toolCallCmp) formatChangeSetResultForCopy() string {
+	var meta tools.ChangeSetResponseMetadata
+	if m.result.Metadata == "" || json.Unmarshal([]byte(m.result.Metadata), &meta) != nil {
+		return m.result.Content
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Changes: +%d -%d\n", meta.Additions, meta.Removals))
+	result.WriteString("```diff\n")
+	for _, f := range meta.Files {
+		diffContent, _, _ := diff.GenerateDiff(f.OldContent, f.NewContent, fsext.PrettyPath(f.FilePath))
+		result.WriteString(diffContent)
+	}
+	result.WriteString("\n```")
+	return result.String()
+}
+
 func (m *toolCallCmp) formatWriteResultForCopy() string {
 	var params tools.WriteParams
 	if json.Unmarshal([]byte(m.call.Input), &params) != nil {
@@ -807,3 +1062,8 @@ func (m *toolCallCmp) SetPermissionRequested() {
 func (m *toolCallCmp) SetPermissionGranted() {
 	m.permissionGranted = true
 }
+
+// SetProgress updates the progress shown while the tool is running
+func (m *toolCallCmp) SetProgress(progress tools.Progress) {
+	m.progress = &progress
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/fsext"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/lsp/protocol"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -570,6 +571,8 @@ func (m *sidebarCmp) mcpBlockCompact(maxWidth int) string {
 		return lipgloss.NewStyle().Width(maxWidth).Render(content)
 	}
 
+	states := mcpStates()
+
 	// Limit items for horizontal layout
 	maxItems := min(5, len(mcps))
 	availableHeight := m.height - 8
@@ -582,21 +585,7 @@ func (m *sidebarCmp) mcpBlockCompact(maxWidth int) string {
 			break
 		}
 
-		iconColor := t.Success
-		if l.MCP.Disabled {
-			iconColor = t.FgMuted
-		}
-
-		mcpList = append(mcpList,
-			core.Status(
-				core.StatusOpts{
-					IconColor:   iconColor,
-					Title:       l.Name,
-					Description: l.MCP.Command,
-				},
-				maxWidth,
-			),
-		)
+		mcpList = append(mcpList, core.Status(mcpStatusOpts(l, states), maxWidth))
 	}
 
 	// Add "..." indicator if there are more MCPs
@@ -799,6 +788,8 @@ func (m *sidebarCmp) mcpBlock() string {
 		)
 	}
 
+	states := mcpStates()
+
 	// Limit the number of MCPs shown
 	_, _, maxMCPs := m.getDynamicLimits()
 	maxMCPs = min(len(mcps), maxMCPs)
@@ -807,20 +798,7 @@ func (m *sidebarCmp) mcpBlock() string {
 			break
 		}
 
-		iconColor := t.Success
-		if l.MCP.Disabled {
-			iconColor = t.FgMuted
-		}
-		mcpList = append(mcpList,
-			core.Status(
-				core.StatusOpts{
-					IconColor:   iconColor,
-					Title:       l.Name,
-					Description: l.MCP.Command,
-				},
-				m.getMaxWidth(),
-			),
-		)
+		mcpList = append(mcpList, core.Status(mcpStatusOpts(l, states), m.getMaxWidth()))
 	}
 
 	// Add indicator if there are more MCPs
@@ -837,7 +815,47 @@ func (m *sidebarCmp) mcpBlock() string {
 	)
 }
 
-func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
+// mcpStates returns the health of the MCP servers by name.
+func mcpStates() map[string]agent.MCPState {
+	states := make(map[string]agent.MCPState)
+	for _, state := range agent.MCPStates() {
+		states[state.Name] = state
+	}
+	return states
+}
+
+// mcpStatusOpts renders an MCP server with the color of its health, and
+// why it failed if it did.
+func mcpStatusOpts(l config.MCP, states map[string]agent.MCPState) core.StatusOpts {
+	t := styles.CurrentTheme()
+	description := l.MCP.Command
+	if description == "" {
+		description = l.MCP.URL
+	}
+	opts := core.StatusOpts{
+		IconColor:   t.Success,
+		Title:       l.Name,
+		Description: description,
+	}
+	state, ok := states[l.Name]
+	switch {
+	case l.MCP.Disabled:
+		opts.IconColor = t.FgMuted
+	case !ok:
+	case state.Status == agent.MCPIdle:
+		opts.IconColor = t.FgHalfMuted
+	case state.Status == agent.MCPStarting:
+		opts.IconColor = t.Warning
+		opts.Description = "starting…"
+	case state.Status == agent.MCPFailed:
+		opts.IconColor = t.Error
+		opts.Description = state.Error
+		opts.DescriptionColor = t.Error
+	}
+	return opts
+}
+
+func formatTokensAndCost(tokens, contextWindow int64, cost, cacheCost, reasoningCost float64) string {
 	t := styles.CurrentTheme()
 	// Format tokens in human-readable format (e.g., 110K, 1.2M)
 	var formattedTokens string
@@ -863,6 +881,12 @@ func formatTokensAndCost(tokens, contextWindow int64, cost float64) string {
 	baseStyle := t.S().Base
 
 	formattedCost := baseStyle.Foreground(t.FgMuted).Render(fmt.Sprintf("$%.2f", cost))
+	if cacheCost >= 0.01 {
+		formattedCost += baseStyle.Foreground(t.FgSubtle).Render(fmt.Sprintf(" ($%.2f cache)", cacheCost))
+	}
+	if reasoningCost >= 0.01 {
+		formattedCost += baseStyle.Foreground(t.FgSubtle).Render(fmt.Sprintf(" ($%.2f thinking)", reasoningCost))
+	}
 
 	formattedTokens = baseStyle.Foreground(t.FgSubtle).Render(fmt.Sprintf("(%s)", formattedTokens))
 	formattedPercentage := baseStyle.Foreground(t.FgMuted).Render(fmt.Sprintf("%d%%", int(percentage)))
@@ -918,6 +942,8 @@ func (s *sidebarCmp) currentModelBlock() string {
 				s.session.CompletionTokens+s.session.PromptTokens,
 				model.ContextWindow,
 				s.session.Cost,
+				s.session.CacheCost,
+				s.session.ReasoningCost,
 			),
 		)
 	}
@@ -1,10 +1,17 @@
 package status
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/help"
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
 	"github.com/charmbracelet/lipgloss/v2"
@@ -15,10 +22,23 @@ type StatusCmp interface {
 	util.Model
 	ToggleFullHelp()
 	SetKeyMap(keyMap help.KeyMap)
+	SetSession(session session.Session)
+}
+
+// BudgetMsg updates the budget shown next to the help, nil when no budget
+// is configured.
+type BudgetMsg struct {
+	Usage *agent.BudgetUsage
 }
 
 type statusCmp struct {
-	info       util.InfoMsg
+	info    util.InfoMsg
+	budget  *agent.BudgetUsage
+	session session.Session
+	// streaming estimates the tokens of the response being streamed, until
+	// the usage of the session is updated.
+	streaming  int64
+	agentName  func() string
 	width      int
 	messageTTL time.Duration
 	help       help.Model
@@ -53,19 +73,137 @@ func (m *statusCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, m.clearMessageCmd(ttl)
 	case util.ClearStatusMsg:
 		m.info = util.InfoMsg{}
+	case BudgetMsg:
+		m.budget = msg.Usage
+	case pubsub.Event[session.Session]:
+		if msg.Payload.ID == m.session.ID {
+			m.session = msg.Payload
+			m.streaming = 0
+		}
+	case pubsub.Event[message.Message]:
+		if msg.Payload.SessionID == m.session.ID && msg.Payload.Role == message.Assistant && !msg.Payload.IsFinished() {
+			m.streaming = int64(agent.EstimateTokens(msg.Payload.Model, []message.Message{msg.Payload}))
+		}
 	}
 	return m, nil
 }
 
 func (m *statusCmp) View() string {
 	t := styles.CurrentTheme()
-	status := t.S().Base.Padding(0, 1, 1, 1).Render(m.help.View(m.keyMap))
+	helpView := m.help.View(m.keyMap)
+	var right []string
+	for _, view := range []string{m.usageView(), m.budgetView()} {
+		if view != "" {
+			right = append(right, view)
+		}
+	}
+	if len(right) > 0 {
+		usage := strings.Join(right, t.S().Base.Foreground(t.FgSubtle).Render(" · "))
+		gap := m.width - 2 - lipgloss.Width(helpView) - lipgloss.Width(usage)
+		if gap > 0 {
+			helpView = lipgloss.JoinHorizontal(lipgloss.Top, helpView, strings.Repeat(" ", gap), usage)
+		}
+	}
+	status := t.S().Base.Padding(0, 1, 1, 1).Render(helpView)
 	if m.info.Msg != "" {
 		status = m.infoMsg()
 	}
 	return status
 }
 
+// usageView renders the model of the agent and, in a session, how full its
+// context window is and what the session cost. The tokens of a response, and
+// their cost, are estimated with the tokenizer of the model while it streams.
+func (m *statusCmp) usageView() string {
+	t := styles.CurrentTheme()
+	cfg := config.Get()
+	agentCfg, ok := cfg.Agents[m.agentName()]
+	if !ok {
+		return ""
+	}
+	selected, ok := cfg.Models[agentCfg.Model]
+	if !ok {
+		return ""
+	}
+	name := selected.Model
+	model := cfg.GetModel(selected.Provider, selected.Model)
+	if model != nil && model.Name != "" {
+		name = model.Name
+	}
+	parts := []string{
+		t.S().Base.Foreground(t.FgMuted).Render(name) + t.S().Base.Foreground(t.FgSubtle).Render(" "+selected.Provider),
+	}
+	if m.session.ID == "" {
+		return parts[0]
+	}
+
+	tokens := m.session.PromptTokens + m.session.CompletionTokens + m.streaming
+	formatted := formatTokens(tokens)
+	if m.streaming > 0 {
+		formatted = "~" + formatted
+	}
+	if model != nil && model.ContextWindow > 0 {
+		percentage := float64(tokens) / float64(model.ContextWindow) * 100
+		style := t.S().Base.Foreground(t.FgMuted)
+		if percentage > 80 {
+			style = style.Foreground(t.Warning)
+		}
+		formatted = style.Render(fmt.Sprintf("%d%%", int(percentage))) + t.S().Base.Foreground(t.FgSubtle).Render(" ("+formatted+")")
+	} else {
+		formatted = t.S().Base.Foreground(t.FgMuted).Render(formatted)
+	}
+	cost := fmt.Sprintf("$%.2f", m.session.Cost)
+	if m.streaming > 0 && model != nil {
+		cost = fmt.Sprintf("~$%.2f", m.session.Cost+float64(m.streaming)*model.CostPer1MOut/1e6)
+	}
+	parts = append(parts, formatted, t.S().Base.Foreground(t.FgMuted).Render(cost))
+	return strings.Join(parts, t.S().Base.Foreground(t.FgSubtle).Render(" · "))
+}
+
+// budgetView renders what was spent against the limits of the budget.
+func (m *statusCmp) budgetView() string {
+	if m.budget == nil {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	b := m.budget.Budget
+	var parts []string
+	add := func(label, spent, limit string, exceeded bool) {
+		style := t.S().Base.Foreground(t.FgMuted)
+		if exceeded {
+			style = style.Foreground(t.Error)
+		}
+		parts = append(parts, style.Render(fmt.Sprintf("%s %s/%s", label, spent, limit)))
+	}
+	if b.SessionCost > 0 {
+		add("session", fmt.Sprintf("$%.2f", m.budget.Session.Cost), fmt.Sprintf("$%.2f", b.SessionCost), m.budget.Session.Cost >= b.SessionCost)
+	}
+	if b.SessionTokens > 0 {
+		add("session", formatTokens(m.budget.Session.Tokens), formatTokens(b.SessionTokens), m.budget.Session.Tokens >= b.SessionTokens)
+	}
+	if b.DailyCost > 0 {
+		add("today", fmt.Sprintf("$%.2f", m.budget.Today.Cost), fmt.Sprintf("$%.2f", b.DailyCost), m.budget.Today.Cost >= b.DailyCost)
+	}
+	if b.DailyTokens > 0 {
+		add("today", formatTokens(m.budget.Today.Tokens), formatTokens(b.DailyTokens), m.budget.Today.Tokens >= b.DailyTokens)
+	}
+	return strings.Join(parts, t.S().Base.Foreground(t.FgSubtle).Render(" · "))
+}
+
+// formatTokens formats a token count in a human-readable way (e.g. 110K, 1.2M).
+func formatTokens(tokens int64) string {
+	var formatted string
+	switch {
+	case tokens >= 1_000_000:
+		formatted = fmt.Sprintf("%.1fM", float64(tokens)/1_000_000)
+	case tokens >= 1_000:
+		formatted = fmt.Sprintf("%.1fK", float64(tokens)/1_000)
+	default:
+		return fmt.Sprintf("%d", tokens)
+	}
+	return strings.Replace(formatted, ".0", "", 1)
+}
+
 func (m *statusCmp) infoMsg() string {
 	t := styles.CurrentTheme()
 	message := ""
@@ -98,12 +236,21 @@ func (m *statusCmp) SetKeyMap(keyMap help.KeyMap) {
 	m.keyMap = keyMap
 }
 
-func NewStatusCmp() StatusCmp {
+// SetSession shows the usage of the session, none when its ID is empty.
+func (m *statusCmp) SetSession(session session.Session) {
+	m.session = session
+	m.streaming = 0
+}
+
+// NewStatusCmp returns the status bar, showing the model of the agent named
+// by agentName.
+func NewStatusCmp(agentName func() string) StatusCmp {
 	t := styles.CurrentTheme()
 	help := help.New()
 	help.Styles = t.S().Help
 	return &statusCmp{
 		messageTTL: 5 * time.Second,
 		help:       help,
+		agentName:  agentName,
 	}
 }
@@ -0,0 +1,40 @@
+package status
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingTokens(t *testing.T) {
+	m := NewStatusCmp(func() string { return "coder" }).(*statusCmp)
+	m.SetSession(session.Session{ID: "session", PromptTokens: 1000})
+
+	streaming := message.Message{
+		SessionID: "session",
+		Role:      message.Assistant,
+		Parts:     []message.ContentPart{message.TextContent{Text: strings.Repeat("word", 100)}},
+	}
+	m.Update(pubsub.Event[message.Message]{Payload: streaming})
+	require.Equal(t, int64(100), m.streaming)
+
+	other := streaming
+	other.SessionID = "other"
+	other.Parts = []message.ContentPart{message.TextContent{Text: strings.Repeat("word", 500)}}
+	m.Update(pubsub.Event[message.Message]{Payload: other})
+	require.Equal(t, int64(100), m.streaming)
+
+	m.Update(pubsub.Event[session.Session]{Type: pubsub.UpdatedEvent, Payload: session.Session{ID: "session", PromptTokens: 1200}})
+	require.Zero(t, m.streaming)
+	require.Equal(t, int64(1200), m.session.PromptTokens)
+}
+
+func TestFormatTokens(t *testing.T) {
+	require.Equal(t, "950", formatTokens(950))
+	require.Equal(t, "12K", formatTokens(12_000))
+	require.Equal(t, "1.5M", formatTokens(1_500_000))
+}
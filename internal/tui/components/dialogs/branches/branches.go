@@ -0,0 +1,176 @@
+package branches
+
+import (
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const BranchesDialogID dialogs.DialogID = "branches"
+
+// ForkSelectedMsg is sent when the message to fork a session at is chosen,
+// the prompt is put back in the editor of the fork.
+type ForkSelectedMsg struct {
+	SessionID string
+	MessageID string
+	Prompt    string
+}
+
+// Item is an entry of the dialog, its message is sent when it's chosen.
+type Item struct {
+	Title string
+	Msg   tea.Msg
+}
+
+// BranchesDialog interface for the dialogs choosing where to fork a session
+// and which branch to switch to
+type BranchesDialog interface {
+	dialogs.DialogModel
+}
+
+type BranchesList = list.FilterableList[list.CompletionItem[Item]]
+
+type branchesDialogCmp struct {
+	wWidth       int
+	wHeight      int
+	width        int
+	title        string
+	keyMap       KeyMap
+	branchesList BranchesList
+	help         help.Model
+}
+
+// NewBranchesDialogCmp creates a new dialog with the title to choose one of
+// the items.
+func NewBranchesDialogCmp(title string, items []Item) BranchesDialog {
+	t := styles.CurrentTheme()
+	listKeyMap := list.DefaultKeyMap()
+	keyMap := DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	listItems := make([]list.CompletionItem[Item], len(items))
+	for i, item := range items {
+		listItems[i] = list.NewCompletionItem(item.Title, item)
+	}
+
+	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
+	branchesList := list.NewFilterableList(
+		listItems,
+		list.WithFilterPlaceholder("Filter"),
+		list.WithFilterInputStyle(inputStyle),
+		list.WithFilterListOptions(
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+		),
+	)
+	help := help.New()
+	help.Styles = t.S().Help
+	return &branchesDialogCmp{
+		title:        title,
+		keyMap:       keyMap,
+		branchesList: branchesList,
+		help:         help,
+	}
+}
+
+func (b *branchesDialogCmp) Init() tea.Cmd {
+	return tea.Sequence(b.branchesList.Init(), b.branchesList.Focus())
+}
+
+func (b *branchesDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.wWidth = msg.Width
+		b.wHeight = msg.Height
+		b.width = min(120, b.wWidth-8)
+		b.branchesList.SetInputWidth(b.listWidth() - 2)
+		return b, b.branchesList.SetSize(b.listWidth(), b.listHeight())
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, b.keyMap.Select):
+			selectedItem := b.branchesList.SelectedItem()
+			if selectedItem != nil {
+				selected := *selectedItem
+				return b, tea.Sequence(
+					util.CmdHandler(dialogs.CloseDialogMsg{}),
+					util.CmdHandler(selected.Value().Msg),
+				)
+			}
+		case key.Matches(msg, b.keyMap.Close):
+			return b, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			u, cmd := b.branchesList.Update(msg)
+			b.branchesList = u.(BranchesList)
+			return b, cmd
+		}
+	}
+	return b, nil
+}
+
+func (b *branchesDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title(b.title, b.width-4)),
+		b.branchesList.View(),
+		"",
+		t.S().Base.Width(b.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(b.help.View(b.keyMap)),
+	)
+	return b.style().Render(content)
+}
+
+func (b *branchesDialogCmp) Cursor() *tea.Cursor {
+	if cursor, ok := b.branchesList.(util.Cursor); ok {
+		cursor := cursor.Cursor()
+		if cursor != nil {
+			cursor = b.moveCursor(cursor)
+		}
+		return cursor
+	}
+	return nil
+}
+
+func (b *branchesDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(b.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (b *branchesDialogCmp) listHeight() int {
+	return b.wHeight/2 - 6 // 5 for the border, title and help
+}
+
+func (b *branchesDialogCmp) listWidth() int {
+	return b.width - 2 // 2 for the border
+}
+
+func (b *branchesDialogCmp) Position() (int, int) {
+	row := b.wHeight/4 - 2 // just a bit above the center
+	col := b.wWidth / 2
+	col -= b.width / 2
+	return row, col
+}
+
+func (b *branchesDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := b.Position()
+	offset := row + 3 // Border + title
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 2
+	return cursor
+}
+
+// ID implements BranchesDialog.
+func (b *branchesDialogCmp) ID() dialogs.DialogID {
+	return BranchesDialogID
+}
@@ -0,0 +1,169 @@
+package checkpoints
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/checkpoint"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const CheckpointsDialogID dialogs.DialogID = "checkpoints"
+
+// CheckpointSelectedMsg is sent when a checkpoint to restore is chosen.
+type CheckpointSelectedMsg struct {
+	Checkpoint checkpoint.Checkpoint
+}
+
+// CheckpointsDialog interface for the checkpoint restoring dialog
+type CheckpointsDialog interface {
+	dialogs.DialogModel
+}
+
+type CheckpointsList = list.FilterableList[list.CompletionItem[checkpoint.Checkpoint]]
+
+type checkpointsDialogCmp struct {
+	wWidth          int
+	wHeight         int
+	width           int
+	keyMap          KeyMap
+	checkpointsList CheckpointsList
+	help            help.Model
+}
+
+// NewCheckpointsDialogCmp creates a new dialog to choose the checkpoint to
+// restore, the latest first.
+func NewCheckpointsDialogCmp(checkpoints []checkpoint.Checkpoint) CheckpointsDialog {
+	t := styles.CurrentTheme()
+	listKeyMap := list.DefaultKeyMap()
+	keyMap := DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	items := make([]list.CompletionItem[checkpoint.Checkpoint], len(checkpoints))
+	for i, c := range checkpoints {
+		title := fmt.Sprintf("%s  %s  %s", c.ShortID(), c.CreatedAt.Format(time.DateTime), c.Message)
+		items[i] = list.NewCompletionItem(title, c, list.WithCompletionID(c.ID))
+	}
+
+	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
+	checkpointsList := list.NewFilterableList(
+		items,
+		list.WithFilterPlaceholder("Enter a checkpoint message"),
+		list.WithFilterInputStyle(inputStyle),
+		list.WithFilterListOptions(
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+		),
+	)
+	help := help.New()
+	help.Styles = t.S().Help
+	return &checkpointsDialogCmp{
+		keyMap:          keyMap,
+		checkpointsList: checkpointsList,
+		help:            help,
+	}
+}
+
+func (c *checkpointsDialogCmp) Init() tea.Cmd {
+	return tea.Sequence(c.checkpointsList.Init(), c.checkpointsList.Focus())
+}
+
+func (c *checkpointsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.wWidth = msg.Width
+		c.wHeight = msg.Height
+		c.width = min(120, c.wWidth-8)
+		c.checkpointsList.SetInputWidth(c.listWidth() - 2)
+		return c, c.checkpointsList.SetSize(c.listWidth(), c.listHeight())
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, c.keyMap.Select):
+			selectedItem := c.checkpointsList.SelectedItem()
+			if selectedItem != nil {
+				selected := *selectedItem
+				return c, tea.Sequence(
+					util.CmdHandler(dialogs.CloseDialogMsg{}),
+					util.CmdHandler(CheckpointSelectedMsg{Checkpoint: selected.Value()}),
+				)
+			}
+		case key.Matches(msg, c.keyMap.Close):
+			return c, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			u, cmd := c.checkpointsList.Update(msg)
+			c.checkpointsList = u.(CheckpointsList)
+			return c, cmd
+		}
+	}
+	return c, nil
+}
+
+func (c *checkpointsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Restore Checkpoint", c.width-4)),
+		c.checkpointsList.View(),
+		"",
+		t.S().Base.Width(c.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(c.help.View(c.keyMap)),
+	)
+	return c.style().Render(content)
+}
+
+func (c *checkpointsDialogCmp) Cursor() *tea.Cursor {
+	if cursor, ok := c.checkpointsList.(util.Cursor); ok {
+		cursor := cursor.Cursor()
+		if cursor != nil {
+			cursor = c.moveCursor(cursor)
+		}
+		return cursor
+	}
+	return nil
+}
+
+func (c *checkpointsDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(c.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (c *checkpointsDialogCmp) listHeight() int {
+	return c.wHeight/2 - 6 // 5 for the border, title and help
+}
+
+func (c *checkpointsDialogCmp) listWidth() int {
+	return c.width - 2 // 2 for the border
+}
+
+func (c *checkpointsDialogCmp) Position() (int, int) {
+	row := c.wHeight/4 - 2 // just a bit above the center
+	col := c.wWidth / 2
+	col -= c.width / 2
+	return row, col
+}
+
+func (c *checkpointsDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := c.Position()
+	offset := row + 3 // Border + title
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 2
+	return cursor
+}
+
+// ID implements CheckpointsDialog.
+func (c *checkpointsDialogCmp) ID() dialogs.DialogID {
+	return CheckpointsDialogID
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/charmbracelet/lipgloss/v2"
 
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/llm/prompt"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
@@ -63,6 +64,8 @@ type (
 	SwitchSessionsMsg     struct{}
 	NewSessionsMsg        struct{}
 	SwitchModelMsg        struct{}
+	AddProviderMsg        struct{}
+	ShowModelStatsMsg     struct{}
 	QuitMsg               struct{}
 	OpenFilePickerMsg     struct{}
 	ToggleHelpMsg         struct{}
@@ -72,6 +75,36 @@ type (
 	CompactMsg            struct {
 		SessionID string
 	}
+	UndoChangeSetMsg struct {
+		SessionID string
+	}
+	RerunInterruptedMsg struct {
+		SessionID string
+	}
+	UndoTurnMsg          struct{}
+	RestoreCheckpointMsg struct{}
+	ForkSessionMsg       struct {
+		SessionID string
+	}
+	ShowBranchesMsg struct {
+		SessionID string
+	}
+	MergeNotesMsg struct {
+		SessionID string
+		Notes     string
+	}
+	ShowTasksMsg   struct{}
+	EnqueueTaskMsg struct {
+		Prompt string
+	}
+	SwitchAgentMsg struct {
+		Name string
+	}
+	// SetThinkingDisplayMsg sets how the thinking of reasoning models is
+	// shown.
+	SetThinkingDisplayMsg struct {
+		Display string
+	}
 )
 
 func NewCommandDialog(sessionID string) CommandsDialog {
@@ -280,20 +313,106 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				return util.CmdHandler(SwitchModelMsg{})
 			},
 		},
+		{
+			ID:          "add_provider",
+			Title:       "Add Custom Provider",
+			Description: "Add an OpenAI-compatible provider and discover its models",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(AddProviderMsg{})
+			},
+		},
+		{
+			ID:          "background_tasks",
+			Title:       "Background Tasks",
+			Description: "Review the runs of the queued and scheduled tasks (/queue to add one)",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ShowTasksMsg{})
+			},
+		},
+		{
+			ID:          "model_stats",
+			Title:       "Model Stats",
+			Description: "Show the latency and throughput of the models",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ShowModelStatsMsg{})
+			},
+		},
+	}
+
+	// Only show the checkpoint commands if the checkpoints are enabled
+	if options := config.Get().Options.Checkpoints; options == nil || !options.Disabled {
+		commands = append(commands, Command{
+			ID:          "undo_turn",
+			Title:       "Undo Last Turn",
+			Description: "Restore the checkpoint taken before the last turn changing files",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(UndoTurnMsg{})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "restore_checkpoint",
+			Title:       "Restore Checkpoint",
+			Description: "Roll back the working directory to a checkpoint",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(RestoreCheckpointMsg{})
+			},
+		})
 	}
 
 	// Only show compact command if there's an active session
 	if c.sessionID != "" {
 		commands = append(commands, Command{
 			ID:          "Summarize",
-			Title:       "Summarize Session",
-			Description: "Summarize the current session and create a new one with the summary",
+			Title:       "Compact Session",
+			Description: "Summarize the older messages of the session to free up context (/compact)",
 			Handler: func(cmd Command) tea.Cmd {
 				return util.CmdHandler(CompactMsg{
 					SessionID: c.sessionID,
 				})
 			},
 		})
+		commands = append(commands, Command{
+			ID:          "fork_session",
+			Title:       "Fork Session",
+			Description: "Continue the session in a new branch from one of its prompts",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ForkSessionMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		commands = append(commands, Command{
+			ID:          "show_branches",
+			Title:       "Branches",
+			Description: "Switch to another branch of the session (/merge to send notes back)",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(ShowBranchesMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
+		if _, ok := agent.FindInterruptedTurn(config.Get().Options.DataDirectory, c.sessionID); ok {
+			commands = append(commands, Command{
+				ID:          "rerun_interrupted",
+				Title:       "Re-run Interrupted Turn",
+				Description: "Run the prompt of the turn interrupted when Crush stopped again",
+				Handler: func(cmd Command) tea.Cmd {
+					return util.CmdHandler(RerunInterruptedMsg{
+						SessionID: c.sessionID,
+					})
+				},
+			})
+		}
+		commands = append(commands, Command{
+			ID:          "undo_change_set",
+			Title:       "Undo Last Change Set",
+			Description: "Restore the files changed by the last patch or transaction of the session",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(UndoChangeSetMsg{
+					SessionID: c.sessionID,
+				})
+			},
+		})
 	}
 
 	// Only show thinking toggle for Anthropic models that can reason
@@ -319,6 +438,21 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		}
 	}
 
+	if c.sessionID != "" {
+		display, title := config.ThinkingExpanded, "Expand Thinking"
+		if cfg.Options.TUI.Thinking == config.ThinkingExpanded {
+			display, title = config.ThinkingCollapsed, "Collapse Thinking"
+		}
+		commands = append(commands, Command{
+			ID:          "thinking_display",
+			Title:       title,
+			Description: "Show or collapse the thinking of reasoning models in the messages",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(SetThinkingDisplayMsg{Display: display})
+			},
+		})
+	}
+
 	// Only show toggle compact mode command if window width is larger than compact breakpoint (90)
 	if c.wWidth > 120 && c.sessionID != "" {
 		commands = append(commands, Command{
@@ -346,6 +480,22 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 		}
 	}
 
+	if len(config.Get().MCP) > 0 {
+		commands = append(commands, Command{
+			ID:          "restart_mcp",
+			Title:       "Restart MCP Servers",
+			Description: "Restart the MCP servers",
+			Handler: func(cmd Command) tea.Cmd {
+				return func() tea.Msg {
+					if err := agent.RestartMCPServers(); err != nil {
+						return util.ReportError(err)()
+					}
+					return util.ReportInfo("MCP servers restarted")()
+				}
+			},
+		})
+	}
+
 	// Add external editor command if $EDITOR is available
 	if os.Getenv("EDITOR") != "" {
 		commands = append(commands, Command{
@@ -379,6 +529,16 @@ func (c *commandDialogCmp) defaultCommands() []Command {
 				})
 			},
 		},
+		{
+			ID:          "memory",
+			Title:       "Update Memory",
+			Description: "Add what the agent learned in this session to the project memory",
+			Handler: func(cmd Command) tea.Cmd {
+				return util.CmdHandler(chat.SendMsg{
+					Text: prompt.Remember(""),
+				})
+			},
+		},
 		{
 			ID:          "quit",
 			Title:       "Quit",
@@ -13,20 +13,10 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/util"
 )
 
-const (
-	UserCommandPrefix    = "user:"
-	ProjectCommandPrefix = "project:"
-)
-
 var namedArgPattern = regexp.MustCompile(`\$([A-Z][A-Z0-9_]*)`)
 
 type commandLoader struct {
-	sources []commandSource
-}
-
-type commandSource struct {
-	path   string
-	prefix string
+	sources []config.CommandSource
 }
 
 func LoadCustomCommands() ([]Command, error) {
@@ -36,53 +26,12 @@ func LoadCustomCommands() ([]Command, error) {
 	}
 
 	loader := &commandLoader{
-		sources: buildCommandSources(cfg),
+		sources: cfg.CommandSources(),
 	}
 
 	return loader.loadAll()
 }
 
-func buildCommandSources(cfg *config.Config) []commandSource {
-	var sources []commandSource
-
-	// XDG config directory
-	if dir := getXDGCommandsDir(); dir != "" {
-		sources = append(sources, commandSource{
-			path:   dir,
-			prefix: UserCommandPrefix,
-		})
-	}
-
-	// Home directory
-	if home, err := os.UserHomeDir(); err == nil {
-		sources = append(sources, commandSource{
-			path:   filepath.Join(home, ".crush", "commands"),
-			prefix: UserCommandPrefix,
-		})
-	}
-
-	// Project directory
-	sources = append(sources, commandSource{
-		path:   filepath.Join(cfg.Options.DataDirectory, "commands"),
-		prefix: ProjectCommandPrefix,
-	})
-
-	return sources
-}
-
-func getXDGCommandsDir() string {
-	xdgHome := os.Getenv("XDG_CONFIG_HOME")
-	if xdgHome == "" {
-		if home, err := os.UserHomeDir(); err == nil {
-			xdgHome = filepath.Join(home, ".config")
-		}
-	}
-	if xdgHome != "" {
-		return filepath.Join(xdgHome, "crush", "commands")
-	}
-	return ""
-}
-
 func (l *commandLoader) loadAll() ([]Command, error) {
 	var commands []Command
 
@@ -95,19 +44,19 @@ func (l *commandLoader) loadAll() ([]Command, error) {
 	return commands, nil
 }
 
-func (l *commandLoader) loadFromSource(source commandSource) ([]Command, error) {
-	if err := ensureDir(source.path); err != nil {
+func (l *commandLoader) loadFromSource(source config.CommandSource) ([]Command, error) {
+	if err := ensureDir(source.Path); err != nil {
 		return nil, err
 	}
 
 	var commands []Command
 
-	err := filepath.WalkDir(source.path, func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(source.Path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() || !isMarkdownFile(d.Name()) {
 			return err
 		}
 
-		cmd, err := l.loadCommand(path, source.path, source.prefix)
+		cmd, err := l.loadCommand(path, source.Path, source.Prefix)
 		if err != nil {
 			return nil // Skip invalid files
 		}
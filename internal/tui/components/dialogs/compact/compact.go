@@ -165,7 +165,7 @@ func (c *compactDialogCmp) renderContent() string {
 	case stateConfirm:
 		explanation := t.S().Text.
 			Width(c.width - 4).
-			Render("This will summarize the current session and reset the context. The conversation history will be condensed into a summary to free up context space while preserving important information.")
+			Render("This will summarize the older messages of the current session to free up context space. The summary pins the important facts, and the most recent messages are kept as they are.")
 
 		question := t.S().Text.
 			Width(c.width - 4).
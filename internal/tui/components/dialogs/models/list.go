@@ -189,6 +189,11 @@ func (m *ModelListComponent) SetModelType(modelType int) tea.Cmd {
 		if providerConfig, exists := cfg.Providers.Get(string(provider.ID)); exists && providerConfig.Disable {
 			continue
 		}
+		// Local providers may only have embedding models, there's nothing
+		// to pick.
+		if len(provider.Models) == 0 {
+			continue
+		}
 
 		name := provider.Name
 		if name == "" {
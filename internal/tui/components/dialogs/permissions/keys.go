@@ -11,12 +11,18 @@ type KeyMap struct {
 	Select,
 	Allow,
 	AllowSession,
+	AllowProject,
 	Deny,
 	ToggleDiffMode,
 	ScrollDown,
 	ScrollUp key.Binding
 	ScrollLeft,
 	ScrollRight key.Binding
+	// The review of the hunks of file changes.
+	ReviewHunks,
+	NextHunk,
+	PreviousHunk,
+	ToggleHunk key.Binding
 }
 
 func DefaultKeyMap() KeyMap {
@@ -41,6 +47,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("s", "S", "ctrl+s"),
 			key.WithHelp("s", "allow session"),
 		),
+		AllowProject: key.NewBinding(
+			key.WithKeys("p", "P"),
+			key.WithHelp("p", "allow project"),
+		),
 		Deny: key.NewBinding(
 			key.WithKeys("d", "D", "ctrl+d"),
 			key.WithHelp("d", "deny"),
@@ -69,6 +79,22 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("shift+right", "L"),
 			key.WithHelp("shift+→", "scroll right"),
 		),
+		ReviewHunks: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "review hunks"),
+		),
+		NextHunk: key.NewBinding(
+			key.WithKeys("n", "]"),
+			key.WithHelp("n", "next hunk"),
+		),
+		PreviousHunk: key.NewBinding(
+			key.WithKeys("N", "["),
+			key.WithHelp("N", "previous hunk"),
+		),
+		ToggleHunk: key.NewBinding(
+			key.WithKeys("space", "x"),
+			key.WithHelp("space", "accept/reject hunk"),
+		),
 	}
 }
 
@@ -81,12 +107,17 @@ func (k KeyMap) KeyBindings() []key.Binding {
 		k.Select,
 		k.Allow,
 		k.AllowSession,
+		k.AllowProject,
 		k.Deny,
 		k.ToggleDiffMode,
 		k.ScrollDown,
 		k.ScrollUp,
 		k.ScrollLeft,
 		k.ScrollRight,
+		k.ReviewHunks,
+		k.NextHunk,
+		k.PreviousHunk,
+		k.ToggleHunk,
 	}
 }
 
@@ -8,7 +8,9 @@ import (
 	"github.com/charmbracelet/bubbles/v2/key"
 	"github.com/charmbracelet/bubbles/v2/viewport"
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/diff"
 	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/llm/agent"
 	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
@@ -25,7 +27,11 @@ type PermissionAction string
 const (
 	PermissionAllow           PermissionAction = "allow"
 	PermissionAllowForSession PermissionAction = "allow_session"
-	PermissionDeny            PermissionAction = "deny"
+	PermissionAllowForProject PermissionAction = "allow_project"
+	// PermissionAllowReviewed allows a file change with only the hunks the
+	// user accepted.
+	PermissionAllowReviewed PermissionAction = "allow_reviewed"
+	PermissionDeny          PermissionAction = "deny"
 
 	PermissionsDialogID dialogs.DialogID = "permissions"
 )
@@ -34,6 +40,9 @@ const (
 type PermissionResponseMsg struct {
 	Permission permission.PermissionRequest
 	Action     PermissionAction
+	// Content is the content of the file with the accepted hunks, for
+	// PermissionAllowReviewed.
+	Content string
 }
 
 // PermissionDialogCmp interface for permission dialog component
@@ -49,7 +58,11 @@ type permissionDialogCmp struct {
 	height          int
 	permission      permission.PermissionRequest
 	contentViewPort viewport.Model
-	selectedOption  int // 0: Allow, 1: Allow for session, 2: Deny
+	selectedOption  int // 0: Allow, 1: Allow for session, 2: Allow in project, 3: Deny
+
+	// confirming is set after the first "Allow" of a destructive command,
+	// which needs to be confirmed a second time.
+	confirming bool
 
 	// Diff view state
 	defaultDiffSplitMode bool  // true for split, false for unified
@@ -57,6 +70,13 @@ type permissionDialogCmp struct {
 	diffXOffset          int   // horizontal scroll offset
 	diffYOffset          int   // vertical scroll offset
 
+	// Hunk review of file changes: the hunks are shown one by one when
+	// reviewing, and only the accepted ones are written.
+	hunks       []diff.Hunk
+	accepted    []bool
+	currentHunk int
+	reviewing   bool
+
 	// Caching
 	cachedContent string
 	contentDirty  bool
@@ -70,21 +90,53 @@ type permissionDialogCmp struct {
 func NewPermissionDialogCmp(permission permission.PermissionRequest) PermissionDialogCmp {
 	// Create viewport for content
 	contentViewport := viewport.New()
-	return &permissionDialogCmp{
+	selectedOption := 0 // Default to "Allow"
+	if permission.DestructiveReason != "" {
+		selectedOption = 3 // Default to "Deny" for destructive commands
+	}
+	p := &permissionDialogCmp{
 		contentViewPort: contentViewport,
-		selectedOption:  0, // Default to "Allow"
+		selectedOption:  selectedOption,
 		permission:      permission,
 		keyMap:          DefaultKeyMap(),
 		contentDirty:    true, // Mark as dirty initially
 	}
+	if _, before, after, ok := p.fileChange(); ok {
+		p.hunks = diff.Hunks(before, after)
+		p.accepted = make([]bool, len(p.hunks))
+		for i := range p.accepted {
+			p.accepted[i] = true
+		}
+	}
+	return p
 }
 
 func (p *permissionDialogCmp) Init() tea.Cmd {
 	return p.contentViewPort.Init()
 }
 
+func (p *permissionDialogCmp) isDestructive() bool {
+	return p.permission.DestructiveReason != ""
+}
+
+// allowsOnce reports whether the request can only be allowed once, for
+// destructive commands and file changes with rejected hunks.
+func (p *permissionDialogCmp) allowsOnce() bool {
+	return p.isDestructive() || p.rejectedHunks() > 0
+}
+
+// moveSelection moves the selected option by delta, skipping "Allow for
+// Session" and "Allow in Project" for requests allowed once.
+func (p *permissionDialogCmp) moveSelection(delta int) {
+	p.confirming = false
+	p.selectedOption = (p.selectedOption + delta + 4) % 4
+	for p.allowsOnce() && (p.selectedOption == 1 || p.selectedOption == 2) {
+		p.selectedOption = (p.selectedOption + delta + 4) % 4
+	}
+}
+
 func (p *permissionDialogCmp) supportsDiffView() bool {
-	return p.permission.ToolName == tools.EditToolName || p.permission.ToolName == tools.WriteToolName || p.permission.ToolName == tools.MultiEditToolName
+	return p.permission.ToolName == tools.EditToolName || p.permission.ToolName == tools.WriteToolName || p.permission.ToolName == tools.MultiEditToolName || p.permission.ToolName == tools.ApplyPatchToolName || p.permission.ToolName == tools.TransactionToolName || p.permission.ToolName == tools.RenameToolName || p.permission.ToolName == tools.CodeActionToolName
 }
 
 func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -100,27 +152,56 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyPressMsg:
 		switch {
 		case key.Matches(msg, p.keyMap.Right) || key.Matches(msg, p.keyMap.Tab):
-			p.selectedOption = (p.selectedOption + 1) % 3
+			p.moveSelection(1)
 			return p, nil
 		case key.Matches(msg, p.keyMap.Left):
-			p.selectedOption = (p.selectedOption + 2) % 3
+			p.moveSelection(-1)
 		case key.Matches(msg, p.keyMap.Select):
 			return p, p.selectCurrentOption()
 		case key.Matches(msg, p.keyMap.Allow):
+			p.selectedOption = 0
+			return p, p.selectCurrentOption()
+		case key.Matches(msg, p.keyMap.AllowSession):
+			if p.allowsOnce() {
+				return p, nil
+			}
 			return p, tea.Batch(
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
-				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllow, Permission: p.permission}),
+				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowForSession, Permission: p.permission}),
 			)
-		case key.Matches(msg, p.keyMap.AllowSession):
+		case key.Matches(msg, p.keyMap.AllowProject):
+			if p.allowsOnce() {
+				return p, nil
+			}
 			return p, tea.Batch(
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
-				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowForSession, Permission: p.permission}),
+				util.CmdHandler(PermissionResponseMsg{Action: PermissionAllowForProject, Permission: p.permission}),
 			)
 		case key.Matches(msg, p.keyMap.Deny):
 			return p, tea.Batch(
 				util.CmdHandler(dialogs.CloseDialogMsg{}),
 				util.CmdHandler(PermissionResponseMsg{Action: PermissionDeny, Permission: p.permission}),
 			)
+		case key.Matches(msg, p.keyMap.ReviewHunks):
+			if p.canReview() {
+				p.toggleReview()
+				return p, nil
+			}
+		case key.Matches(msg, p.keyMap.NextHunk):
+			if p.reviewing {
+				p.moveHunk(1)
+				return p, nil
+			}
+		case key.Matches(msg, p.keyMap.PreviousHunk):
+			if p.reviewing {
+				p.moveHunk(-1)
+				return p, nil
+			}
+		case key.Matches(msg, p.keyMap.ToggleHunk):
+			if p.reviewing {
+				p.toggleHunk()
+				return p, nil
+			}
 		case key.Matches(msg, p.keyMap.ToggleDiffMode):
 			if p.supportsDiffView() {
 				if p.diffSplitMode == nil {
@@ -169,18 +250,34 @@ func (p *permissionDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (p *permissionDialogCmp) selectCurrentOption() tea.Cmd {
 	var action PermissionAction
+	var content string
 
 	switch p.selectedOption {
 	case 0:
+		if p.isDestructive() && !p.confirming {
+			p.confirming = true
+			return nil
+		}
 		action = PermissionAllow
+		if rejected := p.rejectedHunks(); rejected == len(p.hunks) && rejected > 0 {
+			action = PermissionDeny
+		} else if rejected > 0 {
+			reviewed, err := p.reviewedContent()
+			if err != nil {
+				return util.ReportError(err)
+			}
+			action, content = PermissionAllowReviewed, reviewed
+		}
 	case 1:
 		action = PermissionAllowForSession
 	case 2:
+		action = PermissionAllowForProject
+	case 3:
 		action = PermissionDeny
 	}
 
 	return tea.Batch(
-		util.CmdHandler(PermissionResponseMsg{Action: action, Permission: p.permission}),
+		util.CmdHandler(PermissionResponseMsg{Action: action, Permission: p.permission, Content: content}),
 		util.CmdHandler(dialogs.CloseDialogMsg{}),
 	)
 }
@@ -189,23 +286,34 @@ func (p *permissionDialogCmp) renderButtons() string {
 	t := styles.CurrentTheme()
 	baseStyle := t.S().Base
 
-	buttons := []core.ButtonOpts{
-		{
-			Text:           "Allow",
-			UnderlineIndex: 0, // "A"
-			Selected:       p.selectedOption == 0,
-		},
-		{
+	allowButton := core.ButtonOpts{
+		Text:           "Allow",
+		UnderlineIndex: 0, // "A"
+		Selected:       p.selectedOption == 0,
+	}
+	if p.confirming {
+		allowButton.Text = "Allow (press again to confirm)"
+	}
+	if rejected := p.rejectedHunks(); rejected > 0 {
+		allowButton.Text = fmt.Sprintf("Allow %d of %d Hunks", len(p.hunks)-rejected, len(p.hunks))
+	}
+	buttons := []core.ButtonOpts{allowButton}
+	if !p.allowsOnce() {
+		buttons = append(buttons, core.ButtonOpts{
 			Text:           "Allow for Session",
 			UnderlineIndex: 10, // "S" in "Session"
 			Selected:       p.selectedOption == 1,
-		},
-		{
-			Text:           "Deny",
-			UnderlineIndex: 0, // "D"
+		}, core.ButtonOpts{
+			Text:           "Allow in Project",
+			UnderlineIndex: 9, // "P" in "Project"
 			Selected:       p.selectedOption == 2,
-		},
+		})
 	}
+	buttons = append(buttons, core.ButtonOpts{
+		Text:           "Deny",
+		UnderlineIndex: 0, // "D"
+		Selected:       p.selectedOption == 3,
+	})
 
 	content := core.SelectableButtons(buttons, "  ")
 	if lipgloss.Width(content) > p.width-4 {
@@ -248,6 +356,19 @@ func (p *permissionDialogCmp) renderHeader() string {
 		baseStyle.Render(strings.Repeat(" ", p.width)),
 	}
 
+	if p.isDestructive() {
+		reason := fmt.Sprintf("Destructive command: %s", p.permission.DestructiveReason)
+		if p.permission.ToolName == agent.BudgetToolName {
+			reason = p.permission.DestructiveReason
+		}
+		warning := t.S().Base.
+			Foreground(t.Error).
+			Bold(true).
+			Width(p.width).
+			Render(reason)
+		headerParts = append(headerParts, warning, baseStyle.Render(strings.Repeat(" ", p.width)))
+	}
+
 	// Add tool-specific header information
 	switch p.permission.ToolName {
 	case tools.BashToolName:
@@ -319,6 +440,38 @@ func (p *permissionDialogCmp) renderHeader() string {
 			),
 			baseStyle.Render(strings.Repeat(" ", p.width)),
 		)
+	case tools.ApplyPatchToolName:
+		params := p.permission.Params.(tools.ApplyPatchPermissionsParams)
+		fileKey := t.S().Muted.Render("File")
+		filePath := t.S().Text.
+			Width(p.width - lipgloss.Width(fileKey)).
+			Render(fmt.Sprintf(" %s", fsext.PrettyPath(params.FilePath)))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				fileKey,
+				filePath,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
+	case tools.TransactionToolName, tools.RenameToolName, tools.CodeActionToolName:
+		params := p.permission.Params.(tools.TransactionPermissionsParams)
+		var paths []string
+		for _, f := range params.Files {
+			paths = append(paths, fsext.PrettyPath(f.FilePath))
+		}
+		filesKey := t.S().Muted.Render("Files")
+		files := t.S().Text.
+			Width(p.width - lipgloss.Width(filesKey)).
+			Render(fmt.Sprintf(" %s", strings.Join(paths, ", ")))
+		headerParts = append(headerParts,
+			lipgloss.JoinHorizontal(
+				lipgloss.Left,
+				filesKey,
+				files,
+			),
+			baseStyle.Render(strings.Repeat(" ", p.width)),
+		)
 	case tools.FetchToolName:
 		headerParts = append(headerParts, t.S().Muted.Width(p.width).Bold(true).Render("URL"))
 	case tools.ViewToolName:
@@ -373,6 +526,10 @@ func (p *permissionDialogCmp) getOrGenerateContent() string {
 		content = p.generateWriteContent()
 	case tools.MultiEditToolName:
 		content = p.generateMultiEditContent()
+	case tools.ApplyPatchToolName:
+		content = p.generateApplyPatchContent()
+	case tools.TransactionToolName, tools.RenameToolName, tools.CodeActionToolName:
+		content = p.generateTransactionContent()
 	case tools.FetchToolName:
 		content = p.generateFetchContent()
 	case tools.ViewToolName:
@@ -423,6 +580,9 @@ func (p *permissionDialogCmp) generateBashContent() string {
 }
 
 func (p *permissionDialogCmp) generateEditContent() string {
+	if p.reviewing {
+		return p.generateReviewContent()
+	}
 	if pr, ok := p.permission.Params.(tools.EditPermissionsParams); ok {
 		formatter := core.DiffFormatter().
 			Before(fsext.PrettyPath(pr.FilePath), pr.OldContent).
@@ -444,6 +604,9 @@ func (p *permissionDialogCmp) generateEditContent() string {
 }
 
 func (p *permissionDialogCmp) generateWriteContent() string {
+	if p.reviewing {
+		return p.generateReviewContent()
+	}
 	if pr, ok := p.permission.Params.(tools.WritePermissionsParams); ok {
 		// Use the cache for diff rendering
 		formatter := core.DiffFormatter().
@@ -484,6 +647,9 @@ func (p *permissionDialogCmp) generateDownloadContent() string {
 }
 
 func (p *permissionDialogCmp) generateMultiEditContent() string {
+	if p.reviewing {
+		return p.generateReviewContent()
+	}
 	if pr, ok := p.permission.Params.(tools.MultiEditPermissionsParams); ok {
 		// Use the cache for diff rendering
 		formatter := core.DiffFormatter().
@@ -505,6 +671,60 @@ func (p *permissionDialogCmp) generateMultiEditContent() string {
 	return ""
 }
 
+func (p *permissionDialogCmp) generateApplyPatchContent() string {
+	if pr, ok := p.permission.Params.(tools.ApplyPatchPermissionsParams); ok {
+		// Use the cache for diff rendering
+		formatter := core.DiffFormatter().
+			Before(fsext.PrettyPath(pr.FilePath), pr.OldContent).
+			After(fsext.PrettyPath(pr.FilePath), pr.NewContent).
+			Height(p.contentViewPort.Height()).
+			Width(p.contentViewPort.Width()).
+			XOffset(p.diffXOffset).
+			YOffset(p.diffYOffset)
+		if p.useDiffSplitMode() {
+			formatter = formatter.Split()
+		} else {
+			formatter = formatter.Unified()
+		}
+
+		diff := formatter.String()
+		return diff
+	}
+	return ""
+}
+
+func (p *permissionDialogCmp) generateTransactionContent() string {
+	pr, ok := p.permission.Params.(tools.TransactionPermissionsParams)
+	if !ok {
+		return ""
+	}
+	t := styles.CurrentTheme()
+	// The diffs of the files are stacked and scrolled as one.
+	var lines []string
+	for _, f := range pr.Files {
+		lines = append(lines, t.S().Muted.Render(fsext.PrettyPath(f.FilePath)))
+		formatter := core.DiffFormatter().
+			Before(fsext.PrettyPath(f.FilePath), f.OldContent).
+			After(fsext.PrettyPath(f.FilePath), f.NewContent).
+			Width(p.contentViewPort.Width()).
+			XOffset(p.diffXOffset)
+		if p.useDiffSplitMode() {
+			formatter = formatter.Split()
+		} else {
+			formatter = formatter.Unified()
+		}
+		lines = append(lines, strings.Split(formatter.String(), "\n")...)
+	}
+
+	height := p.contentViewPort.Height()
+	p.diffYOffset = min(p.diffYOffset, max(0, len(lines)-height))
+	lines = lines[p.diffYOffset:]
+	if height > 0 && len(lines) > height {
+		lines = lines[:height]
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (p *permissionDialogCmp) generateFetchContent() string {
 	t := styles.CurrentTheme()
 	baseStyle := t.S().Base.Background(t.BgSubtle)
@@ -636,7 +856,11 @@ func (p *permissionDialogCmp) render() string {
 
 	var contentHelp string
 	if p.supportsDiffView() {
-		contentHelp = help.New().View(p.keyMap)
+		bindings := p.keyMap.ShortHelp()
+		if p.canReview() {
+			bindings = append(bindings, p.reviewBindings()...)
+		}
+		contentHelp = help.New().ShortHelpView(bindings)
 	}
 
 	// Calculate content height dynamically based on window size
@@ -691,6 +915,12 @@ func (p *permissionDialogCmp) SetSize() tea.Cmd {
 	case tools.MultiEditToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.8)
+	case tools.ApplyPatchToolName:
+		p.width = int(float64(p.wWidth) * 0.8)
+		p.height = int(float64(p.wHeight) * 0.8)
+	case tools.TransactionToolName, tools.RenameToolName, tools.CodeActionToolName:
+		p.width = int(float64(p.wWidth) * 0.8)
+		p.height = int(float64(p.wHeight) * 0.8)
 	case tools.FetchToolName:
 		p.width = int(float64(p.wWidth) * 0.8)
 		p.height = int(float64(p.wHeight) * 0.3)
@@ -0,0 +1,113 @@
+package permissions
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/diff"
+	"github.com/charmbracelet/crush/internal/fsext"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+)
+
+// fileChange returns the file change of the request, for the tools whose
+// hunks can be accepted or rejected one by one.
+func (p *permissionDialogCmp) fileChange() (path, before, after string, ok bool) {
+	switch params := p.permission.Params.(type) {
+	case tools.EditPermissionsParams:
+		return params.FilePath, params.OldContent, params.NewContent, true
+	case tools.WritePermissionsParams:
+		return params.FilePath, params.OldContent, params.NewContent, true
+	case tools.MultiEditPermissionsParams:
+		return params.FilePath, params.OldContent, params.NewContent, true
+	}
+	return "", "", "", false
+}
+
+// canReview reports whether the change has several hunks to review, a single
+// one is allowed or denied.
+func (p *permissionDialogCmp) canReview() bool {
+	return len(p.hunks) > 1
+}
+
+// rejectedHunks returns the number of hunks the user rejected.
+func (p *permissionDialogCmp) rejectedHunks() int {
+	rejected := 0
+	for _, accepted := range p.accepted {
+		if !accepted {
+			rejected++
+		}
+	}
+	return rejected
+}
+
+// toggleReview shows the hunks one by one, or the whole change again.
+func (p *permissionDialogCmp) toggleReview() {
+	p.reviewing = !p.reviewing
+	p.diffYOffset = 0
+	p.contentDirty = true
+}
+
+func (p *permissionDialogCmp) moveHunk(delta int) {
+	p.currentHunk = (p.currentHunk + delta + len(p.hunks)) % len(p.hunks)
+	p.diffYOffset = 0
+	p.contentDirty = true
+}
+
+// toggleHunk accepts or rejects the current hunk. Changes with rejected hunks
+// can't be allowed for the session or the project.
+func (p *permissionDialogCmp) toggleHunk() {
+	p.accepted[p.currentHunk] = !p.accepted[p.currentHunk]
+	if p.rejectedHunks() > 0 && (p.selectedOption == 1 || p.selectedOption == 2) {
+		p.selectedOption = 0
+	}
+	p.confirming = false
+	p.contentDirty = true
+}
+
+// reviewedContent returns the content of the file with the accepted hunks.
+func (p *permissionDialogCmp) reviewedContent() (string, error) {
+	_, before, after, _ := p.fileChange()
+	return diff.ApplyAccepted(before, after, p.accepted)
+}
+
+// reviewBindings are the help of the hunk review.
+func (p *permissionDialogCmp) reviewBindings() []key.Binding {
+	if !p.reviewing {
+		return []key.Binding{p.keyMap.ReviewHunks}
+	}
+	return []key.Binding{p.keyMap.NextHunk, p.keyMap.PreviousHunk, p.keyMap.ToggleHunk}
+}
+
+// generateReviewContent renders the current hunk, with whether it is
+// accepted.
+func (p *permissionDialogCmp) generateReviewContent() string {
+	t := styles.CurrentTheme()
+	path, before, _, _ := p.fileChange()
+	hunk := p.hunks[p.currentHunk]
+	after, err := diff.ApplyHunks(before, []diff.Hunk{hunk})
+	if err != nil {
+		return t.S().Error.Render(fmt.Sprintf("Failed to show the hunk: %v", err))
+	}
+
+	status := t.S().Base.Foreground(t.Success).Render("accepted")
+	if !p.accepted[p.currentHunk] {
+		status = t.S().Base.Foreground(t.Error).Render("rejected")
+	}
+	header := t.S().Muted.Render(fmt.Sprintf("Hunk %d of %d, ", p.currentHunk+1, len(p.hunks))) + status
+
+	formatter := core.DiffFormatter().
+		Before(fsext.PrettyPath(path), before).
+		After(fsext.PrettyPath(path), after).
+		Height(max(1, p.contentViewPort.Height()-1)).
+		Width(p.contentViewPort.Width()).
+		XOffset(p.diffXOffset).
+		YOffset(p.diffYOffset)
+	if p.useDiffSplitMode() {
+		formatter = formatter.Split()
+	} else {
+		formatter = formatter.Unified()
+	}
+	return header + "\n" + formatter.String()
+}
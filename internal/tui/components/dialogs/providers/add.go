@@ -0,0 +1,309 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/bubbles/v2/spinner"
+	"github.com/charmbracelet/bubbles/v2/textinput"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const AddProviderDialogID dialogs.DialogID = "add_provider"
+
+const (
+	nameInput = iota
+	baseURLInput
+	apiKeyInput
+)
+
+var inputLabels = []string{"Name", "Base URL", "API key (optional, may be $ENV_VAR)"}
+
+// modelsProbedMsg is sent once the models endpoint of the provider answered.
+type modelsProbedMsg struct {
+	baseURL string
+	models  []catwalk.Model
+	err     error
+}
+
+// AddProviderDialog asks for the base URL and the API key of an
+// OpenAI-compatible provider and adds it with the models it serves.
+type AddProviderDialog interface {
+	dialogs.DialogModel
+}
+
+type addProviderDialogCmp struct {
+	width   int
+	wWidth  int // Width of the terminal window
+	wHeight int // Height of the terminal window
+
+	inputs     []textinput.Model
+	focusIndex int
+	probing    bool
+	err        error
+	spinner    spinner.Model
+	keys       KeyMap
+	help       help.Model
+}
+
+func NewAddProviderDialog() AddProviderDialog {
+	t := styles.CurrentTheme()
+	placeholders := []string{"My Server", "http://localhost:8000/v1", "$MY_SERVER_API_KEY"}
+	inputs := make([]textinput.Model, len(placeholders))
+	for i, placeholder := range placeholders {
+		ti := textinput.New()
+		ti.Placeholder = placeholder
+		ti.SetWidth(50)
+		ti.SetVirtualCursor(false)
+		ti.Prompt = ""
+		ti.SetStyles(t.S().TextInput)
+		if i == nameInput {
+			ti.Focus()
+		}
+		inputs[i] = ti
+	}
+
+	return &addProviderDialogCmp{
+		inputs: inputs,
+		spinner: spinner.New(
+			spinner.WithSpinner(spinner.Dot),
+			spinner.WithStyle(t.S().Base.Foreground(t.Green)),
+		),
+		keys:  DefaultKeyMap(),
+		width: 70,
+		help:  help.New(),
+	}
+}
+
+// Init implements AddProviderDialog.
+func (a *addProviderDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements AddProviderDialog.
+func (a *addProviderDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		a.wWidth = msg.Width
+		a.wHeight = msg.Height
+	case spinner.TickMsg:
+		if a.probing {
+			var cmd tea.Cmd
+			a.spinner, cmd = a.spinner.Update(msg)
+			return a, cmd
+		}
+	case modelsProbedMsg:
+		a.probing = false
+		if msg.err != nil {
+			a.err = msg.err
+			return a, nil
+		}
+		return a, a.addProvider(msg.baseURL, msg.models)
+	case tea.KeyPressMsg:
+		if key.Matches(msg, a.keys.Close) {
+			return a, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+		if a.probing {
+			return a, nil
+		}
+		switch {
+		case key.Matches(msg, a.keys.Confirm):
+			if a.focusIndex == len(a.inputs)-1 {
+				return a, a.probe()
+			}
+			a.focus(a.focusIndex + 1)
+		case key.Matches(msg, a.keys.Next):
+			a.focus((a.focusIndex + 1) % len(a.inputs))
+		case key.Matches(msg, a.keys.Previous):
+			a.focus((a.focusIndex - 1 + len(a.inputs)) % len(a.inputs))
+		default:
+			var cmd tea.Cmd
+			a.inputs[a.focusIndex], cmd = a.inputs[a.focusIndex].Update(msg)
+			return a, cmd
+		}
+	}
+	return a, nil
+}
+
+func (a *addProviderDialogCmp) focus(i int) {
+	a.inputs[a.focusIndex].Blur()
+	a.focusIndex = i
+	a.inputs[a.focusIndex].Focus()
+}
+
+func (a *addProviderDialogCmp) value(i int) string {
+	return strings.TrimSpace(a.inputs[i].Value())
+}
+
+// probe validates the inputs and lists the models of the provider.
+func (a *addProviderDialogCmp) probe() tea.Cmd {
+	cfg := config.Get()
+	name := a.value(nameInput)
+	id := config.CustomProviderID(name)
+	a.err = nil
+	switch {
+	case id == "":
+		a.err = fmt.Errorf("a name is required")
+	case a.value(baseURLInput) == "":
+		a.err = fmt.Errorf("a base URL is required")
+	default:
+		if _, exists := cfg.Providers.Get(id); exists {
+			a.err = fmt.Errorf("provider %s already exists", id)
+		}
+	}
+	if a.err != nil {
+		return nil
+	}
+
+	apiKey := a.value(apiKeyInput)
+	if apiKey != "" {
+		resolved, err := cfg.Resolve(apiKey)
+		if err != nil {
+			a.err = fmt.Errorf("failed to resolve API key: %w", err)
+			return nil
+		}
+		apiKey = resolved
+	}
+
+	a.probing = true
+	baseURL := a.value(baseURLInput)
+	return tea.Batch(
+		a.spinner.Tick,
+		func() tea.Msg {
+			baseURL, models, err := config.ProbeOpenAIProvider(context.Background(), baseURL, apiKey)
+			return modelsProbedMsg{baseURL: baseURL, models: models, err: err}
+		},
+	)
+}
+
+// addProvider saves the provider and opens the models dialog to pick one of
+// its models.
+func (a *addProviderDialogCmp) addProvider(baseURL string, providerModels []catwalk.Model) tea.Cmd {
+	name := a.value(nameInput)
+	providerConfig := config.ProviderConfig{
+		ID:      config.CustomProviderID(name),
+		Name:    name,
+		BaseURL: baseURL,
+		Type:    catwalk.TypeOpenAI,
+		APIKey:  a.value(apiKeyInput),
+		Models:  providerModels,
+	}
+	if err := config.Get().AddCustomProvider(providerConfig); err != nil {
+		a.err = err
+		return nil
+	}
+	return tea.Sequence(
+		util.CmdHandler(dialogs.CloseDialogMsg{}),
+		util.ReportInfo(fmt.Sprintf("Added %s with %d models", name, len(providerModels))),
+		util.CmdHandler(dialogs.OpenDialogMsg{
+			Model: models.NewModelDialogCmp(),
+		}),
+	)
+}
+
+// View implements AddProviderDialog.
+func (a *addProviderDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	title := lipgloss.NewStyle().
+		Foreground(t.Primary).
+		Bold(true).
+		Padding(0, 1).
+		Render("Add Custom Provider")
+
+	explanation := t.S().Text.
+		Padding(0, 1).
+		Render("Any OpenAI-compatible API, its models are listed from /v1/models.")
+
+	inputFields := make([]string, len(a.inputs))
+	for i, input := range a.inputs {
+		labelStyle := baseStyle.
+			Padding(1, 1, 0, 1)
+
+		if i == a.focusIndex {
+			labelStyle = labelStyle.Foreground(t.FgBase).Bold(true)
+		} else {
+			labelStyle = labelStyle.Foreground(t.FgMuted)
+		}
+
+		label := labelStyle.Render(inputLabels[i] + ":")
+
+		field := t.S().Text.
+			Padding(0, 1).
+			Render(input.View())
+
+		inputFields[i] = lipgloss.JoinVertical(lipgloss.Left, label, field)
+	}
+
+	elements := []string{title, explanation}
+	elements = append(elements, inputFields...)
+
+	switch {
+	case a.probing:
+		elements = append(elements, "", baseStyle.Padding(0, 1).Render(
+			a.spinner.View()+t.S().Muted.Render(" Fetching models..."),
+		))
+	case a.err != nil:
+		elements = append(elements, "", baseStyle.Padding(0, 1).Width(a.width-4).
+			Foreground(t.Error).
+			Render(a.err.Error()))
+	}
+
+	a.help.ShowAll = false
+	helpText := baseStyle.Padding(0, 1).Render(a.help.View(a.keys))
+	elements = append(elements, "", helpText)
+
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		elements...,
+	)
+
+	return baseStyle.Padding(1, 1, 0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(a.width).
+		Render(content)
+}
+
+func (a *addProviderDialogCmp) Cursor() *tea.Cursor {
+	if a.probing {
+		return nil
+	}
+	cursor := a.inputs[a.focusIndex].Cursor()
+	if cursor != nil {
+		cursor = a.moveCursor(cursor)
+	}
+	return cursor
+}
+
+func (a *addProviderDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := a.Position()
+	offset := row + 3 + (1+a.focusIndex)*3
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 3
+	return cursor
+}
+
+func (a *addProviderDialogCmp) Position() (int, int) {
+	row := a.wHeight / 2
+	row -= a.wHeight / 2
+	col := a.wWidth / 2
+	col -= a.width / 2
+	return row, col
+}
+
+// ID implements AddProviderDialog.
+func (a *addProviderDialogCmp) ID() dialogs.DialogID {
+	return AddProviderDialogID
+}
@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const StatsDialogID dialogs.DialogID = "stats"
+
+// StatsDialog shows the latency and throughput of the models.
+type StatsDialog interface {
+	dialogs.DialogModel
+}
+
+type statsDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	stats  []session.ModelStats
+	days   int
+	keyMap KeyMap
+}
+
+// NewStatsDialog creates a dialog showing the stats of the last days.
+func NewStatsDialog(stats []session.ModelStats, days int) StatsDialog {
+	return &statsDialogCmp{
+		stats:  stats,
+		days:   days,
+		keyMap: DefaultKeyMap(),
+	}
+}
+
+func (s *statsDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (s *statsDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		s.wWidth = msg.Width
+		s.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		if key.Matches(msg, s.keyMap.Close) {
+			return s, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return s, nil
+}
+
+func (s *statsDialogCmp) renderTable() string {
+	if len(s.stats) == 0 {
+		return fmt.Sprintf("No requests in the last %d days", s.days)
+	}
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tREQUESTS\tERRORS\tFIRST TOKEN\tTOKENS/S")
+	for _, st := range s.stats {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.1f%%\t%s\t%.1f\n", st.Provider, st.Model, st.Requests, st.ErrorRate()*100, st.AvgFirstToken.Round(time.Millisecond), st.TokensPerSecond())
+	}
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (s *statsDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	table := t.S().Text.Render(s.renderTable())
+	width := lipgloss.Width(table)
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		core.Title(fmt.Sprintf("Model Stats (last %d days)", s.days), width),
+		"",
+		table,
+		"",
+		t.S().Subtle.Render("Timings only count the successful requests."),
+	)
+
+	return baseStyle.
+		Padding(1, 2).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Render(content)
+}
+
+func (s *statsDialogCmp) Position() (int, int) {
+	view := s.View()
+	row := (s.wHeight - lipgloss.Height(view)) / 2
+	col := (s.wWidth - lipgloss.Width(view)) / 2
+	return max(row, 0), max(col, 0)
+}
+
+func (s *statsDialogCmp) ID() dialogs.DialogID {
+	return StatsDialogID
+}
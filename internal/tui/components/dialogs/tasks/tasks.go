@@ -0,0 +1,184 @@
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/v2/help"
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/scheduler"
+	"github.com/charmbracelet/crush/internal/tui/components/core"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/exp/list"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const TasksDialogID dialogs.DialogID = "tasks"
+
+// TaskSelectedMsg is sent when a task to review is chosen.
+type TaskSelectedMsg struct {
+	Task scheduler.Task
+}
+
+// TasksDialog interface for the dialog reviewing the background tasks
+type TasksDialog interface {
+	dialogs.DialogModel
+}
+
+type TasksList = list.FilterableList[list.CompletionItem[scheduler.Task]]
+
+type tasksDialogCmp struct {
+	wWidth    int
+	wHeight   int
+	width     int
+	keyMap    KeyMap
+	tasksList TasksList
+	help      help.Model
+}
+
+// NewTasksDialogCmp creates a new dialog to choose the task to review the
+// last run of.
+func NewTasksDialogCmp(tasks []scheduler.Task) TasksDialog {
+	t := styles.CurrentTheme()
+	listKeyMap := list.DefaultKeyMap()
+	keyMap := DefaultKeyMap()
+	listKeyMap.Down.SetEnabled(false)
+	listKeyMap.Up.SetEnabled(false)
+	listKeyMap.DownOneItem = keyMap.Next
+	listKeyMap.UpOneItem = keyMap.Previous
+
+	items := make([]list.CompletionItem[scheduler.Task], len(tasks))
+	for i, task := range tasks {
+		title := fmt.Sprintf("%-19s  %s", status(task), task.Name())
+		items[i] = list.NewCompletionItem(title, task, list.WithCompletionID(task.ID))
+	}
+
+	inputStyle := t.S().Base.PaddingLeft(1).PaddingBottom(1)
+	tasksList := list.NewFilterableList(
+		items,
+		list.WithFilterPlaceholder("Enter a task"),
+		list.WithFilterInputStyle(inputStyle),
+		list.WithFilterListOptions(
+			list.WithKeyMap(listKeyMap),
+			list.WithWrapNavigation(),
+		),
+	)
+	help := help.New()
+	help.Styles = t.S().Help
+	return &tasksDialogCmp{
+		keyMap:    keyMap,
+		tasksList: tasksList,
+		help:      help,
+	}
+}
+
+func (c *tasksDialogCmp) Init() tea.Cmd {
+	return tea.Sequence(c.tasksList.Init(), c.tasksList.Focus())
+}
+
+func (c *tasksDialogCmp) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.wWidth = msg.Width
+		c.wHeight = msg.Height
+		c.width = min(120, c.wWidth-8)
+		c.tasksList.SetInputWidth(c.listWidth() - 2)
+		return c, c.tasksList.SetSize(c.listWidth(), c.listHeight())
+	case tea.KeyPressMsg:
+		switch {
+		case key.Matches(msg, c.keyMap.Select):
+			selectedItem := c.tasksList.SelectedItem()
+			if selectedItem != nil {
+				selected := *selectedItem
+				return c, tea.Sequence(
+					util.CmdHandler(dialogs.CloseDialogMsg{}),
+					util.CmdHandler(TaskSelectedMsg{Task: selected.Value()}),
+				)
+			}
+		case key.Matches(msg, c.keyMap.Close):
+			return c, util.CmdHandler(dialogs.CloseDialogMsg{})
+		default:
+			u, cmd := c.tasksList.Update(msg)
+			c.tasksList = u.(TasksList)
+			return c, cmd
+		}
+	}
+	return c, nil
+}
+
+func (c *tasksDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	content := lipgloss.JoinVertical(
+		lipgloss.Left,
+		t.S().Base.Padding(0, 1, 1, 1).Render(core.Title("Background Tasks", c.width-4)),
+		c.tasksList.View(),
+		"",
+		t.S().Base.Width(c.width-2).PaddingLeft(1).AlignHorizontal(lipgloss.Left).Render(c.help.View(c.keyMap)),
+	)
+	return c.style().Render(content)
+}
+
+func (c *tasksDialogCmp) Cursor() *tea.Cursor {
+	if cursor, ok := c.tasksList.(util.Cursor); ok {
+		cursor := cursor.Cursor()
+		if cursor != nil {
+			cursor = c.moveCursor(cursor)
+		}
+		return cursor
+	}
+	return nil
+}
+
+func (c *tasksDialogCmp) style() lipgloss.Style {
+	t := styles.CurrentTheme()
+	return t.S().Base.
+		Width(c.width).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus)
+}
+
+func (c *tasksDialogCmp) listHeight() int {
+	return c.wHeight/2 - 6 // 5 for the border, title and help
+}
+
+func (c *tasksDialogCmp) listWidth() int {
+	return c.width - 2 // 2 for the border
+}
+
+func (c *tasksDialogCmp) Position() (int, int) {
+	row := c.wHeight/4 - 2 // just a bit above the center
+	col := c.wWidth / 2
+	col -= c.width / 2
+	return row, col
+}
+
+func (c *tasksDialogCmp) moveCursor(cursor *tea.Cursor) *tea.Cursor {
+	row, col := c.Position()
+	offset := row + 3 // Border + title
+	cursor.Y += offset
+	cursor.X = cursor.X + col + 2
+	return cursor
+}
+
+// ID implements TasksDialog.
+func (c *tasksDialogCmp) ID() dialogs.DialogID {
+	return TasksDialogID
+}
+
+// status describes the last run of the task.
+func status(task scheduler.Task) string {
+	switch {
+	case task.LastRun == nil && task.Queued():
+		return "queued"
+	case task.LastRun == nil:
+		return task.Spec
+	case task.Running():
+		return "running"
+	case task.LastRun.Error != "":
+		return "failed"
+	}
+	return task.LastRun.FinishedAt.Format(time.DateTime)
+}
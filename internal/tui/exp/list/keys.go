@@ -2,6 +2,7 @@ package list
 
 import (
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/tui/keymap"
 )
 
 type KeyMap struct {
@@ -19,14 +20,14 @@ type KeyMap struct {
 
 func DefaultKeyMap() KeyMap {
 	return KeyMap{
-		Down: key.NewBinding(
+		Down: keymap.Bind("down", key.NewBinding(
 			key.WithKeys("down", "ctrl+j", "ctrl+n", "j"),
 			key.WithHelp("↓", "down"),
-		),
-		Up: key.NewBinding(
+		)),
+		Up: keymap.Bind("up", key.NewBinding(
 			key.WithKeys("up", "ctrl+k", "ctrl+p", "k"),
 			key.WithHelp("↑", "up"),
-		),
+		)),
 		UpOneItem: key.NewBinding(
 			key.WithKeys("shift+up", "K"),
 			key.WithHelp("shift+↑", "up one item"),
@@ -35,30 +36,30 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("shift+down", "J"),
 			key.WithHelp("shift+↓", "down one item"),
 		),
-		HalfPageDown: key.NewBinding(
+		HalfPageDown: keymap.Bind("half_page_down", key.NewBinding(
 			key.WithKeys("d"),
 			key.WithHelp("d", "half page down"),
-		),
-		PageDown: key.NewBinding(
+		)),
+		PageDown: keymap.Bind("page_down", key.NewBinding(
 			key.WithKeys("pgdown", " ", "f"),
 			key.WithHelp("f/pgdn", "page down"),
-		),
-		PageUp: key.NewBinding(
+		)),
+		PageUp: keymap.Bind("page_up", key.NewBinding(
 			key.WithKeys("pgup", "b"),
 			key.WithHelp("b/pgup", "page up"),
-		),
-		HalfPageUp: key.NewBinding(
+		)),
+		HalfPageUp: keymap.Bind("half_page_up", key.NewBinding(
 			key.WithKeys("u"),
 			key.WithHelp("u", "half page up"),
-		),
-		Home: key.NewBinding(
+		)),
+		Home: keymap.Bind("home", key.NewBinding(
 			key.WithKeys("g", "home"),
 			key.WithHelp("g", "home"),
-		),
-		End: key.NewBinding(
+		)),
+		End: keymap.Bind("end", key.NewBinding(
 			key.WithKeys("G", "end"),
 			key.WithHelp("G", "end"),
-		),
+		)),
 	}
 }
 
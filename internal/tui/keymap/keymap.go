@@ -0,0 +1,50 @@
+// Package keymap applies the key bindings of the configuration to the ones
+// of the TUI.
+package keymap
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// vimKeys are the keys added to the actions in vim mode.
+var vimKeys = map[string][]string{
+	"half_page_down": {"ctrl+d"},
+	"half_page_up":   {"ctrl+u"},
+	"page_down":      {"ctrl+f"},
+	"page_up":        {"ctrl+b"},
+}
+
+func current() *config.Keymap {
+	cfg := config.Get()
+	if cfg == nil || cfg.Options == nil || cfg.Options.TUI == nil {
+		return nil
+	}
+	return cfg.Options.TUI.Keymap
+}
+
+// Vim reports whether the editor and the lists have the vim bindings.
+func Vim() bool {
+	keymap := current()
+	return keymap != nil && keymap.Mode == config.KeymapVim
+}
+
+// Bind returns the binding of the action with the keys of the configuration
+// in place of its default ones, if set.
+func Bind(action string, binding key.Binding) key.Binding {
+	keymap := current()
+	if keymap == nil {
+		return binding
+	}
+	if keys, ok := keymap.Bindings[action]; ok {
+		binding.SetKeys(keys...)
+		binding.SetHelp(strings.Join(keys, "/"), binding.Help().Desc)
+		return binding
+	}
+	if keys, ok := vimKeys[action]; ok && keymap.Mode == config.KeymapVim {
+		binding.SetKeys(append(binding.Keys(), keys...)...)
+	}
+	return binding
+}
@@ -0,0 +1,30 @@
+package keymap
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBind(t *testing.T) {
+	quit := key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit"))
+	halfPageDown := key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "half page down"))
+
+	t.Cleanup(func() { config.Set(nil) })
+	config.Set(nil)
+	require.Equal(t, []string{"ctrl+c"}, Bind("quit", quit).Keys())
+	require.False(t, Vim())
+
+	config.Set(&config.Config{Options: &config.Options{TUI: &config.TUIOptions{Keymap: &config.Keymap{
+		Mode:     config.KeymapVim,
+		Bindings: map[string][]string{"quit": {"ctrl+q", "ctrl+c"}},
+	}}}})
+	require.True(t, Vim())
+	bound := Bind("quit", quit)
+	require.Equal(t, []string{"ctrl+q", "ctrl+c"}, bound.Keys())
+	require.Equal(t, key.Help{Key: "ctrl+q/ctrl+c", Desc: "quit"}, bound.Help())
+	require.Equal(t, []string{"d", "ctrl+d"}, Bind("half_page_down", halfPageDown).Keys())
+	require.Equal(t, []string{"ctrl+c"}, quit.Keys(), "the default binding is left as is")
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/tui/keymap"
 )
 
 type KeyMap struct {
@@ -16,25 +17,25 @@ type KeyMap struct {
 
 func DefaultKeyMap() KeyMap {
 	return KeyMap{
-		Quit: key.NewBinding(
+		Quit: keymap.Bind("quit", key.NewBinding(
 			key.WithKeys("ctrl+c"),
 			key.WithHelp("ctrl+c", "quit"),
-		),
-		Help: key.NewBinding(
+		)),
+		Help: keymap.Bind("help", key.NewBinding(
 			key.WithKeys("ctrl+g"),
 			key.WithHelp("ctrl+g", "more"),
-		),
-		Commands: key.NewBinding(
+		)),
+		Commands: keymap.Bind("commands", key.NewBinding(
 			key.WithKeys("ctrl+p"),
 			key.WithHelp("ctrl+p", "commands"),
-		),
-		Suspend: key.NewBinding(
+		)),
+		Suspend: keymap.Bind("suspend", key.NewBinding(
 			key.WithKeys("ctrl+z"),
 			key.WithHelp("ctrl+z", "suspend"),
-		),
-		Sessions: key.NewBinding(
+		)),
+		Sessions: keymap.Bind("sessions", key.NewBinding(
 			key.WithKeys("ctrl+s"),
 			key.WithHelp("ctrl+s", "sessions"),
-		),
+		)),
 	}
 }
@@ -0,0 +1,229 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// terminalState is the state of the agent as shown in the terminal title and
+// progress indicator.
+type terminalState int
+
+const (
+	terminalIdle terminalState = iota
+	terminalBusy
+	// terminalAttention means a turn finished or a permission is requested
+	// while the user was looking elsewhere.
+	terminalAttention
+)
+
+// terminal describes the escape sequences the terminal crush runs in
+// understands.
+type terminal struct {
+	// tmux needs sequences it doesn't know wrapped in a passthrough, and
+	// flags windows that ring the bell.
+	tmux bool
+	// kitty has its own desktop notification protocol, OSC 99.
+	kitty bool
+	// osc9 terminals show desktop notifications with OSC 9, e.g. iTerm2,
+	// WezTerm, and Ghostty.
+	osc9 bool
+	// osc777 terminals show desktop notifications with OSC 777, e.g. foot
+	// and rxvt-unicode.
+	osc777 bool
+	// progress terminals show a progress indicator with OSC 9;4, e.g.
+	// WezTerm, Ghostty, Windows Terminal, and ConEmu.
+	progress bool
+}
+
+func detectTerminal(getenv func(string) string) terminal {
+	var t terminal
+	t.tmux = getenv("TMUX") != ""
+	// Inside tmux TERM_PROGRAM is tmux, but the variables terminals set for
+	// their own panes are usually inherited.
+	program := getenv("TERM_PROGRAM")
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "" || getenv("TERM") == "xterm-kitty":
+		t.kitty = true
+	case getenv("WEZTERM_PANE") != "" || program == "WezTerm":
+		t.osc9, t.progress = true, true
+	case getenv("GHOSTTY_RESOURCES_DIR") != "" || program == "ghostty":
+		t.osc9, t.progress = true, true
+	case program == "iTerm.app" || getenv("LC_TERMINAL") == "iTerm2":
+		t.osc9 = true
+	case getenv("WT_SESSION") != "" || getenv("ConEmuPID") != "":
+		t.progress = true
+	case strings.HasPrefix(getenv("TERM"), "foot") || strings.HasPrefix(getenv("TERM"), "rxvt-unicode"):
+		t.osc777 = true
+	}
+	return t
+}
+
+// withDesktop returns the terminal sending desktop notifications with the
+// protocol of the config, the detected one with auto.
+func (t terminal) withDesktop(desktop string) terminal {
+	if desktop == "" || desktop == config.NotificationsAuto {
+		return t
+	}
+	t.kitty = desktop == config.NotificationsKitty
+	t.osc9 = desktop == config.NotificationsOSC9
+	t.osc777 = desktop == config.NotificationsOSC777
+	return t
+}
+
+// terminalNotifier tells the terminal what the agent is doing, so users
+// juggling several panes can see at a glance which crush needs attention.
+type terminalNotifier struct {
+	terminal terminal
+	disabled bool
+	// bell rings the bell with the notifications.
+	bell  bool
+	title string
+	state terminalState
+	// focused is only known when the terminal reports focus changes, until
+	// then notifications are always sent.
+	focused bool
+	// failure is why the current turn failed, if it did.
+	failure string
+}
+
+func newTerminalNotifier(workingDir string, disabled bool, notifications *config.Notifications) *terminalNotifier {
+	n := &terminalNotifier{
+		terminal: detectTerminal(os.Getenv),
+		disabled: disabled,
+		title:    "crush " + filepath.Base(workingDir),
+	}
+	if notifications != nil {
+		n.terminal = n.terminal.withDesktop(notifications.Desktop)
+		n.bell = notifications.Bell
+	}
+	return n
+}
+
+// WindowTitle returns the terminal title for the current state.
+func (n *terminalNotifier) WindowTitle() string {
+	switch n.state {
+	case terminalBusy:
+		return "● " + n.title
+	case terminalAttention:
+		return "✔ " + n.title
+	default:
+		return n.title
+	}
+}
+
+// SetFocused records whether the terminal has focus. Focusing the terminal
+// acknowledges a finished turn.
+func (n *terminalNotifier) SetFocused(focused bool) {
+	n.focused = focused
+	if focused && n.state == terminalAttention {
+		n.state = terminalIdle
+	}
+}
+
+// Acknowledge clears the attention state after the user interacted with crush.
+func (n *terminalNotifier) Acknowledge() {
+	if n.state == terminalAttention {
+		n.state = terminalIdle
+	}
+}
+
+// Failed records why the current turn failed, for the notification sent when
+// it ends.
+func (n *terminalNotifier) Failed(reason string) {
+	n.failure = reason
+}
+
+// SetBusy updates the progress indicator when the agent starts or stops
+// working, and notifies the user when a turn completed or failed.
+func (n *terminalNotifier) SetBusy(busy bool) tea.Cmd {
+	switch {
+	case busy && n.state != terminalBusy:
+		n.state = terminalBusy
+		n.failure = ""
+		return n.progress(true)
+	case !busy && n.state == terminalBusy:
+		n.state = terminalIdle
+		if n.focused {
+			return n.progress(false)
+		}
+		n.state = terminalAttention
+		body := "Agent finished"
+		if n.failure != "" {
+			body = "Agent failed: " + n.failure
+		}
+		return tea.Batch(n.progress(false), n.notify("Crush", body))
+	}
+	return nil
+}
+
+// Notify sends a notification if the user isn't looking at crush.
+func (n *terminalNotifier) Notify(body string) tea.Cmd {
+	if n.focused {
+		return nil
+	}
+	return n.notify("Crush", body)
+}
+
+func (n *terminalNotifier) notify(title, body string) tea.Cmd {
+	if n.disabled {
+		return nil
+	}
+	title, body = sanitizeNotification(title), sanitizeNotification(body)
+	var seq string
+	switch {
+	case n.terminal.kitty:
+		seq = "\x1b]99;i=crush:d=0:o=unfocused;" + title + "\x1b\\" +
+			"\x1b]99;i=crush:p=body;" + body + "\x1b\\"
+	case n.terminal.osc9:
+		seq = ansi.Notify(title + ": " + body)
+	case n.terminal.osc777:
+		seq = "\x1b]777;notify;" + strings.ReplaceAll(title, ";", ",") + ";" + body + "\x1b\\"
+	}
+	if n.terminal.tmux {
+		// The bell flags the window in tmux's status line.
+		return tea.Raw(n.passthrough(seq) + "\a")
+	}
+	if n.bell {
+		seq += "\a"
+	}
+	if seq == "" {
+		return nil
+	}
+	return tea.Raw(seq)
+}
+
+func (n *terminalNotifier) progress(busy bool) tea.Cmd {
+	if n.disabled || !n.terminal.progress {
+		return nil
+	}
+	// 3 is an indeterminate progress, 0 removes it.
+	seq := "\x1b]9;4;0\x07"
+	if busy {
+		seq = "\x1b]9;4;3\x07"
+	}
+	return tea.Raw(n.passthrough(seq))
+}
+
+func (n *terminalNotifier) passthrough(seq string) string {
+	if !n.terminal.tmux || seq == "" {
+		return seq
+	}
+	return ansi.TmuxPassthrough(seq)
+}
+
+// sanitizeNotification keeps a notification body from breaking out of the
+// escape sequence it's sent in.
+func sanitizeNotification(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,90 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectTerminal(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		env  map[string]string
+		want terminal
+	}{
+		"unknown":          {env: map[string]string{"TERM": "xterm-256color"}, want: terminal{}},
+		"kitty":            {env: map[string]string{"TERM": "xterm-kitty"}, want: terminal{kitty: true}},
+		"wezterm in tmux":  {env: map[string]string{"TMUX": "/tmp/tmux-1000/default,1,0", "TERM_PROGRAM": "tmux", "WEZTERM_PANE": "3"}, want: terminal{tmux: true, osc9: true, progress: true}},
+		"iterm":            {env: map[string]string{"TERM_PROGRAM": "iTerm.app"}, want: terminal{osc9: true}},
+		"windows terminal": {env: map[string]string{"WT_SESSION": "f00"}, want: terminal{progress: true}},
+		"foot":             {env: map[string]string{"TERM": "foot"}, want: terminal{osc777: true}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got := detectTerminal(func(key string) string { return tt.env[key] })
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTerminalNotifier(t *testing.T) {
+	t.Parallel()
+
+	n := &terminalNotifier{terminal: terminal{osc9: true, progress: true}, title: "crush module"}
+	require.Equal(t, "crush module", n.WindowTitle())
+
+	require.Equal(t, tea.RawMsg{Msg: "\x1b]9;4;3\x07"}, n.SetBusy(true)())
+	require.Nil(t, n.SetBusy(true))
+	require.Equal(t, "● crush module", n.WindowTitle())
+
+	// Not focused: the turn completing needs attention.
+	require.NotNil(t, n.SetBusy(false))
+	require.Equal(t, "✔ crush module", n.WindowTitle())
+	n.SetFocused(true)
+	require.Equal(t, "crush module", n.WindowTitle())
+
+	// Focused: only the progress is cleared.
+	n.SetBusy(true)
+	require.Equal(t, tea.RawMsg{Msg: "\x1b]9;4;0\x07"}, n.SetBusy(false)())
+	require.Nil(t, n.Notify("Permission required for bash"))
+	require.Equal(t, "crush module", n.WindowTitle())
+}
+
+func TestTerminalNotifier_Tmux(t *testing.T) {
+	t.Parallel()
+
+	n := &terminalNotifier{terminal: terminal{tmux: true, osc9: true}}
+	msg := n.Notify("done\x1b]0;pwned\x07")()
+	require.Equal(t, tea.RawMsg{Msg: "\x1bPtmux;\x1b\x1b]9;Crush: done ]0;pwned \x07\x1b\\\a"}, msg)
+}
+
+func TestTerminalNotifier_Config(t *testing.T) {
+	t.Parallel()
+
+	n := &terminalNotifier{terminal: terminal{osc9: true}.withDesktop(config.NotificationsOSC777), bell: true}
+	require.Equal(t, tea.RawMsg{Msg: "\x1b]777;notify;Crush;Permission required\x1b\\\a"}, n.Notify("Permission required")())
+
+	n = &terminalNotifier{terminal: terminal{kitty: true}.withDesktop(config.NotificationsOff)}
+	require.Nil(t, n.Notify("Permission required"))
+	n.bell = true
+	require.Equal(t, tea.RawMsg{Msg: "\a"}, n.Notify("Permission required")())
+
+	require.Equal(t, terminal{osc9: true}, terminal{osc9: true}.withDesktop(config.NotificationsAuto))
+}
+
+func TestTerminalNotifier_Failed(t *testing.T) {
+	t.Parallel()
+
+	n := &terminalNotifier{terminal: terminal{osc9: true}}
+	n.SetBusy(true)
+	n.Failed("overloaded")
+	require.Equal(t, tea.RawMsg{Msg: "\x1b]9;Crush: Agent failed: overloaded\a"}, n.SetBusy(false)())
+
+	// The next turn starts without the failure.
+	n.SetBusy(true)
+	require.Equal(t, tea.RawMsg{Msg: "\x1b]9;Crush: Agent finished\a"}, n.SetBusy(false)())
+}
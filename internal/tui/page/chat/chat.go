@@ -11,6 +11,8 @@ import (
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -19,6 +21,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/editor"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/header"
+	"github.com/charmbracelet/crush/internal/tui/components/chat/live"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/sidebar"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/splash"
 	"github.com/charmbracelet/crush/internal/tui/components/completions"
@@ -27,6 +30,7 @@ import (
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
+	"github.com/charmbracelet/crush/internal/tui/keymap"
 	"github.com/charmbracelet/crush/internal/tui/page"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
@@ -92,6 +96,9 @@ type chatPage struct {
 	compact      bool
 	forceCompact bool
 	focusedPane  PanelType
+	// liveWidth is the width of the live panel next to the messages, zero
+	// when it is hidden.
+	liveWidth int
 
 	// Session
 	session session.Session
@@ -103,6 +110,7 @@ type chatPage struct {
 	chat    chat.MessageListCmp
 	editor  editor.Editor
 	splash  splash.Splash
+	live    live.Panel
 
 	// Simple state flags
 	showingDetails   bool
@@ -110,6 +118,8 @@ type chatPage struct {
 	splashFullScreen bool
 	isOnboarding     bool
 	isProjectInit    bool
+	showLive         bool
+	liveZoomed       bool
 }
 
 func New(app *app.App) ChatPage {
@@ -121,6 +131,7 @@ func New(app *app.App) ChatPage {
 		chat:        chat.New(app),
 		editor:      editor.New(app),
 		splash:      splash.New(),
+		live:        live.New(app.LSPClients),
 		focusedPane: PanelTypeSplash,
 	}
 }
@@ -131,6 +142,7 @@ func (p *chatPage) Init() tea.Cmd {
 	p.compact = compact
 	p.forceCompact = compact
 	p.sidebar.SetCompactMode(p.compact)
+	p.showLive = cfg.Options.TUI.LivePanel
 
 	// Set splash state based on config
 	if !config.HasInitialDataConfig() {
@@ -155,6 +167,7 @@ func (p *chatPage) Init() tea.Cmd {
 		p.chat.Init(),
 		p.editor.Init(),
 		p.splash.Init(),
+		p.live.Init(),
 	)
 }
 
@@ -204,6 +217,8 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return p, tea.Batch(p.SetSize(p.width, p.height), cmd)
 	case commands.ToggleThinkingMsg:
 		return p, p.toggleThinking()
+	case commands.SetThinkingDisplayMsg:
+		return p, tea.Batch(p.chat.SetThinkingDisplay(msg.Display), p.updateThinkingDisplayConfig(msg.Display))
 	case commands.OpenExternalEditorMsg:
 		u, cmd := p.editor.Update(msg)
 		p.editor = u.(editor.Editor)
@@ -245,6 +260,11 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case pubsub.Event[message.Message],
 		anim.StepMsg,
 		spinner.TickMsg:
+		if _, ok := msg.(pubsub.Event[message.Message]); ok {
+			u, cmd := p.live.Update(msg)
+			p.live = u.(live.Panel)
+			cmds = append(cmds, cmd)
+		}
 		if p.focusedPane == PanelTypeSplash {
 			u, cmd := p.splash.Update(msg)
 			p.splash = u.(splash.Splash)
@@ -257,17 +277,29 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return p, tea.Batch(cmds...)
 
-	case pubsub.Event[history.File], sidebar.SessionFilesMsg:
+	case pubsub.Event[history.File], pubsub.Event[agent.MCPState], sidebar.SessionFilesMsg:
 		u, cmd := p.sidebar.Update(msg)
 		p.sidebar = u.(sidebar.Sidebar)
 		cmds = append(cmds, cmd)
 		return p, tea.Batch(cmds...)
-	case pubsub.Event[permission.PermissionNotification]:
+	case pubsub.Event[permission.PermissionNotification],
+		pubsub.Event[tools.Progress]:
 		u, cmd := p.chat.Update(msg)
 		p.chat = u.(chat.MessageListCmp)
 		cmds = append(cmds, cmd)
+		u, cmd = p.live.Update(msg)
+		p.live = u.(live.Panel)
+		cmds = append(cmds, cmd)
 		return p, tea.Batch(cmds...)
 
+	case commands.RerunInterruptedMsg:
+		if p.app.CoderAgent.IsBusy() {
+			return p, util.ReportWarn("Agent is busy, please wait before re-running the turn...")
+		}
+		if _, err := p.app.CoderAgent.RerunInterrupted(context.Background(), msg.SessionID); err != nil {
+			return p, util.ReportError(err)
+		}
+		return p, p.chat.GoToBottom()
 	case commands.CommandRunCustomMsg:
 		if p.app.CoderAgent.IsBusy() {
 			return p, util.ReportWarn("Agent is busy, please wait before executing a command...")
@@ -331,6 +363,10 @@ func (p *chatPage) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, p.keyMap.Details):
 			p.toggleDetails()
 			return p, nil
+		case key.Matches(msg, p.keyMap.ToggleLive):
+			return p, p.toggleLive()
+		case key.Matches(msg, p.keyMap.ZoomLive):
+			return p, p.zoomLive()
 		}
 
 		switch p.focusedPane {
@@ -403,6 +439,12 @@ func (p *chatPage) View() string {
 		}
 	} else {
 		messagesView := p.chat.View()
+		switch {
+		case p.showLive && p.liveZoomed:
+			messagesView = p.live.View()
+		case p.showLive:
+			messagesView = lipgloss.JoinHorizontal(lipgloss.Top, messagesView, p.live.View())
+		}
 		editorView := p.editor.View()
 		if p.compact {
 			headerView := p.header.View()
@@ -465,6 +507,19 @@ func (p *chatPage) updateCompactConfig(compact bool) tea.Cmd {
 	}
 }
 
+func (p *chatPage) updateThinkingDisplayConfig(display string) tea.Cmd {
+	return func() tea.Msg {
+		err := config.Get().SetThinkingDisplay(display)
+		if err != nil {
+			return util.InfoMsg{
+				Type: util.InfoTypeError,
+				Msg:  "Failed to update thinking display configuration: " + err.Error(),
+			}
+		}
+		return nil
+	}
+}
+
 func (p *chatPage) toggleThinking() tea.Cmd {
 	return func() tea.Msg {
 		cfg := config.Get()
@@ -533,14 +588,30 @@ func (p *chatPage) SetSize(width, height int) tea.Cmd {
 			cmds = append(cmds, p.editor.SetPosition(0, height-EditorHeight))
 		}
 	} else {
+		messagesWidth, messagesHeight := width-SideBarWidth, height-EditorHeight
+		if p.compact {
+			messagesWidth, messagesHeight = width, height-EditorHeight-HeaderHeight
+		}
+		p.liveWidth = 0
+		if p.showLive {
+			p.liveWidth = messagesWidth * 2 / 5
+			if p.liveZoomed {
+				p.liveWidth = messagesWidth
+			}
+			cmds = append(cmds, p.live.SetSize(p.liveWidth, messagesHeight))
+		}
+		chatWidth := messagesWidth - p.liveWidth
+		if p.liveZoomed {
+			chatWidth = messagesWidth
+		}
 		if p.compact {
-			cmds = append(cmds, p.chat.SetSize(width, height-EditorHeight-HeaderHeight))
+			cmds = append(cmds, p.chat.SetSize(chatWidth, messagesHeight))
 			p.detailsWidth = width - DetailsPositioning
 			cmds = append(cmds, p.sidebar.SetSize(p.detailsWidth-LeftRightBorders, p.detailsHeight-TopBottomBorders))
 			cmds = append(cmds, p.editor.SetSize(width, EditorHeight))
 			cmds = append(cmds, p.header.SetWidth(width-BorderWidth))
 		} else {
-			cmds = append(cmds, p.chat.SetSize(width-SideBarWidth, height-EditorHeight))
+			cmds = append(cmds, p.chat.SetSize(chatWidth, messagesHeight))
 			cmds = append(cmds, p.editor.SetSize(width, EditorHeight))
 			cmds = append(cmds, p.sidebar.SetSize(SideBarWidth, height-EditorHeight))
 		}
@@ -560,6 +631,7 @@ func (p *chatPage) newSession() tea.Cmd {
 	p.chat.Blur()
 	p.isCanceling = false
 	return tea.Batch(
+		p.live.SetSession(p.session),
 		util.CmdHandler(chat.SessionClearedMsg{}),
 		p.SetSize(p.width, p.height),
 	)
@@ -578,6 +650,12 @@ func (p *chatPage) setSession(session session.Session) tea.Cmd {
 	cmds = append(cmds, p.sidebar.SetSession(session))
 	cmds = append(cmds, p.header.SetSession(session))
 	cmds = append(cmds, p.editor.SetSession(session))
+	cmds = append(cmds, p.live.SetSession(session))
+	if p.app.CoderAgent != nil && !p.app.CoderAgent.IsSessionBusy(session.ID) {
+		if _, ok := agent.FindInterruptedTurn(config.Get().Options.DataDirectory, session.ID); ok {
+			cmds = append(cmds, util.ReportInfo("The last turn of this session was interrupted, re-run it from the commands (ctrl+p)"))
+		}
+	}
 
 	return tea.Sequence(cmds...)
 }
@@ -624,6 +702,27 @@ func (p *chatPage) toggleDetails() {
 	p.setShowDetails(!p.showingDetails)
 }
 
+// toggleLive shows or hides the live panel next to the messages.
+func (p *chatPage) toggleLive() tea.Cmd {
+	if p.session.ID == "" {
+		return nil
+	}
+	p.showLive = !p.showLive
+	p.liveZoomed = false
+	return p.SetSize(p.width, p.height)
+}
+
+// zoomLive shows the live panel in place of the messages, or next to them
+// again.
+func (p *chatPage) zoomLive() tea.Cmd {
+	if p.session.ID == "" {
+		return nil
+	}
+	p.liveZoomed = !p.liveZoomed || !p.showLive
+	p.showLive = true
+	return p.SetSize(p.width, p.height)
+}
+
 func (p *chatPage) sendMessage(text string, attachments []message.Attachment) tea.Cmd {
 	session := p.session
 	var cmds []tea.Cmd
@@ -770,15 +869,15 @@ func (p *chatPage) Help() help.KeyMap {
 			return core.NewSimpleHelp(shortList, fullList)
 		}
 		if p.app.CoderAgent != nil && p.app.CoderAgent.IsBusy() {
-			cancelBinding := key.NewBinding(
+			cancelBinding := keymap.Bind("cancel", key.NewBinding(
 				key.WithKeys("esc"),
 				key.WithHelp("esc", "cancel"),
-			)
+			))
 			if p.isCanceling {
-				cancelBinding = key.NewBinding(
+				cancelBinding = keymap.Bind("cancel", key.NewBinding(
 					key.WithKeys("esc"),
 					key.WithHelp("esc", "press again to cancel"),
-				)
+				))
 			}
 			shortList = append(shortList, cancelBinding)
 			fullList = append(fullList,
@@ -790,46 +889,49 @@ func (p *chatPage) Help() help.KeyMap {
 		globalBindings := []key.Binding{}
 		// we are in a session
 		if p.session.ID != "" {
-			tabKey := key.NewBinding(
+			tabKey := keymap.Bind("change_focus", key.NewBinding(
 				key.WithKeys("tab"),
 				key.WithHelp("tab", "focus chat"),
-			)
+			))
 			if p.focusedPane == PanelTypeChat {
-				tabKey = key.NewBinding(
+				tabKey = keymap.Bind("change_focus", key.NewBinding(
 					key.WithKeys("tab"),
 					key.WithHelp("tab", "focus editor"),
-				)
+				))
 			}
 			shortList = append(shortList, tabKey)
 			globalBindings = append(globalBindings, tabKey)
 		}
-		commandsBinding := key.NewBinding(
+		commandsBinding := keymap.Bind("commands", key.NewBinding(
 			key.WithKeys("ctrl+p"),
 			key.WithHelp("ctrl+p", "commands"),
-		)
-		helpBinding := key.NewBinding(
+		))
+		helpBinding := keymap.Bind("help", key.NewBinding(
 			key.WithKeys("ctrl+g"),
 			key.WithHelp("ctrl+g", "more"),
-		)
+		))
 		globalBindings = append(globalBindings, commandsBinding)
 		globalBindings = append(globalBindings,
-			key.NewBinding(
+			keymap.Bind("sessions", key.NewBinding(
 				key.WithKeys("ctrl+s"),
 				key.WithHelp("ctrl+s", "sessions"),
-			),
+			)),
 		)
 		if p.session.ID != "" {
 			globalBindings = append(globalBindings,
-				key.NewBinding(
+				keymap.Bind("new_session", key.NewBinding(
 					key.WithKeys("ctrl+n"),
 					key.WithHelp("ctrl+n", "new sessions"),
-				))
+				)))
 		}
 		shortList = append(shortList,
 			// Commands
 			commandsBinding,
 		)
 		fullList = append(fullList, globalBindings)
+		if p.session.ID != "" {
+			fullList = append(fullList, []key.Binding{p.keyMap.ToggleLive, p.keyMap.ZoomLive})
+		}
 
 		switch p.focusedPane {
 		case PanelTypeChat:
@@ -842,6 +944,14 @@ func (p *chatPage) Help() help.KeyMap {
 					key.WithKeys("c", "y"),
 					key.WithHelp("c/y", "copy"),
 				),
+				key.NewBinding(
+					key.WithKeys("x"),
+					key.WithHelp("x", "copy code"),
+				),
+				key.NewBinding(
+					key.WithKeys("t"),
+					key.WithHelp("t", "thinking"),
+				),
 			)
 			fullList = append(fullList,
 				[]key.Binding{
@@ -853,32 +963,32 @@ func (p *chatPage) Help() help.KeyMap {
 						key.WithKeys("shift+up", "shift+down"),
 						key.WithHelp("shift+↑↓", "next/prev item"),
 					),
-					key.NewBinding(
+					keymap.Bind("page_up", key.NewBinding(
 						key.WithKeys("pgup", "b"),
 						key.WithHelp("b/pgup", "page up"),
-					),
-					key.NewBinding(
+					)),
+					keymap.Bind("page_down", key.NewBinding(
 						key.WithKeys("pgdown", " ", "f"),
 						key.WithHelp("f/pgdn", "page down"),
-					),
+					)),
 				},
 				[]key.Binding{
-					key.NewBinding(
+					keymap.Bind("half_page_up", key.NewBinding(
 						key.WithKeys("u"),
 						key.WithHelp("u", "half page up"),
-					),
-					key.NewBinding(
+					)),
+					keymap.Bind("half_page_down", key.NewBinding(
 						key.WithKeys("d"),
 						key.WithHelp("d", "half page down"),
-					),
-					key.NewBinding(
+					)),
+					keymap.Bind("home", key.NewBinding(
 						key.WithKeys("g", "home"),
 						key.WithHelp("g", "home"),
-					),
-					key.NewBinding(
+					)),
+					keymap.Bind("end", key.NewBinding(
 						key.WithKeys("G", "end"),
 						key.WithHelp("G", "end"),
-					),
+					)),
 				},
 			)
 		case PanelTypeEditor:
@@ -892,23 +1002,36 @@ func (p *chatPage) Help() help.KeyMap {
 			if p.keyboardEnhancements.SupportsKeyDisambiguation() {
 				newLineBinding.SetHelp("shift+enter", newLineBinding.Help().Desc)
 			}
+			newLineBinding = keymap.Bind("newline", newLineBinding)
 			shortList = append(shortList, newLineBinding)
 			fullList = append(fullList,
 				[]key.Binding{
 					newLineBinding,
-					key.NewBinding(
+					keymap.Bind("add_attachment", key.NewBinding(
 						key.WithKeys("ctrl+f"),
 						key.WithHelp("ctrl+f", "add image"),
-					),
+					)),
 					key.NewBinding(
 						key.WithKeys("/"),
 						key.WithHelp("/", "add file"),
 					),
-					key.NewBinding(
+					keymap.Bind("open_editor", key.NewBinding(
 						key.WithKeys("ctrl+o"),
 						key.WithHelp("ctrl+o", "open editor"),
+					)),
+				})
+			if keymap.Vim() {
+				fullList = append(fullList, []key.Binding{
+					key.NewBinding(
+						key.WithKeys("esc"),
+						key.WithHelp("esc", "normal mode"),
+					),
+					key.NewBinding(
+						key.WithKeys("i", "a"),
+						key.WithHelp("i/a", "insert mode"),
 					),
 				})
+			}
 
 			if p.editor.HasAttachments() {
 				fullList = append(fullList, []key.Binding{
@@ -929,18 +1052,18 @@ func (p *chatPage) Help() help.KeyMap {
 		}
 		shortList = append(shortList,
 			// Quit
-			key.NewBinding(
+			keymap.Bind("quit", key.NewBinding(
 				key.WithKeys("ctrl+c"),
 				key.WithHelp("ctrl+c", "quit"),
-			),
+			)),
 			// Help
 			helpBinding,
 		)
 		fullList = append(fullList, []key.Binding{
-			key.NewBinding(
+			keymap.Bind("help", key.NewBinding(
 				key.WithKeys("ctrl+g"),
 				key.WithHelp("ctrl+g", "less"),
-			),
+			)),
 		})
 	}
 
@@ -974,6 +1097,11 @@ func (p *chatPage) isMouseOverChat(x, y int) bool {
 		chatWidth = p.width - SideBarWidth
 		chatHeight = p.height - EditorHeight
 	}
+	// The live panel is on the right of the messages, or in their place.
+	if p.liveZoomed {
+		return false
+	}
+	chatWidth -= p.liveWidth
 
 	// Check if mouse coordinates are within chat bounds
 	return x >= chatX && x < chatX+chatWidth && y >= chatY && y < chatY+chatHeight
@@ -2,6 +2,7 @@ package chat
 
 import (
 	"github.com/charmbracelet/bubbles/v2/key"
+	"github.com/charmbracelet/crush/internal/tui/keymap"
 )
 
 type KeyMap struct {
@@ -10,29 +11,39 @@ type KeyMap struct {
 	Cancel        key.Binding
 	Tab           key.Binding
 	Details       key.Binding
+	ToggleLive    key.Binding
+	ZoomLive      key.Binding
 }
 
 func DefaultKeyMap() KeyMap {
 	return KeyMap{
-		NewSession: key.NewBinding(
+		NewSession: keymap.Bind("new_session", key.NewBinding(
 			key.WithKeys("ctrl+n"),
 			key.WithHelp("ctrl+n", "new session"),
-		),
-		AddAttachment: key.NewBinding(
+		)),
+		AddAttachment: keymap.Bind("add_attachment", key.NewBinding(
 			key.WithKeys("ctrl+f"),
 			key.WithHelp("ctrl+f", "add attachment"),
-		),
-		Cancel: key.NewBinding(
+		)),
+		Cancel: keymap.Bind("cancel", key.NewBinding(
 			key.WithKeys("esc"),
 			key.WithHelp("esc", "cancel"),
-		),
-		Tab: key.NewBinding(
+		)),
+		Tab: keymap.Bind("change_focus", key.NewBinding(
 			key.WithKeys("tab"),
 			key.WithHelp("tab", "change focus"),
-		),
-		Details: key.NewBinding(
+		)),
+		Details: keymap.Bind("toggle_details", key.NewBinding(
 			key.WithKeys("ctrl+d"),
 			key.WithHelp("ctrl+d", "toggle details"),
-		),
+		)),
+		ToggleLive: keymap.Bind("toggle_live_panel", key.NewBinding(
+			key.WithKeys("ctrl+l"),
+			key.WithHelp("ctrl+l", "live panel"),
+		)),
+		ZoomLive: keymap.Bind("zoom_live_panel", key.NewBinding(
+			key.WithKeys("ctrl+x"),
+			key.WithHelp("ctrl+x", "zoom live panel"),
+		)),
 	}
 }
@@ -0,0 +1,175 @@
+package styles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+// themeFile is a theme defined by the user, in JSON, TOML or YAML. The colors
+// it doesn't set are the ones of the theme it extends.
+type themeFile struct {
+	Name string `json:"name"`
+	// Extends is the name of the theme to start from, crush or crush-light
+	// by default.
+	Extends string            `json:"extends"`
+	Dark    *bool             `json:"dark"`
+	Colors  map[string]string `json:"colors"`
+}
+
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// colors returns the colors of the theme by their name in theme files.
+func (t *Theme) colors() map[string]*color.Color {
+	return map[string]*color.Color{
+		"primary":             &t.Primary,
+		"secondary":           &t.Secondary,
+		"tertiary":            &t.Tertiary,
+		"accent":              &t.Accent,
+		"bg_base":             &t.BgBase,
+		"bg_base_lighter":     &t.BgBaseLighter,
+		"bg_subtle":           &t.BgSubtle,
+		"bg_overlay":          &t.BgOverlay,
+		"fg_base":             &t.FgBase,
+		"fg_muted":            &t.FgMuted,
+		"fg_half_muted":       &t.FgHalfMuted,
+		"fg_subtle":           &t.FgSubtle,
+		"fg_selected":         &t.FgSelected,
+		"border":              &t.Border,
+		"border_focus":        &t.BorderFocus,
+		"success":             &t.Success,
+		"error":               &t.Error,
+		"warning":             &t.Warning,
+		"info":                &t.Info,
+		"white":               &t.White,
+		"blue_light":          &t.BlueLight,
+		"blue":                &t.Blue,
+		"yellow":              &t.Yellow,
+		"green":               &t.Green,
+		"green_dark":          &t.GreenDark,
+		"green_light":         &t.GreenLight,
+		"red":                 &t.Red,
+		"red_dark":            &t.RedDark,
+		"red_light":           &t.RedLight,
+		"cherry":              &t.Cherry,
+		"diff_insert":         &t.DiffInsert,
+		"diff_insert_bg":      &t.DiffInsertBg,
+		"diff_insert_code_bg": &t.DiffInsertCodeBg,
+		"diff_delete":         &t.DiffDelete,
+		"diff_delete_bg":      &t.DiffDeleteBg,
+		"diff_delete_code_bg": &t.DiffDeleteCodeBg,
+	}
+}
+
+// parseColor parses a color of a theme file, a hex color or the number of an
+// ANSI color.
+func parseColor(value string) (color.Color, error) {
+	if hexColor.MatchString(value) {
+		return lipgloss.Color(value), nil
+	}
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 && n <= 255 {
+		return lipgloss.Color(value), nil
+	}
+	return nil, fmt.Errorf("invalid color %q, expected #rrggbb or an ANSI color from 0 to 255", value)
+}
+
+// ParseThemeFile parses a theme file, extending one of the themes of the
+// manager.
+func (m *Manager) ParseThemeFile(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format, err := config.FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if format != config.FormatJSON {
+		if data, err = config.ConvertConfig(data, format, config.FormatJSON); err != nil {
+			return nil, err
+		}
+	}
+	var file themeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Name == "" {
+		file.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if file.Extends == "" {
+		file.Extends = "crush"
+		if file.Dark != nil && !*file.Dark {
+			file.Extends = "crush-light"
+		}
+	}
+	base, ok := m.themes[file.Extends]
+	if !ok {
+		return nil, fmt.Errorf("theme %s not found", file.Extends)
+	}
+
+	theme := *base
+	theme.Name = file.Name
+	theme.styles = nil
+	if file.Dark != nil {
+		theme.IsDark = *file.Dark
+	}
+	if theme.IsDark != base.IsDark {
+		// The diff colors of the other background.
+		theme.DiffInsert, theme.DiffInsertBg, theme.DiffInsertCodeBg = nil, nil, nil
+		theme.DiffDelete, theme.DiffDeleteBg, theme.DiffDeleteCodeBg = nil, nil, nil
+	}
+	colors := theme.colors()
+	for name, value := range file.Colors {
+		field, ok := colors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q", name)
+		}
+		c, err := parseColor(value)
+		if err != nil {
+			return nil, fmt.Errorf("color %q: %w", name, err)
+		}
+		*field = c
+	}
+	return &theme, nil
+}
+
+// LoadThemes registers the themes of the files in the directories, which may
+// not exist. A theme may extend the ones of the files before it.
+func (m *Manager) LoadThemes(dirs ...string) error {
+	var errs []error
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, err := config.FormatFromPath(path); err != nil {
+				continue
+			}
+			theme, err := m.ParseThemeFile(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("theme %s: %w", path, err))
+				continue
+			}
+			m.Register(theme)
+		}
+	}
+	return errors.Join(errs...)
+}
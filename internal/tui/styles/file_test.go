@@ -0,0 +1,57 @@
+package styles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadThemes(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ocean.json"), []byte(`{
+		"extends": "dracula",
+		"colors": {"primary": "#0077be", "bg_base": "17"}
+	}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "paper.toml"), []byte(`
+name = "paper"
+dark = false
+
+[colors]
+fg_base = "#111111"
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a theme"), 0o644))
+
+	m := NewManager("crush")
+	require.NoError(t, m.LoadThemes(dir, filepath.Join(dir, "missing")))
+
+	require.NoError(t, m.SetTheme("ocean"))
+	ocean := m.Current()
+	require.True(t, ocean.IsDark)
+	require.Equal(t, lipgloss.Color("#0077be"), ocean.Primary)
+	require.Equal(t, lipgloss.Color("17"), ocean.BgBase)
+	require.Equal(t, NewDraculaTheme().Secondary, ocean.Secondary)
+	require.NotNil(t, ocean.S())
+
+	require.NoError(t, m.SetTheme("paper"))
+	paper := m.Current()
+	require.False(t, paper.IsDark)
+	require.Equal(t, lipgloss.Color("#111111"), paper.FgBase)
+	require.Equal(t, NewCrushLightTheme().BgBase, paper.BgBase)
+}
+
+func TestLoadThemesErrors(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"colors": {"primary": "blue"}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unknown.json"), []byte(`{"colors": {"purple": "#800080"}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "orphan.json"), []byte(`{"extends": "solarized"}`), 0o644))
+
+	m := NewManager("crush")
+	err := m.LoadThemes(dir)
+	require.ErrorContains(t, err, `invalid color "blue"`)
+	require.ErrorContains(t, err, `unknown color "purple"`)
+	require.ErrorContains(t, err, "theme solarized not found")
+	require.Error(t, m.SetTheme("bad"))
+}
@@ -74,6 +74,14 @@ type Theme struct {
 	RedLight color.Color
 	Cherry   color.Color
 
+	// Diffs, the dark or light defaults when not set.
+	DiffInsert       color.Color
+	DiffInsertBg     color.Color
+	DiffInsertCodeBg color.Color
+	DiffDelete       color.Color
+	DiffDeleteBg     color.Color
+	DiffDeleteCodeBg color.Color
+
 	styles *Styles
 }
 
@@ -118,6 +126,7 @@ func (t *Theme) S() *Styles {
 }
 
 func (t *Theme) buildStyles() *Styles {
+	t.setDiffDefaults()
 	base := lipgloss.NewStyle().
 		Foreground(t.FgBase)
 	return &Styles{
@@ -198,7 +207,7 @@ func (t *Theme) buildStyles() *Styles {
 				StylePrimitive: ansi.StylePrimitive{
 					// BlockPrefix: "\n",
 					// BlockSuffix: "\n",
-					Color: stringPtr(charmtone.Smoke.Hex()),
+					Color: stringPtr(hex(t.FgHalfMuted)),
 				},
 				// Margin: uintPtr(defaultMargin),
 			},
@@ -213,7 +222,7 @@ func (t *Theme) buildStyles() *Styles {
 			Heading: ansi.StyleBlock{
 				StylePrimitive: ansi.StylePrimitive{
 					BlockSuffix: "\n",
-					Color:       stringPtr(charmtone.Malibu.Hex()),
+					Color:       stringPtr(hex(t.Info)),
 					Bold:        boolPtr(true),
 				},
 			},
@@ -221,8 +230,8 @@ func (t *Theme) buildStyles() *Styles {
 				StylePrimitive: ansi.StylePrimitive{
 					Prefix:          " ",
 					Suffix:          " ",
-					Color:           stringPtr(charmtone.Zest.Hex()),
-					BackgroundColor: stringPtr(charmtone.Charple.Hex()),
+					Color:           stringPtr(hex(t.Accent)),
+					BackgroundColor: stringPtr(hex(t.Primary)),
 					Bold:            boolPtr(true),
 				},
 			},
@@ -249,7 +258,7 @@ func (t *Theme) buildStyles() *Styles {
 			H6: ansi.StyleBlock{
 				StylePrimitive: ansi.StylePrimitive{
 					Prefix: "###### ",
-					Color:  stringPtr(charmtone.Guac.Hex()),
+					Color:  stringPtr(hex(t.Success)),
 					Bold:   boolPtr(false),
 				},
 			},
@@ -263,7 +272,7 @@ func (t *Theme) buildStyles() *Styles {
 				Bold: boolPtr(true),
 			},
 			HorizontalRule: ansi.StylePrimitive{
-				Color:  stringPtr(charmtone.Charcoal.Hex()),
+				Color:  stringPtr(hex(t.BgSubtle)),
 				Format: "\n--------\n",
 			},
 			Item: ansi.StylePrimitive{
@@ -282,7 +291,7 @@ func (t *Theme) buildStyles() *Styles {
 				Underline: boolPtr(true),
 			},
 			LinkText: ansi.StylePrimitive{
-				Color: stringPtr(charmtone.Guac.Hex()),
+				Color: stringPtr(hex(t.Success)),
 				Bold:  boolPtr(true),
 			},
 			Image: ansi.StylePrimitive{
@@ -290,17 +299,19 @@ func (t *Theme) buildStyles() *Styles {
 				Underline: boolPtr(true),
 			},
 			ImageText: ansi.StylePrimitive{
-				Color:  stringPtr(charmtone.Squid.Hex()),
+				Color:  stringPtr(hex(t.FgMuted)),
 				Format: "Image: {{.text}} →",
 			},
 			Code: ansi.StyleBlock{
 				StylePrimitive: ansi.StylePrimitive{
 					Prefix:          " ",
 					Suffix:          " ",
-					Color:           stringPtr(charmtone.Coral.Hex()),
-					BackgroundColor: stringPtr(charmtone.Charcoal.Hex()),
+					Color:           stringPtr(hex(t.Red)),
+					BackgroundColor: stringPtr(hex(t.BgSubtle)),
 				},
 			},
+			// Code blocks keep the colors of the crush theme, on its
+			// background, in all themes.
 			CodeBlock: ansi.StyleCodeBlock{
 				StyleBlock: ansi.StyleBlock{
 					StylePrimitive: ansi.StylePrimitive{
@@ -437,23 +448,23 @@ func (t *Theme) buildStyles() *Styles {
 			},
 			InsertLine: diffview.LineStyle{
 				LineNumber: lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#629657")).
-					Background(lipgloss.Color("#2b322a")),
+					Foreground(t.DiffInsert).
+					Background(t.DiffInsertBg),
 				Symbol: lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#629657")).
-					Background(lipgloss.Color("#323931")),
+					Foreground(t.DiffInsert).
+					Background(t.DiffInsertCodeBg),
 				Code: lipgloss.NewStyle().
-					Background(lipgloss.Color("#323931")),
+					Background(t.DiffInsertCodeBg),
 			},
 			DeleteLine: diffview.LineStyle{
 				LineNumber: lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#a45c59")).
-					Background(lipgloss.Color("#312929")),
+					Foreground(t.DiffDelete).
+					Background(t.DiffDeleteBg),
 				Symbol: lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#a45c59")).
-					Background(lipgloss.Color("#383030")),
+					Foreground(t.DiffDelete).
+					Background(t.DiffDeleteCodeBg),
 				Code: lipgloss.NewStyle().
-					Background(lipgloss.Color("#383030")),
+					Background(t.DiffDeleteCodeBg),
 			},
 		},
 		FilePicker: filepicker.Styles{
@@ -472,6 +483,26 @@ func (t *Theme) buildStyles() *Styles {
 	}
 }
 
+// setDiffDefaults sets the diff colors the theme doesn't set, for a dark or
+// a light background.
+func (t *Theme) setDiffDefaults() {
+	defaults := [6]string{"#629657", "#2b322a", "#323931", "#a45c59", "#312929", "#383030"}
+	if !t.IsDark {
+		defaults = [6]string{"#2e7d32", "#c8e6c9", "#e8f5e9", "#c62828", "#ffcdd2", "#ffebee"}
+	}
+	for i, c := range []*color.Color{&t.DiffInsert, &t.DiffInsertBg, &t.DiffInsertCodeBg, &t.DiffDelete, &t.DiffDeleteBg, &t.DiffDeleteCodeBg} {
+		if *c == nil {
+			*c = lipgloss.Color(defaults[i])
+		}
+	}
+}
+
+// hex returns the color as #rrggbb, for the markdown styles.
+func hex(c color.Color) string {
+	converted, _ := colorful.MakeColor(c)
+	return converted.Hex()
+}
+
 type Manager struct {
 	themes  map[string]*Theme
 	current *Theme
@@ -503,6 +534,9 @@ func NewManager(defaultTheme string) *Manager {
 	}
 
 	m.Register(NewCrushTheme())
+	m.Register(NewCrushLightTheme())
+	m.Register(NewDraculaTheme())
+	m.Register(NewGruvboxTheme())
 
 	m.current = m.themes[defaultTheme]
 
@@ -0,0 +1,195 @@
+package styles
+
+import (
+	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/charmbracelet/x/exp/charmtone"
+)
+
+// NewCrushLightTheme is the crush theme for terminals with a light
+// background.
+func NewCrushLightTheme() *Theme {
+	return &Theme{
+		Name:   "crush-light",
+		IsDark: false,
+
+		Primary:   charmtone.Charple,
+		Secondary: charmtone.Urchin,
+		Tertiary:  charmtone.Pickle,
+		Accent:    charmtone.Cumin,
+
+		// Backgrounds
+		BgBase:        charmtone.Butter,
+		BgBaseLighter: charmtone.Salt,
+		BgSubtle:      charmtone.Ash,
+		BgOverlay:     charmtone.Smoke,
+
+		// Foregrounds
+		FgBase:      charmtone.Pepper,
+		FgMuted:     charmtone.Oyster,
+		FgHalfMuted: charmtone.Iron,
+		FgSubtle:    charmtone.Squid,
+		FgSelected:  charmtone.Butter,
+
+		// Borders
+		Border:      charmtone.Smoke,
+		BorderFocus: charmtone.Charple,
+
+		// Status
+		Success: charmtone.Pickle,
+		Error:   charmtone.Sriracha,
+		Warning: charmtone.Tang,
+		Info:    charmtone.Damson,
+
+		// Colors
+		White: charmtone.Butter,
+
+		BlueLight: charmtone.Malibu,
+		Blue:      charmtone.Damson,
+
+		Yellow: charmtone.Cumin,
+
+		Green:      charmtone.Pickle,
+		GreenDark:  charmtone.NeueZinc,
+		GreenLight: charmtone.Guac,
+
+		Red:      charmtone.Sriracha,
+		RedDark:  charmtone.Pom,
+		RedLight: charmtone.Coral,
+		Cherry:   charmtone.Cherry,
+	}
+}
+
+// NewDraculaTheme is the theme with the colors of Dracula.
+func NewDraculaTheme() *Theme {
+	var (
+		background  = lipgloss.Color("#282a36")
+		currentLine = lipgloss.Color("#44475a")
+		foreground  = lipgloss.Color("#f8f8f2")
+		comment     = lipgloss.Color("#6272a4")
+		cyan        = lipgloss.Color("#8be9fd")
+		green       = lipgloss.Color("#50fa7b")
+		orange      = lipgloss.Color("#ffb86c")
+		pink        = lipgloss.Color("#ff79c6")
+		purple      = lipgloss.Color("#bd93f9")
+		red         = lipgloss.Color("#ff5555")
+		yellow      = lipgloss.Color("#f1fa8c")
+	)
+	return &Theme{
+		Name:   "dracula",
+		IsDark: true,
+
+		Primary:   purple,
+		Secondary: pink,
+		Tertiary:  cyan,
+		Accent:    yellow,
+
+		// Backgrounds
+		BgBase:        background,
+		BgBaseLighter: lipgloss.Color("#343746"),
+		BgSubtle:      currentLine,
+		BgOverlay:     lipgloss.Color("#515469"),
+
+		// Foregrounds
+		FgBase:      foreground,
+		FgMuted:     comment,
+		FgHalfMuted: lipgloss.Color("#bfbfbf"),
+		FgSubtle:    lipgloss.Color("#565c7a"),
+		FgSelected:  foreground,
+
+		// Borders
+		Border:      currentLine,
+		BorderFocus: purple,
+
+		// Status
+		Success: green,
+		Error:   red,
+		Warning: orange,
+		Info:    cyan,
+
+		// Colors
+		White: foreground,
+
+		BlueLight: cyan,
+		Blue:      lipgloss.Color("#6be5fd"),
+
+		Yellow: yellow,
+
+		Green:      green,
+		GreenDark:  lipgloss.Color("#3ad760"),
+		GreenLight: lipgloss.Color("#8afa9f"),
+
+		Red:      red,
+		RedDark:  lipgloss.Color("#de3e3e"),
+		RedLight: lipgloss.Color("#ff8080"),
+		Cherry:   pink,
+	}
+}
+
+// NewGruvboxTheme is the theme with the dark colors of Gruvbox.
+func NewGruvboxTheme() *Theme {
+	var (
+		bg     = lipgloss.Color("#282828")
+		bg1    = lipgloss.Color("#3c3836")
+		bg2    = lipgloss.Color("#504945")
+		bg3    = lipgloss.Color("#665c54")
+		fg     = lipgloss.Color("#ebdbb2")
+		fg4    = lipgloss.Color("#a89984")
+		gray   = lipgloss.Color("#928374")
+		red    = lipgloss.Color("#fb4934")
+		green  = lipgloss.Color("#b8bb26")
+		yellow = lipgloss.Color("#fabd2f")
+		blue   = lipgloss.Color("#83a598")
+		purple = lipgloss.Color("#d3869b")
+		aqua   = lipgloss.Color("#8ec07c")
+		orange = lipgloss.Color("#fe8019")
+	)
+	return &Theme{
+		Name:   "gruvbox",
+		IsDark: true,
+
+		Primary:   orange,
+		Secondary: purple,
+		Tertiary:  aqua,
+		Accent:    yellow,
+
+		// Backgrounds
+		BgBase:        bg,
+		BgBaseLighter: lipgloss.Color("#32302f"),
+		BgSubtle:      bg1,
+		BgOverlay:     bg2,
+
+		// Foregrounds
+		FgBase:      fg,
+		FgMuted:     gray,
+		FgHalfMuted: fg4,
+		FgSubtle:    bg3,
+		FgSelected:  lipgloss.Color("#fbf1c7"),
+
+		// Borders
+		Border:      bg2,
+		BorderFocus: orange,
+
+		// Status
+		Success: green,
+		Error:   red,
+		Warning: yellow,
+		Info:    blue,
+
+		// Colors
+		White: fg,
+
+		BlueLight: blue,
+		Blue:      lipgloss.Color("#458588"),
+
+		Yellow: yellow,
+
+		Green:      green,
+		GreenDark:  lipgloss.Color("#98971a"),
+		GreenLight: aqua,
+
+		Red:      red,
+		RedDark:  lipgloss.Color("#cc241d"),
+		RedLight: lipgloss.Color("#fe8c7d"),
+		Cherry:   purple,
+	}
+}
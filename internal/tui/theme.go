@@ -0,0 +1,39 @@
+package tui
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+// autoTheme picks the theme for the background of the terminal.
+const autoTheme = "auto"
+
+// setTheme loads the theme files, in the themes directories of the global
+// config and of the project, and sets the theme of the config. It must run
+// before the components are built, as they keep their styles.
+func setTheme(cfg *config.Config) {
+	manager := styles.DefaultManager()
+	dirs := []string{
+		filepath.Join(config.GlobalConfigDir(), "themes"),
+		filepath.Join(cfg.Options.DataDirectory, "themes"),
+	}
+	if err := manager.LoadThemes(dirs...); err != nil {
+		slog.Warn("Failed to load themes", "error", err)
+	}
+
+	name := cfg.Options.TUI.Theme
+	if name == "" || name == autoTheme {
+		name = "crush"
+		if !lipgloss.HasDarkBackground(os.Stdin, os.Stdout) {
+			name = "crush-light"
+		}
+	}
+	if err := manager.SetTheme(name); err != nil {
+		slog.Warn("Failed to set the theme", "error", err)
+	}
+}
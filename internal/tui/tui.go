@@ -2,36 +2,52 @@ package tui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/branch"
+	"github.com/charmbracelet/crush/internal/checkpoint"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/history"
 	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/scheduler"
+	"github.com/charmbracelet/crush/internal/session"
 	cmpChat "github.com/charmbracelet/crush/internal/tui/components/chat"
+	"github.com/charmbracelet/crush/internal/tui/components/chat/editor"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/splash"
 	"github.com/charmbracelet/crush/internal/tui/components/completions"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/core/layout"
 	"github.com/charmbracelet/crush/internal/tui/components/core/status"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/branches"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/checkpoints"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/commands"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/compact"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/filepicker"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/models"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/permissions"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/providers"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/quit"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs/sessions"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/stats"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs/tasks"
 	"github.com/charmbracelet/crush/internal/tui/page"
 	"github.com/charmbracelet/crush/internal/tui/page/chat"
 	"github.com/charmbracelet/crush/internal/tui/styles"
 	"github.com/charmbracelet/crush/internal/tui/util"
 	"github.com/charmbracelet/lipgloss/v2"
+	"github.com/google/uuid"
 )
 
 var lastMouseEvent time.Time
@@ -72,6 +88,11 @@ type appModel struct {
 
 	// Chat Page Specific
 	selectedSessionID string // The ID of the currently selected session
+
+	notifier *terminalNotifier
+
+	// background runs the queued and scheduled tasks.
+	background *scheduler.Scheduler
 }
 
 // Init initializes the application model and returns initial commands.
@@ -82,7 +103,7 @@ func (a appModel) Init() tea.Cmd {
 	a.loadedPages[a.currentPage] = true
 
 	cmd = a.status.Init()
-	cmds = append(cmds, cmd)
+	cmds = append(cmds, cmd, a.budgetCmd())
 
 	cmds = append(cmds, tea.EnableMouseAllMotion)
 
@@ -91,6 +112,12 @@ func (a appModel) Init() tea.Cmd {
 
 // Update handles incoming messages and updates the application state.
 func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := a.update(msg)
+	busy := a.app.CoderAgent != nil && a.app.CoderAgent.IsBusy()
+	return m, tea.Batch(cmd, a.notifier.SetBusy(busy))
+}
+
+func (a *appModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
 	a.isConfigured = config.HasInitialDataConfig()
@@ -105,6 +132,12 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		return a, tea.Batch(cmds...)
+	case tea.FocusMsg:
+		a.notifier.SetFocused(true)
+		return a, nil
+	case tea.BlurMsg:
+		a.notifier.SetFocused(false)
+		return a, nil
 	case tea.WindowSizeMsg:
 		a.wWidth, a.wHeight = msg.Width, msg.Height
 		a.completions.Update(msg)
@@ -148,8 +181,12 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Session
 	case cmpChat.SessionSelectedMsg:
 		a.selectedSessionID = msg.ID
+		a.status.SetSession(session.Session(msg))
+		cmds = append(cmds, a.budgetCmd())
 	case cmpChat.SessionClearedMsg:
 		a.selectedSessionID = ""
+		a.status.SetSession(session.Session{})
+		cmds = append(cmds, a.budgetCmd())
 	// Commands
 	case commands.SwitchSessionsMsg:
 		return a, func() tea.Msg {
@@ -165,11 +202,231 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				Model: models.NewModelDialogCmp(),
 			},
 		)
+	case commands.AddProviderMsg:
+		return a, util.CmdHandler(
+			dialogs.OpenDialogMsg{
+				Model: providers.NewAddProviderDialog(),
+			},
+		)
+	case commands.ShowModelStatsMsg:
+		return a, func() tea.Msg {
+			const days = 30
+			modelStats, err := a.app.Sessions.ModelStats(context.Background(), time.Now().AddDate(0, 0, -days))
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			return dialogs.OpenDialogMsg{
+				Model: stats.NewStatsDialog(modelStats, days),
+			}
+		}
 	// Compact
 	case commands.CompactMsg:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: compact.NewCompactDialogCmp(a.app.CoderAgent, msg.SessionID, true),
 		})
+	case commands.UndoChangeSetMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		return a, func() tea.Msg {
+			changeSet, err := tools.UndoChangeSet(context.Background(), a.app.History, msg.SessionID)
+			if errors.Is(err, history.ErrNoChangeSet) {
+				return util.ReportWarn("No change set to undo")()
+			}
+			if err != nil {
+				return util.ReportError(fmt.Errorf("failed to undo the change set: %w", err))()
+			}
+			return util.ReportInfo(fmt.Sprintf("Restored %d files", len(changeSet.Files)))()
+		}
+	case commands.UndoTurnMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		store := checkpoint.OpenProject(config.Get())
+		if store == nil {
+			return a, util.ReportWarn("Checkpoints need git to be installed")
+		}
+		return a, func() tea.Msg {
+			restored, err := store.Undo(context.Background())
+			if errors.Is(err, checkpoint.ErrNothingToUndo) {
+				return util.ReportWarn("Nothing to undo")()
+			}
+			if err != nil {
+				return util.ReportError(fmt.Errorf("failed to undo the turn: %w", err))()
+			}
+			return util.ReportInfo(fmt.Sprintf("Restored checkpoint %s %s", restored.ShortID(), restored.Message))()
+		}
+	case commands.RestoreCheckpointMsg:
+		store := checkpoint.OpenProject(config.Get())
+		if store == nil {
+			return a, util.ReportWarn("Checkpoints need git to be installed")
+		}
+		return a, func() tea.Msg {
+			list, err := store.List(context.Background())
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			if len(list) == 0 {
+				return util.ReportWarn("No checkpoints yet")()
+			}
+			return dialogs.OpenDialogMsg{
+				Model: checkpoints.NewCheckpointsDialogCmp(list),
+			}
+		}
+	case checkpoints.CheckpointSelectedMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		store := checkpoint.OpenProject(config.Get())
+		if store == nil {
+			return a, nil
+		}
+		return a, func() tea.Msg {
+			if _, err := store.Restore(context.Background(), msg.Checkpoint.ID); err != nil {
+				return util.ReportError(fmt.Errorf("failed to restore the checkpoint: %w", err))()
+			}
+			return util.ReportInfo(fmt.Sprintf("Restored checkpoint %s %s", msg.Checkpoint.ShortID(), msg.Checkpoint.Message))()
+		}
+	case commands.ForkSessionMsg:
+		return a, func() tea.Msg {
+			msgs, err := a.app.Messages.List(context.Background(), msg.SessionID)
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			if len(msgs) == 0 {
+				return util.ReportWarn("Nothing to fork yet")()
+			}
+			items := []branches.Item{{
+				Title: "Latest message",
+				Msg:   branches.ForkSelectedMsg{SessionID: msg.SessionID, MessageID: msgs[len(msgs)-1].ID},
+			}}
+			// Forking at a prompt keeps the messages before it and lets the
+			// prompt be edited, the latest prompts first.
+			for i, m := range slices.Backward(msgs) {
+				prompt := m.Content().Text
+				if m.Role != message.User || prompt == "" {
+					continue
+				}
+				previous := ""
+				if i > 0 {
+					previous = msgs[i-1].ID
+				}
+				title, _, _ := strings.Cut(prompt, "\n")
+				items = append(items, branches.Item{
+					Title: "Edit: " + title,
+					Msg:   branches.ForkSelectedMsg{SessionID: msg.SessionID, MessageID: previous, Prompt: prompt},
+				})
+			}
+			return dialogs.OpenDialogMsg{
+				Model: branches.NewBranchesDialogCmp("Fork Session", items),
+			}
+		}
+	case branches.ForkSelectedMsg:
+		if a.app.CoderAgent.IsBusy() {
+			return a, util.ReportWarn("Agent is busy, please wait...")
+		}
+		fork, err := branch.Fork(context.Background(), a.app.Sessions, a.app.Messages, msg.SessionID, msg.MessageID)
+		if err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to fork the session: %w", err))
+		}
+		return a, tea.Sequence(
+			util.CmdHandler(cmpChat.SessionSelectedMsg(fork)),
+			util.CmdHandler(editor.OpenEditorMsg{Text: msg.Prompt}),
+			util.ReportInfo(fmt.Sprintf("Forked the session to %q", fork.Title)),
+		)
+	case commands.ShowBranchesMsg:
+		return a, func() tea.Msg {
+			allSessions, err := a.app.Sessions.List(context.Background())
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			nodes := branch.Tree(allSessions, msg.SessionID)
+			if len(nodes) < 2 {
+				return util.ReportWarn("The session has no branches, fork it first")()
+			}
+			items := make([]branches.Item, len(nodes))
+			for i, node := range nodes {
+				title := strings.Repeat("  ", node.Depth) + node.Session.Title
+				if node.Session.ID == msg.SessionID {
+					title += " (current)"
+				}
+				items[i] = branches.Item{Title: title, Msg: cmpChat.SessionSelectedMsg(node.Session)}
+			}
+			return dialogs.OpenDialogMsg{
+				Model: branches.NewBranchesDialogCmp("Branches", items),
+			}
+		}
+	case commands.MergeNotesMsg:
+		return a, func() tea.Msg {
+			_, err := branch.MergeNotes(context.Background(), a.app.Sessions, a.app.Messages, msg.SessionID, msg.Notes)
+			if errors.Is(err, branch.ErrNotFork) {
+				return util.ReportWarn("Only the notes of a fork can be merged")()
+			}
+			if err != nil {
+				return util.ReportError(fmt.Errorf("failed to merge the notes: %w", err))()
+			}
+			return util.ReportInfo("Merged the notes into the session the fork came from")()
+		}
+	case commands.EnqueueTaskMsg:
+		task := scheduler.Task{
+			ID:        uuid.New().String()[:8],
+			Prompt:    msg.Prompt,
+			CreatedAt: time.Now(),
+		}
+		if err := a.background.Enqueue(task); err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to queue the task: %w", err))
+		}
+		return a, util.ReportInfo(fmt.Sprintf("Queued task %s, see Background Tasks for its result", task.ID))
+	case commands.SwitchAgentMsg:
+		if msg.Name == "" {
+			return a, util.ReportInfo(fmt.Sprintf("Talking to the %s agent, type /agent <name> to switch to one of %s", a.app.AgentName(), strings.Join(config.Get().MainAgents(), ", ")))
+		}
+		return a, func() tea.Msg {
+			err := a.app.SwitchAgent(msg.Name)
+			if errors.Is(err, agent.ErrSessionBusy) {
+				return util.ReportWarn("Agent is busy, please wait before switching agents...")()
+			}
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			return util.ReportInfo(fmt.Sprintf("Switched to the %s agent", msg.Name))()
+		}
+	case commands.ShowTasksMsg:
+		return a, func() tea.Msg {
+			list, err := scheduler.LoadTasks(scheduler.TasksPath(a.app.Config()))
+			if err != nil {
+				return util.ReportError(err)()
+			}
+			if len(list) == 0 {
+				return util.ReportWarn("No background tasks, type /queue <prompt> to add one")()
+			}
+			return dialogs.OpenDialogMsg{
+				Model: tasks.NewTasksDialogCmp(list),
+			}
+		}
+	case tasks.TaskSelectedMsg:
+		if msg.Task.LastRun == nil || msg.Task.LastRun.SessionID == "" {
+			return a, util.ReportWarn("The task hasn't run yet")
+		}
+		return a, func() tea.Msg {
+			sess, err := a.app.Sessions.Get(context.Background(), msg.Task.LastRun.SessionID)
+			if err != nil {
+				return util.ReportError(fmt.Errorf("failed to open the session of the task: %w", err))()
+			}
+			return cmpChat.SessionSelectedMsg(sess)
+		}
+	case pubsub.Event[scheduler.Task]:
+		run := msg.Payload.LastRun
+		if run == nil || run.FinishedAt.IsZero() {
+			return a, nil
+		}
+		report := fmt.Sprintf("Task %s finished", msg.Payload.Name())
+		status := util.ReportInfo(report)
+		if run.Error != "" {
+			report = fmt.Sprintf("Task %s failed: %s", msg.Payload.Name(), run.Error)
+			status = util.ReportWarn(report)
+		}
+		return a, tea.Batch(status, a.notifier.Notify(report))
 	case commands.QuitMsg:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: quit.NewQuitDialog(),
@@ -196,6 +453,12 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return a, util.ReportInfo(fmt.Sprintf("%s model changed to %s", modelTypeName, msg.Model.Model))
 
+	case app.ConfigReloadedMsg:
+		if msg.Err != nil {
+			return a, util.ReportError(fmt.Errorf("failed to reload config: %w", msg.Err))
+		}
+		return a, util.ReportInfo("Config reloaded")
+
 	// File Picker
 	case commands.OpenFilePickerMsg:
 		if a.dialog.ActiveDialogID() == filepicker.FilePickerID {
@@ -205,6 +468,17 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: filepicker.NewFilePickerCmp(a.app.Config().WorkingDir()),
 		})
+	// Failed turns, the message still goes to the page below.
+	case pubsub.Event[message.Message]:
+		if msg.Payload.Role == message.Assistant && msg.Payload.FinishReason() == message.FinishReasonError {
+			a.notifier.Failed(msg.Payload.FinishPart().Message)
+		}
+	// Tool progress
+	case pubsub.Event[tools.Progress]:
+		// forward to page
+		updated, cmd := a.pages[a.currentPage].Update(msg)
+		a.pages[a.currentPage] = updated.(util.Model)
+		return a, cmd
 	// Permissions
 	case pubsub.Event[permission.PermissionNotification]:
 		// forward to page
@@ -212,15 +486,22 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.pages[a.currentPage] = updated.(util.Model)
 		return a, cmd
 	case pubsub.Event[permission.PermissionRequest]:
-		return a, util.CmdHandler(dialogs.OpenDialogMsg{
-			Model: permissions.NewPermissionDialogCmp(msg.Payload),
-		})
+		return a, tea.Batch(
+			util.CmdHandler(dialogs.OpenDialogMsg{
+				Model: permissions.NewPermissionDialogCmp(msg.Payload),
+			}),
+			a.notifier.Notify(fmt.Sprintf("Permission required for %s", msg.Payload.ToolName)),
+		)
 	case permissions.PermissionResponseMsg:
 		switch msg.Action {
 		case permissions.PermissionAllow:
 			a.app.Permissions.Grant(msg.Permission)
 		case permissions.PermissionAllowForSession:
 			a.app.Permissions.GrantPersistent(msg.Permission)
+		case permissions.PermissionAllowForProject:
+			a.app.Permissions.GrantAlways(msg.Permission)
+		case permissions.PermissionAllowReviewed:
+			a.app.Permissions.GrantReviewed(msg.Permission, msg.Content)
 		case permissions.PermissionDeny:
 			a.app.Permissions.Deny(msg.Permission)
 		}
@@ -236,20 +517,8 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			cmds = append(cmds, dialogCmd)
 		}
 
-		// Handle auto-compact logic
-		if payload.Done && payload.Type == agent.AgentEventTypeResponse && a.selectedSessionID != "" {
-			// Get current session to check token usage
-			session, err := a.app.Sessions.Get(context.Background(), a.selectedSessionID)
-			if err == nil {
-				model := a.app.CoderAgent.Model()
-				contextWindow := model.ContextWindow
-				tokens := session.CompletionTokens + session.PromptTokens
-				if (tokens >= int64(float64(contextWindow)*0.95)) && !config.Get().Options.DisableAutoSummarize { // Show compact confirmation dialog
-					cmds = append(cmds, util.CmdHandler(dialogs.OpenDialogMsg{
-						Model: compact.NewCompactDialogCmp(a.app.CoderAgent, a.selectedSessionID, false),
-					}))
-				}
-			}
+		if payload.Done {
+			cmds = append(cmds, a.budgetCmd())
 		}
 
 		return a, tea.Batch(cmds...)
@@ -261,6 +530,7 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return a, tea.Batch(cmds...)
 	// Key Press Messages
 	case tea.KeyPressMsg:
+		a.notifier.Acknowledge()
 		return a, a.handleKeyPressMsg(msg)
 
 	case tea.MouseWheelMsg:
@@ -295,6 +565,22 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, tea.Batch(cmds...)
 }
 
+// budgetCmd loads the budget usage of the selected session for the status
+// bar.
+func (a *appModel) budgetCmd() tea.Cmd {
+	if config.Get().Options.Budget == nil {
+		return nil
+	}
+	sessionID := a.selectedSessionID
+	return func() tea.Msg {
+		usage, err := agent.GetBudgetUsage(context.Background(), a.app.Sessions, sessionID)
+		if err != nil {
+			return util.InfoMsg{Type: util.InfoTypeError, Msg: err.Error()}
+		}
+		return status.BudgetMsg{Usage: usage}
+	}
+}
+
 // handleWindowResize processes window resize events and updates all components.
 func (a *appModel) handleWindowResize(width, height int) tea.Cmd {
 	var cmds []tea.Cmd
@@ -438,6 +724,7 @@ func (a *appModel) View() tea.View {
 	var view tea.View
 	t := styles.CurrentTheme()
 	view.BackgroundColor = t.BgBase
+	view.WindowTitle = a.notifier.WindowTitle()
 	if a.wWidth < 25 || a.wHeight < 15 {
 		view.Layer = lipgloss.NewCanvas(
 			lipgloss.NewLayer(
@@ -513,7 +800,8 @@ func (a *appModel) View() tea.View {
 }
 
 // New creates and initializes a new TUI application model.
-func New(app *app.App) tea.Model {
+func New(app *app.App, background *scheduler.Scheduler) tea.Model {
+	setTheme(app.Config())
 	chatPage := chat.New(app)
 	keyMap := DefaultKeyMap()
 	keyMap.pageBindings = chatPage.Bindings()
@@ -521,7 +809,7 @@ func New(app *app.App) tea.Model {
 	model := &appModel{
 		currentPage: chat.ChatPageID,
 		app:         app,
-		status:      status.NewStatusCmp(),
+		status:      status.NewStatusCmp(app.AgentName),
 		loadedPages: make(map[page.PageID]bool),
 		keyMap:      keyMap,
 
@@ -531,6 +819,8 @@ func New(app *app.App) tea.Model {
 
 		dialog:      dialogs.NewDialogCmp(),
 		completions: completions.New(),
+		notifier:    newTerminalNotifier(app.Config().WorkingDir(), app.Config().Options.TUI.DisableNotifications, app.Config().Options.TUI.Notifications),
+		background:  background,
 	}
 
 	return model
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+const defaultWarnAt = 0.8
+
+// Budget thresholds, in the order they are crossed.
+const (
+	budgetWarned = iota + 1
+	budgetReached
+)
+
+// Watch sends approval_needed events for the permission requests and
+// budget_threshold events as sessions spend, until ctx is done.
+func (d *Dispatcher) Watch(ctx context.Context, permissions permission.Service) {
+	consume(ctx, &d.wg, permissions.Subscribe(ctx), func(ctx context.Context, event pubsub.Event[permission.PermissionRequest]) {
+		req := event.Payload
+		title := d.title(ctx, req.SessionID)
+		d.Send(ctx, Event{
+			Type:         config.WebhookApprovalNeeded,
+			SessionID:    req.SessionID,
+			SessionTitle: title,
+			Text:         fmt.Sprintf("Approval needed in %s: %s %s", cmp.Or(title, req.SessionID), req.ToolName, req.Description),
+			Permission:   &req,
+		})
+	})
+	consume(ctx, &d.wg, d.sessions.Subscribe(ctx), func(ctx context.Context, event pubsub.Event[session.Session]) {
+		if event.Type == pubsub.UpdatedEvent {
+			d.checkBudget(ctx, event.Payload.ID)
+		}
+	})
+}
+
+// WatchAgent sends task_completed and error events as the turns of the agent
+// finish, until ctx is done.
+func (d *Dispatcher) WatchAgent(ctx context.Context, coder agent.Service) {
+	consume(ctx, &d.wg, coder.SubscribeLoop(ctx), func(ctx context.Context, event pubsub.Event[agent.LoopEvent]) {
+		if event.Payload.Type == agent.LoopEventTurnFinished {
+			d.turnFinished(ctx, event.Payload)
+		}
+	})
+}
+
+// consume handles the events in the background until ctx is done, including
+// the ones already published then, such as the end of the last turn of crush
+// run.
+func consume[T any](ctx context.Context, wg *sync.WaitGroup, events <-chan pubsub.Event[T], handle func(context.Context, pubsub.Event[T])) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				handle(ctx, event)
+			case <-ctx.Done():
+				ctx := context.WithoutCancel(ctx)
+				for {
+					select {
+					case event, ok := <-events:
+						if !ok {
+							return
+						}
+						handle(ctx, event)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) turnFinished(ctx context.Context, finished agent.LoopEvent) {
+	if finished.Error == agent.ErrRequestCancelled.Error() || finished.Error == context.Canceled.Error() {
+		return
+	}
+	title := d.title(ctx, finished.SessionID)
+	name := cmp.Or(title, finished.SessionID)
+	if finished.Error != "" {
+		d.Send(ctx, Event{
+			Type:         config.WebhookError,
+			SessionID:    finished.SessionID,
+			SessionTitle: title,
+			Text:         fmt.Sprintf("Session %s failed: %s", name, finished.Error),
+			Error:        finished.Error,
+		})
+		return
+	}
+	d.Send(ctx, Event{
+		Type:         config.WebhookTaskCompleted,
+		SessionID:    finished.SessionID,
+		SessionTitle: title,
+		Text:         fmt.Sprintf("Session %s finished its task", name),
+		Result:       d.result(ctx, finished.SessionID),
+	})
+}
+
+// checkBudget sends a budget_threshold event for each limit of the budget
+// crossing a threshold since the last check.
+func (d *Dispatcher) checkBudget(ctx context.Context, sessionID string) {
+	usage, err := agent.GetBudgetUsage(ctx, d.sessions, sessionID)
+	if err != nil {
+		slog.Error("Failed to check the budget for webhooks", "session_id", sessionID, "error", err)
+		return
+	}
+	if usage == nil {
+		return
+	}
+	warnAt := cmp.Or(usage.Budget.WarnAt, defaultWarnAt)
+	for _, limit := range usage.Limits() {
+		threshold := 0
+		switch {
+		case limit.Used >= limit.Max:
+			threshold = budgetReached
+		case limit.Used >= limit.Max*warnAt:
+			threshold = budgetWarned
+		}
+		// Session limits are tracked per root session.
+		key := limit.Key
+		if usage.SessionID != "" && limit.Name != "daily_cost" && limit.Name != "daily_tokens" {
+			key += ":" + usage.SessionID
+		}
+		d.budgetMu.Lock()
+		crossed := threshold > d.budgetAlerts[key]
+		if crossed {
+			d.budgetAlerts[key] = threshold
+		}
+		d.budgetMu.Unlock()
+		if !crossed {
+			continue
+		}
+		d.Send(ctx, Event{
+			Type:         config.WebhookBudgetThreshold,
+			SessionID:    usage.SessionID,
+			SessionTitle: d.title(ctx, usage.SessionID),
+			Text:         limit.Describe(),
+			Budget: &Budget{
+				Limit:   limit.Name,
+				Used:    limit.Used,
+				Max:     limit.Max,
+				Percent: int(limit.Used / limit.Max * 100),
+			},
+		})
+	}
+}
+
+func (d *Dispatcher) title(ctx context.Context, sessionID string) string {
+	sess, err := d.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return ""
+	}
+	return sess.Title
+}
+
+// result returns the final answer of the agent in the session.
+func (d *Dispatcher) result(ctx context.Context, sessionID string) string {
+	msgs, err := d.messages.List(ctx, sessionID)
+	if err != nil {
+		slog.Error("Failed to list messages for webhooks", "session_id", sessionID, "error", err)
+		return ""
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == message.Assistant {
+			return msgs[i].Content().String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,254 @@
+// Package webhook posts session events, such as completed tasks and pending
+// approvals, to the webhooks of the config, or runs their commands on them.
+package webhook
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/internal/shell"
+)
+
+const defaultRetries = 3
+
+// Event is posted to the webhooks, as JSON or rendered by their template.
+type Event struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	SessionID    string    `json:"session_id,omitempty"`
+	SessionTitle string    `json:"session_title,omitempty"`
+	// Text is a summary, for chat webhooks such as Slack's.
+	Text string `json:"text"`
+	// Result is the final answer of a completed task.
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Permission is the request of an approval_needed event.
+	Permission *permission.PermissionRequest `json:"permission,omitempty"`
+	// Budget is the limit of a budget_threshold event.
+	Budget *Budget `json:"budget,omitempty"`
+}
+
+// Budget is the use of a limit of the budget.
+type Budget struct {
+	Limit string  `json:"limit"`
+	Used  float64 `json:"used"`
+	Max   float64 `json:"max"`
+	// Percent of the limit used.
+	Percent int `json:"percent"`
+}
+
+type hook struct {
+	url string
+	// command is run on the events in place of posting them.
+	command  string
+	events   []string
+	headers  map[string]string
+	template *template.Template
+	retries  int
+}
+
+// Dispatcher posts events to the webhooks.
+type Dispatcher struct {
+	hooks      []hook
+	sessions   session.Service
+	messages   message.Service
+	client     *http.Client
+	workingDir string
+	// backoff is the delay before the first retry, doubled for each retry.
+	backoff time.Duration
+
+	// budgetAlerts are the budget thresholds already crossed, by limit key.
+	budgetMu     sync.Mutex
+	budgetAlerts map[string]int
+
+	// wg tracks the watches and deliveries.
+	wg sync.WaitGroup
+}
+
+// New returns a dispatcher for the webhooks of the config, or nil when there
+// are none.
+func New(cfg *config.Config, sessions session.Service, messages message.Service) (*Dispatcher, error) {
+	if len(cfg.Webhooks) == 0 {
+		return nil, nil
+	}
+	d := &Dispatcher{
+		sessions:     sessions,
+		messages:     messages,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		workingDir:   cfg.WorkingDir(),
+		backoff:      time.Second,
+		budgetAlerts: map[string]int{},
+	}
+	for i, webhook := range cfg.Webhooks {
+		url, err := cfg.Resolve(webhook.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the URL of webhook %d: %w", i, err)
+		}
+		switch {
+		case url == "" && webhook.Command == "":
+			return nil, fmt.Errorf("webhook %d has no URL or command", i)
+		case url != "" && webhook.Command != "":
+			return nil, fmt.Errorf("webhook %d has both a URL and a command", i)
+		}
+		h := hook{
+			url:     url,
+			command: webhook.Command,
+			events:  webhook.Events,
+			headers: map[string]string{},
+			retries: defaultRetries,
+		}
+		if webhook.Retries != nil {
+			h.retries = *webhook.Retries
+		}
+		for key, value := range webhook.Headers {
+			if h.headers[key], err = cfg.Resolve(value); err != nil {
+				return nil, fmt.Errorf("failed to resolve header %s of webhook %d: %w", key, i, err)
+			}
+		}
+		if webhook.Template != "" {
+			h.template, err = template.New(cmp.Or(url, webhook.Command)).Funcs(template.FuncMap{"json": toJSON}).Parse(webhook.Template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template of webhook %d: %w", i, err)
+			}
+		}
+		d.hooks = append(d.hooks, h)
+	}
+	return d, nil
+}
+
+// toJSON renders a value as JSON in templates, quoting and escaping strings.
+func toJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// Send posts the event to the webhooks subscribed to it, in the background.
+// Deliveries outlive ctx, see Close.
+func (d *Dispatcher) Send(ctx context.Context, event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, h := range d.hooks {
+		if len(h.events) > 0 && !slices.Contains(h.events, event.Type) {
+			continue
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			if err := d.deliver(ctx, h, event); err != nil {
+				slog.Error("Failed to deliver webhook", "event", event.Type, "session_id", event.SessionID, "error", err)
+			}
+		}()
+	}
+}
+
+// Close waits for the deliveries in progress, for up to timeout, once the
+// contexts of the watches are done.
+func (d *Dispatcher) Close(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("Gave up waiting for webhook deliveries")
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, h hook, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if h.template != nil {
+		var buf bytes.Buffer
+		if err := h.template.Execute(&buf, event); err != nil {
+			return fmt.Errorf("failed to render template: %w", err)
+		}
+		if !json.Valid(buf.Bytes()) {
+			return errors.New("the template didn't render valid JSON")
+		}
+		body = buf.Bytes()
+	}
+	if h.command != "" {
+		return d.run(ctx, h, event, body)
+	}
+
+	backoff := d.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(ctx, h, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= h.retries {
+			return err
+		}
+		slog.Debug("Retrying webhook", "event", event.Type, "attempt", attempt+1, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// run runs the command of the hook on the event, once.
+func (d *Dispatcher) run(ctx context.Context, h hook, event Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, d.client.Timeout)
+	defer cancel()
+	sh := shell.NewShell(&shell.Options{
+		WorkingDir: d.workingDir,
+		Env: append(os.Environ(),
+			"CRUSH_EVENT="+event.Type,
+			"CRUSH_EVENT_TEXT="+event.Text,
+			"CRUSH_EVENT_JSON="+string(body),
+		),
+	})
+	_, stderr, err := sh.Exec(ctx, h.command)
+	if err != nil {
+		return fmt.Errorf("command failed: %w: %s", err, stderr)
+	}
+	return nil
+}
+
+// post posts the body once, reporting whether a failure is worth retrying.
+func (d *Dispatcher) post(ctx context.Context, h hook, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range h.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		// Other client errors won't go away by retrying.
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver records the bodies posted to it, answering with the statuses in
+// order, then 204.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header)
+	status := http.StatusNoContent
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func TestDispatcher_TaskCompleted(t *testing.T) {
+	r, url := newReceiver(t)
+	t.Setenv("WEBHOOK_AUTHORIZATION", "Bearer secret")
+	h := agenttest.New(t, agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Webhooks = []config.Webhook{{
+			URL:      url,
+			Events:   []string{config.WebhookTaskCompleted},
+			Headers:  map[string]string{"Authorization": "$WEBHOOK_AUTHORIZATION"},
+			Template: `{"text": {{json .Text}}, "result": {{json .Result}}}`,
+		}}
+	}))
+	h.Large.SetFallback(agenttest.Text("All tests pass."))
+
+	d, err := New(h.Config, h.Sessions, h.Messages)
+	require.NoError(t, err)
+	d.Watch(t.Context(), h.Permissions)
+	d.WatchAgent(t.Context(), h.Agent)
+
+	_, err = h.Run("Fix the tests")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(r.received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.JSONEq(t, `{"text": "Session Test session finished its task", "result": "All tests pass."}`, r.received()[0])
+	require.Equal(t, "Bearer secret", r.headers[0].Get("Authorization"))
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		want     int
+	}{
+		{name: "delivered", want: 1},
+		{name: "server errors", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}, want: 3},
+		{name: "rate limited", statuses: []int{http.StatusTooManyRequests}, want: 2},
+		{name: "client error", statuses: []int{http.StatusBadRequest}, want: 1},
+		{name: "gives up", statuses: []int{500, 500, 500, 500, 500}, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, url := newReceiver(t, tt.statuses...)
+			cfg := config.New(t.TempDir())
+			cfg.Webhooks = []config.Webhook{{URL: url}}
+			d, err := New(cfg, nil, nil)
+			require.NoError(t, err)
+			d.backoff = time.Millisecond
+
+			d.Send(t.Context(), Event{Type: config.WebhookError, Text: "Session failed", Error: "overloaded"})
+			d.Close(5 * time.Second)
+			require.Len(t, r.received(), tt.want)
+
+			var event Event
+			require.NoError(t, json.Unmarshal([]byte(r.received()[0]), &event))
+			require.Equal(t, config.WebhookError, event.Type)
+			require.Equal(t, "overloaded", event.Error)
+		})
+	}
+}
+
+func TestDispatcher_BudgetThreshold(t *testing.T) {
+	r, url := newReceiver(t)
+	h := agenttest.New(t, agenttest.WithConfig(func(cfg *config.Config) {
+		cfg.Options.Budget = &config.Budget{SessionTokens: 1000, WarnAt: 0.5}
+		cfg.Webhooks = []config.Webhook{{
+			URL:      url,
+			Events:   []string{config.WebhookBudgetThreshold},
+			Template: `{{json .Budget}}`,
+		}}
+	}))
+	d, err := New(h.Config, h.Sessions, h.Messages)
+	require.NoError(t, err)
+	d.Watch(t.Context(), h.Permissions)
+
+	spend := func(tokens int64) {
+		require.NoError(t, h.Sessions.RecordUsage(t.Context(), session.Usage{SessionID: h.Session.ID, InputTokens: tokens}))
+		_, err := h.Sessions.Save(t.Context(), h.Session)
+		require.NoError(t, err)
+	}
+	spend(300)
+	spend(300)
+	require.Eventually(t, func() bool {
+		return len(r.received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	spend(100)
+	spend(400)
+	require.Eventually(t, func() bool {
+		return len(r.received()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	spend(100)
+	time.Sleep(100 * time.Millisecond)
+
+	received := r.received()
+	require.Len(t, received, 2)
+	require.JSONEq(t, `{"limit": "session_tokens", "used": 600, "max": 1000, "percent": 60}`, received[0])
+	require.JSONEq(t, `{"limit": "session_tokens", "used": 1100, "max": 1000, "percent": 110}`, received[1])
+}
+
+func TestNew_InvalidTemplate(t *testing.T) {
+	cfg := config.New(t.TempDir())
+	cfg.Webhooks = []config.Webhook{{URL: "http://localhost", Template: `{"text": {{json .Text}`}}
+	_, err := New(cfg, nil, nil)
+	require.ErrorContains(t, err, "invalid template of webhook 0")
+}
+
+func TestDispatcher_Command(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.New(dir)
+	cfg.Webhooks = []config.Webhook{{Command: `printf '%s %s' "$CRUSH_EVENT" "$CRUSH_EVENT_TEXT" > event.txt && printf '%s' "$CRUSH_EVENT_JSON" > event.json`}}
+	d, err := New(cfg, nil, nil)
+	require.NoError(t, err)
+
+	d.Send(t.Context(), Event{Type: config.WebhookError, Text: "Session failed", Error: "overloaded"})
+	d.Close(5 * time.Second)
+
+	text, err := os.ReadFile(filepath.Join(dir, "event.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "error Session failed", string(text))
+	body, err := os.ReadFile(filepath.Join(dir, "event.json"))
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	require.Equal(t, "overloaded", event.Error)
+}
+
+func TestNew_URLOrCommand(t *testing.T) {
+	cfg := config.New(t.TempDir())
+	cfg.Webhooks = []config.Webhook{{Events: []string{config.WebhookError}}}
+	_, err := New(cfg, nil, nil)
+	require.ErrorContains(t, err, "webhook 0 has no URL or command")
+
+	cfg.Webhooks = []config.Webhook{{URL: "http://localhost", Command: "true"}}
+	_, err = New(cfg, nil, nil)
+	require.ErrorContains(t, err, "webhook 0 has both a URL and a command")
+}
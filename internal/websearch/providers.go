@@ -0,0 +1,121 @@
+package websearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// brave searches with the Brave Search API.
+type brave struct {
+	apiKey  string
+	baseURL string
+}
+
+func (b *brave) Name() string {
+	return "Brave"
+}
+
+func (b *brave) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(count)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.baseURL+"/res/v1/web/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Subscription-Token", b.apiKey)
+
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(resp.Web.Results))
+	for _, r := range resp.Web.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Description})
+	}
+	return truncate(results, count), nil
+}
+
+// searxng searches with the JSON API of a SearXNG instance, which must have
+// the json format enabled.
+type searxng struct {
+	baseURL string
+}
+
+func (s *searxng) Name() string {
+	return "SearXNG"
+}
+
+func (s *searxng) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	params := url.Values{"q": {query}, "format": {"json"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return truncate(results, count), nil
+}
+
+// tavily searches with the Tavily API.
+type tavily struct {
+	apiKey  string
+	baseURL string
+}
+
+func (t *tavily) Name() string {
+	return "Tavily"
+}
+
+func (t *tavily) Search(ctx context.Context, query string, count int) ([]Result, error) {
+	body, err := json.Marshal(map[string]any{"query": query, "max_results": count})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.baseURL+"/search", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	var resp struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := doJSON(req, &resp); err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(resp.Results))
+	for _, r := range resp.Results {
+		results = append(results, Result{Title: r.Title, URL: r.URL, Snippet: r.Content})
+	}
+	return truncate(results, count), nil
+}
@@ -0,0 +1,95 @@
+// Package websearch queries the search engines backing the websearch tool.
+package websearch
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// DefaultMaxResults is the number of results of a search unless configured
+// otherwise.
+const DefaultMaxResults = 5
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Result is a page found by a search.
+type Result struct {
+	Title   string
+	URL     string
+	Snippet string
+}
+
+// Provider is a search engine.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query string, count int) ([]Result, error)
+}
+
+// New returns the search engine of the configuration, resolving its API key.
+func New(cfg config.WebSearch, resolver config.VariableResolver) (Provider, error) {
+	apiKey := cfg.APIKey
+	if apiKey != "" && resolver != nil {
+		resolved, err := resolver.ResolveValue(apiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve api_key of %s: %w", cfg.Provider, err)
+		}
+		apiKey = resolved
+	}
+	baseURL := strings.TrimSuffix(cfg.URL, "/")
+
+	switch cfg.Provider {
+	case config.WebSearchBrave:
+		return &brave{
+			apiKey:  apiKey,
+			baseURL: cmp.Or(baseURL, "https://api.search.brave.com"),
+		}, nil
+	case config.WebSearchSearXNG:
+		if baseURL == "" {
+			return nil, fmt.Errorf("searxng needs the url of an instance")
+		}
+		return &searxng{baseURL: baseURL}, nil
+	case config.WebSearchTavily:
+		return &tavily{
+			apiKey:  apiKey,
+			baseURL: cmp.Or(baseURL, "https://api.tavily.com"),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown web search provider %q", cfg.Provider)
+	}
+}
+
+// doJSON sends the request and decodes the JSON response into v.
+func doJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "crush/1.0")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to search: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("search failed with status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode search results: %w", err)
+	}
+	return nil
+}
+
+// truncate keeps the first count results.
+func truncate(results []Result, count int) []Result {
+	if count > 0 && len(results) > count {
+		return results[:count]
+	}
+	return results
+}
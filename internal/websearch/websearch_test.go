@@ -0,0 +1,84 @@
+package websearch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrave(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/res/v1/web/search", r.URL.Path)
+		require.Equal(t, "bubbletea", r.URL.Query().Get("q"))
+		require.Equal(t, "secret", r.Header.Get("X-Subscription-Token"))
+		w.Write([]byte(`{"web":{"results":[
+			{"title":"Bubble Tea","url":"https://github.com/charmbracelet/bubbletea","description":"A TUI framework"},
+			{"title":"Docs","url":"https://pkg.go.dev/github.com/charmbracelet/bubbletea","description":"API"}
+		]}}`))
+	}))
+	defer server.Close()
+
+	resolver := config.NewEnvironmentVariableResolver(env.NewFromMap(map[string]string{"BRAVE_API_KEY": "secret"}))
+	provider, err := New(config.WebSearch{Provider: config.WebSearchBrave, APIKey: "$BRAVE_API_KEY", URL: server.URL}, resolver)
+	require.NoError(t, err)
+	results, err := provider.Search(t.Context(), "bubbletea", 1)
+	require.NoError(t, err)
+	require.Equal(t, []Result{{Title: "Bubble Tea", URL: "https://github.com/charmbracelet/bubbletea", Snippet: "A TUI framework"}}, results)
+}
+
+func TestSearXNG(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/search", r.URL.Path)
+		require.Equal(t, "json", r.URL.Query().Get("format"))
+		w.Write([]byte(`{"results":[{"title":"Lip Gloss","url":"https://github.com/charmbracelet/lipgloss","content":"Style definitions"}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := New(config.WebSearch{Provider: config.WebSearchSearXNG, URL: server.URL + "/"}, nil)
+	require.NoError(t, err)
+	results, err := provider.Search(t.Context(), "lipgloss", 5)
+	require.NoError(t, err)
+	require.Equal(t, []Result{{Title: "Lip Gloss", URL: "https://github.com/charmbracelet/lipgloss", Snippet: "Style definitions"}}, results)
+
+	_, err = New(config.WebSearch{Provider: config.WebSearchSearXNG}, nil)
+	require.Error(t, err)
+}
+
+func TestTavily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var body struct {
+			Query      string `json:"query"`
+			MaxResults int    `json:"max_results"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "glamour", body.Query)
+		require.Equal(t, 3, body.MaxResults)
+		w.Write([]byte(`{"results":[{"title":"Glamour","url":"https://github.com/charmbracelet/glamour","content":"Markdown rendering"}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := New(config.WebSearch{Provider: config.WebSearchTavily, APIKey: "secret", URL: server.URL}, nil)
+	require.NoError(t, err)
+	results, err := provider.Search(t.Context(), "glamour", 3)
+	require.NoError(t, err)
+	require.Equal(t, "https://github.com/charmbracelet/glamour", results[0].URL)
+}
+
+func TestSearch_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	provider, err := New(config.WebSearch{Provider: config.WebSearchBrave, APIKey: "wrong", URL: server.URL}, nil)
+	require.NoError(t, err)
+	_, err = provider.Search(t.Context(), "crush", 5)
+	require.ErrorContains(t, err, "status code 401: invalid token")
+}
@@ -11,9 +11,16 @@ import (
 
 	"github.com/charmbracelet/crush/internal/cmd"
 	"github.com/charmbracelet/crush/internal/log"
+	"github.com/charmbracelet/crush/internal/shell"
 )
 
 func main() {
+	// The landlock sandbox runs Crush to restrict itself before running
+	// the commands of the bash tool.
+	if len(os.Args) > 1 && os.Args[1] == shell.SandboxHelperArg {
+		shell.RunSandboxHelper(os.Args[2:])
+	}
+
 	defer log.RecoverPanic("main", func() {
 		slog.Error("Application terminated due to unhandled panic")
 	})
@@ -0,0 +1,299 @@
+// Package crush embeds the Crush coding agent in Go programs. It runs the
+// same agent as the crush binary, with the same config files, providers and
+// sessions database, without the TUI.
+//
+//	c, err := crush.New(ctx, crush.Options{WorkingDir: dir})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	sess, err := c.CreateSession(ctx, "Fix the tests")
+//	if err != nil {
+//		return err
+//	}
+//	events, err := c.Prompt(ctx, sess.ID, "Make the tests pass")
+//	if err != nil {
+//		return err
+//	}
+//	for event := range events {
+//		if event.Type == crush.EventContentDelta {
+//			fmt.Print(event.Content)
+//		}
+//	}
+//
+// The config is global to the process, so only one Crush can be open at a
+// time.
+package crush
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/tools"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// ErrSessionBusy is returned when prompting a session that is already
+// handling a prompt.
+var ErrSessionBusy = agent.ErrSessionBusy
+
+// Options configures the embedded agent.
+type Options struct {
+	// WorkingDir is the directory the agent works in, the current directory
+	// by default. The project config is read from it.
+	WorkingDir string
+	// Profile is the config profile to use, if any.
+	Profile string
+	// Debug enables debug logging in the Crush log file.
+	Debug bool
+	// Tools are added to the built-in tools of the agent.
+	Tools []Tool
+	// ApprovePermission decides whether the tool calls that need permission
+	// are allowed. It is called from a separate goroutine and the tool call
+	// waits for it. When nil, all tool calls are allowed.
+	ApprovePermission func(PermissionRequest) bool
+}
+
+// Crush is an embedded coding agent.
+type Crush struct {
+	app    *app.App
+	store  db.Store
+	cancel context.CancelFunc
+}
+
+// New loads the config of the working directory, opens the sessions
+// database and starts the agent. Close it once done.
+func New(ctx context.Context, opts Options) (*Crush, error) {
+	workingDir := opts.WorkingDir
+	if workingDir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current working directory: %w", err)
+		}
+		workingDir = cwd
+	}
+
+	cfg, err := config.Init(workingDir, opts.Profile, opts.Debug)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Permissions == nil {
+		cfg.Permissions = &config.Permissions{}
+	}
+	cfg.Permissions.SkipRequests = opts.ApprovePermission == nil
+
+	storage := cfg.Options.Storage
+	store, err := db.OpenStore(ctx, storage.Backend, storage.DSN, cfg.Options.DataDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	agentTools := make([]tools.BaseTool, 0, len(opts.Tools))
+	for _, t := range opts.Tools {
+		agentTools = append(agentTools, &tool{t})
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	a, err := app.New(ctx, store, cfg, agent.WithTools(agentTools...))
+	if err != nil {
+		cancel()
+		store.Close()
+		return nil, err
+	}
+	c := &Crush{app: a, store: store, cancel: cancel}
+	if a.CoderAgent == nil {
+		c.Close()
+		return nil, errors.New("no model configured, run crush to set up a provider")
+	}
+
+	if opts.ApprovePermission != nil {
+		go c.handlePermissions(ctx, opts.ApprovePermission)
+	}
+	return c, nil
+}
+
+// Close stops the agent, cancelling the running prompts, and closes the
+// sessions database.
+func (c *Crush) Close() error {
+	c.app.Shutdown()
+	c.cancel()
+	return c.store.Close()
+}
+
+// handlePermissions answers the permission requests of the tool calls.
+func (c *Crush) handlePermissions(ctx context.Context, approve func(PermissionRequest) bool) {
+	for event := range c.app.Permissions.Subscribe(ctx) {
+		request := event.Payload
+		if approve(newPermissionRequest(request)) {
+			c.app.Permissions.Grant(request)
+		} else {
+			c.app.Permissions.Deny(request)
+		}
+	}
+}
+
+// Session is a conversation with the agent.
+type Session struct {
+	ID               string
+	Title            string
+	MessageCount     int64
+	PromptTokens     int64
+	CompletionTokens int64
+	// Cost is the cost of the session in dollars, CacheCost the part of it
+	// spent on the prompt cache and ReasoningCost the part spent on the
+	// reasoning of the models.
+	Cost          float64
+	CacheCost     float64
+	ReasoningCost float64
+	CreatedAt     int64
+	UpdatedAt     int64
+}
+
+func newSession(s session.Session) Session {
+	return Session{
+		ID:               s.ID,
+		Title:            s.Title,
+		MessageCount:     s.MessageCount,
+		PromptTokens:     s.PromptTokens,
+		CompletionTokens: s.CompletionTokens,
+		Cost:             s.Cost,
+		CacheCost:        s.CacheCost,
+		ReasoningCost:    s.ReasoningCost,
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+}
+
+// CreateSession creates a new session. The title is replaced with a
+// generated one after the first prompt.
+func (c *Crush) CreateSession(ctx context.Context, title string) (Session, error) {
+	s, err := c.app.Sessions.Create(ctx, title)
+	if err != nil {
+		return Session{}, err
+	}
+	return newSession(s), nil
+}
+
+// Session returns the session with the ID.
+func (c *Crush) Session(ctx context.Context, id string) (Session, error) {
+	s, err := c.app.Sessions.Get(ctx, id)
+	if err != nil {
+		return Session{}, err
+	}
+	return newSession(s), nil
+}
+
+// Sessions returns all the sessions, including the ones of the crush binary.
+func (c *Crush) Sessions(ctx context.Context) ([]Session, error) {
+	list, err := c.app.Sessions.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(list))
+	for _, s := range list {
+		sessions = append(sessions, newSession(s))
+	}
+	return sessions, nil
+}
+
+// Prompt sends the prompt in the session and streams the events of the
+// agent until it's done answering. The last event is EventDone. Cancelling
+// the context cancels the prompt.
+func (c *Crush) Prompt(ctx context.Context, sessionID, prompt string) (<-chan Event, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	loopEvents := c.app.CoderAgent.SubscribeLoop(ctx)
+	done, err := c.app.CoderAgent.Run(ctx, sessionID, prompt)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer cancel()
+		defer close(events)
+		send := func(loopEvent agent.LoopEvent) bool {
+			if loopEvent.SessionID != sessionID {
+				return true
+			}
+			event, ok := newEvent(loopEvent)
+			if !ok {
+				return true
+			}
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case event := <-loopEvents:
+				if !send(event.Payload) {
+					return
+				}
+			case result := <-done:
+				// The last events may still be buffered.
+				for drained := false; !drained; {
+					select {
+					case event := <-loopEvents:
+						drained = !send(event.Payload)
+					default:
+						drained = true
+					}
+				}
+				select {
+				case events <- newDoneEvent(sessionID, result):
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// Cancel cancels the prompt running in the session, if any.
+func (c *Crush) Cancel(sessionID string) {
+	c.app.CoderAgent.Cancel(sessionID)
+}
+
+// IsBusy reports whether the session is handling a prompt.
+func (c *Crush) IsBusy(sessionID string) bool {
+	return c.app.CoderAgent.IsSessionBusy(sessionID)
+}
+
+// PermissionRequest is a tool call asking for permission.
+type PermissionRequest struct {
+	ID          string
+	SessionID   string
+	ToolCallID  string
+	ToolName    string
+	Description string
+	Action      string
+	Path        string
+	// DestructiveReason explains why the call is destructive, if it is.
+	DestructiveReason string
+}
+
+func newPermissionRequest(p permission.PermissionRequest) PermissionRequest {
+	return PermissionRequest{
+		ID:                p.ID,
+		SessionID:         p.SessionID,
+		ToolCallID:        p.ToolCallID,
+		ToolName:          p.ToolName,
+		Description:       p.Description,
+		Action:            p.Action,
+		Path:              p.Path,
+		DestructiveReason: p.DestructiveReason,
+	}
+}
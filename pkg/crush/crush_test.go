@@ -0,0 +1,57 @@
+package crush
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrushPrompt(t *testing.T) {
+	h := agenttest.New(t)
+	c := &Crush{app: &app.App{
+		Sessions:    h.Sessions,
+		Messages:    h.Messages,
+		Permissions: h.Permissions,
+		CoderAgent:  h.Agent,
+	}}
+	h.Large.Script(
+		agenttest.ToolCall("write", map[string]string{"file_path": h.Path("hello.txt"), "content": "hello\n"}),
+		agenttest.Step{Thinking: "The file is written.", Content: "Done."},
+	)
+
+	sess, err := c.CreateSession(t.Context(), "Write a file")
+	require.NoError(t, err)
+	events, err := c.Prompt(t.Context(), sess.ID, "Write hello.txt")
+	require.NoError(t, err)
+
+	var types []EventType
+	var last Event
+	for event := range events {
+		require.Equal(t, sess.ID, event.SessionID)
+		types = append(types, event.Type)
+		last = event
+	}
+	require.Equal(t, []EventType{EventToolCall, EventToolResult, EventThinkingDelta, EventContentDelta, EventDone}, types)
+	require.NoError(t, last.Err)
+	require.Equal(t, "Done.", last.Content)
+	require.Equal(t, "end_turn", last.FinishReason)
+	require.Equal(t, "hello\n", h.ReadFile("hello.txt"))
+	require.False(t, c.IsBusy(sess.ID))
+}
+
+func TestCrushPromptError(t *testing.T) {
+	h := agenttest.New(t)
+	c := &Crush{app: &app.App{Sessions: h.Sessions, CoderAgent: h.Agent}}
+
+	events, err := c.Prompt(t.Context(), h.Session.ID, "Hi")
+	require.NoError(t, err)
+
+	var last Event
+	for event := range events {
+		last = event
+	}
+	require.Equal(t, EventDone, last.Type)
+	require.ErrorContains(t, last.Err, "no scripted response left")
+}
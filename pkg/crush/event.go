@@ -0,0 +1,96 @@
+package crush
+
+import (
+	"github.com/charmbracelet/crush/internal/llm/agent"
+)
+
+// EventType is the type of an event of a prompt.
+type EventType string
+
+const (
+	// EventContentDelta streams the text of the response.
+	EventContentDelta EventType = "content_delta"
+	// EventThinkingDelta streams the reasoning of the model.
+	EventThinkingDelta EventType = "thinking_delta"
+	// EventToolCall is sent when the agent runs a tool.
+	EventToolCall EventType = "tool_call"
+	// EventToolResult is sent when a tool is done.
+	EventToolResult EventType = "tool_result"
+	// EventPermissionRequest is sent when a tool call waits for permission.
+	EventPermissionRequest EventType = "permission_request"
+	// EventDone is the last event, with the final response or the error.
+	EventDone EventType = "done"
+)
+
+// Event is an event of a prompt.
+type Event struct {
+	Type      EventType
+	SessionID string
+
+	// The delta for EventContentDelta and EventThinkingDelta, the final
+	// response for EventDone.
+	Content string
+
+	ToolCall   *ToolCall
+	ToolResult *ToolResult
+	Permission *PermissionRequest
+
+	// FinishReason is why the model stopped, such as "end_turn", and Err
+	// the error the prompt failed with, for EventDone.
+	FinishReason string
+	Err          error
+}
+
+// ToolCall is a call of a tool by the model.
+type ToolCall struct {
+	ID   string
+	Name string
+	// Input is the JSON input of the call.
+	Input string
+}
+
+// ToolResult is the output of a tool call.
+type ToolResult struct {
+	ToolCallID string
+	Content    string
+	IsError    bool
+}
+
+// newEvent converts the event of the agent loop. It reports false for the
+// events that aren't part of the API.
+func newEvent(e agent.LoopEvent) (Event, bool) {
+	event := Event{SessionID: e.SessionID}
+	switch e.Type {
+	case agent.LoopEventStreamDelta:
+		event.Type, event.Content = EventContentDelta, e.Content
+		if e.Thinking != "" {
+			event.Type, event.Content = EventThinkingDelta, e.Thinking
+		}
+	case agent.LoopEventToolRequested:
+		event.Type = EventToolCall
+		event.ToolCall = &ToolCall{ID: e.ToolCall.ID, Name: e.ToolCall.Name, Input: e.ToolCall.Input}
+	case agent.LoopEventToolFinished:
+		event.Type = EventToolResult
+		event.ToolResult = &ToolResult{
+			ToolCallID: e.ToolResult.ToolCallID,
+			Content:    e.ToolResult.Content,
+			IsError:    e.ToolResult.IsError,
+		}
+	case agent.LoopEventAwaitingPermission:
+		event.Type = EventPermissionRequest
+		request := newPermissionRequest(*e.Permission)
+		event.Permission = &request
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+func newDoneEvent(sessionID string, result agent.AgentEvent) Event {
+	event := Event{Type: EventDone, SessionID: sessionID, Err: result.Error}
+	if result.Error == nil {
+		event.Content = result.Message.Content().String()
+		event.FinishReason = string(result.Message.FinishReason())
+	}
+	return event
+}
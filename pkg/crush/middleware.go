@@ -0,0 +1,36 @@
+package crush
+
+import (
+	"net/http"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+)
+
+// Middleware wraps the transport of the requests to a provider, to sign them,
+// add headers, or account for them.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// MiddlewareFactory creates the middleware of the provider with the ID from
+// its options in the config.
+type MiddlewareFactory func(providerID string, options map[string]any) (Middleware, error)
+
+// RoundTripperFunc is a function used as a transport.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RegisterMiddleware makes the middleware usable by name in the middleware
+// of the config, as the built-in ones, which it takes precedence over.
+// Register it before New.
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	provider.RegisterMiddleware(name, func(cfg config.ProviderConfig, options map[string]any, _ config.VariableResolver) (provider.Middleware, error) {
+		mw, err := factory(cfg.ID, options)
+		if err != nil {
+			return nil, err
+		}
+		return provider.Middleware(mw), nil
+	})
+}
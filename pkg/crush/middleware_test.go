@@ -0,0 +1,39 @@
+package crush
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/charmbracelet/crush/internal/llm/provider"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterMiddleware(t *testing.T) {
+	h := agenttest.New(t)
+	RegisterMiddleware("team", func(providerID string, options map[string]any) (Middleware, error) {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req = req.Clone(req.Context())
+				req.Header.Set("X-Team", options["team"].(string)+"@"+providerID)
+				return next.RoundTrip(req)
+			})
+		}, nil
+	})
+	h.Config.Options.Middleware = []config.Middleware{{Name: "team", Options: map[string]any{"team": "search"}}}
+
+	var team string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		team = r.Header.Get("X-Team")
+	}))
+	defer srv.Close()
+
+	client, err := provider.NewHTTPClient(config.ProviderConfig{ID: "gateway"})
+	require.NoError(t, err)
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, "search@gateway", team)
+}
@@ -0,0 +1,57 @@
+package crush
+
+import (
+	"context"
+
+	"github.com/charmbracelet/crush/internal/llm/tools"
+)
+
+// Tool is a tool the agent can call, provided by the embedding program.
+// Custom tools don't ask for permission, Run decides what is allowed.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters are the JSON schemas of the properties of the input
+	// object, keyed by property name.
+	Parameters map[string]any
+	Required   []string
+	// Run runs the call and returns the output sent to the model. Errors
+	// are reported to the model as failed calls.
+	Run func(ctx context.Context, call ToolCall) (string, error)
+}
+
+// SessionID returns the ID of the session of the tool call run with ctx.
+func SessionID(ctx context.Context) string {
+	sessionID, _ := tools.GetContextValues(ctx)
+	return sessionID
+}
+
+// tool adapts a Tool to the agent tools.
+type tool struct {
+	Tool
+}
+
+func (t *tool) Name() string {
+	return t.Tool.Name
+}
+
+func (t *tool) Info() tools.ToolInfo {
+	parameters := t.Parameters
+	if parameters == nil {
+		parameters = map[string]any{}
+	}
+	return tools.ToolInfo{
+		Name:        t.Tool.Name,
+		Description: t.Description,
+		Parameters:  parameters,
+		Required:    t.Required,
+	}
+}
+
+func (t *tool) Run(ctx context.Context, params tools.ToolCall) (tools.ToolResponse, error) {
+	output, err := t.Tool.Run(ctx, ToolCall{ID: params.ID, Name: params.Name, Input: params.Input})
+	if err != nil {
+		return tools.NewTextErrorResponse(err.Error()), nil
+	}
+	return tools.NewTextResponse(output), nil
+}
@@ -0,0 +1,61 @@
+package crush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/llm/agent"
+	"github.com/charmbracelet/crush/internal/llm/agent/agenttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTool(t *testing.T) {
+	var sessionID string
+	weather := Tool{
+		Name:        "weather",
+		Description: "Get the weather of a city",
+		Parameters: map[string]any{
+			"city": map[string]any{"type": "string"},
+		},
+		Required: []string{"city"},
+		Run: func(ctx context.Context, call ToolCall) (string, error) {
+			sessionID = SessionID(ctx)
+			var input struct {
+				City string `json:"city"`
+			}
+			if err := json.Unmarshal([]byte(call.Input), &input); err != nil {
+				return "", err
+			}
+			if input.City != "Paris" {
+				return "", errors.New("unknown city")
+			}
+			return "Sunny in Paris", nil
+		},
+	}
+
+	h := agenttest.New(t, agenttest.WithAgentOptions(agent.WithTools(&tool{weather})))
+	h.Large.Script(
+		agenttest.ToolCall("weather", map[string]string{"city": "Paris"}),
+		agenttest.ToolCall("weather", map[string]string{"city": "Atlantis"}),
+		agenttest.Text("It's sunny in Paris."),
+	)
+
+	turn, err := h.Run("What's the weather in Paris and Atlantis?")
+	require.NoError(t, err)
+	require.Equal(t, h.Session.ID, sessionID)
+	require.Contains(t, h.Large.Requests()[0].Tools, "weather")
+
+	var results []ToolResult
+	for _, loopEvent := range turn.Events {
+		event, ok := newEvent(loopEvent)
+		if ok && event.Type == EventToolResult {
+			results = append(results, *event.ToolResult)
+		}
+	}
+	require.Equal(t, []ToolResult{
+		{ToolCallID: "call_1", Content: "Sunny in Paris"},
+		{ToolCallID: "call_2", Content: "unknown city", IsError: true},
+	}, results)
+}